@@ -0,0 +1,34 @@
+// Client for the Fire PMAAS API. schema.d.ts is generated from api/openapi.yaml by
+// `npm run generate`; this file adds authentication and the versioned base URL.
+import createClient from "openapi-fetch";
+import type { components, paths } from "./schema";
+
+export type { components, paths };
+export type CustomReport = components["schemas"]["CustomReport"];
+export type ReportData = components["schemas"]["ReportData"];
+export type ReportTemplate = components["schemas"]["ReportTemplate"];
+export type QuickStat = components["schemas"]["QuickStat"];
+export type StatHistory = components["schemas"]["StatHistory"];
+
+/** Version of the API the client speaks */
+export const API_VERSION = 1;
+
+export interface ClientOptions {
+  /** API key sent in X-API-Key, for integration routes */
+  apiKey?: string;
+  /** Cookies are sent with same-origin requests when running in the browser */
+  credentials?: RequestCredentials;
+}
+
+/** Creates a client for the server at baseUrl, e.g. "https://pmaas.example.com" */
+export function createPmaasClient(baseUrl: string, options: ClientOptions = {}) {
+  const headers: Record<string, string> = {};
+  if (options.apiKey) {
+    headers["X-API-Key"] = options.apiKey;
+  }
+  return createClient<paths>({
+    baseUrl: `${baseUrl.replace(/\/+$/, "")}/api/v${API_VERSION}`,
+    headers,
+    credentials: options.credentials ?? "same-origin",
+  });
+}
@@ -0,0 +1,72 @@
+// Command loadtest seeds a synthetic dataset and checks endpoint latency against performance budgets.
+//
+//	go run ./cmd/loadtest -seed                         # seed 10k units and 1M payments
+//	go run ./cmd/loadtest -url http://localhost:8000 -token $ID_TOKEN
+//
+// Seeding uses the POSTGRES_* environment variables of the server. The exit status is 1 when any
+// target is over budget.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/loadtest"
+)
+
+func main() {
+	seed := flag.Bool("seed", false, "Seed the synthetic dataset before loading")
+	clear := flag.Bool("clear", false, "Delete the synthetic dataset and exit")
+	units := flag.Int("units", loadtest.DefaultSeedConfig.Units, "Units to seed")
+	payments := flag.Int("payments-per-lease", loadtest.DefaultSeedConfig.PaymentsPerLease, "Monthly payments to seed per lease")
+	baseURL := flag.String("url", "", "Server to load, e.g. http://localhost:8000; omit to only seed")
+	token := flag.String("token", os.Getenv("LOADTEST_ID_TOKEN"), "ID token of the user making requests")
+	reportID := flag.Int("report-id", 0, "Saved report to execute, in addition to report previews")
+	concurrency := flag.Int("concurrency", 10, "Parallel clients")
+	requests := flag.Int("requests", 200, "Requests per target")
+	flag.Parse()
+
+	if *seed || *clear {
+		conn, err := sql.Open("postgres", db.DataSourceName())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer conn.Close()
+
+		if *clear {
+			if err := loadtest.ClearSeed(conn); err != nil {
+				log.Fatal(err)
+			}
+			log.Println("Load test data deleted")
+			return
+		}
+
+		cfg := loadtest.DefaultSeedConfig
+		cfg.Units, cfg.PaymentsPerLease = *units, *payments
+		if err := loadtest.Seed(conn, cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *baseURL == "" {
+		return
+	}
+
+	opts := loadtest.Options{BaseURL: *baseURL, IDToken: *token, Concurrency: *concurrency, Requests: *requests}
+	overBudget := false
+	for _, target := range loadtest.DefaultTargets(*reportID) {
+		result := loadtest.Run(context.Background(), opts, target)
+		fmt.Println(result)
+		if !result.WithinBudget() {
+			overBudget = true
+		}
+	}
+	if overBudget {
+		os.Exit(1)
+	}
+}
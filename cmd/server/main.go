@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"log"      // For logging errors and other information
+	"log/slog" // Structured logging
 	"net/http" // For creating HTTP servers
 	"os"       // For accessing environment variables
+	"strings"  // For formatting doctor output
 	"time"     // For time-related operations, like sleeping
 
 	// Third-party libraries
@@ -13,59 +16,230 @@ import (
 	"github.com/golang-migrate/migrate/v4"                              // Database migration tool
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"          // PostgreSQL driver for migrate
 	_ "github.com/golang-migrate/migrate/v4/source/file"                // File source driver for migrate
+	"github.com/greenbrown932/fire-pmaas/pkg/ach"                       // ACH rent debits
+	"github.com/greenbrown932/fire-pmaas/pkg/announcements"             // Tenant announcement notifications
 	"github.com/greenbrown932/fire-pmaas/pkg/api"                       // API route definitions
+	"github.com/greenbrown932/fire-pmaas/pkg/associations"              // HOA/condo dues posting
+	"github.com/greenbrown932/fire-pmaas/pkg/backup"                    // Scheduled database backups
+	"github.com/greenbrown932/fire-pmaas/pkg/changefeed"                // Change feed retention
+	"github.com/greenbrown932/fire-pmaas/pkg/collections"               // Delinquent rent collections
+	"github.com/greenbrown932/fire-pmaas/pkg/config"                    // Startup configuration
 	"github.com/greenbrown932/fire-pmaas/pkg/db"                        // Database initialization and connection
+	"github.com/greenbrown932/fire-pmaas/pkg/digest"                    // Manager KPI digest emails
+	"github.com/greenbrown932/fire-pmaas/pkg/doctor"                    // Startup self-checks
+	"github.com/greenbrown932/fire-pmaas/pkg/escalation"                // Lease rent escalations
+	"github.com/greenbrown932/fire-pmaas/pkg/exports"                   // Stored report export expiry
+	"github.com/greenbrown932/fire-pmaas/pkg/imports"                   // Background CSV imports
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"                   // Structured logging with log scrubbing
+	"github.com/greenbrown932/fire-pmaas/pkg/masking"                   // Personal data masking for staging copies
+	"github.com/greenbrown932/fire-pmaas/pkg/metrics"                   // KPI export to Prometheus
 	firemiddleware "github.com/greenbrown932/fire-pmaas/pkg/middleware" // Custom middleware
+	"github.com/greenbrown932/fire-pmaas/pkg/models"                    // Bootstrap specs
+	"github.com/greenbrown932/fire-pmaas/pkg/oncall"                    // Emergency on-call routing
+	"github.com/greenbrown932/fire-pmaas/pkg/outbox"                    // Reliable webhook/notification delivery
+	"github.com/greenbrown932/fire-pmaas/pkg/preventive"                // Preventive maintenance scheduling
+	"github.com/greenbrown932/fire-pmaas/pkg/renewals"                  // Lease renewal offers
+	"github.com/greenbrown932/fire-pmaas/pkg/reportplugin"              // Custom report types
+	"github.com/greenbrown932/fire-pmaas/pkg/roleexpiry"                // Temporary role assignment expiry
+	"github.com/greenbrown932/fire-pmaas/pkg/scheduler"                 // Scheduled report runs
+	"github.com/greenbrown932/fire-pmaas/pkg/screening"                 // Tenant screening results
+	"github.com/greenbrown932/fire-pmaas/pkg/sla"                       // Maintenance SLA alerts
+	"github.com/greenbrown932/fire-pmaas/pkg/stathistory"               // Daily quick stat snapshots
+	"github.com/greenbrown932/fire-pmaas/pkg/status"                    // Public status page component health
+	"github.com/greenbrown932/fire-pmaas/pkg/subscriptions"             // Report subscription emails
+	"github.com/greenbrown932/fire-pmaas/pkg/syndication"               // Listing site vacancy feeds
+	"github.com/greenbrown932/fire-pmaas/pkg/turnover"                  // Unit turnover tracking
+	"github.com/greenbrown932/fire-pmaas/pkg/usage"                     // Utility usage anomaly detection
 )
 
 func main() {
+	// "fire-pmaas doctor" runs the configuration self-checks and exits
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctor())
+	}
+
+	// "fire-pmaas bootstrap <spec.yaml>" applies a bootstrap spec and exits
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap" {
+		os.Exit(runBootstrap(os.Args[2:]))
+	}
+
+	// "fire-pmaas mask --confirm <database>" masks personal data in a non-production copy and exits
+	if len(os.Args) > 1 && os.Args[1] == "mask" {
+		os.Exit(runMask(os.Args[2:]))
+	}
+
+	// Log at LOG_LEVEL in LOG_FORMAT, redacting tokens, secrets and email addresses from here on
+	logging.Setup(os.Stderr)
+
+	// Load the OIDC client and cookie settings, refusing to start with an invalid configuration
+	cfg, err := config.Init()
+	if err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+
 	runMigrations()
 	db.InitDB()
 
+	// Load custom report types built as Go plugins
+	if dir := os.Getenv("REPORT_PLUGIN_DIR"); dir != "" {
+		loaded, err := reportplugin.LoadDir(dir)
+		if err != nil {
+			logging.Fatal("Failed to load report plugins", "dir", dir, "error", err)
+		}
+		slog.Info("Loaded report plugins", "dir", dir, "count", len(loaded))
+	}
+
 	// Initialize OIDC provider with retry mechanism
 	maxRetries := 10
 	retryInterval := 3 * time.Second
 	for i := 0; i < maxRetries; i++ {
-		err := firemiddleware.InitOIDC()
+		err := firemiddleware.InitOIDC(cfg)
 		if err == nil {
 			break
 		}
-		log.Printf("Failed to initialize OIDC (attempt %d): %v", i+1, err)
+		slog.Warn("Failed to initialize OIDC", "attempt", i+1, "error", err)
 		time.Sleep(retryInterval)
 	}
-	if err := firemiddleware.InitOIDC(); err != nil {
-		log.Fatalf("Failed to initialize OIDC after multiple retries: %v", err)
+	if err := firemiddleware.InitOIDC(cfg); err != nil {
+		logging.Fatal("Failed to initialize OIDC after multiple retries", "error", err)
+	}
+
+	// Start the outbox dispatcher for asynchronous webhook/notification delivery
+	dispatcher := outbox.NewDispatcher()
+	dispatcher.Register("webhook", outbox.NewWebhookHandler(10*time.Second))
+	email, err := outbox.NewEmailHandlerFromEnv()
+	if err != nil {
+		logging.Fatal("Invalid mail configuration", "error", err)
+	}
+	if email != nil {
+		dispatcher.Register("email", email)
+	}
+	if sms := outbox.NewSMSHandlerFromEnv(); sms != nil {
+		dispatcher.Register("sms", sms)
+	}
+	status.Default.Go(context.Background(), "outbox-dispatcher", dispatcher.Run)
+
+	// Alert managers about maintenance requests approaching or breaching their SLA
+	status.Default.Go(context.Background(), "maintenance-sla", sla.NewMonitor().Run)
+
+	// Generate maintenance requests for preventive maintenance plans as they come due
+	status.Default.Go(context.Background(), "preventive-maintenance", preventive.NewScheduler().Run)
+
+	// Send emergency maintenance requests to the property's on-call contact
+	status.Default.Go(context.Background(), "on-call-routing", oncall.NewRouter().Run)
+
+	// Compute lease rent escalations, notify tenants and managers, and apply the new rent when due
+	status.Default.Go(context.Background(), "rent-escalations", escalation.NewScheduler().Run)
+
+	// Flag abnormal utility consumption (potential leaks) and open maintenance requests
+	status.Default.Go(context.Background(), "utility-usage", usage.NewDetector().Run)
+
+	// Take scheduled database backups when a backup method is configured
+	status.Default.Go(context.Background(), "backups", backup.NewRunnerFromEnv().Run)
+
+	// Process queued CSV imports in the background
+	status.Default.Go(context.Background(), "imports", imports.NewRunner().Run)
+
+	// Schedule and submit monthly ACH rent debits under tenants' mandates
+	status.Default.Go(context.Background(), "ach-debits", ach.NewRunner().Run)
+
+	// Fetch tenant screening results the provider has not pushed by webhook
+	status.Default.Go(context.Background(), "tenant-screening", screening.NewPoller().Run)
+
+	// Regenerate vacancy feeds for the enabled listing sites
+	status.Default.Go(context.Background(), "listing-syndication", syndication.NewPublisher().Run)
+
+	// Email and text scheduled announcements to tenants when they are published
+	status.Default.Go(context.Background(), "announcements", announcements.NewSender().Run)
+
+	// Post association dues to owners' ledgers as they fall due
+	status.Default.Go(context.Background(), "association-dues", associations.NewPoster().Run)
+
+	// Snapshot the dashboard quick stats daily for trend sparklines
+	status.Default.Go(context.Background(), "quick-stat-history", stathistory.NewRecorder().Run)
+
+	// Email managers their weekly or monthly KPI digest
+	status.Default.Go(context.Background(), "kpi-digest", digest.NewSender().Run)
+
+	// Remove temporary role assignments once they expire
+	status.Default.Go(context.Background(), "role-expiry", roleexpiry.NewExpirer().Run)
+
+	// Delete stored report output past its organization's retention or storage budget
+	status.Default.Go(context.Background(), "export-expiry", exports.NewExpirer().Run)
+
+	// Delete change feed entries older than the feed's retention
+	status.Default.Go(context.Background(), "change-feed-pruning", changefeed.NewPruner().Run)
+
+	// Send renewal offers ahead of lease expiry and expire the ones tenants did not answer
+	status.Default.Go(context.Background(), "renewal-offers", renewals.NewOfferer().Run)
+
+	// Remind delinquent tenants and issue formal notices as their rent stays past due
+	status.Default.Go(context.Background(), "collections", collections.NewRunner().Run)
+
+	// Open unit turnovers as leases end and close them when the next lease starts
+	status.Default.Go(context.Background(), "unit-turnovers", turnover.NewTracker().Run)
+
+	// Email subscribers the reports they subscribed to in their chosen format and frequency
+	status.Default.Go(context.Background(), "report-subscriptions", subscriptions.NewDeliverer(api.RenderReportExport).Run)
+
+	// Run reports on their own schedules and store the output with each execution
+	status.Default.Go(context.Background(), "scheduled-reports", scheduler.NewScheduler(api.RenderReportExport).Run)
+
+	// Write the API calls counted in memory to the organizations' usage counters
+	status.Default.Go(context.Background(), "api-call-metering", firemiddleware.DefaultAPICallMeter.Run)
+
+	// Push business KPIs to a Prometheus Pushgateway when one is configured
+	if exporter := metrics.NewKPIExporterFromEnv(); exporter != nil {
+		status.Default.Go(context.Background(), "kpi-exporter", exporter.Run)
+	}
+
+	// Check the API, database, workers and notification channels for the public status page
+	status.Default.AddCheck("api", status.GroupCore, status.APICheck)
+	status.Default.AddCheck("database", status.GroupCore, status.DatabaseCheck)
+	for _, channel := range dispatcher.Channels() {
+		status.Default.AddCheck(channel, status.GroupIntegrations, status.OutboxChannelCheck(channel))
 	}
+	go status.Default.Run(context.Background())
 
 	r := chi.NewRouter()
-	r.Use(chimiddleware.Logger)    // Log API requests
-	r.Use(chimiddleware.Recoverer) // Recover from panics
+	r.Use(firemiddleware.RequestLogger)    // Tag requests with an ID and log them with tokens scrubbed
+	r.Use(chimiddleware.Recoverer)         // Recover from panics
+	r.Use(firemiddleware.Compress)         // Gzip/deflate large JSON, CSV and HTML responses
+	r.Use(firemiddleware.LimitRequestBody) // Refuse bodies over 1MB unless the route allows more
+	r.Use(firemiddleware.APIVersioning)    // Serve /api/v1/ routes and deprecate unversioned ones
 
 	api.RegisterRoutes(r)
 
 	if err := http.ListenAndServe(":8000", r); err != nil {
-		log.Fatalf("Error starting server: %v", err)
+		logging.Fatal("Error starting server", "error", err)
 	}
 
 }
 
 func runMigrations() {
-	postgresHost := os.Getenv("POSTGRES_HOST")
-	postgresPort := os.Getenv("POSTGRES_PORT")
-	postgresUser := os.Getenv("POSTGRES_USER")
-	postgresPassword := os.Getenv("POSTGRES_PASSWORD")
-	postgresDb := os.Getenv("POSTGRES_DB")
+	// Path to migrations is relative to the Docker container's WORKDIR
+	migrationsPath := "file://db/migrations"
+	var databaseURL string
 
-	if postgresHost == "" || postgresPort == "" || postgresUser == "" || postgresPassword == "" || postgresDb == "" {
-		log.Println("Database environment variables not set, skipping migrations.")
-		return
-	}
+	if os.Getenv("DB_DRIVER") == "sqlite" {
+		// SQLite deployments use their own dialect-specific migrations
+		migrationsPath = "file://db/migrations/sqlite"
+		databaseURL = "sqlite://" + db.SQLitePath()
+	} else {
+		postgresHost := os.Getenv("POSTGRES_HOST")
+		postgresPort := os.Getenv("POSTGRES_PORT")
+		postgresUser := os.Getenv("POSTGRES_USER")
+		postgresPassword := os.Getenv("POSTGRES_PASSWORD")
+		postgresDb := os.Getenv("POSTGRES_DB")
 
-	databaseURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		postgresUser, postgresPassword, postgresHost, postgresPort, postgresDb)
+		if postgresHost == "" || postgresPort == "" || postgresUser == "" || postgresPassword == "" || postgresDb == "" {
+			slog.Warn("Database environment variables not set, skipping migrations")
+			return
+		}
 
-	// Path to migrations is relative to the Docker container's WORKDIR
-	migrationsPath := "file://db/migrations"
+		databaseURL = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+			postgresUser, postgresPassword, postgresHost, postgresPort, postgresDb)
+	}
 
 	var m *migrate.Migrate
 	var err error
@@ -76,18 +250,129 @@ func runMigrations() {
 		if err == nil {
 			break
 		}
-		log.Printf("Failed to connect to database for migration (attempt %d): %v", i+1, err)
+		slog.Warn("Failed to connect to database for migration", "attempt", i+1, "error", err)
 		time.Sleep(3 * time.Second)
 	}
 
 	if err != nil {
-		log.Fatalf("Could not initialize migrate instance: %v", err)
+		logging.Fatal("Could not initialize migrate instance", "error", err)
 	}
 
-	log.Println("Running database migrations...")
+	slog.Info("Running database migrations")
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Fatalf("An error occurred while running migrations: %v", err)
+		logging.Fatal("An error occurred while running migrations", "error", err)
+	}
+
+	slog.Info("Database migrations finished successfully")
+}
+
+// runBootstrap migrates the database, applies the bootstrap spec at args[0] and prints what changed
+func runBootstrap(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: fire-pmaas bootstrap <spec.yaml|spec.json>")
+		return 2
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read bootstrap spec: %v\n", err)
+		return 1
+	}
+	spec, err := models.ParseBootstrapSpec(data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	runMigrations()
+	db.InitDB()
+
+	result, err := models.ApplyBootstrap(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to apply bootstrap spec: %v\n", err)
+		return 1
+	}
+
+	for _, change := range result.Changes {
+		fmt.Printf("[%-7s] %-15s %s\n", change.Action, change.Kind, change.Name)
+	}
+	fmt.Printf("\nOrganization %d, admin user %d.\n", result.OrganizationID, result.AdminUserID)
+	return 0
+}
+
+// runMask masks personal data in the configured database in place, using the default masking
+// rules or those of a config file. It rewrites the database it connects to, so it asks for the
+// database's name to be confirmed.
+func runMask(args []string) int {
+	flags := flag.NewFlagSet("mask", flag.ContinueOnError)
+	configPath := flags.String("config", "", "Masking config (YAML or JSON); the default rules when omitted")
+	confirm := flags.String("confirm", "", "Name of the database to rewrite, to confirm it is not production")
+	if err := flags.Parse(args); err != nil {
+		return 2
 	}
 
-	log.Println("Database migrations finished successfully.")
+	database := os.Getenv("POSTGRES_DB")
+	if db.CurrentDialect.Name() == "sqlite" {
+		database = db.SQLitePath()
+	}
+	if *confirm == "" || *confirm != database {
+		fmt.Fprintf(os.Stderr, "usage: fire-pmaas mask [--config masking.yaml] --confirm %s\n", database)
+		fmt.Fprintln(os.Stderr, "Masking rewrites the database in place. Run it on a copy, never production.")
+		return 2
+	}
+
+	masker, err := masking.NewMasker(os.Getenv("DATA_MASKING_KEY"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "DATA_MASKING_KEY must be set to the secret masked values are derived from")
+		return 1
+	}
+
+	cfg := masking.DefaultConfig
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read masking config: %v\n", err)
+			return 1
+		}
+		parsed, err := masking.ParseConfig(data)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		cfg = *parsed
+	}
+
+	runMigrations()
+	db.InitDB()
+
+	results, err := masking.Apply(db.DB, &cfg, masker)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to mask database: %v\n", err)
+		return 1
+	}
+	for _, result := range results {
+		if result.Deleted > 0 {
+			fmt.Printf("[deleted] %-30s %d rows\n", result.Table, result.Deleted)
+		} else {
+			fmt.Printf("[masked ] %-30s %d rows\n", result.Table, result.Masked)
+		}
+	}
+	fmt.Println("\nMasking complete. Sign-in accounts were masked too; apply a bootstrap spec to add an admin.")
+	return 0
+}
+
+// runDoctor prints the result of every self-check and returns a non-zero exit code on failure
+func runDoctor() int {
+	report := doctor.Run(context.Background())
+
+	for _, check := range report.Checks {
+		fmt.Printf("[%-7s] %-15s %s\n", strings.ToUpper(check.Status), check.Name, check.Message)
+	}
+
+	if !report.Healthy {
+		fmt.Println("\nOne or more checks failed.")
+		return 1
+	}
+	fmt.Println("\nAll checks passed.")
+	return 0
 }
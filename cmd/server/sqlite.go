@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package main
+
+// Register the SQLite migration driver for single-binary deployments (DB_DRIVER=sqlite).
+import _ "github.com/golang-migrate/migrate/v4/database/sqlite"
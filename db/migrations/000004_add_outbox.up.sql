@@ -0,0 +1,21 @@
+-- Outbox table for reliable webhook and notification delivery.
+-- Rows are written in the same transaction as the domain change that produced
+-- them and are delivered asynchronously by the outbox dispatcher.
+CREATE TABLE outbox_messages (
+    id SERIAL PRIMARY KEY,
+    channel VARCHAR(50) NOT NULL, -- 'webhook', 'email', etc.
+    event_type VARCHAR(100) NOT NULL, -- e.g. 'user.registered', 'report.completed'
+    destination TEXT NOT NULL, -- Webhook URL or email address
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'processing', 'delivered', 'dead'
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 8,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_messages_pending ON outbox_messages(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_outbox_messages_status ON outbox_messages(status);
@@ -0,0 +1,4 @@
+ALTER TABLE payments DROP COLUMN IF EXISTS adjustment_reason;
+ALTER TABLE payments DROP COLUMN IF EXISTS adjusts_payment_id;
+
+DROP TABLE IF EXISTS accounting_periods;
@@ -0,0 +1 @@
+ALTER TABLE report_executions DROP COLUMN IF EXISTS result_snapshot;
@@ -0,0 +1,6 @@
+-- Date the tenant gave notice to vacate; used by the vacancy forecast report
+ALTER TABLE leases ADD COLUMN notice_given_date DATE;
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Vacancy Forecast', 'Projected occupied, on-notice and vacant units over the coming months', 'operational',
+ '{"data_source": "leases", "report_type": "vacancy_forecast", "group_by": "month", "metrics": ["occupied_units", "notice_units", "vacant_units"], "charts": ["area"]}', true);
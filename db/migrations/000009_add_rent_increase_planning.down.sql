@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS rent_increase_proposals;
+DROP TABLE IF EXISTS rent_increase_rules;
+
+ALTER TABLE property_units DROP COLUMN IF EXISTS market_rent;
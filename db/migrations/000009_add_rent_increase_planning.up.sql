@@ -0,0 +1,34 @@
+-- Current market rent per unit, maintained by managers
+ALTER TABLE property_units ADD COLUMN market_rent DECIMAL(10, 2);
+
+-- Caps applied when proposing rent increases. A row without property_id is the
+-- default; a property row overrides it (e.g. for local rent-control rules).
+CREATE TABLE rent_increase_rules (
+    id SERIAL PRIMARY KEY,
+    property_id INT UNIQUE REFERENCES properties(id) ON DELETE CASCADE,
+    jurisdiction VARCHAR(100), -- e.g. 'Oregon SB 608', 'City of Los Angeles RSO'
+    max_percent_per_year DECIMAL(5, 2), -- Maximum increase as a percentage of current rent
+    max_amount DECIMAL(10, 2), -- Maximum increase in currency per renewal
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Proposed increases awaiting manager review, and their outcome
+CREATE TABLE rent_increase_proposals (
+    id SERIAL PRIMARY KEY,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    current_rent DECIMAL(10, 2) NOT NULL,
+    market_rent DECIMAL(10, 2),
+    proposed_rent DECIMAL(10, 2) NOT NULL,
+    reason TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'approved', 'rejected', 'applied'
+    reviewed_by INT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    renewal_lease_id INT REFERENCES leases(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_rent_increase_proposals_status ON rent_increase_proposals(status);
+CREATE INDEX idx_rent_increase_proposals_lease_id ON rent_increase_proposals(lease_id);
+
+INSERT INTO rent_increase_rules (property_id, jurisdiction, max_percent_per_year) VALUES (NULL, 'Default', 10.00);
@@ -0,0 +1,5 @@
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS sla_alert_level;
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS resolved_at;
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS responded_at;
+
+DROP TABLE IF EXISTS maintenance_sla_policies;
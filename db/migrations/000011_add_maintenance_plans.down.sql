@@ -0,0 +1,4 @@
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS due_date;
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS plan_id;
+
+DROP TABLE IF EXISTS maintenance_plans;
@@ -0,0 +1,10 @@
+DELETE FROM maintenance_sla_policies WHERE priority = 'emergency';
+
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS oncall_user_id;
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS oncall_notified_at;
+
+ALTER TABLE properties DROP COLUMN IF EXISTS emergency_contact_email;
+ALTER TABLE properties DROP COLUMN IF EXISTS emergency_contact_phone;
+ALTER TABLE properties DROP COLUMN IF EXISTS emergency_contact_name;
+
+DROP TABLE IF EXISTS oncall_shifts;
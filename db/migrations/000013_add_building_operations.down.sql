@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS visitor_logs;
+DROP TABLE IF EXISTS visitor_authorizations;
+DROP TABLE IF EXISTS packages;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS utility_anomalies;
+DROP TABLE IF EXISTS meter_readings;
@@ -0,0 +1,28 @@
+-- Periodic utility consumption per unit, e.g. daily water meter reads
+CREATE TABLE meter_readings (
+    id SERIAL PRIMARY KEY,
+    unit_id INT NOT NULL REFERENCES property_units(id) ON DELETE CASCADE,
+    utility_type VARCHAR(20) NOT NULL, -- 'water', 'electric' or 'gas'
+    reading_date DATE NOT NULL,
+    consumption DECIMAL(12, 3) NOT NULL, -- Usage for the period ending on reading_date
+    uom VARCHAR(20), -- e.g. 'gal', 'kWh', 'therm'
+    evaluated_at TIMESTAMPTZ, -- Set once the anomaly detector has checked this reading
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (unit_id, utility_type, reading_date)
+);
+
+CREATE INDEX idx_meter_readings_evaluated ON meter_readings(evaluated_at);
+
+-- Abnormal consumption flagged against a rolling baseline
+CREATE TABLE utility_anomalies (
+    id SERIAL PRIMARY KEY,
+    reading_id INT UNIQUE NOT NULL REFERENCES meter_readings(id) ON DELETE CASCADE,
+    unit_id INT NOT NULL REFERENCES property_units(id) ON DELETE CASCADE,
+    utility_type VARCHAR(20) NOT NULL,
+    consumption DECIMAL(12, 3) NOT NULL,
+    baseline_mean DECIMAL(12, 3) NOT NULL,
+    baseline_stddev DECIMAL(12, 3) NOT NULL,
+    z_score DECIMAL(8, 2) NOT NULL,
+    maintenance_request_id INT REFERENCES maintenance_requests(id) ON DELETE SET NULL,
+    detected_at TIMESTAMPTZ DEFAULT NOW()
+);
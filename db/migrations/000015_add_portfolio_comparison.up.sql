@@ -0,0 +1,6 @@
+-- Actual cost of completed maintenance; used for cost-per-unit comparisons
+ALTER TABLE maintenance_requests ADD COLUMN actual_cost DECIMAL(10, 2);
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Portfolio Comparison', 'Selected properties side by side across occupancy, revenue, maintenance cost and delinquency, with rankings', 'operational',
+ '{"data_source": "properties", "report_type": "portfolio_comparison", "group_by": "property", "metrics": ["occupancy", "revenue_per_unit", "maintenance_cost_per_unit", "delinquency"], "charts": ["radar", "bar"]}', true);
@@ -0,0 +1,7 @@
+DELETE FROM report_templates WHERE name = 'Deposit Compliance' AND is_system = true;
+
+DROP TABLE IF EXISTS deposit_rules;
+
+ALTER TABLE leases DROP COLUMN deposit_escrow_account;
+ALTER TABLE leases DROP COLUMN deposit_received_date;
+ALTER TABLE leases DROP COLUMN security_deposit;
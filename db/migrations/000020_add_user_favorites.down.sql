@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_recent_items;
+DROP TABLE IF EXISTS user_favorites;
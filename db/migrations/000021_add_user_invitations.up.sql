@@ -0,0 +1,14 @@
+-- Signup invitations for users created by an administrator (e.g. bulk import). Only a hash of
+-- the token is stored; the plaintext token is emailed to the invitee.
+CREATE TABLE user_invitations (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by INT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_user_invitations_user ON user_invitations(user_id);
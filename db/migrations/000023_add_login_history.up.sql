@@ -0,0 +1,17 @@
+-- Every sign-in attempt through the OIDC callback, successful or not
+CREATE TABLE login_history (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE, -- NULL when the identity could not be resolved
+    identity VARCHAR(255), -- Email or username from the identity provider, when known
+    success BOOLEAN NOT NULL,
+    failure_reason TEXT,
+    ip_address VARCHAR(64),
+    network VARCHAR(64), -- IP prefix (/24 or /48) used as a coarse location
+    user_agent TEXT,
+    mfa_used BOOLEAN NOT NULL DEFAULT FALSE,
+    new_device BOOLEAN NOT NULL DEFAULT FALSE,
+    new_location BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_history_user ON login_history(user_id, created_at);
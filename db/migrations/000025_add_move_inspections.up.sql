@@ -0,0 +1,37 @@
+-- Move-in and move-out condition checklists, one of each per lease
+CREATE TABLE lease_inspections (
+    id SERIAL PRIMARY KEY,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    inspection_type VARCHAR(20) NOT NULL, -- 'move_in' or 'move_out'
+    inspection_date DATE NOT NULL,
+    notes TEXT,
+    inspected_by INT REFERENCES users(id) ON DELETE SET NULL,
+    completed_at TIMESTAMPTZ, -- Checklist is locked once completed
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (lease_id, inspection_type)
+);
+
+-- Condition of each item on a checklist, matched between move-in and move-out by area and item
+CREATE TABLE lease_inspection_items (
+    id SERIAL PRIMARY KEY,
+    inspection_id INT NOT NULL REFERENCES lease_inspections(id) ON DELETE CASCADE,
+    area VARCHAR(100) NOT NULL, -- e.g. 'Kitchen', 'Bedroom 1'
+    item VARCHAR(100) NOT NULL, -- e.g. 'Walls', 'Refrigerator'
+    condition VARCHAR(20) NOT NULL, -- 'excellent', 'good', 'fair', 'poor', 'damaged' or 'missing'
+    notes TEXT,
+    UNIQUE (inspection_id, area, item)
+);
+
+-- Itemized move-out charges deducted from the lease's security deposit
+CREATE TABLE lease_damage_charges (
+    id SERIAL PRIMARY KEY,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    inspection_item_id INT REFERENCES lease_inspection_items(id) ON DELETE SET NULL, -- Move-out item the charge is for
+    description TEXT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_lease_damage_charges_lease ON lease_damage_charges(lease_id);
@@ -0,0 +1,19 @@
+-- Photo galleries for properties and their units. Each gallery (the property itself, or one unit)
+-- has its own ordering and at most one cover photo.
+CREATE TABLE property_photos (
+    id SERIAL PRIMARY KEY,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    unit_id INT REFERENCES property_units(id) ON DELETE CASCADE, -- NULL for the property gallery
+    caption TEXT,
+    position INT NOT NULL DEFAULT 0, -- Display order within the gallery
+    is_cover BOOLEAN NOT NULL DEFAULT FALSE,
+    width INT NOT NULL, -- Dimensions of the full variant
+    height INT NOT NULL,
+    full_url TEXT NOT NULL,
+    medium_url TEXT NOT NULL,
+    thumbnail_url TEXT NOT NULL,
+    uploaded_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_property_photos_gallery ON property_photos(property_id, unit_id, position);
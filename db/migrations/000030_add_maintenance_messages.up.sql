@@ -0,0 +1,41 @@
+-- Message threads on maintenance requests between the reporting tenant, managers and any
+-- vendors added to the request
+CREATE TABLE maintenance_request_participants (
+    request_id INT NOT NULL REFERENCES maintenance_requests(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'vendor' CHECK (role IN ('vendor')),
+    added_by INT REFERENCES users(id) ON DELETE SET NULL,
+    added_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (request_id, user_id)
+);
+
+CREATE TABLE maintenance_messages (
+    id SERIAL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES maintenance_requests(id) ON DELETE CASCADE,
+    author_id INT REFERENCES users(id) ON DELETE SET NULL,
+    author_role VARCHAR(20) NOT NULL CHECK (author_role IN ('tenant', 'manager', 'vendor')),
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_maintenance_messages_request ON maintenance_messages(request_id, id);
+
+CREATE TABLE maintenance_message_attachments (
+    id SERIAL PRIMARY KEY,
+    message_id INT NOT NULL REFERENCES maintenance_messages(id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes INT NOT NULL,
+    url TEXT NOT NULL -- Served through the attachment download endpoint, which checks access
+);
+
+CREATE INDEX idx_maintenance_message_attachments_message ON maintenance_message_attachments(message_id);
+
+-- Read receipts: the newest message each user has read in a thread
+CREATE TABLE maintenance_thread_reads (
+    request_id INT NOT NULL REFERENCES maintenance_requests(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read_message_id INT NOT NULL,
+    read_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (request_id, user_id)
+);
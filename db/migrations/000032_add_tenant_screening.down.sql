@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS screening_requests;
+DROP TABLE IF EXISTS rental_applications;
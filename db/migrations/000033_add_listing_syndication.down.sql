@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS syndication_channels;
+DROP TABLE IF EXISTS unit_listings;
@@ -0,0 +1,30 @@
+-- Units listed for rent and the listing sites their vacancy feeds are syndicated to
+CREATE TABLE unit_listings (
+    unit_id INT PRIMARY KEY REFERENCES property_units(id) ON DELETE CASCADE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    asking_rent DECIMAL(10, 2) NOT NULL CHECK (asking_rent > 0),
+    available_date DATE,
+    headline VARCHAR(200),
+    description TEXT,
+    square_feet INT,
+    contact_name VARCHAR(200),
+    contact_email VARCHAR(255),
+    contact_phone VARCHAR(50),
+    listed_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE syndication_channels (
+    name VARCHAR(50) PRIMARY KEY,
+    format VARCHAR(50) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    feed_url TEXT,
+    listing_count INT NOT NULL DEFAULT 0,
+    last_generated_at TIMESTAMPTZ,
+    last_error TEXT,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+INSERT INTO syndication_channels (name, format) VALUES
+    ('zillow', 'hotpads'),
+    ('apartments_com', 'mits');
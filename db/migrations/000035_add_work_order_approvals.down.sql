@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS maintenance_cost_approvals;
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS approval_status;
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS estimated_cost;
+DROP TABLE IF EXISTS maintenance_approval_policies;
@@ -0,0 +1,35 @@
+-- Per-property cost approval policy for maintenance work orders. Estimates at or below the
+-- auto-approval limit proceed without asking; larger ones are sent to the approver by email.
+CREATE TABLE maintenance_approval_policies (
+    property_id INT PRIMARY KEY REFERENCES properties(id) ON DELETE CASCADE,
+    auto_approve_limit DECIMAL(10, 2) NOT NULL CHECK (auto_approve_limit >= 0),
+    approver_name VARCHAR(255),
+    approver_email VARCHAR(255) NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Latest estimate and where it stands: not_required (no policy), auto_approved, pending,
+-- approved or declined. NULL until a cost is estimated.
+ALTER TABLE maintenance_requests ADD COLUMN estimated_cost DECIMAL(10, 2);
+ALTER TABLE maintenance_requests ADD COLUMN approval_status VARCHAR(20);
+
+-- Every estimate submitted for a work order and its approval decision. A new estimate
+-- supersedes a pending one.
+CREATE TABLE maintenance_cost_approvals (
+    id SERIAL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES maintenance_requests(id) ON DELETE CASCADE,
+    estimated_cost DECIMAL(10, 2) NOT NULL CHECK (estimated_cost >= 0),
+    estimate_notes TEXT,
+    auto_approve_limit DECIMAL(10, 2),
+    status VARCHAR(20) NOT NULL
+        CHECK (status IN ('not_required', 'auto_approved', 'pending', 'approved', 'declined', 'superseded')),
+    approver_email VARCHAR(255),
+    token_hash VARCHAR(64) UNIQUE,
+    expires_at TIMESTAMPTZ,
+    decided_at TIMESTAMPTZ,
+    decision_notes TEXT,
+    requested_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_maintenance_cost_approvals_request_id ON maintenance_cost_approvals(request_id);
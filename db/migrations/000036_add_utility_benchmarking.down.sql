@@ -0,0 +1,4 @@
+DELETE FROM report_templates WHERE name = 'Utility Benchmarking' AND is_system = true;
+DROP TABLE IF EXISTS utility_benchmarks;
+DROP TABLE IF EXISTS utility_expenses;
+ALTER TABLE properties DROP COLUMN IF EXISTS square_feet;
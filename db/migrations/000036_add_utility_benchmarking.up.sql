@@ -0,0 +1,33 @@
+-- Gross floor area, used to normalise utility costs across properties
+ALTER TABLE properties ADD COLUMN square_feet INT CHECK (square_feet > 0);
+
+-- Utility bills paid for a property, covering period_start through period_end
+CREATE TABLE utility_expenses (
+    id SERIAL PRIMARY KEY,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    utility_type VARCHAR(20) NOT NULL CHECK (utility_type IN ('water', 'electric', 'gas')),
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount >= 0),
+    vendor VARCHAR(255),
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (period_end >= period_start)
+);
+
+CREATE INDEX idx_utility_expenses_property_period ON utility_expenses(property_id, period_start);
+
+-- Target annual cost per square foot for a utility, for one property type or, with an empty
+-- property_type, every type without its own benchmark
+CREATE TABLE utility_benchmarks (
+    id SERIAL PRIMARY KEY,
+    utility_type VARCHAR(20) NOT NULL CHECK (utility_type IN ('water', 'electric', 'gas')),
+    property_type VARCHAR(50) NOT NULL DEFAULT '',
+    annual_cost_per_sqft DECIMAL(10, 4) NOT NULL CHECK (annual_cost_per_sqft > 0),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (utility_type, property_type)
+);
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Utility Benchmarking', 'Annualized utility cost per square foot by property compared with benchmarks and peers, with outliers highlighted', 'operational',
+ '{"data_source": "utility_expenses", "report_type": "utility_benchmark", "metrics": ["annual_cost_per_sqft", "variance_percent"]}', true);
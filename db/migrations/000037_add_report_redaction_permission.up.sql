@@ -0,0 +1,3 @@
+-- Tenant contact details in report output are masked for roles without this permission
+UPDATE roles SET permissions = array_append(permissions, 'tenants.contact.read')
+WHERE name IN ('admin', 'property_manager') AND NOT ('tenants.contact.read' = ANY(permissions));
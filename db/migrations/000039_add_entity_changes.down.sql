@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS payments_entity_changes ON payments;
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes ON maintenance_requests;
+DROP TRIGGER IF EXISTS leases_entity_changes ON leases;
+DROP TRIGGER IF EXISTS tenants_entity_changes ON tenants;
+DROP TRIGGER IF EXISTS property_units_entity_changes ON property_units;
+DROP TRIGGER IF EXISTS properties_entity_changes ON properties;
+DROP FUNCTION IF EXISTS record_entity_change();
+DROP TABLE IF EXISTS entity_changes;
@@ -0,0 +1,35 @@
+-- Append-only feed of inserts, updates and deletes on core entities, read by the change feed API.
+-- Rows are written by triggers so every write path is captured, including bulk imports.
+CREATE TABLE entity_changes (
+    id BIGSERIAL PRIMARY KEY, -- Cursor for the change feed
+    entity_type VARCHAR(50) NOT NULL, -- e.g. 'property', 'lease', 'payment'
+    entity_id INT NOT NULL,
+    op VARCHAR(10) NOT NULL, -- 'insert', 'update' or 'delete'
+    changed_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_entity_changes_entity_type ON entity_changes(entity_type, id);
+
+CREATE FUNCTION record_entity_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO entity_changes (entity_type, entity_id, op) VALUES (TG_ARGV[0], OLD.id, 'delete');
+        RETURN OLD;
+    END IF;
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES (TG_ARGV[0], NEW.id, lower(TG_OP));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER properties_entity_changes AFTER INSERT OR UPDATE OR DELETE ON properties
+    FOR EACH ROW EXECUTE FUNCTION record_entity_change('property');
+CREATE TRIGGER property_units_entity_changes AFTER INSERT OR UPDATE OR DELETE ON property_units
+    FOR EACH ROW EXECUTE FUNCTION record_entity_change('unit');
+CREATE TRIGGER tenants_entity_changes AFTER INSERT OR UPDATE OR DELETE ON tenants
+    FOR EACH ROW EXECUTE FUNCTION record_entity_change('tenant');
+CREATE TRIGGER leases_entity_changes AFTER INSERT OR UPDATE OR DELETE ON leases
+    FOR EACH ROW EXECUTE FUNCTION record_entity_change('lease');
+CREATE TRIGGER maintenance_requests_entity_changes AFTER INSERT OR UPDATE OR DELETE ON maintenance_requests
+    FOR EACH ROW EXECUTE FUNCTION record_entity_change('maintenance_request');
+CREATE TRIGGER payments_entity_changes AFTER INSERT OR UPDATE OR DELETE ON payments
+    FOR EACH ROW EXECUTE FUNCTION record_entity_change('payment');
@@ -0,0 +1,15 @@
+-- Devices a user chose to trust after passing MFA, so MFA is skipped on them until expires_at.
+-- The device cookie carries the token; only its SHA-256 hash is stored.
+CREATE TABLE trusted_devices (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    last_used_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_trusted_devices_user ON trusted_devices(user_id, expires_at);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS property_group_managers;
+
+DROP INDEX IF EXISTS idx_properties_group;
+ALTER TABLE properties DROP COLUMN IF EXISTS group_id;
+
+DROP TABLE IF EXISTS property_groups;
@@ -0,0 +1,27 @@
+-- Property grouping hierarchy: regions contain portfolios, and portfolios contain properties.
+-- Used for roll-up reporting, filtering and scoping property managers to part of the portfolio.
+CREATE TABLE property_groups (
+    id SERIAL PRIMARY KEY,
+    parent_id INT REFERENCES property_groups(id) ON DELETE RESTRICT,
+    group_type VARCHAR(20) NOT NULL CHECK (group_type IN ('region', 'portfolio')),
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_property_groups_parent ON property_groups(parent_id);
+
+ALTER TABLE properties ADD COLUMN group_id INT REFERENCES property_groups(id) ON DELETE RESTRICT;
+
+CREATE INDEX idx_properties_group ON properties(group_id);
+
+-- Managers assigned to a region or portfolio only see the properties under it
+CREATE TABLE property_group_managers (
+    group_id INT NOT NULL REFERENCES property_groups(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_property_group_managers_user ON property_group_managers(user_id);
@@ -0,0 +1,6 @@
+DELETE FROM report_templates WHERE name = 'Unit Mix' AND is_system = true;
+
+DROP INDEX IF EXISTS idx_property_units_floorplan;
+ALTER TABLE property_units DROP COLUMN IF EXISTS floorplan_id;
+
+DROP TABLE IF EXISTS floorplans;
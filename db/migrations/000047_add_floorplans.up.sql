@@ -0,0 +1,23 @@
+-- Floorplans: the unit types a property offers. A floorplan's market rent applies to its units
+-- that have no market rent of their own.
+CREATE TABLE floorplans (
+    id SERIAL PRIMARY KEY,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL, -- e.g. 'A1', 'Two Bedroom Corner'
+    bedrooms INT NOT NULL DEFAULT 1,
+    bathrooms INT NOT NULL DEFAULT 1,
+    square_feet INT,
+    market_rent DECIMAL(10, 2),
+    description TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (property_id, name)
+);
+
+ALTER TABLE property_units ADD COLUMN floorplan_id INT REFERENCES floorplans(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_property_units_floorplan ON property_units(floorplan_id);
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Unit Mix', 'Unit counts, occupancy and average rent per floorplan', 'property',
+ '{"data_source": "properties", "report_type": "unit_mix", "metrics": ["units", "occupancy", "average_rent"]}', true);
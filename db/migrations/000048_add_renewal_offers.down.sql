@@ -0,0 +1,3 @@
+DELETE FROM report_templates WHERE name = 'Renewals Funnel' AND is_system = true;
+
+DROP TABLE IF EXISTS renewal_offers;
@@ -0,0 +1,5 @@
+-- Day-count convention used to prorate the rent of months a lease only partly covers. It is kept
+-- on the lease so that changing the default does not alter the first and last months of existing
+-- leases.
+ALTER TABLE leases ADD COLUMN proration_convention VARCHAR(20) NOT NULL DEFAULT 'actual_days'
+    CHECK (proration_convention IN ('actual_days', 'thirty_day', 'annual_365'));
@@ -0,0 +1,6 @@
+DELETE FROM report_templates WHERE name = 'Collections Status' AND is_system = true;
+
+DROP TABLE IF EXISTS collection_notices;
+DROP TABLE IF EXISTS collection_promises;
+DROP TABLE IF EXISTS collection_cases;
+DROP TABLE IF EXISTS collection_stages;
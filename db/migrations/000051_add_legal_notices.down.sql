@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS tenant_timeline_events;
+DROP TABLE IF EXISTS legal_notices;
+DROP TABLE IF EXISTS notice_templates;
+
+ALTER TABLE properties DROP COLUMN IF EXISTS jurisdiction;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS owner_documents;
+DROP TABLE IF EXISTS property_ownerships;
+
+DELETE FROM roles WHERE name = 'owner';
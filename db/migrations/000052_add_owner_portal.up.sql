@@ -0,0 +1,31 @@
+-- Property owners get a read-only portal limited to the properties they own
+INSERT INTO roles (name, display_name, description, permissions) VALUES
+('owner', 'Owner', 'View statements, occupancy, large maintenance and documents for owned properties', ARRAY[
+    'owner.statements.read', 'owner.occupancy.read', 'owner.maintenance.read', 'owner.documents.read'
+]);
+
+-- Users who own a property, and their share of its income and expenses
+CREATE TABLE property_ownerships (
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ownership_percent DECIMAL(5, 2) NOT NULL DEFAULT 100 CHECK (ownership_percent > 0 AND ownership_percent <= 100),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (property_id, user_id)
+);
+
+CREATE INDEX idx_property_ownerships_user ON property_ownerships(user_id);
+
+-- Documents shared with a property's owners, e.g. insurance certificates and inspection reports
+CREATE TABLE owner_documents (
+    id SERIAL PRIMARY KEY,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes INT NOT NULL,
+    document_url TEXT NOT NULL,
+    uploaded_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_owner_documents_property ON owner_documents(property_id);
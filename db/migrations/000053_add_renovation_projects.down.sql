@@ -0,0 +1,3 @@
+DELETE FROM report_templates WHERE name = 'Renovation ROI' AND is_system = true;
+
+DROP TABLE IF EXISTS renovation_projects;
@@ -0,0 +1,25 @@
+-- A unit turnover runs from a tenant's move-out to the next move-in. Turnovers are opened when a
+-- lease ends without the tenant renewing, and closed when the unit's next lease starts. Market
+-- rent is captured at move-out to price the rent lost while the unit is vacant.
+CREATE TABLE unit_turnovers (
+    id SERIAL PRIMARY KEY,
+    unit_id INT NOT NULL REFERENCES property_units(id) ON DELETE CASCADE,
+    move_out_lease_id INT REFERENCES leases(id) ON DELETE SET NULL,
+    move_in_lease_id INT REFERENCES leases(id) ON DELETE SET NULL,
+    move_out_date DATE NOT NULL, -- Last day of the ended lease
+    ready_date DATE, -- Make-ready work finished
+    move_in_date DATE, -- First day of the next lease; null while vacant
+    make_ready_cost DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (make_ready_cost >= 0),
+    market_rent DECIMAL(10, 2) NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (unit_id, move_out_date)
+);
+
+CREATE INDEX idx_unit_turnovers_move_out_lease ON unit_turnovers(move_out_lease_id);
+CREATE INDEX idx_unit_turnovers_open ON unit_turnovers(unit_id) WHERE move_in_date IS NULL;
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Unit Turnover', 'Days vacant, make-ready cost and lost rent of each unit turnover', 'operational',
+ '{"data_source": "unit_turnovers", "report_type": "turnover", "metrics": ["days_vacant", "make_ready_cost", "lost_rent"]}', true);
@@ -0,0 +1,14 @@
+-- Default dashboard shown to users of a role. A row without an organization applies to every
+-- organization; a row with one overrides that default for the organization's users.
+CREATE TABLE role_default_dashboards (
+    id SERIAL PRIMARY KEY,
+    role_id INT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    organization_id INT REFERENCES organizations(id) ON DELETE CASCADE,
+    dashboard_id INT NOT NULL REFERENCES analytics_dashboards(id) ON DELETE CASCADE,
+    assigned_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- One global default and at most one override per organization for each role
+CREATE UNIQUE INDEX idx_role_default_dashboards_scope ON role_default_dashboards(role_id, COALESCE(organization_id, 0));
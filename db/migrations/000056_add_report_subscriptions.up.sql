@@ -0,0 +1,18 @@
+-- Users subscribed to a report's runs, delivered by email on their own frequency and in their own
+-- format, independently of the report owner's schedule
+CREATE TABLE report_subscriptions (
+    id SERIAL PRIMARY KEY,
+    report_id INT NOT NULL REFERENCES custom_reports(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    output_format VARCHAR(20) NOT NULL CHECK (output_format IN ('pdf', 'csv', 'excel')),
+    frequency VARCHAR(20) NOT NULL CHECK (frequency IN ('daily', 'weekly', 'monthly')),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run TIMESTAMPTZ NOT NULL,
+    last_run TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (report_id, user_id, output_format)
+);
+
+CREATE INDEX idx_report_subscriptions_next_run ON report_subscriptions(next_run) WHERE is_active;
+CREATE INDEX idx_report_subscriptions_user ON report_subscriptions(user_id);
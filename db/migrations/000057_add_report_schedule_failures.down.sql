@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS report_schedule_failures;
+
+ALTER TABLE report_subscriptions DROP COLUMN IF EXISTS last_failed_at;
+ALTER TABLE report_subscriptions DROP COLUMN IF EXISTS last_error;
+ALTER TABLE report_subscriptions DROP COLUMN IF EXISTS retry_count;
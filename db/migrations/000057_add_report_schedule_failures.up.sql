@@ -0,0 +1,19 @@
+-- Failed attempts of the current run, retried with backoff until the retry limit, and the most
+-- recent error. A schedule is failing while its last failure is newer than its last success.
+ALTER TABLE report_subscriptions ADD COLUMN retry_count INT NOT NULL DEFAULT 0;
+ALTER TABLE report_subscriptions ADD COLUMN last_error TEXT;
+ALTER TABLE report_subscriptions ADD COLUMN last_failed_at TIMESTAMPTZ;
+
+-- Every failed attempt of a scheduled report delivery
+CREATE TABLE report_schedule_failures (
+    id SERIAL PRIMARY KEY,
+    schedule_type VARCHAR(20) NOT NULL, -- 'subscription'
+    schedule_id INT NOT NULL,
+    report_id INT NOT NULL REFERENCES custom_reports(id) ON DELETE CASCADE,
+    attempt INT NOT NULL, -- 1 for the first try of a run
+    error TEXT NOT NULL,
+    gave_up BOOLEAN NOT NULL DEFAULT FALSE, -- The retry limit was reached and the run was skipped
+    failed_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_report_schedule_failures_schedule ON report_schedule_failures(schedule_type, schedule_id, failed_at);
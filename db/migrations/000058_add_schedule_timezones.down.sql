@@ -0,0 +1,4 @@
+ALTER TABLE report_subscriptions DROP COLUMN IF EXISTS timezone;
+ALTER TABLE custom_reports DROP COLUMN IF EXISTS schedule_timezone;
+ALTER TABLE properties DROP COLUMN IF EXISTS timezone;
+ALTER TABLE organizations DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_custom_reports_next_run;
+
+ALTER TABLE custom_reports DROP COLUMN IF EXISTS last_failed_at;
+ALTER TABLE custom_reports DROP COLUMN IF EXISTS last_error;
+ALTER TABLE custom_reports DROP COLUMN IF EXISTS retry_count;
+ALTER TABLE custom_reports DROP COLUMN IF EXISTS next_run;
+ALTER TABLE custom_reports DROP COLUMN IF EXISTS schedule_format;
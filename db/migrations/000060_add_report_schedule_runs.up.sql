@@ -0,0 +1,11 @@
+-- Runs of reports' own schedules. next_run is planned by the scheduler from schedule_cron; each
+-- run stores its output in schedule_format and records the file on its report execution. Failed
+-- runs are retried like subscription deliveries and logged in report_schedule_failures with the
+-- schedule type 'report'.
+ALTER TABLE custom_reports ADD COLUMN schedule_format VARCHAR(20) NOT NULL DEFAULT 'pdf';
+ALTER TABLE custom_reports ADD COLUMN next_run TIMESTAMPTZ;
+ALTER TABLE custom_reports ADD COLUMN retry_count INT NOT NULL DEFAULT 0;
+ALTER TABLE custom_reports ADD COLUMN last_error TEXT;
+ALTER TABLE custom_reports ADD COLUMN last_failed_at TIMESTAMPTZ;
+
+CREATE INDEX idx_custom_reports_next_run ON custom_reports(next_run) WHERE is_scheduled = true;
@@ -0,0 +1,3 @@
+-- Confidentiality level of a report: 'none', 'internal', 'confidential' or 'restricted'. PDF and
+-- Excel exports of reports above 'none' carry a watermark; every export is audited regardless.
+ALTER TABLE custom_reports ADD COLUMN confidentiality VARCHAR(20) NOT NULL DEFAULT 'none';
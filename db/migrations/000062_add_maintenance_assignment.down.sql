@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_maintenance_requests_assigned_to;
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS assigned_at;
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS assigned_to;
@@ -0,0 +1,8 @@
+-- Most rows a report may return to users of a role before they are asked to aggregate it
+-- instead. 0 means unlimited; roles without a row use the built-in default.
+CREATE TABLE report_row_limits (
+    role_id INT PRIMARY KEY REFERENCES roles(id) ON DELETE CASCADE,
+    max_rows INT NOT NULL CHECK (max_rows >= 0),
+    updated_by INT REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
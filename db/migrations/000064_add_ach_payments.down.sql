@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS ach_debits;
+DROP TABLE IF EXISTS lease_fees;
+DROP TABLE IF EXISTS ach_mandates;
+DROP TABLE IF EXISTS ach_bank_accounts;
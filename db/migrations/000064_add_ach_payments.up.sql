@@ -0,0 +1,74 @@
+-- ACH bank-transfer rent payments: tenants' bank accounts linked through the ACH provider, the
+-- debit mandates (standing authorizations) they give for a lease, and the rent debits collected
+-- under them. Only the provider's token for an account and its last four digits are stored.
+CREATE TABLE ach_bank_accounts (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    external_id VARCHAR(255) NOT NULL, -- Provider's token for the account
+    institution_name VARCHAR(255),
+    account_mask VARCHAR(4) NOT NULL,
+    account_type VARCHAR(20) NOT NULL DEFAULT 'checking' CHECK (account_type IN ('checking', 'savings')),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'removed')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (provider, external_id)
+);
+
+CREATE INDEX idx_ach_bank_accounts_tenant_id ON ach_bank_accounts(tenant_id);
+
+CREATE TABLE ach_mandates (
+    id SERIAL PRIMARY KEY,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    bank_account_id INT NOT NULL REFERENCES ach_bank_accounts(id) ON DELETE CASCADE,
+    amount DECIMAL(10, 2) CHECK (amount > 0), -- Fixed debit; NULL debits the rent billed for the month
+    debit_day INT NOT NULL DEFAULT 1 CHECK (debit_day BETWEEN 1 AND 28),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'revoked')),
+    authorization_text TEXT NOT NULL, -- The authorization the tenant agreed to
+    authorized_at TIMESTAMPTZ DEFAULT NOW(),
+    authorized_ip VARCHAR(64),
+    authorized_by INT REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMPTZ,
+    revoked_reason TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- A lease has at most one active mandate
+CREATE UNIQUE INDEX idx_ach_mandates_active_lease ON ach_mandates(lease_id) WHERE status = 'active';
+
+-- Fees charged to a lease, such as the fee for a returned payment
+CREATE TABLE lease_fees (
+    id SERIAL PRIMARY KEY,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    fee_type VARCHAR(30) NOT NULL, -- e.g. 'returned_payment'
+    description TEXT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    fee_date DATE NOT NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_lease_fees_lease_id ON lease_fees(lease_id);
+
+CREATE TABLE ach_debits (
+    id SERIAL PRIMARY KEY,
+    mandate_id INT NOT NULL REFERENCES ach_mandates(id) ON DELETE CASCADE,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    amount DECIMAL(10, 2) NOT NULL,
+    debit_date DATE NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    external_id VARCHAR(255), -- Provider's transfer ID, once submitted
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'submitted', 'settled', 'returned', 'failed')),
+    payment_id INT REFERENCES payments(id) ON DELETE SET NULL, -- Ledger entry for the debit
+    reversal_payment_id INT REFERENCES payments(id) ON DELETE SET NULL, -- Adjusting entry for a return
+    fee_id INT REFERENCES lease_fees(id) ON DELETE SET NULL,
+    return_code VARCHAR(10), -- NACHA return reason code, e.g. 'R01'
+    return_reason TEXT,
+    error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (mandate_id, debit_date)
+);
+
+CREATE UNIQUE INDEX idx_ach_debits_external_id ON ach_debits(provider, external_id);
+CREATE INDEX idx_ach_debits_status ON ach_debits(status);
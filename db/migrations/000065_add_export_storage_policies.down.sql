@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS export_storage_policies;
+ALTER TABLE report_executions DROP COLUMN IF EXISTS file_size;
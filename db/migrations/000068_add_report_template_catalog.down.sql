@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_report_templates_status;
+
+ALTER TABLE report_templates DROP COLUMN IF EXISTS review_note;
+ALTER TABLE report_templates DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE report_templates DROP COLUMN IF EXISTS reviewed_by;
+ALTER TABLE report_templates DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE report_templates DROP COLUMN IF EXISTS source_report_id;
+ALTER TABLE report_templates DROP COLUMN IF EXISTS status;
+ALTER TABLE report_templates DROP COLUMN IF EXISTS install_count;
+ALTER TABLE report_templates DROP COLUMN IF EXISTS sample_data;
+ALTER TABLE report_templates DROP COLUMN IF EXISTS screenshot_url;
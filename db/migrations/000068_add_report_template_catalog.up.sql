@@ -0,0 +1,16 @@
+-- Turns report templates into a shared catalog. Users submit their reports as templates, which
+-- stay pending until an admin publishes or rejects them; system templates are published. A
+-- template carries an optional screenshot and sample output for previews, since the source
+-- report's own output contains its organization's data.
+ALTER TABLE report_templates ADD COLUMN screenshot_url TEXT;
+ALTER TABLE report_templates ADD COLUMN sample_data JSONB;
+ALTER TABLE report_templates ADD COLUMN install_count INT NOT NULL DEFAULT 0;
+ALTER TABLE report_templates ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('pending', 'published', 'rejected'));
+ALTER TABLE report_templates ADD COLUMN source_report_id INT REFERENCES custom_reports(id) ON DELETE SET NULL;
+ALTER TABLE report_templates ADD COLUMN organization_id INT REFERENCES organizations(id) ON DELETE SET NULL;
+ALTER TABLE report_templates ADD COLUMN reviewed_by INT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE report_templates ADD COLUMN reviewed_at TIMESTAMPTZ;
+ALTER TABLE report_templates ADD COLUMN review_note TEXT;
+
+CREATE INDEX idx_report_templates_status ON report_templates(status, category);
@@ -0,0 +1,13 @@
+CREATE OR REPLACE FUNCTION record_entity_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO entity_changes (entity_type, entity_id, op) VALUES (TG_ARGV[0], OLD.id, 'delete');
+        RETURN OLD;
+    END IF;
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES (TG_ARGV[0], NEW.id, lower(TG_OP));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_entity_changes_property;
+ALTER TABLE entity_changes DROP COLUMN IF EXISTS property_id;
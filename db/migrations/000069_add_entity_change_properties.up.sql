@@ -0,0 +1,48 @@
+-- Record the property each change belongs to so the change feed can be limited to the properties
+-- a manager's regions and portfolios cover. Tenants are not tied to one property and stay NULL.
+ALTER TABLE entity_changes ADD COLUMN property_id INT;
+
+CREATE INDEX idx_entity_changes_property ON entity_changes(property_id, id);
+
+CREATE OR REPLACE FUNCTION record_entity_change() RETURNS TRIGGER AS $$
+DECLARE
+    row_data JSONB;
+    property INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := to_jsonb(OLD);
+    ELSE
+        row_data := to_jsonb(NEW);
+    END IF;
+
+    property := CASE TG_ARGV[0]
+        WHEN 'property' THEN (row_data->>'id')::INT
+        WHEN 'unit' THEN (row_data->>'property_id')::INT
+        WHEN 'maintenance_request' THEN (row_data->>'property_id')::INT
+        WHEN 'lease' THEN (SELECT property_id FROM property_units WHERE id = (row_data->>'unit_id')::INT)
+        WHEN 'payment' THEN (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id
+                             WHERE l.id = (row_data->>'lease_id')::INT)
+    END;
+
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES (TG_ARGV[0], (row_data->>'id')::INT, lower(TG_OP), property);
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Backfill the changes of entities that still exist
+UPDATE entity_changes SET property_id = entity_id WHERE entity_type = 'property';
+UPDATE entity_changes ec SET property_id = pu.property_id
+    FROM property_units pu WHERE ec.entity_type = 'unit' AND pu.id = ec.entity_id;
+UPDATE entity_changes ec SET property_id = mr.property_id
+    FROM maintenance_requests mr WHERE ec.entity_type = 'maintenance_request' AND mr.id = ec.entity_id;
+UPDATE entity_changes ec SET property_id = pu.property_id
+    FROM leases l JOIN property_units pu ON pu.id = l.unit_id
+    WHERE ec.entity_type = 'lease' AND l.id = ec.entity_id;
+UPDATE entity_changes ec SET property_id = pu.property_id
+    FROM payments p JOIN leases l ON l.id = p.lease_id JOIN property_units pu ON pu.id = l.unit_id
+    WHERE ec.entity_type = 'payment' AND p.id = ec.entity_id;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_entity_changes_changed_at;
+DROP INDEX IF EXISTS idx_entity_changes_txid;
+ALTER TABLE entity_changes DROP COLUMN IF EXISTS txid;
@@ -0,0 +1,9 @@
+-- Stamp each change with the transaction that wrote it. IDs are handed out when a change is
+-- recorded, not when it commits, so the change feed orders by (txid, id) and only returns changes
+-- of transactions older than every transaction still in progress. Existing changes have all
+-- committed and keep txid 0.
+ALTER TABLE entity_changes ADD COLUMN txid BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE entity_changes ALTER COLUMN txid SET DEFAULT txid_current();
+
+CREATE INDEX idx_entity_changes_txid ON entity_changes(txid, id);
+CREATE INDEX idx_entity_changes_changed_at ON entity_changes(changed_at);
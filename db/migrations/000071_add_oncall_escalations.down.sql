@@ -0,0 +1 @@
+ALTER TABLE maintenance_requests DROP COLUMN IF EXISTS oncall_escalated_at;
@@ -0,0 +1,3 @@
+-- When managers were told an emergency request could not be routed because the property has no
+-- reachable on-call contact. The request stays unrouted so it is sent once a contact is set up.
+ALTER TABLE maintenance_requests ADD COLUMN oncall_escalated_at TIMESTAMPTZ;
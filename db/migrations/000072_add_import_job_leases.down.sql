@@ -0,0 +1,2 @@
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS attempts;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS lease_expires_at;
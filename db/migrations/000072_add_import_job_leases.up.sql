@@ -0,0 +1,4 @@
+-- A running import holds a lease its worker keeps extending. A job whose lease expired was
+-- abandoned by a worker that stopped, and is claimed again until it has used its attempts.
+ALTER TABLE import_jobs ADD COLUMN lease_expires_at TIMESTAMPTZ;
+ALTER TABLE import_jobs ADD COLUMN attempts INT NOT NULL DEFAULT 0;
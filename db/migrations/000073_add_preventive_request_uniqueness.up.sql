@@ -0,0 +1,8 @@
+-- A plan generates one request per due date, even when two schedulers run at once. Duplicates
+-- generated before this index existed are kept but unlinked from their plan.
+UPDATE maintenance_requests SET plan_id = NULL
+WHERE plan_id IS NOT NULL AND id NOT IN (
+    SELECT MIN(id) FROM maintenance_requests WHERE plan_id IS NOT NULL GROUP BY plan_id, due_date
+);
+
+CREATE UNIQUE INDEX idx_maintenance_requests_plan_due ON maintenance_requests(plan_id, due_date);
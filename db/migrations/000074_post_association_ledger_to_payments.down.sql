@@ -0,0 +1,86 @@
+CREATE TABLE association_charges (
+    id SERIAL PRIMARY KEY,
+    owner_id INT NOT NULL REFERENCES association_owners(id) ON DELETE RESTRICT,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('dues', 'fine')),
+    assessment_id INT REFERENCES dues_assessments(id) ON DELETE SET NULL,
+    violation_id INT REFERENCES association_violations(id) ON DELETE SET NULL,
+    description VARCHAR(255) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    due_date DATE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (assessment_id, owner_id, due_date)
+);
+
+CREATE INDEX idx_association_charges_owner_id ON association_charges(owner_id);
+
+CREATE TABLE association_payments (
+    id SERIAL PRIMARY KEY,
+    owner_id INT NOT NULL REFERENCES association_owners(id) ON DELETE RESTRICT,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    payment_date DATE NOT NULL,
+    payment_method VARCHAR(50),
+    reference VARCHAR(100),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_association_payments_owner_id ON association_payments(owner_id);
+
+INSERT INTO association_charges (owner_id, kind, assessment_id, violation_id, description, amount, due_date, created_at)
+SELECT association_owner_id, fee_type, assessment_id, violation_id, description, amount, fee_date, created_at
+FROM lease_fees WHERE association_owner_id IS NOT NULL ORDER BY id;
+
+-- The association ledger only holds completed payments; adjusting entries are dropped
+INSERT INTO association_payments (owner_id, amount, payment_date, payment_method, reference, created_at)
+SELECT association_owner_id, amount, payment_date, payment_method, reference, created_at
+FROM payments WHERE association_owner_id IS NOT NULL AND status = 'completed' AND amount > 0 ORDER BY id;
+
+DELETE FROM lease_fees WHERE association_owner_id IS NOT NULL;
+DELETE FROM payments WHERE association_owner_id IS NOT NULL AND adjusts_payment_id IS NOT NULL;
+DELETE FROM payments WHERE association_owner_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_lease_fees_assessment_due;
+DROP INDEX IF EXISTS idx_lease_fees_association_owner_id;
+ALTER TABLE lease_fees DROP CONSTRAINT IF EXISTS lease_fees_payer_check;
+ALTER TABLE lease_fees DROP COLUMN IF EXISTS violation_id;
+ALTER TABLE lease_fees DROP COLUMN IF EXISTS assessment_id;
+ALTER TABLE lease_fees DROP COLUMN IF EXISTS association_owner_id;
+ALTER TABLE lease_fees ALTER COLUMN lease_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_payments_association_owner_id;
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_payer_check;
+ALTER TABLE payments DROP COLUMN IF EXISTS reference;
+ALTER TABLE payments DROP COLUMN IF EXISTS association_owner_id;
+ALTER TABLE payments ALTER COLUMN lease_id SET NOT NULL;
+
+CREATE OR REPLACE FUNCTION record_entity_change() RETURNS TRIGGER AS $$
+DECLARE
+    row_data JSONB;
+    property INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := to_jsonb(OLD);
+    ELSE
+        row_data := to_jsonb(NEW);
+    END IF;
+
+    property := CASE TG_ARGV[0]
+        WHEN 'property' THEN (row_data->>'id')::INT
+        WHEN 'unit' THEN (row_data->>'property_id')::INT
+        WHEN 'maintenance_request' THEN (row_data->>'property_id')::INT
+        WHEN 'lease' THEN (SELECT property_id FROM property_units WHERE id = (row_data->>'unit_id')::INT)
+        WHEN 'payment' THEN (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id
+                             WHERE l.id = (row_data->>'lease_id')::INT)
+    END;
+
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES (TG_ARGV[0], (row_data->>'id')::INT, lower(TG_OP), property);
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+UPDATE report_templates SET template_config = '{"data_source": "association_charges", "report_type": "dues_delinquency", "metrics": ["past_due", "days_delinquent"]}'
+WHERE name = 'Dues Delinquency' AND is_system = true;
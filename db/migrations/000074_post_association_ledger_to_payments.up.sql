@@ -0,0 +1,66 @@
+-- Association owners are charged and pay through the same ledger as leases: dues and fines are
+-- lease_fees rows and owner payments are payments rows, each naming an owner instead of a lease
+ALTER TABLE payments ALTER COLUMN lease_id DROP NOT NULL;
+ALTER TABLE payments ADD COLUMN association_owner_id INT REFERENCES association_owners(id) ON DELETE RESTRICT;
+ALTER TABLE payments ADD COLUMN reference VARCHAR(100);
+ALTER TABLE payments ADD CONSTRAINT payments_payer_check CHECK ((lease_id IS NULL) <> (association_owner_id IS NULL));
+
+CREATE INDEX idx_payments_association_owner_id ON payments(association_owner_id);
+
+ALTER TABLE lease_fees ALTER COLUMN lease_id DROP NOT NULL;
+ALTER TABLE lease_fees ADD COLUMN association_owner_id INT REFERENCES association_owners(id) ON DELETE RESTRICT;
+ALTER TABLE lease_fees ADD COLUMN assessment_id INT REFERENCES dues_assessments(id) ON DELETE SET NULL;
+ALTER TABLE lease_fees ADD COLUMN violation_id INT REFERENCES association_violations(id) ON DELETE SET NULL;
+ALTER TABLE lease_fees ADD CONSTRAINT lease_fees_payer_check CHECK ((lease_id IS NULL) <> (association_owner_id IS NULL));
+
+CREATE INDEX idx_lease_fees_association_owner_id ON lease_fees(association_owner_id);
+-- Posting an assessment is idempotent per owner and due date
+CREATE UNIQUE INDEX idx_lease_fees_assessment_due ON lease_fees(assessment_id, association_owner_id, fee_date);
+
+-- Owner payments belong to the property of the owner's unit
+CREATE OR REPLACE FUNCTION record_entity_change() RETURNS TRIGGER AS $$
+DECLARE
+    row_data JSONB;
+    property INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := to_jsonb(OLD);
+    ELSE
+        row_data := to_jsonb(NEW);
+    END IF;
+
+    property := CASE TG_ARGV[0]
+        WHEN 'property' THEN (row_data->>'id')::INT
+        WHEN 'unit' THEN (row_data->>'property_id')::INT
+        WHEN 'maintenance_request' THEN (row_data->>'property_id')::INT
+        WHEN 'lease' THEN (SELECT property_id FROM property_units WHERE id = (row_data->>'unit_id')::INT)
+        WHEN 'payment' THEN COALESCE(
+            (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id
+             WHERE l.id = (row_data->>'lease_id')::INT),
+            (SELECT pu.property_id FROM association_owners ao JOIN property_units pu ON pu.id = ao.unit_id
+             WHERE ao.id = (row_data->>'association_owner_id')::INT))
+    END;
+
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES (TG_ARGV[0], (row_data->>'id')::INT, lower(TG_OP), property);
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+INSERT INTO lease_fees (association_owner_id, fee_type, assessment_id, violation_id, description, amount, fee_date, created_at)
+SELECT owner_id, kind, assessment_id, violation_id, description, amount, due_date, created_at
+FROM association_charges ORDER BY id;
+
+INSERT INTO payments (association_owner_id, amount, payment_date, payment_method, reference, status, created_at)
+SELECT owner_id, amount, payment_date, payment_method, reference, 'completed', created_at
+FROM association_payments ORDER BY id;
+
+DROP TABLE association_charges;
+DROP TABLE association_payments;
+
+UPDATE report_templates SET template_config = '{"data_source": "lease_fees", "report_type": "dues_delinquency", "metrics": ["past_due", "days_delinquent"]}'
+WHERE name = 'Dues Delinquency' AND is_system = true;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS esign_webhook_deliveries;
@@ -0,0 +1,11 @@
+-- E-signature webhook callbacks already applied, so a captured or repeated delivery is not applied
+-- twice. Rows older than twice the accepted timestamp window are pruned as new callbacks arrive.
+CREATE TABLE esign_webhook_deliveries (
+    provider VARCHAR(50) NOT NULL,
+    envelope_id VARCHAR(255) NOT NULL,
+    delivery_id VARCHAR(255) NOT NULL, -- Derived from the callback's signature
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, envelope_id, delivery_id)
+);
+
+CREATE INDEX idx_esign_webhook_deliveries_received ON esign_webhook_deliveries(received_at);
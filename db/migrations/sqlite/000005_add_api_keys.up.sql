@@ -0,0 +1,14 @@
+-- API keys for machine-to-machine integrations such as Zapier and Make.
+-- Only a SHA-256 hash of each key is stored; the plaintext is shown once at creation.
+CREATE TABLE api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL, -- e.g. 'Zapier', 'Make scenario'
+    key_prefix VARCHAR(16) NOT NULL, -- First characters of the key, for identification in the UI
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    last_used_at DATETIME,
+    revoked_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
@@ -0,0 +1,5 @@
+-- SQLite cannot drop a column that takes part in a foreign key, so
+-- payments.adjusts_payment_id is left in place.
+ALTER TABLE payments DROP COLUMN adjustment_reason;
+
+DROP TABLE IF EXISTS accounting_periods;
@@ -0,0 +1,16 @@
+-- Accounting period close. A row locks one month of one property: payments dated
+-- in a locked month can no longer be created or edited, only corrected through
+-- adjusting entries posted to an open period.
+CREATE TABLE accounting_periods (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    period_start DATE NOT NULL, -- First day of the locked month
+    locked_by INT REFERENCES users(id) ON DELETE SET NULL,
+    locked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    notes TEXT,
+    UNIQUE (property_id, period_start)
+);
+
+-- Adjusting entries reference the payment they correct
+ALTER TABLE payments ADD COLUMN adjusts_payment_id INT REFERENCES payments(id) ON DELETE RESTRICT;
+ALTER TABLE payments ADD COLUMN adjustment_reason TEXT;
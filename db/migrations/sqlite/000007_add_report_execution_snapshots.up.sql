@@ -0,0 +1,2 @@
+-- Store the output of each report execution so runs can be compared later
+ALTER TABLE report_executions ADD COLUMN result_snapshot TEXT;
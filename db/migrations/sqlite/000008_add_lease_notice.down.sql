@@ -0,0 +1,3 @@
+DELETE FROM report_templates WHERE name = 'Vacancy Forecast' AND is_system = true;
+
+ALTER TABLE leases DROP COLUMN notice_given_date;
@@ -0,0 +1,5 @@
+ALTER TABLE maintenance_requests DROP COLUMN sla_alert_level;
+ALTER TABLE maintenance_requests DROP COLUMN resolved_at;
+ALTER TABLE maintenance_requests DROP COLUMN responded_at;
+
+DROP TABLE IF EXISTS maintenance_sla_policies;
@@ -0,0 +1,19 @@
+-- SLA targets per maintenance priority
+CREATE TABLE maintenance_sla_policies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    priority VARCHAR(50) UNIQUE NOT NULL, -- Matches maintenance_requests.priority
+    response_hours INT NOT NULL, -- Time allowed until the request is acknowledged
+    resolution_hours INT NOT NULL, -- Time allowed until the request is completed
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO maintenance_sla_policies (priority, response_hours, resolution_hours) VALUES
+('high', 4, 24),
+('medium', 24, 72),
+('low', 48, 168);
+
+-- Timestamps used to measure SLA compliance
+ALTER TABLE maintenance_requests ADD COLUMN responded_at DATETIME;
+ALTER TABLE maintenance_requests ADD COLUMN resolved_at DATETIME;
+ALTER TABLE maintenance_requests ADD COLUMN sla_alert_level VARCHAR(20); -- Last alert sent: 'at_risk' or 'breached'
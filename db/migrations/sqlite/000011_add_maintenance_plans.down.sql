@@ -0,0 +1,5 @@
+-- SQLite cannot drop a column that takes part in a foreign key, so
+-- maintenance_requests.plan_id is left in place.
+ALTER TABLE maintenance_requests DROP COLUMN due_date;
+
+DROP TABLE IF EXISTS maintenance_plans;
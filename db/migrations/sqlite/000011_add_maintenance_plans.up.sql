@@ -0,0 +1,21 @@
+-- Preventive maintenance plans that generate maintenance requests on a schedule
+CREATE TABLE maintenance_plans (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    unit_id INT REFERENCES property_units(id) ON DELETE CASCADE, -- NULL for property-wide tasks
+    title VARCHAR(255) NOT NULL, -- e.g. 'Replace HVAC filter'
+    description TEXT,
+    interval_days INT NOT NULL, -- e.g. 90
+    priority VARCHAR(50) NOT NULL DEFAULT 'low',
+    next_due_date DATE NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_maintenance_plans_next_due ON maintenance_plans(next_due_date);
+
+-- Link generated requests back to their plan and record when they were due
+ALTER TABLE maintenance_requests ADD COLUMN plan_id INT REFERENCES maintenance_plans(id) ON DELETE SET NULL;
+ALTER TABLE maintenance_requests ADD COLUMN due_date DATE;
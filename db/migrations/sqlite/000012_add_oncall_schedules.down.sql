@@ -0,0 +1,11 @@
+DELETE FROM maintenance_sla_policies WHERE priority = 'emergency';
+
+-- SQLite cannot drop a column that takes part in a foreign key, so
+-- maintenance_requests.oncall_user_id is left in place.
+ALTER TABLE maintenance_requests DROP COLUMN oncall_notified_at;
+
+ALTER TABLE properties DROP COLUMN emergency_contact_email;
+ALTER TABLE properties DROP COLUMN emergency_contact_phone;
+ALTER TABLE properties DROP COLUMN emergency_contact_name;
+
+DROP TABLE IF EXISTS oncall_shifts;
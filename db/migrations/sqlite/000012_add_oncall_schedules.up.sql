@@ -0,0 +1,26 @@
+-- On-call shifts: who handles emergency maintenance for a property and when
+CREATE TABLE oncall_shifts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    starts_at DATETIME NOT NULL,
+    ends_at DATETIME NOT NULL,
+    notes TEXT,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_oncall_shifts_property_time ON oncall_shifts(property_id, starts_at, ends_at);
+
+-- Fallback contact used when no shift covers the current time
+ALTER TABLE properties ADD COLUMN emergency_contact_name VARCHAR(255);
+ALTER TABLE properties ADD COLUMN emergency_contact_phone VARCHAR(50);
+ALTER TABLE properties ADD COLUMN emergency_contact_email VARCHAR(255);
+
+-- Emergency requests are routed to the on-call contact once
+ALTER TABLE maintenance_requests ADD COLUMN oncall_notified_at DATETIME;
+ALTER TABLE maintenance_requests ADD COLUMN oncall_user_id INT REFERENCES users(id) ON DELETE SET NULL;
+
+INSERT INTO maintenance_sla_policies (priority, response_hours, resolution_hours) VALUES
+('emergency', 1, 8);
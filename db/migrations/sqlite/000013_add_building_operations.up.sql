@@ -0,0 +1,53 @@
+-- Packages received at the front desk or package locker
+CREATE TABLE packages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    tenant_id INT REFERENCES tenants(id) ON DELETE SET NULL,
+    unit_id INT REFERENCES property_units(id) ON DELETE SET NULL,
+    carrier VARCHAR(100),
+    tracking_number VARCHAR(100),
+    description TEXT,
+    storage_location VARCHAR(100), -- Shelf or locker number
+    status VARCHAR(20) NOT NULL DEFAULT 'received', -- 'received', 'picked_up' or 'returned'
+    received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    received_by INT REFERENCES users(id) ON DELETE SET NULL,
+    picked_up_at DATETIME,
+    picked_up_by VARCHAR(255), -- Name of the person who collected the package
+    released_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_packages_property_status ON packages(property_id, status);
+CREATE INDEX idx_packages_tenant ON packages(tenant_id);
+
+-- Visitors pre-authorized by tenants or staff
+CREATE TABLE visitor_authorizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    tenant_id INT REFERENCES tenants(id) ON DELETE CASCADE,
+    visitor_name VARCHAR(255) NOT NULL,
+    access_code VARCHAR(20) NOT NULL,
+    valid_from DATETIME NOT NULL,
+    valid_until DATETIME NOT NULL,
+    notes TEXT,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_visitor_authorizations_property ON visitor_authorizations(property_id, valid_until);
+
+-- Visitor arrivals recorded by the front desk
+CREATE TABLE visitor_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    authorization_id INT REFERENCES visitor_authorizations(id) ON DELETE SET NULL,
+    tenant_id INT REFERENCES tenants(id) ON DELETE SET NULL,
+    visitor_name VARCHAR(255) NOT NULL,
+    checked_in_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    checked_out_at DATETIME,
+    logged_by INT REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_visitor_logs_property_time ON visitor_logs(property_id, checked_in_at);
@@ -0,0 +1,3 @@
+DELETE FROM report_templates WHERE name = 'Portfolio Comparison' AND is_system = true;
+
+ALTER TABLE maintenance_requests DROP COLUMN actual_cost;
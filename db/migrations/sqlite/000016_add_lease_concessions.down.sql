@@ -0,0 +1,3 @@
+DELETE FROM report_templates WHERE name = 'Rent Roll' AND is_system = true;
+
+DROP TABLE IF EXISTS lease_concessions;
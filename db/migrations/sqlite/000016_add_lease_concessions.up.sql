@@ -0,0 +1,18 @@
+-- Structured lease concessions (free months and discounts) used to compute effective rent
+CREATE TABLE lease_concessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    concession_type VARCHAR(20) NOT NULL, -- 'free_rent', 'fixed_discount' or 'percent_discount'
+    start_month DATE NOT NULL, -- First month the concession applies to
+    months INT NOT NULL DEFAULT 1,
+    amount DECIMAL(10, 2), -- Monthly discount amount or percentage; unused for free_rent
+    description TEXT, -- e.g. the marketing promotion that granted it
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_lease_concessions_lease ON lease_concessions(lease_id);
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Rent Roll', 'Active leases with contract rent, current concessions and net effective rent', 'financial',
+ '{"data_source": "leases", "report_type": "rent_roll", "metrics": ["contract_rent", "concessions", "effective_rent"]}', true);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS lease_escalations;
+DROP TABLE IF EXISTS cpi_index_values;
+DROP TABLE IF EXISTS lease_escalation_rules;
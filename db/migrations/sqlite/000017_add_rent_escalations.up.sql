@@ -0,0 +1,45 @@
+-- Rent escalation clause attached to a lease
+CREATE TABLE lease_escalation_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT NOT NULL UNIQUE REFERENCES leases(id) ON DELETE CASCADE,
+    escalation_type VARCHAR(20) NOT NULL, -- 'fixed_percent', 'cpi' or 'stepped'
+    percent DECIMAL(6, 3), -- Increase per escalation for fixed_percent
+    cpi_series VARCHAR(50) NOT NULL DEFAULT 'CPI-U',
+    cpi_floor_percent DECIMAL(6, 3), -- Minimum and maximum CPI-linked increase
+    cpi_cap_percent DECIMAL(6, 3),
+    interval_months INT NOT NULL DEFAULT 12,
+    steps TEXT, -- JSON list of {"effective_date", "monthly_rent"} for stepped schedules
+    next_escalation_date DATE, -- NULL once a stepped schedule is exhausted
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_lease_escalation_rules_next ON lease_escalation_rules(next_escalation_date);
+
+-- Published index values, one per series and month
+CREATE TABLE cpi_index_values (
+    series VARCHAR(50) NOT NULL,
+    period DATE NOT NULL, -- First day of the month the value is for
+    index_value DECIMAL(10, 3) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (series, period)
+);
+
+-- Computed escalations, from notice through to the new rent taking effect
+CREATE TABLE lease_escalations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    rule_id INT REFERENCES lease_escalation_rules(id) ON DELETE SET NULL,
+    effective_date DATE NOT NULL,
+    old_rent DECIMAL(10, 2) NOT NULL,
+    new_rent DECIMAL(10, 2) NOT NULL,
+    basis TEXT, -- How the new rent was calculated
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled', -- 'scheduled' or 'applied'
+    notified_at DATETIME,
+    applied_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (lease_id, effective_date)
+);
+
+CREATE INDEX idx_lease_escalations_status ON lease_escalations(status, effective_date);
@@ -0,0 +1,24 @@
+-- Security deposit held for each lease
+ALTER TABLE leases ADD COLUMN security_deposit DECIMAL(10, 2);
+ALTER TABLE leases ADD COLUMN deposit_received_date DATE;
+ALTER TABLE leases ADD COLUMN deposit_escrow_account VARCHAR(100); -- Account the deposit is held in, if escrowed
+
+-- Jurisdiction limits on deposits. A row without property_id is the default;
+-- a property row overrides it (e.g. for state or city deposit laws).
+CREATE TABLE deposit_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT UNIQUE REFERENCES properties(id) ON DELETE CASCADE,
+    jurisdiction VARCHAR(100), -- e.g. 'Massachusetts', 'City of Chicago RLTO'
+    max_months_rent DECIMAL(5, 2), -- Maximum deposit as a multiple of monthly rent
+    max_amount DECIMAL(10, 2), -- Maximum deposit in currency
+    interest_rate_percent DECIMAL(5, 3), -- Annual interest owed to the tenant on the deposit
+    escrow_required BOOLEAN NOT NULL DEFAULT FALSE, -- Deposit must be held in a separate account
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO deposit_rules (property_id, jurisdiction, max_months_rent) VALUES (NULL, 'Default', 2.00);
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Deposit Compliance', 'Security deposits that exceed jurisdiction limits or are not escrowed as required, with interest owed', 'financial',
+ '{"data_source": "leases", "report_type": "deposit_compliance", "metrics": ["security_deposit", "interest_owed"]}', true);
@@ -0,0 +1,3 @@
+-- Per-user UI settings (theme, locale, default dashboard, date format) stored as a JSON object.
+-- Missing keys fall back to the application defaults.
+ALTER TABLE users ADD COLUMN preferences TEXT;
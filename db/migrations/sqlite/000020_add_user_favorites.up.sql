@@ -0,0 +1,23 @@
+-- Reports and dashboards a user has starred
+CREATE TABLE user_favorites (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_type VARCHAR(20) NOT NULL, -- 'report' or 'dashboard'
+    item_id INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, item_type, item_id)
+);
+
+-- Reports and dashboards a user recently viewed or executed; one row per item, pruned per user
+CREATE TABLE user_recent_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_type VARCHAR(20) NOT NULL, -- 'report' or 'dashboard'
+    item_id INT NOT NULL,
+    last_action VARCHAR(20) NOT NULL, -- 'viewed' or 'executed'
+    access_count INT NOT NULL DEFAULT 1,
+    accessed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, item_type, item_id)
+);
+
+CREATE INDEX idx_user_recent_items_user ON user_recent_items(user_id, accessed_at);
@@ -0,0 +1,14 @@
+-- Signup invitations for users created by an administrator (e.g. bulk import). Only a hash of
+-- the token is stored; the plaintext token is emailed to the invitee.
+CREATE TABLE user_invitations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by INT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_invitations_user ON user_invitations(user_id);
@@ -0,0 +1,13 @@
+-- Administrative actions such as account suspension, with who performed them and why
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(100) NOT NULL, -- e.g. 'user.suspended', 'user.reactivated'
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id INT NOT NULL,
+    reason TEXT,
+    details TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at);
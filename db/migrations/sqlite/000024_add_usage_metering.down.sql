@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS usage_quotas;
+DROP TABLE IF EXISTS usage_counters;
+ALTER TABLE users DROP COLUMN organization_id;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,30 @@
+-- Organizations that usage is metered and billed against. Existing users belong to the
+-- default organization (id 1) until assigned elsewhere.
+CREATE TABLE organizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO organizations (name) VALUES ('Default Organization'); -- First row, so id 1
+
+ALTER TABLE users ADD COLUMN organization_id INT REFERENCES organizations(id) ON DELETE SET NULL;
+
+-- Monthly usage per organization and metric
+CREATE TABLE usage_counters (
+    organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    period VARCHAR(7) NOT NULL, -- 'YYYY-MM'
+    metric VARCHAR(50) NOT NULL, -- 'api_calls', 'report_executions', 'export_bytes', 'storage_bytes'
+    value BIGINT NOT NULL DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, period, metric)
+);
+
+-- Monthly limits per organization and metric; metrics without a row are unlimited
+CREATE TABLE usage_quotas (
+    organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    metric VARCHAR(50) NOT NULL,
+    monthly_limit BIGINT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, metric)
+);
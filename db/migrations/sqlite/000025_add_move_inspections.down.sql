@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS lease_damage_charges;
+DROP TABLE IF EXISTS lease_inspection_items;
+DROP TABLE IF EXISTS lease_inspections;
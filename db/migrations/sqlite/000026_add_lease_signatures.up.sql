@@ -0,0 +1,15 @@
+-- Lease documents sent for e-signature. A completed envelope moves a pending lease to 'signed'.
+CREATE TABLE lease_signatures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL, -- 'docusign' or 'dropboxsign'
+    envelope_id VARCHAR(255) NOT NULL, -- Provider's envelope or signature request ID
+    status VARCHAR(20) NOT NULL DEFAULT 'sent', -- 'sent', 'signed', 'declined' or 'voided'
+    signed_document_url TEXT, -- Executed PDF in the document store
+    sent_by INT REFERENCES users(id) ON DELETE SET NULL,
+    sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME,
+    UNIQUE (provider, envelope_id)
+);
+
+CREATE INDEX idx_lease_signatures_lease ON lease_signatures(lease_id);
@@ -0,0 +1,31 @@
+-- CSV imports run as background jobs. The uploaded file is kept on the job so a worker can
+-- process it after the request returns; per-row failures are recorded for download.
+CREATE TABLE import_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    import_type VARCHAR(50) NOT NULL, -- e.g., 'properties'
+    mode VARCHAR(20) NOT NULL CHECK (mode IN ('all_or_nothing', 'skip_bad_rows')),
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    filename TEXT,
+    source_csv TEXT NOT NULL,
+    total_rows INT NOT NULL DEFAULT 0,
+    processed_rows INT NOT NULL DEFAULT 0,
+    succeeded_rows INT NOT NULL DEFAULT 0,
+    failed_rows INT NOT NULL DEFAULT 0,
+    error TEXT, -- Why a job failed as a whole
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME
+);
+
+CREATE INDEX idx_import_jobs_status ON import_jobs(status, id);
+
+CREATE TABLE import_job_errors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INT NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    row_number INT NOT NULL, -- Line number in the uploaded file, counting the header as 1
+    message TEXT NOT NULL,
+    raw_row TEXT NOT NULL -- The row's fields re-encoded as CSV
+);
+
+CREATE INDEX idx_import_job_errors_job ON import_job_errors(job_id, row_number);
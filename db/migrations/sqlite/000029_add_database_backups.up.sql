@@ -0,0 +1,20 @@
+-- Database backups taken by pg_dump or an external backup webhook, with the outcome of the
+-- optional restore verification into a scratch schema
+CREATE TABLE database_backups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    method VARCHAR(20) NOT NULL CHECK (method IN ('pg_dump', 'webhook')),
+    source VARCHAR(20) NOT NULL CHECK (source IN ('manual', 'scheduled')), -- What started the backup
+    status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'succeeded', 'failed')),
+    location TEXT, -- Dump file path, or the location reported by the webhook
+    size_bytes BIGINT,
+    checksum VARCHAR(64), -- SHA-256 of the dump file
+    error TEXT,
+    verify_status VARCHAR(20) NOT NULL DEFAULT 'skipped' CHECK (verify_status IN ('skipped', 'passed', 'failed')),
+    verify_error TEXT,
+    verified_tables INT, -- Tables restored into the scratch schema
+    requested_by INT REFERENCES users(id) ON DELETE SET NULL,
+    started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME
+);
+
+CREATE INDEX idx_database_backups_started ON database_backups(started_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS maintenance_thread_reads;
+DROP TABLE IF EXISTS maintenance_message_attachments;
+DROP TABLE IF EXISTS maintenance_messages;
+DROP TABLE IF EXISTS maintenance_request_participants;
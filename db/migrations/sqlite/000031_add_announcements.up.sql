@@ -0,0 +1,20 @@
+-- Announcements published to tenant portals for one property or, with no property, the whole
+-- portfolio, optionally also sent by email and SMS when they are published
+CREATE TABLE announcements (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT REFERENCES properties(id) ON DELETE CASCADE,
+    title VARCHAR(200) NOT NULL,
+    body TEXT NOT NULL,
+    send_email BOOLEAN NOT NULL DEFAULT FALSE,
+    send_sms BOOLEAN NOT NULL DEFAULT FALSE,
+    publish_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME,
+    notified_at DATETIME,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK (expires_at IS NULL OR expires_at > publish_at)
+);
+
+CREATE INDEX idx_announcements_publish_at ON announcements(publish_at);
+CREATE INDEX idx_announcements_property_id ON announcements(property_id);
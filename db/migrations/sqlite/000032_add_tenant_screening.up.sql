@@ -0,0 +1,46 @@
+-- Rental applications, the applicant's consent to be screened, and credit/background screening
+-- requests sent to a screening provider. Only the provider's summarized decision is stored.
+CREATE TABLE rental_applications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    unit_id INT REFERENCES property_units(id) ON DELETE SET NULL,
+    first_name VARCHAR(100) NOT NULL,
+    last_name VARCHAR(100) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    phone_number VARCHAR(20),
+    monthly_income DECIMAL(10, 2),
+    desired_move_in DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'withdrawn')),
+    consent_text TEXT,
+    consent_given_at DATETIME,
+    consent_ip VARCHAR(64),
+    consent_user_agent TEXT,
+    consent_recorded_by INT REFERENCES users(id) ON DELETE SET NULL,
+    decision_reason TEXT,
+    decided_by INT REFERENCES users(id) ON DELETE SET NULL,
+    decided_at DATETIME,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_rental_applications_status ON rental_applications(status);
+
+CREATE TABLE screening_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    application_id INT NOT NULL REFERENCES rental_applications(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    external_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'failed')),
+    decision VARCHAR(20) CHECK (decision IN ('approve', 'conditional', 'decline')),
+    credit_score INT,
+    summary TEXT,
+    error TEXT,
+    requested_by INT REFERENCES users(id) ON DELETE SET NULL,
+    requested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME,
+    UNIQUE (provider, external_id)
+);
+
+CREATE INDEX idx_screening_requests_application_id ON screening_requests(application_id);
+CREATE INDEX idx_screening_requests_status ON screening_requests(status);
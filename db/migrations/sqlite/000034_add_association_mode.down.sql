@@ -0,0 +1,7 @@
+DELETE FROM report_templates WHERE name IN ('Dues Delinquency', 'Association Violations') AND is_system = true;
+DROP TABLE IF EXISTS association_payments;
+DROP TABLE IF EXISTS association_charges;
+DROP TABLE IF EXISTS association_violations;
+DROP TABLE IF EXISTS dues_assessments;
+DROP TABLE IF EXISTS association_owners;
+ALTER TABLE properties DROP COLUMN operating_mode;
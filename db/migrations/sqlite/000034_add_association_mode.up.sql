@@ -0,0 +1,92 @@
+-- Association (HOA/condo) operating mode: units belong to owners who are assessed dues instead of
+-- leasing to tenants, and violations of the association's rules can be fined
+ALTER TABLE properties ADD COLUMN operating_mode VARCHAR(20) NOT NULL DEFAULT 'rental'
+    CHECK (operating_mode IN ('rental', 'association'));
+
+-- Owner accounts. An owner whose ownership has ended keeps their ledger history.
+CREATE TABLE association_owners (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    unit_id INT NOT NULL REFERENCES property_units(id) ON DELETE RESTRICT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    phone VARCHAR(50),
+    mailing_address TEXT,
+    ownership_start DATE NOT NULL,
+    ownership_end DATE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ownership_end IS NULL OR ownership_end >= ownership_start)
+);
+
+CREATE INDEX idx_association_owners_unit_id ON association_owners(unit_id);
+
+-- Recurring or one-time dues levied on one unit or, with no unit, every unit of the property
+CREATE TABLE dues_assessments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    unit_id INT REFERENCES property_units(id) ON DELETE CASCADE,
+    description VARCHAR(255) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    frequency VARCHAR(20) NOT NULL CHECK (frequency IN ('monthly', 'quarterly', 'annual', 'one_time')),
+    start_date DATE NOT NULL,
+    end_date DATE,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+CREATE INDEX idx_dues_assessments_property_id ON dues_assessments(property_id);
+
+-- Rule violations reported against a unit and its owner
+CREATE TABLE association_violations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    unit_id INT NOT NULL REFERENCES property_units(id) ON DELETE CASCADE,
+    owner_id INT REFERENCES association_owners(id) ON DELETE SET NULL,
+    category VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    observed_on DATE NOT NULL,
+    cure_by DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'cured', 'fined', 'dismissed')),
+    resolution_notes TEXT,
+    resolved_at DATETIME,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_association_violations_unit_id ON association_violations(unit_id);
+
+-- Owner ledger: charges for dues and fines, and the payments made against them. Posting an
+-- assessment is idempotent per owner and due date.
+CREATE TABLE association_charges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INT NOT NULL REFERENCES association_owners(id) ON DELETE RESTRICT,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('dues', 'fine')),
+    assessment_id INT REFERENCES dues_assessments(id) ON DELETE SET NULL,
+    violation_id INT REFERENCES association_violations(id) ON DELETE SET NULL,
+    description VARCHAR(255) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    due_date DATE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (assessment_id, owner_id, due_date)
+);
+
+CREATE INDEX idx_association_charges_owner_id ON association_charges(owner_id);
+
+CREATE TABLE association_payments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INT NOT NULL REFERENCES association_owners(id) ON DELETE RESTRICT,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    payment_date DATE NOT NULL,
+    payment_method VARCHAR(50),
+    reference VARCHAR(100),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_association_payments_owner_id ON association_payments(owner_id);
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Dues Delinquency', 'Association owners with past-due dues and fines, aged 0-30, 31-60, 61-90 and over 90 days', 'financial',
+ '{"data_source": "association_charges", "report_type": "dues_delinquency", "metrics": ["past_due", "days_delinquent"]}', true),
+('Association Violations', 'Rule violations by unit and owner with their status and fines charged', 'operational',
+ '{"data_source": "association_violations", "report_type": "association_violations", "metrics": ["violation_count", "fines_total"]}', true);
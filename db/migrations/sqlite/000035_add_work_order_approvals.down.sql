@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS maintenance_cost_approvals;
+ALTER TABLE maintenance_requests DROP COLUMN approval_status;
+ALTER TABLE maintenance_requests DROP COLUMN estimated_cost;
+DROP TABLE IF EXISTS maintenance_approval_policies;
@@ -0,0 +1,3 @@
+UPDATE roles SET permissions = (
+    SELECT json_group_array(value) FROM json_each(roles.permissions) WHERE value != 'tenants.contact.read'
+);
@@ -0,0 +1,4 @@
+-- Tenant contact details in report output are masked for roles without this permission
+UPDATE roles SET permissions = json_insert(permissions, '$[#]', 'tenants.contact.read')
+WHERE name IN ('admin', 'property_manager')
+  AND NOT EXISTS (SELECT 1 FROM json_each(roles.permissions) WHERE value = 'tenants.contact.read');
@@ -0,0 +1,19 @@
+DROP TRIGGER IF EXISTS properties_entity_changes_insert;
+DROP TRIGGER IF EXISTS properties_entity_changes_update;
+DROP TRIGGER IF EXISTS properties_entity_changes_delete;
+DROP TRIGGER IF EXISTS property_units_entity_changes_insert;
+DROP TRIGGER IF EXISTS property_units_entity_changes_update;
+DROP TRIGGER IF EXISTS property_units_entity_changes_delete;
+DROP TRIGGER IF EXISTS tenants_entity_changes_insert;
+DROP TRIGGER IF EXISTS tenants_entity_changes_update;
+DROP TRIGGER IF EXISTS tenants_entity_changes_delete;
+DROP TRIGGER IF EXISTS leases_entity_changes_insert;
+DROP TRIGGER IF EXISTS leases_entity_changes_update;
+DROP TRIGGER IF EXISTS leases_entity_changes_delete;
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes_insert;
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes_update;
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes_delete;
+DROP TRIGGER IF EXISTS payments_entity_changes_insert;
+DROP TRIGGER IF EXISTS payments_entity_changes_update;
+DROP TRIGGER IF EXISTS payments_entity_changes_delete;
+DROP TABLE IF EXISTS entity_changes;
@@ -0,0 +1,101 @@
+-- Append-only feed of inserts, updates and deletes on core entities, read by the change feed API.
+-- Rows are written by triggers so every write path is captured, including bulk imports.
+CREATE TABLE entity_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT, -- Cursor for the change feed
+    entity_type VARCHAR(50) NOT NULL, -- e.g. 'property', 'lease', 'payment'
+    entity_id INT NOT NULL,
+    op VARCHAR(10) NOT NULL, -- 'insert', 'update' or 'delete'
+    changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_entity_changes_entity_type ON entity_changes(entity_type, id);
+
+CREATE TRIGGER properties_entity_changes_insert AFTER INSERT ON properties
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('property', NEW.id, 'insert');
+END;
+
+CREATE TRIGGER properties_entity_changes_update AFTER UPDATE ON properties
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('property', NEW.id, 'update');
+END;
+
+CREATE TRIGGER properties_entity_changes_delete AFTER DELETE ON properties
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('property', OLD.id, 'delete');
+END;
+
+CREATE TRIGGER property_units_entity_changes_insert AFTER INSERT ON property_units
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('unit', NEW.id, 'insert');
+END;
+
+CREATE TRIGGER property_units_entity_changes_update AFTER UPDATE ON property_units
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('unit', NEW.id, 'update');
+END;
+
+CREATE TRIGGER property_units_entity_changes_delete AFTER DELETE ON property_units
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('unit', OLD.id, 'delete');
+END;
+
+CREATE TRIGGER tenants_entity_changes_insert AFTER INSERT ON tenants
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('tenant', NEW.id, 'insert');
+END;
+
+CREATE TRIGGER tenants_entity_changes_update AFTER UPDATE ON tenants
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('tenant', NEW.id, 'update');
+END;
+
+CREATE TRIGGER tenants_entity_changes_delete AFTER DELETE ON tenants
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('tenant', OLD.id, 'delete');
+END;
+
+CREATE TRIGGER leases_entity_changes_insert AFTER INSERT ON leases
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('lease', NEW.id, 'insert');
+END;
+
+CREATE TRIGGER leases_entity_changes_update AFTER UPDATE ON leases
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('lease', NEW.id, 'update');
+END;
+
+CREATE TRIGGER leases_entity_changes_delete AFTER DELETE ON leases
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('lease', OLD.id, 'delete');
+END;
+
+CREATE TRIGGER maintenance_requests_entity_changes_insert AFTER INSERT ON maintenance_requests
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('maintenance_request', NEW.id, 'insert');
+END;
+
+CREATE TRIGGER maintenance_requests_entity_changes_update AFTER UPDATE ON maintenance_requests
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('maintenance_request', NEW.id, 'update');
+END;
+
+CREATE TRIGGER maintenance_requests_entity_changes_delete AFTER DELETE ON maintenance_requests
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('maintenance_request', OLD.id, 'delete');
+END;
+
+CREATE TRIGGER payments_entity_changes_insert AFTER INSERT ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('payment', NEW.id, 'insert');
+END;
+
+CREATE TRIGGER payments_entity_changes_update AFTER UPDATE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('payment', NEW.id, 'update');
+END;
+
+CREATE TRIGGER payments_entity_changes_delete AFTER DELETE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('payment', OLD.id, 'delete');
+END;
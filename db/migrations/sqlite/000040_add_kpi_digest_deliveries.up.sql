@@ -0,0 +1,8 @@
+-- Records each KPI digest email queued for a user so a period is only sent once
+CREATE TABLE kpi_digest_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    period VARCHAR(20) NOT NULL, -- e.g. 'weekly:2026-W41' or 'monthly:2026-09'
+    sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, period)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS expense_ratio_benchmarks;
+DROP TABLE IF EXISTS market_rent_benchmarks;
@@ -0,0 +1,24 @@
+-- Market rent for a unit size in a zip code, imported from a market data provider
+CREATE TABLE market_rent_benchmarks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    zip_code VARCHAR(10) NOT NULL,
+    bedrooms INT NOT NULL CHECK (bedrooms >= 0), -- 0 for studios
+    market_rent DECIMAL(10, 2) NOT NULL CHECK (market_rent > 0),
+    source VARCHAR(255),
+    as_of DATE,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (zip_code, bedrooms)
+);
+
+-- Market operating expense ratio (operating expenses as a percentage of revenue). An empty
+-- zip_code or property_type applies wherever no more specific benchmark exists.
+CREATE TABLE expense_ratio_benchmarks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    zip_code VARCHAR(10) NOT NULL DEFAULT '',
+    property_type VARCHAR(50) NOT NULL DEFAULT '',
+    expense_ratio DECIMAL(5, 2) NOT NULL CHECK (expense_ratio >= 0 AND expense_ratio <= 100),
+    source VARCHAR(255),
+    as_of DATE,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (zip_code, property_type)
+);
@@ -0,0 +1,13 @@
+-- Daily snapshots of the dashboard quick stats, so trends can be charted without recomputing
+-- historical joins. property_id 0 is the whole portfolio.
+CREATE TABLE stats_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stat_name VARCHAR(100) NOT NULL,
+    property_id INT NOT NULL DEFAULT 0,
+    snapshot_date DATE NOT NULL,
+    stat_values TEXT NOT NULL, -- Field values keyed by field key, as returned by GET /api/stats/{name}
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (stat_name, property_id, snapshot_date)
+);
+
+CREATE INDEX idx_stats_history_date ON stats_history(snapshot_date);
@@ -0,0 +1,11 @@
+-- Keycloak SSO sessions ended by back-channel logout. ID tokens issued for a revoked session
+-- (session_id), or for the subject before a subject-wide logout (session_id ''), are rejected.
+CREATE TABLE oidc_session_revocations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    session_id VARCHAR(255) NOT NULL DEFAULT '',
+    revoked_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_oidc_session_revocations_session ON oidc_session_revocations(session_id);
+CREATE INDEX idx_oidc_session_revocations_subject ON oidc_session_revocations(subject);
@@ -0,0 +1,5 @@
+-- Temporary role assignments, e.g. contractor access. Assignments are removed once expires_at
+-- passes; NULL never expires.
+ALTER TABLE user_roles ADD COLUMN expires_at DATETIME;
+
+CREATE INDEX idx_user_roles_expires_at ON user_roles(expires_at);
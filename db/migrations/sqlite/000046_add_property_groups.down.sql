@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS property_group_managers;
+
+-- SQLite cannot drop a column that takes part in a foreign key, so properties.group_id is left
+-- in place.
+DROP INDEX IF EXISTS idx_properties_group;
+
+DROP TABLE IF EXISTS property_groups;
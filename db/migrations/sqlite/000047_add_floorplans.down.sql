@@ -0,0 +1,7 @@
+DELETE FROM report_templates WHERE name = 'Unit Mix' AND is_system = true;
+
+-- SQLite cannot drop a column that takes part in a foreign key, so property_units.floorplan_id
+-- is left in place.
+DROP INDEX IF EXISTS idx_property_units_floorplan;
+
+DROP TABLE IF EXISTS floorplans;
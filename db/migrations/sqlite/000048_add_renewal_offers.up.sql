@@ -0,0 +1,25 @@
+-- Renewal offers sent to tenants ahead of lease expiry, and the tenant's response. Accepting an
+-- offer creates the pending renewal lease.
+CREATE TABLE renewal_offers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    proposal_id INT REFERENCES rent_increase_proposals(id) ON DELETE SET NULL, -- Approved proposal the terms came from
+    proposed_rent DECIMAL(10, 2) NOT NULL,
+    term_months INT NOT NULL DEFAULT 12,
+    offer_expires_at DATE NOT NULL, -- Last day the tenant can respond
+    status VARCHAR(20) NOT NULL DEFAULT 'sent', -- 'sent', 'accepted', 'declined', 'expired', 'withdrawn'
+    viewed_at DATETIME, -- First time the tenant opened the offer in the portal
+    responded_at DATETIME,
+    decline_reason TEXT,
+    renewal_lease_id INT REFERENCES leases(id) ON DELETE SET NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL, -- NULL when generated automatically
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_renewal_offers_lease_id ON renewal_offers(lease_id);
+CREATE INDEX idx_renewal_offers_status ON renewal_offers(status);
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Renewals Funnel', 'Expiring leases, renewal offers sent, viewed, accepted and declined', 'tenant',
+ '{"data_source": "leases", "report_type": "renewals_funnel", "metrics": ["offers", "accepted", "acceptance_rate"]}', true);
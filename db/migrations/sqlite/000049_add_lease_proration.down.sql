@@ -0,0 +1 @@
+ALTER TABLE leases DROP COLUMN proration_convention;
@@ -0,0 +1,68 @@
+-- Delinquency stages of the collections workflow. Each stage is actioned once per case when the
+-- tenant's oldest unpaid rent has been past due for days_past_due days.
+CREATE TABLE collection_stages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(50) UNIQUE NOT NULL,
+    days_past_due INT NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('reminder', 'formal_notice')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO collection_stages (name, days_past_due, action) VALUES
+('first_reminder', 5, 'reminder'),
+('second_reminder', 15, 'reminder'),
+('formal_notice', 30, 'formal_notice');
+
+-- Collection cases follow a lease from the day its rent falls past due until it is paid up
+CREATE TABLE collection_cases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- 'open', 'promised', 'resolved'
+    past_due DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    days_past_due INT NOT NULL DEFAULT 0,
+    stage VARCHAR(50), -- Last stage actioned
+    stage_days INT NOT NULL DEFAULT 0, -- days_past_due of the last stage actioned
+    last_action_at DATETIME,
+    opened_at DATETIME NOT NULL,
+    resolved_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_collection_cases_open_lease ON collection_cases(lease_id) WHERE status <> 'resolved';
+CREATE INDEX idx_collection_cases_status ON collection_cases(status);
+
+-- Promises to pay pause a case's escalation until promised_date. A promise is kept when the
+-- payments made from recorded_on cover its amount.
+CREATE TABLE collection_promises (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    case_id INT NOT NULL REFERENCES collection_cases(id) ON DELETE CASCADE,
+    amount DECIMAL(10, 2) NOT NULL,
+    promised_date DATE NOT NULL,
+    recorded_on DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'kept', 'broken'
+    notes TEXT,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    settled_at DATETIME
+);
+
+CREATE INDEX idx_collection_promises_case_id ON collection_promises(case_id);
+
+-- Formal notices issued to delinquent tenants, with the balance at the time of issue
+CREATE TABLE collection_notices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    case_id INT NOT NULL REFERENCES collection_cases(id) ON DELETE CASCADE,
+    stage VARCHAR(50) NOT NULL,
+    amount_due DECIMAL(10, 2) NOT NULL,
+    days_past_due INT NOT NULL,
+    issued_by INT REFERENCES users(id) ON DELETE SET NULL, -- NULL when issued automatically
+    issued_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_collection_notices_case_id ON collection_notices(case_id);
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Collections Status', 'Delinquent leases by collections stage, with promises to pay and notices', 'financial',
+ '{"data_source": "collection_cases", "report_type": "collections_status", "metrics": ["past_due", "days_past_due"]}', true);
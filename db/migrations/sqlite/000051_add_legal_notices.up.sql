@@ -0,0 +1,80 @@
+-- Jurisdiction whose notice templates apply to the property, e.g. 'California'. NULL uses the
+-- default templates.
+ALTER TABLE properties ADD COLUMN jurisdiction VARCHAR(100);
+
+-- Notice templates per notice type and jurisdiction. The body is a Go text/template merged with the
+-- tenant and lease; the row with an empty jurisdiction is the default for the notice type.
+CREATE TABLE notice_templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    notice_type VARCHAR(30) NOT NULL CHECK (notice_type IN ('late_rent', 'lease_violation', 'entry')),
+    jurisdiction VARCHAR(100) NOT NULL DEFAULT '',
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    notice_period_days INT NOT NULL DEFAULT 0, -- Days the tenant has to pay or cure, or notice given before entry
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (notice_type, jurisdiction)
+);
+
+INSERT INTO notice_templates (notice_type, jurisdiction, title, body, notice_period_days) VALUES
+('late_rent', '', 'Notice of Late Rent',
+ 'To {{.TenantName}}, tenant of {{.Premises}}:
+
+Rent of {{money .AmountDue}} under your lease dated {{date .LeaseStart}} is past due. Please pay this amount in full by {{date .Deadline}}.
+
+If the rent is not paid by that date, the landlord may begin proceedings to recover the rent and possession of the premises.', 5),
+('lease_violation', '', 'Notice of Lease Violation',
+ 'To {{.TenantName}}, tenant of {{.Premises}}:
+
+You are in violation of your lease dated {{date .LeaseStart}}:
+
+{{.Description}}
+
+Please correct this violation by {{date .Deadline}}. If it is not corrected, the landlord may take further action under the lease.', 10),
+('entry', '', 'Notice of Intent to Enter',
+ 'To {{.TenantName}}, tenant of {{.Premises}}:
+
+The landlord or its agents will enter the premises on {{date .EntryDate}}{{if .EntryWindow}} between {{.EntryWindow}}{{end}} for the following purpose:
+
+{{.Description}}', 1),
+('late_rent', 'California', '3-Day Notice to Pay Rent or Quit',
+ 'To {{.TenantName}}, tenant in possession of {{.Premises}}:
+
+Within three days, excluding Saturdays, Sundays and judicial holidays, after service of this notice you are required to pay rent of {{money .AmountDue}} now due, or deliver up possession of the premises.
+
+If you fail to do so, legal proceedings will be instituted against you to recover possession of the premises, declare the forfeiture of the lease under which you occupy them, and recover rents and damages.', 3);
+
+-- Notices generated for tenants and how they were delivered
+CREATE TABLE legal_notices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    template_id INT REFERENCES notice_templates(id) ON DELETE SET NULL,
+    notice_type VARCHAR(30) NOT NULL,
+    jurisdiction VARCHAR(100) NOT NULL DEFAULT '',
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL, -- Merged text, kept as issued
+    deadline DATE, -- Date to pay or cure by, or of entry
+    document_url TEXT NOT NULL,
+    delivery_method VARCHAR(20) NOT NULL CHECK (delivery_method IN ('email', 'hand_delivery', 'posted', 'certified_mail')),
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_legal_notices_tenant_id ON legal_notices(tenant_id);
+CREATE INDEX idx_legal_notices_lease_id ON legal_notices(lease_id);
+
+-- Dated events in a tenant's history, newest first on the tenant timeline
+CREATE TABLE tenant_timeline_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    lease_id INT REFERENCES leases(id) ON DELETE SET NULL,
+    event_type VARCHAR(50) NOT NULL, -- e.g. 'legal_notice'
+    summary TEXT NOT NULL,
+    reference_type VARCHAR(50), -- Record the event is about, e.g. 'legal_notice'
+    reference_id INT,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    occurred_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_tenant_timeline_events_tenant_id ON tenant_timeline_events(tenant_id, occurred_at);
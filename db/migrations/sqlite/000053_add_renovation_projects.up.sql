@@ -0,0 +1,24 @@
+-- Capital projects on a whole property, or on one unit when unit_id is set. A project is complete
+-- once its end date has passed; rent, occupancy and maintenance before its start and after its
+-- end are compared to measure its return.
+CREATE TABLE renovation_projects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    property_id INT NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    unit_id INT REFERENCES property_units(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    scope TEXT, -- e.g. 'Kitchen remodel with new appliances'
+    budget DECIMAL(12, 2) NOT NULL CHECK (budget >= 0),
+    actual_cost DECIMAL(12, 2) CHECK (actual_cost >= 0),
+    start_date DATE NOT NULL,
+    end_date DATE CHECK (end_date >= start_date),
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_renovation_projects_property ON renovation_projects(property_id);
+CREATE INDEX idx_renovation_projects_unit ON renovation_projects(unit_id);
+
+INSERT INTO report_templates (name, description, category, template_config, is_system) VALUES
+('Renovation ROI', 'Rent, occupancy and maintenance cost before and after each completed renovation, with its return on cost', 'financial',
+ '{"data_source": "renovation_projects", "report_type": "renovation_roi", "metrics": ["average_rent", "occupancy", "maintenance_cost", "roi"]}', true);
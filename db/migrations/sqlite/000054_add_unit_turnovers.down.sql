@@ -0,0 +1,3 @@
+DELETE FROM report_templates WHERE name = 'Unit Turnover' AND is_system = true;
+
+DROP TABLE IF EXISTS unit_turnovers;
@@ -0,0 +1,4 @@
+ALTER TABLE report_subscriptions DROP COLUMN timezone;
+ALTER TABLE custom_reports DROP COLUMN schedule_timezone;
+ALTER TABLE properties DROP COLUMN timezone;
+ALTER TABLE organizations DROP COLUMN timezone;
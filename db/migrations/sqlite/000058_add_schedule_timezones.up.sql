@@ -0,0 +1,7 @@
+-- IANA time zone names, such as America/New_York. Schedules run on the local wall clock of the
+-- most specific zone set: a report's or subscription's own, then its property's or organization's.
+-- Where none is set they keep running in UTC.
+ALTER TABLE organizations ADD COLUMN timezone VARCHAR(64);
+ALTER TABLE properties ADD COLUMN timezone VARCHAR(64);
+ALTER TABLE custom_reports ADD COLUMN schedule_timezone VARCHAR(64);
+ALTER TABLE report_subscriptions ADD COLUMN timezone VARCHAR(64);
@@ -0,0 +1,14 @@
+-- Incident notes admins post to the public status page
+CREATE TABLE status_incidents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'investigating', -- 'investigating', 'identified', 'monitoring', 'resolved'
+    component VARCHAR(100), -- Affected status page component, if any
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_status_incidents_resolved_at ON status_incidents(resolved_at);
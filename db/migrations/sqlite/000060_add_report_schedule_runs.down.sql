@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_custom_reports_next_run;
+
+ALTER TABLE custom_reports DROP COLUMN last_failed_at;
+ALTER TABLE custom_reports DROP COLUMN last_error;
+ALTER TABLE custom_reports DROP COLUMN retry_count;
+ALTER TABLE custom_reports DROP COLUMN next_run;
+ALTER TABLE custom_reports DROP COLUMN schedule_format;
@@ -0,0 +1 @@
+ALTER TABLE custom_reports DROP COLUMN confidentiality;
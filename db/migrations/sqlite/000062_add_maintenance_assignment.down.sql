@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_maintenance_requests_assigned_to;
+
+-- SQLite cannot drop a column that takes part in a foreign key, so
+-- maintenance_requests.assigned_to is left in place.
+ALTER TABLE maintenance_requests DROP COLUMN assigned_at;
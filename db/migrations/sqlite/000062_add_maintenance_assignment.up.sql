@@ -0,0 +1,6 @@
+-- Who a maintenance request is assigned to. Requests move reported -> assigned -> in_progress ->
+-- completed; assigning sets both columns.
+ALTER TABLE maintenance_requests ADD COLUMN assigned_to INT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE maintenance_requests ADD COLUMN assigned_at DATETIME;
+
+CREATE INDEX idx_maintenance_requests_assigned_to ON maintenance_requests(assigned_to);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS export_storage_policies;
+ALTER TABLE report_executions DROP COLUMN file_size;
@@ -0,0 +1,13 @@
+-- Size of each stored report output, so organizations can be held to a storage budget.
+-- Files stored before sizes were recorded count as empty.
+ALTER TABLE report_executions ADD COLUMN file_size BIGINT;
+
+-- How much stored report output an organization may keep and for how long. max_bytes 0 means
+-- unlimited; a NULL retention_days uses the server default and 0 keeps files until deleted.
+CREATE TABLE export_storage_policies (
+    organization_id INT PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    max_bytes BIGINT NOT NULL DEFAULT 0 CHECK (max_bytes >= 0),
+    retention_days INT CHECK (retention_days >= 0),
+    updated_by INT REFERENCES users(id) ON DELETE SET NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,18 @@
+-- Grants of sensitive roles wait here until a second admin approves or rejects them. Approved
+-- requests are copied into user_roles; the row stays as the record of who asked and who decided.
+CREATE TABLE role_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    expires_at DATETIME,
+    requested_by INT REFERENCES users(id) ON DELETE SET NULL,
+    requested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    decided_by INT REFERENCES users(id) ON DELETE SET NULL,
+    decided_at DATETIME,
+    reason TEXT
+);
+
+-- At most one pending request per user and role
+CREATE UNIQUE INDEX idx_role_requests_pending ON role_requests(user_id, role_id) WHERE status = 'pending';
+CREATE INDEX idx_role_requests_status ON role_requests(status, requested_at);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_report_templates_status;
+
+-- SQLite cannot drop a column that takes part in a foreign key, so report_templates.reviewed_by,
+-- organization_id and source_report_id are left in place.
+ALTER TABLE report_templates DROP COLUMN review_note;
+ALTER TABLE report_templates DROP COLUMN reviewed_at;
+ALTER TABLE report_templates DROP COLUMN status;
+ALTER TABLE report_templates DROP COLUMN install_count;
+ALTER TABLE report_templates DROP COLUMN sample_data;
+ALTER TABLE report_templates DROP COLUMN screenshot_url;
@@ -0,0 +1,110 @@
+DROP TRIGGER IF EXISTS properties_entity_changes_insert;
+CREATE TRIGGER properties_entity_changes_insert AFTER INSERT ON properties
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('property', NEW.id, 'insert');
+END;
+
+DROP TRIGGER IF EXISTS properties_entity_changes_update;
+CREATE TRIGGER properties_entity_changes_update AFTER UPDATE ON properties
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('property', NEW.id, 'update');
+END;
+
+DROP TRIGGER IF EXISTS properties_entity_changes_delete;
+CREATE TRIGGER properties_entity_changes_delete AFTER DELETE ON properties
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('property', OLD.id, 'delete');
+END;
+
+DROP TRIGGER IF EXISTS property_units_entity_changes_insert;
+CREATE TRIGGER property_units_entity_changes_insert AFTER INSERT ON property_units
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('unit', NEW.id, 'insert');
+END;
+
+DROP TRIGGER IF EXISTS property_units_entity_changes_update;
+CREATE TRIGGER property_units_entity_changes_update AFTER UPDATE ON property_units
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('unit', NEW.id, 'update');
+END;
+
+DROP TRIGGER IF EXISTS property_units_entity_changes_delete;
+CREATE TRIGGER property_units_entity_changes_delete AFTER DELETE ON property_units
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('unit', OLD.id, 'delete');
+END;
+
+DROP TRIGGER IF EXISTS tenants_entity_changes_insert;
+CREATE TRIGGER tenants_entity_changes_insert AFTER INSERT ON tenants
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('tenant', NEW.id, 'insert');
+END;
+
+DROP TRIGGER IF EXISTS tenants_entity_changes_update;
+CREATE TRIGGER tenants_entity_changes_update AFTER UPDATE ON tenants
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('tenant', NEW.id, 'update');
+END;
+
+DROP TRIGGER IF EXISTS tenants_entity_changes_delete;
+CREATE TRIGGER tenants_entity_changes_delete AFTER DELETE ON tenants
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('tenant', OLD.id, 'delete');
+END;
+
+DROP TRIGGER IF EXISTS leases_entity_changes_insert;
+CREATE TRIGGER leases_entity_changes_insert AFTER INSERT ON leases
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('lease', NEW.id, 'insert');
+END;
+
+DROP TRIGGER IF EXISTS leases_entity_changes_update;
+CREATE TRIGGER leases_entity_changes_update AFTER UPDATE ON leases
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('lease', NEW.id, 'update');
+END;
+
+DROP TRIGGER IF EXISTS leases_entity_changes_delete;
+CREATE TRIGGER leases_entity_changes_delete AFTER DELETE ON leases
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('lease', OLD.id, 'delete');
+END;
+
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes_insert;
+CREATE TRIGGER maintenance_requests_entity_changes_insert AFTER INSERT ON maintenance_requests
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('maintenance_request', NEW.id, 'insert');
+END;
+
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes_update;
+CREATE TRIGGER maintenance_requests_entity_changes_update AFTER UPDATE ON maintenance_requests
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('maintenance_request', NEW.id, 'update');
+END;
+
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes_delete;
+CREATE TRIGGER maintenance_requests_entity_changes_delete AFTER DELETE ON maintenance_requests
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('maintenance_request', OLD.id, 'delete');
+END;
+
+DROP TRIGGER IF EXISTS payments_entity_changes_insert;
+CREATE TRIGGER payments_entity_changes_insert AFTER INSERT ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('payment', NEW.id, 'insert');
+END;
+
+DROP TRIGGER IF EXISTS payments_entity_changes_update;
+CREATE TRIGGER payments_entity_changes_update AFTER UPDATE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('payment', NEW.id, 'update');
+END;
+
+DROP TRIGGER IF EXISTS payments_entity_changes_delete;
+CREATE TRIGGER payments_entity_changes_delete AFTER DELETE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op) VALUES ('payment', OLD.id, 'delete');
+END;
+
+DROP INDEX IF EXISTS idx_entity_changes_property;
+ALTER TABLE entity_changes DROP COLUMN property_id;
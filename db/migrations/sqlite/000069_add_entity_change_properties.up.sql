@@ -0,0 +1,144 @@
+-- Record the property each change belongs to so the change feed can be limited to the properties
+-- a manager's regions and portfolios cover. Tenants are not tied to one property and stay NULL.
+ALTER TABLE entity_changes ADD COLUMN property_id INT;
+
+CREATE INDEX idx_entity_changes_property ON entity_changes(property_id, id);
+
+DROP TRIGGER IF EXISTS properties_entity_changes_insert;
+CREATE TRIGGER properties_entity_changes_insert AFTER INSERT ON properties
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('property', NEW.id, 'insert', NEW.id);
+END;
+
+DROP TRIGGER IF EXISTS properties_entity_changes_update;
+CREATE TRIGGER properties_entity_changes_update AFTER UPDATE ON properties
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('property', NEW.id, 'update', NEW.id);
+END;
+
+DROP TRIGGER IF EXISTS properties_entity_changes_delete;
+CREATE TRIGGER properties_entity_changes_delete AFTER DELETE ON properties
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('property', OLD.id, 'delete', OLD.id);
+END;
+
+DROP TRIGGER IF EXISTS property_units_entity_changes_insert;
+CREATE TRIGGER property_units_entity_changes_insert AFTER INSERT ON property_units
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('unit', NEW.id, 'insert', NEW.property_id);
+END;
+
+DROP TRIGGER IF EXISTS property_units_entity_changes_update;
+CREATE TRIGGER property_units_entity_changes_update AFTER UPDATE ON property_units
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('unit', NEW.id, 'update', NEW.property_id);
+END;
+
+DROP TRIGGER IF EXISTS property_units_entity_changes_delete;
+CREATE TRIGGER property_units_entity_changes_delete AFTER DELETE ON property_units
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('unit', OLD.id, 'delete', OLD.property_id);
+END;
+
+DROP TRIGGER IF EXISTS tenants_entity_changes_insert;
+CREATE TRIGGER tenants_entity_changes_insert AFTER INSERT ON tenants
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('tenant', NEW.id, 'insert', NULL);
+END;
+
+DROP TRIGGER IF EXISTS tenants_entity_changes_update;
+CREATE TRIGGER tenants_entity_changes_update AFTER UPDATE ON tenants
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('tenant', NEW.id, 'update', NULL);
+END;
+
+DROP TRIGGER IF EXISTS tenants_entity_changes_delete;
+CREATE TRIGGER tenants_entity_changes_delete AFTER DELETE ON tenants
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('tenant', OLD.id, 'delete', NULL);
+END;
+
+DROP TRIGGER IF EXISTS leases_entity_changes_insert;
+CREATE TRIGGER leases_entity_changes_insert AFTER INSERT ON leases
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('lease', NEW.id, 'insert', (SELECT property_id FROM property_units WHERE id = NEW.unit_id));
+END;
+
+DROP TRIGGER IF EXISTS leases_entity_changes_update;
+CREATE TRIGGER leases_entity_changes_update AFTER UPDATE ON leases
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('lease', NEW.id, 'update', (SELECT property_id FROM property_units WHERE id = NEW.unit_id));
+END;
+
+DROP TRIGGER IF EXISTS leases_entity_changes_delete;
+CREATE TRIGGER leases_entity_changes_delete AFTER DELETE ON leases
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('lease', OLD.id, 'delete', (SELECT property_id FROM property_units WHERE id = OLD.unit_id));
+END;
+
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes_insert;
+CREATE TRIGGER maintenance_requests_entity_changes_insert AFTER INSERT ON maintenance_requests
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('maintenance_request', NEW.id, 'insert', NEW.property_id);
+END;
+
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes_update;
+CREATE TRIGGER maintenance_requests_entity_changes_update AFTER UPDATE ON maintenance_requests
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('maintenance_request', NEW.id, 'update', NEW.property_id);
+END;
+
+DROP TRIGGER IF EXISTS maintenance_requests_entity_changes_delete;
+CREATE TRIGGER maintenance_requests_entity_changes_delete AFTER DELETE ON maintenance_requests
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('maintenance_request', OLD.id, 'delete', OLD.property_id);
+END;
+
+DROP TRIGGER IF EXISTS payments_entity_changes_insert;
+CREATE TRIGGER payments_entity_changes_insert AFTER INSERT ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('payment', NEW.id, 'insert', (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = NEW.lease_id));
+END;
+
+DROP TRIGGER IF EXISTS payments_entity_changes_update;
+CREATE TRIGGER payments_entity_changes_update AFTER UPDATE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('payment', NEW.id, 'update', (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = NEW.lease_id));
+END;
+
+DROP TRIGGER IF EXISTS payments_entity_changes_delete;
+CREATE TRIGGER payments_entity_changes_delete AFTER DELETE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('payment', OLD.id, 'delete', (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = OLD.lease_id));
+END;
+
+-- Backfill the changes of entities that still exist
+UPDATE entity_changes SET property_id = entity_id WHERE entity_type = 'property';
+UPDATE entity_changes SET property_id = (SELECT property_id FROM property_units WHERE id = entity_changes.entity_id)
+    WHERE entity_type = 'unit';
+UPDATE entity_changes SET property_id = (SELECT property_id FROM maintenance_requests WHERE id = entity_changes.entity_id)
+    WHERE entity_type = 'maintenance_request';
+UPDATE entity_changes SET property_id = (SELECT pu.property_id FROM leases l
+    JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = entity_changes.entity_id)
+    WHERE entity_type = 'lease';
+UPDATE entity_changes SET property_id = (SELECT pu.property_id FROM payments p JOIN leases l ON l.id = p.lease_id
+    JOIN property_units pu ON pu.id = l.unit_id WHERE p.id = entity_changes.entity_id)
+    WHERE entity_type = 'payment';
@@ -0,0 +1,6 @@
+-- SQLite serialises writers, so changes commit in ID order and every change keeps txid 0.
+-- The column only exists so the change feed query is the same on both databases.
+ALTER TABLE entity_changes ADD COLUMN txid INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_entity_changes_txid ON entity_changes(txid, id);
+CREATE INDEX idx_entity_changes_changed_at ON entity_changes(changed_at);
@@ -0,0 +1 @@
+ALTER TABLE maintenance_requests DROP COLUMN oncall_escalated_at;
@@ -0,0 +1,2 @@
+ALTER TABLE import_jobs DROP COLUMN attempts;
+ALTER TABLE import_jobs DROP COLUMN lease_expires_at;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_maintenance_requests_plan_due;
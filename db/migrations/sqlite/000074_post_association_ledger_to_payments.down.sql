@@ -0,0 +1,95 @@
+CREATE TABLE association_charges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INT NOT NULL REFERENCES association_owners(id) ON DELETE RESTRICT,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('dues', 'fine')),
+    assessment_id INT REFERENCES dues_assessments(id) ON DELETE SET NULL,
+    violation_id INT REFERENCES association_violations(id) ON DELETE SET NULL,
+    description VARCHAR(255) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    due_date DATE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (assessment_id, owner_id, due_date)
+);
+
+CREATE INDEX idx_association_charges_owner_id ON association_charges(owner_id);
+
+CREATE TABLE association_payments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INT NOT NULL REFERENCES association_owners(id) ON DELETE RESTRICT,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    payment_date DATE NOT NULL,
+    payment_method VARCHAR(50),
+    reference VARCHAR(100),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_association_payments_owner_id ON association_payments(owner_id);
+
+INSERT INTO association_charges (owner_id, kind, assessment_id, violation_id, description, amount, due_date, created_at)
+SELECT association_owner_id, fee_type, assessment_id, violation_id, description, amount, fee_date, created_at
+FROM lease_fees WHERE association_owner_id IS NOT NULL ORDER BY id;
+
+-- The association ledger only holds completed payments; adjusting entries are dropped
+INSERT INTO association_payments (owner_id, amount, payment_date, payment_method, reference, created_at)
+SELECT association_owner_id, amount, payment_date, payment_method, reference, created_at
+FROM payments WHERE association_owner_id IS NOT NULL AND status = 'completed' AND amount > 0 ORDER BY id;
+
+CREATE TABLE lease_fees_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE CASCADE,
+    fee_type VARCHAR(30) NOT NULL, -- e.g. 'returned_payment'
+    description TEXT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    fee_date DATE NOT NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO lease_fees_old (id, lease_id, fee_type, description, amount, fee_date, created_by, created_at)
+SELECT id, lease_id, fee_type, description, amount, fee_date, created_by, created_at
+FROM lease_fees WHERE lease_id IS NOT NULL;
+
+DROP TABLE lease_fees;
+ALTER TABLE lease_fees_old RENAME TO lease_fees;
+
+CREATE INDEX idx_lease_fees_lease_id ON lease_fees(lease_id);
+
+CREATE TABLE payments_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT NOT NULL REFERENCES leases(id) ON DELETE RESTRICT,
+    amount DECIMAL(10, 2) NOT NULL,
+    payment_date DATE NOT NULL,
+    payment_method VARCHAR(50), -- e.g., 'Credit Card', 'Bank Transfer'
+    status VARCHAR(50) NOT NULL DEFAULT 'completed', -- e.g., 'completed', 'pending', 'failed'
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    adjusts_payment_id INT REFERENCES payments(id) ON DELETE RESTRICT,
+    adjustment_reason TEXT
+);
+
+INSERT INTO payments_old (id, lease_id, amount, payment_date, payment_method, status, created_at, adjusts_payment_id, adjustment_reason)
+SELECT id, lease_id, amount, payment_date, payment_method, status, created_at, adjusts_payment_id, adjustment_reason
+FROM payments WHERE lease_id IS NOT NULL;
+
+DROP TABLE payments;
+ALTER TABLE payments_old RENAME TO payments;
+
+CREATE TRIGGER payments_entity_changes_insert AFTER INSERT ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('payment', NEW.id, 'insert', (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = NEW.lease_id));
+END;
+
+CREATE TRIGGER payments_entity_changes_update AFTER UPDATE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('payment', NEW.id, 'update', (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = NEW.lease_id));
+END;
+
+CREATE TRIGGER payments_entity_changes_delete AFTER DELETE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('payment', OLD.id, 'delete', (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = OLD.lease_id));
+END;
+
+UPDATE report_templates SET template_config = '{"data_source": "association_charges", "report_type": "dues_delinquency", "metrics": ["past_due", "days_delinquent"]}'
+WHERE name = 'Dues Delinquency' AND is_system = true;
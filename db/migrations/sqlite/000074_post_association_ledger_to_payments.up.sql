@@ -0,0 +1,92 @@
+-- Association owners are charged and pay through the same ledger as leases: dues and fines are
+-- lease_fees rows and owner payments are payments rows, each naming an owner instead of a lease.
+-- SQLite cannot relax NOT NULL in place, so both tables are rebuilt.
+CREATE TABLE payments_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT REFERENCES leases(id) ON DELETE RESTRICT,
+    association_owner_id INT REFERENCES association_owners(id) ON DELETE RESTRICT,
+    amount DECIMAL(10, 2) NOT NULL,
+    payment_date DATE NOT NULL,
+    payment_method VARCHAR(50), -- e.g., 'Credit Card', 'Bank Transfer'
+    reference VARCHAR(100),
+    status VARCHAR(50) NOT NULL DEFAULT 'completed', -- e.g., 'completed', 'pending', 'failed'
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    adjusts_payment_id INT REFERENCES payments(id) ON DELETE RESTRICT,
+    adjustment_reason TEXT,
+    CHECK ((lease_id IS NULL) <> (association_owner_id IS NULL))
+);
+
+INSERT INTO payments_new (id, lease_id, amount, payment_date, payment_method, status, created_at, adjusts_payment_id, adjustment_reason)
+SELECT id, lease_id, amount, payment_date, payment_method, status, created_at, adjusts_payment_id, adjustment_reason
+FROM payments;
+
+DROP TABLE payments;
+ALTER TABLE payments_new RENAME TO payments;
+
+CREATE INDEX idx_payments_association_owner_id ON payments(association_owner_id);
+
+-- Owner payments belong to the property of the owner's unit
+CREATE TRIGGER payments_entity_changes_insert AFTER INSERT ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('payment', NEW.id, 'insert', COALESCE(
+        (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = NEW.lease_id),
+        (SELECT pu.property_id FROM association_owners ao JOIN property_units pu ON pu.id = ao.unit_id WHERE ao.id = NEW.association_owner_id)));
+END;
+
+CREATE TRIGGER payments_entity_changes_update AFTER UPDATE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('payment', NEW.id, 'update', COALESCE(
+        (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = NEW.lease_id),
+        (SELECT pu.property_id FROM association_owners ao JOIN property_units pu ON pu.id = ao.unit_id WHERE ao.id = NEW.association_owner_id)));
+END;
+
+CREATE TRIGGER payments_entity_changes_delete AFTER DELETE ON payments
+BEGIN
+    INSERT INTO entity_changes (entity_type, entity_id, op, property_id)
+    VALUES ('payment', OLD.id, 'delete', COALESCE(
+        (SELECT pu.property_id FROM leases l JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = OLD.lease_id),
+        (SELECT pu.property_id FROM association_owners ao JOIN property_units pu ON pu.id = ao.unit_id WHERE ao.id = OLD.association_owner_id)));
+END;
+
+CREATE TABLE lease_fees_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lease_id INT REFERENCES leases(id) ON DELETE CASCADE,
+    association_owner_id INT REFERENCES association_owners(id) ON DELETE RESTRICT,
+    fee_type VARCHAR(30) NOT NULL, -- e.g. 'returned_payment', or 'dues' and 'fine' for owners
+    assessment_id INT REFERENCES dues_assessments(id) ON DELETE SET NULL,
+    violation_id INT REFERENCES association_violations(id) ON DELETE SET NULL,
+    description TEXT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    fee_date DATE NOT NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((lease_id IS NULL) <> (association_owner_id IS NULL))
+);
+
+INSERT INTO lease_fees_new (id, lease_id, fee_type, description, amount, fee_date, created_by, created_at)
+SELECT id, lease_id, fee_type, description, amount, fee_date, created_by, created_at
+FROM lease_fees;
+
+DROP TABLE lease_fees;
+ALTER TABLE lease_fees_new RENAME TO lease_fees;
+
+CREATE INDEX idx_lease_fees_lease_id ON lease_fees(lease_id);
+CREATE INDEX idx_lease_fees_association_owner_id ON lease_fees(association_owner_id);
+-- Posting an assessment is idempotent per owner and due date
+CREATE UNIQUE INDEX idx_lease_fees_assessment_due ON lease_fees(assessment_id, association_owner_id, fee_date);
+
+INSERT INTO lease_fees (association_owner_id, fee_type, assessment_id, violation_id, description, amount, fee_date, created_at)
+SELECT owner_id, kind, assessment_id, violation_id, description, amount, due_date, created_at
+FROM association_charges ORDER BY id;
+
+INSERT INTO payments (association_owner_id, amount, payment_date, payment_method, reference, status, created_at)
+SELECT owner_id, amount, payment_date, payment_method, reference, 'completed', created_at
+FROM association_payments ORDER BY id;
+
+DROP TABLE association_charges;
+DROP TABLE association_payments;
+
+UPDATE report_templates SET template_config = '{"data_source": "lease_fees", "report_type": "dues_delinquency", "metrics": ["past_due", "days_delinquent"]}'
+WHERE name = 'Dues Delinquency' AND is_system = true;
@@ -0,0 +1,128 @@
+package ach
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// ErrInvalidSignature is returned for webhook callbacks that fail the provider's authenticity check
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Transfer statuses reported by providers
+const (
+	StatusSettled  = "settled"
+	StatusReturned = "returned"
+)
+
+// DefaultReturnFee is the fee charged for a returned debit unless ACH_RETURN_FEE sets another
+const DefaultReturnFee = 25.0
+
+// BankAccount is an account the tenant linked through the provider's account-linking flow
+type BankAccount struct {
+	ExternalID      string
+	InstitutionName string
+	Mask            string // Last four digits of the account number
+	AccountType     string // checking or savings
+}
+
+// Debit asks the provider to pull funds from a linked account
+type Debit struct {
+	AccountID      string // Provider's token for the account
+	Amount         float64
+	Description    string // Shown on the tenant's bank statement
+	IdempotencyKey string // Resubmitting with the same key never debits twice
+}
+
+// Event is a transfer status update pushed by the provider
+type Event struct {
+	TransferID   string
+	Status       string
+	ReturnCode   string // NACHA return reason code for returns, e.g. R01
+	ReturnReason string
+}
+
+// Provider moves money by ACH through a bank-transfer service (Plaid, Dwolla and the like)
+type Provider interface {
+	// Name identifies the provider in webhook URLs and stored records
+	Name() string
+	// LinkAccount exchanges the token from the provider's account-linking widget for the account
+	LinkAccount(ctx context.Context, publicToken string) (*BankAccount, error)
+	// Debit starts a debit and returns the provider's ID for the transfer
+	Debit(ctx context.Context, debit Debit) (string, error)
+	// ParseWebhook authenticates a callback and returns the status update it reports
+	ParseWebhook(r *http.Request) (*Event, error)
+}
+
+// NewProviderFromEnv configures the provider named by ACH_PROVIDER. Only the sandbox provider is
+// built in. It returns nil when ACH payments are not configured.
+func NewProviderFromEnv() Provider {
+	switch strings.ToLower(os.Getenv("ACH_PROVIDER")) {
+	case "sandbox":
+		return &Sandbox{WebhookSecret: os.Getenv("ACH_WEBHOOK_SECRET")}
+	}
+	return nil
+}
+
+// ReturnFeeFromEnv returns the fee charged for a returned debit, set by ACH_RETURN_FEE (default
+// DefaultReturnFee). Set it to 0 to charge no fee.
+func ReturnFeeFromEnv() float64 {
+	if fee, err := strconv.ParseFloat(os.Getenv("ACH_RETURN_FEE"), 64); err == nil && fee >= 0 {
+		return fee
+	}
+	return DefaultReturnFee
+}
+
+// unauthorizedReturnCodes are returns where the account holder disputes the debit. No fee is
+// charged for them.
+var unauthorizedReturnCodes = map[string]bool{
+	"R05": true, // Unauthorized debit to a consumer account
+	"R07": true, // Authorization revoked by the customer
+	"R10": true, // Customer advises not authorized
+	"R29": true, // Corporate customer advises not authorized
+}
+
+// mandateEndingReturnCodes are returns after which the account cannot be debited again: it is
+// closed, missing, frozen or the tenant withdrew authorization
+var mandateEndingReturnCodes = map[string]bool{
+	"R02": true, // Account closed
+	"R03": true, // No account / unable to locate account
+	"R04": true, // Invalid account number
+	"R05": true,
+	"R07": true,
+	"R08": true, // Payment stopped
+	"R10": true,
+	"R16": true, // Account frozen
+	"R20": true, // Non-transaction account
+	"R29": true,
+}
+
+// ReturnPolicy decides how a return is handled: returns for insufficient or uncollected funds
+// keep the mandate and charge fee, disputed debits charge nothing, and returns from closed,
+// invalid or frozen accounts end the mandate
+func ReturnPolicy(code, reason string, fee float64) models.ACHReturn {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	ret := models.ACHReturn{Code: code, Reason: reason, Fee: fee, RevokeMandate: mandateEndingReturnCodes[code]}
+	if unauthorizedReturnCodes[code] {
+		ret.Fee = 0
+	}
+	return ret
+}
+
+// Apply records a transfer status update against its debit. Updates that do not change the
+// debit, such as a repeated callback, return sql.ErrNoRows.
+func Apply(debit *models.ACHDebit, event *Event, fee float64, now time.Time) error {
+	switch event.Status {
+	case StatusSettled:
+		return models.SettleACHDebit(debit.ID)
+	case StatusReturned:
+		return models.ReturnACHDebit(debit.ID, ReturnPolicy(event.ReturnCode, event.ReturnReason, fee), now)
+	}
+	return errors.New("unknown ACH transfer status " + event.Status)
+}
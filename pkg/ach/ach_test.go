@@ -0,0 +1,83 @@
+package ach
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxLinkAccount(t *testing.T) {
+	s := &Sandbox{}
+	ctx := context.Background()
+
+	account, err := s.LinkAccount(ctx, "public-sandbox-6789")
+	require.NoError(t, err)
+	assert.Equal(t, "6789", account.Mask)
+	assert.Equal(t, "checking", account.AccountType)
+	assert.True(t, strings.HasPrefix(account.ExternalID, "acct_sbx_"))
+
+	account, err = s.LinkAccount(ctx, "public-sandbox-abc-savings")
+	require.NoError(t, err)
+	assert.Equal(t, "0000", account.Mask)
+	assert.Equal(t, "savings", account.AccountType)
+
+	_, err = s.LinkAccount(ctx, "public-production-1234")
+	assert.Error(t, err)
+
+	_, err = s.Debit(ctx, Debit{AccountID: account.ExternalID})
+	assert.Error(t, err, "debits need a positive amount")
+}
+
+func TestSandboxWebhookSignature(t *testing.T) {
+	s := &Sandbox{WebhookSecret: "secret"}
+	body := `{"transfer_id":"tr_sbx_01","status":"returned","return_code":"R01","return_reason":"Insufficient funds"}`
+
+	req := httptest.NewRequest("POST", "/api/ach/webhook/sandbox", strings.NewReader(body))
+	req.Header.Set("X-Sandbox-Signature", "bad")
+	_, err := s.ParseWebhook(req)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	req = httptest.NewRequest("POST", "/api/ach/webhook/sandbox", strings.NewReader(body))
+	req.Header.Set("X-Sandbox-Signature", hex.EncodeToString(mac.Sum(nil)))
+	event, err := s.ParseWebhook(req)
+	require.NoError(t, err)
+	assert.Equal(t, "tr_sbx_01", event.TransferID)
+	assert.Equal(t, StatusReturned, event.Status)
+	assert.Equal(t, "R01", event.ReturnCode)
+}
+
+func TestReturnPolicy(t *testing.T) {
+	// Insufficient funds: fee charged, mandate kept
+	ret := ReturnPolicy("r01", "Insufficient funds", 25)
+	assert.Equal(t, "R01", ret.Code)
+	assert.Equal(t, 25.0, ret.Fee)
+	assert.False(t, ret.RevokeMandate)
+
+	// Closed account: fee charged, mandate ended
+	ret = ReturnPolicy("R02", "Account closed", 25)
+	assert.Equal(t, 25.0, ret.Fee)
+	assert.True(t, ret.RevokeMandate)
+
+	// Disputed debit: no fee, mandate ended
+	ret = ReturnPolicy("R10", "Customer advises not authorized", 25)
+	assert.Zero(t, ret.Fee)
+	assert.True(t, ret.RevokeMandate)
+}
+
+func TestReturnFeeFromEnv(t *testing.T) {
+	t.Setenv("ACH_RETURN_FEE", "")
+	assert.Equal(t, DefaultReturnFee, ReturnFeeFromEnv())
+	t.Setenv("ACH_RETURN_FEE", "0")
+	assert.Zero(t, ReturnFeeFromEnv())
+	t.Setenv("ACH_RETURN_FEE", "-5")
+	assert.Equal(t, DefaultReturnFee, ReturnFeeFromEnv())
+}
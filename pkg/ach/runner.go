@@ -0,0 +1,94 @@
+package ach
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Runner schedules each month's rent debits under the active mandates and submits them to the
+// provider. A debit the provider refuses is retried on later runs until RetryDays after its
+// debit date, then failed.
+type Runner struct {
+	Interval  time.Duration
+	RetryDays int
+	Provider  Provider
+}
+
+// NewRunner creates a runner for the configured provider that runs hourly and retries
+// submissions for 3 days
+func NewRunner() *Runner {
+	return &Runner{Interval: time.Hour, RetryDays: 3, Provider: NewProviderFromEnv()}
+}
+
+// Run schedules and submits debits every Interval until the context is cancelled. It does
+// nothing when no provider is configured.
+func (r *Runner) Run(ctx context.Context) {
+	if r.Provider == nil {
+		return
+	}
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		if scheduled, submitted, err := r.RunOnce(ctx, time.Now()); err != nil {
+			slog.Error("ACH debit run failed", "error", err)
+		} else if scheduled+submitted > 0 {
+			slog.Info("Ran ACH debits", "scheduled", scheduled, "submitted", submitted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce schedules the debits due by now and submits every pending debit. It returns the
+// number scheduled and submitted.
+func (r *Runner) RunOnce(ctx context.Context, now time.Time) (int, int, error) {
+	scheduled, err := models.ScheduleACHDebits(r.Provider.Name(), now)
+	if err != nil {
+		return scheduled, 0, err
+	}
+
+	pending, err := models.GetPendingACHDebits(r.Provider.Name())
+	if err != nil {
+		return scheduled, 0, err
+	}
+
+	submitted := 0
+	for _, debit := range pending {
+		if err := r.submit(ctx, &debit, now); err != nil {
+			slog.Error("Failed to submit ACH debit", "debit_id", debit.ID, "error", err)
+			continue
+		}
+		submitted++
+	}
+	return scheduled, submitted, nil
+}
+
+// submit sends a debit to the provider and posts it to the ledger. The idempotency key lets a
+// debit whose recording failed be resubmitted without debiting the tenant twice.
+func (r *Runner) submit(ctx context.Context, debit *models.ACHDebit, now time.Time) error {
+	transferID, err := r.Provider.Debit(ctx, Debit{
+		AccountID:      debit.AccountExternalID,
+		Amount:         debit.Amount,
+		Description:    fmt.Sprintf("Rent lease %d", debit.LeaseID),
+		IdempotencyKey: fmt.Sprintf("ach-debit-%d", debit.ID),
+	})
+	if err != nil {
+		if now.After(debit.DebitDate.AddDate(0, 0, r.RetryDays+1)) {
+			if failErr := models.FailACHDebit(debit.ID, err.Error()); failErr != nil {
+				return failErr
+			}
+		}
+		return err
+	}
+	return models.SubmitACHDebit(debit.ID, transferID)
+}
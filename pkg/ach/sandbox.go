@@ -0,0 +1,113 @@
+package ach
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sandbox is an ACH provider for development and testing that moves no money. Any public token
+// starting with "public-sandbox-" links an account; a trailing "-savings" links a savings
+// account, and the last four digits of the token, if it ends in them, become the account mask.
+// Debits are always accepted and stay submitted until a webhook settles or returns them.
+//
+// Webhooks are JSON bodies {"transfer_id", "status", "return_code", "return_reason"} signed with
+// a hex HMAC-SHA256 of the body in the X-Sandbox-Signature header.
+type Sandbox struct {
+	WebhookSecret string
+}
+
+// Name implements Provider
+func (s *Sandbox) Name() string { return "sandbox" }
+
+// LinkAccount implements Provider
+func (s *Sandbox) LinkAccount(ctx context.Context, publicToken string) (*BankAccount, error) {
+	if !strings.HasPrefix(publicToken, "public-sandbox-") {
+		return nil, fmt.Errorf("unknown sandbox public token")
+	}
+
+	account := &BankAccount{InstitutionName: "Sandbox Bank", Mask: "0000", AccountType: "checking"}
+	token := strings.TrimSuffix(publicToken, "-savings")
+	if token != publicToken {
+		account.AccountType = "savings"
+	}
+	if len(token) >= 4 && isDigits(token[len(token)-4:]) {
+		account.Mask = token[len(token)-4:]
+	}
+
+	id, err := sandboxID("acct")
+	if err != nil {
+		return nil, err
+	}
+	account.ExternalID = id
+	return account, nil
+}
+
+// Debit implements Provider
+func (s *Sandbox) Debit(ctx context.Context, debit Debit) (string, error) {
+	if debit.AccountID == "" || debit.Amount <= 0 {
+		return "", fmt.Errorf("sandbox debit needs an account and a positive amount")
+	}
+	return sandboxID("tr")
+}
+
+// ParseWebhook implements Provider. Callbacks are refused when no webhook secret is configured.
+func (s *Sandbox) ParseWebhook(r *http.Request) (*Event, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.WebhookSecret == "" {
+		return nil, ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(s.WebhookSecret))
+	mac.Write(body)
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get("X-Sandbox-Signature"))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload struct {
+		TransferID   string `json:"transfer_id"`
+		Status       string `json:"status"`
+		ReturnCode   string `json:"return_code"`
+		ReturnReason string `json:"return_reason"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.TransferID == "" || (payload.Status != StatusSettled && payload.Status != StatusReturned) {
+		return nil, fmt.Errorf("sandbox webhook needs a transfer_id and a status of settled or returned")
+	}
+	return &Event{
+		TransferID:   payload.TransferID,
+		Status:       payload.Status,
+		ReturnCode:   payload.ReturnCode,
+		ReturnReason: payload.ReturnReason,
+	}, nil
+}
+
+// sandboxID returns a random ID with the given prefix
+func sandboxID(prefix string) (string, error) {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s_sbx_%s", prefix, hex.EncodeToString(token)), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,40 @@
+package announcements
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Sender queues the email and SMS notifications of announcements once they are published, so
+// scheduled announcements go out at their publish time
+type Sender struct {
+	Interval time.Duration
+}
+
+// NewSender creates a sender that checks for published announcements every minute
+func NewSender() *Sender {
+	return &Sender{Interval: time.Minute}
+}
+
+// Run sends due announcements every Interval until the context is cancelled
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if sent, err := models.SendDueAnnouncements(time.Now()); err != nil {
+			slog.Error("Sending announcements failed", "error", err)
+		} else if sent > 0 {
+			slog.Info("Sent announcements", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// passwordResetLink builds the link emailed to users who ask to reset their password
+func passwordResetLink(token string) string {
+	return appBaseURL() + "/password-reset?token=" + url.QueryEscape(token)
+}
+
+// emailVerificationLink builds the link emailed to users to confirm their address
+func emailVerificationLink(token string) string {
+	return appBaseURL() + "/verify-email?token=" + url.QueryEscape(token)
+}
+
+// handlePasswordResetPage renders the standalone page linked from password reset emails
+func handlePasswordResetPage(w http.ResponseWriter, r *http.Request) {
+	t, err := template.ParseFiles("templates/password-reset.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := t.Execute(w, struct{ Token string }{r.URL.Query().Get("token")}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleVerifyEmailLink confirms a user's address from the link in a verification email and
+// sends them to their profile
+func handleVerifyEmailLink(w http.ResponseWriter, r *http.Request) {
+	userID, err := models.VerifyEmail(r.URL.Query().Get("token"), time.Now())
+	if err == models.ErrEmailVerificationInvalid {
+		http.Error(w, "This verification link is invalid or has expired. Request a new one from your profile.", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to verify email", "error", err)
+		http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+	meResponses.invalidate(userID)
+	http.Redirect(w, r, "/profile?email_verified=1", http.StatusSeeOther)
+}
+
+// handleResendEmailVerification emails the signed-in user a new verification link
+func handleResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	err := models.RequestEmailVerification(user, emailVerificationLink, time.Now())
+	if err == models.ErrEmailAlreadyVerified {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to send email verification", "user_id", user.ID, "error", err)
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "A verification link has been sent to " + user.Email}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
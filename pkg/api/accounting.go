@@ -0,0 +1,292 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterAccountingRoutes registers period close and payment entry routes
+func RegisterAccountingRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		// Period close
+		auth.Get("/api/accounting/periods", handleGetAccountingPeriods)
+		auth.Post("/api/accounting/periods", handleLockAccountingPeriod)
+
+		// Payments; entries in locked periods are corrected with adjusting entries
+		auth.With(middleware.Idempotent).Post("/api/payments", handleCreatePayment)
+		auth.Put("/api/payments/{id}", handleUpdatePayment)
+		auth.With(middleware.Idempotent).Post("/api/payments/{id}/adjustments", handleCreateAdjustingEntry)
+	})
+
+	// Reopening a closed period is restricted to admins
+	r.Group(func(admin chi.Router) {
+		admin.Use(middleware.LoadUserFromToken)
+		admin.Use(middleware.RequireLogin)
+		admin.Use(middleware.RequireRole("admin"))
+
+		admin.Delete("/api/accounting/periods/{property_id}/{month}", handleUnlockAccountingPeriod)
+	})
+}
+
+// paymentRequest is the request body for creating, updating and adjusting payments
+type paymentRequest struct {
+	LeaseID          int     `json:"lease_id"`
+	Amount           float64 `json:"amount"`
+	PaymentDate      string  `json:"payment_date"` // YYYY-MM-DD
+	PaymentMethod    string  `json:"payment_method"`
+	Status           string  `json:"status"`
+	AdjustmentReason string  `json:"adjustment_reason"`
+	EmailReceipt     bool    `json:"email_receipt"` // Email the tenant a receipt for a completed payment
+}
+
+// toPayment validates the request and converts it to a payment
+func (req paymentRequest) toPayment() (*models.Payment, string) {
+	paymentDate := time.Now()
+	if req.PaymentDate != "" {
+		parsed, err := time.Parse("2006-01-02", req.PaymentDate)
+		if err != nil {
+			return nil, "Invalid payment_date, expected YYYY-MM-DD"
+		}
+		paymentDate = parsed
+	}
+
+	return &models.Payment{
+		LeaseID:          req.LeaseID,
+		Amount:           req.Amount,
+		PaymentDate:      paymentDate,
+		PaymentMethod:    models.NullString(req.PaymentMethod),
+		Status:           req.Status,
+		AdjustmentReason: models.NullString(strings.TrimSpace(req.AdjustmentReason)),
+	}, ""
+}
+
+// writePaymentError maps payment errors to HTTP responses
+func writePaymentError(w http.ResponseWriter, err error, action string) {
+	switch err {
+	case models.ErrPeriodLocked:
+		http.Error(w, "Accounting period is locked; record an adjusting entry instead", http.StatusConflict)
+	case sql.ErrNoRows:
+		http.Error(w, "Payment not found", http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+func handleGetAccountingPeriods(w http.ResponseWriter, r *http.Request) {
+	propertyID := 0
+	if idStr := r.URL.Query().Get("property_id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "Invalid property ID", http.StatusBadRequest)
+			return
+		}
+		propertyID = id
+	}
+
+	periods, err := models.GetAccountingPeriods(propertyID)
+	if err != nil {
+		http.Error(w, "Failed to fetch accounting periods", http.StatusInternalServerError)
+		return
+	}
+
+	if periods == nil {
+		periods = []models.AccountingPeriod{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(periods); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleLockAccountingPeriod(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		PropertyID int    `json:"property_id"`
+		Month      string `json:"month"` // YYYY-MM
+		Notes      string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	month, err := time.Parse("2006-01", req.Month)
+	if err != nil || req.PropertyID <= 0 {
+		http.Error(w, "property_id and month (YYYY-MM) are required", http.StatusBadRequest)
+		return
+	}
+
+	period := &models.AccountingPeriod{
+		PropertyID:  req.PropertyID,
+		PeriodStart: month,
+		LockedBy:    sql.NullInt32{Int32: int32(user.ID), Valid: true},
+		Notes:       models.NullString(req.Notes),
+	}
+
+	if err := models.LockAccountingPeriod(period); err != nil {
+		http.Error(w, "Failed to lock accounting period", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(period); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleUnlockAccountingPeriod(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "property_id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	month, err := time.Parse("2006-01", chi.URLParam(r, "month"))
+	if err != nil {
+		http.Error(w, "Invalid month, expected YYYY-MM", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.UnlockAccountingPeriod(propertyID, month); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Accounting period is not locked", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to unlock accounting period", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleCreatePayment(w http.ResponseWriter, r *http.Request) {
+	var req paymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payment, msg := req.toPayment()
+	if msg == "" && req.LeaseID <= 0 {
+		msg = "lease_id is required"
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	payment.AdjustmentReason = sql.NullString{}
+
+	if err := models.CreatePayment(payment); err != nil {
+		writePaymentError(w, err, "create payment")
+		return
+	}
+	if req.EmailReceipt {
+		emailReceiptForPayment(payment.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(payment); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleUpdatePayment(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req paymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payment, msg := req.toPayment()
+	if msg == "" && req.LeaseID <= 0 {
+		msg = "lease_id is required"
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	payment.ID = paymentID
+	if payment.Status == "" {
+		payment.Status = "completed"
+	}
+
+	if err := models.UpdatePayment(payment); err != nil {
+		writePaymentError(w, err, "update payment")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(payment); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCreateAdjustingEntry(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req paymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	adjustment, msg := req.toPayment()
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if !adjustment.AdjustmentReason.Valid {
+		http.Error(w, "adjustment_reason is required", http.StatusBadRequest)
+		return
+	}
+	if adjustment.Amount == 0 {
+		http.Error(w, "amount must be non-zero", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.CreateAdjustingEntry(paymentID, adjustment); err != nil {
+		writePaymentError(w, err, "create adjusting entry")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(adjustment); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
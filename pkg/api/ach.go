@@ -0,0 +1,431 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/ach"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// newACHProvider returns the configured ACH provider, or nil; replaced in tests
+var newACHProvider = ach.NewProviderFromEnv
+
+// RegisterACHRoutes registers bank account linking, ACH mandate and rent debit routes
+func RegisterACHRoutes(r chi.Router) {
+	// Provider callbacks, authenticated by the provider's webhook signature
+	r.Post("/api/ach/webhook/{provider}", handleACHWebhook)
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/leases/{id}/ach-mandates", handleGetLeaseACHMandates)
+		auth.Get("/api/leases/{id}/ach-debits", handleGetLeaseACHDebits)
+		auth.Get("/api/leases/{id}/fees", handleGetLeaseFees)
+		auth.Delete("/api/ach-mandates/{id}", handleRevokeACHMandate)
+	})
+
+	// Tenant portal
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("tenant"))
+
+		auth.Get("/api/portal/bank-accounts", handleGetPortalBankAccounts)
+		auth.Post("/api/portal/bank-accounts", handleLinkPortalBankAccount)
+		auth.Delete("/api/portal/bank-accounts/{id}", handleRemovePortalBankAccount)
+		auth.Get("/api/portal/leases/{id}/ach-mandates", handleGetPortalACHMandates)
+		auth.Post("/api/portal/leases/{id}/ach-mandates/preview", handlePreviewPortalACHMandate)
+		auth.Post("/api/portal/leases/{id}/ach-mandates", handleCreatePortalACHMandate)
+		auth.Delete("/api/portal/ach-mandates/{id}", handleRevokePortalACHMandate)
+		auth.Get("/api/portal/leases/{id}/ach-debits", handleGetPortalACHDebits)
+	})
+}
+
+// writeACHError maps bank account and mandate errors to responses
+func writeACHError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch err {
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	case models.ErrInvalidBankAccount, models.ErrInvalidMandate:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case models.ErrMandateExists:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+// writeACHJSON encodes v as the JSON response
+func writeACHJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// parseACHID parses the id URL parameter, writing a bad request naming what if it is not a number
+func parseACHID(w http.ResponseWriter, r *http.Request, what string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid "+what+" ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// portalLease resolves the lease in the id URL parameter for the portal tenant. Other tenants'
+// leases are reported as missing.
+func portalLease(w http.ResponseWriter, r *http.Request) (*models.Tenant, int, bool) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+	leaseID, ok := parseACHID(w, r, "lease")
+	if !ok {
+		return nil, 0, false
+	}
+
+	tenantID, err := models.GetLeaseTenantID(leaseID)
+	if err != nil || tenantID != tenant.ID {
+		if err != nil && err != sql.ErrNoRows {
+			http.Error(w, "Failed to fetch lease", http.StatusInternalServerError)
+		} else {
+			http.Error(w, "Lease not found", http.StatusNotFound)
+		}
+		return nil, 0, false
+	}
+	return tenant, leaseID, true
+}
+
+func handleGetLeaseACHMandates(w http.ResponseWriter, r *http.Request) {
+	leaseID, ok := parseACHID(w, r, "lease")
+	if !ok {
+		return
+	}
+	mandates, err := models.GetLeaseACHMandates(leaseID)
+	if err != nil {
+		http.Error(w, "Failed to fetch mandates", http.StatusInternalServerError)
+		return
+	}
+	writeACHJSON(w, http.StatusOK, mandates)
+}
+
+func handleGetLeaseACHDebits(w http.ResponseWriter, r *http.Request) {
+	leaseID, ok := parseACHID(w, r, "lease")
+	if !ok {
+		return
+	}
+	debits, err := models.GetLeaseACHDebits(leaseID)
+	if err != nil {
+		http.Error(w, "Failed to fetch debits", http.StatusInternalServerError)
+		return
+	}
+	writeACHJSON(w, http.StatusOK, debits)
+}
+
+func handleGetLeaseFees(w http.ResponseWriter, r *http.Request) {
+	leaseID, ok := parseACHID(w, r, "lease")
+	if !ok {
+		return
+	}
+	fees, err := models.GetLeaseFees(leaseID)
+	if err != nil {
+		http.Error(w, "Failed to fetch fees", http.StatusInternalServerError)
+		return
+	}
+	writeACHJSON(w, http.StatusOK, fees)
+}
+
+func handleRevokeACHMandate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseACHID(w, r, "mandate")
+	if !ok {
+		return
+	}
+	if err := models.RevokeACHMandate(id, "revoked by property manager"); err != nil {
+		writeACHError(w, err, "Active mandate not found", "Failed to revoke mandate")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetPortalBankAccounts(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+	accounts, err := models.GetTenantBankAccounts(tenant.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch bank accounts", http.StatusInternalServerError)
+		return
+	}
+	writeACHJSON(w, http.StatusOK, accounts)
+}
+
+// handleLinkPortalBankAccount exchanges the public token from the provider's account-linking
+// widget for the tenant's account
+func handleLinkPortalBankAccount(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	provider := newACHProvider()
+	if provider == nil {
+		http.Error(w, "ACH payments are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		PublicToken string `json:"public_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PublicToken == "" {
+		http.Error(w, "public_token is required", http.StatusBadRequest)
+		return
+	}
+
+	linked, err := provider.LinkAccount(r.Context(), req.PublicToken)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to link bank account", "tenant_id", tenant.ID, "error", err)
+		http.Error(w, "The bank account could not be linked", http.StatusBadGateway)
+		return
+	}
+
+	account := &models.BankAccount{
+		TenantID:        tenant.ID,
+		Provider:        provider.Name(),
+		ExternalID:      linked.ExternalID,
+		InstitutionName: models.NullString(linked.InstitutionName),
+		AccountMask:     linked.Mask,
+		AccountType:     linked.AccountType,
+	}
+	if err := models.CreateBankAccount(account); err != nil {
+		writeACHError(w, err, "Bank account not found", "Failed to save bank account")
+		return
+	}
+	writeACHJSON(w, http.StatusCreated, account)
+}
+
+func handleRemovePortalBankAccount(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+	id, ok := parseACHID(w, r, "bank account")
+	if !ok {
+		return
+	}
+
+	account, err := models.GetBankAccount(id)
+	if err != nil || account.TenantID != tenant.ID {
+		if err != nil && err != sql.ErrNoRows {
+			http.Error(w, "Failed to fetch bank account", http.StatusInternalServerError)
+		} else {
+			http.Error(w, "Bank account not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	if err := models.RemoveBankAccount(id); err != nil {
+		writeACHError(w, err, "Bank account not found", "Failed to remove bank account")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetPortalACHMandates(w http.ResponseWriter, r *http.Request) {
+	_, leaseID, ok := portalLease(w, r)
+	if !ok {
+		return
+	}
+	mandates, err := models.GetLeaseACHMandates(leaseID)
+	if err != nil {
+		http.Error(w, "Failed to fetch mandates", http.StatusInternalServerError)
+		return
+	}
+	writeACHJSON(w, http.StatusOK, mandates)
+}
+
+// mandateRequest is the request body for authorizing rent debits. A missing amount debits the
+// rent billed each month.
+type mandateRequest struct {
+	BankAccountID int      `json:"bank_account_id"`
+	DebitDay      int      `json:"debit_day"`
+	Amount        *float64 `json:"amount"`
+	Authorized    bool     `json:"authorized"`
+}
+
+// toMandate converts the request into a mandate on the lease, checking the bank account is the
+// tenant's own. It writes an error response and returns nil when it is not.
+func (req mandateRequest) toMandate(w http.ResponseWriter, tenant *models.Tenant, leaseID int) *models.ACHMandate {
+	account, err := models.GetBankAccount(req.BankAccountID)
+	if err != nil || account.TenantID != tenant.ID || account.Status != "active" {
+		if err != nil && err != sql.ErrNoRows {
+			http.Error(w, "Failed to fetch bank account", http.StatusInternalServerError)
+		} else {
+			http.Error(w, "bank_account_id must be one of your linked bank accounts", http.StatusBadRequest)
+		}
+		return nil
+	}
+
+	mandate := &models.ACHMandate{
+		LeaseID:       leaseID,
+		BankAccountID: account.ID,
+		AccountMask:   account.AccountMask,
+		DebitDay:      req.DebitDay,
+	}
+	if req.Amount != nil {
+		mandate.Amount = sql.NullFloat64{Float64: *req.Amount, Valid: true}
+	}
+	return mandate
+}
+
+// handlePreviewPortalACHMandate returns the authorization the tenant will agree to for the terms
+// in the request body
+func handlePreviewPortalACHMandate(w http.ResponseWriter, r *http.Request) {
+	tenant, leaseID, ok := portalLease(w, r)
+	if !ok {
+		return
+	}
+
+	var req mandateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	mandate := req.toMandate(w, tenant, leaseID)
+	if mandate == nil {
+		return
+	}
+
+	writeACHJSON(w, http.StatusOK, map[string]string{
+		"authorization_text": models.FormatACHAuthorization(tenant.FirstName+" "+tenant.LastName, mandate),
+	})
+}
+
+// handleCreatePortalACHMandate records the tenant's authorization to debit rent for their lease.
+// The body must confirm authorized: true for the terms shown by the preview.
+func handleCreatePortalACHMandate(w http.ResponseWriter, r *http.Request) {
+	tenant, leaseID, ok := portalLease(w, r)
+	if !ok {
+		return
+	}
+
+	var req mandateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.Authorized {
+		http.Error(w, "authorized must be true to accept the debit authorization", http.StatusBadRequest)
+		return
+	}
+	mandate := req.toMandate(w, tenant, leaseID)
+	if mandate == nil {
+		return
+	}
+
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	mandate.AuthorizedIP = models.NullString(ip)
+	mandate.AuthorizedBy = currentUserID(r)
+
+	if err := models.CreateACHMandate(mandate); err != nil {
+		writeACHError(w, err, "Lease not found", "Failed to save mandate")
+		return
+	}
+	writeACHJSON(w, http.StatusCreated, mandate)
+}
+
+func handleRevokePortalACHMandate(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+	id, ok := parseACHID(w, r, "mandate")
+	if !ok {
+		return
+	}
+
+	// Tenants revoke only the mandates debiting their own accounts
+	mandate, err := models.GetACHMandate(id)
+	if err == nil {
+		var account *models.BankAccount
+		if account, err = models.GetBankAccount(mandate.BankAccountID); err == nil && account.TenantID != tenant.ID {
+			err = sql.ErrNoRows
+		}
+	}
+	if err == nil {
+		err = models.RevokeACHMandate(id, "revoked by tenant")
+	}
+	if err != nil {
+		writeACHError(w, err, "Active mandate not found", "Failed to revoke mandate")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetPortalACHDebits(w http.ResponseWriter, r *http.Request) {
+	_, leaseID, ok := portalLease(w, r)
+	if !ok {
+		return
+	}
+	debits, err := models.GetLeaseACHDebits(leaseID)
+	if err != nil {
+		http.Error(w, "Failed to fetch debits", http.StatusInternalServerError)
+		return
+	}
+	writeACHJSON(w, http.StatusOK, debits)
+}
+
+// handleACHWebhook records transfer status updates pushed by the ACH provider. Failures return
+// 5xx so the provider retries.
+func handleACHWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := newACHProvider()
+	if provider == nil || provider.Name() != chi.URLParam(r, "provider") {
+		http.Error(w, "Unknown ACH provider", http.StatusNotFound)
+		return
+	}
+
+	event, err := provider.ParseWebhook(r)
+	if err != nil {
+		if err == ach.ErrInvalidSignature {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		} else {
+			http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+		}
+		return
+	}
+
+	debit, err := models.GetACHDebitByExternalID(provider.Name(), event.TransferID)
+	if err == sql.ErrNoRows {
+		// Transfers made outside this application are acknowledged and ignored
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch debit", http.StatusInternalServerError)
+		return
+	}
+
+	// Updates for debits already settled or returned are duplicates
+	if err := ach.Apply(debit, event, ach.ReturnFeeFromEnv(), time.Now()); err != nil && err != sql.ErrNoRows {
+		logging.FromContext(r.Context()).Error("Failed to record ACH transfer status", "status", event.Status, "debit_id", debit.ID,
+			"error", err)
+		http.Error(w, "Failed to record transfer status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterAnnouncementRoutes registers announcement management and tenant portal routes
+func RegisterAnnouncementRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		// ?property_id= limits the list to a property and portfolio-wide announcements;
+		// ?include_expired=true adds expired ones
+		auth.Get("/api/announcements", handleGetAnnouncements)
+		auth.Post("/api/announcements", handleCreateAnnouncement)
+		auth.Get("/api/announcements/{id}", handleGetAnnouncement)
+		auth.Put("/api/announcements/{id}", handleUpdateAnnouncement)
+		auth.Delete("/api/announcements/{id}", handleDeleteAnnouncement)
+	})
+
+	// Tenant portal
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("tenant"))
+
+		auth.Get("/api/portal/announcements", handleGetPortalAnnouncements)
+	})
+}
+
+// announcementRequest is the request body for creating or updating an announcement. Without a
+// property_id the announcement goes to the whole portfolio; without publish_at it is published
+// immediately.
+type announcementRequest struct {
+	PropertyID *int       `json:"property_id"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	SendEmail  bool       `json:"send_email"`
+	SendSMS    bool       `json:"send_sms"`
+	PublishAt  *time.Time `json:"publish_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// toAnnouncement converts the request to an announcement
+func (req announcementRequest) toAnnouncement() *models.Announcement {
+	a := &models.Announcement{
+		Title:     req.Title,
+		Body:      req.Body,
+		SendEmail: req.SendEmail,
+		SendSMS:   req.SendSMS,
+	}
+	if req.PropertyID != nil {
+		a.PropertyID = sql.NullInt32{Int32: int32(*req.PropertyID), Valid: true}
+	}
+	if req.PublishAt != nil {
+		a.PublishAt = *req.PublishAt
+	}
+	if req.ExpiresAt != nil {
+		a.ExpiresAt = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+	}
+	return a
+}
+
+// writeAnnouncementError maps announcement save errors to responses
+func writeAnnouncementError(w http.ResponseWriter, err error, notFound string) {
+	switch err {
+	case models.ErrInvalidAnnouncement:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to save announcement", http.StatusInternalServerError)
+	}
+}
+
+func handleGetAnnouncements(w http.ResponseWriter, r *http.Request) {
+	propertyID := 0
+	if value := r.URL.Query().Get("property_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid property ID", http.StatusBadRequest)
+			return
+		}
+		propertyID = id
+	}
+	includeExpired := r.URL.Query().Get("include_expired") == "true"
+
+	announcements, err := models.GetAnnouncements(propertyID, includeExpired, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to fetch announcements", http.StatusInternalServerError)
+		return
+	}
+
+	if announcements == nil {
+		announcements = []models.Announcement{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(announcements); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleCreateAnnouncement publishes or schedules an announcement. Email and SMS notifications
+// are sent in the background once it is published.
+func handleCreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	var req announcementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	a := req.toAnnouncement()
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		a.CreatedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+
+	if err := models.CreateAnnouncement(a); err != nil {
+		writeAnnouncementError(w, err, "Property not found")
+		return
+	}
+
+	created, err := models.GetAnnouncement(a.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch announcement", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid announcement ID", http.StatusBadRequest)
+		return
+	}
+
+	a, err := models.GetAnnouncement(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Announcement not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch announcement", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleUpdateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid announcement ID", http.StatusBadRequest)
+		return
+	}
+
+	var req announcementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	a := req.toAnnouncement()
+	a.ID = id
+	if err := models.UpdateAnnouncement(a); err != nil {
+		writeAnnouncementError(w, err, "Announcement or property not found")
+		return
+	}
+
+	updated, err := models.GetAnnouncement(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch announcement", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid announcement ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteAnnouncement(id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Announcement not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete announcement", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetPortalAnnouncements returns the announcements currently shown to the logged-in tenant
+func handleGetPortalAnnouncements(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	announcements, err := models.GetTenantAnnouncements(tenant.ID, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to fetch announcements", http.StatusInternalServerError)
+		return
+	}
+
+	if announcements == nil {
+		announcements = []models.Announcement{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(announcements); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
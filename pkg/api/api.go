@@ -1,18 +1,18 @@
 package api
 
 import (
-	"encoding/csv"
 	"fmt"
 	"html/template"
-	"io"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
 	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
 	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
 )
 
 func RegisterRoutes(r *chi.Mux) {
@@ -21,17 +21,180 @@ func RegisterRoutes(r *chi.Mux) {
 	filesDir := http.Dir(filepath.Join(workDir, "static"))
 	r.Handle("/static/*", http.StripPrefix("/static", http.FileServer(filesDir)))
 
+	// Serve uploaded files (profile pictures) when stored on local disk
+	if local, ok := storage.Default.(*storage.LocalBackend); ok {
+		r.Handle(local.BaseURL+"/*", local.Handler())
+	}
+
 	// Register user API routes (includes public and protected routes)
 	RegisterUserRoutes(r)
 
+	// Register bulk user import and signup invitation routes
+	RegisterUserImportRoutes(r)
+
 	// Register report and analytics API routes
 	RegisterReportRoutes(r)
 
+	// Register report, chart and dashboard definition export/import routes
+	RegisterDefinitionBundleRoutes(r)
+
+	// Register report and dashboard favorites and recent items
+	RegisterFavoriteRoutes(r)
+
+	// Register organization usage metering and quota routes
+	RegisterUsageRoutes(r)
+
+	// Register outbox administration routes
+	RegisterOutboxRoutes(r)
+
+	// Register configuration doctor route
+	RegisterDoctorRoutes(r)
+
+	// Register database backup routes
+	RegisterBackupRoutes(r)
+
+	// Register Zapier/Make integration triggers and API key management
+	RegisterIntegrationRoutes(r)
+
+	// Register accounting period close and payment routes
+	RegisterAccountingRoutes(r)
+
+	// Register payment receipt and year-end statement routes
+	RegisterReceiptRoutes(r)
+
+	// Register move-in/move-out inspection and damage charge routes
+	RegisterMoveInspectionRoutes(r)
+
+	// Register lease document and e-signature routes
+	RegisterLeaseSignatureRoutes(r)
+
+	// Register property and unit photo gallery routes
+	RegisterPropertyPhotoRoutes(r)
+
+	// Register unit listing and listing site syndication routes
+	RegisterSyndicationRoutes(r)
+
+	// Register background CSV import job routes
+	RegisterImportRoutes(r)
+
+	// Register rental application and tenant screening routes
+	RegisterApplicationRoutes(r)
+
+	// Register rent increase planning routes
+	RegisterRentIncreaseRoutes(r)
+
+	// Register lease concession and effective rent routes
+	RegisterConcessionRoutes(r)
+
+	// Register lease rent escalation and CPI index routes
+	RegisterEscalationRoutes(r)
+
+	// Register lease creation and security deposit compliance routes
+	RegisterDepositRoutes(r)
+
+	// Register maintenance SLA and preventive maintenance routes
+	RegisterMaintenanceRoutes(r)
+
+	// Register maintenance request detail and message thread routes
+	RegisterMaintenanceMessageRoutes(r)
+
+	// Register maintenance request creation, editing and status routes
+	RegisterMaintenanceRequestRoutes(r)
+
+	// Register work order cost estimate and approval routes
+	RegisterMaintenanceApprovalRoutes(r)
+
+	// Register on-call schedule and emergency contact routes
+	RegisterOnCallRoutes(r)
+
+	// Register tenant announcement routes
+	RegisterAnnouncementRoutes(r)
+
+	// Register HOA/condo association owner, dues and violation routes
+	RegisterAssociationRoutes(r)
+
+	// Register optional package and visitor routes for multifamily buildings
+	RegisterBuildingOpsRoutes(r)
+
+	// Register utility meter reading and usage anomaly routes
+	RegisterUtilityRoutes(r)
+
+	// Register batch request and delta sync routes for mobile clients
+	RegisterBatchRoutes(r)
+
+	// Register the entity change feed for integrations
+	RegisterChangeFeedRoutes(r)
+
+	// Register the token-authenticated environment bootstrap route
+	RegisterBootstrapRoutes(r)
+
+	// Register market benchmark dataset import routes
+	RegisterMarketBenchmarkRoutes(r)
+
+	// Register region and portfolio hierarchy and roll-up routes
+	RegisterPropertyGroupRoutes(r)
+
+	// Register floorplan and unit mix routes
+	RegisterFloorplanRoutes(r)
+
+	// Register lease renewal offer routes
+	RegisterRenewalOfferRoutes(r)
+
+	// Register rent proration preview and lease rent schedule routes
+	RegisterProrationRoutes(r)
+
+	// Register collections workflow routes for delinquent accounts
+	RegisterCollectionsRoutes(r)
+
+	// Register legal notice template, generation and tenant timeline routes
+	RegisterLegalNoticeRoutes(r)
+
+	// Register property ownership and owner portal routes
+	RegisterOwnerPortalRoutes(r)
+
+	// Register renovation project and before/after comparison routes
+	RegisterRenovationRoutes(r)
+
+	// Register unit turnover and make-ready routes
+	RegisterTurnoverRoutes(r)
+
+	// Register the public JSON Schemas of webhook payloads
+	RegisterWebhookSchemaRoutes(r)
+
+	// Register per-role default dashboards and default dashboard resolution
+	RegisterRoleDashboardRoutes(r)
+
+	// Register report subscriptions for users other than the report's owner
+	RegisterReportSubscriptionRoutes(r)
+
+	// Register scheduled report output and the admin health view of failing scheduled deliveries
+	RegisterReportScheduleRoutes(r)
+
+	// Register the time zones property and organization schedules run in
+	RegisterTimezoneRoutes(r)
+
+	// Register the public status page and its admin incident notes
+	RegisterStatusRoutes(r)
+
+	// Register report confidentiality levels and the export audit trail
+	RegisterReportConfidentialityRoutes(r)
+
+	// Register per-role report row limits
+	RegisterReportRowLimitRoutes(r)
+
+	// Register ACH bank account linking, debit mandates and rent debits
+	RegisterACHRoutes(r)
+
+	// Register stored report export and export storage budget routes
+	RegisterExportStorageRoutes(r)
+
 	// API Routes
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("OK"))
 	})
 	r.Get("/callback", middleware.HandleCallback)
+	r.Post("/logout", middleware.HandleLogout)
+	r.Post("/backchannel-logout", middleware.HandleBackchannelLogout)
 
 	// Web routes (protected by authentication)
 	r.Group(func(auth chi.Router) {
@@ -42,7 +205,7 @@ func RegisterRoutes(r *chi.Mux) {
 		auth.Get("/properties/new", handleNewPropertyForm)
 		auth.Post("/properties/new", handleCreateProperty)
 		auth.Get("/properties/import", handleImportPropertyForm)
-		auth.Post("/properties/import", handleImportProperty)
+		auth.With(middleware.LimitBody(maxImportBodyBytes)).Post("/properties/import", handleImportProperty)
 
 		// Dashboard and UI routes
 		auth.Get("/", handleDashboard)
@@ -51,6 +214,7 @@ func RegisterRoutes(r *chi.Mux) {
 		// Properties routes with role-based access
 		auth.Group(func(props chi.Router) {
 			props.Use(middleware.RequireAnyRole("admin", "property_manager", "viewer"))
+			props.Use(scopedPropertyRoutes)
 			props.Get("/properties", handleProperties)
 			props.Get("/properties/{id}", handlePropertyDetail)
 		})
@@ -119,15 +283,39 @@ func handleDashboard(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	renderTemplate(w, "dashboard.html", data)
+	renderTemplate(w, r, "dashboard.html", data)
 }
 
 func handleProperties(w http.ResponseWriter, r *http.Request) {
 	tags := r.URL.Query()["tags"]
+	groupID, ok := parseGroupID(r.URL.Query().Get("group_id"))
+	if !ok {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
 
-	var properties []models.PropertyDetail
-	var err error
+	// Managers scoped to regions or portfolios only see the properties under them
+	user, _ := middleware.GetUserFromContext(r.Context())
+	propertyIDs, scoped, err := models.PropertyScope(groupID, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	lastModified, rowCount, err := models.GetPropertiesVersion()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	variant := strings.Join(tags, ",")
+	if scoped {
+		variant += fmt.Sprintf("|%v", propertyIDs)
+	}
+	if checkPageNotModified(w, r, lastModified, rowCount, variant) {
+		return
+	}
+
+	var properties []models.PropertyDetail
 	if len(tags) > 0 {
 		properties, err = models.GetPropertiesByTags(tags)
 	} else {
@@ -138,6 +326,9 @@ func handleProperties(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if scoped {
+		properties = models.FilterPropertyDetails(properties, propertyIDs)
+	}
 	data := struct {
 		Title      string
 		Properties []models.PropertyDetail
@@ -145,10 +336,19 @@ func handleProperties(w http.ResponseWriter, r *http.Request) {
 		Title:      "All Properties",
 		Properties: properties,
 	}
-	renderTemplate(w, "properties.html", data)
+	renderTemplate(w, r, "properties.html", data)
 }
 
 func handlePropertyDetail(w http.ResponseWriter, r *http.Request) {
+	lastModified, rowCount, err := models.GetPropertiesVersion()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if checkPageNotModified(w, r, lastModified, rowCount, chi.URLParam(r, "id")) {
+		return
+	}
+
 	// TODO: In a real app, you'd parse the ID and look up the property
 	properties, err := models.GetProperties()
 	if err != nil {
@@ -163,7 +363,7 @@ func handlePropertyDetail(w http.ResponseWriter, r *http.Request) {
 		Property: properties[0], // Mock - using first property
 	}
 
-	renderTemplate(w, "property-detail.html", data)
+	renderTemplate(w, r, "property-detail.html", data)
 }
 
 func handleTenants(w http.ResponseWriter, r *http.Request) {
@@ -179,7 +379,7 @@ func handleTenants(w http.ResponseWriter, r *http.Request) {
 		Title:      "Tenant Management",
 		Properties: properties,
 	}
-	renderTemplate(w, "tenants.html", data)
+	renderTemplate(w, r, "tenants.html", data)
 }
 
 func handleMaintenance(w http.ResponseWriter, r *http.Request) {
@@ -188,12 +388,18 @@ func handleMaintenance(w http.ResponseWriter, r *http.Request) {
 	}{
 		Title: "Maintenance Requests",
 	}
-	renderTemplate(w, "maintenance.html", data)
+	renderTemplate(w, r, "maintenance.html", data)
 }
 
-func renderTemplate(w http.ResponseWriter, tmpl string, data interface{}) {
+func renderTemplate(w http.ResponseWriter, r *http.Request, tmpl string, data interface{}) {
+	prefs := requestPreferences(r)
+
 	// Parse the base template and the specific template
-	t, err := template.ParseFiles("templates/base.html", "templates/"+tmpl)
+	t, err := template.New("base.html").Funcs(template.FuncMap{
+		"locale":     func() string { return prefs.Locale },
+		"theme":      func() string { return prefs.Theme },
+		"formatDate": prefs.FormatDate,
+	}).ParseFiles("templates/base.html", "templates/"+tmpl)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -205,6 +411,20 @@ func renderTemplate(w http.ResponseWriter, tmpl string, data interface{}) {
 	}
 }
 
+// requestPreferences returns the logged-in user's preferences, or the defaults when there is no
+// user or they cannot be loaded
+func requestPreferences(r *http.Request) models.UserPreferences {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		return models.DefaultPreferences()
+	}
+	prefs, err := models.GetUserPreferences(user.ID)
+	if err != nil {
+		return models.DefaultPreferences()
+	}
+	return prefs
+}
+
 // The following handlers are placeholders.  Implement the logic to
 // create, update, and delete properties as needed.
 
@@ -250,113 +470,21 @@ func handleDeleteProperty(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleNewPropertyForm(w http.ResponseWriter, r *http.Request) {
-	renderTemplate(w, "property-form.html", nil)
+	renderTemplate(w, r, "property-form.html", nil)
 }
 
 func handleImportPropertyForm(w http.ResponseWriter, r *http.Request) {
-	renderTemplate(w, "property-import.html", nil)
+	renderTemplate(w, r, "property-import.html", nil)
 }
 
+// handleImportProperty queues a property import and returns to the import page, which polls
+// the job's progress
 func handleImportProperty(w http.ResponseWriter, r *http.Request) {
-	// Parse the multipart form with a maximum file size of 10MB
-	err := r.ParseMultipartForm(10 << 20)
-	if err != nil {
-		http.Error(w, "Error parsing form: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Get the file from the form data
-	file, handler, err := r.FormFile("csvFile")
-	if err != nil {
-		http.Error(w, "Error retrieving file from form: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-	defer file.Close()
-
-	// Check if the file is a CSV file
-	if filepath.Ext(handler.Filename) != ".csv" {
-		http.Error(w, "Invalid file type. Only CSV files are allowed.", http.StatusBadRequest)
-		return
-	}
-
-	// Create a temporary file to store the uploaded CSV file
-	tempFile, err := os.CreateTemp("", "upload-*.csv")
-	if err != nil {
-		http.Error(w, "Error creating temporary file: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-
-	// Copy the uploaded file to the temporary file
-	if _, err := io.Copy(tempFile, file); err != nil {
-		http.Error(w, "Error copying file to temporary file: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Open the temporary file for reading
-	csvFile, err := os.Open(tempFile.Name())
-	if err != nil {
-		http.Error(w, "Error opening temporary file: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer csvFile.Close()
-
-	// Read the CSV file
-	reader := csv.NewReader(csvFile)
-	reader.Comma = ',' // Set the delimiter to comma
-	reader.TrimLeadingSpace = true
-
-	// Read the header row
-	header, err := reader.Read()
-	if err != nil {
-		http.Error(w, "Error reading header row: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Validate the header row
-	if len(header) != 3 || header[0] != "Name" || header[1] != "Address" || header[2] != "PropertyType" {
-		http.Error(w, "Invalid CSV header format. The header should be: Name,Address,PropertyType", http.StatusBadRequest)
+	job, ok := queueImport(w, r, "properties")
+	if !ok {
 		return
 	}
-
-	// Read the data rows
-	for {
-		row, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			http.Error(w, "Error reading CSV row: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Validate the data row
-		if len(row) != 3 {
-			http.Error(w, "Invalid CSV row format. Each row should have 3 columns.", http.StatusBadRequest)
-			return
-		}
-
-		name := row[0]
-		address := row[1]
-		propertyType := row[2]
-
-		// Create a new property
-		property := &models.Property{
-			Name:         name,
-			Address:      address,
-			PropertyType: propertyType,
-		}
-
-		// Create the property in the database
-		if err := models.CreateProperty(property); err != nil {
-			http.Error(w, "Error creating property: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Redirect to the properties page
-	http.Redirect(w, r, "/properties", http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/properties/import?job=%d", job.ID), http.StatusSeeOther)
 }
 
 func handleProfilePage(w http.ResponseWriter, r *http.Request) {
@@ -366,15 +494,26 @@ func handleProfilePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	logins, err := models.GetLoginHistory(user.ID, 10)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load login history", "user_id", user.ID, "error", err)
+	}
+
 	data := struct {
-		Title string
-		User  *models.User
+		Title        string
+		User         *models.User
+		Preferences  models.UserPreferences
+		DateFormats  []string
+		LoginHistory []models.LoginAttempt
 	}{
-		Title: "User Profile",
-		User:  user,
+		Title:        "User Profile",
+		User:         user,
+		Preferences:  requestPreferences(r),
+		DateFormats:  models.DateFormats(),
+		LoginHistory: logins,
 	}
 
-	renderTemplate(w, "profile.html", data)
+	renderTemplate(w, r, "profile.html", data)
 }
 
 func handleAdminUsersPage(w http.ResponseWriter, r *http.Request) {
@@ -384,7 +523,7 @@ func handleAdminUsersPage(w http.ResponseWriter, r *http.Request) {
 		Title: "User Management",
 	}
 
-	renderTemplate(w, "admin-users.html", data)
+	renderTemplate(w, r, "admin-users.html", data)
 }
 
 func handleReportsPage(w http.ResponseWriter, r *http.Request) {
@@ -402,7 +541,7 @@ func handleReportsPage(w http.ResponseWriter, r *http.Request) {
 		User:  user,
 	}
 
-	renderTemplate(w, "reports.html", data)
+	renderTemplate(w, r, "reports.html", data)
 }
 
 func handleAnalyticsPage(w http.ResponseWriter, r *http.Request) {
@@ -420,7 +559,7 @@ func handleAnalyticsPage(w http.ResponseWriter, r *http.Request) {
 		User:  user,
 	}
 
-	renderTemplate(w, "analytics.html", data)
+	renderTemplate(w, r, "analytics.html", data)
 }
 
 func handleCreateReportPage(w http.ResponseWriter, r *http.Request) {
@@ -438,7 +577,7 @@ func handleCreateReportPage(w http.ResponseWriter, r *http.Request) {
 		User:  user,
 	}
 
-	renderTemplate(w, "create-report.html", data)
+	renderTemplate(w, r, "create-report.html", data)
 }
 
 func handleViewReportPage(w http.ResponseWriter, r *http.Request) {
@@ -449,6 +588,9 @@ func handleViewReportPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	reportID := chi.URLParam(r, "id")
+	if id, err := strconv.Atoi(reportID); err == nil {
+		trackRecentItem(r, models.ItemTypeReport, id, models.ActionViewed)
+	}
 
 	data := struct {
 		Title    string
@@ -460,5 +602,5 @@ func handleViewReportPage(w http.ResponseWriter, r *http.Request) {
 		ReportID: reportID,
 	}
 
-	renderTemplate(w, "view-report.html", data)
+	renderTemplate(w, r, "view-report.html", data)
 }
@@ -0,0 +1,350 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/screening"
+)
+
+// newScreeningProvider returns the configured screening provider, or nil; replaced in tests
+var newScreeningProvider = screening.NewProviderFromEnv
+
+// RegisterApplicationRoutes registers rental application and tenant screening routes
+func RegisterApplicationRoutes(r chi.Router) {
+	// Provider callbacks, authenticated by the provider's webhook signature
+	r.Post("/api/screening/webhook/{provider}", handleScreeningWebhook)
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/applications", handleGetApplications)
+		auth.Post("/api/applications", handleCreateApplication)
+		auth.Get("/api/applications/{id}", handleGetApplication)
+		auth.Post("/api/applications/{id}/consent", handleRecordScreeningConsent)
+		auth.Post("/api/applications/{id}/screenings", handleRequestScreening)
+		auth.Post("/api/applications/{id}/screenings/{screeningID}/refresh", handleRefreshScreening)
+		auth.Post("/api/applications/{id}/decision", handleDecideApplication)
+	})
+}
+
+// currentUserID returns the logged-in user's ID for audit columns
+func currentUserID(r *http.Request) sql.NullInt32 {
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		return sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+	return sql.NullInt32{}
+}
+
+// writeApplicationError maps rental application and screening errors to responses
+func writeApplicationError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch err {
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	case models.ErrInvalidApplication, models.ErrInvalidApplicationDecision:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case models.ErrApplicationClosed, models.ErrScreeningInProgress:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case models.ErrConsentRequired, models.ErrScreeningRequired, models.ErrConditionsRequired:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+// writeApplication responds with an application and its screenings
+func writeApplication(w http.ResponseWriter, id, status int) {
+	application, err := models.GetRentalApplication(id)
+	if err != nil {
+		writeApplicationError(w, err, "Application not found", "Failed to fetch application")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(application); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetApplications(w http.ResponseWriter, r *http.Request) {
+	applications, err := models.GetRentalApplications(r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, "Failed to fetch applications", http.StatusInternalServerError)
+		return
+	}
+
+	if applications == nil {
+		applications = []models.RentalApplication{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(applications); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCreateApplication(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyID    int      `json:"property_id"`
+		UnitID        *int     `json:"unit_id"`
+		FirstName     string   `json:"first_name"`
+		LastName      string   `json:"last_name"`
+		Email         string   `json:"email"`
+		PhoneNumber   string   `json:"phone_number"`
+		MonthlyIncome *float64 `json:"monthly_income"`
+		DesiredMoveIn string   `json:"desired_move_in"` // YYYY-MM-DD
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	application := &models.RentalApplication{
+		PropertyID:  req.PropertyID,
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Email:       req.Email,
+		PhoneNumber: models.NullString(strings.TrimSpace(req.PhoneNumber)),
+		CreatedBy:   currentUserID(r),
+	}
+	if req.UnitID != nil {
+		application.UnitID = sql.NullInt32{Int32: int32(*req.UnitID), Valid: true}
+	}
+	if req.MonthlyIncome != nil {
+		application.MonthlyIncome = sql.NullFloat64{Float64: *req.MonthlyIncome, Valid: true}
+	}
+	if req.DesiredMoveIn != "" {
+		moveIn, err := time.Parse("2006-01-02", req.DesiredMoveIn)
+		if err != nil {
+			http.Error(w, "desired_move_in must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		application.DesiredMoveIn = sql.NullTime{Time: moveIn, Valid: true}
+	}
+
+	if err := models.CreateRentalApplication(application); err != nil {
+		writeApplicationError(w, err, "Property or unit not found", "Failed to create application")
+		return
+	}
+	writeApplication(w, application.ID, http.StatusCreated)
+}
+
+func handleGetApplication(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid application ID", http.StatusBadRequest)
+		return
+	}
+	writeApplication(w, id, http.StatusOK)
+}
+
+// handleRecordScreeningConsent records the applicant's explicit consent to screening. The body
+// carries the disclosure text the applicant agreed to; the client address and user agent are
+// kept as evidence of where the consent was given.
+func handleRecordScreeningConsent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid application ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ConsentText string `json:"consent_text"`
+		Consented   bool   `json:"consented"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.Consented || strings.TrimSpace(req.ConsentText) == "" {
+		http.Error(w, "consented must be true and consent_text must hold the disclosure the applicant agreed to", http.StatusBadRequest)
+		return
+	}
+
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if err := models.RecordScreeningConsent(id, strings.TrimSpace(req.ConsentText), ip, r.UserAgent(), currentUserID(r)); err != nil {
+		writeApplicationError(w, err, "Application not found", "Failed to record consent")
+		return
+	}
+	writeApplication(w, id, http.StatusOK)
+}
+
+// handleRequestScreening sends a consenting applicant to the screening provider
+func handleRequestScreening(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid application ID", http.StatusBadRequest)
+		return
+	}
+
+	provider := newScreeningProvider()
+	if provider == nil {
+		http.Error(w, "Tenant screening is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	application, err := models.GetScreenableApplication(id)
+	if err != nil {
+		writeApplicationError(w, err, "Application not found", "Failed to fetch application")
+		return
+	}
+
+	externalID, err := provider.Request(r.Context(), screening.Applicant{
+		FirstName:     application.FirstName,
+		LastName:      application.LastName,
+		Email:         application.Email,
+		Phone:         application.PhoneNumber.String,
+		MonthlyIncome: application.MonthlyIncome.Float64,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to request screening", "application_id", id, "error", err)
+		http.Error(w, "The screening provider rejected the request", http.StatusBadGateway)
+		return
+	}
+
+	request := &models.ScreeningRequest{
+		ApplicationID: id,
+		Provider:      provider.Name(),
+		ExternalID:    externalID,
+		RequestedBy:   currentUserID(r),
+	}
+	if err := models.CreateScreeningRequest(request); err != nil {
+		http.Error(w, "Failed to record screening", http.StatusInternalServerError)
+		return
+	}
+
+	// Providers that answer straight away complete the screening now rather than at the next poll
+	if result, err := provider.Fetch(r.Context(), externalID); err == nil && result.Completed {
+		if err := screening.Apply(request, result); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to record screening", "screening_id", request.ID, "error", err)
+		}
+	}
+	writeApplication(w, id, http.StatusAccepted)
+}
+
+// handleRefreshScreening polls the provider for a pending screening's result
+func handleRefreshScreening(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid application ID", http.StatusBadRequest)
+		return
+	}
+	screeningID, err := strconv.Atoi(chi.URLParam(r, "screeningID"))
+	if err != nil {
+		http.Error(w, "Invalid screening ID", http.StatusBadRequest)
+		return
+	}
+
+	request, err := models.GetScreeningRequest(screeningID)
+	if err != nil || request.ApplicationID != id {
+		if err == nil || err == sql.ErrNoRows {
+			http.Error(w, "Screening not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch screening", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if request.Status == models.ScreeningPending {
+		provider := newScreeningProvider()
+		if provider == nil || provider.Name() != request.Provider {
+			http.Error(w, "The screening's provider is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		result, err := provider.Fetch(r.Context(), request.ExternalID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to fetch screening", "screening_id", request.ID, "error", err)
+			http.Error(w, "Failed to fetch the screening result", http.StatusBadGateway)
+			return
+		}
+		if result.Completed {
+			if err := screening.Apply(request, result); err != nil && err != sql.ErrNoRows {
+				http.Error(w, "Failed to record screening", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	writeApplication(w, id, http.StatusOK)
+}
+
+// handleDecideApplication approves, rejects or withdraws an application. Approval requires a
+// completed screening that was not declined.
+func handleDecideApplication(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid application ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DecideRentalApplication(id, req.Status, req.Reason, currentUserID(r)); err != nil {
+		writeApplicationError(w, err, "Application not found", "Failed to update application")
+		return
+	}
+	writeApplication(w, id, http.StatusOK)
+}
+
+// handleScreeningWebhook records results pushed by the screening provider. Failures return 5xx
+// so the provider retries.
+func handleScreeningWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := newScreeningProvider()
+	if provider == nil || provider.Name() != chi.URLParam(r, "provider") {
+		http.Error(w, "Unknown screening provider", http.StatusNotFound)
+		return
+	}
+
+	result, err := provider.ParseWebhook(r)
+	if err != nil {
+		if err == screening.ErrInvalidSignature {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		} else {
+			http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+		}
+		return
+	}
+
+	request, err := models.GetScreeningRequestByExternalID(provider.Name(), result.ExternalID)
+	if err == sql.ErrNoRows {
+		// Screenings requested outside this application are acknowledged and ignored
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch screening", http.StatusInternalServerError)
+		return
+	}
+
+	if result.Completed {
+		// Results for screenings that are no longer pending are duplicates
+		if err := screening.Apply(request, result); err != nil && err != sql.ErrNoRows {
+			http.Error(w, "Failed to record screening", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
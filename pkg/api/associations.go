@@ -0,0 +1,615 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterAssociationRoutes registers HOA/condo association routes: the property operating
+// mode, owner accounts and ledgers, dues assessments and violations
+func RegisterAssociationRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+		auth.Use(scopedRecordRoutes("/api/associations/owners/{id}", "id",
+			models.GetAssociationOwnerPropertyID, "Owner not found"))
+		auth.Use(scopedRecordRoutes("/api/associations/assessments/{id}", "id",
+			models.GetDuesAssessmentPropertyID, "Assessment not found"))
+		auth.Use(scopedRecordRoutes("/api/associations/violations/{id}", "id",
+			models.GetAssociationViolationPropertyID, "Violation not found"))
+
+		auth.Put("/api/properties/{id}/operating-mode", handleSetOperatingMode)
+
+		// ?property_id= limits the list to one association; ?include_former=true adds past owners
+		auth.Get("/api/associations/owners", handleGetAssociationOwners)
+		auth.Post("/api/associations/owners", handleCreateAssociationOwner)
+		auth.Get("/api/associations/owners/{id}", handleGetAssociationOwner)
+		auth.Put("/api/associations/owners/{id}", handleUpdateAssociationOwner)
+		auth.Get("/api/associations/owners/{id}/ledger", handleGetOwnerLedger)
+		auth.With(middleware.Idempotent).Post("/api/associations/owners/{id}/payments", handleRecordAssociationPayment)
+
+		auth.Get("/api/associations/assessments", handleGetDuesAssessments)
+		auth.Post("/api/associations/assessments", handleCreateDuesAssessment)
+		auth.Post("/api/associations/assessments/{id}/end", handleEndDuesAssessment)
+
+		// ?property_id= and ?status= filter the list
+		auth.Get("/api/associations/violations", handleGetAssociationViolations)
+		auth.Post("/api/associations/violations", handleCreateAssociationViolation)
+		auth.Get("/api/associations/violations/{id}", handleGetAssociationViolation)
+		auth.Post("/api/associations/violations/{id}/fine", handleFineAssociationViolation)
+		auth.Post("/api/associations/violations/{id}/resolve", handleResolveAssociationViolation)
+	})
+}
+
+// writeAssociationError maps association errors to responses
+func writeAssociationError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch err {
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	case models.ErrInvalidOperatingMode, models.ErrInvalidOwner, models.ErrInvalidAssessment,
+		models.ErrInvalidViolation, models.ErrInvalidFine, models.ErrInvalidAssociationPayment:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case models.ErrOperatingModeInUse, models.ErrNotAssociation, models.ErrUnitOwned,
+		models.ErrViolationClosed, models.ErrPeriodLocked:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+// parseOptionalDate parses a YYYY-MM-DD date, treating an empty value as no date
+func parseOptionalDate(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: date, Valid: true}, nil
+}
+
+// handleSetOperatingMode switches a property between rental and association mode
+func handleSetOperatingMode(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetPropertyOperatingMode(propertyID, req.Mode); err != nil {
+		writeAssociationError(w, err, "Property not found", "Failed to set operating mode")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"property_id":    propertyID,
+		"operating_mode": req.Mode,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetAssociationOwners(w http.ResponseWriter, r *http.Request) {
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+	includeFormer := r.URL.Query().Get("include_former") == "true"
+
+	owners, err := models.GetAssociationOwners(properties, includeFormer)
+	if err != nil {
+		http.Error(w, "Failed to fetch owners", http.StatusInternalServerError)
+		return
+	}
+
+	if owners == nil {
+		owners = []models.AssociationOwner{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(owners); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ownerRequest is the request body for adding or updating an owner. unit_id and
+// ownership_start are only used when adding.
+type ownerRequest struct {
+	UnitID         int    `json:"unit_id"`
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	Phone          string `json:"phone"`
+	MailingAddress string `json:"mailing_address"`
+	OwnershipStart string `json:"ownership_start"` // YYYY-MM-DD, defaults to today
+	OwnershipEnd   string `json:"ownership_end"`   // YYYY-MM-DD
+}
+
+// toOwner converts the request to an owner
+func (req ownerRequest) toOwner() (*models.AssociationOwner, error) {
+	owner := &models.AssociationOwner{
+		UnitID:         req.UnitID,
+		Name:           req.Name,
+		Email:          models.NullString(req.Email),
+		Phone:          models.NullString(req.Phone),
+		MailingAddress: models.NullString(req.MailingAddress),
+		OwnershipStart: time.Now().Truncate(24 * time.Hour),
+	}
+	start, err := parseOptionalDate(req.OwnershipStart)
+	if err != nil {
+		return nil, err
+	}
+	if start.Valid {
+		owner.OwnershipStart = start.Time
+	}
+	if owner.OwnershipEnd, err = parseOptionalDate(req.OwnershipEnd); err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+func handleCreateAssociationOwner(w http.ResponseWriter, r *http.Request) {
+	var req ownerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	owner, err := req.toOwner()
+	if err != nil {
+		http.Error(w, "Dates must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if !requireUnitInScope(w, r, owner.UnitID, "Unit not found") {
+		return
+	}
+
+	if err := models.CreateAssociationOwner(owner); err != nil {
+		writeAssociationError(w, err, "Unit not found", "Failed to create owner")
+		return
+	}
+
+	created, err := models.GetAssociationOwner(owner.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch owner", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetAssociationOwner(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid owner ID", http.StatusBadRequest)
+		return
+	}
+
+	owner, err := models.GetAssociationOwner(id)
+	if err != nil {
+		writeAssociationError(w, err, "Owner not found", "Failed to fetch owner")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(owner); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUpdateAssociationOwner updates an owner's contact details, or ends their ownership when
+// the unit is sold
+func handleUpdateAssociationOwner(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid owner ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ownerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	owner, err := req.toOwner()
+	if err != nil {
+		http.Error(w, "Dates must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	owner.ID = id
+
+	if err := models.UpdateAssociationOwner(owner); err != nil {
+		writeAssociationError(w, err, "Owner not found", "Failed to update owner")
+		return
+	}
+
+	updated, err := models.GetAssociationOwner(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch owner", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetOwnerLedger(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid owner ID", http.StatusBadRequest)
+		return
+	}
+
+	ledger, err := models.GetOwnerLedger(id)
+	if err != nil {
+		writeAssociationError(w, err, "Owner not found", "Failed to fetch ledger")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ledger); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleRecordAssociationPayment(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid owner ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Amount        float64 `json:"amount"`
+		PaymentDate   string  `json:"payment_date"` // YYYY-MM-DD, defaults to today
+		PaymentMethod string  `json:"payment_method"`
+		Reference     string  `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	paymentDate, err := parseOptionalDate(req.PaymentDate)
+	if err != nil {
+		http.Error(w, "payment_date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	payment := &models.Payment{
+		Amount:        req.Amount,
+		PaymentDate:   time.Now().Truncate(24 * time.Hour),
+		PaymentMethod: models.NullString(req.PaymentMethod),
+		Reference:     models.NullString(req.Reference),
+	}
+	if paymentDate.Valid {
+		payment.PaymentDate = paymentDate.Time
+	}
+
+	if err := models.RecordAssociationPayment(ownerID, payment); err != nil {
+		writeAssociationError(w, err, "Owner not found", "Failed to record payment")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(payment); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetDuesAssessments(w http.ResponseWriter, r *http.Request) {
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	assessments, err := models.GetDuesAssessments(properties)
+	if err != nil {
+		http.Error(w, "Failed to fetch assessments", http.StatusInternalServerError)
+		return
+	}
+
+	if assessments == nil {
+		assessments = []models.DuesAssessment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(assessments); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleCreateDuesAssessment levies dues on every unit of an association, or on one unit with
+// unit_id. Charges post to owners' ledgers as each due date arrives.
+func handleCreateDuesAssessment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyID  int     `json:"property_id"`
+		UnitID      *int    `json:"unit_id"`
+		Description string  `json:"description"`
+		Amount      float64 `json:"amount"`
+		Frequency   string  `json:"frequency"`  // monthly, quarterly, annual or one_time
+		StartDate   string  `json:"start_date"` // YYYY-MM-DD
+		EndDate     string  `json:"end_date"`   // YYYY-MM-DD
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		http.Error(w, "start_date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	endDate, err := parseOptionalDate(req.EndDate)
+	if err != nil {
+		http.Error(w, "end_date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if !requirePropertyInScope(w, r, req.PropertyID, "Property or unit not found") {
+		return
+	}
+
+	assessment := &models.DuesAssessment{
+		PropertyID:  req.PropertyID,
+		Description: req.Description,
+		Amount:      req.Amount,
+		Frequency:   req.Frequency,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		CreatedBy:   currentUserID(r),
+	}
+	if req.UnitID != nil {
+		assessment.UnitID = sql.NullInt32{Int32: int32(*req.UnitID), Valid: true}
+	}
+
+	if err := models.CreateDuesAssessment(assessment); err != nil {
+		writeAssociationError(w, err, "Property or unit not found", "Failed to create assessment")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(assessment); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleEndDuesAssessment stops an assessment after end_date; posted charges are kept
+func handleEndDuesAssessment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid assessment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		EndDate string `json:"end_date"` // YYYY-MM-DD
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	endDate, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		http.Error(w, "end_date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.EndDuesAssessment(id, endDate); err != nil {
+		writeAssociationError(w, err, "Assessment not found or end_date is before it starts", "Failed to end assessment")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetAssociationViolations(w http.ResponseWriter, r *http.Request) {
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	violations, err := models.GetAssociationViolations(properties, r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, "Failed to fetch violations", http.StatusInternalServerError)
+		return
+	}
+
+	if violations == nil {
+		violations = []models.AssociationViolation{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(violations); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleCreateAssociationViolation records a violation. Without owner_id it is recorded against
+// the unit's current owner.
+func handleCreateAssociationViolation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UnitID      int    `json:"unit_id"`
+		OwnerID     *int   `json:"owner_id"`
+		Category    string `json:"category"`
+		Description string `json:"description"`
+		ObservedOn  string `json:"observed_on"` // YYYY-MM-DD, defaults to today
+		CureBy      string `json:"cure_by"`     // YYYY-MM-DD
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	observedOn, err := parseOptionalDate(req.ObservedOn)
+	if err != nil {
+		http.Error(w, "observed_on must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	cureBy, err := parseOptionalDate(req.CureBy)
+	if err != nil {
+		http.Error(w, "cure_by must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	violation := &models.AssociationViolation{
+		UnitID:      req.UnitID,
+		Category:    req.Category,
+		Description: req.Description,
+		ObservedOn:  time.Now().Truncate(24 * time.Hour),
+		CureBy:      cureBy,
+		CreatedBy:   currentUserID(r),
+	}
+	if observedOn.Valid {
+		violation.ObservedOn = observedOn.Time
+	}
+	if req.OwnerID != nil {
+		violation.OwnerID = sql.NullInt32{Int32: int32(*req.OwnerID), Valid: true}
+	}
+	if !requireUnitInScope(w, r, violation.UnitID, "Unit not found") {
+		return
+	}
+
+	if err := models.CreateAssociationViolation(violation); err != nil {
+		writeAssociationError(w, err, "Unit not found", "Failed to record violation")
+		return
+	}
+
+	created, err := models.GetAssociationViolation(violation.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch violation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetAssociationViolation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid violation ID", http.StatusBadRequest)
+		return
+	}
+
+	violation, err := models.GetAssociationViolation(id)
+	if err != nil {
+		writeAssociationError(w, err, "Violation not found", "Failed to fetch violation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(violation); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleFineAssociationViolation charges a fine for an uncured violation to its owner's ledger
+func handleFineAssociationViolation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid violation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Amount  float64 `json:"amount"`
+		DueDate string  `json:"due_date"` // YYYY-MM-DD, defaults to today
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	dueDate, err := parseOptionalDate(req.DueDate)
+	if err != nil {
+		http.Error(w, "due_date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if !dueDate.Valid {
+		dueDate.Time = time.Now().Truncate(24 * time.Hour)
+	}
+
+	charge, err := models.FineAssociationViolation(id, req.Amount, dueDate.Time)
+	if err != nil {
+		writeAssociationError(w, err, "Violation not found", "Failed to fine violation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(charge); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleResolveAssociationViolation closes a violation as cured or dismissed
+func handleResolveAssociationViolation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid violation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"` // cured or dismissed
+		Notes  string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.ResolveAssociationViolation(id, req.Status, req.Notes); err != nil {
+		writeAssociationError(w, err, "Violation not found", "Failed to resolve violation")
+		return
+	}
+
+	violation, err := models.GetAssociationViolation(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch violation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(violation); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
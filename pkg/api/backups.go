@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/backup"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// newBackupRunner returns the configured backup runner; replaced in tests
+var newBackupRunner = backup.NewRunnerFromEnv
+
+// RegisterBackupRoutes registers database backup trigger and history routes
+func RegisterBackupRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("admin"))
+
+		auth.Get("/api/admin/backups", handleGetBackups)
+		auth.Post("/api/admin/backups", handleTriggerBackup)
+		auth.Get("/api/admin/backups/{id}", handleGetBackup)
+	})
+}
+
+// handleTriggerBackup starts a backup in the background and returns its record for polling
+func handleTriggerBackup(w http.ResponseWriter, r *http.Request) {
+	runner := newBackupRunner()
+
+	var requestedBy sql.NullInt32
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		requestedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+
+	record, err := runner.Start(models.BackupManual, requestedBy)
+	if err != nil {
+		switch err {
+		case backup.ErrNotConfigured:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case models.ErrBackupRunning:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to start backup", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The backup outlives the request, so it must not use the request context
+	go runner.Execute(context.Background(), record)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(record); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetBackups(w http.ResponseWriter, r *http.Request) {
+	backups, err := models.GetDatabaseBackups(50)
+	if err != nil {
+		http.Error(w, "Failed to fetch backups", http.StatusInternalServerError)
+		return
+	}
+
+	if backups == nil {
+		backups = []models.DatabaseBackup{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(backups); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetBackup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid backup ID", http.StatusBadRequest)
+		return
+	}
+
+	record, err := models.GetDatabaseBackup(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Backup not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch backup", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(record); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
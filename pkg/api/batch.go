@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// maxBatchRequests bounds the sub-requests accepted in one batch
+const maxBatchRequests = 50
+
+// maxBatchBodyBytes limits the size of a batch request, all of its sub-request bodies included
+const maxBatchBodyBytes = 5 << 20
+
+// batchForwardedHeaders are copied from the batch request to every sub-request so they run as the same user
+var batchForwardedHeaders = []string{"Cookie", "Authorization", "X-API-Key"}
+
+// batchItemHeaders are the headers a sub-request may set for itself
+var batchItemHeaders = []string{"Content-Type", "Accept", "Idempotency-Key", "If-None-Match"}
+
+// batchResponseHeaders are the sub-response headers returned to the client
+var batchResponseHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// batchRequest is one sub-request of a batch
+type batchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// batchResponse is the outcome of one sub-request. JSON bodies are embedded as is, anything else as a string.
+type batchResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+// RegisterBatchRoutes registers the batch endpoint and the delta sync endpoint used by mobile clients.
+// Sub-requests are dispatched through r, so each one passes the same middleware as a direct request.
+func RegisterBatchRoutes(r *chi.Mux) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+
+		auth.With(middleware.LimitBody(maxBatchBodyBytes)).Post("/api/batch", handleBatch(r))
+
+		auth.With(middleware.RequireAnyRole("admin", "property_manager")).Get("/api/sync/changes", handleGetSyncChanges)
+	})
+}
+
+// handleBatch runs an array of API requests in order and returns each one's status and body. A failed
+// sub-request does not stop the batch; clients inspect each status.
+func handleBatch(router http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var items []batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if len(items) == 0 {
+			http.Error(w, "At least one request is required", http.StatusUnprocessableEntity)
+			return
+		}
+		if len(items) > maxBatchRequests {
+			http.Error(w, "A batch may contain at most 50 requests", http.StatusUnprocessableEntity)
+			return
+		}
+
+		responses := make([]batchResponse, 0, len(items))
+		for _, item := range items {
+			responses = append(responses, runBatchRequest(router, r, item))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(responses); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+	}
+}
+
+// runBatchRequest dispatches one sub-request through the router and records its response
+func runBatchRequest(router http.Handler, outer *http.Request, item batchRequest) batchResponse {
+	method := strings.ToUpper(item.Method)
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return batchError(http.StatusBadRequest, "Unsupported method")
+	}
+	if !strings.HasPrefix(item.Path, "/api/") {
+		return batchError(http.StatusBadRequest, "Path must start with /api/")
+	}
+	if strings.HasPrefix(item.Path, "/api/batch") {
+		return batchError(http.StatusBadRequest, "Batches cannot be nested")
+	}
+
+	var body []byte
+	if len(item.Body) > 0 && string(item.Body) != "null" {
+		body = item.Body
+	}
+
+	// Clear chi's routing context so the router matches the sub-request's own path
+	ctx := context.WithValue(outer.Context(), chi.RouteCtxKey, nil)
+	sub, err := http.NewRequestWithContext(ctx, method, item.Path, bytes.NewReader(body))
+	if err != nil {
+		return batchError(http.StatusBadRequest, "Invalid path")
+	}
+	sub.RemoteAddr = outer.RemoteAddr
+	for _, name := range batchForwardedHeaders {
+		if v := outer.Header.Get(name); v != "" {
+			sub.Header.Set(name, v)
+		}
+	}
+	for _, name := range batchItemHeaders {
+		for k, v := range item.Headers {
+			if strings.EqualFold(k, name) {
+				sub.Header.Set(name, v)
+			}
+		}
+	}
+	if body != nil && sub.Header.Get("Content-Type") == "" {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, sub)
+
+	resp := batchResponse{Status: rec.Code, Headers: map[string]string{}}
+	for _, name := range batchResponseHeaders {
+		if v := rec.Header().Get(name); v != "" {
+			resp.Headers[name] = v
+		}
+	}
+	if rec.Body.Len() > 0 {
+		if json.Valid(rec.Body.Bytes()) {
+			resp.Body = json.RawMessage(rec.Body.Bytes())
+		} else {
+			resp.Body = rec.Body.String()
+		}
+	}
+	return resp
+}
+
+// batchError is the response for a sub-request rejected before it was dispatched
+func batchError(status int, message string) batchResponse {
+	return batchResponse{Status: status, Body: message}
+}
+
+// handleGetSyncChanges returns the properties, units, tenants, leases and maintenance requests changed
+// since the RFC 3339 since parameter (default: everything). Clients pass the returned server_time as
+// since on their next sync. Managers limited to regions or portfolios only receive their properties.
+func handleGetSyncChanges(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "Invalid since, expected RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+	properties, ok := requestPropertyScope(w, r)
+	if !ok {
+		return
+	}
+
+	changes, err := models.GetSyncChanges(since, properties)
+	if err != nil {
+		http.Error(w, "Failed to fetch changes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(changes); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
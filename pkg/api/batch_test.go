@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchRunsSubRequestsInOrder(t *testing.T) {
+	var calls []string
+	r := chi.NewRouter()
+	r.Post("/api/batch", handleBatch(r))
+	r.Get("/api/properties/{id}", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "get "+chi.URLParam(r, "id")+" "+r.Header.Get("Cookie"))
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":`+chi.URLParam(r, "id")+`}`)
+	})
+	r.Post("/api/payments", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, "post "+string(body)+" "+r.Header.Get("Idempotency-Key"))
+		http.Error(w, "Invalid amount", http.StatusUnprocessableEntity)
+	})
+
+	req := httptest.NewRequest("POST", "/api/batch", strings.NewReader(`[
+		{"method": "GET", "path": "/api/properties/7"},
+		{"method": "POST", "path": "/api/payments", "headers": {"idempotency-key": "k1"}, "body": {"amount": -1}},
+		{"method": "POST", "path": "/api/batch", "body": []},
+		{"method": "GET", "path": "/api/missing"}
+	]`))
+	req.Header.Set("Cookie", "id_token=abc")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var responses []struct {
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers"`
+		Body    json.RawMessage   `json:"body"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &responses))
+	require.Len(t, responses, 4)
+
+	assert.Equal(t, []string{"get 7 id_token=abc", `post {"amount": -1} k1`}, calls)
+	assert.Equal(t, http.StatusOK, responses[0].Status)
+	assert.JSONEq(t, `{"id":7}`, string(responses[0].Body))
+	assert.Equal(t, "application/json", responses[0].Headers["Content-Type"])
+	assert.Equal(t, http.StatusUnprocessableEntity, responses[1].Status)
+	assert.Equal(t, `"Invalid amount\n"`, string(responses[1].Body))
+	assert.Equal(t, http.StatusBadRequest, responses[2].Status)
+	assert.Equal(t, http.StatusNotFound, responses[3].Status)
+}
+
+func TestBatchRejectsEmptyAndOversizedBatches(t *testing.T) {
+	r := chi.NewRouter()
+	r.Post("/api/batch", handleBatch(r))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/api/batch", strings.NewReader(`[]`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	items := strings.Repeat(`{"method":"GET","path":"/api/x"},`, maxBatchRequests)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/api/batch", strings.NewReader("["+items+`{"method":"GET","path":"/api/x"}]`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
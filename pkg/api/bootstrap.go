@@ -0,0 +1,63 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// maxBootstrapSpecBytes bounds the size of an uploaded bootstrap spec
+const maxBootstrapSpecBytes = 1 << 20
+
+// RegisterBootstrapRoutes registers the environment bootstrap endpoint. It authenticates with the
+// BOOTSTRAP_TOKEN environment variable rather than a user session, since it is meant to run before
+// any user exists, and is disabled when the variable is unset.
+func RegisterBootstrapRoutes(r chi.Router) {
+	r.Post("/api/bootstrap", handleBootstrap)
+}
+
+// handleBootstrap applies a YAML or JSON bootstrap spec and reports what it created or updated.
+// Applying the same spec again is safe.
+func handleBootstrap(w http.ResponseWriter, r *http.Request) {
+	token := os.Getenv("BOOTSTRAP_TOKEN")
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		http.Error(w, "Invalid bootstrap token", http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBootstrapSpecBytes))
+	if err != nil {
+		http.Error(w, "Bootstrap spec must be at most 1 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	spec, err := models.ParseBootstrapSpec(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	result, err := models.ApplyBootstrap(spec)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to apply bootstrap spec", "error", err)
+		http.Error(w, "Failed to apply bootstrap spec", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
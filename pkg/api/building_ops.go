@@ -0,0 +1,592 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterBuildingOpsRoutes registers the optional package and visitor endpoints for multifamily
+// buildings. They are only available when BUILDING_OPS_ENABLED=true.
+func RegisterBuildingOpsRoutes(r chi.Router) {
+	if os.Getenv("BUILDING_OPS_ENABLED") != "true" {
+		return
+	}
+
+	// Front desk
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+
+		auth.Get("/api/properties/{id}/packages", handleGetPropertyPackages)
+		auth.Post("/api/properties/{id}/packages", handleCheckInPackage)
+		auth.Post("/api/packages/{id}/pickup", handlePackagePickup)
+
+		auth.Get("/api/properties/{id}/visitors", handleGetPropertyVisitors)
+		auth.Post("/api/properties/{id}/visitors", handleCreatePropertyVisitor)
+		auth.Delete("/api/visitors/{id}", handleRevokeVisitor)
+
+		auth.Get("/api/properties/{id}/visitor-log", handleGetPropertyVisitorLog)
+		auth.Post("/api/properties/{id}/visitor-log", handleVisitorCheckIn)
+		auth.Post("/api/visitor-log/{id}/checkout", handleVisitorCheckOut)
+	})
+
+	// Tenant portal
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("tenant"))
+
+		auth.Get("/api/portal/packages", handleGetPortalPackages)
+		auth.Get("/api/portal/visitors", handleGetPortalVisitors)
+		auth.Post("/api/portal/visitors", handleCreatePortalVisitor)
+		auth.Delete("/api/portal/visitors/{id}", handleRevokePortalVisitor)
+		auth.Get("/api/portal/visitor-log", handleGetPortalVisitorLog)
+	})
+}
+
+// visitorRequest is the request body for pre-authorizing a visitor
+type visitorRequest struct {
+	PropertyID  int       `json:"property_id"`
+	TenantID    *int      `json:"tenant_id"`
+	VisitorName string    `json:"visitor_name"`
+	ValidFrom   time.Time `json:"valid_from"`
+	ValidUntil  time.Time `json:"valid_until"`
+	Notes       string    `json:"notes"`
+}
+
+// toAuthorization validates the request and converts it to an authorization.
+// valid_from defaults to now and valid_until to 24 hours later.
+func (req visitorRequest) toAuthorization(userID int) (*models.VisitorAuthorization, string) {
+	if strings.TrimSpace(req.VisitorName) == "" {
+		return nil, "visitor_name is required"
+	}
+	if req.ValidFrom.IsZero() {
+		req.ValidFrom = time.Now()
+	}
+	if req.ValidUntil.IsZero() {
+		req.ValidUntil = req.ValidFrom.Add(24 * time.Hour)
+	}
+	if !req.ValidUntil.After(req.ValidFrom) {
+		return nil, "valid_until must be after valid_from"
+	}
+
+	auth := &models.VisitorAuthorization{
+		PropertyID:  req.PropertyID,
+		VisitorName: strings.TrimSpace(req.VisitorName),
+		ValidFrom:   req.ValidFrom,
+		ValidUntil:  req.ValidUntil,
+		Notes:       models.NullString(strings.TrimSpace(req.Notes)),
+		CreatedBy:   sql.NullInt32{Int32: int32(userID), Valid: true},
+	}
+	if req.TenantID != nil {
+		auth.TenantID = sql.NullInt32{Int32: int32(*req.TenantID), Valid: true}
+	}
+	return auth, ""
+}
+
+// parseLogRange reads start and end (YYYY-MM-DD) query parameters, defaulting to the last 7 days.
+// The end date is inclusive.
+func parseLogRange(r *http.Request) (time.Time, time.Time, bool) {
+	end := time.Now()
+	start := end.AddDate(0, 0, -7)
+
+	if s := r.URL.Query().Get("start"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return start, end, false
+		}
+		start = parsed
+	}
+	if s := r.URL.Query().Get("end"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return start, end, false
+		}
+		end = parsed.AddDate(0, 0, 1)
+	}
+	return start, end, end.After(start)
+}
+
+func handleGetPropertyPackages(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	packages, err := models.GetPackages(propertyID, r.URL.Query().Get("status"), 0)
+	if err != nil {
+		http.Error(w, "Failed to fetch packages", http.StatusInternalServerError)
+		return
+	}
+	if packages == nil {
+		packages = []models.BuildingPackage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(packages); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCheckInPackage(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		TenantID        *int   `json:"tenant_id"`
+		UnitID          *int   `json:"unit_id"`
+		Carrier         string `json:"carrier"`
+		TrackingNumber  string `json:"tracking_number"`
+		Description     string `json:"description"`
+		StorageLocation string `json:"storage_location"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.TenantID == nil && req.UnitID == nil {
+		http.Error(w, "tenant_id or unit_id is required", http.StatusBadRequest)
+		return
+	}
+
+	pkg := &models.BuildingPackage{
+		PropertyID:      propertyID,
+		Carrier:         models.NullString(req.Carrier),
+		TrackingNumber:  models.NullString(req.TrackingNumber),
+		Description:     models.NullString(req.Description),
+		StorageLocation: models.NullString(req.StorageLocation),
+		ReceivedBy:      sql.NullInt32{Int32: int32(user.ID), Valid: true},
+	}
+	if req.TenantID != nil {
+		pkg.TenantID = sql.NullInt32{Int32: int32(*req.TenantID), Valid: true}
+	}
+	if req.UnitID != nil {
+		pkg.UnitID = sql.NullInt32{Int32: int32(*req.UnitID), Valid: true}
+	}
+
+	if err := models.CheckInPackage(pkg); err != nil {
+		http.Error(w, "Failed to check in package", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(pkg); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handlePackagePickup(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	packageID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid package ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		PickedUpBy string `json:"picked_up_by"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	switch err := models.ReleasePackage(packageID, strings.TrimSpace(req.PickedUpBy), user.ID); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case sql.ErrNoRows:
+		http.Error(w, "Package not found", http.StatusNotFound)
+	case models.ErrPackageNotWaiting:
+		http.Error(w, "Package has already been picked up or returned", http.StatusConflict)
+	default:
+		http.Error(w, "Failed to record package pickup", http.StatusInternalServerError)
+	}
+}
+
+func handleGetPropertyVisitors(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	auths, err := models.GetVisitorAuthorizations(propertyID, 0, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to fetch visitor authorizations", http.StatusInternalServerError)
+		return
+	}
+	if auths == nil {
+		auths = []models.VisitorAuthorization{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(auths); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCreatePropertyVisitor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req visitorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.PropertyID = propertyID
+
+	auth, msg := req.toAuthorization(user.ID)
+	if auth == nil {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := models.CreateVisitorAuthorization(auth); err != nil {
+		http.Error(w, "Failed to create visitor authorization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(auth); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleRevokeVisitor(w http.ResponseWriter, r *http.Request) {
+	authID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid visitor authorization ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RevokeVisitorAuthorization(authID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Visitor authorization not found or already revoked", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to revoke visitor authorization", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetPropertyVisitorLog(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	start, end, ok := parseLogRange(r)
+	if !ok {
+		http.Error(w, "Invalid start or end date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := models.GetVisitorLog(propertyID, 0, start, end)
+	if err != nil {
+		http.Error(w, "Failed to fetch visitor log", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []models.VisitorLogEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleVisitorCheckIn logs an arrival. Pre-authorized visitors give their access code;
+// walk-ins are logged by name.
+func handleVisitorCheckIn(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		AccessCode  string `json:"access_code"`
+		VisitorName string `json:"visitor_name"`
+		TenantID    *int   `json:"tenant_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.AccessCode = strings.TrimSpace(req.AccessCode)
+	if req.AccessCode == "" && strings.TrimSpace(req.VisitorName) == "" {
+		http.Error(w, "access_code or visitor_name is required", http.StatusBadRequest)
+		return
+	}
+
+	entry := &models.VisitorLogEntry{
+		PropertyID:  propertyID,
+		VisitorName: strings.TrimSpace(req.VisitorName),
+		LoggedBy:    sql.NullInt32{Int32: int32(user.ID), Valid: true},
+	}
+	if req.TenantID != nil {
+		entry.TenantID = sql.NullInt32{Int32: int32(*req.TenantID), Valid: true}
+	}
+
+	if err := models.CheckInVisitor(entry, req.AccessCode, time.Now()); err != nil {
+		if err == models.ErrNoActiveAuthorization {
+			http.Error(w, "Access code is invalid, expired or revoked", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to check in visitor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleVisitorCheckOut(w http.ResponseWriter, r *http.Request) {
+	entryID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid visitor log ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.CheckOutVisitor(entryID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Visitor log entry not found or already checked out", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to check out visitor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// portalTenant resolves the tenant record for the logged-in user, writing an error response if there is none
+func portalTenant(w http.ResponseWriter, r *http.Request) (*models.Tenant, bool) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	tenant, err := models.GetTenantForUser(user)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "No tenant record is linked to this account", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to load tenant", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return tenant, true
+}
+
+func handleGetPortalPackages(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	packages, err := models.GetPackages(0, r.URL.Query().Get("status"), tenant.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch packages", http.StatusInternalServerError)
+		return
+	}
+	if packages == nil {
+		packages = []models.BuildingPackage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(packages); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetPortalVisitors(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	auths, err := models.GetVisitorAuthorizations(0, tenant.ID, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to fetch visitor authorizations", http.StatusInternalServerError)
+		return
+	}
+	if auths == nil {
+		auths = []models.VisitorAuthorization{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(auths); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleCreatePortalVisitor lets a tenant pre-authorize a visitor at a property where they hold an
+// active lease. property_id may be omitted when the tenant leases at a single property.
+func handleCreatePortalVisitor(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUserFromContext(r.Context())
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	var req visitorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	propertyIDs, err := models.GetTenantPropertyIDs(tenant.ID)
+	if err != nil {
+		http.Error(w, "Failed to load tenant properties", http.StatusInternalServerError)
+		return
+	}
+	if req.PropertyID == 0 && len(propertyIDs) == 1 {
+		req.PropertyID = propertyIDs[0]
+	}
+
+	leased := false
+	for _, id := range propertyIDs {
+		if id == req.PropertyID {
+			leased = true
+			break
+		}
+	}
+	if !leased {
+		http.Error(w, "You do not have an active lease at this property", http.StatusForbidden)
+		return
+	}
+
+	req.TenantID = &tenant.ID
+	auth, msg := req.toAuthorization(user.ID)
+	if auth == nil {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := models.CreateVisitorAuthorization(auth); err != nil {
+		http.Error(w, "Failed to create visitor authorization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(auth); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleRevokePortalVisitor(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	authID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid visitor authorization ID", http.StatusBadRequest)
+		return
+	}
+
+	// Tenants may only revoke their own authorizations
+	auth, err := models.GetVisitorAuthorization(authID)
+	if err == sql.ErrNoRows || (err == nil && (!auth.TenantID.Valid || int(auth.TenantID.Int32) != tenant.ID)) {
+		http.Error(w, "Visitor authorization not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load visitor authorization", http.StatusInternalServerError)
+		return
+	}
+
+	handleRevokeVisitor(w, r)
+}
+
+func handleGetPortalVisitorLog(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	start, end, ok := parseLogRange(r)
+	if !ok {
+		http.Error(w, "Invalid start or end date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := models.GetVisitorLog(0, tenant.ID, start, end)
+	if err != nil {
+		http.Error(w, "Failed to fetch visitor log", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []models.VisitorLogEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterChangeFeedRoutes registers the change feed integrators use for incremental syncs
+func RegisterChangeFeedRoutes(r chi.Router) {
+	r.Group(func(integ chi.Router) {
+		integ.Use(middleware.RequireAPIKey)
+		integ.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		integ.Get("/api/changes", handleGetChanges)
+	})
+}
+
+// changeFeedPage is one page of the change feed. Clients pass next_cursor as since on the next
+// call and keep paging while has_more is true.
+type changeFeedPage struct {
+	Changes    []models.EntityChange `json:"changes"`
+	NextCursor string                `json:"next_cursor"`
+	HasMore    bool                  `json:"has_more"`
+}
+
+// handleGetChanges returns changes recorded after the since cursor (default: from the beginning),
+// in commit order, optionally filtered by entity_type and group_id. limit defaults to 100, at most
+// 1000. Keys of managers limited to regions or portfolios only see those properties' changes.
+// Changes older than the feed's retention are pruned, so clients must sync at least that often.
+func handleGetChanges(w http.ResponseWriter, r *http.Request) {
+	var since models.ChangeCursor
+	if s := r.URL.Query().Get("since"); s != "" {
+		parsed, err := models.ParseChangeCursor(s)
+		if err != nil {
+			http.Error(w, "Invalid since cursor", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	limit := 100
+	if s := r.URL.Query().Get("limit"); s != "" {
+		l, err := strconv.Atoi(s)
+		if err != nil || l <= 0 || l > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	entityType := r.URL.Query().Get("entity_type")
+	if entityType != "" && !models.ChangeFeedEntityTypes[entityType] {
+		http.Error(w, "Unknown entity_type", http.StatusBadRequest)
+		return
+	}
+
+	properties, ok := requestPropertyScope(w, r)
+	if !ok {
+		return
+	}
+
+	// Fetch one extra change to tell whether another page follows
+	changes, err := models.GetEntityChanges(since, entityType, limit+1, properties)
+	if err != nil {
+		http.Error(w, "Failed to fetch changes", http.StatusInternalServerError)
+		return
+	}
+
+	page := changeFeedPage{Changes: changes, NextCursor: since.String()}
+	if len(page.Changes) > limit {
+		page.Changes = page.Changes[:limit]
+		page.HasMore = true
+	}
+	if page.Changes == nil {
+		page.Changes = []models.EntityChange{}
+	}
+	if len(page.Changes) > 0 {
+		page.NextCursor = page.Changes[len(page.Changes)-1].Cursor
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
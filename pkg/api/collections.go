@@ -0,0 +1,355 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterCollectionsRoutes registers the collections workflow routes: delinquency stages,
+// collection cases, promises to pay and formal notices
+func RegisterCollectionsRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/collections/stages", handleGetCollectionStages)
+		auth.Put("/api/collections/stages/{name}", handleSaveCollectionStage)
+		auth.Delete("/api/collections/stages/{name}", handleDeleteCollectionStage)
+
+		auth.Get("/api/collections/delinquencies", handleGetLeaseDelinquencies)
+		// Cases are also brought up to date hourly
+		auth.Post("/api/collections/run", handleRunCollections)
+		auth.Get("/api/collections/cases", handleGetCollectionCases)
+		auth.Get("/api/collections/cases/{id}", handleGetCollectionCase)
+		auth.Post("/api/collections/cases/{id}/promises", handleRecordPromiseToPay)
+		auth.Post("/api/collections/cases/{id}/notices", handleIssueCollectionNotice)
+		auth.Get("/api/collections/notices/{id}/pdf", handleGetCollectionNoticePDF)
+	})
+}
+
+// writeCollectionCaseError maps collection case errors to responses
+func writeCollectionCaseError(w http.ResponseWriter, err error, failure string) {
+	switch err {
+	case models.ErrCollectionCaseClosed:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case sql.ErrNoRows:
+		http.Error(w, "Collection case not found", http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+func handleGetCollectionStages(w http.ResponseWriter, r *http.Request) {
+	stages, err := models.GetCollectionStages()
+	if err != nil {
+		http.Error(w, "Failed to fetch collection stages", http.StatusInternalServerError)
+		return
+	}
+
+	if stages == nil {
+		stages = []models.CollectionStage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stages); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleSaveCollectionStage(w http.ResponseWriter, r *http.Request) {
+	var stage models.CollectionStage
+	if err := json.NewDecoder(r.Body).Decode(&stage); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	stage.Name = strings.ToLower(chi.URLParam(r, "name"))
+	if err := models.SaveCollectionStage(&stage); err != nil {
+		if err == models.ErrInvalidCollectionStage {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to save collection stage", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stage); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteCollectionStage(w http.ResponseWriter, r *http.Request) {
+	if err := models.DeleteCollectionStage(strings.ToLower(chi.URLParam(r, "name"))); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Collection stage not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete collection stage", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetLeaseDelinquencies lists the active leases with rent past due, as of today or ?as_of=YYYY-MM-DD
+func handleGetLeaseDelinquencies(w http.ResponseWriter, r *http.Request) {
+	asOf := time.Now()
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "as_of must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+		asOf = parsed
+	}
+
+	delinquencies, err := models.GetLeaseDelinquencies(nil, asOf)
+	if err != nil {
+		http.Error(w, "Failed to fetch delinquent leases", http.StatusInternalServerError)
+		return
+	}
+
+	if delinquencies == nil {
+		delinquencies = []models.LeaseDelinquency{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(delinquencies); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleRunCollections(w http.ResponseWriter, r *http.Request) {
+	result, err := models.RunCollections(time.Now())
+	if err != nil {
+		http.Error(w, "Failed to run collections", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetCollectionCases(w http.ResponseWriter, r *http.Request) {
+	cases, err := models.GetCollectionCases(r.URL.Query().Get("status"), nil)
+	if err != nil {
+		http.Error(w, "Failed to fetch collection cases", http.StatusInternalServerError)
+		return
+	}
+
+	if cases == nil {
+		cases = []models.CollectionCase{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cases); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetCollectionCase(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid collection case ID", http.StatusBadRequest)
+		return
+	}
+
+	c, err := models.GetCollectionCase(id)
+	if err != nil {
+		writeCollectionCaseError(w, err, "Failed to fetch collection case")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleRecordPromiseToPay records a tenant's promise to pay, pausing the case's escalation until
+// the promised date
+func handleRecordPromiseToPay(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	caseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid collection case ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Amount       float64 `json:"amount"`
+		PromisedDate string  `json:"promised_date"` // YYYY-MM-DD
+		Notes        string  `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Amount <= 0 {
+		http.Error(w, "amount must be a positive number", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	promisedDate, err := time.Parse("2006-01-02", req.PromisedDate)
+	if err != nil || promisedDate.Before(now.UTC().Truncate(24*time.Hour)) {
+		http.Error(w, "promised_date must be in YYYY-MM-DD format and not in the past", http.StatusBadRequest)
+		return
+	}
+
+	promise := &models.PromiseToPay{
+		CaseID:       caseID,
+		Amount:       req.Amount,
+		PromisedDate: promisedDate,
+		Notes:        models.NullString(strings.TrimSpace(req.Notes)),
+		CreatedBy:    sql.NullInt32{Int32: int32(user.ID), Valid: true},
+	}
+	if err := models.RecordPromiseToPay(promise, now); err != nil {
+		writeCollectionCaseError(w, err, "Failed to record promise to pay")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(promise); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleIssueCollectionNotice escalates a case to a formal notice without waiting for its stage
+func handleIssueCollectionNotice(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	caseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid collection case ID", http.StatusBadRequest)
+		return
+	}
+
+	issuedBy := sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	notice, err := models.IssueCollectionNotice(caseID, issuedBy, time.Now())
+	if err != nil {
+		writeCollectionCaseError(w, err, "Failed to issue collection notice")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(notice); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetCollectionNoticePDF(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid notice ID", http.StatusBadRequest)
+		return
+	}
+
+	notice, err := models.GetCollectionNotice(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Notice not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch notice", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	data, err := NewPDFReportGenerator().GenerateDocumentPDF(collectionNoticeTemplate, notice, collectionNoticeLines(notice))
+	if err != nil {
+		http.Error(w, "Failed to generate notice", http.StatusInternalServerError)
+		return
+	}
+	writePDF(w, "notice_"+notice.Reference()+".pdf", data)
+}
+
+// collectionNoticeLines is the plain text form of a formal notice of unpaid rent
+func collectionNoticeLines(n *models.CollectionNotice) []string {
+	premises := n.PropertyName
+	if n.UnitNumber.Valid {
+		premises += ", " + n.UnitNumber.String
+	}
+	return []string{
+		"Fire PMAAS - Formal Notice of Unpaid Rent",
+		"",
+		"Notice number: " + n.Reference(),
+		"Date issued:   " + n.IssuedAt.Format("January 2, 2006"),
+		"",
+		"To:            " + n.TenantName,
+		"Premises:      " + premises,
+		"Address:       " + n.PropertyAddress,
+		"",
+		fmt.Sprintf("Monthly rent:  $%.2f", n.MonthlyRent),
+		fmt.Sprintf("Amount due:    $%.2f", n.AmountDue),
+		fmt.Sprintf("Days past due: %d", n.DaysPastDue),
+		"",
+		"Our records show the rent above remains unpaid. Please pay the amount due in full, or",
+		"contact the property manager to arrange payment. Further action may be taken if the",
+		"balance is not paid.",
+	}
+}
+
+// collectionNoticeTemplate renders a formal notice of unpaid rent for wkhtmltopdf
+var collectionNoticeTemplate = template.Must(template.New("collection_notice").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Notice {{.Reference}}</title>
+    <style>
+        body { font-family: Arial, sans-serif; color: #333; padding: 20px; }
+        h1 { color: #1F2937; border-bottom: 2px solid #DC2626; padding-bottom: 10px; }
+        table { border-collapse: collapse; width: 100%; }
+        td { padding: 8px 0; }
+        .label { font-weight: bold; width: 180px; }
+        .amount { font-size: 24px; font-weight: bold; }
+        .footer { margin-top: 40px; color: #6B7280; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <h1>Formal Notice of Unpaid Rent</h1>
+    <table>
+        <tr><td class="label">Notice number</td><td>{{.Reference}}</td></tr>
+        <tr><td class="label">Date issued</td><td>{{.IssuedAt.Format "January 2, 2006"}}</td></tr>
+        <tr><td class="label">To</td><td>{{.TenantName}}</td></tr>
+        <tr><td class="label">Premises</td><td>{{.PropertyName}}{{if .UnitNumber.Valid}}, {{.UnitNumber.String}}{{end}}<br>{{.PropertyAddress}}</td></tr>
+        <tr><td class="label">Monthly rent</td><td>${{printf "%.2f" .MonthlyRent}}</td></tr>
+        <tr><td class="label">Amount due</td><td class="amount">${{printf "%.2f" .AmountDue}}</td></tr>
+        <tr><td class="label">Days past due</td><td>{{.DaysPastDue}}</td></tr>
+    </table>
+    <p>Our records show the rent above remains unpaid. Please pay the amount due in full, or contact the
+    property manager to arrange payment. Further action may be taken if the balance is not paid.</p>
+    <div class="footer">Fire PMAAS - Property Management as a Service</div>
+</body>
+</html>`))
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterConcessionRoutes registers lease concession and effective rent routes
+func RegisterConcessionRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/leases/{id}/concessions", handleGetLeaseConcessions)
+		auth.Post("/api/leases/{id}/concessions", handleCreateLeaseConcession)
+		auth.Delete("/api/concessions/{id}", handleDeleteLeaseConcession)
+	})
+}
+
+// handleGetLeaseConcessions returns a lease's concessions and its net effective rent
+func handleGetLeaseConcessions(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	lease, err := models.GetScheduledLease(leaseID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Lease not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch lease concessions", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	concessions := lease.Concessions
+	if concessions == nil {
+		concessions = []models.LeaseConcession{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"lease_id":       lease.LeaseID,
+		"monthly_rent":   lease.MonthlyRent,
+		"effective_rent": lease.EffectiveRent(),
+		"concessions":    concessions,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleCreateLeaseConcession records a free-rent period or discount on a lease
+func handleCreateLeaseConcession(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ConcessionType string   `json:"concession_type"`
+		StartMonth     string   `json:"start_month"` // YYYY-MM
+		Months         int      `json:"months"`
+		Amount         *float64 `json:"amount"`
+		Description    string   `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	valid := false
+	for _, t := range models.ValidConcessionTypes {
+		valid = valid || req.ConcessionType == t
+	}
+	if !valid {
+		http.Error(w, "concession_type must be free_rent, fixed_discount or percent_discount", http.StatusBadRequest)
+		return
+	}
+
+	startMonth, err := time.Parse("2006-01", req.StartMonth)
+	if err != nil {
+		http.Error(w, "start_month must be in YYYY-MM format", http.StatusBadRequest)
+		return
+	}
+	if req.Months <= 0 {
+		http.Error(w, "months must be at least 1", http.StatusBadRequest)
+		return
+	}
+
+	concession := &models.LeaseConcession{
+		LeaseID:        leaseID,
+		ConcessionType: req.ConcessionType,
+		StartMonth:     startMonth,
+		Months:         req.Months,
+		Description:    models.NullString(req.Description),
+	}
+	if req.ConcessionType != models.ConcessionFreeRent {
+		if req.Amount == nil || *req.Amount <= 0 {
+			http.Error(w, "amount must be a positive number for discounts", http.StatusBadRequest)
+			return
+		}
+		if req.ConcessionType == models.ConcessionPercentDiscount && *req.Amount > 100 {
+			http.Error(w, "amount must not exceed 100 for percent_discount", http.StatusBadRequest)
+			return
+		}
+		concession.Amount = sql.NullFloat64{Float64: *req.Amount, Valid: true}
+	}
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		concession.CreatedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+
+	if err := models.CreateLeaseConcession(concession); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Lease not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to create lease concession", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(concession); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteLeaseConcession(w http.ResponseWriter, r *http.Request) {
+	concessionID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid concession ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteLeaseConcession(concessionID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Concession not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete lease concession", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+)
+
+// resourceETag builds a weak ETag from when a resource was last modified and anything else the
+// response depends on, such as the viewer
+func resourceETag(lastModified time.Time, parts ...interface{}) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d", lastModified.UnixNano())
+	for _, part := range parts {
+		fmt.Fprintf(hash, "|%v", part)
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil))[:16] + `"`
+}
+
+// checkNotModified sets the ETag and Last-Modified headers for a GET response and answers 304
+// Not Modified when the client's If-None-Match or If-Modified-Since shows its copy is current.
+// It returns true when the 304 has been written and the handler should stop.
+func checkNotModified(w http.ResponseWriter, r *http.Request, lastModified time.Time, parts ...interface{}) bool {
+	etag := resourceETag(lastModified, parts...)
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	// Responses are per user and must be revalidated before reuse
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	notModified := false
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		// If-None-Match takes precedence over If-Modified-Since
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				notModified = true
+				break
+			}
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		// HTTP dates have one second resolution
+		notModified = !lastModified.Truncate(time.Second).After(since)
+	}
+
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return notModified
+}
+
+// templatesLoadedAt versions rendered pages, whose templates change with each deploy
+var templatesLoadedAt = time.Now()
+
+// checkPageNotModified is checkNotModified for rendered pages, which also depend on the viewer's
+// roles and preferences and on the templates
+func checkPageNotModified(w http.ResponseWriter, r *http.Request, lastModified time.Time, parts ...interface{}) bool {
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		parts = append(parts, user.ID, user.UpdatedAt.UnixNano())
+		for _, role := range user.Roles {
+			parts = append(parts, role.Name)
+		}
+	}
+	if templatesLoadedAt.After(lastModified) {
+		lastModified = templatesLoadedAt
+	}
+	return checkNotModified(w, r, lastModified, parts...)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNotModifiedETag(t *testing.T) {
+	updatedAt := time.Date(2026, 3, 4, 10, 30, 15, 500, time.UTC)
+
+	rr := httptest.NewRecorder()
+	assert.False(t, checkNotModified(rr, httptest.NewRequest("GET", "/api/reports/1", nil), updatedAt))
+	etag := rr.Header().Get("ETag")
+	assert.Regexp(t, `^W/"[0-9a-f]{16}"$`, etag)
+	assert.Equal(t, "Wed, 04 Mar 2026 10:30:15 GMT", rr.Header().Get("Last-Modified"))
+
+	req := httptest.NewRequest("GET", "/api/reports/1", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	rr = httptest.NewRecorder()
+	assert.True(t, checkNotModified(rr, req, updatedAt))
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	// A newer updated_at changes the ETag
+	rr = httptest.NewRecorder()
+	assert.False(t, checkNotModified(rr, req, updatedAt.Add(time.Millisecond)))
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+}
+
+func TestCheckNotModifiedIfModifiedSince(t *testing.T) {
+	updatedAt := time.Date(2026, 3, 4, 10, 30, 15, 500, time.UTC)
+
+	req := httptest.NewRequest("GET", "/api/users/profile", nil)
+	req.Header.Set("If-Modified-Since", "Wed, 04 Mar 2026 10:30:15 GMT")
+	rr := httptest.NewRecorder()
+	assert.True(t, checkNotModified(rr, req, updatedAt))
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	rr = httptest.NewRecorder()
+	assert.False(t, checkNotModified(rr, req, updatedAt.Add(time.Second)))
+
+	// If-None-Match takes precedence
+	req.Header.Set("If-None-Match", `W/"stale"`)
+	rr = httptest.NewRecorder()
+	assert.False(t, checkNotModified(rr, req, updatedAt))
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Refresh intervals in seconds hinted for widget data when no dashboard interval applies
+const (
+	defaultWidgetRefreshSeconds  = 300
+	defaultHistoryRefreshSeconds = 3600
+)
+
+// setRefreshHints tells clients how long widget data stays fresh. The interval comes from the
+// dashboard named by the dashboard_id query parameter when it has auto-refresh configured, and
+// is otherwise defaultSeconds. Cache-Control max-age runs until the next aligned refresh, which
+// X-Next-Refresh also gives as a timestamp, so all viewers of a dashboard refresh together.
+// X-Refresh-Interval is 0 when the dashboard has auto-refresh turned off.
+func setRefreshHints(w http.ResponseWriter, r *http.Request, defaultSeconds int, now time.Time) {
+	interval := defaultSeconds
+	if dashboard := refreshDashboard(r); dashboard != nil {
+		interval = dashboard.RefreshInterval()
+	}
+
+	w.Header().Set("X-Refresh-Interval", strconv.Itoa(interval))
+	if interval == 0 {
+		w.Header().Set("Cache-Control", "private, no-cache")
+		return
+	}
+	next := models.NextRefresh(now, interval)
+	maxAge := int(next.Sub(now).Seconds())
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+	w.Header().Set("X-Next-Refresh", next.UTC().Format(time.RFC3339))
+}
+
+// refreshDashboard returns the dashboard widget data is being requested for, or nil when
+// dashboard_id is missing, unknown or names a dashboard the user cannot see
+func refreshDashboard(r *http.Request) *models.AnalyticsDashboard {
+	id, err := strconv.Atoi(r.URL.Query().Get("dashboard_id"))
+	if err != nil {
+		return nil
+	}
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		return nil
+	}
+	dashboard, err := models.GetAnalyticsDashboardByID(id)
+	if err != nil {
+		return nil
+	}
+	if !dashboard.IsPublic && dashboard.CreatedBy != user.ID && !user.HasRole("admin") {
+		return nil
+	}
+	return dashboard
+}
+
+// handleSetDashboardRefresh sets a dashboard's auto-refresh interval, stored with its layout.
+// An interval of 0 turns auto-refresh off.
+func handleSetDashboardRefresh(w http.ResponseWriter, r *http.Request) {
+	dashboardID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid dashboard ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		RefreshIntervalSeconds *int `json:"refresh_interval_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.RefreshIntervalSeconds == nil {
+		http.Error(w, "refresh_interval_seconds is required", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateDashboardRefreshInterval(*req.RefreshIntervalSeconds); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	dashboard, err := models.GetAnalyticsDashboardByID(dashboardID)
+	if err != nil {
+		http.Error(w, "Dashboard not found", http.StatusNotFound)
+		return
+	}
+	// Only the owner or an admin may change a dashboard's settings
+	if dashboard.CreatedBy != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	if err := models.SetDashboardRefreshInterval(dashboardID, *req.RefreshIntervalSeconds); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Dashboard not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":                       dashboardID,
+		"refresh_interval_seconds": *req.RefreshIntervalSeconds,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
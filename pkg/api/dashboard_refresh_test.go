@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRefreshHints(t *testing.T) {
+	now := time.Date(2026, 10, 16, 15, 7, 30, 0, time.UTC)
+
+	rr := httptest.NewRecorder()
+	setRefreshHints(rr, httptest.NewRequest("GET", "/api/stats/financial", nil), defaultWidgetRefreshSeconds, now)
+	assert.Equal(t, "300", rr.Header().Get("X-Refresh-Interval"))
+	assert.Equal(t, "private, max-age=150", rr.Header().Get("Cache-Control"))
+	assert.Equal(t, "2026-10-16T15:10:00Z", rr.Header().Get("X-Next-Refresh"))
+
+	// Without a user an unknown dashboard falls back to the default interval
+	rr = httptest.NewRecorder()
+	setRefreshHints(rr, httptest.NewRequest("GET", "/api/stats/financial?dashboard_id=9", nil), defaultHistoryRefreshSeconds, now)
+	assert.Equal(t, "3600", rr.Header().Get("X-Refresh-Interval"))
+	assert.Equal(t, "2026-10-16T16:00:00Z", rr.Header().Get("X-Next-Refresh"))
+}
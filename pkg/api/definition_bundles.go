@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// maxDefinitionBundleBytes limits the size of an imported definition bundle
+const maxDefinitionBundleBytes = 10 << 20
+
+// RegisterDefinitionBundleRoutes registers export and import of report, chart and dashboard
+// definitions for promoting them between environments
+func RegisterDefinitionBundleRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		// Bundles span every user's definitions, so they are limited to administrators
+		auth.Use(middleware.RequireRole("admin"))
+
+		auth.Get("/api/definitions/export", handleExportDefinitions)
+		auth.With(middleware.LimitBody(maxDefinitionBundleBytes), middleware.Idempotent).Post("/api/definitions/import", handleImportDefinitions)
+	})
+}
+
+// definitionImportRequest is an exported bundle, optionally with owner_map added to assign
+// definitions to users by their source environment ID
+type definitionImportRequest struct {
+	models.DefinitionBundle
+	OwnerMap map[int]int `json:"owner_map,omitempty"`
+}
+
+// parseIDList parses a comma-separated list of IDs
+func parseIDList(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// handleExportDefinitions downloads a definition bundle. The reports, charts and dashboards query
+// parameters list the IDs to export; with none of them every definition is exported.
+func handleExportDefinitions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var sel models.DefinitionSelection
+	var err error
+	if sel.ReportIDs, err = parseIDList(query.Get("reports")); err != nil {
+		http.Error(w, "Invalid report IDs", http.StatusBadRequest)
+		return
+	}
+	if sel.ChartIDs, err = parseIDList(query.Get("charts")); err != nil {
+		http.Error(w, "Invalid chart IDs", http.StatusBadRequest)
+		return
+	}
+	if sel.DashboardIDs, err = parseIDList(query.Get("dashboards")); err != nil {
+		http.Error(w, "Invalid dashboard IDs", http.StatusBadRequest)
+		return
+	}
+	sel.All = len(sel.ReportIDs) == 0 && len(sel.ChartIDs) == 0 && len(sel.DashboardIDs) == 0
+
+	bundle, err := models.ExportDefinitions(sel)
+	if err != nil {
+		if errors.Is(err, models.ErrDefinitionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to export definitions", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"definitions_%s.json\"", time.Now().Format("20060102")))
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleImportDefinitions creates the definitions in a bundle and returns the new ID of each.
+// Owners are taken from owner_map, then matched by email, and otherwise set to the importer.
+func handleImportDefinitions(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	var req definitionImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	result, err := models.ImportDefinitions(&req.DefinitionBundle, req.OwnerMap, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrUnsupportedBundle) || errors.Is(err, models.ErrInvalidDefinition) ||
+			errors.Is(err, models.ErrUnknownOwner) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		} else {
+			http.Error(w, "Failed to import definitions", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,357 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterDepositRoutes registers lease creation, security deposit rule and compliance routes
+func RegisterDepositRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		// Leases and their deposits, validated against the jurisdiction rules
+		auth.Post("/api/leases", handleCreateLease)
+		auth.Put("/api/leases/{id}/deposit", handleSetLeaseDeposit)
+		auth.Put("/api/leases/{id}/notice", handleRecordLeaseNotice)
+		auth.Delete("/api/leases/{id}/notice", handleWithdrawLeaseNotice)
+
+		// Jurisdiction rules (default and per-property)
+		auth.Get("/api/deposits/rules", handleGetDepositRules)
+		auth.Put("/api/deposits/rules", handleSaveDepositRule)
+		auth.Delete("/api/deposits/rules/{propertyID}", handleDeleteDepositRule)
+
+		// Deposits that break the rules
+		auth.Get("/api/deposits/compliance", handleGetDepositCompliance)
+	})
+}
+
+// depositRequest holds the deposit fields shared by lease creation and deposit updates
+type depositRequest struct {
+	SecurityDeposit      *float64 `json:"security_deposit"`
+	DepositReceivedDate  string   `json:"deposit_received_date"` // YYYY-MM-DD
+	DepositEscrowAccount string   `json:"deposit_escrow_account"`
+}
+
+// parse converts the deposit fields, returning a message on failure
+func (req depositRequest) parse() (sql.NullFloat64, sql.NullTime, sql.NullString, string) {
+	var deposit sql.NullFloat64
+	var received sql.NullTime
+	if req.SecurityDeposit != nil {
+		if *req.SecurityDeposit < 0 {
+			return deposit, received, sql.NullString{}, "security_deposit must not be negative"
+		}
+		deposit = sql.NullFloat64{Float64: *req.SecurityDeposit, Valid: true}
+	}
+	if req.DepositReceivedDate != "" {
+		parsed, err := time.Parse("2006-01-02", req.DepositReceivedDate)
+		if err != nil {
+			return deposit, received, sql.NullString{}, "deposit_received_date must be in YYYY-MM-DD format"
+		}
+		received = sql.NullTime{Time: parsed, Valid: true}
+	}
+	return deposit, received, models.NullString(req.DepositEscrowAccount), ""
+}
+
+// writeDepositError maps deposit validation and lookup errors to responses
+func writeDepositError(w http.ResponseWriter, err error, notFound string) {
+	var violation *models.DepositViolationError
+	switch {
+	case errors.As(err, &violation):
+		http.Error(w, violation.Error(), http.StatusUnprocessableEntity)
+	case err == models.ErrAssociationProperty:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err == sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to save lease", http.StatusInternalServerError)
+	}
+}
+
+func handleCreateLease(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		depositRequest
+		UnitID      int     `json:"unit_id"`
+		TenantID    int     `json:"tenant_id"`
+		StartDate   string  `json:"start_date"` // YYYY-MM-DD
+		EndDate     string  `json:"end_date"`   // YYYY-MM-DD
+		MonthlyRent float64 `json:"monthly_rent"`
+		Status      string  `json:"status"`
+
+		ProrationConvention string `json:"proration_convention"` // Defaults to PRORATION_CONVENTION
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.UnitID <= 0 || req.TenantID <= 0 {
+		http.Error(w, "unit_id and tenant_id are required", http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		http.Error(w, "start_date must be in YYYY-MM-DD format", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil || end.Before(start) {
+		http.Error(w, "end_date must be in YYYY-MM-DD format and not before start_date", http.StatusBadRequest)
+		return
+	}
+	if req.MonthlyRent <= 0 {
+		http.Error(w, "monthly_rent must be a positive number", http.StatusBadRequest)
+		return
+	}
+	switch req.Status {
+	case "", "pending", "active":
+	default:
+		http.Error(w, "status must be pending or active", http.StatusBadRequest)
+		return
+	}
+
+	if req.ProrationConvention == "" {
+		req.ProrationConvention = defaultProrationConvention()
+	}
+	if !models.ValidProrationConvention(req.ProrationConvention) {
+		http.Error(w, models.ErrInvalidProrationConvention.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deposit, received, escrow, msg := req.parse()
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	lease := &models.Lease{
+		UnitID:               req.UnitID,
+		TenantID:             req.TenantID,
+		StartDate:            start,
+		EndDate:              end,
+		MonthlyRent:          req.MonthlyRent,
+		Status:               req.Status,
+		SecurityDeposit:      deposit,
+		DepositReceivedDate:  received,
+		DepositEscrowAccount: escrow,
+		ProrationConvention:  req.ProrationConvention,
+	}
+	if err := models.CreateLease(lease); err != nil {
+		writeDepositError(w, err, "Unit not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":                   lease.ID,
+		"status":               lease.Status,
+		"proration_convention": lease.ProrationConvention,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleSetLeaseDeposit(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	var req depositRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	deposit, received, escrow, msg := req.parse()
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetLeaseDeposit(leaseID, deposit, received, escrow); err != nil {
+		writeDepositError(w, err, "Lease not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRecordLeaseNotice(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		NoticeGivenDate string `json:"notice_given_date"` // YYYY-MM-DD
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	noticeDate, err := time.Parse("2006-01-02", req.NoticeGivenDate)
+	if err != nil {
+		http.Error(w, "notice_given_date must be in YYYY-MM-DD format", http.StatusBadRequest)
+		return
+	}
+
+	writeLeaseNoticeResult(w, models.RecordLeaseNotice(leaseID, sql.NullTime{Time: noticeDate, Valid: true}))
+}
+
+func handleWithdrawLeaseNotice(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	writeLeaseNoticeResult(w, models.RecordLeaseNotice(leaseID, sql.NullTime{}))
+}
+
+// writeLeaseNoticeResult maps the result of recording or withdrawing a notice to a response
+func writeLeaseNoticeResult(w http.ResponseWriter, err error) {
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Lease not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, "Failed to save lease notice", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleGetDepositRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := models.GetDepositRules()
+	if err != nil {
+		http.Error(w, "Failed to fetch deposit rules", http.StatusInternalServerError)
+		return
+	}
+
+	if rules == nil {
+		rules = []models.DepositRule{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleSaveDepositRule(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyID          *int     `json:"property_id"` // Omit for the default rule
+		Jurisdiction        string   `json:"jurisdiction"`
+		MaxMonthsRent       *float64 `json:"max_months_rent"`
+		MaxAmount           *float64 `json:"max_amount"`
+		InterestRatePercent *float64 `json:"interest_rate_percent"`
+		EscrowRequired      bool     `json:"escrow_required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rule := &models.DepositRule{Jurisdiction: models.NullString(req.Jurisdiction), EscrowRequired: req.EscrowRequired}
+	if req.PropertyID != nil {
+		if *req.PropertyID <= 0 {
+			http.Error(w, "property_id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if !requirePropertyInScope(w, r, *req.PropertyID, "Property not found") {
+			return
+		}
+		rule.PropertyID = sql.NullInt32{Int32: int32(*req.PropertyID), Valid: true}
+	}
+	limits := []struct {
+		name  string
+		value *float64
+		dest  *sql.NullFloat64
+	}{
+		{"max_months_rent", req.MaxMonthsRent, &rule.MaxMonthsRent},
+		{"max_amount", req.MaxAmount, &rule.MaxAmount},
+		{"interest_rate_percent", req.InterestRatePercent, &rule.InterestRatePercent},
+	}
+	for _, limit := range limits {
+		if limit.value == nil {
+			continue
+		}
+		if *limit.value < 0 {
+			http.Error(w, limit.name+" must not be negative", http.StatusBadRequest)
+			return
+		}
+		*limit.dest = sql.NullFloat64{Float64: *limit.value, Valid: true}
+	}
+
+	if err := models.SaveDepositRule(rule); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Property not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to save deposit rule", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteDepositRule(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "propertyID"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteDepositRule(propertyID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Deposit rule not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete deposit rule", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetDepositCompliance(w http.ResponseWriter, r *http.Request) {
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	issues, err := models.GetDepositComplianceIssues(properties, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to check deposit compliance", http.StatusInternalServerError)
+		return
+	}
+
+	if issues == nil {
+		issues = []models.DepositComplianceIssue{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(issues); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
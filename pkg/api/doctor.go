@@ -0,0 +1,31 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/doctor"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+)
+
+// RegisterDoctorRoutes registers the configuration doctor endpoint
+func RegisterDoctorRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("admin"))
+
+		auth.Get("/api/admin/doctor", handleDoctor)
+	})
+}
+
+func handleDoctor(w http.ResponseWriter, r *http.Request) {
+	report := doctor.Run(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterEscalationRoutes registers lease rent escalation and CPI index routes
+func RegisterEscalationRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		// Escalation clause per lease
+		auth.Get("/api/leases/{id}/escalation-rule", handleGetEscalationRule)
+		auth.Put("/api/leases/{id}/escalation-rule", handleSaveEscalationRule)
+		auth.Delete("/api/leases/{id}/escalation-rule", handleDeleteEscalationRule)
+
+		// Scheduled and applied escalations
+		auth.Get("/api/escalations", handleGetLeaseEscalations)
+
+		// Published CPI values used by CPI-linked clauses
+		auth.Get("/api/cpi/{series}", handleGetCPIIndexValues)
+		auth.Put("/api/cpi/{series}", handleSaveCPIIndexValues)
+	})
+}
+
+func handleGetEscalationRule(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := models.GetEscalationRule(leaseID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Escalation rule not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch escalation rule", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// escalationRuleRequest is the request body for saving a lease's escalation clause
+type escalationRuleRequest struct {
+	EscalationType     string            `json:"escalation_type"`
+	Percent            *float64          `json:"percent"`
+	CPISeries          string            `json:"cpi_series"`
+	CPIFloorPercent    *float64          `json:"cpi_floor_percent"`
+	CPICapPercent      *float64          `json:"cpi_cap_percent"`
+	IntervalMonths     int               `json:"interval_months"`
+	Steps              []models.RentStep `json:"steps"`
+	NextEscalationDate string            `json:"next_escalation_date"` // YYYY-MM-DD, optional
+	Active             *bool             `json:"active"`
+}
+
+// toRule validates the request and converts it to a rule, returning a message on failure
+func (req escalationRuleRequest) toRule(leaseID int) (*models.EscalationRule, string) {
+	rule := &models.EscalationRule{
+		LeaseID:        leaseID,
+		EscalationType: req.EscalationType,
+		CPISeries:      req.CPISeries,
+		IntervalMonths: req.IntervalMonths,
+		Steps:          req.Steps,
+		Active:         req.Active == nil || *req.Active,
+	}
+	if rule.CPISeries == "" {
+		rule.CPISeries = "CPI-U"
+	}
+	if rule.IntervalMonths == 0 {
+		rule.IntervalMonths = 12
+	}
+	if rule.IntervalMonths < 1 {
+		return nil, "interval_months must be at least 1"
+	}
+
+	switch req.EscalationType {
+	case models.EscalationFixedPercent:
+		if req.Percent == nil || *req.Percent <= 0 {
+			return nil, "percent must be a positive number for fixed_percent"
+		}
+		rule.Percent = sql.NullFloat64{Float64: *req.Percent, Valid: true}
+	case models.EscalationCPI:
+		if req.CPIFloorPercent != nil {
+			rule.CPIFloorPercent = sql.NullFloat64{Float64: *req.CPIFloorPercent, Valid: true}
+		}
+		if req.CPICapPercent != nil {
+			rule.CPICapPercent = sql.NullFloat64{Float64: *req.CPICapPercent, Valid: true}
+		}
+		if rule.CPIFloorPercent.Valid && rule.CPICapPercent.Valid && rule.CPIFloorPercent.Float64 > rule.CPICapPercent.Float64 {
+			return nil, "cpi_floor_percent must not exceed cpi_cap_percent"
+		}
+	case models.EscalationStepped:
+		if len(req.Steps) == 0 {
+			return nil, "steps are required for a stepped schedule"
+		}
+		for _, step := range req.Steps {
+			if _, err := time.Parse("2006-01-02", step.EffectiveDate); err != nil {
+				return nil, "step effective_date must be in YYYY-MM-DD format"
+			}
+			if step.MonthlyRent <= 0 {
+				return nil, "step monthly_rent must be a positive number"
+			}
+		}
+	default:
+		return nil, "escalation_type must be fixed_percent, cpi or stepped"
+	}
+
+	if req.NextEscalationDate != "" {
+		next, err := time.Parse("2006-01-02", req.NextEscalationDate)
+		if err != nil {
+			return nil, "next_escalation_date must be in YYYY-MM-DD format"
+		}
+		rule.NextEscalationDate = sql.NullTime{Time: next, Valid: true}
+	}
+	return rule, ""
+}
+
+func handleSaveEscalationRule(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	var req escalationRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rule, msg := req.toRule(leaseID)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SaveEscalationRule(rule); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Lease not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to save escalation rule", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteEscalationRule(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteEscalationRule(leaseID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Escalation rule not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete escalation rule", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetLeaseEscalations(w http.ResponseWriter, r *http.Request) {
+	leaseID := 0
+	if v := r.URL.Query().Get("lease_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+			return
+		}
+		leaseID = id
+	}
+
+	escalations, err := models.GetLeaseEscalations(r.URL.Query().Get("status"), leaseID)
+	if err != nil {
+		http.Error(w, "Failed to fetch escalations", http.StatusInternalServerError)
+		return
+	}
+
+	if escalations == nil {
+		escalations = []models.LeaseEscalation{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(escalations); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetCPIIndexValues(w http.ResponseWriter, r *http.Request) {
+	values, err := models.GetCPIIndexValues(chi.URLParam(r, "series"))
+	if err != nil {
+		http.Error(w, "Failed to fetch CPI values", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(values); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSaveCPIIndexValues records a batch of monthly index values for a series
+func handleSaveCPIIndexValues(w http.ResponseWriter, r *http.Request) {
+	series := chi.URLParam(r, "series")
+
+	var req []struct {
+		Period     string  `json:"period"` // YYYY-MM
+		IndexValue float64 `json:"index_value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req) == 0 {
+		http.Error(w, "Request body must be a non-empty array of CPI values", http.StatusBadRequest)
+		return
+	}
+
+	for _, value := range req {
+		period, err := time.Parse("2006-01", value.Period)
+		if err != nil {
+			http.Error(w, "period must be in YYYY-MM format", http.StatusBadRequest)
+			return
+		}
+		if value.IndexValue <= 0 {
+			http.Error(w, "index_value must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if err := models.SaveCPIIndexValue(series, period, value.IndexValue); err != nil {
+			http.Error(w, "Failed to save CPI value", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
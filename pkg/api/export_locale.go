@@ -0,0 +1,482 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// exportLocale localizes a report export chosen at export time, independently of the UI language
+// of the user exporting it: the fixed labels, column headers and summary labels, and how dates,
+// numbers and amounts are written.
+type exportLocale struct {
+	tag           string
+	dateFormat    string // Date format preference CSV dates are written in
+	dateLayout    string // Dates shown in PDFs
+	timeLayout    string // The generation time shown in PDFs
+	xlsxDate      string // Excel date format
+	xlsxLanguage  string // Excel locale id, so Excel names months in the export's language
+	decimal       string
+	thousands     string
+	currencyAfter bool // "1.234,50 €", with a no-break space, rather than "€1,234.50"
+	months        [12]string
+	labels        map[string]string // Translations keyed by lowercase English label; English when nil
+}
+
+var englishMonths = [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+// exportLocales are the locales reports can be exported in
+var exportLocales = map[string]*exportLocale{
+	"en-US": {
+		tag: "en-US", dateFormat: "MM/DD/YYYY", dateLayout: "Jan 2, 2006", timeLayout: "January 2, 2006 at 3:04 PM",
+		xlsxDate: "mm/dd/yyyy", xlsxLanguage: "409", decimal: ".", thousands: ",", months: englishMonths,
+	},
+	"en-GB": {
+		tag: "en-GB", dateFormat: "DD/MM/YYYY", dateLayout: "2 Jan 2006", timeLayout: "2 January 2006 at 15:04",
+		xlsxDate: "dd/mm/yyyy", xlsxLanguage: "809", decimal: ".", thousands: ",", months: englishMonths,
+	},
+	"de-DE": {
+		tag: "de-DE", dateFormat: "DD.MM.YYYY", dateLayout: "02.01.2006", timeLayout: "02.01.2006 um 15:04",
+		xlsxDate: "dd.mm.yyyy", xlsxLanguage: "407", decimal: ",", thousands: ".", currencyAfter: true,
+		months: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		labels: germanLabels,
+	},
+	"fr-FR": {
+		tag: "fr-FR", dateFormat: "DD/MM/YYYY", dateLayout: "02/01/2006", timeLayout: "02/01/2006 à 15:04",
+		xlsxDate: "dd/mm/yyyy", xlsxLanguage: "40C", decimal: ",", thousands: "\u00a0", currencyAfter: true,
+		months: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		labels: frenchLabels,
+	},
+	"es-ES": {
+		tag: "es-ES", dateFormat: "DD/MM/YYYY", dateLayout: "02/01/2006", timeLayout: "02/01/2006 a las 15:04",
+		xlsxDate: "dd/mm/yyyy", xlsxLanguage: "C0A", decimal: ",", thousands: ".", currencyAfter: true,
+		months: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		labels: spanishLabels,
+	},
+	"pt-BR": {
+		tag: "pt-BR", dateFormat: "DD/MM/YYYY", dateLayout: "02/01/2006", timeLayout: "02/01/2006 às 15:04",
+		xlsxDate: "dd/mm/yyyy", xlsxLanguage: "416", decimal: ",", thousands: ".",
+		months: [12]string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		labels: portugueseLabels,
+	},
+}
+
+// exportLanguages picks the locale used for a bare language tag such as "de"
+var exportLanguages = map[string]string{"en": "en-US", "de": "de-DE", "fr": "fr-FR", "es": "es-ES", "pt": "pt-BR"}
+
+// lookupExportLocale returns the export locale for a language tag such as "de-DE" or "de". A tag
+// whose region is not supported falls back to its language. An empty tag returns nil, leaving the
+// export in English with CSV following the user's preferences.
+func lookupExportLocale(tag string) (*exportLocale, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	if locale, ok := exportLocales[tag]; ok {
+		return locale, nil
+	}
+	language, _, _ := strings.Cut(tag, "-")
+	if fallback, ok := exportLanguages[strings.ToLower(language)]; ok {
+		return exportLocales[fallback], nil
+	}
+	return nil, fmt.Errorf("unsupported export locale %q; supported locales are %s", tag, strings.Join(exportLocaleTags(), ", "))
+}
+
+// exportLocaleTags returns the supported export locales
+func exportLocaleTags() []string {
+	tags := make([]string, 0, len(exportLocales))
+	for tag := range exportLocales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// orDefault returns the locale, or US English for exports made without one
+func (l *exportLocale) orDefault() *exportLocale {
+	if l == nil {
+		return exportLocales["en-US"]
+	}
+	return l
+}
+
+// label translates an English label, leaving labels without a translation as they are
+func (l *exportLocale) label(english string) string {
+	if l == nil {
+		return english
+	}
+	if translated, ok := l.labels[strings.ToLower(english)]; ok {
+		return translated
+	}
+	return english
+}
+
+// labelf translates a format string such as "%s Report" and formats it
+func (l *exportLocale) labelf(format string, args ...interface{}) string {
+	return fmt.Sprintf(l.label(format), args...)
+}
+
+// summaryLabel returns the label of a summary key such as "total_units": its translation, or the
+// key in title case
+func (l *exportLocale) summaryLabel(key string) string {
+	english := strings.ReplaceAll(key, "_", " ")
+	if translated := l.label(english); translated != english {
+		return translated
+	}
+	return strings.Title(english)
+}
+
+// translate returns a copy of report data with its headers translated. A header whose translation
+// would clash with another header is left in English.
+func (l *exportLocale) translate(data *models.ReportData) *models.ReportData {
+	if l == nil || l.labels == nil {
+		return data
+	}
+
+	names := make(map[string]string, len(data.Headers))
+	used := make(map[string]bool, len(data.Headers))
+	for _, header := range data.Headers {
+		used[header] = true
+	}
+	for _, header := range data.Headers {
+		translated := l.label(header)
+		if translated != header && used[translated] {
+			translated = header
+		}
+		names[header] = translated
+		used[translated] = true
+	}
+	rename := func(header string) string {
+		if name, ok := names[header]; ok {
+			return name
+		}
+		return header
+	}
+
+	translated := *data
+	translated.Headers = make([]string, len(data.Headers))
+	for i, header := range data.Headers {
+		translated.Headers[i] = rename(header)
+	}
+	translated.Rows = make([]map[string]interface{}, len(data.Rows))
+	for i, row := range data.Rows {
+		translated.Rows[i] = make(map[string]interface{}, len(row))
+		for header, value := range row {
+			translated.Rows[i][rename(header)] = value
+		}
+	}
+	translated.Columns = make([]models.ReportColumn, len(data.Columns))
+	for i, column := range data.Columns {
+		column.Name = rename(column.Name)
+		translated.Columns[i] = column
+	}
+	return &translated
+}
+
+// preferences overlays the locale's language and date format on a user's preferences for CSV
+func (l *exportLocale) preferences(prefs models.UserPreferences) models.UserPreferences {
+	if l == nil {
+		return prefs
+	}
+	prefs.Locale = l.tag
+	prefs.DateFormat = l.dateFormat
+	return prefs
+}
+
+// formatTime formats the time an export was generated
+func (l *exportLocale) formatTime(t time.Time) string {
+	return t.Format(l.orDefault().timeLayout)
+}
+
+// formatNumber formats a number with the given decimal places, or as many as it needs when
+// decimals is negative, and the locale's separators
+func (l *exportLocale) formatNumber(number float64, decimals int) string {
+	l = l.orDefault()
+	grouped := groupThousands(number, decimals)
+	return strings.NewReplacer(",", l.thousands, ".", l.decimal).Replace(grouped)
+}
+
+// formatCurrency formats an amount with its currency symbol where the locale puts it
+func (l *exportLocale) formatCurrency(number float64, decimals int, currency string) string {
+	l = l.orDefault()
+	formatted := l.formatNumber(math.Abs(number), decimals)
+	sign := ""
+	if number < 0 {
+		sign = "-"
+	}
+	symbol := strings.TrimSpace(currencySymbol(currency))
+	if l.currencyAfter {
+		return sign + formatted + "\u00a0" + symbol
+	}
+	return sign + currencySymbol(currency) + formatted
+}
+
+// formatMonth formats a month such as "Mar 2024"
+func (l *exportLocale) formatMonth(t time.Time) string {
+	return l.orDefault().months[t.Month()-1] + " " + strconv.Itoa(t.Year())
+}
+
+// formatSummaryValue renders a summary value. Without a locale it is shown as it is; with one,
+// numbers use the locale's separators.
+func (l *exportLocale) formatSummaryValue(value interface{}) string {
+	if l != nil {
+		if number, ok := exportNumber(value); ok {
+			return l.formatNumber(number, -1)
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// Translations of the labels of PDF exports, the headers of the built-in reports and their summary
+// fields. Keys are lowercase English; labels without a translation stay in English.
+var germanLabels = map[string]string{
+	"%s report":                         "Bericht %s",
+	"generated":                         "Erstellt",
+	"report type":                       "Berichtstyp",
+	"description":                       "Beschreibung",
+	"no description provided":           "Keine Beschreibung vorhanden",
+	"total records":                     "Datensätze",
+	"columns":                           "Spalten",
+	"summary statistics":                "Zusammenfassung",
+	"no data available for this report": "Für diesen Bericht sind keine Daten vorhanden",
+	"property management as a service":  "Immobilienverwaltung als Service",
+	"this report was generated automatically on %s": "Dieser Bericht wurde automatisch am %s erstellt",
+	"financial":            "Finanzen",
+	"occupancy":            "Belegung",
+	"maintenance":          "Instandhaltung",
+	"tenant":               "Mieter",
+	"property":             "Objekt",
+	"custom":               "Benutzerdefiniert",
+	"id":                   "ID",
+	"name":                 "Name",
+	"address":              "Adresse",
+	"type":                 "Typ",
+	"unit":                 "Einheit",
+	"units":                "Einheiten",
+	"owner":                "Eigentümer",
+	"email":                "E-Mail",
+	"phone":                "Telefon",
+	"first name":           "Vorname",
+	"last name":            "Nachname",
+	"status":               "Status",
+	"category":             "Kategorie",
+	"month":                "Monat",
+	"rent":                 "Miete",
+	"monthly rent":         "Monatsmiete",
+	"avg rent":             "Durchschn. Miete",
+	"market rent":          "Marktmiete",
+	"contract rent":        "Vertragsmiete",
+	"security deposit":     "Kaution",
+	"balance":              "Saldo",
+	"past due":             "Überfällig",
+	"days past due":        "Tage überfällig",
+	"start date":           "Beginn",
+	"end date":             "Ende",
+	"lease start":          "Mietbeginn",
+	"lease end":            "Mietende",
+	"occupied":             "Belegt",
+	"occupied units":       "Belegte Einheiten",
+	"vacant units":         "Freie Einheiten",
+	"occupancy rate":       "Belegungsquote",
+	"maintenance requests": "Wartungsanfragen",
+	"cost":                 "Kosten",
+	"payment count":        "Anzahl Zahlungen",
+	"total amount":         "Gesamtbetrag",
+	"average amount":       "Durchschnittsbetrag",
+	"count":                "Anzahl",
+	"total properties":     "Objekte gesamt",
+	"total units":          "Einheiten gesamt",
+	"total occupied":       "Belegt gesamt",
+	"total payments":       "Zahlungen gesamt",
+	"total requests":       "Anfragen gesamt",
+}
+
+var frenchLabels = map[string]string{
+	"%s report":                         "Rapport %s",
+	"generated":                         "Généré",
+	"report type":                       "Type de rapport",
+	"description":                       "Description",
+	"no description provided":           "Aucune description",
+	"total records":                     "Nombre d'enregistrements",
+	"columns":                           "Colonnes",
+	"summary statistics":                "Synthèse",
+	"no data available for this report": "Aucune donnée disponible pour ce rapport",
+	"property management as a service":  "Gestion immobilière en tant que service",
+	"this report was generated automatically on %s": "Ce rapport a été généré automatiquement le %s",
+	"financial":            "Financier",
+	"occupancy":            "Occupation",
+	"maintenance":          "Maintenance",
+	"tenant":               "Locataire",
+	"property":             "Bien",
+	"custom":               "Personnalisé",
+	"id":                   "ID",
+	"name":                 "Nom",
+	"address":              "Adresse",
+	"type":                 "Type",
+	"unit":                 "Lot",
+	"units":                "Lots",
+	"owner":                "Propriétaire",
+	"email":                "E-mail",
+	"phone":                "Téléphone",
+	"first name":           "Prénom",
+	"last name":            "Nom de famille",
+	"status":               "Statut",
+	"category":             "Catégorie",
+	"month":                "Mois",
+	"rent":                 "Loyer",
+	"monthly rent":         "Loyer mensuel",
+	"avg rent":             "Loyer moyen",
+	"market rent":          "Loyer de marché",
+	"contract rent":        "Loyer contractuel",
+	"security deposit":     "Dépôt de garantie",
+	"balance":              "Solde",
+	"past due":             "Impayé",
+	"days past due":        "Jours de retard",
+	"start date":           "Date de début",
+	"end date":             "Date de fin",
+	"lease start":          "Début du bail",
+	"lease end":            "Fin du bail",
+	"occupied":             "Occupés",
+	"occupied units":       "Lots occupés",
+	"vacant units":         "Lots vacants",
+	"occupancy rate":       "Taux d'occupation",
+	"maintenance requests": "Demandes d'intervention",
+	"cost":                 "Coût",
+	"payment count":        "Nombre de paiements",
+	"total amount":         "Montant total",
+	"average amount":       "Montant moyen",
+	"count":                "Nombre",
+	"total properties":     "Total des biens",
+	"total units":          "Total des lots",
+	"total occupied":       "Total occupés",
+	"total payments":       "Total des paiements",
+	"total requests":       "Total des demandes",
+}
+
+var spanishLabels = map[string]string{
+	"%s report":                         "Informe %s",
+	"generated":                         "Generado",
+	"report type":                       "Tipo de informe",
+	"description":                       "Descripción",
+	"no description provided":           "Sin descripción",
+	"total records":                     "Total de registros",
+	"columns":                           "Columnas",
+	"summary statistics":                "Resumen",
+	"no data available for this report": "No hay datos disponibles para este informe",
+	"property management as a service":  "Gestión inmobiliaria como servicio",
+	"this report was generated automatically on %s": "Este informe se generó automáticamente el %s",
+	"financial":            "Financiero",
+	"occupancy":            "Ocupación",
+	"maintenance":          "Mantenimiento",
+	"tenant":               "Inquilino",
+	"property":             "Propiedad",
+	"custom":               "Personalizado",
+	"id":                   "ID",
+	"name":                 "Nombre",
+	"address":              "Dirección",
+	"type":                 "Tipo",
+	"unit":                 "Unidad",
+	"units":                "Unidades",
+	"owner":                "Propietario",
+	"email":                "Correo electrónico",
+	"phone":                "Teléfono",
+	"first name":           "Nombre de pila",
+	"last name":            "Apellidos",
+	"status":               "Estado",
+	"category":             "Categoría",
+	"month":                "Mes",
+	"rent":                 "Alquiler",
+	"monthly rent":         "Alquiler mensual",
+	"avg rent":             "Alquiler medio",
+	"market rent":          "Alquiler de mercado",
+	"contract rent":        "Alquiler contractual",
+	"security deposit":     "Fianza",
+	"balance":              "Saldo",
+	"past due":             "Vencido",
+	"days past due":        "Días de retraso",
+	"start date":           "Fecha de inicio",
+	"end date":             "Fecha de fin",
+	"lease start":          "Inicio del contrato",
+	"lease end":            "Fin del contrato",
+	"occupied":             "Ocupadas",
+	"occupied units":       "Unidades ocupadas",
+	"vacant units":         "Unidades vacías",
+	"occupancy rate":       "Tasa de ocupación",
+	"maintenance requests": "Solicitudes de mantenimiento",
+	"cost":                 "Coste",
+	"payment count":        "Número de pagos",
+	"total amount":         "Importe total",
+	"average amount":       "Importe medio",
+	"count":                "Cantidad",
+	"total properties":     "Total de propiedades",
+	"total units":          "Total de unidades",
+	"total occupied":       "Total ocupadas",
+	"total payments":       "Total de pagos",
+	"total requests":       "Total de solicitudes",
+}
+
+var portugueseLabels = map[string]string{
+	"%s report":                         "Relatório %s",
+	"generated":                         "Gerado",
+	"report type":                       "Tipo de relatório",
+	"description":                       "Descrição",
+	"no description provided":           "Sem descrição",
+	"total records":                     "Total de registros",
+	"columns":                           "Colunas",
+	"summary statistics":                "Resumo",
+	"no data available for this report": "Não há dados disponíveis para este relatório",
+	"property management as a service":  "Gestão de imóveis como serviço",
+	"this report was generated automatically on %s": "Este relatório foi gerado automaticamente em %s",
+	"financial":            "Financeiro",
+	"occupancy":            "Ocupação",
+	"maintenance":          "Manutenção",
+	"tenant":               "Inquilino",
+	"property":             "Imóvel",
+	"custom":               "Personalizado",
+	"id":                   "ID",
+	"name":                 "Nome",
+	"address":              "Endereço",
+	"type":                 "Tipo",
+	"unit":                 "Unidade",
+	"units":                "Unidades",
+	"owner":                "Proprietário",
+	"email":                "E-mail",
+	"phone":                "Telefone",
+	"first name":           "Nome",
+	"last name":            "Sobrenome",
+	"status":               "Status",
+	"category":             "Categoria",
+	"month":                "Mês",
+	"rent":                 "Aluguel",
+	"monthly rent":         "Aluguel mensal",
+	"avg rent":             "Aluguel médio",
+	"market rent":          "Aluguel de mercado",
+	"contract rent":        "Aluguel contratual",
+	"security deposit":     "Caução",
+	"balance":              "Saldo",
+	"past due":             "Em atraso",
+	"days past due":        "Dias em atraso",
+	"start date":           "Data de início",
+	"end date":             "Data de término",
+	"lease start":          "Início do contrato",
+	"lease end":            "Fim do contrato",
+	"occupied":             "Ocupadas",
+	"occupied units":       "Unidades ocupadas",
+	"vacant units":         "Unidades vagas",
+	"occupancy rate":       "Taxa de ocupação",
+	"maintenance requests": "Solicitações de manutenção",
+	"cost":                 "Custo",
+	"payment count":        "Número de pagamentos",
+	"total amount":         "Valor total",
+	"average amount":       "Valor médio",
+	"count":                "Quantidade",
+	"total properties":     "Total de imóveis",
+	"total units":          "Total de unidades",
+	"total occupied":       "Total ocupadas",
+	"total payments":       "Total de pagamentos",
+	"total requests":       "Total de solicitações",
+}
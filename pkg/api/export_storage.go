@@ -0,0 +1,170 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/exports"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// RegisterExportStorageRoutes registers the routes listing and deleting stored report output and
+// managing each organization's storage budget and retention
+func RegisterExportStorageRoutes(r chi.Router) {
+	r.Group(func(admin chi.Router) {
+		admin.Use(middleware.LoadUserFromToken)
+		admin.Use(middleware.RequireLogin)
+		admin.Use(middleware.RequireRole("admin"))
+
+		admin.Get("/api/admin/exports", handleGetStoredExports)
+		admin.Delete("/api/admin/exports/{id}", handleDeleteStoredExport)
+		admin.Get("/api/admin/exports/storage", handleGetExportStorage)
+		admin.Put("/api/admin/exports/storage/{organizationID}", handleSetExportStoragePolicy)
+	})
+}
+
+// handleGetStoredExports lists stored report output with sizes, newest first. Query parameters:
+// organization_id to list one organization's files and limit (default 100, at most 1000).
+func handleGetStoredExports(w http.ResponseWriter, r *http.Request) {
+	organizationID := 0
+	if value := r.URL.Query().Get("organization_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			http.Error(w, "Invalid organization_id", http.StatusBadRequest)
+			return
+		}
+		organizationID = id
+	}
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		l, err := strconv.Atoi(value)
+		if err != nil || l < 1 || l > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	stored, err := models.GetStoredExports(organizationID, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch stored exports", http.StatusInternalServerError)
+		return
+	}
+
+	if stored == nil {
+		stored = []models.StoredExport{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stored); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleDeleteStoredExport deletes the stored output of a report execution. The execution and
+// its snapshot are kept.
+func handleDeleteStoredExport(w http.ResponseWriter, r *http.Request) {
+	executionID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid execution ID", http.StatusBadRequest)
+		return
+	}
+
+	export, err := models.GetStoredExport(executionID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Stored export not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch stored export", http.StatusInternalServerError)
+		return
+	}
+
+	if err := exports.Delete(storage.Reports, export); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete stored export", "execution_id", executionID, "error", err)
+		http.Error(w, "Failed to delete stored export", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetExportStorage returns every organization's stored report output against its budget
+// and retention
+func handleGetExportStorage(w http.ResponseWriter, r *http.Request) {
+	usage, err := models.GetExportStorageUsage(0, exports.RetentionDaysFromEnv())
+	if err != nil {
+		http.Error(w, "Failed to fetch export storage", http.StatusInternalServerError)
+		return
+	}
+
+	if usage == nil {
+		usage = []models.ExportStorageUsage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"default_retention_days": exports.RetentionDaysFromEnv(),
+		"organizations":          usage,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSetExportStoragePolicy sets an organization's export storage policy:
+// {"max_bytes": 1073741824, "retention_days": 30}. A max_bytes of 0 is unlimited; omitting
+// retention_days uses the server default and 0 keeps files until deleted.
+func handleSetExportStoragePolicy(w http.ResponseWriter, r *http.Request) {
+	organizationID, err := strconv.Atoi(chi.URLParam(r, "organizationID"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		MaxBytes      int64 `json:"max_bytes"`
+		RetentionDays *int  `json:"retention_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.RetentionDays != nil && *req.RetentionDays > 36500 {
+		http.Error(w, "retention_days must be at most 36500", http.StatusBadRequest)
+		return
+	}
+
+	policy := &models.ExportStoragePolicy{OrganizationID: organizationID, MaxBytes: req.MaxBytes}
+	if req.RetentionDays != nil {
+		policy.RetentionDays = sql.NullInt32{Int32: int32(*req.RetentionDays), Valid: true}
+	}
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		policy.UpdatedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+
+	if err := models.SetExportStoragePolicy(policy); err != nil {
+		switch err {
+		case models.ErrInvalidExportStoragePolicy:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case sql.ErrNoRows:
+			http.Error(w, "Organization not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to save export storage policy", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policy); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withUser runs a request as user, bypassing the token middleware
+func withUser(req *http.Request, user *models.User) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, user))
+}
+
+func TestReportHandlersWithFakeStore(t *testing.T) {
+	store := testutils.UseFakeStore(t)
+	manager := store.AddUser("manager", "property_manager")
+	other := store.AddUser("other", "property_manager")
+
+	body, _ := json.Marshal(models.CustomReport{Name: "Rent roll", ReportType: "financial"})
+	rr := httptest.NewRecorder()
+	handleCreateReport(rr, withUser(httptest.NewRequest(http.MethodPost, "/api/reports", bytes.NewReader(body)), manager))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created models.CustomReport
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+	assert.Equal(t, manager.ID, created.CreatedBy)
+
+	rr = httptest.NewRecorder()
+	handleGetReports(rr, withUser(httptest.NewRequest(http.MethodGet, "/api/reports", nil), manager))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var reports []models.CustomReport
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&reports))
+	assert.Len(t, reports, 1)
+
+	// Another manager can neither see nor delete the private report
+	rr = httptest.NewRecorder()
+	handleGetReports(rr, withUser(httptest.NewRequest(http.MethodGet, "/api/reports", nil), other))
+	assert.JSONEq(t, "null", rr.Body.String())
+
+	r := chi.NewRouter()
+	r.Delete("/api/reports/{id}", handleDeleteReport)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, withUser(httptest.NewRequest(http.MethodDelete, "/api/reports/"+strconv.Itoa(created.ID), nil), other))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, withUser(httptest.NewRequest(http.MethodDelete, "/api/reports/999", nil), manager))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestUpdateReportValidatesLikeCreate(t *testing.T) {
+	store := testutils.UseFakeStore(t)
+	manager := store.AddUser("manager", "property_manager")
+	report := models.CustomReport{Name: "Rent roll", ReportType: "financial", CreatedBy: manager.ID,
+		Criteria: map[string]interface{}{"property_type": "apartment"}}
+	require.NoError(t, models.CreateCustomReport(&report))
+
+	r := chi.NewRouter()
+	r.Put("/api/reports/{id}", handleUpdateReport)
+	update := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, withUser(httptest.NewRequest(http.MethodPut, "/api/reports/"+strconv.Itoa(report.ID),
+			bytes.NewBufferString(body)), manager))
+		return rr
+	}
+
+	// Computed columns create would reject are rejected on update too
+	rr := update(`{"criteria":{"computed_columns":"x"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	rr = update(`{"name":""}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = update(`{"name":"Monthly rent roll"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
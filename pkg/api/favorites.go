@@ -0,0 +1,145 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterFavoriteRoutes registers per-user favorite and recently used report and dashboard routes
+func RegisterFavoriteRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+
+		// Starred reports and dashboards
+		auth.Get("/api/favorites", handleGetFavorites)
+		auth.Put("/api/reports/{id}/favorite", favoriteHandler(models.ItemTypeReport, true))
+		auth.Delete("/api/reports/{id}/favorite", favoriteHandler(models.ItemTypeReport, false))
+		auth.Put("/api/dashboards/{id}/favorite", favoriteHandler(models.ItemTypeDashboard, true))
+		auth.Delete("/api/dashboards/{id}/favorite", favoriteHandler(models.ItemTypeDashboard, false))
+
+		// Recently viewed and executed items, tracked by the report and dashboard handlers
+		auth.Get("/api/recent", handleGetRecentItems)
+	})
+}
+
+// favoriteHandler stars or unstars the report or dashboard named by the id URL parameter
+func favoriteHandler(itemType string, star bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid "+itemType+" ID", http.StatusBadRequest)
+			return
+		}
+
+		user, ok := middleware.GetUserFromContext(r.Context())
+		if !ok {
+			http.Error(w, "User not found in context", http.StatusInternalServerError)
+			return
+		}
+
+		if star {
+			err = models.AddFavorite(user.ID, itemType, itemID)
+		} else {
+			err = models.RemoveFavorite(user.ID, itemType, itemID)
+		}
+		if err != nil {
+			if err == sql.ErrNoRows && star {
+				// Other users' private items are reported as missing rather than forbidden
+				http.Error(w, "Item not found", http.StatusNotFound)
+			} else if err == sql.ErrNoRows {
+				http.Error(w, "Favorite not found", http.StatusNotFound)
+			} else {
+				http.Error(w, "Failed to update favorites", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleGetFavorites(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	favorites, err := models.GetFavorites(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch favorites", http.StatusInternalServerError)
+		return
+	}
+
+	if favorites == nil {
+		favorites = []models.UserItem{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(favorites); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetRecentItems(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	itemType := r.URL.Query().Get("type")
+	if itemType != "" && !models.ValidItemType(itemType) {
+		http.Error(w, "type must be report or dashboard", http.StatusBadRequest)
+		return
+	}
+
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	items, err := models.GetRecentItems(user.ID, itemType, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch recent items", http.StatusInternalServerError)
+		return
+	}
+
+	if items == nil {
+		items = []models.UserItem{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// trackRecentItem records that the current user viewed or executed an item. Failures are
+// logged rather than returned so tracking never breaks the request itself.
+func trackRecentItem(r *http.Request, itemType string, itemID int, action string) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		return
+	}
+	if err := models.RecordRecentItem(user.ID, itemType, itemID, action, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record recent item", "item_type", itemType, "item_id", itemID,
+			"user_id", user.ID, "error", err)
+	}
+}
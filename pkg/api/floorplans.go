@@ -0,0 +1,221 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterFloorplanRoutes registers floorplan and unit mix routes
+func RegisterFloorplanRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+
+		auth.Get("/api/properties/{id}/floorplans", handleGetFloorplans)
+		auth.Post("/api/properties/{id}/floorplans", handleCreateFloorplan)
+		auth.Get("/api/floorplans/{id}", handleGetFloorplan)
+		auth.Put("/api/floorplans/{id}", handleUpdateFloorplan)
+		auth.Delete("/api/floorplans/{id}", handleDeleteFloorplan)
+
+		// Link a unit to a floorplan, or unlink it with a null floorplan_id
+		auth.Put("/api/units/{id}/floorplan", handleSetUnitFloorplan)
+	})
+}
+
+// floorplanRequest is the request body for creating or updating a floorplan
+type floorplanRequest struct {
+	Name        string   `json:"name"`
+	Bedrooms    int      `json:"bedrooms"`
+	Bathrooms   int      `json:"bathrooms"`
+	SquareFeet  *int     `json:"square_feet"`
+	MarketRent  *float64 `json:"market_rent"`
+	Description string   `json:"description"`
+}
+
+// toFloorplan converts the request to a floorplan
+func (req floorplanRequest) toFloorplan() *models.Floorplan {
+	f := &models.Floorplan{
+		Name:        req.Name,
+		Bedrooms:    req.Bedrooms,
+		Bathrooms:   req.Bathrooms,
+		Description: models.NullString(req.Description),
+	}
+	if req.SquareFeet != nil {
+		f.SquareFeet = sql.NullInt32{Int32: int32(*req.SquareFeet), Valid: true}
+	}
+	if req.MarketRent != nil {
+		f.MarketRent = sql.NullFloat64{Float64: *req.MarketRent, Valid: true}
+	}
+	return f
+}
+
+// writeFloorplanError maps floorplan errors to responses
+func writeFloorplanError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch err {
+	case models.ErrInvalidFloorplan, models.ErrFloorplanProperty:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+func handleGetFloorplans(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	floorplans, err := models.GetFloorplans(propertyID)
+	if err != nil {
+		http.Error(w, "Failed to fetch floorplans", http.StatusInternalServerError)
+		return
+	}
+
+	if floorplans == nil {
+		floorplans = []models.Floorplan{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(floorplans); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCreateFloorplan(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req floorplanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	f := req.toFloorplan()
+	f.PropertyID = propertyID
+	if err := models.CreateFloorplan(f); err != nil {
+		writeFloorplanError(w, err, "Property not found", "Failed to save floorplan")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(f); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetFloorplan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid floorplan ID", http.StatusBadRequest)
+		return
+	}
+
+	f, err := models.GetFloorplan(id)
+	if err != nil {
+		writeFloorplanError(w, err, "Floorplan not found", "Failed to fetch floorplan")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(f); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUpdateFloorplan changes a floorplan; its units take the new bedrooms and bathrooms
+func handleUpdateFloorplan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid floorplan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req floorplanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	f := req.toFloorplan()
+	f.ID = id
+	if err := models.UpdateFloorplan(f); err != nil {
+		writeFloorplanError(w, err, "Floorplan not found", "Failed to save floorplan")
+		return
+	}
+
+	updated, err := models.GetFloorplan(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch floorplan", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteFloorplan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid floorplan ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteFloorplan(id); err != nil {
+		writeFloorplanError(w, err, "Floorplan not found", "Failed to delete floorplan")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleSetUnitFloorplan(w http.ResponseWriter, r *http.Request) {
+	unitID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid unit ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		FloorplanID *int `json:"floorplan_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var floorplanID sql.NullInt32
+	if req.FloorplanID != nil {
+		floorplanID = sql.NullInt32{Int32: int32(*req.FloorplanID), Valid: true}
+	}
+	if err := models.SetUnitFloorplan(unitID, floorplanID); err != nil {
+		writeFloorplanError(w, err, "Unit or floorplan not found", "Failed to update unit")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"unit_id": unitID, "floorplan_id": req.FloorplanID}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
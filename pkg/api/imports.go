@@ -0,0 +1,211 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/imports"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// maxImportFileBytes limits the size of an uploaded import file
+const maxImportFileBytes = 10 << 20
+
+// maxImportBodyBytes limits an import request: the file and the multipart framing around it
+const maxImportBodyBytes = maxImportFileBytes + 1<<20
+
+// RegisterImportRoutes registers background CSV import job routes
+func RegisterImportRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/imports", handleGetImportJobs)
+		auth.With(middleware.LimitBody(maxImportBodyBytes), middleware.Idempotent).Post("/api/imports/{type}", handleCreateImportJob)
+		auth.Get("/api/imports/{id}", handleGetImportJob)
+		auth.Get("/api/imports/{id}/errors.csv", handleGetImportErrors)
+	})
+}
+
+// queueImport reads the uploaded csvFile and commit mode and queues an import job, writing an
+// error response on failure
+func queueImport(w http.ResponseWriter, r *http.Request, importType string) (*models.ImportJob, bool) {
+	if !imports.Supported(importType) {
+		http.Error(w, "Unsupported import type", http.StatusNotFound)
+		return nil, false
+	}
+
+	// The file is streamed from the form rather than buffered with the rest of it
+	var filename, mode string
+	var data []byte
+	err := streamMultipart(r, func(part *multipart.Part) error {
+		var err error
+		switch part.FormName() {
+		case "csvFile":
+			filename = part.FileName()
+			data, err = readUploadedFile(part, maxImportFileBytes)
+		case "mode":
+			mode, err = readFormValue(part)
+		}
+		return err
+	})
+	if err != nil {
+		writeUploadError(w, err, "Error parsing form: "+err.Error())
+		return nil, false
+	}
+	if filename == "" {
+		http.Error(w, "Error retrieving file from form: a CSV file is required in the csvFile field", http.StatusBadRequest)
+		return nil, false
+	}
+	if filepath.Ext(filename) != ".csv" {
+		http.Error(w, "Invalid file type. Only CSV files are allowed.", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if mode == "" {
+		mode = r.URL.Query().Get("mode")
+	}
+	if mode == "" {
+		mode = models.ImportAllOrNothing
+	}
+	if !models.ValidImportMode(mode) {
+		http.Error(w, "mode must be all_or_nothing or skip_bad_rows", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Reject a wrong file immediately rather than after it has been queued
+	header, err := csv.NewReader(strings.NewReader(string(data))).Read()
+	if err != nil {
+		http.Error(w, "Error reading header row", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := imports.CheckHeader(importType, header); err != nil {
+		http.Error(w, "Invalid CSV header format. The header should be: "+strings.Join(imports.Header(importType), ","), http.StatusBadRequest)
+		return nil, false
+	}
+
+	job := &models.ImportJob{
+		ImportType: importType,
+		Mode:       mode,
+		Filename:   models.NullString(filename),
+		SourceCSV:  string(data),
+	}
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		job.CreatedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+	if err := models.CreateImportJob(job); err != nil {
+		http.Error(w, "Failed to queue import", http.StatusInternalServerError)
+		return nil, false
+	}
+	return job, true
+}
+
+// handleCreateImportJob queues an import and returns the job for progress polling
+func handleCreateImportJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := queueImport(w, r, chi.URLParam(r, "type"))
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/imports/%d", job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetImportJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := models.GetImportJobs(50)
+	if err != nil {
+		http.Error(w, "Failed to fetch import jobs", http.StatusInternalServerError)
+		return
+	}
+
+	if jobs == nil {
+		jobs = []models.ImportJob{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(jobs); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetImportJob returns an import job's status and row counts
+func handleGetImportJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid import job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := models.GetImportJob(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Import job not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch import job", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetImportErrors downloads a job's bad rows with the reason each was rejected
+func handleGetImportErrors(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid import job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := models.GetImportJob(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Import job not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch import job", http.StatusInternalServerError)
+		}
+		return
+	}
+	rowErrors, err := models.GetImportRowErrors(job.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch import errors", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"import_%d_errors.csv\"", job.ID))
+
+	writer := csv.NewWriter(w)
+	writer.Write(append([]string{"Row", "Error"}, imports.Header(job.ImportType)...))
+	for _, rowErr := range rowErrors {
+		record := []string{strconv.Itoa(rowErr.RowNumber), csvSafe(rowErr.Message)}
+		// The original columns follow so the rows can be fixed and re-imported
+		if fields, err := csv.NewReader(strings.NewReader(rowErr.RawRow)).Read(); err == nil {
+			for _, field := range fields {
+				record = append(record, csvSafe(field))
+			}
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+}
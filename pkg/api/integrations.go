@@ -0,0 +1,228 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterIntegrationRoutes registers API key management and the polling triggers
+// used by no-code automation tools such as Zapier and Make
+func RegisterIntegrationRoutes(r chi.Router) {
+	// API key management for logged-in managers
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/integrations/keys", handleListAPIKeys)
+		auth.Post("/api/integrations/keys", handleCreateAPIKey)
+		auth.Delete("/api/integrations/keys/{id}", handleRevokeAPIKey)
+	})
+
+	// Polling triggers authenticated by API key
+	r.Group(func(integ chi.Router) {
+		integ.Use(middleware.RequireAPIKey)
+		integ.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		// Zapier calls this to test the connection and label the account
+		integ.Get("/api/integrations/me", handleIntegrationMe)
+		integ.Get("/api/integrations/triggers/new-maintenance", handleNewMaintenanceTrigger)
+		integ.Get("/api/integrations/triggers/new-payment", handleNewPaymentTrigger)
+		integ.Get("/api/integrations/triggers/lease-expiring", handleLeaseExpiringTrigger)
+	})
+}
+
+func handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := models.GetAPIKeysByUser(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch API keys", http.StatusInternalServerError)
+		return
+	}
+
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "Key name is required", http.StatusBadRequest)
+		return
+	}
+
+	plaintext, key, err := models.CreateAPIKey(user.ID, req.Name)
+	if err != nil {
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	// The plaintext key is only ever returned here
+	response := map[string]interface{}{
+		"api_key": plaintext,
+		"key":     key,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid key ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RevokeAPIKey(keyID, user.ID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "API key not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleIntegrationMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":       user.ID,
+		"username": user.Username,
+		"email":    user.Email,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// triggerParams parses the cursor and limit query parameters shared by all triggers.
+// cursor is the highest id already processed; limit defaults to 50 and is capped at 100.
+func triggerParams(r *http.Request) (cursor, limit int) {
+	limit = 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		if c, err := strconv.Atoi(cursorStr); err == nil && c > 0 {
+			cursor = c
+		}
+	}
+	return cursor, limit
+}
+
+func handleNewMaintenanceTrigger(w http.ResponseWriter, r *http.Request) {
+	cursor, limit := triggerParams(r)
+
+	items, err := models.GetNewMaintenanceTriggers(cursor, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch maintenance requests", http.StatusInternalServerError)
+		return
+	}
+
+	if items == nil {
+		items = []models.MaintenanceTrigger{}
+	}
+	writeTriggerResponse(w, items)
+}
+
+func handleNewPaymentTrigger(w http.ResponseWriter, r *http.Request) {
+	cursor, limit := triggerParams(r)
+
+	items, err := models.GetNewPaymentTriggers(cursor, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch payments", http.StatusInternalServerError)
+		return
+	}
+
+	if items == nil {
+		items = []models.PaymentTrigger{}
+	}
+	writeTriggerResponse(w, items)
+}
+
+func handleLeaseExpiringTrigger(w http.ResponseWriter, r *http.Request) {
+	cursor, limit := triggerParams(r)
+
+	days := 30
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		if d, err := strconv.Atoi(daysStr); err == nil && d > 0 && d <= 365 {
+			days = d
+		}
+	}
+
+	items, err := models.GetLeaseExpiringTriggers(days, cursor, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch expiring leases", http.StatusInternalServerError)
+		return
+	}
+
+	if items == nil {
+		items = []models.LeaseExpiringTrigger{}
+	}
+	writeTriggerResponse(w, items)
+}
+
+// writeTriggerResponse writes a bare JSON array, which is the shape Zapier polling triggers expect
+func writeTriggerResponse(w http.ResponseWriter, items interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/esign"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// leaseSignatureAnchor marks the tenant's signature line in the generated lease
+const leaseSignatureAnchor = "Tenant signature:"
+
+// newESignProvider returns the configured e-signature provider, or nil; replaced in tests
+var newESignProvider = esign.NewProviderFromEnv
+
+// RegisterLeaseSignatureRoutes registers lease document generation and e-signature routes
+func RegisterLeaseSignatureRoutes(r chi.Router) {
+	// Provider callbacks, authenticated by the provider's webhook signature
+	r.Post("/api/esign/webhook/{provider}", handleESignWebhook)
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/leases/{id}/document", handleGetLeaseDocument)
+		auth.Get("/api/leases/{id}/signatures", handleGetLeaseSignatures)
+		auth.Post("/api/leases/{id}/signatures", handleSendLeaseForSignature)
+	})
+}
+
+// loadLeaseDocument fetches the document terms for the lease in the id URL parameter, writing an
+// error response on failure
+func loadLeaseDocument(w http.ResponseWriter, r *http.Request) (*models.LeaseDocument, bool) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	doc, err := models.GetLeaseDocument(leaseID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Lease not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch lease", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return doc, true
+}
+
+func generateLeasePDF(doc *models.LeaseDocument) ([]byte, error) {
+	return NewPDFReportGenerator().GenerateDocumentPDF(leaseDocumentTemplate, doc, leaseDocumentLines(doc))
+}
+
+// handleGetLeaseDocument downloads the generated lease for review before it is sent
+func handleGetLeaseDocument(w http.ResponseWriter, r *http.Request) {
+	doc, ok := loadLeaseDocument(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := generateLeasePDF(doc)
+	if err != nil {
+		http.Error(w, "Failed to generate lease document", http.StatusInternalServerError)
+		return
+	}
+	writePDF(w, fmt.Sprintf("lease_%d.pdf", doc.LeaseID), data)
+}
+
+func handleGetLeaseSignatures(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	signatures, err := models.GetLeaseSignatures(leaseID)
+	if err != nil {
+		http.Error(w, "Failed to fetch lease signatures", http.StatusInternalServerError)
+		return
+	}
+
+	if signatures == nil {
+		signatures = []models.LeaseSignature{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(signatures); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSendLeaseForSignature generates a pending lease's document and sends it to the tenant
+// through the configured provider
+func handleSendLeaseForSignature(w http.ResponseWriter, r *http.Request) {
+	provider := newESignProvider()
+	if provider == nil {
+		http.Error(w, "E-signature is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	doc, ok := loadLeaseDocument(w, r)
+	if !ok {
+		return
+	}
+	if err := models.CheckLeaseSignable(doc); err != nil {
+		if err == models.ErrLeaseNotPending || err == models.ErrSignatureOutstanding {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		} else {
+			http.Error(w, "Failed to check lease signatures", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	data, err := generateLeasePDF(doc)
+	if err != nil {
+		http.Error(w, "Failed to generate lease document", http.StatusInternalServerError)
+		return
+	}
+
+	envelopeID, err := provider.Send(r.Context(), esign.Envelope{
+		Subject:      "Please sign your lease for " + doc.PropertyName,
+		Message:      "Your lease is ready for your signature.",
+		DocumentName: fmt.Sprintf("lease_%d.pdf", doc.LeaseID),
+		Document:     data,
+		Signers:      []esign.Signer{{Name: doc.TenantName, Email: doc.TenantEmail}},
+		AnchorText:   leaseSignatureAnchor,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to send lease for signature", "lease_id", doc.LeaseID, "error", err)
+		http.Error(w, "Failed to send lease for signature", http.StatusBadGateway)
+		return
+	}
+
+	signature := &models.LeaseSignature{LeaseID: doc.LeaseID, Provider: provider.Name(), EnvelopeID: envelopeID}
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		signature.SentBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+	if err := models.CreateLeaseSignature(signature); err != nil {
+		logging.FromContext(r.Context()).Error("Lease sent for signature but not recorded", "lease_id", doc.LeaseID,
+			"envelope_id", envelopeID, "error", err)
+		http.Error(w, "Failed to record lease signature", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(signature); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleESignWebhook applies provider status callbacks. Failures return 5xx so the provider retries.
+// Callbacks with a stale signed timestamp are rejected, and deliveries already applied are
+// acknowledged without being applied again.
+func handleESignWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := newESignProvider()
+	if provider == nil || provider.Name() != chi.URLParam(r, "provider") {
+		http.Error(w, "Unknown e-signature provider", http.StatusNotFound)
+		return
+	}
+
+	event, err := provider.ParseWebhook(r)
+	if err != nil {
+		switch err {
+		case esign.ErrInvalidSignature:
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		case esign.ErrStaleWebhook:
+			http.Error(w, "Stale webhook timestamp", http.StatusBadRequest)
+		default:
+			http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+		}
+		return
+	}
+	if event == nil {
+		w.Write([]byte(provider.WebhookAck()))
+		return
+	}
+
+	fresh, err := models.RecordESignDelivery(provider.Name(), event.EnvelopeID, event.DeliveryID,
+		time.Now().Add(-2*esign.WebhookTolerance))
+	if err != nil {
+		http.Error(w, "Failed to record webhook delivery", http.StatusInternalServerError)
+		return
+	}
+	if !fresh {
+		w.Write([]byte(provider.WebhookAck()))
+		return
+	}
+
+	if status, message := applyESignEvent(r, provider, event); status != 0 {
+		if err := models.ForgetESignDelivery(provider.Name(), event.EnvelopeID, event.DeliveryID); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to release webhook delivery", "envelope_id", event.EnvelopeID, "error", err)
+		}
+		http.Error(w, message, status)
+		return
+	}
+	w.Write([]byte(provider.WebhookAck()))
+}
+
+// applyESignEvent updates the lease signature an event reports on, returning the error status and
+// message of a failure, or 0 when the event was applied
+func applyESignEvent(r *http.Request, provider esign.Provider, event *esign.Event) (int, string) {
+	signature, err := models.GetLeaseSignatureByEnvelope(provider.Name(), event.EnvelopeID)
+	if err == sql.ErrNoRows {
+		// Envelopes sent outside this application are acknowledged and ignored
+		return 0, ""
+	}
+	if err != nil {
+		return http.StatusInternalServerError, "Failed to fetch lease signature"
+	}
+
+	switch event.Type {
+	case esign.EventCompleted:
+		if signature.Status == models.SignatureSigned {
+			break
+		}
+		data, err := provider.DownloadSigned(r.Context(), event.EnvelopeID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to download signed lease", "lease_id", signature.LeaseID, "error", err)
+			return http.StatusBadGateway, "Failed to download signed document"
+		}
+		// Envelope IDs are unguessable, so the executed lease is not exposed by its URL
+		url, err := storage.Default.Save(
+			fmt.Sprintf("leases/%d/signed_%s.pdf", signature.LeaseID, sanitizeFilename(event.EnvelopeID)), data)
+		if err != nil {
+			return http.StatusInternalServerError, "Failed to store signed document"
+		}
+		if err := models.CompleteLeaseSignature(signature.ID, url); err != nil {
+			return http.StatusInternalServerError, "Failed to update lease signature"
+		}
+	case esign.EventDeclined, esign.EventVoided:
+		status := models.SignatureDeclined
+		if event.Type == esign.EventVoided {
+			status = models.SignatureVoided
+		}
+		if err := models.CloseLeaseSignature(signature.ID, status); err != nil && err != sql.ErrNoRows {
+			return http.StatusInternalServerError, "Failed to update lease signature"
+		}
+	}
+	return 0, ""
+}
+
+// leaseDocumentLines is the plain text form of a generated lease
+func leaseDocumentLines(doc *models.LeaseDocument) []string {
+	premises := doc.PropertyAddress
+	if doc.UnitNumber.Valid {
+		premises = doc.UnitNumber.String + ", " + premises
+	}
+	lines := []string{
+		"Fire PMAAS - Residential Lease Agreement",
+		"",
+		fmt.Sprintf("Lease number: %d", doc.LeaseID),
+		"Tenant:       " + doc.TenantName,
+		"Property:     " + doc.PropertyName,
+		"Premises:     " + premises,
+		"",
+		"Term:         " + doc.StartDate.Format("January 2, 2006") + " to " + doc.EndDate.Format("January 2, 2006"),
+		fmt.Sprintf("Monthly rent: $%.2f, due on the first day of each month", doc.MonthlyRent),
+	}
+	if doc.SecurityDeposit.Valid {
+		lines = append(lines, fmt.Sprintf("Security deposit: $%.2f", doc.SecurityDeposit.Float64))
+	}
+	return append(lines, "",
+		"The tenant agrees to rent the premises for the term and rent above, subject to the",
+		"property's rules and applicable law.",
+		"",
+		"",
+		leaseSignatureAnchor+"  ______________________________    Date: ____________")
+}
+
+// leaseDocumentTemplate renders a lease for wkhtmltopdf
+var leaseDocumentTemplate = template.Must(template.New("lease").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Lease Agreement {{.LeaseID}}</title>
+    <style>
+        body { font-family: Arial, sans-serif; color: #333; padding: 20px; }
+        h1 { color: #1F2937; border-bottom: 2px solid #3B82F6; padding-bottom: 10px; }
+        table { border-collapse: collapse; width: 100%; }
+        td { padding: 8px 0; }
+        .label { font-weight: bold; width: 180px; }
+        .signature { margin-top: 60px; }
+    </style>
+</head>
+<body>
+    <h1>Residential Lease Agreement</h1>
+    <table>
+        <tr><td class="label">Lease number</td><td>{{.LeaseID}}</td></tr>
+        <tr><td class="label">Tenant</td><td>{{.TenantName}}</td></tr>
+        <tr><td class="label">Property</td><td>{{.PropertyName}}</td></tr>
+        <tr><td class="label">Premises</td><td>{{if .UnitNumber.Valid}}{{.UnitNumber.String}}, {{end}}{{.PropertyAddress}}</td></tr>
+        <tr><td class="label">Term</td><td>{{.StartDate.Format "January 2, 2006"}} to {{.EndDate.Format "January 2, 2006"}}</td></tr>
+        <tr><td class="label">Monthly rent</td><td>${{printf "%.2f" .MonthlyRent}}, due on the first day of each month</td></tr>
+        {{if .SecurityDeposit.Valid}}<tr><td class="label">Security deposit</td><td>${{printf "%.2f" .SecurityDeposit.Float64}}</td></tr>{{end}}
+    </table>
+    <p>The tenant agrees to rent the premises for the term and rent above, subject to the property's rules and applicable law.</p>
+    <p class="signature">Tenant signature: ______________________________ &nbsp; Date: ____________</p>
+</body>
+</html>`))
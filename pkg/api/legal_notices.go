@@ -0,0 +1,338 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// RegisterLegalNoticeRoutes registers the routes for jurisdiction-specific notice templates,
+// generating notices for a lease, and the tenant timeline
+func RegisterLegalNoticeRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+
+		auth.Get("/api/notice-templates", handleGetNoticeTemplates)
+		auth.Put("/api/notice-templates", handleSaveNoticeTemplate)
+		auth.Delete("/api/notice-templates/{id}", handleDeleteNoticeTemplate)
+		auth.Put("/api/properties/{id}/jurisdiction", handleSetPropertyJurisdiction)
+
+		auth.Post("/api/leases/{id}/notices", handleGenerateLegalNotice)
+		auth.Get("/api/notices/{id}", handleGetLegalNotice)
+		auth.Get("/api/tenants/{id}/notices", handleGetTenantLegalNotices)
+		auth.Get("/api/tenants/{id}/timeline", handleGetTenantTimeline)
+	})
+}
+
+// writeLegalNoticeError maps legal notice errors to responses
+func writeLegalNoticeError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch err {
+	case models.ErrInvalidNoticeType, models.ErrInvalidDeliveryMethod, models.ErrInvalidNoticeTemplate,
+		models.ErrNoticeDetailsRequired, models.ErrEntryNoticeTooShort:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case models.ErrNoNoticeTemplate, models.ErrNoRentPastDue:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+func handleGetNoticeTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := models.GetNoticeTemplates()
+	if err != nil {
+		http.Error(w, "Failed to fetch notice templates", http.StatusInternalServerError)
+		return
+	}
+
+	if templates == nil {
+		templates = []models.NoticeTemplate{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(templates); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSaveNoticeTemplate creates or replaces the template for a notice type in a jurisdiction
+func handleSaveNoticeTemplate(w http.ResponseWriter, r *http.Request) {
+	var t models.NoticeTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SaveNoticeTemplate(&t); err != nil {
+		writeLegalNoticeError(w, err, "Notice template not found", "Failed to save notice template")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteNoticeTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid notice template ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteNoticeTemplate(id); err != nil {
+		writeLegalNoticeError(w, err, "Notice template not found", "Failed to delete notice template")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetPropertyJurisdiction sets which jurisdiction's notice templates apply to a property
+func handleSetPropertyJurisdiction(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Jurisdiction string `json:"jurisdiction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetPropertyJurisdiction(propertyID, req.Jurisdiction); err != nil {
+		writeLegalNoticeError(w, err, "Property not found", "Failed to set jurisdiction")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"property_id":  propertyID,
+		"jurisdiction": strings.TrimSpace(req.Jurisdiction),
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGenerateLegalNotice merges the lease's notice template, stores the notice as a PDF in the
+// document store and logs it on the tenant's timeline
+func handleGenerateLegalNotice(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		NoticeType     string   `json:"notice_type"`
+		DeliveryMethod string   `json:"delivery_method"`
+		Description    string   `json:"description"`  // Violation, or purpose of entry
+		EntryDate      string   `json:"entry_date"`   // YYYY-MM-DD, entry notices only
+		EntryWindow    string   `json:"entry_window"` // e.g. "9am and 12pm"
+		AmountDue      *float64 `json:"amount_due"`   // Overrides the rent past due on late rent notices
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	noticeReq := &models.NoticeRequest{
+		LeaseID:        leaseID,
+		NoticeType:     req.NoticeType,
+		DeliveryMethod: req.DeliveryMethod,
+		Description:    req.Description,
+		EntryWindow:    req.EntryWindow,
+		CreatedBy:      sql.NullInt32{Int32: int32(user.ID), Valid: true},
+	}
+	if req.EntryDate != "" {
+		if noticeReq.EntryDate, err = time.Parse("2006-01-02", req.EntryDate); err != nil {
+			http.Error(w, "entry_date must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.AmountDue != nil {
+		noticeReq.AmountDue = sql.NullFloat64{Float64: *req.AmountDue, Valid: true}
+	}
+
+	now := time.Now()
+	notice, err := models.PrepareLegalNotice(noticeReq, now)
+	if err != nil {
+		writeLegalNoticeError(w, err, "Lease not found", "Failed to prepare notice")
+		return
+	}
+
+	data, err := NewPDFReportGenerator().GenerateDocumentPDF(legalNoticeTemplate, notice, legalNoticeLines(notice))
+	if err != nil {
+		http.Error(w, "Failed to generate notice", http.StatusInternalServerError)
+		return
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		http.Error(w, "Failed to store notice", http.StatusInternalServerError)
+		return
+	}
+	key := fmt.Sprintf("tenants/%d/notices/%s.pdf", notice.TenantID, token)
+	if notice.DocumentURL, err = storage.Default.Save(key, data); err != nil {
+		http.Error(w, "Failed to store notice", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.RecordLegalNotice(notice, now); err != nil {
+		deleteStoredFiles(map[string]string{key: notice.DocumentURL})
+		http.Error(w, "Failed to record notice", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(notice); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetLegalNotice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid notice ID", http.StatusBadRequest)
+		return
+	}
+
+	notice, err := models.GetLegalNotice(id)
+	if err != nil {
+		writeLegalNoticeError(w, err, "Notice not found", "Failed to fetch notice")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(notice); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetTenantLegalNotices(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid tenant ID", http.StatusBadRequest)
+		return
+	}
+
+	notices, err := models.GetTenantLegalNotices(tenantID)
+	if err != nil {
+		http.Error(w, "Failed to fetch notices", http.StatusInternalServerError)
+		return
+	}
+
+	if notices == nil {
+		notices = []models.LegalNotice{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(notices); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetTenantTimeline lists a tenant's most recent timeline events; limit defaults to 100
+func handleGetTenantTimeline(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid tenant ID", http.StatusBadRequest)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > 500 {
+			http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := models.GetTenantTimeline(tenantID, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch tenant timeline", http.StatusInternalServerError)
+		return
+	}
+
+	if events == nil {
+		events = []models.TenantTimelineEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// legalNoticeLines is the plain text form of a legal notice
+func legalNoticeLines(n *models.LegalNotice) []string {
+	lines := []string{
+		n.Title,
+		"",
+		"Date:     " + n.Data.NoticeDate.Format("January 2, 2006"),
+		"To:       " + n.Data.TenantName,
+		"Premises: " + n.Data.Premises,
+		"",
+	}
+	lines = append(lines, strings.Split(n.Body, "\n")...)
+	return append(lines, "", "Delivered by: "+strings.ReplaceAll(n.DeliveryMethod, "_", " "))
+}
+
+// legalNoticeTemplate renders a merged legal notice for wkhtmltopdf
+var legalNoticeTemplate = template.Must(template.New("legal_notice").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Title}}</title>
+    <style>
+        body { font-family: "Times New Roman", serif; color: #111; padding: 30px; }
+        h1 { text-align: center; font-size: 22px; text-transform: uppercase; }
+        table { border-collapse: collapse; margin-bottom: 20px; }
+        td { padding: 4px 0; }
+        .label { font-weight: bold; width: 120px; }
+        .body { white-space: pre-wrap; line-height: 1.5; }
+        .footer { margin-top: 40px; color: #6B7280; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <h1>{{.Title}}</h1>
+    <table>
+        <tr><td class="label">Date</td><td>{{.Data.NoticeDate.Format "January 2, 2006"}}</td></tr>
+        <tr><td class="label">To</td><td>{{.Data.TenantName}}</td></tr>
+        <tr><td class="label">Premises</td><td>{{.Data.Premises}}</td></tr>
+    </table>
+    <div class="body">{{.Body}}</div>
+    <div class="footer">Delivered by {{.DeliveryMethod}}</div>
+</body>
+</html>`))
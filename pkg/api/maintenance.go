@@ -0,0 +1,397 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterMaintenanceRoutes registers maintenance SLA and preventive maintenance routes
+func RegisterMaintenanceRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedMaintenanceRoutes)
+		auth.Use(scopedRecordRoutes("/api/maintenance/plans/{id}", "id",
+			models.GetMaintenancePlanPropertyID, "Maintenance plan not found"))
+
+		// SLA policies and open request status
+		auth.Get("/api/maintenance/sla-policies", handleGetSLAPolicies)
+		auth.Put("/api/maintenance/sla-policies/{priority}", handleSaveSLAPolicy)
+		auth.Get("/api/maintenance/sla", handleGetOpenMaintenanceSLA)
+
+		// Response and resolution timestamps used to measure SLAs
+		auth.Post("/api/maintenance/{id}/respond", handleRespondMaintenance)
+		auth.Post("/api/maintenance/{id}/resolve", handleResolveMaintenance)
+		auth.Put("/api/maintenance/{id}/cost", handleSetMaintenanceCost)
+
+		// Preventive maintenance plans, calendar and compliance
+		auth.Get("/api/maintenance/plans", handleGetMaintenancePlans)
+		auth.Post("/api/maintenance/plans", handleCreateMaintenancePlan)
+		auth.Put("/api/maintenance/plans/{id}", handleUpdateMaintenancePlan)
+		auth.Get("/api/maintenance/calendar", handleGetMaintenanceCalendar)
+		auth.Get("/api/maintenance/preventive/compliance", handleGetPreventiveCompliance)
+	})
+}
+
+func handleGetSLAPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := models.GetMaintenanceSLAPolicies()
+	if err != nil {
+		http.Error(w, "Failed to fetch SLA policies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policies); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleSaveSLAPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy models.MaintenanceSLAPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	policy.Priority = strings.ToLower(chi.URLParam(r, "priority"))
+	if policy.ResponseHours <= 0 || policy.ResolutionHours <= 0 {
+		http.Error(w, "response_hours and resolution_hours must be positive", http.StatusBadRequest)
+		return
+	}
+	if policy.ResponseHours > policy.ResolutionHours {
+		http.Error(w, "response_hours cannot exceed resolution_hours", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SaveMaintenanceSLAPolicy(&policy); err != nil {
+		http.Error(w, "Failed to save SLA policy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policy); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetOpenMaintenanceSLA(w http.ResponseWriter, r *http.Request) {
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	records, err := models.GetOpenMaintenanceSLARecords(properties)
+	if err != nil {
+		http.Error(w, "Failed to fetch maintenance SLA status", http.StatusInternalServerError)
+		return
+	}
+
+	// Optional filter, e.g. ?status=breached
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := []models.MaintenanceSLARecord{}
+		for _, record := range records {
+			if record.SLA != nil && record.SLA.Status == status {
+				filtered = append(filtered, record)
+			}
+		}
+		records = filtered
+	}
+
+	if records == nil {
+		records = []models.MaintenanceSLARecord{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(records); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleRespondMaintenance(w http.ResponseWriter, r *http.Request) {
+	transitionMaintenanceSLA(w, r, models.MaintenanceInProgress)
+}
+
+func handleResolveMaintenance(w http.ResponseWriter, r *http.Request) {
+	transitionMaintenanceSLA(w, r, models.MaintenanceCompleted)
+}
+
+// transitionMaintenanceSLA moves the request named in the URL to status, which records its
+// response or resolution time. It goes through the same checks as the status endpoint, so a
+// request cannot skip a step or start before its cost estimate is approved.
+func transitionMaintenanceSLA(w http.ResponseWriter, r *http.Request, status string) {
+	requestID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.TransitionMaintenanceRequest(requestID, status, sql.NullInt32{}, time.Now()); err != nil {
+		writeMaintenanceRequestError(w, err, "Maintenance request not found", "Failed to update maintenance request")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetMaintenanceCost records the actual cost of a maintenance request
+func handleSetMaintenanceCost(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ActualCost *float64 `json:"actual_cost"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ActualCost == nil || *req.ActualCost < 0 {
+		http.Error(w, "actual_cost must be zero or greater", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetMaintenanceActualCost(requestID, *req.ActualCost); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Maintenance request not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update maintenance request", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maintenancePlanRequest is the request body for creating and updating preventive maintenance plans
+type maintenancePlanRequest struct {
+	PropertyID   int    `json:"property_id"`
+	UnitID       *int   `json:"unit_id"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	IntervalDays int    `json:"interval_days"`
+	Priority     string `json:"priority"`
+	NextDueDate  string `json:"next_due_date"` // YYYY-MM-DD
+	Active       *bool  `json:"active"`
+}
+
+// toPlan validates the request and converts it to a plan
+func (req maintenancePlanRequest) toPlan() (*models.MaintenancePlan, string) {
+	if strings.TrimSpace(req.Title) == "" {
+		return nil, "title is required"
+	}
+	if req.IntervalDays <= 0 {
+		return nil, "interval_days must be positive"
+	}
+
+	priority := strings.ToLower(req.Priority)
+	switch priority {
+	case "":
+		priority = "low"
+	case "low", "medium", "high":
+	default:
+		return nil, "priority must be low, medium or high"
+	}
+
+	nextDue, err := time.Parse("2006-01-02", req.NextDueDate)
+	if err != nil {
+		return nil, "Invalid next_due_date, expected YYYY-MM-DD"
+	}
+
+	plan := &models.MaintenancePlan{
+		PropertyID:   req.PropertyID,
+		Title:        strings.TrimSpace(req.Title),
+		Description:  models.NullString(req.Description),
+		IntervalDays: req.IntervalDays,
+		Priority:     priority,
+		NextDueDate:  nextDue,
+		Active:       req.Active == nil || *req.Active,
+	}
+	if req.UnitID != nil {
+		plan.UnitID = sql.NullInt32{Int32: int32(*req.UnitID), Valid: true}
+	}
+	return plan, ""
+}
+
+func handleGetMaintenancePlans(w http.ResponseWriter, r *http.Request) {
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	plans, err := models.GetMaintenancePlans(properties)
+	if err != nil {
+		http.Error(w, "Failed to fetch maintenance plans", http.StatusInternalServerError)
+		return
+	}
+	if plans == nil {
+		plans = []models.MaintenancePlan{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plans); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCreateMaintenancePlan(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req maintenancePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.PropertyID <= 0 {
+		http.Error(w, "property_id is required", http.StatusBadRequest)
+		return
+	}
+	if !requirePropertyInScope(w, r, req.PropertyID, "Property not found") {
+		return
+	}
+
+	plan, msg := req.toPlan()
+	if plan == nil {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	plan.CreatedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+
+	if err := models.CreateMaintenancePlan(plan); err != nil {
+		http.Error(w, "Failed to create maintenance plan", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUpdateMaintenancePlan replaces a plan's schedule. Set "active": false to pause it.
+func handleUpdateMaintenancePlan(w http.ResponseWriter, r *http.Request) {
+	planID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid plan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req maintenancePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	plan, msg := req.toPlan()
+	if plan == nil {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	plan.ID = planID
+
+	if err := models.UpdateMaintenancePlan(plan); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Maintenance plan not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update maintenance plan", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseCalendarRange reads start and end (YYYY-MM-DD) query parameters, defaulting to
+// the given number of days either side of today
+func parseCalendarRange(r *http.Request, daysBefore, daysAfter int) (time.Time, time.Time, bool) {
+	today := time.Now().Truncate(24 * time.Hour)
+	start := today.AddDate(0, 0, -daysBefore)
+	end := today.AddDate(0, 0, daysAfter)
+
+	if s := r.URL.Query().Get("start"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return start, end, false
+		}
+		start = parsed
+	}
+	if s := r.URL.Query().Get("end"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return start, end, false
+		}
+		end = parsed
+	}
+	return start, end, !end.Before(start)
+}
+
+// handleGetMaintenanceCalendar lists preventive tasks between start and end (default: next 90 days)
+func handleGetMaintenanceCalendar(w http.ResponseWriter, r *http.Request) {
+	start, end, ok := parseCalendarRange(r, 0, 90)
+	if !ok {
+		http.Error(w, "Invalid start or end date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := models.GetMaintenanceCalendar(start, end, properties)
+	if err != nil {
+		http.Error(w, "Failed to fetch maintenance calendar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetPreventiveCompliance reports on-time completion of preventive requests (default: last 90 days)
+func handleGetPreventiveCompliance(w http.ResponseWriter, r *http.Request) {
+	start, end, ok := parseCalendarRange(r, 90, 0)
+	if !ok {
+		http.Error(w, "Invalid start or end date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	compliance, err := models.GetPreventiveCompliance(start, end, properties)
+	if err != nil {
+		http.Error(w, "Failed to calculate preventive maintenance compliance", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(compliance); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
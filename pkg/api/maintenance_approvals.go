@@ -0,0 +1,275 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterMaintenanceApprovalRoutes registers work order cost estimate and approval routes
+func RegisterMaintenanceApprovalRoutes(r chi.Router) {
+	// Approvers follow emailed links and need no account; the token is the credential
+	r.Get("/work-orders/approve", handleCostApprovalPage)
+	r.Get("/api/maintenance/approvals/{token}", handleGetCostApproval)
+	r.Post("/api/maintenance/approvals/{token}", handleDecideCostApproval)
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedMaintenanceRoutes)
+		auth.Use(scopedRecordRoutes("/api/maintenance/approval-policies/{propertyID}", "propertyID",
+			propertyItself, "Property not found"))
+
+		auth.Get("/api/maintenance/approval-policies", handleGetApprovalPolicies)
+		auth.Put("/api/maintenance/approval-policies/{propertyID}", handleSaveApprovalPolicy)
+		auth.Delete("/api/maintenance/approval-policies/{propertyID}", handleDeleteApprovalPolicy)
+
+		auth.Get("/api/maintenance/pending-approvals", handleGetPendingCostApprovals)
+		auth.Post("/api/maintenance/{id}/estimate", handleSubmitMaintenanceEstimate)
+		auth.Get("/api/maintenance/{id}/approvals", handleGetMaintenanceCostApprovals)
+	})
+}
+
+// costApprovalLink builds the approver's review link for an approval token
+func costApprovalLink(token string) string {
+	return appBaseURL() + "/work-orders/approve?token=" + url.QueryEscape(token)
+}
+
+func handleGetApprovalPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := models.GetMaintenanceApprovalPolicies()
+	if err != nil {
+		http.Error(w, "Failed to fetch approval policies", http.StatusInternalServerError)
+		return
+	}
+
+	if policies == nil {
+		policies = []models.MaintenanceApprovalPolicy{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policies); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSaveApprovalPolicy sets a property's auto-approval limit and approver
+func handleSaveApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "propertyID"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		AutoApproveLimit float64 `json:"auto_approve_limit"`
+		ApproverName     string  `json:"approver_name"`
+		ApproverEmail    string  `json:"approver_email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	policy := &models.MaintenanceApprovalPolicy{
+		PropertyID:       propertyID,
+		AutoApproveLimit: req.AutoApproveLimit,
+		ApproverName:     models.NullString(req.ApproverName),
+		ApproverEmail:    req.ApproverEmail,
+	}
+	if err := models.SaveMaintenanceApprovalPolicy(policy); err != nil {
+		switch err {
+		case models.ErrInvalidApprovalPolicy:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case sql.ErrNoRows:
+			http.Error(w, "Property not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to save approval policy", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policy); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "propertyID"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteMaintenanceApprovalPolicy(propertyID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Approval policy not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete approval policy", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetPendingCostApprovals(w http.ResponseWriter, r *http.Request) {
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	approvals, err := models.GetPendingCostApprovals(properties)
+	if err != nil {
+		http.Error(w, "Failed to fetch pending approvals", http.StatusInternalServerError)
+		return
+	}
+
+	if approvals == nil {
+		approvals = []models.MaintenanceCostApproval{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(approvals); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSubmitMaintenanceEstimate records a work order's cost estimate. Estimates above the
+// property's auto-approval limit are emailed to its approver.
+func handleSubmitMaintenanceEstimate(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		EstimatedCost *float64 `json:"estimated_cost"`
+		Notes         string   `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.EstimatedCost == nil {
+		http.Error(w, "estimated_cost is required", http.StatusBadRequest)
+		return
+	}
+
+	approval, err := models.SubmitMaintenanceEstimate(requestID, *req.EstimatedCost, req.Notes, currentUserID(r),
+		costApprovalLink, time.Now())
+	if err != nil {
+		switch err {
+		case models.ErrInvalidEstimate:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case sql.ErrNoRows:
+			http.Error(w, "Maintenance request not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to submit estimate", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(approval); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetMaintenanceCostApprovals returns a work order's estimates and approval decisions
+func handleGetMaintenanceCostApprovals(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return
+	}
+
+	approvals, err := models.GetMaintenanceCostApprovals(requestID)
+	if err != nil {
+		http.Error(w, "Failed to fetch approvals", http.StatusInternalServerError)
+		return
+	}
+
+	if approvals == nil {
+		approvals = []models.MaintenanceCostApproval{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(approvals); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCostApprovalPage(w http.ResponseWriter, r *http.Request) {
+	t, err := template.ParseFiles("templates/approve-work-order.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := t.Execute(w, struct{ Token string }{r.URL.Query().Get("token")}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetCostApproval(w http.ResponseWriter, r *http.Request) {
+	review, err := models.GetCostApprovalByToken(chi.URLParam(r, "token"), time.Now())
+	if err != nil {
+		if err == models.ErrApprovalInvalid {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch approval", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleDecideCostApproval approves or declines the estimate an approval link is for
+func handleDecideCostApproval(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Decision string `json:"decision"` // approve or decline
+		Notes    string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Decision != "approve" && req.Decision != "decline" {
+		http.Error(w, "decision must be approve or decline", http.StatusBadRequest)
+		return
+	}
+
+	approval, err := models.DecideCostApproval(chi.URLParam(r, "token"), req.Decision == "approve", req.Notes, time.Now())
+	if err != nil {
+		if err == models.ErrApprovalInvalid {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to record decision", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(approval); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,434 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// Limits on files attached to a maintenance message
+const (
+	maxMessageAttachments     = 5
+	maxMessageAttachmentBytes = 10 << 20
+	// The attachments, the message and the multipart framing around them
+	maxMessageBodyBytes = maxMessageAttachments*maxMessageAttachmentBytes + 1<<20
+)
+
+// messageAttachmentTypes lists the attachment content types accepted and the extension each is
+// stored with. Anything that a browser might render as a page is refused.
+var messageAttachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+// RegisterMaintenanceMessageRoutes registers maintenance request detail, message thread and
+// vendor participant routes
+func RegisterMaintenanceMessageRoutes(r chi.Router) {
+	// The reporting tenant and vendors on a request share these with staff; access is checked
+	// per request in the handlers
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(scopedMaintenanceRoutes)
+
+		auth.Get("/api/maintenance/{id}", handleGetMaintenanceDetail)
+		auth.Get("/api/maintenance/{id}/messages", handleGetMaintenanceMessages)
+		auth.With(middleware.LimitBody(maxMessageBodyBytes)).Post("/api/maintenance/{id}/messages", handlePostMaintenanceMessage)
+		auth.Get("/api/maintenance/attachments/{id}", handleGetMaintenanceAttachment)
+	})
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedMaintenanceRoutes)
+
+		auth.Post("/api/maintenance/{id}/participants", handleAddMaintenanceParticipant)
+		auth.Delete("/api/maintenance/{id}/participants/{userID}", handleRemoveMaintenanceParticipant)
+	})
+}
+
+// maintenanceThreadRole returns the role the user takes in a request's thread, or "" if they
+// may not see it. Staff see every thread, tenants the requests they reported and vendors the
+// requests they were added to.
+func maintenanceThreadRole(user *models.User, requestID int) (string, error) {
+	access, err := models.GetMaintenanceThreadAccess(requestID)
+	if err != nil {
+		return "", err
+	}
+	if user.HasAnyRole("admin", "property_manager") {
+		return models.MessageAuthorManager, nil
+	}
+	if access.ParticipantIDs[user.ID] {
+		return models.MessageAuthorVendor, nil
+	}
+	if access.ReportedByTenantID.Valid && user.HasRole("tenant") {
+		tenant, err := models.GetTenantForUser(user)
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if tenant.ID == int(access.ReportedByTenantID.Int32) {
+			return models.MessageAuthorTenant, nil
+		}
+	}
+	return "", nil
+}
+
+// authorizeMaintenanceThread resolves the caller's role in the thread of the request in the
+// URL, writing the error response when they have none. Requests the caller may not see are
+// reported as not found so their existence is not revealed.
+func authorizeMaintenanceThread(w http.ResponseWriter, r *http.Request) (*models.User, int, string, bool) {
+	requestID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return nil, 0, "", false
+	}
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, 0, "", false
+	}
+
+	role, err := maintenanceThreadRole(user, requestID)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Failed to fetch maintenance request", http.StatusInternalServerError)
+		return nil, 0, "", false
+	}
+	if role == "" {
+		http.Error(w, "Maintenance request not found", http.StatusNotFound)
+		return nil, 0, "", false
+	}
+	return user, requestID, role, true
+}
+
+// handleGetMaintenanceDetail returns a request with its participants and thread, marking the
+// thread read by the caller
+func handleGetMaintenanceDetail(w http.ResponseWriter, r *http.Request) {
+	user, requestID, _, ok := authorizeMaintenanceThread(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.MarkMaintenanceThreadRead(requestID, user.ID); err != nil {
+		http.Error(w, "Failed to update read receipts", http.StatusInternalServerError)
+		return
+	}
+	meResponses.invalidate(user.ID)
+	detail, err := models.GetMaintenanceRequestDetail(requestID)
+	if err != nil {
+		http.Error(w, "Failed to fetch maintenance request", http.StatusInternalServerError)
+		return
+	}
+
+	if detail.Participants == nil {
+		detail.Participants = []models.MaintenanceParticipant{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(detail); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetMaintenanceMessages returns a request's thread, marking it read by the caller
+func handleGetMaintenanceMessages(w http.ResponseWriter, r *http.Request) {
+	user, requestID, _, ok := authorizeMaintenanceThread(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.MarkMaintenanceThreadRead(requestID, user.ID); err != nil {
+		http.Error(w, "Failed to update read receipts", http.StatusInternalServerError)
+		return
+	}
+	meResponses.invalidate(user.ID)
+	messages, err := models.GetMaintenanceThread(requestID)
+	if err != nil {
+		http.Error(w, "Failed to fetch messages", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(messages); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handlePostMaintenanceMessage adds a message to a request's thread and notifies the others in
+// it. The body is JSON {"body": ...}, or multipart with a "body" field and "attachments" files.
+func handlePostMaintenanceMessage(w http.ResponseWriter, r *http.Request) {
+	user, requestID, role, ok := authorizeMaintenanceThread(w, r)
+	if !ok {
+		return
+	}
+
+	msg := models.MaintenanceMessage{
+		RequestID:  requestID,
+		AuthorID:   sql.NullInt32{Int32: int32(user.ID), Valid: true},
+		AuthorName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+		AuthorRole: role,
+	}
+	if msg.AuthorName == "" {
+		msg.AuthorName = user.Username
+	}
+
+	var uploads []messageUpload
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			http.Error(w, fmt.Sprintf("Attach up to %d files of at most 10MB each", maxMessageAttachments), http.StatusBadRequest)
+			return
+		}
+		msg.Body = r.FormValue("body")
+
+		var err error
+		if uploads, err = readMessageUploads(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		var req struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		msg.Body = req.Body
+	}
+
+	msg.Body = strings.TrimSpace(msg.Body)
+	if msg.Body == "" && len(uploads) == 0 {
+		http.Error(w, "A message needs a body or an attachment", http.StatusUnprocessableEntity)
+		return
+	}
+
+	stored := map[string]string{}
+	for _, upload := range uploads {
+		token, err := randomHex(16)
+		if err != nil {
+			deleteStoredFiles(stored)
+			http.Error(w, "Failed to store attachment", http.StatusInternalServerError)
+			return
+		}
+		key := fmt.Sprintf("maintenance/%d/%s%s", requestID, token, messageAttachmentTypes[upload.contentType])
+		url, err := storage.Default.Save(key, upload.data)
+		if err != nil {
+			deleteStoredFiles(stored)
+			http.Error(w, "Failed to store attachment", http.StatusInternalServerError)
+			return
+		}
+		stored[key] = url
+		msg.Attachments = append(msg.Attachments, models.MaintenanceAttachment{
+			Filename:    upload.filename,
+			ContentType: upload.contentType,
+			SizeBytes:   len(upload.data),
+			URL:         url,
+		})
+	}
+
+	link := fmt.Sprintf("%s/maintenance?request=%d", appBaseURL(), requestID)
+	if err := models.CreateMaintenanceMessage(&msg, link); err != nil {
+		deleteStoredFiles(stored)
+		http.Error(w, "Failed to post message", http.StatusInternalServerError)
+		return
+	}
+	// Posting marks the thread read by its author
+	meResponses.invalidate(user.ID)
+
+	if msg.Attachments == nil {
+		msg.Attachments = []models.MaintenanceAttachment{}
+	}
+	msg.ReadBy = []models.MessageReadReceipt{}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// messageUpload is a validated attachment waiting to be stored
+type messageUpload struct {
+	filename    string
+	contentType string
+	data        []byte
+}
+
+// readMessageUploads reads and validates the "attachments" files of a multipart message
+func readMessageUploads(r *http.Request) ([]messageUpload, error) {
+	files := r.MultipartForm.File["attachments"]
+	if len(files) > maxMessageAttachments {
+		return nil, fmt.Errorf("Attach at most %d files", maxMessageAttachments)
+	}
+
+	uploads := make([]messageUpload, 0, len(files))
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read %s", header.Filename)
+		}
+		data, err := io.ReadAll(io.LimitReader(file, maxMessageAttachmentBytes+1))
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read %s", header.Filename)
+		}
+		if len(data) == 0 || len(data) > maxMessageAttachmentBytes {
+			return nil, fmt.Errorf("%s must be between 1 byte and 10MB", header.Filename)
+		}
+
+		// Trust the content, not the client's declared type
+		contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+		if _, ok := messageAttachmentTypes[contentType]; !ok {
+			return nil, fmt.Errorf("%s must be an image, PDF or plain text file", header.Filename)
+		}
+		uploads = append(uploads, messageUpload{
+			filename:    sanitizeFilename(filepath.Base(header.Filename)),
+			contentType: contentType,
+			data:        data,
+		})
+	}
+	return uploads, nil
+}
+
+// randomHex returns n random bytes as hex, for unguessable storage keys
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handleGetMaintenanceAttachment redirects to a message attachment once the caller is shown to
+// have access to its thread
+func handleGetMaintenanceAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	attachment, requestID, err := models.GetMaintenanceAttachment(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch attachment", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	role, err := maintenanceThreadRole(user, requestID)
+	if err != nil {
+		http.Error(w, "Failed to fetch attachment", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.Redirect(w, r, attachment.URL, http.StatusFound)
+}
+
+// handleAddMaintenanceParticipant adds a vendor to a request's thread
+func handleAddMaintenanceParticipant(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		UserID int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var addedBy sql.NullInt32
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		addedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+
+	if err := models.AddMaintenanceParticipant(requestID, req.UserID, addedBy); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Maintenance request or user not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to add participant", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	participants, err := models.GetMaintenanceParticipants(requestID)
+	if err != nil {
+		http.Error(w, "Failed to fetch participants", http.StatusInternalServerError)
+		return
+	}
+
+	if participants == nil {
+		participants = []models.MaintenanceParticipant{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(participants); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleRemoveMaintenanceParticipant removes a vendor from a request's thread
+func handleRemoveMaintenanceParticipant(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RemoveMaintenanceParticipant(requestID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Participant not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to remove participant", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterMaintenanceRequestRoutes registers maintenance request creation, listing, editing and
+// status routes
+func RegisterMaintenanceRequestRoutes(r chi.Router) {
+	// Tenants list and report requests at the properties they lease; staff see and report them all
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+
+		auth.Get("/api/maintenance", handleGetMaintenanceRequests)
+		auth.Post("/api/maintenance", handleCreateMaintenanceRequest)
+	})
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedMaintenanceRoutes)
+
+		auth.Put("/api/maintenance/{id}", handleUpdateMaintenanceRequest)
+		auth.Delete("/api/maintenance/{id}", handleDeleteMaintenanceRequest)
+		auth.Post("/api/maintenance/{id}/status", handleTransitionMaintenanceRequest)
+	})
+}
+
+// maintenanceTenant returns the tenant record of a caller who is not staff. ok is false, with the
+// error response written, for callers who are neither staff nor a tenant.
+func maintenanceTenant(w http.ResponseWriter, r *http.Request) (user *models.User, tenant *models.Tenant, ok bool) {
+	user, found := middleware.GetUserFromContext(r.Context())
+	if !found {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	if user.HasAnyRole("admin", "property_manager") {
+		return user, nil, true
+	}
+	if !user.HasRole("tenant") {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, nil, false
+	}
+	tenant, err := models.GetTenantForUser(user)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No tenant record found for this account", http.StatusForbidden)
+		return nil, nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch tenant", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return user, tenant, true
+}
+
+// writeMaintenanceRequestError maps maintenance request errors to responses
+func writeMaintenanceRequestError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch err {
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	case models.ErrInvalidMaintenanceRequest, models.ErrMaintenanceAssigneeRequired:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case models.ErrInvalidMaintenanceTransition, models.ErrMaintenanceAwaitingApproval:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+// writeMaintenanceRequest responds with a maintenance request
+func writeMaintenanceRequest(w http.ResponseWriter, id, status int) {
+	request, err := models.GetMaintenanceRequest(id)
+	if err != nil {
+		writeMaintenanceRequestError(w, err, "Maintenance request not found", "Failed to fetch maintenance request")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(request); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetMaintenanceRequests lists maintenance requests, newest first. Staff may filter by
+// ?status=, ?property_id=, ?group_id= and ?assigned_to= and see only the properties they manage;
+// tenants see only the requests they reported.
+func handleGetMaintenanceRequests(w http.ResponseWriter, r *http.Request) {
+	_, tenant, ok := maintenanceTenant(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.MaintenanceRequestFilter{Status: query.Get("status")}
+	for name, dest := range map[string]*int{"property_id": &filter.PropertyID, "assigned_to": &filter.AssignedTo} {
+		if value := query.Get(name); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dest = id
+		}
+	}
+	if tenant != nil {
+		filter.TenantID = tenant.ID
+	} else if filter.Properties, ok = requestPropertyScope(w, r); !ok {
+		return
+	}
+
+	requests, err := models.GetMaintenanceRequests(filter)
+	if err != nil {
+		http.Error(w, "Failed to fetch maintenance requests", http.StatusInternalServerError)
+		return
+	}
+
+	if requests == nil {
+		requests = []models.MaintenanceRequest{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(requests); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleCreateMaintenanceRequest reports a maintenance request. A tenant's request is recorded as
+// reported by them and must be at a property they lease; property_id may be left out when they
+// lease at only one.
+func handleCreateMaintenanceRequest(w http.ResponseWriter, r *http.Request) {
+	_, tenant, ok := maintenanceTenant(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PropertyID         int    `json:"property_id"`
+		Description        string `json:"description"`
+		Priority           string `json:"priority"`
+		DueDate            string `json:"due_date"`              // YYYY-MM-DD; staff only
+		ReportedByTenantID *int   `json:"reported_by_tenant_id"` // Staff only
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	request := &models.MaintenanceRequest{
+		PropertyID:  req.PropertyID,
+		Description: req.Description,
+		Priority:    req.Priority,
+	}
+	if tenant != nil {
+		propertyIDs, err := models.GetTenantPropertyIDs(tenant.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch leased properties", http.StatusInternalServerError)
+			return
+		}
+		if request.PropertyID == 0 && len(propertyIDs) == 1 {
+			request.PropertyID = propertyIDs[0]
+		}
+		leased := false
+		for _, id := range propertyIDs {
+			leased = leased || id == request.PropertyID
+		}
+		if !leased {
+			http.Error(w, "Maintenance can only be reported at a property you lease", http.StatusForbidden)
+			return
+		}
+		request.ReportedByTenantID = sql.NullInt32{Int32: int32(tenant.ID), Valid: true}
+	} else {
+		if req.ReportedByTenantID != nil {
+			request.ReportedByTenantID = sql.NullInt32{Int32: int32(*req.ReportedByTenantID), Valid: true}
+		}
+		if !parseMaintenanceDueDate(w, req.DueDate, request) {
+			return
+		}
+		if !requirePropertyInScope(w, r, request.PropertyID, "Property not found") {
+			return
+		}
+	}
+
+	if err := models.CreateMaintenanceRequest(request); err != nil {
+		writeMaintenanceRequestError(w, err, "Property not found", "Failed to create maintenance request")
+		return
+	}
+	writeMaintenanceRequest(w, request.ID, http.StatusCreated)
+}
+
+// parseMaintenanceDueDate sets the request's due date from a YYYY-MM-DD string, if given,
+// writing the error response when it is malformed
+func parseMaintenanceDueDate(w http.ResponseWriter, value string, request *models.MaintenanceRequest) bool {
+	if value == "" {
+		return true
+	}
+	due, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		http.Error(w, "due_date must be YYYY-MM-DD", http.StatusBadRequest)
+		return false
+	}
+	request.DueDate = sql.NullTime{Time: due, Valid: true}
+	return true
+}
+
+// handleUpdateMaintenanceRequest changes a request's description, priority and due date
+func handleUpdateMaintenanceRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Description string `json:"description"`
+		Priority    string `json:"priority"`
+		DueDate     string `json:"due_date"` // YYYY-MM-DD; empty clears it
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	request := &models.MaintenanceRequest{ID: id, Description: req.Description, Priority: req.Priority}
+	if !parseMaintenanceDueDate(w, req.DueDate, request) {
+		return
+	}
+	if err := models.UpdateMaintenanceRequest(request); err != nil {
+		writeMaintenanceRequestError(w, err, "Maintenance request not found", "Failed to update maintenance request")
+		return
+	}
+	writeMaintenanceRequest(w, id, http.StatusOK)
+}
+
+func handleDeleteMaintenanceRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteMaintenanceRequest(id); err != nil {
+		writeMaintenanceRequestError(w, err, "Maintenance request not found", "Failed to delete maintenance request")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTransitionMaintenanceRequest moves a request to its next status:
+// {"status": "assigned", "assigned_to": 12}, then "in_progress", then "completed"
+func handleTransitionMaintenanceRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid maintenance request ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Status     string `json:"status"`
+		AssignedTo *int   `json:"assigned_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var assignee sql.NullInt32
+	if req.AssignedTo != nil {
+		assignee = sql.NullInt32{Int32: int32(*req.AssignedTo), Valid: true}
+	}
+
+	if err := models.TransitionMaintenanceRequest(id, req.Status, assignee, time.Now()); err != nil {
+		writeMaintenanceRequestError(w, err, "Maintenance request not found", "Failed to update maintenance request status")
+		return
+	}
+	writeMaintenanceRequest(w, id, http.StatusOK)
+}
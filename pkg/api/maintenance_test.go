@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveMaintenanceWaitsForApproval(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	originalDB := db.DB
+	db.DB = mockDB
+	t.Cleanup(func() {
+		db.DB = originalDB
+		mockDB.Close()
+	})
+
+	router := chi.NewRouter()
+	router.Post("/api/maintenance/{id}/respond", handleRespondMaintenance)
+	router.Post("/api/maintenance/{id}/resolve", handleResolveMaintenance)
+
+	// Resolving goes through the status checks instead of writing the status directly
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(21).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).
+			AddRow(models.MaintenanceInProgress, models.ApprovalPending))
+	mock.ExpectRollback()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/maintenance/21/resolve", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// Responding cannot skip assignment
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(22).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).
+			AddRow(models.MaintenanceReported, nil))
+	mock.ExpectRollback()
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/maintenance/22/respond", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// Approved work is resolved
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(23).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).
+			AddRow(models.MaintenanceInProgress, models.ApprovalApproved))
+	mock.ExpectExec(`resolved_at = COALESCE\(resolved_at, \$4\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/maintenance/23/resolve", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// maxBenchmarkRows limits the number of rows in one benchmark import file
+const maxBenchmarkRows = 50000
+
+// maxBenchmarkFileBytes limits the size of a benchmark import request
+const maxBenchmarkFileBytes = 10 << 20
+
+// RegisterMarketBenchmarkRoutes registers market benchmark dataset import and listing routes
+func RegisterMarketBenchmarkRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.With(middleware.LimitBody(maxBenchmarkFileBytes), middleware.Idempotent).Post("/api/market-benchmarks/import", handleImportMarketBenchmarks)
+		auth.Get("/api/market-benchmarks/rents", handleGetMarketRentBenchmarks)
+		auth.Get("/api/market-benchmarks/expense-ratios", handleGetExpenseRatioBenchmarks)
+	})
+}
+
+// benchmarkImportResult reports the outcome of a benchmark import
+type benchmarkImportResult struct {
+	Dataset  string                     `json:"dataset"`
+	Mode     string                     `json:"mode"`
+	Imported int                        `json:"imported"`
+	Errors   []models.BenchmarkRowError `json:"errors"`
+}
+
+// handleImportMarketBenchmarks loads a benchmark dataset from a CSV file (multipart field "file"
+// or a text/csv body). dataset is market_rent or expense_ratio; mode=replace clears the dataset
+// before importing instead of updating matching rows. The file is rejected as a whole if any
+// row is invalid, so a dataset is never left half imported.
+func handleImportMarketBenchmarks(w http.ResponseWriter, r *http.Request) {
+	result := benchmarkImportResult{
+		Dataset: r.URL.Query().Get("dataset"),
+		Mode:    r.URL.Query().Get("mode"),
+		Errors:  []models.BenchmarkRowError{},
+	}
+	switch result.Dataset {
+	case models.BenchmarkDatasetMarketRent, models.BenchmarkDatasetExpenseRatio:
+	default:
+		http.Error(w, "dataset must be market_rent or expense_ratio", http.StatusBadRequest)
+		return
+	}
+	switch result.Mode {
+	case "":
+		result.Mode = "upsert"
+	case "upsert", "replace":
+	default:
+		http.Error(w, "mode must be upsert or replace", http.StatusBadRequest)
+		return
+	}
+	source := strings.TrimSpace(r.URL.Query().Get("source"))
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "A CSV file is required in the file field", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		http.Error(w, "Invalid CSV: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(records) > maxBenchmarkRows+1 {
+		http.Error(w, fmt.Sprintf("Benchmark files are limited to %d rows", maxBenchmarkRows), http.StatusBadRequest)
+		return
+	}
+
+	replace := result.Mode == "replace"
+	var importErr error
+	if result.Dataset == models.BenchmarkDatasetMarketRent {
+		var rents []models.MarketRentBenchmark
+		rents, result.Errors, err = models.ParseMarketRentRows(records, source)
+		if err == nil && len(result.Errors) == 0 {
+			importErr = models.ImportMarketRentBenchmarks(rents, replace)
+			result.Imported = len(rents)
+		}
+	} else {
+		var ratios []models.ExpenseRatioBenchmark
+		ratios, result.Errors, err = models.ParseExpenseRatioRows(records, source)
+		if err == nil && len(result.Errors) == 0 {
+			importErr = models.ImportExpenseRatioBenchmarks(ratios, replace)
+			result.Imported = len(ratios)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if importErr != nil {
+		http.Error(w, "Failed to import benchmarks", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if len(result.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	} else if result.Imported == 0 {
+		http.Error(w, "The file has no benchmark rows", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetMarketRentBenchmarks(w http.ResponseWriter, r *http.Request) {
+	benchmarks, err := models.GetMarketRentBenchmarks()
+	if err != nil {
+		http.Error(w, "Failed to fetch market rent benchmarks", http.StatusInternalServerError)
+		return
+	}
+	if benchmarks == nil {
+		benchmarks = []models.MarketRentBenchmark{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(benchmarks); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetExpenseRatioBenchmarks(w http.ResponseWriter, r *http.Request) {
+	benchmarks, err := models.GetExpenseRatioBenchmarks()
+	if err != nil {
+		http.Error(w, "Failed to fetch expense ratio benchmarks", http.StatusInternalServerError)
+		return
+	}
+	if benchmarks == nil {
+		benchmarks = []models.ExpenseRatioBenchmark{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(benchmarks); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/config"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// meCacheTTL is how long a built /api/me response is reused. Changes to the user, their roles
+// or their preferences take effect at once; unread counts may lag by up to this long.
+const meCacheTTL = 30 * time.Second
+
+// meCacheMaxEntries bounds the cache; expired entries are pruned once it is reached
+const meCacheMaxEntries = 10000
+
+// meResponse is everything a client needs to render its shell for the signed-in user
+type meResponse struct {
+	User                *models.User             `json:"user"`
+	Roles               []string                 `json:"roles"`
+	Permissions         []string                 `json:"permissions"`
+	Organization        *models.UserOrganization `json:"organization"`
+	Preferences         models.UserPreferences   `json:"preferences"`
+	Features            map[string]bool          `json:"features"`
+	UnreadNotifications int                      `json:"unread_notifications"`
+}
+
+type meCacheEntry struct {
+	version string
+	body    []byte
+	expires time.Time
+}
+
+// meCache holds encoded /api/me responses by user ID
+type meCache struct {
+	mu      sync.Mutex
+	entries map[int]meCacheEntry
+}
+
+var meResponses = &meCache{entries: map[int]meCacheEntry{}}
+
+// get returns the cached response for a user if it was built for this version and is still fresh
+func (c *meCache) get(userID int, version string, now time.Time) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[userID]
+	if !ok || entry.version != version || !now.Before(entry.expires) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (c *meCache) put(userID int, version string, body []byte, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= meCacheMaxEntries {
+		for id, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[userID] = meCacheEntry{version: version, body: body, expires: now.Add(meCacheTTL)}
+}
+
+// invalidate drops a user's cached response, for changes its version does not capture
+func (c *meCache) invalidate(userID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+}
+
+// meVersion identifies the user state a cached response was built from. Preferences are stored
+// on the user, so saving them changes updated_at.
+func meVersion(user *models.User) string {
+	return strconv.FormatInt(user.UpdatedAt.UnixNano(), 10) + "|" + strings.Join(userRoleNames(user), ",")
+}
+
+func userRoleNames(user *models.User) []string {
+	names := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+// userPermissions returns the distinct permissions granted by a user's roles, sorted
+func userPermissions(user *models.User) []string {
+	seen := map[string]bool{}
+	permissions := []string{}
+	for _, role := range user.Roles {
+		for _, permission := range role.Permissions {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// buildMe gathers the signed-in user's profile, access, organization, preferences, feature
+// flags and unread notifications
+func buildMe(user *models.User) (*meResponse, error) {
+	prefs, err := models.GetUserPreferences(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	organization, err := models.GetUserOrganization(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantID := 0
+	if user.HasRole("tenant") {
+		tenant, err := models.GetTenantForUser(user)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil {
+			tenantID = tenant.ID
+		}
+	}
+	unread, err := models.CountUnreadMaintenanceMessages(user.ID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	features := map[string]bool{}
+	for name, enabled := range config.Default.Features {
+		features[name] = enabled
+	}
+
+	return &meResponse{
+		User:                user,
+		Roles:               userRoleNames(user),
+		Permissions:         userPermissions(user),
+		Organization:        organization,
+		Preferences:         prefs,
+		Features:            features,
+		UnreadNotifications: unread,
+	}, nil
+}
+
+// handleGetMe returns everything a client needs to boot in one call: the user's profile, roles,
+// permissions, organization, preferences, feature flags and unread notification count. Responses
+// are cached briefly per user and carry an ETag, so clients can revalidate cheaply.
+func handleGetMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	version := meVersion(user)
+	body, cached := meResponses.get(user.ID, version, now)
+	if !cached {
+		response, err := buildMe(user)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to build /api/me", "user_id", user.ID, "error", err)
+			http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+			return
+		}
+		if body, err = json.Marshal(response); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+		meResponses.put(user.ID, version, body, now)
+	}
+
+	if checkNotModified(w, r, user.UpdatedAt, string(body)) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserPermissions(t *testing.T) {
+	user := &models.User{Roles: []models.Role{
+		{Name: "property_manager", Permissions: models.StringArray{"properties.*", "reports.read"}},
+		{Name: "tenant", Permissions: models.StringArray{"reports.read", "maintenance.create"}},
+	}}
+	assert.Equal(t, []string{"maintenance.create", "properties.*", "reports.read"}, userPermissions(user))
+	assert.Equal(t, []string{}, userPermissions(&models.User{}))
+}
+
+func TestMeCache(t *testing.T) {
+	cache := &meCache{entries: map[int]meCacheEntry{}}
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	user := &models.User{ID: 7, UpdatedAt: now.Add(-time.Hour), Roles: []models.Role{{Name: "tenant"}}}
+
+	cache.put(user.ID, meVersion(user), []byte(`{"roles":["tenant"]}`), now)
+	body, ok := cache.get(user.ID, meVersion(user), now.Add(meCacheTTL-time.Second))
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"roles":["tenant"]}`, string(body))
+
+	_, ok = cache.get(user.ID, meVersion(user), now.Add(meCacheTTL))
+	assert.False(t, ok, "entries expire after the TTL")
+
+	user.Roles = append(user.Roles, models.Role{Name: "vendor"})
+	_, ok = cache.get(user.ID, meVersion(user), now)
+	assert.False(t, ok, "a role change bypasses the cache")
+
+	cache.put(user.ID, meVersion(user), []byte(`{}`), now)
+	cache.invalidate(user.ID)
+	_, ok = cache.get(user.ID, meVersion(user), now)
+	assert.False(t, ok)
+}
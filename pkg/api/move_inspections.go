@@ -0,0 +1,472 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterMoveInspectionRoutes registers move-in/move-out checklist, damage charge and move-out summary routes
+func RegisterMoveInspectionRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		// Condition checklists ({type} is move_in or move_out)
+		auth.Get("/api/leases/{id}/inspections", handleGetLeaseInspections)
+		auth.Put("/api/leases/{id}/inspections/{type}", handleSaveLeaseInspection)
+		auth.Post("/api/leases/{id}/inspections/{type}/complete", handleCompleteLeaseInspection)
+
+		// Move-out comparison, damage charges and deposit disposition
+		auth.Get("/api/leases/{id}/move-out", handleGetMoveOutSummary)
+		auth.Get("/api/leases/{id}/move-out/pdf", handleGetMoveOutSummaryPDF)
+		auth.With(middleware.Idempotent).Post("/api/leases/{id}/move-out/charges", handleCreateDamageCharge)
+		auth.Delete("/api/leases/{id}/move-out/charges/{chargeID}", handleDeleteDamageCharge)
+		auth.Post("/api/leases/{id}/move-out/share", handleShareMoveOutSummary)
+	})
+
+	// Tenant portal
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("tenant"))
+
+		auth.Get("/api/portal/leases/{id}/move-out/pdf", handleGetPortalMoveOutSummaryPDF)
+	})
+}
+
+// inspectionParams parses the lease id and inspection type URL parameters
+func inspectionParams(w http.ResponseWriter, r *http.Request) (int, string, bool) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return 0, "", false
+	}
+	inspectionType := chi.URLParam(r, "type")
+	if !models.ValidInspectionType(inspectionType) {
+		http.Error(w, "Inspection type must be move_in or move_out", http.StatusBadRequest)
+		return 0, "", false
+	}
+	return leaseID, inspectionType, true
+}
+
+func handleGetLeaseInspections(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	inspections, err := models.GetLeaseInspections(leaseID)
+	if err != nil {
+		http.Error(w, "Failed to fetch inspections", http.StatusInternalServerError)
+		return
+	}
+
+	if inspections == nil {
+		inspections = []models.LeaseInspection{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(inspections); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSaveLeaseInspection creates or replaces a checklist until it is completed
+func handleSaveLeaseInspection(w http.ResponseWriter, r *http.Request) {
+	leaseID, inspectionType, ok := inspectionParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		InspectionDate string `json:"inspection_date"` // YYYY-MM-DD, defaults to today
+		Notes          string `json:"notes"`
+		Items          []struct {
+			Area      string `json:"area"`
+			Item      string `json:"item"`
+			Condition string `json:"condition"`
+			Notes     string `json:"notes"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	inspection := &models.LeaseInspection{
+		LeaseID:        leaseID,
+		InspectionType: inspectionType,
+		InspectionDate: time.Now().Truncate(24 * time.Hour),
+		Notes:          models.NullString(req.Notes),
+		Items:          []models.InspectionItem{},
+	}
+	if req.InspectionDate != "" {
+		date, err := time.Parse("2006-01-02", req.InspectionDate)
+		if err != nil {
+			http.Error(w, "inspection_date must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+		inspection.InspectionDate = date
+	}
+
+	seen := map[string]bool{}
+	for _, item := range req.Items {
+		area, name := strings.TrimSpace(item.Area), strings.TrimSpace(item.Item)
+		if area == "" || name == "" {
+			http.Error(w, "Each item requires an area and item", http.StatusBadRequest)
+			return
+		}
+		if !models.ValidItemCondition(item.Condition) {
+			http.Error(w, "condition must be one of "+strings.Join(models.ItemConditions, ", "), http.StatusBadRequest)
+			return
+		}
+		key := strings.ToLower(area) + "/" + strings.ToLower(name)
+		if seen[key] {
+			http.Error(w, fmt.Sprintf("%s / %s is listed more than once", area, name), http.StatusBadRequest)
+			return
+		}
+		seen[key] = true
+		inspection.Items = append(inspection.Items, models.InspectionItem{
+			Area: area, Item: name, Condition: item.Condition, Notes: models.NullString(item.Notes),
+		})
+	}
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		inspection.InspectedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+
+	if err := models.SaveLeaseInspection(inspection); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			http.Error(w, "Lease not found", http.StatusNotFound)
+		case models.ErrInspectionCompleted:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, "Failed to save inspection", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(inspection); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleCompleteLeaseInspection locks a checklist so it can serve as the record of the unit's condition
+func handleCompleteLeaseInspection(w http.ResponseWriter, r *http.Request) {
+	leaseID, inspectionType, ok := inspectionParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.CompleteLeaseInspection(leaseID, inspectionType); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to complete inspection", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadMoveOutSummary fetches the summary for the lease in the id URL parameter, writing an error
+// response on failure
+func loadMoveOutSummary(w http.ResponseWriter, r *http.Request) (*models.MoveOutSummary, bool) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	summary, err := models.GetMoveOutSummary(leaseID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Lease not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch move-out summary", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return summary, true
+}
+
+func handleGetMoveOutSummary(w http.ResponseWriter, r *http.Request) {
+	summary, ok := loadMoveOutSummary(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func writeMoveOutSummaryPDF(w http.ResponseWriter, summary *models.MoveOutSummary) {
+	data, err := NewPDFReportGenerator().GenerateDocumentPDF(moveOutTemplate, summary, moveOutLines(summary))
+	if err != nil {
+		http.Error(w, "Failed to generate move-out summary", http.StatusInternalServerError)
+		return
+	}
+	writePDF(w, fmt.Sprintf("move_out_summary_%d.pdf", summary.LeaseID), data)
+}
+
+func handleGetMoveOutSummaryPDF(w http.ResponseWriter, r *http.Request) {
+	summary, ok := loadMoveOutSummary(w, r)
+	if !ok {
+		return
+	}
+	writeMoveOutSummaryPDF(w, summary)
+}
+
+func handleGetPortalMoveOutSummaryPDF(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+	summary, ok := loadMoveOutSummary(w, r)
+	if !ok {
+		return
+	}
+
+	// Tenants only see their own leases, and only once the move-out inspection is completed
+	if summary.TenantID != tenant.ID || summary.MoveOut == nil || !summary.MoveOut.CompletedAt.Valid {
+		http.Error(w, "Move-out summary not found", http.StatusNotFound)
+		return
+	}
+	writeMoveOutSummaryPDF(w, summary)
+}
+
+// handleCreateDamageCharge itemizes a move-out charge against the security deposit
+func handleCreateDamageCharge(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		InspectionItemID *int    `json:"inspection_item_id"` // Move-out checklist item, optional
+		Description      string  `json:"description"`
+		Amount           float64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Description) == "" || req.Amount <= 0 {
+		http.Error(w, "description and a positive amount are required", http.StatusBadRequest)
+		return
+	}
+
+	charge := &models.DamageCharge{
+		LeaseID:     leaseID,
+		Description: strings.TrimSpace(req.Description),
+		Amount:      req.Amount,
+	}
+	if req.InspectionItemID != nil {
+		charge.InspectionItemID = sql.NullInt32{Int32: int32(*req.InspectionItemID), Valid: true}
+	}
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		charge.CreatedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+
+	if err := models.CreateDamageCharge(charge); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			http.Error(w, "Move-out inspection not found", http.StatusNotFound)
+		case models.ErrMoveOutIncomplete, models.ErrChargeItemMismatch:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, "Failed to create damage charge", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(charge); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteDamageCharge(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+	chargeID, err := strconv.Atoi(chi.URLParam(r, "chargeID"))
+	if err != nil {
+		http.Error(w, "Invalid charge ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteDamageCharge(leaseID, chargeID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Damage charge not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete damage charge", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleShareMoveOutSummary emails the tenant their deposit disposition with a link to the summary PDF
+func handleShareMoveOutSummary(w http.ResponseWriter, r *http.Request) {
+	summary, ok := loadMoveOutSummary(w, r)
+	if !ok {
+		return
+	}
+	if summary.MoveOut == nil || !summary.MoveOut.CompletedAt.Valid {
+		http.Error(w, "The move-out inspection must be completed before the summary is shared", http.StatusUnprocessableEntity)
+		return
+	}
+
+	link := fmt.Sprintf("%s/api/portal/leases/%d/move-out/pdf", appBaseURL(), summary.LeaseID)
+	if err := models.EmailMoveOutSummary(summary, link); err != nil {
+		http.Error(w, "Failed to queue move-out summary email", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// moveOutLines is the plain text form of a move-out summary
+func moveOutLines(summary *models.MoveOutSummary) []string {
+	property := summary.PropertyName
+	if summary.UnitNumber.Valid {
+		property += ", " + summary.UnitNumber.String
+	}
+	lines := []string{
+		"Fire PMAAS - Move-Out Summary",
+		"",
+		"Tenant:   " + summary.TenantName,
+		"Property: " + property,
+	}
+	if summary.MoveIn != nil {
+		lines = append(lines, "Move-in inspection:  "+summary.MoveIn.InspectionDate.Format("January 2, 2006"))
+	}
+	if summary.MoveOut != nil {
+		lines = append(lines, "Move-out inspection: "+summary.MoveOut.InspectionDate.Format("January 2, 2006"))
+	}
+
+	lines = append(lines, "", "Condition", fmt.Sprintf("%-36s %-12s %-12s", "Area / item", "Move-in", "Move-out"))
+	for _, c := range summary.Comparison {
+		name := c.Area + " / " + c.Item
+		if len(name) > 36 {
+			name = name[:36]
+		}
+		line := fmt.Sprintf("%-36s %-12s %-12s", name, orDash(c.MoveInCondition), orDash(c.MoveOutCondition))
+		if c.Worsened {
+			line += " *"
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, "", "Charges")
+	if len(summary.Charges) == 0 {
+		lines = append(lines, "None")
+	}
+	for _, c := range summary.Charges {
+		description := c.Description
+		if len(description) > 50 {
+			description = description[:50]
+		}
+		lines = append(lines, fmt.Sprintf("%-50s %12.2f", description, c.Amount))
+	}
+
+	d := summary.Deposit
+	lines = append(lines, "",
+		fmt.Sprintf("Security deposit: $%.2f", d.SecurityDeposit),
+		fmt.Sprintf("Deductions:       $%.2f", d.Deductions),
+		fmt.Sprintf("Refund due:       $%.2f", d.Refund))
+	if d.BalanceDue > 0 {
+		lines = append(lines, fmt.Sprintf("Balance owed:     $%.2f", d.BalanceDue))
+	}
+	return append(lines, "", "* condition worse than at move-in")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// moveOutTemplate renders a move-out summary for wkhtmltopdf
+var moveOutTemplate = template.Must(template.New("move_out").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Move-Out Summary</title>
+    <style>
+        body { font-family: Arial, sans-serif; color: #333; padding: 20px; }
+        h1 { color: #1F2937; border-bottom: 2px solid #3B82F6; padding-bottom: 10px; }
+        h2 { color: #374151; margin-top: 30px; }
+        table { border-collapse: collapse; width: 100%; }
+        th, td { border: 1px solid #D1D5DB; padding: 8px; text-align: left; }
+        th { background-color: #F9FAFB; }
+        .worse td { background-color: #FEF2F2; }
+        .total td { font-weight: bold; }
+        .footer { margin-top: 40px; color: #6B7280; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <h1>Move-Out Summary</h1>
+    <p><strong>Tenant:</strong> {{.TenantName}}<br>
+       <strong>Property:</strong> {{.PropertyName}}{{if .UnitNumber.Valid}}, {{.UnitNumber.String}}{{end}}<br>
+       {{if .MoveIn}}<strong>Move-in inspection:</strong> {{.MoveIn.InspectionDate.Format "January 2, 2006"}}<br>{{end}}
+       {{if .MoveOut}}<strong>Move-out inspection:</strong> {{.MoveOut.InspectionDate.Format "January 2, 2006"}}{{end}}</p>
+
+    <h2>Condition</h2>
+    <table>
+        <thead><tr><th>Area</th><th>Item</th><th>Move-in</th><th>Move-out</th><th>Notes</th></tr></thead>
+        <tbody>
+            {{range .Comparison}}
+            <tr{{if .Worsened}} class="worse"{{end}}>
+                <td>{{.Area}}</td><td>{{.Item}}</td>
+                <td>{{or .MoveInCondition "-"}}</td><td>{{or .MoveOutCondition "-"}}</td>
+                <td>{{.MoveOutNotes.String}}</td>
+            </tr>
+            {{end}}
+        </tbody>
+    </table>
+
+    <h2>Charges</h2>
+    <table>
+        <thead><tr><th>Description</th><th>Amount</th></tr></thead>
+        <tbody>
+            {{range .Charges}}<tr><td>{{.Description}}</td><td>${{printf "%.2f" .Amount}}</td></tr>{{else}}<tr><td colspan="2">None</td></tr>{{end}}
+        </tbody>
+    </table>
+
+    <h2>Security Deposit</h2>
+    <table>
+        <tr><td>Security deposit</td><td>${{printf "%.2f" .Deposit.SecurityDeposit}}</td></tr>
+        <tr><td>Deductions</td><td>${{printf "%.2f" .Deposit.Deductions}}</td></tr>
+        <tr class="total"><td>Refund due</td><td>${{printf "%.2f" .Deposit.Refund}}</td></tr>
+        {{if gt .Deposit.BalanceDue 0.0}}<tr class="total"><td>Balance owed</td><td>${{printf "%.2f" .Deposit.BalanceDue}}</td></tr>{{end}}
+    </table>
+    <div class="footer">Highlighted items are in worse condition than at move-in. Fire PMAAS - Property Management as a Service</div>
+</body>
+</html>`))
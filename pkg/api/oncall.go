@@ -0,0 +1,233 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterOnCallRoutes registers on-call schedule and emergency contact routes
+func RegisterOnCallRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+		auth.Use(scopedRecordRoutes("/api/oncall/shifts/{id}", "id", models.GetOnCallShiftPropertyID, "On-call shift not found"))
+
+		auth.Get("/api/oncall/shifts", handleGetOnCallShifts)
+		auth.Post("/api/oncall/shifts", handleCreateOnCallShift)
+		auth.Delete("/api/oncall/shifts/{id}", handleDeleteOnCallShift)
+		auth.Put("/api/properties/{id}/emergency-contact", handleSetEmergencyContact)
+	})
+
+	// Tenants can look up who to call in an emergency
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager", "tenant"))
+		auth.Use(scopedPropertyRoutes)
+
+		auth.Get("/api/properties/{id}/oncall", handleGetOnCallContact)
+	})
+}
+
+// handleGetOnCallShifts lists shifts between start and end (RFC 3339, default: the next 14 days)
+func handleGetOnCallShifts(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	end := start.AddDate(0, 0, 14)
+
+	if s := r.URL.Query().Get("start"); s != "" {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "Invalid start, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		start = parsed
+	}
+	if s := r.URL.Query().Get("end"); s != "" {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "Invalid end, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		end = parsed
+	}
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	shifts, err := models.GetOnCallShifts(properties, start, end)
+	if err != nil {
+		http.Error(w, "Failed to fetch on-call shifts", http.StatusInternalServerError)
+		return
+	}
+	if shifts == nil {
+		shifts = []models.OnCallShift{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(shifts); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCreateOnCallShift(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		PropertyID int       `json:"property_id"`
+		UserID     int       `json:"user_id"`
+		StartsAt   time.Time `json:"starts_at"`
+		EndsAt     time.Time `json:"ends_at"`
+		Notes      string    `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.PropertyID <= 0 || req.UserID <= 0 {
+		http.Error(w, "property_id and user_id are required", http.StatusBadRequest)
+		return
+	}
+	if !requirePropertyInScope(w, r, req.PropertyID, "Property not found") {
+		return
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		http.Error(w, "ends_at must be after starts_at", http.StatusBadRequest)
+		return
+	}
+
+	shift := &models.OnCallShift{
+		PropertyID: req.PropertyID,
+		UserID:     req.UserID,
+		StartsAt:   req.StartsAt,
+		EndsAt:     req.EndsAt,
+		Notes:      models.NullString(strings.TrimSpace(req.Notes)),
+		CreatedBy:  sql.NullInt32{Int32: int32(user.ID), Valid: true},
+	}
+	if err := models.CreateOnCallShift(shift); err != nil {
+		http.Error(w, "Failed to create on-call shift", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(shift); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteOnCallShift(w http.ResponseWriter, r *http.Request) {
+	shiftID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid shift ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteOnCallShift(shiftID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "On-call shift not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete on-call shift", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleSetEmergencyContact(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var contact models.EmergencyContact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetPropertyEmergencyContact(propertyID, contact); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Property not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update emergency contact", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetOnCallContact resolves the property's on-call contact now, or at ?at= (RFC 3339).
+// Tenants may only look up properties where they hold an active lease.
+func handleGetOnCallContact(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	_, tenant, ok := maintenanceTenant(w, r)
+	if !ok {
+		return
+	}
+	if tenant != nil {
+		propertyIDs, err := models.GetTenantPropertyIDs(tenant.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch leased properties", http.StatusInternalServerError)
+			return
+		}
+		leased := false
+		for _, id := range propertyIDs {
+			leased = leased || id == propertyID
+		}
+		if !leased {
+			http.Error(w, "You do not have an active lease at this property", http.StatusForbidden)
+			return
+		}
+	}
+
+	at := time.Now()
+	if s := r.URL.Query().Get("at"); s != "" {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "Invalid at, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		at = parsed
+	}
+
+	contact, err := models.GetOnCallContact(propertyID, at)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "No on-call contact configured for this property", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to resolve on-call contact", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(contact); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterOutboxRoutes registers admin routes for inspecting and retrying outbox deliveries
+func RegisterOutboxRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("admin"))
+
+		auth.Get("/api/admin/outbox", handleListOutboxMessages)
+		auth.Post("/api/admin/outbox/{id}/retry", handleRetryOutboxMessage)
+	})
+}
+
+func handleListOutboxMessages(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 500 {
+			limit = l
+		}
+	}
+
+	messages, err := models.GetOutboxMessages(status, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch outbox messages", http.StatusInternalServerError)
+		return
+	}
+
+	if messages == nil {
+		messages = []models.OutboxMessage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(messages); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleRetryOutboxMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RequeueOutboxMessage(messageID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Dead-lettered message not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to requeue message", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Message requeued for delivery"}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,393 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// maxOwnerDocumentBytes limits the size of a document shared with owners
+const maxOwnerDocumentBytes = 10 << 20
+
+// RegisterOwnerPortalRoutes registers the property ownership and owner document management
+// routes, and the owner portal routes limited to the caller's own properties
+func RegisterOwnerPortalRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+		auth.Use(scopedRecordRoutes("/api/owner-documents/{id}", "id",
+			models.GetOwnerDocumentPropertyID, "Document not found"))
+
+		auth.Get("/api/properties/{id}/owners", handleGetPropertyOwners)
+		auth.Put("/api/properties/{id}/owners", handleSetPropertyOwners)
+		auth.Get("/api/properties/{id}/owner-documents", handleGetPropertyOwnerDocuments)
+		auth.With(middleware.LimitBody(maxOwnerDocumentBytes+1<<20)).Post("/api/properties/{id}/owner-documents", handleUploadOwnerDocument)
+		auth.Delete("/api/owner-documents/{id}", handleDeleteOwnerDocument)
+	})
+
+	// Owner portal
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("owner"))
+
+		auth.Get("/api/owner/properties", handleGetOwnedProperties)
+		auth.With(middleware.RequirePermission(models.PermOwnerStatements)).
+			Get("/api/owner/statements", handleGetOwnerStatements)
+		auth.With(middleware.RequirePermission(models.PermOwnerOccupancy)).
+			Get("/api/owner/occupancy", handleGetOwnerOccupancy)
+		auth.With(middleware.RequirePermission(models.PermOwnerMaintenance)).
+			Get("/api/owner/maintenance", handleGetOwnerMaintenance)
+		auth.With(middleware.RequirePermission(models.PermOwnerDocuments)).
+			Get("/api/owner/documents", handleGetOwnerDocuments)
+	})
+}
+
+// writeOwnershipError maps property ownership errors to responses
+func writeOwnershipError(w http.ResponseWriter, err error, notFound, failure string) {
+	var unknown *models.UnknownUsersError
+	switch {
+	case errors.As(err, &unknown):
+		http.Error(w, unknown.Error(), http.StatusUnprocessableEntity)
+	case err == models.ErrInvalidOwnership:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case err == sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+func handleGetPropertyOwners(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	owners, err := models.GetPropertyOwners(propertyID)
+	if err != nil {
+		http.Error(w, "Failed to fetch property owners", http.StatusInternalServerError)
+		return
+	}
+
+	if owners == nil {
+		owners = []models.PropertyOwnership{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(owners); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSetPropertyOwners replaces the users who own a property. Owners only see properties they
+// are recorded against here, and also need the owner role to use the portal.
+func handleSetPropertyOwners(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Owners []struct {
+			UserID           int     `json:"user_id"`
+			OwnershipPercent float64 `json:"ownership_percent"`
+		} `json:"owners"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	owners := make([]models.PropertyOwnership, len(req.Owners))
+	for i, o := range req.Owners {
+		owners[i] = models.PropertyOwnership{UserID: o.UserID, OwnershipPercent: o.OwnershipPercent}
+	}
+	if err := models.SetPropertyOwners(propertyID, owners); err != nil {
+		writeOwnershipError(w, err, "Property not found", "Failed to set property owners")
+		return
+	}
+
+	saved, err := models.GetPropertyOwners(propertyID)
+	if err != nil {
+		http.Error(w, "Failed to fetch property owners", http.StatusInternalServerError)
+		return
+	}
+	if saved == nil {
+		saved = []models.PropertyOwnership{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(saved); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetPropertyOwnerDocuments(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	documents, err := models.GetPropertyOwnerDocuments(propertyID)
+	if err != nil {
+		http.Error(w, "Failed to fetch owner documents", http.StatusInternalServerError)
+		return
+	}
+
+	if documents == nil {
+		documents = []models.OwnerDocument{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(documents); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUploadOwnerDocument accepts a multipart "file" and "title" and shares the document with
+// the property's owners
+func handleUploadOwnerDocument(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseMultipartForm(maxOwnerDocumentBytes); err != nil {
+		http.Error(w, "Upload one document of at most 10MB", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "A document file is required", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxOwnerDocumentBytes+1))
+	file.Close()
+	if err != nil || len(data) == 0 || len(data) > maxOwnerDocumentBytes {
+		http.Error(w, "The document must be between 1 byte and 10MB", http.StatusBadRequest)
+		return
+	}
+
+	// Trust the content, not the client's declared type
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	ext, ok := messageAttachmentTypes[contentType]
+	if !ok {
+		http.Error(w, "The document must be an image, PDF or plain text file", http.StatusBadRequest)
+		return
+	}
+
+	filename := sanitizeFilename(filepath.Base(header.Filename))
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" {
+		title = filename
+	}
+
+	token, err := randomHex(16)
+	if err != nil {
+		http.Error(w, "Failed to store document", http.StatusInternalServerError)
+		return
+	}
+	key := fmt.Sprintf("properties/%d/owner-documents/%s%s", propertyID, token, ext)
+	url, err := storage.Default.Save(key, data)
+	if err != nil {
+		http.Error(w, "Failed to store document", http.StatusInternalServerError)
+		return
+	}
+
+	doc := &models.OwnerDocument{
+		PropertyID:  propertyID,
+		Title:       title,
+		Filename:    filename,
+		ContentType: contentType,
+		SizeBytes:   len(data),
+		URL:         url,
+		UploadedBy:  sql.NullInt32{Int32: int32(user.ID), Valid: true},
+	}
+	if err := models.CreateOwnerDocument(doc); err != nil {
+		deleteStoredFiles(map[string]string{key: url})
+		writeOwnershipError(w, err, "Property not found", "Failed to save document")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteOwnerDocument(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	url, err := models.DeleteOwnerDocument(id)
+	if err != nil {
+		writeOwnershipError(w, err, "Document not found", "Failed to delete document")
+		return
+	}
+	deleteStoredFiles(map[string]string{url: url})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownerPortalUser returns the logged-in owner, writing an error response if there is none
+func ownerPortalUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+func handleGetOwnedProperties(w http.ResponseWriter, r *http.Request) {
+	user, ok := ownerPortalUser(w, r)
+	if !ok {
+		return
+	}
+
+	properties, err := models.GetOwnedProperties(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch properties", http.StatusInternalServerError)
+		return
+	}
+
+	if properties == nil {
+		properties = []models.PropertyOwnership{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(properties); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetOwnerStatements returns the owner's statement for each of their properties for
+// ?month=YYYY-MM, defaulting to the current month
+func handleGetOwnerStatements(w http.ResponseWriter, r *http.Request) {
+	user, ok := ownerPortalUser(w, r)
+	if !ok {
+		return
+	}
+
+	month := time.Now()
+	if v := r.URL.Query().Get("month"); v != "" {
+		var err error
+		if month, err = time.Parse("2006-01", v); err != nil {
+			http.Error(w, "month must be in YYYY-MM format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	statements, err := models.GetOwnerStatements(user.ID, month)
+	if err != nil {
+		http.Error(w, "Failed to fetch statements", http.StatusInternalServerError)
+		return
+	}
+
+	if statements == nil {
+		statements = []models.OwnerStatement{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statements); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetOwnerOccupancy(w http.ResponseWriter, r *http.Request) {
+	user, ok := ownerPortalUser(w, r)
+	if !ok {
+		return
+	}
+
+	occupancy, err := models.GetOwnerOccupancy(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch occupancy", http.StatusInternalServerError)
+		return
+	}
+
+	if occupancy == nil {
+		occupancy = []models.OwnerOccupancy{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(occupancy); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetOwnerMaintenance lists work orders at the owner's properties whose estimates were
+// above the auto-approval limit
+func handleGetOwnerMaintenance(w http.ResponseWriter, r *http.Request) {
+	user, ok := ownerPortalUser(w, r)
+	if !ok {
+		return
+	}
+
+	requests, err := models.GetOwnerMaintenance(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch maintenance requests", http.StatusInternalServerError)
+		return
+	}
+
+	if requests == nil {
+		requests = []models.OwnerMaintenanceRequest{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(requests); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetOwnerDocuments(w http.ResponseWriter, r *http.Request) {
+	user, ok := ownerPortalUser(w, r)
+	if !ok {
+		return
+	}
+
+	documents, err := models.GetOwnerDocuments(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch documents", http.StatusInternalServerError)
+		return
+	}
+
+	if documents == nil {
+		documents = []models.OwnerDocument{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(documents); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,7 +14,9 @@ import (
 
 // PDFReportGenerator handles PDF generation for reports
 type PDFReportGenerator struct {
-	Template *template.Template
+	Template  *template.Template
+	Watermark *models.ReportWatermark // Stamped on every page when set
+	Locale    *exportLocale           // Labels, dates and numbers; US English when nil
 }
 
 // NewPDFReportGenerator creates a new PDF report generator
@@ -47,11 +50,15 @@ func (g *PDFReportGenerator) generateHTMLContent(reportData *models.ReportData,
 		Data        *models.ReportData
 		GeneratedAt time.Time
 		Title       string
+		Watermark   *models.ReportWatermark
+		Locale      *exportLocale
 	}{
 		Report:      reportInfo,
 		Data:        reportData,
 		GeneratedAt: time.Now(),
-		Title:       fmt.Sprintf("%s Report", reportInfo.Name),
+		Title:       g.Locale.labelf("%s Report", reportInfo.Name),
+		Watermark:   g.Watermark,
+		Locale:      g.Locale,
 	}
 
 	var buf bytes.Buffer
@@ -157,7 +164,7 @@ BT
 (This is a simplified PDF export.) Tj
 0 -20 Td
 (For full formatting, install wkhtmltopdf.) Tj
-ET
+%sET
 endstream
 endobj
 
@@ -187,11 +194,21 @@ startxref
 %%%%EOF`,
 		180, // approximate length of the content stream
 		time.Now().Format("2006-01-02 15:04:05"),
+		g.simplePDFWatermark(),
 	)
 
 	return []byte(pdfContent), nil
 }
 
+// simplePDFWatermark returns the content stream operators that print the watermark, if any
+func (g *PDFReportGenerator) simplePDFWatermark() string {
+	if g.Watermark == nil {
+		return ""
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(g.Watermark.Text())
+	return fmt.Sprintf("0 -40 Td\n(%s) Tj\n", escaped)
+}
+
 // loadPDFTemplates loads HTML templates for PDF generation
 func loadPDFTemplates() *template.Template {
 	htmlTemplate := `
@@ -251,6 +268,9 @@ func loadPDFTemplates() *template.Template {
             padding: 12px 8px;
             text-align: left;
         }
+        .data-table td.numeric {
+            text-align: right;
+        }
         .data-table th {
             background-color: #F9FAFB;
             font-weight: bold;
@@ -300,6 +320,27 @@ func loadPDFTemplates() *template.Template {
             color: #6B7280;
             font-size: 12px;
         }
+        .watermark {
+            position: fixed;
+            top: 45%;
+            left: 0;
+            width: 100%;
+            text-align: center;
+            transform: rotate(-30deg);
+            color: rgba(220, 38, 38, 0.15);
+            font-size: 28px;
+            font-weight: bold;
+            z-index: 1000;
+            pointer-events: none;
+        }
+        .confidentiality {
+            border: 1px solid #DC2626;
+            color: #DC2626;
+            padding: 8px;
+            margin-bottom: 20px;
+            text-align: center;
+            font-weight: bold;
+        }
         @media print {
             body { margin: 0; }
             .header { page-break-inside: avoid; }
@@ -309,27 +350,31 @@ func loadPDFTemplates() *template.Template {
     </style>
 </head>
 <body>
+    {{if .Watermark}}
+    <div class="watermark">{{.Watermark.Label}}<br>{{.Watermark.ExportedBy}}<br>{{.Watermark.ExportedAt.Format "2006-01-02 15:04 UTC"}}</div>
+    <div class="confidentiality">{{.Watermark.Text}}</div>
+    {{end}}
     <div class="header">
         <h1>{{.Report.Name}}</h1>
-        <p class="subtitle">{{.Report.ReportType | title}} Report</p>
+        <p class="subtitle">{{labelf .Locale "%s Report" (label .Locale (.Report.ReportType | title))}}</p>
     </div>
 
     <div class="meta-info">
         <table>
             <tr>
-                <td class="label">Generated:</td>
-                <td>{{.GeneratedAt.Format "January 2, 2006 at 3:04 PM"}}</td>
-                <td class="label">Report Type:</td>
-                <td>{{.Report.ReportType | title}}</td>
+                <td class="label">{{label .Locale "Generated"}}:</td>
+                <td>{{timestamp .Locale .GeneratedAt}}</td>
+                <td class="label">{{label .Locale "Report Type"}}:</td>
+                <td>{{label .Locale (.Report.ReportType | title)}}</td>
             </tr>
             <tr>
-                <td class="label">Description:</td>
-                <td colspan="3">{{if .Report.Description.Valid}}{{.Report.Description.String}}{{else}}No description provided{{end}}</td>
+                <td class="label">{{label .Locale "Description"}}:</td>
+                <td colspan="3">{{if .Report.Description.Valid}}{{.Report.Description.String}}{{else}}{{label .Locale "No description provided"}}{{end}}</td>
             </tr>
             <tr>
-                <td class="label">Total Records:</td>
+                <td class="label">{{label .Locale "Total Records"}}:</td>
                 <td>{{len .Data.Rows}}</td>
-                <td class="label">Columns:</td>
+                <td class="label">{{label .Locale "Columns"}}:</td>
                 <td>{{len .Data.Headers}}</td>
             </tr>
         </table>
@@ -337,12 +382,12 @@ func loadPDFTemplates() *template.Template {
 
     {{if .Data.Summary}}
     <div class="summary">
-        <h3>Summary Statistics</h3>
+        <h3>{{label .Locale "Summary Statistics"}}</h3>
         <div class="summary-grid">
             {{range $key, $value := .Data.Summary}}
             <div class="summary-item">
-                <div class="label">{{$key | replace "_" " " | title}}</div>
-                <div class="value">{{$value}}</div>
+                <div class="label">{{summaryLabel $.Locale $key}}</div>
+                <div class="value">{{summaryValue $.Locale $value}}</div>
             </div>
             {{end}}
         </div>
@@ -359,10 +404,10 @@ func loadPDFTemplates() *template.Template {
             </tr>
         </thead>
         <tbody>
-            {{range .Data.Rows}}
+            {{range $row := .Data.Rows}}
             <tr>
                 {{range $.Data.Headers}}
-                <td>{{index $ .}}</td>
+                <td{{if numeric $.Data .}} class="numeric"{{end}}>{{cell $.Locale $.Data $row .}}</td>
                 {{end}}
             </tr>
             {{end}}
@@ -370,13 +415,14 @@ func loadPDFTemplates() *template.Template {
     </table>
     {{else}}
     <div style="text-align: center; padding: 50px; color: #6B7280;">
-        <p>No data available for this report</p>
+        <p>{{label .Locale "No data available for this report"}}</p>
     </div>
     {{end}}
 
     <div class="footer">
-        <p>Fire PMAAS - Property Management as a Service</p>
-        <p>This report was generated automatically on {{.GeneratedAt.Format "January 2, 2006 at 3:04 PM"}}</p>
+        <p>Fire PMAAS - {{label .Locale "Property Management as a Service"}}</p>
+        <p>{{labelf .Locale "This report was generated automatically on %s" (timestamp .Locale .GeneratedAt)}}</p>
+        {{if .Watermark}}<p>{{.Watermark.Text}}. Do not distribute.</p>{{end}}
     </div>
 </body>
 </html>`
@@ -393,12 +439,114 @@ func loadPDFTemplates() *template.Template {
 		"replace": func(old, new, s string) string {
 			return strings.ReplaceAll(s, old, new)
 		},
+		"cell": func(locale *exportLocale, data *models.ReportData, row map[string]interface{}, header string) string {
+			column, _ := data.Column(header)
+			return locale.formatCell(row[header], column)
+		},
+		"numeric": func(data *models.ReportData, header string) bool {
+			column, _ := data.Column(header)
+			return column.IsNumeric()
+		},
+		"label": func(locale *exportLocale, english string) string {
+			return locale.label(english)
+		},
+		"labelf": func(locale *exportLocale, format string, args ...interface{}) string {
+			return locale.labelf(format, args...)
+		},
+		"timestamp": func(locale *exportLocale, t time.Time) string {
+			return locale.formatTime(t)
+		},
+		"summaryLabel": func(locale *exportLocale, key string) string {
+			return locale.summaryLabel(key)
+		},
+		"summaryValue": func(locale *exportLocale, value interface{}) string {
+			return locale.formatSummaryValue(value)
+		},
 	})
 
 	template.Must(tmpl.Parse(htmlTemplate))
 	return tmpl
 }
 
+// formatReportCell renders a value for display in US English according to its column type:
+// "$1,234.50", "85.50%", "1,234", "Mar 7, 2024" or "Mar 2024". Values of undeclared columns and
+// values not of the column's type, such as redacted ones, are shown as they are.
+func formatReportCell(value interface{}, column models.ReportColumn) string {
+	return (*exportLocale)(nil).formatCell(value, column)
+}
+
+// formatCell renders a value for display in the locale according to its column type, as
+// formatReportCell does for US English
+func (l *exportLocale) formatCell(value interface{}, column models.ReportColumn) string {
+	if value == nil {
+		return ""
+	}
+
+	if column.IsNumeric() {
+		number, ok := exportNumber(value)
+		if !ok {
+			return fmt.Sprintf("%v", value)
+		}
+		switch column.Type {
+		case models.ColumnCurrency:
+			return l.formatCurrency(number, column.Decimals, column.Currency)
+		case models.ColumnPercent:
+			return l.formatNumber(number, column.Decimals) + "%"
+		default:
+			return l.formatNumber(number, column.Decimals)
+		}
+	}
+
+	if column.Type == models.ColumnDate {
+		layout := "2006-01-02"
+		if column.Format == models.ColumnFormatMonth {
+			layout = "2006-01"
+		}
+		date, ok := value.(time.Time)
+		if s, isString := value.(string); isString {
+			parsed, err := time.Parse(layout, s)
+			date, ok = parsed, err == nil
+		}
+		if ok {
+			if column.Format == models.ColumnFormatMonth {
+				return l.formatMonth(date)
+			}
+			return date.Format(l.orDefault().dateLayout)
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// groupThousands formats a number with the given decimal places and comma thousands separators
+func groupThousands(number float64, decimals int) string {
+	formatted := strconv.FormatFloat(number, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(formatted, "-") {
+		sign, formatted = "-", formatted[1:]
+	}
+	whole, fraction := formatted, ""
+	if dot := strings.IndexByte(formatted, '.'); dot >= 0 {
+		whole, fraction = formatted[:dot], formatted[dot:]
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	return sign + whole + fraction
+}
+
+// currencySymbol returns the symbol shown before amounts in a currency, or the code itself
+func currencySymbol(currency string) string {
+	switch currency {
+	case "", "USD":
+		return "$"
+	case "EUR":
+		return "€"
+	case "GBP":
+		return "£"
+	}
+	return currency + " "
+}
+
 // sanitizeFilename removes unsafe characters from filenames
 func sanitizeFilename(filename string) string {
 	// Replace unsafe characters with underscores
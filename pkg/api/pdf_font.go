@@ -0,0 +1,237 @@
+package api
+
+import (
+	"bytes"
+	"compress/zlib"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// documentFontData is DejaVu Sans Mono; see fonts/LICENSE. A monospaced face keeps the columns
+// of plain text statements aligned.
+//
+//go:embed fonts/DejaVuSansMono.ttf
+var documentFontData []byte
+
+// documentFont is the font plain text PDFs are written in
+var documentFont = mustParseTrueType("DejaVuSansMono", documentFontData)
+
+// trueTypeFont is a TrueType font embedded in generated PDFs as a Type0 font with Identity-H
+// encoding, so text in any script the font covers is drawn as written
+type trueTypeFont struct {
+	name       string
+	data       []byte
+	unitsPerEm int
+	bbox       [4]int
+	ascent     int
+	descent    int
+	widths     []int           // Advance width of each glyph in font units
+	glyphs     map[rune]uint16 // Glyph ID of each character the font covers
+}
+
+// mustParseTrueType parses the tables of a TrueType font needed to embed it, panicking on a
+// malformed font
+func mustParseTrueType(name string, data []byte) *trueTypeFont {
+	font, err := parseTrueType(name, data)
+	if err != nil {
+		panic(fmt.Sprintf("font %s: %v", name, err))
+	}
+	return font
+}
+
+func parseTrueType(name string, data []byte) (*trueTypeFont, error) {
+	if len(data) < 12 {
+		return nil, errors.New("truncated font")
+	}
+	tables := map[string][]byte{}
+	for i, n := 0, int(binary.BigEndian.Uint16(data[4:])); i < n; i++ {
+		entry := 12 + 16*i
+		if entry+16 > len(data) {
+			return nil, errors.New("truncated table directory")
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+8:]))
+		length := int(binary.BigEndian.Uint32(data[entry+12:]))
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("table %s out of range", data[entry:entry+4])
+		}
+		tables[string(data[entry:entry+4])] = data[offset : offset+length]
+	}
+	head, hhea, hmtx, cmap := tables["head"], tables["hhea"], tables["hmtx"], tables["cmap"]
+	if len(head) < 54 || len(hhea) < 36 || hmtx == nil || cmap == nil {
+		return nil, errors.New("missing head, hhea, hmtx or cmap table")
+	}
+
+	font := &trueTypeFont{name: name, data: data, glyphs: map[rune]uint16{}}
+	font.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	for i := range font.bbox {
+		font.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+	font.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	font.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+
+	metrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	if metrics == 0 || 4*metrics > len(hmtx) {
+		return nil, errors.New("invalid horizontal metrics")
+	}
+	font.widths = make([]int, metrics)
+	for i := range font.widths {
+		font.widths[i] = int(binary.BigEndian.Uint16(hmtx[4*i:]))
+	}
+
+	if err := font.parseCmap(cmap); err != nil {
+		return nil, err
+	}
+	return font, nil
+}
+
+// parseCmap reads the Unicode character to glyph mapping, preferring the full-repertoire
+// format 12 subtable over the Basic Multilingual Plane format 4 one
+func (f *trueTypeFont) parseCmap(cmap []byte) error {
+	var format4, format12 []byte
+	for i, n := 0, int(binary.BigEndian.Uint16(cmap[2:])); i < n && 4+8*i+8 <= len(cmap); i++ {
+		record := cmap[4+8*i:]
+		platform, encoding := binary.BigEndian.Uint16(record), binary.BigEndian.Uint16(record[2:])
+		offset := int(binary.BigEndian.Uint32(record[4:]))
+		if offset+4 > len(cmap) || (platform != 0 && !(platform == 3 && (encoding == 1 || encoding == 10))) {
+			continue
+		}
+		switch binary.BigEndian.Uint16(cmap[offset:]) {
+		case 4:
+			format4 = cmap[offset:]
+		case 12:
+			format12 = cmap[offset:]
+		}
+	}
+
+	switch {
+	case format12 != nil && len(format12) >= 16:
+		groups := int(binary.BigEndian.Uint32(format12[12:]))
+		for i := 0; i < groups && 16+12*i+12 <= len(format12); i++ {
+			group := format12[16+12*i:]
+			start, end := binary.BigEndian.Uint32(group), binary.BigEndian.Uint32(group[4:])
+			glyph := binary.BigEndian.Uint32(group[8:])
+			for c := start; c <= end && c <= 0x10FFFF; c++ {
+				f.glyphs[rune(c)] = uint16(glyph + c - start)
+			}
+		}
+	case format4 != nil && len(format4) >= 14:
+		segments := int(binary.BigEndian.Uint16(format4[6:])) / 2
+		ends, starts := 14, 16+2*segments
+		deltas, rangeOffsets := starts+2*segments, starts+4*segments
+		if rangeOffsets+2*segments > len(format4) {
+			return errors.New("truncated cmap")
+		}
+		for s := 0; s < segments; s++ {
+			start := int(binary.BigEndian.Uint16(format4[starts+2*s:]))
+			end := int(binary.BigEndian.Uint16(format4[ends+2*s:]))
+			delta := int(binary.BigEndian.Uint16(format4[deltas+2*s:]))
+			rangeOffset := int(binary.BigEndian.Uint16(format4[rangeOffsets+2*s:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				glyph := c + delta
+				if rangeOffset != 0 {
+					at := rangeOffsets + 2*s + rangeOffset + 2*(c-start)
+					if at+2 > len(format4) {
+						continue
+					}
+					if glyph = int(binary.BigEndian.Uint16(format4[at:])); glyph != 0 {
+						glyph += delta
+					}
+				}
+				if glyph&0xFFFF != 0 {
+					f.glyphs[rune(c)] = uint16(glyph)
+				}
+			}
+		}
+	default:
+		return errors.New("no Unicode cmap")
+	}
+	return nil
+}
+
+// width returns a glyph's advance width in PDF text space units (1/1000 of the font size)
+func (f *trueTypeFont) width(glyph uint16) int {
+	w := f.widths[len(f.widths)-1] // Glyphs past the metrics share the last width
+	if int(glyph) < len(f.widths) {
+		w = f.widths[glyph]
+	}
+	return w * 1000 / f.unitsPerEm
+}
+
+// encode returns text as a PDF hex string of glyph IDs, recording the characters of the glyphs
+// used. Characters the font lacks are drawn as its missing glyph.
+func (f *trueTypeFont) encode(text string, used map[uint16]rune) string {
+	var hex strings.Builder
+	hex.WriteByte('<')
+	for _, c := range text {
+		glyph := f.glyphs[c]
+		if glyph != 0 {
+			used[glyph] = c
+		}
+		fmt.Fprintf(&hex, "%04X", glyph)
+	}
+	hex.WriteByte('>')
+	return hex.String()
+}
+
+// objects returns the PDF objects of the font numbered from first: the Type0 font, its
+// descendant CIDFont, the font descriptor, the compressed font file and the ToUnicode map of
+// the glyphs used, which lets readers copy and search the text
+func (f *trueTypeFont) objects(first int, used map[uint16]rune) []string {
+	glyphs := make([]int, 0, len(used))
+	for glyph := range used {
+		glyphs = append(glyphs, int(glyph))
+	}
+	sort.Ints(glyphs)
+
+	var widths, mappings strings.Builder
+	for i, glyph := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", glyph, f.width(uint16(glyph)))
+		if i%100 == 0 {
+			if i > 0 {
+				mappings.WriteString("endbfchar\n")
+			}
+			fmt.Fprintf(&mappings, "%d beginbfchar\n", min(100, len(glyphs)-i))
+		}
+		fmt.Fprintf(&mappings, "<%04X> <", glyph)
+		for _, unit := range utf16.Encode([]rune{used[uint16(glyph)]}) {
+			fmt.Fprintf(&mappings, "%04X", unit)
+		}
+		mappings.WriteString(">\n")
+	}
+	if len(glyphs) > 0 {
+		mappings.WriteString("endbfchar\n")
+	}
+	toUnicode := "/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n" +
+		mappings.String() +
+		"endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend"
+
+	var file bytes.Buffer
+	zw := zlib.NewWriter(&file)
+	zw.Write(f.data)
+	zw.Close()
+
+	scale := func(v int) int { return v * 1000 / f.unitsPerEm }
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			f.name, first+1, first+4),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s "+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+			"/FontDescriptor %d 0 R /CIDToGIDMap /Identity /DW %d /W [%s] >>",
+			f.name, first+2, f.width(0), strings.TrimSpace(widths.String())),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 33 /FontBBox [%d %d %d %d] /ItalicAngle 0 "+
+			"/Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+			f.name, scale(f.bbox[0]), scale(f.bbox[1]), scale(f.bbox[2]), scale(f.bbox[3]),
+			scale(f.ascent), scale(f.descent), scale(f.ascent), first+3),
+		fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream",
+			file.Len(), len(f.data), file.String()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(toUnicode), toUnicode),
+	}
+}
@@ -0,0 +1,462 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterPropertyGroupRoutes registers region and portfolio management and roll-up routes
+func RegisterPropertyGroupRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+
+		auth.Group(func(read chi.Router) {
+			read.Use(middleware.RequireAnyRole("admin", "property_manager", "viewer"))
+
+			// ?type=region or ?type=portfolio limits the list to one level of the hierarchy
+			read.Get("/api/property-groups", handleGetPropertyGroups)
+			// ?type=region (default) or portfolio, ?group_id=, ?start_date= and ?end_date=
+			read.Get("/api/property-groups/rollup", handleGetPropertyGroupRollup)
+			read.Get("/api/property-groups/{id}", handleGetPropertyGroup)
+		})
+
+		auth.Group(func(manage chi.Router) {
+			manage.Use(middleware.RequireAnyRole("admin", "property_manager"))
+			manage.Use(scopedPropertyRoutes)
+			manage.Put("/api/properties/{id}/group", handleSetPropertyGroup)
+		})
+
+		auth.Group(func(admin chi.Router) {
+			admin.Use(middleware.RequireRole("admin"))
+			admin.Post("/api/property-groups", handleCreatePropertyGroup)
+			admin.Put("/api/property-groups/{id}", handleUpdatePropertyGroup)
+			admin.Delete("/api/property-groups/{id}", handleDeletePropertyGroup)
+			admin.Put("/api/property-groups/{id}/managers", handleSetPropertyGroupManagers)
+		})
+	})
+}
+
+// propertyGroupRequest is the request body for creating or updating a region or portfolio. A
+// portfolio's parent_id is the region it belongs to.
+type propertyGroupRequest struct {
+	ParentID    *int   `json:"parent_id"`
+	GroupType   string `json:"group_type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// toPropertyGroup converts the request to a property group
+func (req propertyGroupRequest) toPropertyGroup() *models.PropertyGroup {
+	g := &models.PropertyGroup{
+		GroupType:   req.GroupType,
+		Name:        req.Name,
+		Description: models.NullString(req.Description),
+	}
+	if req.ParentID != nil {
+		g.ParentID = sql.NullInt32{Int32: int32(*req.ParentID), Valid: true}
+	}
+	return g
+}
+
+// writePropertyGroupError maps property group errors to responses
+func writePropertyGroupError(w http.ResponseWriter, err error, notFound, failure string) {
+	var unknown *models.UnknownUsersError
+	switch {
+	case errors.As(err, &unknown):
+		http.Error(w, unknown.Error(), http.StatusUnprocessableEntity)
+	case err == models.ErrInvalidPropertyGroup, err == models.ErrNotPortfolio:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case err == models.ErrPropertyGroupInUse:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err == sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+// parseGroupID parses the optional ?group_id= filter, where "" means no filter
+func parseGroupID(value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(value)
+	return id, err == nil && id > 0
+}
+
+// requestPropertyScope returns the properties a list request covers: the ?group_id= filter
+// narrowed to the caller's managed properties. It writes the error response when ok is false.
+func requestPropertyScope(w http.ResponseWriter, r *http.Request) (filter models.PropertyFilter, ok bool) {
+	groupID, valid := parseGroupID(r.URL.Query().Get("group_id"))
+	if !valid {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return filter, false
+	}
+	user, _ := middleware.GetUserFromContext(r.Context())
+	filter, err := models.ScopeProperties(groupID, user)
+	if err != nil {
+		http.Error(w, "Failed to fetch property groups", http.StatusInternalServerError)
+		return filter, false
+	}
+	return filter, true
+}
+
+// requestListScope is requestPropertyScope narrowed further to the ?property_id= filter when one is given
+func requestListScope(w http.ResponseWriter, r *http.Request) (models.PropertyFilter, bool) {
+	filter, ok := requestPropertyScope(w, r)
+	if !ok {
+		return filter, false
+	}
+	if value := r.URL.Query().Get("property_id"); value != "" && value != "0" {
+		propertyID, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid property ID", http.StatusBadRequest)
+			return filter, false
+		}
+		filter = filter.Only(propertyID)
+	}
+	return filter, true
+}
+
+// requireUnitInScope reports whether a unit belongs to a property the caller manages, writing a
+// 404 with the notFound message when it does not
+func requireUnitInScope(w http.ResponseWriter, r *http.Request, unitID int, notFound string) bool {
+	propertyID, err := models.UnitPropertyID(unitID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, notFound, http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch unit", http.StatusInternalServerError)
+		return false
+	}
+	return requirePropertyInScope(w, r, propertyID, notFound)
+}
+
+// requirePropertyInScope reports whether the caller manages a property, writing a 404 with the
+// notFound message when they do not so records outside their regions and portfolios are not revealed
+func requirePropertyInScope(w http.ResponseWriter, r *http.Request, propertyID int, notFound string) bool {
+	user, _ := middleware.GetUserFromContext(r.Context())
+	filter, err := models.ScopeProperties(0, user)
+	if err != nil {
+		http.Error(w, "Failed to fetch property groups", http.StatusInternalServerError)
+		return false
+	}
+	if !filter.Allows(propertyID) {
+		http.Error(w, notFound, http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// isPropertyRoute reports whether a route pattern's {id} is a property ID
+func isPropertyRoute(pattern string) bool {
+	return pattern == "/properties/{id}" || strings.HasPrefix(pattern, "/api/properties/{id}")
+}
+
+// scopedPropertyRoutes rejects requests to /properties/{id} and /api/properties/{id}/... routes for properties outside
+// the caller's managed regions and portfolios. Use it after the user is loaded.
+func scopedPropertyRoutes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && isPropertyRoute(rctx.RoutePattern()) {
+			propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+			if err != nil {
+				http.Error(w, "Invalid property ID", http.StatusBadRequest)
+				return
+			}
+			if !requirePropertyInScope(w, r, propertyID, "Property not found") {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scopedRecordRoutes rejects requests to routes at or under pattern whose param names a record at a
+// property outside the caller's managed regions and portfolios. propertyOf returns a record's
+// property. Use it after the user is loaded.
+func scopedRecordRoutes(pattern, param string, propertyOf func(id int) (int, error), notFound string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rctx := chi.RouteContext(r.Context())
+			if rctx == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if route := rctx.RoutePattern(); route != pattern && !strings.HasPrefix(route, pattern+"/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, _ := middleware.GetUserFromContext(r.Context())
+			filter, err := models.ScopeProperties(0, user)
+			if err != nil {
+				http.Error(w, "Failed to fetch property groups", http.StatusInternalServerError)
+				return
+			}
+			if filter.Scoped {
+				id, err := strconv.Atoi(chi.URLParam(r, param))
+				if err != nil {
+					http.Error(w, "Invalid ID", http.StatusBadRequest)
+					return
+				}
+				propertyID, err := propertyOf(id)
+				if errors.Is(err, sql.ErrNoRows) || (err == nil && !filter.Allows(propertyID)) {
+					http.Error(w, notFound, http.StatusNotFound)
+					return
+				}
+				if err != nil {
+					http.Error(w, "Failed to check property access", http.StatusInternalServerError)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// propertyItself is the propertyOf for routes whose parameter is the property ID
+func propertyItself(id int) (int, error) {
+	return id, nil
+}
+
+// scopedMaintenanceRoutes scopes /api/maintenance/{id}/... routes by the request's property
+var scopedMaintenanceRoutes = scopedRecordRoutes("/api/maintenance/{id}", "id",
+	models.GetMaintenanceRequestPropertyID, "Maintenance request not found")
+
+func handleGetPropertyGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := models.GetPropertyGroups(r.URL.Query().Get("type"))
+	if err != nil {
+		http.Error(w, "Failed to fetch property groups", http.StatusInternalServerError)
+		return
+	}
+
+	if groups == nil {
+		groups = []models.PropertyGroup{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetPropertyGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property group ID", http.StatusBadRequest)
+		return
+	}
+
+	g, err := models.GetPropertyGroup(id)
+	if err != nil {
+		writePropertyGroupError(w, err, "Property group not found", "Failed to fetch property group")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(g); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCreatePropertyGroup(w http.ResponseWriter, r *http.Request) {
+	var req propertyGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	g := req.toPropertyGroup()
+	if err := models.CreatePropertyGroup(g); err != nil {
+		writePropertyGroupError(w, err, "Parent region not found", "Failed to save property group")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(g); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUpdatePropertyGroup renames a group or moves a portfolio to another region; group_type
+// is ignored since a group's type cannot change
+func handleUpdatePropertyGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property group ID", http.StatusBadRequest)
+		return
+	}
+
+	var req propertyGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	g := req.toPropertyGroup()
+	g.ID = id
+	if err := models.UpdatePropertyGroup(g); err != nil {
+		writePropertyGroupError(w, err, "Property group or parent region not found", "Failed to save property group")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(g); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeletePropertyGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property group ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeletePropertyGroup(id); err != nil {
+		writePropertyGroupError(w, err, "Property group not found", "Failed to delete property group")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetPropertyGroupManagers replaces the users scoped to a region or portfolio. Managers
+// assigned to any group only see the properties under their groups.
+func handleSetPropertyGroupManagers(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property group ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		UserIDs []int `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetPropertyGroupManagers(id, req.UserIDs); err != nil {
+		writePropertyGroupError(w, err, "Property group not found", "Failed to save managers")
+		return
+	}
+
+	g, err := models.GetPropertyGroup(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch property group", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(g); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSetPropertyGroup places a property in a portfolio, or removes it from its portfolio
+// when group_id is null
+func handleSetPropertyGroup(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		GroupID *int `json:"group_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var groupID sql.NullInt32
+	if req.GroupID != nil {
+		groupID = sql.NullInt32{Int32: int32(*req.GroupID), Valid: true}
+	}
+	if err := models.SetPropertyGroup(propertyID, groupID); err != nil {
+		writePropertyGroupError(w, err, "Property or portfolio not found", "Failed to save property group")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"property_id": propertyID, "group_id": req.GroupID}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetPropertyGroupRollup aggregates the portfolio comparison KPIs per region or portfolio,
+// over the properties the caller may see
+func handleGetPropertyGroupRollup(w http.ResponseWriter, r *http.Request) {
+	groupType := r.URL.Query().Get("type")
+	if groupType == "" {
+		groupType = models.PropertyGroupRegion
+	}
+	groupID, ok := parseGroupID(r.URL.Query().Get("group_id"))
+	if !ok {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	// Default to the last three months, as the portfolio comparison report does
+	endDate := time.Now()
+	startDate := models.PeriodStart(endDate).AddDate(0, -2, 0)
+	if value := r.URL.Query().Get("start_date"); value != "" {
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			startDate = parsed
+		}
+	}
+	if value := r.URL.Query().Get("end_date"); value != "" {
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			endDate = parsed
+		}
+	}
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	propertyIDs, scoped, err := models.PropertyScope(groupID, user)
+	if err != nil {
+		http.Error(w, "Failed to fetch property groups", http.StatusInternalServerError)
+		return
+	}
+
+	rollup, err := models.GetPropertyGroupRollup(groupType, startDate, endDate, propertyIDs, scoped)
+	if err != nil {
+		if err == models.ErrInvalidPropertyGroup {
+			http.Error(w, "type must be region or portfolio", http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to calculate roll-up", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	setRefreshHints(w, r, defaultWidgetRefreshSeconds, time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"group_type":   groupType,
+		"period_start": startDate.Format("2006-01-02"),
+		"period_end":   endDate.Format("2006-01-02"),
+		"groups":       rollup,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
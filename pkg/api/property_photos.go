@@ -0,0 +1,390 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/avatar"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/photo"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// maxPhotosPerUpload limits the number of files in one gallery upload
+const maxPhotosPerUpload = 10
+
+// maxPhotoUploadBytes limits a gallery upload request: the photos and the multipart framing
+const maxPhotoUploadBytes = maxPhotosPerUpload*photo.MaxUploadBytes + 1<<20
+
+// errTooManyPhotos stops reading an upload with more than maxPhotosPerUpload photos
+var errTooManyPhotos = errors.New("too many photos")
+
+// photoError is a photo in an upload that could not be processed
+type photoError struct {
+	filename string
+	err      error
+}
+
+func (e *photoError) Error() string { return e.filename + ": " + e.err.Error() }
+
+// writePhotoError maps a photo that could not be processed to a response
+func writePhotoError(w http.ResponseWriter, e *photoError) {
+	switch {
+	case errors.Is(e.err, avatar.ErrTooLarge):
+		http.Error(w, e.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(e.err, avatar.ErrUnsupportedFormat):
+		http.Error(w, e.Error(), http.StatusUnsupportedMediaType)
+	default:
+		http.Error(w, "Failed to process "+e.filename, http.StatusUnprocessableEntity)
+	}
+}
+
+// RegisterPropertyPhotoRoutes registers property and unit photo gallery routes
+func RegisterPropertyPhotoRoutes(r chi.Router) {
+	// Stable public links to a photo size, for the properties pages and listings
+	r.Get("/photos/{id}/{variant}", handleServePropertyPhoto)
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+
+		// ?unit_id= selects a unit's gallery instead of the property's own
+		auth.Get("/api/properties/{id}/photos", handleGetPropertyPhotos)
+		auth.With(middleware.LimitBody(maxPhotoUploadBytes)).Post("/api/properties/{id}/photos", handleUploadPropertyPhotos)
+		auth.Put("/api/properties/{id}/photos/order", handleReorderPropertyPhotos)
+
+		auth.Put("/api/photos/{id}", handleUpdatePropertyPhoto)
+		auth.Post("/api/photos/{id}/cover", handleSetCoverPhoto)
+		auth.Delete("/api/photos/{id}", handleDeletePropertyPhoto)
+	})
+}
+
+// parseUnitID parses an optional unit ID, where "" selects the property gallery
+func parseUnitID(value string) (sql.NullInt32, bool) {
+	if value == "" {
+		return sql.NullInt32{}, true
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return sql.NullInt32{}, false
+	}
+	return sql.NullInt32{Int32: int32(id), Valid: true}, true
+}
+
+// writeGalleryError maps gallery lookup errors to responses
+func writeGalleryError(w http.ResponseWriter, err error, action string) {
+	switch err {
+	case sql.ErrNoRows:
+		http.Error(w, "Property not found", http.StatusNotFound)
+	case models.ErrUnitNotInProperty, models.ErrPhotoOrderMismatch:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+func handleGetPropertyPhotos(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+	unitID, ok := parseUnitID(r.URL.Query().Get("unit_id"))
+	if !ok {
+		http.Error(w, "Invalid unit ID", http.StatusBadRequest)
+		return
+	}
+
+	photos, err := models.GetPropertyPhotos(propertyID, unitID)
+	if err != nil {
+		http.Error(w, "Failed to fetch photos", http.StatusInternalServerError)
+		return
+	}
+
+	if photos == nil {
+		photos = []models.PropertyPhoto{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(photos); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUploadPropertyPhotos accepts multipart "photos" files with optional "captions" in the same
+// order and an optional "unit_id", adding them to the end of the gallery
+func handleUploadPropertyPhotos(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	// Each photo is processed as it streams in, so only one is held unprocessed at a time. Every
+	// file is processed before any is stored, so a bad file rejects the whole upload.
+	var processed []*photo.Processed
+	var captions []string
+	var unitValue string
+	var totalBytes int64
+	err = streamMultipart(r, func(part *multipart.Part) error {
+		switch part.FormName() {
+		case "photos":
+			if len(processed) == maxPhotosPerUpload {
+				return errTooManyPhotos
+			}
+			data, err := readUploadedFile(part, photo.MaxUploadBytes)
+			if err != nil {
+				return err
+			}
+			p, err := photo.Process(data)
+			if err != nil {
+				return &photoError{filename: part.FileName(), err: err}
+			}
+			processed = append(processed, p)
+			for _, variant := range p.Variants {
+				totalBytes += int64(len(variant))
+			}
+		case "captions":
+			caption, err := readFormValue(part)
+			if err != nil {
+				return err
+			}
+			captions = append(captions, caption)
+		case "unit_id":
+			var err error
+			unitValue, err = readFormValue(part)
+			return err
+		}
+		return nil
+	})
+	var failed *photoError
+	switch {
+	case errors.As(err, &failed):
+		writePhotoError(w, failed)
+		return
+	case err == errTooManyPhotos || err == nil && len(processed) == 0:
+		http.Error(w, fmt.Sprintf("Upload between 1 and %d photos", maxPhotosPerUpload), http.StatusBadRequest)
+		return
+	case err != nil:
+		writeUploadError(w, err, fmt.Sprintf("Upload up to %d photos of at most 10MB each", maxPhotosPerUpload))
+		return
+	}
+	unitID, ok := parseUnitID(unitValue)
+	if !ok {
+		http.Error(w, "Invalid unit ID", http.StatusBadRequest)
+		return
+	}
+
+	if !requireQuota(w, r, models.UsageStorageBytes) {
+		return
+	}
+
+	var uploadedBy sql.NullInt32
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		uploadedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+
+	created := make([]models.PropertyPhoto, 0, len(processed))
+	for i, p := range processed {
+		urls := map[string]string{}
+		for _, v := range photo.Variants {
+			key := fmt.Sprintf("photos/properties/%d/%d-%d-%s.jpg", propertyID, time.Now().UnixNano(), i, v.Name)
+			url, err := storage.Default.Save(key, p.Variants[v.Name])
+			if err != nil {
+				deleteStoredFiles(urls)
+				http.Error(w, "Failed to store photo", http.StatusInternalServerError)
+				return
+			}
+			urls[v.Name] = url
+		}
+
+		record := models.PropertyPhoto{
+			PropertyID:   propertyID,
+			UnitID:       unitID,
+			Width:        p.Width,
+			Height:       p.Height,
+			FullURL:      urls["full"],
+			MediumURL:    urls["medium"],
+			ThumbnailURL: urls["thumbnail"],
+			UploadedBy:   uploadedBy,
+		}
+		if i < len(captions) {
+			record.Caption = models.NullString(strings.TrimSpace(captions[i]))
+		}
+		if err := models.CreatePropertyPhoto(&record); err != nil {
+			deleteStoredFiles(urls)
+			writeGalleryError(w, err, "save photo")
+			return
+		}
+		created = append(created, record)
+	}
+	recordUsage(r, models.UsageStorageBytes, totalBytes)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// deleteStoredFiles removes uploaded files by URL, logging failures, and returns the bytes freed
+func deleteStoredFiles(urls map[string]string) int64 {
+	var freed int64
+	for _, url := range urls {
+		key, ok := storage.Default.KeyForURL(url)
+		if !ok {
+			continue
+		}
+		size, err := storage.Default.Size(key)
+		if err == nil {
+			err = storage.Default.Delete(key)
+		}
+		if err != nil {
+			slog.Error("Failed to delete stored file", "key", key, "error", err)
+			continue
+		}
+		freed += size
+	}
+	return freed
+}
+
+func handleReorderPropertyPhotos(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		UnitID   *int  `json:"unit_id"`
+		PhotoIDs []int `json:"photo_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var unitID sql.NullInt32
+	if req.UnitID != nil {
+		unitID = sql.NullInt32{Int32: int32(*req.UnitID), Valid: true}
+	}
+
+	if err := models.ReorderPropertyPhotos(propertyID, unitID, req.PhotoIDs); err != nil {
+		writeGalleryError(w, err, "reorder photos")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleUpdatePropertyPhoto(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid photo ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Caption string `json:"caption"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.UpdatePhotoCaption(id, strings.TrimSpace(req.Caption)); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update photo", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleSetCoverPhoto(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid photo ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetCoverPhoto(id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to set cover photo", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDeletePropertyPhoto(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid photo ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := models.DeletePropertyPhoto(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete photo", http.StatusInternalServerError)
+		}
+		return
+	}
+	freed := deleteStoredFiles(map[string]string{
+		"full":      deleted.FullURL,
+		"medium":    deleted.MediumURL,
+		"thumbnail": deleted.ThumbnailURL,
+	})
+	releaseUsage(r, models.UsageStorageBytes, freed)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleServePropertyPhoto redirects to the stored file for a photo size
+func handleServePropertyPhoto(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid photo ID", http.StatusBadRequest)
+		return
+	}
+	variant := chi.URLParam(r, "variant")
+	if !photo.ValidVariant(variant) {
+		http.Error(w, "Size must be thumbnail, medium or full", http.StatusBadRequest)
+		return
+	}
+
+	p, err := models.GetPropertyPhoto(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch photo", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.Redirect(w, r, p.VariantURL(variant), http.StatusFound)
+}
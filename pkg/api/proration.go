@@ -0,0 +1,128 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterProrationRoutes registers the proration preview and lease rent schedule routes
+func RegisterProrationRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		// First and last month charges for a lease being created
+		auth.Post("/api/leases/proration-preview", handleProrationPreview)
+		auth.Get("/api/leases/{id}/rent-schedule", handleGetLeaseRentSchedule)
+	})
+}
+
+// defaultProrationConvention is the convention for new leases that do not choose one, set by
+// PRORATION_CONVENTION (default actual_days)
+func defaultProrationConvention() string {
+	if convention := os.Getenv("PRORATION_CONVENTION"); models.ValidProrationConvention(convention) {
+		return convention
+	}
+	return models.ProrationActualDays
+}
+
+// handleProrationPreview shows the prorated first and last month charges for proposed lease terms
+func handleProrationPreview(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		StartDate           string  `json:"start_date"` // YYYY-MM-DD
+		EndDate             string  `json:"end_date"`   // YYYY-MM-DD
+		MonthlyRent         float64 `json:"monthly_rent"`
+		ProrationConvention string  `json:"proration_convention"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		http.Error(w, "start_date must be in YYYY-MM-DD format", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil || end.Before(start) {
+		http.Error(w, "end_date must be in YYYY-MM-DD format and not before start_date", http.StatusBadRequest)
+		return
+	}
+	if req.MonthlyRent <= 0 {
+		http.Error(w, "monthly_rent must be a positive number", http.StatusBadRequest)
+		return
+	}
+	if req.ProrationConvention == "" {
+		req.ProrationConvention = defaultProrationConvention()
+	}
+	if !models.ValidProrationConvention(req.ProrationConvention) {
+		http.Error(w, models.ErrInvalidProrationConvention.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lease := models.ScheduledLease{
+		MonthlyRent:         req.MonthlyRent,
+		StartDate:           start,
+		EndDate:             end,
+		ProrationConvention: req.ProrationConvention,
+	}
+	charges := lease.Charges()
+
+	var total float64
+	for _, charge := range charges {
+		total += charge.AmountDue
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"proration_convention": req.ProrationConvention,
+		"first_month":          charges[0],
+		"last_month":           charges[len(charges)-1],
+		"months":               len(charges),
+		"total_rent":           total,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetLeaseRentSchedule lists the rent billed for each month of a lease, with prorated first
+// and last months and concessions applied
+func handleGetLeaseRentSchedule(w http.ResponseWriter, r *http.Request) {
+	leaseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid lease ID", http.StatusBadRequest)
+		return
+	}
+
+	lease, err := models.GetScheduledLease(leaseID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Lease not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch lease", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"lease_id":             lease.LeaseID,
+		"monthly_rent":         lease.MonthlyRent,
+		"proration_convention": lease.ProrationConvention,
+		"charges":              lease.Charges(),
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
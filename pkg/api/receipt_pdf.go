@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// GenerateDocumentPDF renders an HTML template to PDF with wkhtmltopdf when it is installed.
+// Otherwise it writes the plain text lines to a basic PDF so the document is still usable.
+func (g *PDFReportGenerator) GenerateDocumentPDF(tmpl *template.Template, data interface{}, lines []string) ([]byte, error) {
+	if !isWkhtmltopdfAvailable() {
+		return textPDF(lines), nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to generate HTML content: %w", err)
+	}
+	return g.convertWithWkhtmltopdf(buf.String())
+}
+
+// textPDF writes lines of text to a single-column PDF in the embedded document font, adding pages
+// as needed
+func textPDF(lines []string) []byte {
+	const linesPerPage = 48
+	const firstPage = 8 // Objects: 1 catalog, 2 pages, 3-7 font, then a page and content stream per page
+	strip := strings.NewReplacer("\r", "", "\n", " ")
+
+	var pages [][]string
+	for len(lines) > linesPerPage {
+		pages = append(pages, lines[:linesPerPage])
+		lines = lines[linesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	used := map[uint16]rune{}
+	var pageObjects []string
+	for i, page := range pages {
+		var stream strings.Builder
+		stream.WriteString("BT\n/F1 11 Tf\n14 TL\n50 750 Td\n")
+		for _, line := range page {
+			fmt.Fprintf(&stream, "%s Tj T*\n", documentFont.encode(strip.Replace(line), used))
+		}
+		stream.WriteString("ET")
+		pageObjects = append(pageObjects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources << /Font << /F1 3 0 R >> >> >>", firstPage+1+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", stream.Len(), stream.String()))
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+	}
+	objects = append(objects, documentFont.objects(3, used)...)
+	objects = append(objects, pageObjects...)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// receiptLines is the plain text form of a payment receipt
+func receiptLines(receipt *models.TenantPayment) []string {
+	lines := []string{
+		"Fire PMAAS - Payment Receipt",
+		"",
+		"Receipt number: " + receipt.ReceiptNumber,
+		"Payment date:   " + receipt.PaymentDate.Format("January 2, 2006"),
+		fmt.Sprintf("Amount paid:    $%.2f", receipt.Amount),
+	}
+	if receipt.PaymentMethod.Valid {
+		lines = append(lines, "Payment method: "+receipt.PaymentMethod.String)
+	}
+	lines = append(lines, "",
+		"Received from:  "+receipt.TenantName,
+		"Property:       "+receipt.PropertyName,
+		"Address:        "+receipt.PropertyAddress)
+	if receipt.UnitNumber.Valid {
+		lines = append(lines, "Unit:           "+receipt.UnitNumber.String)
+	}
+	lines = append(lines, "", "Issued "+time.Now().Format("January 2, 2006"))
+	return lines
+}
+
+// statementLines is the plain text form of a year-end rent statement
+func statementLines(statement *models.RentStatement) []string {
+	lines := []string{
+		fmt.Sprintf("Fire PMAAS - %d Rent Paid Statement", statement.Year),
+		"",
+		"Tenant: " + statement.TenantName,
+		"",
+		fmt.Sprintf("%-14s %-16s %-28s %12s", "Date", "Receipt", "Property", "Amount"),
+	}
+	for _, p := range statement.Payments {
+		property := p.PropertyName
+		if p.UnitNumber.Valid {
+			property += " " + p.UnitNumber.String
+		}
+		if runes := []rune(property); len(runes) > 28 {
+			property = string(runes[:28])
+		}
+		lines = append(lines, fmt.Sprintf("%-14s %-16s %-28s %12.2f",
+			p.PaymentDate.Format("2006-01-02"), p.ReceiptNumber, property, p.Amount))
+	}
+	lines = append(lines, "",
+		fmt.Sprintf("Total rent paid in %d: $%.2f", statement.Year, statement.Total),
+		"",
+		"Keep this statement for your tax records.")
+	return lines
+}
+
+// receiptTemplate renders a payment receipt for wkhtmltopdf
+var receiptTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Receipt {{.ReceiptNumber}}</title>
+    <style>
+        body { font-family: Arial, sans-serif; color: #333; padding: 20px; }
+        h1 { color: #1F2937; border-bottom: 2px solid #3B82F6; padding-bottom: 10px; }
+        table { border-collapse: collapse; width: 100%; }
+        td { padding: 8px 0; }
+        .label { font-weight: bold; width: 180px; }
+        .amount { font-size: 24px; font-weight: bold; }
+        .footer { margin-top: 40px; color: #6B7280; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <h1>Payment Receipt</h1>
+    <table>
+        <tr><td class="label">Receipt number</td><td>{{.ReceiptNumber}}</td></tr>
+        <tr><td class="label">Payment date</td><td>{{.PaymentDate.Format "January 2, 2006"}}</td></tr>
+        <tr><td class="label">Amount paid</td><td class="amount">${{printf "%.2f" .Amount}}</td></tr>
+        {{if .PaymentMethod.Valid}}<tr><td class="label">Payment method</td><td>{{.PaymentMethod.String}}</td></tr>{{end}}
+        <tr><td class="label">Received from</td><td>{{.TenantName}}</td></tr>
+        <tr><td class="label">Property</td><td>{{.PropertyName}}{{if .UnitNumber.Valid}}, {{.UnitNumber.String}}{{end}}<br>{{.PropertyAddress}}</td></tr>
+    </table>
+    <div class="footer">Fire PMAAS - Property Management as a Service</div>
+</body>
+</html>`))
+
+// statementTemplate renders a year-end rent statement for wkhtmltopdf
+var statementTemplate = template.Must(template.New("statement").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Year}} Rent Paid Statement</title>
+    <style>
+        body { font-family: Arial, sans-serif; color: #333; padding: 20px; }
+        h1 { color: #1F2937; border-bottom: 2px solid #3B82F6; padding-bottom: 10px; }
+        table { border-collapse: collapse; width: 100%; margin-top: 20px; }
+        th, td { border: 1px solid #D1D5DB; padding: 8px; text-align: left; }
+        th { background-color: #F9FAFB; }
+        .total td { font-weight: bold; }
+        .footer { margin-top: 40px; color: #6B7280; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <h1>{{.Year}} Rent Paid Statement</h1>
+    <p><strong>Tenant:</strong> {{.TenantName}}</p>
+    <table>
+        <thead><tr><th>Date</th><th>Receipt</th><th>Property</th><th>Amount</th></tr></thead>
+        <tbody>
+            {{range .Payments}}
+            <tr>
+                <td>{{.PaymentDate.Format "2006-01-02"}}</td>
+                <td>{{.ReceiptNumber}}</td>
+                <td>{{.PropertyName}}{{if .UnitNumber.Valid}}, {{.UnitNumber.String}}{{end}}</td>
+                <td>${{printf "%.2f" .Amount}}</td>
+            </tr>
+            {{end}}
+            <tr class="total"><td colspan="3">Total rent paid</td><td>${{printf "%.2f" .Total}}</td></tr>
+        </tbody>
+    </table>
+    <div class="footer">Keep this statement for your tax records. Fire PMAAS - Property Management as a Service</div>
+</body>
+</html>`))
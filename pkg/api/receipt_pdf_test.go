@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextPDF(t *testing.T) {
+	lines := make([]string, 60)
+	for i := range lines {
+		lines[i] = "Line (" + strconv.Itoa(i) + ")"
+	}
+	pdf := textPDF(lines)
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "/Count 2")
+	assert.Contains(t, string(pdf), documentFont.encode("Line (59)", map[uint16]rune{})+" Tj")
+
+	// startxref must point at the xref table
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	require.NotNil(t, m)
+	offset, err := strconv.Atoi(string(m[1]))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(pdf[offset:]), "xref\n"))
+}
+
+func TestTextPDFEmbedsUnicodeFont(t *testing.T) {
+	pdf := string(textPDF([]string{"Received from:  Zoë Ñúñez", "Адрес: ул. Ленина"}))
+
+	assert.Contains(t, pdf, "/Subtype /Type0 /BaseFont /DejaVuSansMono /Encoding /Identity-H")
+	assert.Contains(t, pdf, "/FontFile2 6 0 R")
+	// Each character is drawn with its own glyph and mapped back to Unicode for copying text
+	for _, c := range "ëÑúЛ" {
+		glyph := documentFont.glyphs[c]
+		require.NotZero(t, glyph, "font covers %q", c)
+		assert.Contains(t, pdf, fmt.Sprintf("<%04X> <%04X>", glyph, c))
+	}
+	assert.Contains(t, pdf, strings.Trim(documentFont.encode("Zoë Ñúñez", map[uint16]rune{}), "<>"))
+}
+
+func TestStatementLinesTruncateByCharacter(t *testing.T) {
+	lines := statementLines(&models.RentStatement{Year: 2025, TenantName: "Zoë", Payments: []models.TenantPayment{
+		{PropertyName: "Résidence Château-Élysée Montréal", Amount: 1200},
+	}})
+	assert.True(t, utf8.ValidString(lines[5]))
+	assert.Contains(t, lines[5], "Résidence Château-Élysée Mon ")
+}
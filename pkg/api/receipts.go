@@ -0,0 +1,232 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterReceiptRoutes registers payment receipt and year-end statement routes
+func RegisterReceiptRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/payments/{id}/receipt", handleGetPaymentReceipt)
+		auth.Post("/api/payments/{id}/receipt/email", handleEmailPaymentReceipt)
+		auth.Get("/api/tenants/{id}/statements/{year}", handleGetTenantStatement)
+	})
+
+	// Tenant portal
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("tenant"))
+
+		auth.Get("/api/portal/payments", handleGetPortalPayments)
+		auth.Get("/api/portal/payments/{id}/receipt", handleGetPortalPaymentReceipt)
+		auth.Get("/api/portal/statements/{year}", handleGetPortalStatement)
+	})
+}
+
+// receiptLink is the tenant portal download link included in receipt emails
+func receiptLink(paymentID int) string {
+	return fmt.Sprintf("%s/api/portal/payments/%d/receipt", appBaseURL(), paymentID)
+}
+
+// loadReceipt fetches the receipt for the payment in the id URL parameter, writing an error
+// response if it is not available. A non-zero tenantID restricts it to that tenant's payments;
+// other payments are reported as missing.
+func loadReceipt(w http.ResponseWriter, r *http.Request, tenantID int) (*models.TenantPayment, bool) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	receipt, err := models.GetPaymentReceipt(paymentID, tenantID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			http.Error(w, "Payment not found", http.StatusNotFound)
+		case models.ErrReceiptUnavailable:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, "Failed to fetch payment", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return receipt, true
+}
+
+// statementYear parses the year URL parameter
+func statementYear(w http.ResponseWriter, r *http.Request) (int, bool) {
+	year, err := strconv.Atoi(chi.URLParam(r, "year"))
+	if err != nil || year < 1900 || year > time.Now().Year() {
+		http.Error(w, "Invalid statement year", http.StatusBadRequest)
+		return 0, false
+	}
+	return year, true
+}
+
+// writePDF sends a generated PDF as a download
+func writePDF(w http.ResponseWriter, filename string, data []byte) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", sanitizeFilename(filename)))
+	w.Write(data)
+}
+
+func writeReceiptPDF(w http.ResponseWriter, receipt *models.TenantPayment) {
+	data, err := NewPDFReportGenerator().GenerateDocumentPDF(receiptTemplate, receipt, receiptLines(receipt))
+	if err != nil {
+		http.Error(w, "Failed to generate receipt", http.StatusInternalServerError)
+		return
+	}
+	writePDF(w, "receipt_"+receipt.ReceiptNumber+".pdf", data)
+}
+
+func writeStatementPDF(w http.ResponseWriter, tenant *models.Tenant, year int) {
+	statement, err := models.GetRentStatement(tenant, year)
+	if err != nil {
+		http.Error(w, "Failed to fetch payments", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := NewPDFReportGenerator().GenerateDocumentPDF(statementTemplate, statement, statementLines(statement))
+	if err != nil {
+		http.Error(w, "Failed to generate statement", http.StatusInternalServerError)
+		return
+	}
+	writePDF(w, fmt.Sprintf("rent_statement_%d_%d.pdf", year, tenant.ID), data)
+}
+
+func handleGetPaymentReceipt(w http.ResponseWriter, r *http.Request) {
+	receipt, ok := loadReceipt(w, r, 0)
+	if !ok {
+		return
+	}
+	writeReceiptPDF(w, receipt)
+}
+
+func handleEmailPaymentReceipt(w http.ResponseWriter, r *http.Request) {
+	receipt, ok := loadReceipt(w, r, 0)
+	if !ok {
+		return
+	}
+
+	if err := models.EmailPaymentReceipt(receipt, receiptLink(receipt.PaymentID)); err != nil {
+		http.Error(w, "Failed to queue receipt email", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// emailReceiptForPayment queues the receipt email for a newly recorded payment, logging failures
+func emailReceiptForPayment(paymentID int) {
+	receipt, err := models.GetPaymentReceipt(paymentID, 0)
+	if err == models.ErrReceiptUnavailable {
+		return
+	}
+	if err == nil {
+		err = models.EmailPaymentReceipt(receipt, receiptLink(paymentID))
+	}
+	if err != nil {
+		slog.Error("Failed to email receipt", "payment_id", paymentID, "error", err)
+	}
+}
+
+func handleGetTenantStatement(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid tenant ID", http.StatusBadRequest)
+		return
+	}
+	year, ok := statementYear(w, r)
+	if !ok {
+		return
+	}
+
+	tenant, err := models.GetTenantByID(tenantID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch tenant", http.StatusInternalServerError)
+		}
+		return
+	}
+	writeStatementPDF(w, tenant, year)
+}
+
+func handleGetPortalPayments(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	payments, err := models.GetTenantPayments(tenant.ID, time.Time{}, time.Time{})
+	if err != nil {
+		http.Error(w, "Failed to fetch payments", http.StatusInternalServerError)
+		return
+	}
+
+	// Newest first, each completed payment with its receipt download link
+	response := make([]map[string]interface{}, 0, len(payments))
+	for i := len(payments) - 1; i >= 0; i-- {
+		p := payments[i]
+		item := map[string]interface{}{
+			"payment_id":     p.PaymentID,
+			"amount":         p.Amount,
+			"payment_date":   p.PaymentDate.Format("2006-01-02"),
+			"payment_method": p.PaymentMethod.String,
+			"status":         p.Status,
+			"property_name":  p.PropertyName,
+			"unit_number":    p.UnitNumber.String,
+		}
+		if p.Status == "completed" {
+			item["receipt_number"] = p.ReceiptNumber
+			item["receipt_url"] = fmt.Sprintf("/api/portal/payments/%d/receipt", p.PaymentID)
+		}
+		response = append(response, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetPortalPaymentReceipt(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+	// Tenants only see their own receipts; other payments are reported as missing
+	receipt, ok := loadReceipt(w, r, tenant.ID)
+	if !ok {
+		return
+	}
+	writeReceiptPDF(w, receipt)
+}
+
+func handleGetPortalStatement(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+	year, ok := statementYear(w, r)
+	if !ok {
+		return
+	}
+	writeStatementPDF(w, tenant, year)
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterRenewalOfferRoutes registers lease renewal offer routes for managers and the tenant portal
+func RegisterRenewalOfferRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		// Offers are also sent automatically ahead of lease expiry
+		auth.Post("/api/renewal-offers/generate", handleGenerateRenewalOffers)
+		auth.Get("/api/renewal-offers", handleGetRenewalOffers)
+		auth.Get("/api/renewal-offers/{id}", handleGetRenewalOffer)
+		auth.Post("/api/renewal-offers/{id}/withdraw", handleWithdrawRenewalOffer)
+	})
+
+	// Tenant portal
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("tenant"))
+
+		auth.Get("/api/portal/renewal-offers", handleGetPortalRenewalOffers)
+		auth.Post("/api/portal/renewal-offers/{id}/accept", handleAcceptRenewalOffer)
+		auth.Post("/api/portal/renewal-offers/{id}/decline", handleDeclineRenewalOffer)
+	})
+}
+
+// writeRenewalOfferError maps renewal offer errors to responses
+func writeRenewalOfferError(w http.ResponseWriter, err error, failure string) {
+	switch err {
+	case models.ErrRenewalOfferClosed:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case sql.ErrNoRows:
+		http.Error(w, "Renewal offer not found", http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+func handleGenerateRenewalOffers(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		PropertyID   int `json:"property_id"`
+		WithinDays   int `json:"within_days"`
+		ResponseDays int `json:"response_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		// Empty body sends offers for all properties
+		req.PropertyID = 0
+	}
+	if req.WithinDays <= 0 || req.WithinDays > 365 {
+		req.WithinDays = 90
+	}
+	if req.ResponseDays <= 0 || req.ResponseDays > 90 {
+		req.ResponseDays = 30
+	}
+
+	createdBy := sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	offers, err := models.GenerateRenewalOffers(req.PropertyID, req.WithinDays, req.ResponseDays, createdBy, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to send renewal offers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(offers); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetRenewalOffers(w http.ResponseWriter, r *http.Request) {
+	offers, err := models.GetRenewalOffers(r.URL.Query().Get("status"), 0)
+	if err != nil {
+		http.Error(w, "Failed to fetch renewal offers", http.StatusInternalServerError)
+		return
+	}
+
+	if offers == nil {
+		offers = []models.RenewalOffer{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(offers); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetRenewalOffer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid renewal offer ID", http.StatusBadRequest)
+		return
+	}
+
+	offer, err := models.GetRenewalOffer(id)
+	if err != nil {
+		writeRenewalOfferError(w, err, "Failed to fetch renewal offer")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(offer); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleWithdrawRenewalOffer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid renewal offer ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.WithdrawRenewalOffer(id, time.Now()); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Open renewal offer not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to withdraw renewal offer", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetPortalRenewalOffers lists the tenant's offers and records that they have seen the open ones
+func handleGetPortalRenewalOffers(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.MarkRenewalOffersViewed(tenant.ID, time.Now()); err != nil {
+		http.Error(w, "Failed to update renewal offers", http.StatusInternalServerError)
+		return
+	}
+
+	offers, err := models.GetRenewalOffers(r.URL.Query().Get("status"), tenant.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch renewal offers", http.StatusInternalServerError)
+		return
+	}
+
+	if offers == nil {
+		offers = []models.RenewalOffer{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(offers); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleAcceptRenewalOffer accepts an offer and returns it with the new pending renewal lease
+func handleAcceptRenewalOffer(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid renewal offer ID", http.StatusBadRequest)
+		return
+	}
+
+	offer, err := models.AcceptRenewalOffer(id, tenant.ID, time.Now())
+	if err != nil {
+		writeRenewalOfferError(w, err, "Failed to accept renewal offer")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(offer); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeclineRenewalOffer(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := portalTenant(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid renewal offer ID", http.StatusBadRequest)
+		return
+	}
+
+	// The reason is optional
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	if err := models.DeclineRenewalOffer(id, tenant.ID, req.Reason, time.Now()); err != nil {
+		writeRenewalOfferError(w, err, "Failed to decline renewal offer")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterRenovationRoutes registers renovation project and before/after comparison routes
+func RegisterRenovationRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+		auth.Use(scopedRecordRoutes("/api/renovations/{id}", "id", renovationPropertyID, "Renovation not found"))
+
+		auth.Get("/api/properties/{id}/renovations", handleGetRenovations)
+		auth.Post("/api/properties/{id}/renovations", handleCreateRenovation)
+		auth.Get("/api/renovations/{id}", handleGetRenovation)
+		auth.Put("/api/renovations/{id}", handleUpdateRenovation)
+		auth.Delete("/api/renovations/{id}", handleDeleteRenovation)
+		auth.Get("/api/renovations/{id}/comparison", handleGetRenovationComparison)
+	})
+}
+
+// renovationRequest is the request body for creating or updating a renovation project
+type renovationRequest struct {
+	UnitID     *int     `json:"unit_id"`
+	Name       string   `json:"name"`
+	Scope      string   `json:"scope"`
+	Budget     float64  `json:"budget"`
+	ActualCost *float64 `json:"actual_cost"`
+	StartDate  string   `json:"start_date"`
+	EndDate    string   `json:"end_date"`
+}
+
+// toRenovation converts the request to a renovation project. Dates are YYYY-MM-DD.
+func (req renovationRequest) toRenovation() (*models.RenovationProject, error) {
+	p := &models.RenovationProject{
+		Name:   req.Name,
+		Scope:  models.NullString(req.Scope),
+		Budget: req.Budget,
+	}
+	if req.UnitID != nil {
+		p.UnitID = sql.NullInt32{Int32: int32(*req.UnitID), Valid: true}
+	}
+	if req.ActualCost != nil {
+		p.ActualCost = sql.NullFloat64{Float64: *req.ActualCost, Valid: true}
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	p.StartDate = start
+	if req.EndDate != "" {
+		end, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		p.EndDate = sql.NullTime{Time: end, Valid: true}
+	}
+	return p, nil
+}
+
+// writeRenovationError maps renovation errors to responses
+func writeRenovationError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch err {
+	case models.ErrInvalidRenovation, models.ErrRenovationUnit, models.ErrRenovationNotComplete:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+// renovationPropertyID returns the property a renovation is at
+func renovationPropertyID(id int) (int, error) {
+	p, err := models.GetRenovationProject(id)
+	if err != nil {
+		return 0, err
+	}
+	return p.PropertyID, nil
+}
+
+func handleGetRenovations(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	projects, err := models.GetRenovationProjects(propertyID)
+	if err != nil {
+		http.Error(w, "Failed to fetch renovations", http.StatusInternalServerError)
+		return
+	}
+
+	if projects == nil {
+		projects = []models.RenovationProject{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(projects); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleCreateRenovation(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req renovationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	p, err := req.toRenovation()
+	if err != nil {
+		http.Error(w, "Dates must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	p.PropertyID = propertyID
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		p.CreatedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+	if err := models.CreateRenovationProject(p); err != nil {
+		writeRenovationError(w, err, "Property or unit not found", "Failed to save renovation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetRenovation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid renovation ID", http.StatusBadRequest)
+		return
+	}
+
+	p, err := models.GetRenovationProject(id)
+	if err != nil {
+		writeRenovationError(w, err, "Renovation not found", "Failed to fetch renovation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleUpdateRenovation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid renovation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req renovationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	p, err := req.toRenovation()
+	if err != nil {
+		http.Error(w, "Dates must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	p.ID = id
+	if err := models.UpdateRenovationProject(p); err != nil {
+		writeRenovationError(w, err, "Renovation or unit not found", "Failed to save renovation")
+		return
+	}
+
+	updated, err := models.GetRenovationProject(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch renovation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteRenovation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid renovation ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteRenovationProject(id); err != nil {
+		writeRenovationError(w, err, "Renovation not found", "Failed to delete renovation")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetRenovationComparison compares a completed renovation over window_months (default 12)
+// before and after it, as of as_of (default today)
+func handleGetRenovationComparison(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid renovation ID", http.StatusBadRequest)
+		return
+	}
+
+	months := models.DefaultRenovationWindowMonths
+	if value := r.URL.Query().Get("window_months"); value != "" {
+		months, err = strconv.Atoi(value)
+		if err != nil || months < 1 || months > 60 {
+			http.Error(w, "window_months must be between 1 and 60", http.StatusBadRequest)
+			return
+		}
+	}
+	asOf := todayUTC()
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		asOf, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Invalid as_of date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	comparison, err := models.GetRenovationComparison(id, months, asOf)
+	if err != nil {
+		writeRenovationError(w, err, "Renovation not found", "Failed to compare renovation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(comparison); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterRentIncreaseRoutes registers rent increase planning routes
+func RegisterRentIncreaseRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		// Market rent per unit
+		auth.Put("/api/units/{id}/market-rent", handleSetUnitMarketRent)
+
+		// Caps (default and per-property rent-control rules)
+		auth.Get("/api/rent-increases/rules", handleGetRentIncreaseRules)
+		auth.Put("/api/rent-increases/rules", handleSaveRentIncreaseRule)
+
+		// Review list
+		auth.Post("/api/rent-increases/proposals/generate", handleGenerateRentIncreaseProposals)
+		auth.Get("/api/rent-increases/proposals", handleGetRentIncreaseProposals)
+		auth.Post("/api/rent-increases/proposals/{id}/approve", handleApproveRentIncreaseProposal)
+		auth.Post("/api/rent-increases/proposals/{id}/reject", handleRejectRentIncreaseProposal)
+
+		// Create renewal leases for approved proposals
+		auth.Post("/api/rent-increases/apply", handleApplyRentIncreases)
+	})
+}
+
+func handleSetUnitMarketRent(w http.ResponseWriter, r *http.Request) {
+	unitID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid unit ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		MarketRent float64 `json:"market_rent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MarketRent <= 0 {
+		http.Error(w, "market_rent must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetUnitMarketRent(unitID, req.MarketRent); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unit not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update market rent", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetRentIncreaseRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := models.GetRentIncreaseRules()
+	if err != nil {
+		http.Error(w, "Failed to fetch rent increase rules", http.StatusInternalServerError)
+		return
+	}
+
+	if rules == nil {
+		rules = []models.RentIncreaseRule{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleSaveRentIncreaseRule(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyID        *int     `json:"property_id"` // Omit for the default rule
+		Jurisdiction      string   `json:"jurisdiction"`
+		MaxPercentPerYear *float64 `json:"max_percent_per_year"`
+		MaxAmount         *float64 `json:"max_amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rule := &models.RentIncreaseRule{Jurisdiction: models.NullString(req.Jurisdiction)}
+	if req.PropertyID != nil {
+		rule.PropertyID = sql.NullInt32{Int32: int32(*req.PropertyID), Valid: true}
+	}
+	if req.MaxPercentPerYear != nil {
+		if *req.MaxPercentPerYear < 0 {
+			http.Error(w, "max_percent_per_year must not be negative", http.StatusBadRequest)
+			return
+		}
+		rule.MaxPercentPerYear = sql.NullFloat64{Float64: *req.MaxPercentPerYear, Valid: true}
+	}
+	if req.MaxAmount != nil {
+		if *req.MaxAmount < 0 {
+			http.Error(w, "max_amount must not be negative", http.StatusBadRequest)
+			return
+		}
+		rule.MaxAmount = sql.NullFloat64{Float64: *req.MaxAmount, Valid: true}
+	}
+
+	if err := models.SaveRentIncreaseRule(rule); err != nil {
+		http.Error(w, "Failed to save rent increase rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGenerateRentIncreaseProposals(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyID int `json:"property_id"`
+		WithinDays int `json:"within_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		// Empty body generates proposals for all properties
+		req.PropertyID = 0
+	}
+	if req.WithinDays <= 0 || req.WithinDays > 365 {
+		req.WithinDays = 90
+	}
+
+	proposals, err := models.GenerateRentIncreaseProposals(req.PropertyID, req.WithinDays)
+	if err != nil {
+		http.Error(w, "Failed to generate rent increase proposals", http.StatusInternalServerError)
+		return
+	}
+
+	if proposals == nil {
+		proposals = []models.RentIncreaseProposal{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(proposals); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetRentIncreaseProposals(w http.ResponseWriter, r *http.Request) {
+	proposals, err := models.GetRentIncreaseProposals(r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, "Failed to fetch rent increase proposals", http.StatusInternalServerError)
+		return
+	}
+
+	if proposals == nil {
+		proposals = []models.RentIncreaseProposal{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(proposals); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleApproveRentIncreaseProposal(w http.ResponseWriter, r *http.Request) {
+	reviewRentIncreaseProposal(w, r, true)
+}
+
+func handleRejectRentIncreaseProposal(w http.ResponseWriter, r *http.Request) {
+	reviewRentIncreaseProposal(w, r, false)
+}
+
+// reviewRentIncreaseProposal records a manager's decision on a proposal
+func reviewRentIncreaseProposal(w http.ResponseWriter, r *http.Request, approve bool) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	proposalID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid proposal ID", http.StatusBadRequest)
+		return
+	}
+
+	// Approvals may override the calculated rent
+	var req struct {
+		ProposedRent *float64 `json:"proposed_rent"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.ProposedRent != nil && (*req.ProposedRent <= 0 || !approve) {
+		http.Error(w, "proposed_rent must be positive and is only allowed when approving", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.ReviewRentIncreaseProposal(proposalID, approve, req.ProposedRent, user.ID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Pending proposal not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to review proposal", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleApplyRentIncreases(w http.ResponseWriter, r *http.Request) {
+	applied, err := models.ApplyApprovedRentIncreases()
+	if err != nil {
+		http.Error(w, "Failed to apply rent increases", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"applied": applied}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterReportConfidentialityRoutes registers report confidentiality levels and the audit trail
+// of a report's exports, both for the report's owner and admins
+func RegisterReportConfidentialityRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+
+		auth.Put("/api/reports/{id}/confidentiality", handleSetReportConfidentiality)
+		auth.Get("/api/reports/{id}/exports", handleGetReportExports)
+	})
+}
+
+// ownedReport loads the report named in the URL, writing an error unless the user owns it or is an
+// admin
+func ownedReport(w http.ResponseWriter, r *http.Request) (*models.CustomReport, bool) {
+	reportID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	report, err := models.GetCustomReportByID(reportID)
+	if err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return nil, false
+	}
+	if report.CreatedBy != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return nil, false
+	}
+	return report, true
+}
+
+// handleSetReportConfidentiality sets the level a report's exports are watermarked with
+func handleSetReportConfidentiality(w http.ResponseWriter, r *http.Request) {
+	report, ok := ownedReport(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Confidentiality string `json:"confidentiality"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetReportConfidentiality(report.ID, req.Confidentiality); err != nil {
+		switch err {
+		case models.ErrInvalidConfidentiality:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case sql.ErrNoRows:
+			http.Error(w, "Report not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to set confidentiality", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	report, err := models.GetCustomReportByID(report.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetReportExports lists who exported a report, when, how and in which format, newest first
+func handleGetReportExports(w http.ResponseWriter, r *http.Request) {
+	report, ok := ownedReport(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := models.GetAuditLog("report", report.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch exports", http.StatusInternalServerError)
+		return
+	}
+
+	exports := []models.AuditEntry{}
+	for _, entry := range entries {
+		if entry.Action == models.AuditReportExported {
+			exports = append(exports, entry)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(exports); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterReportRowLimitRoutes registers the routes managing how many rows a report may return to
+// users of each role
+func RegisterReportRowLimitRoutes(r chi.Router) {
+	r.Group(func(admin chi.Router) {
+		admin.Use(middleware.LoadUserFromToken)
+		admin.Use(middleware.RequireLogin)
+		admin.Use(middleware.RequireRole("admin"))
+
+		admin.Get("/api/report-row-limits", handleGetReportRowLimits)
+		admin.Put("/api/report-row-limits", handleSetReportRowLimit)
+		admin.Delete("/api/report-row-limits", handleDeleteReportRowLimit)
+	})
+}
+
+// reportTooLargeResponse is the warning returned in place of a report over the caller's row limit
+type reportTooLargeResponse struct {
+	Warning string `json:"warning"`
+	Message string `json:"message"`
+	*models.ReportTooLargeError
+}
+
+// writeReportExecutionError maps report execution errors to responses. A report over the caller's
+// row limit is answered with a 422 warning listing the aggregations they can run instead, or
+// ignore_row_limit to run it anyway.
+func writeReportExecutionError(w http.ResponseWriter, err error) {
+	var tooLarge *models.ReportTooLargeError
+	switch {
+	case errors.As(err, &tooLarge):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(reportTooLargeResponse{
+			Warning: "row_limit_exceeded",
+			Message: fmt.Sprintf("This report would return about %d rows, more than your limit of %d. "+
+				"Run it with one of the suggested group_by parameters to aggregate it, or with %s to return every row.",
+				tooLarge.EstimatedRows, tooLarge.RowLimit, models.ReportParamIgnoreRowLimit),
+			ReportTooLargeError: tooLarge,
+		})
+	case errors.Is(err, models.ErrUnknownGroupByColumn):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fmt.Sprintf("Failed to execute report: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func handleGetReportRowLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := models.GetReportRowLimits()
+	if err != nil {
+		http.Error(w, "Failed to fetch report row limits", http.StatusInternalServerError)
+		return
+	}
+
+	if limits == nil {
+		limits = []models.ReportRowLimit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"default_max_rows": models.DefaultReportRowLimit,
+		"limits":           limits,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSetReportRowLimit sets the row limit of a role: {"role": "viewer", "max_rows": 5000}.
+// A max_rows of 0 is unlimited.
+func handleSetReportRowLimit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role    string `json:"role"`
+		MaxRows *int   `json:"max_rows"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MaxRows == nil {
+		http.Error(w, "max_rows is required", http.StatusBadRequest)
+		return
+	}
+
+	roleID, _, message, status := roleDefaultScope(req.Role, nil)
+	if message != "" {
+		http.Error(w, message, status)
+		return
+	}
+
+	var updatedBy sql.NullInt32
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		updatedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+	if err := models.SetReportRowLimit(roleID, *req.MaxRows, updatedBy); err != nil {
+		if err == models.ErrInvalidReportRowLimit {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to save report row limit", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteReportRowLimit returns the role named by the role query parameter to the default
+// row limit
+func handleDeleteReportRowLimit(w http.ResponseWriter, r *http.Request) {
+	roleID, _, message, status := roleDefaultScope(r.URL.Query().Get("role"), nil)
+	if message != "" {
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := models.DeleteReportRowLimit(roleID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Role has no row limit configured", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete report row limit", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
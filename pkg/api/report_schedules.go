@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// RegisterReportScheduleRoutes registers the output of scheduled report runs and the admin health
+// view of scheduled report deliveries
+func RegisterReportScheduleRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+
+		auth.Get("/api/reports/{id}/scheduled-runs", handleGetScheduledReportRuns)
+		auth.Get("/api/report-executions/{id}/file", handleDownloadReportExecutionFile)
+	})
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("admin"))
+
+		auth.Get("/api/admin/report-schedules/failing", handleGetFailingReportSchedules)
+		auth.Get("/api/admin/report-schedules/{type}/{id}/failures", handleGetReportScheduleFailures)
+	})
+}
+
+// handleGetFailingReportSchedules lists the schedules whose last attempt failed, counting their
+// failed attempts over the last days days (default 7)
+func handleGetFailingReportSchedules(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if value := r.URL.Query().Get("days"); value != "" {
+		d, err := strconv.Atoi(value)
+		if err != nil || d < 1 || d > 90 {
+			http.Error(w, "days must be between 1 and 90", http.StatusBadRequest)
+			return
+		}
+		days = d
+	}
+
+	schedules, err := models.GetFailingReportSchedules(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		http.Error(w, "Failed to fetch failing schedules", http.StatusInternalServerError)
+		return
+	}
+
+	if schedules == nil {
+		schedules = []models.FailingReportSchedule{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(schedules); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetReportScheduleFailures lists the most recent failed attempts of one schedule
+func handleGetReportScheduleFailures(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 500 {
+			limit = l
+		}
+	}
+
+	failures, err := models.GetReportScheduleFailures(chi.URLParam(r, "type"), id, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch schedule failures", http.StatusInternalServerError)
+		return
+	}
+
+	if failures == nil {
+		failures = []models.ReportScheduleFailure{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(failures); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// canReadScheduledOutput reports whether a user may read a report's stored scheduled output. The
+// output is rendered as the report's creator sees it, so only they and admins may.
+func canReadScheduledOutput(report *models.CustomReport, user *models.User) bool {
+	return report.CreatedBy == user.ID || user.HasRole("admin")
+}
+
+// handleGetScheduledReportRuns lists a report's most recent scheduled runs with stored output
+func handleGetScheduledReportRuns(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := models.GetCustomReportByID(reportID)
+	if err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if !canReadScheduledOutput(report, user) {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 500 {
+			limit = l
+		}
+	}
+
+	executions, err := models.GetStoredReportExecutions(reportID, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch scheduled runs", http.StatusInternalServerError)
+		return
+	}
+
+	if executions == nil {
+		executions = []models.ReportExecution{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(executions); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleDownloadReportExecutionFile downloads the stored output of a scheduled run. PDF and Excel
+// output of a confidential report is rendered again from the run's snapshot so the watermark names
+// the user downloading it rather than the schedule's owner. Every download is audited.
+func handleDownloadReportExecutionFile(w http.ResponseWriter, r *http.Request) {
+	executionID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid execution ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	execution, err := models.GetReportExecutionByID(executionID)
+	if err != nil || !execution.FilePath.Valid {
+		http.Error(w, "Execution file not found", http.StatusNotFound)
+		return
+	}
+	report, err := models.GetCustomReportByID(execution.ReportID)
+	if err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if !canReadScheduledOutput(report, user) {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	var data []byte
+	if report.IsConfidential() && (execution.OutputFormat == "pdf" || execution.OutputFormat == "excel") {
+		if execution.Snapshot == nil {
+			http.Error(w, "The output of this confidential report cannot be watermarked for you", http.StatusConflict)
+			return
+		}
+		file, err := RenderReportExport(report, execution.OutputFormat, execution.Snapshot, requestPreferences(r), user)
+		if err != nil {
+			http.Error(w, "Failed to render execution file", http.StatusInternalServerError)
+			return
+		}
+		data = file.Data
+	} else if data, err = storage.Reports.Load(execution.FilePath.String); err != nil {
+		http.Error(w, "Execution file not found", http.StatusNotFound)
+		return
+	}
+
+	if err := models.RecordReportExport(report, user, execution.OutputFormat, "download", len(data)); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to audit report export", "report_id", report.ID, "error", err)
+	}
+
+	contentType := "application/octet-stream"
+	if format, ok := exportFormats[execution.OutputFormat]; ok {
+		contentType = format.contentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", path.Base(execution.FilePath.String)))
+	w.Write(data)
+}
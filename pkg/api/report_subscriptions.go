@@ -0,0 +1,250 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterReportSubscriptionRoutes registers routes for subscribing to reports owned by others
+func RegisterReportSubscriptionRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+
+		auth.Get("/api/reports/{id}/subscriptions", handleGetReportSubscriptions)
+		auth.Post("/api/reports/{id}/subscriptions", handleCreateReportSubscription)
+		auth.Get("/api/report-subscriptions", handleGetMyReportSubscriptions)
+		auth.Put("/api/report-subscriptions/{id}", handleUpdateReportSubscription)
+		auth.Delete("/api/report-subscriptions/{id}", handleDeleteReportSubscription)
+	})
+}
+
+// writeReportSubscriptionError maps report subscription errors to responses
+func writeReportSubscriptionError(w http.ResponseWriter, err error, failure string) {
+	switch err {
+	case models.ErrInvalidSubscription, models.ErrInvalidTimezone:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case models.ErrDuplicateSubscription:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case sql.ErrNoRows:
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+// handleGetReportSubscriptions lists a report's subscriptions: all of them for the report's owner
+// and admins, otherwise only the caller's own
+func handleGetReportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := models.GetCustomReportByID(reportID)
+	if err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
+	subscriptions, err := models.GetReportSubscriptions(reportID)
+	if err != nil {
+		http.Error(w, "Failed to fetch subscriptions", http.StatusInternalServerError)
+		return
+	}
+
+	visible := []models.ReportSubscription{}
+	for _, s := range subscriptions {
+		if report.CreatedBy == user.ID || user.HasRole("admin") || s.UserID == user.ID {
+			visible = append(visible, s)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(visible); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleCreateReportSubscription subscribes the caller to a report they can see, delivered by
+// email in the chosen format and frequency whether or not the report itself is scheduled. Runs
+// are at 07:00 in the chosen time zone, or the caller's organization's when none is given.
+func handleCreateReportSubscription(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		Format    string `json:"format"`
+		Frequency string `json:"frequency"`
+		Timezone  string `json:"timezone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Format == "" {
+		req.Format = "pdf"
+	}
+
+	report, err := models.GetCustomReportByID(reportID)
+	if err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if !models.CanSubscribeToReport(report, user) {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	subscription := &models.ReportSubscription{
+		ReportID:     reportID,
+		UserID:       user.ID,
+		OutputFormat: req.Format,
+		Frequency:    req.Frequency,
+		Timezone:     models.NullString(req.Timezone),
+		Email:        user.Email,
+	}
+	if err := models.CreateReportSubscription(subscription, time.Now()); err != nil {
+		writeReportSubscriptionError(w, err, "Failed to create subscription")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(subscription); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetMyReportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	subscriptions, err := models.GetUserReportSubscriptions(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch subscriptions", http.StatusInternalServerError)
+		return
+	}
+
+	if subscriptions == nil {
+		subscriptions = []models.ReportSubscription{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(subscriptions); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// subscriptionForCaller loads a subscription the caller may manage: their own, or any for admins.
+// It writes the error response and returns nil otherwise.
+func subscriptionForCaller(w http.ResponseWriter, r *http.Request) *models.ReportSubscription {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return nil
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return nil
+	}
+
+	subscription, err := models.GetReportSubscription(id)
+	if err != nil {
+		writeReportSubscriptionError(w, err, "Failed to fetch subscription")
+		return nil
+	}
+	if subscription.UserID != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return nil
+	}
+	return subscription
+}
+
+// handleUpdateReportSubscription changes a subscription's format, frequency or time zone, or
+// pauses and resumes it with is_active. Omitted fields keep their current values; an empty
+// timezone falls back to the organization's.
+func handleUpdateReportSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription := subscriptionForCaller(w, r)
+	if subscription == nil {
+		return
+	}
+
+	var req struct {
+		Format    *string `json:"format"`
+		Frequency *string `json:"frequency"`
+		Timezone  *string `json:"timezone"`
+		IsActive  *bool   `json:"is_active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Format != nil {
+		subscription.OutputFormat = *req.Format
+	}
+	if req.Frequency != nil {
+		subscription.Frequency = *req.Frequency
+	}
+	if req.Timezone != nil {
+		subscription.Timezone = models.NullString(*req.Timezone)
+	}
+	if req.IsActive != nil {
+		subscription.IsActive = *req.IsActive
+	}
+
+	if err := models.UpdateReportSubscription(subscription, time.Now()); err != nil {
+		writeReportSubscriptionError(w, err, "Failed to update subscription")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(subscription); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteReportSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription := subscriptionForCaller(w, r)
+	if subscription == nil {
+		return
+	}
+
+	if err := models.DeleteReportSubscription(subscription.ID); err != nil {
+		writeReportSubscriptionError(w, err, "Failed to delete subscription")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderReportExport(t *testing.T) {
+	report := &models.CustomReport{ID: 4, Name: "Rent Roll/East"}
+	data := &models.ReportData{
+		Headers: []string{"Unit", "Rent"},
+		Rows:    []map[string]interface{}{{"Unit": "101", "Rent": 1450.0}},
+	}
+
+	file, err := RenderReportExport(report, "csv", data, models.DefaultPreferences(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Rent_Roll_East.csv", file.Filename)
+	assert.Equal(t, "text/csv", file.ContentType)
+	assert.Contains(t, string(file.Data), "\"Unit\",\"Rent\"\n")
+
+	file, err = RenderReportExport(report, "excel", data, models.DefaultPreferences(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Rent_Roll_East.xlsx", file.Filename)
+	assert.Equal(t, "PK", string(file.Data[:2]), "xlsx files are zip archives")
+
+	_, err = RenderReportExport(report, "json", data, models.DefaultPreferences(), nil)
+	assert.Error(t, err)
+}
+
+func TestRenderReportExportWatermarksConfidentialReports(t *testing.T) {
+	report := &models.CustomReport{ID: 4, Name: "Owner Statement", Confidentiality: models.ConfidentialityConfidential}
+	data := &models.ReportData{
+		Headers: []string{"Unit", "Rent"},
+		Rows:    []map[string]interface{}{{"Unit": "101", "Rent": 1450.0}},
+	}
+	recipient := &models.User{ID: 9, FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"}
+
+	file, err := RenderReportExport(report, "excel", data, models.DefaultPreferences(), recipient)
+	require.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(file.Data), int64(len(file.Data)))
+	require.NoError(t, err)
+	var sheet []byte
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			sheet, err = io.ReadAll(rc)
+			rc.Close()
+			require.NoError(t, err)
+		}
+	}
+	assert.Contains(t, string(sheet), `<oddHeader>&amp;C&amp;&#34;-,Bold&#34;Confidential - Exported by Jane Doe &lt;jane@example.com&gt; - `)
+
+	generator := NewPDFReportGenerator()
+	generator.Watermark = models.NewReportWatermark(report, recipient, time.Date(2026, 10, 16, 8, 40, 0, 0, time.UTC))
+	html, err := generator.generateHTMLContent(data, report)
+	require.NoError(t, err)
+	assert.Contains(t, html, `<div class="confidentiality">Confidential - Exported by Jane Doe &lt;jane@example.com&gt; - 2026-10-16 08:40 UTC</div>`)
+
+	// Reports that are not confidential are not watermarked
+	report.Confidentiality = models.ConfidentialityNone
+	generator.Watermark = models.NewReportWatermark(report, recipient, time.Now())
+	html, err = generator.generateHTMLContent(data, report)
+	require.NoError(t, err)
+	assert.NotContains(t, html, `class="confidentiality"`)
+}
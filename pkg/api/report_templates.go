@@ -0,0 +1,220 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// handleGetReportTemplateCategories lists the catalog's categories with their template counts
+func handleGetReportTemplateCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := models.GetReportTemplateCategories()
+	if err != nil {
+		http.Error(w, "Failed to fetch report template categories", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(categories); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// loadVisibleReportTemplate returns the template in the URL if the caller may see it: published
+// templates are visible to everyone, submissions only to admins and their submitter. It writes
+// the error response and returns nil otherwise.
+func loadVisibleReportTemplate(w http.ResponseWriter, r *http.Request) *models.ReportTemplate {
+	templateID, err := strconv.Atoi(chi.URLParam(r, "templateId"))
+	if err != nil {
+		http.Error(w, "Invalid template ID", http.StatusBadRequest)
+		return nil
+	}
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return nil
+	}
+
+	template, err := models.GetReportTemplate(templateID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch report template", http.StatusInternalServerError)
+		return nil
+	}
+	if template.Status != models.ReportTemplatePublished && !user.HasRole("admin") &&
+		!(template.CreatedBy.Valid && int(template.CreatedBy.Int32) == user.ID) {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return nil
+	}
+	return template
+}
+
+// handleGetReportTemplate returns one template with its sample data
+func handleGetReportTemplate(w http.ResponseWriter, r *http.Request) {
+	template := loadVisibleReportTemplate(w, r)
+	if template == nil {
+		return
+	}
+	if checkNotModified(w, r, template.UpdatedAt, template.InstallCount) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetReportTemplatePreview returns what a template's report looks like before it is
+// installed: its screenshot and sample output, with the columns and charts it defines
+func handleGetReportTemplatePreview(w http.ResponseWriter, r *http.Request) {
+	template := loadVisibleReportTemplate(w, r)
+	if template == nil {
+		return
+	}
+
+	var definition models.CustomReport
+	installable := template.ReportDefinition(&definition) == nil
+	preview := map[string]interface{}{
+		"template_id": template.ID,
+		"installable": installable,
+		"sample_data": template.SampleData,
+	}
+	if template.ScreenshotURL.Valid {
+		preview["screenshot_url"] = template.ScreenshotURL.String
+	}
+	if installable {
+		preview["report_type"] = definition.ReportType
+		preview["columns"] = definition.Columns
+		preview["chart_config"] = definition.ChartConfig
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSubmitReportTemplate offers one of the caller's reports to the template catalog. The
+// template is published once an admin approves it.
+func handleSubmitReportTemplate(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := models.GetCustomReportByID(reportID)
+	if err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if report.CreatedBy != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	var submission models.ReportTemplateSubmission
+	if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	submission.Name = strings.TrimSpace(submission.Name)
+	if submission.Name == "" {
+		submission.Name = report.Name
+	}
+	if submission.Description == "" {
+		submission.Description = report.Description.String
+	}
+	if submission.Category = strings.TrimSpace(submission.Category); submission.Category == "" {
+		http.Error(w, "Category is required", http.StatusBadRequest)
+		return
+	}
+	submission.SubmittedBy = user.ID
+	if organization, err := models.GetUserOrganization(user.ID); err == nil {
+		submission.OrganizationID = organization.ID
+	}
+
+	template, err := models.SubmitReportTemplate(report, submission)
+	if err == models.ErrTemplateSubmissionPending {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to submit report template", "report_id", reportID, "error", err)
+		http.Error(w, "Failed to submit report template", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// reportTemplateReviewHandler publishes or rejects a pending template submission, with an
+// optional note for the submitter
+func reportTemplateReviewHandler(publish bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templateID, err := strconv.Atoi(chi.URLParam(r, "templateId"))
+		if err != nil {
+			http.Error(w, "Invalid template ID", http.StatusBadRequest)
+			return
+		}
+		admin, ok := middleware.GetUserFromContext(r.Context())
+		if !ok {
+			http.Error(w, "User not found in context", http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			Note string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		template, err := models.ReviewReportTemplate(templateID, admin.ID, publish, strings.TrimSpace(req.Note), time.Now())
+		switch {
+		case err == sql.ErrNoRows:
+			http.Error(w, "Template not found", http.StatusNotFound)
+			return
+		case err == models.ErrTemplateReviewed:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("Failed to review report template", "template_id", templateID, "error", err)
+			http.Error(w, "Failed to review report template", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(template); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package api
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
 	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
 	"github.com/greenbrown932/fire-pmaas/pkg/models"
 )
@@ -23,14 +29,23 @@ func RegisterReportRoutes(r chi.Router) {
 		// Custom Reports
 		auth.Get("/api/reports", handleGetReports)
 		auth.Post("/api/reports", handleCreateReport)
+		auth.Post("/api/reports/preview", handlePreviewReport)
 		auth.Get("/api/reports/{id}", handleGetReport)
 		auth.Put("/api/reports/{id}", handleUpdateReport)
 		auth.Delete("/api/reports/{id}", handleDeleteReport)
 		auth.Post("/api/reports/{id}/execute", handleExecuteReport)
+		auth.Get("/api/report-executions/compare", handleCompareReportExecutions)
+		auth.Get("/api/report-types", handleGetReportTypes)
 
 		// Report Templates
 		auth.Get("/api/report-templates", handleGetReportTemplates)
+		auth.Get("/api/report-templates/categories", handleGetReportTemplateCategories)
+		auth.Get("/api/report-templates/{templateId}", handleGetReportTemplate)
+		auth.Get("/api/report-templates/{templateId}/preview", handleGetReportTemplatePreview)
 		auth.Post("/api/reports/from-template/{templateId}", handleCreateReportFromTemplate)
+		auth.Post("/api/reports/{id}/publish-template", handleSubmitReportTemplate)
+		auth.With(middleware.RequireRole("admin")).Post("/api/report-templates/{templateId}/publish", reportTemplateReviewHandler(true))
+		auth.With(middleware.RequireRole("admin")).Post("/api/report-templates/{templateId}/reject", reportTemplateReviewHandler(false))
 
 		// Analytics and KPIs
 		auth.Get("/api/analytics/kpis", handleGetKPIs)
@@ -50,16 +65,16 @@ func RegisterReportRoutes(r chi.Router) {
 		auth.Get("/api/dashboards/{id}", handleGetDashboard)
 		auth.Put("/api/dashboards/{id}", handleUpdateDashboard)
 		auth.Delete("/api/dashboards/{id}", handleDeleteDashboard)
+		auth.Put("/api/dashboards/{id}/refresh", handleSetDashboardRefresh)
 
 		// Data Export
 		auth.Post("/api/reports/{id}/export", handleExportReport)
 		auth.Get("/api/analytics/summary", handleGetAnalyticsSummary)
 
 		// Quick Stats (for dashboard widgets)
-		auth.Get("/api/stats/properties", handleGetPropertyStats)
-		auth.Get("/api/stats/financial", handleGetFinancialStats)
-		auth.Get("/api/stats/tenants", handleGetTenantStats)
-		auth.Get("/api/stats/maintenance", handleGetMaintenanceStats)
+		auth.Get("/api/stats", handleListQuickStats)
+		auth.Get("/api/stats/{name}", handleGetQuickStat)
+		auth.Get("/api/stats/{name}/history", handleGetQuickStatHistory)
 	})
 }
 
@@ -98,9 +113,7 @@ func handleCreateReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if report.Name == "" || report.ReportType == "" {
-		http.Error(w, "Name and report type are required", http.StatusBadRequest)
+	if !validateReportDefinition(w, &report) {
 		return
 	}
 
@@ -119,6 +132,75 @@ func handleCreateReport(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// validateReportDefinition writes an error response and returns false if a created or updated
+// report is missing required fields or has invalid computed columns, schedule or confidentiality
+func validateReportDefinition(w http.ResponseWriter, report *models.CustomReport) bool {
+	// Validate required fields
+	if report.Name == "" || report.ReportType == "" {
+		http.Error(w, "Name and report type are required", http.StatusBadRequest)
+		return false
+	}
+	if err := models.ValidateComputedColumns(report.Criteria); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return false
+	}
+	if err := report.ValidateSchedule(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return false
+	}
+	if err := models.ValidateConfidentiality(report.Confidentiality); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return false
+	}
+	return true
+}
+
+// handlePreviewReport runs an unsaved report definition and returns its first rows with the
+// total row count, so users can iterate on criteria before saving or scheduling it
+func handlePreviewReport(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		models.CustomReport
+		Parameters map[string]interface{} `json:"parameters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.ReportType == "" {
+		http.Error(w, "Report type is required", http.StatusBadRequest)
+		return
+	}
+	if !models.ValidReportType(req.ReportType) {
+		http.Error(w, "Unsupported report type", http.StatusUnprocessableEntity)
+		return
+	}
+	if err := models.ValidateComputedColumns(req.Criteria); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if req.Parameters == nil {
+		req.Parameters = map[string]interface{}{}
+	}
+
+	if !requireQuota(w, r, models.UsageReportExecutions) {
+		return
+	}
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	preview, err := models.PreviewReport(&req.CustomReport, req.Parameters, user)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to preview report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	recordUsage(r, models.UsageReportExecutions, 1)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
 func handleGetReport(w http.ResponseWriter, r *http.Request) {
 	reportID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
@@ -131,6 +213,10 @@ func handleGetReport(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
+	trackRecentItem(r, models.ItemTypeReport, reportID, models.ActionViewed)
+	if checkNotModified(w, r, report.UpdatedAt) {
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(report); err != nil {
@@ -165,11 +251,20 @@ func handleUpdateReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updateData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
+	// Apply the changes to a copy of the report so they are validated the same way as on create.
+	// Criteria sent in the update replace the stored criteria rather than merging into them.
+	updated := *existingReport
+	updated.Criteria = nil
+	if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if updated.Criteria == nil {
+		updated.Criteria = existingReport.Criteria
+	}
+	if !validateReportDefinition(w, &updated) {
+		return
+	}
 
 	// TODO: Implement report update logic
 	w.Header().Set("Content-Type", "application/json")
@@ -223,10 +318,41 @@ func handleExecuteReport(w http.ResponseWriter, r *http.Request) {
 		parameters = make(map[string]interface{})
 	}
 
-	// Execute the report
-	data, err := models.ExecuteReport(reportID, parameters)
+	// Honor the Accept header so clients can fetch CSV, PDF or Excel in one call
+	w.Header().Set("Vary", "Accept")
+	format, ok := negotiateReportFormat(r.Header.Get("Accept"))
+	if !ok {
+		http.Error(w, "Not Acceptable: supported types are application/json, text/csv, application/pdf and "+
+			exportFormats["excel"].contentType, http.StatusNotAcceptable)
+		return
+	}
+	// Exports can be localized with ?locale=, independently of the caller's own settings
+	locale, err := lookupExportLocale(r.URL.Query().Get("locale"))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to execute report: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !requireQuota(w, r, models.UsageReportExecutions) {
+		return
+	}
+	if format != "json" && !requireQuota(w, r, models.UsageExportBytes) {
+		return
+	}
+
+	// Execute the report, masking the columns the caller may not see and refusing more rows than
+	// their row limit allows
+	user, _ := middleware.GetUserFromContext(r.Context())
+	data, err := models.ExecuteReportWithinLimit(reportID, parameters, user)
+	if err != nil {
+		writeReportExecutionError(w, err)
+		return
+	}
+	trackRecentItem(r, models.ItemTypeReport, reportID, models.ActionExecuted)
+	recordUsage(r, models.UsageReportExecutions, 1)
+
+	if format != "json" {
+		writeReportExport(w, r, reportID, format, data, locale)
 		return
 	}
 
@@ -237,10 +363,98 @@ func handleExecuteReport(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func handleCompareReportExecutions(w http.ResponseWriter, r *http.Request) {
+	baseID, err := strconv.Atoi(r.URL.Query().Get("base"))
+	if err != nil {
+		http.Error(w, "Invalid base execution ID", http.StatusBadRequest)
+		return
+	}
+	targetID, err := strconv.Atoi(r.URL.Query().Get("target"))
+	if err != nil {
+		http.Error(w, "Invalid target execution ID", http.StatusBadRequest)
+		return
+	}
+
+	base, err := models.GetReportExecutionByID(baseID)
+	if err != nil {
+		http.Error(w, "Base execution not found", http.StatusNotFound)
+		return
+	}
+	target, err := models.GetReportExecutionByID(targetID)
+	if err != nil {
+		http.Error(w, "Target execution not found", http.StatusNotFound)
+		return
+	}
+
+	if base.ReportID != target.ReportID {
+		http.Error(w, "Executions belong to different reports", http.StatusBadRequest)
+		return
+	}
+	if base.Snapshot == nil || target.Snapshot == nil {
+		http.Error(w, "Execution has no stored snapshot to compare", http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Snapshots are stored unredacted, so mask them for the caller before comparing
+	user, _ := middleware.GetUserFromContext(r.Context())
+	models.RedactReportData(base.Snapshot, user)
+	models.RedactReportData(target.Snapshot, user)
+
+	diff := models.DiffReportData(base.Snapshot, target.Snapshot, r.URL.Query().Get("key"))
+	diff.BaseExecutionID = base.ID
+	diff.TargetExecutionID = target.ID
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetReportTypes lists the built-in report types and those registered by plugins
+func handleGetReportTypes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models.ReportTypes()); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
 // Report Templates Handlers
 
+// handleGetReportTemplates browses the template catalog. Filters: category, q (searches names
+// and descriptions) and sort (popular, name or newest). Admins can list pending or rejected
+// submissions with status.
 func handleGetReportTemplates(w http.ResponseWriter, r *http.Request) {
-	templates, err := models.GetReportTemplates()
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.ReportTemplateFilter{
+		Category: query.Get("category"),
+		Search:   query.Get("q"),
+		Status:   query.Get("status"),
+		Sort:     query.Get("sort"),
+	}
+	switch filter.Status {
+	case "", models.ReportTemplatePublished:
+	case models.ReportTemplatePending, models.ReportTemplateRejected:
+		if !user.HasRole("admin") {
+			http.Error(w, "Only admins can review template submissions", http.StatusForbidden)
+			return
+		}
+	default:
+		http.Error(w, "status must be published, pending or rejected", http.StatusBadRequest)
+		return
+	}
+
+	templates, err := models.SearchReportTemplates(filter)
+	if err == models.ErrInvalidTemplateSort {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch report templates", http.StatusInternalServerError)
 		return
@@ -253,6 +467,8 @@ func handleGetReportTemplates(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleCreateReportFromTemplate installs a published template as a new report owned by the
+// caller, optionally renamed
 func handleCreateReportFromTemplate(w http.ResponseWriter, r *http.Request) {
 	templateID, err := strconv.Atoi(chi.URLParam(r, "templateId"))
 	if err != nil {
@@ -267,26 +483,36 @@ func handleCreateReportFromTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var requestData struct {
-		Name        string                 `json:"name"`
-		Description string                 `json:"description,omitempty"`
-		Parameters  map[string]interface{} `json:"parameters,omitempty"`
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil && err != io.EOF {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	// TODO: Implement template-based report creation
-	response := map[string]interface{}{
-		"message":     "Report created from template successfully",
-		"template_id": templateID,
-		"user_id":     user.ID,
+	report := models.CustomReport{
+		Name:        requestData.Name,
+		Description: models.NullString(requestData.Description),
+		CreatedBy:   user.ID,
+	}
+	err = models.InstallReportTemplate(templateID, &report)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	case err == models.ErrTemplateNotInstallable:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Error("Failed to install report template", "template_id", templateID, "error", err)
+		http.Error(w, "Failed to create report", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := json.NewEncoder(w).Encode(report); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -333,6 +559,7 @@ func handleGetKPIs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setRefreshHints(w, r, defaultWidgetRefreshSeconds, time.Now())
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(kpis); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
@@ -407,14 +634,17 @@ func handleGetTrendAnalysis(w http.ResponseWriter, r *http.Request) {
 // Charts and Visualization Handlers
 
 func handleGetSavedCharts(w http.ResponseWriter, r *http.Request) {
-	_, ok := middleware.GetUserFromContext(r.Context())
+	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
 		http.Error(w, "User not found in context", http.StatusInternalServerError)
 		return
 	}
 
-	// TODO: Implement GetSavedCharts in models
-	charts := []models.SavedChart{} // Placeholder
+	charts, err := models.GetSavedCharts(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch charts", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(charts); err != nil {
@@ -438,15 +668,14 @@ func handleCreateChart(w http.ResponseWriter, r *http.Request) {
 
 	chart.CreatedBy = user.ID
 
-	// TODO: Implement CreateSavedChart in models
-	response := map[string]interface{}{
-		"message": "Chart created successfully",
-		"id":      1, // Placeholder
+	if err := models.CreateSavedChart(&chart); err != nil {
+		writeSavedItemError(w, err, "Chart not found", "Failed to create chart")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := json.NewEncoder(w).Encode(chart); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -459,14 +688,29 @@ func handleGetChart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Implement GetSavedChartByID in models
-	response := map[string]interface{}{
-		"id":      chartID,
-		"message": "Chart retrieved successfully",
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	chart, err := models.GetSavedChartByID(chartID)
+	if err != nil {
+		writeSavedItemError(w, err, "Chart not found", "Failed to fetch chart")
+		return
+	}
+
+	// Private charts are visible to their owner and admins
+	if !chart.IsPublic && chart.CreatedBy != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+	if checkNotModified(w, r, chart.UpdatedAt) {
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := json.NewEncoder(w).Encode(chart); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -479,19 +723,40 @@ func handleUpdateChart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updateData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	existing, err := models.GetSavedChartByID(chartID)
+	if err != nil {
+		writeSavedItemError(w, err, "Chart not found", "Failed to fetch chart")
+		return
+	}
+
+	// Charts are edited by their owner or an admin
+	if existing.CreatedBy != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	var chart models.SavedChart
+	if err := json.NewDecoder(r.Body).Decode(&chart); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	chart.ID = existing.ID
+	chart.CreatedBy = existing.CreatedBy
+	chart.CreatedAt = existing.CreatedAt
 
-	response := map[string]interface{}{
-		"id":      chartID,
-		"message": "Chart updated successfully",
+	if err := models.UpdateSavedChart(&chart); err != nil {
+		writeSavedItemError(w, err, "Chart not found", "Failed to update chart")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := json.NewEncoder(w).Encode(chart); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -504,23 +769,57 @@ func handleDeleteChart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Implement chart deletion
-	_ = chartID // Use the ID
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	chart, err := models.GetSavedChartByID(chartID)
+	if err != nil {
+		writeSavedItemError(w, err, "Chart not found", "Failed to fetch chart")
+		return
+	}
+	if chart.CreatedBy != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	if err := models.DeleteSavedChart(chartID); err != nil {
+		writeSavedItemError(w, err, "Chart not found", "Failed to delete chart")
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// writeSavedItemError responds to a failed chart or dashboard operation: 404 when it does not
+// exist, 400 when it failed validation, otherwise 500 with message
+func writeSavedItemError(w http.ResponseWriter, err error, notFound, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, notFound, http.StatusNotFound)
+	case errors.Is(err, models.ErrInvalidSavedChart), errors.Is(err, models.ErrInvalidDashboard):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
 // Dashboard Management Handlers
 
 func handleGetDashboards(w http.ResponseWriter, r *http.Request) {
-	_, ok := middleware.GetUserFromContext(r.Context())
+	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
 		http.Error(w, "User not found in context", http.StatusInternalServerError)
 		return
 	}
 
-	// TODO: Implement GetDashboards in models
-	dashboards := []models.AnalyticsDashboard{} // Placeholder
+	dashboards, err := models.GetDashboards(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch dashboards", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(dashboards); err != nil {
@@ -544,15 +843,14 @@ func handleCreateDashboard(w http.ResponseWriter, r *http.Request) {
 
 	dashboard.CreatedBy = user.ID
 
-	// TODO: Implement CreateDashboard in models
-	response := map[string]interface{}{
-		"message": "Dashboard created successfully",
-		"id":      1, // Placeholder
+	if err := models.CreateDashboard(&dashboard); err != nil {
+		writeSavedItemError(w, err, "Dashboard not found", "Failed to create dashboard")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := json.NewEncoder(w).Encode(dashboard); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -565,14 +863,30 @@ func handleGetDashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Implement GetDashboardByID in models
-	response := map[string]interface{}{
-		"id":      dashboardID,
-		"message": "Dashboard retrieved successfully",
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	dashboard, err := models.GetAnalyticsDashboardByID(dashboardID)
+	if err != nil {
+		http.Error(w, "Dashboard not found", http.StatusNotFound)
+		return
+	}
+
+	// Private dashboards are visible to their owner and admins
+	if !dashboard.IsPublic && dashboard.CreatedBy != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+	trackRecentItem(r, models.ItemTypeDashboard, dashboardID, models.ActionViewed)
+	if checkNotModified(w, r, dashboard.UpdatedAt) {
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := json.NewEncoder(w).Encode(dashboard); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -585,19 +899,50 @@ func handleUpdateDashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updateData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	existing, err := models.GetAnalyticsDashboardByID(dashboardID)
+	if err != nil {
+		writeSavedItemError(w, err, "Dashboard not found", "Failed to fetch dashboard")
+		return
+	}
+
+	// Dashboards are edited by their owner or an admin
+	if existing.CreatedBy != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	var dashboard models.AnalyticsDashboard
+	if err := json.NewDecoder(r.Body).Decode(&dashboard); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	dashboard.ID = existing.ID
+	dashboard.CreatedBy = existing.CreatedBy
+	dashboard.CreatedAt = existing.CreatedAt
+
+	// Keep the auto-refresh interval, which has its own endpoint, when the new layout leaves it out
+	if interval, ok := existing.Layout[models.DashboardRefreshLayoutKey]; ok {
+		if dashboard.Layout == nil {
+			dashboard.Layout = map[string]interface{}{}
+		}
+		if _, set := dashboard.Layout[models.DashboardRefreshLayoutKey]; !set {
+			dashboard.Layout[models.DashboardRefreshLayoutKey] = interval
+		}
+	}
 
-	response := map[string]interface{}{
-		"id":      dashboardID,
-		"message": "Dashboard updated successfully",
+	if err := models.UpdateDashboard(&dashboard); err != nil {
+		writeSavedItemError(w, err, "Dashboard not found", "Failed to update dashboard")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := json.NewEncoder(w).Encode(dashboard); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -610,8 +955,26 @@ func handleDeleteDashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Implement dashboard deletion
-	_ = dashboardID // Use the ID
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	dashboard, err := models.GetAnalyticsDashboardByID(dashboardID)
+	if err != nil {
+		writeSavedItemError(w, err, "Dashboard not found", "Failed to fetch dashboard")
+		return
+	}
+	if dashboard.CreatedBy != user.ID && !user.HasRole("admin") {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	if err := models.DeleteDashboard(dashboardID); err != nil {
+		writeSavedItemError(w, err, "Dashboard not found", "Failed to delete dashboard")
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -628,6 +991,7 @@ func handleExportReport(w http.ResponseWriter, r *http.Request) {
 	var exportRequest struct {
 		Format     string                 `json:"format"` // pdf, csv, excel
 		Parameters map[string]interface{} `json:"parameters"`
+		Locale     string                 `json:"locale"` // Such as de-DE; the exporting user's settings when empty
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&exportRequest); err != nil {
@@ -638,34 +1002,181 @@ func handleExportReport(w http.ResponseWriter, r *http.Request) {
 	if exportRequest.Format == "" {
 		exportRequest.Format = "pdf"
 	}
+	if _, ok := exportFormats[exportRequest.Format]; !ok {
+		http.Error(w, "Unsupported export format", http.StatusBadRequest)
+		return
+	}
+	locale, err := lookupExportLocale(exportRequest.Locale)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !requireQuota(w, r, models.UsageReportExecutions) || !requireQuota(w, r, models.UsageExportBytes) {
+		return
+	}
 
 	// Execute the report to get data
-	data, err := models.ExecuteReport(reportID, exportRequest.Parameters)
+	user, _ := middleware.GetUserFromContext(r.Context())
+	data, err := models.ExecuteReportWithinLimit(reportID, exportRequest.Parameters, user)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to execute report: %v", err), http.StatusInternalServerError)
+		writeReportExecutionError(w, err)
 		return
 	}
+	trackRecentItem(r, models.ItemTypeReport, reportID, models.ActionExecuted)
+	recordUsage(r, models.UsageReportExecutions, 1)
+
+	writeReportExport(w, r, reportID, exportRequest.Format, data, locale)
+}
+
+// exportFormat is the content type and file extension of an export format
+type exportFormat struct {
+	contentType string
+	extension   string
+}
+
+// exportFormats are the formats reports can be exported in
+var exportFormats = map[string]exportFormat{
+	"pdf":   {contentType: "application/pdf", extension: "pdf"},
+	"csv":   {contentType: "text/csv", extension: "csv"},
+	"excel": {contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", extension: "xlsx"},
+}
+
+// negotiateReportFormat picks "json" or an export format for an Accept header, preferring the
+// highest quality media range. ok is false when the client accepts none of them.
+func negotiateReportFormat(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return "json", true
+	}
+
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mr := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), quality: 1}
+		for _, param := range params[1:] {
+			if value, found := strings.CutPrefix(strings.TrimSpace(param), "q="); found {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					mr.quality = q
+				}
+			}
+		}
+		if mr.quality > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
 
-	// TODO: Implement actual export logic based on format
-	switch exportRequest.Format {
+	for _, mr := range ranges {
+		switch mr.mediaType {
+		case "application/json", "application/*", "*/*":
+			return "json", true
+		case "text/*":
+			return "csv", true
+		}
+		for name, format := range exportFormats {
+			if mr.mediaType == format.contentType {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// writeReportExport writes report data as a file download in an export format, metering its size.
+// PDF and Excel exports of confidential reports are watermarked for the requesting user, and every
+// export is recorded in the audit log. A locale, when given, translates the export's labels and
+// formats its dates and numbers in place of the user's preferences.
+func writeReportExport(w http.ResponseWriter, r *http.Request, reportID int, format string, data *models.ReportData, locale *exportLocale) {
+	report, err := models.GetCustomReportByID(reportID)
+	if err != nil {
+		http.Error(w, "Failed to load report", http.StatusInternalServerError)
+		return
+	}
+	user, _ := middleware.GetUserFromContext(r.Context())
+	watermark := models.NewReportWatermark(report, user, time.Now())
+	data = locale.translate(data)
+
+	// Binary formats are built before any header is written so a failure can still be reported
+	var body []byte
+	switch format {
 	case "pdf":
-		w.Header().Set("Content-Type", "application/pdf")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"report_%d.pdf\"", reportID))
-		// TODO: Generate PDF
-		w.Write([]byte("PDF content placeholder"))
-	case "csv":
-		w.Header().Set("Content-Type", "text/csv")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"report_%d.csv\"", reportID))
-		// TODO: Generate CSV
-		generateCSVResponse(w, data)
+		generator := NewPDFReportGenerator()
+		generator.Watermark = watermark
+		generator.Locale = locale
+		if body, err = generator.GeneratePDFReport(data, report); err != nil {
+			http.Error(w, "Failed to generate PDF", http.StatusInternalServerError)
+			return
+		}
 	case "excel":
-		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"report_%d.xlsx\"", reportID))
-		// TODO: Generate Excel
-		w.Write([]byte("Excel content placeholder"))
+		if body, err = generateXLSX(data, watermark, locale); err != nil {
+			http.Error(w, "Failed to generate Excel file", http.StatusInternalServerError)
+			return
+		}
+	case "csv":
+		// Streamed below
 	default:
 		http.Error(w, "Unsupported export format", http.StatusBadRequest)
+		return
+	}
+
+	counter := &countingWriter{ResponseWriter: w}
+	defer func() {
+		recordUsage(r, models.UsageExportBytes, counter.n)
+		if err := models.RecordReportExport(report, user, format, "download", int(counter.n)); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to audit report export", "report_id", reportID, "error", err)
+		}
+	}()
+	w = counter
+
+	w.Header().Set("Content-Type", exportFormats[format].contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"report_%d.%s\"", reportID, exportFormats[format].extension))
+
+	if format == "csv" {
+		generateCSVResponse(w, data, locale.preferences(requestPreferences(r)))
+		return
 	}
+	w.Write(body)
+}
+
+// RenderReportExport renders report data as a file in an export format for delivery outside an
+// HTTP response, such as report subscription emails. CSV numbers follow prefs; PDF and Excel
+// files of confidential reports are watermarked for the recipient.
+func RenderReportExport(report *models.CustomReport, format string, data *models.ReportData, prefs models.UserPreferences, recipient *models.User) (*models.ReportFile, error) {
+	info, ok := exportFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+	watermark := models.NewReportWatermark(report, recipient, time.Now())
+
+	var body []byte
+	var err error
+	switch format {
+	case "pdf":
+		generator := NewPDFReportGenerator()
+		generator.Watermark = watermark
+		body, err = generator.GeneratePDFReport(data, report)
+	case "excel":
+		body, err = generateXLSX(data, watermark, nil)
+	case "csv":
+		var buf bytes.Buffer
+		generateCSVResponse(&buf, data, prefs)
+		body = buf.Bytes()
+	default:
+		return nil, fmt.Errorf("no renderer for export format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ReportFile{
+		Filename:    fmt.Sprintf("%s.%s", sanitizeFilename(report.Name), info.extension),
+		ContentType: info.contentType,
+		Data:        body,
+	}, nil
 }
 
 func handleGetAnalyticsSummary(w http.ResponseWriter, r *http.Request) {
@@ -707,6 +1218,7 @@ func handleGetAnalyticsSummary(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	setRefreshHints(w, r, defaultWidgetRefreshSeconds, now)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(summary); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
@@ -716,78 +1228,100 @@ func handleGetAnalyticsSummary(w http.ResponseWriter, r *http.Request) {
 
 // Quick Stats Handlers for Dashboard Widgets
 
-func handleGetPropertyStats(w http.ResponseWriter, r *http.Request) {
-	// Calculate current property statistics
-	stats := map[string]interface{}{
-		"total_properties":     0,
-		"occupied_units":       0,
-		"vacant_units":         0,
-		"maintenance_requests": 0,
-		"occupancy_rate":       0.0,
-	}
-
-	// TODO: Implement actual property stats calculation
-	// This would query the database for real-time statistics
-
+// handleListQuickStats describes every registered stat widget and the format of its fields
+func handleListQuickStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
+	if err := json.NewEncoder(w).Encode(models.ListQuickStats()); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-func handleGetFinancialStats(w http.ResponseWriter, r *http.Request) {
-	// Calculate current financial statistics
-	stats := map[string]interface{}{
-		"monthly_revenue": 0.0,
-		"total_expenses":  0.0,
-		"net_income":      0.0,
-		"collection_rate": 0.0,
-		"average_rent":    0.0,
+// handleGetQuickStat computes a registered stat over the caller's properties, optionally for a
+// single ?property_id= or ?group_id=
+func handleGetQuickStat(w http.ResponseWriter, r *http.Request) {
+	stat, ok := models.GetQuickStat(chi.URLParam(r, "name"))
+	if !ok {
+		http.Error(w, "Stat not found", http.StatusNotFound)
+		return
 	}
 
-	// TODO: Implement actual financial stats calculation
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
 
+	now := time.Now()
+	values, err := models.ComputeQuickStat(stat, properties, now)
+	if err != nil {
+		http.Error(w, "Failed to calculate stats", http.StatusInternalServerError)
+		return
+	}
+
+	setRefreshHints(w, r, defaultWidgetRefreshSeconds, now)
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
+	if err := json.NewEncoder(w).Encode(values); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-func handleGetTenantStats(w http.ResponseWriter, r *http.Request) {
-	// Calculate current tenant statistics
-	stats := map[string]interface{}{
-		"total_tenants":      0,
-		"new_tenants":        0,
-		"lease_renewals":     0,
-		"move_outs":          0,
-		"satisfaction_score": 0.0,
+// handleGetQuickStatHistory returns a stat's daily snapshots for the last days days (default 90)
+// for sparkline trends, for the portfolio or the property given by property_id
+func handleGetQuickStatHistory(w http.ResponseWriter, r *http.Request) {
+	stat, ok := models.GetQuickStat(chi.URLParam(r, "name"))
+	if !ok {
+		http.Error(w, "Stat not found", http.StatusNotFound)
+		return
 	}
 
-	// TODO: Implement actual tenant stats calculation
+	propertyID := 0
+	if propertyIDStr := r.URL.Query().Get("property_id"); propertyIDStr != "" {
+		pid, err := strconv.Atoi(propertyIDStr)
+		if err != nil {
+			http.Error(w, "Invalid property ID", http.StatusBadRequest)
+			return
+		}
+		propertyID = pid
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	// Snapshots are taken per property and for the whole portfolio, so managers limited to
+	// regions or portfolios can only see their own properties' history
+	user, _ := middleware.GetUserFromContext(r.Context())
+	scope, err := models.ScopeProperties(0, user)
+	if err != nil {
+		http.Error(w, "Failed to fetch property groups", http.StatusInternalServerError)
+		return
+	}
+	if scope.Scoped && propertyID == 0 {
+		http.Error(w, "property_id is required", http.StatusForbidden)
+		return
+	}
+	if !scope.Allows(propertyID) {
+		http.Error(w, "Property not found", http.StatusNotFound)
 		return
 	}
-}
 
-func handleGetMaintenanceStats(w http.ResponseWriter, r *http.Request) {
-	// Calculate current maintenance statistics
-	stats := map[string]interface{}{
-		"open_requests":        0,
-		"completed_this_month": 0,
-		"avg_resolution_time":  0.0,
-		"total_cost":           0.0,
-		"priority_breakdown":   map[string]int{},
+	days := 90
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		d, err := strconv.Atoi(daysStr)
+		if err != nil || d < 1 || d > models.MaxStatHistoryDays {
+			http.Error(w, fmt.Sprintf("days must be between 1 and %d", models.MaxStatHistoryDays), http.StatusBadRequest)
+			return
+		}
+		days = d
 	}
 
-	// TODO: Implement actual maintenance stats calculation
+	now := time.Now()
+	history, err := models.GetQuickStatHistory(stat, propertyID, days, now)
+	if err != nil {
+		http.Error(w, "Failed to fetch stat history", http.StatusInternalServerError)
+		return
+	}
 
+	setRefreshHints(w, r, defaultHistoryRefreshSeconds, now)
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
+	if err := json.NewEncoder(w).Encode(history); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -795,26 +1329,95 @@ func handleGetMaintenanceStats(w http.ResponseWriter, r *http.Request) {
 
 // Helper functions
 
-func generateCSVResponse(w http.ResponseWriter, data *models.ReportData) {
+func generateCSVResponse(w io.Writer, data *models.ReportData, prefs models.UserPreferences) {
+	// Locales with a decimal comma use semicolons between fields, as spreadsheet apps expect
+	delimiter := ","
+	if prefs.DecimalSeparator() == "," {
+		delimiter = ";"
+	}
+
 	// Write CSV header
 	for i, header := range data.Headers {
 		if i > 0 {
-			w.Write([]byte(","))
+			w.Write([]byte(delimiter))
 		}
 		w.Write([]byte(fmt.Sprintf("\"%s\"", header)))
 	}
 	w.Write([]byte("\n"))
 
+	columns := make([]models.ReportColumn, len(data.Headers))
+	for i, header := range data.Headers {
+		columns[i], _ = data.Column(header)
+	}
+
 	// Write CSV rows
 	for _, row := range data.Rows {
 		for i, header := range data.Headers {
 			if i > 0 {
-				w.Write([]byte(","))
+				w.Write([]byte(delimiter))
 			}
 			if value, exists := row[header]; exists {
-				w.Write([]byte(fmt.Sprintf("\"%v\"", value)))
+				w.Write([]byte(fmt.Sprintf("\"%s\"", formatExportColumnValue(value, columns[i], prefs))))
 			}
 		}
 		w.Write([]byte("\n"))
 	}
 }
+
+// formatExportColumnValue renders a value of a typed column. Numbers get the column's decimal
+// places but no currency or percent sign so spreadsheets still read them as numbers; months are
+// left as "2006-01". Everything else, including redacted values, uses formatExportValue.
+func formatExportColumnValue(value interface{}, column models.ReportColumn, prefs models.UserPreferences) string {
+	if value == nil {
+		return ""
+	}
+	if column.IsNumeric() {
+		if number, ok := exportNumber(value); ok {
+			return strings.Replace(strconv.FormatFloat(number, 'f', column.Decimals, 64), ".", prefs.DecimalSeparator(), 1)
+		}
+	}
+	if column.Type == models.ColumnDate && column.Format == models.ColumnFormatMonth {
+		if month, ok := value.(string); ok {
+			return month
+		}
+	}
+	return formatExportValue(value, prefs)
+}
+
+// exportNumber converts a numeric report value to a float64
+func exportNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// formatExportValue renders dates and decimals using the user's date format and locale
+func formatExportValue(value interface{}, prefs models.UserPreferences) string {
+	switch v := value.(type) {
+	case time.Time:
+		return prefs.FormatDate(v)
+	case string:
+		if len(v) == len("2006-01-02") {
+			if date, err := time.Parse("2006-01-02", v); err == nil {
+				return prefs.FormatDate(date)
+			}
+		}
+		return v
+	case float64:
+		return strings.Replace(strconv.FormatFloat(v, 'f', -1, 64), ".", prefs.DecimalSeparator(), 1)
+	case float32:
+		return strings.Replace(strconv.FormatFloat(float64(v), 'f', -1, 32), ".", prefs.DecimalSeparator(), 1)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"archive/zip"
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -85,6 +87,39 @@ func TestExecuteReport(t *testing.T) {
 	assert.Contains(t, []int{http.StatusOK, http.StatusNotFound, http.StatusInternalServerError}, rr.Code)
 }
 
+func TestNegotiateReportFormat(t *testing.T) {
+	cases := map[string]string{
+		"":                           "json",
+		"application/json":           "json",
+		"*/*":                        "json",
+		"text/csv":                   "csv",
+		"application/pdf, */*;q=0.1": "pdf",
+		"text/csv;q=0.5, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": "excel",
+	}
+	for accept, expected := range cases {
+		format, ok := negotiateReportFormat(accept)
+		assert.True(t, ok, accept)
+		assert.Equal(t, expected, format, accept)
+	}
+
+	_, ok := negotiateReportFormat("image/png, application/json;q=0")
+	assert.False(t, ok)
+}
+
+func TestExecuteReportNotAcceptable(t *testing.T) {
+	r := chi.NewRouter()
+	r.Post("/api/reports/{id}/execute", handleExecuteReport)
+
+	req := httptest.NewRequest("POST", "/api/reports/1/execute", nil)
+	req.Header.Set("Accept", "image/png")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotAcceptable, rr.Code)
+	assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+}
+
 func TestGetReportTemplates(t *testing.T) {
 	r := setupReportsAPI(t)
 
@@ -374,7 +409,7 @@ func TestReportDataGeneration(t *testing.T) {
 	}
 
 	rec := httptest.NewRecorder()
-	generateCSVResponse(rec, reportData)
+	generateCSVResponse(rec, reportData, models.DefaultPreferences())
 
 	csvContent := rec.Body.String()
 	assert.Contains(t, csvContent, "ID,Name,Value")
@@ -473,3 +508,150 @@ func TestFilenameSanitization(t *testing.T) {
 		assert.Equal(t, tc.expected, result, "Failed for input: %s", tc.input)
 	}
 }
+
+func TestFormatExportValue(t *testing.T) {
+	german := models.UserPreferences{Locale: "de-DE", DateFormat: "DD.MM.YYYY"}
+
+	assert.Equal(t, "07.03.2024", formatExportValue("2024-03-07", german))
+	assert.Equal(t, "07.03.2024", formatExportValue(time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC), german))
+	assert.Equal(t, "1234,5", formatExportValue(1234.5, german))
+	assert.Equal(t, "1234.5", formatExportValue(1234.5, models.DefaultPreferences()))
+	assert.Equal(t, "Unit 4", formatExportValue("Unit 4", german))
+	assert.Equal(t, "3", formatExportValue(3, german))
+}
+
+func TestFormatExportColumnValue(t *testing.T) {
+	german := models.UserPreferences{Locale: "de-DE", DateFormat: "DD.MM.YYYY"}
+	currency := models.ReportColumn{Type: models.ColumnCurrency, Decimals: 2, Currency: "USD"}
+
+	assert.Equal(t, "1234,50", formatExportColumnValue(1234.5, currency, german))
+	assert.Equal(t, "1200.00", formatExportColumnValue(1200, currency, models.DefaultPreferences()))
+	assert.Equal(t, models.RedactedValue, formatExportColumnValue(models.RedactedValue, currency, german))
+	assert.Equal(t, "2024-03", formatExportColumnValue("2024-03",
+		models.ReportColumn{Type: models.ColumnDate, Format: models.ColumnFormatMonth}, german))
+	assert.Equal(t, "07.03.2024", formatExportColumnValue("2024-03-07", models.ReportColumn{Type: models.ColumnDate}, german))
+	assert.Equal(t, "", formatExportColumnValue(nil, currency, german))
+}
+
+func TestFormatReportCell(t *testing.T) {
+	currency := models.ReportColumn{Type: models.ColumnCurrency, Decimals: 2, Currency: "USD"}
+	assert.Equal(t, "$1,234,567.50", formatReportCell(1234567.5, currency))
+	assert.Equal(t, "-$12.00", formatReportCell(-12, currency))
+	assert.Equal(t, "85.50%", formatReportCell(85.5, models.ReportColumn{Type: models.ColumnPercent, Decimals: 2}))
+	assert.Equal(t, "1,200", formatReportCell(int64(1200), models.ReportColumn{Type: models.ColumnNumber}))
+	assert.Equal(t, "Mar 7, 2024", formatReportCell("2024-03-07", models.ReportColumn{Type: models.ColumnDate}))
+	assert.Equal(t, "Mar 2024", formatReportCell("2024-03",
+		models.ReportColumn{Type: models.ColumnDate, Format: models.ColumnFormatMonth}))
+	assert.Equal(t, models.RedactedValue, formatReportCell(models.RedactedValue, currency))
+	assert.Equal(t, "10000", formatReportCell(10000, models.ReportColumn{}), "undeclared columns are shown as is")
+}
+
+func TestGenerateXLSX(t *testing.T) {
+	data := &models.ReportData{
+		Headers: []string{"Tenant", "Balance", "Due", "Share %"},
+		Rows: []map[string]interface{}{
+			{"Tenant": "Ann & Bo", "Balance": 1250.5, "Due": "2024-03-07", "Share %": 40.0},
+			{"Tenant": "Cy", "Balance": models.RedactedValue, "Due": nil, "Share %": 60.0},
+		},
+		Columns: []models.ReportColumn{
+			{Name: "Tenant", Type: models.ColumnString},
+			{Name: "Balance", Type: models.ColumnCurrency, Decimals: 2, Currency: "USD"},
+			{Name: "Due", Type: models.ColumnDate},
+			{Name: "Share %", Type: models.ColumnPercent, Decimals: 2},
+		},
+	}
+
+	content, err := generateXLSX(data, nil, nil)
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		parts[f.Name] = string(body)
+	}
+	require.Contains(t, parts, "[Content_Types].xml")
+	require.Contains(t, parts, "xl/workbook.xml")
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">Tenant</t></is></c>`)
+	assert.Contains(t, sheet, `Ann &amp; Bo`)
+	assert.Contains(t, sheet, `<c r="B2" s="2"><v>1250.5</v></c>`)
+	assert.Contains(t, sheet, `<c r="C2" s="3"><v>45358</v></c>`)
+	assert.Contains(t, sheet, `<c r="D3" s="4"><v>60</v></c>`)
+	assert.Contains(t, sheet, `<c r="B3" s="0" t="inlineStr"><is><t xml:space="preserve">[redacted]</t></is></c>`)
+	assert.NotContains(t, sheet, `r="C3"`)
+
+	styles := parts["xl/styles.xml"]
+	assert.Contains(t, styles, `<numFmt numFmtId="164" formatCode="&#34;$&#34;#,##0.00"/>`)
+	assert.Contains(t, styles, `<numFmt numFmtId="165" formatCode="yyyy-mm-dd"/>`)
+	assert.Contains(t, styles, `<numFmt numFmtId="166" formatCode="#,##0.00&#34;%&#34;"/>`)
+
+	assert.Equal(t, "AA12", xlsxCellRef(26, 12))
+}
+
+func TestExportLocale(t *testing.T) {
+	german, err := lookupExportLocale("de")
+	require.NoError(t, err)
+	assert.Equal(t, "de-DE", german.tag)
+	brazil, err := lookupExportLocale("pt-PT")
+	require.NoError(t, err, "unsupported regions fall back to their language")
+	assert.Equal(t, "pt-BR", brazil.tag)
+	_, err = lookupExportLocale("ja-JP")
+	assert.Error(t, err)
+	none, err := lookupExportLocale("")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	currency := models.ReportColumn{Type: models.ColumnCurrency, Decimals: 2, Currency: "EUR"}
+	assert.Equal(t, "1.234.567,50\u00a0€", german.formatCell(1234567.5, currency))
+	assert.Equal(t, "-12,00\u00a0€", german.formatCell(-12, currency))
+	assert.Equal(t, "85,50%", german.formatCell(85.5, models.ReportColumn{Type: models.ColumnPercent, Decimals: 2}))
+	assert.Equal(t, "07.03.2024", german.formatCell("2024-03-07", models.ReportColumn{Type: models.ColumnDate}))
+	assert.Equal(t, "Mär 2024", german.formatCell("2024-03",
+		models.ReportColumn{Type: models.ColumnDate, Format: models.ColumnFormatMonth}))
+	assert.Equal(t, "$1.200,00", brazil.formatCell(1200, models.ReportColumn{Type: models.ColumnCurrency, Decimals: 2, Currency: "USD"}))
+
+	data := &models.ReportData{
+		Headers: []string{"Unit", "Rent", "Lease End", "Einheit"},
+		Rows:    []map[string]interface{}{{"Unit": "101", "Rent": 1450.5, "Lease End": "2024-03-07", "Einheit": "x"}},
+		Columns: []models.ReportColumn{
+			{Name: "Unit", Type: models.ColumnString},
+			{Name: "Rent", Type: models.ColumnCurrency, Decimals: 2, Currency: "EUR"},
+			{Name: "Lease End", Type: models.ColumnDate},
+		},
+		Summary: map[string]interface{}{"total_units": 1, "net_profit": 7000},
+	}
+	translated := german.translate(data)
+	assert.Equal(t, []string{"Unit", "Miete", "Mietende", "Einheit"}, translated.Headers, "clashing translations stay in English")
+	assert.Equal(t, 1450.5, translated.Rows[0]["Miete"])
+	assert.Equal(t, "Miete", translated.Columns[1].Name)
+	assert.Equal(t, "Rent", data.Headers[1], "the original data is left unchanged")
+
+	rec := httptest.NewRecorder()
+	generateCSVResponse(rec, translated, german.preferences(models.DefaultPreferences()))
+	assert.Equal(t, "\"Unit\";\"Miete\";\"Mietende\";\"Einheit\"\n\"101\";\"1450,50\";\"07.03.2024\";\"x\"\n", rec.Body.String())
+
+	generator := NewPDFReportGenerator()
+	generator.Locale = german
+	html, err := generator.generateHTMLContent(translated, &models.CustomReport{Name: "Mieten", ReportType: "financial"})
+	require.NoError(t, err)
+	assert.Contains(t, html, "<title>Bericht Mieten</title>")
+	assert.Contains(t, html, `<p class="subtitle">Bericht Finanzen</p>`)
+	assert.Contains(t, html, "Zusammenfassung")
+	assert.Contains(t, html, "Keine Beschreibung vorhanden")
+	assert.Contains(t, html, "<th>Mietende</th>")
+	assert.Contains(t, html, "1.450,50\u00a0€")
+	assert.Contains(t, html, `<div class="label">Einheiten gesamt</div>`)
+	assert.Contains(t, html, `<div class="label">Net Profit</div>`)
+	assert.Contains(t, html, `<div class="value">7.000</div>`)
+
+	assert.Equal(t, `#,##0.00" €"`, xlsxNumberFormat(data.Columns[1], german))
+	assert.Equal(t, "[$-407]dd.mm.yyyy", xlsxNumberFormat(data.Columns[2], german))
+	assert.Equal(t, `"€"#,##0.00`, xlsxNumberFormat(data.Columns[1], nil))
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/config"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// bulkRoleRequest is the body of a bulk role assignment or removal
+type bulkRoleRequest struct {
+	UserIDs   []int      `json:"user_ids"`
+	ExpiresAt *time.Time `json:"expires_at"` // Optional; assignments without it never expire
+}
+
+// parseExpiresAt checks that an optional role expiry is in the future
+func parseExpiresAt(expiresAt *time.Time, now time.Time) (sql.NullTime, string) {
+	if expiresAt == nil {
+		return sql.NullTime{}, ""
+	}
+	if !expiresAt.After(now) {
+		return sql.NullTime{}, "expires_at must be in the future"
+	}
+	return sql.NullTime{Time: *expiresAt, Valid: true}, ""
+}
+
+// decodeBulkRoleRequest reads the role ID and request body of a bulk role change, dropping
+// duplicate user IDs. It writes an error response and returns false when the request is invalid.
+func decodeBulkRoleRequest(w http.ResponseWriter, r *http.Request) (int, bulkRoleRequest, bool) {
+	var req bulkRoleRequest
+	roleID, err := strconv.Atoi(chi.URLParam(r, "roleId"))
+	if err != nil {
+		http.Error(w, "Invalid role ID", http.StatusBadRequest)
+		return 0, req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return 0, req, false
+	}
+
+	seen := make(map[int]bool, len(req.UserIDs))
+	userIDs := make([]int, 0, len(req.UserIDs))
+	for _, id := range req.UserIDs {
+		if !seen[id] {
+			seen[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+	req.UserIDs = userIDs
+
+	if len(req.UserIDs) == 0 {
+		http.Error(w, "At least one user ID is required", http.StatusBadRequest)
+		return 0, req, false
+	}
+	if len(req.UserIDs) > models.MaxBulkRoleUsers {
+		http.Error(w, fmt.Sprintf("At most %d users may be changed at once", models.MaxBulkRoleUsers), http.StatusBadRequest)
+		return 0, req, false
+	}
+	return roleID, req, true
+}
+
+// writeBulkRoleResult responds with the per-user outcome of a bulk role change, or maps its error
+func writeBulkRoleResult(w http.ResponseWriter, changes []models.RoleChange, err error, failure string) {
+	var unknown *models.UnknownUsersError
+	switch {
+	case err == nil:
+	case errors.As(err, &unknown):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "Unknown users",
+			"user_ids": unknown.UserIDs,
+		})
+		return
+	case err == sql.ErrNoRows:
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"results": changes}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Bulk Assign Role Handler (Admin only)
+func handleBulkAssignRole(w http.ResponseWriter, r *http.Request) {
+	roleID, req, ok := decodeBulkRoleRequest(w, r)
+	if !ok {
+		return
+	}
+	expiresAt, problem := parseExpiresAt(req.ExpiresAt, time.Now())
+	if problem != "" {
+		http.Error(w, problem, http.StatusUnprocessableEntity)
+		return
+	}
+
+	roleName, err := models.GetRoleName(roleID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch role", http.StatusInternalServerError)
+		return
+	}
+	if config.Default.RequiresApproval(roleName) {
+		http.Error(w, fmt.Sprintf("The %s role needs a second admin's approval and must be requested for one user at a time", roleName),
+			http.StatusUnprocessableEntity)
+		return
+	}
+
+	currentUser, _ := middleware.GetUserFromContext(r.Context())
+	changes, err := models.BulkAssignRole(roleID, req.UserIDs, expiresAt, currentUser.ID)
+	writeBulkRoleResult(w, changes, err, "Failed to assign role")
+}
+
+// Bulk Remove Role Handler (Admin only)
+func handleBulkRemoveRole(w http.ResponseWriter, r *http.Request) {
+	roleID, req, ok := decodeBulkRoleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	currentUser, _ := middleware.GetUserFromContext(r.Context())
+	changes, err := models.BulkRemoveRole(roleID, req.UserIDs, currentUser.ID)
+	writeBulkRoleResult(w, changes, err, "Failed to remove role")
+}
+
+// Get Role Assignments Handler (Admin only)
+func handleGetRoleAssignments(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.Atoi(chi.URLParam(r, "roleId"))
+	if err != nil {
+		http.Error(w, "Invalid role ID", http.StatusBadRequest)
+		return
+	}
+
+	assignments, err := models.GetRoleAssignments(roleID, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to fetch role assignments", http.StatusInternalServerError)
+		return
+	}
+	if assignments == nil {
+		assignments = []models.RoleAssignment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(assignments); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// requestRoleApproval records a grant of a role that needs approval and responds 202 Accepted
+// with the pending request
+func requestRoleApproval(w http.ResponseWriter, rq *models.RoleRequest) {
+	if err := models.CreateRoleRequest(rq, appBaseURL()+"/admin/users"); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			http.Error(w, "User not found", http.StatusNotFound)
+		case models.ErrRoleAlreadyAssigned, models.ErrRoleRequestPending:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to request role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(rq); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Get Role Requests Handler (Admin only). Lists pending requests, or those with the status
+// given by ?status=approved|rejected, or every request for ?status=all.
+func handleGetRoleRequests(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.RoleRequestPending
+	case "all":
+		status = ""
+	case models.RoleRequestPending, models.RoleRequestApproved, models.RoleRequestRejected:
+	default:
+		http.Error(w, "status must be pending, approved, rejected or all", http.StatusBadRequest)
+		return
+	}
+
+	requests, err := models.GetRoleRequests(status)
+	if err != nil {
+		http.Error(w, "Failed to fetch role requests", http.StatusInternalServerError)
+		return
+	}
+	if requests == nil {
+		requests = []models.RoleRequest{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(requests); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// writeRoleRequestDecision responds with a decided role request, or maps its error
+func writeRoleRequestDecision(w http.ResponseWriter, rq *models.RoleRequest, err error) {
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		http.Error(w, "Role request not found", http.StatusNotFound)
+		return
+	case models.ErrRoleRequestDecided:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case models.ErrSelfApproval:
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case models.ErrRoleRequestExpired:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	default:
+		http.Error(w, "Failed to decide role request", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rq); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Approve Role Request Handler (Admin only). Grants the role; the approver must be neither the
+// requester nor the user receiving it.
+func handleApproveRoleRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid role request ID", http.StatusBadRequest)
+		return
+	}
+
+	currentUser, _ := middleware.GetUserFromContext(r.Context())
+	rq, err := models.ApproveRoleRequest(id, currentUser.ID, time.Now())
+	writeRoleRequestDecision(w, rq, err)
+}
+
+// Reject Role Request Handler. Admins may reject any pending request; anyone else only
+// withdraws their own: {"reason": "..."}
+func handleRejectRoleRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid role request ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	currentUser, _ := middleware.GetUserFromContext(r.Context())
+	if !currentUser.HasRole("admin") {
+		rq, err := models.GetRoleRequest(id)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Role request not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to fetch role request", http.StatusInternalServerError)
+			return
+		}
+		if !rq.RequestedBy.Valid || int(rq.RequestedBy.Int32) != currentUser.ID {
+			http.Error(w, "Only admins may reject role requests made by others", http.StatusForbidden)
+			return
+		}
+	}
+
+	rq, err := models.RejectRoleRequest(id, currentUser.ID, strings.TrimSpace(req.Reason), time.Now())
+	writeRoleRequestDecision(w, rq, err)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterRoleDashboardRoutes registers default dashboard resolution and per-role default routes
+func RegisterRoleDashboardRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+
+		auth.Get("/api/dashboards/default", handleGetDefaultDashboard)
+	})
+
+	// Designating role defaults is restricted to admins
+	r.Group(func(admin chi.Router) {
+		admin.Use(middleware.LoadUserFromToken)
+		admin.Use(middleware.RequireLogin)
+		admin.Use(middleware.RequireRole("admin"))
+
+		admin.Get("/api/dashboards/defaults", handleGetRoleDefaultDashboards)
+		admin.Put("/api/dashboards/defaults", handleSetRoleDefaultDashboard)
+		admin.Delete("/api/dashboards/defaults", handleDeleteRoleDefaultDashboard)
+	})
+}
+
+// roleDefaultScope resolves a role name and optional organization ID to the stored scope
+func roleDefaultScope(role string, organizationID *int) (int, sql.NullInt32, string, int) {
+	if role == "" {
+		return 0, sql.NullInt32{}, "role is required", http.StatusBadRequest
+	}
+	r, err := models.GetRoleByName(role)
+	if err == sql.ErrNoRows {
+		return 0, sql.NullInt32{}, "Unknown role", http.StatusUnprocessableEntity
+	}
+	if err != nil {
+		return 0, sql.NullInt32{}, "Failed to fetch role", http.StatusInternalServerError
+	}
+	var organization sql.NullInt32
+	if organizationID != nil {
+		organization = sql.NullInt32{Int32: int32(*organizationID), Valid: true}
+	}
+	return r.ID, organization, "", 0
+}
+
+// handleGetDefaultDashboard returns the caller's default dashboard: their organization's override
+// for their role, else the role's global default, else their own dashboard marked as default.
+// Designated defaults are returned even when the dashboard is private to the admin who built it.
+func handleGetDefaultDashboard(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	resolution, err := models.ResolveDefaultDashboard(user)
+	if err == models.ErrNoDefaultDashboard {
+		http.Error(w, "No default dashboard configured", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to resolve default dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resolution); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetRoleDefaultDashboards(w http.ResponseWriter, r *http.Request) {
+	defaults, err := models.GetRoleDefaultDashboards()
+	if err != nil {
+		http.Error(w, "Failed to fetch default dashboards", http.StatusInternalServerError)
+		return
+	}
+
+	if defaults == nil {
+		defaults = []models.RoleDefaultDashboard{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(defaults); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSetRoleDefaultDashboard designates a role's default dashboard. Without organization_id it
+// sets the global default; with one it sets that organization's override.
+func handleSetRoleDefaultDashboard(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role           string `json:"role"`
+		OrganizationID *int   `json:"organization_id"`
+		DashboardID    int    `json:"dashboard_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	roleID, organization, message, status := roleDefaultScope(req.Role, req.OrganizationID)
+	if message != "" {
+		http.Error(w, message, status)
+		return
+	}
+	if _, err := models.GetAnalyticsDashboardByID(req.DashboardID); err != nil {
+		http.Error(w, "Dashboard not found", http.StatusUnprocessableEntity)
+		return
+	}
+
+	var assignedBy sql.NullInt32
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		assignedBy = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+	if err := models.SetRoleDefaultDashboard(roleID, organization, req.DashboardID, assignedBy); err != nil {
+		http.Error(w, "Failed to save default dashboard", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteRoleDefaultDashboard removes a role's global default, or with organization_id that
+// organization's override, taken from the role and organization_id query parameters
+func handleDeleteRoleDefaultDashboard(w http.ResponseWriter, r *http.Request) {
+	var organizationID *int
+	if value := r.URL.Query().Get("organization_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid organization_id", http.StatusBadRequest)
+			return
+		}
+		organizationID = &id
+	}
+
+	roleID, organization, message, status := roleDefaultScope(r.URL.Query().Get("role"), organizationID)
+	if message != "" {
+		http.Error(w, message, status)
+		return
+	}
+
+	err := models.DeleteRoleDefaultDashboard(roleID, organization)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Default dashboard not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete default dashboard", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
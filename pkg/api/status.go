@@ -0,0 +1,270 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/status"
+)
+
+// statusIncidentDays is how long resolved incidents stay on the public status page
+const statusIncidentDays = 7
+
+// RegisterStatusRoutes registers the public status page and the admin incident notes shown on it
+func RegisterStatusRoutes(r chi.Router) {
+	r.Get("/status", handleGetStatus)
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("admin"))
+
+		// ?days= includes incidents resolved in the last days days (default 30)
+		auth.Get("/api/admin/status/incidents", handleGetStatusIncidents)
+		auth.Post("/api/admin/status/incidents", handleCreateStatusIncident)
+		auth.Put("/api/admin/status/incidents/{id}", handleUpdateStatusIncident)
+		auth.Delete("/api/admin/status/incidents/{id}", handleDeleteStatusIncident)
+	})
+}
+
+// statusPage is the public status page: component health with uptime counters and incident notes
+type statusPage struct {
+	status.Snapshot
+	Incidents []models.StatusIncident `json:"incidents"`
+}
+
+// handleGetStatus serves the status page as JSON, or as a minimal HTML page for browsers
+// (Accept: text/html) or with ?format=html. It needs no login and may be fetched from any origin,
+// so a separate status site can embed it.
+func handleGetStatus(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	page := statusPage{Snapshot: status.Default.Snapshot(now)}
+
+	// The page still reports component health when incident notes cannot be loaded
+	incidents, err := models.GetStatusIncidents(now.AddDate(0, 0, -statusIncidentDays))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load status incidents", "error", err)
+	}
+	page.Incidents = incidents
+	if page.Incidents == nil {
+		page.Incidents = []models.StatusIncident{}
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=30")
+
+	format := r.URL.Query().Get("format")
+	if format == "html" || (format == "" && strings.Contains(r.Header.Get("Accept"), "text/html")) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := statusPageTemplate.Execute(w, page); err != nil {
+			http.Error(w, "Failed to render status page", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// statusPageTemplate renders the status page without external assets, so it can be framed
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"uptime": func(seconds int64) string {
+		return (time.Duration(seconds) * time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Fire PMAAS Status</title>
+    <style>
+        body { font-family: Arial, sans-serif; color: #1F2937; max-width: 720px; margin: 0 auto; padding: 20px; }
+        table { border-collapse: collapse; width: 100%; }
+        td, th { padding: 6px 0; text-align: left; border-bottom: 1px solid #E5E7EB; }
+        .operational { color: #059669; }
+        .degraded { color: #D97706; }
+        .outage { color: #DC2626; }
+        .muted { color: #6B7280; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <h1>System status: <span class="{{.Status}}">{{.Status}}</span></h1>
+    <p class="muted">Up {{uptime .UptimeSeconds}} since {{.StartedAt.UTC.Format "2006-01-02 15:04 UTC"}}</p>
+    {{if .Incidents}}
+    <h2>Incidents</h2>
+    {{range .Incidents}}
+    <div>
+        <h3>{{.Title}} <span class="muted">{{.Status}}{{if .Component.Valid}} &middot; {{.Component.String}}{{end}}</span></h3>
+        <p>{{.Message}}</p>
+        <p class="muted">Posted {{.CreatedAt.UTC.Format "2006-01-02 15:04 UTC"}}{{if .ResolvedAt.Valid}}, resolved {{.ResolvedAt.Time.UTC.Format "2006-01-02 15:04 UTC"}}{{end}}</p>
+    </div>
+    {{end}}
+    {{end}}
+    <h2>Components</h2>
+    <table>
+        <tr><th>Component</th><th>Status</th><th>Uptime</th></tr>
+        {{range .Components}}
+        <tr>
+            <td>{{.Name}} <span class="muted">{{.Group}}</span></td>
+            <td class="{{.Status}}">{{.Status}}{{if .Message}} <span class="muted">{{.Message}}</span>{{end}}</td>
+            <td>{{printf "%.2f" .UptimePercent}}%</td>
+        </tr>
+        {{end}}
+    </table>
+</body>
+</html>`))
+
+// writeStatusIncidentError maps incident errors to responses
+func writeStatusIncidentError(w http.ResponseWriter, err error, failure string) {
+	switch err {
+	case models.ErrInvalidIncident:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case sql.ErrNoRows:
+		http.Error(w, "Incident not found", http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+func handleGetStatusIncidents(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		d, err := strconv.Atoi(value)
+		if err != nil || d < 0 || d > 365 {
+			http.Error(w, "days must be between 0 and 365", http.StatusBadRequest)
+			return
+		}
+		days = d
+	}
+
+	incidents, err := models.GetStatusIncidents(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		http.Error(w, "Failed to fetch incidents", http.StatusInternalServerError)
+		return
+	}
+
+	if incidents == nil {
+		incidents = []models.StatusIncident{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(incidents); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// statusIncidentRequest is the body of incident create and update requests. Omitted fields keep
+// their current values on update.
+type statusIncidentRequest struct {
+	Title     *string `json:"title"`
+	Message   *string `json:"message"`
+	Status    *string `json:"status"`
+	Component *string `json:"component"`
+}
+
+// apply copies the request's fields onto an incident
+func (req *statusIncidentRequest) apply(i *models.StatusIncident) {
+	if req.Title != nil {
+		i.Title = *req.Title
+	}
+	if req.Message != nil {
+		i.Message = *req.Message
+	}
+	if req.Status != nil {
+		i.Status = *req.Status
+	}
+	if req.Component != nil {
+		i.Component = models.NullString(*req.Component)
+	}
+}
+
+// handleCreateStatusIncident posts an incident note to the status page
+func handleCreateStatusIncident(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	var req statusIncidentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	incident := &models.StatusIncident{CreatedBy: sql.NullInt32{Int32: int32(user.ID), Valid: true}}
+	req.apply(incident)
+	if err := models.CreateStatusIncident(incident, time.Now()); err != nil {
+		writeStatusIncidentError(w, err, "Failed to create incident")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(incident); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUpdateStatusIncident updates an incident's note or status; setting the status to resolved
+// records when it was resolved
+func handleUpdateStatusIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid incident ID", http.StatusBadRequest)
+		return
+	}
+
+	incident, err := models.GetStatusIncident(id)
+	if err != nil {
+		writeStatusIncidentError(w, err, "Failed to fetch incident")
+		return
+	}
+
+	var req statusIncidentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.apply(incident)
+
+	if err := models.UpdateStatusIncident(incident, time.Now()); err != nil {
+		writeStatusIncidentError(w, err, "Failed to update incident")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(incident); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteStatusIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid incident ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteStatusIncident(id); err != nil {
+		writeStatusIncidentError(w, err, "Failed to delete incident")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
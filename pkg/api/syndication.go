@@ -0,0 +1,240 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/syndication"
+)
+
+// RegisterSyndicationRoutes registers unit listing and listing site syndication routes
+func RegisterSyndicationRoutes(r chi.Router) {
+	// Stable feed URLs for listing sites to fetch
+	r.Get("/feeds/{channel}.xml", handleServeSyndicationFeed)
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/listings", handleGetListings)
+		auth.Put("/api/units/{id}/listing", handleSaveUnitListing)
+		auth.Delete("/api/units/{id}/listing", handleDeleteUnitListing)
+
+		auth.Get("/api/syndication/channels", handleGetSyndicationChannels)
+		auth.Put("/api/syndication/channels/{name}", handleUpdateSyndicationChannel)
+		auth.Get("/api/syndication/channels/{name}/validate", handleValidateSyndicationFeed)
+	})
+}
+
+// handleServeSyndicationFeed redirects to the latest published feed of an enabled channel
+func handleServeSyndicationFeed(w http.ResponseWriter, r *http.Request) {
+	channel, err := models.GetSyndicationChannel(chi.URLParam(r, "channel"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Feed not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch feed", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !channel.Enabled || !channel.FeedURL.Valid {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.Redirect(w, r, channel.FeedURL.String, http.StatusFound)
+}
+
+// handleGetListings returns the active listings as they are syndicated
+func handleGetListings(w http.ResponseWriter, r *http.Request) {
+	listings, err := models.GetSyndicatedListings()
+	if err != nil {
+		http.Error(w, "Failed to fetch listings", http.StatusInternalServerError)
+		return
+	}
+
+	if listings == nil {
+		listings = []models.SyndicatedListing{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(listings); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// unitListingRequest is the request body for listing a unit. active defaults to true.
+type unitListingRequest struct {
+	Active        *bool   `json:"active"`
+	AskingRent    float64 `json:"asking_rent"`
+	AvailableDate string  `json:"available_date"` // YYYY-MM-DD
+	Headline      string  `json:"headline"`
+	Description   string  `json:"description"`
+	SquareFeet    *int    `json:"square_feet"`
+	ContactName   string  `json:"contact_name"`
+	ContactEmail  string  `json:"contact_email"`
+	ContactPhone  string  `json:"contact_phone"`
+}
+
+func handleSaveUnitListing(w http.ResponseWriter, r *http.Request) {
+	unitID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid unit ID", http.StatusBadRequest)
+		return
+	}
+
+	var req unitListingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	listing := &models.UnitListing{
+		UnitID:       unitID,
+		Active:       req.Active == nil || *req.Active,
+		AskingRent:   req.AskingRent,
+		Headline:     models.NullString(req.Headline),
+		Description:  models.NullString(req.Description),
+		ContactName:  models.NullString(req.ContactName),
+		ContactEmail: models.NullString(req.ContactEmail),
+		ContactPhone: models.NullString(req.ContactPhone),
+	}
+	if req.AvailableDate != "" {
+		available, err := time.Parse("2006-01-02", req.AvailableDate)
+		if err != nil {
+			http.Error(w, "available_date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		listing.AvailableDate = sql.NullTime{Time: available, Valid: true}
+	}
+	if req.SquareFeet != nil {
+		listing.SquareFeet = sql.NullInt32{Int32: int32(*req.SquareFeet), Valid: true}
+	}
+
+	if err := models.SaveUnitListing(listing); err != nil {
+		switch err {
+		case models.ErrInvalidListing:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case sql.ErrNoRows:
+			http.Error(w, "Unit not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to save listing", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(listing); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteUnitListing(w http.ResponseWriter, r *http.Request) {
+	unitID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid unit ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteUnitListing(unitID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete listing", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetSyndicationChannels(w http.ResponseWriter, r *http.Request) {
+	channels, err := models.GetSyndicationChannels()
+	if err != nil {
+		http.Error(w, "Failed to fetch syndication channels", http.StatusInternalServerError)
+		return
+	}
+
+	if channels == nil {
+		channels = []models.SyndicationChannel{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(channels); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUpdateSyndicationChannel enables or disables a channel's feed. Enabled feeds are
+// published at the next scheduled run.
+func handleUpdateSyndicationChannel(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		http.Error(w, "enabled is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetSyndicationChannelEnabled(name, *req.Enabled); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Syndication channel not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update syndication channel", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	channel, err := models.GetSyndicationChannel(name)
+	if err != nil {
+		http.Error(w, "Failed to fetch syndication channel", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(channel); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleValidateSyndicationFeed reports which listings a channel's feed would leave out and why,
+// and whether the feed generates as well-formed XML
+func handleValidateSyndicationFeed(w http.ResponseWriter, r *http.Request) {
+	channel, err := models.GetSyndicationChannel(chi.URLParam(r, "name"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Syndication channel not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch syndication channel", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	listings, err := models.GetSyndicatedListings()
+	if err != nil {
+		http.Error(w, "Failed to fetch listings", http.StatusInternalServerError)
+		return
+	}
+
+	validation := syndication.Validate(*channel, listings, appBaseURL())
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(validation); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
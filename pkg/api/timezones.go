@@ -0,0 +1,134 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterTimezoneRoutes registers the time zones property and organization schedules run in
+func RegisterTimezoneRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+
+		auth.Get("/api/properties/{id}/timezone", handleGetPropertyTimezone)
+		auth.Put("/api/properties/{id}/timezone", handleSetPropertyTimezone)
+	})
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("admin"))
+
+		auth.Get("/api/admin/organizations/{id}/timezone", handleGetOrganizationTimezone)
+		auth.Put("/api/admin/organizations/{id}/timezone", handleSetOrganizationTimezone)
+	})
+}
+
+// writeTimezoneError maps time zone errors to responses
+func writeTimezoneError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch err {
+	case models.ErrInvalidTimezone:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	default:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+}
+
+// writeTimezone responds with an entity's ID, under key, and its time zone
+func writeTimezone(w http.ResponseWriter, key string, id int, tz string) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{key: id, "timezone": tz}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetPropertyTimezone(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	tz, err := models.GetPropertyTimezone(propertyID)
+	if err != nil {
+		writeTimezoneError(w, err, "Property not found", "Failed to fetch time zone")
+		return
+	}
+	writeTimezone(w, "property_id", propertyID, tz)
+}
+
+// handleSetPropertyTimezone sets the time zone the property's dues and collection reminders run
+// in. An empty timezone clears it, and they run in UTC.
+func handleSetPropertyTimezone(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetPropertyTimezone(propertyID, req.Timezone); err != nil {
+		writeTimezoneError(w, err, "Property not found", "Failed to set time zone")
+		return
+	}
+	writeTimezone(w, "property_id", propertyID, req.Timezone)
+}
+
+func handleGetOrganizationTimezone(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	tz, err := models.GetOrganizationTimezone(orgID)
+	if err != nil {
+		writeTimezoneError(w, err, "Organization not found", "Failed to fetch time zone")
+		return
+	}
+	writeTimezone(w, "organization_id", orgID, tz)
+}
+
+// handleSetOrganizationTimezone sets the time zone the organization's report schedules and
+// subscriptions run in when they have none of their own. An empty timezone clears it, and they
+// run in UTC. Subscriptions already scheduled move to the new zone from their next run.
+func handleSetOrganizationTimezone(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetOrganizationTimezone(orgID, req.Timezone); err != nil {
+		writeTimezoneError(w, err, "Organization not found", "Failed to set time zone")
+		return
+	}
+	writeTimezone(w, "organization_id", orgID, req.Timezone)
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterTurnoverRoutes registers unit turnover and make-ready routes
+func RegisterTurnoverRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+
+		auth.Get("/api/turnovers", handleGetTurnovers)
+		auth.Get("/api/turnovers/summary", handleGetTurnoverSummary)
+		auth.Get("/api/turnovers/{id}", handleGetTurnover)
+		auth.Put("/api/turnovers/{id}/make-ready", handleUpdateTurnoverMakeReady)
+
+		// Open and close turnovers from lease changes now rather than at the next hourly sync
+		auth.Post("/api/turnovers/sync", handleSyncTurnovers)
+	})
+}
+
+// turnoverFilterFromQuery parses the property_id (comma-separated), from and to query parameters
+func turnoverFilterFromQuery(r *http.Request) (models.TurnoverFilter, bool) {
+	var filter models.TurnoverFilter
+	query := r.URL.Query()
+	if ids := query.Get("property_id"); ids != "" {
+		for _, value := range strings.Split(ids, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return filter, false
+			}
+			filter.PropertyIDs = append(filter.PropertyIDs, id)
+		}
+	}
+	for name, date := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return filter, false
+			}
+			*date = parsed
+		}
+	}
+	return filter, true
+}
+
+// handleGetTurnovers lists turnovers by move-out date with their days vacant and costs
+func handleGetTurnovers(w http.ResponseWriter, r *http.Request) {
+	filter, ok := turnoverFilterFromQuery(r)
+	if !ok {
+		http.Error(w, "Invalid property_id, from or to", http.StatusBadRequest)
+		return
+	}
+
+	turnovers, err := models.GetUnitTurnovers(filter, todayUTC())
+	if err != nil {
+		http.Error(w, "Failed to fetch turnovers", http.StatusInternalServerError)
+		return
+	}
+
+	if turnovers == nil {
+		turnovers = []models.UnitTurnover{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(turnovers); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetTurnoverSummary totals the turnovers selected by the same filters as the list
+func handleGetTurnoverSummary(w http.ResponseWriter, r *http.Request) {
+	filter, ok := turnoverFilterFromQuery(r)
+	if !ok {
+		http.Error(w, "Invalid property_id, from or to", http.StatusBadRequest)
+		return
+	}
+
+	turnovers, err := models.GetUnitTurnovers(filter, todayUTC())
+	if err != nil {
+		http.Error(w, "Failed to fetch turnovers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models.SummarizeTurnovers(turnovers)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetTurnover(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid turnover ID", http.StatusBadRequest)
+		return
+	}
+
+	t, err := models.GetUnitTurnover(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Turnover not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch turnover", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUpdateTurnoverMakeReady records the make-ready date, cost and notes of a turnover
+func handleUpdateTurnoverMakeReady(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid turnover ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ReadyDate     string  `json:"ready_date"`
+		MakeReadyCost float64 `json:"make_ready_cost"`
+		Notes         string  `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var readyDate sql.NullTime
+	if req.ReadyDate != "" {
+		parsed, err := time.Parse("2006-01-02", req.ReadyDate)
+		if err != nil {
+			http.Error(w, "Invalid ready_date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		readyDate = sql.NullTime{Time: parsed, Valid: true}
+	}
+
+	err = models.UpdateUnitTurnoverMakeReady(id, readyDate, req.MakeReadyCost, models.NullString(req.Notes))
+	switch err {
+	case nil:
+	case models.ErrInvalidTurnover:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case sql.ErrNoRows:
+		http.Error(w, "Turnover not found", http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "Failed to save turnover", http.StatusInternalServerError)
+		return
+	}
+
+	t, err := models.GetUnitTurnover(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch turnover", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleSyncTurnovers(w http.ResponseWriter, r *http.Request) {
+	result, err := models.SyncUnitTurnovers(time.Now())
+	if err != nil {
+		http.Error(w, "Failed to sync turnovers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// todayUTC returns the current date at midnight UTC, matching DATE columns
+func todayUTC() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+)
+
+// maxFormValueBytes limits each text field of a streamed multipart form
+const maxFormValueBytes = 64 << 10
+
+// streamMultipart calls handle with each part of a multipart/form-data request as it arrives, so
+// large uploads are processed without first buffering the whole form in memory or temporary files
+func streamMultipart(r *http.Request, handle func(part *multipart.Part) error) error {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = handle(part)
+		part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readFormValue reads a text field of a streamed multipart form
+func readFormValue(part *multipart.Part) (string, error) {
+	data, err := readUploadedFile(part, maxFormValueBytes)
+	return strings.TrimSpace(string(data)), err
+}
+
+// readUploadedFile reads a part of a streamed multipart form, failing with an
+// *http.MaxBytesError when it is larger than limit
+func readUploadedFile(part *multipart.Part, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(part, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &http.MaxBytesError{Limit: limit}
+	}
+	return data, nil
+}
+
+// writeUploadError responds to a failed upload: 413 naming the limit when the body or a file was
+// too large, otherwise 400 with message
+func writeUploadError(w http.ResponseWriter, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Upload too large: at most %s is accepted", middleware.FormatByteSize(tooLarge.Limit)),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, message, http.StatusBadRequest)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func multipartRequest(t *testing.T, fields map[string]string, filename, file string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	part, err := writer.CreateFormFile("csvFile", filename)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(file))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/api/imports/properties", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestStreamMultipart(t *testing.T) {
+	req := multipartRequest(t, map[string]string{"mode": " skip_bad_rows "}, "props.csv", "name,address\n")
+
+	var mode, filename string
+	var data []byte
+	err := streamMultipart(req, func(part *multipart.Part) error {
+		var err error
+		switch part.FormName() {
+		case "mode":
+			mode, err = readFormValue(part)
+		case "csvFile":
+			filename = part.FileName()
+			data, err = readUploadedFile(part, 64)
+		}
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "skip_bad_rows", mode)
+	assert.Equal(t, "props.csv", filename)
+	assert.Equal(t, "name,address\n", string(data))
+}
+
+func TestStreamedUploadTooLarge(t *testing.T) {
+	req := multipartRequest(t, nil, "props.csv", strings.Repeat("x", 100))
+	err := streamMultipart(req, func(part *multipart.Part) error {
+		_, err := readUploadedFile(part, 64)
+		return err
+	})
+
+	rr := httptest.NewRecorder()
+	writeUploadError(rr, err, "Error parsing form")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), "at most 64 bytes")
+
+	rr = httptest.NewRecorder()
+	writeUploadError(rr, streamMultipart(httptest.NewRequest("POST", "/", nil), nil), "Error parsing form")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
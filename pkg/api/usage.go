@@ -0,0 +1,199 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/exports"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RegisterUsageRoutes registers organization usage metering and quota routes
+func RegisterUsageRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireRole("admin"))
+
+		// Usage for the caller's organization, for billing pages
+		auth.Get("/api/usage", handleGetUsage)
+
+		// Usage across all organizations and quota management, for operations
+		auth.Get("/api/admin/usage", handleGetAllUsage)
+		auth.Put("/api/admin/usage/quotas", handleSetUsageQuota)
+	})
+}
+
+// usagePeriodParam returns the ?period=YYYY-MM query parameter, defaulting to the current month
+func usagePeriodParam(r *http.Request) (string, bool) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		return models.UsagePeriod(time.Now()), true
+	}
+	if _, err := time.Parse("2006-01", period); err != nil {
+		return "", false
+	}
+	return period, true
+}
+
+func handleGetUsage(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	period, ok := usagePeriodParam(r)
+	if !ok {
+		http.Error(w, "period must be in YYYY-MM format", http.StatusBadRequest)
+		return
+	}
+
+	organizationID, err := models.UserOrganizationID(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch organization", http.StatusInternalServerError)
+		return
+	}
+
+	usage, err := models.GetOrganizationUsage(period, organizationID)
+	if err != nil || len(usage) == 0 {
+		http.Error(w, "Failed to fetch usage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(usage[0]); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetAllUsage(w http.ResponseWriter, r *http.Request) {
+	period, ok := usagePeriodParam(r)
+	if !ok {
+		http.Error(w, "period must be in YYYY-MM format", http.StatusBadRequest)
+		return
+	}
+
+	usage, err := models.GetOrganizationUsage(period, 0)
+	if err != nil {
+		http.Error(w, "Failed to fetch usage", http.StatusInternalServerError)
+		return
+	}
+
+	// Stored exports are a running total rather than a monthly counter, so they are shown as is
+	stored, err := models.GetExportStorageUsage(0, exports.RetentionDaysFromEnv())
+	if err != nil {
+		http.Error(w, "Failed to fetch export storage", http.StatusInternalServerError)
+		return
+	}
+	for i := range stored {
+		for j := range usage {
+			if usage[j].OrganizationID == stored[i].OrganizationID {
+				usage[j].ExportStorage = &stored[i]
+			}
+		}
+	}
+
+	if usage == nil {
+		usage = []models.OrganizationUsage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(usage); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleSetUsageQuota(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		OrganizationID int    `json:"organization_id"`
+		Metric         string `json:"metric"`
+		Limit          int64  `json:"limit"` // 0 removes the quota
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if request.OrganizationID == 0 {
+		request.OrganizationID = models.DefaultOrganizationID
+	}
+	if !models.ValidUsageMetric(request.Metric) || request.Limit < 0 {
+		http.Error(w, "metric must be one of api_calls, report_executions, export_bytes or storage_bytes, with a non-negative limit",
+			http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetUsageQuota(request.OrganizationID, request.Metric, request.Limit); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Organization not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update quota", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireQuota responds with 402 Payment Required when the user's organization has used up its
+// monthly quota for a metric, returning false. Metering errors are logged and the request allowed.
+func requireQuota(w http.ResponseWriter, r *http.Request, metric string) bool {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		return true
+	}
+
+	status, err := models.GetUsageStatus(user.ID, metric, time.Now())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to check quota", "metric", metric, "user_id", user.ID, "error", err)
+		return true
+	}
+	if status.Exhausted() {
+		http.Error(w, "Monthly "+metric+" quota of "+strconv.FormatInt(status.Limit, 10)+" exceeded",
+			http.StatusPaymentRequired)
+		return false
+	}
+	return true
+}
+
+// recordUsage adds to the user's organization usage for a metric, logging failures
+func recordUsage(r *http.Request, metric string, amount int64) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok || amount == 0 {
+		return
+	}
+	if _, err := models.RecordUsage(user.ID, metric, amount, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record usage", "metric", metric, "user_id", user.ID, "error", err)
+	}
+}
+
+// releaseUsage gives back usage of a metric, such as the bytes of deleted files, logging failures
+func releaseUsage(r *http.Request, metric string, amount int64) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok || amount == 0 {
+		return
+	}
+	if err := models.ReleaseUsage(user.ID, metric, amount, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to release usage", "metric", metric, "user_id", user.ID, "error", err)
+	}
+}
+
+// countingWriter counts the bytes written through a ResponseWriter
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.n += int64(n)
+	return n, err
+}
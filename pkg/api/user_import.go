@@ -0,0 +1,348 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/config"
+	"github.com/greenbrown932/fire-pmaas/pkg/keycloak"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// maxImportRows limits the number of users in one import file
+const maxImportRows = 1000
+
+// maxUserImportBytes limits the size of a user import request
+const maxUserImportBytes = 5 << 20
+
+// RegisterUserImportRoutes registers bulk user import and signup invitation routes
+func RegisterUserImportRoutes(r chi.Router) {
+	// Public routes used by invitees to complete signup
+	r.Get("/invitations/accept", handleAcceptInvitationPage)
+	r.Get("/api/users/invitations/{token}", handleGetInvitation)
+	r.Post("/api/users/invitations/accept", handleAcceptInvitation)
+
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		// Imports can grant any role, so they are limited to administrators
+		auth.Use(middleware.RequireRole("admin"))
+
+		auth.With(middleware.LimitBody(maxUserImportBytes), middleware.Idempotent).Post("/api/admin/users/import", handleImportUsers)
+		auth.Post("/api/admin/users/{id}/invite", handleResendInvitation)
+	})
+}
+
+// appBaseURL returns the externally visible base URL used in emailed links
+func appBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	return "http://localhost:8000"
+}
+
+// invitationLink builds the signup completion link for an invitation token
+func invitationLink(token string) string {
+	return appBaseURL() + "/invitations/accept?token=" + url.QueryEscape(token)
+}
+
+// importResult reports the outcome for one row of an import file
+type importResult struct {
+	Row      int    `json:"row"`
+	Email    string `json:"email"`
+	Username string `json:"username,omitempty"`
+	Status   string `json:"status"` // created, invited, skipped or failed
+	UserID   int    `json:"user_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Roles waiting for a second admin's approval before they are granted
+	PendingRoles []string `json:"pending_roles,omitempty"`
+}
+
+// handleImportUsers creates users from a CSV file (multipart field "file" or a text/csv body).
+// mode=keycloak creates the accounts in Keycloak, which emails the invitations; otherwise local
+// accounts are created and invited by email unless send_invites=false.
+func handleImportUsers(w http.ResponseWriter, r *http.Request) {
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	var kc *keycloak.AdminClient
+	switch r.URL.Query().Get("mode") {
+	case "", "invite":
+	case "keycloak":
+		if kc = keycloak.NewAdminClientFromEnv(); kc == nil {
+			http.Error(w, "Keycloak admin credentials are not configured", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "mode must be invite or keycloak", http.StatusBadRequest)
+		return
+	}
+	sendInvites := r.URL.Query().Get("send_invites") != "false"
+
+	var source io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "A CSV file is required in the file field", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		source = file
+	}
+
+	reader := csv.NewReader(source)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		http.Error(w, "Invalid CSV: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := models.ParseImportRows(records)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(rows) > maxImportRows {
+		http.Error(w, fmt.Sprintf("Import files are limited to %d users", maxImportRows), http.StatusBadRequest)
+		return
+	}
+
+	roles, err := models.GetAllRoles()
+	if err != nil {
+		http.Error(w, "Failed to fetch roles", http.StatusInternalServerError)
+		return
+	}
+	roleIDs := map[string]int{}
+	for _, role := range roles {
+		roleIDs[role.Name] = role.ID
+	}
+
+	opts := models.ImportOptions{
+		InvitedBy:    sql.NullInt32{Int32: int32(admin.ID), Valid: true},
+		ApprovalLink: appBaseURL() + "/admin/users",
+		Now:          time.Now(),
+	}
+	if kc == nil && sendInvites {
+		opts.InviteLink = invitationLink
+	}
+
+	results := make([]importResult, 0, len(rows))
+	summary := map[string]int{"total": len(rows), "created": 0, "invited": 0, "skipped": 0, "failed": 0}
+	seen := map[string]bool{}
+	for _, row := range rows {
+		result := importRow(r, kc, row, roleIDs, seen, opts)
+		summary[result.Status]++
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"summary": summary,
+		"results": results,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// importRow validates and creates a single imported user
+func importRow(r *http.Request, kc *keycloak.AdminClient, row models.ImportUserRow, roleIDs map[string]int,
+	seen map[string]bool, opts models.ImportOptions) importResult {
+	result := importResult{Row: row.Row, Email: row.Email}
+	fail := func(status, msg string) importResult {
+		result.Status = status
+		result.Error = msg
+		return result
+	}
+
+	if err := row.Validate(); err != nil {
+		return fail("failed", err.Error())
+	}
+	result.Email, result.Username = row.Email, row.Username
+
+	key := strings.ToLower(row.Email)
+	if seen[key] || seen["username:"+row.Username] {
+		return fail("skipped", "duplicate of an earlier row")
+	}
+	seen[key], seen["username:"+row.Username] = true, true
+
+	// Sensitive roles are requested rather than granted, so a second admin still approves them
+	var ids []int
+	var pending []string
+	opts.ApprovalRoleIDs = nil
+	for _, name := range row.Roles {
+		id, ok := roleIDs[name]
+		if !ok {
+			return fail("failed", fmt.Sprintf("unknown role %q", name))
+		}
+		if config.Default.RequiresApproval(name) {
+			opts.ApprovalRoleIDs = append(opts.ApprovalRoleIDs, id)
+			pending = append(pending, name)
+		} else {
+			ids = append(ids, id)
+		}
+	}
+
+	var warning string
+	if kc != nil {
+		keycloakID, err := kc.InviteUser(r.Context(), keycloak.NewUser{
+			Username:  row.Username,
+			Email:     row.Email,
+			FirstName: row.FirstName,
+			LastName:  row.LastName,
+		}, appBaseURL()+"/")
+		switch {
+		case errors.Is(err, keycloak.ErrUserExists):
+			return fail("skipped", "user already exists in Keycloak")
+		case err != nil && keycloakID == "":
+			return fail("failed", err.Error())
+		case err != nil:
+			warning = err.Error()
+		}
+		opts.KeycloakID = keycloakID
+	}
+
+	user, err := models.ImportUser(row, ids, opts)
+	if err == models.ErrUserExists {
+		return fail("skipped", err.Error())
+	}
+	if err != nil {
+		return fail("failed", "failed to create user")
+	}
+
+	result.UserID = user.ID
+	result.PendingRoles = pending
+	result.Error = warning
+	result.Status = "created"
+	if kc != nil && warning == "" || kc == nil && opts.InviteLink != nil {
+		result.Status = "invited"
+	}
+	return result
+}
+
+func handleResendInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	invitedBy := sql.NullInt32{Int32: int32(admin.ID), Valid: true}
+	if err := models.ResendInvitation(userID, invitedBy, invitationLink, time.Now()); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			http.Error(w, "User not found", http.StatusNotFound)
+		case models.ErrInvitationInvalid:
+			http.Error(w, "User has already completed signup", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to send invitation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetInvitation(w http.ResponseWriter, r *http.Request) {
+	invitation, err := models.GetInvitationByToken(chi.URLParam(r, "token"), time.Now())
+	if err != nil {
+		if err == models.ErrInvitationInvalid {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch invitation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user, err := models.GetUserByID(invitation.UserID)
+	if err != nil {
+		http.Error(w, "Failed to fetch invitation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"email":      user.Email,
+		"username":   user.Username,
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"expires_at": invitation.ExpiresAt,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token       string `json:"token"`
+		FirstName   string `json:"first_name"`
+		LastName    string `json:"last_name"`
+		PhoneNumber string `json:"phone_number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.AcceptInvitation(req.Token, strings.TrimSpace(req.FirstName),
+		strings.TrimSpace(req.LastName), strings.TrimSpace(req.PhoneNumber), time.Now())
+	if err != nil {
+		if err == models.ErrInvitationInvalid {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to complete signup", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The first login from this browser links the account to the identity that signs in
+	middleware.SetInvitationCookie(w, req.Token)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":        user.ID,
+		"username":  user.Username,
+		"email":     user.Email,
+		"status":    user.Status,
+		"login_url": "/",
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleAcceptInvitationPage renders the standalone signup completion page linked from invitations
+func handleAcceptInvitationPage(w http.ResponseWriter, r *http.Request) {
+	t, err := template.ParseFiles("templates/accept-invitation.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := t.Execute(w, struct{ Token string }{r.URL.Query().Get("token")}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -2,17 +2,25 @@ package api
 
 import (
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/avatar"
+	"github.com/greenbrown932/fire-pmaas/pkg/config"
+	"github.com/greenbrown932/fire-pmaas/pkg/keycloak"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
 	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
 	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
 )
 
 // RegisterUserRoutes registers all user-related API routes
@@ -22,6 +30,8 @@ func RegisterUserRoutes(r chi.Router) {
 	r.Post("/api/users/login", handleUserLogin)
 	r.Post("/api/users/password-reset/request", handlePasswordResetRequest)
 	r.Post("/api/users/password-reset/confirm", handlePasswordResetConfirm)
+	r.Get("/password-reset", handlePasswordResetPage)
+	r.Get("/verify-email", handleVerifyEmailLink)
 
 	// Protected routes (authentication required)
 	r.Group(func(auth chi.Router) {
@@ -29,14 +39,29 @@ func RegisterUserRoutes(r chi.Router) {
 		auth.Use(middleware.RequireLogin)
 
 		// User profile management
+		auth.Get("/api/me", handleGetMe)
 		auth.Get("/api/users/profile", handleGetProfile)
 		auth.Put("/api/users/profile", handleUpdateProfile)
+		// Leave room for the multipart framing around the picture itself
+		auth.With(middleware.LimitBody(avatar.MaxUploadBytes+1<<20)).Post("/api/users/profile/picture", handleUploadProfilePicture)
+		auth.Delete("/api/users/profile/picture", handleDeleteProfilePicture)
+		auth.Get("/api/users/{id}/avatar", handleGetAvatar)
+		auth.Get("/api/users/profile/preferences", handleGetPreferences)
+		auth.Put("/api/users/profile/preferences", handleUpdatePreferences)
+		auth.Get("/api/users/profile/logins", handleGetProfileLogins)
 		auth.Post("/api/users/logout", handleLogout)
+		auth.Post("/api/users/verify-email", handleResendEmailVerification)
 
 		// MFA management
 		auth.Post("/api/users/mfa/enable", handleEnableMFA)
 		auth.Post("/api/users/mfa/disable", handleDisableMFA)
 		auth.Post("/api/users/mfa/verify", handleVerifyMFA)
+		auth.Get("/api/users/mfa/challenge", handleGetMFAChallenge)
+
+		// Trusted devices that skip MFA
+		auth.Get("/api/users/trusted-devices", handleGetTrustedDevices)
+		auth.Delete("/api/users/trusted-devices", handleRevokeTrustedDevices)
+		auth.Delete("/api/users/trusted-devices/{id}", handleRevokeTrustedDevice)
 
 		// Admin-only routes
 		auth.Group(func(admin chi.Router) {
@@ -46,9 +71,19 @@ func RegisterUserRoutes(r chi.Router) {
 			admin.Get("/api/users/{id}", handleGetUser)
 			admin.Put("/api/users/{id}", handleUpdateUser)
 			admin.Delete("/api/users/{id}", handleDeleteUser)
+			admin.Post("/api/users/{id}/suspend", userStatusHandler(models.SuspendUser))
+			admin.Post("/api/users/{id}/reactivate", userStatusHandler(models.ReactivateUser))
+			admin.Get("/api/users/{id}/audit", handleGetUserAudit)
+			admin.Get("/api/users/{id}/logins", handleGetUserLogins)
 			admin.Post("/api/users/{id}/roles", handleAssignRole)
 			admin.Delete("/api/users/{id}/roles/{roleId}", handleRemoveRole)
 			admin.Get("/api/roles", handleGetRoles)
+			admin.Get("/api/roles/{roleId}/assignments", handleGetRoleAssignments)
+			admin.Post("/api/roles/{roleId}/bulk-assign", handleBulkAssignRole)
+			admin.Post("/api/roles/{roleId}/bulk-remove", handleBulkRemoveRole)
+			admin.Get("/api/role-requests", handleGetRoleRequests)
+			admin.Post("/api/role-requests/{id}/reject", handleRejectRoleRequest)
+			admin.With(middleware.RequireRole("admin")).Post("/api/role-requests/{id}/approve", handleApproveRoleRequest)
 		})
 	})
 }
@@ -93,7 +128,7 @@ func handleUserRegistration(w http.ResponseWriter, r *http.Request) {
 		FirstName:     registration.FirstName,
 		LastName:      registration.LastName,
 		PhoneNumber:   models.NullString(registration.PhoneNumber),
-		EmailVerified: false, // Email verification would be implemented separately
+		EmailVerified: false,
 		Status:        "active",
 	}
 
@@ -111,6 +146,11 @@ func handleUserRegistration(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// The account works before the address is confirmed, so a failed email is only logged
+	if err := models.RequestEmailVerification(user, emailVerificationLink, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to send email verification", "user_id", user.ID, "error", err)
+	}
+
 	// Return user info (without sensitive data)
 	response := map[string]interface{}{
 		"id":         user.ID,
@@ -150,8 +190,85 @@ func handleGetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Preferences are stored on the user, so saving them also changes updated_at
+	if checkNotModified(w, r, user.UpdatedAt, userRoleNames(user), user.LastLogin.Time.UnixNano()) {
+		return
+	}
+
+	prefs, err := models.GetUserPreferences(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch preferences", http.StatusInternalServerError)
+		return
+	}
+
+	// Preferences are merged with the defaults so clients always receive every setting
+	profile := struct {
+		*models.User
+		Preferences models.UserPreferences `json:"preferences"`
+	}{user, prefs}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(user); err != nil {
+	if err := json.NewEncoder(w).Encode(profile); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Get Preferences Handler: returns the user's settings alongside the defaults and supported formats
+func handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	prefs, err := models.GetUserPreferences(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"preferences":  prefs,
+		"defaults":     models.DefaultPreferences(),
+		"date_formats": models.DateFormats(),
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Update Preferences Handler: fields omitted from the body keep their current value
+func handleUpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	prefs, err := models.GetUserPreferences(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch preferences", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := prefs.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SaveUserPreferences(user.ID, prefs); err != nil {
+		http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(prefs); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -166,10 +283,9 @@ func handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var updateData struct {
-		FirstName         string `json:"first_name"`
-		LastName          string `json:"last_name"`
-		PhoneNumber       string `json:"phone_number"`
-		ProfilePictureURL string `json:"profile_picture_url"`
+		FirstName   string `json:"first_name"`
+		LastName    string `json:"last_name"`
+		PhoneNumber string `json:"phone_number"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
@@ -184,8 +300,8 @@ func handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 	if updateData.LastName != "" {
 		user.LastName = updateData.LastName
 	}
+	// The picture only changes through the upload and delete endpoints
 	user.PhoneNumber = models.NullString(updateData.PhoneNumber)
-	user.ProfilePictureURL = models.NullString(updateData.ProfilePictureURL)
 
 	if err := models.UpdateUser(user); err != nil {
 		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
@@ -199,40 +315,189 @@ func handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Logout Handler
-func handleLogout(w http.ResponseWriter, r *http.Request) {
-	// Delete session cookie if present
-	sessionCookie, err := r.Cookie("session_token")
-	if err == nil && sessionCookie.Value != "" {
-		if delErr := models.DeleteUserSession(sessionCookie.Value); delErr != nil {
-			// Log error but continue with logout
+// profilePictureResponse is returned after the profile picture changes
+type profilePictureResponse struct {
+	ProfilePictureURL string `json:"profile_picture_url"`
+}
+
+// Upload Profile Picture Handler: accepts a multipart "picture" field, stores a square
+// PNG and replaces any previously uploaded picture
+func handleUploadProfilePicture(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	file, _, err := r.FormFile("picture")
+	if err != nil {
+		http.Error(w, "A picture file is required (max 5MB)", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, avatar.MaxUploadBytes+1))
+	if err != nil {
+		http.Error(w, "Failed to read picture", http.StatusBadRequest)
+		return
+	}
+
+	processed, err := avatar.Process(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, avatar.ErrTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, avatar.ErrUnsupportedFormat):
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		default:
+			http.Error(w, "Failed to process picture", http.StatusUnprocessableEntity)
 		}
+		return
 	}
 
-	// Delete ID token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "id_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false, // Set to true in production
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   -1, // Delete the cookie
-	})
+	if !requireQuota(w, r, models.UsageStorageBytes) {
+		return
+	}
 
-	// Delete session token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false, // Set to true in production
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   -1, // Delete the cookie
-	})
+	// A fresh key per upload keeps caches from serving the old picture
+	key := fmt.Sprintf("avatars/%d-%d.png", user.ID, time.Now().UnixNano())
+	url, err := storage.Default.Save(key, processed)
+	if err != nil {
+		http.Error(w, "Failed to store picture", http.StatusInternalServerError)
+		return
+	}
 
+	previous := user.ProfilePictureURL
+	user.ProfilePictureURL = models.NullString(url)
+	if err := models.UpdateUser(user); err != nil {
+		storage.Default.Delete(key)
+		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+	recordUsage(r, models.UsageStorageBytes, int64(len(processed)))
+	releaseUsage(r, models.UsageStorageBytes, deleteStoredPicture(user.ID, previous))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(profilePictureResponse{ProfilePictureURL: url}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Delete Profile Picture Handler: removes the uploaded picture and returns the default avatar URL
+func handleDeleteProfilePicture(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	previous := user.ProfilePictureURL
+	user.ProfilePictureURL = sql.NullString{}
+	if err := models.UpdateUser(user); err != nil {
+		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+	releaseUsage(r, models.UsageStorageBytes, deleteStoredPicture(user.ID, previous))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(profilePictureResponse{ProfilePictureURL: defaultAvatarURL(user.ID)}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Get Avatar Handler: redirects to the user's picture, or renders an initials avatar when none is set
+func handleGetAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Only pictures this user uploaded are served; any other stored URL falls back to initials
+	if _, ok := ownPictureKey(user.ID, user.ProfilePictureURL); ok {
+		http.Redirect(w, r, user.ProfilePictureURL.String, http.StatusFound)
+		return
+	}
+
+	initials := avatar.Initials(user.FirstName+" "+user.LastName, user.Username)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if err := avatar.WriteDefault(w, initials, user.Username); err != nil {
+		http.Error(w, "Failed to render avatar", http.StatusInternalServerError)
+		return
+	}
+}
+
+// defaultAvatarURL returns the URL of the generated avatar for a user
+func defaultAvatarURL(userID int) string {
+	return fmt.Sprintf("/api/users/%d/avatar", userID)
+}
+
+// ownPictureKey returns the storage key of a picture uploaded by userID. Keys outside
+// avatars/{userID}- are never treated as the user's, whatever URL the row holds.
+func ownPictureKey(userID int, url sql.NullString) (string, bool) {
+	if !url.Valid {
+		return "", false
+	}
+	key, ok := storage.Default.KeyForURL(url.String)
+	if !ok {
+		return "", false
+	}
+	name, ok := strings.CutPrefix(key, fmt.Sprintf("avatars/%d-", userID))
+	if !ok || name == "" || strings.ContainsAny(name, "/\\") || strings.Contains(name, "..") {
+		return "", false
+	}
+	return key, true
+}
+
+// deleteStoredPicture removes a picture the user previously uploaded and returns the bytes freed;
+// anything else is left alone
+func deleteStoredPicture(userID int, url sql.NullString) int64 {
+	key, ok := ownPictureKey(userID, url)
+	if !ok {
+		return 0
+	}
+	size, err := storage.Default.Size(key)
+	if err == nil {
+		err = storage.Default.Delete(key)
+	}
+	if err != nil {
+		slog.Error("Failed to delete profile picture", "key", key, "error", err)
+		return 0
+	}
+	return size
+}
+
+// Logout Handler
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	idToken, err := middleware.EndLocalSession(w, r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete session", "error", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	// Clients navigate to logout_url to end the identity provider's SSO session as well
+	response := map[string]string{"message": "Logged out successfully"}
+	if logoutURL := middleware.EndSessionURL(idToken); logoutURL != "" {
+		response["logout_url"] = logoutURL
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Logged out successfully"}); err != nil {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -314,6 +579,10 @@ func handleDisableMFA(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to disable MFA", http.StatusInternalServerError)
 		return
 	}
+	// Trust only skips MFA, so it ends with it
+	if _, err := models.RevokeTrustedDevices(user.ID, user.ID); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to revoke trusted devices", "user_id", user.ID, "error", err)
+	}
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(map[string]string{"message": "MFA disabled successfully"}); err != nil {
@@ -331,7 +600,8 @@ func handleVerifyMFA(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request struct {
-		MFACode string `json:"mfa_code"`
+		MFACode     string `json:"mfa_code"`
+		TrustDevice bool   `json:"trust_device"` // Skip MFA on this device for 30 days
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -349,6 +619,39 @@ func handleVerifyMFA(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"valid": valid,
 	}
+	if valid && request.TrustDevice {
+		device, err := middleware.TrustDevice(w, r, user.ID)
+		if err != nil {
+			http.Error(w, "Failed to trust device", http.StatusInternalServerError)
+			return
+		}
+		response["trusted_until"] = device.ExpiresAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetMFAChallenge tells the client whether to ask for an MFA code, which is skipped on the
+// user's trusted devices
+func handleGetMFAChallenge(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	trusted := false
+	if user.MFAEnabled {
+		trusted = middleware.TrustedDeviceID(r, user.ID) != 0
+	}
+	response := map[string]interface{}{
+		"mfa_required":   user.MFAEnabled && !trusted,
+		"trusted_device": trusted,
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -357,6 +660,78 @@ func handleVerifyMFA(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func handleGetTrustedDevices(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	devices, err := models.GetTrustedDevices(user.ID, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to fetch trusted devices", http.StatusInternalServerError)
+		return
+	}
+	if devices == nil {
+		devices = []models.TrustedDevice{}
+	}
+	current := middleware.TrustedDeviceID(r, user.ID)
+	for i := range devices {
+		devices[i].Current = devices[i].ID == current
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(devices); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleRevokeTrustedDevice(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	deviceID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid device ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RevokeTrustedDevice(user.ID, deviceID, user.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Trusted device not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke trusted device", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRevokeTrustedDevices(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	revoked, err := models.RevokeTrustedDevices(user.ID, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to revoke trusted devices", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"revoked": revoked}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
 // Password Reset Request Handler
 func handlePasswordResetRequest(w http.ResponseWriter, r *http.Request) {
 	var request struct {
@@ -379,24 +754,13 @@ func handlePasswordResetRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate reset token
-	token, err := models.GenerateResetToken()
-	if err != nil {
-		http.Error(w, "Failed to generate reset token", http.StatusInternalServerError)
-		return
-	}
-
-	user.PasswordResetToken = models.NullString(token)
-	user.PasswordResetExpires = sql.NullTime{Time: time.Now().Add(24 * time.Hour), Valid: true}
-
-	if err := models.UpdateUser(user); err != nil {
-		http.Error(w, "Failed to save reset token", http.StatusInternalServerError)
+	if err := models.RequestPasswordReset(user, passwordResetLink, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to send password reset", "user_id", user.ID, "error", err)
+		http.Error(w, "Failed to send reset link", http.StatusInternalServerError)
 		return
 	}
-
-	// TODO: Send email with reset link
-	// For now, just log the token (in production, this would be sent via email)
-	fmt.Printf("Password reset token for %s: %s\n", user.Email, token)
+	// The token itself is never logged
+	logging.FromContext(r.Context()).Info("Password reset requested", "user_id", user.ID)
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(map[string]string{"message": "If the email exists, a reset link has been sent"}); err != nil {
@@ -405,59 +769,140 @@ func handlePasswordResetRequest(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Password Reset Confirm Handler
+// Password Reset Confirm Handler. The new password is set in Keycloak, which holds the
+// credentials, and the reset token is then consumed.
 func handlePasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
 	var reset models.PasswordReset
 	if err := json.NewDecoder(r.Body).Decode(&reset); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if reset.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+	if len(reset.NewPassword) < 8 {
+		http.Error(w, "Password must be at least 8 characters", http.StatusBadRequest)
+		return
+	}
+	if reset.NewPassword != reset.ConfirmPassword {
+		http.Error(w, "Passwords do not match", http.StatusBadRequest)
+		return
+	}
+
+	kc := keycloak.NewAdminClientFromEnv()
+	if kc == nil {
+		http.Error(w, "Password reset is handled by the authentication provider", http.StatusNotImplemented)
+		return
+	}
+
+	user, err := models.GetUserByResetToken(reset.Token, time.Now())
+	if err == models.ErrPasswordResetInvalid {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	if !user.KeycloakID.Valid {
+		http.Error(w, "This account has not completed signup", http.StatusConflict)
+		return
+	}
 
-	// For OIDC-based auth, password reset would typically be handled by Keycloak
-	http.Error(w, "Password reset is handled by the authentication provider", http.StatusNotImplemented)
+	if err := kc.ResetPassword(r.Context(), user.KeycloakID.String, reset.NewPassword); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to set password in Keycloak", "user_id", user.ID, "error", err)
+		http.Error(w, "Failed to reset password", http.StatusBadGateway)
+		return
+	}
+	if err := models.CompletePasswordReset(user.ID, reset.Token); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to consume password reset token", "user_id", user.ID, "error", err)
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Your password has been reset"}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
 }
 
-// List Users Handler (Admin only)
+// Default and largest page sizes of the user list
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 500
+)
+
+// List Users Handler (Admin only). Filters: role, status, q and last_login_before/after. Pages
+// are chosen with limit (default 50, at most 500) and offset, and ordered by sort, e.g.
+// sort=-last_login. The CSV export ignores limit and offset and includes every matching user.
 func handleListUsers(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement pagination
-	query := `
-		SELECT u.id, u.keycloak_id, u.username, u.email, u.first_name, u.last_name,
-			   u.phone_number, u.email_verified, u.mfa_enabled, u.status, u.last_login, u.created_at
-		FROM users u
-		ORDER BY u.created_at DESC`
+	query := r.URL.Query()
+	filter := models.UserListFilter{
+		Role:   query.Get("role"),
+		Status: query.Get("status"),
+		Search: query.Get("q"),
+	}
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"last_login_before", &filter.LastLoginBefore},
+		{"last_login_after", &filter.LastLoginAfter},
+	} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := parseDateOrTime(value)
+		if err != nil {
+			http.Error(w, param.name+" must be a YYYY-MM-DD date or RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		*param.dest = &parsed
+	}
+
+	csvExport := query.Get("format") == "csv"
+	page := models.UserListPage{Sort: query.Get("sort"), Limit: defaultUserPageSize}
+	if csvExport {
+		page.Limit = 0
+	} else {
+		if value := query.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxUserPageSize {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxUserPageSize), http.StatusBadRequest)
+				return
+			}
+			page.Limit = limit
+		}
+		if value := query.Get("offset"); value != "" {
+			offset, err := strconv.Atoi(value)
+			if err != nil || offset < 0 {
+				http.Error(w, "offset must be 0 or more", http.StatusBadRequest)
+				return
+			}
+			page.Offset = offset
+		}
+	}
 
-	rows, err := db.DB.Query(query)
+	users, total, err := models.ListUsers(filter, page)
+	if err == models.ErrInvalidUserSort {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var users []map[string]interface{}
-	for rows.Next() {
-		var user struct {
-			ID            int            `json:"id"`
-			KeycloakID    sql.NullString `json:"keycloak_id,omitempty"`
-			Username      string         `json:"username"`
-			Email         string         `json:"email"`
-			FirstName     string         `json:"first_name"`
-			LastName      string         `json:"last_name"`
-			PhoneNumber   sql.NullString `json:"phone_number,omitempty"`
-			EmailVerified bool           `json:"email_verified"`
-			MFAEnabled    bool           `json:"mfa_enabled"`
-			Status        string         `json:"status"`
-			LastLogin     sql.NullTime   `json:"last_login,omitempty"`
-			CreatedAt     time.Time      `json:"created_at"`
-		}
 
-		err := rows.Scan(&user.ID, &user.KeycloakID, &user.Username, &user.Email,
-			&user.FirstName, &user.LastName, &user.PhoneNumber, &user.EmailVerified,
-			&user.MFAEnabled, &user.Status, &user.LastLogin, &user.CreatedAt)
-		if err != nil {
-			http.Error(w, "Failed to scan user data", http.StatusInternalServerError)
-			return
-		}
+	if csvExport {
+		writeUsersCSV(w, users)
+		return
+	}
 
+	result := []map[string]interface{}{}
+	for _, user := range users {
 		// Convert to map for JSON response
 		userMap := map[string]interface{}{
 			"id":             user.ID,
@@ -468,6 +913,7 @@ func handleListUsers(w http.ResponseWriter, r *http.Request) {
 			"email_verified": user.EmailVerified,
 			"mfa_enabled":    user.MFAEnabled,
 			"status":         user.Status,
+			"roles":          user.Roles,
 			"created_at":     user.CreatedAt,
 		}
 
@@ -481,16 +927,68 @@ func handleListUsers(w http.ResponseWriter, r *http.Request) {
 			userMap["last_login"] = user.LastLogin.Time
 		}
 
-		users = append(users, userMap)
+		result = append(result, userMap)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(users); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"users":  result,
+		"total":  total,
+		"limit":  page.Limit,
+		"offset": page.Offset,
+	}); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
+// csvSafe prefixes values that spreadsheet applications would evaluate as formulas
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// parseDateOrTime accepts a YYYY-MM-DD date or an RFC 3339 timestamp
+func parseDateOrTime(value string) (time.Time, error) {
+	if parsed, err := time.Parse("2006-01-02", value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// writeUsersCSV writes the user list as a CSV download
+func writeUsersCSV(w http.ResponseWriter, users []models.UserSummary) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"users_%s.csv\"", time.Now().Format("20060102")))
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"ID", "Username", "Email", "First Name", "Last Name", "Phone Number",
+		"Status", "Roles", "Email Verified", "MFA Enabled", "Last Login", "Created At"})
+	for _, user := range users {
+		lastLogin := ""
+		if user.LastLogin.Valid {
+			lastLogin = user.LastLogin.Time.Format(time.RFC3339)
+		}
+		writer.Write([]string{
+			strconv.Itoa(user.ID),
+			csvSafe(user.Username),
+			csvSafe(user.Email),
+			csvSafe(user.FirstName),
+			csvSafe(user.LastName),
+			csvSafe(user.PhoneNumber.String),
+			user.Status,
+			strings.Join(user.Roles, ";"),
+			strconv.FormatBool(user.EmailVerified),
+			strconv.FormatBool(user.MFAEnabled),
+			lastLogin,
+			user.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	writer.Flush()
+}
+
 // Get User Handler (Admin only)
 func handleGetUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -566,8 +1064,10 @@ func handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	if updateData.EmailVerified != nil {
 		user.EmailVerified = *updateData.EmailVerified
 	}
-	if updateData.Status != "" {
-		user.Status = updateData.Status
+	// Status changes revoke access and are audited, so they go through suspend and reactivate
+	if updateData.Status != "" && updateData.Status != user.Status {
+		http.Error(w, "Use the suspend and reactivate endpoints to change a user's status", http.StatusBadRequest)
+		return
 	}
 
 	if err := models.UpdateUser(user); err != nil {
@@ -590,14 +1090,175 @@ func handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.DeleteUser(userID); err != nil {
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+	currentUser, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+	if currentUser.ID == userID {
+		http.Error(w, "You cannot delete your own account", http.StatusBadRequest)
+		return
+	}
+
+	// The reason is optional; it is recorded in the audit log when given
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	actorID := sql.NullInt32{Int32: int32(currentUser.ID), Valid: true}
+	if err := models.DeleteUser(userID, actorID, strings.TrimSpace(request.Reason)); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			http.Error(w, "User not found", http.StatusNotFound)
+		case models.ErrUserStatusConflict:
+			http.Error(w, "User is already deleted", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		}
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// userStatusHandler suspends or reactivates the user named by the id URL parameter. A reason
+// is required and is recorded in the audit log with the acting administrator.
+func userStatusHandler(change func(userID int, actorID sql.NullInt32, reason string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		var request struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		request.Reason = strings.TrimSpace(request.Reason)
+		if request.Reason == "" {
+			http.Error(w, "A reason is required", http.StatusBadRequest)
+			return
+		}
+
+		currentUser, ok := middleware.GetUserFromContext(r.Context())
+		if !ok {
+			http.Error(w, "User not found in context", http.StatusInternalServerError)
+			return
+		}
+		if currentUser.ID == userID {
+			http.Error(w, "You cannot change the status of your own account", http.StatusBadRequest)
+			return
+		}
+
+		actorID := sql.NullInt32{Int32: int32(currentUser.ID), Valid: true}
+		if err := change(userID, actorID, request.Reason); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				http.Error(w, "User not found", http.StatusNotFound)
+			case models.ErrUserStatusConflict:
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, "Failed to update user status", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		user, err := models.GetUserByID(userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(user); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Get User Audit Log Handler (Admin only)
+func handleGetUserAudit(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := models.GetAuditLog("user", userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Get Own Login History Handler
+func handleGetProfileLogins(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+	writeLoginHistory(w, r, user.ID)
+}
+
+// Get User Login History Handler (Admin only)
+func handleGetUserLogins(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	writeLoginHistory(w, r, userID)
+}
+
+// writeLoginHistory responds with a user's recent login attempts, limited by ?limit= (default 50)
+func writeLoginHistory(w http.ResponseWriter, r *http.Request, userID int) {
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 500 {
+			http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	attempts, err := models.GetLoginHistory(userID, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch login history", http.StatusInternalServerError)
+		return
+	}
+
+	if attempts == nil {
+		attempts = []models.LoginAttempt{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(attempts); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
 // Assign Role Handler (Admin only)
 func handleAssignRole(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -607,18 +1268,43 @@ func handleAssignRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request struct {
-		RoleID int `json:"role_id"`
+		RoleID    int        `json:"role_id"`
+		ExpiresAt *time.Time `json:"expires_at"` // Optional; temporary access is removed once it passes
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	expiresAt, problem := parseExpiresAt(request.ExpiresAt, time.Now())
+	if problem != "" {
+		http.Error(w, problem, http.StatusUnprocessableEntity)
+		return
+	}
+
+	roleName, err := models.GetRoleName(request.RoleID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch role", http.StatusInternalServerError)
+		return
+	}
 
 	currentUser, _ := middleware.GetUserFromContext(r.Context())
+	if config.Default.RequiresApproval(roleName) {
+		requestRoleApproval(w, &models.RoleRequest{
+			UserID:      userID,
+			RoleID:      request.RoleID,
+			ExpiresAt:   expiresAt,
+			RequestedBy: sql.NullInt32{Int32: int32(currentUser.ID), Valid: true},
+		})
+		return
+	}
 	assignedBy := &currentUser.ID
 
-	if err := models.AssignRole(userID, request.RoleID, assignedBy); err != nil {
+	if err := models.AssignRoleUntil(userID, request.RoleID, assignedBy, expiresAt); err != nil {
 		if strings.Contains(err.Error(), "duplicate") {
 			http.Error(w, "Role already assigned to user", http.StatusConflict)
 		} else {
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteUsersCSV(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	users := []models.UserSummary{{
+		ID:          7,
+		Username:    "jdoe",
+		Email:       "jane@example.com",
+		FirstName:   "=HYPERLINK(\"x\")",
+		LastName:    "Doe",
+		PhoneNumber: sql.NullString{String: "555-0100", Valid: true},
+		Status:      "active",
+		CreatedAt:   created,
+		Roles:       []string{"admin", "viewer"},
+	}}
+
+	rec := httptest.NewRecorder()
+	writeUsersCSV(rec, users)
+
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "ID,Username,Email"))
+	assert.Equal(t, `7,jdoe,jane@example.com,"'=HYPERLINK(""x"")",Doe,555-0100,active,admin;viewer,false,false,,2024-01-02T03:04:05Z`, lines[1])
+}
+
+func TestParseDateOrTime(t *testing.T) {
+	parsed, err := parseDateOrTime("2024-03-01")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), parsed)
+
+	parsed, err = parseDateOrTime("2024-03-01T10:00:00Z")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, parsed.Hour())
+
+	_, err = parseDateOrTime("yesterday")
+	assert.Error(t, err)
+}
+
+func TestOwnPictureKey(t *testing.T) {
+	key, ok := ownPictureKey(7, models.NullString("/uploads/avatars/7-1700000000.png"))
+	assert.True(t, ok)
+	assert.Equal(t, "avatars/7-1700000000.png", key)
+
+	for _, url := range []string{
+		"/uploads/avatars/8-1700000000.png",  // another user's upload
+		"/uploads/avatars/77-1700000000.png", // prefix of a different id
+		"/uploads/avatars/7-/../8-1.png",
+		"/uploads/receipts/7-1.pdf",
+		"https://example.com/avatars/7-1.png",
+		"",
+	} {
+		_, ok := ownPictureKey(7, models.NullString(url))
+		assert.False(t, ok, url)
+	}
+}
@@ -0,0 +1,369 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/middleware"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// utilityTypes are the accepted meter reading utility types
+var utilityTypes = map[string]bool{"water": true, "electric": true, "gas": true}
+
+// RegisterUtilityRoutes registers meter reading, usage anomaly, utility expense and benchmarking routes
+func RegisterUtilityRoutes(r chi.Router) {
+	r.Group(func(auth chi.Router) {
+		auth.Use(middleware.LoadUserFromToken)
+		auth.Use(middleware.RequireLogin)
+		auth.Use(middleware.RequireAnyRole("admin", "property_manager"))
+		auth.Use(scopedPropertyRoutes)
+
+		auth.Get("/api/units/{id}/meter-readings", handleGetMeterReadings)
+		auth.Post("/api/units/{id}/meter-readings", handleSaveMeterReadings)
+		auth.Get("/api/utilities/anomalies", handleGetUtilityAnomalies)
+
+		// Utility bills and cost per square foot benchmarking
+		auth.Put("/api/properties/{id}/square-feet", handleSetPropertySquareFeet)
+		auth.Get("/api/utilities/expenses", handleGetUtilityExpenses)
+		auth.Post("/api/utilities/expenses", handleCreateUtilityExpense)
+		auth.Get("/api/utilities/benchmarks", handleGetUtilityBenchmarks)
+		auth.Put("/api/utilities/benchmarks", handleSaveUtilityBenchmark)
+		auth.Delete("/api/utilities/benchmarks/{id}", handleDeleteUtilityBenchmark)
+		auth.Get("/api/utilities/benchmarking", handleGetUtilityBenchmarking)
+	})
+}
+
+// handleGetMeterReadings lists a unit's readings between start and end (YYYY-MM-DD, default: last 90 days)
+func handleGetMeterReadings(w http.ResponseWriter, r *http.Request) {
+	unitID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid unit ID", http.StatusBadRequest)
+		return
+	}
+
+	start, end, ok := parseCalendarRange(r, 90, 0)
+	if !ok {
+		http.Error(w, "Invalid start or end date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	readings, err := models.GetMeterReadings(unitID, r.URL.Query().Get("utility_type"), start, end)
+	if err != nil {
+		http.Error(w, "Failed to fetch meter readings", http.StatusInternalServerError)
+		return
+	}
+	if readings == nil {
+		readings = []models.MeterReading{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(readings); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSaveMeterReadings records a batch of readings for a unit. Re-sending a reading for the
+// same utility and date replaces it and queues it for anomaly detection again.
+func handleSaveMeterReadings(w http.ResponseWriter, r *http.Request) {
+	unitID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid unit ID", http.StatusBadRequest)
+		return
+	}
+
+	var req []struct {
+		UtilityType string  `json:"utility_type"`
+		ReadingDate string  `json:"reading_date"` // YYYY-MM-DD
+		Consumption float64 `json:"consumption"`
+		UOM         string  `json:"uom"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body, expected an array of readings", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the whole batch before saving any of it
+	readings := make([]models.MeterReading, 0, len(req))
+	for i, item := range req {
+		utilityType := strings.ToLower(item.UtilityType)
+		if !utilityTypes[utilityType] {
+			http.Error(w, fmt.Sprintf("Reading %d: utility_type must be water, electric or gas", i), http.StatusBadRequest)
+			return
+		}
+		readingDate, err := time.Parse("2006-01-02", item.ReadingDate)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Reading %d: invalid reading_date, expected YYYY-MM-DD", i), http.StatusBadRequest)
+			return
+		}
+		if item.Consumption < 0 {
+			http.Error(w, fmt.Sprintf("Reading %d: consumption cannot be negative", i), http.StatusBadRequest)
+			return
+		}
+
+		readings = append(readings, models.MeterReading{
+			UnitID:      unitID,
+			UtilityType: utilityType,
+			ReadingDate: readingDate,
+			Consumption: item.Consumption,
+			UOM:         models.NullString(item.UOM),
+		})
+	}
+
+	for i := range readings {
+		if err := models.SaveMeterReading(&readings[i]); err != nil {
+			http.Error(w, "Failed to save meter readings", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(readings); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetUtilityAnomalies lists anomalies detected in the last ?days= days (default 30)
+func handleGetUtilityAnomalies(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 {
+		days = d
+	}
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	anomalies, err := models.GetUtilityAnomalies(properties, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		http.Error(w, "Failed to fetch utility anomalies", http.StatusInternalServerError)
+		return
+	}
+	if anomalies == nil {
+		anomalies = []models.UtilityAnomaly{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(anomalies); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSetPropertySquareFeet records the floor area used to normalise a property's utility costs
+func handleSetPropertySquareFeet(w http.ResponseWriter, r *http.Request) {
+	propertyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid property ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		SquareFeet int `json:"square_feet"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetPropertySquareFeet(propertyID, req.SquareFeet); err != nil {
+		switch err {
+		case models.ErrInvalidSquareFeet:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case sql.ErrNoRows:
+			http.Error(w, "Property not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to update property", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetUtilityExpenses lists expenses overlapping start and end (YYYY-MM-DD, default: last 365 days)
+func handleGetUtilityExpenses(w http.ResponseWriter, r *http.Request) {
+	start, end, ok := parseCalendarRange(r, 365, 0)
+	if !ok {
+		http.Error(w, "Invalid start or end date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	expenses, err := models.GetUtilityExpenses(properties, start, end)
+	if err != nil {
+		http.Error(w, "Failed to fetch utility expenses", http.StatusInternalServerError)
+		return
+	}
+	if expenses == nil {
+		expenses = []models.UtilityExpense{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(expenses); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleCreateUtilityExpense records a utility bill for a property
+func handleCreateUtilityExpense(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyID  int     `json:"property_id"`
+		UtilityType string  `json:"utility_type"`
+		PeriodStart string  `json:"period_start"` // YYYY-MM-DD
+		PeriodEnd   string  `json:"period_end"`   // YYYY-MM-DD
+		Amount      float64 `json:"amount"`
+		Vendor      string  `json:"vendor"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	periodStart, err := time.Parse("2006-01-02", req.PeriodStart)
+	if err != nil {
+		http.Error(w, "Invalid period_start, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	periodEnd, err := time.Parse("2006-01-02", req.PeriodEnd)
+	if err != nil {
+		http.Error(w, "Invalid period_end, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	expense := &models.UtilityExpense{
+		PropertyID:  req.PropertyID,
+		UtilityType: strings.ToLower(req.UtilityType),
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Amount:      req.Amount,
+		Vendor:      models.NullString(req.Vendor),
+		CreatedBy:   currentUserID(r),
+	}
+	if err := models.CreateUtilityExpense(expense); err != nil {
+		switch err {
+		case models.ErrInvalidUtilityExpense:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case sql.ErrNoRows:
+			http.Error(w, "Property not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to save utility expense", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(expense); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetUtilityBenchmarks(w http.ResponseWriter, r *http.Request) {
+	benchmarks, err := models.GetUtilityBenchmarks()
+	if err != nil {
+		http.Error(w, "Failed to fetch utility benchmarks", http.StatusInternalServerError)
+		return
+	}
+	if benchmarks == nil {
+		benchmarks = []models.UtilityBenchmark{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(benchmarks); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSaveUtilityBenchmark sets the annual cost per square foot benchmark for a utility and,
+// optionally, a property type
+func handleSaveUtilityBenchmark(w http.ResponseWriter, r *http.Request) {
+	var benchmark models.UtilityBenchmark
+	if err := json.NewDecoder(r.Body).Decode(&benchmark); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	benchmark.UtilityType = strings.ToLower(benchmark.UtilityType)
+
+	if err := models.SaveUtilityBenchmark(&benchmark); err != nil {
+		if err == models.ErrInvalidUtilityBenchmark {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		} else {
+			http.Error(w, "Failed to save utility benchmark", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(benchmark); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteUtilityBenchmark(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid benchmark ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteUtilityBenchmark(id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Utility benchmark not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete utility benchmark", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetUtilityBenchmarking compares annualized utility cost per square foot across
+// properties and against benchmarks between start and end (YYYY-MM-DD, default: last 365 days)
+func handleGetUtilityBenchmarking(w http.ResponseWriter, r *http.Request) {
+	start, end, ok := parseCalendarRange(r, 365, 0)
+	if !ok {
+		http.Error(w, "Invalid start or end date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	properties, ok := requestListScope(w, r)
+	if !ok {
+		return
+	}
+
+	rows, missingSquareFeet, err := models.GetUtilityBenchmarkRows(properties, start, end)
+	if err != nil {
+		http.Error(w, "Failed to build utility benchmarking", http.StatusInternalServerError)
+		return
+	}
+	if rows == nil {
+		rows = []models.UtilityBenchmarkRow{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"start":                         start.Format("2006-01-02"),
+		"end":                           end.Format("2006-01-02"),
+		"rows":                          rows,
+		"properties_missing_floor_area": missingSquareFeet,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/greenbrown932/fire-pmaas/pkg/webhooks"
+)
+
+// RegisterWebhookSchemaRoutes registers the JSON Schemas of webhook payloads. They describe
+// only the shape of events, so they are public for receivers to fetch when generating types
+// or validating deliveries.
+func RegisterWebhookSchemaRoutes(r chi.Router) {
+	r.Get("/api/webhooks/schemas", handleListWebhookSchemas)
+	r.Get("/api/webhooks/schemas/{event}", handleGetWebhookSchema)
+}
+
+// handleListWebhookSchemas returns every event type with its schema
+func handleListWebhookSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas := map[string]json.RawMessage{}
+	for _, eventType := range webhooks.EventTypes() {
+		schema, err := webhooks.Schema(eventType)
+		if err != nil {
+			http.Error(w, "Failed to load webhook schemas", http.StatusInternalServerError)
+			return
+		}
+		schemas[eventType] = schema
+	}
+
+	response := map[string]interface{}{
+		"event_types": webhooks.EventTypes(),
+		"schemas":     schemas,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetWebhookSchema returns the schema document of one event type
+func handleGetWebhookSchema(w http.ResponseWriter, r *http.Request) {
+	schema, err := webhooks.Schema(chi.URLParam(r, "event"))
+	if err == webhooks.ErrUnknownEvent {
+		http.Error(w, "Unknown webhook event type", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load webhook schema", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(schema)
+}
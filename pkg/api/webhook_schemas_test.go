@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSchemaRoutes(t *testing.T) {
+	r := chi.NewRouter()
+	RegisterWebhookSchemaRoutes(r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/webhooks/schemas", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var list struct {
+		EventTypes []string                   `json:"event_types"`
+		Schemas    map[string]json.RawMessage `json:"schemas"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Contains(t, list.EventTypes, "utility.anomaly")
+	assert.Len(t, list.Schemas, len(list.EventTypes))
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/webhooks/schemas/maintenance.sla_breached", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/schema+json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, string(list.Schemas["maintenance.sla_breached"]), rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/webhooks/schemas/unknown.event", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// First id available to custom number formats; lower ids are built into Excel
+const xlsxFirstCustomNumFmt = 164
+
+// Excel counts dates in days from this epoch
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// Fixed parts of the workbook. The report is a single sheet named after xlsxSheetName.
+const (
+	xlsxSheetName    = "Report"
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + xlsxSheetName + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`
+)
+
+// generateXLSX renders report data as an Excel workbook. Number, currency, percent and date
+// columns are written as numeric cells with a number format, so they sort and sum in Excel; a
+// locale, when given, sets where currency symbols go and how dates are written. A watermark, when
+// given, is printed in the page header and footer of every page.
+func generateXLSX(data *models.ReportData, watermark *models.ReportWatermark, locale *exportLocale) ([]byte, error) {
+	columns := make([]models.ReportColumn, len(data.Headers))
+	for i, header := range data.Headers {
+		columns[i], _ = data.Column(header)
+	}
+
+	// Cell styles: 0 is the default, 1 the bold header, then one per distinct number format
+	var formats []string
+	styles := make([]int, len(columns))
+	for i, column := range columns {
+		code := xlsxNumberFormat(column, locale)
+		if code == "" {
+			continue
+		}
+		id := -1
+		for j, existing := range formats {
+			if existing == code {
+				id = j
+			}
+		}
+		if id < 0 {
+			formats = append(formats, code)
+			id = len(formats) - 1
+		}
+		styles[i] = 2 + id
+	}
+
+	var sheet strings.Builder
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	sheet.WriteString(`<row r="1">`)
+	for i, header := range data.Headers {
+		writeXLSXStringCell(&sheet, xlsxCellRef(i, 1), 1, header)
+	}
+	sheet.WriteString(`</row>`)
+	for r, row := range data.Rows {
+		rowNum := r + 2
+		fmt.Fprintf(&sheet, `<row r="%d">`, rowNum)
+		for i, header := range data.Headers {
+			value, exists := row[header]
+			if !exists || value == nil {
+				continue
+			}
+			ref := xlsxCellRef(i, rowNum)
+			if number, ok := xlsxNumericValue(value, columns[i]); ok {
+				fmt.Fprintf(&sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, styles[i], strconv.FormatFloat(number, 'f', -1, 64))
+				continue
+			}
+			writeXLSXStringCell(&sheet, ref, 0, fmt.Sprintf("%v", value))
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData>`)
+	if watermark != nil {
+		// Header and footer codes start with &; a literal & is written &&
+		text := strings.ReplaceAll(watermark.Text(), "&", "&&")
+		sheet.WriteString(`<headerFooter><oddHeader>`)
+		xml.EscapeText(&sheet, []byte(`&C&"-,Bold"`+text))
+		sheet.WriteString(`</oddHeader><oddFooter>`)
+		xml.EscapeText(&sheet, []byte(`&C`+text+` - Page &P of &N`))
+		sheet.WriteString(`</oddFooter></headerFooter>`)
+	}
+	sheet.WriteString(`</worksheet>`)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles(formats)},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// xlsxNumberFormat returns the Excel number format of a typed column, or "" for text. Excel
+// shows separators as the reader's system settings say, so only the placement of currency
+// symbols and the order and language of dates depend on the locale.
+func xlsxNumberFormat(column models.ReportColumn, locale *exportLocale) string {
+	number := "#,##0"
+	if column.Decimals > 0 {
+		number += "." + strings.Repeat("0", column.Decimals)
+	}
+
+	switch column.Type {
+	case models.ColumnNumber:
+		return number
+	case models.ColumnCurrency:
+		if locale != nil && locale.currencyAfter {
+			return number + `" ` + strings.TrimSpace(currencySymbol(column.Currency)) + `"`
+		}
+		return `"` + currencySymbol(column.Currency) + `"` + number
+	case models.ColumnPercent:
+		// Values are already percentages, so the sign is a literal rather than Excel's 0%
+		return number + `"%"`
+	case models.ColumnDate:
+		if locale == nil {
+			if column.Format == models.ColumnFormatMonth {
+				return "mmm yyyy"
+			}
+			return "yyyy-mm-dd"
+		}
+		if column.Format == models.ColumnFormatMonth {
+			return "[$-" + locale.xlsxLanguage + "]mmm yyyy"
+		}
+		return "[$-" + locale.xlsxLanguage + "]" + locale.xlsxDate
+	}
+	return ""
+}
+
+// xlsxNumericValue converts a value of a numeric or date column to the number Excel stores.
+// Values not of the column's type, such as redacted ones, are written as text. Numbers in
+// undeclared columns stay numbers.
+func xlsxNumericValue(value interface{}, column models.ReportColumn) (float64, bool) {
+	if column.Type == "" || column.IsNumeric() {
+		return exportNumber(value)
+	}
+	if column.Type != models.ColumnDate {
+		return 0, false
+	}
+
+	date, ok := value.(time.Time)
+	if s, isString := value.(string); isString {
+		layout := "2006-01-02"
+		if column.Format == models.ColumnFormatMonth {
+			layout = "2006-01"
+		}
+		parsed, err := time.Parse(layout, s)
+		if err != nil {
+			return 0, false
+		}
+		date, ok = parsed, true
+	}
+	if !ok {
+		return 0, false
+	}
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return date.Sub(xlsxEpoch).Hours() / 24, true
+}
+
+// writeXLSXStringCell writes an inline string cell
+func writeXLSXStringCell(sheet *strings.Builder, ref string, style int, text string) {
+	fmt.Fprintf(sheet, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+	xml.EscapeText(sheet, []byte(text))
+	sheet.WriteString(`</t></is></c>`)
+}
+
+// xlsxCellRef returns the A1 reference of a zero-based column and one-based row
+func xlsxCellRef(col, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
+
+// xlsxStyles builds the stylesheet with the custom number formats in style order
+func xlsxStyles(formats []string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(formats) > 0 {
+		fmt.Fprintf(&b, `<numFmts count="%d">`, len(formats))
+		for i, code := range formats {
+			fmt.Fprintf(&b, `<numFmt numFmtId="%d" formatCode="`, xlsxFirstCustomNumFmt+i)
+			xml.EscapeText(&b, []byte(code))
+			b.WriteString(`"/>`)
+		}
+		b.WriteString(`</numFmts>`)
+	}
+	b.WriteString(`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>`)
+	b.WriteString(`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>`)
+	b.WriteString(`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>`)
+	b.WriteString(`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`)
+	fmt.Fprintf(&b, `<cellXfs count="%d">`, 2+len(formats))
+	b.WriteString(`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>`)
+	b.WriteString(`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>`)
+	for i := range formats {
+		fmt.Fprintf(&b, `<xf numFmtId="%d" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`, xlsxFirstCustomNumFmt+i)
+	}
+	b.WriteString(`</cellXfs></styleSheet>`)
+	return b.String()
+}
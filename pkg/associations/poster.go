@@ -0,0 +1,40 @@
+package associations
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Poster posts association dues to owners' ledgers as they fall due. Posting is idempotent, so
+// a missed run is caught up by the next one.
+type Poster struct {
+	Interval time.Duration
+}
+
+// NewPoster creates a poster that runs hourly
+func NewPoster() *Poster {
+	return &Poster{Interval: time.Hour}
+}
+
+// Run posts due assessments every Interval until the context is cancelled
+func (p *Poster) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if posted, err := models.PostDueAssessments(time.Now()); err != nil {
+			slog.Error("Posting association dues failed", "error", err)
+		} else if posted > 0 {
+			slog.Info("Posted association dues charges", "count", posted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
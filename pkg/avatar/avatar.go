@@ -0,0 +1,184 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"image"
+	"image/color"
+	_ "image/gif"  // Register the GIF decoder
+	_ "image/jpeg" // Register the JPEG decoder
+	"image/png"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Size is the width and height in pixels of processed profile pictures
+const Size = 256
+
+// MaxUploadBytes is the largest accepted upload
+const MaxUploadBytes = 5 << 20
+
+// MaxSourceDimension bounds the width and height of uploaded images so a small, highly
+// compressed file cannot decode into an enormous bitmap
+const MaxSourceDimension = 8000
+
+var (
+	// ErrUnsupportedFormat is returned for data that is not a PNG, JPEG or GIF image
+	ErrUnsupportedFormat = errors.New("image must be a PNG, JPEG or GIF")
+	// ErrTooLarge is returned for images larger than MaxUploadBytes or MaxSourceDimension
+	ErrTooLarge = errors.New("image is too large")
+)
+
+// Process validates an uploaded image, crops it to a centered square, scales it to
+// Size x Size and returns it encoded as PNG
+func Process(data []byte) ([]byte, error) {
+	if len(data) > MaxUploadBytes {
+		return nil, ErrTooLarge
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	switch format {
+	case "png", "jpeg", "gif":
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width > MaxSourceDimension || config.Height > MaxSourceDimension {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, Resize(CropSquare(src.Bounds()), src, Size)); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// CropSquare returns the largest square centered within bounds
+func CropSquare(bounds image.Rectangle) image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > h {
+		offset := (w - h) / 2
+		return image.Rect(bounds.Min.X+offset, bounds.Min.Y, bounds.Min.X+offset+h, bounds.Max.Y)
+	}
+	offset := (h - w) / 2
+	return image.Rect(bounds.Min.X, bounds.Min.Y+offset, bounds.Max.X, bounds.Min.Y+offset+w)
+}
+
+// Resize scales the area of src within rect to a size x size image
+func Resize(rect image.Rectangle, src image.Image, size int) *image.NRGBA {
+	return Scale(rect, src, size, size)
+}
+
+// Scale scales the area of src within rect to a width x height image. Each destination pixel
+// averages the source pixels it covers, so downscaling does not alias; upscaling repeats pixels.
+func Scale(rect image.Rectangle, src image.Image, width, height int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	w, h := rect.Dx(), rect.Dy()
+
+	for y := 0; y < height; y++ {
+		y0 := rect.Min.Y + y*h/height
+		y1 := rect.Min.Y + (y+1)*h/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := rect.Min.X + x*w/width
+			x1 := rect.Min.X + (x+1)*w/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+
+			// RGBA returns premultiplied 16-bit values; convert back to 8-bit non-premultiplied
+			c := color.NRGBA64{}
+			if a > 0 {
+				c = color.NRGBA64{
+					R: uint16(r * 0xffff / a),
+					G: uint16(g * 0xffff / a),
+					B: uint16(b * 0xffff / a),
+					A: uint16(a / n),
+				}
+			}
+			dst.Set(x, y, c)
+		}
+	}
+	return dst
+}
+
+// palette holds the background colors used for generated avatars
+var palette = []string{
+	"#1abc9c", "#2ecc71", "#3498db", "#9b59b6", "#34495e",
+	"#16a085", "#27ae60", "#2980b9", "#8e44ad", "#e67e22",
+	"#e74c3c", "#d35400", "#c0392b", "#7f8c8d",
+}
+
+// Initials returns up to two uppercase initials for a display name, falling back to the
+// first letter of fallback (typically the username) and finally "?"
+func Initials(name, fallback string) string {
+	var initials []rune
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				initials = append(initials, unicode.ToUpper(r))
+				break
+			}
+		}
+		if len(initials) == 2 {
+			break
+		}
+	}
+	if len(initials) == 0 {
+		for _, r := range fallback {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				initials = append(initials, unicode.ToUpper(r))
+				break
+			}
+		}
+	}
+	if len(initials) == 0 {
+		return "?"
+	}
+	return string(initials)
+}
+
+// Color picks a stable background color for key
+func Color(key string) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// WriteDefault writes an SVG avatar showing the initials on a background color derived from key
+func WriteDefault(w io.Writer, initials, key string) error {
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[1]d" viewBox="0 0 %[1]d %[1]d">`+
+		`<rect width="100%%" height="100%%" fill="%[2]s"/>`+
+		`<text x="50%%" y="50%%" dy=".35em" text-anchor="middle" fill="#ffffff" font-family="Helvetica, Arial, sans-serif" font-size="%[3]d">%[4]s</text>`+
+		`</svg>`, Size, Color(key), Size*2/5, html.EscapeString(initials))
+	return err
+}
@@ -0,0 +1,81 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessCropsAndResizes(t *testing.T) {
+	// 600x300: left and right quarters red, centre half blue
+	src := image.NewRGBA(image.Rect(0, 0, 600, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 600; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 150 && x < 450 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, src, &jpeg.Options{Quality: 100}))
+
+	out, err := Process(buf.Bytes())
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(out))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, Size, Size), img.Bounds())
+
+	// The centred square crop keeps only the blue half
+	r, _, b, _ := img.At(Size/2, Size/2).RGBA()
+	assert.Less(t, r>>8, uint32(20))
+	assert.Greater(t, b>>8, uint32(235))
+}
+
+func TestProcessRejectsInvalidImages(t *testing.T) {
+	_, err := Process([]byte("not an image"))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	_, err = Process(make([]byte, MaxUploadBytes+1))
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestCropSquare(t *testing.T) {
+	assert.Equal(t, image.Rect(50, 0, 150, 100), CropSquare(image.Rect(0, 0, 200, 100)))
+	assert.Equal(t, image.Rect(0, 25, 50, 75), CropSquare(image.Rect(0, 0, 50, 100)))
+	assert.Equal(t, image.Rect(10, 10, 20, 20), CropSquare(image.Rect(10, 10, 20, 20)))
+}
+
+func TestResizeUpscalesSmallImages(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.RGBA{G: 255, A: 255})
+	dst := Resize(src.Bounds(), src, 4)
+	assert.Equal(t, color.NRGBA{G: 255, A: 255}, dst.NRGBAAt(1, 1))
+	assert.Equal(t, color.NRGBA{}, dst.NRGBAAt(3, 3))
+}
+
+func TestInitials(t *testing.T) {
+	assert.Equal(t, "JD", Initials("Jane Doe", "jdoe"))
+	assert.Equal(t, "MA", Initials("mary ann smith", ""))
+	assert.Equal(t, "J", Initials("", "jdoe"))
+	assert.Equal(t, "?", Initials(" ", ""))
+}
+
+func TestWriteDefault(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteDefault(&buf, "<J>", "jdoe"))
+	svg := buf.String()
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, "&lt;J&gt;")
+	assert.Contains(t, svg, Color("jdoe"))
+	assert.Equal(t, Color("jdoe"), Color("jdoe"))
+}
@@ -0,0 +1,347 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// ErrNotConfigured is returned when neither a backup webhook nor pg_dump is available
+var ErrNotConfigured = errors.New("backups are not configured: set BACKUP_WEBHOOK_URL or install pg_dump")
+
+// Runner takes database backups on demand and on a schedule.
+//
+// When BACKUP_WEBHOOK_URL is set the webhook is asked to take the backup; otherwise pg_dump
+// writes a custom-format dump to BACKUP_DIR. With BACKUP_VERIFY=true each dump is restored
+// into the scratch database at BACKUP_VERIFY_DSN, which is emptied again, to prove it can be
+// restored. The application database is never touched by verification.
+type Runner struct {
+	Interval      time.Duration // Scheduled backups are taken this often; zero disables them
+	CheckInterval time.Duration
+	Dir           string
+	WebhookURL    string
+	WebhookToken  string
+	VerifyDSN     string // Scratch database dumps are restored into; empty disables verification
+	Timeout       time.Duration
+	Client        *http.Client
+}
+
+// NewRunnerFromEnv creates a runner configured from the environment
+func NewRunnerFromEnv() *Runner {
+	r := &Runner{
+		Interval:      24 * time.Hour,
+		CheckInterval: 15 * time.Minute,
+		Dir:           "backups",
+		WebhookURL:    os.Getenv("BACKUP_WEBHOOK_URL"),
+		WebhookToken:  os.Getenv("BACKUP_WEBHOOK_TOKEN"),
+		Timeout:       time.Hour,
+		Client:        &http.Client{Timeout: 5 * time.Minute},
+	}
+	if dir := os.Getenv("BACKUP_DIR"); dir != "" {
+		r.Dir = dir
+	}
+	if os.Getenv("BACKUP_VERIFY") == "true" {
+		r.VerifyDSN = os.Getenv("BACKUP_VERIFY_DSN")
+		if err := checkScratchDSN(r.VerifyDSN); err != nil {
+			slog.Warn("Backup verification disabled", "error", err)
+			r.VerifyDSN = ""
+		}
+	}
+	if value := os.Getenv("BACKUP_INTERVAL"); value != "" {
+		if interval, err := time.ParseDuration(value); err == nil {
+			r.Interval = interval
+		} else {
+			slog.Warn("Ignoring invalid BACKUP_INTERVAL", "value", value, "error", err)
+		}
+	}
+	return r
+}
+
+// Method returns how backups will be taken, or "" if they cannot be
+func (r *Runner) Method() string {
+	if r.WebhookURL != "" {
+		return models.BackupMethodWebhook
+	}
+	if db.CurrentDialect.Name() != "postgres" {
+		return ""
+	}
+	if _, err := exec.LookPath("pg_dump"); err != nil {
+		return ""
+	}
+	return models.BackupMethodPgDump
+}
+
+// Run takes a scheduled backup whenever the last one is older than Interval, until the
+// context is cancelled
+func (r *Runner) Run(ctx context.Context) {
+	if r.Interval <= 0 || r.Method() == "" {
+		return
+	}
+
+	ticker := time.NewTicker(r.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.runIfDue(ctx); err != nil {
+			slog.Error("Scheduled database backup failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) runIfDue(ctx context.Context) error {
+	last, err := models.GetLastBackupStart()
+	if err != nil {
+		return err
+	}
+	if time.Since(last) < r.Interval {
+		return nil
+	}
+
+	backup, err := r.Start(models.BackupScheduled, sql.NullInt32{})
+	if err == models.ErrBackupRunning {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.Execute(ctx, backup)
+	if backup.Status == models.BackupFailed {
+		return errors.New(backup.Error.String)
+	}
+	return nil
+}
+
+// Start records a new backup so it can be executed, possibly in the background
+func (r *Runner) Start(source string, requestedBy sql.NullInt32) (*models.DatabaseBackup, error) {
+	method := r.Method()
+	if method == "" {
+		return nil, ErrNotConfigured
+	}
+	backup := &models.DatabaseBackup{Method: method, Source: source, RequestedBy: requestedBy}
+	if err := models.StartDatabaseBackup(backup); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// Execute takes a started backup, verifies it when configured, and records the outcome
+func (r *Runner) Execute(ctx context.Context, backup *models.DatabaseBackup) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	var err error
+	if backup.Method == models.BackupMethodWebhook {
+		err = r.callWebhook(ctx, backup)
+	} else {
+		err = r.dump(ctx, backup)
+	}
+
+	backup.Status = models.BackupSucceeded
+	backup.VerifyStatus = models.VerifySkipped
+	if err != nil {
+		backup.Status = models.BackupFailed
+		backup.Error = models.NullString(err.Error())
+	} else if r.VerifyDSN != "" && backup.Method == models.BackupMethodPgDump {
+		tables, err := r.verify(ctx, backup)
+		if err != nil {
+			backup.VerifyStatus = models.VerifyFailed
+			backup.VerifyError = models.NullString(err.Error())
+		} else {
+			backup.VerifyStatus = models.VerifyPassed
+			backup.VerifiedTables = sql.NullInt32{Int32: int32(tables), Valid: true}
+		}
+	}
+
+	if err := models.FinishDatabaseBackup(backup); err != nil {
+		slog.Error("Failed to record backup outcome", "backup_id", backup.ID, "error", err)
+	}
+}
+
+// pgEnv returns the environment for pg_dump and friends; the password is passed through
+// PGPASSWORD so it does not appear in the process list
+func pgEnv() []string {
+	return append(os.Environ(),
+		"PGHOST="+os.Getenv("POSTGRES_HOST"),
+		"PGPORT="+os.Getenv("POSTGRES_PORT"),
+		"PGUSER="+os.Getenv("POSTGRES_USER"),
+		"PGPASSWORD="+os.Getenv("POSTGRES_PASSWORD"),
+		"PGDATABASE="+os.Getenv("POSTGRES_DB"),
+	)
+}
+
+// commandError includes a failed command's stderr in its error
+func commandError(name string, err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%s: %v: %s", name, err, msg)
+	}
+	return fmt.Errorf("%s: %v", name, err)
+}
+
+// dump writes the application schema to a custom-format file in Dir
+func (r *Runner) dump(ctx context.Context, backup *models.DatabaseBackup) error {
+	if err := os.MkdirAll(r.Dir, 0o750); err != nil {
+		return err
+	}
+	path := filepath.Join(r.Dir, fmt.Sprintf("fire-pmaas_%s_%d.dump", backup.StartedAt.UTC().Format("20060102T150405Z"), backup.ID))
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "pg_dump", "--format=custom", "--schema=public", "--no-owner", "--no-privileges", "--file="+path)
+	cmd.Env = pgEnv()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(path)
+		return commandError("pg_dump", err, &stderr)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+
+	backup.Location = models.NullString(path)
+	backup.SizeBytes = sql.NullInt64{Int64: size, Valid: true}
+	backup.Checksum = models.NullString(hex.EncodeToString(hash.Sum(nil)))
+	return nil
+}
+
+// checkScratchDSN rejects a verification database that is missing or is the application
+// database, since verifying replaces the restored schema
+func checkScratchDSN(dsn string) error {
+	if dsn == "" {
+		return errors.New("BACKUP_VERIFY_DSN is not set")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return errors.New("BACKUP_VERIFY_DSN must be a postgres:// URL")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "5432"
+	}
+	livePort := os.Getenv("POSTGRES_PORT")
+	if livePort == "" {
+		livePort = "5432"
+	}
+	if u.Hostname() == os.Getenv("POSTGRES_HOST") && port == livePort &&
+		strings.TrimPrefix(u.Path, "/") == os.Getenv("POSTGRES_DB") {
+		return errors.New("BACKUP_VERIFY_DSN must name a scratch database, not the application database")
+	}
+	return nil
+}
+
+// verify restores a dump into the scratch database and returns the number of tables restored.
+// The restored schema is dropped afterwards so no copy of the data is left behind.
+func (r *Runner) verify(ctx context.Context, backup *models.DatabaseBackup) (int, error) {
+	scratch, err := sql.Open("postgres", r.VerifyDSN)
+	if err != nil {
+		return 0, err
+	}
+	defer scratch.Close()
+
+	// Clear whatever an interrupted verification left behind
+	if _, err := scratch.ExecContext(ctx, "DROP SCHEMA IF EXISTS public CASCADE; CREATE SCHEMA public"); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if _, err := scratch.Exec("DROP SCHEMA IF EXISTS public CASCADE; CREATE SCHEMA public"); err != nil {
+			slog.Error("Failed to empty backup verification database", "error", err)
+		}
+	}()
+
+	var stderr bytes.Buffer
+	restore := exec.CommandContext(ctx, "pg_restore", "--clean", "--if-exists", "--no-owner", "--no-privileges",
+		"--single-transaction", "--exit-on-error", "--dbname="+r.VerifyDSN, backup.Location.String)
+	restore.Stderr = &stderr
+	if err := restore.Run(); err != nil {
+		return 0, commandError("pg_restore", err, &stderr)
+	}
+
+	var tables int
+	err = scratch.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'public'").Scan(&tables)
+	if err != nil {
+		return 0, err
+	}
+	if tables == 0 {
+		return 0, errors.New("the dump restored no tables")
+	}
+	return tables, nil
+}
+
+// webhookResponse is the optional JSON body a backup webhook returns
+type webhookResponse struct {
+	Location  string `json:"location"`
+	SizeBytes int64  `json:"size_bytes"`
+	Checksum  string `json:"checksum"`
+}
+
+// callWebhook asks an external service to take the backup. Any non-2xx response is a failure.
+func (r *Runner) callWebhook(ctx context.Context, backup *models.DatabaseBackup) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"backup_id":  backup.ID,
+		"source":     backup.Source,
+		"started_at": backup.StartedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.WebhookToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.WebhookToken)
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("backup webhook returned status %d", resp.StatusCode)
+	}
+
+	var result webhookResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid backup webhook response: %v", err)
+	}
+	backup.Location = models.NullString(result.Location)
+	if result.SizeBytes > 0 {
+		backup.SizeBytes = sql.NullInt64{Int64: result.SizeBytes, Valid: true}
+	}
+	backup.Checksum = models.NullString(result.Checksum)
+	return nil
+}
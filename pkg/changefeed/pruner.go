@@ -0,0 +1,60 @@
+package changefeed
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// RetentionDaysFromEnv returns how many days changes stay in the change feed:
+// CHANGE_FEED_RETENTION_DAYS, or models.DefaultChangeFeedRetentionDays. 0 keeps every change.
+func RetentionDaysFromEnv() int {
+	if days, err := strconv.Atoi(os.Getenv("CHANGE_FEED_RETENTION_DAYS")); err == nil && days >= 0 {
+		return days
+	}
+	return models.DefaultChangeFeedRetentionDays
+}
+
+// Pruner deletes change feed entries older than the retention so the table does not grow forever
+type Pruner struct {
+	Interval      time.Duration
+	RetentionDays int
+}
+
+// NewPruner creates a pruner that runs hourly with the configured retention
+func NewPruner() *Pruner {
+	return &Pruner{Interval: time.Hour, RetentionDays: RetentionDaysFromEnv()}
+}
+
+// Run prunes the change feed every Interval until the context is cancelled
+func (p *Pruner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := p.PruneOnce(time.Now()); err != nil {
+			slog.Error("Pruning the change feed failed", "error", err)
+		} else if deleted > 0 {
+			slog.Info("Pruned change feed entries", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PruneOnce deletes the changes that fell out of the retention by now and returns how many were
+// deleted
+func (p *Pruner) PruneOnce(now time.Time) (int64, error) {
+	if p.RetentionDays == 0 {
+		return 0, nil
+	}
+	return models.PruneEntityChanges(now.AddDate(0, 0, -p.RetentionDays))
+}
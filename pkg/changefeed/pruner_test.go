@@ -0,0 +1,19 @@
+package changefeed
+
+import (
+	"testing"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionDaysFromEnv(t *testing.T) {
+	t.Setenv("CHANGE_FEED_RETENTION_DAYS", "")
+	assert.Equal(t, models.DefaultChangeFeedRetentionDays, RetentionDaysFromEnv())
+	t.Setenv("CHANGE_FEED_RETENTION_DAYS", "30")
+	assert.Equal(t, 30, RetentionDaysFromEnv())
+	t.Setenv("CHANGE_FEED_RETENTION_DAYS", "0")
+	assert.Zero(t, RetentionDaysFromEnv())
+	t.Setenv("CHANGE_FEED_RETENTION_DAYS", "-1")
+	assert.Equal(t, models.DefaultChangeFeedRetentionDays, RetentionDaysFromEnv())
+}
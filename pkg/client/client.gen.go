@@ -0,0 +1,1726 @@
+// Package client provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.5.1 DO NOT EDIT.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oapi-codegen/runtime"
+)
+
+const (
+	ApiKeyScopes  = "apiKey.Scopes"
+	IdTokenScopes = "idToken.Scopes"
+)
+
+// Defines values for ExportRequestFormat.
+const (
+	ExportCSV   ExportRequestFormat = "csv"
+	ExportExcel ExportRequestFormat = "excel"
+	ExportPDF   ExportRequestFormat = "pdf"
+)
+
+// Defines values for ReportColumnType.
+const (
+	ColumnCurrency ReportColumnType = "currency"
+	ColumnDate     ReportColumnType = "date"
+	ColumnNumber   ReportColumnType = "number"
+	ColumnPercent  ReportColumnType = "percent"
+	ColumnString   ReportColumnType = "string"
+)
+
+// ChartData defines model for ChartData.
+type ChartData struct {
+	Config *map[string]interface{} `json:"config,omitempty"`
+	Data   map[string]interface{}  `json:"data"`
+	Title  string                  `json:"title"`
+	Type   string                  `json:"type"`
+}
+
+// CustomReport defines model for CustomReport.
+type CustomReport struct {
+	ChartConfig      *map[string]interface{} `json:"chart_config,omitempty"`
+	Columns          *[]string               `json:"columns,omitempty"`
+	Confidentiality  *string                 `json:"confidentiality,omitempty"`
+	CreatedAt        *time.Time              `json:"created_at,omitempty"`
+	CreatedBy        *int                    `json:"created_by,omitempty"`
+	Criteria         *map[string]interface{} `json:"criteria,omitempty"`
+	Description      *NullString             `json:"description,omitempty"`
+	Id               *int                    `json:"id,omitempty"`
+	IsPublic         *bool                   `json:"is_public,omitempty"`
+	IsScheduled      *bool                   `json:"is_scheduled,omitempty"`
+	LastGenerated    *NullTime               `json:"last_generated,omitempty"`
+	Name             string                  `json:"name"`
+	ReportType       string                  `json:"report_type"`
+	ScheduleCron     *NullString             `json:"schedule_cron,omitempty"`
+	ScheduleFormat   *string                 `json:"schedule_format,omitempty"`
+	ScheduleTimezone *NullString             `json:"schedule_timezone,omitempty"`
+	UpdatedAt        *time.Time              `json:"updated_at,omitempty"`
+}
+
+// ExportRequest defines model for ExportRequest.
+type ExportRequest struct {
+	Format ExportRequestFormat `json:"format"`
+
+	// Parameters Values of the report's criteria parameters, such as start_date
+	Parameters *ReportParameters `json:"parameters,omitempty"`
+}
+
+// ExportRequestFormat defines model for ExportRequest.Format.
+type ExportRequestFormat string
+
+// Message defines model for Message.
+type Message struct {
+	Message string `json:"message"`
+}
+
+// NullInt32 defines model for NullInt32.
+type NullInt32 struct {
+	Int32 int32 `json:"Int32"`
+	Valid bool  `json:"Valid"`
+}
+
+// NullString defines model for NullString.
+type NullString struct {
+	String string `json:"String"`
+	Valid  bool   `json:"Valid"`
+}
+
+// NullTime defines model for NullTime.
+type NullTime struct {
+	Time  time.Time `json:"Time"`
+	Valid bool      `json:"Valid"`
+}
+
+// QuickStat defines model for QuickStat.
+type QuickStat struct {
+	Fields []StatField `json:"fields"`
+	Name   string      `json:"name"`
+	Title  string      `json:"title"`
+}
+
+// ReportColumn defines model for ReportColumn.
+type ReportColumn struct {
+	// Currency ISO 4217 code of currency columns
+	Currency *string `json:"currency,omitempty"`
+
+	// Decimals Digits after the decimal point of number, currency and percent values
+	Decimals int `json:"decimals"`
+
+	// Format "month" for date columns holding months
+	Format *string          `json:"format,omitempty"`
+	Name   string           `json:"name"`
+	Type   ReportColumnType `json:"type"`
+}
+
+// ReportColumnType defines model for ReportColumn.Type.
+type ReportColumnType string
+
+// ReportData defines model for ReportData.
+type ReportData struct {
+	Charts *[]ChartData `json:"charts,omitempty"`
+
+	// Columns Type and formatting hints of each header, in the same order
+	Columns *[]ReportColumn `json:"columns,omitempty"`
+
+	// ComputedColumns Result columns each computed column is derived from
+	ComputedColumns *map[string][]string `json:"computed_columns,omitempty"`
+	Headers         []string             `json:"headers"`
+
+	// RedactedColumns Columns and summary fields masked for the viewer
+	RedactedColumns *[]string                `json:"redacted_columns,omitempty"`
+	Rows            []map[string]interface{} `json:"rows"`
+	Summary         *map[string]interface{}  `json:"summary,omitempty"`
+}
+
+// ReportParameters Values of the report's criteria parameters, such as start_date
+type ReportParameters map[string]interface{}
+
+// ReportTemplate defines model for ReportTemplate.
+type ReportTemplate struct {
+	Category       string                 `json:"category"`
+	CreatedAt      time.Time              `json:"created_at"`
+	CreatedBy      *NullInt32             `json:"created_by,omitempty"`
+	Description    *NullString            `json:"description,omitempty"`
+	Id             int                    `json:"id"`
+	InstallCount   int                    `json:"install_count"`
+	IsSystem       bool                   `json:"is_system"`
+	Name           string                 `json:"name"`
+	OrganizationId *NullInt32             `json:"organization_id,omitempty"`
+	ReviewNote     *NullString            `json:"review_note,omitempty"`
+	ReviewedAt     *NullTime              `json:"reviewed_at,omitempty"`
+	ReviewedBy     *NullInt32             `json:"reviewed_by,omitempty"`
+	SampleData     *ReportData            `json:"sample_data,omitempty"`
+	ScreenshotUrl  *NullString            `json:"screenshot_url,omitempty"`
+	SourceReportId *NullInt32             `json:"source_report_id,omitempty"`
+	Status         string                 `json:"status"`
+	TemplateConfig map[string]interface{} `json:"template_config"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+// StatField defines model for StatField.
+type StatField struct {
+	Breakdown *bool  `json:"breakdown,omitempty"`
+	Format    string `json:"format"`
+	Key       string `json:"key"`
+	Label     string `json:"label"`
+}
+
+// StatHistory defines model for StatHistory.
+type StatHistory struct {
+	Dates      []string    `json:"dates"`
+	Fields     []StatField `json:"fields"`
+	Name       string      `json:"name"`
+	PropertyId int         `json:"property_id"`
+
+	// Series Values of each field, one per date
+	Series map[string][]interface{} `json:"series"`
+}
+
+// PropertyID defines model for PropertyID.
+type PropertyID = int
+
+// ReportID defines model for ReportID.
+type ReportID = int
+
+// StatName defines model for StatName.
+type StatName = string
+
+// ExecuteReportParams defines parameters for ExecuteReport.
+type ExecuteReportParams struct {
+	// Locale Locale numbers and dates are formatted in
+	Locale *string `form:"locale,omitempty" json:"locale,omitempty"`
+}
+
+// GetQuickStatParams defines parameters for GetQuickStat.
+type GetQuickStatParams struct {
+	// PropertyId Property to compute the stat for; the whole portfolio when not given
+	PropertyId *PropertyID `form:"property_id,omitempty" json:"property_id,omitempty"`
+}
+
+// GetQuickStatHistoryParams defines parameters for GetQuickStatHistory.
+type GetQuickStatHistoryParams struct {
+	// PropertyId Property to compute the stat for; the whole portfolio when not given
+	PropertyId *PropertyID `form:"property_id,omitempty" json:"property_id,omitempty"`
+
+	// Days Number of days of history, 90 when not given
+	Days *int `form:"days,omitempty" json:"days,omitempty"`
+}
+
+// CreateReportJSONRequestBody defines body for CreateReport for application/json ContentType.
+type CreateReportJSONRequestBody = CustomReport
+
+// UpdateReportJSONRequestBody defines body for UpdateReport for application/json ContentType.
+type UpdateReportJSONRequestBody = CustomReport
+
+// ExecuteReportJSONRequestBody defines body for ExecuteReport for application/json ContentType.
+type ExecuteReportJSONRequestBody = ReportParameters
+
+// ExportReportJSONRequestBody defines body for ExportReport for application/json ContentType.
+type ExportReportJSONRequestBody = ExportRequest
+
+// RequestEditorFn  is the function signature for the RequestEditor callback function
+type RequestEditorFn func(ctx context.Context, req *http.Request) error
+
+// Doer performs HTTP requests.
+//
+// The standard http.Client implements this interface.
+type HttpRequestDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client which conforms to the OpenAPI3 specification for this service.
+type Client struct {
+	// The endpoint of the server conforming to this interface, with scheme,
+	// https://api.deepmap.com for example. This can contain a path relative
+	// to the server, such as https://api.deepmap.com/dev-test, and all the
+	// paths in the swagger spec will be appended to the server.
+	Server string
+
+	// Doer for performing requests, typically a *http.Client with any
+	// customized settings, such as certificate chains.
+	Client HttpRequestDoer
+
+	// A list of callbacks for modifying requests which are generated before sending over
+	// the network.
+	RequestEditors []RequestEditorFn
+}
+
+// ClientOption allows setting custom parameters during construction
+type ClientOption func(*Client) error
+
+// Creates a new Client, with reasonable defaults
+func NewClient(server string, opts ...ClientOption) (*Client, error) {
+	// create a client with sane default values
+	client := Client{
+		Server: server,
+	}
+	// mutate client and add all optional params
+	for _, o := range opts {
+		if err := o(&client); err != nil {
+			return nil, err
+		}
+	}
+	// ensure the server URL always has a trailing slash
+	if !strings.HasSuffix(client.Server, "/") {
+		client.Server += "/"
+	}
+	// create httpClient, if not already present
+	if client.Client == nil {
+		client.Client = &http.Client{}
+	}
+	return &client, nil
+}
+
+// WithHTTPClient allows overriding the default Doer, which is
+// automatically created using http.Client. This is useful for tests.
+func WithHTTPClient(doer HttpRequestDoer) ClientOption {
+	return func(c *Client) error {
+		c.Client = doer
+		return nil
+	}
+}
+
+// WithRequestEditorFn allows setting up a callback function, which will be
+// called right before sending the request. This can be used to mutate the request.
+func WithRequestEditorFn(fn RequestEditorFn) ClientOption {
+	return func(c *Client) error {
+		c.RequestEditors = append(c.RequestEditors, fn)
+		return nil
+	}
+}
+
+// The interface specification for the client above.
+type ClientInterface interface {
+	// ListReportTemplates request
+	ListReportTemplates(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// ListReports request
+	ListReports(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// CreateReportWithBody request with any body
+	CreateReportWithBody(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	CreateReport(ctx context.Context, body CreateReportJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// DeleteReport request
+	DeleteReport(ctx context.Context, id ReportID, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetReport request
+	GetReport(ctx context.Context, id ReportID, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// UpdateReportWithBody request with any body
+	UpdateReportWithBody(ctx context.Context, id ReportID, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	UpdateReport(ctx context.Context, id ReportID, body UpdateReportJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// ExecuteReportWithBody request with any body
+	ExecuteReportWithBody(ctx context.Context, id ReportID, params *ExecuteReportParams, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	ExecuteReport(ctx context.Context, id ReportID, params *ExecuteReportParams, body ExecuteReportJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// ExportReportWithBody request with any body
+	ExportReportWithBody(ctx context.Context, id ReportID, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	ExportReport(ctx context.Context, id ReportID, body ExportReportJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// ListQuickStats request
+	ListQuickStats(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetQuickStat request
+	GetQuickStat(ctx context.Context, name StatName, params *GetQuickStatParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetQuickStatHistory request
+	GetQuickStatHistory(ctx context.Context, name StatName, params *GetQuickStatHistoryParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+}
+
+func (c *Client) ListReportTemplates(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewListReportTemplatesRequest(c.Server)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ListReports(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewListReportsRequest(c.Server)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) CreateReportWithBody(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewCreateReportRequestWithBody(c.Server, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) CreateReport(ctx context.Context, body CreateReportJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewCreateReportRequest(c.Server, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) DeleteReport(ctx context.Context, id ReportID, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewDeleteReportRequest(c.Server, id)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetReport(ctx context.Context, id ReportID, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetReportRequest(c.Server, id)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) UpdateReportWithBody(ctx context.Context, id ReportID, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewUpdateReportRequestWithBody(c.Server, id, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) UpdateReport(ctx context.Context, id ReportID, body UpdateReportJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewUpdateReportRequest(c.Server, id, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ExecuteReportWithBody(ctx context.Context, id ReportID, params *ExecuteReportParams, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewExecuteReportRequestWithBody(c.Server, id, params, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ExecuteReport(ctx context.Context, id ReportID, params *ExecuteReportParams, body ExecuteReportJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewExecuteReportRequest(c.Server, id, params, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ExportReportWithBody(ctx context.Context, id ReportID, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewExportReportRequestWithBody(c.Server, id, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ExportReport(ctx context.Context, id ReportID, body ExportReportJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewExportReportRequest(c.Server, id, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ListQuickStats(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewListQuickStatsRequest(c.Server)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetQuickStat(ctx context.Context, name StatName, params *GetQuickStatParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetQuickStatRequest(c.Server, name, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetQuickStatHistory(ctx context.Context, name StatName, params *GetQuickStatHistoryParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetQuickStatHistoryRequest(c.Server, name, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+// NewListReportTemplatesRequest generates requests for ListReportTemplates
+func NewListReportTemplatesRequest(server string) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/report-templates")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewListReportsRequest generates requests for ListReports
+func NewListReportsRequest(server string) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/reports")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewCreateReportRequest calls the generic CreateReport builder with application/json body
+func NewCreateReportRequest(server string, body CreateReportJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewCreateReportRequestWithBody(server, "application/json", bodyReader)
+}
+
+// NewCreateReportRequestWithBody generates requests for CreateReport with any type of body
+func NewCreateReportRequestWithBody(server string, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/reports")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+// NewDeleteReportRequest generates requests for DeleteReport
+func NewDeleteReportRequest(server string, id ReportID) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/reports/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("DELETE", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewGetReportRequest generates requests for GetReport
+func NewGetReportRequest(server string, id ReportID) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/reports/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewUpdateReportRequest calls the generic UpdateReport builder with application/json body
+func NewUpdateReportRequest(server string, id ReportID, body UpdateReportJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewUpdateReportRequestWithBody(server, id, "application/json", bodyReader)
+}
+
+// NewUpdateReportRequestWithBody generates requests for UpdateReport with any type of body
+func NewUpdateReportRequestWithBody(server string, id ReportID, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/reports/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PUT", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+// NewExecuteReportRequest calls the generic ExecuteReport builder with application/json body
+func NewExecuteReportRequest(server string, id ReportID, params *ExecuteReportParams, body ExecuteReportJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewExecuteReportRequestWithBody(server, id, params, "application/json", bodyReader)
+}
+
+// NewExecuteReportRequestWithBody generates requests for ExecuteReport with any type of body
+func NewExecuteReportRequestWithBody(server string, id ReportID, params *ExecuteReportParams, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/reports/%s/execute", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if params.Locale != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "locale", runtime.ParamLocationQuery, *params.Locale); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+// NewExportReportRequest calls the generic ExportReport builder with application/json body
+func NewExportReportRequest(server string, id ReportID, body ExportReportJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewExportReportRequestWithBody(server, id, "application/json", bodyReader)
+}
+
+// NewExportReportRequestWithBody generates requests for ExportReport with any type of body
+func NewExportReportRequestWithBody(server string, id ReportID, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/reports/%s/export", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+// NewListQuickStatsRequest generates requests for ListQuickStats
+func NewListQuickStatsRequest(server string) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/stats")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewGetQuickStatRequest generates requests for GetQuickStat
+func NewGetQuickStatRequest(server string, name StatName, params *GetQuickStatParams) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "name", runtime.ParamLocationPath, name)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/stats/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if params.PropertyId != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "property_id", runtime.ParamLocationQuery, *params.PropertyId); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewGetQuickStatHistoryRequest generates requests for GetQuickStatHistory
+func NewGetQuickStatHistoryRequest(server string, name StatName, params *GetQuickStatHistoryParams) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "name", runtime.ParamLocationPath, name)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/stats/%s/history", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if params.PropertyId != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "property_id", runtime.ParamLocationQuery, *params.PropertyId); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Days != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "days", runtime.ParamLocationQuery, *params.Days); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (c *Client) applyEditors(ctx context.Context, req *http.Request, additionalEditors []RequestEditorFn) error {
+	for _, r := range c.RequestEditors {
+		if err := r(ctx, req); err != nil {
+			return err
+		}
+	}
+	for _, r := range additionalEditors {
+		if err := r(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClientWithResponses builds on ClientInterface to offer response payloads
+type ClientWithResponses struct {
+	ClientInterface
+}
+
+// NewClientWithResponses creates a new ClientWithResponses, which wraps
+// Client with return type handling
+func NewClientWithResponses(server string, opts ...ClientOption) (*ClientWithResponses, error) {
+	client, err := NewClient(server, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientWithResponses{client}, nil
+}
+
+// WithBaseURL overrides the baseURL.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) error {
+		newBaseURL, err := url.Parse(baseURL)
+		if err != nil {
+			return err
+		}
+		c.Server = newBaseURL.String()
+		return nil
+	}
+}
+
+// ClientWithResponsesInterface is the interface specification for the client with responses above.
+type ClientWithResponsesInterface interface {
+	// ListReportTemplatesWithResponse request
+	ListReportTemplatesWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*ListReportTemplatesResponse, error)
+
+	// ListReportsWithResponse request
+	ListReportsWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*ListReportsResponse, error)
+
+	// CreateReportWithBodyWithResponse request with any body
+	CreateReportWithBodyWithResponse(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*CreateReportResponse, error)
+
+	CreateReportWithResponse(ctx context.Context, body CreateReportJSONRequestBody, reqEditors ...RequestEditorFn) (*CreateReportResponse, error)
+
+	// DeleteReportWithResponse request
+	DeleteReportWithResponse(ctx context.Context, id ReportID, reqEditors ...RequestEditorFn) (*DeleteReportResponse, error)
+
+	// GetReportWithResponse request
+	GetReportWithResponse(ctx context.Context, id ReportID, reqEditors ...RequestEditorFn) (*GetReportResponse, error)
+
+	// UpdateReportWithBodyWithResponse request with any body
+	UpdateReportWithBodyWithResponse(ctx context.Context, id ReportID, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*UpdateReportResponse, error)
+
+	UpdateReportWithResponse(ctx context.Context, id ReportID, body UpdateReportJSONRequestBody, reqEditors ...RequestEditorFn) (*UpdateReportResponse, error)
+
+	// ExecuteReportWithBodyWithResponse request with any body
+	ExecuteReportWithBodyWithResponse(ctx context.Context, id ReportID, params *ExecuteReportParams, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*ExecuteReportResponse, error)
+
+	ExecuteReportWithResponse(ctx context.Context, id ReportID, params *ExecuteReportParams, body ExecuteReportJSONRequestBody, reqEditors ...RequestEditorFn) (*ExecuteReportResponse, error)
+
+	// ExportReportWithBodyWithResponse request with any body
+	ExportReportWithBodyWithResponse(ctx context.Context, id ReportID, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*ExportReportResponse, error)
+
+	ExportReportWithResponse(ctx context.Context, id ReportID, body ExportReportJSONRequestBody, reqEditors ...RequestEditorFn) (*ExportReportResponse, error)
+
+	// ListQuickStatsWithResponse request
+	ListQuickStatsWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*ListQuickStatsResponse, error)
+
+	// GetQuickStatWithResponse request
+	GetQuickStatWithResponse(ctx context.Context, name StatName, params *GetQuickStatParams, reqEditors ...RequestEditorFn) (*GetQuickStatResponse, error)
+
+	// GetQuickStatHistoryWithResponse request
+	GetQuickStatHistoryWithResponse(ctx context.Context, name StatName, params *GetQuickStatHistoryParams, reqEditors ...RequestEditorFn) (*GetQuickStatHistoryResponse, error)
+}
+
+type ListReportTemplatesResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *[]ReportTemplate
+}
+
+// Status returns HTTPResponse.Status
+func (r ListReportTemplatesResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r ListReportTemplatesResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type ListReportsResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *[]CustomReport
+}
+
+// Status returns HTTPResponse.Status
+func (r ListReportsResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r ListReportsResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type CreateReportResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON201      *CustomReport
+}
+
+// Status returns HTTPResponse.Status
+func (r CreateReportResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r CreateReportResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type DeleteReportResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+}
+
+// Status returns HTTPResponse.Status
+func (r DeleteReportResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r DeleteReportResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetReportResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *CustomReport
+}
+
+// Status returns HTTPResponse.Status
+func (r GetReportResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetReportResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type UpdateReportResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *Message
+}
+
+// Status returns HTTPResponse.Status
+func (r UpdateReportResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r UpdateReportResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type ExecuteReportResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *ReportData
+}
+
+// Status returns HTTPResponse.Status
+func (r ExecuteReportResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r ExecuteReportResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type ExportReportResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+}
+
+// Status returns HTTPResponse.Status
+func (r ExportReportResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r ExportReportResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type ListQuickStatsResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *[]QuickStat
+}
+
+// Status returns HTTPResponse.Status
+func (r ListQuickStatsResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r ListQuickStatsResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetQuickStatResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *map[string]interface{}
+}
+
+// Status returns HTTPResponse.Status
+func (r GetQuickStatResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetQuickStatResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetQuickStatHistoryResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *StatHistory
+}
+
+// Status returns HTTPResponse.Status
+func (r GetQuickStatHistoryResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetQuickStatHistoryResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+// ListReportTemplatesWithResponse request returning *ListReportTemplatesResponse
+func (c *ClientWithResponses) ListReportTemplatesWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*ListReportTemplatesResponse, error) {
+	rsp, err := c.ListReportTemplates(ctx, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseListReportTemplatesResponse(rsp)
+}
+
+// ListReportsWithResponse request returning *ListReportsResponse
+func (c *ClientWithResponses) ListReportsWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*ListReportsResponse, error) {
+	rsp, err := c.ListReports(ctx, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseListReportsResponse(rsp)
+}
+
+// CreateReportWithBodyWithResponse request with arbitrary body returning *CreateReportResponse
+func (c *ClientWithResponses) CreateReportWithBodyWithResponse(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*CreateReportResponse, error) {
+	rsp, err := c.CreateReportWithBody(ctx, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCreateReportResponse(rsp)
+}
+
+func (c *ClientWithResponses) CreateReportWithResponse(ctx context.Context, body CreateReportJSONRequestBody, reqEditors ...RequestEditorFn) (*CreateReportResponse, error) {
+	rsp, err := c.CreateReport(ctx, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCreateReportResponse(rsp)
+}
+
+// DeleteReportWithResponse request returning *DeleteReportResponse
+func (c *ClientWithResponses) DeleteReportWithResponse(ctx context.Context, id ReportID, reqEditors ...RequestEditorFn) (*DeleteReportResponse, error) {
+	rsp, err := c.DeleteReport(ctx, id, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDeleteReportResponse(rsp)
+}
+
+// GetReportWithResponse request returning *GetReportResponse
+func (c *ClientWithResponses) GetReportWithResponse(ctx context.Context, id ReportID, reqEditors ...RequestEditorFn) (*GetReportResponse, error) {
+	rsp, err := c.GetReport(ctx, id, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetReportResponse(rsp)
+}
+
+// UpdateReportWithBodyWithResponse request with arbitrary body returning *UpdateReportResponse
+func (c *ClientWithResponses) UpdateReportWithBodyWithResponse(ctx context.Context, id ReportID, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*UpdateReportResponse, error) {
+	rsp, err := c.UpdateReportWithBody(ctx, id, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseUpdateReportResponse(rsp)
+}
+
+func (c *ClientWithResponses) UpdateReportWithResponse(ctx context.Context, id ReportID, body UpdateReportJSONRequestBody, reqEditors ...RequestEditorFn) (*UpdateReportResponse, error) {
+	rsp, err := c.UpdateReport(ctx, id, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseUpdateReportResponse(rsp)
+}
+
+// ExecuteReportWithBodyWithResponse request with arbitrary body returning *ExecuteReportResponse
+func (c *ClientWithResponses) ExecuteReportWithBodyWithResponse(ctx context.Context, id ReportID, params *ExecuteReportParams, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*ExecuteReportResponse, error) {
+	rsp, err := c.ExecuteReportWithBody(ctx, id, params, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseExecuteReportResponse(rsp)
+}
+
+func (c *ClientWithResponses) ExecuteReportWithResponse(ctx context.Context, id ReportID, params *ExecuteReportParams, body ExecuteReportJSONRequestBody, reqEditors ...RequestEditorFn) (*ExecuteReportResponse, error) {
+	rsp, err := c.ExecuteReport(ctx, id, params, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseExecuteReportResponse(rsp)
+}
+
+// ExportReportWithBodyWithResponse request with arbitrary body returning *ExportReportResponse
+func (c *ClientWithResponses) ExportReportWithBodyWithResponse(ctx context.Context, id ReportID, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*ExportReportResponse, error) {
+	rsp, err := c.ExportReportWithBody(ctx, id, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseExportReportResponse(rsp)
+}
+
+func (c *ClientWithResponses) ExportReportWithResponse(ctx context.Context, id ReportID, body ExportReportJSONRequestBody, reqEditors ...RequestEditorFn) (*ExportReportResponse, error) {
+	rsp, err := c.ExportReport(ctx, id, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseExportReportResponse(rsp)
+}
+
+// ListQuickStatsWithResponse request returning *ListQuickStatsResponse
+func (c *ClientWithResponses) ListQuickStatsWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*ListQuickStatsResponse, error) {
+	rsp, err := c.ListQuickStats(ctx, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseListQuickStatsResponse(rsp)
+}
+
+// GetQuickStatWithResponse request returning *GetQuickStatResponse
+func (c *ClientWithResponses) GetQuickStatWithResponse(ctx context.Context, name StatName, params *GetQuickStatParams, reqEditors ...RequestEditorFn) (*GetQuickStatResponse, error) {
+	rsp, err := c.GetQuickStat(ctx, name, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetQuickStatResponse(rsp)
+}
+
+// GetQuickStatHistoryWithResponse request returning *GetQuickStatHistoryResponse
+func (c *ClientWithResponses) GetQuickStatHistoryWithResponse(ctx context.Context, name StatName, params *GetQuickStatHistoryParams, reqEditors ...RequestEditorFn) (*GetQuickStatHistoryResponse, error) {
+	rsp, err := c.GetQuickStatHistory(ctx, name, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetQuickStatHistoryResponse(rsp)
+}
+
+// ParseListReportTemplatesResponse parses an HTTP response from a ListReportTemplatesWithResponse call
+func ParseListReportTemplatesResponse(rsp *http.Response) (*ListReportTemplatesResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ListReportTemplatesResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest []ReportTemplate
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseListReportsResponse parses an HTTP response from a ListReportsWithResponse call
+func ParseListReportsResponse(rsp *http.Response) (*ListReportsResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ListReportsResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest []CustomReport
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseCreateReportResponse parses an HTTP response from a CreateReportWithResponse call
+func ParseCreateReportResponse(rsp *http.Response) (*CreateReportResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CreateReportResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 201:
+		var dest CustomReport
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON201 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseDeleteReportResponse parses an HTTP response from a DeleteReportWithResponse call
+func ParseDeleteReportResponse(rsp *http.Response) (*DeleteReportResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &DeleteReportResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	return response, nil
+}
+
+// ParseGetReportResponse parses an HTTP response from a GetReportWithResponse call
+func ParseGetReportResponse(rsp *http.Response) (*GetReportResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetReportResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest CustomReport
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseUpdateReportResponse parses an HTTP response from a UpdateReportWithResponse call
+func ParseUpdateReportResponse(rsp *http.Response) (*UpdateReportResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &UpdateReportResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest Message
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseExecuteReportResponse parses an HTTP response from a ExecuteReportWithResponse call
+func ParseExecuteReportResponse(rsp *http.Response) (*ExecuteReportResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ExecuteReportResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest ReportData
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseExportReportResponse parses an HTTP response from a ExportReportWithResponse call
+func ParseExportReportResponse(rsp *http.Response) (*ExportReportResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ExportReportResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	return response, nil
+}
+
+// ParseListQuickStatsResponse parses an HTTP response from a ListQuickStatsWithResponse call
+func ParseListQuickStatsResponse(rsp *http.Response) (*ListQuickStatsResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ListQuickStatsResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest []QuickStat
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseGetQuickStatResponse parses an HTTP response from a GetQuickStatWithResponse call
+func ParseGetQuickStatResponse(rsp *http.Response) (*GetQuickStatResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetQuickStatResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest map[string]interface{}
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseGetQuickStatHistoryResponse parses an HTTP response from a GetQuickStatHistoryWithResponse call
+func ParseGetQuickStatHistoryResponse(rsp *http.Response) (*GetQuickStatHistoryResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetQuickStatHistoryResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest StatHistory
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/reports/7/execute", r.URL.Path)
+		cookie, err := r.Cookie("id_token")
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", cookie.Value)
+
+		var params map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		assert.Equal(t, "2024-01-01", params["start_date"])
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"headers":["Property","Rent"],"rows":[{"Property":"Maple","Rent":1200.5}],
+			"columns":[{"name":"Property","type":"string","decimals":0},{"name":"Rent","type":"currency","decimals":2}]}`))
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL+"/", WithIDToken("token-1"))
+	require.NoError(t, err)
+	resp, err := c.ExecuteReportWithResponse(context.Background(), 7, nil, ReportParameters{"start_date": "2024-01-01"})
+	require.NoError(t, err)
+	require.NoError(t, CheckResponse(resp.StatusCode(), resp.Body))
+	data := resp.JSON200
+	require.NotNil(t, data)
+	assert.Equal(t, []string{"Property", "Rent"}, data.Headers)
+	assert.Equal(t, 1200.5, data.Rows[0]["Rent"])
+	require.NotNil(t, data.Columns)
+	assert.Equal(t, ColumnCurrency, (*data.Columns)[1].Type)
+}
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key-1", r.Header.Get("X-API-Key"))
+		http.Error(w, "Report not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, WithAPIKey("key-1"))
+	require.NoError(t, err)
+	resp, err := c.GetReportWithResponse(context.Background(), 3)
+	require.NoError(t, err)
+	assert.Nil(t, resp.JSON200)
+	err = CheckResponse(resp.StatusCode(), resp.Body)
+	require.Error(t, err)
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, "fire-pmaas: 404 Not Found: Report not found", err.Error())
+}
+
+func TestExportReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body ExportRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, ExportCSV, body.Format)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("\"Property\"\n\"Maple\"\n"))
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+	resp, err := c.ExportReport(context.Background(), 2, ExportRequest{Format: ExportCSV})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "\"Property\"\n\"Maple\"\n", string(content))
+}
+
+func TestGetQuickStatHistoryQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/stats/occupancy/history", r.URL.Path)
+		assert.Equal(t, "30", r.URL.Query().Get("days"))
+		assert.Equal(t, "5", r.URL.Query().Get("property_id"))
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+	propertyID, days := 5, 30
+	_, err = c.GetQuickStatHistory(context.Background(), "occupancy", &GetQuickStatHistoryParams{PropertyId: &propertyID, Days: &days})
+	require.NoError(t, err)
+}
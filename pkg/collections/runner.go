@@ -0,0 +1,41 @@
+package collections
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Runner moves delinquent leases through the collection stages. A run is idempotent, so a missed
+// run is caught up by the next one.
+type Runner struct {
+	Interval time.Duration
+}
+
+// NewRunner creates a runner that runs hourly
+func NewRunner() *Runner {
+	return &Runner{Interval: time.Hour}
+}
+
+// Run brings the collections workflow up to date every Interval until the context is cancelled
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		if result, err := models.RunCollections(time.Now()); err != nil {
+			slog.Error("Collections run failed", "error", err)
+		} else if result.Opened+result.Reminders+result.Notices+result.Resolved > 0 {
+			slog.Info("Ran collections", "opened", result.Opened, "reminders", result.Reminders,
+				"notices", result.Notices, "resolved", result.Resolved)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings that are validated at startup rather than read on first use
+type Config struct {
+	OIDC          OIDC            `yaml:"oidc"`
+	Cookies       Cookies         `yaml:"cookies"`
+	Features      map[string]bool `yaml:"features"`       // Feature flags sent to clients by name
+	ApprovalRoles []string        `yaml:"approval_roles"` // Roles a second admin must approve granting
+}
+
+// OIDC configures the OpenID Connect client used to sign users in
+type OIDC struct {
+	Issuer       string   `yaml:"issuer"`        // Realm URL, e.g. https://auth.example.com/realms/fire
+	ClientID     string   `yaml:"client_id"`     // Must match the client registered with the provider
+	ClientSecret string   `yaml:"client_secret"` // Confidential client secret
+	RedirectURL  string   `yaml:"redirect_url"`  // Callback URL registered with the provider
+	Scopes       []string `yaml:"scopes"`        // Requested scopes; must include openid
+}
+
+// Cookies configures the security flags of session and login cookies
+type Cookies struct {
+	Secure   *bool  `yaml:"secure"`    // Send only over HTTPS; defaults to true for an https redirect URL
+	SameSite string `yaml:"same_site"` // lax, strict or none
+}
+
+// Default is the configuration loaded by Init. Until then it holds the built-in defaults.
+var Default = defaults()
+
+// defaults returns the configuration used when neither the file nor the environment sets a value
+func defaults() *Config {
+	return &Config{
+		OIDC: OIDC{
+			ClientID: "pmaas-app",
+			Scopes:   []string{"openid", "profile", "email"},
+		},
+		Cookies:       Cookies{SameSite: "lax"},
+		ApprovalRoles: []string{"admin"},
+	}
+}
+
+// Init loads the configuration from the file named by CONFIG_FILE, if set, and the environment,
+// and makes it Default when it is valid
+func Init() (*Config, error) {
+	cfg, err := Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	Default = cfg
+	return cfg, nil
+}
+
+// Load reads the YAML or JSON config file at path, when path is not empty, applies environment
+// overrides and validates the result. Environment variables take precedence over the file:
+//
+//	KEYCLOAK_ISSUER     oidc.issuer
+//	OIDC_CLIENT_ID      oidc.client_id
+//	OIDC_CLIENT_SECRET  oidc.client_secret
+//	OIDC_REDIRECT_URL   oidc.redirect_url (default APP_BASE_URL + /callback)
+//	OIDC_SCOPES         oidc.scopes, separated by spaces or commas
+//	COOKIE_SECURE       cookies.secure
+//	COOKIE_SAMESITE     cookies.same_site
+//	FEATURE_FLAGS       features, e.g. "new_dashboard,-beta_reports" to turn one on and one off
+//	APPROVAL_ROLES      approval_roles, separated by spaces or commas
+func Load(path string) (*Config, error) {
+	cfg := defaults()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if cfg.OIDC.RedirectURL == "" {
+		cfg.OIDC.RedirectURL = defaultRedirectURL()
+	}
+	if cfg.Cookies.Secure == nil {
+		secure := strings.HasPrefix(cfg.OIDC.RedirectURL, "https://")
+		cfg.Cookies.Secure = &secure
+	}
+	return cfg, cfg.Validate()
+}
+
+// applyEnv overrides file settings with the environment variables that are set
+func (c *Config) applyEnv() error {
+	setString := func(target *string, name string) {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+	setString(&c.OIDC.Issuer, "KEYCLOAK_ISSUER")
+	setString(&c.OIDC.ClientID, "OIDC_CLIENT_ID")
+	setString(&c.OIDC.ClientSecret, "OIDC_CLIENT_SECRET")
+	setString(&c.OIDC.RedirectURL, "OIDC_REDIRECT_URL")
+	setString(&c.Cookies.SameSite, "COOKIE_SAMESITE")
+
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		c.OIDC.Scopes = strings.FieldsFunc(scopes, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	if value := os.Getenv("COOKIE_SECURE"); value != "" {
+		secure, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("COOKIE_SECURE must be true or false, got %q", value)
+		}
+		c.Cookies.Secure = &secure
+	}
+	if roles := os.Getenv("APPROVAL_ROLES"); roles != "" {
+		c.ApprovalRoles = strings.FieldsFunc(roles, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	if flags := os.Getenv("FEATURE_FLAGS"); flags != "" {
+		if c.Features == nil {
+			c.Features = map[string]bool{}
+		}
+		for _, flag := range strings.FieldsFunc(flags, func(r rune) bool { return r == ',' || r == ' ' }) {
+			if name := strings.TrimPrefix(flag, "-"); name != flag {
+				c.Features[name] = false
+			} else {
+				c.Features[flag] = true
+			}
+		}
+	}
+	return nil
+}
+
+// defaultRedirectURL is the /callback route under APP_BASE_URL, or on localhost when it is unset
+func defaultRedirectURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/") + "/callback"
+	}
+	return "http://localhost:8000/callback"
+}
+
+// Validate reports every problem with the configuration at once
+func (c *Config) Validate() error {
+	var problems []error
+	if err := validateURL(c.OIDC.Issuer); err != nil {
+		problems = append(problems, fmt.Errorf("oidc.issuer (KEYCLOAK_ISSUER): %w", err))
+	}
+	if c.OIDC.ClientID == "" {
+		problems = append(problems, errors.New("oidc.client_id (OIDC_CLIENT_ID) is required"))
+	}
+	if c.OIDC.ClientSecret == "" {
+		problems = append(problems, errors.New("oidc.client_secret (OIDC_CLIENT_SECRET) is required"))
+	}
+	if err := validateURL(c.OIDC.RedirectURL); err != nil {
+		problems = append(problems, fmt.Errorf("oidc.redirect_url (OIDC_REDIRECT_URL): %w", err))
+	}
+	if !containsString(c.OIDC.Scopes, "openid") {
+		problems = append(problems, errors.New("oidc.scopes (OIDC_SCOPES) must include openid"))
+	}
+
+	switch strings.ToLower(c.Cookies.SameSite) {
+	case "lax", "strict":
+	case "none":
+		if !c.Cookies.IsSecure() {
+			problems = append(problems, errors.New("cookies.same_site none requires secure cookies"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("cookies.same_site (COOKIE_SAMESITE) must be lax, strict or none, got %q",
+			c.Cookies.SameSite))
+	}
+	return errors.Join(problems...)
+}
+
+// validateURL checks that a setting is an absolute http or https URL
+func validateURL(value string) error {
+	if value == "" {
+		return errors.New("is required")
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("must be an absolute http or https URL, got %q", value)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// FeatureEnabled reports whether a feature flag is turned on. Unknown flags are off.
+func (c *Config) FeatureEnabled(name string) bool {
+	return c.Features[name]
+}
+
+// RequiresApproval reports whether granting a role needs a second admin's approval
+func (c *Config) RequiresApproval(role string) bool {
+	return containsString(c.ApprovalRoles, role)
+}
+
+// IsSecure reports whether cookies are sent only over HTTPS
+func (c Cookies) IsSecure() bool {
+	return c.Secure != nil && *c.Secure
+}
+
+// SameSiteMode returns the SameSite attribute for cookies, defaulting to Lax
+func (c Cookies) SameSiteMode() http.SameSite {
+	switch strings.ToLower(c.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
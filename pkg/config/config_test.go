@@ -0,0 +1,101 @@
+package config
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv unsets every variable Load reads so the host environment can't leak into a test
+func clearEnv(t *testing.T) {
+	for _, name := range []string{"KEYCLOAK_ISSUER", "OIDC_CLIENT_ID", "OIDC_CLIENT_SECRET", "OIDC_REDIRECT_URL",
+		"OIDC_SCOPES", "COOKIE_SECURE", "COOKIE_SAMESITE", "APP_BASE_URL", "FEATURE_FLAGS", "APPROVAL_ROLES"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("KEYCLOAK_ISSUER", "http://localhost:8080/realms/fire")
+	t.Setenv("OIDC_CLIENT_SECRET", "s3cret")
+	t.Setenv("OIDC_SCOPES", "openid, email offline_access")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, "pmaas-app", cfg.OIDC.ClientID)
+	assert.Equal(t, "http://localhost:8000/callback", cfg.OIDC.RedirectURL)
+	assert.Equal(t, []string{"openid", "email", "offline_access"}, cfg.OIDC.Scopes)
+	assert.False(t, cfg.Cookies.IsSecure())
+	assert.Equal(t, http.SameSiteLaxMode, cfg.Cookies.SameSiteMode())
+	assert.True(t, cfg.RequiresApproval("admin"))
+	assert.False(t, cfg.RequiresApproval("tenant"))
+}
+
+func TestLoadFileWithEnvOverrides(t *testing.T) {
+	clearEnv(t)
+	path := filepath.Join(t.TempDir(), "fire.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+oidc:
+  issuer: https://auth.example.com/realms/fire
+  client_id: fire-web
+  client_secret: from-file
+cookies:
+  same_site: strict
+features:
+  new_dashboard: true
+  beta_reports: true
+`), 0o600))
+	t.Setenv("OIDC_CLIENT_SECRET", "from-env")
+	t.Setenv("FEATURE_FLAGS", "-beta_reports,ach_payments")
+	t.Setenv("APPROVAL_ROLES", "admin property_manager")
+	t.Setenv("APP_BASE_URL", "https://pm.example.com/")
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "fire-web", cfg.OIDC.ClientID)
+	assert.Equal(t, "from-env", cfg.OIDC.ClientSecret)
+	assert.Equal(t, "https://pm.example.com/callback", cfg.OIDC.RedirectURL)
+	assert.True(t, cfg.Cookies.IsSecure(), "an https redirect URL defaults to secure cookies")
+	assert.Equal(t, http.SameSiteStrictMode, cfg.Cookies.SameSiteMode())
+	assert.True(t, cfg.FeatureEnabled("new_dashboard"))
+	assert.False(t, cfg.FeatureEnabled("beta_reports"), "the environment turns off a flag the file turns on")
+	assert.True(t, cfg.FeatureEnabled("ach_payments"))
+	assert.False(t, cfg.FeatureEnabled("unknown"))
+	assert.True(t, cfg.RequiresApproval("property_manager"))
+
+	t.Setenv("COOKIE_SECURE", "false")
+	cfg, err = Load(path)
+	require.NoError(t, err)
+	assert.False(t, cfg.Cookies.IsSecure())
+}
+
+func TestLoadRejectsUnknownFileFields(t *testing.T) {
+	clearEnv(t)
+	path := filepath.Join(t.TempDir(), "fire.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("oidc:\n  client_secrt: typo\n"), 0o600))
+
+	_, err := Load(path)
+	assert.ErrorContains(t, err, "client_secrt")
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("KEYCLOAK_ISSUER", "localhost:8080")
+	t.Setenv("OIDC_SCOPES", "profile")
+	t.Setenv("COOKIE_SAMESITE", "none")
+
+	_, err := Load("")
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "oidc.issuer")
+	assert.ErrorContains(t, err, "oidc.client_secret (OIDC_CLIENT_SECRET) is required")
+	assert.ErrorContains(t, err, "must include openid")
+	assert.ErrorContains(t, err, "same_site none requires secure cookies")
+
+	t.Setenv("COOKIE_SECURE", "maybe")
+	_, err = Load("")
+	assert.ErrorContains(t, err, "COOKIE_SECURE")
+}
@@ -0,0 +1,249 @@
+// Package cron parses five-field cron expressions and finds their next run in a time zone.
+//
+// Schedules are evaluated on the local wall clock, so "0 8 1 * *" runs at 08:00 local time on
+// the 1st whatever the UTC offset is that day. Daylight saving transitions are handled the way
+// most cron daemons do: a run that falls in the hour skipped when clocks go forward happens at the
+// moment of the transition, and a run in the hour repeated when clocks go back happens only once,
+// the first time that wall-clock time is reached.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "time/tzdata" // Time zones must load even where the host has no zoneinfo database
+)
+
+// ErrInvalidExpression is returned for an expression Parse cannot read
+var ErrInvalidExpression = errors.New("invalid cron expression")
+
+// searchYears bounds the search for the next run, so expressions that can never match, such as
+// "0 0 30 2 *", do not loop forever
+const searchYears = 5
+
+// Schedule is a parsed cron expression. Each field is a bit set of the values it matches.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// A restricted day of month and day of week match days that satisfy either, as in Vixie cron
+	domRestricted, dowRestricted bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	// Sunday is 0 or 7
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+// macros are the supported shorthand expressions
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse reads a standard five-field expression (minute, hour, day of month, month, day of week)
+// or one of the macros @yearly, @monthly, @weekly, @daily and @hourly. Fields accept *, values,
+// ranges (1-5), steps (*/15, 0-30/10) and comma-separated lists; months and days of the week may
+// be given by their three-letter English names.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := macros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w %q: expected 5 fields, got %d", ErrInvalidExpression, expr, len(fields))
+	}
+
+	s := &Schedule{}
+	var err error
+	if s.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = fields[2] != "*" && !strings.HasPrefix(fields[2], "*/")
+	s.dowRestricted = fields[4] != "*" && !strings.HasPrefix(fields[4], "*/")
+	return s, nil
+}
+
+// MustParse is like Parse but panics on an invalid expression. It is meant for constants.
+func MustParse(expr string) *Schedule {
+	s, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parseField parses one comma-separated field into a bit set
+func parseField(text string, f field) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(text, ",") {
+		rangeText, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, fmt.Errorf("%w: invalid step %q in %s field", ErrInvalidExpression, stepText, f.name)
+			}
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangeText == "*":
+		case strings.Contains(rangeText, "-"):
+			lowText, highText, _ := strings.Cut(rangeText, "-")
+			var err error
+			if low, err = parseValue(lowText, f); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(highText, f); err != nil {
+				return 0, err
+			}
+			if high < low {
+				return 0, fmt.Errorf("%w: range %q in %s field ends before it starts", ErrInvalidExpression, rangeText, f.name)
+			}
+		default:
+			var err error
+			if low, err = parseValue(rangeText, f); err != nil {
+				return 0, err
+			}
+			// A single value with a step, such as 5/15, runs from the value to the end of the range
+			if !hasStep {
+				high = low
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// parseValue parses a number or name within a field's range
+func parseValue(text string, f field) (int, error) {
+	if v, ok := f.names[strings.ToUpper(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%w: %q is not a valid %s (%d-%d)", ErrInvalidExpression, text, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// matchesDay reports whether the schedule runs on a calendar day
+func (s *Schedule) matchesDay(year int, month time.Month, day int) bool {
+	if s.month&(1<<uint(month)) == 0 {
+		return false
+	}
+	weekday := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday()
+	domMatch := s.dom&(1<<uint(day)) != 0
+	dowMatch := s.dow&(1<<uint(weekday)) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next returns the first run strictly after t on loc's wall clock, or the zero time if the
+// schedule has no run within the next five years. A nil loc means UTC.
+func (s *Schedule) Next(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	// Wall-clock minutes are compared as numbers so the repeated hour when clocks go back is
+	// not visited twice
+	afterMinute := local.Hour()*60 + local.Minute()
+
+	year, month, day := local.Date()
+	for i := 0; i < searchYears*366; i++ {
+		date := time.Date(year, month, day+i, 0, 0, 0, 0, time.UTC)
+		y, m, d := date.Date()
+		if !s.matchesDay(y, m, d) {
+			continue
+		}
+		for hours := s.hour; hours != 0; hours &= hours - 1 {
+			h := bits.TrailingZeros64(hours)
+			for minutes := s.minute; minutes != 0; minutes &= minutes - 1 {
+				min := bits.TrailingZeros64(minutes)
+				if i == 0 && h*60+min <= afterMinute {
+					continue
+				}
+				if run := resolve(y, m, d, h, min, loc); run.After(t) {
+					return run
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// resolve returns the instant a wall-clock time occurs in loc. A time skipped by a forward
+// transition resolves to the transition; a time repeated by a backward one to its first occurrence.
+func resolve(year int, month time.Month, day, hour, min int, loc *time.Location) time.Time {
+	run := time.Date(year, month, day, hour, min, 0, 0, loc)
+	if run.Hour() != hour || run.Minute() != min {
+		// In a gap: time.Date moved the time to one side of the transition
+		start, end := run.ZoneBounds()
+		if run.Hour()*60+run.Minute() > hour*60+min {
+			return start
+		}
+		return end
+	}
+
+	// A time within a backward transition's overlap also occurs under the previous, larger offset
+	start, _ := run.ZoneBounds()
+	if start.IsZero() {
+		return run
+	}
+	_, offset := run.Zone()
+	_, previousOffset := start.Add(-time.Nanosecond).Zone()
+	if previousOffset > offset {
+		earlier := run.Add(-time.Duration(previousOffset-offset) * time.Second)
+		if earlier.Hour() == hour && earlier.Minute() == min && earlier.Before(start) {
+			return earlier
+		}
+	}
+	return run
+}
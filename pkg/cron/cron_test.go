@@ -0,0 +1,77 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+		"* * * * 8", "*/0 * * * *", "5-1 * * * *", "* * * FOO *"} {
+		_, err := Parse(expr)
+		assert.ErrorIs(t, err, ErrInvalidExpression, expr)
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Friday 16 October 2026, 10:20 UTC
+	now := time.Date(2026, 10, 16, 10, 20, 0, 0, time.UTC)
+	for expr, want := range map[string]time.Time{
+		"*/15 * * * *":    time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC),
+		"0 8 1 * *":       time.Date(2026, 11, 1, 8, 0, 0, 0, time.UTC),
+		"0 9 * * MON-FRI": time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		"30 10 * * *":     time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC),
+		"20 10 * * *":     time.Date(2026, 10, 17, 10, 20, 0, 0, time.UTC),
+		"0 0 29 2 *":      time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		"0 12 * * 7":      time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		"@monthly":        time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		// Restricted day of month and day of week match either
+		"0 0 1 * FRI": time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC),
+	} {
+		assert.Equal(t, want, MustParse(expr).Next(now, nil), expr)
+	}
+
+	assert.True(t, MustParse("0 0 30 2 *").Next(now, nil).IsZero(), "February 30th never comes")
+}
+
+func TestNextLocalTime(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// 08:00 on the 1st in Tokyo is 23:00 UTC the day before
+	now := time.Date(2026, 10, 16, 10, 20, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 10, 31, 23, 0, 0, 0, time.UTC), MustParse("0 8 1 * *").Next(now, tokyo).UTC())
+}
+
+func TestNextDaylightSaving(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// Clocks go forward at 02:00 on 8 March 2026; 02:30 does not exist and runs at the transition
+	before := time.Date(2026, 3, 8, 0, 0, 0, 0, newYork)
+	run := MustParse("30 2 * * *").Next(before, newYork)
+	assert.Equal(t, time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC), run.UTC())
+	assert.Equal(t, time.Date(2026, 3, 9, 2, 30, 0, 0, newYork), MustParse("30 2 * * *").Next(run, newYork))
+
+	// 08:00 stays 08:00 local across the change, so its UTC time moves an hour
+	assert.Equal(t, time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC), MustParse("0 8 * * *").Next(before, newYork).UTC())
+	assert.Equal(t, time.Date(2026, 3, 7, 13, 0, 0, 0, time.UTC),
+		MustParse("0 8 * * *").Next(time.Date(2026, 3, 7, 0, 0, 0, 0, newYork), newYork).UTC())
+
+	// Clocks go back at 02:00 on 1 November 2026; 01:30 happens twice but runs once
+	schedule := MustParse("30 1 * * *")
+	first := schedule.Next(time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), newYork)
+	assert.Equal(t, time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), first.UTC())
+	assert.Equal(t, time.Date(2026, 11, 2, 6, 30, 0, 0, time.UTC), schedule.Next(first, newYork).UTC())
+
+	// Every 15 minutes through the repeated hour visits each wall-clock time once
+	quarterly := MustParse("*/15 1 * * *")
+	runs := []time.Time{}
+	for run := quarterly.Next(time.Date(2026, 11, 1, 0, 59, 0, 0, newYork), newYork); run.Day() == 1; run = quarterly.Next(run, newYork) {
+		runs = append(runs, run)
+	}
+	assert.Len(t, runs, 4)
+}
@@ -3,10 +3,12 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
 	_ "github.com/lib/pq"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
 )
 
 // DB is the database connection instance.
@@ -16,6 +18,40 @@ var DB *sql.DB
 func InitDB() {
 	var err error // Variable to hold errors
 
+	// Select the SQL dialect; PostgreSQL is the default.
+	CurrentDialect, err = DialectByName(os.Getenv("DB_DRIVER"))
+	if err != nil {
+		logging.Fatal("Invalid database driver", "error", err)
+	}
+
+	// Open a database connection.
+	DB, err = sql.Open(CurrentDialect.DriverName(), DataSourceName())
+	if err != nil {
+		logging.Fatal("Failed to open database", "error", err)
+	}
+
+	// Test the database connection.
+	if err = DB.Ping(); err != nil {
+		logging.Fatal("Failed to connect to database", "error", err)
+	}
+
+	// Route reporting reads to a replica when one is configured.
+	InitReplica()
+
+	SeedDatabase()
+}
+
+// DataSourceName builds the connection string for the current dialect from environment variables.
+func DataSourceName() string {
+	if CurrentDialect.Name() == "sqlite" {
+		if !driverRegistered(CurrentDialect.DriverName()) {
+			logging.Fatal("SQLite support is not compiled in; rebuild with -tags sqlite")
+		}
+
+		// Enforce foreign keys and wait on locks instead of failing immediately.
+		return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", SQLitePath())
+	}
+
 	// Retrieve database connection details from environment variables.
 	postgresHost := os.Getenv("POSTGRES_HOST")
 	postgresPort := os.Getenv("POSTGRES_PORT")
@@ -25,30 +61,35 @@ func InitDB() {
 
 	// Check if all required environment variables are set.
 	if postgresHost == "" || postgresPort == "" || postgresUser == "" || postgresPassword == "" || postgresDb == "" {
-		log.Fatal("One or more PostgreSQL environment variables not set") // Log fatal error and exit if any variable is missing.
+		logging.Fatal("One or more PostgreSQL environment variables not set") // Log fatal error and exit if any variable is missing.
 	}
 
 	// Construct the database connection URL.
-	databaseURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
 		postgresUser, postgresPassword, postgresHost, postgresPort, postgresDb) // Format the connection string.
+}
 
-	// Open a database connection.
-	DB, err = sql.Open("postgres", databaseURL)
-	if err != nil {
-		log.Fatal(err)
+// SQLitePath returns the SQLite database file path, defaulting to fire-pmaas.db in the working directory.
+func SQLitePath() string {
+	if sqlitePath := os.Getenv("SQLITE_PATH"); sqlitePath != "" {
+		return sqlitePath
 	}
+	return "fire-pmaas.db"
+}
 
-	// Test the database connection.
-	if err = DB.Ping(); err != nil {
-		log.Fatal(err)
+// driverRegistered reports whether a database/sql driver with the given name is available.
+func driverRegistered(name string) bool {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return true
+		}
 	}
-
-	SeedDatabase()
+	return false
 }
 
 // SeedDatabase seeds the database with initial data.
 func SeedDatabase() {
-	log.Println("Seeding database...")
+	slog.Info("Seeding database")
 
 	// Example properties
 	properties := []struct {
@@ -67,9 +108,9 @@ func SeedDatabase() {
 			VALUES ($1, $2, $3)
 		`, p.Name, p.Address, p.PropertyType)
 		if err != nil {
-			log.Printf("Failed to seed property %s: %v", p.Name, err)
+			slog.Error("Failed to seed property", "property", p.Name, "error", err)
 		}
 	}
 
-	log.Println("Database seeding complete.")
+	slog.Info("Database seeding complete")
 }
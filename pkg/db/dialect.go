@@ -0,0 +1,176 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/lib/pq"
+)
+
+// Dialect captures the SQL differences between the supported database engines.
+// Repository code keeps writing PostgreSQL-flavoured queries and asks the current
+// dialect for the handful of constructs that are not portable.
+type Dialect interface {
+	// Name returns the dialect identifier used in DB_DRIVER
+	Name() string
+	// DriverName returns the database/sql driver name to open connections with
+	DriverName() string
+	// ArrayValue encodes a string slice for storage in an array column
+	ArrayValue(values []string) (driver.Value, error)
+	// ScanArray decodes an array column into a string slice
+	ScanArray(src interface{}) ([]string, error)
+	// DateTrunc truncates a date expression to the given unit (only "month" is portable)
+	DateTrunc(unit, expr string) string
+	// DaysBetween returns the fractional number of days from start to end
+	DaysBetween(start, end string) string
+	// ArrayContainsAll returns a predicate that is true when column contains every element of placeholder
+	ArrayContainsAll(column, placeholder string) string
+	// SkipLocked returns the row-locking clause used when claiming queued work
+	SkipLocked() string
+	// SnapshotXmin returns an expression for the oldest transaction still in progress; every row
+	// stamped with a lower transaction ID belongs to a transaction that has finished
+	SnapshotXmin() string
+	// Rebind rewrites a query written for PostgreSQL into this dialect
+	Rebind(query string) string
+}
+
+// CurrentDialect is the dialect of the open database connection
+var CurrentDialect Dialect = PostgresDialect{}
+
+// DialectByName returns the dialect registered under name
+func DialectByName(name string) (Dialect, error) {
+	switch name {
+	case "", "postgres":
+		return PostgresDialect{}, nil
+	case "sqlite":
+		return SQLiteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q (expected postgres or sqlite)", name)
+	}
+}
+
+// PostgresDialect is the default dialect used in production deployments
+type PostgresDialect struct{}
+
+// Name implements Dialect
+func (PostgresDialect) Name() string { return "postgres" }
+
+// DriverName implements Dialect
+func (PostgresDialect) DriverName() string { return "postgres" }
+
+// ArrayValue implements Dialect using native PostgreSQL arrays
+func (PostgresDialect) ArrayValue(values []string) (driver.Value, error) {
+	return pq.Array(values).Value()
+}
+
+// ScanArray implements Dialect using native PostgreSQL arrays
+func (PostgresDialect) ScanArray(src interface{}) ([]string, error) {
+	var values []string
+	err := pq.Array(&values).Scan(src)
+	return values, err
+}
+
+// DateTrunc implements Dialect
+func (PostgresDialect) DateTrunc(unit, expr string) string {
+	return fmt.Sprintf("DATE_TRUNC('%s', %s)", unit, expr)
+}
+
+// DaysBetween implements Dialect
+func (PostgresDialect) DaysBetween(start, end string) string {
+	return fmt.Sprintf("(EXTRACT(EPOCH FROM (%s)::timestamp - (%s)::timestamp) / 86400)", end, start)
+}
+
+// ArrayContainsAll implements Dialect
+func (PostgresDialect) ArrayContainsAll(column, placeholder string) string {
+	return fmt.Sprintf("%s @> %s", column, placeholder)
+}
+
+// SkipLocked implements Dialect
+func (PostgresDialect) SkipLocked() string { return "FOR UPDATE SKIP LOCKED" }
+
+// SnapshotXmin implements Dialect
+func (PostgresDialect) SnapshotXmin() string { return "txid_snapshot_xmin(txid_current_snapshot())" }
+
+// Rebind implements Dialect; PostgreSQL queries need no rewriting
+func (PostgresDialect) Rebind(query string) string { return query }
+
+// SQLiteDialect supports single-binary deployments backed by an embedded SQLite file.
+// Arrays are stored as JSON text and queried with the json1 functions.
+type SQLiteDialect struct{}
+
+// Name implements Dialect
+func (SQLiteDialect) Name() string { return "sqlite" }
+
+// DriverName implements Dialect; the driver is registered by sqlite.go when built with -tags sqlite
+func (SQLiteDialect) DriverName() string { return "sqlite-pmaas" }
+
+// ArrayValue implements Dialect by encoding the slice as a JSON array
+func (SQLiteDialect) ArrayValue(values []string) (driver.Value, error) {
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// ScanArray implements Dialect by decoding a JSON array
+func (SQLiteDialect) ScanArray(src interface{}) ([]string, error) {
+	var raw []byte
+	switch v := src.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return nil, fmt.Errorf("cannot scan %T into string array", src)
+	}
+
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// DateTrunc implements Dialect
+func (SQLiteDialect) DateTrunc(unit, expr string) string {
+	switch unit {
+	case "year":
+		return fmt.Sprintf("strftime('%%Y-01-01', %s)", expr)
+	case "day":
+		return fmt.Sprintf("date(%s)", expr)
+	default:
+		return fmt.Sprintf("strftime('%%Y-%%m-01', %s)", expr)
+	}
+}
+
+// DaysBetween implements Dialect
+func (SQLiteDialect) DaysBetween(start, end string) string {
+	return fmt.Sprintf("(julianday(%s) - julianday(%s))", end, start)
+}
+
+// ArrayContainsAll implements Dialect using json_each
+func (SQLiteDialect) ArrayContainsAll(column, placeholder string) string {
+	return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM json_each(%s) WHERE value NOT IN (SELECT value FROM json_each(%s)))",
+		placeholder, column)
+}
+
+// SkipLocked implements Dialect; SQLite serialises writers so no lock clause is needed
+func (SQLiteDialect) SkipLocked() string { return "" }
+
+// SnapshotXmin implements Dialect. SQLite serialises writers, so rows are stamped with
+// transaction ID 0 and are always visible in the order they were written.
+func (SQLiteDialect) SnapshotXmin() string { return "1" }
+
+var (
+	positionalParam = regexp.MustCompile(`\$(\d+)`)
+	nowCall         = regexp.MustCompile(`(?i)\bNOW\(\)`)
+)
+
+// Rebind implements Dialect by converting $N placeholders to ?N and NOW() to CURRENT_TIMESTAMP
+func (SQLiteDialect) Rebind(query string) string {
+	query = positionalParam.ReplaceAllString(query, "?$1")
+	return nowCall.ReplaceAllString(query, "CURRENT_TIMESTAMP")
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialectByName(t *testing.T) {
+	d, err := DialectByName("")
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres", d.Name())
+
+	d, err = DialectByName("sqlite")
+	assert.NoError(t, err)
+	assert.Equal(t, "sqlite", d.Name())
+
+	_, err = DialectByName("mysql")
+	assert.Error(t, err)
+}
+
+func TestSQLiteRebind(t *testing.T) {
+	query := "UPDATE users SET updated_at = NOW() WHERE id = $1 AND status = $12"
+	assert.Equal(t, "UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = ?1 AND status = ?12",
+		SQLiteDialect{}.Rebind(query))
+	assert.Equal(t, query, PostgresDialect{}.Rebind(query))
+}
+
+func TestSQLiteArrayRoundTrip(t *testing.T) {
+	d := SQLiteDialect{}
+
+	value, err := d.ArrayValue([]string{"pool", "gym"})
+	assert.NoError(t, err)
+	assert.Equal(t, `["pool","gym"]`, value)
+
+	values, err := d.ScanArray([]byte(`["pool","gym"]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pool", "gym"}, values)
+}
+
+func TestDateTrunc(t *testing.T) {
+	assert.Equal(t, "DATE_TRUNC('month', p.payment_date)", PostgresDialect{}.DateTrunc("month", "p.payment_date"))
+	assert.Equal(t, "strftime('%Y-%m-01', p.payment_date)", SQLiteDialect{}.DateTrunc("month", "p.payment_date"))
+}
+
+func TestDaysBetween(t *testing.T) {
+	assert.Equal(t, "(EXTRACT(EPOCH FROM (mr.completed_date)::timestamp - (mr.reported_date)::timestamp) / 86400)",
+		PostgresDialect{}.DaysBetween("mr.reported_date", "mr.completed_date"))
+	assert.Equal(t, "(julianday(mr.completed_date) - julianday(mr.reported_date))",
+		SQLiteDialect{}.DaysBetween("mr.reported_date", "mr.completed_date"))
+}
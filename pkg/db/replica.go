@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaDB is the optional read-only replica connection used for reporting queries.
+var ReplicaDB *sql.DB
+
+// replicaHealthy is updated by the replica monitor; reads fall back to the primary while it is false.
+var replicaHealthy atomic.Bool
+
+// ReadDB returns the connection to use for read-only reporting, analytics and stats queries.
+// It returns the replica when one is configured and healthy, and the primary otherwise.
+func ReadDB() *sql.DB {
+	if ReplicaDB != nil && replicaHealthy.Load() {
+		return ReplicaDB
+	}
+	return DB
+}
+
+// ReplicaHealthy reports whether reads are currently being routed to the replica.
+func ReplicaHealthy() bool {
+	return ReplicaDB != nil && replicaHealthy.Load()
+}
+
+// InitReplica opens the read replica configured by DB_REPLICA_DSN and starts monitoring it.
+// Replication lag above DB_REPLICA_MAX_LAG_SECONDS (default 30) routes reads back to the primary.
+func InitReplica() {
+	dsn := os.Getenv("DB_REPLICA_DSN")
+	if dsn == "" {
+		return
+	}
+
+	if CurrentDialect.Name() != "postgres" {
+		slog.Warn("DB_REPLICA_DSN is only supported with PostgreSQL, ignoring replica")
+		return
+	}
+
+	maxLag := 30 * time.Second
+	if v := os.Getenv("DB_REPLICA_MAX_LAG_SECONDS"); v != "" {
+		var seconds int
+		if _, err := fmt.Sscanf(v, "%d", &seconds); err == nil && seconds > 0 {
+			maxLag = time.Duration(seconds) * time.Second
+		}
+	}
+
+	var err error
+	ReplicaDB, err = sql.Open(CurrentDialect.DriverName(), dsn)
+	if err != nil {
+		slog.Error("Failed to open read replica, using primary for all queries", "error", err)
+		ReplicaDB = nil
+		return
+	}
+
+	updateReplicaHealth(maxLag)
+	go monitorReplica(15*time.Second, maxLag)
+}
+
+// CheckReplica pings the replica and verifies its replication lag is within maxLag.
+func CheckReplica(maxLag time.Duration) error {
+	if ReplicaDB == nil {
+		return fmt.Errorf("no read replica configured")
+	}
+
+	if err := ReplicaDB.Ping(); err != nil {
+		return fmt.Errorf("replica unreachable: %w", err)
+	}
+
+	// pg_last_xact_replay_timestamp is NULL on a primary or before any WAL has been replayed
+	var lagSeconds float64
+	err := ReplicaDB.QueryRow(`
+		SELECT COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)`).Scan(&lagSeconds)
+	if err != nil {
+		return fmt.Errorf("failed to read replication lag: %w", err)
+	}
+
+	lag := time.Duration(lagSeconds * float64(time.Second))
+	if lag > maxLag {
+		return fmt.Errorf("replica lag %s exceeds %s", lag.Round(time.Second), maxLag)
+	}
+	return nil
+}
+
+// monitorReplica periodically re-checks replica health
+func monitorReplica(interval, maxLag time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		updateReplicaHealth(maxLag)
+	}
+}
+
+// updateReplicaHealth runs a health check and logs routing changes
+func updateReplicaHealth(maxLag time.Duration) {
+	err := CheckReplica(maxLag)
+	healthy := err == nil
+
+	if previous := replicaHealthy.Swap(healthy); previous != healthy {
+		if healthy {
+			slog.Info("Read replica healthy, routing reporting queries to replica")
+		} else {
+			slog.Warn("Read replica unhealthy, falling back to primary", "error", err)
+		}
+	}
+}
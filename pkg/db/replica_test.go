@@ -0,0 +1,29 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadDBFallsBackToPrimary(t *testing.T) {
+	originalDB, originalReplica := DB, ReplicaDB
+	defer func() {
+		DB, ReplicaDB = originalDB, originalReplica
+		replicaHealthy.Store(false)
+	}()
+
+	DB = new(sql.DB)
+	ReplicaDB = nil
+	assert.Same(t, DB, ReadDB(), "no replica configured")
+
+	ReplicaDB = new(sql.DB)
+	replicaHealthy.Store(false)
+	assert.Same(t, DB, ReadDB(), "replica unhealthy")
+	assert.False(t, ReplicaHealthy())
+
+	replicaHealthy.Store(true)
+	assert.Same(t, ReplicaDB, ReadDB(), "replica healthy")
+	assert.True(t, ReplicaHealthy())
+}
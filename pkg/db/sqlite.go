@@ -0,0 +1,75 @@
+//go:build sqlite
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"modernc.org/sqlite"
+)
+
+// The SQLite driver is only compiled in with -tags sqlite so that the default
+// build does not pull in the embedded database engine.
+func init() {
+	sql.Register(SQLiteDialect{}.DriverName(), &rebindDriver{base: &sqlite.Driver{}, dialect: SQLiteDialect{}})
+}
+
+// rebindDriver wraps a driver and rewrites PostgreSQL-flavoured queries into its dialect
+type rebindDriver struct {
+	base    driver.Driver
+	dialect Dialect
+}
+
+// Open implements driver.Driver
+func (d *rebindDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &rebindConn{Conn: conn, dialect: d.dialect}, nil
+}
+
+// rebindConn rewrites every query before handing it to the underlying connection
+type rebindConn struct {
+	driver.Conn
+	dialect Dialect
+}
+
+// Prepare implements driver.Conn
+func (c *rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(c.dialect.Rebind(query))
+}
+
+// PrepareContext implements driver.ConnPrepareContext
+func (c *rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return pc.PrepareContext(ctx, c.dialect.Rebind(query))
+	}
+	return c.Prepare(query)
+}
+
+// QueryContext implements driver.QueryerContext
+func (c *rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if qc, ok := c.Conn.(driver.QueryerContext); ok {
+		return qc.QueryContext(ctx, c.dialect.Rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// ExecContext implements driver.ExecerContext
+func (c *rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if ec, ok := c.Conn.(driver.ExecerContext); ok {
+		return ec.ExecContext(ctx, c.dialect.Rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// BeginTx implements driver.ConnBeginTx
+func (c *rebindConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without BeginTx
+}
@@ -0,0 +1,59 @@
+package digest
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparkline(t *testing.T) {
+	for _, values := range [][]float64{{1, 3, 2, 5, 4}, {7, 7, 7}, {42}, nil} {
+		data, err := Sparkline(values)
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, SparklineWidth, img.Bounds().Dx())
+		assert.Equal(t, SparklineHeight, img.Bounds().Dy())
+	}
+
+	points := sparklinePoints([]float64{0, 10})
+	assert.Greater(t, points[0].Y, points[1].Y, "higher values are drawn nearer the top")
+}
+
+func TestRender(t *testing.T) {
+	digest := &models.KPIDigest{
+		Frequency:   models.DigestWeekly,
+		Period:      "weekly:2026-W41",
+		PeriodStart: time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:   time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+		Metrics: []models.DigestMetric{
+			{Key: "rent_collected", Label: "Rent collected", Format: models.StatFormatCurrency, Values: []float64{1000, 1250.5}},
+			{Key: "occupancy_rate", Label: "Occupancy", Format: models.StatFormatPercentage, Values: []float64{95, 95}},
+		},
+		ExpiringLeases: []models.DigestLease{{LeaseID: 3, PropertyName: "Maple Court", UnitNumber: "101",
+			TenantName: "Ana <Diaz>", EndDate: time.Date(2026, 11, 30, 0, 0, 0, 0, time.UTC), MonthlyRent: 1450}},
+	}
+	recipient := models.DigestRecipient{UserID: 2, Email: "pm@example.com", FirstName: "Sam",
+		Preferences: models.UserPreferences{Locale: "de-DE", DateFormat: "DD.MM.YYYY"}}
+
+	message, err := Render(digest, recipient, "https://pm.example.com/")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Your weekly portfolio digest: 05.10.2026 to 11.10.2026", message.Subject)
+	assert.Contains(t, message.Text, "- Rent collected: $1250,50 (+25,1% vs previous week)")
+	assert.Contains(t, message.Text, "- Rent collected up 25,1%")
+	assert.Contains(t, message.Text, "- 30.11.2026: Maple Court 101 (Ana <Diaz>), $1450,00/month")
+	assert.Contains(t, message.Text, "No maintenance requests are past their SLA.")
+	assert.Contains(t, message.Text, "https://pm.example.com/dashboard")
+
+	assert.Contains(t, message.HTML, `<img src="cid:sparkline-rent-collected"`)
+	assert.Contains(t, message.HTML, "Ana &lt;Diaz&gt;")
+	assert.Len(t, message.Images, 2)
+	assert.Contains(t, message.Images, "sparkline-occupancy-rate")
+}
@@ -0,0 +1,187 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Message is a rendered digest email
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+	Images  map[string][]byte // Sparkline PNGs by Content-ID
+}
+
+// view is the data passed to the digest templates
+type view struct {
+	*models.KPIDigest
+	Name        string
+	PeriodLabel string
+	Sparklines  map[string]string // Content-IDs by metric key
+	BaseURL     string
+}
+
+const textTemplate = `Hi {{.Name}},
+
+Here is your {{.Frequency}} portfolio digest for {{.PeriodLabel}}.
+
+KEY METRICS
+{{range .Metrics}}- {{.Label}}: {{value .Format .Current}}{{with change .}} ({{.}} vs previous {{period $.Frequency}}){{end}}
+{{end}}
+{{- with .Trends}}
+NOTABLE TRENDS
+{{range .}}- {{.Label}} {{if gt .Change 0.0}}up{{else}}down{{end}} {{percent .Change}}
+{{end}}{{end}}
+UPCOMING LEASE EXPIRATIONS
+{{range .ExpiringLeases}}- {{date .EndDate}}: {{.PropertyName}} {{.UnitNumber}} ({{.TenantName}}), {{currency .MonthlyRent}}/month
+{{else}}No leases expire in the next {{leaseDays}} days.
+{{end}}
+OVERDUE MAINTENANCE
+{{range .OverdueMaintenance}}- #{{.ID}} {{.PropertyName}}: {{.Description}} ({{.Priority}}, opened {{date .CreatedAt}})
+{{else}}No maintenance requests are past their SLA.
+{{end}}{{with .BaseURL}}
+Open the dashboard: {{.}}/dashboard
+{{end}}
+You can change how often you receive this email in your profile preferences.
+`
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: Arial, sans-serif; color: #1f2937; max-width: 640px;">
+<p>Hi {{.Name}},</p>
+<p>Here is your {{.Frequency}} portfolio digest for {{.PeriodLabel}}.</p>
+
+<h2 style="font-size: 18px;">Key metrics</h2>
+<table cellpadding="6" style="border-collapse: collapse; width: 100%;">
+{{range .Metrics}}<tr style="border-bottom: 1px solid #e5e7eb;">
+<td>{{.Label}}</td>
+<td style="font-weight: bold; text-align: right;">{{value .Format .Current}}</td>
+<td style="text-align: right; color: #6b7280;">{{with change .}}{{.}}{{end}}</td>
+<td>{{with index $.Sparklines .Key}}<img src="cid:{{.}}" width="120" height="32" alt="">{{end}}</td>
+</tr>
+{{end}}</table>
+{{with .Trends}}
+<h2 style="font-size: 18px;">Notable trends</h2>
+<ul>
+{{range .}}<li>{{.Label}} {{if gt .Change 0.0}}up{{else}}down{{end}} {{percent .Change}}</li>
+{{end}}</ul>
+{{end}}
+<h2 style="font-size: 18px;">Upcoming lease expirations</h2>
+{{if .ExpiringLeases}}<table cellpadding="6" style="border-collapse: collapse; width: 100%;">
+<tr style="text-align: left;"><th>Ends</th><th>Property</th><th>Unit</th><th>Tenant</th><th style="text-align: right;">Rent</th></tr>
+{{range .ExpiringLeases}}<tr style="border-bottom: 1px solid #e5e7eb;"><td>{{date .EndDate}}</td><td>{{.PropertyName}}</td><td>{{.UnitNumber}}</td><td>{{.TenantName}}</td><td style="text-align: right;">{{currency .MonthlyRent}}</td></tr>
+{{end}}</table>
+{{else}}<p>No leases expire in the next {{leaseDays}} days.</p>
+{{end}}
+<h2 style="font-size: 18px;">Overdue maintenance</h2>
+{{if .OverdueMaintenance}}<table cellpadding="6" style="border-collapse: collapse; width: 100%;">
+<tr style="text-align: left;"><th>#</th><th>Property</th><th>Request</th><th>Priority</th><th>Opened</th></tr>
+{{range .OverdueMaintenance}}<tr style="border-bottom: 1px solid #e5e7eb;"><td>{{.ID}}</td><td>{{.PropertyName}}</td><td>{{.Description}}</td><td>{{.Priority}}</td><td>{{date .CreatedAt}}</td></tr>
+{{end}}</table>
+{{else}}<p>No maintenance requests are past their SLA.</p>
+{{end}}
+{{with .BaseURL}}<p><a href="{{.}}/dashboard">Open the dashboard</a></p>{{end}}
+<p style="color: #6b7280; font-size: 12px;">You can change how often you receive this email in your profile preferences.</p>
+</body>
+</html>
+`
+
+// Render builds the digest email for a recipient, formatting dates and numbers with their
+// preferences
+func Render(digest *models.KPIDigest, recipient models.DigestRecipient, baseURL string) (*Message, error) {
+	prefs := recipient.Preferences
+	funcs := map[string]interface{}{
+		"date":      prefs.FormatDate,
+		"leaseDays": func() int { return models.DigestLeaseHorizonDays },
+		"currency":  func(v float64) string { return formatNumber(prefs, "$%.2f", v) },
+		"percent":   func(v float64) string { return formatNumber(prefs, "%.1f%%", math.Abs(v)) },
+		"value":     func(format string, v float64) string { return formatValue(prefs, format, v) },
+		"change": func(metric models.DigestMetric) string {
+			change, ok := metric.Change()
+			if !ok {
+				return ""
+			}
+			return formatNumber(prefs, "%+.1f%%", change)
+		},
+		"period": func(frequency string) string {
+			if frequency == models.DigestMonthly {
+				return "month"
+			}
+			return "week"
+		},
+	}
+
+	data := view{
+		KPIDigest:   digest,
+		Name:        recipient.FirstName,
+		PeriodLabel: periodLabel(digest, prefs),
+		Sparklines:  map[string]string{},
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+	}
+	message := &Message{
+		Subject: fmt.Sprintf("Your %s portfolio digest: %s", digest.Frequency, data.PeriodLabel),
+		Images:  map[string][]byte{},
+	}
+	for _, metric := range digest.Metrics {
+		image, err := Sparkline(metric.Values)
+		if err != nil {
+			return nil, err
+		}
+		cid := "sparkline-" + strings.ReplaceAll(metric.Key, "_", "-")
+		message.Images[cid] = image
+		data.Sparklines[metric.Key] = cid
+	}
+
+	text, err := texttemplate.New("text").Funcs(funcs).Parse(textTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := text.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	message.Text = buf.String()
+
+	html, err := htmltemplate.New("html").Funcs(funcs).Parse(htmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+	buf.Reset()
+	if err := html.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	message.HTML = buf.String()
+
+	return message, nil
+}
+
+// periodLabel describes the digest period, e.g. "2026-10-05 to 2026-10-11" or "September 2026"
+func periodLabel(digest *models.KPIDigest, prefs models.UserPreferences) string {
+	if digest.Frequency == models.DigestMonthly {
+		return digest.PeriodStart.Format("January 2006")
+	}
+	return prefs.FormatDate(digest.PeriodStart) + " to " + prefs.FormatDate(digest.PeriodEnd.AddDate(0, 0, -1))
+}
+
+// formatValue formats a metric value according to its stat format
+func formatValue(prefs models.UserPreferences, format string, v float64) string {
+	switch format {
+	case models.StatFormatCurrency:
+		return formatNumber(prefs, "$%.2f", v)
+	case models.StatFormatPercentage:
+		return formatNumber(prefs, "%.1f%%", v)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+// formatNumber formats v and applies the locale's decimal separator
+func formatNumber(prefs models.UserPreferences, format string, v float64) string {
+	return strings.Replace(fmt.Sprintf(format, v), ".", prefs.DecimalSeparator(), 1)
+}
@@ -0,0 +1,137 @@
+package digest
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Sender emails each admin and property manager a weekly or monthly KPI digest, according to
+// their kpi_digest preference, once the period has ended
+type Sender struct {
+	Interval time.Duration
+	SendHour int // Local hour of day from which a finished period's digests are sent
+	BaseURL  string
+}
+
+// NewSender creates an hourly sender. KPI_DIGEST_HOUR sets the hour digests go out (default 7)
+// and APP_BASE_URL is used for the dashboard link.
+func NewSender() *Sender {
+	s := &Sender{Interval: time.Hour, SendHour: 7, BaseURL: os.Getenv("APP_BASE_URL")}
+	if hour, err := strconv.Atoi(os.Getenv("KPI_DIGEST_HOUR")); err == nil && hour >= 0 && hour < 24 {
+		s.SendHour = hour
+	}
+	return s
+}
+
+// Run sends due digests every Interval until the context is cancelled
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if sent, err := s.SendOnce(time.Now()); err != nil {
+			slog.Error("Sending KPI digests failed", "error", err)
+		} else if sent > 0 {
+			slog.Info("Sent KPI digests", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendOnce queues the digest for the last completed period to every recipient who has not had
+// it yet and returns the number queued. Each digest is built at most once per run for each set
+// of properties.
+func (s *Sender) SendOnce(now time.Time) (int, error) {
+	if now.Hour() < s.SendHour {
+		return 0, nil
+	}
+
+	recipients, err := models.GetKPIDigestRecipients()
+	if err != nil {
+		return 0, err
+	}
+
+	digests := map[string]*models.KPIDigest{}
+	sent := 0
+	for _, recipient := range recipients {
+		frequency := recipient.Preferences.DigestFrequency()
+		if frequency == models.DigestOff {
+			continue
+		}
+
+		queued, err := s.send(recipient, frequency, now, digests)
+		if err != nil {
+			return sent, fmt.Errorf("failed to send KPI digest to user %d: %w", recipient.UserID, err)
+		}
+		if queued {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// send claims the recipient's digest for the period and queues the email in one transaction.
+// It returns false when the digest was already sent.
+func (s *Sender) send(recipient models.DigestRecipient, frequency string, now time.Time, digests map[string]*models.KPIDigest) (bool, error) {
+	period, _, _ := models.DigestPeriod(frequency, now)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	claimed, err := models.ClaimKPIDigest(tx, recipient.UserID, period)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	// Recipients share a digest only when it covers the same properties
+	key := fmt.Sprintf("%s:%v", frequency, recipient.Properties)
+	digest, ok := digests[key]
+	if !ok {
+		if digest, err = models.BuildKPIDigest(frequency, now, recipient.Properties); err != nil {
+			return false, err
+		}
+		digests[key] = digest
+	}
+
+	message, err := Render(digest, recipient, s.BaseURL)
+	if err != nil {
+		return false, err
+	}
+	images := map[string]interface{}{}
+	for cid, image := range message.Images {
+		images[cid] = base64.StdEncoding.EncodeToString(image)
+	}
+
+	err = models.EnqueueOutboxMessage(tx, &models.OutboxMessage{
+		Channel:     "email",
+		Destination: recipient.Email,
+		EventType:   "kpi.digest",
+		Payload: map[string]interface{}{
+			"period":  period,
+			"subject": message.Subject,
+			"body":    message.Text,
+			"html":    message.HTML,
+			"images":  images,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,116 @@
+package digest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Sparkline dimensions in pixels
+const (
+	SparklineWidth  = 120
+	SparklineHeight = 32
+)
+
+var (
+	sparklineBackground = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	sparklineFill       = color.RGBA{R: 0xdb, G: 0xea, B: 0xfe, A: 0xff}
+	sparklineLine       = color.RGBA{R: 0x25, G: 0x63, B: 0xeb, A: 0xff}
+)
+
+// Sparkline draws values as a small line chart with the area beneath it shaded and the latest
+// value marked, and returns it as a PNG. A flat or single-value series is drawn mid-height.
+func Sparkline(values []float64) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, SparklineWidth, SparklineHeight))
+	for y := 0; y < SparklineHeight; y++ {
+		for x := 0; x < SparklineWidth; x++ {
+			img.Set(x, y, sparklineBackground)
+		}
+	}
+
+	points := sparklinePoints(values)
+	for i := 1; i < len(points); i++ {
+		drawLine(img, points[i-1], points[i])
+	}
+	if len(points) > 0 {
+		last := points[len(points)-1]
+		for dx := -2; dx <= 2; dx++ {
+			for dy := -2; dy <= 2; dy++ {
+				if dx*dx+dy*dy <= 5 {
+					img.Set(last.X+dx, last.Y+dy, sparklineLine)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sparklinePoints scales values to pixel positions, leaving a margin for the end marker
+func sparklinePoints(values []float64) []image.Point {
+	const margin = 3
+	if len(values) == 0 {
+		return nil
+	}
+
+	low, high := values[0], values[0]
+	for _, v := range values {
+		if v < low {
+			low = v
+		}
+		if v > high {
+			high = v
+		}
+	}
+
+	width := float64(SparklineWidth - 2*margin - 1)
+	height := float64(SparklineHeight - 2*margin - 1)
+	points := make([]image.Point, len(values))
+	for i, v := range values {
+		x := margin + width/2
+		if len(values) > 1 {
+			x = margin + width*float64(i)/float64(len(values)-1)
+		}
+		y := margin + height/2
+		if high > low {
+			y = margin + height*(high-v)/(high-low)
+		}
+		points[i] = image.Point{X: int(x + 0.5), Y: int(y + 0.5)}
+	}
+	return points
+}
+
+// drawLine draws a two pixel wide segment from a to b and shades each column beneath it
+func drawLine(img *image.RGBA, a, b image.Point) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	steps := abs(dx)
+	if abs(dy) > steps {
+		steps = abs(dy)
+	}
+	if steps == 0 {
+		steps = 1
+	}
+	for i := 0; i <= steps; i++ {
+		x := a.X + dx*i/steps
+		y := a.Y + dy*i/steps
+		for fy := y + 2; fy < SparklineHeight; fy++ {
+			if img.RGBAAt(x, fy) == sparklineBackground {
+				img.SetRGBA(x, fy, sparklineFill)
+			}
+		}
+		img.SetRGBA(x, y, sparklineLine)
+		img.SetRGBA(x, y+1, sparklineLine)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
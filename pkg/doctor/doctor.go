@@ -0,0 +1,430 @@
+package doctor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/backup"
+	"github.com/greenbrown932/fire-pmaas/pkg/config"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// Check statuses
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+	StatusSkip = "skipped"
+)
+
+// CheckResult is the outcome of a single self-check
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report is the outcome of a full doctor run
+type Report struct {
+	Healthy   bool          `json:"healthy"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+// MigrationsDir is the directory scanned for the expected schema version
+var MigrationsDir = "db/migrations"
+
+// Run executes every check and returns a report. Healthy is false if any check failed;
+// warnings do not affect overall health.
+func Run(ctx context.Context) *Report {
+	report := &Report{Healthy: true, CheckedAt: time.Now()}
+
+	configResult := CheckConfig()
+	report.add(configResult)
+
+	conn, dbResult := checkDatabase(configResult.Status != StatusFail)
+	report.add(dbResult)
+	report.add(checkSchemaVersion(conn))
+	report.add(checkBackups(conn, backup.NewRunnerFromEnv()))
+	if conn != nil && conn != db.DB {
+		conn.Close()
+	}
+
+	report.add(checkOIDCDiscovery(ctx))
+	report.add(CheckStorage(StorageDir()))
+	report.add(checkSMTP())
+	report.add(checkWkhtmltopdf())
+
+	return report
+}
+
+func (r *Report) add(result CheckResult) {
+	if result.Status == StatusFail {
+		r.Healthy = false
+	}
+	r.Checks = append(r.Checks, result)
+}
+
+// requiredEnv returns the environment variables required by the configured database driver
+func requiredEnv() []string {
+	var required []string
+	if os.Getenv("DB_DRIVER") != "sqlite" {
+		required = append(required, "POSTGRES_HOST", "POSTGRES_PORT", "POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DB")
+	}
+	return required
+}
+
+// CheckConfig validates that required environment variables are set and well-formed and that the
+// OIDC and cookie configuration would let the server start
+func CheckConfig() CheckResult {
+	result := CheckResult{Name: "config"}
+
+	if _, err := db.DialectByName(os.Getenv("DB_DRIVER")); err != nil {
+		result.Status = StatusFail
+		result.Message = err.Error()
+		return result
+	}
+
+	var missing []string
+	for _, name := range requiredEnv() {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Missing environment variables: %s", strings.Join(missing, ", "))
+		return result
+	}
+
+	if port := os.Getenv("POSTGRES_PORT"); port != "" {
+		if _, err := strconv.Atoi(port); err != nil {
+			result.Status = StatusFail
+			result.Message = fmt.Sprintf("POSTGRES_PORT must be numeric, got %q", port)
+			return result
+		}
+	}
+
+	if _, err := config.Load(os.Getenv("CONFIG_FILE")); err != nil {
+		result.Status = StatusFail
+		result.Message = "Invalid configuration: " + strings.ReplaceAll(err.Error(), "\n", "; ")
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Message = "All required environment variables are set and the configuration is valid"
+	return result
+}
+
+// checkDatabase verifies connectivity, reusing the server connection when one is open
+func checkDatabase(configured bool) (*sql.DB, CheckResult) {
+	result := CheckResult{Name: "database"}
+
+	conn := db.DB
+	if conn == nil {
+		if !configured {
+			result.Status = StatusSkip
+			result.Message = "Skipped until the database configuration is fixed"
+			return nil, result
+		}
+
+		dialect, _ := db.DialectByName(os.Getenv("DB_DRIVER"))
+		db.CurrentDialect = dialect
+
+		var err error
+		conn, err = sql.Open(dialect.DriverName(), db.DataSourceName())
+		if err != nil {
+			result.Status = StatusFail
+			result.Message = fmt.Sprintf("Cannot open database: %v", err)
+			return nil, result
+		}
+	}
+
+	if err := conn.Ping(); err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Cannot reach database: %v. Check the host, port and credentials.", err)
+		return conn, result
+	}
+
+	result.Status = StatusOK
+	result.Message = fmt.Sprintf("Connected (%s)", db.CurrentDialect.Name())
+	if db.ReplicaDB != nil && !db.ReplicaHealthy() {
+		result.Status = StatusWarn
+		result.Message += "; read replica is unhealthy, reporting queries are using the primary"
+	}
+	return conn, result
+}
+
+// checkSchemaVersion compares the applied migration version with the newest migration on disk
+func checkSchemaVersion(conn *sql.DB) CheckResult {
+	result := CheckResult{Name: "schema_version"}
+
+	if conn == nil {
+		result.Status = StatusSkip
+		result.Message = "Skipped because the database is unavailable"
+		return result
+	}
+
+	expected, err := LatestMigrationVersion(MigrationsDir)
+	if err != nil {
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("Cannot read migrations directory: %v", err)
+		return result
+	}
+
+	var version int
+	var dirty bool
+	if err := conn.QueryRow("SELECT version, dirty FROM schema_migrations").Scan(&version, &dirty); err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Cannot read schema_migrations: %v. Have migrations been run?", err)
+		return result
+	}
+
+	switch {
+	case dirty:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Migration %d failed part-way (dirty). Fix the schema and force the version with migrate.", version)
+	case version < expected:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Schema is at version %d but %d is available. Restart the server to apply migrations.", version, expected)
+	case version > expected:
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("Schema version %d is newer than this binary expects (%d)", version, expected)
+	default:
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("Schema is at version %d", version)
+	}
+	return result
+}
+
+// checkBackups reports whether recent database backups succeeded and restored cleanly
+func checkBackups(conn *sql.DB, runner *backup.Runner) CheckResult {
+	result := CheckResult{Name: "backups"}
+
+	if runner.Method() == "" {
+		result.Status = StatusWarn
+		result.Message = "Backups are not configured; set BACKUP_WEBHOOK_URL or install pg_dump"
+		return result
+	}
+	if conn == nil {
+		result.Status = StatusSkip
+		result.Message = "Skipped because the database is unavailable"
+		return result
+	}
+
+	var status, verifyStatus string
+	var lastError, verifyError sql.NullString
+	var startedAt time.Time
+	err := conn.QueryRow(`
+		SELECT status, verify_status, error, verify_error, started_at
+		FROM database_backups
+		ORDER BY started_at DESC, id DESC
+		LIMIT 1`).Scan(&status, &verifyStatus, &lastError, &verifyError, &startedAt)
+	if err == sql.ErrNoRows {
+		result.Status = StatusWarn
+		result.Message = "No backups have been taken yet"
+		return result
+	}
+	if err != nil {
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("Cannot read backup history: %v", err)
+		return result
+	}
+
+	var lastSuccess sql.NullTime
+	err = conn.QueryRow(`
+		SELECT started_at FROM database_backups
+		WHERE status = 'succeeded'
+		ORDER BY started_at DESC
+		LIMIT 1`).Scan(&lastSuccess.Time)
+	lastSuccess.Valid = err == nil
+	if err != nil && err != sql.ErrNoRows {
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("Cannot read backup history: %v", err)
+		return result
+	}
+
+	switch {
+	case status == "failed":
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("The last backup, started %s, failed: %s", startedAt.Format(time.RFC3339), lastError.String)
+	case runner.Interval > 0 && (!lastSuccess.Valid || time.Since(lastSuccess.Time) > 2*runner.Interval):
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("No successful backup in the last %s", 2*runner.Interval)
+	case verifyStatus == "failed":
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("The last backup could not be restored: %s", verifyError.String)
+	case status == "running":
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("A backup has been running since %s", startedAt.Format(time.RFC3339))
+	default:
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("The last backup succeeded at %s (restore verification %s)", startedAt.Format(time.RFC3339), verifyStatus)
+	}
+	return result
+}
+
+// LatestMigrationVersion returns the highest migration version found in dir
+func LatestMigrationVersion(dir string) (int, error) {
+	if os.Getenv("DB_DRIVER") == "sqlite" {
+		dir = filepath.Join(dir, "sqlite")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	latest := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".up.sql") {
+			continue
+		}
+		prefix, _, found := strings.Cut(entry.Name(), "_")
+		if !found {
+			continue
+		}
+		if version, err := strconv.Atoi(prefix); err == nil && version > latest {
+			latest = version
+		}
+	}
+	return latest, nil
+}
+
+// checkOIDCDiscovery fetches the issuer's discovery document
+func checkOIDCDiscovery(ctx context.Context) CheckResult {
+	result := CheckResult{Name: "oidc_discovery"}
+
+	issuer := os.Getenv("KEYCLOAK_ISSUER")
+	if issuer == "" {
+		result.Status = StatusSkip
+		result.Message = "KEYCLOAK_ISSUER is not set"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	discoveryURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Invalid KEYCLOAK_ISSUER: %v", err)
+		return result
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Cannot reach %s: %v. Check that Keycloak is running and reachable from this host.", discoveryURL, err)
+		return result
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("%s returned status %d. Check that the realm name in KEYCLOAK_ISSUER is correct.", discoveryURL, resp.StatusCode)
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Message = "Discovery document retrieved from " + discoveryURL
+	return result
+}
+
+// StorageDir returns the directory used for generated files and uploads
+func StorageDir() string {
+	return storage.Dir()
+}
+
+// CheckStorage verifies that dir exists and is writable
+func CheckStorage(dir string) CheckResult {
+	result := CheckResult{Name: "storage"}
+
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Cannot write to %s: %v. Create the directory or fix its permissions.", dir, err)
+		return result
+	}
+	name := f.Name()
+	f.Close()
+	os.Remove(name)
+
+	result.Status = StatusOK
+	result.Message = fmt.Sprintf("%s is writable", dir)
+	return result
+}
+
+// checkSMTP verifies the configured SMTP server accepts TCP connections. An email API provider
+// chosen with MAIL_PROVIDER is only checked for its API key.
+func checkSMTP() CheckResult {
+	result := CheckResult{Name: "smtp"}
+
+	switch provider := os.Getenv("MAIL_PROVIDER"); provider {
+	case "", "smtp":
+	case "sendgrid":
+		if os.Getenv("SENDGRID_API_KEY") == "" {
+			result.Status = StatusFail
+			result.Message = "MAIL_PROVIDER is sendgrid but SENDGRID_API_KEY is not set"
+			return result
+		}
+		result.Status = StatusOK
+		result.Message = "Email is sent through SendGrid"
+		return result
+	default:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Unknown MAIL_PROVIDER %q", provider)
+		return result
+	}
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		result.Status = StatusWarn
+		result.Message = "SMTP_HOST is not set; email notifications are disabled"
+		return result
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), 5*time.Second)
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Cannot connect to SMTP server %s:%s: %v", host, port, err)
+		return result
+	}
+	conn.Close()
+
+	result.Status = StatusOK
+	result.Message = fmt.Sprintf("Connected to %s:%s", host, port)
+	return result
+}
+
+// checkWkhtmltopdf reports whether high-fidelity PDF export is available
+func checkWkhtmltopdf() CheckResult {
+	result := CheckResult{Name: "wkhtmltopdf"}
+
+	path, err := exec.LookPath("wkhtmltopdf")
+	if err != nil {
+		result.Status = StatusWarn
+		result.Message = "wkhtmltopdf not found in PATH; PDF exports will use the basic built-in renderer"
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Message = "Found at " + path
+	return result
+}
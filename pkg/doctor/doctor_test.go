@@ -0,0 +1,76 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/backup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestMigrationVersion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"000001_init.up.sql", "000001_init.down.sql",
+		"000007_outbox.up.sql", "000003_reports.up.sql", "README.md",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(""), 0o644))
+	}
+
+	version, err := LatestMigrationVersion(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, version)
+}
+
+func TestCheckConfigMissingEnv(t *testing.T) {
+	t.Setenv("DB_DRIVER", "")
+	t.Setenv("KEYCLOAK_ISSUER", "http://localhost:8080/realms/test")
+	t.Setenv("POSTGRES_HOST", "")
+
+	result := CheckConfig()
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Contains(t, result.Message, "POSTGRES_HOST")
+}
+
+func TestCheckConfigInvalidOIDC(t *testing.T) {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("KEYCLOAK_ISSUER", "http://localhost:8080/realms/test")
+	t.Setenv("OIDC_CLIENT_SECRET", "")
+
+	result := CheckConfig()
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Contains(t, result.Message, "OIDC_CLIENT_SECRET")
+}
+
+func TestCheckConfigUnknownDriver(t *testing.T) {
+	t.Setenv("DB_DRIVER", "oracle")
+
+	result := CheckConfig()
+	assert.Equal(t, StatusFail, result.Status)
+}
+
+func TestCheckStorage(t *testing.T) {
+	assert.Equal(t, StatusOK, CheckStorage(t.TempDir()).Status)
+	assert.Equal(t, StatusFail, CheckStorage(filepath.Join(t.TempDir(), "missing")).Status)
+}
+
+func TestCheckBackupsLastFailed(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectQuery(`FROM database_backups`).WillReturnRows(
+		sqlmock.NewRows([]string{"status", "verify_status", "error", "verify_error", "started_at"}).
+			AddRow("failed", "skipped", "backup webhook returned status 500", nil, time.Now()))
+	mock.ExpectQuery(`WHERE status = 'succeeded'`).WillReturnRows(
+		sqlmock.NewRows([]string{"started_at"}).AddRow(time.Now().Add(-time.Hour)))
+
+	result := checkBackups(conn, &backup.Runner{WebhookURL: "http://backup.invalid", Interval: 24 * time.Hour})
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Contains(t, result.Message, "status 500")
+}
@@ -0,0 +1,153 @@
+package escalation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Scheduler computes lease rent escalations NoticeDays ahead of their effective date, notifies
+// the tenant and property managers through the outbox, and applies the new rent once the
+// effective date arrives
+type Scheduler struct {
+	Interval   time.Duration
+	NoticeDays int
+	CPI        models.CPILookup
+}
+
+// NewScheduler creates an hourly scheduler. RENT_ESCALATION_NOTICE_DAYS sets the notice period
+// (default 60 days).
+func NewScheduler() *Scheduler {
+	s := &Scheduler{Interval: time.Hour, NoticeDays: 60, CPI: models.LookupCPIIndexValue}
+	if days, err := strconv.Atoi(os.Getenv("RENT_ESCALATION_NOTICE_DAYS")); err == nil && days > 0 {
+		s.NoticeDays = days
+	}
+	return s
+}
+
+// Run schedules and applies escalations every Interval until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if scheduled, err := s.ScheduleOnce(now); err != nil {
+			slog.Error("Rent escalation scheduling failed", "error", err)
+		} else if scheduled > 0 {
+			slog.Info("Scheduled rent escalations", "count", scheduled)
+		}
+		if applied, err := models.ApplyDueEscalations(now); err != nil {
+			slog.Error("Applying rent escalations failed", "error", err)
+		} else if applied > 0 {
+			slog.Info("Applied rent escalations", "count", applied)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScheduleOnce computes every escalation falling within the notice period and returns the
+// number scheduled. CPI escalations whose index values are not yet published are retried later.
+func (s *Scheduler) ScheduleOnce(now time.Time) (int, error) {
+	due, err := models.GetDueEscalations(now.AddDate(0, 0, s.NoticeDays))
+	if err != nil {
+		return 0, err
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	managers, err := models.GetEscalationManagerEmails()
+	if err != nil {
+		return 0, err
+	}
+
+	scheduled := 0
+	for _, d := range due {
+		effective := d.Rule.NextEscalationDate.Time
+		newRent, basis, err := models.ComputeEscalation(&d.Rule, d.CurrentRent, effective, s.CPI)
+		if errors.Is(err, models.ErrCPIUnavailable) {
+			slog.Warn("Rent escalation is waiting for CPI data", "lease_id", d.Rule.LeaseID,
+				"effective", effective.Format("2006-01-02"), "error", err)
+			continue
+		}
+		if err != nil {
+			return scheduled, fmt.Errorf("failed to compute escalation for lease %d: %w", d.Rule.LeaseID, err)
+		}
+
+		escalation := &models.LeaseEscalation{
+			LeaseID:       d.Rule.LeaseID,
+			RuleID:        sql.NullInt32{Int32: int32(d.Rule.ID), Valid: true},
+			PropertyName:  d.PropertyName,
+			UnitNumber:    d.UnitNumber,
+			TenantName:    d.TenantName,
+			EffectiveDate: effective,
+			OldRent:       d.CurrentRent,
+			NewRent:       newRent,
+			Basis:         models.NullString(basis),
+		}
+		if err := schedule(escalation, d, d.Rule.FollowingDate(effective), managers); err != nil {
+			return scheduled, fmt.Errorf("failed to schedule escalation for lease %d: %w", d.Rule.LeaseID, err)
+		}
+		scheduled++
+	}
+
+	return scheduled, nil
+}
+
+// schedule records the escalation and queues the notices in one transaction
+func schedule(e *models.LeaseEscalation, d models.DueEscalation, next sql.NullTime, managers []string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := models.ScheduleEscalation(tx, e, next); err != nil {
+		return err
+	}
+
+	subject, body := models.FormatEscalationNotice(e)
+	payload := map[string]interface{}{
+		"lease_id":       e.LeaseID,
+		"escalation_id":  e.ID,
+		"effective_date": e.EffectiveDate.Format("2006-01-02"),
+		"old_rent":       e.OldRent,
+		"new_rent":       e.NewRent,
+		"subject":        subject,
+		"body":           body,
+	}
+	if err := models.AddLeaseNoticeHTML(payload); err != nil {
+		return err
+	}
+
+	notices := []models.OutboxMessage{{Channel: "email", Destination: d.TenantEmail}}
+	if d.TenantPhone.Valid && d.TenantPhone.String != "" {
+		notices = append(notices, models.OutboxMessage{Channel: "sms", Destination: d.TenantPhone.String})
+	}
+	for _, email := range managers {
+		notices = append(notices, models.OutboxMessage{Channel: "email", Destination: email})
+	}
+	for _, notice := range notices {
+		notice.EventType = "lease.escalation_scheduled"
+		notice.Payload = payload
+		if err := models.EnqueueOutboxMessage(tx, &notice); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
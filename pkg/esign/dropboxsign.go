@@ -0,0 +1,161 @@
+package esign
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DropboxSign sends signature requests through the Dropbox Sign (formerly HelloSign) API. Status
+// updates arrive as account callbacks, authenticated by the event hash.
+type DropboxSign struct {
+	BaseURL  string // e.g. https://api.hellosign.com/v3
+	APIKey   string
+	TestMode bool // Send non-binding test requests
+	HTTP     *http.Client
+}
+
+// Name implements Provider
+func (d *DropboxSign) Name() string { return "dropboxsign" }
+
+// do sends a request authenticated with the API key and returns the response for 2xx statuses
+func (d *DropboxSign) do(ctx context.Context, method, endpoint, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(d.BaseURL, "/")+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(d.APIKey, "")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := d.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("dropbox sign %s %s failed with status %d", method, endpoint, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// Send implements Provider. Documents without text tags get a signature page appended.
+func (d *DropboxSign) Send(ctx context.Context, envelope Envelope) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("title", envelope.DocumentName)
+	form.WriteField("subject", envelope.Subject)
+	form.WriteField("message", envelope.Message)
+	if d.TestMode {
+		form.WriteField("test_mode", "1")
+	}
+	for i, s := range envelope.Signers {
+		form.WriteField(fmt.Sprintf("signers[%d][name]", i), s.Name)
+		form.WriteField(fmt.Sprintf("signers[%d][email_address]", i), s.Email)
+		form.WriteField(fmt.Sprintf("signers[%d][order]", i), fmt.Sprint(i))
+	}
+	file, err := form.CreateFormFile("files[0]", envelope.DocumentName)
+	if err != nil {
+		return "", err
+	}
+	file.Write(envelope.Document)
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	resp, err := d.do(ctx, http.MethodPost, "/signature_request/send", form.FormDataContentType(), &body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		SignatureRequest struct {
+			SignatureRequestID string `json:"signature_request_id"`
+		} `json:"signature_request"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", err
+	}
+	if created.SignatureRequest.SignatureRequestID == "" {
+		return "", fmt.Errorf("dropbox sign did not return a signature request ID")
+	}
+	return created.SignatureRequest.SignatureRequestID, nil
+}
+
+// ParseWebhook implements Provider. Callbacks post a multipart form whose json field carries the
+// event; event_hash is HMAC-SHA256 of event_time (Unix seconds) and event_type keyed with the API key.
+func (d *DropboxSign) ParseWebhook(r *http.Request) (*Event, error) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+		return nil, err
+	}
+	var payload struct {
+		Event struct {
+			EventTime string `json:"event_time"`
+			EventType string `json:"event_type"`
+			EventHash string `json:"event_hash"`
+		} `json:"event"`
+		SignatureRequest struct {
+			SignatureRequestID string `json:"signature_request_id"`
+		} `json:"signature_request"`
+	}
+	if err := json.Unmarshal([]byte(r.FormValue("json")), &payload); err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, []byte(d.APIKey))
+	mac.Write([]byte(payload.Event.EventTime + payload.Event.EventType))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(payload.Event.EventHash)) {
+		return nil, ErrInvalidSignature
+	}
+	seconds, err := strconv.ParseInt(payload.Event.EventTime, 10, 64)
+	if err != nil {
+		return nil, ErrStaleWebhook
+	}
+	eventTime := time.Unix(seconds, 0)
+	if err := checkEventTime(eventTime); err != nil {
+		return nil, err
+	}
+
+	event := &Event{
+		EnvelopeID: payload.SignatureRequest.SignatureRequestID,
+		DeliveryID: payload.Event.EventHash,
+		Time:       eventTime,
+	}
+	switch payload.Event.EventType {
+	case "signature_request_all_signed":
+		event.Type = EventCompleted
+	case "signature_request_declined":
+		event.Type = EventDeclined
+	case "signature_request_canceled":
+		event.Type = EventVoided
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
+
+// WebhookAck implements Provider; Dropbox Sign retries callbacks that do not return this body
+func (d *DropboxSign) WebhookAck() string { return "Hello API Event Received" }
+
+// DownloadSigned implements Provider
+func (d *DropboxSign) DownloadSigned(ctx context.Context, envelopeID string) ([]byte, error) {
+	resp, err := d.do(ctx, http.MethodGet, "/signature_request/files/"+url.PathEscape(envelopeID)+"?file_type=pdf", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,118 @@
+package esign
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned for webhook callbacks that fail the provider's authenticity check
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrStaleWebhook is returned for webhook callbacks whose signed timestamp is missing or outside
+	// WebhookTolerance, so captured callbacks cannot be replayed later
+	ErrStaleWebhook = errors.New("webhook timestamp is missing or outside the allowed window")
+)
+
+// WebhookTolerance is how far the signed timestamp of a callback may be from the current time
+const WebhookTolerance = time.Hour
+
+// now is replaced in tests
+var now = time.Now
+
+// Webhook event types
+const (
+	EventCompleted = "completed" // Every signer has signed; the executed document is available
+	EventDeclined  = "declined"
+	EventVoided    = "voided"
+)
+
+// Signer is a person who must sign a document
+type Signer struct {
+	Name  string
+	Email string
+}
+
+// Envelope is a PDF sent out for signature
+type Envelope struct {
+	Subject      string
+	Message      string
+	DocumentName string // File name shown to signers, e.g. lease_12.pdf
+	Document     []byte
+	Signers      []Signer
+	// AnchorText marks where signers sign; providers that support text anchors place a signature
+	// field after it, the others append a signature page
+	AnchorText string
+}
+
+// Event is a status change reported by a provider's webhook
+type Event struct {
+	EnvelopeID string
+	Type       string
+	DeliveryID string    // Derived from the signature; identical for a replayed callback
+	Time       time.Time // When the provider generated the event, covered by its signature
+}
+
+// checkEventTime rejects callbacks whose signed timestamp is outside WebhookTolerance
+func checkEventTime(t time.Time) error {
+	if t.IsZero() {
+		return ErrStaleWebhook
+	}
+	if age := now().Sub(t); age > WebhookTolerance || age < -WebhookTolerance {
+		return ErrStaleWebhook
+	}
+	return nil
+}
+
+// Provider sends documents for signature through an e-signature service
+type Provider interface {
+	// Name identifies the provider in webhook URLs and stored records
+	Name() string
+	// Send creates the envelope and emails the signers, returning the provider's envelope ID
+	Send(ctx context.Context, envelope Envelope) (string, error)
+	// ParseWebhook authenticates a callback, rejects it with ErrStaleWebhook when its signed timestamp
+	// is too old, and returns its event, or nil for events that need no action
+	ParseWebhook(r *http.Request) (*Event, error)
+	// WebhookAck is the response body the provider expects from a successful callback
+	WebhookAck() string
+	// DownloadSigned fetches the executed PDF of a completed envelope
+	DownloadSigned(ctx context.Context, envelopeID string) ([]byte, error)
+}
+
+// NewProviderFromEnv configures the provider named by ESIGN_PROVIDER (docusign or dropboxsign).
+// It returns nil when e-signature is not configured.
+func NewProviderFromEnv() Provider {
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch strings.ToLower(os.Getenv("ESIGN_PROVIDER")) {
+	case "docusign":
+		p := &DocuSign{
+			BaseURL:     os.Getenv("DOCUSIGN_BASE_URL"),
+			AccountID:   os.Getenv("DOCUSIGN_ACCOUNT_ID"),
+			AccessToken: os.Getenv("DOCUSIGN_ACCESS_TOKEN"),
+			HMACKey:     os.Getenv("DOCUSIGN_HMAC_KEY"),
+			HTTP:        client,
+		}
+		if p.BaseURL == "" {
+			p.BaseURL = "https://demo.docusign.net/restapi"
+		}
+		if p.AccountID == "" || p.AccessToken == "" {
+			return nil
+		}
+		return p
+	case "dropboxsign":
+		p := &DropboxSign{
+			BaseURL:  "https://api.hellosign.com/v3",
+			APIKey:   os.Getenv("DROPBOX_SIGN_API_KEY"),
+			TestMode: os.Getenv("DROPBOX_SIGN_TEST_MODE") == "true",
+			HTTP:     client,
+		}
+		if p.APIKey == "" {
+			return nil
+		}
+		return p
+	}
+	return nil
+}
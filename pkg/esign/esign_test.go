@@ -0,0 +1,107 @@
+package esign
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocuSignSend(t *testing.T) {
+	var created map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2.1/accounts/acct/envelopes", r.URL.Path)
+		assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+		json.NewDecoder(r.Body).Decode(&created)
+		json.NewEncoder(w).Encode(map[string]string{"envelopeId": "env-1"})
+	}))
+	defer server.Close()
+
+	d := &DocuSign{BaseURL: server.URL, AccountID: "acct", AccessToken: "tok", HTTP: server.Client()}
+	id, err := d.Send(context.Background(), Envelope{Subject: "Sign", DocumentName: "lease.pdf", Document: []byte("%PDF"),
+		Signers: []Signer{{Name: "Ann Lee", Email: "ann@example.com"}}, AnchorText: "Tenant signature:"})
+	require.NoError(t, err)
+	assert.Equal(t, "env-1", id)
+	assert.Equal(t, "sent", created["status"])
+}
+
+// setNow fixes the clock webhook timestamps are checked against
+func setNow(t *testing.T, at time.Time) {
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestDocuSignWebhook(t *testing.T) {
+	generated := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	setNow(t, generated.Add(2*time.Minute))
+	d := &DocuSign{HMACKey: "secret"}
+	body := `{"event":"envelope-completed","generatedDateTime":"2026-10-16T09:30:00.0000000Z","data":{"envelopeId":"env-1"}}`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("X-DocuSign-Signature-1", signature)
+	event, err := d.ParseWebhook(req)
+	require.NoError(t, err)
+	assert.Equal(t, "env-1", event.EnvelopeID)
+	assert.Equal(t, EventCompleted, event.Type)
+	assert.Equal(t, signature, event.DeliveryID)
+	assert.True(t, generated.Equal(event.Time))
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("X-DocuSign-Signature-1", "forged")
+	_, err = d.ParseWebhook(req)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	// A captured callback replayed later is rejected
+	setNow(t, generated.Add(WebhookTolerance+time.Minute))
+	req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("X-DocuSign-Signature-1", signature)
+	_, err = d.ParseWebhook(req)
+	assert.Equal(t, ErrStaleWebhook, err)
+}
+
+func TestDropboxSignWebhook(t *testing.T) {
+	setNow(t, time.Unix(1700000000, 0).Add(30*time.Second))
+	d := &DropboxSign{APIKey: "key"}
+	callback := func(eventType, hash string) *http.Request {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"event":             map[string]string{"event_time": "1700000000", "event_type": eventType, "event_hash": hash},
+			"signature_request": map[string]string{"signature_request_id": "sr-1"},
+		})
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("json", string(payload))
+		form.Close()
+		req := httptest.NewRequest("POST", "/", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		return req
+	}
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("1700000000signature_request_all_signed"))
+	hash := hex.EncodeToString(mac.Sum(nil))
+
+	event, err := d.ParseWebhook(callback("signature_request_all_signed", hash))
+	require.NoError(t, err)
+	assert.Equal(t, &Event{EnvelopeID: "sr-1", Type: EventCompleted, DeliveryID: hash, Time: time.Unix(1700000000, 0)}, event)
+
+	_, err = d.ParseWebhook(callback("signature_request_all_signed", "forged"))
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	setNow(t, time.Unix(1700000000, 0).Add(WebhookTolerance+time.Minute))
+	_, err = d.ParseWebhook(callback("signature_request_all_signed", hash))
+	assert.Equal(t, ErrStaleWebhook, err)
+}
@@ -0,0 +1,88 @@
+package exports
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// RetentionDaysFromEnv returns how many days stored report output is kept for organizations
+// without their own retention: EXPORT_RETENTION_DAYS, or models.DefaultExportRetentionDays.
+// 0 keeps files until they are deleted or pushed out by a storage budget.
+func RetentionDaysFromEnv() int {
+	if days, err := strconv.Atoi(os.Getenv("EXPORT_RETENTION_DAYS")); err == nil && days >= 0 {
+		return days
+	}
+	return models.DefaultExportRetentionDays
+}
+
+// Delete removes a stored export's file and forgets it on its execution. A file that is
+// already gone is not an error, so a half-finished delete can be retried.
+func Delete(store storage.Backend, export *models.StoredExport) error {
+	if err := store.Delete(export.FilePath); err != nil {
+		return err
+	}
+	if err := models.ClearReportExecutionFile(export.ExecutionID); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
+// Expirer deletes stored report output older than its organization's retention or beyond its
+// storage budget
+type Expirer struct {
+	Interval      time.Duration
+	RetentionDays int
+	Store         storage.Backend
+}
+
+// NewExpirer creates an expirer for report storage that runs hourly with the configured default
+// retention
+func NewExpirer() *Expirer {
+	return &Expirer{Interval: time.Hour, RetentionDays: RetentionDaysFromEnv(), Store: storage.Reports}
+}
+
+// Run expires stored exports every Interval until the context is cancelled
+func (e *Expirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := e.ExpireOnce(time.Now()); err != nil {
+			slog.Error("Expiring stored report exports failed", "error", err)
+		} else if deleted > 0 {
+			slog.Info("Deleted expired report exports", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireOnce deletes the exports due for expiry at now and returns how many were deleted. A file
+// that cannot be deleted is logged and retried on the next run.
+func (e *Expirer) ExpireOnce(now time.Time) (int, error) {
+	expired, err := models.GetExpiredExports(e.RetentionDays, now)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for i := range expired {
+		if err := Delete(e.Store, &expired[i]); err != nil {
+			slog.Error("Failed to delete expired report export", "execution_id", expired[i].ExecutionID, "error", err)
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
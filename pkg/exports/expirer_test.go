@@ -0,0 +1,19 @@
+package exports
+
+import (
+	"testing"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionDaysFromEnv(t *testing.T) {
+	t.Setenv("EXPORT_RETENTION_DAYS", "")
+	assert.Equal(t, models.DefaultExportRetentionDays, RetentionDaysFromEnv())
+	t.Setenv("EXPORT_RETENTION_DAYS", "30")
+	assert.Equal(t, 30, RetentionDaysFromEnv())
+	t.Setenv("EXPORT_RETENTION_DAYS", "0")
+	assert.Zero(t, RetentionDaysFromEnv())
+	t.Setenv("EXPORT_RETENTION_DAYS", "-1")
+	assert.Equal(t, models.DefaultExportRetentionDays, RetentionDaysFromEnv())
+}
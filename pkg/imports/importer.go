@@ -0,0 +1,89 @@
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Importer creates records of one type from CSV rows
+type Importer interface {
+	// Header is the exact header row the file must start with
+	Header() []string
+	// Validate checks a row without touching the database
+	Validate(row []string) error
+	// Insert creates the row's record using q, which may be a transaction
+	Insert(q models.Querier, row []string) error
+}
+
+var importers = map[string]Importer{
+	"properties": propertyImporter{},
+}
+
+// Supported reports whether importType has a registered importer
+func Supported(importType string) bool {
+	_, ok := importers[importType]
+	return ok
+}
+
+// Header returns the header row expected for importType, or nil if it is not supported
+func Header(importType string) []string {
+	if importer, ok := importers[importType]; ok {
+		return importer.Header()
+	}
+	return nil
+}
+
+// CheckHeader verifies that header matches the importer's expected header
+func CheckHeader(importType string, header []string) error {
+	importer, ok := importers[importType]
+	if !ok {
+		return fmt.Errorf("unsupported import type %q", importType)
+	}
+	expected := importer.Header()
+	if len(header) != len(expected) {
+		return fmt.Errorf("invalid CSV header format. The header should be: %s", strings.Join(expected, ","))
+	}
+	for i := range expected {
+		if strings.TrimSpace(header[i]) != expected[i] {
+			return fmt.Errorf("invalid CSV header format. The header should be: %s", strings.Join(expected, ","))
+		}
+	}
+	return nil
+}
+
+// propertyImporter imports rows of Name,Address,PropertyType
+type propertyImporter struct{}
+
+func (propertyImporter) Header() []string {
+	return []string{"Name", "Address", "PropertyType"}
+}
+
+func (propertyImporter) Validate(row []string) error {
+	if len(row) != 3 {
+		return errors.New("each row should have 3 columns")
+	}
+	switch {
+	case strings.TrimSpace(row[0]) == "":
+		return errors.New("Name is required")
+	case strings.TrimSpace(row[1]) == "":
+		return errors.New("Address is required")
+	case strings.TrimSpace(row[2]) == "":
+		return errors.New("PropertyType is required")
+	case len(row[0]) > 255:
+		return errors.New("Name must be at most 255 characters")
+	case len(row[2]) > 50:
+		return errors.New("PropertyType must be at most 50 characters")
+	}
+	return nil
+}
+
+func (propertyImporter) Insert(q models.Querier, row []string) error {
+	return models.InsertProperty(q, &models.Property{
+		Name:         strings.TrimSpace(row[0]),
+		Address:      strings.TrimSpace(row[1]),
+		PropertyType: strings.TrimSpace(row[2]),
+	})
+}
@@ -0,0 +1,330 @@
+package imports
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Runner processes queued CSV import jobs one at a time, saving progress as it goes so
+// clients can poll it and recording every bad row for the downloadable error report.
+//
+// In all_or_nothing mode every row is validated first; the rows are then inserted in a single
+// transaction only if none were invalid. In skip_bad_rows mode each valid row is saved as it
+// is processed and invalid rows are skipped.
+//
+// A claimed job is leased to the runner, which extends the lease while it works. If the runner
+// stops, the lease expires and another runner claims the job again: all_or_nothing jobs start
+// over, and skip_bad_rows jobs resume after the last row saved. A job is failed once it has
+// been claimed MaxAttempts times.
+type Runner struct {
+	Interval      time.Duration
+	ProgressEvery int
+	Lease         time.Duration
+	MaxAttempts   int
+}
+
+// NewRunner creates a runner that checks for queued imports every few seconds
+func NewRunner() *Runner {
+	return &Runner{Interval: 5 * time.Second, ProgressEvery: 100, Lease: 2 * time.Minute, MaxAttempts: 3}
+}
+
+// Run processes queued jobs every Interval until the context is cancelled
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			processed, err := r.RunOnce()
+			if err != nil {
+				slog.Error("Import job processing failed", "error", err)
+			}
+			if !processed || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and processes the oldest queued job. It reports whether a job was claimed.
+func (r *Runner) RunOnce() (bool, error) {
+	job, err := models.ClaimImportJob(r.Lease)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if job.Attempts > r.MaxAttempts {
+		reason := fmt.Sprintf("the import was interrupted %d times and was not retried again", job.Attempts-1)
+		return true, r.fail(job, reason)
+	}
+
+	stop := r.keepLease(job.ID)
+	err = r.Process(job)
+	stop()
+	if err != nil {
+		job.Status = models.ImportFailed
+		job.Error = models.NullString("import failed: " + err.Error())
+		if finishErr := models.FinishImportJob(job); finishErr != nil {
+			slog.Error("Failed to record import job failure", "job_id", job.ID, "error", finishErr)
+		}
+		return true, fmt.Errorf("import job %d: %w", job.ID, err)
+	}
+	return true, nil
+}
+
+// keepLease extends a job's lease until the returned function is called
+func (r *Runner) keepLease(jobID int) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(r.Lease / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := models.ExtendImportJobLease(jobID, r.Lease); err != nil {
+					slog.Error("Failed to extend import job lease", "job_id", jobID, "error", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// csvRow is one data row of an import file
+type csvRow struct {
+	line   int
+	fields []string
+	err    error // Set when the row could not be parsed
+}
+
+// Process imports a claimed job's rows and records its outcome. Problems with the file itself
+// fail the job; the returned error is reserved for database failures.
+func (r *Runner) Process(job *models.ImportJob) error {
+	importer, ok := importers[job.ImportType]
+	if !ok {
+		return r.fail(job, fmt.Sprintf("unsupported import type %q", job.ImportType))
+	}
+
+	rows, err := parseRows(job.ImportType, job.SourceCSV)
+	if err != nil {
+		return r.fail(job, err.Error())
+	}
+	job.TotalRows = len(rows)
+
+	if job.Mode == models.ImportSkipBadRows {
+		if err := models.UpdateImportJobProgress(db.DB, job); err != nil {
+			return err
+		}
+		return r.skipBadRows(job, importer, rows)
+	}
+	// Nothing of an earlier attempt was saved, so an interrupted job starts over
+	if job.Attempts > 1 {
+		if err := models.ResetImportJobProgress(job); err != nil {
+			return err
+		}
+	} else if err := models.UpdateImportJobProgress(db.DB, job); err != nil {
+		return err
+	}
+	return r.allOrNothing(job, importer, rows)
+}
+
+func (r *Runner) skipBadRows(job *models.ImportJob, importer Importer, rows []csvRow) error {
+	// Rows saved by an earlier attempt are counted in ProcessedRows
+	if job.ProcessedRows > len(rows) {
+		job.ProcessedRows = len(rows)
+	}
+	for _, row := range rows[job.ProcessedRows:] {
+		if err := r.saveRow(job, importer, row); err != nil {
+			return err
+		}
+	}
+
+	job.Status = models.ImportCompleted
+	return models.FinishImportJob(job)
+}
+
+// saveRow imports one row in skip_bad_rows mode. The row, or its error, is committed together
+// with the job's progress, so a resumed job neither repeats nor skips a row.
+func (r *Runner) saveRow(job *models.ImportJob, importer Importer, row csvRow) error {
+	rowErr := row.err
+	if rowErr == nil {
+		rowErr = importer.Validate(row.fields)
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if rowErr == nil {
+		if insertErr := importer.Insert(tx, row.fields); insertErr != nil {
+			// The failed insert aborts the transaction, so the error is recorded in a new one
+			tx.Rollback()
+			if tx, err = db.DB.Begin(); err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			rowErr = fmt.Errorf("could not be saved: %v", insertErr)
+		}
+	}
+
+	processed, succeeded, failed := job.ProcessedRows, job.SucceededRows, job.FailedRows
+	job.ProcessedRows++
+	if rowErr != nil {
+		err = r.rowFailed(tx, job, row, rowErr)
+	} else {
+		job.SucceededRows++
+	}
+	if err == nil {
+		err = models.UpdateImportJobProgress(tx, job)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		job.ProcessedRows, job.SucceededRows, job.FailedRows = processed, succeeded, failed
+	}
+	return err
+}
+
+func (r *Runner) allOrNothing(job *models.ImportJob, importer Importer, rows []csvRow) error {
+	for _, row := range rows {
+		err := row.err
+		if err == nil {
+			err = importer.Validate(row.fields)
+		}
+		if err != nil {
+			if err := r.rowFailed(db.DB, job, row, err); err != nil {
+				return err
+			}
+		}
+		if err := r.advance(job); err != nil {
+			return err
+		}
+	}
+	if job.FailedRows > 0 {
+		return r.fail(job, fmt.Sprintf("%d of %d rows are invalid; nothing was imported", job.FailedRows, job.TotalRows))
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		if err := importer.Insert(tx, row.fields); err != nil {
+			// Release the transaction before recording the error on the main connection
+			tx.Rollback()
+			if err := r.rowFailed(db.DB, job, row, fmt.Errorf("could not be saved: %v", err)); err != nil {
+				return err
+			}
+			return r.fail(job, fmt.Sprintf("row %d could not be saved; nothing was imported", row.line))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	job.SucceededRows = len(rows)
+	job.Status = models.ImportCompleted
+	return models.FinishImportJob(job)
+}
+
+// rowFailed counts and records a bad row
+func (r *Runner) rowFailed(q models.Querier, job *models.ImportJob, row csvRow, err error) error {
+	job.FailedRows++
+	return models.AddImportRowError(q, job.ID, models.ImportRowError{
+		RowNumber: row.line,
+		Message:   err.Error(),
+		RawRow:    encodeRow(row.fields),
+	})
+}
+
+// advance counts a processed row, saving progress every ProgressEvery rows
+func (r *Runner) advance(job *models.ImportJob) error {
+	job.ProcessedRows++
+	if r.ProgressEvery > 0 && job.ProcessedRows%r.ProgressEvery == 0 {
+		return models.UpdateImportJobProgress(db.DB, job)
+	}
+	return nil
+}
+
+// fail marks a job as failed with a reason shown to the user
+func (r *Runner) fail(job *models.ImportJob, reason string) error {
+	job.Status = models.ImportFailed
+	job.Error = models.NullString(reason)
+	return models.FinishImportJob(job)
+}
+
+// parseRows checks the header and splits the file into data rows. Rows that are not valid CSV
+// are returned with their parse error so they appear in the error report.
+func parseRows(importType, source string) ([]csvRow, error) {
+	reader := csv.NewReader(strings.NewReader(source))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading header row: %v", err)
+	}
+	if err := CheckHeader(importType, header); err != nil {
+		return nil, err
+	}
+
+	var rows []csvRow
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, csvRow{line: parseErr.StartLine, err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, csvRow{line: line, fields: fields})
+	}
+	return rows, nil
+}
+
+// encodeRow re-encodes a row's fields as a CSV line for the error report
+func encodeRow(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(fields)
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,159 @@
+package imports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDB(t *testing.T) (sqlmock.Sqlmock, func()) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	originalDB := db.DB
+	db.DB = mockDB
+	return mock, func() {
+		db.DB = originalDB
+		mockDB.Close()
+	}
+}
+
+const sampleCSV = "Name,Address,PropertyType\n" +
+	"Sunset Apartments,1 Main St,Apartment Building\n" +
+	",2 Main St,Single Family Home\n" +
+	"Oak House,3 Main St,Single Family Home\n"
+
+func TestParseRows(t *testing.T) {
+	rows, err := parseRows("properties", sampleCSV+"\"unterminated,x,y\n")
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, 2, rows[0].line)
+	assert.Equal(t, []string{"Sunset Apartments", "1 Main St", "Apartment Building"}, rows[0].fields)
+	assert.Equal(t, 4, rows[2].line)
+	assert.Error(t, rows[3].err)
+
+	_, err = parseRows("properties", "Name,Address\nA,B\n")
+	assert.EqualError(t, err, "invalid CSV header format. The header should be: Name,Address,PropertyType")
+}
+
+func TestProcessSkipBadRows(t *testing.T) {
+	mock, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	job := &models.ImportJob{ID: 7, ImportType: "properties", Mode: models.ImportSkipBadRows, SourceCSV: sampleCSV}
+
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WithArgs(7, 3, 0, 0, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Each row is committed with the progress that counts it
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO properties`).WithArgs("Sunset Apartments", "1 Main St", "Apartment Building").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WithArgs(7, 3, 1, 1, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO import_job_errors`).WithArgs(7, 3, "Name is required", ",2 Main St,Single Family Home").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WithArgs(7, 3, 2, 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO properties`).WithArgs("Oak House", "3 Main St", "Single Family Home").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WithArgs(7, 3, 3, 2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`UPDATE import_jobs SET status`).WithArgs(7, models.ImportCompleted, 3, 3, 2, 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := &Runner{ProgressEvery: 100}
+	require.NoError(t, r.Process(job))
+	assert.Equal(t, models.ImportCompleted, job.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessSkipBadRowsResumesAfterSavedRows(t *testing.T) {
+	mock, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// An earlier attempt saved the first two rows before its worker stopped
+	job := &models.ImportJob{ID: 7, ImportType: "properties", Mode: models.ImportSkipBadRows, SourceCSV: sampleCSV,
+		Attempts: 2, ProcessedRows: 2, SucceededRows: 1, FailedRows: 1}
+
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WithArgs(7, 3, 2, 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO properties`).WithArgs("Oak House", "3 Main St", "Single Family Home").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WithArgs(7, 3, 3, 2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`UPDATE import_jobs SET status`).WithArgs(7, models.ImportCompleted, 3, 3, 2, 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := &Runner{ProgressEvery: 100}
+	require.NoError(t, r.Process(job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunOnceFailsJobOutOfAttempts(t *testing.T) {
+	mock, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	columns := []string{"id", "import_type", "mode", "status", "filename", "total_rows", "processed_rows",
+		"succeeded_rows", "failed_rows", "attempts", "error", "created_by", "created_at", "started_at", "finished_at", "source_csv"}
+	mock.ExpectQuery(`UPDATE import_jobs`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, "properties", models.ImportAllOrNothing, models.ImportRunning,
+			nil, 3, 1, 0, 0, 4, nil, 1, time.Now(), time.Now(), nil, sampleCSV))
+	mock.ExpectExec(`UPDATE import_jobs SET status`).WithArgs(7, models.ImportFailed, 3, 1, 0, 0,
+		models.NullString("the import was interrupted 3 times and was not retried again")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := &Runner{Lease: time.Minute, MaxAttempts: 3}
+	processed, err := r.RunOnce()
+	require.NoError(t, err)
+	assert.True(t, processed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessAllOrNothingImportsNothingWhenARowIsBad(t *testing.T) {
+	mock, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	job := &models.ImportJob{ID: 7, ImportType: "properties", Mode: models.ImportAllOrNothing, SourceCSV: sampleCSV}
+
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO import_job_errors`).WithArgs(7, 3, "Name is required", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE import_jobs SET status`).WithArgs(7, models.ImportFailed, 3, 3, 0, 1,
+		models.NullString("1 of 3 rows are invalid; nothing was imported")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := &Runner{ProgressEvery: 100}
+	require.NoError(t, r.Process(job))
+	assert.Equal(t, models.ImportFailed, job.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessAllOrNothingCommitsValidFile(t *testing.T) {
+	mock, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	source := "Name,Address,PropertyType\nA,1 Main St,Duplex\nB,2 Main St,Duplex\n"
+	job := &models.ImportJob{ID: 8, ImportType: "properties", Mode: models.ImportAllOrNothing, SourceCSV: source}
+
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Progress is saved after every row while validating
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WithArgs(8, 2, 1, 0, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE import_jobs SET total_rows`).WithArgs(8, 2, 2, 0, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO properties`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO properties`).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`UPDATE import_jobs SET status`).WithArgs(8, models.ImportCompleted, 2, 2, 2, 0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := &Runner{ProgressEvery: 1}
+	require.NoError(t, r.Process(job))
+	assert.Equal(t, 2, job.SucceededRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,209 @@
+package keycloak
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/config"
+)
+
+// ErrUserExists is returned when Keycloak already has a user with the same username or email
+var ErrUserExists = errors.New("keycloak user already exists")
+
+// AdminClient calls the Keycloak Admin REST API using a service account (client credentials)
+type AdminClient struct {
+	BaseURL      string // Server root, e.g. https://auth.example.com
+	Realm        string
+	ClientID     string
+	ClientSecret string
+	AppClientID  string // OIDC client that invitation redirect URIs belong to
+	HTTP         *http.Client
+}
+
+// NewAdminClientFromEnv configures the client from KEYCLOAK_ADMIN_CLIENT_ID and
+// KEYCLOAK_ADMIN_CLIENT_SECRET and the configured OIDC issuer (https://host/realms/<realm>) and
+// client. It returns nil when the admin credentials are not set or the issuer is not a Keycloak
+// realm URL.
+func NewAdminClientFromEnv() *AdminClient {
+	clientID := os.Getenv("KEYCLOAK_ADMIN_CLIENT_ID")
+	secret := os.Getenv("KEYCLOAK_ADMIN_CLIENT_SECRET")
+	if clientID == "" || secret == "" {
+		return nil
+	}
+
+	base, realm, err := ParseIssuer(config.Default.OIDC.Issuer)
+	if err != nil {
+		return nil
+	}
+	return &AdminClient{
+		BaseURL:      base,
+		Realm:        realm,
+		ClientID:     clientID,
+		ClientSecret: secret,
+		AppClientID:  config.Default.OIDC.ClientID,
+		HTTP:         &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ParseIssuer splits a realm issuer URL into the server root and realm name
+func ParseIssuer(issuer string) (string, string, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(issuer, "/"))
+	if err != nil || parsed.Host == "" {
+		return "", "", fmt.Errorf("invalid issuer %q", issuer)
+	}
+	realms, realm := path.Split(parsed.Path)
+	realms = path.Clean(realms)
+	if path.Base(realms) != "realms" || realm == "" {
+		return "", "", fmt.Errorf("issuer %q is not a Keycloak realm URL", issuer)
+	}
+	parsed.Path = strings.TrimSuffix(path.Dir(realms), "/")
+	return parsed.String(), realm, nil
+}
+
+// NewUser holds the fields used to create a Keycloak user
+type NewUser struct {
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// token obtains a service account access token
+func (c *AdminClient) token(ctx context.Context) (string, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/realms/%s/protocol/openid-connect/token", c.BaseURL, url.PathEscape(c.Realm)),
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("keycloak token request failed with status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.AccessToken, nil
+}
+
+// do sends an authenticated admin API request and returns the response for 2xx statuses
+func (c *AdminClient) do(ctx context.Context, token, method, endpoint string, payload interface{}) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method,
+		fmt.Sprintf("%s/admin/realms/%s%s", c.BaseURL, url.PathEscape(c.Realm), endpoint), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusConflict {
+		resp.Body.Close()
+		return nil, ErrUserExists
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("keycloak %s %s failed with status %d", method, endpoint, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// InviteUser creates a user and emails them Keycloak's required actions (verify email and set
+// a password), returning the new user's Keycloak ID
+func (c *AdminClient) InviteUser(ctx context.Context, user NewUser, redirectURI string) (string, error) {
+	token, err := c.token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.do(ctx, token, http.MethodPost, "/users", map[string]interface{}{
+		"username":        user.Username,
+		"email":           user.Email,
+		"firstName":       user.FirstName,
+		"lastName":        user.LastName,
+		"enabled":         true,
+		"emailVerified":   false,
+		"requiredActions": []string{"VERIFY_EMAIL", "UPDATE_PASSWORD"},
+	})
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	// The new user's ID is the last segment of the Location header
+	id := path.Base(resp.Header.Get("Location"))
+	if id == "" || id == "." || id == "/" {
+		return "", fmt.Errorf("keycloak did not return the new user's location")
+	}
+
+	query := url.Values{"lifespan": {fmt.Sprint(int((7 * 24 * time.Hour).Seconds()))}}
+	if redirectURI != "" && c.AppClientID != "" {
+		query.Set("redirect_uri", redirectURI)
+		query.Set("client_id", c.AppClientID)
+	}
+	resp, err = c.do(ctx, token, http.MethodPut, "/users/"+url.PathEscape(id)+"/execute-actions-email?"+query.Encode(),
+		[]string{"VERIFY_EMAIL", "UPDATE_PASSWORD"})
+	if err != nil {
+		return id, fmt.Errorf("user created but the invitation email failed: %w", err)
+	}
+	resp.Body.Close()
+	return id, nil
+}
+
+// ResetPassword sets a user's permanent password
+func (c *AdminClient) ResetPassword(ctx context.Context, keycloakID, password string) error {
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, token, http.MethodPut, "/users/"+url.PathEscape(keycloakID)+"/reset-password", map[string]interface{}{
+		"type":      "password",
+		"value":     password,
+		"temporary": false,
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
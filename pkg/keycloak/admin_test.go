@@ -0,0 +1,96 @@
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIssuer(t *testing.T) {
+	base, realm, err := ParseIssuer("https://auth.example.com/realms/pmaas")
+	require.NoError(t, err)
+	assert.Equal(t, "https://auth.example.com", base)
+	assert.Equal(t, "pmaas", realm)
+
+	base, realm, err = ParseIssuer("http://localhost:8080/auth/realms/dev/")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/auth", base)
+	assert.Equal(t, "dev", realm)
+
+	_, _, err = ParseIssuer("https://accounts.example.com")
+	assert.Error(t, err)
+}
+
+func TestInviteUser(t *testing.T) {
+	var created map[string]interface{}
+	var actionsQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/realms/pmaas/protocol/openid-connect/token":
+			assert.NoError(t, r.ParseForm())
+			assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "tok"})
+		case r.Method == http.MethodPost && r.URL.Path == "/admin/realms/pmaas/users":
+			assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+			json.NewDecoder(r.Body).Decode(&created)
+			w.Header().Set("Location", "http://"+r.Host+"/admin/realms/pmaas/users/abc-123")
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && r.URL.Path == "/admin/realms/pmaas/users/abc-123/execute-actions-email":
+			actionsQuery = r.URL.RawQuery
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &AdminClient{BaseURL: server.URL, Realm: "pmaas", ClientID: "svc", ClientSecret: "secret",
+		AppClientID: "pmaas-app", HTTP: server.Client()}
+	id, err := client.InviteUser(context.Background(), NewUser{Username: "jdoe", Email: "jane@example.com"}, "http://app/")
+	require.NoError(t, err)
+	assert.Equal(t, "abc-123", id)
+	assert.Equal(t, "jane@example.com", created["email"])
+	assert.Contains(t, actionsQuery, "client_id=pmaas-app")
+}
+
+func TestInviteUserConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/realms/pmaas/protocol/openid-connect/token" {
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "tok"})
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	client := &AdminClient{BaseURL: server.URL, Realm: "pmaas", HTTP: server.Client()}
+	_, err := client.InviteUser(context.Background(), NewUser{Username: "jdoe"}, "")
+	assert.ErrorIs(t, err, ErrUserExists)
+}
+
+func TestResetPassword(t *testing.T) {
+	var credential map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/realms/pmaas/protocol/openid-connect/token":
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "tok"})
+		case r.Method == http.MethodPut && r.URL.Path == "/admin/realms/pmaas/users/abc-123/reset-password":
+			json.NewDecoder(r.Body).Decode(&credential)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &AdminClient{BaseURL: server.URL, Realm: "pmaas", HTTP: server.Client()}
+	require.NoError(t, client.ResetPassword(context.Background(), "abc-123", "n3w-passw0rd"))
+	assert.Equal(t, map[string]interface{}{"type": "password", "value": "n3w-passw0rd", "temporary": false}, credential)
+}
@@ -0,0 +1,31 @@
+//go:build loadtest
+
+package loadtest
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// TestPerformanceBudgets loads a running server seeded with the default dataset and fails when a
+// target's P95 latency exceeds its budget. Run with `make loadtest`.
+func TestPerformanceBudgets(t *testing.T) {
+	baseURL := os.Getenv("LOADTEST_URL")
+	if baseURL == "" {
+		t.Skip("LOADTEST_URL is not set")
+	}
+	reportID, _ := strconv.Atoi(os.Getenv("LOADTEST_REPORT_ID"))
+	opts := Options{BaseURL: baseURL, IDToken: os.Getenv("LOADTEST_ID_TOKEN")}
+
+	for _, target := range DefaultTargets(reportID) {
+		t.Run(target.Name, func(t *testing.T) {
+			result := Run(context.Background(), opts, target)
+			t.Log(result)
+			if !result.WithinBudget() {
+				t.Errorf("%s: p95 %v over budget %v with %d errors", target.Name, result.P95, target.P95, result.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Target is an endpoint to load and the P95 latency it must stay under
+type Target struct {
+	Name   string
+	Method string
+	Path   string
+	Body   string        // JSON request body, if any
+	P95    time.Duration // Latency budget for the 95th percentile
+}
+
+// DefaultTargets are the report execution and dashboard endpoints with their budgets against the
+// default seed. Saved report execution is included when reportID is not 0.
+func DefaultTargets(reportID int) []Target {
+	targets := []Target{
+		{Name: "financial report preview", Method: http.MethodPost, Path: "/api/reports/preview",
+			Body: `{"name":"Load test","report_type":"financial","criteria":{}}`, P95: 2 * time.Second},
+		{Name: "property report preview", Method: http.MethodPost, Path: "/api/reports/preview",
+			Body: `{"name":"Load test","report_type":"property","criteria":{}}`, P95: 2 * time.Second},
+		{Name: "analytics summary", Method: http.MethodGet, Path: "/api/analytics/summary", P95: time.Second},
+		{Name: "properties stat", Method: http.MethodGet, Path: "/api/stats/properties", P95: 500 * time.Millisecond},
+		{Name: "financial stat", Method: http.MethodGet, Path: "/api/stats/financial", P95: 500 * time.Millisecond},
+		{Name: "dashboards", Method: http.MethodGet, Path: "/api/dashboards", P95: 300 * time.Millisecond},
+	}
+	if reportID != 0 {
+		targets = append(targets, Target{Name: "report execution", Method: http.MethodPost,
+			Path: fmt.Sprintf("/api/reports/%d/execute", reportID), Body: `{}`, P95: 2 * time.Second})
+	}
+	return targets
+}
+
+// Options configures a run against a server
+type Options struct {
+	BaseURL     string
+	IDToken     string // Sent as the id_token session cookie
+	Concurrency int    // Parallel clients, default 10
+	Requests    int    // Requests per target, default 200
+	Client      *http.Client
+}
+
+// Result is the latency distribution of one target's run
+type Result struct {
+	Target    Target
+	Requests  int
+	Errors    int // Transport errors and non-2xx responses
+	P50       time.Duration
+	P95       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+	FirstFail string // First error seen, for diagnosis
+}
+
+// WithinBudget reports whether every request succeeded and the P95 met the target's budget
+func (r Result) WithinBudget() bool {
+	return r.Errors == 0 && r.P95 <= r.Target.P95
+}
+
+// String summarizes the result on one line
+func (r Result) String() string {
+	status := "ok"
+	if !r.WithinBudget() {
+		status = "OVER BUDGET"
+	}
+	line := fmt.Sprintf("%-28s %4d req %3d err  p50 %-8v p95 %-8v p99 %-8v max %-8v budget %-6v %s",
+		r.Target.Name, r.Requests, r.Errors, round(r.P50), round(r.P95), round(r.P99), round(r.Max), r.Target.P95, status)
+	if r.FirstFail != "" {
+		line += " (" + r.FirstFail + ")"
+	}
+	return line
+}
+
+// Run sends opts.Requests requests to the target from opts.Concurrency clients and returns the
+// latency distribution
+func Run(ctx context.Context, opts Options, target Target) Result {
+	concurrency, requests := opts.Concurrency, opts.Requests
+	if concurrency < 1 {
+		concurrency = 10
+	}
+	if requests < 1 {
+		requests = 200
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	jobs := make(chan struct{})
+	var mu sync.Mutex
+	result := Result{Target: target}
+	latencies := make([]time.Duration, 0, requests)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				latency, err := send(ctx, client, opts, target)
+				mu.Lock()
+				latencies = append(latencies, latency)
+				if err != nil {
+					result.Errors++
+					if result.FirstFail == "" {
+						result.FirstFail = err.Error()
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < requests && ctx.Err() == nil; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.Requests = len(latencies)
+	result.P50 = Percentile(latencies, 50)
+	result.P95 = Percentile(latencies, 95)
+	result.P99 = Percentile(latencies, 99)
+	if len(latencies) > 0 {
+		result.Max = latencies[len(latencies)-1]
+	}
+	return result
+}
+
+// send makes one request and times it until the body has been read
+func send(ctx context.Context, client *http.Client, opts Options, target Target) (time.Duration, error) {
+	var body io.Reader
+	if target.Body != "" {
+		body = bytes.NewReader([]byte(target.Body))
+	}
+	req, err := http.NewRequestWithContext(ctx, target.Method, opts.BaseURL+target.Path, body)
+	if err != nil {
+		return 0, err
+	}
+	if target.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if opts.IDToken != "" {
+		req.AddCookie(&http.Cookie{Name: "id_token", Value: opts.IDToken})
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Since(start), err
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	latency := time.Since(start)
+	if err != nil {
+		return latency, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return latency, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return latency, nil
+}
+
+// Percentile returns the nearest-rank percentile of sorted latencies
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// round trims durations to a readable precision
+func round(d time.Duration) time.Duration {
+	if d > time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,59 @@
+package loadtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+	assert.Equal(t, 50*time.Millisecond, Percentile(latencies, 50))
+	assert.Equal(t, 95*time.Millisecond, Percentile(latencies, 95))
+	assert.Equal(t, 100*time.Millisecond, Percentile(latencies, 100))
+	assert.Equal(t, 3*time.Millisecond, Percentile([]time.Duration{3 * time.Millisecond}, 95))
+	assert.Equal(t, time.Duration(0), Percentile(nil, 95))
+}
+
+func TestRun(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		cookie, err := r.Cookie("id_token")
+		if err != nil || cookie.Value != "token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if n == 1 && r.URL.Path == "/flaky" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	opts := Options{BaseURL: server.URL, IDToken: "token", Concurrency: 4, Requests: 40}
+	result := Run(context.Background(), opts, Target{Name: "ok", Method: http.MethodGet, Path: "/ok", P95: time.Second})
+	assert.Equal(t, 40, result.Requests)
+	assert.Equal(t, 0, result.Errors)
+	assert.True(t, result.WithinBudget())
+	assert.LessOrEqual(t, result.P50, result.P95)
+	assert.LessOrEqual(t, result.P95, result.Max)
+
+	atomic.StoreInt32(&calls, 0)
+	flaky := Run(context.Background(), opts, Target{Name: "flaky", Method: http.MethodGet, Path: "/flaky", P95: time.Second})
+	assert.Equal(t, 1, flaky.Errors)
+	assert.Equal(t, "status 500", flaky.FirstFail)
+	assert.False(t, flaky.WithinBudget(), "errors fail the budget")
+
+	tight := Run(context.Background(), opts, Target{Name: "tight", Method: http.MethodGet, Path: "/ok", P95: time.Nanosecond})
+	assert.False(t, tight.WithinBudget())
+}
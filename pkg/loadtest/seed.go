@@ -0,0 +1,119 @@
+// Package loadtest seeds a large synthetic dataset and measures endpoint latency against
+// performance budgets, to catch regressions in report execution and dashboard queries.
+package loadtest
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// SeedPropertyType marks the properties the seeder creates, so ClearSeed can find everything it made
+const SeedPropertyType = "Load Test"
+
+// SeedConfig sizes the synthetic dataset. Every unit has one active lease with PaymentsPerLease
+// monthly payments, so the defaults make 10,000 units and 1,000,000 payments.
+type SeedConfig struct {
+	Units            int
+	UnitsPerProperty int
+	PaymentsPerLease int
+}
+
+// DefaultSeedConfig is the dataset the performance budgets are set against
+var DefaultSeedConfig = SeedConfig{Units: 10000, UnitsPerProperty: 100, PaymentsPerLease: 100}
+
+// Seed inserts the synthetic dataset. It is PostgreSQL only, using generate_series so a million
+// payments insert in seconds rather than row by row. Existing load test data is removed first.
+func Seed(db *sql.DB, cfg SeedConfig) error {
+	if cfg.Units < 1 || cfg.UnitsPerProperty < 1 || cfg.PaymentsPerLease < 0 {
+		return fmt.Errorf("invalid seed config %+v", cfg)
+	}
+	if err := ClearSeed(db); err != nil {
+		return err
+	}
+
+	properties := (cfg.Units + cfg.UnitsPerProperty - 1) / cfg.UnitsPerProperty
+	steps := []struct {
+		name  string
+		query string
+		args  []interface{}
+	}{
+		{"properties", `
+			INSERT INTO properties (name, address, property_type)
+			SELECT 'Load Test Property ' || g, g || ' Load Test Ave', $1
+			FROM generate_series(1, $2) g`,
+			[]interface{}{SeedPropertyType, properties}},
+		{"units", `
+			INSERT INTO property_units (property_id, unit_number, bedrooms, bathrooms, market_rent)
+			SELECT id, 'Unit ' || n, 1 + n % 3, 1 + n % 2, 900 + (n % 20) * 50
+			FROM (
+				SELECT p.id, g AS n, ROW_NUMBER() OVER (ORDER BY p.id, g) AS seq
+				FROM properties p CROSS JOIN generate_series(1, $2) g
+				WHERE p.property_type = $1
+			) u
+			WHERE seq <= $3`,
+			[]interface{}{SeedPropertyType, cfg.UnitsPerProperty, cfg.Units}},
+		{"tenants", `
+			INSERT INTO tenants (first_name, last_name, email, status)
+			SELECT 'Load', 'Tenant ' || u.id, 'loadtest+' || u.id || '@example.com', 'active'
+			FROM property_units u JOIN properties p ON p.id = u.property_id
+			WHERE p.property_type = $1`,
+			[]interface{}{SeedPropertyType}},
+		{"leases", `
+			INSERT INTO leases (unit_id, tenant_id, start_date, end_date, monthly_rent, status)
+			SELECT u.id, t.id, CURRENT_DATE - 30 * $2, CURRENT_DATE + 365, u.market_rent, 'active'
+			FROM property_units u
+			JOIN properties p ON p.id = u.property_id
+			JOIN tenants t ON t.email = 'loadtest+' || u.id || '@example.com'
+			WHERE p.property_type = $1`,
+			[]interface{}{SeedPropertyType, cfg.PaymentsPerLease}},
+		{"payments", `
+			INSERT INTO payments (lease_id, amount, payment_date, payment_method, status)
+			SELECT l.id, l.monthly_rent, CURRENT_DATE - 30 * g, 'Bank Transfer',
+				CASE WHEN (l.id + g) % 50 = 0 THEN 'failed' ELSE 'completed' END
+			FROM leases l
+			JOIN property_units u ON u.id = l.unit_id
+			JOIN properties p ON p.id = u.property_id
+			CROSS JOIN generate_series(0, $2 - 1) g
+			WHERE p.property_type = $1`,
+			[]interface{}{SeedPropertyType, cfg.PaymentsPerLease}},
+	}
+
+	for _, step := range steps {
+		result, err := db.Exec(step.query, step.args...)
+		if err != nil {
+			return fmt.Errorf("failed to seed %s: %w", step.name, err)
+		}
+		n, _ := result.RowsAffected()
+		slog.Info("Seeded load test data", "table", step.name, "count", n)
+	}
+
+	// Refresh planner statistics so the first measured queries use realistic plans
+	if _, err := db.Exec("ANALYZE"); err != nil {
+		return fmt.Errorf("failed to analyze seeded tables: %w", err)
+	}
+	return nil
+}
+
+// ClearSeed deletes the data Seed created, children first
+func ClearSeed(db *sql.DB) error {
+	loadTestLeases := `SELECT l.id FROM leases l
+		JOIN property_units u ON u.id = l.unit_id
+		JOIN properties p ON p.id = u.property_id
+		WHERE p.property_type = $1`
+	queries := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"DELETE FROM payments WHERE lease_id IN (" + loadTestLeases + ")", []interface{}{SeedPropertyType}},
+		{"DELETE FROM leases WHERE id IN (" + loadTestLeases + ")", []interface{}{SeedPropertyType}},
+		{"DELETE FROM tenants WHERE first_name = 'Load' AND email LIKE 'loadtest+%@example.com'", nil},
+		{"DELETE FROM properties WHERE property_type = $1", []interface{}{SeedPropertyType}},
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q.query, q.args...); err != nil {
+			return fmt.Errorf("failed to clear load test data: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// RequestIDHeader carries the request ID in and out of the API
+const RequestIDHeader = "X-Request-ID"
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// validRequestID matches request IDs accepted from callers; anything else is replaced so IDs
+// can't inject text into the log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ParseLevel converts a LOG_LEVEL value (debug, info, warn or error) to a slog level, defaulting
+// to info
+func ParseLevel(value string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// NewHandler returns a scrubbing slog handler writing records at or above level to w, as JSON
+// when format is "json" and as key=value text otherwise
+func NewHandler(w io.Writer, level slog.Leveler, format string) slog.Handler {
+	scrubbed := NewScrubWriter(w)
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: ScrubAttr}
+	if strings.EqualFold(format, "json") {
+		return slog.NewJSONHandler(scrubbed, opts)
+	}
+	return slog.NewTextHandler(scrubbed, opts)
+}
+
+// WithContext returns a copy of ctx carrying logger
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger when there is none
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// NewRequestID returns the caller's request ID when it is safe to log, or a new random one
+func NewRequestID(supplied string) string {
+	if validRequestID.MatchString(supplied) {
+		return supplied
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// Fatal logs an error and exits, like log.Fatal for structured logs
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(t *testing.T) {
+	assert.Equal(t, slog.LevelDebug, ParseLevel("DEBUG"))
+	assert.Equal(t, slog.LevelWarn, ParseLevel("warning"))
+	assert.Equal(t, slog.LevelError, ParseLevel(" error "))
+	assert.Equal(t, slog.LevelInfo, ParseLevel(""))
+	assert.Equal(t, slog.LevelInfo, ParseLevel("verbose"))
+}
+
+func TestNewHandlerLevelsAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, slog.LevelInfo, "json"))
+
+	logger.Debug("hidden")
+	logger.Info("reset requested", "email", "ann@example.com", "token", "abc123")
+
+	out := buf.String()
+	assert.NotContains(t, out, "hidden")
+	assert.Contains(t, out, `"msg":"reset requested"`)
+	assert.Contains(t, out, `"email":"***@example.com"`)
+	assert.Contains(t, out, `"token":"[redacted]"`)
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Same(t, slog.Default(), FromContext(context.Background()))
+
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, slog.LevelInfo, "text")).With("request_id", "abc")
+	ctx := WithRequestID(WithContext(context.Background(), logger), "abc")
+
+	FromContext(ctx).Info("handled")
+	assert.Contains(t, buf.String(), "request_id=abc")
+	assert.Equal(t, "abc", RequestID(ctx))
+	assert.Empty(t, RequestID(context.Background()))
+}
+
+func TestNewRequestID(t *testing.T) {
+	assert.Equal(t, "req-42.a_b", NewRequestID("req-42.a_b"))
+
+	generated := NewRequestID("bad id\ninjected=1")
+	assert.Len(t, generated, 16)
+	assert.NotEqual(t, generated, NewRequestID(""))
+}
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces scrubbed values in log output
+const Redacted = "[redacted]"
+
+// sensitiveKey matches field and parameter names whose values are secrets
+const sensitiveKey = `[A-Za-z_]*(?:token|password|passwd|secret|api_?key|authorization|cookie)[A-Za-z_]*|code|otp|totp`
+
+var (
+	jwtPattern     = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern  = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+(:?)`)
+	keyedPattern   = regexp.MustCompile(`(?i)\b("?(?:` + sensitiveKey + `)"?\s*[:=]\s*"?)([^\s"&,;})\]\[]+)`)
+	emailPattern   = regexp.MustCompile(`[A-Za-z0-9._%+-]+@((?:[A-Za-z0-9-]+\.)+[A-Za-z]{2,})`)
+	opaquePattern  = regexp.MustCompile(`/[A-Za-z0-9_-]{32,}`)
+	sensitiveNames = regexp.MustCompile(`(?i)^(?:` + sensitiveKey + `)$`)
+)
+
+// Scrub redacts tokens, passwords, MFA secrets and email addresses from a log line. Email
+// addresses keep their domain so delivery problems can still be diagnosed.
+func Scrub(s string) string {
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	s = bearerPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := bearerPattern.FindStringSubmatch(match)
+		if parts[2] != "" {
+			// "Bearer Key:" is a field value followed by the next field, not a credential
+			return match
+		}
+		return parts[1] + " " + Redacted
+	})
+	s = keyedPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := keyedPattern.FindStringSubmatch(match)
+		if strings.EqualFold(parts[2], "bearer") || strings.EqualFold(parts[2], "basic") {
+			// The credential after the scheme was already redacted
+			return match
+		}
+		return parts[1] + Redacted
+	})
+	s = emailPattern.ReplaceAllString(s, "***@${1}")
+	// Opaque path segments are invitation, approval and API tokens
+	return opaquePattern.ReplaceAllString(s, "/"+Redacted)
+}
+
+// IsSensitiveKey reports whether values logged under the key are secrets
+func IsSensitiveKey(key string) bool {
+	return sensitiveNames.MatchString(key)
+}
+
+// scrubWriter scrubs everything written through it
+type scrubWriter struct {
+	w io.Writer
+}
+
+// NewScrubWriter returns a writer that scrubs each write before passing it to w
+func NewScrubWriter(w io.Writer) io.Writer {
+	return &scrubWriter{w: w}
+}
+
+func (s *scrubWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(s.w, Scrub(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ScrubAttr is a slog ReplaceAttr hook that redacts attributes with sensitive keys and scrubs
+// the message and every other string, error or Stringer value
+func ScrubAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, Scrub(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, Scrub(v.String()))
+		}
+	}
+	return a
+}
+
+// Setup installs a scrubbing slog handler as the default structured logger, at the level named
+// by LOG_LEVEL and in the format named by LOG_FORMAT ("text" or "json"), and routes the
+// standard log package through a scrubbing writer, keeping its classic line format
+func Setup(w io.Writer) {
+	level := ParseLevel(os.Getenv("LOG_LEVEL"))
+	slog.SetDefault(slog.New(NewHandler(w, level, os.Getenv("LOG_FORMAT"))))
+
+	// slog.SetDefault redirects the log package into the handler; write to it directly instead
+	log.SetOutput(NewScrubWriter(w))
+	log.SetFlags(log.LstdFlags)
+}
@@ -0,0 +1,153 @@
+// Package mailer renders email from templates and delivers it over SMTP or an email API
+// provider. Messages are normally queued in the outbox and delivered by its email channel, so
+// they are only sent if the change that triggered them commits.
+package mailer
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Message is an email to one recipient. Text is required; HTML adds a rich alternative whose
+// Images are attached inline under their Content-IDs. Attachments are only sent with Text.
+type Message struct {
+	To          string
+	Subject     string
+	Text        string
+	HTML        string
+	Images      map[string][]byte // PNG images by Content-ID, referenced from HTML as cid: URLs
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Sender delivers email
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewSenderFromEnv configures the backend named by MAIL_PROVIDER:
+//
+//	smtp      SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM
+//	sendgrid  SENDGRID_API_KEY and MAIL_FROM (or SMTP_FROM)
+//
+// Without MAIL_PROVIDER it uses SMTP when SMTP_HOST is set. It returns nil when email is not
+// configured, and an error when the chosen provider is missing settings.
+func NewSenderFromEnv() (Sender, error) {
+	provider := os.Getenv("MAIL_PROVIDER")
+	if provider == "" {
+		if os.Getenv("SMTP_HOST") == "" {
+			return nil, nil
+		}
+		provider = "smtp"
+	}
+
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_FROM")
+	}
+
+	switch provider {
+	case "smtp":
+		sender := &SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+		if sender.Host == "" {
+			return nil, errors.New("MAIL_PROVIDER smtp requires SMTP_HOST")
+		}
+		if sender.Port == "" {
+			sender.Port = "587"
+		}
+		if sender.From == "" {
+			sender.From = sender.Username
+		}
+		return sender, nil
+	case "sendgrid":
+		sender := NewSendGridSender(os.Getenv("SENDGRID_API_KEY"), from)
+		if sender.APIKey == "" || sender.From == "" {
+			return nil, errors.New("MAIL_PROVIDER sendgrid requires SENDGRID_API_KEY and MAIL_FROM")
+		}
+		return sender, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_PROVIDER %q; use smtp or sendgrid", provider)
+	}
+}
+
+// Payload encodes the message as the payload of an outbox email: "subject", "body" and the
+// optional "html", "images" and "attachments" fields read by MessageFromPayload
+func (m *Message) Payload() map[string]interface{} {
+	payload := map[string]interface{}{"subject": m.Subject, "body": m.Text}
+	if m.HTML != "" {
+		payload["html"] = m.HTML
+	}
+	if len(m.Images) > 0 {
+		images := make(map[string]interface{}, len(m.Images))
+		for cid, data := range m.Images {
+			images[cid] = base64.StdEncoding.EncodeToString(data)
+		}
+		payload["images"] = images
+	}
+	if len(m.Attachments) > 0 {
+		attachments := make([]interface{}, 0, len(m.Attachments))
+		for _, a := range m.Attachments {
+			attachments = append(attachments, map[string]interface{}{
+				"filename":     a.Filename,
+				"content_type": a.ContentType,
+				"data":         base64.StdEncoding.EncodeToString(a.Data),
+			})
+		}
+		payload["attachments"] = attachments
+	}
+	return payload
+}
+
+// MessageFromPayload decodes the payload of an outbox email addressed to to. Images and
+// attachment data are base64-encoded; other payload fields are ignored.
+func MessageFromPayload(to string, payload map[string]interface{}) (*Message, error) {
+	msg := &Message{To: to}
+	msg.Subject, _ = payload["subject"].(string)
+	msg.Text, _ = payload["body"].(string)
+	msg.HTML, _ = payload["html"].(string)
+	if msg.Text == "" {
+		return nil, errors.New("email has no body")
+	}
+
+	if encoded, _ := payload["images"].(map[string]interface{}); len(encoded) > 0 {
+		msg.Images = make(map[string][]byte, len(encoded))
+		for cid, value := range encoded {
+			data, _ := value.(string)
+			image, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image %s: %w", cid, err)
+			}
+			msg.Images[cid] = image
+		}
+	}
+	if encoded, _ := payload["attachments"].([]interface{}); len(encoded) > 0 {
+		msg.Attachments = make([]Attachment, 0, len(encoded))
+		for _, value := range encoded {
+			fields, _ := value.(map[string]interface{})
+			filename, _ := fields["filename"].(string)
+			contentType, _ := fields["content_type"].(string)
+			data, _ := fields["data"].(string)
+			decoded, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid attachment %s: %w", filename, err)
+			}
+			msg.Attachments = append(msg.Attachments, Attachment{Filename: filename, ContentType: contentType, Data: decoded})
+		}
+	}
+	return msg, nil
+}
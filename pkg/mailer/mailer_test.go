@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessagePayloadRoundTrip(t *testing.T) {
+	msg := &Message{
+		To:          "pm@example.com",
+		Subject:     "Digest",
+		Text:        "Plain",
+		HTML:        "<p>Rich</p>",
+		Images:      map[string][]byte{"chart": {0x89, 'P', 'N', 'G'}},
+		Attachments: []Attachment{{Filename: "roll.csv", ContentType: "text/csv", Data: []byte("a,b\n")}},
+	}
+
+	// Payloads are stored as JSON in the outbox
+	raw, err := json.Marshal(msg.Payload())
+	require.NoError(t, err)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &payload))
+
+	decoded, err := MessageFromPayload("pm@example.com", payload)
+	require.NoError(t, err)
+	assert.Equal(t, msg, decoded)
+}
+
+func TestMessageFromPayloadErrors(t *testing.T) {
+	_, err := MessageFromPayload("pm@example.com", map[string]interface{}{"subject": "No body"})
+	assert.Error(t, err)
+
+	_, err = MessageFromPayload("pm@example.com", map[string]interface{}{
+		"body":   "Text",
+		"images": map[string]interface{}{"chart": "not base64!"},
+	})
+	assert.Error(t, err)
+}
+
+func TestNewSenderFromEnv(t *testing.T) {
+	for _, key := range []string{"MAIL_PROVIDER", "MAIL_FROM", "SMTP_HOST", "SMTP_PORT", "SMTP_USERNAME", "SMTP_PASSWORD", "SMTP_FROM", "SENDGRID_API_KEY"} {
+		t.Setenv(key, "")
+	}
+
+	sender, err := NewSenderFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, sender)
+
+	t.Setenv("SMTP_HOST", "mail.example.com")
+	t.Setenv("SMTP_USERNAME", "noreply@example.com")
+	sender, err = NewSenderFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, &SMTPSender{Host: "mail.example.com", Port: "587", Username: "noreply@example.com", From: "noreply@example.com"}, sender)
+
+	t.Setenv("MAIL_PROVIDER", "sendgrid")
+	_, err = NewSenderFromEnv()
+	assert.Error(t, err, "sendgrid needs an API key")
+
+	t.Setenv("SENDGRID_API_KEY", "SG.key")
+	t.Setenv("MAIL_FROM", "alerts@example.com")
+	sender, err = NewSenderFromEnv()
+	require.NoError(t, err)
+	require.IsType(t, &SendGridSender{}, sender)
+	assert.Equal(t, "alerts@example.com", sender.(*SendGridSender).From)
+
+	t.Setenv("MAIL_PROVIDER", "pigeon")
+	_, err = NewSenderFromEnv()
+	assert.Error(t, err)
+}
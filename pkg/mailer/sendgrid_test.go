@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendGridSend(t *testing.T) {
+	var got map[string]interface{}
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sender := NewSendGridSender("SG.key", "noreply@example.com")
+	sender.URL = server.URL
+	err := sender.Send(context.Background(), &Message{
+		To:      "pm@example.com",
+		Subject: "Digest",
+		Text:    "Plain",
+		HTML:    `<img src="cid:chart">`,
+		Images:  map[string][]byte{"chart": {0x89, 'P', 'N', 'G'}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Bearer SG.key", gotAuth)
+	assert.Equal(t, "Digest", got["subject"])
+	assert.Equal(t, map[string]interface{}{"email": "noreply@example.com"}, got["from"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"to": []interface{}{map[string]interface{}{"email": "pm@example.com"}},
+	}}, got["personalizations"])
+	assert.Len(t, got["content"], 2)
+	attachments := got["attachments"].([]interface{})
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "chart", attachments[0].(map[string]interface{})["content_id"])
+	assert.Equal(t, "inline", attachments[0].(map[string]interface{})["disposition"])
+}
+
+func TestSendGridSendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":[{"message":"bad key"}]}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	sender := NewSendGridSender("SG.key", "noreply@example.com")
+	sender.URL = server.URL
+	err := sender.Send(context.Background(), &Message{To: "pm@example.com", Text: "Plain"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad key")
+}
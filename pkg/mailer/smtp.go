@@ -0,0 +1,209 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// SMTPSender delivers email through an SMTP server, authenticating when Username is set
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send formats the message as MIME and hands it to the SMTP server
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	message := FormatEmail(s.From, msg.To, msg.Subject, msg.Text)
+	if len(msg.Attachments) > 0 {
+		var err error
+		if message, err = FormatEmailWithAttachments(s.From, msg.To, msg.Subject, msg.Text, msg.Attachments); err != nil {
+			return err
+		}
+	} else if msg.HTML != "" {
+		var err error
+		if message, err = FormatHTMLEmail(s.From, msg.To, msg.Subject, msg.Text, msg.HTML, msg.Images); err != nil {
+			return err
+		}
+	}
+
+	return smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, s.From, []string{msg.To}, message)
+}
+
+// FormatEmail builds a plain-text RFC 5322 message
+func FormatEmail(from, to, subject, body string) []byte {
+	var b strings.Builder
+	writeEmailHeaders(&b, from, to, subject)
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// FormatHTMLEmail builds a multipart/alternative message with a plain-text body and an HTML body.
+// images are attached inline under their Content-IDs so the HTML can show them as cid: URLs.
+func FormatHTMLEmail(from, to, subject, text, html string, images map[string][]byte) ([]byte, error) {
+	var b bytes.Buffer
+	alternative := multipart.NewWriter(&b)
+
+	var headers strings.Builder
+	writeEmailHeaders(&headers, from, to, subject)
+	fmt.Fprintf(&headers, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", alternative.Boundary())
+	message := bytes.NewBufferString(headers.String())
+
+	part, err := alternative.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(part, strings.ReplaceAll(text, "\n", "\r\n")); err != nil {
+		return nil, err
+	}
+
+	// The HTML and its images form a multipart/related part of the alternative
+	var related bytes.Buffer
+	relatedWriter := multipart.NewWriter(&related)
+	part, err = relatedWriter.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(part, html); err != nil {
+		return nil, err
+	}
+
+	cids := make([]string, 0, len(images))
+	for cid := range images {
+		cids = append(cids, cid)
+	}
+	sort.Strings(cids)
+	for _, cid := range cids {
+		part, err := relatedWriter.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"image/png"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-ID":                {"<" + cid + ">"},
+			"Content-Disposition":       {fmt.Sprintf("inline; filename=%q", cid+".png")},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64Lines(part, images[cid])
+	}
+	if err := relatedWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	part, err = alternative.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf("multipart/related; boundary=%q", relatedWriter.Boundary())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(related.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+
+	message.Write(b.Bytes())
+	return message.Bytes(), nil
+}
+
+// FormatEmailWithAttachments builds a multipart/mixed message with a plain-text body followed by
+// the attachments
+func FormatEmailWithAttachments(from, to, subject, body string, attachments []Attachment) ([]byte, error) {
+	var b bytes.Buffer
+	mixed := multipart.NewWriter(&b)
+
+	var headers strings.Builder
+	writeEmailHeaders(&headers, from, to, subject)
+	fmt.Fprintf(&headers, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mixed.Boundary())
+	message := bytes.NewBufferString(headers.String())
+
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(part, strings.ReplaceAll(body, "\n", "\r\n")); err != nil {
+		return nil, err
+	}
+
+	// Filenames are quoted, so strip the characters that would end the quoting or the header
+	clean := strings.NewReplacer("\r", "", "\n", "", `"`, "", `\`, "")
+	for _, attachment := range attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", clean.Replace(attachment.Filename))},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64Lines(part, attachment.Data)
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+
+	message.Write(b.Bytes())
+	return message.Bytes(), nil
+}
+
+// writeBase64Lines writes data base64-encoded in lines of 76 characters, as MIME requires
+func writeBase64Lines(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		fmt.Fprintf(w, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(w, "%s\r\n", encoded)
+}
+
+// writeEmailHeaders writes the address, subject and MIME version headers
+func writeEmailHeaders(b *strings.Builder, from, to, subject string) {
+	// Strip line breaks so header values cannot inject extra headers
+	clean := strings.NewReplacer("\r", "", "\n", " ")
+
+	fmt.Fprintf(b, "From: %s\r\n", clean.Replace(from))
+	fmt.Fprintf(b, "To: %s\r\n", clean.Replace(to))
+	fmt.Fprintf(b, "Subject: %s\r\n", clean.Replace(subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+}
+
+// writeQuotedPrintable writes s to w in quoted-printable encoding
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
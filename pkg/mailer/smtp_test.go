@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatEmail(t *testing.T) {
+	msg := string(FormatEmail("noreply@example.com", "oncall@example.com", "Emergency\nBcc: x@example.com", "Line one\nLine two"))
+
+	assert.Contains(t, msg, "Subject: Emergency Bcc: x@example.com\r\n")
+	assert.Contains(t, msg, "\r\n\r\nLine one\r\nLine two")
+}
+
+func TestFormatHTMLEmail(t *testing.T) {
+	raw, err := FormatHTMLEmail("noreply@example.com", "pm@example.com", "Digest", "Plain\nbody",
+		`<p>Rich <img src="cid:chart"></p>`, map[string][]byte{"chart": {0x89, 'P', 'N', 'G'}})
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	text, err := parts.NextPart()
+	require.NoError(t, err)
+	body, _ := io.ReadAll(text)
+	assert.Equal(t, "Plain\r\nbody", string(body))
+
+	related, err := parts.NextPart()
+	require.NoError(t, err)
+	mediaType, params, err = mime.ParseMediaType(related.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/related", mediaType)
+
+	inner := multipart.NewReader(related, params["boundary"])
+	html, err := inner.NextPart()
+	require.NoError(t, err)
+	body, _ = io.ReadAll(html)
+	assert.Equal(t, `<p>Rich <img src="cid:chart"></p>`, string(body))
+
+	image, err := inner.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "<chart>", image.Header.Get("Content-ID"))
+	body, _ = io.ReadAll(base64.NewDecoder(base64.StdEncoding, image))
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, body)
+}
+
+func TestFormatEmailWithAttachments(t *testing.T) {
+	raw, err := FormatEmailWithAttachments("noreply@example.com", "pm@example.com", "Rent Roll", "Attached.",
+		[]Attachment{{Filename: "Rent_Roll\".csv", ContentType: "text/csv", Data: []byte("a,b\n1,2\n")}})
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	text, err := parts.NextPart()
+	require.NoError(t, err)
+	body, _ := io.ReadAll(text)
+	assert.Equal(t, "Attached.", string(body))
+
+	attachment, err := parts.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "text/csv", attachment.Header.Get("Content-Type"))
+	assert.Equal(t, "Rent_Roll.csv", attachment.FileName())
+	body, _ = io.ReadAll(base64.NewDecoder(base64.StdEncoding, attachment))
+	assert.Equal(t, "a,b\n1,2\n", string(body))
+}
@@ -0,0 +1,70 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*
+var templateFiles embed.FS
+
+// ErrUnknownTemplate is returned when no email template has the given name
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// templateFuncs are available to both the text and HTML parts of a template
+var templateFuncs = map[string]interface{}{
+	"paragraphs": paragraphs,
+}
+
+// paragraphs splits text on blank lines so HTML templates can wrap each paragraph in <p>
+func paragraphs(text string) []string {
+	var result []string
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Render builds a message from templates/<name>.tmpl, which defines "subject", "text" and
+// "content". The subject and text are rendered as plain text; "content" is rendered as HTML
+// inside templates/layout.html. The caller sets the recipient.
+func Render(name string, data interface{}) (*Message, error) {
+	file := "templates/" + name + ".tmpl"
+	if _, err := fs.Stat(templateFiles, file); err != nil {
+		return nil, ErrUnknownTemplate
+	}
+
+	text, err := texttemplate.New(name).Funcs(templateFuncs).ParseFS(templateFiles, file)
+	if err != nil {
+		return nil, err
+	}
+	var subject, body bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := text.ExecuteTemplate(&body, "text", data); err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.New(name).Funcs(templateFuncs).ParseFS(templateFiles, "templates/layout.html", file)
+	if err != nil {
+		return nil, err
+	}
+	var page bytes.Buffer
+	if err := html.ExecuteTemplate(&page, "layout.html", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+		HTML:    page.String(),
+	}, nil
+}
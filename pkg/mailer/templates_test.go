@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	msg, err := Render("password_reset", map[string]interface{}{
+		"Name":    "Jane",
+		"Link":    "https://app.example.com/password-reset?token=abc",
+		"Expires": "March 1, 2026 15:04 UTC",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Reset your password", msg.Subject)
+	assert.Contains(t, msg.Text, "Hello Jane,")
+	assert.Contains(t, msg.Text, "https://app.example.com/password-reset?token=abc\n")
+	assert.Contains(t, msg.HTML, "<title>Reset your password</title>")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/password-reset?token=abc"`)
+}
+
+func TestRenderEscapesHTMLOnly(t *testing.T) {
+	msg, err := Render("maintenance_message", map[string]interface{}{
+		"RequestID":  7,
+		"AuthorName": "Tom & Co",
+		"Body":       "First <b>paragraph</b>\n\nSecond",
+		"Link":       "",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "New message on maintenance request #7", msg.Subject)
+	assert.Contains(t, msg.Text, "Tom & Co wrote")
+	assert.Contains(t, msg.Text, "First <b>paragraph</b>")
+	assert.NotContains(t, msg.Text, "View the conversation")
+	assert.Contains(t, msg.HTML, "Tom &amp; Co")
+	assert.Contains(t, msg.HTML, "<p>First &lt;b&gt;paragraph&lt;/b&gt;</p>")
+	assert.Contains(t, msg.HTML, "<p>Second</p>")
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	_, err := Render("missing", nil)
+	assert.ErrorIs(t, err, ErrUnknownTemplate)
+
+	_, err = Render("../mailer", nil)
+	assert.ErrorIs(t, err, ErrUnknownTemplate)
+}
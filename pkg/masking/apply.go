@@ -0,0 +1,129 @@
+package masking
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TableResult counts the rows masked or deleted in one table
+type TableResult struct {
+	Table   string `json:"table"`
+	Masked  int    `json:"masked,omitempty"`
+	Deleted int    `json:"deleted,omitempty"`
+}
+
+// Apply masks the database in place according to cfg, in one transaction so a failure leaves it
+// untouched. Run it against a copy restored from production, never production itself.
+func Apply(conn *sql.DB, cfg *Config, m *Masker) ([]TableResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]TableResult, 0, len(cfg.Tables))
+	for i := range cfg.Tables {
+		rule := &cfg.Tables[i]
+		result := TableResult{Table: rule.Table}
+		if rule.Delete {
+			deleted, err := tx.Exec("DELETE FROM " + rule.Table)
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete from %s: %w", rule.Table, err)
+			}
+			n, _ := deleted.RowsAffected()
+			result.Deleted = int(n)
+		} else if result.Masked, err = maskTable(tx, rule, m); err != nil {
+			return nil, fmt.Errorf("failed to mask %s: %w", rule.Table, err)
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+// maskTable clears the rule's null columns and rewrites its other columns row by row, returning
+// the number of rows rewritten
+func maskTable(tx *sql.Tx, rule *TableRule, m *Masker) (int, error) {
+	var nullColumns, valueColumns []string
+	for _, column := range rule.columns() {
+		if rule.Columns[column] == KindNull {
+			nullColumns = append(nullColumns, column)
+		} else {
+			valueColumns = append(valueColumns, column)
+		}
+	}
+
+	masked := 0
+	if len(nullColumns) > 0 {
+		assignments := make([]string, len(nullColumns))
+		for i, column := range nullColumns {
+			assignments[i] = column + " = NULL"
+		}
+		result, err := tx.Exec("UPDATE " + rule.Table + " SET " + strings.Join(assignments, ", "))
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		masked = int(n)
+	}
+	if len(valueColumns) == 0 {
+		return masked, nil
+	}
+
+	// Read every row first: the driver cannot run updates while a result set is open
+	type row struct {
+		key    interface{}
+		values []sql.NullString
+	}
+	rows, err := tx.Query("SELECT " + rule.Key + ", " + strings.Join(valueColumns, ", ") + " FROM " + rule.Table)
+	if err != nil {
+		return 0, err
+	}
+	var all []row
+	for rows.Next() {
+		r := row{values: make([]sql.NullString, len(valueColumns))}
+		dest := []interface{}{&r.key}
+		for i := range r.values {
+			dest = append(dest, &r.values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	assignments := make([]string, len(valueColumns))
+	for i, column := range valueColumns {
+		assignments[i] = fmt.Sprintf("%s = $%d", column, i+1)
+	}
+	update := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d",
+		rule.Table, strings.Join(assignments, ", "), rule.Key, len(valueColumns)+1)
+
+	for _, r := range all {
+		args := make([]interface{}, 0, len(valueColumns)+1)
+		for i, column := range valueColumns {
+			value := r.values[i]
+			if value.Valid {
+				value.String = m.Mask(rule.Columns[column], value.String)
+			}
+			args = append(args, value)
+		}
+		args = append(args, r.key)
+		if _, err := tx.Exec(update, args...); err != nil {
+			return 0, err
+		}
+	}
+	if len(all) > masked {
+		masked = len(all)
+	}
+	return masked, nil
+}
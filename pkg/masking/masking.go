@@ -0,0 +1,237 @@
+// Package masking rewrites a database's personal data with realistic stand-ins, so a copy of
+// production can be used in staging for report development without exposing tenants and users.
+//
+// Masking is deterministic: a value always masks to the same stand-in under the same key, so an
+// email shared by a user and a tenant still matches after masking, unique columns stay unique and
+// grouping by name still groups the same rows. Stand-ins are derived from an HMAC of the value, so
+// they cannot be reversed without the key.
+package masking
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Masking kinds, the kinds of value a column holds
+const (
+	KindFirstName = "first_name"
+	KindLastName  = "last_name"
+	KindName      = "name" // Full name
+	KindEmail     = "email"
+	KindUsername  = "username"
+	KindPhone     = "phone"
+	KindAddress   = "address"
+	KindIP        = "ip"
+	KindToken     = "token" // Opaque identifier, such as a payment provider's token
+	KindText      = "text"  // Free text, replaced by a placeholder
+	KindNull      = "null"  // Cleared
+)
+
+var kinds = map[string]bool{
+	KindFirstName: true, KindLastName: true, KindName: true, KindEmail: true, KindUsername: true,
+	KindPhone: true, KindAddress: true, KindIP: true, KindToken: true, KindText: true, KindNull: true,
+}
+
+// ErrNoKey is returned when masking is attempted without a key
+var ErrNoKey = errors.New("a masking key is required")
+
+var identifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// TableRule masks the columns of one table, or deletes its rows
+type TableRule struct {
+	Table   string            `json:"table" yaml:"table"`
+	Key     string            `json:"key,omitempty" yaml:"key"`         // Primary key column; id when omitted
+	Delete  bool              `json:"delete,omitempty" yaml:"delete"`   // Delete every row rather than mask it
+	Columns map[string]string `json:"columns,omitempty" yaml:"columns"` // Masking kind by column
+}
+
+// Config lists the tables to mask
+type Config struct {
+	Tables []TableRule `json:"tables" yaml:"tables"`
+}
+
+// ParseConfig reads a masking config from YAML or JSON and validates it
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid masking config: %w", err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks table and column names and masking kinds, and fills in default keys
+func (c *Config) Validate() error {
+	for i := range c.Tables {
+		t := &c.Tables[i]
+		if t.Key == "" {
+			t.Key = "id"
+		}
+		if !identifier.MatchString(t.Table) || !identifier.MatchString(t.Key) {
+			return fmt.Errorf("invalid table %q or key %q", t.Table, t.Key)
+		}
+		if t.Delete {
+			continue
+		}
+		if len(t.Columns) == 0 {
+			return fmt.Errorf("table %s has no columns to mask", t.Table)
+		}
+		for column, kind := range t.Columns {
+			if !identifier.MatchString(column) {
+				return fmt.Errorf("invalid column %q in table %s", column, t.Table)
+			}
+			if !kinds[kind] {
+				return fmt.Errorf("unknown masking kind %q for %s.%s", kind, t.Table, column)
+			}
+		}
+	}
+	return nil
+}
+
+// columns returns the rule's columns in a stable order
+func (t *TableRule) columns() []string {
+	columns := make([]string, 0, len(t.Columns))
+	for column := range t.Columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// DefaultConfig masks the personal data of tenants, users, applicants, owners and contacts and the
+// tenants' bank account tokens, and deletes sessions, trusted devices and queued notifications,
+// which are no use outside production
+var DefaultConfig = Config{Tables: []TableRule{
+	{Table: "users", Columns: map[string]string{
+		"first_name": KindFirstName, "last_name": KindLastName, "email": KindEmail, "username": KindUsername,
+		"phone_number": KindPhone, "profile_picture_url": KindNull, "keycloak_id": KindNull,
+		"mfa_secret": KindNull, "password_reset_token": KindNull,
+	}},
+	{Table: "tenants", Columns: map[string]string{
+		"first_name": KindFirstName, "last_name": KindLastName, "email": KindEmail, "phone_number": KindPhone,
+	}},
+	{Table: "rental_applications", Columns: map[string]string{
+		"first_name": KindFirstName, "last_name": KindLastName, "email": KindEmail, "phone_number": KindPhone,
+		"consent_ip": KindIP, "consent_user_agent": KindNull,
+	}},
+	{Table: "ach_bank_accounts", Columns: map[string]string{"external_id": KindToken}},
+	{Table: "ach_mandates", Columns: map[string]string{"authorized_ip": KindIP}},
+	{Table: "association_owners", Columns: map[string]string{
+		"name": KindName, "email": KindEmail, "phone": KindPhone, "mailing_address": KindAddress,
+	}},
+	{Table: "properties", Columns: map[string]string{
+		"emergency_contact_name": KindName, "emergency_contact_email": KindEmail, "emergency_contact_phone": KindPhone,
+	}},
+	{Table: "unit_listings", Key: "unit_id", Columns: map[string]string{
+		"contact_name": KindName, "contact_email": KindEmail, "contact_phone": KindPhone,
+	}},
+	{Table: "maintenance_approval_policies", Key: "property_id", Columns: map[string]string{
+		"approver_name": KindName, "approver_email": KindEmail,
+	}},
+	{Table: "maintenance_cost_approvals", Columns: map[string]string{"approver_email": KindEmail}},
+	{Table: "user_invitations", Columns: map[string]string{"email": KindEmail}},
+	{Table: "email_verifications", Columns: map[string]string{"email": KindEmail}},
+	{Table: "visitor_authorizations", Columns: map[string]string{"visitor_name": KindName}},
+	{Table: "visitor_logs", Columns: map[string]string{"visitor_name": KindName}},
+	{Table: "packages", Columns: map[string]string{"picked_up_by": KindName}},
+	{Table: "login_history", Columns: map[string]string{"identity": KindEmail, "ip_address": KindIP, "network": KindNull}},
+	{Table: "audit_log", Columns: map[string]string{"details": KindNull}},
+	{Table: "scheduled_reports", Columns: map[string]string{"email_recipients": KindNull}},
+	{Table: "outbox_messages", Delete: true},
+	{Table: "user_sessions", Delete: true},
+	{Table: "trusted_devices", Delete: true},
+	{Table: "idempotency_keys", Delete: true},
+}}
+
+var firstNames = []string{
+	"Avery", "Blake", "Cameron", "Dana", "Elliot", "Finley", "Gray", "Harper", "Indigo", "Jordan",
+	"Kai", "Logan", "Morgan", "Noel", "Oakley", "Parker", "Quinn", "Riley", "Sage", "Taylor",
+	"Umber", "Val", "Wren", "Xen", "Yael", "Zion", "Alex", "Brook", "Casey", "Drew",
+	"Emery", "Frankie", "Hayden", "Jamie", "Kendall", "Lennon", "Marlow", "Nico", "Peyton", "Reese",
+}
+
+var lastNames = []string{
+	"Abbott", "Barker", "Calder", "Dalton", "Ellison", "Fairbanks", "Garrison", "Hollis", "Ingram", "Jarvis",
+	"Keller", "Lambert", "Mercer", "Norwood", "Osborne", "Prescott", "Quincy", "Radcliffe", "Sterling", "Thorne",
+	"Underwood", "Vance", "Whitaker", "Yardley", "Ashford", "Brennan", "Carver", "Donovan", "Everett", "Fletcher",
+	"Gallagher", "Hensley", "Kingsley", "Lowell", "Maddox", "Nash", "Pembroke", "Rowan", "Sutton", "Winslow",
+}
+
+var streets = []string{
+	"Maple", "Oak", "Cedar", "Pine", "Elm", "Birch", "Willow", "Aspen", "Spruce", "Juniper",
+	"Hawthorn", "Chestnut", "Magnolia", "Sycamore", "Laurel", "Alder",
+}
+
+// Masker derives stand-ins for values from a secret key
+type Masker struct {
+	key []byte
+}
+
+// NewMasker creates a masker for a key. Use the same key to mask related databases consistently
+// and keep it secret: anyone holding it can test guesses of the original values.
+func NewMasker(key string) (*Masker, error) {
+	if key == "" {
+		return nil, ErrNoKey
+	}
+	return &Masker{key: []byte(key)}, nil
+}
+
+// digest is the HMAC of a value for a kind, ignoring case and surrounding space so variants of the
+// same email or name mask alike
+func (m *Masker) digest(kind, value string) []byte {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return mac.Sum(nil)
+}
+
+// pick chooses a list entry from part of a digest
+func pick(list []string, digest []byte, offset int) string {
+	return list[binary.BigEndian.Uint32(digest[offset:offset+4])%uint32(len(list))]
+}
+
+// Mask returns the stand-in for a value of a kind. Empty values stay empty.
+func (m *Masker) Mask(kind, value string) string {
+	if value == "" || kind == KindNull {
+		return ""
+	}
+	d := m.digest(kind, value)
+	tag := hex.EncodeToString(d[:5])
+	switch kind {
+	case KindFirstName:
+		return pick(firstNames, d, 0)
+	case KindLastName:
+		return pick(lastNames, d, 0)
+	case KindName:
+		return pick(firstNames, d, 0) + " " + pick(lastNames, d, 4)
+	case KindEmail:
+		// The tag keeps distinct addresses distinct; example.com never receives mail
+		return fmt.Sprintf("user-%s@example.com", tag)
+	case KindUsername:
+		return "user-" + tag
+	case KindPhone:
+		// 555-01xx numbers are reserved for fiction
+		return fmt.Sprintf("555-01%02d", binary.BigEndian.Uint32(d[0:4])%100)
+	case KindAddress:
+		return fmt.Sprintf("%d %s Street", 100+binary.BigEndian.Uint32(d[0:4])%9900, pick(streets, d, 4))
+	case KindIP:
+		// 192.0.2.0/24 is reserved for documentation
+		return fmt.Sprintf("192.0.2.%d", d[0])
+	case KindToken:
+		// Long enough that distinct tokens stay distinct under their unique constraints
+		return "masked-" + hex.EncodeToString(d[:16])
+	}
+	return "Masked text " + tag
+}
@@ -0,0 +1,99 @@
+package masking
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskIsDeterministic(t *testing.T) {
+	m, err := NewMasker("staging-key")
+	require.NoError(t, err)
+
+	email := m.Mask(KindEmail, "Jane.Doe@Example.org")
+	assert.Regexp(t, `^user-[0-9a-f]{10}@example\.com$`, email)
+	assert.Equal(t, email, m.Mask(KindEmail, " jane.doe@example.org "), "case and space are ignored")
+	assert.NotEqual(t, email, m.Mask(KindEmail, "john.doe@example.org"))
+
+	other, err := NewMasker("other-key")
+	require.NoError(t, err)
+	assert.NotEqual(t, email, other.Mask(KindEmail, "jane.doe@example.org"), "stand-ins depend on the key")
+
+	assert.Contains(t, firstNames, m.Mask(KindFirstName, "Jane"))
+	assert.Contains(t, lastNames, m.Mask(KindLastName, "Doe"))
+	assert.Regexp(t, `^555-01\d\d$`, m.Mask(KindPhone, "+1 415 555 2671"))
+	assert.Regexp(t, `^192\.0\.2\.\d+$`, m.Mask(KindIP, "203.0.113.7"))
+	assert.Regexp(t, `^\d+ \w+ Street$`, m.Mask(KindAddress, "12 Real Road"))
+	assert.Regexp(t, `^masked-[0-9a-f]{32}$`, m.Mask(KindToken, "ba_1NfQ2x"))
+	assert.Equal(t, "", m.Mask(KindName, ""))
+	assert.Equal(t, "", m.Mask(KindNull, "secret"))
+}
+
+func TestNewMaskerRequiresKey(t *testing.T) {
+	_, err := NewMasker("")
+	assert.Equal(t, ErrNoKey, err)
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+tables:
+  - table: tenants
+    columns:
+      first_name: first_name
+      email: email
+  - table: user_sessions
+    delete: true
+`))
+	require.NoError(t, err)
+	require.Len(t, cfg.Tables, 2)
+	assert.Equal(t, "id", cfg.Tables[0].Key)
+	assert.True(t, cfg.Tables[1].Delete)
+
+	_, err = ParseConfig([]byte(`{"tables": [{"table": "tenants", "columns": {"email": "scramble"}}]}`))
+	assert.Error(t, err, "unknown kind")
+	_, err = ParseConfig([]byte(`{"tables": [{"table": "tenants; DROP TABLE users", "delete": true}]}`))
+	assert.Error(t, err, "invalid table name")
+	_, err = ParseConfig([]byte(`{"tables": [{"table": "tenants", "colums": {"email": "email"}}]}`))
+	assert.Error(t, err, "unknown field")
+
+	assert.NoError(t, DefaultConfig.Validate())
+}
+
+func TestApply(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	m, err := NewMasker("staging-key")
+	require.NoError(t, err)
+	cfg := &Config{Tables: []TableRule{
+		{Table: "tenants", Columns: map[string]string{"email": KindEmail, "first_name": KindFirstName, "notes": KindNull}},
+		{Table: "user_sessions", Delete: true},
+	}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE tenants SET notes = NULL")).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, first_name FROM tenants")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "first_name"}).
+			AddRow(1, "jane@example.org", "Jane").
+			AddRow(2, nil, "John"))
+	update := regexp.QuoteMeta("UPDATE tenants SET email = $1, first_name = $2 WHERE id = $3")
+	mock.ExpectExec(update).
+		WithArgs(m.Mask(KindEmail, "jane@example.org"), m.Mask(KindFirstName, "Jane"), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(update).
+		WithArgs(nil, m.Mask(KindFirstName, "John"), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM user_sessions")).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectCommit()
+
+	results, err := Apply(mockDB, cfg, m)
+	require.NoError(t, err)
+	assert.Equal(t, []TableResult{{Table: "tenants", Masked: 2}, {Table: "user_sessions", Deleted: 5}}, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
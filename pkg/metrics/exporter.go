@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// DefaultKPIMetrics are the kpi_metrics exported when KPI_EXPORT_METRICS is not set
+var DefaultKPIMetrics = []string{"Occupancy Rate", "Collection Rate", "Monthly Revenue"}
+
+// KPIExporter periodically pushes the latest KPI metrics to a Prometheus Pushgateway
+type KPIExporter struct {
+	PushgatewayURL string
+	Job            string
+	Interval       time.Duration
+	MetricNames    []string
+	Client         *http.Client
+}
+
+// NewKPIExporterFromEnv builds an exporter from environment settings. It returns nil when
+// PROMETHEUS_PUSHGATEWAY_URL is not set, which disables the exporter.
+//
+//	PROMETHEUS_PUSHGATEWAY_URL  Pushgateway base URL, e.g. http://pushgateway:9091
+//	KPI_EXPORT_INTERVAL         Push interval as a Go duration (default 5m)
+//	KPI_EXPORT_METRICS          Comma-separated kpi_metrics names to export
+//	KPI_EXPORT_JOB              Pushgateway job label (default fire_pmaas_kpis)
+func NewKPIExporterFromEnv() *KPIExporter {
+	pushURL := os.Getenv("PROMETHEUS_PUSHGATEWAY_URL")
+	if pushURL == "" {
+		return nil
+	}
+
+	exporter := &KPIExporter{
+		PushgatewayURL: strings.TrimSuffix(pushURL, "/"),
+		Job:            "fire_pmaas_kpis",
+		Interval:       5 * time.Minute,
+		MetricNames:    DefaultKPIMetrics,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if job := os.Getenv("KPI_EXPORT_JOB"); job != "" {
+		exporter.Job = job
+	}
+	if interval, err := time.ParseDuration(os.Getenv("KPI_EXPORT_INTERVAL")); err == nil && interval > 0 {
+		exporter.Interval = interval
+	}
+	if names := os.Getenv("KPI_EXPORT_METRICS"); names != "" {
+		exporter.MetricNames = nil
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				exporter.MetricNames = append(exporter.MetricNames, name)
+			}
+		}
+	}
+
+	return exporter
+}
+
+// Run pushes metrics every Interval until the context is cancelled
+func (e *KPIExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.PushOnce(ctx); err != nil {
+			slog.Error("KPI export to Pushgateway failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PushOnce loads the latest KPI values and replaces the job's metrics on the Pushgateway
+func (e *KPIExporter) PushOnce(ctx context.Context) error {
+	kpis, err := models.GetLatestKPIMetrics(e.MetricNames)
+	if err != nil {
+		return fmt.Errorf("failed to load KPI metrics: %w", err)
+	}
+
+	body := FormatKPIMetrics(kpis)
+	pushURL := fmt.Sprintf("%s/metrics/job/%s", e.PushgatewayURL, url.PathEscape(e.Job))
+
+	// PUT replaces every metric in the job's group so removed properties disappear
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, pushURL, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("pushgateway returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// FormatKPIMetrics renders KPI metrics in the Prometheus text exposition format.
+// Each KPI becomes a gauge named pmaas_kpi_<metric_name> labelled by property and category.
+func FormatKPIMetrics(kpis []models.PropertyKPIMetric) string {
+	grouped := make(map[string][]models.PropertyKPIMetric)
+	for _, kpi := range kpis {
+		name := "pmaas_kpi_" + SanitizeMetricName(kpi.MetricName)
+		grouped[name] = append(grouped[name], kpi)
+	}
+
+	names := make([]string, 0, len(grouped))
+	for name := range grouped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf strings.Builder
+	for _, name := range names {
+		group := grouped[name]
+		fmt.Fprintf(&buf, "# HELP %s %s\n", name, escapeHelp(group[0].MetricName))
+		fmt.Fprintf(&buf, "# TYPE %s gauge\n", name)
+
+		for _, kpi := range group {
+			propertyID := "all"
+			if kpi.PropertyID.Valid {
+				propertyID = strconv.Itoa(int(kpi.PropertyID.Int32))
+			}
+
+			labels := []string{
+				fmt.Sprintf("property_id=%q", propertyID),
+				fmt.Sprintf("property=%q", kpi.PropertyName),
+				fmt.Sprintf("category=%q", kpi.Category),
+			}
+			if kpi.MetricUnit.Valid {
+				labels = append(labels, fmt.Sprintf("unit=%q", kpi.MetricUnit.String))
+			}
+
+			fmt.Fprintf(&buf, "%s{%s} %s\n", name, strings.Join(labels, ","),
+				strconv.FormatFloat(kpi.MetricValue, 'f', -1, 64))
+		}
+	}
+
+	return buf.String()
+}
+
+// SanitizeMetricName converts a KPI display name such as "Occupancy Rate" to occupancy_rate
+func SanitizeMetricName(name string) string {
+	var b strings.Builder
+	lastUnderscore := true
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			lastUnderscore = false
+		} else if !lastUnderscore {
+			b.WriteByte('_')
+			lastUnderscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// escapeHelp escapes backslashes and newlines as required in HELP lines
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeMetricName(t *testing.T) {
+	assert.Equal(t, "occupancy_rate", SanitizeMetricName("Occupancy Rate"))
+	assert.Equal(t, "net_operating_income_noi", SanitizeMetricName("Net Operating Income (NOI)"))
+	assert.Equal(t, "revenue_2024", SanitizeMetricName("  Revenue -- 2024 "))
+}
+
+func TestFormatKPIMetrics(t *testing.T) {
+	kpis := []models.PropertyKPIMetric{
+		{
+			KPIMetric: models.KPIMetric{
+				MetricName:  "Occupancy Rate",
+				MetricValue: 85.5,
+				MetricUnit:  sql.NullString{String: "percentage", Valid: true},
+				Category:    "operational",
+				PropertyID:  sql.NullInt32{Int32: 3, Valid: true},
+			},
+			PropertyName: "Sunset Apartments",
+		},
+		{
+			KPIMetric: models.KPIMetric{
+				MetricName:  "Occupancy Rate",
+				MetricValue: 90,
+				Category:    "operational",
+			},
+		},
+	}
+
+	expected := "# HELP pmaas_kpi_occupancy_rate Occupancy Rate\n" +
+		"# TYPE pmaas_kpi_occupancy_rate gauge\n" +
+		"pmaas_kpi_occupancy_rate{property_id=\"3\",property=\"Sunset Apartments\",category=\"operational\",unit=\"percentage\"} 85.5\n" +
+		"pmaas_kpi_occupancy_rate{property_id=\"all\",property=\"\",category=\"operational\"} 90\n"
+
+	assert.Equal(t, expected, FormatKPIMetrics(kpis))
+}
+
+func TestNewKPIExporterFromEnv(t *testing.T) {
+	t.Setenv("PROMETHEUS_PUSHGATEWAY_URL", "")
+	assert.Nil(t, NewKPIExporterFromEnv())
+
+	t.Setenv("PROMETHEUS_PUSHGATEWAY_URL", "http://pushgateway:9091/")
+	t.Setenv("KPI_EXPORT_METRICS", "Occupancy Rate, Monthly Revenue")
+	t.Setenv("KPI_EXPORT_INTERVAL", "1m")
+
+	exporter := NewKPIExporterFromEnv()
+	assert.NotNil(t, exporter)
+	assert.Equal(t, "http://pushgateway:9091", exporter.PushgatewayURL)
+	assert.Equal(t, []string{"Occupancy Rate", "Monthly Revenue"}, exporter.MetricNames)
+	assert.Equal(t, "1m0s", exporter.Interval.String())
+}
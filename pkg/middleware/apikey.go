@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// APIKeyFromRequest extracts an API key from the X-API-Key header, a Bearer
+// Authorization header or the api_key query parameter, in that order
+func APIKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// RequireAPIKey is a middleware that authenticates integrations by API key and loads
+// the key's owner into the request context. Unlike RequireLogin it never redirects.
+func RequireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := APIKeyFromRequest(r)
+		if token == "" {
+			http.Error(w, "Missing API key", http.StatusUnauthorized)
+			return
+		}
+
+		key, err := models.GetAPIKeyByToken(token)
+		if err != nil {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := models.GetUserByID(key.UserID)
+		if err != nil || user.Status != "active" {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		if !meterAPIRequest(w, r, user) {
+			return
+		}
+
+		if err := models.TouchAPIKey(key.ID); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to record API key usage", "api_key_id", key.ID, "error", err)
+		}
+
+		ctx := context.WithValue(r.Context(), UserContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyFromRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/integrations/me?api_key=query-key", nil)
+	assert.Equal(t, "query-key", APIKeyFromRequest(req))
+
+	req.Header.Set("Authorization", "Bearer bearer-key")
+	assert.Equal(t, "bearer-key", APIKeyFromRequest(req))
+
+	req.Header.Set("X-API-Key", "header-key")
+	assert.Equal(t, "header-key", APIKeyFromRequest(req))
+}
+
+func TestRequireAPIKeyMissingKey(t *testing.T) {
+	handler := RequireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be called without an API key")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/integrations/me", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
@@ -5,57 +5,58 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/greenbrown932/fire-pmaas/pkg/config"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
 	"github.com/greenbrown932/fire-pmaas/pkg/models"
 	"golang.org/x/oauth2"
 )
 
 var (
-	// You'll want to load these from environment variables in production!
-	clientID       = "pmaas-app"                        // Must match Keycloak client exactly
-	clientSecret   = "CALTAkts8DxnpjCeD6xSDcavEetqMrxl" // Add your actual client secret here
-	keycloakIssuer = os.Getenv("KEYCLOAK_ISSUER")
-	redirectURL    = "http://localhost:8000/callback"
+	// authConfig holds the OIDC client and cookie settings passed to InitOIDC
+	authConfig = config.Default
 
 	provider     *oidc.Provider
 	oidcConfig   *oidc.Config
 	oauth2Config oauth2.Config
 )
 
-// InitOIDC initializes the OIDC provider and configuration.
+// InitOIDC initializes the OIDC provider and configuration from a validated config.
 // Call this in main() before starting your server.
-func InitOIDC() error {
-	if keycloakIssuer == "" {
-		return fmt.Errorf("KEYCLOAK_ISSUER environment variable is not set")
+func InitOIDC(cfg *config.Config) error {
+	if cfg.OIDC.Issuer == "" {
+		return fmt.Errorf("OIDC issuer is not configured")
 	}
 
 	ctx := context.Background() // Create a background context
 	var err error               // Declare an error variable
 
 	// Initialize the OIDC provider
-	provider, err = oidc.NewProvider(ctx, keycloakIssuer)
+	provider, err = oidc.NewProvider(ctx, cfg.OIDC.Issuer)
 	if err != nil {
 		return fmt.Errorf("could not connect to OIDC provider: %w", err)
 	}
+	authConfig = cfg
 
 	// Configure the OIDC client
 	oidcConfig = &oidc.Config{
-		ClientID: clientID, // Set the client ID
+		ClientID: cfg.OIDC.ClientID, // Set the client ID
 	}
 
 	// Configure the OAuth2 settings
 	oauth2Config = oauth2.Config{
-		ClientID:     clientID,                                       // Set the client ID
-		ClientSecret: clientSecret,                                   // Set the client secret
-		Endpoint:     provider.Endpoint(),                            // Set the endpoint from the provider
-		RedirectURL:  redirectURL,                                    // Set the redirect URL
-		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"}, // Set the scopes
+		ClientID:     cfg.OIDC.ClientID,     // Set the client ID
+		ClientSecret: cfg.OIDC.ClientSecret, // Set the client secret
+		Endpoint:     provider.Endpoint(),   // Set the endpoint from the provider
+		RedirectURL:  cfg.OIDC.RedirectURL,  // Set the redirect URL
+		Scopes:       cfg.OIDC.Scopes,       // Set the scopes
 	}
 	return nil
 }
@@ -81,20 +82,31 @@ func RequireLogin(next http.Handler) http.Handler {
 		// If an ID token cookie is present, verify it before trusting.
 		c, err := r.Cookie("id_token")
 		if err == nil && c.Value != "" {
-			fmt.Println("id_token cookie found:", c.Value)
-
 			// Verify the ID token
-			_, err = provider.Verifier(oidcConfig).Verify(r.Context(), c.Value)
+			idToken, err := provider.Verifier(oidcConfig).Verify(r.Context(), c.Value)
+			if err == nil {
+				revoked, checkErr := sessionRevoked(idToken)
+				if checkErr != nil {
+					writeRevocationCheckError(w, r, checkErr)
+					return
+				}
+				if revoked {
+					err = errors.New("SSO session was logged out")
+				}
+			}
 			if err == nil {
-				fmt.Println("id_token cookie is valid")
 				// If verification is successful, serve the next handler
+				clearLoginRedirects(w, r)
 				next.ServeHTTP(w, r)
 				return
 			}
-			fmt.Println("id_token cookie is invalid:", err)
+			logging.FromContext(r.Context()).Debug("id_token cookie is invalid", "error", err)
 			// If verification fails, fall through to start login.
-		} else {
-			fmt.Println("id_token cookie not found:", err)
+		}
+
+		// Guard against redirect loops when logins keep failing
+		if !checkLoginRedirect(w, r, time.Now()) {
+			return
 		}
 
 		// Generate a simple per-request state to prevent CSRF in the OAuth2 flow.
@@ -115,7 +127,8 @@ func RequireLogin(next http.Handler) http.Handler {
 			Value:    codeVerifier,
 			Path:     "/",
 			HttpOnly: true,
-			Secure:   false, // Set to true in production with HTTPS
+			Secure:   authConfig.Cookies.IsSecure(),
+			// Stays Lax whatever the policy: the callback is a cross-site redirect from the provider
 			SameSite: http.SameSiteLaxMode,
 			MaxAge:   600, // 10 minutes
 		})
@@ -138,6 +151,11 @@ func RequireLogin(next http.Handler) http.Handler {
 func HandleCallback(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context() // Get the request context
 
+	// The identity provider reports cancelled or denied logins with an error parameter
+	if errCode := r.URL.Query().Get("error"); errCode != "" {
+		recordLogin(r, nil, "", false, errCode+": "+r.URL.Query().Get("error_description"), false)
+	}
+
 	// Get the code from the query parameters
 	code := r.URL.Query().Get("code")
 	if code == "" {
@@ -156,6 +174,7 @@ func HandleCallback(w http.ResponseWriter, r *http.Request) {
 	// Do the OAuth2 code-for-token exchange with PKCE
 	token, err := oauth2Config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
 	if err != nil {
+		recordLogin(r, nil, "", false, "token exchange failed", false)
 		http.Error(w, "Failed to exchange token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -178,40 +197,50 @@ func HandleCallback(w http.ResponseWriter, r *http.Request) {
 	// Verify the ID token
 	idToken, err := provider.Verifier(oidcConfig).Verify(ctx, rawIDToken)
 	if err != nil {
+		recordLogin(r, nil, "", false, "invalid ID token", false)
 		http.Error(w, "Invalid ID token: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
 
 	// Optionally parse user info (claims) from the token
 	var claims struct {
-		Email             string `json:"email"`
-		EmailVerified     bool   `json:"email_verified"`
-		Name              string `json:"name"`
-		PreferredUsername string `json:"preferred_username"`
+		Subject           string   `json:"sub"`
+		Email             string   `json:"email"`
+		EmailVerified     bool     `json:"email_verified"`
+		Name              string   `json:"name"`
+		PreferredUsername string   `json:"preferred_username"`
+		AMR               []string `json:"amr"`
 	}
 	if err := idToken.Claims(&claims); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Record the login against the local account, refusing accounts that are not allowed to sign in
+	identity := claims.Email
+	if identity == "" {
+		identity = claims.PreferredUsername
+	}
+	user := loginUser(claims.Subject, claims.Email, claims.EmailVerified)
+	mfaUsed := models.MFAUsed(claims.AMR)
+	if user != nil && user.Status != models.UserStatusActive && user.Status != models.UserStatusInvited {
+		recordLogin(r, user, identity, false, "account "+user.Status, mfaUsed)
+		http.Error(w, "Account is "+user.Status, http.StatusForbidden)
+		return
+	}
+	recordLogin(r, user, identity, true, "", mfaUsed)
+
 	// Set the ID token in a secure httpOnly cookie (for demo only)
 	http.SetCookie(w, &http.Cookie{
-		Name:     "id_token",           // Cookie name
-		Value:    rawIDToken,           // Cookie value
-		Path:     "/",                  // Cookie path
-		HttpOnly: true,                 // HttpOnly flag
-		Secure:   false,                // Set to true in production with https!
-		SameSite: http.SameSiteLaxMode, // SameSite attribute
-		MaxAge:   3600,                 // 1 hour
+		Name:     "id_token",                        // Cookie name
+		Value:    rawIDToken,                        // Cookie value
+		Path:     "/",                               // Cookie path
+		HttpOnly: true,                              // HttpOnly flag
+		Secure:   authConfig.Cookies.IsSecure(),     // Send only over HTTPS when configured
+		SameSite: authConfig.Cookies.SameSiteMode(), // SameSite attribute
+		MaxAge:   3600,                              // 1 hour
 	})
 
-	// Redirect to the home/dashboard
-	// Log the values for debugging
-	fmt.Printf("Code: %s\n", code)
-	fmt.Printf("Token: %+v\n", token)
-	fmt.Printf("Raw ID Token: %s\n", rawIDToken)
-	fmt.Printf("Claims: %+v\n", claims)
-
 	// Get the state from the query parameters
 	state := r.URL.Query().Get("state")
 	if state == "" {
@@ -222,19 +251,17 @@ func HandleCallback(w http.ResponseWriter, r *http.Request) {
 
 	// Set the ID token in a secure httpOnly cookie (for demo only)
 	cookie := &http.Cookie{
-		Name:     "id_token",           // Cookie name
-		Value:    rawIDToken,           // Cookie value
-		Path:     "/",                  // Cookie path
-		HttpOnly: true,                 // HttpOnly flag
-		Secure:   false,                // Set to true in production with https!
-		SameSite: http.SameSiteLaxMode, // SameSite attribute
-		MaxAge:   3600,                 // 1 hour
+		Name:     "id_token",                        // Cookie name
+		Value:    rawIDToken,                        // Cookie value
+		Path:     "/",                               // Cookie path
+		HttpOnly: true,                              // HttpOnly flag
+		Secure:   authConfig.Cookies.IsSecure(),     // Send only over HTTPS when configured
+		SameSite: authConfig.Cookies.SameSiteMode(), // SameSite attribute
+		MaxAge:   3600,                              // 1 hour
 	}
 	http.SetCookie(w, cookie)
-	fmt.Println("Cookie set")
 
 	// Redirect to the home/dashboard (clear query params to avoid loops)
-	fmt.Println("Redirecting to /")
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
@@ -295,42 +322,56 @@ func RequireRole(roleName string) func(http.Handler) http.Handler {
 func RequireAnyRole(roleNames ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := logging.FromContext(r.Context())
 			user, ok := GetUserFromContext(r.Context())
 			if !ok {
-				fmt.Printf("DEBUG: RequireAnyRole - No user in context for %s\n", r.URL.Path)
+				logger.Debug("No user in context for role check", "path", r.URL.Path)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
-			fmt.Printf("DEBUG: RequireAnyRole - User %s (ID: %d) accessing %s\n", user.Username, user.ID, r.URL.Path)
-			fmt.Printf("DEBUG: RequireAnyRole - Required roles: %v\n", roleNames)
-			fmt.Printf("DEBUG: RequireAnyRole - User has %d roles: ", len(user.Roles))
-			for _, role := range user.Roles {
-				fmt.Printf("%s ", role.Name)
-			}
-			fmt.Printf("\n")
-
 			hasRole := false
 			for _, roleName := range roleNames {
 				if user.HasRole(roleName) {
-					fmt.Printf("DEBUG: RequireAnyRole - User has required role: %s\n", roleName)
 					hasRole = true
 					break
 				}
 			}
 
 			if !hasRole {
-				fmt.Printf("DEBUG: RequireAnyRole - User does not have any required roles, denying access\n")
+				userRoles := make([]string, 0, len(user.Roles))
+				for _, role := range user.Roles {
+					userRoles = append(userRoles, role.Name)
+				}
+				logger.Debug("Access denied, user has none of the required roles", "user_id", user.ID,
+					"path", r.URL.Path, "required_roles", roleNames, "user_roles", userRoles)
 				http.Error(w, "Forbidden", http.StatusForbidden)
 				return
 			}
 
-			fmt.Printf("DEBUG: RequireAnyRole - Access granted\n")
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// invitationCookie carries an accepted invitation token to the first login, which links the
+// invited account to the identity that signed in
+const invitationCookie = "invitation_token"
+
+// SetInvitationCookie remembers an accepted invitation until the user signs in
+func SetInvitationCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     invitationCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   authConfig.Cookies.IsSecure(),
+		// Lax so the cookie survives the redirect back from the identity provider
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   3600,
+	})
+}
+
 // LoadUserFromToken is a middleware that loads user information from OIDC token
 func LoadUserFromToken(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -339,6 +380,16 @@ func LoadUserFromToken(next http.Handler) http.Handler {
 		if err == nil && c.Value != "" {
 			// Verify the ID token
 			idToken, err := provider.Verifier(oidcConfig).Verify(r.Context(), c.Value)
+			if err == nil {
+				revoked, checkErr := sessionRevoked(idToken)
+				if checkErr != nil {
+					writeRevocationCheckError(w, r, checkErr)
+					return
+				}
+				if revoked {
+					err = errors.New("SSO session was logged out")
+				}
+			}
 			if err == nil {
 				// Extract claims from the token
 				var claims struct {
@@ -367,13 +418,28 @@ func LoadUserFromToken(next http.Handler) http.Handler {
 						}
 					}
 
-					// Debug: Log Keycloak roles
-					fmt.Printf("DEBUG: Keycloak roles for user %s: %v\n", claims.Subject, keycloakRoles)
+					logger := logging.FromContext(r.Context()).With("subject", claims.Subject)
+					logger.Debug("Loaded Keycloak roles", "roles", keycloakRoles)
 
 					// Try to find existing user by Keycloak ID
 					user, err := models.GetUserByKeycloakID(claims.Subject)
+					if err != nil && claims.EmailVerified {
+						// Invited accounts are only linked in the browser that accepted the invitation;
+						// existing accounts are never matched by email alone
+						if c, cookieErr := r.Cookie(invitationCookie); cookieErr == nil && c.Value != "" {
+							http.SetCookie(w, &http.Cookie{Name: invitationCookie, Value: "", Path: "/", MaxAge: -1})
+							linked, linkErr := models.LinkInvitedUser(c.Value, claims.Subject, claims.Email,
+								authConfig.RequiresApproval, time.Now())
+							if linkErr == nil {
+								logger.Info("Linked invited user to Keycloak user", "user_id", linked.ID)
+								user, err = linked, nil
+							} else {
+								logger.Warn("Failed to link invited user", "error", linkErr)
+							}
+						}
+					}
 					if err != nil {
-						fmt.Printf("DEBUG: User %s not found, creating new user\n", claims.Subject)
+						logger.Debug("User not found, creating new user")
 						// User doesn't exist, create one
 						user = &models.User{
 							KeycloakID:    models.NullString(claims.Subject),
@@ -387,25 +453,30 @@ func LoadUserFromToken(next http.Handler) http.Handler {
 
 						// Create the user in the database
 						if err := models.CreateUser(user); err == nil {
-							fmt.Printf("DEBUG: User created with ID %d, assigning roles: %v\n", user.ID, keycloakRoles)
+							logger.Info("Created user for Keycloak user", "user_id", user.ID)
 							// Assign roles based on Keycloak realm roles
-							assignRolesFromKeycloak(user.ID, keycloakRoles)
+							assignRolesFromKeycloak(logger, user.ID, keycloakRoles)
 							// Reload user with roles
 							user, _ = models.GetUserByID(user.ID)
-							fmt.Printf("DEBUG: User after role assignment has %d roles\n", len(user.Roles))
 						} else {
-							fmt.Printf("DEBUG: Failed to create user: %v\n", err)
+							logger.Error("Failed to create user", "error", err)
 						}
 					} else {
-						fmt.Printf("DEBUG: Found existing user %s (ID: %d), syncing roles\n", claims.Subject, user.ID)
 						// User exists, sync roles from Keycloak
-						assignRolesFromKeycloak(user.ID, keycloakRoles)
+						assignRolesFromKeycloak(logger, user.ID, keycloakRoles)
 						// Reload user with updated roles
 						user, _ = models.GetUserByID(user.ID)
-						fmt.Printf("DEBUG: User after role sync has %d roles\n", len(user.Roles))
-						for _, role := range user.Roles {
-							fmt.Printf("DEBUG: User has role: %s\n", role.Name)
-						}
+					}
+
+					if user != nil && user.Status != models.UserStatusActive {
+						// Suspended and deactivated accounts are signed out even if their token is still valid
+						http.SetCookie(w, &http.Cookie{Name: "id_token", Value: "", Path: "/", MaxAge: -1})
+						http.Error(w, "Account is "+user.Status, http.StatusForbidden)
+						return
+					}
+
+					if user != nil && !meterAPIRequest(w, r, user) {
+						return
 					}
 
 					if user != nil {
@@ -496,8 +567,8 @@ func getClientIP(r *http.Request) string {
 }
 
 // assignRolesFromKeycloak maps Keycloak realm roles to application roles
-func assignRolesFromKeycloak(userID int, keycloakRoles []string) {
-	fmt.Printf("DEBUG: assignRolesFromKeycloak called with userID=%d, roles=%v\n", userID, keycloakRoles)
+func assignRolesFromKeycloak(logger *slog.Logger, userID int, keycloakRoles []string) {
+	logger = logger.With("user_id", userID)
 
 	// Role mapping from Keycloak realm roles to application roles
 	roleMapping := map[string]string{
@@ -505,6 +576,7 @@ func assignRolesFromKeycloak(userID int, keycloakRoles []string) {
 		"property_manager": "property_manager",
 		"tenant":           "tenant",
 		"viewer":           "viewer",
+		"owner":            "owner",
 	}
 
 	// First, remove all existing roles for this user to ensure clean sync
@@ -512,13 +584,14 @@ func assignRolesFromKeycloak(userID int, keycloakRoles []string) {
 	for _, appRole := range roleMapping {
 		appRoleRecord, err := models.GetRoleByName(appRole)
 		if err == nil {
-			// Remove existing role assignment (ignore errors if not assigned)
-			err = models.RemoveRole(userID, appRoleRecord.ID)
+			// Remove existing role assignment (ignore errors if not assigned). Temporary
+			// assignments are kept until they expire.
+			err = models.RemovePermanentRole(userID, appRoleRecord.ID)
 			if err != nil {
-				fmt.Printf("DEBUG: Failed to remove role %s from user %d: %v\n", appRole, userID, err)
+				logger.Debug("Failed to remove role", "role", appRole, "error", err)
 			}
 		} else {
-			fmt.Printf("DEBUG: Failed to get role %s: %v\n", appRole, err)
+			logger.Warn("Failed to get role", "role", appRole, "error", err)
 		}
 	}
 
@@ -526,40 +599,37 @@ func assignRolesFromKeycloak(userID int, keycloakRoles []string) {
 	assignedCount := 0
 	for _, keycloakRole := range keycloakRoles {
 		if appRole, exists := roleMapping[keycloakRole]; exists {
-			fmt.Printf("DEBUG: Mapping Keycloak role '%s' to app role '%s'\n", keycloakRole, appRole)
 			appRoleRecord, err := models.GetRoleByName(appRole)
 			if err == nil {
 				// Assign the role (ignore errors if already assigned)
 				err = models.AssignRole(userID, appRoleRecord.ID, nil)
 				if err != nil {
-					fmt.Printf("DEBUG: Failed to assign role %s to user %d: %v\n", appRole, userID, err)
+					logger.Debug("Failed to assign role", "role", appRole, "error", err)
 				} else {
-					fmt.Printf("DEBUG: Successfully assigned role %s to user %d\n", appRole, userID)
 					assignedCount++
 				}
 			} else {
-				fmt.Printf("DEBUG: Failed to get role %s: %v\n", appRole, err)
+				logger.Warn("Failed to get role", "role", appRole, "error", err)
 			}
 		} else {
-			fmt.Printf("DEBUG: Keycloak role '%s' not mapped to any app role\n", keycloakRole)
+			logger.Debug("Keycloak role not mapped to any app role", "keycloak_role", keycloakRole)
 		}
 	}
 
 	// If no mapped roles were found, assign default tenant role
 	if assignedCount == 0 {
-		fmt.Printf("DEBUG: No roles assigned, assigning default 'tenant' role\n")
 		defaultRole, err := models.GetRoleByName("tenant")
 		if err == nil {
 			err = models.AssignRole(userID, defaultRole.ID, nil)
 			if err != nil {
-				fmt.Printf("DEBUG: Failed to assign default tenant role: %v\n", err)
+				logger.Debug("Failed to assign default tenant role", "error", err)
 			} else {
-				fmt.Printf("DEBUG: Successfully assigned default tenant role\n")
+				assignedCount++
 			}
 		} else {
-			fmt.Printf("DEBUG: Failed to get default tenant role: %v\n", err)
+			logger.Warn("Failed to get default tenant role", "error", err)
 		}
 	}
 
-	fmt.Printf("DEBUG: assignRolesFromKeycloak completed, assigned %d roles\n", assignedCount)
+	logger.Debug("Synced roles from Keycloak", "roles", assignedCount)
 }
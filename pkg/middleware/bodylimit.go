@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxRequestBodyBytes is the largest request body routes accept unless they set their own limit
+// with LimitBody
+var MaxRequestBodyBytes int64 = 1 << 20
+
+// LimitRequestBody limits every request body to MaxRequestBodyBytes. Reading past the limit fails
+// with an *http.MaxBytesError, and an error response the handler writes after that is replaced
+// with a 413 naming the limit, so handlers that report any unreadable body as a bad request
+// still answer clearly.
+func LimitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w, r = limitBody(w, r, MaxRequestBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitBody sets the body size limit of a route, raising or lowering LimitRequestBody's default.
+// Bodies declaring a larger Content-Length are refused before they are read.
+func LimitBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				writeBodyTooLarge(w, limit)
+				return
+			}
+			if body, ok := r.Body.(*limitedBody); ok {
+				body.limit = limit
+				next.ServeHTTP(w, r)
+				return
+			}
+			w, r = limitBody(w, r, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitBody wraps a request's body and response writer to enforce limit
+func limitBody(w http.ResponseWriter, r *http.Request, limit int64) (http.ResponseWriter, *http.Request) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return w, r
+	}
+	body := &limitedBody{ReadCloser: r.Body, limit: limit, contentLength: r.ContentLength}
+	r.Body = body
+	return &bodyLimitWriter{ResponseWriter: w, body: body}, r
+}
+
+// limitedBody is a request body that fails reads past its limit, which LimitBody may change
+// before the handler starts reading
+type limitedBody struct {
+	io.ReadCloser
+	limit         int64
+	contentLength int64
+	read          int64
+	err           error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.contentLength > b.limit {
+		b.err = &http.MaxBytesError{Limit: b.limit}
+		return 0, b.err
+	}
+	// Read one byte past the limit to tell a body of exactly the limit from a larger one
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		n -= int(b.read - b.limit)
+		b.read = b.limit
+		b.err = &http.MaxBytesError{Limit: b.limit}
+		return n, b.err
+	}
+	return n, err
+}
+
+// bodyLimitWriter turns the error response to a request whose body went over its limit into a 413
+type bodyLimitWriter struct {
+	http.ResponseWriter
+	body     *limitedBody
+	rejected bool
+}
+
+func (bw *bodyLimitWriter) WriteHeader(status int) {
+	if status >= 400 && bw.body.err != nil {
+		bw.rejected = true
+		writeBodyTooLarge(bw.ResponseWriter, bw.body.limit)
+		return
+	}
+	bw.ResponseWriter.WriteHeader(status)
+}
+
+func (bw *bodyLimitWriter) Write(p []byte) (int, error) {
+	if bw.rejected {
+		return len(p), nil
+	}
+	return bw.ResponseWriter.Write(p)
+}
+
+func (bw *bodyLimitWriter) Flush() {
+	if flusher, ok := bw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeBodyTooLarge responds with 413 and closes the connection rather than reading the rest of
+// the body
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("Connection", "close")
+	http.Error(w, fmt.Sprintf("Request body too large: this endpoint accepts at most %s", FormatByteSize(limit)),
+		http.StatusRequestEntityTooLarge)
+}
+
+// FormatByteSize formats a size limit in bytes as "10 MB", "64 KB" or "300 bytes"
+func FormatByteSize(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+// bodyLimitRouter decodes JSON bodies and reports any failure as a bad request, like the API
+// handlers do
+func bodyLimitRouter() http.Handler {
+	decode := func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	r := chi.NewRouter()
+	r.Use(LimitRequestBody)
+	r.Post("/small", decode)
+	r.With(LimitBody(64)).Post("/tiny", decode)
+	r.With(LimitBody(4<<20)).Post("/large", decode)
+	return r
+}
+
+func jsonBody(size int) string {
+	return `{"data":"` + strings.Repeat("x", size) + `"}`
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	defer func(limit int64) { MaxRequestBodyBytes = limit }(MaxRequestBodyBytes)
+	MaxRequestBodyBytes = 1 << 10
+
+	cases := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/small", jsonBody(100), http.StatusNoContent},
+		{"/small", jsonBody(2 << 10), http.StatusRequestEntityTooLarge},
+		{"/tiny", jsonBody(100), http.StatusRequestEntityTooLarge},
+		{"/large", jsonBody(2 << 10), http.StatusNoContent},
+		// Bad bodies within the limit are still the handler's to answer
+		{"/small", "{", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		bodyLimitRouter().ServeHTTP(rr, httptest.NewRequest("POST", c.path, strings.NewReader(c.body)))
+		assert.Equal(t, c.status, rr.Code, "%s with %d bytes", c.path, len(c.body))
+	}
+}
+
+func TestLimitBodyRejectsDeclaredLength(t *testing.T) {
+	called := false
+	handler := LimitBody(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 20)))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Equal(t, "close", rr.Header().Get("Connection"))
+	assert.Contains(t, rr.Body.String(), "at most 10 bytes")
+}
+
+func TestLimitedBodyReadsUpToTheLimit(t *testing.T) {
+	body := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("0123456789")), limit: 10, contentLength: -1}
+	data, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	body = &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("0123456789!")), limit: 10, contentLength: -1}
+	data, err = io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	assert.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, "0123456789", string(data))
+}
+
+func TestFormatByteSize(t *testing.T) {
+	assert.Equal(t, "10 MB", FormatByteSize(10<<20))
+	assert.Equal(t, "1.5 MB", FormatByteSize(3<<19))
+	assert.Equal(t, "64 KB", FormatByteSize(64<<10))
+	assert.Equal(t, "300 bytes", FormatByteSize(300))
+}
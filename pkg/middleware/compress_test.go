@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveCompressed(t *testing.T, acceptEncoding, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/reports", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "gzip", negotiateEncoding("gzip, deflate, br"))
+	assert.Equal(t, "gzip", negotiateEncoding("deflate, gzip"))
+	assert.Equal(t, "deflate", negotiateEncoding("gzip;q=0.5, deflate"))
+	assert.Equal(t, "gzip", negotiateEncoding("*"))
+	assert.Equal(t, "", negotiateEncoding("gzip;q=0, br"))
+	assert.Equal(t, "", negotiateEncoding(""))
+}
+
+func TestCompressGzipsLargeJSON(t *testing.T) {
+	body := `{"rows":[` + strings.Repeat(`{"name":"Maple Court"},`, 100) + `{}]}`
+	rr := serveCompressed(t, "gzip", "application/json", body)
+
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+	gz, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+}
+
+func TestCompressDeflateUsesZlib(t *testing.T) {
+	body := strings.Repeat("id,name\n1,Maple Court\n", 100)
+	rr := serveCompressed(t, "deflate", "text/csv", body)
+
+	assert.Equal(t, "deflate", rr.Header().Get("Content-Encoding"))
+	zr, err := zlib.NewReader(rr.Body)
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+}
+
+func TestCompressSkipsSmallAndPrecompressedResponses(t *testing.T) {
+	rr := serveCompressed(t, "gzip", "application/json", `{"ok":true}`)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{"ok":true}`, rr.Body.String())
+
+	pdf := strings.Repeat("%PDF-1.4 ", 500)
+	rr = serveCompressed(t, "gzip", "application/pdf", pdf)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, pdf, rr.Body.String())
+
+	body := strings.Repeat("x", 5000)
+	rr = serveCompressed(t, "identity", "text/plain", body)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Empty(t, rr.Header().Get("Vary"))
+}
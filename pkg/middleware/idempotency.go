@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// IdempotencyKeyHeader is the request header clients set to make a POST safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// maxBufferedIdempotentBody is the largest request body Idempotent keeps in memory while it hashes
+// it; larger bodies, such as CSV imports, are spooled to a temporary file instead
+var maxBufferedIdempotentBody int64 = 64 << 10
+
+// Idempotent makes a POST safe to retry when the client sends an Idempotency-Key header. The
+// first request with a key runs normally and its response is stored; retries with the same key
+// and body replay that response instead of running the handler again. Reusing a key for a
+// different request is rejected with 422, and a retry while the first request is still running
+// with 409. Server errors are not stored, so the client can retry them. Requests without the
+// header are unaffected. Must run after LoadUserFromToken.
+func Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+			return
+		}
+
+		user, ok := GetUserFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		hash := sha256.New()
+		io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+		body, err := spoolBody(r.Body, hash)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+		r.Body = body
+
+		stored, created, err := models.BeginIdempotentRequest(user.ID, key, r.Method, r.URL.Path,
+			hex.EncodeToString(hash.Sum(nil)), time.Now())
+		switch {
+		case err == models.ErrIdempotencyKeyReused:
+			http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+			return
+		case err == models.ErrIdempotentRequestInProgress:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("Failed to check idempotency key", "user_id", user.ID, "error", err)
+			http.Error(w, "Failed to check idempotency key", http.StatusInternalServerError)
+			return
+		case !created:
+			// Replay the stored response
+			if stored.ResponseContentType.Valid {
+				w.Header().Set("Content-Type", stored.ResponseContentType.String)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(int(stored.ResponseStatus.Int32))
+			io.WriteString(w, stored.ResponseBody.String)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			// Free the key after a server error or panic so the request can be retried
+			if !completed {
+				if err := models.ReleaseIdempotentRequest(stored.ID); err != nil {
+					logging.FromContext(r.Context()).Error("Failed to release idempotency key", "key_id", stored.ID, "error", err)
+				}
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+		completed = true
+		if err := models.CompleteIdempotentRequest(stored.ID, recorder.status,
+			recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to store response for idempotency key", "key_id", stored.ID, "error", err)
+		}
+	})
+}
+
+// spoolBody reads a request body through hash and returns a copy to hand to the handler. Bodies
+// up to maxBufferedIdempotentBody are kept in memory and larger ones written to a temporary file,
+// which closing the copy removes, so large uploads are never held in memory whole.
+func spoolBody(body io.Reader, hash io.Writer) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(io.MultiWriter(&buf, hash), body, maxBufferedIdempotentBody+1); err == io.EOF {
+		return io.NopCloser(&buf), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &spooledBody{File: file}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	return spooled, nil
+}
+
+// spooledBody is a request body spooled to a temporary file that is removed when it is closed
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.File.Name())
+	return err
+}
+
+// responseRecorder passes a response through while keeping a copy of its status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.wroteHeader = true
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(p)
+	return rr.ResponseWriter.Write(p)
+}
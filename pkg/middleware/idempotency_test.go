@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func idempotentRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/payments", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	return req.WithContext(context.WithValue(req.Context(), UserContextKey, &models.User{ID: 3}))
+}
+
+func setupIdempotencyTestDB(t *testing.T) sqlmock.Sqlmock {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	originalDB := db.DB
+	db.DB = mockDB
+	t.Cleanup(func() {
+		db.DB = originalDB
+		mockDB.Close()
+	})
+	return mock
+}
+
+func TestIdempotentStoresFirstResponse(t *testing.T) {
+	mock := setupIdempotencyTestDB(t)
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO idempotency_keys").
+		WithArgs(3, "pay-1", http.MethodPost, "/api/payments", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectExec("UPDATE idempotency_keys").
+		WithArgs(11, http.StatusCreated, "application/json", `{"id":90}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	calls := 0
+	handler := Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":90}`))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("pay-1", `{"amount":1200}`))
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotentReplaysCompletedResponse(t *testing.T) {
+	mock := setupIdempotencyTestDB(t)
+	now := time.Now()
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO idempotency_keys").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("FROM idempotency_keys WHERE user_id").WithArgs(3, "pay-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "idempotency_key", "method", "path", "request_hash",
+			"response_status", "response_content_type", "response_body", "created_at", "expires_at"}).
+			AddRow(11, 3, "pay-1", http.MethodPost, "/api/payments",
+				fmt.Sprintf("%x", sha256.Sum256([]byte("POST /api/payments\n{\"amount\":1200}"))),
+				http.StatusCreated, "application/json", `{"id":90}`, now, now.Add(time.Hour)))
+
+	handler := Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not run for a replayed request")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("pay-1", `{"amount":1200}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "true", rr.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, `{"id":90}`, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotentRejectsKeyReuseAndReleasesOnServerError(t *testing.T) {
+	mock := setupIdempotencyTestDB(t)
+	now := time.Now()
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO idempotency_keys").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("FROM idempotency_keys WHERE user_id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "idempotency_key", "method", "path", "request_hash",
+			"response_status", "response_content_type", "response_body", "created_at", "expires_at"}).
+			AddRow(11, 3, "pay-1", http.MethodPost, "/api/payments", "other-hash", nil, nil, nil, now, now.Add(time.Hour)))
+
+	handler := Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Failed to record payment", http.StatusInternalServerError)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("pay-1", `{"amount":1}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	// A failed request frees its key for a retry
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO idempotency_keys").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+	mock.ExpectExec(`DELETE FROM idempotency_keys WHERE id = \$1`).WithArgs(12).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("pay-2", `{"amount":1}`))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSpoolBodySpillsLargeBodiesToDisk(t *testing.T) {
+	original := maxBufferedIdempotentBody
+	maxBufferedIdempotentBody = 16
+	defer func() { maxBufferedIdempotentBody = original }()
+
+	for _, body := range []string{"small body", strings.Repeat("large body ", 10)} {
+		hash := sha256.New()
+		spooled, err := spoolBody(strings.NewReader(body), hash)
+		require.NoError(t, err)
+
+		assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(body))), fmt.Sprintf("%x", hash.Sum(nil)))
+		read, err := io.ReadAll(spooled)
+		require.NoError(t, err)
+		assert.Equal(t, body, string(read))
+
+		file, onDisk := spooled.(*spooledBody)
+		assert.Equal(t, len(body) > 16, onDisk)
+		require.NoError(t, spooled.Close())
+		if onDisk {
+			_, err := os.Stat(file.Name())
+			assert.True(t, os.IsNotExist(err), "the temporary file is removed")
+		}
+	}
+}
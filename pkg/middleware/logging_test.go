@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(logging.NewHandler(&buf, slog.LevelInfo, "text")))
+	defer slog.SetDefault(previous)
+
+	var seen string
+	handler := RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+		logging.FromContext(r.Context()).Info("inside handler")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/invitations/accept?token=abc123", nil)
+	req.Header.Set(logging.RequestIDHeader, "client-req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "client-req-1", seen)
+	assert.Equal(t, "client-req-1", rec.Header().Get(logging.RequestIDHeader))
+
+	out := buf.String()
+	assert.Contains(t, out, `msg="inside handler" request_id=client-req-1`)
+	assert.Contains(t, out, "msg=request request_id=client-req-1 method=GET")
+	assert.Contains(t, out, "status=201")
+	assert.Contains(t, out, "token=[redacted]")
+	assert.NotContains(t, out, "abc123")
+}
+
+func TestRequestLoggerGeneratesID(t *testing.T) {
+	handler := RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/properties", nil))
+
+	assert.Len(t, rec.Header().Get(logging.RequestIDHeader), 16)
+}
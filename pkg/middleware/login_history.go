@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// loginUser finds the local account for a signed-in identity by Keycloak ID, falling back to
+// a verified email. It returns nil for first-time logins, which LoadUserFromToken provisions.
+func loginUser(subject, email string, emailVerified bool) *models.User {
+	if user, err := models.GetUserByKeycloakID(subject); err == nil {
+		return user
+	}
+	if emailVerified && email != "" {
+		if user, err := models.GetUserByEmail(email); err == nil {
+			return user
+		}
+	}
+	return nil
+}
+
+// recordLogin stores a login attempt in the login history. Failures are logged rather than
+// returned so a history problem never blocks signing in.
+func recordLogin(r *http.Request, user *models.User, identity string, success bool, reason string, mfaUsed bool) {
+	attempt := &models.LoginAttempt{
+		Identity:      models.NullString(identity),
+		Success:       success,
+		FailureReason: models.NullString(reason),
+		IPAddress:     strings.TrimSpace(getClientIP(r)),
+		UserAgent:     r.UserAgent(),
+		MFAUsed:       mfaUsed,
+	}
+	if user != nil {
+		attempt.UserID = sql.NullInt32{Int32: int32(user.ID), Valid: true}
+	}
+	if err := models.RecordLoginAttempt(attempt, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record login attempt", "error", err)
+	}
+}
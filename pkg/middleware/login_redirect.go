@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+)
+
+// API auth modes for LoginRedirectPolicy.APIMode
+const (
+	APIAuthModeJSON     = "json"
+	APIAuthModeRedirect = "redirect"
+)
+
+// loginRedirectCookie counts recent login redirects as "<count>.<unix time of the first>"
+const loginRedirectCookie = "login_redirects"
+
+// LoginRedirectPolicy controls how RequireLogin answers requests without a valid session
+type LoginRedirectPolicy struct {
+	MaxAttempts int           // Login redirects allowed within Window before the loop error page is shown
+	Window      time.Duration // How long redirects keep counting towards MaxAttempts
+	APIMode     string        // json answers API clients with a 401, redirect sends them to the login page
+}
+
+var loginRedirectPolicy = LoadLoginRedirectPolicy()
+
+// LoadLoginRedirectPolicy reads the policy from LOGIN_REDIRECT_MAX_ATTEMPTS (default 3),
+// LOGIN_REDIRECT_WINDOW (a duration, default 2m) and LOGIN_API_AUTH_MODE (json or redirect,
+// default json)
+func LoadLoginRedirectPolicy() LoginRedirectPolicy {
+	policy := LoginRedirectPolicy{MaxAttempts: 3, Window: 2 * time.Minute, APIMode: APIAuthModeJSON}
+	if attempts, err := strconv.Atoi(os.Getenv("LOGIN_REDIRECT_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		policy.MaxAttempts = attempts
+	}
+	if window, err := time.ParseDuration(os.Getenv("LOGIN_REDIRECT_WINDOW")); err == nil && window > 0 {
+		policy.Window = window
+	}
+	if os.Getenv("LOGIN_API_AUTH_MODE") == APIAuthModeRedirect {
+		policy.APIMode = APIAuthModeRedirect
+	}
+	return policy
+}
+
+// isAPIRequest reports whether a request comes from a script or integration rather than a
+// browser navigation, which cannot follow a redirect to the login page
+func isAPIRequest(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/api/") || r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+// loginRedirects returns how many login redirects the client has had within the policy window
+// and when the first of them happened
+func loginRedirects(r *http.Request, now time.Time) (int, time.Time) {
+	c, err := r.Cookie(loginRedirectCookie)
+	if err != nil {
+		return 0, now
+	}
+	countStr, firstStr, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return 0, now
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return 0, now
+	}
+	firstUnix, err := strconv.ParseInt(firstStr, 10, 64)
+	if err != nil {
+		return 0, now
+	}
+	first := time.Unix(firstUnix, 0)
+	if now.Sub(first) > loginRedirectPolicy.Window {
+		return 0, now
+	}
+	return count, first
+}
+
+// setLoginRedirects stores the login redirect count for the client
+func setLoginRedirects(w http.ResponseWriter, count int, first time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginRedirectCookie,
+		Value:    fmt.Sprintf("%d.%d", count, first.Unix()),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   authConfig.Cookies.IsSecure(),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(loginRedirectPolicy.Window.Seconds()),
+	})
+}
+
+// clearLoginRedirects resets the client's login redirect count once it has a working session
+func clearLoginRedirects(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie(loginRedirectCookie); err != nil {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginRedirectCookie, Value: "", Path: "/", MaxAge: -1})
+}
+
+// writeLoginRequired answers an API client without a valid session
+func writeLoginRequired(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"}); err != nil {
+		slog.Error("Failed to encode login required response", "error", err)
+	}
+}
+
+var loginLoopPage = template.Must(template.New("login-loop").Parse(`<!doctype html>
+<html lang="en">
+    <head>
+        <meta charset="UTF-8" />
+        <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+        <title>Sign-in problem - Fire PMAAS</title>
+        <link rel="stylesheet" href="/static/css/style.css" />
+    </head>
+    <body class="bg-gray-100 font-sans leading-normal tracking-normal">
+        <div class="max-w-md mx-auto mt-16 bg-white p-6 rounded-lg shadow-md">
+            <h1 class="text-2xl font-semibold mb-4">We couldn't sign you in</h1>
+            <p class="mb-4">You were sent to the sign-in page {{.Attempts}} times without a session being started.
+                This usually means your browser is blocking cookies for this site or its clock is wrong.</p>
+            <p class="mb-4">Check that cookies are enabled, then try again. If the problem persists, contact your administrator.</p>
+            <a href="{{.RetryURL}}" class="bg-blue-600 text-white px-4 py-2 rounded">Try again</a>
+        </div>
+    </body>
+</html>
+`))
+
+// writeLoginLoop shows the loop error page and resets the count so the retry link starts over
+func writeLoginLoop(w http.ResponseWriter, r *http.Request, attempts int) {
+	http.SetCookie(w, &http.Cookie{Name: loginRedirectCookie, Value: "", Path: "/", MaxAge: -1})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusTooManyRequests)
+	data := struct {
+		Attempts int
+		RetryURL string
+	}{attempts, r.URL.RequestURI()}
+	if err := loginLoopPage.Execute(w, data); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render login loop page", "error", err)
+	}
+}
+
+// checkLoginRedirect decides whether RequireLogin may redirect the client to the login page.
+// API clients get a 401 unless the policy sends them to the login page, and a browser that has
+// been redirected MaxAttempts times within the window gets the loop error page instead. It
+// returns false when the response has been written.
+func checkLoginRedirect(w http.ResponseWriter, r *http.Request, now time.Time) bool {
+	if loginRedirectPolicy.APIMode == APIAuthModeJSON && isAPIRequest(r) {
+		writeLoginRequired(w)
+		return false
+	}
+
+	count, first := loginRedirects(r, now)
+	if count >= loginRedirectPolicy.MaxAttempts {
+		logging.FromContext(r.Context()).Warn("Login redirect loop detected", "path", r.URL.Path, "attempts", count)
+		writeLoginLoop(w, r, count)
+		return false
+	}
+	setLoginRedirects(w, count+1, first)
+	return true
+}
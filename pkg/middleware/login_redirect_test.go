@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLoginRedirectAPIClients(t *testing.T) {
+	defer func(p LoginRedirectPolicy) { loginRedirectPolicy = p }(loginRedirectPolicy)
+	loginRedirectPolicy = LoginRedirectPolicy{MaxAttempts: 3, Window: time.Minute, APIMode: APIAuthModeJSON}
+	now := time.Now()
+
+	rr := httptest.NewRecorder()
+	assert.False(t, checkLoginRedirect(rr, httptest.NewRequest("GET", "/api/properties", nil), now))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"error": "Authentication required"}`, rr.Body.String())
+
+	req := httptest.NewRequest("GET", "/reports", nil)
+	req.Header.Set("Accept", "application/json")
+	rr = httptest.NewRecorder()
+	assert.False(t, checkLoginRedirect(rr, req, now))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// In redirect mode API clients are sent to the login page like browsers
+	loginRedirectPolicy.APIMode = APIAuthModeRedirect
+	rr = httptest.NewRecorder()
+	assert.True(t, checkLoginRedirect(rr, httptest.NewRequest("GET", "/api/properties", nil), now))
+}
+
+func TestCheckLoginRedirectLoop(t *testing.T) {
+	defer func(p LoginRedirectPolicy) { loginRedirectPolicy = p }(loginRedirectPolicy)
+	loginRedirectPolicy = LoginRedirectPolicy{MaxAttempts: 2, Window: time.Minute, APIMode: APIAuthModeJSON}
+	now := time.Now()
+
+	var cookie *http.Cookie
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/dashboard", nil)
+		req.Header.Set("Accept", "text/html")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		if checkLoginRedirect(rr, req, now) {
+			rr.WriteHeader(http.StatusFound)
+		}
+		for _, c := range rr.Result().Cookies() {
+			if c.Name == loginRedirectCookie {
+				cookie = c
+			}
+		}
+		return rr
+	}
+
+	assert.Equal(t, http.StatusFound, request().Code)
+	assert.Equal(t, http.StatusFound, request().Code)
+	rr := request()
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "We couldn't sign you in")
+	assert.Contains(t, rr.Body.String(), `href="/dashboard"`)
+
+	// The error page resets the count so trying again redirects once more
+	assert.Equal(t, -1, cookie.MaxAge)
+	cookie = nil
+	assert.Equal(t, http.StatusFound, request().Code)
+}
+
+func TestLoginRedirectsExpire(t *testing.T) {
+	defer func(p LoginRedirectPolicy) { loginRedirectPolicy = p }(loginRedirectPolicy)
+	loginRedirectPolicy = LoginRedirectPolicy{MaxAttempts: 2, Window: time.Minute, APIMode: APIAuthModeJSON}
+	now := time.Now()
+
+	req := httptest.NewRequest("GET", "/dashboard", nil)
+	req.AddCookie(&http.Cookie{Name: loginRedirectCookie, Value: "5.1000"})
+	count, first := loginRedirects(req, now)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, now, first)
+}
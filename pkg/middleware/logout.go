@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// backchannelLogoutEvent is the event a back-channel logout token must carry
+const backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// postLogoutRedirectURL is where the identity provider sends users after logout. It is
+// POST_LOGOUT_REDIRECT_URL, or the root of APP_BASE_URL, and must be registered as a valid
+// post logout redirect URI on the Keycloak client.
+func postLogoutRedirectURL() string {
+	if target := os.Getenv("POST_LOGOUT_REDIRECT_URL"); target != "" {
+		return target
+	}
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/") + "/"
+	}
+	return "http://localhost:8000/"
+}
+
+// EndSessionURL returns the identity provider's RP-initiated logout URL for the session the ID
+// token belongs to, or "" when the provider does not advertise an end_session_endpoint
+func EndSessionURL(idTokenHint string) string {
+	if provider == nil {
+		return ""
+	}
+	var metadata struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&metadata); err != nil || metadata.EndSessionEndpoint == "" {
+		return ""
+	}
+	endpoint, err := url.Parse(metadata.EndSessionEndpoint)
+	if err != nil {
+		return ""
+	}
+
+	params := endpoint.Query()
+	params.Set("client_id", authConfig.OIDC.ClientID)
+	params.Set("post_logout_redirect_uri", postLogoutRedirectURL())
+	if idTokenHint != "" {
+		params.Set("id_token_hint", idTokenHint)
+	}
+	endpoint.RawQuery = params.Encode()
+	return endpoint.String()
+}
+
+// EndLocalSession deletes the caller's local session and clears the session cookies. It returns
+// the ID token the caller was signed in with, for use as the logout id_token_hint. If the session
+// cannot be deleted the cookies are left in place and the error is returned, so the caller is not
+// told they are signed out while the session still works.
+func EndLocalSession(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie("session_token"); err == nil && c.Value != "" {
+		if err := models.DeleteUserSession(c.Value); err != nil {
+			return "", err
+		}
+	}
+
+	idToken := ""
+	if c, err := r.Cookie("id_token"); err == nil {
+		idToken = c.Value
+	}
+	for _, name := range []string{"id_token", "session_token"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   authConfig.Cookies.IsSecure(),
+			SameSite: authConfig.Cookies.SameSiteMode(),
+			MaxAge:   -1, // Delete the cookie
+		})
+	}
+	return idToken, nil
+}
+
+// sameOriginRequest reports whether a browser request was made by one of the app's own pages.
+// Browsers send Origin with POSTs; Referer is the fallback for those that do not.
+func sameOriginRequest(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	origin, err := url.Parse(source)
+	if source == "" || err != nil || origin.Host == "" {
+		return false
+	}
+	if strings.EqualFold(origin.Host, r.Host) {
+		return true
+	}
+	base, err := url.Parse(os.Getenv("APP_BASE_URL"))
+	return err == nil && base.Host != "" && strings.EqualFold(origin.Host, base.Host)
+}
+
+// HandleLogout signs the browser out locally and then at the identity provider, so the SSO
+// session ends too and the next visit asks for credentials again. It only accepts POSTs from the
+// app's own pages, so another site cannot sign users out.
+func HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if !sameOriginRequest(r) {
+		http.Error(w, "Cross-origin logout request rejected", http.StatusForbidden)
+		return
+	}
+
+	idToken, err := EndLocalSession(w, r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete session", "error", err)
+		http.Error(w, "Failed to sign out, please try again", http.StatusInternalServerError)
+		return
+	}
+	target := EndSessionURL(idToken)
+	if target == "" {
+		target = "/"
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// HandleBackchannelLogout receives OpenID Connect back-channel logout tokens from the identity
+// provider and revokes the SSO session they name, so ID tokens issued for it stop working
+func HandleBackchannelLogout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	rawToken := r.PostForm.Get("logout_token")
+	if rawToken == "" {
+		http.Error(w, "Missing logout_token", http.StatusBadRequest)
+		return
+	}
+	if provider == nil {
+		http.Error(w, "OIDC is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	token, err := provider.Verifier(oidcConfig).Verify(r.Context(), rawToken)
+	if err != nil {
+		http.Error(w, "Invalid logout token", http.StatusBadRequest)
+		return
+	}
+	var claims logoutTokenClaims
+	if err := token.Claims(&claims); err != nil {
+		http.Error(w, "Invalid logout token", http.StatusBadRequest)
+		return
+	}
+	if err := claims.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RevokeOIDCSession(claims.Subject, claims.SessionID, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to revoke SSO session", "error", err)
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info("Back-channel logout revoked SSO session", "subject", claims.Subject)
+	w.WriteHeader(http.StatusOK)
+}
+
+// logoutTokenClaims are the claims of a back-channel logout token
+type logoutTokenClaims struct {
+	Subject   string                     `json:"sub"`
+	SessionID string                     `json:"sid"`
+	Events    map[string]json.RawMessage `json:"events"`
+	Nonce     *string                    `json:"nonce"`
+}
+
+// validate checks a logout token's claims as the back-channel logout spec requires
+func (c logoutTokenClaims) validate() error {
+	if _, ok := c.Events[backchannelLogoutEvent]; !ok {
+		return errors.New("logout token is missing the back-channel logout event")
+	}
+	if c.Nonce != nil {
+		return errors.New("logout token must not contain a nonce")
+	}
+	if c.Subject == "" && c.SessionID == "" {
+		return errors.New("logout token must contain sub or sid")
+	}
+	return nil
+}
+
+// sessionRevoked reports whether a verified ID token's SSO session has been ended by a
+// back-channel logout. Callers must reject the request when the check itself fails.
+func sessionRevoked(idToken *oidc.IDToken) (bool, error) {
+	var claims struct {
+		SessionID string `json:"sid"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return false, err
+	}
+	return models.IsOIDCSessionRevoked(idToken.Subject, claims.SessionID, idToken.IssuedAt)
+}
+
+// writeRevocationCheckError responds when a session's revocation could not be checked
+func writeRevocationCheckError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error("Failed to check SSO session revocation", "error", err)
+	http.Error(w, "Could not verify the SSO session", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogoutTokenClaimsValidate(t *testing.T) {
+	events := map[string]json.RawMessage{backchannelLogoutEvent: json.RawMessage(`{}`)}
+	nonce := "abc"
+
+	assert.NoError(t, logoutTokenClaims{SessionID: "sid-1", Events: events}.validate())
+	assert.NoError(t, logoutTokenClaims{Subject: "kc-user", Events: events}.validate())
+	assert.Error(t, logoutTokenClaims{Subject: "kc-user"}.validate())
+	assert.Error(t, logoutTokenClaims{Subject: "kc-user", Events: events, Nonce: &nonce}.validate())
+	assert.Error(t, logoutTokenClaims{Events: events}.validate())
+}
+
+func TestHandleLogoutClearsSession(t *testing.T) {
+	mock := setupIdempotencyTestDB(t)
+	mock.ExpectExec("DELETE FROM user_sessions WHERE session_token").
+		WithArgs("sess-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "http://pmaas.example/logout", nil)
+	req.Header.Set("Origin", "http://pmaas.example")
+	req.AddCookie(&http.Cookie{Name: "id_token", Value: "token"})
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "sess-1"})
+	rr := httptest.NewRecorder()
+	HandleLogout(rr, req)
+
+	// Without a configured provider there is no end_session_endpoint to send the browser to
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/", rr.Header().Get("Location"))
+	cleared := map[string]bool{}
+	for _, c := range rr.Result().Cookies() {
+		cleared[c.Name] = c.MaxAge < 0
+	}
+	assert.Equal(t, map[string]bool{"id_token": true, "session_token": true}, cleared)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleLogoutRejectsCrossOriginRequests(t *testing.T) {
+	for name, headers := range map[string]map[string]string{
+		"other origin":  {"Origin": "https://evil.example"},
+		"other referer": {"Referer": "https://evil.example/page"},
+		"no origin":     {},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "http://pmaas.example/logout", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: "sess-1"})
+		rr := httptest.NewRecorder()
+		HandleLogout(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code, name)
+		assert.Empty(t, rr.Result().Cookies(), name)
+	}
+}
+
+func TestHandleLogoutReportsSessionDeleteFailure(t *testing.T) {
+	mock := setupIdempotencyTestDB(t)
+	mock.ExpectExec("DELETE FROM user_sessions WHERE session_token").
+		WithArgs("sess-1").
+		WillReturnError(errors.New("connection reset"))
+
+	req := httptest.NewRequest(http.MethodPost, "http://pmaas.example/logout", nil)
+	req.Header.Set("Referer", "http://pmaas.example/dashboard")
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "sess-1"})
+	rr := httptest.NewRecorder()
+	HandleLogout(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Empty(t, rr.Result().Cookies(), "cookies are kept while the session still works")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleBackchannelLogoutRequiresToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/backchannel-logout", strings.NewReader(url.Values{}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	HandleBackchannelLogout(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+}
+
+func TestRevocationCheckFailureRejectsRequest(t *testing.T) {
+	// A token whose session cannot be checked is not treated as live
+	_, err := sessionRevoked(&oidc.IDToken{})
+	assert.Error(t, err)
+
+	rr := httptest.NewRecorder()
+	writeRevocationCheckError(rr, httptest.NewRequest(http.MethodGet, "/api/properties", nil), errors.New("connection refused"))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
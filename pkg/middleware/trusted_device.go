@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/logging"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// trustedDeviceCookie holds "<user id>.<device id>.<token>.<signature>" for a trusted device
+const trustedDeviceCookie = "trusted_device"
+
+// trustedDeviceKey signs device cookies. It is TRUSTED_DEVICE_SECRET, or a random key when that
+// is unset, in which case devices must be trusted again after a restart.
+var trustedDeviceKey = loadTrustedDeviceKey()
+
+func loadTrustedDeviceKey() []byte {
+	if secret := os.Getenv("TRUSTED_DEVICE_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		logging.Fatal("Failed to generate trusted device key", "error", err)
+	}
+	return key
+}
+
+// signTrustedDevice returns the signature of a device cookie payload
+func signTrustedDevice(payload string) string {
+	mac := hmac.New(sha256.New, trustedDeviceKey)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetTrustedDeviceCookie stores a newly trusted device's token in a signed cookie that lasts as
+// long as the trust
+func SetTrustedDeviceCookie(w http.ResponseWriter, device *models.TrustedDevice, token string) {
+	payload := fmt.Sprintf("%d.%d.%s", device.UserID, device.ID, token)
+	http.SetCookie(w, &http.Cookie{
+		Name:     trustedDeviceCookie,
+		Value:    payload + "." + signTrustedDevice(payload),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   authConfig.Cookies.IsSecure(),
+		SameSite: authConfig.Cookies.SameSiteMode(),
+		Expires:  device.ExpiresAt,
+	})
+}
+
+// TrustedDeviceID returns the ID of the user's trusted device the request comes from, recording
+// that it was used, or 0 when the request has no valid device cookie for the user
+func TrustedDeviceID(r *http.Request, userID int) int {
+	c, err := r.Cookie(trustedDeviceCookie)
+	if err != nil {
+		return 0
+	}
+	parts := strings.Split(c.Value, ".")
+	if len(parts) != 4 {
+		return 0
+	}
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(signTrustedDevice(payload)), []byte(parts[3])) {
+		return 0
+	}
+	if cookieUser, err := strconv.Atoi(parts[0]); err != nil || cookieUser != userID {
+		return 0
+	}
+	deviceID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0
+	}
+
+	if err := models.UseTrustedDevice(userID, deviceID, parts[2], time.Now()); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logging.FromContext(r.Context()).Error("Failed to check trusted device", "error", err)
+		}
+		return 0
+	}
+	return deviceID
+}
+
+// TrustDevice trusts the device the request comes from for the user and sets its cookie
+func TrustDevice(w http.ResponseWriter, r *http.Request, userID int) (*models.TrustedDevice, error) {
+	token, device, err := models.CreateTrustedDevice(userID, r.UserAgent(), strings.TrimSpace(getClientIP(r)), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	SetTrustedDeviceCookie(w, device, token)
+	return device, nil
+}
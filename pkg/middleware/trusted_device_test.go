@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func trustedDeviceRequest(t *testing.T) (*http.Request, *http.Cookie) {
+	rr := httptest.NewRecorder()
+	device := &models.TrustedDevice{ID: 3, UserID: 7, ExpiresAt: time.Now().AddDate(0, 0, models.TrustedDeviceDays)}
+	SetTrustedDeviceCookie(rr, device, "tok")
+	cookies := rr.Result().Cookies()
+	assert.Len(t, cookies, 1)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/mfa/challenge", nil)
+	req.AddCookie(cookies[0])
+	return req, cookies[0]
+}
+
+func TestTrustedDeviceID(t *testing.T) {
+	mock := setupIdempotencyTestDB(t)
+	mock.ExpectExec("UPDATE trusted_devices SET last_used_at").
+		WithArgs(sqlmock.AnyArg(), 3, 7, models.HashAPIKey("tok")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req, _ := trustedDeviceRequest(t)
+	assert.Equal(t, 3, TrustedDeviceID(req, 7))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTrustedDeviceIDRejectsTamperedCookies(t *testing.T) {
+	setupIdempotencyTestDB(t)
+
+	// Another user's cookie is ignored without a database lookup
+	req, cookie := trustedDeviceRequest(t)
+	assert.Equal(t, 0, TrustedDeviceID(req, 8))
+
+	// Changing the device ID breaks the signature
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: cookie.Name, Value: strings.Replace(cookie.Value, "7.3.", "7.4.", 1)})
+	assert.Equal(t, 0, TrustedDeviceID(req, 7))
+
+	assert.Equal(t, 0, TrustedDeviceID(httptest.NewRequest(http.MethodGet, "/", nil), 7))
+}
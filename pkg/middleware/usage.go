@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// APICallMeter counts authenticated API calls in memory and adds them to the organizations' usage
+// counters every Interval, so metering does not write to the database on every request. Quotas
+// are checked against the usage read back at the last flush plus the calls counted since, so an
+// organization can go over its quota by at most one interval's calls.
+type APICallMeter struct {
+	Interval time.Duration
+
+	mu      sync.Mutex
+	period  string                     // Usage period the known statuses belong to
+	pending map[int]int64              // User ID to calls not yet written
+	known   map[int]models.UsageStatus // User ID to the organization's usage at the last flush
+}
+
+// NewAPICallMeter creates a meter that writes the counted calls every 10 seconds
+func NewAPICallMeter() *APICallMeter {
+	return &APICallMeter{Interval: 10 * time.Second, pending: map[int]int64{}, known: map[int]models.UsageStatus{}}
+}
+
+// DefaultAPICallMeter meters the requests authenticated by token or API key; the server runs it
+var DefaultAPICallMeter = NewAPICallMeter()
+
+// Count records an API call by a user and reports whether the user's organization has gone over
+// its monthly API call quota
+func (m *APICallMeter) Count(userID int, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if period := models.UsagePeriod(now); period != m.period {
+		m.period = period
+		m.known = map[int]models.UsageStatus{}
+	}
+	m.pending[userID]++
+
+	status, ok := m.known[userID]
+	if !ok {
+		return false
+	}
+	status.Used += m.pending[userID]
+	return status.Exceeded()
+}
+
+// Flush adds the calls counted since the last flush to the usage counters and refreshes the
+// known usage. Calls that fail to be written are kept for the next flush.
+func (m *APICallMeter) Flush(now time.Time) error {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = map[int]int64{}
+	m.mu.Unlock()
+
+	var firstErr error
+	for userID, calls := range pending {
+		status, err := models.RecordUsage(userID, models.UsageAPICalls, calls, now)
+		if err == sql.ErrNoRows {
+			// The user has been deleted
+			continue
+		}
+
+		m.mu.Lock()
+		if err != nil {
+			m.pending[userID] += calls
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else if models.UsagePeriod(now) == m.period {
+			m.known[userID] = status
+		}
+		m.mu.Unlock()
+	}
+	return firstErr
+}
+
+// Run flushes the counted calls every Interval until the context is cancelled, then flushes once more
+func (m *APICallMeter) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.Flush(time.Now()); err != nil {
+				slog.Error("Metering API calls failed", "error", err)
+			}
+			return
+		case <-ticker.C:
+		}
+		if err := m.Flush(time.Now()); err != nil {
+			slog.Error("Metering API calls failed", "error", err)
+		}
+	}
+}
+
+// meterAPIRequest counts an authenticated API call against the user's organization and
+// responds with 429 once the organization's monthly API call quota is used up. It returns
+// false when the request has been rejected.
+func meterAPIRequest(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+
+	now := time.Now()
+	if DefaultAPICallMeter.Count(user.ID, now) {
+		retryAfter := int(models.NextUsagePeriodStart(now).Sub(now).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, "Monthly API call quota exceeded", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPICallMeterBatchesCalls(t *testing.T) {
+	mock := setupIdempotencyTestDB(t)
+	meter := NewAPICallMeter()
+	now := time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC)
+
+	// Calls are counted without touching the database until the meter flushes
+	for i := 0; i < 3; i++ {
+		assert.False(t, meter.Count(3, now))
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(`INSERT INTO usage_counters`).
+		WithArgs(3, models.DefaultOrganizationID, "2024-07", models.UsageAPICalls, int64(3), now).
+		WillReturnRows(sqlmock.NewRows([]string{"organization_id", "value"}).AddRow(1, 9))
+	mock.ExpectQuery(`SELECT monthly_limit FROM usage_quotas`).
+		WillReturnRows(sqlmock.NewRows([]string{"monthly_limit"}).AddRow(10))
+	require.NoError(t, meter.Flush(now))
+
+	// The quota is checked against the flushed usage plus the calls counted since
+	assert.False(t, meter.Count(3, now))
+	assert.True(t, meter.Count(3, now))
+
+	// Calls that fail to be written are kept for the next flush
+	mock.ExpectQuery(`INSERT INTO usage_counters`).
+		WithArgs(3, models.DefaultOrganizationID, "2024-07", models.UsageAPICalls, int64(2), now).
+		WillReturnError(errors.New("connection reset"))
+	assert.Error(t, meter.Flush(now))
+	assert.Equal(t, int64(2), meter.pending[3])
+
+	// A new month starts without the previous month's usage
+	assert.False(t, meter.Count(3, now.AddDate(0, 1, 0)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,304 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIVersions are the API versions served, oldest first. The last is the current version, which
+// clients that do not ask for one get.
+var APIVersions = []int{1}
+
+// APIVersionHeader carries the version a client asks for and the version a response was served as
+const APIVersionHeader = "API-Version"
+
+// APIVersionContextKey is the context key of the version a request is served as
+const APIVersionContextKey ContextKey = "api_version"
+
+// apiVersionMediaType prefixes the Accept media type that asks for a version, as in
+// application/vnd.fire-pmaas.v1+json
+const apiVersionMediaType = "application/vnd.fire-pmaas.v"
+
+// Unversioned /api/ routes are deprecated in favour of the same routes under /api/v1/. Their
+// responses carry these dates in the Deprecation and Sunset headers.
+var (
+	UnversionedAPIDeprecatedAt = time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	UnversionedAPISunset       = time.Date(2027, 10, 16, 0, 0, 0, 0, time.UTC)
+)
+
+// CurrentAPIVersion returns the newest API version
+func CurrentAPIVersion() int {
+	return APIVersions[len(APIVersions)-1]
+}
+
+// GetAPIVersion returns the API version a request is served as, the current one outside
+// APIVersioning
+func GetAPIVersion(ctx context.Context) int {
+	if version, ok := ctx.Value(APIVersionContextKey).(int); ok {
+		return version
+	}
+	return CurrentAPIVersion()
+}
+
+// CompatibilityShim adapts the JSON payloads of one API version to the next, so a breaking change
+// to a payload ships in a new version while clients of the old one keep working. Request upgrades
+// a body sent by a Version client to the next version's shape; Response downgrades the next
+// version's response body to Version's shape. Either may be nil.
+type CompatibilityShim struct {
+	Version  int
+	Path     string // Unversioned route path prefix the shim applies to, such as "/api/reports"
+	Request  func(body interface{}) interface{}
+	Response func(body interface{}) interface{}
+}
+
+var compatibilityShims []CompatibilityShim
+
+// RegisterCompatibilityShim adds a shim, normally from an init function beside the handler whose
+// payload changed. It is not safe to call once the server is handling requests.
+func RegisterCompatibilityShim(shim CompatibilityShim) {
+	compatibilityShims = append(compatibilityShims, shim)
+	sort.SliceStable(compatibilityShims, func(i, j int) bool {
+		return compatibilityShims[i].Version < compatibilityShims[j].Version
+	})
+}
+
+// shimsFor returns the shims that carry a request at version from its path up to the current
+// version, oldest first
+func shimsFor(version int, path string) []CompatibilityShim {
+	var shims []CompatibilityShim
+	for _, shim := range compatibilityShims {
+		if shim.Version >= version && shim.Version < CurrentAPIVersion() && strings.HasPrefix(path, shim.Path) {
+			shims = append(shims, shim)
+		}
+	}
+	return shims
+}
+
+// APIVersioning negotiates the API version of /api/ requests. The version is taken from a
+// /api/v{N}/ path prefix, which is then stripped so the request reaches the unversioned route, or
+// else from the API-Version header or an application/vnd.fire-pmaas.v{N}+json Accept type, and
+// defaults to the current version. The version is stored in the context and echoed in the
+// API-Version response header, and the compatibility shims of older versions are applied.
+// Requests to unversioned paths are answered with Deprecation, Sunset and successor Link headers.
+func APIVersioning(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		pathVersion, route, versioned := splitVersionedPath(r.URL.Path)
+		requested, err := requestedAPIVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		version := CurrentAPIVersion()
+		switch {
+		case versioned && requested != 0 && requested != pathVersion:
+			http.Error(w, "The requested API version does not match the version in the path", http.StatusBadRequest)
+			return
+		case versioned:
+			version = pathVersion
+		case requested != 0:
+			version = requested
+		}
+		if !supportedAPIVersion(version) {
+			status := http.StatusBadRequest
+			if versioned {
+				status = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf("Unsupported API version %d; supported versions are %s", version, supportedAPIVersions()), status)
+			return
+		}
+
+		w.Header().Set(APIVersionHeader, strconv.Itoa(version))
+		w.Header().Add("Vary", APIVersionHeader)
+		if versioned {
+			u := *r.URL
+			u.Path, u.RawPath = route, ""
+			r.URL = &u
+		} else {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", UnversionedAPIDeprecatedAt.Unix()))
+			w.Header().Set("Sunset", UnversionedAPISunset.Format(http.TimeFormat))
+			w.Header().Add("Link", fmt.Sprintf(`</api/v%d%s>; rel="successor-version"`,
+				CurrentAPIVersion(), strings.TrimPrefix(r.URL.Path, "/api")))
+		}
+
+		// Handlers negotiate plain media types; the version has been taken from the vendor one
+		if accept := r.Header.Get("Accept"); strings.Contains(accept, apiVersionMediaType) {
+			r.Header.Set("Accept", replaceVersionMediaTypes(accept))
+		}
+		r = r.WithContext(context.WithValue(r.Context(), APIVersionContextKey, version))
+
+		shims := shimsFor(version, r.URL.Path)
+		if len(shims) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := upgradeRequestBody(r, shims); err != nil {
+			http.Error(w, "Invalid JSON request body", http.StatusBadRequest)
+			return
+		}
+		sw := &shimWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		sw.finish(shims)
+	})
+}
+
+// splitVersionedPath splits /api/v{N}/rest into N and /api/rest
+func splitVersionedPath(path string) (int, string, bool) {
+	segment, rest, _ := strings.Cut(strings.TrimPrefix(path, "/api/"), "/")
+	digits, ok := strings.CutPrefix(segment, "v")
+	if !ok || digits == "" {
+		return 0, path, false
+	}
+	version, err := strconv.Atoi(digits)
+	if err != nil || strings.TrimLeft(digits, "0123456789") != "" {
+		return 0, path, false
+	}
+	return version, "/api/" + rest, true
+}
+
+// requestedAPIVersion returns the version asked for in the API-Version header or the Accept
+// header, or 0 when neither asks for one
+func requestedAPIVersion(r *http.Request) (int, error) {
+	if value := strings.TrimSpace(r.Header.Get(APIVersionHeader)); value != "" {
+		version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(value), "v"))
+		if err != nil || version <= 0 {
+			return 0, fmt.Errorf("invalid %s header %q", APIVersionHeader, value)
+		}
+		return version, nil
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if digits, ok := strings.CutPrefix(mediaType, apiVersionMediaType); ok {
+			digits = strings.TrimSuffix(digits, "+json")
+			version, err := strconv.Atoi(digits)
+			if err != nil || version <= 0 {
+				return 0, fmt.Errorf("invalid API version media type %q", mediaType)
+			}
+			return version, nil
+		}
+	}
+	return 0, nil
+}
+
+// replaceVersionMediaTypes swaps the vendor media types in an Accept header for application/json
+func replaceVersionMediaTypes(accept string) string {
+	parts := strings.Split(accept, ",")
+	for i, part := range parts {
+		mediaType, params, found := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.HasPrefix(strings.ToLower(mediaType), apiVersionMediaType) {
+			parts[i] = "application/json"
+			if found {
+				parts[i] += ";" + params
+			}
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func supportedAPIVersion(version int) bool {
+	for _, v := range APIVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func supportedAPIVersions() string {
+	versions := make([]string, len(APIVersions))
+	for i, v := range APIVersions {
+		versions[i] = strconv.Itoa(v)
+	}
+	return strings.Join(versions, ", ")
+}
+
+// upgradeRequestBody passes a JSON request body through the shims' Request functions, oldest first
+func upgradeRequestBody(r *http.Request, shims []CompatibilityShim) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Body == nil || mediaType != "application/json" {
+		return nil
+	}
+	raw, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		return nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return err
+	}
+	for _, shim := range shims {
+		if shim.Request != nil {
+			body = shim.Request(body)
+		}
+	}
+	upgraded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(upgraded))
+	r.ContentLength = int64(len(upgraded))
+	r.Header.Set("Content-Length", strconv.Itoa(len(upgraded)))
+	return nil
+}
+
+// shimWriter buffers a response so its JSON body can be downgraded before it is sent
+type shimWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (sw *shimWriter) WriteHeader(status int) {
+	sw.status = status
+}
+
+func (sw *shimWriter) Write(p []byte) (int, error) {
+	return sw.buf.Write(p)
+}
+
+// finish sends the buffered response, passing a JSON body through the shims' Response
+// functions, newest first. Bodies that are not JSON are sent as written.
+func (sw *shimWriter) finish(shims []CompatibilityShim) {
+	body := sw.buf.Bytes()
+	mediaType, _, _ := mime.ParseMediaType(sw.Header().Get("Content-Type"))
+	if mediaType == "application/json" && len(bytes.TrimSpace(body)) > 0 {
+		var decoded interface{}
+		if err := json.Unmarshal(body, &decoded); err == nil {
+			for i := len(shims) - 1; i >= 0; i-- {
+				if shims[i].Response != nil {
+					decoded = shims[i].Response(decoded)
+				}
+			}
+			if downgraded, err := json.Marshal(decoded); err == nil {
+				body = append(downgraded, '\n')
+				sw.Header().Del("Content-Length")
+			}
+		}
+	}
+	sw.ResponseWriter.WriteHeader(sw.status)
+	_, _ = sw.ResponseWriter.Write(body)
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// versionedRouter serves /api/reports/{id}, echoing the version, Accept header and request body
+func versionedRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(APIVersioning)
+	r.Post("/api/reports/{id}", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      chi.URLParam(r, "id"),
+			"version": GetAPIVersion(r.Context()),
+			"accept":  r.Header.Get("Accept"),
+			"body":    string(body),
+		})
+	})
+	return r
+}
+
+func serveVersioned(t *testing.T, req *http.Request) (*httptest.ResponseRecorder, map[string]interface{}) {
+	rr := httptest.NewRecorder()
+	versionedRouter().ServeHTTP(rr, req)
+	var body map[string]interface{}
+	if rr.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	}
+	return rr, body
+}
+
+func TestAPIVersioningPathPrefix(t *testing.T) {
+	rr, body := serveVersioned(t, httptest.NewRequest("POST", "/api/v1/reports/7", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "7", body["id"])
+	assert.Equal(t, float64(1), body["version"])
+	assert.Equal(t, "1", rr.Header().Get(APIVersionHeader))
+	assert.Empty(t, rr.Header().Get("Deprecation"))
+	assert.Empty(t, rr.Header().Get("Sunset"))
+
+	rr, _ = serveVersioned(t, httptest.NewRequest("POST", "/api/v9/reports/7", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAPIVersioningDeprecatesUnversionedRoutes(t *testing.T) {
+	rr, body := serveVersioned(t, httptest.NewRequest("POST", "/api/reports/7", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, float64(1), body["version"])
+	assert.Equal(t, "@1792108800", rr.Header().Get("Deprecation"))
+	assert.Equal(t, "Sat, 16 Oct 2027 00:00:00 GMT", rr.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/reports/7>; rel="successor-version"`, rr.Header().Get("Link"))
+}
+
+func TestAPIVersioningNegotiation(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v1/reports/7", nil)
+	req.Header.Set("Accept", "application/vnd.fire-pmaas.v1+json, text/csv;q=0.5")
+	rr, body := serveVersioned(t, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json, text/csv;q=0.5", body["accept"])
+
+	req = httptest.NewRequest("POST", "/api/reports/7", nil)
+	req.Header.Set(APIVersionHeader, "3")
+	rr, _ = serveVersioned(t, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "supported versions are 1")
+
+	// A header asking for another version than the path is refused
+	req = httptest.NewRequest("POST", "/api/v1/reports/7", nil)
+	req.Header.Set(APIVersionHeader, "2")
+	rr, _ = serveVersioned(t, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Routes that merely start with a v are not versions
+	_, route, versioned := splitVersionedPath("/api/vendors/3")
+	assert.False(t, versioned)
+	assert.Equal(t, "/api/vendors/3", route)
+}
+
+func TestAPIVersioningCompatibilityShims(t *testing.T) {
+	versions, shims := APIVersions, compatibilityShims
+	defer func() { APIVersions, compatibilityShims = versions, shims }()
+
+	// Version 2 renamed "name" to "title" in report request payloads
+	APIVersions = []int{1, 2}
+	RegisterCompatibilityShim(CompatibilityShim{
+		Version: 1,
+		Path:    "/api/reports",
+		Request: func(body interface{}) interface{} {
+			m := body.(map[string]interface{})
+			m["title"] = m["name"]
+			delete(m, "name")
+			return m
+		},
+		Response: func(body interface{}) interface{} {
+			m := body.(map[string]interface{})
+			m["downgraded"] = true
+			return m
+		},
+	})
+
+	req := httptest.NewRequest("POST", "/api/v1/reports/7", strings.NewReader(`{"name":"Rent roll"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr, body := serveVersioned(t, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"title":"Rent roll"}`, body["body"])
+	assert.Equal(t, true, body["downgraded"])
+
+	// Current version clients get the handler's payloads untouched
+	req = httptest.NewRequest("POST", "/api/v2/reports/7", strings.NewReader(`{"title":"Rent roll"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr, body = serveVersioned(t, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"title":"Rent roll"}`, body["body"])
+	assert.NotContains(t, body, "downgraded")
+
+	// Unversioned routes point at the current version
+	rr, _ = serveVersioned(t, httptest.NewRequest("POST", "/api/reports/7", nil))
+	assert.Equal(t, `</api/v2/reports/7>; rel="successor-version"`, rr.Header().Get("Link"))
+}
@@ -0,0 +1,331 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ErrPeriodLocked is returned when a change would alter a locked accounting period
+var ErrPeriodLocked = errors.New("accounting period is locked")
+
+// AccountingPeriod represents a closed (locked) month for a property
+type AccountingPeriod struct {
+	ID          int            `json:"id"`
+	PropertyID  int            `json:"property_id"`
+	PeriodStart time.Time      `json:"period_start"`
+	LockedBy    sql.NullInt32  `json:"locked_by,omitempty"`
+	LockedAt    time.Time      `json:"locked_at"`
+	Notes       sql.NullString `json:"notes,omitempty"`
+}
+
+// Payment represents a payment or an adjusting entry against a lease or, with LeaseID zero, an
+// association owner's account
+type Payment struct {
+	ID                 int            `json:"id"`
+	LeaseID            int            `json:"lease_id"`
+	AssociationOwnerID sql.NullInt32  `json:"association_owner_id,omitempty"`
+	Amount             float64        `json:"amount"`
+	PaymentDate        time.Time      `json:"payment_date"`
+	PaymentMethod      sql.NullString `json:"payment_method,omitempty"`
+	Reference          sql.NullString `json:"reference,omitempty"`
+	Status             string         `json:"status"`
+	AdjustsPaymentID   sql.NullInt32  `json:"adjusts_payment_id,omitempty"`
+	AdjustmentReason   sql.NullString `json:"adjustment_reason,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+}
+
+// PeriodStart returns the first day of the month containing t
+func PeriodStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// MonthsInRange returns the first day of every month between start and end, inclusive
+func MonthsInRange(start, end time.Time) []time.Time {
+	var months []time.Time
+	for month := PeriodStart(start); !month.After(PeriodStart(end)); month = month.AddDate(0, 1, 0) {
+		months = append(months, month)
+	}
+	return months
+}
+
+// LockAccountingPeriod closes a month for a property
+func LockAccountingPeriod(period *AccountingPeriod) error {
+	period.PeriodStart = PeriodStart(period.PeriodStart)
+
+	query := `
+		INSERT INTO accounting_periods (property_id, period_start, locked_by, notes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, locked_at`
+
+	return db.DB.QueryRow(query, period.PropertyID, period.PeriodStart, period.LockedBy,
+		period.Notes).Scan(&period.ID, &period.LockedAt)
+}
+
+// UnlockAccountingPeriod reopens a month for a property. It returns sql.ErrNoRows if the month was not locked.
+func UnlockAccountingPeriod(propertyID int, month time.Time) error {
+	result, err := db.DB.Exec("DELETE FROM accounting_periods WHERE property_id = $1 AND period_start = $2",
+		propertyID, PeriodStart(month))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAccountingPeriods retrieves locked periods, optionally filtered by property (0 for all)
+func GetAccountingPeriods(propertyID int) ([]AccountingPeriod, error) {
+	query := `
+		SELECT id, property_id, period_start, locked_by, locked_at, notes
+		FROM accounting_periods`
+	var args []interface{}
+	if propertyID > 0 {
+		query += " WHERE property_id = $1"
+		args = append(args, propertyID)
+	}
+	query += " ORDER BY property_id, period_start DESC"
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []AccountingPeriod
+	for rows.Next() {
+		var period AccountingPeriod
+		err := rows.Scan(&period.ID, &period.PropertyID, &period.PeriodStart, &period.LockedBy,
+			&period.LockedAt, &period.Notes)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+
+	return periods, nil
+}
+
+// isPeriodLocked reports whether the month containing date is locked for the lease's property
+func isPeriodLocked(q Querier, leaseID int, date time.Time) (bool, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM accounting_periods ap
+		JOIN property_units pu ON pu.property_id = ap.property_id
+		JOIN leases l ON l.unit_id = pu.id
+		WHERE l.id = $1 AND ap.period_start = $2`
+
+	var count int
+	if err := q.QueryRow(query, leaseID, PeriodStart(date)).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// isPropertyPeriodLocked reports whether the month containing date is locked for the property
+func isPropertyPeriodLocked(q Querier, propertyID int, date time.Time) (bool, error) {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM accounting_periods WHERE property_id = $1 AND period_start = $2",
+		propertyID, PeriodStart(date)).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// isPaymentPeriodLocked reports whether the month containing date is locked for the property of
+// the lease or association owner a payment is made against
+func isPaymentPeriodLocked(q Querier, leaseID int, ownerID sql.NullInt32, date time.Time) (bool, error) {
+	if !ownerID.Valid {
+		return isPeriodLocked(q, leaseID, date)
+	}
+	propertyID, err := ownerPropertyID(q, int(ownerID.Int32))
+	if err != nil {
+		return false, err
+	}
+	return isPropertyPeriodLocked(q, propertyID, date)
+}
+
+// HasUnlockedPeriods reports whether any month between start and end is still open for any of
+// the given properties. An empty propertyIDs checks every property.
+func HasUnlockedPeriods(propertyIDs []int, start, end time.Time) (bool, error) {
+	months := MonthsInRange(start, end)
+
+	propertyFilter, lockFilter := "", ""
+	args := []interface{}{PeriodStart(start), PeriodStart(end)}
+	if len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			args = append(args, id)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		inList := strings.Join(placeholders, ", ")
+		propertyFilter = fmt.Sprintf(" WHERE id IN (%s)", inList)
+		lockFilter = fmt.Sprintf(" AND property_id IN (%s)", inList)
+	}
+
+	// Every property must have a lock row for every month in the range
+	query := fmt.Sprintf(`
+		SELECT
+			(SELECT COUNT(*) FROM properties%s),
+			(SELECT COUNT(*) FROM accounting_periods
+			 WHERE period_start >= $1 AND period_start <= $2%s)`, propertyFilter, lockFilter)
+
+	var propertyCount, lockedCount int
+	if err := db.ReadDB().QueryRow(query, args...).Scan(&propertyCount, &lockedCount); err != nil {
+		return false, err
+	}
+
+	return lockedCount < propertyCount*len(months), nil
+}
+
+// GetPaymentByID retrieves a payment by ID
+func GetPaymentByID(id int) (*Payment, error) {
+	payment := &Payment{}
+	query := `
+		SELECT id, COALESCE(lease_id, 0), association_owner_id, amount, payment_date, payment_method,
+			   reference, status, adjusts_payment_id, adjustment_reason, created_at
+		FROM payments WHERE id = $1`
+
+	err := db.DB.QueryRow(query, id).Scan(&payment.ID, &payment.LeaseID, &payment.AssociationOwnerID,
+		&payment.Amount, &payment.PaymentDate, &payment.PaymentMethod, &payment.Reference, &payment.Status,
+		&payment.AdjustsPaymentID, &payment.AdjustmentReason, &payment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// CreatePayment records a payment. It returns ErrPeriodLocked if the payment date falls in a locked period.
+func CreatePayment(payment *Payment) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertPayment(tx, payment); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertPayment checks the period lock and inserts the payment within tx
+func insertPayment(tx *sql.Tx, payment *Payment) error {
+	locked, err := isPaymentPeriodLocked(tx, payment.LeaseID, payment.AssociationOwnerID, payment.PaymentDate)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return ErrPeriodLocked
+	}
+
+	if payment.Status == "" {
+		payment.Status = "completed"
+	}
+
+	if payment.AssociationOwnerID.Valid {
+		return tx.QueryRow(`
+			INSERT INTO payments (association_owner_id, amount, payment_date, payment_method, reference,
+								  status, adjusts_payment_id, adjustment_reason)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, created_at`,
+			payment.AssociationOwnerID, payment.Amount, payment.PaymentDate, payment.PaymentMethod,
+			payment.Reference, payment.Status, payment.AdjustsPaymentID,
+			payment.AdjustmentReason).Scan(&payment.ID, &payment.CreatedAt)
+	}
+
+	query := `
+		INSERT INTO payments (lease_id, amount, payment_date, payment_method, status,
+							  adjusts_payment_id, adjustment_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+
+	return tx.QueryRow(query, payment.LeaseID, payment.Amount, payment.PaymentDate,
+		payment.PaymentMethod, payment.Status, payment.AdjustsPaymentID,
+		payment.AdjustmentReason).Scan(&payment.ID, &payment.CreatedAt)
+}
+
+// UpdatePayment updates a payment. It returns ErrPeriodLocked if either the current or the
+// new payment date falls in a locked period. An association owner's payment stays with the owner.
+func UpdatePayment(payment *Payment) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var currentLeaseID int
+	var currentDate time.Time
+	err = tx.QueryRow("SELECT COALESCE(lease_id, 0), association_owner_id, payment_date FROM payments WHERE id = $1", payment.ID).
+		Scan(&currentLeaseID, &payment.AssociationOwnerID, &currentDate)
+	if err != nil {
+		return err
+	}
+	if payment.AssociationOwnerID.Valid {
+		payment.LeaseID = 0
+	}
+
+	for _, check := range []struct {
+		leaseID int
+		date    time.Time
+	}{{currentLeaseID, currentDate}, {payment.LeaseID, payment.PaymentDate}} {
+		locked, err := isPaymentPeriodLocked(tx, check.leaseID, payment.AssociationOwnerID, check.date)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return ErrPeriodLocked
+		}
+	}
+
+	query := `
+		UPDATE payments
+		SET lease_id = NULLIF($2, 0), amount = $3, payment_date = $4, payment_method = $5, status = $6
+		WHERE id = $1`
+
+	_, err = tx.Exec(query, payment.ID, payment.LeaseID, payment.Amount, payment.PaymentDate,
+		payment.PaymentMethod, payment.Status)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateAdjustingEntry corrects a payment, which may be in a locked period, by posting a new
+// entry for the difference into the open period containing adjustment.PaymentDate
+func CreateAdjustingEntry(originalID int, adjustment *Payment) error {
+	original, err := GetPaymentByID(originalID)
+	if err != nil {
+		return err
+	}
+
+	adjustment.LeaseID = original.LeaseID
+	adjustment.AssociationOwnerID = original.AssociationOwnerID
+	adjustment.AdjustsPaymentID = sql.NullInt32{Int32: int32(original.ID), Valid: true}
+	if !adjustment.PaymentMethod.Valid {
+		adjustment.PaymentMethod = original.PaymentMethod
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertPayment(tx, adjustment); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
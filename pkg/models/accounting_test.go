@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeriodStart(t *testing.T) {
+	date := time.Date(2024, 3, 17, 15, 4, 5, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), PeriodStart(date))
+}
+
+func TestMonthsInRange(t *testing.T) {
+	start := time.Date(2023, 11, 20, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)
+
+	months := MonthsInRange(start, end)
+	assert.Equal(t, []time.Time{
+		time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}, months)
+
+	assert.Len(t, MonthsInRange(end, start), 0)
+}
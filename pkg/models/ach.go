@@ -0,0 +1,729 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ACH debit statuses. Debits are posted to the ledger when submitted; a settled debit needs no
+// further entry and a returned one is reversed by an adjusting entry.
+const (
+	ACHDebitPending   = "pending"   // Scheduled, not yet sent to the provider
+	ACHDebitSubmitted = "submitted" // Sent to the provider and posted as a payment
+	ACHDebitSettled   = "settled"
+	ACHDebitReturned  = "returned"
+	ACHDebitFailed    = "failed" // Never sent, e.g. the mandate was revoked first
+)
+
+// ACHPaymentMethod is the payment method recorded for ACH debits and their reversals
+const ACHPaymentMethod = "ACH"
+
+// FeeTypeReturnedPayment is the lease fee charged when a debit is returned
+const FeeTypeReturnedPayment = "returned_payment"
+
+var (
+	// ErrInvalidBankAccount is returned for a linked account without a provider token or a
+	// four-digit mask, or with an unknown account type
+	ErrInvalidBankAccount = errors.New("a bank account needs the provider's account ID, the last four digits and a type of checking or savings")
+	// ErrInvalidMandate is returned for a mandate without an active bank account of the lease's
+	// tenant, a debit day between 1 and 28 and, when fixed, a positive amount
+	ErrInvalidMandate = errors.New("a mandate needs an active bank account of the lease's tenant, a debit_day between 1 and 28 and a positive amount when the amount is fixed")
+	// ErrMandateExists is returned when authorizing debits for a lease that already has an active mandate
+	ErrMandateExists = errors.New("the lease already has an active ACH mandate; revoke it first")
+)
+
+var accountMaskPattern = regexp.MustCompile(`^[0-9]{4}$`)
+
+// BankAccount is a tenant's bank account linked through the ACH provider. Only the provider's
+// token for the account and its last four digits are kept.
+type BankAccount struct {
+	ID              int            `json:"id"`
+	TenantID        int            `json:"tenant_id"`
+	Provider        string         `json:"provider"`
+	ExternalID      string         `json:"-"`
+	InstitutionName sql.NullString `json:"institution_name,omitempty"`
+	AccountMask     string         `json:"account_mask"`
+	AccountType     string         `json:"account_type"`
+	Status          string         `json:"status"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// ACHMandate is a tenant's standing authorization to debit rent for a lease from a bank account
+// on a day of each month
+type ACHMandate struct {
+	ID                int             `json:"id"`
+	LeaseID           int             `json:"lease_id"`
+	BankAccountID     int             `json:"bank_account_id"`
+	AccountMask       string          `json:"account_mask"`
+	Amount            sql.NullFloat64 `json:"amount,omitempty"` // Unset debits the rent billed for the month
+	DebitDay          int             `json:"debit_day"`
+	Status            string          `json:"status"`
+	AuthorizationText string          `json:"authorization_text"`
+	AuthorizedAt      time.Time       `json:"authorized_at"`
+	AuthorizedIP      sql.NullString  `json:"authorized_ip,omitempty"`
+	AuthorizedBy      sql.NullInt32   `json:"authorized_by,omitempty"`
+	RevokedAt         sql.NullTime    `json:"revoked_at,omitempty"`
+	RevokedReason     sql.NullString  `json:"revoked_reason,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+}
+
+// ACHDebit is one rent debit collected under a mandate
+type ACHDebit struct {
+	ID                int            `json:"id"`
+	MandateID         int            `json:"mandate_id"`
+	LeaseID           int            `json:"lease_id"`
+	Amount            float64        `json:"amount"`
+	DebitDate         time.Time      `json:"debit_date"`
+	Provider          string         `json:"provider"`
+	ExternalID        sql.NullString `json:"external_id,omitempty"`
+	Status            string         `json:"status"`
+	PaymentID         sql.NullInt32  `json:"payment_id,omitempty"`
+	ReversalPaymentID sql.NullInt32  `json:"reversal_payment_id,omitempty"`
+	FeeID             sql.NullInt32  `json:"fee_id,omitempty"`
+	ReturnCode        sql.NullString `json:"return_code,omitempty"`
+	ReturnReason      sql.NullString `json:"return_reason,omitempty"`
+	Error             sql.NullString `json:"error,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+
+	AccountExternalID string `json:"-"` // Provider's token for the account to debit
+}
+
+// LeaseFee is a fee charged to a lease, such as the fee for a returned payment, or with LeaseID
+// zero a dues or fine charge to an association owner's account
+type LeaseFee struct {
+	ID                 int           `json:"id"`
+	LeaseID            int           `json:"lease_id"`
+	AssociationOwnerID sql.NullInt32 `json:"association_owner_id,omitempty"`
+	FeeType            string        `json:"fee_type"`
+	AssessmentID       sql.NullInt32 `json:"assessment_id,omitempty"`
+	ViolationID        sql.NullInt32 `json:"violation_id,omitempty"`
+	Description        string        `json:"description"`
+	Amount             float64       `json:"amount"`
+	FeeDate            time.Time     `json:"fee_date"`
+	CreatedBy          sql.NullInt32 `json:"created_by,omitempty"`
+	CreatedAt          time.Time     `json:"created_at"`
+}
+
+// ACHReturn is how a returned debit is handled: the bank's return code and reason, the fee
+// charged to the lease (none when zero) and whether the mandate ends
+type ACHReturn struct {
+	Code          string
+	Reason        string
+	Fee           float64
+	RevokeMandate bool
+}
+
+const bankAccountColumns = `id, tenant_id, provider, external_id, institution_name, account_mask, account_type,
+	status, created_at, updated_at`
+
+func scanBankAccount(row interface{ Scan(...interface{}) error }) (BankAccount, error) {
+	var a BankAccount
+	err := row.Scan(&a.ID, &a.TenantID, &a.Provider, &a.ExternalID, &a.InstitutionName, &a.AccountMask,
+		&a.AccountType, &a.Status, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// CreateBankAccount records an account the tenant linked through the provider
+func CreateBankAccount(a *BankAccount) error {
+	a.AccountType = strings.ToLower(strings.TrimSpace(a.AccountType))
+	if a.AccountType == "" {
+		a.AccountType = "checking"
+	}
+	if a.ExternalID == "" || !accountMaskPattern.MatchString(a.AccountMask) ||
+		(a.AccountType != "checking" && a.AccountType != "savings") {
+		return ErrInvalidBankAccount
+	}
+
+	return db.DB.QueryRow(`
+		INSERT INTO ach_bank_accounts (tenant_id, provider, external_id, institution_name, account_mask, account_type)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at, updated_at`,
+		a.TenantID, a.Provider, a.ExternalID, a.InstitutionName, a.AccountMask, a.AccountType).
+		Scan(&a.ID, &a.Status, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// GetBankAccount retrieves a linked bank account
+func GetBankAccount(id int) (*BankAccount, error) {
+	a, err := scanBankAccount(db.DB.QueryRow("SELECT "+bankAccountColumns+" FROM ach_bank_accounts WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetTenantBankAccounts lists a tenant's active linked bank accounts
+func GetTenantBankAccounts(tenantID int) ([]BankAccount, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+bankAccountColumns+`
+		FROM ach_bank_accounts
+		WHERE tenant_id = $1 AND status = 'active'
+		ORDER BY created_at`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []BankAccount{}
+	for rows.Next() {
+		a, err := scanBankAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// RemoveBankAccount unlinks a bank account and revokes the mandates that debit it. It returns
+// sql.ErrNoRows if the account is not active.
+func RemoveBankAccount(id int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE ach_bank_accounts SET status = 'removed', updated_at = NOW()
+		WHERE id = $1 AND status = 'active'`, id)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id FROM ach_mandates WHERE bank_account_id = $1 AND status = 'active'", id)
+	if err != nil {
+		return err
+	}
+	var mandateIDs []int
+	for rows.Next() {
+		var mandateID int
+		if err := rows.Scan(&mandateID); err != nil {
+			rows.Close()
+			return err
+		}
+		mandateIDs = append(mandateIDs, mandateID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, mandateID := range mandateIDs {
+		if err := revokeACHMandate(tx, mandateID, "bank account removed"); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// FormatACHAuthorization returns the authorization a tenant agrees to when creating a mandate
+func FormatACHAuthorization(tenantName string, m *ACHMandate) string {
+	amount := "the rent billed for that month"
+	if m.Amount.Valid {
+		amount = fmt.Sprintf("$%.2f", m.Amount.Float64)
+	}
+	return fmt.Sprintf("I, %s, authorize the property manager to debit %s from my bank account ending in %s "+
+		"on day %d of each month for rent on lease %d, and, if a debit is returned, to reverse the "+
+		"payment and charge any returned payment fee under my lease. This authorization remains in "+
+		"effect until I revoke it, which I may do at any time before a debit is submitted.",
+		tenantName, amount, m.AccountMask, m.DebitDay, m.LeaseID)
+}
+
+const achMandateColumns = `m.id, m.lease_id, m.bank_account_id, ba.account_mask, m.amount, m.debit_day, m.status,
+	m.authorization_text, m.authorized_at, m.authorized_ip, m.authorized_by, m.revoked_at, m.revoked_reason,
+	m.created_at`
+
+func scanACHMandate(row interface{ Scan(...interface{}) error }) (ACHMandate, error) {
+	var m ACHMandate
+	err := row.Scan(&m.ID, &m.LeaseID, &m.BankAccountID, &m.AccountMask, &m.Amount, &m.DebitDay, &m.Status,
+		&m.AuthorizationText, &m.AuthorizedAt, &m.AuthorizedIP, &m.AuthorizedBy, &m.RevokedAt, &m.RevokedReason,
+		&m.CreatedAt)
+	return m, err
+}
+
+// CreateACHMandate records a tenant's authorization to debit rent for a lease. The bank account
+// must be active and belong to the lease's tenant, and the lease must have no other active mandate.
+// The authorization text is generated from the mandate's terms.
+func CreateACHMandate(m *ACHMandate) error {
+	if m.DebitDay < 1 || m.DebitDay > 28 || (m.Amount.Valid && m.Amount.Float64 <= 0) {
+		return ErrInvalidMandate
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tenantName string
+	err = tx.QueryRow(`
+		SELECT ba.account_mask, t.first_name || ' ' || t.last_name
+		FROM ach_bank_accounts ba
+		JOIN leases l ON l.tenant_id = ba.tenant_id
+		JOIN tenants t ON t.id = ba.tenant_id
+		WHERE ba.id = $1 AND l.id = $2 AND ba.status = 'active'`, m.BankAccountID, m.LeaseID).
+		Scan(&m.AccountMask, &tenantName)
+	if err == sql.ErrNoRows {
+		return ErrInvalidMandate
+	}
+	if err != nil {
+		return err
+	}
+
+	var active int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM ach_mandates WHERE lease_id = $1 AND status = 'active'",
+		m.LeaseID).Scan(&active); err != nil {
+		return err
+	}
+	if active > 0 {
+		return ErrMandateExists
+	}
+
+	m.AuthorizationText = FormatACHAuthorization(tenantName, m)
+	err = tx.QueryRow(`
+		INSERT INTO ach_mandates (lease_id, bank_account_id, amount, debit_day, authorization_text,
+								  authorized_ip, authorized_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, authorized_at, created_at`,
+		m.LeaseID, m.BankAccountID, m.Amount, m.DebitDay, m.AuthorizationText, m.AuthorizedIP, m.AuthorizedBy).
+		Scan(&m.ID, &m.Status, &m.AuthorizedAt, &m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetACHMandate retrieves a mandate
+func GetACHMandate(id int) (*ACHMandate, error) {
+	m, err := scanACHMandate(db.DB.QueryRow(`
+		SELECT `+achMandateColumns+`
+		FROM ach_mandates m
+		JOIN ach_bank_accounts ba ON ba.id = m.bank_account_id
+		WHERE m.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetLeaseACHMandates lists a lease's mandates, newest first
+func GetLeaseACHMandates(leaseID int) ([]ACHMandate, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+achMandateColumns+`
+		FROM ach_mandates m
+		JOIN ach_bank_accounts ba ON ba.id = m.bank_account_id
+		WHERE m.lease_id = $1
+		ORDER BY m.authorized_at DESC`, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mandates := []ACHMandate{}
+	for rows.Next() {
+		m, err := scanACHMandate(rows)
+		if err != nil {
+			return nil, err
+		}
+		mandates = append(mandates, m)
+	}
+	return mandates, rows.Err()
+}
+
+// RevokeACHMandate ends a mandate. Debits scheduled under it but not yet submitted are failed.
+// It returns sql.ErrNoRows if the mandate is not active.
+func RevokeACHMandate(id int, reason string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeACHMandate(tx, id, reason); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// revokeACHMandate ends an active mandate within q and fails its unsubmitted debits
+func revokeACHMandate(q Querier, id int, reason string) error {
+	result, err := q.Exec(`
+		UPDATE ach_mandates SET status = 'revoked', revoked_at = NOW(), revoked_reason = $2
+		WHERE id = $1 AND status = 'active'`, id, NullString(reason))
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+
+	_, err = q.Exec(`
+		UPDATE ach_debits SET status = 'failed', error = 'mandate revoked', updated_at = NOW()
+		WHERE mandate_id = $1 AND status = 'pending'`, id)
+	return err
+}
+
+// ACHDebitDate returns the day of month's debit under a mandate with the given debit day
+func ACHDebitDate(month time.Time, debitDay int) time.Time {
+	return PeriodStart(month).AddDate(0, 0, debitDay-1)
+}
+
+// ScheduleACHDebits creates this month's debit for every active mandate whose debit day has
+// arrived by today and that has none yet. Mandates authorized after the debit day start the
+// next month, and months the lease does not cover are skipped. A debit with no fixed amount
+// collects the rent billed for the month. It returns the number of debits scheduled.
+func ScheduleACHDebits(provider string, today time.Time) (int, error) {
+	monthStart := PeriodStart(today)
+	rows, err := db.DB.Query(`
+		SELECT m.id, m.lease_id, m.amount, m.debit_day, m.authorized_at
+		FROM ach_mandates m
+		JOIN leases l ON l.id = m.lease_id
+		JOIN ach_bank_accounts ba ON ba.id = m.bank_account_id
+		WHERE m.status = 'active' AND ba.status = 'active' AND l.status = 'active' AND m.debit_day <= $1
+		  AND NOT EXISTS (SELECT 1 FROM ach_debits d WHERE d.mandate_id = m.id AND d.debit_date >= $2)
+		ORDER BY m.id`, today.Day(), monthStart)
+	if err != nil {
+		return 0, err
+	}
+	var due []ACHMandate
+	for rows.Next() {
+		var m ACHMandate
+		if err := rows.Scan(&m.ID, &m.LeaseID, &m.Amount, &m.DebitDay, &m.AuthorizedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	scheduled := 0
+	for _, m := range due {
+		debitDate := ACHDebitDate(monthStart, m.DebitDay)
+		authorized := m.AuthorizedAt.UTC()
+		if debitDate.Before(time.Date(authorized.Year(), authorized.Month(), authorized.Day(), 0, 0, 0, 0, time.UTC)) {
+			continue
+		}
+
+		lease, err := GetScheduledLease(m.LeaseID)
+		if err != nil {
+			return scheduled, err
+		}
+		charge := lease.ChargeForMonth(monthStart)
+		if charge.AmountDue <= 0 {
+			continue
+		}
+		amount := charge.AmountDue
+		if m.Amount.Valid {
+			amount = m.Amount.Float64
+		}
+
+		result, err := db.DB.Exec(`
+			INSERT INTO ach_debits (mandate_id, lease_id, amount, debit_date, provider)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (mandate_id, debit_date) DO NOTHING`,
+			m.ID, m.LeaseID, amount, debitDate, provider)
+		if err != nil {
+			return scheduled, err
+		}
+		if requireAffected(result) == nil {
+			scheduled++
+		}
+	}
+	return scheduled, nil
+}
+
+const achDebitColumns = `d.id, d.mandate_id, d.lease_id, d.amount, d.debit_date, d.provider, d.external_id, d.status,
+	d.payment_id, d.reversal_payment_id, d.fee_id, d.return_code, d.return_reason, d.error, d.created_at,
+	d.updated_at, ba.external_id`
+
+func scanACHDebit(row interface{ Scan(...interface{}) error }) (ACHDebit, error) {
+	var d ACHDebit
+	err := row.Scan(&d.ID, &d.MandateID, &d.LeaseID, &d.Amount, &d.DebitDate, &d.Provider, &d.ExternalID,
+		&d.Status, &d.PaymentID, &d.ReversalPaymentID, &d.FeeID, &d.ReturnCode, &d.ReturnReason, &d.Error,
+		&d.CreatedAt, &d.UpdatedAt, &d.AccountExternalID)
+	return d, err
+}
+
+// queryACHDebits runs a query selecting achDebitColumns
+func queryACHDebits(where string, args ...interface{}) ([]ACHDebit, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+achDebitColumns+`
+		FROM ach_debits d
+		JOIN ach_mandates m ON m.id = d.mandate_id
+		JOIN ach_bank_accounts ba ON ba.id = m.bank_account_id
+		WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	debits := []ACHDebit{}
+	for rows.Next() {
+		d, err := scanACHDebit(rows)
+		if err != nil {
+			return nil, err
+		}
+		debits = append(debits, d)
+	}
+	return debits, rows.Err()
+}
+
+// GetPendingACHDebits returns a provider's scheduled debits that have not been submitted yet
+func GetPendingACHDebits(provider string) ([]ACHDebit, error) {
+	return queryACHDebits("d.provider = $1 AND d.status = 'pending' ORDER BY d.debit_date, d.id", provider)
+}
+
+// GetLeaseACHDebits lists a lease's debits, newest first
+func GetLeaseACHDebits(leaseID int) ([]ACHDebit, error) {
+	return queryACHDebits("d.lease_id = $1 ORDER BY d.debit_date DESC, d.id DESC", leaseID)
+}
+
+// GetACHDebitByExternalID finds a debit by the provider's transfer ID
+func GetACHDebitByExternalID(provider, externalID string) (*ACHDebit, error) {
+	debits, err := queryACHDebits("d.provider = $1 AND d.external_id = $2", provider, externalID)
+	if err != nil {
+		return nil, err
+	}
+	if len(debits) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &debits[0], nil
+}
+
+// SubmitACHDebit records that the provider accepted a pending debit and posts it to the lease's
+// ledger as a payment on the debit date
+func SubmitACHDebit(id int, externalID string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var leaseID int
+	var amount float64
+	var debitDate time.Time
+	err = tx.QueryRow("SELECT lease_id, amount, debit_date FROM ach_debits WHERE id = $1 AND status = 'pending'", id).
+		Scan(&leaseID, &amount, &debitDate)
+	if err != nil {
+		return err
+	}
+
+	payment := &Payment{
+		LeaseID:       leaseID,
+		Amount:        amount,
+		PaymentDate:   debitDate,
+		PaymentMethod: NullString(ACHPaymentMethod),
+		Status:        "completed",
+	}
+	if err := insertPayment(tx, payment); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE ach_debits SET status = 'submitted', external_id = $2, payment_id = $3, updated_at = NOW()
+		WHERE id = $1`, id, externalID, payment.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FailACHDebit records that a pending debit could not be submitted
+func FailACHDebit(id int, message string) error {
+	result, err := db.DB.Exec(`
+		UPDATE ach_debits SET status = 'failed', error = $2, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'`, id, message)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SettleACHDebit records that a submitted debit's funds arrived. Debits that are no longer
+// submitted are left unchanged and sql.ErrNoRows is returned.
+func SettleACHDebit(id int) error {
+	result, err := db.DB.Exec(`
+		UPDATE ach_debits SET status = 'settled', updated_at = NOW()
+		WHERE id = $1 AND status = 'submitted'`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// ReturnACHDebit handles a debit the bank returned, which can happen after settlement: its
+// payment is reversed by an adjusting entry dated today, the return fee is charged to the lease,
+// the mandate is revoked when ret says so, and the tenant is notified. Debits already returned
+// are left unchanged and sql.ErrNoRows is returned.
+func ReturnACHDebit(id int, ret ACHReturn, today time.Time) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var d ACHDebit
+	var tenantName, tenantEmail string
+	err = tx.QueryRow(`
+		SELECT d.mandate_id, d.lease_id, d.amount, d.debit_date, d.payment_id,
+			   t.first_name || ' ' || t.last_name, t.email
+		FROM ach_debits d
+		JOIN leases l ON l.id = d.lease_id
+		JOIN tenants t ON t.id = l.tenant_id
+		WHERE d.id = $1 AND d.status IN ('submitted', 'settled')`, id).
+		Scan(&d.MandateID, &d.LeaseID, &d.Amount, &d.DebitDate, &d.PaymentID, &tenantName, &tenantEmail)
+	if err != nil {
+		return err
+	}
+
+	reason := "ACH debit returned"
+	if ret.Code != "" {
+		reason = fmt.Sprintf("ACH debit returned (%s)", ret.Code)
+	}
+	if ret.Reason != "" {
+		reason += ": " + ret.Reason
+	}
+
+	if d.PaymentID.Valid {
+		reversal := &Payment{
+			LeaseID:          d.LeaseID,
+			Amount:           -d.Amount,
+			PaymentDate:      today,
+			PaymentMethod:    NullString(ACHPaymentMethod),
+			Status:           "completed",
+			AdjustsPaymentID: d.PaymentID,
+			AdjustmentReason: NullString(reason),
+		}
+		if err := insertPayment(tx, reversal); err != nil {
+			return err
+		}
+		d.ReversalPaymentID = sql.NullInt32{Int32: int32(reversal.ID), Valid: true}
+	}
+
+	if ret.Fee > 0 {
+		fee := &LeaseFee{
+			LeaseID:     d.LeaseID,
+			FeeType:     FeeTypeReturnedPayment,
+			Description: fmt.Sprintf("Returned payment fee for the ACH debit of %s", d.DebitDate.Format("2006-01-02")),
+			Amount:      ret.Fee,
+			FeeDate:     today,
+		}
+		if err := insertLeaseFee(tx, fee); err != nil {
+			return err
+		}
+		d.FeeID = sql.NullInt32{Int32: int32(fee.ID), Valid: true}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE ach_debits
+		SET status = 'returned', return_code = $2, return_reason = $3, reversal_payment_id = $4, fee_id = $5,
+			updated_at = NOW()
+		WHERE id = $1`, id, NullString(ret.Code), NullString(ret.Reason), d.ReversalPaymentID, d.FeeID)
+	if err != nil {
+		return err
+	}
+
+	if ret.RevokeMandate {
+		if err := revokeACHMandate(tx, d.MandateID, reason); err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	subject, body := FormatACHReturnNotice(tenantName, &d, ret)
+	err = EnqueueOutboxMessage(tx, &OutboxMessage{
+		Channel:     "email",
+		EventType:   "ach.debit_returned",
+		Destination: tenantEmail,
+		Payload: map[string]interface{}{
+			"lease_id":     d.LeaseID,
+			"ach_debit_id": id,
+			"return_code":  ret.Code,
+			"fee":          ret.Fee,
+			"subject":      subject,
+			"body":         body,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FormatACHReturnNotice returns the subject and body of the email telling a tenant their debit
+// was returned
+func FormatACHReturnNotice(tenantName string, d *ACHDebit, ret ACHReturn) (string, string) {
+	subject := "Your rent payment was returned by your bank"
+	body := fmt.Sprintf("Hi %s,\n\nYour bank returned the ACH rent payment of $%.2f from %s",
+		tenantName, d.Amount, d.DebitDate.Format("January 2, 2006"))
+	if ret.Reason != "" {
+		body += fmt.Sprintf(" (%s)", ret.Reason)
+	}
+	body += ". The payment has been reversed and the amount is due again."
+	if ret.Fee > 0 {
+		body += fmt.Sprintf(" A returned payment fee of $%.2f has been charged under your lease.", ret.Fee)
+	}
+	if ret.RevokeMandate {
+		body += " Automatic payments from this account have been stopped; please link a bank account again to resume them."
+	}
+	return subject, body + "\n"
+}
+
+// insertLeaseFee charges a fee to a lease, or to an association owner, within q. It returns
+// sql.ErrNoRows for an owner's dues already posted for the assessment and date.
+func insertLeaseFee(q Querier, fee *LeaseFee) error {
+	if fee.AssociationOwnerID.Valid {
+		return q.QueryRow(`
+			INSERT INTO lease_fees (association_owner_id, fee_type, assessment_id, violation_id, description,
+									amount, fee_date, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (assessment_id, association_owner_id, fee_date) DO NOTHING
+			RETURNING id, created_at`,
+			fee.AssociationOwnerID, fee.FeeType, fee.AssessmentID, fee.ViolationID, fee.Description,
+			fee.Amount, fee.FeeDate, fee.CreatedBy).
+			Scan(&fee.ID, &fee.CreatedAt)
+	}
+	return q.QueryRow(`
+		INSERT INTO lease_fees (lease_id, fee_type, description, amount, fee_date, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		fee.LeaseID, fee.FeeType, fee.Description, fee.Amount, fee.FeeDate, fee.CreatedBy).
+		Scan(&fee.ID, &fee.CreatedAt)
+}
+
+// GetLeaseFees lists the fees charged to a lease, newest first
+func GetLeaseFees(leaseID int) ([]LeaseFee, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, lease_id, fee_type, description, amount, fee_date, created_by, created_at
+		FROM lease_fees
+		WHERE lease_id = $1
+		ORDER BY fee_date DESC, id DESC`, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fees := []LeaseFee{}
+	for rows.Next() {
+		var f LeaseFee
+		if err := rows.Scan(&f.ID, &f.LeaseID, &f.FeeType, &f.Description, &f.Amount, &f.FeeDate,
+			&f.CreatedBy, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		fees = append(fees, f)
+	}
+	return fees, rows.Err()
+}
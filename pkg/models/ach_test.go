@@ -0,0 +1,160 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateBankAccountValidation(t *testing.T) {
+	assert.ErrorIs(t, CreateBankAccount(&BankAccount{ExternalID: "acct_1", AccountMask: "12"}), ErrInvalidBankAccount)
+	assert.ErrorIs(t, CreateBankAccount(&BankAccount{ExternalID: "acct_1", AccountMask: "1234", AccountType: "brokerage"}),
+		ErrInvalidBankAccount)
+	assert.ErrorIs(t, CreateBankAccount(&BankAccount{AccountMask: "1234"}), ErrInvalidBankAccount)
+}
+
+func TestCreateACHMandate(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT ba.account_mask`).
+		WithArgs(3, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"account_mask", "name"}).AddRow("6789", "Ann Lee"))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM ach_mandates WHERE lease_id`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO ach_mandates`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "authorized_at", "created_at"}).
+			AddRow(5, "active", now, now))
+	mock.ExpectCommit()
+
+	mandate := &ACHMandate{LeaseID: 10, BankAccountID: 3, DebitDay: 3}
+	require.NoError(t, CreateACHMandate(mandate))
+	assert.Equal(t, 5, mandate.ID)
+	assert.Contains(t, mandate.AuthorizationText, "I, Ann Lee, authorize")
+	assert.Contains(t, mandate.AuthorizationText, "ending in 6789 on day 3 of each month")
+	assert.Contains(t, mandate.AuthorizationText, "the rent billed for that month")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Debit days past the 28th would skip short months
+	assert.ErrorIs(t, CreateACHMandate(&ACHMandate{LeaseID: 10, BankAccountID: 3, DebitDay: 31}), ErrInvalidMandate)
+}
+
+func TestCreateACHMandateExists(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT ba.account_mask`).
+		WithArgs(3, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"account_mask", "name"}).AddRow("6789", "Ann Lee"))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM ach_mandates WHERE lease_id`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	err := CreateACHMandate(&ACHMandate{LeaseID: 10, BankAccountID: 3, DebitDay: 1})
+	assert.ErrorIs(t, err, ErrMandateExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReturnACHDebitReversesPaymentAndChargesFee(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	today := time.Date(2026, 10, 6, 0, 0, 0, 0, time.UTC)
+	debitDate := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT d.mandate_id, d.lease_id, d.amount, d.debit_date, d.payment_id`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"mandate_id", "lease_id", "amount", "debit_date", "payment_id", "name", "email"}).
+			AddRow(5, 10, 1500.0, debitDate, 40, "Ann Lee", "ann@example.com"))
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM accounting_periods`).
+		WithArgs(10, PeriodStart(today)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO payments`).
+		WithArgs(10, -1500.0, today, NullString(ACHPaymentMethod), "completed",
+			sql.NullInt32{Int32: 40, Valid: true}, NullString("ACH debit returned (R01): Insufficient funds")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(41, now))
+	mock.ExpectQuery(`INSERT INTO lease_fees`).
+		WithArgs(10, FeeTypeReturnedPayment, "Returned payment fee for the ACH debit of 2026-10-01", 25.0, today,
+			sql.NullInt32{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, now))
+	mock.ExpectExec(`UPDATE ach_debits\s+SET status = 'returned'`).
+		WithArgs(7, NullString("R01"), NullString("Insufficient funds"),
+			sql.NullInt32{Int32: 41, Valid: true}, sql.NullInt32{Int32: 2, Valid: true}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	err := ReturnACHDebit(7, ACHReturn{Code: "R01", Reason: "Insufficient funds", Fee: 25}, today)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReturnACHDebitRevokesMandate(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	today := time.Date(2026, 10, 6, 0, 0, 0, 0, time.UTC)
+
+	// Without a fee or a posted payment only the debit, the mandate and the notice change
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT d.mandate_id`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"mandate_id", "lease_id", "amount", "debit_date", "payment_id", "name", "email"}).
+			AddRow(5, 10, 1500.0, today, nil, "Ann Lee", "ann@example.com"))
+	mock.ExpectExec(`UPDATE ach_debits\s+SET status = 'returned'`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE ach_mandates SET status = 'revoked'`).
+		WithArgs(5, NullString("ACH debit returned (R10): Not authorized")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE ach_debits SET status = 'failed'`).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	err := ReturnACHDebit(7, ACHReturn{Code: "R10", Reason: "Not authorized", RevokeMandate: true}, today)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReturnACHDebitAlreadyReturned(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT d.mandate_id`).WithArgs(7).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	assert.Equal(t, sql.ErrNoRows, ReturnACHDebit(7, ACHReturn{Code: "R01"}, time.Now()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFormatACHReturnNotice(t *testing.T) {
+	debit := &ACHDebit{Amount: 1500, DebitDate: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}
+	subject, body := FormatACHReturnNotice("Ann Lee", debit, ACHReturn{Code: "R01", Reason: "Insufficient funds", Fee: 25})
+	assert.Equal(t, "Your rent payment was returned by your bank", subject)
+	assert.Contains(t, body, "$1500.00 from October 1, 2026 (Insufficient funds)")
+	assert.Contains(t, body, "returned payment fee of $25.00")
+	assert.NotContains(t, body, "Automatic payments")
+}
+
+func TestACHDebitDate(t *testing.T) {
+	assert.Equal(t, time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC),
+		ACHDebitDate(time.Date(2026, 2, 14, 9, 0, 0, 0, time.UTC), 28))
+}
@@ -0,0 +1,311 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ErrInvalidAnnouncement is returned when an announcement is missing its text or expires before
+// it is published
+var ErrInvalidAnnouncement = errors.New("an announcement needs a title and body, and must expire after it is published")
+
+// Announcement is a notice shown in tenant portals for one property, or the whole portfolio when
+// PropertyID is null, between PublishAt and ExpiresAt
+type Announcement struct {
+	ID           int            `json:"id"`
+	PropertyID   sql.NullInt32  `json:"property_id,omitempty"`
+	PropertyName sql.NullString `json:"property_name,omitempty"`
+	Title        string         `json:"title"`
+	Body         string         `json:"body"`
+	SendEmail    bool           `json:"send_email"`
+	SendSMS      bool           `json:"send_sms"`
+	PublishAt    time.Time      `json:"publish_at"`
+	ExpiresAt    sql.NullTime   `json:"expires_at,omitempty"`
+	NotifiedAt   sql.NullTime   `json:"notified_at,omitempty"`
+	CreatedBy    sql.NullInt32  `json:"created_by,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// Validate checks the announcement has text and a sensible schedule
+func (a *Announcement) Validate() error {
+	a.Title = strings.TrimSpace(a.Title)
+	a.Body = strings.TrimSpace(a.Body)
+	if a.Title == "" || a.Body == "" {
+		return ErrInvalidAnnouncement
+	}
+	if a.ExpiresAt.Valid && !a.ExpiresAt.Time.After(a.PublishAt) {
+		return ErrInvalidAnnouncement
+	}
+	return nil
+}
+
+const announcementColumns = `a.id, a.property_id, p.name, a.title, a.body, a.send_email, a.send_sms,
+	a.publish_at, a.expires_at, a.notified_at, a.created_by, a.created_at, a.updated_at`
+
+func scanAnnouncement(row interface{ Scan(...interface{}) error }) (Announcement, error) {
+	var a Announcement
+	err := row.Scan(&a.ID, &a.PropertyID, &a.PropertyName, &a.Title, &a.Body, &a.SendEmail, &a.SendSMS,
+		&a.PublishAt, &a.ExpiresAt, &a.NotifiedAt, &a.CreatedBy, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+func queryAnnouncements(query string, args ...interface{}) ([]Announcement, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var announcements []Announcement
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, a)
+	}
+	return announcements, rows.Err()
+}
+
+// checkAnnouncementProperty returns sql.ErrNoRows if the announcement targets a missing property
+func checkAnnouncementProperty(a *Announcement) error {
+	if !a.PropertyID.Valid {
+		return nil
+	}
+	var exists int
+	return db.DB.QueryRow("SELECT 1 FROM properties WHERE id = $1", a.PropertyID.Int32).Scan(&exists)
+}
+
+// CreateAnnouncement saves a new announcement. A zero PublishAt publishes it immediately.
+func CreateAnnouncement(a *Announcement) error {
+	if a.PublishAt.IsZero() {
+		a.PublishAt = time.Now()
+	}
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	if err := checkAnnouncementProperty(a); err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO announcements (property_id, title, body, send_email, send_sms, publish_at, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		a.PropertyID, a.Title, a.Body, a.SendEmail, a.SendSMS, a.PublishAt, a.ExpiresAt, a.CreatedBy).
+		Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// UpdateAnnouncement changes an announcement. Once its notifications have gone out they are not
+// sent again, however the announcement is edited.
+func UpdateAnnouncement(a *Announcement) error {
+	if a.PublishAt.IsZero() {
+		a.PublishAt = time.Now()
+	}
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	if err := checkAnnouncementProperty(a); err != nil {
+		return err
+	}
+	result, err := db.DB.Exec(`
+		UPDATE announcements
+		SET property_id = $2, title = $3, body = $4, send_email = $5, send_sms = $6, publish_at = $7,
+			expires_at = $8, updated_at = NOW()
+		WHERE id = $1`,
+		a.ID, a.PropertyID, a.Title, a.Body, a.SendEmail, a.SendSMS, a.PublishAt, a.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteAnnouncement removes an announcement
+func DeleteAnnouncement(id int) error {
+	result, err := db.DB.Exec("DELETE FROM announcements WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetAnnouncement retrieves an announcement by ID
+func GetAnnouncement(id int) (*Announcement, error) {
+	a, err := scanAnnouncement(db.DB.QueryRow(`
+		SELECT `+announcementColumns+`
+		FROM announcements a
+		LEFT JOIN properties p ON p.id = a.property_id
+		WHERE a.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAnnouncements returns announcements for managers, newest first, optionally limited to one
+// property and its portfolio-wide announcements (0 for all). Expired announcements are included
+// only when includeExpired is set.
+func GetAnnouncements(propertyID int, includeExpired bool, now time.Time) ([]Announcement, error) {
+	query := `
+		SELECT ` + announcementColumns + `
+		FROM announcements a
+		LEFT JOIN properties p ON p.id = a.property_id
+		WHERE ($1 = 0 OR a.property_id = $1 OR a.property_id IS NULL)`
+	args := []interface{}{propertyID}
+	if !includeExpired {
+		query += " AND (a.expires_at IS NULL OR a.expires_at > $2)"
+		args = append(args, now)
+	}
+	query += " ORDER BY a.publish_at DESC, a.id DESC"
+	return queryAnnouncements(query, args...)
+}
+
+// GetTenantAnnouncements returns the announcements currently shown to a tenant: portfolio-wide
+// ones and those for properties where they hold an active lease
+func GetTenantAnnouncements(tenantID int, now time.Time) ([]Announcement, error) {
+	return queryAnnouncements(`
+		SELECT `+announcementColumns+`
+		FROM announcements a
+		LEFT JOIN properties p ON p.id = a.property_id
+		WHERE a.publish_at <= $2
+		  AND (a.expires_at IS NULL OR a.expires_at > $2)
+		  AND (a.property_id IS NULL OR a.property_id IN (
+				SELECT pu.property_id
+				FROM leases l
+				JOIN property_units pu ON pu.id = l.unit_id
+				WHERE l.tenant_id = $1 AND l.status = 'active'))
+		ORDER BY a.publish_at DESC, a.id DESC`, tenantID, now)
+}
+
+// announcementRecipient is a tenant to notify of an announcement
+type announcementRecipient struct {
+	Email string
+	Phone sql.NullString
+}
+
+// announcementRecipients returns the active tenants with an active lease at the property, or
+// anywhere in the portfolio when propertyID is null
+func announcementRecipients(q Querier, propertyID sql.NullInt32) ([]announcementRecipient, error) {
+	rows, err := q.Query(`
+		SELECT DISTINCT t.email, t.phone_number
+		FROM tenants t
+		JOIN leases l ON l.tenant_id = t.id
+		JOIN property_units pu ON pu.id = l.unit_id
+		WHERE t.status = 'active' AND l.status = 'active'
+		  AND ($1 = 0 OR pu.property_id = $1)
+		ORDER BY t.email`, propertyID.Int32)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []announcementRecipient
+	for rows.Next() {
+		var r announcementRecipient
+		if err := rows.Scan(&r.Email, &r.Phone); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, rows.Err()
+}
+
+// SendDueAnnouncements queues the email and SMS notifications of every announcement that has
+// been published but not yet sent, and returns the number of announcements sent. Announcements
+// that expire before they are sent are skipped.
+func SendDueAnnouncements(now time.Time) (int, error) {
+	due, err := queryAnnouncements(`
+		SELECT `+announcementColumns+`
+		FROM announcements a
+		LEFT JOIN properties p ON p.id = a.property_id
+		WHERE a.notified_at IS NULL AND a.publish_at <= $1
+		  AND (a.send_email = TRUE OR a.send_sms = TRUE)
+		  AND (a.expires_at IS NULL OR a.expires_at > $1)
+		ORDER BY a.publish_at, a.id`, now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range due {
+		ok, err := sendAnnouncement(&due[i])
+		if err != nil {
+			return sent, fmt.Errorf("announcement %d: %v", due[i].ID, err)
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// sendAnnouncement claims an announcement and queues its notifications in one transaction, so
+// each is sent once even if several servers run the sender. It returns false if another
+// server claimed it first.
+func sendAnnouncement(a *Announcement) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE announcements SET notified_at = NOW() WHERE id = $1 AND notified_at IS NULL", a.ID)
+	if err != nil {
+		return false, err
+	}
+	if err := requireAffected(result); err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	recipients, err := announcementRecipients(tx, a.PropertyID)
+	if err != nil {
+		return false, err
+	}
+
+	subject := a.Title
+	if a.PropertyName.Valid {
+		subject = a.PropertyName.String + ": " + a.Title
+	}
+	payload := map[string]interface{}{
+		"announcement_id": a.ID,
+		"subject":         subject,
+		"body":            a.Body,
+	}
+	// SMS messages have no subject line, so the title leads the text
+	smsPayload := map[string]interface{}{
+		"announcement_id": a.ID,
+		"body":            subject + "\n" + a.Body,
+	}
+	for _, r := range recipients {
+		if a.SendEmail {
+			err := EnqueueOutboxMessage(tx, &OutboxMessage{
+				Channel:     "email",
+				EventType:   "announcement.published",
+				Destination: r.Email,
+				Payload:     payload,
+			})
+			if err != nil {
+				return false, err
+			}
+		}
+		if a.SendSMS && r.Phone.Valid && r.Phone.String != "" {
+			err := EnqueueOutboxMessage(tx, &OutboxMessage{
+				Channel:     "sms",
+				EventType:   "announcement.published",
+				Destination: r.Phone.String,
+				Payload:     smsPayload,
+			})
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnouncementValidate(t *testing.T) {
+	now := time.Now()
+	a := &Announcement{Title: " Water shutoff ", Body: "Tuesday 9-12", PublishAt: now}
+	require.NoError(t, a.Validate())
+	assert.Equal(t, "Water shutoff", a.Title)
+
+	a.ExpiresAt = sql.NullTime{Time: now.Add(-time.Hour), Valid: true}
+	assert.Equal(t, ErrInvalidAnnouncement, a.Validate())
+
+	assert.Equal(t, ErrInvalidAnnouncement, (&Announcement{Title: "Notice", PublishAt: now}).Validate())
+}
+
+func TestSendDueAnnouncements(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	columns := []string{"id", "property_id", "name", "title", "body", "send_email", "send_sms",
+		"publish_at", "expires_at", "notified_at", "created_by", "created_at", "updated_at"}
+	mock.ExpectQuery(`WHERE a.notified_at IS NULL AND a.publish_at <= \$1`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 4, "Maple Court", "Water shutoff", "Tuesday 9-12", true, true, now, nil, nil, nil, now, now).
+			AddRow(2, nil, nil, "Office closed", "Holiday", true, false, now, nil, nil, nil, now, now))
+
+	// The first announcement goes by email to both tenants and by text to the one with a phone
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE announcements SET notified_at = NOW\(\)`).WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT DISTINCT t.email, t.phone_number`).WithArgs(int32(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"email", "phone_number"}).
+			AddRow("a@example.com", "+15550100").
+			AddRow("b@example.com", nil))
+	outboxColumns := []string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}
+	for _, channel := range []string{"email", "sms", "email"} {
+		mock.ExpectQuery(`INSERT INTO outbox_messages`).WithArgs(channel, "announcement.published",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows(outboxColumns).AddRow(1, "pending", 0, now, now, now))
+	}
+	mock.ExpectCommit()
+
+	// Another server already sent the second one
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE announcements SET notified_at = NOW\(\)`).WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	sent, err := SendDueAnnouncements(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// APIKeyPrefix is prepended to every generated key so leaked keys are easy to identify
+const APIKeyPrefix = "pmk_"
+
+// APIKey represents a key used by integrations to call the API on behalf of a user
+type APIKey struct {
+	ID         int          `json:"id"`
+	UserID     int          `json:"user_id"`
+	Name       string       `json:"name"`
+	KeyPrefix  string       `json:"key_prefix"`
+	KeyHash    string       `json:"-"` // Never expose in JSON
+	LastUsedAt sql.NullTime `json:"last_used_at,omitempty"`
+	RevokedAt  sql.NullTime `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 hash stored for a key
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateAPIKey generates a new key for a user and returns the plaintext key, which is not stored
+func CreateAPIKey(userID int, name string) (string, *APIKey, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", nil, err
+	}
+	plaintext := APIKeyPrefix + hex.EncodeToString(bytes)
+
+	key := &APIKey{
+		UserID:    userID,
+		Name:      name,
+		KeyPrefix: plaintext[:len(APIKeyPrefix)+8],
+		KeyHash:   HashAPIKey(plaintext),
+	}
+
+	query := `
+		INSERT INTO api_keys (user_id, name, key_prefix, key_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := db.DB.QueryRow(query, key.UserID, key.Name, key.KeyPrefix, key.KeyHash).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return plaintext, key, nil
+}
+
+// GetAPIKeyByToken retrieves an active (non-revoked) key by its plaintext value
+func GetAPIKeyByToken(token string) (*APIKey, error) {
+	key := &APIKey{}
+	query := `
+		SELECT id, user_id, name, key_prefix, key_hash, last_used_at, revoked_at, created_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`
+
+	err := db.DB.QueryRow(query, HashAPIKey(token)).Scan(&key.ID, &key.UserID, &key.Name,
+		&key.KeyPrefix, &key.KeyHash, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// GetAPIKeysByUser retrieves all keys belonging to a user, including revoked ones
+func GetAPIKeysByUser(userID int) ([]APIKey, error) {
+	query := `
+		SELECT id, user_id, name, key_prefix, key_hash, last_used_at, revoked_at, created_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := db.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var key APIKey
+		err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.KeyPrefix, &key.KeyHash,
+			&key.LastUsedAt, &key.RevokedAt, &key.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of a user's keys. It returns sql.ErrNoRows if no active key matched.
+func RevokeAPIKey(id, userID int) error {
+	result, err := db.DB.Exec(`
+		UPDATE api_keys SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TouchAPIKey records that a key was just used
+func TouchAPIKey(id int) error {
+	_, err := db.DB.Exec("UPDATE api_keys SET last_used_at = NOW() WHERE id = $1", id)
+	return err
+}
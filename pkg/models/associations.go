@@ -0,0 +1,1037 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Property operating modes. Rental properties lease units to tenants; association (HOA/condo)
+// properties assess dues on the owners of their units.
+const (
+	OperatingModeRental      = "rental"
+	OperatingModeAssociation = "association"
+)
+
+var (
+	// ErrInvalidOperatingMode is returned for an operating mode other than rental or association
+	ErrInvalidOperatingMode = errors.New("operating mode must be rental or association")
+	// ErrOperatingModeInUse is returned when switching a property's mode would orphan its active
+	// leases or current owners
+	ErrOperatingModeInUse = errors.New("the property has active leases or current owners under its present operating mode")
+	// ErrNotAssociation is returned for association records on a property in rental mode
+	ErrNotAssociation = errors.New("the property is not in association mode")
+	// ErrAssociationProperty is returned when leasing a unit of a property in association mode
+	ErrAssociationProperty = errors.New("units of an association are owned, not leased")
+	// ErrInvalidOwner is returned when an owner has no name or ends ownership before it starts
+	ErrInvalidOwner = errors.New("an owner needs a name, and ownership cannot end before it starts")
+	// ErrUnitOwned is returned when adding an owner to a unit that already has a current owner
+	ErrUnitOwned = errors.New("the unit already has a current owner; end their ownership first")
+	// ErrInvalidAssessment is returned for an assessment without a description, positive amount
+	// or known frequency, or a recurring one starting after the 28th
+	ErrInvalidAssessment = errors.New("an assessment needs a description, a positive amount and a frequency of monthly, quarterly, annual or one_time; recurring assessments must start on or before the 28th")
+	// ErrInvalidViolation is returned when a violation has no category or description
+	ErrInvalidViolation = errors.New("a violation needs a category and description")
+	// ErrViolationClosed is returned when changing a violation that was cured or dismissed
+	ErrViolationClosed = errors.New("the violation has already been cured or dismissed")
+	// ErrInvalidFine is returned for a fine without a positive amount, or on a violation with no owner
+	ErrInvalidFine = errors.New("a fine needs a positive amount and a violation with an owner")
+	// ErrInvalidAssociationPayment is returned for a payment without a positive amount
+	ErrInvalidAssociationPayment = errors.New("a payment needs an amount greater than zero")
+)
+
+// Lease fee types of the charges to an association owner's account
+const (
+	FeeTypeDues = "dues"
+	FeeTypeFine = "fine"
+)
+
+// Assessment frequencies
+const (
+	FrequencyMonthly   = "monthly"
+	FrequencyQuarterly = "quarterly"
+	FrequencyAnnual    = "annual"
+	FrequencyOneTime   = "one_time"
+)
+
+var assessmentFrequencyMonths = map[string]int{
+	FrequencyMonthly:   1,
+	FrequencyQuarterly: 3,
+	FrequencyAnnual:    12,
+	FrequencyOneTime:   0,
+}
+
+// AssociationOwner is the owner account of a unit in an association. Balance is charges less
+// payments.
+type AssociationOwner struct {
+	ID             int            `json:"id"`
+	UnitID         int            `json:"unit_id"`
+	UnitNumber     sql.NullString `json:"unit_number,omitempty"`
+	PropertyID     int            `json:"property_id"`
+	PropertyName   string         `json:"property_name"`
+	Name           string         `json:"name"`
+	Email          sql.NullString `json:"email,omitempty"`
+	Phone          sql.NullString `json:"phone,omitempty"`
+	MailingAddress sql.NullString `json:"mailing_address,omitempty"`
+	OwnershipStart time.Time      `json:"ownership_start"`
+	OwnershipEnd   sql.NullTime   `json:"ownership_end,omitempty"`
+	Balance        float64        `json:"balance"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// DuesAssessment levies dues on one unit, or every unit of the property when UnitID is null
+type DuesAssessment struct {
+	ID          int            `json:"id"`
+	PropertyID  int            `json:"property_id"`
+	UnitID      sql.NullInt32  `json:"unit_id,omitempty"`
+	Description string         `json:"description"`
+	Amount      float64        `json:"amount"`
+	Frequency   string         `json:"frequency"`
+	StartDate   time.Time      `json:"start_date"`
+	EndDate     sql.NullTime   `json:"end_date,omitempty"`
+	CreatedBy   sql.NullInt32  `json:"created_by,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UnitNumber  sql.NullString `json:"unit_number,omitempty"`
+}
+
+// Validate checks the assessment's amount, frequency and schedule
+func (a *DuesAssessment) Validate() error {
+	a.Description = strings.TrimSpace(a.Description)
+	months, ok := assessmentFrequencyMonths[a.Frequency]
+	if a.Description == "" || a.Amount <= 0 || !ok {
+		return ErrInvalidAssessment
+	}
+	// Recurring dues fall on the same day of every period, which every month must have
+	if months > 0 && a.StartDate.Day() > 28 {
+		return ErrInvalidAssessment
+	}
+	if a.EndDate.Valid && a.EndDate.Time.Before(a.StartDate) {
+		return ErrInvalidAssessment
+	}
+	return nil
+}
+
+// DueDates returns the dates the assessment falls due on or before through
+func (a *DuesAssessment) DueDates(through time.Time) []time.Time {
+	last := through
+	if a.EndDate.Valid && a.EndDate.Time.Before(last) {
+		last = a.EndDate.Time
+	}
+
+	months := assessmentFrequencyMonths[a.Frequency]
+	var dates []time.Time
+	for n := 0; ; n++ {
+		due := a.StartDate.AddDate(0, n*months, 0)
+		if due.After(last) {
+			break
+		}
+		dates = append(dates, due)
+		if months == 0 {
+			break
+		}
+	}
+	return dates
+}
+
+// AssociationViolation is a breach of the association's rules by a unit's owner
+type AssociationViolation struct {
+	ID              int            `json:"id"`
+	UnitID          int            `json:"unit_id"`
+	UnitNumber      sql.NullString `json:"unit_number,omitempty"`
+	PropertyID      int            `json:"property_id"`
+	PropertyName    string         `json:"property_name"`
+	OwnerID         sql.NullInt32  `json:"owner_id,omitempty"`
+	OwnerName       sql.NullString `json:"owner_name,omitempty"`
+	Category        string         `json:"category"`
+	Description     string         `json:"description"`
+	ObservedOn      time.Time      `json:"observed_on"`
+	CureBy          sql.NullTime   `json:"cure_by,omitempty"`
+	Status          string         `json:"status"` // open, cured, fined or dismissed
+	ResolutionNotes sql.NullString `json:"resolution_notes,omitempty"`
+	ResolvedAt      sql.NullTime   `json:"resolved_at,omitempty"`
+	FinesTotal      float64        `json:"fines_total"`
+	CreatedBy       sql.NullInt32  `json:"created_by,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// LedgerEntry is a line of an owner's ledger. Charges are positive and payments negative.
+type LedgerEntry struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"` // dues, fine, payment or adjustment
+	ReferenceID int       `json:"reference_id"`
+	Description string    `json:"description"`
+	Amount      float64   `json:"amount"`
+	Balance     float64   `json:"balance"`
+}
+
+// GetPropertyOperatingMode returns whether a property is run as a rental or an association
+func GetPropertyOperatingMode(propertyID int) (string, error) {
+	var mode string
+	err := db.DB.QueryRow("SELECT operating_mode FROM properties WHERE id = $1", propertyID).Scan(&mode)
+	return mode, err
+}
+
+// SetPropertyOperatingMode switches a property between rental and association mode. It returns
+// ErrOperatingModeInUse while the property has active leases (switching to association) or
+// current owners (switching to rental).
+func SetPropertyOperatingMode(propertyID int, mode string) error {
+	if mode != OperatingModeRental && mode != OperatingModeAssociation {
+		return ErrInvalidOperatingMode
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	if err := tx.QueryRow("SELECT operating_mode FROM properties WHERE id = $1", propertyID).Scan(&current); err != nil {
+		return err
+	}
+	if current == mode {
+		return nil
+	}
+
+	inUse := `
+		SELECT COUNT(*) FROM leases l JOIN property_units pu ON pu.id = l.unit_id
+		WHERE pu.property_id = $1 AND l.status = 'active'`
+	if mode == OperatingModeRental {
+		inUse = `
+			SELECT COUNT(*) FROM association_owners ao JOIN property_units pu ON pu.id = ao.unit_id
+			WHERE pu.property_id = $1 AND ao.ownership_end IS NULL`
+	}
+	var count int
+	if err := tx.QueryRow(inUse, propertyID).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrOperatingModeInUse
+	}
+
+	if _, err := tx.Exec("UPDATE properties SET operating_mode = $2, updated_at = NOW() WHERE id = $1", propertyID, mode); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// unitOperatingMode returns the property and operating mode of a unit
+func unitOperatingMode(q Querier, unitID int) (int, string, error) {
+	var propertyID int
+	var mode string
+	err := q.QueryRow(`
+		SELECT p.id, p.operating_mode
+		FROM property_units pu JOIN properties p ON p.id = pu.property_id
+		WHERE pu.id = $1`, unitID).Scan(&propertyID, &mode)
+	return propertyID, mode, err
+}
+
+// requireAssociationUnit returns the unit's property, or ErrNotAssociation if it is a rental
+func requireAssociationUnit(q Querier, unitID int) (int, error) {
+	propertyID, mode, err := unitOperatingMode(q, unitID)
+	if err != nil {
+		return 0, err
+	}
+	if mode != OperatingModeAssociation {
+		return 0, ErrNotAssociation
+	}
+	return propertyID, nil
+}
+
+const associationOwnerColumns = `ao.id, ao.unit_id, pu.unit_number, p.id, p.name, ao.name, ao.email, ao.phone,
+	ao.mailing_address, ao.ownership_start, ao.ownership_end,
+	COALESCE((SELECT SUM(f.amount) FROM lease_fees f WHERE f.association_owner_id = ao.id), 0) -
+	COALESCE((SELECT SUM(pay.amount) FROM payments pay
+	          WHERE pay.association_owner_id = ao.id AND pay.status = 'completed'), 0),
+	ao.created_at, ao.updated_at`
+
+const associationOwnerFrom = `
+	FROM association_owners ao
+	JOIN property_units pu ON pu.id = ao.unit_id
+	JOIN properties p ON p.id = pu.property_id`
+
+func scanAssociationOwner(row interface{ Scan(...interface{}) error }) (AssociationOwner, error) {
+	var o AssociationOwner
+	err := row.Scan(&o.ID, &o.UnitID, &o.UnitNumber, &o.PropertyID, &o.PropertyName, &o.Name, &o.Email, &o.Phone,
+		&o.MailingAddress, &o.OwnershipStart, &o.OwnershipEnd, &o.Balance, &o.CreatedAt, &o.UpdatedAt)
+	o.Balance = math.Round(o.Balance*100) / 100
+	return o, err
+}
+
+// CreateAssociationOwner adds the owner of a unit. It returns sql.ErrNoRows if the unit does not
+// exist and ErrUnitOwned if the unit still has a current owner.
+func CreateAssociationOwner(o *AssociationOwner) error {
+	o.Name = strings.TrimSpace(o.Name)
+	if o.Name == "" || (o.OwnershipEnd.Valid && o.OwnershipEnd.Time.Before(o.OwnershipStart)) {
+		return ErrInvalidOwner
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := requireAssociationUnit(tx, o.UnitID); err != nil {
+		return err
+	}
+	var current int
+	err = tx.QueryRow("SELECT COUNT(*) FROM association_owners WHERE unit_id = $1 AND ownership_end IS NULL", o.UnitID).
+		Scan(&current)
+	if err != nil {
+		return err
+	}
+	if current > 0 && !o.OwnershipEnd.Valid {
+		return ErrUnitOwned
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO association_owners (unit_id, name, email, phone, mailing_address, ownership_start, ownership_end)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`,
+		o.UnitID, o.Name, o.Email, o.Phone, o.MailingAddress, o.OwnershipStart, o.OwnershipEnd).
+		Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateAssociationOwner updates an owner's contact details and the end of their ownership
+func UpdateAssociationOwner(o *AssociationOwner) error {
+	o.Name = strings.TrimSpace(o.Name)
+	if o.Name == "" {
+		return ErrInvalidOwner
+	}
+
+	var start time.Time
+	var unitID int
+	err := db.DB.QueryRow("SELECT unit_id, ownership_start FROM association_owners WHERE id = $1", o.ID).Scan(&unitID, &start)
+	if err != nil {
+		return err
+	}
+	if o.OwnershipEnd.Valid && o.OwnershipEnd.Time.Before(start) {
+		return ErrInvalidOwner
+	}
+	if !o.OwnershipEnd.Valid {
+		var others int
+		err := db.DB.QueryRow(`
+			SELECT COUNT(*) FROM association_owners WHERE unit_id = $1 AND id <> $2 AND ownership_end IS NULL`,
+			unitID, o.ID).Scan(&others)
+		if err != nil {
+			return err
+		}
+		if others > 0 {
+			return ErrUnitOwned
+		}
+	}
+
+	_, err = db.DB.Exec(`
+		UPDATE association_owners
+		SET name = $2, email = $3, phone = $4, mailing_address = $5, ownership_end = $6, updated_at = NOW()
+		WHERE id = $1`, o.ID, o.Name, o.Email, o.Phone, o.MailingAddress, o.OwnershipEnd)
+	return err
+}
+
+// GetAssociationOwner retrieves an owner with their balance
+func GetAssociationOwner(id int) (*AssociationOwner, error) {
+	o, err := scanAssociationOwner(db.DB.QueryRow("SELECT "+associationOwnerColumns+associationOwnerFrom+" WHERE ao.id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// GetAssociationOwnerPropertyID returns the property of the unit an owner holds
+func GetAssociationOwnerPropertyID(id int) (int, error) {
+	var propertyID int
+	err := db.DB.QueryRow(`
+		SELECT pu.property_id FROM association_owners ao JOIN property_units pu ON pu.id = ao.unit_id
+		WHERE ao.id = $1`, id).Scan(&propertyID)
+	return propertyID, err
+}
+
+// GetAssociationOwners returns the owners of the units of the associations in properties.
+// Former owners are included only with includeFormer.
+func GetAssociationOwners(properties PropertyFilter, includeFormer bool) ([]AssociationOwner, error) {
+	scope, args := properties.condition("p.id", []interface{}{includeFormer})
+	rows, err := db.DB.Query("SELECT "+associationOwnerColumns+associationOwnerFrom+`
+		WHERE ($1 OR ao.ownership_end IS NULL)`+scope+`
+		ORDER BY p.name, pu.unit_number, ao.ownership_start`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []AssociationOwner
+	for rows.Next() {
+		o, err := scanAssociationOwner(rows)
+		if err != nil {
+			return nil, err
+		}
+		owners = append(owners, o)
+	}
+	return owners, rows.Err()
+}
+
+// CreateDuesAssessment levies dues on an association's units. Charges are posted to owners'
+// ledgers by PostDueAssessments as each due date arrives.
+func CreateDuesAssessment(a *DuesAssessment) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+
+	var mode string
+	if err := db.DB.QueryRow("SELECT operating_mode FROM properties WHERE id = $1", a.PropertyID).Scan(&mode); err != nil {
+		return err
+	}
+	if mode != OperatingModeAssociation {
+		return ErrNotAssociation
+	}
+	if a.UnitID.Valid {
+		propertyID, _, err := unitOperatingMode(db.DB, int(a.UnitID.Int32))
+		if err != nil {
+			return err
+		}
+		if propertyID != a.PropertyID {
+			return sql.ErrNoRows
+		}
+	}
+
+	return db.DB.QueryRow(`
+		INSERT INTO dues_assessments (property_id, unit_id, description, amount, frequency, start_date, end_date, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`,
+		a.PropertyID, a.UnitID, a.Description, a.Amount, a.Frequency, a.StartDate, a.EndDate, a.CreatedBy).
+		Scan(&a.ID, &a.CreatedAt)
+}
+
+// EndDuesAssessment stops an assessment after endDate. Charges already posted are kept.
+func EndDuesAssessment(id int, endDate time.Time) error {
+	result, err := db.DB.Exec("UPDATE dues_assessments SET end_date = $2 WHERE id = $1 AND start_date <= $2", id, endDate)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+const duesAssessmentColumns = `da.id, da.property_id, da.unit_id, da.description, da.amount, da.frequency,
+	da.start_date, da.end_date, da.created_by, da.created_at, pu.unit_number`
+
+func scanDuesAssessment(row interface{ Scan(...interface{}) error }) (DuesAssessment, error) {
+	var a DuesAssessment
+	err := row.Scan(&a.ID, &a.PropertyID, &a.UnitID, &a.Description, &a.Amount, &a.Frequency,
+		&a.StartDate, &a.EndDate, &a.CreatedBy, &a.CreatedAt, &a.UnitNumber)
+	return a, err
+}
+
+func queryDuesAssessments(query string, args ...interface{}) ([]DuesAssessment, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assessments []DuesAssessment
+	for rows.Next() {
+		a, err := scanDuesAssessment(rows)
+		if err != nil {
+			return nil, err
+		}
+		assessments = append(assessments, a)
+	}
+	return assessments, rows.Err()
+}
+
+// GetDuesAssessments returns the assessments of the associations in properties
+func GetDuesAssessments(properties PropertyFilter) ([]DuesAssessment, error) {
+	scope, args := properties.condition("da.property_id", nil)
+	return queryDuesAssessments(`
+		SELECT `+duesAssessmentColumns+`
+		FROM dues_assessments da
+		LEFT JOIN property_units pu ON pu.id = da.unit_id
+		WHERE 1 = 1`+scope+`
+		ORDER BY da.property_id, da.start_date, da.id`, args...)
+}
+
+// GetDuesAssessmentPropertyID returns the property an assessment was levied on
+func GetDuesAssessmentPropertyID(id int) (int, error) {
+	var propertyID int
+	err := db.DB.QueryRow("SELECT property_id FROM dues_assessments WHERE id = $1", id).Scan(&propertyID)
+	return propertyID, err
+}
+
+// PostDueAssessments posts a dues charge to each owner for every assessment date that has
+// arrived by now while they owned the unit, and returns the number of charges posted. A date
+// arrives at midnight in the property's time zone when it has one. Posting is idempotent, so
+// missed runs catch up. Dates in locked accounting periods are skipped.
+func PostDueAssessments(now time.Time) (int, error) {
+	timezones, err := GetPropertyTimezones()
+	if err != nil {
+		return 0, err
+	}
+	// Local dates run up to a day ahead of UTC
+	assessments, err := queryDuesAssessments(`
+		SELECT `+duesAssessmentColumns+`
+		FROM dues_assessments da
+		JOIN properties p ON p.id = da.property_id
+		LEFT JOIN property_units pu ON pu.id = da.unit_id
+		WHERE p.operating_mode = 'association' AND da.start_date <= $1
+		ORDER BY da.id`, now.AddDate(0, 0, 1))
+	if err != nil {
+		return 0, err
+	}
+
+	posted := 0
+	for _, a := range assessments {
+		through := now
+		if loc, ok := timezones[a.PropertyID]; ok {
+			through = LocalDate(now, loc)
+		}
+		n, err := postAssessment(a, through)
+		posted += n
+		if err != nil {
+			return posted, err
+		}
+	}
+	return posted, nil
+}
+
+// postAssessment posts one assessment's charges due on or before through within a transaction
+func postAssessment(a DuesAssessment, through time.Time) (int, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	type ownership struct {
+		id         int
+		start      time.Time
+		end        sql.NullTime
+		unitNumber sql.NullString
+	}
+	rows, err := tx.Query(`
+		SELECT ao.id, ao.ownership_start, ao.ownership_end, pu.unit_number
+		FROM association_owners ao
+		JOIN property_units pu ON pu.id = ao.unit_id
+		WHERE pu.property_id = $1 AND ($2 = 0 OR ao.unit_id = $2)`, a.PropertyID, a.UnitID.Int32)
+	if err != nil {
+		return 0, err
+	}
+	var owners []ownership
+	for rows.Next() {
+		var o ownership
+		if err := rows.Scan(&o.id, &o.start, &o.end, &o.unitNumber); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		owners = append(owners, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	posted := 0
+	for _, due := range a.DueDates(through) {
+		locked, err := isPropertyPeriodLocked(tx, a.PropertyID, due)
+		if err != nil {
+			return 0, err
+		}
+		if locked {
+			continue
+		}
+		for _, o := range owners {
+			if due.Before(o.start) || (o.end.Valid && due.After(o.end.Time)) {
+				continue
+			}
+			err := insertLeaseFee(tx, &LeaseFee{
+				AssociationOwnerID: sql.NullInt32{Int32: int32(o.id), Valid: true},
+				FeeType:            FeeTypeDues,
+				AssessmentID:       sql.NullInt32{Int32: int32(a.ID), Valid: true},
+				Description:        a.Description,
+				Amount:             a.Amount,
+				FeeDate:            due,
+			})
+			if err == sql.ErrNoRows {
+				continue // Posted by an earlier run
+			}
+			if err != nil {
+				return 0, err
+			}
+			posted++
+		}
+	}
+	return posted, tx.Commit()
+}
+
+// ownerPropertyID returns the property of an owner's unit
+func ownerPropertyID(q Querier, ownerID int) (int, error) {
+	var propertyID int
+	err := q.QueryRow(`
+		SELECT pu.property_id FROM association_owners ao JOIN property_units pu ON pu.id = ao.unit_id
+		WHERE ao.id = $1`, ownerID).Scan(&propertyID)
+	return propertyID, err
+}
+
+// RecordAssociationPayment records an owner's payment in the payments ledger. It returns
+// sql.ErrNoRows if the owner does not exist and ErrPeriodLocked if the payment date falls in a
+// locked period of the owner's property.
+func RecordAssociationPayment(ownerID int, p *Payment) error {
+	if p.Amount <= 0 {
+		return ErrInvalidAssociationPayment
+	}
+	p.LeaseID = 0
+	p.AssociationOwnerID = sql.NullInt32{Int32: int32(ownerID), Valid: true}
+	p.Status = "completed"
+	p.AdjustsPaymentID, p.AdjustmentReason = sql.NullInt32{}, sql.NullString{}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertPayment(tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetOwnerLedger returns an owner's charges and payments in date order with a running balance.
+// It returns sql.ErrNoRows if the owner does not exist.
+func GetOwnerLedger(ownerID int) ([]LedgerEntry, error) {
+	var exists int
+	if err := db.DB.QueryRow("SELECT 1 FROM association_owners WHERE id = $1", ownerID).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT fee_date, fee_type, id, description, amount FROM lease_fees WHERE association_owner_id = $1
+		UNION ALL
+		SELECT payment_date, CASE WHEN adjusts_payment_id IS NULL THEN 'payment' ELSE 'adjustment' END, id,
+			   COALESCE(adjustment_reason, payment_method, 'Payment'), -amount
+		FROM payments WHERE association_owner_id = $1 AND status = 'completed'`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LedgerEntry{}
+	for rows.Next() {
+		var e LedgerEntry
+		if err := rows.Scan(&e.Date, &e.Type, &e.ReferenceID, &e.Description, &e.Amount); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Charges before payments on the same day, so a payment never shows as a credit it is not
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].Date.Equal(entries[j].Date) {
+			return entries[i].Date.Before(entries[j].Date)
+		}
+		return entries[i].Amount > 0 && entries[j].Amount < 0
+	})
+	balance := 0.0
+	for i := range entries {
+		balance += entries[i].Amount
+		entries[i].Balance = math.Round(balance*100) / 100
+	}
+	return entries, nil
+}
+
+const associationViolationColumns = `v.id, v.unit_id, pu.unit_number, p.id, p.name, v.owner_id, ao.name, v.category,
+	v.description, v.observed_on, v.cure_by, v.status, v.resolution_notes, v.resolved_at,
+	COALESCE((SELECT SUM(f.amount) FROM lease_fees f WHERE f.violation_id = v.id), 0),
+	v.created_by, v.created_at, v.updated_at`
+
+const associationViolationFrom = `
+	FROM association_violations v
+	JOIN property_units pu ON pu.id = v.unit_id
+	JOIN properties p ON p.id = pu.property_id
+	LEFT JOIN association_owners ao ON ao.id = v.owner_id`
+
+func scanAssociationViolation(row interface{ Scan(...interface{}) error }) (AssociationViolation, error) {
+	var v AssociationViolation
+	err := row.Scan(&v.ID, &v.UnitID, &v.UnitNumber, &v.PropertyID, &v.PropertyName, &v.OwnerID, &v.OwnerName,
+		&v.Category, &v.Description, &v.ObservedOn, &v.CureBy, &v.Status, &v.ResolutionNotes, &v.ResolvedAt,
+		&v.FinesTotal, &v.CreatedBy, &v.CreatedAt, &v.UpdatedAt)
+	return v, err
+}
+
+// CreateAssociationViolation records a violation against a unit. Without an owner it is charged
+// to the unit's current owner, if any.
+func CreateAssociationViolation(v *AssociationViolation) error {
+	v.Category = strings.TrimSpace(v.Category)
+	v.Description = strings.TrimSpace(v.Description)
+	if v.Category == "" || v.Description == "" {
+		return ErrInvalidViolation
+	}
+
+	if _, err := requireAssociationUnit(db.DB, v.UnitID); err != nil {
+		return err
+	}
+	if !v.OwnerID.Valid {
+		var ownerID int
+		err := db.DB.QueryRow(`
+			SELECT id FROM association_owners WHERE unit_id = $1 AND ownership_end IS NULL
+			ORDER BY ownership_start DESC LIMIT 1`, v.UnitID).Scan(&ownerID)
+		if err == nil {
+			v.OwnerID = sql.NullInt32{Int32: int32(ownerID), Valid: true}
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	v.Status = "open"
+	return db.DB.QueryRow(`
+		INSERT INTO association_violations (unit_id, owner_id, category, description, observed_on, cure_by, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`,
+		v.UnitID, v.OwnerID, v.Category, v.Description, v.ObservedOn, v.CureBy, v.CreatedBy).
+		Scan(&v.ID, &v.CreatedAt, &v.UpdatedAt)
+}
+
+// GetAssociationViolation retrieves a violation with its fines total
+func GetAssociationViolation(id int) (*AssociationViolation, error) {
+	v, err := scanAssociationViolation(db.DB.QueryRow("SELECT "+associationViolationColumns+associationViolationFrom+" WHERE v.id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetAssociationViolationPropertyID returns the property of the unit a violation was recorded at
+func GetAssociationViolationPropertyID(id int) (int, error) {
+	var propertyID int
+	err := db.DB.QueryRow(`
+		SELECT pu.property_id FROM association_violations v JOIN property_units pu ON pu.id = v.unit_id
+		WHERE v.id = $1`, id).Scan(&propertyID)
+	return propertyID, err
+}
+
+// GetAssociationViolations returns the violations at properties, optionally filtered by status,
+// newest first
+func GetAssociationViolations(properties PropertyFilter, status string) ([]AssociationViolation, error) {
+	scope, args := properties.condition("p.id", []interface{}{status})
+	rows, err := db.DB.Query("SELECT "+associationViolationColumns+associationViolationFrom+`
+		WHERE ($1 = '' OR v.status = $1)`+scope+`
+		ORDER BY v.observed_on DESC, v.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var violations []AssociationViolation
+	for rows.Next() {
+		v, err := scanAssociationViolation(rows)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
+
+// ResolveAssociationViolation closes a violation as cured or dismissed. Fines already charged
+// stay on the owner's ledger.
+func ResolveAssociationViolation(id int, status, notes string) error {
+	if status != "cured" && status != "dismissed" {
+		return ErrInvalidViolation
+	}
+	result, err := db.DB.Exec(`
+		UPDATE association_violations
+		SET status = $2, resolution_notes = $3, resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status IN ('open', 'fined')`, id, status, NullString(notes))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := GetAssociationViolation(id); err != nil {
+			return err
+		}
+		return ErrViolationClosed
+	}
+	return nil
+}
+
+// FineAssociationViolation charges a fine for a violation to its owner's ledger and marks the
+// violation fined. A violation can be fined again while it remains uncured.
+func FineAssociationViolation(violationID int, amount float64, dueDate time.Time) (*LeaseFee, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidFine
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var ownerID sql.NullInt32
+	var status, category string
+	err = tx.QueryRow("SELECT owner_id, status, category FROM association_violations WHERE id = $1", violationID).
+		Scan(&ownerID, &status, &category)
+	if err != nil {
+		return nil, err
+	}
+	if status == "cured" || status == "dismissed" {
+		return nil, ErrViolationClosed
+	}
+	if !ownerID.Valid {
+		return nil, ErrInvalidFine
+	}
+
+	propertyID, err := ownerPropertyID(tx, int(ownerID.Int32))
+	if err != nil {
+		return nil, err
+	}
+	locked, err := isPropertyPeriodLocked(tx, propertyID, dueDate)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		return nil, ErrPeriodLocked
+	}
+
+	charge := &LeaseFee{
+		AssociationOwnerID: ownerID,
+		FeeType:            FeeTypeFine,
+		ViolationID:        sql.NullInt32{Int32: int32(violationID), Valid: true},
+		Description:        "Violation fine: " + category,
+		Amount:             amount,
+		FeeDate:            dueDate,
+	}
+	if err := insertLeaseFee(tx, charge); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE association_violations SET status = 'fined', updated_at = NOW() WHERE id = $1", violationID); err != nil {
+		return nil, err
+	}
+	return charge, tx.Commit()
+}
+
+// DuesDelinquency is an owner's past-due balance, aged by how long the oldest unpaid charges
+// have been due
+type DuesDelinquency struct {
+	OwnerID        int            `json:"owner_id"`
+	OwnerName      string         `json:"owner_name"`
+	PropertyName   string         `json:"property_name"`
+	UnitNumber     sql.NullString `json:"unit_number,omitempty"`
+	Balance        float64        `json:"balance"`
+	PastDue        float64        `json:"past_due"`
+	Days0To30      float64        `json:"days_0_30"`
+	Days31To60     float64        `json:"days_31_60"`
+	Days61To90     float64        `json:"days_61_90"`
+	Over90Days     float64        `json:"over_90_days"`
+	OldestPastDue  sql.NullTime   `json:"oldest_past_due,omitempty"`
+	DaysDelinquent int            `json:"days_delinquent"`
+}
+
+// AgeOwnerBalance applies an owner's payments to their charges oldest first and ages whatever
+// remains unpaid of the charges due by asOf. Charges must be in due date order.
+func AgeOwnerBalance(d *DuesDelinquency, charges []LeaseFee, paid float64, asOf time.Time) {
+	credit := paid
+	total := 0.0
+	for _, c := range charges {
+		total += c.Amount
+		unpaid := c.Amount
+		if credit > 0 {
+			applied := math.Min(credit, unpaid)
+			credit -= applied
+			unpaid -= applied
+		}
+		if unpaid < 0.005 || c.FeeDate.After(asOf) {
+			continue
+		}
+
+		d.PastDue += unpaid
+		if !d.OldestPastDue.Valid {
+			d.OldestPastDue = sql.NullTime{Time: c.FeeDate, Valid: true}
+			d.DaysDelinquent = int(asOf.Sub(c.FeeDate).Hours() / 24)
+		}
+		switch days := int(asOf.Sub(c.FeeDate).Hours() / 24); {
+		case days <= 30:
+			d.Days0To30 += unpaid
+		case days <= 60:
+			d.Days31To60 += unpaid
+		case days <= 90:
+			d.Days61To90 += unpaid
+		default:
+			d.Over90Days += unpaid
+		}
+	}
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	d.Balance = round(total - paid)
+	d.PastDue = round(d.PastDue)
+	d.Days0To30, d.Days31To60 = round(d.Days0To30), round(d.Days31To60)
+	d.Days61To90, d.Over90Days = round(d.Days61To90), round(d.Over90Days)
+}
+
+// GetDuesDelinquencies returns the owners with a past-due balance as of asOf, most delinquent
+// first, at properties
+func GetDuesDelinquencies(properties PropertyFilter, asOf time.Time) ([]DuesDelinquency, error) {
+	scope, args := properties.condition("p.id", nil)
+	rows, err := db.ReadDB().Query(`
+		SELECT ao.id, ao.name, p.name, pu.unit_number,
+			   COALESCE((SELECT SUM(pay.amount) FROM payments pay
+			             WHERE pay.association_owner_id = ao.id AND pay.status = 'completed'), 0),
+			   f.id, f.amount, f.fee_date
+		FROM association_owners ao
+		JOIN property_units pu ON pu.id = ao.unit_id
+		JOIN properties p ON p.id = pu.property_id
+		JOIN lease_fees f ON f.association_owner_id = ao.id
+		WHERE 1 = 1`+scope+`
+		ORDER BY ao.id, f.fee_date, f.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []DuesDelinquency
+	var charges [][]LeaseFee
+	var paid []float64
+	for rows.Next() {
+		var d DuesDelinquency
+		var ownerPaid float64
+		var c LeaseFee
+		err := rows.Scan(&d.OwnerID, &d.OwnerName, &d.PropertyName, &d.UnitNumber, &ownerPaid,
+			&c.ID, &c.Amount, &c.FeeDate)
+		if err != nil {
+			return nil, err
+		}
+		if len(owners) == 0 || owners[len(owners)-1].OwnerID != d.OwnerID {
+			owners = append(owners, d)
+			charges = append(charges, nil)
+			paid = append(paid, ownerPaid)
+		}
+		charges[len(charges)-1] = append(charges[len(charges)-1], c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var delinquencies []DuesDelinquency
+	for i := range owners {
+		AgeOwnerBalance(&owners[i], charges[i], paid[i], asOf)
+		if owners[i].PastDue > 0 {
+			delinquencies = append(delinquencies, owners[i])
+		}
+	}
+	sort.SliceStable(delinquencies, func(i, j int) bool {
+		return delinquencies[i].DaysDelinquent > delinquencies[j].DaysDelinquent
+	})
+	return delinquencies, nil
+}
+
+// generateDuesDelinquencyReport ages owners' past-due dues and fines
+func generateDuesDelinquencyReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	asOf := time.Now()
+	if date, ok := parameters["as_of"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", date); err == nil {
+			asOf = parsed
+		}
+	}
+
+	delinquencies, err := GetDuesDelinquencies(reportProperties(report), asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &ReportData{
+		Headers: []string{"Property", "Unit", "Owner", "Balance", "Past Due", "0-30 Days", "31-60 Days",
+			"61-90 Days", "Over 90 Days", "Days Delinquent"},
+		Rows: []map[string]interface{}{},
+	}
+
+	var totalPastDue, totalOver90 float64
+	for _, d := range delinquencies {
+		data.Rows = append(data.Rows, map[string]interface{}{
+			"Property":        d.PropertyName,
+			"Unit":            d.UnitNumber.String,
+			"Owner":           d.OwnerName,
+			"Balance":         d.Balance,
+			"Past Due":        d.PastDue,
+			"0-30 Days":       d.Days0To30,
+			"31-60 Days":      d.Days31To60,
+			"61-90 Days":      d.Days61To90,
+			"Over 90 Days":    d.Over90Days,
+			"Days Delinquent": d.DaysDelinquent,
+		})
+		totalPastDue += d.PastDue
+		totalOver90 += d.Over90Days
+	}
+
+	data.Summary = map[string]interface{}{
+		"delinquent_owners": len(delinquencies),
+		"total_past_due":    math.Round(totalPastDue*100) / 100,
+		"over_90_days":      math.Round(totalOver90*100) / 100,
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Balance": currencyColumn, "Past Due": currencyColumn, "0-30 Days": currencyColumn,
+		"31-60 Days": currencyColumn, "61-90 Days": currencyColumn, "Over 90 Days": currencyColumn,
+		"Days Delinquent": numberColumn,
+	})
+	return data, nil
+}
+
+// generateAssociationViolationsReport lists violations with their status and fines
+func generateAssociationViolationsReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	status, _ := parameters["status"].(string)
+	violations, err := GetAssociationViolations(reportProperties(report), status)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &ReportData{
+		Headers: []string{"Property", "Unit", "Owner", "Category", "Observed", "Cure By", "Status", "Fines"},
+		Rows:    []map[string]interface{}{},
+	}
+
+	byStatus := map[string]int{}
+	var totalFines float64
+	for _, v := range violations {
+		cureBy := ""
+		if v.CureBy.Valid {
+			cureBy = v.CureBy.Time.Format("2006-01-02")
+		}
+		data.Rows = append(data.Rows, map[string]interface{}{
+			"Property": v.PropertyName,
+			"Unit":     v.UnitNumber.String,
+			"Owner":    v.OwnerName.String,
+			"Category": v.Category,
+			"Observed": v.ObservedOn.Format("2006-01-02"),
+			"Cure By":  cureBy,
+			"Status":   v.Status,
+			"Fines":    v.FinesTotal,
+		})
+		byStatus[v.Status]++
+		totalFines += v.FinesTotal
+	}
+
+	data.Summary = map[string]interface{}{
+		"violation_count": len(violations),
+		"open":            byStatus["open"] + byStatus["fined"],
+		"fines_total":     math.Round(totalFines*100) / 100,
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Observed": dateColumn, "Cure By": dateColumn, "Fines": currencyColumn,
+	})
+	return data, nil
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDuesAssessmentValidate(t *testing.T) {
+	a := &DuesAssessment{Description: " Monthly dues ", Amount: 350, Frequency: FrequencyMonthly, StartDate: day(2026, 1, 1)}
+	require.NoError(t, a.Validate())
+	assert.Equal(t, "Monthly dues", a.Description)
+
+	a.StartDate = day(2026, 1, 31)
+	assert.Equal(t, ErrInvalidAssessment, a.Validate())
+
+	// A one-time special assessment can fall on any day
+	a.Frequency = FrequencyOneTime
+	assert.NoError(t, a.Validate())
+
+	a.Frequency = "weekly"
+	assert.Equal(t, ErrInvalidAssessment, a.Validate())
+}
+
+func TestDuesAssessmentDueDates(t *testing.T) {
+	quarterly := &DuesAssessment{Frequency: FrequencyQuarterly, StartDate: day(2026, 1, 15)}
+	assert.Equal(t, []time.Time{day(2026, 1, 15), day(2026, 4, 15), day(2026, 7, 15)},
+		quarterly.DueDates(day(2026, 9, 30)))
+
+	quarterly.EndDate = sql.NullTime{Time: day(2026, 5, 1), Valid: true}
+	assert.Equal(t, []time.Time{day(2026, 1, 15), day(2026, 4, 15)}, quarterly.DueDates(day(2026, 9, 30)))
+
+	special := &DuesAssessment{Frequency: FrequencyOneTime, StartDate: day(2026, 3, 31)}
+	assert.Equal(t, []time.Time{day(2026, 3, 31)}, special.DueDates(day(2026, 12, 31)))
+	assert.Empty(t, special.DueDates(day(2026, 3, 1)))
+}
+
+func TestAgeOwnerBalanceAppliesPaymentsOldestFirst(t *testing.T) {
+	charges := []LeaseFee{
+		{Amount: 300, FeeDate: day(2026, 6, 1)},
+		{Amount: 300, FeeDate: day(2026, 7, 1)},
+		{Amount: 300, FeeDate: day(2026, 8, 1)},
+		{Amount: 300, FeeDate: day(2026, 9, 1)},
+		{Amount: 300, FeeDate: day(2026, 10, 1)}, // Not yet due
+	}
+
+	var d DuesDelinquency
+	AgeOwnerBalance(&d, charges, 450, day(2026, 9, 15))
+
+	assert.Equal(t, 1050.0, d.Balance)
+	assert.Equal(t, 750.0, d.PastDue)
+	assert.Equal(t, 300.0, d.Days0To30)
+	assert.Equal(t, 300.0, d.Days31To60)
+	assert.Equal(t, 150.0, d.Days61To90)
+	assert.Equal(t, 0.0, d.Over90Days)
+	assert.Equal(t, day(2026, 7, 1), d.OldestPastDue.Time)
+	assert.Equal(t, 76, d.DaysDelinquent)
+}
+
+func TestSetPropertyOperatingModeRejectsActiveLeases(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT operating_mode FROM properties`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"operating_mode"}).AddRow("rental"))
+	mock.ExpectQuery(`FROM leases l JOIN property_units pu`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	assert.Equal(t, ErrOperatingModeInUse, SetPropertyOperatingMode(3, OperatingModeAssociation))
+	assert.Equal(t, ErrInvalidOperatingMode, SetPropertyOperatingMode(3, "coop"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFineAssociationViolation(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	due := day(2026, 10, 1)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT owner_id, status, category FROM association_violations`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"owner_id", "status", "category"}).AddRow(9, "open", "Parking"))
+	mock.ExpectQuery(`SELECT pu.property_id FROM association_owners`).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"property_id"}).AddRow(3))
+	mock.ExpectQuery(`FROM accounting_periods`).WithArgs(3, due).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO lease_fees \(association_owner_id`).
+		WithArgs(sql.NullInt32{Int32: 9, Valid: true}, FeeTypeFine, sql.NullInt32{},
+			sql.NullInt32{Int32: 5, Valid: true}, "Violation fine: Parking", 50.0, due, sql.NullInt32{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(21, time.Now()))
+	mock.ExpectExec(`UPDATE association_violations SET status = 'fined'`).WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	charge, err := FineAssociationViolation(5, 50, due)
+	require.NoError(t, err)
+	assert.Equal(t, 21, charge.ID)
+	assert.Equal(t, int32(9), charge.AssociationOwnerID.Int32)
+
+	// A cured violation cannot be fined
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT owner_id, status, category FROM association_violations`).WithArgs(6).
+		WillReturnRows(sqlmock.NewRows([]string{"owner_id", "status", "category"}).AddRow(9, "cured", "Noise"))
+	mock.ExpectRollback()
+
+	_, err = FineAssociationViolation(6, 50, due)
+	assert.Equal(t, ErrViolationClosed, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordAssociationPaymentPostsToPayments(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	paid := day(2026, 10, 5)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pu.property_id FROM association_owners`).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"property_id"}).AddRow(3))
+	mock.ExpectQuery(`FROM accounting_periods`).WithArgs(3, day(2026, 10, 1)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO payments \(association_owner_id`).
+		WithArgs(sql.NullInt32{Int32: 9, Valid: true}, 300.0, paid, NullString("Check"), NullString("1042"),
+			"completed", sql.NullInt32{}, sql.NullString{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(77, time.Now()))
+	mock.ExpectCommit()
+
+	payment := &Payment{Amount: 300, PaymentDate: paid, PaymentMethod: NullString("Check"), Reference: NullString("1042")}
+	require.NoError(t, RecordAssociationPayment(9, payment))
+	assert.Equal(t, 77, payment.ID)
+	assert.Zero(t, payment.LeaseID)
+
+	assert.Equal(t, ErrInvalidAssociationPayment, RecordAssociationPayment(9, &Payment{Amount: -5}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
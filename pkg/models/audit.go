@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// AuditEntry records an administrative action taken on an entity
+type AuditEntry struct {
+	ID         int                    `json:"id"`
+	ActorID    sql.NullInt32          `json:"actor_id,omitempty"`
+	Action     string                 `json:"action"`
+	EntityType string                 `json:"entity_type"`
+	EntityID   int                    `json:"entity_id"`
+	Reason     sql.NullString         `json:"reason,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// RecordAudit writes an audit entry using the given querier. Pass the caller's *sql.Tx so the
+// entry is only kept if the audited change commits.
+func RecordAudit(q Querier, entry *AuditEntry) error {
+	var details interface{}
+	if entry.Details != nil {
+		data, err := json.Marshal(entry.Details)
+		if err != nil {
+			return err
+		}
+		details = data
+	}
+
+	return q.QueryRow(`
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, reason, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		entry.ActorID, entry.Action, entry.EntityType, entry.EntityID, entry.Reason, details).
+		Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// GetAuditLog returns the audit entries for an entity, newest first
+func GetAuditLog(entityType string, entityID int) ([]AuditEntry, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, actor_id, action, entity_type, entity_id, reason, details, created_at
+		FROM audit_log
+		WHERE entity_type = $1 AND entity_id = $2
+		ORDER BY created_at DESC, id DESC`, entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.EntityType,
+			&entry.EntityID, &entry.Reason, &details, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &entry.Details); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,275 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"gopkg.in/yaml.v3"
+)
+
+// BootstrapSpec declares the organization, admin user, roles, report templates and settings an
+// environment should have. Applying it again converges to the same state, so it can be kept in
+// version control and applied by provisioning tools on every deploy.
+type BootstrapSpec struct {
+	Organization    BootstrapOrganization     `json:"organization" yaml:"organization"`
+	Admin           BootstrapAdmin            `json:"admin" yaml:"admin"`
+	Roles           []BootstrapRole           `json:"roles,omitempty" yaml:"roles"`
+	ReportTemplates []BootstrapReportTemplate `json:"report_templates,omitempty" yaml:"report_templates"`
+	Settings        BootstrapSettings         `json:"settings,omitempty" yaml:"settings"`
+}
+
+// BootstrapOrganization is matched to an existing organization by name
+type BootstrapOrganization struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+// BootstrapAdmin is matched to an existing user by email. Privileged accounts are never linked
+// to an identity by email, so the admin signs in once KeycloakID holds their Keycloak subject.
+type BootstrapAdmin struct {
+	Email      string `json:"email" yaml:"email"`
+	Username   string `json:"username,omitempty" yaml:"username"`
+	FirstName  string `json:"first_name" yaml:"first_name"`
+	LastName   string `json:"last_name" yaml:"last_name"`
+	KeycloakID string `json:"keycloak_id,omitempty" yaml:"keycloak_id"`
+}
+
+// BootstrapRole is created, or updated when a role with the same name exists
+type BootstrapRole struct {
+	Name        string   `json:"name" yaml:"name"`
+	DisplayName string   `json:"display_name" yaml:"display_name"`
+	Description string   `json:"description,omitempty" yaml:"description"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+// BootstrapReportTemplate is a system report template, matched by name
+type BootstrapReportTemplate struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Description string                 `json:"description,omitempty" yaml:"description"`
+	Category    string                 `json:"category" yaml:"category"`
+	Config      map[string]interface{} `json:"config" yaml:"config"`
+}
+
+// BootstrapSettings are organization-wide settings
+type BootstrapSettings struct {
+	Quotas map[string]int64 `json:"quotas,omitempty" yaml:"quotas"` // Monthly usage limits by metric; 0 removes a limit
+}
+
+// BootstrapChange records what applying a spec did to one item
+type BootstrapChange struct {
+	Kind   string `json:"kind"` // 'organization', 'admin', 'role', 'report_template' or 'quota'
+	Name   string `json:"name"`
+	Action string `json:"action"` // 'created' or 'updated'
+}
+
+// BootstrapResult is the outcome of applying a spec
+type BootstrapResult struct {
+	OrganizationID int               `json:"organization_id"`
+	AdminUserID    int               `json:"admin_user_id"`
+	Changes        []BootstrapChange `json:"changes"`
+}
+
+// ParseBootstrapSpec reads a spec from YAML or JSON (JSON is valid YAML). Unknown fields are
+// rejected so typos do not silently leave settings unapplied.
+func ParseBootstrapSpec(data []byte) (*BootstrapSpec, error) {
+	spec := &BootstrapSpec{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap spec: %w", err)
+	}
+	return spec, spec.Validate()
+}
+
+// Validate checks the spec's required fields and fills in defaults
+func (s *BootstrapSpec) Validate() error {
+	s.Organization.Name = strings.TrimSpace(s.Organization.Name)
+	if s.Organization.Name == "" {
+		return fmt.Errorf("organization.name is required")
+	}
+
+	s.Admin.Email = strings.TrimSpace(s.Admin.Email)
+	address, err := mail.ParseAddress(s.Admin.Email)
+	if err != nil || address.Address != s.Admin.Email {
+		return fmt.Errorf("admin.email %q is not a valid email address", s.Admin.Email)
+	}
+	if s.Admin.Username == "" {
+		s.Admin.Username = strings.ToLower(s.Admin.Email[:strings.Index(s.Admin.Email, "@")])
+	}
+	if s.Admin.FirstName == "" || s.Admin.LastName == "" {
+		return fmt.Errorf("admin.first_name and admin.last_name are required")
+	}
+
+	for i, role := range s.Roles {
+		if role.Name == "" || role.DisplayName == "" {
+			return fmt.Errorf("roles[%d]: name and display_name are required", i)
+		}
+	}
+	for i, template := range s.ReportTemplates {
+		if template.Name == "" || template.Category == "" || len(template.Config) == 0 {
+			return fmt.Errorf("report_templates[%d]: name, category and config are required", i)
+		}
+	}
+	for metric, limit := range s.Settings.Quotas {
+		if !ValidUsageMetric(metric) {
+			return fmt.Errorf("settings.quotas: unknown usage metric %q", metric)
+		}
+		if limit < 0 {
+			return fmt.Errorf("settings.quotas.%s must not be negative", metric)
+		}
+	}
+	return nil
+}
+
+// ApplyBootstrap creates or updates everything the spec declares in one transaction. Existing
+// records are matched by natural key (organization name, user email, role name, system template
+// name) and updated in place; nothing outside the spec is removed.
+func ApplyBootstrap(spec *BootstrapSpec) (*BootstrapResult, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &BootstrapResult{Changes: []BootstrapChange{}}
+	record := func(kind, name string, created bool) {
+		action := "updated"
+		if created {
+			action = "created"
+		}
+		result.Changes = append(result.Changes, BootstrapChange{Kind: kind, Name: name, Action: action})
+	}
+
+	// Organization
+	err = tx.QueryRow("SELECT id FROM organizations WHERE name = $1 ORDER BY id LIMIT 1",
+		spec.Organization.Name).Scan(&result.OrganizationID)
+	switch {
+	case err == sql.ErrNoRows:
+		if err := tx.QueryRow("INSERT INTO organizations (name) VALUES ($1) RETURNING id",
+			spec.Organization.Name).Scan(&result.OrganizationID); err != nil {
+			return nil, err
+		}
+		record("organization", spec.Organization.Name, true)
+	case err != nil:
+		return nil, err
+	}
+
+	// Roles come before the admin so a spec may redefine the admin role's permissions
+	for _, role := range spec.Roles {
+		var roleID int
+		if err := tx.QueryRow(`
+			INSERT INTO roles (name, display_name, description, permissions)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (name) DO NOTHING
+			RETURNING id`,
+			role.Name, role.DisplayName, NullString(role.Description), StringArray(role.Permissions)).Scan(&roleID); err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		created := roleID != 0
+		if !created {
+			if _, err := tx.Exec(`
+				UPDATE roles SET display_name = $2, description = $3, permissions = $4, updated_at = NOW()
+				WHERE name = $1`,
+				role.Name, role.DisplayName, NullString(role.Description), StringArray(role.Permissions)); err != nil {
+				return nil, err
+			}
+		}
+		record("role", role.Name, created)
+	}
+
+	// Admin user
+	err = tx.QueryRow("SELECT id FROM users WHERE email = $1", spec.Admin.Email).Scan(&result.AdminUserID)
+	switch {
+	case err == sql.ErrNoRows:
+		if err := tx.QueryRow(`
+			INSERT INTO users (username, email, first_name, last_name, status, organization_id, keycloak_id)
+			VALUES ($1, $2, $3, $4, 'active', $5, $6)
+			RETURNING id`,
+			spec.Admin.Username, spec.Admin.Email, spec.Admin.FirstName, spec.Admin.LastName,
+			result.OrganizationID, NullString(spec.Admin.KeycloakID)).Scan(&result.AdminUserID); err != nil {
+			return nil, err
+		}
+		record("admin", spec.Admin.Email, true)
+	case err != nil:
+		return nil, err
+	default:
+		if _, err := tx.Exec(`
+			UPDATE users SET first_name = $2, last_name = $3, organization_id = $4,
+			                 keycloak_id = COALESCE(keycloak_id, $5), updated_at = NOW()
+			WHERE id = $1`,
+			result.AdminUserID, spec.Admin.FirstName, spec.Admin.LastName, result.OrganizationID,
+			NullString(spec.Admin.KeycloakID)); err != nil {
+			return nil, err
+		}
+		record("admin", spec.Admin.Email, false)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = 'admin'
+		ON CONFLICT (user_id, role_id) DO NOTHING`, result.AdminUserID); err != nil {
+		return nil, err
+	}
+
+	// System report templates
+	for _, template := range spec.ReportTemplates {
+		config, err := json.Marshal(template.Config)
+		if err != nil {
+			return nil, fmt.Errorf("report template %q: %w", template.Name, err)
+		}
+
+		var id int
+		err = tx.QueryRow("SELECT id FROM report_templates WHERE name = $1 AND is_system = TRUE ORDER BY id LIMIT 1",
+			template.Name).Scan(&id)
+		switch {
+		case err == sql.ErrNoRows:
+			if _, err := tx.Exec(`
+				INSERT INTO report_templates (name, description, category, template_config, is_system)
+				VALUES ($1, $2, $3, $4, TRUE)`,
+				template.Name, NullString(template.Description), template.Category, config); err != nil {
+				return nil, err
+			}
+			record("report_template", template.Name, true)
+		case err != nil:
+			return nil, err
+		default:
+			if _, err := tx.Exec(`
+				UPDATE report_templates SET description = $2, category = $3, template_config = $4, updated_at = NOW()
+				WHERE id = $1`,
+				id, NullString(template.Description), template.Category, config); err != nil {
+				return nil, err
+			}
+			record("report_template", template.Name, false)
+		}
+	}
+
+	// Usage quotas
+	for _, metric := range UsageMetrics {
+		limit, ok := spec.Settings.Quotas[metric]
+		if !ok {
+			continue
+		}
+		if limit == 0 {
+			if _, err := tx.Exec("DELETE FROM usage_quotas WHERE organization_id = $1 AND metric = $2",
+				result.OrganizationID, metric); err != nil {
+				return nil, err
+			}
+		} else if _, err := tx.Exec(`
+			INSERT INTO usage_quotas (organization_id, metric, monthly_limit, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (organization_id, metric)
+			DO UPDATE SET monthly_limit = excluded.monthly_limit, updated_at = NOW()`,
+			result.OrganizationID, metric, limit); err != nil {
+			return nil, err
+		}
+		record("quota", metric, false)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bootstrapYAML = `
+organization:
+  name: Acme Rentals
+admin:
+  email: ops@acme.example
+  first_name: Ada
+  last_name: Ops
+roles:
+  - name: leasing_agent
+    display_name: Leasing Agent
+    permissions: [tenants.read, leases.read]
+report_templates:
+  - name: Weekly Vacancy
+    category: operational
+    config:
+      data_source: properties
+      metrics: [vacancy_rate]
+settings:
+  quotas:
+    report_executions: 500
+`
+
+func TestParseBootstrapSpec(t *testing.T) {
+	spec, err := ParseBootstrapSpec([]byte(bootstrapYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Rentals", spec.Organization.Name)
+	assert.Equal(t, "ops", spec.Admin.Username)
+	assert.Equal(t, []string{"tenants.read", "leases.read"}, spec.Roles[0].Permissions)
+	assert.Equal(t, "properties", spec.ReportTemplates[0].Config["data_source"])
+	assert.Equal(t, int64(500), spec.Settings.Quotas["report_executions"])
+
+	// JSON is accepted as well
+	spec, err = ParseBootstrapSpec([]byte(`{"organization": {"name": "Acme"},
+		"admin": {"email": "a@acme.example", "first_name": "A", "last_name": "B"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", spec.Organization.Name)
+
+	_, err = ParseBootstrapSpec([]byte("organisation:\n  name: Typo\n"))
+	assert.Error(t, err)
+
+	_, err = ParseBootstrapSpec([]byte("organization:\n  name: Acme\nadmin:\n  email: not-an-email\n"))
+	assert.ErrorContains(t, err, "admin.email")
+
+	_, err = ParseBootstrapSpec([]byte(bootstrapYAML + "    storage_gigabytes: 5\n"))
+	assert.ErrorContains(t, err, "unknown usage metric")
+}
+
+func TestApplyBootstrapCreatesMissingRecords(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	spec, err := ParseBootstrapSpec([]byte(bootstrapYAML))
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM organizations WHERE name").WithArgs("Acme Rentals").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO organizations").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery("INSERT INTO roles").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE roles").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id FROM users WHERE email").WithArgs("ops@acme.example").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("ops", "ops@acme.example", "Ada", "Ops", 4, sql.NullString{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+	mock.ExpectExec("INSERT INTO user_roles").WithArgs(12).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id FROM report_templates").WithArgs("Weekly Vacancy").WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO report_templates").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO usage_quotas").WithArgs(4, "report_executions", int64(500)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := ApplyBootstrap(spec)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.OrganizationID)
+	assert.Equal(t, 12, result.AdminUserID)
+	assert.Equal(t, []BootstrapChange{
+		{Kind: "organization", Name: "Acme Rentals", Action: "created"},
+		{Kind: "role", Name: "leasing_agent", Action: "updated"},
+		{Kind: "admin", Name: "ops@acme.example", Action: "created"},
+		{Kind: "report_template", Name: "Weekly Vacancy", Action: "created"},
+		{Kind: "quota", Name: "report_executions", Action: "updated"},
+	}, result.Changes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,376 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Package statuses
+const (
+	PackageStatusReceived = "received"
+	PackageStatusPickedUp = "picked_up"
+	PackageStatusReturned = "returned"
+)
+
+// ErrPackageNotWaiting is returned when releasing a package that was already picked up or returned
+var ErrPackageNotWaiting = errors.New("package is not waiting for pickup")
+
+// ErrNoActiveAuthorization is returned when a visitor access code does not match an active authorization
+var ErrNoActiveAuthorization = errors.New("no active visitor authorization")
+
+// BuildingPackage is a package held for a tenant at the front desk or in a locker
+type BuildingPackage struct {
+	ID              int            `json:"id"`
+	PropertyID      int            `json:"property_id"`
+	TenantID        sql.NullInt32  `json:"tenant_id,omitempty"`
+	TenantName      sql.NullString `json:"tenant_name,omitempty"`
+	UnitID          sql.NullInt32  `json:"unit_id,omitempty"`
+	UnitNumber      sql.NullString `json:"unit_number,omitempty"`
+	Carrier         sql.NullString `json:"carrier,omitempty"`
+	TrackingNumber  sql.NullString `json:"tracking_number,omitempty"`
+	Description     sql.NullString `json:"description,omitempty"`
+	StorageLocation sql.NullString `json:"storage_location,omitempty"`
+	Status          string         `json:"status"`
+	ReceivedAt      time.Time      `json:"received_at"`
+	ReceivedBy      sql.NullInt32  `json:"received_by,omitempty"`
+	PickedUpAt      sql.NullTime   `json:"picked_up_at,omitempty"`
+	PickedUpBy      sql.NullString `json:"picked_up_by,omitempty"`
+	ReleasedBy      sql.NullInt32  `json:"released_by,omitempty"`
+}
+
+// VisitorAuthorization pre-authorizes a visitor for a time window
+type VisitorAuthorization struct {
+	ID          int            `json:"id"`
+	PropertyID  int            `json:"property_id"`
+	TenantID    sql.NullInt32  `json:"tenant_id,omitempty"`
+	VisitorName string         `json:"visitor_name"`
+	AccessCode  string         `json:"access_code"`
+	ValidFrom   time.Time      `json:"valid_from"`
+	ValidUntil  time.Time      `json:"valid_until"`
+	Notes       sql.NullString `json:"notes,omitempty"`
+	CreatedBy   sql.NullInt32  `json:"created_by,omitempty"`
+	RevokedAt   sql.NullTime   `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// VisitorLogEntry records a visitor's arrival and departure
+type VisitorLogEntry struct {
+	ID              int           `json:"id"`
+	PropertyID      int           `json:"property_id"`
+	AuthorizationID sql.NullInt32 `json:"authorization_id,omitempty"`
+	TenantID        sql.NullInt32 `json:"tenant_id,omitempty"`
+	VisitorName     string        `json:"visitor_name"`
+	CheckedInAt     time.Time     `json:"checked_in_at"`
+	CheckedOutAt    sql.NullTime  `json:"checked_out_at,omitempty"`
+	LoggedBy        sql.NullInt32 `json:"logged_by,omitempty"`
+}
+
+// ActiveAt reports whether the authorization admits a visitor at t
+func (v *VisitorAuthorization) ActiveAt(t time.Time) bool {
+	return !v.RevokedAt.Valid && !t.Before(v.ValidFrom) && t.Before(v.ValidUntil)
+}
+
+// GenerateAccessCode returns a random six-digit visitor access code
+func GenerateAccessCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// CheckInPackage records a received package and queues a pickup notification to the tenant.
+// When only the unit is known, the package is assigned to the unit's active tenant.
+func CheckInPackage(pkg *BuildingPackage) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !pkg.TenantID.Valid && pkg.UnitID.Valid {
+		err := tx.QueryRow(`
+			SELECT tenant_id FROM leases
+			WHERE unit_id = $1 AND status = 'active'
+			ORDER BY start_date DESC LIMIT 1`, pkg.UnitID).Scan(&pkg.TenantID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	pkg.Status = PackageStatusReceived
+	err = tx.QueryRow(`
+		INSERT INTO packages (property_id, tenant_id, unit_id, carrier, tracking_number, description,
+							  storage_location, status, received_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, received_at`,
+		pkg.PropertyID, pkg.TenantID, pkg.UnitID, pkg.Carrier, pkg.TrackingNumber, pkg.Description,
+		pkg.StorageLocation, pkg.Status, pkg.ReceivedBy).Scan(&pkg.ID, &pkg.ReceivedAt)
+	if err != nil {
+		return err
+	}
+
+	if pkg.TenantID.Valid {
+		if err := notifyPackageReceived(tx, pkg); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// notifyPackageReceived queues email and SMS notifications to the package's tenant
+func notifyPackageReceived(tx *sql.Tx, pkg *BuildingPackage) error {
+	var propertyName, email string
+	var phone sql.NullString
+	err := tx.QueryRow(`
+		SELECT p.name, t.email, t.phone_number
+		FROM tenants t, properties p
+		WHERE t.id = $1 AND p.id = $2`, pkg.TenantID, pkg.PropertyID).Scan(&propertyName, &email, &phone)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("A package has arrived for you at %s", propertyName)
+	if pkg.Carrier.Valid {
+		body += " (" + pkg.Carrier.String + ")"
+	}
+	if pkg.StorageLocation.Valid {
+		body += ". Pick it up from " + pkg.StorageLocation.String
+	}
+	body += "."
+
+	payload := map[string]interface{}{
+		"package_id":  pkg.ID,
+		"property_id": pkg.PropertyID,
+		"subject":     "Package received at " + propertyName,
+		"body":        body,
+	}
+
+	err = EnqueueOutboxMessage(tx, &OutboxMessage{
+		Channel:     "email",
+		EventType:   "package.received",
+		Destination: email,
+		Payload:     payload,
+	})
+	if err != nil || !phone.Valid || phone.String == "" {
+		return err
+	}
+
+	return EnqueueOutboxMessage(tx, &OutboxMessage{
+		Channel:     "sms",
+		EventType:   "package.received",
+		Destination: phone.String,
+		Payload:     payload,
+	})
+}
+
+// ReleasePackage records that a waiting package was collected
+func ReleasePackage(packageID int, pickedUpBy string, releasedBy int) error {
+	result, err := db.DB.Exec(`
+		UPDATE packages
+		SET status = $2, picked_up_at = NOW(), picked_up_by = $3, released_by = $4, updated_at = NOW()
+		WHERE id = $1 AND status = $5`,
+		packageID, PackageStatusPickedUp, NullString(pickedUpBy), releasedBy, PackageStatusReceived)
+	if err != nil {
+		return err
+	}
+
+	if err := requireAffected(result); err != sql.ErrNoRows {
+		return err
+	}
+
+	// Distinguish a missing package from one that was already released
+	var status string
+	if err := db.DB.QueryRow("SELECT status FROM packages WHERE id = $1", packageID).Scan(&status); err != nil {
+		return err
+	}
+	return ErrPackageNotWaiting
+}
+
+// GetPackages retrieves packages, optionally filtered by property, status and tenant (0 or empty for all)
+func GetPackages(propertyID int, status string, tenantID int) ([]BuildingPackage, error) {
+	query := `
+		SELECT pk.id, pk.property_id, pk.tenant_id, t.first_name || ' ' || t.last_name, pk.unit_id,
+			   pu.unit_number, pk.carrier, pk.tracking_number, pk.description, pk.storage_location,
+			   pk.status, pk.received_at, pk.received_by, pk.picked_up_at, pk.picked_up_by, pk.released_by
+		FROM packages pk
+		LEFT JOIN tenants t ON pk.tenant_id = t.id
+		LEFT JOIN property_units pu ON pk.unit_id = pu.id
+		WHERE ($1 = 0 OR pk.property_id = $1) AND ($2 = '' OR pk.status = $2) AND ($3 = 0 OR pk.tenant_id = $3)
+		ORDER BY pk.received_at DESC`
+
+	rows, err := db.DB.Query(query, propertyID, status, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var packages []BuildingPackage
+	for rows.Next() {
+		var pkg BuildingPackage
+		err := rows.Scan(&pkg.ID, &pkg.PropertyID, &pkg.TenantID, &pkg.TenantName, &pkg.UnitID,
+			&pkg.UnitNumber, &pkg.Carrier, &pkg.TrackingNumber, &pkg.Description, &pkg.StorageLocation,
+			&pkg.Status, &pkg.ReceivedAt, &pkg.ReceivedBy, &pkg.PickedUpAt, &pkg.PickedUpBy, &pkg.ReleasedBy)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+	}
+
+	return packages, nil
+}
+
+// CreateVisitorAuthorization pre-authorizes a visitor and assigns a random access code
+func CreateVisitorAuthorization(auth *VisitorAuthorization) error {
+	code, err := GenerateAccessCode()
+	if err != nil {
+		return err
+	}
+	auth.AccessCode = code
+
+	query := `
+		INSERT INTO visitor_authorizations (property_id, tenant_id, visitor_name, access_code,
+											valid_from, valid_until, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`
+
+	return db.DB.QueryRow(query, auth.PropertyID, auth.TenantID, auth.VisitorName, auth.AccessCode,
+		auth.ValidFrom, auth.ValidUntil, auth.Notes, auth.CreatedBy).Scan(&auth.ID, &auth.CreatedAt)
+}
+
+// GetVisitorAuthorization retrieves a single authorization
+func GetVisitorAuthorization(authID int) (*VisitorAuthorization, error) {
+	auths, err := queryVisitorAuthorizations("WHERE id = $1", authID)
+	if err != nil {
+		return nil, err
+	}
+	if len(auths) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &auths[0], nil
+}
+
+// GetVisitorAuthorizations retrieves authorizations that have not yet expired, optionally
+// filtered by property and tenant (0 for all)
+func GetVisitorAuthorizations(propertyID, tenantID int, now time.Time) ([]VisitorAuthorization, error) {
+	return queryVisitorAuthorizations(`
+		WHERE ($1 = 0 OR property_id = $1) AND ($2 = 0 OR tenant_id = $2) AND valid_until > $3
+		ORDER BY valid_from`, propertyID, tenantID, now)
+}
+
+func queryVisitorAuthorizations(where string, args ...interface{}) ([]VisitorAuthorization, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, property_id, tenant_id, visitor_name, access_code, valid_from, valid_until,
+			   notes, created_by, revoked_at, created_at
+		FROM visitor_authorizations `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var auths []VisitorAuthorization
+	for rows.Next() {
+		var auth VisitorAuthorization
+		err := rows.Scan(&auth.ID, &auth.PropertyID, &auth.TenantID, &auth.VisitorName, &auth.AccessCode,
+			&auth.ValidFrom, &auth.ValidUntil, &auth.Notes, &auth.CreatedBy, &auth.RevokedAt, &auth.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, auth)
+	}
+
+	return auths, nil
+}
+
+// RevokeVisitorAuthorization revokes an authorization so its access code no longer works
+func RevokeVisitorAuthorization(authID int) error {
+	result, err := db.DB.Exec(
+		"UPDATE visitor_authorizations SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", authID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// CheckInVisitor records a visitor arrival. With an access code, the visitor must match an
+// authorization active at the property now; without one, the entry is logged as a walk-in.
+func CheckInVisitor(entry *VisitorLogEntry, accessCode string, now time.Time) error {
+	if accessCode != "" {
+		auths, err := queryVisitorAuthorizations(
+			"WHERE property_id = $1 AND access_code = $2", entry.PropertyID, accessCode)
+		if err != nil {
+			return err
+		}
+
+		var match *VisitorAuthorization
+		for i := range auths {
+			if auths[i].ActiveAt(now) {
+				match = &auths[i]
+				break
+			}
+		}
+		if match == nil {
+			return ErrNoActiveAuthorization
+		}
+
+		entry.AuthorizationID = sql.NullInt32{Int32: int32(match.ID), Valid: true}
+		entry.TenantID = match.TenantID
+		if entry.VisitorName == "" {
+			entry.VisitorName = match.VisitorName
+		}
+	}
+
+	query := `
+		INSERT INTO visitor_logs (property_id, authorization_id, tenant_id, visitor_name, checked_in_at, logged_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	entry.CheckedInAt = now
+	return db.DB.QueryRow(query, entry.PropertyID, entry.AuthorizationID, entry.TenantID, entry.VisitorName,
+		entry.CheckedInAt, entry.LoggedBy).Scan(&entry.ID)
+}
+
+// CheckOutVisitor records a visitor's departure
+func CheckOutVisitor(entryID int) error {
+	result, err := db.DB.Exec(
+		"UPDATE visitor_logs SET checked_out_at = NOW() WHERE id = $1 AND checked_out_at IS NULL", entryID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetVisitorLog retrieves visitor arrivals between start and end, optionally filtered by property and tenant (0 for all)
+func GetVisitorLog(propertyID, tenantID int, start, end time.Time) ([]VisitorLogEntry, error) {
+	query := `
+		SELECT id, property_id, authorization_id, tenant_id, visitor_name, checked_in_at, checked_out_at, logged_by
+		FROM visitor_logs
+		WHERE ($1 = 0 OR property_id = $1) AND ($2 = 0 OR tenant_id = $2) AND checked_in_at >= $3 AND checked_in_at < $4
+		ORDER BY checked_in_at DESC`
+
+	rows, err := db.DB.Query(query, propertyID, tenantID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []VisitorLogEntry
+	for rows.Next() {
+		var entry VisitorLogEntry
+		err := rows.Scan(&entry.ID, &entry.PropertyID, &entry.AuthorizationID, &entry.TenantID,
+			&entry.VisitorName, &entry.CheckedInAt, &entry.CheckedOutAt, &entry.LoggedBy)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisitorAuthorizationActiveAt(t *testing.T) {
+	from := time.Date(2024, 6, 1, 18, 0, 0, 0, time.UTC)
+	auth := VisitorAuthorization{ValidFrom: from, ValidUntil: from.Add(4 * time.Hour)}
+
+	assert.False(t, auth.ActiveAt(from.Add(-time.Minute)))
+	assert.True(t, auth.ActiveAt(from))
+	assert.True(t, auth.ActiveAt(from.Add(2*time.Hour)))
+	assert.False(t, auth.ActiveAt(from.Add(4*time.Hour)))
+
+	auth.RevokedAt = sql.NullTime{Time: from, Valid: true}
+	assert.False(t, auth.ActiveAt(from.Add(time.Hour)))
+}
+
+func TestGenerateAccessCode(t *testing.T) {
+	code, err := GenerateAccessCode()
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\d{6}$`, code)
+}
@@ -0,0 +1,942 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Collection stage actions
+const (
+	CollectionActionReminder     = "reminder"
+	CollectionActionFormalNotice = "formal_notice"
+)
+
+// Collection case statuses
+const (
+	CollectionCaseOpen     = "open"
+	CollectionCasePromised = "promised" // Escalation is paused until a promise to pay falls due
+	CollectionCaseResolved = "resolved"
+)
+
+// Promise to pay statuses
+const (
+	PromisePending = "pending"
+	PromiseKept    = "kept"
+	PromiseBroken  = "broken"
+)
+
+var (
+	// ErrCollectionCaseClosed is returned when acting on a case that has been resolved
+	ErrCollectionCaseClosed = errors.New("collection case is resolved")
+	// ErrInvalidCollectionStage is returned for a stage without a name, a positive day count and a known action
+	ErrInvalidCollectionStage = errors.New("stage needs a name, days_past_due of at least 1 and action reminder or formal_notice")
+)
+
+// CollectionStage is a delinquency stage, actioned once per case when the oldest unpaid rent has
+// been past due for DaysPastDue days
+type CollectionStage struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	DaysPastDue int       `json:"days_past_due"`
+	Action      string    `json:"action"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Validate checks the stage can be saved
+func (s *CollectionStage) Validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" || s.DaysPastDue < 1 ||
+		(s.Action != CollectionActionReminder && s.Action != CollectionActionFormalNotice) {
+		return ErrInvalidCollectionStage
+	}
+	return nil
+}
+
+// NextCollectionStage returns the furthest stage reached after daysPastDue days that lies beyond
+// the last stage actioned, or nil when there is nothing new to do. Stages must be in day order.
+// Stages skipped over, e.g. while a promise to pay was pending, are not actioned separately.
+func NextCollectionStage(stages []CollectionStage, daysPastDue, stageDays int) *CollectionStage {
+	var next *CollectionStage
+	for i := range stages {
+		if stages[i].DaysPastDue > stageDays && stages[i].DaysPastDue <= daysPastDue {
+			next = &stages[i]
+		}
+	}
+	return next
+}
+
+// GetCollectionStages retrieves the stages in day order
+func GetCollectionStages() ([]CollectionStage, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, name, days_past_due, action, created_at, updated_at
+		FROM collection_stages
+		ORDER BY days_past_due, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stages []CollectionStage
+	for rows.Next() {
+		var s CollectionStage
+		if err := rows.Scan(&s.ID, &s.Name, &s.DaysPastDue, &s.Action, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stages = append(stages, s)
+	}
+	return stages, rows.Err()
+}
+
+// SaveCollectionStage creates or updates the stage named stage.Name
+func SaveCollectionStage(stage *CollectionStage) error {
+	if err := stage.Validate(); err != nil {
+		return err
+	}
+
+	err := db.DB.QueryRow(`
+		UPDATE collection_stages SET days_past_due = $2, action = $3, updated_at = NOW()
+		WHERE name = $1
+		RETURNING id, created_at, updated_at`, stage.Name, stage.DaysPastDue, stage.Action).
+		Scan(&stage.ID, &stage.CreatedAt, &stage.UpdatedAt)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	return db.DB.QueryRow(`
+		INSERT INTO collection_stages (name, days_past_due, action)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`, stage.Name, stage.DaysPastDue, stage.Action).
+		Scan(&stage.ID, &stage.CreatedAt, &stage.UpdatedAt)
+}
+
+// DeleteCollectionStage removes a stage. Cases that already reached it keep their stage name.
+func DeleteCollectionStage(name string) error {
+	result, err := db.DB.Exec("DELETE FROM collection_stages WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// LeaseDelinquency is the unpaid rent of a lease, aged from the oldest charge not covered by payments
+type LeaseDelinquency struct {
+	LeaseID      int            `json:"lease_id"`
+	PropertyID   int            `json:"property_id"`
+	PropertyName string         `json:"property_name"`
+	UnitNumber   sql.NullString `json:"unit_number,omitempty"`
+	TenantID     int            `json:"tenant_id"`
+	TenantName   string         `json:"tenant_name"`
+	TenantEmail  string         `json:"-"`
+	TenantPhone  sql.NullString `json:"-"`
+	Billed       float64        `json:"billed"` // Rent due to date, net of concessions
+	Paid         float64        `json:"paid"`
+	PastDue      float64        `json:"past_due"`
+	OldestUnpaid sql.NullTime   `json:"oldest_unpaid,omitempty"`
+	DaysPastDue  int            `json:"days_past_due"`
+}
+
+// AgeLeaseBalance applies the lease's payments to its monthly charges oldest first and ages what
+// remains unpaid of the charges due by asOf. Each month's rent is due on the first day the lease
+// covers in that month.
+func AgeLeaseBalance(d *LeaseDelinquency, charges []RentCharge, paid float64, asOf time.Time) {
+	credit := paid
+	for _, c := range charges {
+		if c.AmountDue <= 0 || c.PeriodStart.After(asOf) {
+			continue
+		}
+		d.Billed += c.AmountDue
+		unpaid := c.AmountDue
+		if credit > 0 {
+			applied := math.Min(credit, unpaid)
+			credit -= applied
+			unpaid -= applied
+		}
+		if unpaid < 0.005 {
+			continue
+		}
+
+		d.PastDue += unpaid
+		if !d.OldestUnpaid.Valid {
+			d.OldestUnpaid = sql.NullTime{Time: c.PeriodStart, Valid: true}
+			d.DaysPastDue = int(asOf.Sub(c.PeriodStart).Hours() / 24)
+		}
+	}
+
+	d.Paid = paid
+	d.Billed = math.Round(d.Billed*100) / 100
+	d.PastDue = math.Round(d.PastDue*100) / 100
+}
+
+// loadLeaseDelinquencies returns the active leases with rent past due as of asOf, most delinquent
+// first, optionally limited to some properties
+func loadLeaseDelinquencies(q Querier, propertyIDs []interface{}, asOf time.Time) ([]LeaseDelinquency, error) {
+	query := `
+		SELECT l.id, pu.property_id, p.name, pu.unit_number, t.id, t.first_name || ' ' || t.last_name,
+			   t.email, t.phone_number, l.monthly_rent, l.start_date, l.end_date, l.proration_convention,
+			   COALESCE((SELECT SUM(pay.amount) FROM payments pay
+			             WHERE pay.lease_id = l.id AND pay.status = 'completed'), 0)
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		JOIN tenants t ON l.tenant_id = t.id
+		WHERE l.status = 'active' AND l.start_date <= $1`
+	args := []interface{}{asOf}
+	if len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+1)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND pu.property_id IN (%s)", strings.Join(placeholders, ", "))
+	}
+
+	rows, err := q.Query(query+" ORDER BY l.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []LeaseDelinquency
+	var leases []ScheduledLease
+	var paid []float64
+	for rows.Next() {
+		var d LeaseDelinquency
+		var l ScheduledLease
+		var leasePaid float64
+		err := rows.Scan(&d.LeaseID, &d.PropertyID, &d.PropertyName, &d.UnitNumber, &d.TenantID, &d.TenantName,
+			&d.TenantEmail, &d.TenantPhone, &l.MonthlyRent, &l.StartDate, &l.EndDate, &l.ProrationConvention,
+			&leasePaid)
+		if err != nil {
+			return nil, err
+		}
+		l.LeaseID, l.PropertyID = d.LeaseID, d.PropertyID
+		accounts = append(accounts, d)
+		leases = append(leases, l)
+		paid = append(paid, leasePaid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pointers := make([]*ScheduledLease, len(leases))
+	for i := range leases {
+		pointers[i] = &leases[i]
+	}
+	if err := attachLeaseSchedules(q, pointers); err != nil {
+		return nil, err
+	}
+
+	var delinquencies []LeaseDelinquency
+	for i := range accounts {
+		AgeLeaseBalance(&accounts[i], leases[i].Charges(), paid[i], asOf)
+		if accounts[i].PastDue > 0 {
+			delinquencies = append(delinquencies, accounts[i])
+		}
+	}
+	sort.SliceStable(delinquencies, func(i, j int) bool {
+		return delinquencies[i].DaysPastDue > delinquencies[j].DaysPastDue
+	})
+	return delinquencies, nil
+}
+
+// GetLeaseDelinquencies returns the active leases with rent past due as of asOf, most delinquent
+// first, for the given properties (all when empty)
+func GetLeaseDelinquencies(propertyIDs []interface{}, asOf time.Time) ([]LeaseDelinquency, error) {
+	return loadLeaseDelinquencies(db.ReadDB(), propertyIDs, asOf)
+}
+
+// CollectionCase tracks a delinquent lease through the collection stages until it is paid up
+type CollectionCase struct {
+	ID           int            `json:"id"`
+	LeaseID      int            `json:"lease_id"`
+	PropertyID   int            `json:"property_id"`
+	PropertyName string         `json:"property_name"`
+	UnitNumber   sql.NullString `json:"unit_number,omitempty"`
+	TenantID     int            `json:"tenant_id"`
+	TenantName   string         `json:"tenant_name"`
+	Status       string         `json:"status"`
+	PastDue      float64        `json:"past_due"`
+	DaysPastDue  int            `json:"days_past_due"`
+	Stage        sql.NullString `json:"stage,omitempty"`
+	StageDays    int            `json:"stage_days"`
+	LastActionAt sql.NullTime   `json:"last_action_at,omitempty"`
+	OpenedAt     time.Time      `json:"opened_at"`
+	ResolvedAt   sql.NullTime   `json:"resolved_at,omitempty"`
+
+	Promises []PromiseToPay     `json:"promises,omitempty"`
+	Notices  []CollectionNotice `json:"notices,omitempty"`
+}
+
+const collectionCaseColumns = `
+	cc.id, cc.lease_id, pu.property_id, p.name, pu.unit_number, t.id, t.first_name || ' ' || t.last_name,
+	cc.status, cc.past_due, cc.days_past_due, cc.stage, cc.stage_days, cc.last_action_at, cc.opened_at,
+	cc.resolved_at`
+
+const collectionCaseJoins = `
+	FROM collection_cases cc
+	JOIN leases l ON cc.lease_id = l.id
+	JOIN tenants t ON l.tenant_id = t.id
+	JOIN property_units pu ON l.unit_id = pu.id
+	JOIN properties p ON pu.property_id = p.id`
+
+func scanCollectionCase(row interface{ Scan(...interface{}) error }) (*CollectionCase, error) {
+	var c CollectionCase
+	err := row.Scan(&c.ID, &c.LeaseID, &c.PropertyID, &c.PropertyName, &c.UnitNumber, &c.TenantID, &c.TenantName,
+		&c.Status, &c.PastDue, &c.DaysPastDue, &c.Stage, &c.StageDays, &c.LastActionAt, &c.OpenedAt, &c.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetCollectionCases lists cases, optionally filtered by status, most delinquent first. Resolved
+// cases are only included when asked for by status.
+func GetCollectionCases(status string, propertyIDs []interface{}) ([]CollectionCase, error) {
+	query := "SELECT" + collectionCaseColumns + collectionCaseJoins
+	var args []interface{}
+	if status != "" {
+		args = append(args, status)
+		query += " WHERE cc.status = $1"
+	} else {
+		query += " WHERE cc.status <> 'resolved'"
+	}
+	if len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+1)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND pu.property_id IN (%s)", strings.Join(placeholders, ", "))
+	}
+	query += " ORDER BY cc.days_past_due DESC, cc.id"
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cases []CollectionCase
+	for rows.Next() {
+		c, err := scanCollectionCase(rows)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, *c)
+	}
+	return cases, rows.Err()
+}
+
+// GetCollectionCase retrieves a case with its promises to pay and notices
+func GetCollectionCase(id int) (*CollectionCase, error) {
+	c, err := scanCollectionCase(db.DB.QueryRow("SELECT"+collectionCaseColumns+collectionCaseJoins+" WHERE cc.id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	if c.Promises, err = getPromisesToPay(id); err != nil {
+		return nil, err
+	}
+	if c.Notices, err = getCollectionNotices(id); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// PromiseToPay is a tenant's commitment to pay an amount by a date, which pauses escalation of
+// their case until then
+type PromiseToPay struct {
+	ID           int            `json:"id"`
+	CaseID       int            `json:"case_id"`
+	Amount       float64        `json:"amount"`
+	PromisedDate time.Time      `json:"promised_date"`
+	RecordedOn   time.Time      `json:"recorded_on"`
+	Status       string         `json:"status"`
+	Notes        sql.NullString `json:"notes,omitempty"`
+	CreatedBy    sql.NullInt32  `json:"created_by,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	SettledAt    sql.NullTime   `json:"settled_at,omitempty"`
+}
+
+func getPromisesToPay(caseID int) ([]PromiseToPay, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, case_id, amount, promised_date, recorded_on, status, notes, created_by, created_at, settled_at
+		FROM collection_promises
+		WHERE case_id = $1
+		ORDER BY created_at, id`, caseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promises []PromiseToPay
+	for rows.Next() {
+		var p PromiseToPay
+		if err := rows.Scan(&p.ID, &p.CaseID, &p.Amount, &p.PromisedDate, &p.RecordedOn, &p.Status, &p.Notes,
+			&p.CreatedBy, &p.CreatedAt, &p.SettledAt); err != nil {
+			return nil, err
+		}
+		promises = append(promises, p)
+	}
+	return promises, rows.Err()
+}
+
+// RecordPromiseToPay records a promise on an unresolved case, replacing any pending promise, and
+// pauses the case's escalation until the promised date. It returns sql.ErrNoRows if the case does
+// not exist and ErrCollectionCaseClosed if it has been resolved.
+func RecordPromiseToPay(p *PromiseToPay, now time.Time) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	if err := tx.QueryRow("SELECT status FROM collection_cases WHERE id = $1", p.CaseID).Scan(&status); err != nil {
+		return err
+	}
+	if status == CollectionCaseResolved {
+		return ErrCollectionCaseClosed
+	}
+
+	// A new promise supersedes one that has not fallen due
+	if _, err := tx.Exec(`
+		UPDATE collection_promises SET status = 'broken', settled_at = $2
+		WHERE case_id = $1 AND status = 'pending'`, p.CaseID, now); err != nil {
+		return err
+	}
+
+	p.RecordedOn = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	p.Status = PromisePending
+	err = tx.QueryRow(`
+		INSERT INTO collection_promises (case_id, amount, promised_date, recorded_on, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		p.CaseID, p.Amount, p.PromisedDate, p.RecordedOn, p.Notes, p.CreatedBy).Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE collection_cases SET status = 'promised', updated_at = $2 WHERE id = $1`, p.CaseID, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CollectionNotice is a formal notice issued to a delinquent tenant
+type CollectionNotice struct {
+	ID          int           `json:"id"`
+	CaseID      int           `json:"case_id"`
+	Stage       string        `json:"stage"`
+	AmountDue   float64       `json:"amount_due"`
+	DaysPastDue int           `json:"days_past_due"`
+	IssuedBy    sql.NullInt32 `json:"issued_by,omitempty"`
+	IssuedAt    time.Time     `json:"issued_at"`
+
+	// Filled in by GetCollectionNotice for the notice document
+	TenantName      string         `json:"tenant_name,omitempty"`
+	PropertyName    string         `json:"property_name,omitempty"`
+	PropertyAddress string         `json:"property_address,omitempty"`
+	UnitNumber      sql.NullString `json:"unit_number,omitempty"`
+	MonthlyRent     float64        `json:"monthly_rent,omitempty"`
+}
+
+// Reference is the notice number printed on the document, e.g. CN-2026-000042
+func (n *CollectionNotice) Reference() string {
+	return fmt.Sprintf("CN-%d-%06d", n.IssuedAt.Year(), n.ID)
+}
+
+func getCollectionNotices(caseID int) ([]CollectionNotice, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, case_id, stage, amount_due, days_past_due, issued_by, issued_at
+		FROM collection_notices
+		WHERE case_id = $1
+		ORDER BY issued_at, id`, caseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notices []CollectionNotice
+	for rows.Next() {
+		var n CollectionNotice
+		if err := rows.Scan(&n.ID, &n.CaseID, &n.Stage, &n.AmountDue, &n.DaysPastDue, &n.IssuedBy, &n.IssuedAt); err != nil {
+			return nil, err
+		}
+		notices = append(notices, n)
+	}
+	return notices, rows.Err()
+}
+
+// GetCollectionNotice retrieves a notice with the tenant and property details printed on it
+func GetCollectionNotice(id int) (*CollectionNotice, error) {
+	var n CollectionNotice
+	err := db.DB.QueryRow(`
+		SELECT cn.id, cn.case_id, cn.stage, cn.amount_due, cn.days_past_due, cn.issued_by, cn.issued_at,
+			   t.first_name || ' ' || t.last_name, p.name, p.address, pu.unit_number, l.monthly_rent
+		FROM collection_notices cn
+		JOIN collection_cases cc ON cn.case_id = cc.id
+		JOIN leases l ON cc.lease_id = l.id
+		JOIN tenants t ON l.tenant_id = t.id
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		WHERE cn.id = $1`, id).Scan(&n.ID, &n.CaseID, &n.Stage, &n.AmountDue, &n.DaysPastDue, &n.IssuedBy,
+		&n.IssuedAt, &n.TenantName, &n.PropertyName, &n.PropertyAddress, &n.UnitNumber, &n.MonthlyRent)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// insertCollectionNotice records a formal notice for the case's current balance
+func insertCollectionNotice(q Querier, c *CollectionCase, stage string, issuedBy sql.NullInt32, now time.Time) (*CollectionNotice, error) {
+	n := &CollectionNotice{CaseID: c.ID, Stage: stage, AmountDue: c.PastDue, DaysPastDue: c.DaysPastDue,
+		IssuedBy: issuedBy, IssuedAt: now}
+	err := q.QueryRow(`
+		INSERT INTO collection_notices (case_id, stage, amount_due, days_past_due, issued_by, issued_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`, n.CaseID, n.Stage, n.AmountDue, n.DaysPastDue, n.IssuedBy, n.IssuedAt).Scan(&n.ID)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// IssueCollectionNotice escalates an unresolved case to a formal notice straight away. It returns
+// sql.ErrNoRows if the case does not exist and ErrCollectionCaseClosed if it has been resolved.
+func IssueCollectionNotice(caseID int, issuedBy sql.NullInt32, now time.Time) (*CollectionNotice, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	c, err := scanCollectionCase(tx.QueryRow("SELECT"+collectionCaseColumns+collectionCaseJoins+" WHERE cc.id = $1", caseID))
+	if err != nil {
+		return nil, err
+	}
+	if c.Status == CollectionCaseResolved {
+		return nil, ErrCollectionCaseClosed
+	}
+
+	n, err := insertCollectionNotice(tx, c, CollectionActionFormalNotice, issuedBy, now)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE collection_cases SET last_action_at = $2, updated_at = $2 WHERE id = $1`, caseID, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// FormatCollectionReminder renders the reminder or notice message sent to a delinquent tenant
+func FormatCollectionReminder(d *LeaseDelinquency, action string) (string, string) {
+	unit := d.PropertyName
+	if d.UnitNumber.Valid {
+		unit += " " + d.UnitNumber.String
+	}
+	if action == CollectionActionFormalNotice {
+		subject := fmt.Sprintf("Formal notice of unpaid rent for %s", unit)
+		body := fmt.Sprintf("Hi %s,\n\nRent of $%.2f for %s has been past due for %d days. "+
+			"A formal notice has been issued. Please pay the balance in full or contact the property "+
+			"manager to arrange payment.\n", d.TenantName, d.PastDue, unit, d.DaysPastDue)
+		return subject, body
+	}
+	subject := fmt.Sprintf("Rent reminder for %s", unit)
+	body := fmt.Sprintf("Hi %s,\n\nOur records show $%.2f of rent for %s is %d days past due. "+
+		"Please pay the balance as soon as possible, or contact the property manager if you need to "+
+		"arrange a payment date.\n", d.TenantName, d.PastDue, unit, d.DaysPastDue)
+	return subject, body
+}
+
+// enqueueCollectionReminder queues a stage's message to the tenant by email, and by SMS when they
+// have a phone number
+func enqueueCollectionReminder(q Querier, caseID int, d *LeaseDelinquency, stage *CollectionStage) error {
+	subject, body := FormatCollectionReminder(d, stage.Action)
+	payload := map[string]interface{}{
+		"lease_id":           d.LeaseID,
+		"collection_case_id": caseID,
+		"stage":              stage.Name,
+		"past_due":           d.PastDue,
+		"days_past_due":      d.DaysPastDue,
+		"subject":            subject,
+		"body":               body,
+	}
+
+	notices := []OutboxMessage{{Channel: "email", Destination: d.TenantEmail}}
+	if d.TenantPhone.Valid && d.TenantPhone.String != "" {
+		notices = append(notices, OutboxMessage{Channel: "sms", Destination: d.TenantPhone.String})
+	}
+	for _, notice := range notices {
+		notice.EventType = "collections." + stage.Action
+		notice.Payload = payload
+		if err := EnqueueOutboxMessage(q, &notice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CollectionRunResult counts what one pass of the collections workflow did
+type CollectionRunResult struct {
+	Opened         int `json:"opened"`
+	Reminders      int `json:"reminders"`
+	Notices        int `json:"notices"`
+	Resolved       int `json:"resolved"`
+	PromisesKept   int `json:"promises_kept"`
+	PromisesBroken int `json:"promises_broken"`
+}
+
+// Collection stages are acted on between these hours of the local day at properties with a time
+// zone set, so tenants are not sent reminders overnight. A stage reached outside them waits for the
+// first run inside them.
+const (
+	CollectionReminderStartHour = 8
+	CollectionReminderEndHour   = 20
+)
+
+// withinCollectionReminderHours reports whether a local time is inside the reminder hours
+func withinCollectionReminderHours(local time.Time) bool {
+	return local.Hour() >= CollectionReminderStartHour && local.Hour() < CollectionReminderEndHour
+}
+
+// RunCollections brings the collections workflow up to date as of now. Promises to pay that fell
+// due are settled as kept or broken, cases are opened for leases with rent past due and resolved
+// once they are paid up, and each case is escalated to the furthest stage its days past due have
+// reached unless a pending promise to pay holds it back or, at a property with a time zone, it is
+// outside the reminder hours there.
+func RunCollections(now time.Time) (*CollectionRunResult, error) {
+	stages, err := GetCollectionStages()
+	if err != nil {
+		return nil, err
+	}
+	timezones, err := GetPropertyTimezones()
+	if err != nil {
+		return nil, err
+	}
+	delinquencies, err := loadLeaseDelinquencies(db.DB, nil, now)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &CollectionRunResult{}
+	if err := settlePromisesToPay(tx, result, now); err != nil {
+		return nil, err
+	}
+
+	cases, err := openCollectionCases(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range delinquencies {
+		d := &delinquencies[i]
+		c, ok := cases[d.LeaseID]
+		delete(cases, d.LeaseID)
+		if !ok {
+			c = &CollectionCase{LeaseID: d.LeaseID, Status: CollectionCaseOpen, OpenedAt: now}
+			if err := tx.QueryRow(`
+				INSERT INTO collection_cases (lease_id, past_due, days_past_due, opened_at)
+				VALUES ($1, $2, $3, $4)
+				RETURNING id`, d.LeaseID, d.PastDue, d.DaysPastDue, now).Scan(&c.ID); err != nil {
+				return nil, err
+			}
+			result.Opened++
+		}
+		c.PastDue, c.DaysPastDue = d.PastDue, d.DaysPastDue
+
+		stage := NextCollectionStage(stages, d.DaysPastDue, c.StageDays)
+		if loc, ok := timezones[d.PropertyID]; ok && !withinCollectionReminderHours(now.In(loc)) {
+			stage = nil
+		}
+		if c.Status == CollectionCasePromised || stage == nil {
+			if _, err := tx.Exec(`
+				UPDATE collection_cases SET past_due = $2, days_past_due = $3, updated_at = $4
+				WHERE id = $1`, c.ID, d.PastDue, d.DaysPastDue, now); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if stage.Action == CollectionActionFormalNotice {
+			if _, err := insertCollectionNotice(tx, c, stage.Name, sql.NullInt32{}, now); err != nil {
+				return nil, err
+			}
+			result.Notices++
+		} else {
+			result.Reminders++
+		}
+		if err := enqueueCollectionReminder(tx, c.ID, d, stage); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE collection_cases
+			SET past_due = $2, days_past_due = $3, stage = $4, stage_days = $5, last_action_at = $6, updated_at = $6
+			WHERE id = $1`, c.ID, d.PastDue, d.DaysPastDue, stage.Name, stage.DaysPastDue, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// Whatever is left has been paid up, or the lease is no longer active
+	for _, c := range cases {
+		if _, err := tx.Exec(`
+			UPDATE collection_promises SET status = 'kept', settled_at = $2
+			WHERE case_id = $1 AND status = 'pending'`, c.ID, now); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE collection_cases SET status = 'resolved', past_due = 0, resolved_at = $2, updated_at = $2
+			WHERE id = $1`, c.ID, now); err != nil {
+			return nil, err
+		}
+		result.Resolved++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// openCollectionCases loads the unresolved cases keyed by lease
+func openCollectionCases(q Querier) (map[int]*CollectionCase, error) {
+	rows, err := q.Query(`
+		SELECT id, lease_id, status, stage_days, opened_at
+		FROM collection_cases
+		WHERE status <> 'resolved'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cases := make(map[int]*CollectionCase)
+	for rows.Next() {
+		var c CollectionCase
+		if err := rows.Scan(&c.ID, &c.LeaseID, &c.Status, &c.StageDays, &c.OpenedAt); err != nil {
+			return nil, err
+		}
+		cases[c.LeaseID] = &c
+	}
+	return cases, rows.Err()
+}
+
+// settlePromisesToPay closes the pending promises whose date has passed, as kept when the payments
+// made since the promise was recorded cover it and broken otherwise, and resumes escalation of
+// their cases
+func settlePromisesToPay(q Querier, result *CollectionRunResult, now time.Time) error {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	rows, err := q.Query(`
+		SELECT cp.id, cp.case_id, cp.amount,
+			   COALESCE((SELECT SUM(pay.amount) FROM payments pay
+			             WHERE pay.lease_id = cc.lease_id AND pay.status = 'completed'
+			               AND pay.payment_date >= cp.recorded_on), 0)
+		FROM collection_promises cp
+		JOIN collection_cases cc ON cp.case_id = cc.id
+		WHERE cp.status = 'pending' AND cp.promised_date < $1`, today)
+	if err != nil {
+		return err
+	}
+
+	type duePromise struct {
+		id, caseID   int
+		amount, paid float64
+	}
+	var due []duePromise
+	for rows.Next() {
+		var p duePromise
+		if err := rows.Scan(&p.id, &p.caseID, &p.amount, &p.paid); err != nil {
+			rows.Close()
+			return err
+		}
+		due = append(due, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range due {
+		status := PromiseBroken
+		if p.paid >= p.amount-0.005 {
+			status = PromiseKept
+			result.PromisesKept++
+		} else {
+			result.PromisesBroken++
+		}
+		if _, err := q.Exec(`
+			UPDATE collection_promises SET status = $2, settled_at = $3 WHERE id = $1`, p.id, status, now); err != nil {
+			return err
+		}
+		if _, err := q.Exec(`
+			UPDATE collection_cases SET status = 'open', updated_at = $2
+			WHERE id = $1 AND status = 'promised'`, p.caseID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectionStatusLine is an unresolved case with its latest promise to pay for the status report
+type collectionStatusLine struct {
+	CollectionCase
+	promiseAmount sql.NullFloat64
+	promiseDate   sql.NullTime
+	promiseStatus sql.NullString
+	notices       int
+}
+
+// generateCollectionsStatusReport lists the unresolved collection cases at the selected
+// properties (criteria property_ids, default all) with their stage, latest promise to pay and
+// the number of formal notices issued
+func generateCollectionsStatusReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	query := `
+		SELECT` + collectionCaseColumns + `,
+			   cp.amount, cp.promised_date, cp.status,
+			   (SELECT COUNT(*) FROM collection_notices cn WHERE cn.case_id = cc.id)` + collectionCaseJoins + `
+		LEFT JOIN collection_promises cp ON cp.id = (
+			SELECT MAX(cp2.id) FROM collection_promises cp2 WHERE cp2.case_id = cc.id)
+		WHERE cc.status <> 'resolved'`
+	var args []interface{}
+	if propertyIDs, _ := report.Criteria["property_ids"].([]interface{}); len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+1)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND pu.property_id IN (%s)", strings.Join(placeholders, ", "))
+	}
+	query += " ORDER BY cc.days_past_due DESC, cc.id"
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []collectionStatusLine
+	for rows.Next() {
+		var l collectionStatusLine
+		c := &l.CollectionCase
+		err := rows.Scan(&c.ID, &c.LeaseID, &c.PropertyID, &c.PropertyName, &c.UnitNumber, &c.TenantID,
+			&c.TenantName, &c.Status, &c.PastDue, &c.DaysPastDue, &c.Stage, &c.StageDays, &c.LastActionAt,
+			&c.OpenedAt, &c.ResolvedAt, &l.promiseAmount, &l.promiseDate, &l.promiseStatus, &l.notices)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buildCollectionsStatusReport(lines), nil
+}
+
+// buildCollectionsStatusReport lays out the cases with totals and a chart of the balance past due
+// at each stage
+func buildCollectionsStatusReport(lines []collectionStatusLine) *ReportData {
+	data := &ReportData{
+		Headers: []string{"Property", "Unit", "Tenant", "Past Due", "Days Past Due", "Stage", "Status",
+			"Promised Amount", "Promised Date", "Promise Status", "Notices"},
+		Rows: []map[string]interface{}{},
+	}
+
+	var totalPastDue float64
+	var promised, brokenPromises, notices int
+	byStage := make(map[string]float64)
+	stageDays := make(map[string]int)
+	var stageOrder []string
+	for _, l := range lines {
+		stage := "none"
+		if l.Stage.Valid {
+			stage = l.Stage.String
+		}
+		row := map[string]interface{}{
+			"Property":        l.PropertyName,
+			"Unit":            l.UnitNumber.String,
+			"Tenant":          l.TenantName,
+			"Past Due":        l.PastDue,
+			"Days Past Due":   l.DaysPastDue,
+			"Stage":           stage,
+			"Status":          l.Status,
+			"Promised Amount": nil,
+			"Promised Date":   "",
+			"Promise Status":  "",
+			"Notices":         l.notices,
+		}
+		if l.promiseAmount.Valid {
+			row["Promised Amount"] = l.promiseAmount.Float64
+			row["Promised Date"] = l.promiseDate.Time.Format("2006-01-02")
+			row["Promise Status"] = l.promiseStatus.String
+			if l.promiseStatus.String == PromiseBroken {
+				brokenPromises++
+			}
+		}
+		data.Rows = append(data.Rows, row)
+
+		totalPastDue += l.PastDue
+		if l.Status == CollectionCasePromised {
+			promised++
+		}
+		notices += l.notices
+		if _, ok := byStage[stage]; !ok {
+			stageOrder = append(stageOrder, stage)
+			stageDays[stage] = l.StageDays
+		}
+		byStage[stage] += l.PastDue
+	}
+
+	data.Summary = map[string]interface{}{
+		"open_cases":      len(lines),
+		"total_past_due":  math.Round(totalPastDue*100) / 100,
+		"promised_cases":  promised,
+		"broken_promises": brokenPromises,
+		"notices_issued":  notices,
+	}
+
+	if len(lines) > 0 {
+		sort.SliceStable(stageOrder, func(i, j int) bool {
+			return stageDays[stageOrder[i]] < stageDays[stageOrder[j]]
+		})
+		labels := make([]interface{}, len(stageOrder))
+		values := make([]interface{}, len(stageOrder))
+		for i, stage := range stageOrder {
+			labels[i] = stage
+			values[i] = math.Round(byStage[stage]*100) / 100
+		}
+		data.Charts = []ChartData{{
+			Type:  "bar",
+			Title: "Past Due by Collections Stage",
+			Data: map[string]interface{}{
+				"labels": labels,
+				"datasets": []map[string]interface{}{{
+					"label":           "Past Due",
+					"data":            values,
+					"backgroundColor": "rgba(255, 99, 132, 0.5)",
+				}},
+			},
+		}}
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Past Due": currencyColumn, "Days Past Due": numberColumn, "Promised Amount": currencyColumn,
+		"Promised Date": dateColumn, "Notices": numberColumn,
+	})
+	return data
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgeLeaseBalanceAppliesPaymentsOldestFirst(t *testing.T) {
+	lease := ScheduledLease{MonthlyRent: 1500, StartDate: date(2026, 6, 16), EndDate: date(2027, 6, 15)}
+
+	var d LeaseDelinquency
+	AgeLeaseBalance(&d, lease.Charges(), 1750, date(2026, 9, 20))
+
+	// June is prorated to $750; October is not yet due
+	assert.Equal(t, 5250.0, d.Billed)
+	assert.Equal(t, 3500.0, d.PastDue)
+	assert.Equal(t, date(2026, 7, 1), d.OldestUnpaid.Time)
+	assert.Equal(t, 81, d.DaysPastDue)
+}
+
+func TestAgeLeaseBalancePaidUp(t *testing.T) {
+	lease := ScheduledLease{MonthlyRent: 1500, StartDate: date(2026, 6, 1), EndDate: date(2027, 5, 31)}
+
+	var d LeaseDelinquency
+	AgeLeaseBalance(&d, lease.Charges(), 6000, date(2026, 9, 20))
+	assert.Equal(t, 0.0, d.PastDue)
+	assert.False(t, d.OldestUnpaid.Valid)
+	assert.Equal(t, 0, d.DaysPastDue)
+}
+
+func TestNextCollectionStage(t *testing.T) {
+	stages := []CollectionStage{
+		{Name: "first_reminder", DaysPastDue: 5, Action: CollectionActionReminder},
+		{Name: "second_reminder", DaysPastDue: 15, Action: CollectionActionReminder},
+		{Name: "formal_notice", DaysPastDue: 30, Action: CollectionActionFormalNotice},
+	}
+
+	assert.Nil(t, NextCollectionStage(stages, 3, 0))
+	assert.Equal(t, "first_reminder", NextCollectionStage(stages, 7, 0).Name)
+	assert.Nil(t, NextCollectionStage(stages, 7, 5), "a stage is only actioned once")
+	assert.Equal(t, "formal_notice", NextCollectionStage(stages, 40, 5).Name, "skipped stages are not actioned")
+	assert.Nil(t, NextCollectionStage(stages, 40, 30))
+}
+
+func TestCollectionStageValidate(t *testing.T) {
+	assert.NoError(t, (&CollectionStage{Name: " final ", DaysPastDue: 45, Action: CollectionActionFormalNotice}).Validate())
+	assert.Equal(t, ErrInvalidCollectionStage, (&CollectionStage{Name: "final", DaysPastDue: 0, Action: CollectionActionReminder}).Validate())
+	assert.Equal(t, ErrInvalidCollectionStage, (&CollectionStage{Name: "final", DaysPastDue: 10, Action: "eviction"}).Validate())
+}
+
+func TestRecordPromiseToPayOnResolvedCase(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM collection_cases`).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(CollectionCaseResolved))
+	mock.ExpectRollback()
+
+	promise := &PromiseToPay{CaseID: 8, Amount: 500, PromisedDate: date(2026, 11, 1)}
+	assert.Equal(t, ErrCollectionCaseClosed, RecordPromiseToPay(promise, time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildCollectionsStatusReport(t *testing.T) {
+	lines := []collectionStatusLine{
+		{
+			CollectionCase: CollectionCase{PropertyName: "Elm Court", TenantName: "Dana Reyes", Status: CollectionCasePromised,
+				PastDue: 1200, DaysPastDue: 34, Stage: sql.NullString{String: "formal_notice", Valid: true}, StageDays: 30},
+			promiseAmount: sql.NullFloat64{Float64: 600, Valid: true},
+			promiseDate:   sql.NullTime{Time: date(2026, 11, 1), Valid: true},
+			promiseStatus: sql.NullString{String: PromisePending, Valid: true},
+			notices:       1,
+		},
+		{
+			CollectionCase: CollectionCase{PropertyName: "Oak Plaza", TenantName: "Sam Ortiz", Status: CollectionCaseOpen,
+				PastDue: 300.5, DaysPastDue: 8, Stage: sql.NullString{String: "first_reminder", Valid: true}, StageDays: 5},
+			promiseAmount: sql.NullFloat64{Float64: 300, Valid: true},
+			promiseDate:   sql.NullTime{Time: date(2026, 10, 10), Valid: true},
+			promiseStatus: sql.NullString{String: PromiseBroken, Valid: true},
+		},
+		{CollectionCase: CollectionCase{PropertyName: "Oak Plaza", TenantName: "Lee Park", Status: CollectionCaseOpen,
+			PastDue: 100, DaysPastDue: 2}},
+	}
+
+	data := buildCollectionsStatusReport(lines)
+	require.Len(t, data.Rows, 3)
+	assert.Equal(t, "2026-11-01", data.Rows[0]["Promised Date"])
+	assert.Equal(t, "none", data.Rows[2]["Stage"])
+	assert.Nil(t, data.Rows[2]["Promised Amount"])
+
+	assert.Equal(t, 3, data.Summary["open_cases"])
+	assert.Equal(t, 1600.5, data.Summary["total_past_due"])
+	assert.Equal(t, 1, data.Summary["promised_cases"])
+	assert.Equal(t, 1, data.Summary["broken_promises"])
+	assert.Equal(t, 1, data.Summary["notices_issued"])
+	require.Len(t, data.Charts, 1)
+	assert.Equal(t, []interface{}{"none", "first_reminder", "formal_notice"}, data.Charts[0].Data["labels"])
+}
+
+func TestWithinCollectionReminderHours(t *testing.T) {
+	assert.False(t, withinCollectionReminderHours(time.Date(2026, 10, 16, 7, 59, 0, 0, time.UTC)))
+	assert.True(t, withinCollectionReminderHours(time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)))
+	assert.False(t, withinCollectionReminderHours(time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,402 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Lease concession types
+const (
+	ConcessionFreeRent        = "free_rent"        // The whole month's rent is waived
+	ConcessionFixedDiscount   = "fixed_discount"   // Amount is taken off each month
+	ConcessionPercentDiscount = "percent_discount" // Amount is a percentage of each month's rent
+)
+
+// ValidConcessionTypes lists the accepted concession_type values
+var ValidConcessionTypes = []string{ConcessionFreeRent, ConcessionFixedDiscount, ConcessionPercentDiscount}
+
+// LeaseConcession is a free-rent period or discount granted on a lease
+type LeaseConcession struct {
+	ID             int             `json:"id"`
+	LeaseID        int             `json:"lease_id"`
+	ConcessionType string          `json:"concession_type"`
+	StartMonth     time.Time       `json:"start_month"`
+	Months         int             `json:"months"`
+	Amount         sql.NullFloat64 `json:"amount,omitempty"`
+	Description    sql.NullString  `json:"description,omitempty"`
+	CreatedBy      sql.NullInt32   `json:"created_by,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// AppliesTo reports whether the concession covers the month starting at month
+func (c LeaseConcession) AppliesTo(month time.Time) bool {
+	first := PeriodStart(c.StartMonth)
+	month = PeriodStart(month)
+	return !month.Before(first) && month.Before(first.AddDate(0, c.Months, 0))
+}
+
+// AmountForMonth returns how much of monthlyRent the concession waives in the given month
+func (c LeaseConcession) AmountForMonth(month time.Time, monthlyRent float64) float64 {
+	if !c.AppliesTo(month) {
+		return 0
+	}
+
+	var amount float64
+	switch c.ConcessionType {
+	case ConcessionFreeRent:
+		amount = monthlyRent
+	case ConcessionFixedDiscount:
+		amount = c.Amount.Float64
+	case ConcessionPercentDiscount:
+		amount = monthlyRent * c.Amount.Float64 / 100
+	}
+	return math.Min(math.Max(amount, 0), monthlyRent)
+}
+
+// ScheduledLease is a lease's contract rent together with its concessions and applied escalations
+type ScheduledLease struct {
+	LeaseID     int
+	PropertyID  int
+	MonthlyRent float64 // Current rent
+	StartDate   time.Time
+	EndDate     time.Time
+	Concessions []LeaseConcession
+	RentChanges []RentChange // In effective date order
+
+	ProrationConvention string // Day-count convention for partly covered months
+}
+
+// RentAsOf returns the contract rent in force on date, undoing escalations that took effect after it
+func (l ScheduledLease) RentAsOf(date time.Time) float64 {
+	for _, change := range l.RentChanges {
+		if date.Before(change.EffectiveDate) {
+			return change.OldRent
+		}
+	}
+	return l.MonthlyRent
+}
+
+// RentForMonth returns the rent due, prorated for a partly covered first or last month, and the
+// concessions granted for a month
+func (l ScheduledLease) RentForMonth(month time.Time) (rent, concession float64) {
+	charge := l.ChargeForMonth(month)
+	return charge.Rent, charge.Concession
+}
+
+// EffectiveRent averages the rent actually charged over the lease term, net of concessions.
+// Prorated months count as the share of the month they were charged for.
+func (l ScheduledLease) EffectiveRent() float64 {
+	var rent, concessions, months float64
+	for _, charge := range l.Charges() {
+		rent += charge.Rent
+		concessions += charge.Concession
+		if charge.ContractRent > 0 {
+			months += charge.Rent / charge.ContractRent
+		}
+	}
+	if rent == 0 {
+		return l.MonthlyRent
+	}
+	return math.Round((rent-concessions)/months*100) / 100
+}
+
+// RentSchedule totals contract rent and concessions over a set of months
+type RentSchedule struct {
+	ScheduledRent float64 `json:"scheduled_rent"`
+	Concessions   float64 `json:"concessions"`
+	EffectiveRent float64 `json:"effective_rent"`
+}
+
+// SummarizeRentSchedule totals the leases' rent and concessions for every month in the range
+func SummarizeRentSchedule(leases []ScheduledLease, startDate, endDate time.Time) RentSchedule {
+	var schedule RentSchedule
+	for _, month := range MonthsInRange(startDate, endDate) {
+		for _, lease := range leases {
+			rent, concession := lease.RentForMonth(month)
+			schedule.ScheduledRent += rent
+			schedule.Concessions += concession
+		}
+	}
+	schedule.ScheduledRent = math.Round(schedule.ScheduledRent*100) / 100
+	schedule.Concessions = math.Round(schedule.Concessions*100) / 100
+	schedule.EffectiveRent = math.Round((schedule.ScheduledRent-schedule.Concessions)*100) / 100
+	return schedule
+}
+
+// CreateLeaseConcession records a concession. It returns sql.ErrNoRows if the lease does not exist.
+func CreateLeaseConcession(c *LeaseConcession) error {
+	var exists int
+	if err := db.DB.QueryRow("SELECT 1 FROM leases WHERE id = $1", c.LeaseID).Scan(&exists); err != nil {
+		return err
+	}
+
+	c.StartMonth = PeriodStart(c.StartMonth)
+	return db.DB.QueryRow(`
+		INSERT INTO lease_concessions (lease_id, concession_type, start_month, months, amount, description, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		c.LeaseID, c.ConcessionType, c.StartMonth, c.Months, c.Amount, c.Description, c.CreatedBy,
+	).Scan(&c.ID, &c.CreatedAt)
+}
+
+// DeleteLeaseConcession removes a concession
+func DeleteLeaseConcession(id int) error {
+	result, err := db.DB.Exec("DELETE FROM lease_concessions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetScheduledLease loads a lease's rent terms and concessions
+func GetScheduledLease(leaseID int) (*ScheduledLease, error) {
+	lease := &ScheduledLease{LeaseID: leaseID}
+	err := db.DB.QueryRow(`
+		SELECT pu.property_id, l.monthly_rent, l.start_date, l.end_date, l.proration_convention
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		WHERE l.id = $1`, leaseID).Scan(&lease.PropertyID, &lease.MonthlyRent, &lease.StartDate, &lease.EndDate,
+		&lease.ProrationConvention)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := attachLeaseSchedules(db.DB, []*ScheduledLease{lease}); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// loadScheduledLeases loads active and ended leases overlapping the period, optionally limited to
+// some properties, with their concessions
+func loadScheduledLeases(q Querier, startDate, endDate time.Time, propertyIDs []interface{}) ([]ScheduledLease, error) {
+	query := `
+		SELECT l.id, pu.property_id, l.monthly_rent, l.start_date, l.end_date, l.proration_convention
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		WHERE l.status IN ('active', 'ended') AND l.start_date <= $2 AND l.end_date >= $1`
+	args := []interface{}{startDate, endDate}
+	if len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+1)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND pu.property_id IN (%s)", strings.Join(placeholders, ", "))
+	}
+
+	rows, err := q.Query(query+" ORDER BY l.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leases []ScheduledLease
+	for rows.Next() {
+		var l ScheduledLease
+		if err := rows.Scan(&l.LeaseID, &l.PropertyID, &l.MonthlyRent, &l.StartDate, &l.EndDate,
+			&l.ProrationConvention); err != nil {
+			return nil, err
+		}
+		leases = append(leases, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pointers := make([]*ScheduledLease, len(leases))
+	for i := range leases {
+		pointers[i] = &leases[i]
+	}
+	if err := attachLeaseSchedules(q, pointers); err != nil {
+		return nil, err
+	}
+	return leases, nil
+}
+
+// attachLeaseSchedules loads the concessions and applied escalations of the leases
+func attachLeaseSchedules(q Querier, leases []*ScheduledLease) error {
+	leaseIDs := make([]int, len(leases))
+	for i, l := range leases {
+		leaseIDs[i] = l.LeaseID
+	}
+
+	concessions, err := getConcessionsByLease(q, leaseIDs)
+	if err != nil {
+		return err
+	}
+	changes, err := getRentChangesByLease(q, leaseIDs)
+	if err != nil {
+		return err
+	}
+	for _, l := range leases {
+		l.Concessions = concessions[l.LeaseID]
+		l.RentChanges = changes[l.LeaseID]
+	}
+	return nil
+}
+
+// getConcessionsByLease loads the concessions of the given leases, keyed by lease ID
+func getConcessionsByLease(q Querier, leaseIDs []int) (map[int][]LeaseConcession, error) {
+	concessions := map[int][]LeaseConcession{}
+	if len(leaseIDs) == 0 {
+		return concessions, nil
+	}
+
+	placeholders := make([]string, len(leaseIDs))
+	args := make([]interface{}, len(leaseIDs))
+	for i, id := range leaseIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	rows, err := q.Query(fmt.Sprintf(`
+		SELECT id, lease_id, concession_type, start_month, months, amount, description, created_by, created_at
+		FROM lease_concessions
+		WHERE lease_id IN (%s)
+		ORDER BY start_month, id`, strings.Join(placeholders, ", ")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c LeaseConcession
+		if err := rows.Scan(&c.ID, &c.LeaseID, &c.ConcessionType, &c.StartMonth, &c.Months, &c.Amount,
+			&c.Description, &c.CreatedBy, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		concessions[c.LeaseID] = append(concessions[c.LeaseID], c)
+	}
+	return concessions, rows.Err()
+}
+
+// generateRentRollReport lists active leases as of parameters["as_of"] (default today) with their
+// contract rent, this month's concessions and net effective rent over the lease term
+func generateRentRollReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	asOf := time.Now()
+	if value, ok := parameters["as_of"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			asOf = parsed
+		}
+	}
+
+	query := `
+		SELECT l.id, p.name, COALESCE(pu.unit_number, ''), t.first_name || ' ' || t.last_name,
+			   l.monthly_rent, l.start_date, l.end_date, p.address, pu.bedrooms, l.proration_convention
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		JOIN tenants t ON l.tenant_id = t.id
+		WHERE l.status = 'active' AND l.start_date <= $1 AND l.end_date >= $1`
+	args := []interface{}{asOf}
+	if propertyIDs, ok := report.Criteria["property_ids"].([]interface{}); ok && len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+1)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND p.id IN (%s)", strings.Join(placeholders, ", "))
+	}
+	query += " ORDER BY p.name, pu.unit_number"
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type rentRollLine struct {
+		lease                           ScheduledLease
+		property, unit, tenant, address string
+		bedrooms                        int
+	}
+	var lines []rentRollLine
+	for rows.Next() {
+		var line rentRollLine
+		if err := rows.Scan(&line.lease.LeaseID, &line.property, &line.unit, &line.tenant,
+			&line.lease.MonthlyRent, &line.lease.StartDate, &line.lease.EndDate, &line.address, &line.bedrooms,
+			&line.lease.ProrationConvention); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	leases := make([]*ScheduledLease, len(lines))
+	for i := range lines {
+		leases[i] = &lines[i].lease
+	}
+	if err := attachLeaseSchedules(db.ReadDB(), leases); err != nil {
+		return nil, err
+	}
+	benchmarks, err := LoadMarketBenchmarks()
+	if err != nil {
+		return nil, err
+	}
+
+	data := &ReportData{
+		Headers: []string{"Property", "Unit", "Tenant", "Lease Start", "Lease End", "Contract Rent",
+			"Concession This Month", "Effective Rent"},
+		Rows: []map[string]interface{}{},
+	}
+
+	var contractTotal, concessionTotal, effectiveTotal float64
+	var benchmarkedRent, marketTotal float64
+	for _, line := range lines {
+		_, concession := line.lease.RentForMonth(asOf)
+		effective := line.lease.EffectiveRent()
+
+		row := map[string]interface{}{
+			"Property":              line.property,
+			"Unit":                  line.unit,
+			"Tenant":                line.tenant,
+			"Lease Start":           line.lease.StartDate.Format("2006-01-02"),
+			"Lease End":             line.lease.EndDate.Format("2006-01-02"),
+			"Contract Rent":         line.lease.MonthlyRent,
+			"Concession This Month": math.Round(concession*100) / 100,
+			"Effective Rent":        effective,
+		}
+		// Variance to market is measured on effective rent, so concessions count against it
+		if market, ok := benchmarks.MarketRent(ZipCodeFromAddress(line.address), line.bedrooms); ok {
+			row["Market Rent"] = market
+			row["Variance to Market %"] = VarianceToMarket(effective, market)
+			benchmarkedRent += effective
+			marketTotal += market
+		}
+		data.Rows = append(data.Rows, row)
+		contractTotal += line.lease.MonthlyRent
+		concessionTotal += concession
+		effectiveTotal += effective
+	}
+
+	data.Summary = map[string]interface{}{
+		"as_of":                 asOf.Format("2006-01-02"),
+		"lease_count":           len(lines),
+		"total_contract_rent":   math.Round(contractTotal*100) / 100,
+		"total_concessions":     math.Round(concessionTotal*100) / 100,
+		"total_effective_rent":  math.Round(effectiveTotal*100) / 100,
+		"effective_rent_factor": 0.0,
+	}
+	if contractTotal > 0 {
+		data.Summary["effective_rent_factor"] = math.Round(effectiveTotal/contractTotal*10000) / 10000
+	}
+	if marketTotal > 0 {
+		data.Headers = append(data.Headers, "Market Rent", "Variance to Market %")
+		data.Summary["total_market_rent"] = math.Round(marketTotal*100) / 100
+		data.Summary["variance_to_market_percent"] = VarianceToMarket(benchmarkedRent, marketTotal)
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Lease Start": dateColumn, "Lease End": dateColumn, "Contract Rent": currencyColumn,
+		"Concession This Month": currencyColumn, "Effective Rent": currencyColumn, "Market Rent": currencyColumn,
+		"Variance to Market %": percentColumn,
+	})
+	return data, nil
+}
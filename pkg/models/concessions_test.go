@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseConcessionAmountForMonth(t *testing.T) {
+	month := func(m time.Month) time.Time { return time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC) }
+
+	free := LeaseConcession{ConcessionType: ConcessionFreeRent, StartMonth: month(2), Months: 2}
+	assert.Equal(t, 0.0, free.AmountForMonth(month(1), 1500))
+	assert.Equal(t, 1500.0, free.AmountForMonth(month(2), 1500))
+	assert.Equal(t, 1500.0, free.AmountForMonth(month(3).AddDate(0, 0, 14), 1500))
+	assert.Equal(t, 0.0, free.AmountForMonth(month(4), 1500))
+
+	fixed := LeaseConcession{ConcessionType: ConcessionFixedDiscount, StartMonth: month(1), Months: 12,
+		Amount: sql.NullFloat64{Float64: 2000, Valid: true}}
+	assert.Equal(t, 1500.0, fixed.AmountForMonth(month(6), 1500), "discount is capped at the rent")
+
+	percent := LeaseConcession{ConcessionType: ConcessionPercentDiscount, StartMonth: month(1), Months: 6,
+		Amount: sql.NullFloat64{Float64: 10, Valid: true}}
+	assert.Equal(t, 150.0, percent.AmountForMonth(month(6), 1500))
+}
+
+func TestScheduledLeaseEffectiveRent(t *testing.T) {
+	lease := ScheduledLease{
+		MonthlyRent: 1200,
+		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:     time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+	}
+	assert.Equal(t, 1200.0, lease.EffectiveRent())
+
+	// One free month and a $50 discount for six months; the free month absorbs January's discount,
+	// so (14400 - 1200 - 250) / 12
+	lease.Concessions = []LeaseConcession{
+		{ConcessionType: ConcessionFreeRent, StartMonth: lease.StartDate, Months: 1},
+		{ConcessionType: ConcessionFixedDiscount, StartMonth: lease.StartDate, Months: 6,
+			Amount: sql.NullFloat64{Float64: 50, Valid: true}},
+	}
+	assert.Equal(t, 1079.17, lease.EffectiveRent())
+
+	rent, concession := lease.RentForMonth(lease.StartDate)
+	assert.Equal(t, 1200.0, rent)
+	assert.Equal(t, 1200.0, concession, "combined concessions never exceed the rent")
+
+	rent, concession = lease.RentForMonth(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 0.0, rent)
+	assert.Equal(t, 0.0, concession)
+}
+
+func TestSummarizeRentSchedule(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	leases := []ScheduledLease{
+		{MonthlyRent: 1000, StartDate: start, EndDate: start.AddDate(1, 0, -1),
+			Concessions: []LeaseConcession{{ConcessionType: ConcessionFreeRent, StartMonth: start, Months: 1}}},
+		{MonthlyRent: 800, StartDate: start.AddDate(0, 1, 0), EndDate: start.AddDate(1, 0, -1)},
+	}
+
+	schedule := SummarizeRentSchedule(leases, start, start.AddDate(0, 2, -1))
+	assert.Equal(t, 2800.0, schedule.ScheduledRent)
+	assert.Equal(t, 1000.0, schedule.Concessions)
+	assert.Equal(t, 1800.0, schedule.EffectiveRent)
+}
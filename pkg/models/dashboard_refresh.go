@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Dashboard refresh interval limits in seconds. An interval of 0 turns auto-refresh off.
+const (
+	DashboardRefreshLayoutKey  = "refresh_interval_seconds"
+	MinDashboardRefreshSeconds = 30
+	MaxDashboardRefreshSeconds = 86400
+)
+
+// ValidateDashboardRefreshInterval checks a dashboard auto-refresh interval
+func ValidateDashboardRefreshInterval(seconds int) error {
+	if seconds == 0 {
+		return nil
+	}
+	if seconds < MinDashboardRefreshSeconds || seconds > MaxDashboardRefreshSeconds {
+		return fmt.Errorf("refresh_interval_seconds must be 0 or between %d and %d",
+			MinDashboardRefreshSeconds, MaxDashboardRefreshSeconds)
+	}
+	return nil
+}
+
+// RefreshInterval returns the dashboard's auto-refresh interval in seconds from its layout,
+// or 0 when auto-refresh is off or not configured
+func (d *AnalyticsDashboard) RefreshInterval() int {
+	seconds, ok := d.Layout[DashboardRefreshLayoutKey].(float64)
+	if !ok || ValidateDashboardRefreshInterval(int(seconds)) != nil {
+		return 0
+	}
+	return int(seconds)
+}
+
+// NextRefresh returns when data refreshed every interval seconds is next due after now. Refreshes
+// are aligned to multiples of the interval so every client viewing a dashboard refreshes together.
+func NextRefresh(now time.Time, interval int) time.Time {
+	period := time.Duration(interval) * time.Second
+	return now.Truncate(period).Add(period)
+}
+
+// SetDashboardRefreshInterval stores a dashboard's auto-refresh interval in its layout
+func SetDashboardRefreshInterval(id, seconds int) error {
+	if err := ValidateDashboardRefreshInterval(seconds); err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var layoutJSON []byte
+	if err := tx.QueryRow("SELECT layout FROM analytics_dashboards WHERE id = $1", id).Scan(&layoutJSON); err != nil {
+		return err
+	}
+	layout := map[string]interface{}{}
+	if err := unmarshalOptional(layoutJSON, &layout); err != nil {
+		return err
+	}
+	if layout == nil {
+		layout = map[string]interface{}{}
+	}
+	layout[DashboardRefreshLayoutKey] = seconds
+	data, err := json.Marshal(layout)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE analytics_dashboards SET layout = $1, updated_at = NOW() WHERE id = $2", string(data), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardRefreshInterval(t *testing.T) {
+	dashboard := &AnalyticsDashboard{Layout: map[string]interface{}{"refresh_interval_seconds": 120.0}}
+	assert.Equal(t, 120, dashboard.RefreshInterval())
+
+	// Missing or out of range settings leave auto-refresh off
+	assert.Equal(t, 0, (&AnalyticsDashboard{}).RefreshInterval())
+	dashboard.Layout["refresh_interval_seconds"] = 5.0
+	assert.Equal(t, 0, dashboard.RefreshInterval())
+
+	assert.NoError(t, ValidateDashboardRefreshInterval(0))
+	assert.NoError(t, ValidateDashboardRefreshInterval(MinDashboardRefreshSeconds))
+	assert.Error(t, ValidateDashboardRefreshInterval(10))
+	assert.Error(t, ValidateDashboardRefreshInterval(MaxDashboardRefreshSeconds+1))
+}
+
+func TestNextRefresh(t *testing.T) {
+	now := time.Date(2026, 10, 16, 15, 7, 42, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 10, 16, 15, 10, 0, 0, time.UTC), NextRefresh(now, 300))
+	assert.Equal(t, time.Date(2026, 10, 16, 15, 8, 0, 0, time.UTC), NextRefresh(now, 60))
+}
+
+func TestSetDashboardRefreshInterval(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT layout FROM analytics_dashboards WHERE id`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"layout"}).AddRow(`{"columns": 3}`))
+	mock.ExpectExec(`UPDATE analytics_dashboards SET layout`).
+		WithArgs(`{"columns":3,"refresh_interval_seconds":60}`, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, SetDashboardRefreshInterval(4, 60))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Invalid intervals are rejected before touching the database
+	assert.Error(t, SetDashboardRefreshInterval(4, 15))
+}
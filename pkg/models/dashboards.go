@@ -0,0 +1,299 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+var (
+	ErrInvalidDashboard  = errors.New("a dashboard needs a name")
+	ErrInvalidSavedChart = errors.New("a chart needs a name, a data source and a chart type of line, bar, pie, doughnut, scatter or area")
+)
+
+// validSavedChartTypes are the chart types a saved chart may render as
+var validSavedChartTypes = map[string]bool{
+	"line": true, "bar": true, "pie": true, "doughnut": true, "scatter": true, "area": true,
+}
+
+const dashboardColumns = `id, name, description, created_by, layout, widgets, is_default, is_public, created_at, updated_at`
+
+const savedChartColumns = `id, name, description, chart_type, data_source, config, filters, created_by, is_public,
+	created_at, updated_at`
+
+// scanDashboard scans a row selected with dashboardColumns
+func scanDashboard(row interface{ Scan(...interface{}) error }) (*AnalyticsDashboard, error) {
+	dashboard := &AnalyticsDashboard{}
+	var layoutJSON, widgetsJSON []byte
+	if err := row.Scan(&dashboard.ID, &dashboard.Name, &dashboard.Description, &dashboard.CreatedBy,
+		&layoutJSON, &widgetsJSON, &dashboard.IsDefault, &dashboard.IsPublic, &dashboard.CreatedAt,
+		&dashboard.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := unmarshalOptional(layoutJSON, &dashboard.Layout); err != nil {
+		return nil, err
+	}
+	if err := unmarshalOptional(widgetsJSON, &dashboard.Widgets); err != nil {
+		return nil, err
+	}
+	return dashboard, nil
+}
+
+// scanSavedChart scans a row selected with savedChartColumns
+func scanSavedChart(row interface{ Scan(...interface{}) error }) (*SavedChart, error) {
+	chart := &SavedChart{}
+	var configJSON, filtersJSON []byte
+	if err := row.Scan(&chart.ID, &chart.Name, &chart.Description, &chart.ChartType, &chart.DataSource,
+		&configJSON, &filtersJSON, &chart.CreatedBy, &chart.IsPublic, &chart.CreatedAt,
+		&chart.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := unmarshalOptional(configJSON, &chart.Config); err != nil {
+		return nil, err
+	}
+	if err := unmarshalOptional(filtersJSON, &chart.Filters); err != nil {
+		return nil, err
+	}
+	return chart, nil
+}
+
+// validateDashboard trims a dashboard's name and fills in an empty layout and widget list
+func validateDashboard(dashboard *AnalyticsDashboard) error {
+	dashboard.Name = strings.TrimSpace(dashboard.Name)
+	if dashboard.Name == "" {
+		return ErrInvalidDashboard
+	}
+	if dashboard.Layout == nil {
+		dashboard.Layout = map[string]interface{}{}
+	}
+	if dashboard.Widgets == nil {
+		dashboard.Widgets = []interface{}{}
+	}
+	return nil
+}
+
+// validateSavedChart trims a chart's fields, checks its type and fills in an empty config
+func validateSavedChart(chart *SavedChart) error {
+	chart.Name = strings.TrimSpace(chart.Name)
+	chart.DataSource = strings.TrimSpace(chart.DataSource)
+	chart.ChartType = strings.ToLower(strings.TrimSpace(chart.ChartType))
+	if chart.Name == "" || chart.DataSource == "" || !validSavedChartTypes[chart.ChartType] {
+		return ErrInvalidSavedChart
+	}
+	if chart.Config == nil {
+		chart.Config = map[string]interface{}{}
+	}
+	return nil
+}
+
+// GetDashboards lists the dashboards a user can see: their own and every public dashboard
+func GetDashboards(userID int) ([]AnalyticsDashboard, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT `+dashboardColumns+`
+		FROM analytics_dashboards
+		WHERE created_by = $1 OR is_public = true
+		ORDER BY is_default DESC, name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dashboards := []AnalyticsDashboard{}
+	for rows.Next() {
+		dashboard, err := scanDashboard(rows)
+		if err != nil {
+			return nil, err
+		}
+		dashboards = append(dashboards, *dashboard)
+	}
+	return dashboards, rows.Err()
+}
+
+// CreateDashboard saves a new dashboard. Marking it as default unmarks the owner's other dashboards.
+func CreateDashboard(dashboard *AnalyticsDashboard) error {
+	if err := validateDashboard(dashboard); err != nil {
+		return err
+	}
+	layout, widgets, err := marshalDashboard(dashboard)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if dashboard.IsDefault {
+		if err := clearDefaultDashboard(tx, dashboard.CreatedBy, 0); err != nil {
+			return err
+		}
+	}
+	if err := tx.QueryRow(`
+		INSERT INTO analytics_dashboards (name, description, created_by, layout, widgets, is_default, is_public)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`,
+		dashboard.Name, dashboard.Description, dashboard.CreatedBy, layout, widgets, dashboard.IsDefault,
+		dashboard.IsPublic).Scan(&dashboard.ID, &dashboard.CreatedAt, &dashboard.UpdatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateDashboard replaces a dashboard's name, description, layout, widgets and flags. Marking it as
+// default unmarks the owner's other dashboards.
+func UpdateDashboard(dashboard *AnalyticsDashboard) error {
+	if err := validateDashboard(dashboard); err != nil {
+		return err
+	}
+	layout, widgets, err := marshalDashboard(dashboard)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if dashboard.IsDefault {
+		if err := clearDefaultDashboard(tx, dashboard.CreatedBy, dashboard.ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.QueryRow(`
+		UPDATE analytics_dashboards
+		SET name = $1, description = $2, layout = $3, widgets = $4, is_default = $5, is_public = $6,
+			updated_at = NOW()
+		WHERE id = $7
+		RETURNING updated_at`,
+		dashboard.Name, dashboard.Description, layout, widgets, dashboard.IsDefault, dashboard.IsPublic,
+		dashboard.ID).Scan(&dashboard.UpdatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteDashboard removes a dashboard along with its sharing permissions
+func DeleteDashboard(id int) error {
+	result, err := db.DB.Exec("DELETE FROM analytics_dashboards WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// marshalDashboard encodes a dashboard's layout and widgets for storage
+func marshalDashboard(dashboard *AnalyticsDashboard) (string, string, error) {
+	layout, err := json.Marshal(dashboard.Layout)
+	if err != nil {
+		return "", "", err
+	}
+	widgets, err := json.Marshal(dashboard.Widgets)
+	if err != nil {
+		return "", "", err
+	}
+	return string(layout), string(widgets), nil
+}
+
+// clearDefaultDashboard unmarks every default dashboard of a user other than exceptID
+func clearDefaultDashboard(tx *sql.Tx, userID, exceptID int) error {
+	_, err := tx.Exec(`
+		UPDATE analytics_dashboards SET is_default = false, updated_at = NOW()
+		WHERE created_by = $1 AND is_default = true AND id <> $2`, userID, exceptID)
+	return err
+}
+
+// GetSavedCharts lists the charts a user can see: their own and every public chart
+func GetSavedCharts(userID int) ([]SavedChart, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT `+savedChartColumns+`
+		FROM saved_charts
+		WHERE created_by = $1 OR is_public = true
+		ORDER BY name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	charts := []SavedChart{}
+	for rows.Next() {
+		chart, err := scanSavedChart(rows)
+		if err != nil {
+			return nil, err
+		}
+		charts = append(charts, *chart)
+	}
+	return charts, rows.Err()
+}
+
+// GetSavedChartByID retrieves a saved chart
+func GetSavedChartByID(id int) (*SavedChart, error) {
+	return scanSavedChart(db.ReadDB().QueryRow(`SELECT `+savedChartColumns+` FROM saved_charts WHERE id = $1`, id))
+}
+
+// CreateSavedChart saves a new chart configuration
+func CreateSavedChart(chart *SavedChart) error {
+	if err := validateSavedChart(chart); err != nil {
+		return err
+	}
+	config, filters, err := marshalSavedChart(chart)
+	if err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO saved_charts (name, description, chart_type, data_source, config, filters, created_by, is_public)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		chart.Name, chart.Description, chart.ChartType, chart.DataSource, config, filters, chart.CreatedBy,
+		chart.IsPublic).Scan(&chart.ID, &chart.CreatedAt, &chart.UpdatedAt)
+}
+
+// UpdateSavedChart replaces a saved chart's configuration
+func UpdateSavedChart(chart *SavedChart) error {
+	if err := validateSavedChart(chart); err != nil {
+		return err
+	}
+	config, filters, err := marshalSavedChart(chart)
+	if err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		UPDATE saved_charts
+		SET name = $1, description = $2, chart_type = $3, data_source = $4, config = $5, filters = $6,
+			is_public = $7, updated_at = NOW()
+		WHERE id = $8
+		RETURNING updated_at`,
+		chart.Name, chart.Description, chart.ChartType, chart.DataSource, config, filters, chart.IsPublic,
+		chart.ID).Scan(&chart.UpdatedAt)
+}
+
+// DeleteSavedChart removes a saved chart
+func DeleteSavedChart(id int) error {
+	result, err := db.DB.Exec("DELETE FROM saved_charts WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// marshalSavedChart encodes a chart's config and its optional filters for storage
+func marshalSavedChart(chart *SavedChart) (string, interface{}, error) {
+	config, err := json.Marshal(chart.Config)
+	if err != nil {
+		return "", nil, err
+	}
+	if chart.Filters == nil {
+		return string(config), nil, nil
+	}
+	filters, err := json.Marshal(chart.Filters)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(config), string(filters), nil
+}
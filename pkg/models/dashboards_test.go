@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateDefaultDashboard(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE analytics_dashboards SET is_default = false`).
+		WithArgs(3, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO analytics_dashboards`).
+		WithArgs("Operations", sql.NullString{}, 3, `{}`, `[]`, true, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(8, now, now))
+	mock.ExpectCommit()
+
+	dashboard := &AnalyticsDashboard{Name: " Operations ", CreatedBy: 3, IsDefault: true}
+	require.NoError(t, CreateDashboard(dashboard))
+	assert.Equal(t, 8, dashboard.ID)
+	assert.Equal(t, "Operations", dashboard.Name)
+	assert.NotNil(t, dashboard.Widgets)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Unnamed dashboards are rejected before touching the database
+	assert.ErrorIs(t, CreateDashboard(&AnalyticsDashboard{Name: " "}), ErrInvalidDashboard)
+}
+
+func TestUpdateMissingDashboard(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE analytics_dashboards`).
+		WithArgs("Leasing", sql.NullString{}, `{"columns":2}`, `[]`, false, true, 12).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err := UpdateDashboard(&AnalyticsDashboard{ID: 12, Name: "Leasing", IsPublic: true,
+		Layout: map[string]interface{}{"columns": 2}})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSavedCharts(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM saved_charts WHERE created_by = \$1 OR is_public = true`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "chart_type", "data_source",
+			"config", "filters", "created_by", "is_public", "created_at", "updated_at"}).
+			AddRow(1, "Rent by month", nil, "bar", "payments", `{"stacked":true}`, nil, 5, false, now, now).
+			AddRow(2, "Occupancy", "Shared", "line", "properties", `{}`, `{"property_id":4}`, 9, true, now, now))
+
+	charts, err := GetSavedCharts(5)
+	require.NoError(t, err)
+	require.Len(t, charts, 2)
+	assert.Equal(t, true, charts[0].Config["stacked"])
+	assert.Nil(t, charts[0].Filters)
+	assert.Equal(t, float64(4), charts[1].Filters["property_id"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateSavedChartValidation(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectQuery(`INSERT INTO saved_charts`).
+		WithArgs("Work orders", sql.NullString{}, "pie", "maintenance", `{}`, nil, 2, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(6, now, now))
+
+	chart := &SavedChart{Name: "Work orders", ChartType: "Pie", DataSource: "maintenance", CreatedBy: 2}
+	require.NoError(t, CreateSavedChart(chart))
+	assert.Equal(t, 6, chart.ID)
+	assert.Equal(t, "pie", chart.ChartType)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.ErrorIs(t, CreateSavedChart(&SavedChart{Name: "Radar", ChartType: "radar", DataSource: "payments"}),
+		ErrInvalidSavedChart)
+	assert.ErrorIs(t, CreateSavedChart(&SavedChart{Name: "No source", ChartType: "bar"}), ErrInvalidSavedChart)
+}
+
+func TestDeleteMissingSavedChart(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectExec(`DELETE FROM saved_charts WHERE id`).
+		WithArgs(40).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, DeleteSavedChart(40), sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Backup methods, sources and statuses
+const (
+	BackupMethodPgDump  = "pg_dump"
+	BackupMethodWebhook = "webhook"
+
+	BackupManual    = "manual"
+	BackupScheduled = "scheduled"
+
+	BackupRunning   = "running"
+	BackupSucceeded = "succeeded"
+	BackupFailed    = "failed"
+
+	VerifySkipped = "skipped"
+	VerifyPassed  = "passed"
+	VerifyFailed  = "failed"
+)
+
+// ErrBackupRunning is returned when a backup is requested while another is still in progress
+var ErrBackupRunning = errors.New("a backup is already running")
+
+// backupStaleAfter is how long a running backup blocks new ones; after that it is assumed to
+// have died with the server
+const backupStaleAfter = 6 * time.Hour
+
+// DatabaseBackup records one backup attempt
+type DatabaseBackup struct {
+	ID             int            `json:"id"`
+	Method         string         `json:"method"`
+	Source         string         `json:"source"`
+	Status         string         `json:"status"`
+	Location       sql.NullString `json:"location,omitempty"`
+	SizeBytes      sql.NullInt64  `json:"size_bytes,omitempty"`
+	Checksum       sql.NullString `json:"checksum,omitempty"`
+	Error          sql.NullString `json:"error,omitempty"`
+	VerifyStatus   string         `json:"verify_status"`
+	VerifyError    sql.NullString `json:"verify_error,omitempty"`
+	VerifiedTables sql.NullInt32  `json:"verified_tables,omitempty"`
+	RequestedBy    sql.NullInt32  `json:"requested_by,omitempty"`
+	StartedAt      time.Time      `json:"started_at"`
+	FinishedAt     sql.NullTime   `json:"finished_at,omitempty"`
+}
+
+// StartDatabaseBackup records a new running backup, refusing if another one is in progress
+func StartDatabaseBackup(backup *DatabaseBackup) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var running int
+	err = tx.QueryRow("SELECT COUNT(*) FROM database_backups WHERE status = 'running' AND started_at > $1",
+		time.Now().Add(-backupStaleAfter)).Scan(&running)
+	if err != nil {
+		return err
+	}
+	if running > 0 {
+		return ErrBackupRunning
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO database_backups (method, source, requested_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, status, verify_status, started_at`,
+		backup.Method, backup.Source, backup.RequestedBy).
+		Scan(&backup.ID, &backup.Status, &backup.VerifyStatus, &backup.StartedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FinishDatabaseBackup records the outcome of a backup and its verification
+func FinishDatabaseBackup(backup *DatabaseBackup) error {
+	_, err := db.DB.Exec(`
+		UPDATE database_backups
+		SET status = $2, location = $3, size_bytes = $4, checksum = $5, error = $6,
+			verify_status = $7, verify_error = $8, verified_tables = $9, finished_at = NOW()
+		WHERE id = $1`,
+		backup.ID, backup.Status, backup.Location, backup.SizeBytes, backup.Checksum, backup.Error,
+		backup.VerifyStatus, backup.VerifyError, backup.VerifiedTables)
+	return err
+}
+
+const databaseBackupColumns = `id, method, source, status, location, size_bytes, checksum, error,
+	verify_status, verify_error, verified_tables, requested_by, started_at, finished_at`
+
+func scanDatabaseBackup(row interface{ Scan(...interface{}) error }) (DatabaseBackup, error) {
+	var b DatabaseBackup
+	err := row.Scan(&b.ID, &b.Method, &b.Source, &b.Status, &b.Location, &b.SizeBytes, &b.Checksum, &b.Error,
+		&b.VerifyStatus, &b.VerifyError, &b.VerifiedTables, &b.RequestedBy, &b.StartedAt, &b.FinishedAt)
+	return b, err
+}
+
+// GetDatabaseBackup retrieves a backup by ID
+func GetDatabaseBackup(id int) (*DatabaseBackup, error) {
+	backup, err := scanDatabaseBackup(db.DB.QueryRow("SELECT "+databaseBackupColumns+" FROM database_backups WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+// GetDatabaseBackups returns the most recent backups, newest first
+func GetDatabaseBackups(limit int) ([]DatabaseBackup, error) {
+	rows, err := db.DB.Query("SELECT "+databaseBackupColumns+" FROM database_backups ORDER BY started_at DESC, id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backups []DatabaseBackup
+	for rows.Next() {
+		backup, err := scanDatabaseBackup(rows)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+	return backups, rows.Err()
+}
+
+// GetLastBackupStart returns when the most recent backup was started, or the zero time if none
+// has been taken
+func GetLastBackupStart() (time.Time, error) {
+	var started time.Time
+	err := db.DB.QueryRow("SELECT started_at FROM database_backups ORDER BY started_at DESC LIMIT 1").Scan(&started)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return started, err
+}
@@ -0,0 +1,517 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Definition bundle format written by ExportDefinitions
+const (
+	DefinitionBundleFormat  = "fire-pmaas/definitions"
+	DefinitionBundleVersion = 1
+)
+
+var (
+	// ErrUnsupportedBundle is returned when importing a file that is not a definition bundle
+	// this version understands
+	ErrUnsupportedBundle = errors.New("unsupported definition bundle format or version")
+	// ErrInvalidDefinition is returned when a bundled definition is missing required fields
+	ErrInvalidDefinition = errors.New("invalid definition")
+	// ErrDefinitionNotFound is returned when exporting a definition that does not exist
+	ErrDefinitionNotFound = errors.New("definition not found")
+	// ErrUnknownOwner is returned when an owner mapping names a user that does not exist
+	ErrUnknownOwner = errors.New("owner mapping refers to an unknown user")
+)
+
+// DefinitionOwner identifies a definition's owner in the source environment. The email is used
+// to find the same person when the bundle is imported elsewhere.
+type DefinitionOwner struct {
+	ID       int    `json:"id"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// ReportDefinition is a custom report's configuration without its execution history
+type ReportDefinition struct {
+	ID           int                    `json:"id"`
+	Owner        DefinitionOwner        `json:"owner"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	ReportType   string                 `json:"report_type"`
+	Criteria     map[string]interface{} `json:"criteria"`
+	Columns      []string               `json:"columns"`
+	ChartConfig  map[string]interface{} `json:"chart_config,omitempty"`
+	IsPublic     bool                   `json:"is_public"`
+	IsScheduled  bool                   `json:"is_scheduled"`
+	ScheduleCron string                 `json:"schedule_cron,omitempty"`
+}
+
+// ChartDefinition is a saved chart's configuration
+type ChartDefinition struct {
+	ID          int                    `json:"id"`
+	Owner       DefinitionOwner        `json:"owner"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	ChartType   string                 `json:"chart_type"`
+	DataSource  string                 `json:"data_source"`
+	Config      map[string]interface{} `json:"config"`
+	Filters     map[string]interface{} `json:"filters,omitempty"`
+	IsPublic    bool                   `json:"is_public"`
+}
+
+// DashboardDefinition is a dashboard's layout and widgets. Widgets refer to bundled reports
+// and charts by their report_id and chart_id, which are remapped on import.
+type DashboardDefinition struct {
+	ID          int                    `json:"id"`
+	Owner       DefinitionOwner        `json:"owner"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Layout      map[string]interface{} `json:"layout"`
+	Widgets     []interface{}          `json:"widgets"`
+	IsDefault   bool                   `json:"is_default"`
+	IsPublic    bool                   `json:"is_public"`
+}
+
+// DefinitionBundle is a portable set of report, chart and dashboard definitions, used to
+// promote them from one environment to another
+type DefinitionBundle struct {
+	Format     string                `json:"format"`
+	Version    int                   `json:"version"`
+	ExportedAt time.Time             `json:"exported_at"`
+	Reports    []ReportDefinition    `json:"reports"`
+	Charts     []ChartDefinition     `json:"charts"`
+	Dashboards []DashboardDefinition `json:"dashboards"`
+}
+
+// DefinitionSelection chooses the definitions to export. All exports every definition;
+// otherwise the listed definitions are exported along with the reports and charts their
+// dashboards' widgets refer to.
+type DefinitionSelection struct {
+	All          bool
+	ReportIDs    []int
+	ChartIDs     []int
+	DashboardIDs []int
+}
+
+// DefinitionImportResult maps each imported definition's source ID to its new ID
+type DefinitionImportResult struct {
+	Reports    map[int]int `json:"reports"`
+	Charts     map[int]int `json:"charts"`
+	Dashboards map[int]int `json:"dashboards"`
+}
+
+// ExportDefinitions builds a bundle of the selected definitions
+func ExportDefinitions(sel DefinitionSelection) (*DefinitionBundle, error) {
+	bundle := &DefinitionBundle{
+		Format:     DefinitionBundleFormat,
+		Version:    DefinitionBundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Reports:    []ReportDefinition{},
+		Charts:     []ChartDefinition{},
+		Dashboards: []DashboardDefinition{},
+	}
+
+	if sel.All {
+		var err error
+		if bundle.Reports, err = queryReportDefinitions("", nil); err != nil {
+			return nil, err
+		}
+		if bundle.Charts, err = queryChartDefinitions("", nil); err != nil {
+			return nil, err
+		}
+		if bundle.Dashboards, err = queryDashboardDefinitions("", nil); err != nil {
+			return nil, err
+		}
+		return bundle, nil
+	}
+
+	reportIDs := append([]int{}, sel.ReportIDs...)
+	chartIDs := append([]int{}, sel.ChartIDs...)
+	for _, id := range sel.DashboardIDs {
+		dashboards, err := queryDashboardDefinitions("WHERE d.id = $1", []interface{}{id})
+		if err != nil {
+			return nil, err
+		}
+		if len(dashboards) == 0 {
+			return nil, fmt.Errorf("%w: dashboard %d", ErrDefinitionNotFound, id)
+		}
+		bundle.Dashboards = append(bundle.Dashboards, dashboards[0])
+		for _, widget := range dashboards[0].Widgets {
+			if id, ok := widgetReference(widget, "report_id"); ok {
+				reportIDs = append(reportIDs, id)
+			}
+			if id, ok := widgetReference(widget, "chart_id"); ok {
+				chartIDs = append(chartIDs, id)
+			}
+		}
+	}
+
+	seen := map[int]bool{}
+	for _, id := range reportIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		reports, err := queryReportDefinitions("WHERE r.id = $1", []interface{}{id})
+		if err != nil {
+			return nil, err
+		}
+		if len(reports) == 0 {
+			return nil, fmt.Errorf("%w: report %d", ErrDefinitionNotFound, id)
+		}
+		bundle.Reports = append(bundle.Reports, reports[0])
+	}
+
+	seen = map[int]bool{}
+	for _, id := range chartIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		charts, err := queryChartDefinitions("WHERE c.id = $1", []interface{}{id})
+		if err != nil {
+			return nil, err
+		}
+		if len(charts) == 0 {
+			return nil, fmt.Errorf("%w: chart %d", ErrDefinitionNotFound, id)
+		}
+		bundle.Charts = append(bundle.Charts, charts[0])
+	}
+	return bundle, nil
+}
+
+func queryReportDefinitions(where string, args []interface{}) ([]ReportDefinition, error) {
+	rows, err := db.DB.Query(`
+		SELECT r.id, u.id, u.username, u.email, r.name, r.description, r.report_type, r.criteria,
+			   r.columns, r.chart_config, r.is_public, r.is_scheduled, r.schedule_cron
+		FROM custom_reports r
+		JOIN users u ON u.id = r.created_by
+		`+where+`
+		ORDER BY r.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []ReportDefinition{}
+	for rows.Next() {
+		var d ReportDefinition
+		var description, scheduleCron sql.NullString
+		var criteriaJSON, chartConfigJSON []byte
+		var columns StringArray
+		if err := rows.Scan(&d.ID, &d.Owner.ID, &d.Owner.Username, &d.Owner.Email, &d.Name, &description,
+			&d.ReportType, &criteriaJSON, &columns, &chartConfigJSON, &d.IsPublic, &d.IsScheduled,
+			&scheduleCron); err != nil {
+			return nil, err
+		}
+		d.Description = description.String
+		d.ScheduleCron = scheduleCron.String
+		d.Columns = columns
+		if err := unmarshalOptional(criteriaJSON, &d.Criteria); err != nil {
+			return nil, err
+		}
+		if err := unmarshalOptional(chartConfigJSON, &d.ChartConfig); err != nil {
+			return nil, err
+		}
+		reports = append(reports, d)
+	}
+	return reports, rows.Err()
+}
+
+func queryChartDefinitions(where string, args []interface{}) ([]ChartDefinition, error) {
+	rows, err := db.DB.Query(`
+		SELECT c.id, u.id, u.username, u.email, c.name, c.description, c.chart_type, c.data_source,
+			   c.config, c.filters, c.is_public
+		FROM saved_charts c
+		JOIN users u ON u.id = c.created_by
+		`+where+`
+		ORDER BY c.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	charts := []ChartDefinition{}
+	for rows.Next() {
+		var d ChartDefinition
+		var description sql.NullString
+		var configJSON, filtersJSON []byte
+		if err := rows.Scan(&d.ID, &d.Owner.ID, &d.Owner.Username, &d.Owner.Email, &d.Name, &description,
+			&d.ChartType, &d.DataSource, &configJSON, &filtersJSON, &d.IsPublic); err != nil {
+			return nil, err
+		}
+		d.Description = description.String
+		if err := unmarshalOptional(configJSON, &d.Config); err != nil {
+			return nil, err
+		}
+		if err := unmarshalOptional(filtersJSON, &d.Filters); err != nil {
+			return nil, err
+		}
+		charts = append(charts, d)
+	}
+	return charts, rows.Err()
+}
+
+func queryDashboardDefinitions(where string, args []interface{}) ([]DashboardDefinition, error) {
+	rows, err := db.DB.Query(`
+		SELECT d.id, u.id, u.username, u.email, d.name, d.description, d.layout, d.widgets,
+			   d.is_default, d.is_public
+		FROM analytics_dashboards d
+		JOIN users u ON u.id = d.created_by
+		`+where+`
+		ORDER BY d.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dashboards := []DashboardDefinition{}
+	for rows.Next() {
+		var d DashboardDefinition
+		var description sql.NullString
+		var layoutJSON, widgetsJSON []byte
+		if err := rows.Scan(&d.ID, &d.Owner.ID, &d.Owner.Username, &d.Owner.Email, &d.Name, &description,
+			&layoutJSON, &widgetsJSON, &d.IsDefault, &d.IsPublic); err != nil {
+			return nil, err
+		}
+		d.Description = description.String
+		if err := unmarshalOptional(layoutJSON, &d.Layout); err != nil {
+			return nil, err
+		}
+		if err := unmarshalOptional(widgetsJSON, &d.Widgets); err != nil {
+			return nil, err
+		}
+		dashboards = append(dashboards, d)
+	}
+	return dashboards, rows.Err()
+}
+
+// unmarshalOptional decodes a nullable JSON column, leaving v unchanged when it is NULL
+func unmarshalOptional(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// widgetReference returns the integer ID a widget stores under key
+func widgetReference(widget interface{}, key string) (int, bool) {
+	fields, ok := widget.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	switch v := fields[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
+
+// validate checks the fields the definition tables require
+func (b *DefinitionBundle) validate() error {
+	if b.Format != DefinitionBundleFormat || b.Version < 1 || b.Version > DefinitionBundleVersion {
+		return ErrUnsupportedBundle
+	}
+	for _, d := range b.Reports {
+		if d.Name == "" || d.ReportType == "" || len(d.Columns) == 0 {
+			return fmt.Errorf("%w: report %d needs a name, report_type and columns", ErrInvalidDefinition, d.ID)
+		}
+		if err := ValidateComputedColumns(d.Criteria); err != nil {
+			return fmt.Errorf("%w: report %d: %v", ErrInvalidDefinition, d.ID, err)
+		}
+	}
+	for _, d := range b.Charts {
+		if d.Name == "" || d.ChartType == "" || d.DataSource == "" {
+			return fmt.Errorf("%w: chart %d needs a name, chart_type and data_source", ErrInvalidDefinition, d.ID)
+		}
+	}
+	for _, d := range b.Dashboards {
+		if d.Name == "" {
+			return fmt.Errorf("%w: dashboard %d needs a name", ErrInvalidDefinition, d.ID)
+		}
+	}
+	return nil
+}
+
+// ownerResolver maps source owners to users in this environment: an explicit mapping from
+// source user ID wins, then a user with the same email, then the importing user
+type ownerResolver struct {
+	q            Querier
+	ownerMap     map[int]int
+	defaultOwner int
+	resolved     map[int]int
+}
+
+func (o *ownerResolver) resolve(owner DefinitionOwner) (int, error) {
+	if id, ok := o.resolved[owner.ID]; ok {
+		return id, nil
+	}
+
+	id := o.defaultOwner
+	if mapped, ok := o.ownerMap[owner.ID]; ok {
+		var exists int
+		err := o.q.QueryRow("SELECT 1 FROM users WHERE id = $1", mapped).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("%w: %d", ErrUnknownOwner, mapped)
+		}
+		if err != nil {
+			return 0, err
+		}
+		id = mapped
+	} else if owner.Email != "" {
+		var match int
+		err := o.q.QueryRow("SELECT id FROM users WHERE LOWER(email) = LOWER($1)", owner.Email).Scan(&match)
+		if err == nil {
+			id = match
+		} else if err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+	o.resolved[owner.ID] = id
+	return id, nil
+}
+
+// ImportDefinitions creates every definition in the bundle as a new record, assigning owners
+// through ownerMap (source user ID to local user ID) and rewriting dashboard widget references
+// to the newly created reports and charts. Nothing is imported if any definition fails.
+func ImportDefinitions(bundle *DefinitionBundle, ownerMap map[int]int, defaultOwner int) (*DefinitionImportResult, error) {
+	if err := bundle.validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	owners := &ownerResolver{q: tx, ownerMap: ownerMap, defaultOwner: defaultOwner, resolved: map[int]int{}}
+	result := &DefinitionImportResult{Reports: map[int]int{}, Charts: map[int]int{}, Dashboards: map[int]int{}}
+
+	for _, d := range bundle.Reports {
+		ownerID, err := owners.resolve(d.Owner)
+		if err != nil {
+			return nil, err
+		}
+		criteriaJSON, err := json.Marshal(orEmptyMap(d.Criteria))
+		if err != nil {
+			return nil, err
+		}
+		chartConfigJSON, err := json.Marshal(d.ChartConfig)
+		if err != nil {
+			return nil, err
+		}
+		var id int
+		err = tx.QueryRow(`
+			INSERT INTO custom_reports (name, description, report_type, created_by, criteria, columns,
+									  chart_config, is_public, is_scheduled, schedule_cron)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id`,
+			d.Name, NullString(d.Description), d.ReportType, ownerID, criteriaJSON, StringArray(d.Columns),
+			chartConfigJSON, d.IsPublic, d.IsScheduled, NullString(d.ScheduleCron)).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		result.Reports[d.ID] = id
+	}
+
+	for _, d := range bundle.Charts {
+		ownerID, err := owners.resolve(d.Owner)
+		if err != nil {
+			return nil, err
+		}
+		configJSON, err := json.Marshal(orEmptyMap(d.Config))
+		if err != nil {
+			return nil, err
+		}
+		filtersJSON, err := json.Marshal(d.Filters)
+		if err != nil {
+			return nil, err
+		}
+		var id int
+		err = tx.QueryRow(`
+			INSERT INTO saved_charts (name, description, chart_type, data_source, config, filters,
+									  created_by, is_public)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id`,
+			d.Name, NullString(d.Description), d.ChartType, d.DataSource, configJSON, filtersJSON,
+			ownerID, d.IsPublic).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		result.Charts[d.ID] = id
+	}
+
+	for _, d := range bundle.Dashboards {
+		ownerID, err := owners.resolve(d.Owner)
+		if err != nil {
+			return nil, err
+		}
+		layoutJSON, err := json.Marshal(orEmptyMap(d.Layout))
+		if err != nil {
+			return nil, err
+		}
+		widgetsJSON, err := json.Marshal(remapWidgets(d.Widgets, result))
+		if err != nil {
+			return nil, err
+		}
+		var id int
+		err = tx.QueryRow(`
+			INSERT INTO analytics_dashboards (name, description, created_by, layout, widgets, is_default, is_public)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`,
+			d.Name, NullString(d.Description), ownerID, layoutJSON, widgetsJSON, d.IsDefault, d.IsPublic).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		result.Dashboards[d.ID] = id
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// remapWidgets returns copies of widgets whose report_id and chart_id point at the imported
+// definitions. References to definitions outside the bundle are left unchanged.
+func remapWidgets(widgets []interface{}, result *DefinitionImportResult) []interface{} {
+	remapped := make([]interface{}, 0, len(widgets))
+	for _, widget := range widgets {
+		fields, ok := widget.(map[string]interface{})
+		if !ok {
+			remapped = append(remapped, widget)
+			continue
+		}
+		copied := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			copied[k] = v
+		}
+		if id, ok := widgetReference(fields, "report_id"); ok {
+			if newID, ok := result.Reports[id]; ok {
+				copied["report_id"] = newID
+			}
+		}
+		if id, ok := widgetReference(fields, "chart_id"); ok {
+			if newID, ok := result.Charts[id]; ok {
+				copied["chart_id"] = newID
+			}
+		}
+		remapped = append(remapped, copied)
+	}
+	return remapped
+}
+
+func orEmptyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportDefinitionsRemapsOwnersAndWidgets(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	bundle := &DefinitionBundle{
+		Format:  DefinitionBundleFormat,
+		Version: DefinitionBundleVersion,
+		Reports: []ReportDefinition{{
+			ID: 10, Owner: DefinitionOwner{ID: 3, Email: "ana@example.com"},
+			Name: "Occupancy", ReportType: "property", Columns: []string{"name"},
+		}},
+		Dashboards: []DashboardDefinition{{
+			ID: 20, Owner: DefinitionOwner{ID: 4, Email: "nobody@example.com"}, Name: "Overview",
+			Widgets: []interface{}{map[string]interface{}{"type": "report", "report_id": float64(10)}},
+		}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT 1 FROM users WHERE id = \$1`).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO custom_reports`).
+		WithArgs("Occupancy", sql.NullString{}, "property", 42, []byte("{}"), sqlmock.AnyArg(), []byte("null"),
+			false, false, sql.NullString{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(110))
+	mock.ExpectQuery(`SELECT id FROM users WHERE LOWER\(email\) = LOWER\(\$1\)`).WithArgs("nobody@example.com").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`INSERT INTO analytics_dashboards`).
+		WithArgs("Overview", sql.NullString{}, 1, []byte("{}"), []byte(`[{"report_id":110,"type":"report"}]`), false, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(120))
+	mock.ExpectCommit()
+
+	result, err := ImportDefinitions(bundle, map[int]int{3: 42}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{10: 110}, result.Reports)
+	assert.Equal(t, map[int]int{20: 120}, result.Dashboards)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportDefinitionsRejectsUnknownFormat(t *testing.T) {
+	_, err := ImportDefinitions(&DefinitionBundle{Format: "other", Version: 1}, nil, 1)
+	assert.Equal(t, ErrUnsupportedBundle, err)
+
+	_, err = ImportDefinitions(&DefinitionBundle{
+		Format: DefinitionBundleFormat, Version: 1,
+		Charts: []ChartDefinition{{ID: 5, Name: "Rent"}},
+	}, nil, 1)
+	assert.ErrorIs(t, err, ErrInvalidDefinition)
+}
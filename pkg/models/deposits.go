@@ -0,0 +1,356 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// DepositRule limits the security deposits taken under a jurisdiction, either by default or for one property
+type DepositRule struct {
+	ID                  int             `json:"id"`
+	PropertyID          sql.NullInt32   `json:"property_id,omitempty"`
+	Jurisdiction        sql.NullString  `json:"jurisdiction,omitempty"`
+	MaxMonthsRent       sql.NullFloat64 `json:"max_months_rent,omitempty"`
+	MaxAmount           sql.NullFloat64 `json:"max_amount,omitempty"`
+	InterestRatePercent sql.NullFloat64 `json:"interest_rate_percent,omitempty"`
+	EscrowRequired      bool            `json:"escrow_required"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+}
+
+// DepositViolationError is returned when a lease's deposit breaks its jurisdiction's rule
+type DepositViolationError struct {
+	Jurisdiction string
+	Violations   []string
+}
+
+func (e *DepositViolationError) Error() string {
+	return fmt.Sprintf("deposit does not comply with %s rules: %s", e.Jurisdiction, strings.Join(e.Violations, "; "))
+}
+
+// DepositComplianceIssue is a lease whose deposit breaks the configured rules
+type DepositComplianceIssue struct {
+	LeaseID         int            `json:"lease_id"`
+	PropertyName    string         `json:"property_name"`
+	UnitNumber      string         `json:"unit_number"`
+	TenantName      string         `json:"tenant_name"`
+	MonthlyRent     float64        `json:"monthly_rent"`
+	SecurityDeposit float64        `json:"security_deposit"`
+	EscrowAccount   sql.NullString `json:"escrow_account,omitempty"`
+	Jurisdiction    string         `json:"jurisdiction"`
+	Violations      []string       `json:"violations"`
+	InterestOwed    float64        `json:"interest_owed"`
+}
+
+// ValidateDeposit checks a deposit against a rule and returns a description of each violation
+func ValidateDeposit(rule *DepositRule, monthlyRent, deposit float64, escrowAccount string) []string {
+	var violations []string
+	if rule == nil || deposit <= 0 {
+		return violations
+	}
+
+	if rule.MaxMonthsRent.Valid {
+		if limit := monthlyRent * rule.MaxMonthsRent.Float64; deposit > limit {
+			violations = append(violations, fmt.Sprintf("deposit %.2f exceeds %.2g months' rent (%.2f)",
+				deposit, rule.MaxMonthsRent.Float64, limit))
+		}
+	}
+	if rule.MaxAmount.Valid && deposit > rule.MaxAmount.Float64 {
+		violations = append(violations, fmt.Sprintf("deposit %.2f exceeds the maximum of %.2f", deposit, rule.MaxAmount.Float64))
+	}
+	if rule.EscrowRequired && strings.TrimSpace(escrowAccount) == "" {
+		violations = append(violations, "deposit must be held in an escrow account")
+	}
+	return violations
+}
+
+// DepositInterestOwed calculates simple interest on a deposit from the date it was received
+func DepositInterestOwed(deposit, ratePercent float64, received, now time.Time) float64 {
+	if deposit <= 0 || ratePercent <= 0 || !now.After(received) {
+		return 0
+	}
+	years := now.Sub(received).Hours() / 24 / 365
+	return math.Round(deposit*ratePercent/100*years*100) / 100
+}
+
+// GetDepositRules retrieves all configured rules
+func GetDepositRules() ([]DepositRule, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, property_id, jurisdiction, max_months_rent, max_amount, interest_rate_percent,
+			escrow_required, created_at, updated_at
+		FROM deposit_rules
+		ORDER BY property_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []DepositRule
+	for rows.Next() {
+		var rule DepositRule
+		err := rows.Scan(&rule.ID, &rule.PropertyID, &rule.Jurisdiction, &rule.MaxMonthsRent, &rule.MaxAmount,
+			&rule.InterestRatePercent, &rule.EscrowRequired, &rule.CreatedAt, &rule.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// SaveDepositRule creates or replaces the rule for rule.PropertyID (or the default rule). It
+// returns sql.ErrNoRows if the property does not exist.
+func SaveDepositRule(rule *DepositRule) error {
+	var existingID int
+	var err error
+	if rule.PropertyID.Valid {
+		var exists int
+		if err := db.DB.QueryRow("SELECT 1 FROM properties WHERE id = $1", rule.PropertyID).Scan(&exists); err != nil {
+			return err
+		}
+		err = db.DB.QueryRow("SELECT id FROM deposit_rules WHERE property_id = $1", rule.PropertyID).Scan(&existingID)
+	} else {
+		err = db.DB.QueryRow("SELECT id FROM deposit_rules WHERE property_id IS NULL").Scan(&existingID)
+	}
+
+	switch {
+	case err == sql.ErrNoRows:
+		return db.DB.QueryRow(`
+			INSERT INTO deposit_rules (property_id, jurisdiction, max_months_rent, max_amount, interest_rate_percent, escrow_required)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at, updated_at`,
+			rule.PropertyID, rule.Jurisdiction, rule.MaxMonthsRent, rule.MaxAmount, rule.InterestRatePercent,
+			rule.EscrowRequired).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	case err != nil:
+		return err
+	}
+
+	return db.DB.QueryRow(`
+		UPDATE deposit_rules
+		SET jurisdiction = $2, max_months_rent = $3, max_amount = $4, interest_rate_percent = $5,
+			escrow_required = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING id, created_at, updated_at`,
+		existingID, rule.Jurisdiction, rule.MaxMonthsRent, rule.MaxAmount, rule.InterestRatePercent,
+		rule.EscrowRequired).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+}
+
+// DeleteDepositRule removes a property's rule so the default applies again
+func DeleteDepositRule(propertyID int) error {
+	result, err := db.DB.Exec("DELETE FROM deposit_rules WHERE property_id = $1", propertyID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// depositRuleForProperty picks the property's rule, falling back to the default rule
+func depositRuleForProperty(rules []DepositRule, propertyID int) *DepositRule {
+	var fallback *DepositRule
+	for i := range rules {
+		if rules[i].PropertyID.Valid && int(rules[i].PropertyID.Int32) == propertyID {
+			return &rules[i]
+		}
+		if !rules[i].PropertyID.Valid {
+			fallback = &rules[i]
+		}
+	}
+	return fallback
+}
+
+// jurisdictionName describes the rule in messages
+func (r *DepositRule) jurisdictionName() string {
+	if r.Jurisdiction.Valid && r.Jurisdiction.String != "" {
+		return r.Jurisdiction.String
+	}
+	return "jurisdiction"
+}
+
+// checkLeaseDeposit validates a deposit for a lease on the given unit against the applicable rule
+func checkLeaseDeposit(unitID int, monthlyRent float64, deposit sql.NullFloat64, escrowAccount sql.NullString) error {
+	if !deposit.Valid {
+		return nil
+	}
+
+	var propertyID int
+	if err := db.DB.QueryRow("SELECT property_id FROM property_units WHERE id = $1", unitID).Scan(&propertyID); err != nil {
+		return err
+	}
+
+	rules, err := GetDepositRules()
+	if err != nil {
+		return err
+	}
+	rule := depositRuleForProperty(rules, propertyID)
+	if violations := ValidateDeposit(rule, monthlyRent, deposit.Float64, escrowAccount.String); len(violations) > 0 {
+		return &DepositViolationError{Jurisdiction: rule.jurisdictionName(), Violations: violations}
+	}
+	return nil
+}
+
+// CreateLease validates the lease's deposit against the unit's jurisdiction rule and creates the lease.
+// It returns sql.ErrNoRows if the unit does not exist, ErrAssociationProperty if the unit belongs to an
+// association, ErrInvalidProrationConvention for an unknown convention, and a *DepositViolationError
+// if the deposit is not allowed. The convention defaults to actual_days.
+func CreateLease(lease *Lease) error {
+	if lease.ProrationConvention == "" {
+		lease.ProrationConvention = ProrationActualDays
+	}
+	if !ValidProrationConvention(lease.ProrationConvention) {
+		return ErrInvalidProrationConvention
+	}
+
+	_, mode, err := unitOperatingMode(db.DB, lease.UnitID)
+	if err != nil {
+		return err
+	}
+	if mode == OperatingModeAssociation {
+		return ErrAssociationProperty
+	}
+	if err := checkLeaseDeposit(lease.UnitID, lease.MonthlyRent, lease.SecurityDeposit, lease.DepositEscrowAccount); err != nil {
+		return err
+	}
+	if lease.Status == "" {
+		lease.Status = "pending"
+	}
+
+	return db.DB.QueryRow(`
+		INSERT INTO leases (unit_id, tenant_id, start_date, end_date, monthly_rent, status,
+			security_deposit, deposit_received_date, deposit_escrow_account, proration_convention)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at`,
+		lease.UnitID, lease.TenantID, lease.StartDate, lease.EndDate, lease.MonthlyRent, lease.Status,
+		lease.SecurityDeposit, lease.DepositReceivedDate, lease.DepositEscrowAccount, lease.ProrationConvention,
+	).Scan(&lease.ID, &lease.CreatedAt, &lease.UpdatedAt)
+}
+
+// SetLeaseDeposit records the deposit held for an existing lease after validating it.
+// It returns sql.ErrNoRows if the lease does not exist and a *DepositViolationError if the deposit is not allowed.
+func SetLeaseDeposit(leaseID int, deposit sql.NullFloat64, received sql.NullTime, escrowAccount sql.NullString) error {
+	var unitID int
+	var monthlyRent float64
+	err := db.DB.QueryRow("SELECT unit_id, monthly_rent FROM leases WHERE id = $1", leaseID).Scan(&unitID, &monthlyRent)
+	if err != nil {
+		return err
+	}
+	if err := checkLeaseDeposit(unitID, monthlyRent, deposit, escrowAccount); err != nil {
+		return err
+	}
+
+	result, err := db.DB.Exec(`
+		UPDATE leases
+		SET security_deposit = $2, deposit_received_date = $3, deposit_escrow_account = $4, updated_at = NOW()
+		WHERE id = $1`, leaseID, deposit, received, escrowAccount)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// RecordLeaseNotice sets the date the tenant gave notice to vacate, or clears it when noticeDate
+// is null because the notice was withdrawn. The vacancy forecast counts a lease with notice as
+// ending at its end date.
+func RecordLeaseNotice(leaseID int, noticeDate sql.NullTime) error {
+	result, err := db.DB.Exec(`
+		UPDATE leases
+		SET notice_given_date = $2, updated_at = NOW()
+		WHERE id = $1`, leaseID, noticeDate)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetDepositComplianceIssues lists active and pending leases whose deposits break their rule.
+// Interest owed under the rule is reported for every listed deposit.
+func GetDepositComplianceIssues(properties PropertyFilter, now time.Time) ([]DepositComplianceIssue, error) {
+	rules, err := GetDepositRules()
+	if err != nil {
+		return nil, err
+	}
+
+	scope, args := properties.condition("p.id", nil)
+	rows, err := db.ReadDB().Query(`
+		SELECT l.id, p.id, p.name, COALESCE(pu.unit_number, ''), t.first_name || ' ' || t.last_name,
+			l.monthly_rent, l.security_deposit, COALESCE(l.deposit_received_date, l.start_date), l.deposit_escrow_account
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		JOIN tenants t ON l.tenant_id = t.id
+		WHERE l.status IN ('active', 'pending') AND l.security_deposit > 0`+scope+`
+		ORDER BY p.name, pu.unit_number`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []DepositComplianceIssue
+	for rows.Next() {
+		var issue DepositComplianceIssue
+		var leasePropertyID int
+		var received time.Time
+		if err := rows.Scan(&issue.LeaseID, &leasePropertyID, &issue.PropertyName, &issue.UnitNumber, &issue.TenantName,
+			&issue.MonthlyRent, &issue.SecurityDeposit, &received, &issue.EscrowAccount); err != nil {
+			return nil, err
+		}
+
+		rule := depositRuleForProperty(rules, leasePropertyID)
+		issue.Violations = ValidateDeposit(rule, issue.MonthlyRent, issue.SecurityDeposit, issue.EscrowAccount.String)
+		if len(issue.Violations) == 0 {
+			continue
+		}
+		issue.Jurisdiction = rule.jurisdictionName()
+		if rule.InterestRatePercent.Valid {
+			issue.InterestOwed = DepositInterestOwed(issue.SecurityDeposit, rule.InterestRatePercent.Float64, received, now)
+		}
+		issues = append(issues, issue)
+	}
+
+	return issues, rows.Err()
+}
+
+// generateDepositComplianceReport lists deposits that violate the configured jurisdiction rules
+func generateDepositComplianceReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	issues, err := GetDepositComplianceIssues(reportProperties(report), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	data := &ReportData{
+		Headers: []string{"Property", "Unit", "Tenant", "Monthly Rent", "Security Deposit", "Jurisdiction",
+			"Violations", "Interest Owed"},
+		Rows: []map[string]interface{}{},
+	}
+
+	var totalDeposits, totalInterest float64
+	for _, issue := range issues {
+		data.Rows = append(data.Rows, map[string]interface{}{
+			"Property":         issue.PropertyName,
+			"Unit":             issue.UnitNumber,
+			"Tenant":           issue.TenantName,
+			"Monthly Rent":     issue.MonthlyRent,
+			"Security Deposit": issue.SecurityDeposit,
+			"Jurisdiction":     issue.Jurisdiction,
+			"Violations":       strings.Join(issue.Violations, "; "),
+			"Interest Owed":    issue.InterestOwed,
+		})
+		totalDeposits += issue.SecurityDeposit
+		totalInterest += issue.InterestOwed
+	}
+
+	data.Summary = map[string]interface{}{
+		"violation_count":      len(issues),
+		"deposits_in_question": math.Round(totalDeposits*100) / 100,
+		"interest_owed":        math.Round(totalInterest*100) / 100,
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Monthly Rent": currencyColumn, "Security Deposit": currencyColumn, "Interest Owed": currencyColumn,
+	})
+	return data, nil
+}
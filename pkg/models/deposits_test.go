@@ -0,0 +1,113 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDeposit(t *testing.T) {
+	rule := &DepositRule{
+		MaxMonthsRent:  sql.NullFloat64{Float64: 1.5, Valid: true},
+		MaxAmount:      sql.NullFloat64{Float64: 2500, Valid: true},
+		EscrowRequired: true,
+	}
+
+	assert.Empty(t, ValidateDeposit(rule, 2000, 2000, "Escrow 1234"))
+	assert.Empty(t, ValidateDeposit(nil, 2000, 10000, ""))
+	assert.Empty(t, ValidateDeposit(rule, 2000, 0, ""), "no deposit taken")
+
+	violations := ValidateDeposit(rule, 1000, 3000, " ")
+	assert.Equal(t, []string{
+		"deposit 3000.00 exceeds 1.5 months' rent (1500.00)",
+		"deposit 3000.00 exceeds the maximum of 2500.00",
+		"deposit must be held in an escrow account",
+	}, violations)
+}
+
+func TestDepositInterestOwed(t *testing.T) {
+	received := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 30.0, DepositInterestOwed(2000, 1.5, received, received.AddDate(0, 0, 365)))
+	assert.Equal(t, 15.0, DepositInterestOwed(2000, 1.5, received, received.AddDate(0, 0, 182).Add(12*time.Hour)))
+	assert.Equal(t, 0.0, DepositInterestOwed(2000, 0, received, received.AddDate(1, 0, 0)))
+	assert.Equal(t, 0.0, DepositInterestOwed(2000, 1.5, received, received))
+}
+
+func TestDepositRuleForProperty(t *testing.T) {
+	rules := []DepositRule{
+		{ID: 1},
+		{ID: 2, PropertyID: sql.NullInt32{Int32: 7, Valid: true}},
+	}
+
+	assert.Equal(t, 2, depositRuleForProperty(rules, 7).ID)
+	assert.Equal(t, 1, depositRuleForProperty(rules, 8).ID)
+	assert.Nil(t, depositRuleForProperty(nil, 8))
+}
+
+func TestCreateLeaseRejectsNonCompliantDeposit(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT p.id, p.operating_mode`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "operating_mode"}).AddRow(7, "rental"))
+	mock.ExpectQuery(`SELECT property_id FROM property_units`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"property_id"}).AddRow(7))
+	mock.ExpectQuery(`SELECT (.+) FROM deposit_rules`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "property_id", "jurisdiction", "max_months_rent", "max_amount",
+			"interest_rate_percent", "escrow_required", "created_at", "updated_at"}).
+			AddRow(1, nil, "Default", 2.0, nil, nil, false, time.Now(), time.Now()).
+			AddRow(2, 7, "Massachusetts", 1.0, nil, 5.0, true, time.Now(), time.Now()))
+
+	err := CreateLease(&Lease{
+		UnitID:          4,
+		TenantID:        9,
+		MonthlyRent:     1800,
+		SecurityDeposit: sql.NullFloat64{Float64: 1800, Valid: true},
+	})
+
+	violation, ok := err.(*DepositViolationError)
+	assert.True(t, ok)
+	assert.Equal(t, "Massachusetts", violation.Jurisdiction)
+	assert.Equal(t, []string{"deposit must be held in an escrow account"}, violation.Violations)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveDepositRuleUnknownProperty(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT 1 FROM properties WHERE id`).
+		WithArgs(42).
+		WillReturnError(sql.ErrNoRows)
+
+	err := SaveDepositRule(&DepositRule{
+		PropertyID:   sql.NullInt32{Int32: 42, Valid: true},
+		Jurisdiction: sql.NullString{String: "Massachusetts", Valid: true},
+	})
+
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordLeaseNotice(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	noticeDate := sql.NullTime{Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	mock.ExpectExec(`UPDATE leases\s+SET notice_given_date = \$2`).
+		WithArgs(3, noticeDate).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE leases\s+SET notice_given_date = \$2`).
+		WithArgs(9, sql.NullTime{}).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, RecordLeaseNotice(3, noticeDate))
+	assert.Equal(t, sql.ErrNoRows, RecordLeaseNotice(9, sql.NullTime{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
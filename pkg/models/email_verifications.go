@@ -0,0 +1,139 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/mailer"
+)
+
+// EmailVerificationTTL is how long an email verification link stays valid
+const EmailVerificationTTL = 48 * time.Hour
+
+var (
+	// ErrEmailVerificationInvalid is returned for unknown, expired or already used verification tokens
+	ErrEmailVerificationInvalid = errors.New("verification link is invalid or has expired")
+	// ErrEmailAlreadyVerified is returned when asking to verify an address that already is
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+)
+
+// RequestEmailVerification queues an email asking the user to confirm their address, with the
+// link built by verifyLink. Earlier unused links for the user are revoked.
+func RequestEmailVerification(user *User, verifyLink func(token string) string, now time.Time) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := queueEmailVerification(tx, user, verifyLink, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// queueEmailVerification creates a verification token and queues the verification email
+func queueEmailVerification(q Querier, user *User, verifyLink func(token string) string, now time.Time) error {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(bytes)
+
+	msg, err := mailer.Render("email_verification", map[string]interface{}{
+		"Name": displayName(user),
+		"Link": verifyLink(token),
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = user.Email
+
+	if _, err := q.Exec(
+		"DELETE FROM email_verifications WHERE user_id = $1 AND verified_at IS NULL", user.ID); err != nil {
+		return err
+	}
+	var verificationID int
+	if err := q.QueryRow(`
+		INSERT INTO email_verifications (user_id, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`,
+		user.ID, user.Email, HashAPIKey(token), now.Add(EmailVerificationTTL)).Scan(&verificationID); err != nil {
+		return err
+	}
+	return EnqueueEmail(q, "user.email_verification", msg, map[string]interface{}{
+		"user_id":         user.ID,
+		"verification_id": verificationID,
+	})
+}
+
+// VerifyEmail consumes a plaintext verification token and marks the user's email verified. It
+// returns ErrEmailVerificationInvalid if the token is unknown, expired or used, or if the user
+// has since changed their address.
+func VerifyEmail(token string, now time.Time) (int, error) {
+	var id, userID int
+	var email string
+	var expiresAt time.Time
+	var verifiedAt sql.NullTime
+	err := db.DB.QueryRow(`
+		SELECT id, user_id, email, expires_at, verified_at
+		FROM email_verifications WHERE token_hash = $1`, HashAPIKey(token)).Scan(
+		&id, &userID, &email, &expiresAt, &verifiedAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrEmailVerificationInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	if verifiedAt.Valid || !now.Before(expiresAt) {
+		return 0, ErrEmailVerificationInvalid
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The verified_at check guards against the same token being used twice concurrently
+	result, err := tx.Exec(
+		"UPDATE email_verifications SET verified_at = $1 WHERE id = $2 AND verified_at IS NULL", now, id)
+	if err != nil {
+		return 0, err
+	}
+	if err := requireAffected(result); err != nil {
+		return 0, ErrEmailVerificationInvalid
+	}
+
+	result, err = tx.Exec(
+		"UPDATE users SET email_verified = $1, updated_at = NOW() WHERE id = $2 AND LOWER(email) = LOWER($3)",
+		true, userID, email)
+	if err != nil {
+		return 0, err
+	}
+	if err := requireAffected(result); err != nil {
+		return 0, ErrEmailVerificationInvalid
+	}
+
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    sql.NullInt32{Int32: int32(userID), Valid: true},
+		Action:     "user.email_verified",
+		EntityType: "user",
+		EntityID:   userID,
+		Details:    map[string]interface{}{"email": email},
+	}); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
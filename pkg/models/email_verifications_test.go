@@ -0,0 +1,87 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestEmailVerification(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	payload := &payloadArg{}
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM email_verifications`).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO email_verifications`).
+		WithArgs(7, "jane@example.com", sqlmock.AnyArg(), now.Add(EmailVerificationTTL)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", "user.email_verification", "jane@example.com", payload, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	user := &User{ID: 7, Username: "jdoe", Email: "jane@example.com"}
+	err := RequestEmailVerification(user, func(token string) string { return "https://app/verify-email?token=" + token }, now)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "Verify your email address", payload.payload["subject"])
+	assert.Contains(t, payload.payload["body"], "https://app/verify-email?token=")
+	assert.Equal(t, float64(3), payload.payload["verification_id"])
+
+	user.EmailVerified = true
+	assert.Equal(t, ErrEmailAlreadyVerified, RequestEmailVerification(user, nil, now))
+}
+
+func TestVerifyEmail(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "user_id", "email", "expires_at", "verified_at"}
+	mock.ExpectQuery(`FROM email_verifications WHERE token_hash = \$1`).WithArgs(HashAPIKey("abc")).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 7, "jane@example.com", now.Add(time.Hour), nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE email_verifications SET verified_at = \$1`).WithArgs(now, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET email_verified = \$1`).WithArgs(true, 7, "jane@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 7, Valid: true}, "user.email_verified", "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	mock.ExpectCommit()
+
+	userID, err := VerifyEmail("abc", now)
+	require.NoError(t, err)
+	assert.Equal(t, 7, userID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyEmailRejectsChangedAddress(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "user_id", "email", "expires_at", "verified_at"}
+	mock.ExpectQuery(`FROM email_verifications`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 7, "old@example.com", now.Add(time.Hour), nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE email_verifications SET verified_at`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET email_verified`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err := VerifyEmail("abc", now)
+	assert.Equal(t, ErrEmailVerificationInvalid, err)
+
+	mock.ExpectQuery(`FROM email_verifications`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 7, "jane@example.com", now.Add(-time.Hour), nil))
+	_, err = VerifyEmail("abc", now)
+	assert.Equal(t, ErrEmailVerificationInvalid, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
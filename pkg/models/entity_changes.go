@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// DefaultChangeFeedRetentionDays is how long changes stay in the feed unless configured otherwise
+const DefaultChangeFeedRetentionDays = 90
+
+// ErrInvalidChangeCursor is returned for a change feed cursor that was not issued by the feed
+var ErrInvalidChangeCursor = errors.New("invalid change feed cursor")
+
+// ChangeCursor is a position in the change feed: the transaction that recorded a change and the
+// change's ID. Changes are read in (TxID, ID) order, which, unlike ID order alone, never places a
+// change that commits late behind a cursor a client has already moved past.
+type ChangeCursor struct {
+	TxID int64
+	ID   int64
+}
+
+// String encodes the cursor as "txid:id"
+func (c ChangeCursor) String() string {
+	return fmt.Sprintf("%d:%d", c.TxID, c.ID)
+}
+
+// ParseChangeCursor decodes a cursor returned by the feed. A bare change ID, as issued before
+// changes were stamped with transactions, is read as a cursor at transaction 0.
+func ParseChangeCursor(s string) (ChangeCursor, error) {
+	txid, id, found := strings.Cut(s, ":")
+	if !found {
+		txid, id = "0", s
+	}
+	var c ChangeCursor
+	var err error
+	if c.TxID, err = strconv.ParseInt(txid, 10, 64); err != nil || c.TxID < 0 {
+		return ChangeCursor{}, ErrInvalidChangeCursor
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil || c.ID < 0 {
+		return ChangeCursor{}, ErrInvalidChangeCursor
+	}
+	return c, nil
+}
+
+// ChangeFeedEntityTypes are the entity types recorded in the change feed
+var ChangeFeedEntityTypes = map[string]bool{
+	"property":            true,
+	"unit":                true,
+	"tenant":              true,
+	"lease":               true,
+	"maintenance_request": true,
+	"payment":             true,
+}
+
+// EntityChange is one insert, update or delete on a core entity. Changes only identify the entity;
+// integrators fetch its current state from the entity's own endpoint.
+type EntityChange struct {
+	Cursor     string    `json:"cursor"`
+	EntityType string    `json:"entity_type"`
+	EntityID   int       `json:"entity_id"`
+	Op         string    `json:"op"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// GetEntityChanges returns up to limit changes at properties after the cursor, optionally only for
+// one entity type. Changes of transactions that may still be in progress are held back until every
+// older transaction has finished, so a later page never contains a change ordered before the cursor.
+// Tenant changes are included while the tenant leases at one of the properties. It reads the
+// primary: a replica's snapshot would hold back changes of transactions it has not replayed.
+func GetEntityChanges(cursor ChangeCursor, entityType string, limit int, properties PropertyFilter) ([]EntityChange, error) {
+	args := []interface{}{cursor.TxID, cursor.ID, entityType, limit}
+	scope := ""
+	if properties.Scoped {
+		var propertyScope, tenantScope string
+		propertyScope, args = properties.condition("property_id", args)
+		tenantScope, args = properties.condition("pu.property_id", args)
+		scope = ` AND (1 = 1` + propertyScope + ` OR entity_type = 'tenant' AND EXISTS (
+			SELECT 1 FROM leases l JOIN property_units pu ON pu.id = l.unit_id
+			WHERE l.tenant_id = entity_changes.entity_id` + tenantScope + `))`
+	}
+	rows, err := db.DB.Query(`
+		SELECT txid, id, entity_type, entity_id, op, changed_at
+		FROM entity_changes
+		WHERE (txid > $1 OR txid = $1 AND id > $2) AND txid < `+db.CurrentDialect.SnapshotXmin()+`
+		  AND ($3 = '' OR entity_type = $3)`+scope+`
+		ORDER BY txid, id
+		LIMIT $4`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []EntityChange
+	for rows.Next() {
+		var c EntityChange
+		var position ChangeCursor
+		if err := rows.Scan(&position.TxID, &position.ID, &c.EntityType, &c.EntityID, &c.Op,
+			&c.ChangedAt); err != nil {
+			return nil, err
+		}
+		c.Cursor = position.String()
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// PruneEntityChanges deletes changes recorded before the cutoff and returns how many were deleted.
+// Integrators that fall further behind than the retention must start again with a full sync.
+func PruneEntityChanges(before time.Time) (int64, error) {
+	result, err := db.DB.Exec("DELETE FROM entity_changes WHERE changed_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEntityChanges(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM entity_changes\s+WHERE \(txid > \$1 OR txid = \$1 AND id > \$2\) AND txid < txid_snapshot_xmin`).
+		WithArgs(int64(900), int64(41), "lease", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"txid", "id", "entity_type", "entity_id", "op", "changed_at"}).
+			AddRow(900, 45, "lease", 7, "update", now).
+			AddRow(903, 42, "lease", 7, "insert", now))
+
+	changes, err := GetEntityChanges(ChangeCursor{TxID: 900, ID: 41}, "lease", 3, PropertyFilter{})
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "900:45", changes[0].Cursor)
+	assert.Equal(t, "update", changes[0].Op)
+	assert.Equal(t, "903:42", changes[1].Cursor)
+	assert.Equal(t, 7, changes[1].EntityID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseChangeCursor(t *testing.T) {
+	cursor, err := ParseChangeCursor("903:42")
+	require.NoError(t, err)
+	assert.Equal(t, ChangeCursor{TxID: 903, ID: 42}, cursor)
+
+	// Cursors issued before changes carried transaction IDs
+	cursor, err = ParseChangeCursor("42")
+	require.NoError(t, err)
+	assert.Equal(t, ChangeCursor{ID: 42}, cursor)
+
+	for _, invalid := range []string{"", "x", "1:", ":2", "-1:2", "1:-2"} {
+		_, err = ParseChangeCursor(invalid)
+		assert.ErrorIs(t, err, ErrInvalidChangeCursor, invalid)
+	}
+}
+
+func TestPruneEntityChanges(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	cutoff := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`DELETE FROM entity_changes WHERE changed_at < \$1`).
+		WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+
+	deleted, err := PruneEntityChanges(cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,513 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Lease escalation types
+const (
+	EscalationFixedPercent = "fixed_percent" // Rent rises by Percent every IntervalMonths
+	EscalationCPI          = "cpi"           // Rent follows the CPI series, within the floor and cap
+	EscalationStepped      = "stepped"       // Rent follows an explicit schedule of Steps
+)
+
+// Lease escalation statuses
+const (
+	EscalationStatusScheduled = "scheduled"
+	EscalationStatusApplied   = "applied"
+)
+
+// CPILagMonths is how far before the effective date the CPI reference month falls, allowing
+// for the index being published after the month it measures
+const CPILagMonths = 2
+
+// ErrCPIUnavailable is returned when the index values needed for a CPI escalation are missing
+var ErrCPIUnavailable = errors.New("CPI index value not available")
+
+// RentStep is one entry of a stepped rent schedule
+type RentStep struct {
+	EffectiveDate string  `json:"effective_date"` // YYYY-MM-DD
+	MonthlyRent   float64 `json:"monthly_rent"`
+}
+
+// EscalationRule is the rent escalation clause of a lease
+type EscalationRule struct {
+	ID                 int             `json:"id"`
+	LeaseID            int             `json:"lease_id"`
+	EscalationType     string          `json:"escalation_type"`
+	Percent            sql.NullFloat64 `json:"percent,omitempty"`
+	CPISeries          string          `json:"cpi_series"`
+	CPIFloorPercent    sql.NullFloat64 `json:"cpi_floor_percent,omitempty"`
+	CPICapPercent      sql.NullFloat64 `json:"cpi_cap_percent,omitempty"`
+	IntervalMonths     int             `json:"interval_months"`
+	Steps              []RentStep      `json:"steps,omitempty"`
+	NextEscalationDate sql.NullTime    `json:"next_escalation_date,omitempty"`
+	Active             bool            `json:"active"`
+	CreatedAt          time.Time       `json:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at"`
+}
+
+// LeaseEscalation is a computed rent change, from notice until it takes effect
+type LeaseEscalation struct {
+	ID            int            `json:"id"`
+	LeaseID       int            `json:"lease_id"`
+	RuleID        sql.NullInt32  `json:"rule_id,omitempty"`
+	PropertyName  string         `json:"property_name,omitempty"`
+	UnitNumber    string         `json:"unit_number,omitempty"`
+	TenantName    string         `json:"tenant_name,omitempty"`
+	EffectiveDate time.Time      `json:"effective_date"`
+	OldRent       float64        `json:"old_rent"`
+	NewRent       float64        `json:"new_rent"`
+	Basis         sql.NullString `json:"basis,omitempty"`
+	Status        string         `json:"status"`
+	NotifiedAt    sql.NullTime   `json:"notified_at,omitempty"`
+	AppliedAt     sql.NullTime   `json:"applied_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// DueEscalation is a rule whose next escalation falls within the notice window,
+// with the lease details needed to compute it and notify the tenant
+type DueEscalation struct {
+	Rule         EscalationRule
+	CurrentRent  float64
+	PropertyName string
+	UnitNumber   string
+	TenantName   string
+	TenantEmail  string
+	TenantPhone  sql.NullString
+}
+
+// CPILookup returns the index value of a series for the month starting at month
+type CPILookup func(series string, month time.Time) (float64, bool)
+
+// stepDates returns the rule's step dates in order, skipping any that fail to parse
+func (r *EscalationRule) stepDates() []time.Time {
+	var dates []time.Time
+	for _, step := range r.Steps {
+		if d, err := time.Parse("2006-01-02", step.EffectiveDate); err == nil {
+			dates = append(dates, d)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// FollowingDate returns the escalation date after the one at after, or an invalid time when
+// a stepped schedule has no further steps
+func (r *EscalationRule) FollowingDate(after time.Time) sql.NullTime {
+	if r.EscalationType == EscalationStepped {
+		for _, d := range r.stepDates() {
+			if d.After(after) {
+				return sql.NullTime{Time: d, Valid: true}
+			}
+		}
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: after.AddDate(0, r.IntervalMonths, 0), Valid: true}
+}
+
+// FirstEscalationDate returns the first interval anniversary of leaseStart that falls after now
+func FirstEscalationDate(leaseStart, now time.Time, intervalMonths int) time.Time {
+	date := leaseStart.AddDate(0, intervalMonths, 0)
+	for n := 2; !date.After(now); n++ {
+		date = leaseStart.AddDate(0, intervalMonths*n, 0)
+	}
+	return date
+}
+
+// ComputeEscalation calculates the rent taking effect on effective under the rule, and
+// describes how it was reached
+func ComputeEscalation(rule *EscalationRule, currentRent float64, effective time.Time, cpi CPILookup) (float64, string, error) {
+	switch rule.EscalationType {
+	case EscalationFixedPercent:
+		newRent := math.Round(currentRent*(1+rule.Percent.Float64/100)*100) / 100
+		return newRent, fmt.Sprintf("Fixed %.2f%% increase", rule.Percent.Float64), nil
+
+	case EscalationCPI:
+		reference := PeriodStart(effective).AddDate(0, -CPILagMonths, 0)
+		base := reference.AddDate(0, -rule.IntervalMonths, 0)
+		current, ok := cpi(rule.CPISeries, reference)
+		if !ok {
+			return 0, "", fmt.Errorf("%w: %s %s", ErrCPIUnavailable, rule.CPISeries, reference.Format("2006-01"))
+		}
+		previous, ok := cpi(rule.CPISeries, base)
+		if !ok || previous <= 0 {
+			return 0, "", fmt.Errorf("%w: %s %s", ErrCPIUnavailable, rule.CPISeries, base.Format("2006-01"))
+		}
+
+		change := (current/previous - 1) * 100
+		basis := fmt.Sprintf("%s %s vs %s: %+.2f%%", rule.CPISeries, reference.Format("2006-01"), base.Format("2006-01"), change)
+		if rule.CPIFloorPercent.Valid && change < rule.CPIFloorPercent.Float64 {
+			change = rule.CPIFloorPercent.Float64
+			basis += fmt.Sprintf(", raised to the %.2f%% floor", change)
+		}
+		if rule.CPICapPercent.Valid && change > rule.CPICapPercent.Float64 {
+			change = rule.CPICapPercent.Float64
+			basis += fmt.Sprintf(", limited to the %.2f%% cap", change)
+		}
+		return math.Round(currentRent*(1+change/100)*100) / 100, basis, nil
+
+	case EscalationStepped:
+		for _, step := range rule.Steps {
+			if d, err := time.Parse("2006-01-02", step.EffectiveDate); err == nil && sameDay(d, effective) {
+				return step.MonthlyRent, "Stepped rent schedule", nil
+			}
+		}
+		return 0, "", fmt.Errorf("no rent step on %s", effective.Format("2006-01-02"))
+	}
+
+	return 0, "", fmt.Errorf("unknown escalation type %q", rule.EscalationType)
+}
+
+// sameDay reports whether a and b fall on the same calendar date
+func sameDay(a, b time.Time) bool {
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
+
+// FormatEscalationNotice builds the subject and body of the notice sent to the tenant and managers
+func FormatEscalationNotice(e *LeaseEscalation) (string, string) {
+	subject := fmt.Sprintf("Rent change effective %s at %s", e.EffectiveDate.Format("January 2, 2006"), e.PropertyName)
+	body := fmt.Sprintf("The monthly rent for %s %s (%s) changes from %.2f to %.2f on %s under the lease's escalation clause.",
+		e.PropertyName, e.UnitNumber, e.TenantName, e.OldRent, e.NewRent, e.EffectiveDate.Format("2006-01-02"))
+	if e.Basis.Valid && e.Basis.String != "" {
+		body += " Basis: " + e.Basis.String + "."
+	}
+	return subject, body
+}
+
+// SaveEscalationRule creates or replaces a lease's escalation rule. When no next escalation date
+// is given it defaults to the next interval anniversary of the lease start, or the first step.
+// It returns sql.ErrNoRows if the lease does not exist.
+func SaveEscalationRule(rule *EscalationRule) error {
+	var leaseStart time.Time
+	if err := db.DB.QueryRow("SELECT start_date FROM leases WHERE id = $1", rule.LeaseID).Scan(&leaseStart); err != nil {
+		return err
+	}
+
+	if !rule.NextEscalationDate.Valid {
+		if rule.EscalationType == EscalationStepped {
+			rule.NextEscalationDate = rule.FollowingDate(time.Now())
+		} else {
+			rule.NextEscalationDate = sql.NullTime{Time: FirstEscalationDate(leaseStart, time.Now(), rule.IntervalMonths), Valid: true}
+		}
+	}
+
+	var steps sql.NullString
+	if len(rule.Steps) > 0 {
+		stepsJSON, err := json.Marshal(rule.Steps)
+		if err != nil {
+			return err
+		}
+		steps = NullString(string(stepsJSON))
+	}
+
+	return db.DB.QueryRow(`
+		INSERT INTO lease_escalation_rules (lease_id, escalation_type, percent, cpi_series, cpi_floor_percent,
+			cpi_cap_percent, interval_months, steps, next_escalation_date, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (lease_id) DO UPDATE SET
+			escalation_type = EXCLUDED.escalation_type, percent = EXCLUDED.percent,
+			cpi_series = EXCLUDED.cpi_series, cpi_floor_percent = EXCLUDED.cpi_floor_percent,
+			cpi_cap_percent = EXCLUDED.cpi_cap_percent, interval_months = EXCLUDED.interval_months,
+			steps = EXCLUDED.steps, next_escalation_date = EXCLUDED.next_escalation_date,
+			active = EXCLUDED.active, updated_at = NOW()
+		RETURNING id, created_at, updated_at`,
+		rule.LeaseID, rule.EscalationType, rule.Percent, rule.CPISeries, rule.CPIFloorPercent,
+		rule.CPICapPercent, rule.IntervalMonths, steps, rule.NextEscalationDate, rule.Active,
+	).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+}
+
+// escalationRuleColumns is the column list scanned by scanEscalationRule
+const escalationRuleColumns = `r.id, r.lease_id, r.escalation_type, r.percent, r.cpi_series, r.cpi_floor_percent,
+	r.cpi_cap_percent, r.interval_months, r.steps, r.next_escalation_date, r.active, r.created_at, r.updated_at`
+
+// scanEscalationRule scans escalationRuleColumns followed by any extra destinations
+func scanEscalationRule(scan func(dest ...interface{}) error, rule *EscalationRule, extra ...interface{}) error {
+	var steps sql.NullString
+	dest := append([]interface{}{&rule.ID, &rule.LeaseID, &rule.EscalationType, &rule.Percent, &rule.CPISeries,
+		&rule.CPIFloorPercent, &rule.CPICapPercent, &rule.IntervalMonths, &steps, &rule.NextEscalationDate,
+		&rule.Active, &rule.CreatedAt, &rule.UpdatedAt}, extra...)
+	if err := scan(dest...); err != nil {
+		return err
+	}
+	if steps.Valid && steps.String != "" {
+		return json.Unmarshal([]byte(steps.String), &rule.Steps)
+	}
+	return nil
+}
+
+// GetEscalationRule retrieves a lease's escalation rule
+func GetEscalationRule(leaseID int) (*EscalationRule, error) {
+	rule := &EscalationRule{}
+	err := scanEscalationRule(db.DB.QueryRow(`
+		SELECT `+escalationRuleColumns+`
+		FROM lease_escalation_rules r
+		WHERE r.lease_id = $1`, leaseID).Scan, rule)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// DeleteEscalationRule removes a lease's escalation rule. Escalations already scheduled still apply.
+func DeleteEscalationRule(leaseID int) error {
+	result, err := db.DB.Exec("DELETE FROM lease_escalation_rules WHERE lease_id = $1", leaseID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SaveCPIIndexValue records the published index value of a series for a month
+func SaveCPIIndexValue(series string, month time.Time, value float64) error {
+	_, err := db.DB.Exec(`
+		INSERT INTO cpi_index_values (series, period, index_value) VALUES ($1, $2, $3)
+		ON CONFLICT (series, period) DO UPDATE SET index_value = EXCLUDED.index_value`,
+		series, PeriodStart(month), value)
+	return err
+}
+
+// LookupCPIIndexValue reads an index value from cpi_index_values; it satisfies CPILookup
+func LookupCPIIndexValue(series string, month time.Time) (float64, bool) {
+	var value float64
+	err := db.DB.QueryRow("SELECT index_value FROM cpi_index_values WHERE series = $1 AND period = $2",
+		series, PeriodStart(month)).Scan(&value)
+	return value, err == nil
+}
+
+// GetCPIIndexValues lists a series' recorded values, newest first
+func GetCPIIndexValues(series string) ([]map[string]interface{}, error) {
+	rows, err := db.DB.Query(`
+		SELECT period, index_value FROM cpi_index_values
+		WHERE series = $1
+		ORDER BY period DESC`, series)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []map[string]interface{}{}
+	for rows.Next() {
+		var period time.Time
+		var value float64
+		if err := rows.Scan(&period, &value); err != nil {
+			return nil, err
+		}
+		values = append(values, map[string]interface{}{"period": period.Format("2006-01"), "index_value": value})
+	}
+	return values, rows.Err()
+}
+
+// GetDueEscalations returns active rules on active leases whose next escalation is on or before
+// horizon and has not been scheduled yet. The current rent accounts for escalations already
+// scheduled but not yet applied.
+func GetDueEscalations(horizon time.Time) ([]DueEscalation, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+escalationRuleColumns+`,
+			COALESCE((SELECT e.new_rent FROM lease_escalations e
+				WHERE e.lease_id = l.id AND e.status = 'scheduled'
+				ORDER BY e.effective_date DESC LIMIT 1), l.monthly_rent),
+			p.name, COALESCE(pu.unit_number, ''), t.first_name || ' ' || t.last_name, t.email, t.phone_number
+		FROM lease_escalation_rules r
+		JOIN leases l ON r.lease_id = l.id
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		JOIN tenants t ON l.tenant_id = t.id
+		WHERE r.active = TRUE AND l.status = 'active'
+		  AND r.next_escalation_date IS NOT NULL AND r.next_escalation_date <= $1
+		  AND r.next_escalation_date <= l.end_date
+		  AND NOT EXISTS (SELECT 1 FROM lease_escalations e
+			WHERE e.lease_id = r.lease_id AND e.effective_date = r.next_escalation_date)
+		ORDER BY r.next_escalation_date`, horizon)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DueEscalation
+	for rows.Next() {
+		var d DueEscalation
+		if err := scanEscalationRule(rows.Scan, &d.Rule, &d.CurrentRent, &d.PropertyName, &d.UnitNumber,
+			&d.TenantName, &d.TenantEmail, &d.TenantPhone); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// ScheduleEscalation records a computed escalation and moves the rule on to its following date
+func ScheduleEscalation(q Querier, e *LeaseEscalation, next sql.NullTime) error {
+	err := q.QueryRow(`
+		INSERT INTO lease_escalations (lease_id, rule_id, effective_date, old_rent, new_rent, basis, status, notified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id, created_at`,
+		e.LeaseID, e.RuleID, e.EffectiveDate, e.OldRent, e.NewRent, e.Basis, EscalationStatusScheduled,
+	).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	e.Status = EscalationStatusScheduled
+
+	_, err = q.Exec("UPDATE lease_escalation_rules SET next_escalation_date = $2, updated_at = NOW() WHERE id = $1",
+		e.RuleID, next)
+	return err
+}
+
+// GetEscalationManagerEmails returns the email addresses of active property managers, who are
+// notified of escalations alongside the tenant
+func GetEscalationManagerEmails() ([]string, error) {
+	rows, err := db.DB.Query(`
+		SELECT DISTINCT u.email
+		FROM users u
+		JOIN user_roles ur ON ur.user_id = u.id
+		JOIN roles r ON ur.role_id = r.id
+		WHERE r.name = 'property_manager' AND u.status = 'active'
+		ORDER BY u.email`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// ApplyDueEscalations sets the new rent on every scheduled escalation whose effective date has
+// arrived and marks them applied. It returns the number applied.
+func ApplyDueEscalations(now time.Time) (int, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, lease_id, new_rent FROM lease_escalations
+		WHERE status = $1 AND effective_date <= $2
+		ORDER BY effective_date`, EscalationStatusScheduled, now)
+	if err != nil {
+		return 0, err
+	}
+
+	type change struct {
+		id, leaseID int
+		rent        float64
+	}
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.id, &c.leaseID, &c.rent); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, c := range changes {
+		if _, err := tx.Exec("UPDATE leases SET monthly_rent = $2, updated_at = NOW() WHERE id = $1", c.leaseID, c.rent); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec("UPDATE lease_escalations SET status = $2, applied_at = NOW() WHERE id = $1",
+			c.id, EscalationStatusApplied); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(changes), nil
+}
+
+// GetLeaseEscalations lists escalations, optionally filtered by status and lease (0 for all)
+func GetLeaseEscalations(status string, leaseID int) ([]LeaseEscalation, error) {
+	rows, err := db.DB.Query(`
+		SELECT e.id, e.lease_id, e.rule_id, p.name, COALESCE(pu.unit_number, ''),
+			t.first_name || ' ' || t.last_name, e.effective_date, e.old_rent, e.new_rent, e.basis,
+			e.status, e.notified_at, e.applied_at, e.created_at
+		FROM lease_escalations e
+		JOIN leases l ON e.lease_id = l.id
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		JOIN tenants t ON l.tenant_id = t.id
+		WHERE ($1 = '' OR e.status = $1) AND ($2 = 0 OR e.lease_id = $2)
+		ORDER BY e.effective_date DESC, e.id DESC`, status, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var escalations []LeaseEscalation
+	for rows.Next() {
+		var e LeaseEscalation
+		if err := rows.Scan(&e.ID, &e.LeaseID, &e.RuleID, &e.PropertyName, &e.UnitNumber, &e.TenantName,
+			&e.EffectiveDate, &e.OldRent, &e.NewRent, &e.Basis, &e.Status, &e.NotifiedAt, &e.AppliedAt,
+			&e.CreatedAt); err != nil {
+			return nil, err
+		}
+		escalations = append(escalations, e)
+	}
+	return escalations, rows.Err()
+}
+
+// RentChange is an applied escalation, used to reconstruct the rent in force on past dates
+type RentChange struct {
+	EffectiveDate time.Time
+	OldRent       float64
+}
+
+// getRentChangesByLease loads applied escalations of the given leases in date order, keyed by lease ID
+func getRentChangesByLease(q Querier, leaseIDs []int) (map[int][]RentChange, error) {
+	changes := map[int][]RentChange{}
+	if len(leaseIDs) == 0 {
+		return changes, nil
+	}
+
+	placeholders := make([]string, len(leaseIDs))
+	args := []interface{}{EscalationStatusApplied}
+	for i, id := range leaseIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+		args = append(args, id)
+	}
+
+	rows, err := q.Query(fmt.Sprintf(`
+		SELECT lease_id, effective_date, old_rent
+		FROM lease_escalations
+		WHERE status = $1 AND lease_id IN (%s)
+		ORDER BY effective_date`, strings.Join(placeholders, ", ")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var leaseID int
+		var c RentChange
+		if err := rows.Scan(&leaseID, &c.EffectiveDate, &c.OldRent); err != nil {
+			return nil, err
+		}
+		changes[leaseID] = append(changes[leaseID], c)
+	}
+	return changes, rows.Err()
+}
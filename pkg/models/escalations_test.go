@@ -0,0 +1,113 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestComputeEscalationFixedAndStepped(t *testing.T) {
+	fixed := &EscalationRule{EscalationType: EscalationFixedPercent, Percent: sql.NullFloat64{Float64: 3, Valid: true}}
+	rent, basis, err := ComputeEscalation(fixed, 1500, date(2025, 1, 1), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1545.0, rent)
+	assert.Equal(t, "Fixed 3.00% increase", basis)
+
+	stepped := &EscalationRule{EscalationType: EscalationStepped, Steps: []RentStep{
+		{EffectiveDate: "2025-01-01", MonthlyRent: 1600},
+		{EffectiveDate: "2026-01-01", MonthlyRent: 1700},
+	}}
+	rent, _, err = ComputeEscalation(stepped, 1500, date(2026, 1, 1), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1700.0, rent)
+
+	_, _, err = ComputeEscalation(stepped, 1500, date(2025, 6, 1), nil)
+	assert.Error(t, err)
+}
+
+func TestComputeEscalationCPI(t *testing.T) {
+	index := map[string]float64{"2023-11": 300, "2024-11": 309}
+	cpi := func(series string, month time.Time) (float64, bool) {
+		v, ok := index[month.Format("2006-01")]
+		return v, ok && series == "CPI-U"
+	}
+
+	// January 2025 uses November 2024 against November 2023: +3%
+	rule := &EscalationRule{EscalationType: EscalationCPI, CPISeries: "CPI-U", IntervalMonths: 12}
+	rent, basis, err := ComputeEscalation(rule, 2000, date(2025, 1, 1), cpi)
+	assert.NoError(t, err)
+	assert.Equal(t, 2060.0, rent)
+	assert.Equal(t, "CPI-U 2024-11 vs 2023-11: +3.00%", basis)
+
+	rule.CPICapPercent = sql.NullFloat64{Float64: 2.5, Valid: true}
+	rent, basis, err = ComputeEscalation(rule, 2000, date(2025, 1, 1), cpi)
+	assert.NoError(t, err)
+	assert.Equal(t, 2050.0, rent)
+	assert.Contains(t, basis, "limited to the 2.50% cap")
+
+	// Deflation is raised to the floor rather than cutting the rent
+	index["2024-11"] = 297
+	rule.CPIFloorPercent = sql.NullFloat64{Float64: 1, Valid: true}
+	rent, basis, err = ComputeEscalation(rule, 2000, date(2025, 1, 1), cpi)
+	assert.NoError(t, err)
+	assert.Equal(t, 2020.0, rent)
+	assert.Contains(t, basis, "raised to the 1.00% floor")
+
+	_, _, err = ComputeEscalation(rule, 2000, date(2025, 3, 1), cpi)
+	assert.ErrorIs(t, err, ErrCPIUnavailable)
+}
+
+func TestEscalationRuleFollowingDate(t *testing.T) {
+	fixed := &EscalationRule{EscalationType: EscalationFixedPercent, IntervalMonths: 12}
+	assert.Equal(t, sql.NullTime{Time: date(2026, 3, 1), Valid: true}, fixed.FollowingDate(date(2025, 3, 1)))
+
+	stepped := &EscalationRule{EscalationType: EscalationStepped, Steps: []RentStep{
+		{EffectiveDate: "2026-01-01", MonthlyRent: 1700},
+		{EffectiveDate: "2025-01-01", MonthlyRent: 1600},
+	}}
+	assert.Equal(t, sql.NullTime{Time: date(2025, 1, 1), Valid: true}, stepped.FollowingDate(date(2024, 6, 1)))
+	assert.Equal(t, sql.NullTime{Time: date(2026, 1, 1), Valid: true}, stepped.FollowingDate(date(2025, 1, 1)))
+	assert.False(t, stepped.FollowingDate(date(2026, 1, 1)).Valid)
+}
+
+func TestFirstEscalationDate(t *testing.T) {
+	assert.Equal(t, date(2025, 4, 1), FirstEscalationDate(date(2023, 4, 1), date(2024, 10, 16), 12))
+	assert.Equal(t, date(2024, 4, 1), FirstEscalationDate(date(2023, 4, 1), date(2023, 5, 1), 12))
+}
+
+func TestFormatEscalationNotice(t *testing.T) {
+	subject, body := FormatEscalationNotice(&LeaseEscalation{
+		PropertyName:  "Sunset Apartments",
+		UnitNumber:    "Apt 4",
+		TenantName:    "Jane Doe",
+		EffectiveDate: date(2025, 1, 1),
+		OldRent:       1500,
+		NewRent:       1545,
+		Basis:         sql.NullString{String: "Fixed 3.00% increase", Valid: true},
+	})
+
+	assert.Equal(t, "Rent change effective January 1, 2025 at Sunset Apartments", subject)
+	assert.Equal(t, "The monthly rent for Sunset Apartments Apt 4 (Jane Doe) changes from 1500.00 to 1545.00 on 2025-01-01 "+
+		"under the lease's escalation clause. Basis: Fixed 3.00% increase.", body)
+}
+
+func TestScheduledLeaseRentAsOf(t *testing.T) {
+	lease := ScheduledLease{
+		MonthlyRent: 1600,
+		StartDate:   date(2024, 1, 1),
+		EndDate:     date(2025, 12, 31),
+		RentChanges: []RentChange{{EffectiveDate: date(2025, 1, 1), OldRent: 1500}},
+	}
+
+	rent, _ := lease.RentForMonth(date(2024, 12, 1))
+	assert.Equal(t, 1500.0, rent)
+	rent, _ = lease.RentForMonth(date(2025, 1, 1))
+	assert.Equal(t, 1600.0, rent)
+	assert.Equal(t, 1550.0, lease.EffectiveRent())
+}
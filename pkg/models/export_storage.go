@@ -0,0 +1,243 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// DefaultExportRetentionDays is how long stored report output is kept for organizations without
+// a configured retention
+const DefaultExportRetentionDays = 90
+
+// ErrInvalidExportStoragePolicy is returned for negative budgets or retention periods
+var ErrInvalidExportStoragePolicy = errors.New("max_bytes and retention_days must be 0 or more")
+
+// StoredExport is the output file of a report execution kept in report storage
+type StoredExport struct {
+	ExecutionID    int           `json:"execution_id"`
+	ReportID       int           `json:"report_id"`
+	ReportName     string        `json:"report_name"`
+	OrganizationID int           `json:"organization_id"`
+	ExecutedBy     sql.NullInt32 `json:"executed_by,omitempty"`
+	Format         string        `json:"format"`
+	FilePath       string        `json:"file_path"`
+	FileSize       int64         `json:"file_size"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+// ExportStoragePolicy limits how much stored report output an organization keeps. A MaxBytes of
+// 0 is unlimited; RetentionDays is the server default when not set and forever when 0.
+type ExportStoragePolicy struct {
+	OrganizationID int           `json:"organization_id"`
+	MaxBytes       int64         `json:"max_bytes"`
+	RetentionDays  sql.NullInt32 `json:"retention_days"`
+	UpdatedBy      sql.NullInt32 `json:"updated_by,omitempty"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// ExportStorageUsage is an organization's stored report output against its policy
+type ExportStorageUsage struct {
+	OrganizationID int    `json:"organization_id"`
+	Name           string `json:"name"`
+	Files          int    `json:"files"`
+	UsedBytes      int64  `json:"used_bytes"`
+	MaxBytes       int64  `json:"max_bytes"`      // 0 means unlimited
+	RetentionDays  int    `json:"retention_days"` // 0 means kept until deleted
+}
+
+// storedExportColumns selects a StoredExport from report_executions e joined to custom_reports
+// r and the executing user u. Runs without a user belong to the default organization (id 1).
+const storedExportColumns = `
+	e.id, e.report_id, r.name, COALESCE(u.organization_id, 1), e.executed_by,
+	e.output_format, e.file_path, COALESCE(e.file_size, 0), e.execution_time`
+
+const storedExportFrom = `
+	FROM report_executions e
+	JOIN custom_reports r ON r.id = e.report_id
+	LEFT JOIN users u ON u.id = e.executed_by
+	WHERE e.file_path IS NOT NULL`
+
+func scanStoredExport(row interface{ Scan(...interface{}) error }) (*StoredExport, error) {
+	e := &StoredExport{}
+	err := row.Scan(&e.ExecutionID, &e.ReportID, &e.ReportName, &e.OrganizationID, &e.ExecutedBy,
+		&e.Format, &e.FilePath, &e.FileSize, &e.CreatedAt)
+	return e, err
+}
+
+// GetStoredExports lists stored report output newest first, for one organization or for all
+// when organizationID is 0. A limit of 0 returns every file.
+func GetStoredExports(organizationID, limit int) ([]StoredExport, error) {
+	query := "SELECT " + storedExportColumns + storedExportFrom
+	var args []interface{}
+	if organizationID != 0 {
+		args = append(args, organizationID)
+		query += " AND COALESCE(u.organization_id, 1) = $1"
+	}
+	query += " ORDER BY e.execution_time DESC, e.id DESC"
+	if limit > 0 {
+		args = append(args, limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []StoredExport
+	for rows.Next() {
+		e, err := scanStoredExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *e)
+	}
+	return exports, rows.Err()
+}
+
+// GetStoredExport returns the stored output of an execution, or sql.ErrNoRows when it has none
+func GetStoredExport(executionID int) (*StoredExport, error) {
+	return scanStoredExport(db.DB.QueryRow("SELECT "+storedExportColumns+storedExportFrom+" AND e.id = $1",
+		executionID))
+}
+
+// ClearReportExecutionFile forgets an execution's stored output once the file is deleted. It
+// returns sql.ErrNoRows if the execution has no stored output.
+func ClearReportExecutionFile(executionID int) error {
+	result, err := db.DB.Exec(`
+		UPDATE report_executions SET file_path = NULL, file_size = NULL
+		WHERE id = $1 AND file_path IS NOT NULL`, executionID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetExportStoragePolicies returns the configured policies by organization ID
+func GetExportStoragePolicies() (map[int]ExportStoragePolicy, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT organization_id, max_bytes, retention_days, updated_by, updated_at
+		FROM export_storage_policies`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := map[int]ExportStoragePolicy{}
+	for rows.Next() {
+		var p ExportStoragePolicy
+		if err := rows.Scan(&p.OrganizationID, &p.MaxBytes, &p.RetentionDays, &p.UpdatedBy, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies[p.OrganizationID] = p
+	}
+	return policies, rows.Err()
+}
+
+// SetExportStoragePolicy sets an organization's storage budget and retention, replacing any
+// existing policy. It returns sql.ErrNoRows if the organization does not exist.
+func SetExportStoragePolicy(p *ExportStoragePolicy) error {
+	if p.MaxBytes < 0 || (p.RetentionDays.Valid && p.RetentionDays.Int32 < 0) {
+		return ErrInvalidExportStoragePolicy
+	}
+	var exists int
+	if err := db.DB.QueryRow("SELECT id FROM organizations WHERE id = $1", p.OrganizationID).Scan(&exists); err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO export_storage_policies (organization_id, max_bytes, retention_days, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (organization_id) DO UPDATE
+		SET max_bytes = EXCLUDED.max_bytes, retention_days = EXCLUDED.retention_days,
+			updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`,
+		p.OrganizationID, p.MaxBytes, p.RetentionDays, p.UpdatedBy).Scan(&p.UpdatedAt)
+}
+
+// GetExportStorageUsage returns the stored report output of every organization, or only of
+// organizationID when it is non-zero, with the retention that applies to it
+func GetExportStorageUsage(organizationID, defaultRetentionDays int) ([]ExportStorageUsage, error) {
+	query := `
+		SELECT o.id, o.name, COALESCE(s.files, 0), COALESCE(s.bytes, 0), COALESCE(p.max_bytes, 0),
+			   COALESCE(p.retention_days, $1)
+		FROM organizations o
+		LEFT JOIN export_storage_policies p ON p.organization_id = o.id
+		LEFT JOIN (
+			SELECT COALESCE(u.organization_id, 1) AS organization_id, COUNT(*) AS files,
+				   SUM(COALESCE(e.file_size, 0)) AS bytes
+			FROM report_executions e
+			LEFT JOIN users u ON u.id = e.executed_by
+			WHERE e.file_path IS NOT NULL
+			GROUP BY COALESCE(u.organization_id, 1)
+		) s ON s.organization_id = o.id`
+	args := []interface{}{defaultRetentionDays}
+	if organizationID != 0 {
+		query += " WHERE o.id = $2"
+		args = append(args, organizationID)
+	}
+
+	rows, err := db.ReadDB().Query(query+" ORDER BY o.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []ExportStorageUsage
+	for rows.Next() {
+		var u ExportStorageUsage
+		if err := rows.Scan(&u.OrganizationID, &u.Name, &u.Files, &u.UsedBytes, &u.MaxBytes, &u.RetentionDays); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// GetExpiredExports returns the stored report output due for deletion at now under each
+// organization's policy
+func GetExpiredExports(defaultRetentionDays int, now time.Time) ([]StoredExport, error) {
+	exports, err := GetStoredExports(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := GetExportStoragePolicies()
+	if err != nil {
+		return nil, err
+	}
+	return SelectExpiredExports(exports, policies, defaultRetentionDays, now), nil
+}
+
+// SelectExpiredExports picks the exports, listed newest first, that are older than their
+// organization's retention or that fall outside its budget. Each organization keeps its newest
+// files up to MaxBytes; the first file that does not fit and every older one are expired.
+func SelectExpiredExports(exports []StoredExport, policies map[int]ExportStoragePolicy,
+	defaultRetentionDays int, now time.Time) []StoredExport {
+	used := map[int]int64{}
+	full := map[int]bool{}
+
+	var expired []StoredExport
+	for _, e := range exports {
+		policy := policies[e.OrganizationID]
+		retention := defaultRetentionDays
+		if policy.RetentionDays.Valid {
+			retention = int(policy.RetentionDays.Int32)
+		}
+
+		if retention > 0 && e.CreatedAt.Before(now.AddDate(0, 0, -retention)) {
+			expired = append(expired, e)
+			continue
+		}
+		if policy.MaxBytes > 0 && (full[e.OrganizationID] || used[e.OrganizationID]+e.FileSize > policy.MaxBytes) {
+			full[e.OrganizationID] = true
+			expired = append(expired, e)
+			continue
+		}
+		used[e.OrganizationID] += e.FileSize
+	}
+	return expired
+}
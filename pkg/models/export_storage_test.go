@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectExpiredExports(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	export := func(id, org int, size int64, age time.Duration) StoredExport {
+		return StoredExport{ExecutionID: id, OrganizationID: org, FileSize: size, CreatedAt: now.Add(-age)}
+	}
+	day := 24 * time.Hour
+
+	// Newest first, as GetStoredExports lists them
+	exports := []StoredExport{
+		export(1, 1, 400, day),
+		export(2, 2, 400, day),
+		export(3, 1, 400, 2*day),
+		export(4, 1, 300, 3*day),
+		export(5, 2, 400, 100*day),
+		export(6, 3, 400, 100*day),
+	}
+	policies := map[int]ExportStoragePolicy{
+		1: {OrganizationID: 1, MaxBytes: 1000},
+		3: {OrganizationID: 3, RetentionDays: sql.NullInt32{Int32: 0, Valid: true}},
+	}
+
+	var ids []int
+	for _, e := range SelectExpiredExports(exports, policies, 90, now) {
+		ids = append(ids, e.ExecutionID)
+	}
+	// Organization 1 keeps its two newest files within the budget and the third doesn't fit.
+	// Organization 2's old file is past the default retention; organization 3 keeps files forever.
+	assert.Equal(t, []int{4, 5}, ids)
+}
+
+func TestSetExportStoragePolicy(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	assert.Equal(t, ErrInvalidExportStoragePolicy, SetExportStoragePolicy(&ExportStoragePolicy{OrganizationID: 1, MaxBytes: -1}))
+	assert.Equal(t, ErrInvalidExportStoragePolicy, SetExportStoragePolicy(&ExportStoragePolicy{OrganizationID: 1,
+		RetentionDays: sql.NullInt32{Int32: -5, Valid: true}}))
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT id FROM organizations WHERE id`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO export_storage_policies`).
+		WithArgs(2, int64(1<<30), sql.NullInt32{Int32: 30, Valid: true}, sql.NullInt32{}).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(now))
+
+	policy := &ExportStoragePolicy{OrganizationID: 2, MaxBytes: 1 << 30, RetentionDays: sql.NullInt32{Int32: 30, Valid: true}}
+	require.NoError(t, SetExportStoragePolicy(policy))
+	assert.Equal(t, now, policy.UpdatedAt)
+
+	mock.ExpectQuery(`SELECT id FROM organizations WHERE id`).WithArgs(9).WillReturnError(sql.ErrNoRows)
+	assert.Equal(t, sql.ErrNoRows, SetExportStoragePolicy(&ExportStoragePolicy{OrganizationID: 9}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClearReportExecutionFile(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectExec(`UPDATE report_executions SET file_path = NULL, file_size = NULL`).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE report_executions SET file_path = NULL, file_size = NULL`).
+		WithArgs(8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, ClearReportExecutionFile(7))
+	assert.Equal(t, sql.ErrNoRows, ClearReportExecutionFile(8))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStoredExportsForOrganization(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	created := time.Date(2026, 10, 1, 6, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM report_executions e .* AND COALESCE\(u.organization_id, 1\) = \$1 ORDER BY .* LIMIT \$2`).
+		WithArgs(2, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "report_id", "name", "organization_id", "executed_by",
+			"output_format", "file_path", "file_size", "execution_time"}).
+			AddRow(11, 4, "Rent roll", 2, 5, "csv", "4/11-rent-roll.csv", 2048, created))
+
+	exports, err := GetStoredExports(2, 50)
+	require.NoError(t, err)
+	require.Len(t, exports, 1)
+	assert.Equal(t, int64(2048), exports[0].FileSize)
+	assert.Equal(t, "4/11-rent-roll.csv", exports[0].FilePath)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
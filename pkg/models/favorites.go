@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Item types that can be starred or tracked as recent
+const (
+	ItemTypeReport    = "report"
+	ItemTypeDashboard = "dashboard"
+)
+
+// Recent item actions
+const (
+	ActionViewed   = "viewed"
+	ActionExecuted = "executed"
+)
+
+// MaxRecentItems is the number of recent items kept per user
+const MaxRecentItems = 50
+
+// itemTables maps item types to the table holding the item
+var itemTables = map[string]string{
+	ItemTypeReport:    "custom_reports",
+	ItemTypeDashboard: "analytics_dashboards",
+}
+
+// ValidItemType reports whether itemType can be starred or tracked
+func ValidItemType(itemType string) bool {
+	_, ok := itemTables[itemType]
+	return ok
+}
+
+// UserItem is a starred or recently used report or dashboard
+type UserItem struct {
+	ItemType    string       `json:"item_type"`
+	ItemID      int          `json:"item_id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	IsFavorite  bool         `json:"is_favorite"`
+	LastAction  string       `json:"last_action,omitempty"`
+	AccessCount int          `json:"access_count,omitempty"`
+	AccessedAt  sql.NullTime `json:"accessed_at,omitempty"`
+	StarredAt   sql.NullTime `json:"starred_at,omitempty"`
+}
+
+// userItemJoins resolves item names; items whose report or dashboard was deleted, or that the
+// user can no longer see because they were made private, have no match
+const userItemJoins = `
+	LEFT JOIN custom_reports cr ON i.item_type = 'report' AND cr.id = i.item_id
+		AND (cr.created_by = i.user_id OR cr.is_public = true)
+	LEFT JOIN analytics_dashboards ad ON i.item_type = 'dashboard' AND ad.id = i.item_id
+		AND (ad.created_by = i.user_id OR ad.is_public = true)`
+
+// AddFavorite stars an item for the user. Returns sql.ErrNoRows if the item does not exist or
+// is another user's private report or dashboard.
+func AddFavorite(userID int, itemType string, itemID int) error {
+	table, ok := itemTables[itemType]
+	if !ok {
+		return fmt.Errorf("unsupported item type %q", itemType)
+	}
+
+	var exists int
+	if err := db.DB.QueryRow(fmt.Sprintf("SELECT id FROM %s WHERE id = $1 AND (created_by = $2 OR is_public = true)", table),
+		itemID, userID).Scan(&exists); err != nil {
+		return err
+	}
+
+	_, err := db.DB.Exec(`
+		INSERT INTO user_favorites (user_id, item_type, item_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, item_type, item_id) DO NOTHING`, userID, itemType, itemID)
+	return err
+}
+
+// RemoveFavorite unstars an item. Returns sql.ErrNoRows if it was not starred.
+func RemoveFavorite(userID int, itemType string, itemID int) error {
+	result, err := db.DB.Exec(
+		"DELETE FROM user_favorites WHERE user_id = $1 AND item_type = $2 AND item_id = $3",
+		userID, itemType, itemID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetFavorites returns the user's starred items, most recently starred first
+func GetFavorites(userID int) ([]UserItem, error) {
+	rows, err := db.DB.Query(`
+		SELECT i.item_type, i.item_id, COALESCE(cr.name, ad.name, ''),
+		       COALESCE(cr.description, ad.description, ''), i.created_at
+		FROM user_favorites i`+userItemJoins+`
+		WHERE i.user_id = $1 AND (cr.id IS NOT NULL OR ad.id IS NOT NULL)
+		ORDER BY i.created_at DESC, i.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []UserItem
+	for rows.Next() {
+		item := UserItem{IsFavorite: true}
+		if err := rows.Scan(&item.ItemType, &item.ItemID, &item.Name, &item.Description, &item.StarredAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// RecordRecentItem notes that the user viewed or executed an item and prunes the user's
+// history to MaxRecentItems
+func RecordRecentItem(userID int, itemType string, itemID int, action string, now time.Time) error {
+	if !ValidItemType(itemType) {
+		return fmt.Errorf("unsupported item type %q", itemType)
+	}
+
+	if _, err := db.DB.Exec(`
+		INSERT INTO user_recent_items (user_id, item_type, item_id, last_action, access_count, accessed_at)
+		VALUES ($1, $2, $3, $4, 1, $5)
+		ON CONFLICT (user_id, item_type, item_id) DO UPDATE SET
+			last_action = EXCLUDED.last_action,
+			access_count = user_recent_items.access_count + 1,
+			accessed_at = EXCLUDED.accessed_at`,
+		userID, itemType, itemID, action, now); err != nil {
+		return err
+	}
+
+	_, err := db.DB.Exec(`
+		DELETE FROM user_recent_items
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM user_recent_items WHERE user_id = $1
+			ORDER BY accessed_at DESC, id DESC LIMIT $2)`, userID, MaxRecentItems)
+	return err
+}
+
+// GetRecentItems returns up to limit items the user most recently viewed or executed.
+// itemType optionally restricts the results to reports or dashboards.
+func GetRecentItems(userID int, itemType string, limit int) ([]UserItem, error) {
+	if limit <= 0 || limit > MaxRecentItems {
+		limit = MaxRecentItems
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT i.item_type, i.item_id, COALESCE(cr.name, ad.name, ''),
+		       COALESCE(cr.description, ad.description, ''), i.last_action, i.access_count,
+		       i.accessed_at, f.created_at
+		FROM user_recent_items i`+userItemJoins+`
+		LEFT JOIN user_favorites f ON f.user_id = i.user_id AND f.item_type = i.item_type AND f.item_id = i.item_id
+		WHERE i.user_id = $1 AND ($2 = '' OR i.item_type = $2) AND (cr.id IS NOT NULL OR ad.id IS NOT NULL)
+		ORDER BY i.accessed_at DESC, i.id DESC
+		LIMIT $3`, userID, itemType, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []UserItem
+	for rows.Next() {
+		var item UserItem
+		if err := rows.Scan(&item.ItemType, &item.ItemID, &item.Name, &item.Description, &item.LastAction,
+			&item.AccessCount, &item.AccessedAt, &item.StarredAt); err != nil {
+			return nil, err
+		}
+		item.IsFavorite = item.StarredAt.Valid
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddFavoriteRequiresVisibleItem(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT id FROM analytics_dashboards WHERE id = \$1 AND \(created_by = \$2 OR is_public = true\)`).
+		WithArgs(9, 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT id FROM custom_reports WHERE id = \$1 AND \(created_by = \$2 OR is_public = true\)`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`INSERT INTO user_favorites`).
+		WithArgs(1, ItemTypeReport, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	assert.Equal(t, sql.ErrNoRows, AddFavorite(1, ItemTypeDashboard, 9))
+	assert.NoError(t, AddFavorite(1, ItemTypeReport, 3))
+	assert.Error(t, AddFavorite(1, "chart", 3))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordRecentItemPrunesHistory(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`INSERT INTO user_recent_items (.+) ON CONFLICT`).
+		WithArgs(1, ItemTypeReport, 3, ActionExecuted, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM user_recent_items`).
+		WithArgs(1, MaxRecentItems).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, RecordRecentItem(1, ItemTypeReport, 3, ActionExecuted, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRecentItems(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	accessed := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT (.+) FROM user_recent_items i`).
+		WithArgs(1, "", MaxRecentItems).
+		WillReturnRows(sqlmock.NewRows([]string{"item_type", "item_id", "name", "description", "last_action",
+			"access_count", "accessed_at", "created_at"}).
+			AddRow(ItemTypeReport, 3, "Rent Roll", "", ActionExecuted, 4, accessed, accessed).
+			AddRow(ItemTypeDashboard, 2, "Ops", "", ActionViewed, 1, accessed, nil))
+
+	items, err := GetRecentItems(1, "", 0)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.True(t, items[0].IsFavorite)
+	assert.Equal(t, 4, items[0].AccessCount)
+	assert.False(t, items[1].IsFavorite)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
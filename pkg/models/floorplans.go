@@ -0,0 +1,381 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+var (
+	// ErrInvalidFloorplan is returned when a floorplan is missing its name or has impossible figures
+	ErrInvalidFloorplan = errors.New("a floorplan needs a name, bedrooms and bathrooms of zero or more, and a positive square footage and market rent when given")
+	// ErrFloorplanProperty is returned when linking a unit to another property's floorplan
+	ErrFloorplanProperty = errors.New("the floorplan belongs to a different property than the unit")
+)
+
+// Floorplan is a unit type a property offers. Units linked to it take its bedrooms and
+// bathrooms, and its market rent when they have none of their own.
+type Floorplan struct {
+	ID          int             `json:"id"`
+	PropertyID  int             `json:"property_id"`
+	Name        string          `json:"name"`
+	Bedrooms    int             `json:"bedrooms"`
+	Bathrooms   int             `json:"bathrooms"`
+	SquareFeet  sql.NullInt32   `json:"square_feet,omitempty"`
+	MarketRent  sql.NullFloat64 `json:"market_rent,omitempty"`
+	Description sql.NullString  `json:"description,omitempty"`
+	UnitCount   int             `json:"unit_count"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Validate checks the floorplan has a name and sensible figures
+func (f *Floorplan) Validate() error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" || f.Bedrooms < 0 || f.Bathrooms < 0 {
+		return ErrInvalidFloorplan
+	}
+	if f.SquareFeet.Valid && f.SquareFeet.Int32 <= 0 {
+		return ErrInvalidFloorplan
+	}
+	if f.MarketRent.Valid && f.MarketRent.Float64 <= 0 {
+		return ErrInvalidFloorplan
+	}
+	return nil
+}
+
+const floorplanColumns = `f.id, f.property_id, f.name, f.bedrooms, f.bathrooms, f.square_feet, f.market_rent,
+	f.description, f.created_at, f.updated_at,
+	(SELECT COUNT(*) FROM property_units pu WHERE pu.floorplan_id = f.id)`
+
+func scanFloorplan(row interface{ Scan(...interface{}) error }) (Floorplan, error) {
+	var f Floorplan
+	err := row.Scan(&f.ID, &f.PropertyID, &f.Name, &f.Bedrooms, &f.Bathrooms, &f.SquareFeet, &f.MarketRent,
+		&f.Description, &f.CreatedAt, &f.UpdatedAt, &f.UnitCount)
+	return f, err
+}
+
+// CreateFloorplan saves a new floorplan. It returns sql.ErrNoRows when the property does not exist.
+func CreateFloorplan(f *Floorplan) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	var exists int
+	if err := db.DB.QueryRow("SELECT 1 FROM properties WHERE id = $1", f.PropertyID).Scan(&exists); err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO floorplans (property_id, name, bedrooms, bathrooms, square_feet, market_rent, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`,
+		f.PropertyID, f.Name, f.Bedrooms, f.Bathrooms, f.SquareFeet, f.MarketRent, f.Description).
+		Scan(&f.ID, &f.CreatedAt, &f.UpdatedAt)
+}
+
+// UpdateFloorplan changes a floorplan and carries its bedrooms and bathrooms over to its units
+func UpdateFloorplan(f *Floorplan) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE floorplans
+		SET name = $2, bedrooms = $3, bathrooms = $4, square_feet = $5, market_rent = $6, description = $7,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING property_id, created_at, updated_at`,
+		f.ID, f.Name, f.Bedrooms, f.Bathrooms, f.SquareFeet, f.MarketRent, f.Description).
+		Scan(&f.PropertyID, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE property_units SET bedrooms = $2, bathrooms = $3, updated_at = NOW()
+		WHERE floorplan_id = $1 AND (bedrooms <> $2 OR bathrooms <> $3)`, f.ID, f.Bedrooms, f.Bathrooms); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteFloorplan removes a floorplan. Its units stay, no longer linked to a floorplan.
+func DeleteFloorplan(id int) error {
+	result, err := db.DB.Exec("DELETE FROM floorplans WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetFloorplan retrieves a floorplan by ID
+func GetFloorplan(id int) (*Floorplan, error) {
+	f, err := scanFloorplan(db.ReadDB().QueryRow(`
+		SELECT `+floorplanColumns+`
+		FROM floorplans f
+		WHERE f.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetFloorplans returns a property's floorplans, smallest first
+func GetFloorplans(propertyID int) ([]Floorplan, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT `+floorplanColumns+`
+		FROM floorplans f
+		WHERE f.property_id = $1
+		ORDER BY f.bedrooms, f.bathrooms, f.name`, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var floorplans []Floorplan
+	for rows.Next() {
+		f, err := scanFloorplan(rows)
+		if err != nil {
+			return nil, err
+		}
+		floorplans = append(floorplans, f)
+	}
+	return floorplans, rows.Err()
+}
+
+// SetUnitFloorplan links a unit to a floorplan of its property, copying the floorplan's bedrooms
+// and bathrooms to it, or unlinks it when floorplanID is not valid. It returns sql.ErrNoRows when
+// the unit or floorplan does not exist.
+func SetUnitFloorplan(unitID int, floorplanID sql.NullInt32) error {
+	if !floorplanID.Valid {
+		result, err := db.DB.Exec("UPDATE property_units SET floorplan_id = NULL, updated_at = NOW() WHERE id = $1", unitID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result)
+	}
+
+	var unitPropertyID int
+	if err := db.DB.QueryRow("SELECT property_id FROM property_units WHERE id = $1", unitID).Scan(&unitPropertyID); err != nil {
+		return err
+	}
+	f, err := GetFloorplan(int(floorplanID.Int32))
+	if err != nil {
+		return err
+	}
+	if f.PropertyID != unitPropertyID {
+		return ErrFloorplanProperty
+	}
+
+	result, err := db.DB.Exec(`
+		UPDATE property_units SET floorplan_id = $2, bedrooms = $3, bathrooms = $4, updated_at = NOW()
+		WHERE id = $1`, unitID, f.ID, f.Bedrooms, f.Bathrooms)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// UnitMixLine is one floorplan of a property in the unit mix, or the property's units without a
+// floorplan when FloorplanID is null
+type UnitMixLine struct {
+	PropertyID    int             `json:"property_id"`
+	PropertyName  string          `json:"property_name"`
+	FloorplanID   sql.NullInt32   `json:"floorplan_id"`
+	Floorplan     string          `json:"floorplan"`
+	Bedrooms      sql.NullInt32   `json:"bedrooms"`
+	Bathrooms     sql.NullInt32   `json:"bathrooms"`
+	SquareFeet    sql.NullInt32   `json:"square_feet"`
+	MarketRent    sql.NullFloat64 `json:"market_rent"`
+	Units         int             `json:"units"`
+	OccupiedUnits int             `json:"occupied_units"`
+	AverageRent   float64         `json:"average_rent"` // Of the occupied units' leases
+}
+
+// OccupancyPercent returns the share of the line's units under an active lease, rounded to two
+// decimal places
+func (l UnitMixLine) OccupancyPercent() float64 {
+	if l.Units == 0 {
+		return 0
+	}
+	return math.Round(float64(l.OccupiedUnits)/float64(l.Units)*10000) / 100
+}
+
+// GetUnitMix counts units, occupied units and the average rent of leases in force on asOf per
+// floorplan, for the given properties or all of them
+func GetUnitMix(propertyIDs []interface{}, asOf time.Time) ([]UnitMixLine, error) {
+	query := `
+		SELECT p.id, p.name, f.id, COALESCE(f.name, ''), f.bedrooms, f.bathrooms, f.square_feet, f.market_rent,
+			   COUNT(DISTINCT pu.id), COUNT(DISTINCT l.unit_id), COALESCE(AVG(l.monthly_rent), 0)
+		FROM property_units pu
+		JOIN properties p ON pu.property_id = p.id
+		LEFT JOIN floorplans f ON pu.floorplan_id = f.id
+		LEFT JOIN leases l ON l.unit_id = pu.id AND l.status = 'active' AND l.start_date <= $1 AND l.end_date >= $1
+		WHERE 1=1`
+	args := []interface{}{asOf}
+	if len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+1)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND p.id IN (%s)", strings.Join(placeholders, ", "))
+	}
+	query += `
+		GROUP BY p.id, p.name, f.id, f.name, f.bedrooms, f.bathrooms, f.square_feet, f.market_rent`
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []UnitMixLine
+	for rows.Next() {
+		var l UnitMixLine
+		if err := rows.Scan(&l.PropertyID, &l.PropertyName, &l.FloorplanID, &l.Floorplan, &l.Bedrooms, &l.Bathrooms,
+			&l.SquareFeet, &l.MarketRent, &l.Units, &l.OccupiedUnits, &l.AverageRent); err != nil {
+			return nil, err
+		}
+		l.AverageRent = math.Round(l.AverageRent*100) / 100
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Floorplans smallest first within each property, with unlinked units last
+	sort.SliceStable(lines, func(a, b int) bool {
+		x, y := lines[a], lines[b]
+		if x.PropertyName != y.PropertyName {
+			return x.PropertyName < y.PropertyName
+		}
+		if x.FloorplanID.Valid != y.FloorplanID.Valid {
+			return x.FloorplanID.Valid
+		}
+		if x.Bedrooms.Int32 != y.Bedrooms.Int32 {
+			return x.Bedrooms.Int32 < y.Bedrooms.Int32
+		}
+		if x.Bathrooms.Int32 != y.Bathrooms.Int32 {
+			return x.Bathrooms.Int32 < y.Bathrooms.Int32
+		}
+		return x.Floorplan < y.Floorplan
+	})
+	return lines, nil
+}
+
+// generateUnitMixReport shows unit counts, occupancy and average rent per floorplan as of
+// parameters["as_of"] (default today), for the criteria's property_ids or every property
+func generateUnitMixReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	asOf := time.Now()
+	if value, ok := parameters["as_of"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			asOf = parsed
+		}
+	}
+
+	propertyIDs, _ := report.Criteria["property_ids"].([]interface{})
+	lines, err := GetUnitMix(propertyIDs, asOf)
+	if err != nil {
+		return nil, err
+	}
+	return buildUnitMixReport(lines, asOf), nil
+}
+
+// buildUnitMixReport lays out the unit mix with portfolio totals and a chart of units per floorplan
+func buildUnitMixReport(lines []UnitMixLine, asOf time.Time) *ReportData {
+	data := &ReportData{
+		Headers: []string{"Property", "Floorplan", "Bedrooms", "Bathrooms", "Square Feet", "Units", "Occupied",
+			"Occupancy %", "Average Rent", "Market Rent", "Share of Units %"},
+		Rows: []map[string]interface{}{},
+	}
+
+	var units, occupied int
+	var rentTotal float64
+	for _, l := range lines {
+		units += l.Units
+		occupied += l.OccupiedUnits
+		rentTotal += l.AverageRent * float64(l.OccupiedUnits)
+	}
+
+	labels := make([]interface{}, len(lines))
+	counts := make([]interface{}, len(lines))
+	for i, l := range lines {
+		name := l.Floorplan
+		if !l.FloorplanID.Valid {
+			name = "No floorplan"
+		}
+		row := map[string]interface{}{
+			"Property":     l.PropertyName,
+			"Floorplan":    name,
+			"Units":        l.Units,
+			"Occupied":     l.OccupiedUnits,
+			"Occupancy %":  l.OccupancyPercent(),
+			"Average Rent": l.AverageRent,
+		}
+		if l.Bedrooms.Valid {
+			row["Bedrooms"] = l.Bedrooms.Int32
+			row["Bathrooms"] = l.Bathrooms.Int32
+		}
+		if l.SquareFeet.Valid {
+			row["Square Feet"] = l.SquareFeet.Int32
+		}
+		if l.MarketRent.Valid {
+			row["Market Rent"] = l.MarketRent.Float64
+		}
+		if units > 0 {
+			row["Share of Units %"] = math.Round(float64(l.Units)/float64(units)*10000) / 100
+		}
+		data.Rows = append(data.Rows, row)
+
+		labels[i] = l.PropertyName + " - " + name
+		counts[i] = l.Units
+	}
+
+	data.Summary = map[string]interface{}{
+		"as_of":             asOf.Format("2006-01-02"),
+		"floorplan_count":   len(lines),
+		"total_units":       units,
+		"occupied_units":    occupied,
+		"occupancy_percent": 0.0,
+		"average_rent":      0.0,
+	}
+	if units > 0 {
+		data.Summary["occupancy_percent"] = math.Round(float64(occupied)/float64(units)*10000) / 100
+	}
+	if occupied > 0 {
+		data.Summary["average_rent"] = math.Round(rentTotal/float64(occupied)*100) / 100
+	}
+
+	if len(lines) > 0 {
+		data.Charts = []ChartData{{
+			Type:  "bar",
+			Title: "Units by Floorplan",
+			Data: map[string]interface{}{
+				"labels": labels,
+				"datasets": []map[string]interface{}{{
+					"label":           "Units",
+					"data":            counts,
+					"backgroundColor": "rgba(54, 162, 235, 0.5)",
+				}},
+			},
+		}}
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Bedrooms": numberColumn, "Bathrooms": numberColumn, "Square Feet": numberColumn, "Units": numberColumn,
+		"Occupied": numberColumn, "Occupancy %": percentColumn, "Average Rent": currencyColumn,
+		"Market Rent": currencyColumn, "Share of Units %": percentColumn,
+	})
+	return data
+}
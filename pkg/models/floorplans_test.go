@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFloorplanValidate(t *testing.T) {
+	assert.NoError(t, (&Floorplan{Name: "A1", Bedrooms: 1, Bathrooms: 1}).Validate())
+	assert.NoError(t, (&Floorplan{Name: "Studio", SquareFeet: sql.NullInt32{Int32: 450, Valid: true}}).Validate())
+
+	assert.Equal(t, ErrInvalidFloorplan, (&Floorplan{Name: "  "}).Validate())
+	assert.Equal(t, ErrInvalidFloorplan, (&Floorplan{Name: "B2", Bedrooms: -1}).Validate())
+	assert.Equal(t, ErrInvalidFloorplan, (&Floorplan{Name: "B2", SquareFeet: sql.NullInt32{Valid: true}}).Validate())
+	assert.Equal(t, ErrInvalidFloorplan, (&Floorplan{Name: "B2", MarketRent: sql.NullFloat64{Float64: -5, Valid: true}}).Validate())
+}
+
+func TestSetUnitFloorplanRejectsOtherProperty(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT property_id FROM property_units`).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"property_id"}).AddRow(1))
+	mock.ExpectQuery(`FROM floorplans f`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "property_id", "name", "bedrooms", "bathrooms", "square_feet",
+			"market_rent", "description", "created_at", "updated_at", "units"}).
+			AddRow(3, 2, "A1", 1, 1, nil, nil, nil, now, now, 0))
+
+	assert.Equal(t, ErrFloorplanProperty, SetUnitFloorplan(12, sql.NullInt32{Int32: 3, Valid: true}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildUnitMixReport(t *testing.T) {
+	asOf := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	lines := []UnitMixLine{
+		{PropertyName: "Elm Court", FloorplanID: sql.NullInt32{Int32: 1, Valid: true}, Floorplan: "A1",
+			Bedrooms: sql.NullInt32{Int32: 1, Valid: true}, Bathrooms: sql.NullInt32{Int32: 1, Valid: true},
+			MarketRent: sql.NullFloat64{Float64: 1500, Valid: true}, Units: 6, OccupiedUnits: 3, AverageRent: 1400},
+		{PropertyName: "Elm Court", Units: 2, OccupiedUnits: 1, AverageRent: 1800},
+	}
+
+	data := buildUnitMixReport(lines, asOf)
+	require.Len(t, data.Rows, 2)
+	assert.Equal(t, 50.0, data.Rows[0]["Occupancy %"])
+	assert.Equal(t, 75.0, data.Rows[0]["Share of Units %"])
+	assert.Equal(t, 1500.0, data.Rows[0]["Market Rent"])
+	assert.Equal(t, "No floorplan", data.Rows[1]["Floorplan"])
+	assert.NotContains(t, data.Rows[1], "Bedrooms")
+
+	assert.Equal(t, 8, data.Summary["total_units"])
+	assert.Equal(t, 50.0, data.Summary["occupancy_percent"])
+	assert.Equal(t, 1500.0, data.Summary["average_rent"])
+	require.Len(t, data.Charts, 1)
+	assert.Equal(t, "bar", data.Charts[0].Type)
+}
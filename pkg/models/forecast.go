@@ -0,0 +1,219 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ForecastLease is the subset of a lease used to project unit availability
+type ForecastLease struct {
+	UnitID      int
+	StartDate   time.Time
+	EndDate     time.Time
+	Pending     bool // Accepted application that has not moved in yet
+	NoticeGiven bool
+}
+
+// VacancyForecastMonth is the projected state of all units at the start of a month
+type VacancyForecastMonth struct {
+	Month    time.Time
+	Occupied int
+	Notice   int
+	Vacant   int
+}
+
+// ForecastVacancy projects, for each of the next months starting at from, how many units will be
+// occupied, occupied but on notice, or vacant. A unit is occupied in a month if an active or pending
+// lease covers the first of that month; leases are assumed not to renew past their end date.
+func ForecastVacancy(totalUnits int, leases []ForecastLease, from time.Time, months int) []VacancyForecastMonth {
+	byUnit := make(map[int][]ForecastLease)
+	for _, lease := range leases {
+		byUnit[lease.UnitID] = append(byUnit[lease.UnitID], lease)
+	}
+
+	forecast := make([]VacancyForecastMonth, 0, months)
+	for i := 0; i < months; i++ {
+		month := PeriodStart(from).AddDate(0, i, 0)
+		point := VacancyForecastMonth{Month: month}
+
+		for _, unitLeases := range byUnit {
+			state := ""
+			for _, lease := range unitLeases {
+				if lease.StartDate.After(month) || lease.EndDate.Before(month) {
+					continue
+				}
+				// A pending or un-noticed lease outranks a noticed one for the same unit
+				if lease.NoticeGiven && !lease.Pending {
+					if state == "" {
+						state = "notice"
+					}
+				} else {
+					state = "occupied"
+				}
+			}
+
+			switch state {
+			case "occupied":
+				point.Occupied++
+			case "notice":
+				point.Notice++
+			}
+		}
+
+		point.Vacant = totalUnits - point.Occupied - point.Notice
+		if point.Vacant < 0 {
+			point.Vacant = 0
+		}
+		forecast = append(forecast, point)
+	}
+
+	return forecast
+}
+
+// generateVacancyForecastReport projects unit availability from the lease pipeline
+func generateVacancyForecastReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	months := 12
+	if m, ok := parameters["months"].(float64); ok && m >= 1 && m <= 24 {
+		months = int(m)
+	}
+
+	propertyFilter := ""
+	args := []interface{}{}
+	if propertyIDs, ok := report.Criteria["property_ids"].([]interface{}); ok && len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			args = append(args, id)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		propertyFilter = fmt.Sprintf(" AND pu.property_id IN (%s)", strings.Join(placeholders, ", "))
+	}
+
+	var totalUnits int
+	err := db.ReadDB().QueryRow("SELECT COUNT(*) FROM property_units pu WHERE 1=1"+propertyFilter, args...).
+		Scan(&totalUnits)
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Now()
+	args = append(args, PeriodStart(from))
+	query := fmt.Sprintf(`
+		SELECT l.unit_id, l.start_date, l.end_date, l.status, l.notice_given_date IS NOT NULL
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		WHERE l.status IN ('active', 'pending') AND l.end_date >= $%d%s`, len(args), propertyFilter)
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leases []ForecastLease
+	for rows.Next() {
+		var lease ForecastLease
+		var status string
+		if err := rows.Scan(&lease.UnitID, &lease.StartDate, &lease.EndDate, &status, &lease.NoticeGiven); err != nil {
+			return nil, err
+		}
+		lease.Pending = status == "pending"
+		leases = append(leases, lease)
+	}
+
+	forecast := ForecastVacancy(totalUnits, leases, from, months)
+
+	data := &ReportData{
+		Headers: []string{"Month", "Occupied Units", "Notice Units", "Vacant Units", "Projected Occupancy"},
+		Rows:    []map[string]interface{}{},
+	}
+
+	lowest := 100.0
+	lowestMonth := ""
+	for _, point := range forecast {
+		occupancy := 0.0
+		if totalUnits > 0 {
+			occupancy = math.Round(float64(point.Occupied+point.Notice)/float64(totalUnits)*10000) / 100
+		}
+		if occupancy < lowest || lowestMonth == "" {
+			lowest = occupancy
+			lowestMonth = point.Month.Format("2006-01")
+		}
+
+		data.Rows = append(data.Rows, map[string]interface{}{
+			"Month":               point.Month.Format("2006-01"),
+			"Occupied Units":      point.Occupied,
+			"Notice Units":        point.Notice,
+			"Vacant Units":        point.Vacant,
+			"Projected Occupancy": occupancy,
+		})
+	}
+
+	data.Summary = map[string]interface{}{
+		"total_units":                totalUnits,
+		"forecast_months":            months,
+		"lowest_occupancy":           lowest,
+		"lowest_occupancy_month":     lowestMonth,
+		"notice_ending_next_30_days": countNoticeEndingWithin(leases, from, 30),
+	}
+
+	data.Charts = []ChartData{vacancyForecastChart(data.Rows)}
+
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Month": monthColumn, "Occupied Units": numberColumn, "Notice Units": numberColumn,
+		"Vacant Units": numberColumn, "Projected Occupancy": percentColumn,
+	})
+	return data, nil
+}
+
+// countNoticeEndingWithin counts noticed leases whose end date falls within the next days
+func countNoticeEndingWithin(leases []ForecastLease, from time.Time, days int) int {
+	until := from.AddDate(0, 0, days)
+	count := 0
+	for _, lease := range leases {
+		if lease.NoticeGiven && !lease.Pending && !lease.EndDate.Before(from) && !lease.EndDate.After(until) {
+			count++
+		}
+	}
+	return count
+}
+
+// vacancyForecastChart builds a stacked area chart of occupied, notice and vacant units by month
+func vacancyForecastChart(rows []map[string]interface{}) ChartData {
+	series := []struct {
+		column string
+		color  string
+	}{
+		{"Occupied Units", "54, 162, 235"},
+		{"Notice Units", "255, 206, 86"},
+		{"Vacant Units", "255, 99, 132"},
+	}
+
+	datasets := make([]map[string]interface{}, 0, len(series))
+	for _, s := range series {
+		datasets = append(datasets, map[string]interface{}{
+			"label":           s.column,
+			"data":            extractColumn(rows, s.column),
+			"backgroundColor": fmt.Sprintf("rgba(%s, 0.4)", s.color),
+			"borderColor":     fmt.Sprintf("rgba(%s, 1)", s.color),
+			"fill":            true,
+		})
+	}
+
+	return ChartData{
+		Type:  "line",
+		Title: "Vacancy Forecast",
+		Data: map[string]interface{}{
+			"labels":   extractColumn(rows, "Month"),
+			"datasets": datasets,
+		},
+		Config: map[string]interface{}{
+			"scales": map[string]interface{}{
+				"y": map[string]interface{}{"stacked": true},
+			},
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForecastVacancy(t *testing.T) {
+	from := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	leases := []ForecastLease{
+		// Unit 1: occupied all year
+		{UnitID: 1, StartDate: date(1, 1), EndDate: date(12, 31)},
+		// Unit 2: on notice, leaving end of February
+		{UnitID: 2, StartDate: date(1, 1), EndDate: date(2, 28), NoticeGiven: true},
+		// Unit 3: vacant until a pending lease starts in March
+		{UnitID: 3, StartDate: date(3, 1), EndDate: date(12, 31), Pending: true},
+	}
+
+	forecast := ForecastVacancy(4, leases, from, 4)
+	assert.Len(t, forecast, 4)
+
+	assert.Equal(t, date(1, 1), forecast[0].Month)
+	assert.Equal(t, VacancyForecastMonth{Month: date(1, 1), Occupied: 1, Notice: 1, Vacant: 2}, forecast[0])
+	assert.Equal(t, VacancyForecastMonth{Month: date(2, 1), Occupied: 1, Notice: 1, Vacant: 2}, forecast[1])
+	assert.Equal(t, VacancyForecastMonth{Month: date(3, 1), Occupied: 2, Notice: 0, Vacant: 2}, forecast[2])
+	assert.Equal(t, VacancyForecastMonth{Month: date(4, 1), Occupied: 2, Notice: 0, Vacant: 2}, forecast[3])
+}
+
+func TestForecastVacancyPendingReplacesNoticedLease(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	leases := []ForecastLease{
+		{UnitID: 1, StartDate: from, EndDate: from.AddDate(0, 1, 0), NoticeGiven: true},
+		{UnitID: 1, StartDate: from, EndDate: from.AddDate(1, 0, 0), Pending: true},
+	}
+
+	forecast := ForecastVacancy(1, leases, from, 1)
+	assert.Equal(t, 1, forecast[0].Occupied)
+	assert.Equal(t, 0, forecast[0].Notice)
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// IdempotencyKeyTTL is how long a stored response is replayed for retries with the same key
+const IdempotencyKeyTTL = 24 * time.Hour
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+	// ErrIdempotentRequestInProgress is returned when the original request with a key has not finished
+	ErrIdempotentRequestInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// IdempotentRequest is a POST made with an Idempotency-Key header and, once it finished, its response
+type IdempotentRequest struct {
+	ID                  int
+	UserID              int
+	Key                 string
+	Method              string
+	Path                string
+	RequestHash         string
+	ResponseStatus      sql.NullInt32
+	ResponseContentType sql.NullString
+	ResponseBody        sql.NullString
+	CreatedAt           time.Time
+	ExpiresAt           time.Time
+}
+
+// Completed reports whether the original request finished and its response can be replayed
+func (ir *IdempotentRequest) Completed() bool {
+	return ir.ResponseStatus.Valid
+}
+
+// BeginIdempotentRequest claims an idempotency key for a request. When the key is new it is
+// stored as in progress and created is true. When the key was used before, the earlier request
+// is returned for replay if it completed, or ErrIdempotentRequestInProgress or
+// ErrIdempotencyKeyReused is returned.
+func BeginIdempotentRequest(userID int, key, method, path, requestHash string, now time.Time) (*IdempotentRequest, bool, error) {
+	// Expired keys may be reused for new requests
+	if _, err := db.DB.Exec("DELETE FROM idempotency_keys WHERE user_id = $1 AND expires_at < $2",
+		userID, now); err != nil {
+		return nil, false, err
+	}
+
+	ir := &IdempotentRequest{UserID: userID, Key: key, Method: method, Path: path, RequestHash: requestHash,
+		CreatedAt: now, ExpiresAt: now.Add(IdempotencyKeyTTL)}
+	err := db.DB.QueryRow(`
+		INSERT INTO idempotency_keys (user_id, idempotency_key, method, path, request_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, idempotency_key) DO NOTHING
+		RETURNING id`,
+		userID, key, method, path, requestHash, now, ir.ExpiresAt).Scan(&ir.ID)
+	if err == nil {
+		return ir, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	existing := &IdempotentRequest{}
+	err = db.DB.QueryRow(`
+		SELECT id, user_id, idempotency_key, method, path, request_hash, response_status,
+			   response_content_type, response_body, created_at, expires_at
+		FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2`, userID, key).Scan(
+		&existing.ID, &existing.UserID, &existing.Key, &existing.Method, &existing.Path, &existing.RequestHash,
+		&existing.ResponseStatus, &existing.ResponseContentType, &existing.ResponseBody,
+		&existing.CreatedAt, &existing.ExpiresAt)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if existing.RequestHash != requestHash {
+		return existing, false, ErrIdempotencyKeyReused
+	}
+	if !existing.Completed() {
+		return existing, false, ErrIdempotentRequestInProgress
+	}
+	return existing, false, nil
+}
+
+// CompleteIdempotentRequest stores the response to replay for retries
+func CompleteIdempotentRequest(id, status int, contentType string, body []byte) error {
+	result, err := db.DB.Exec(`
+		UPDATE idempotency_keys
+		SET response_status = $2, response_content_type = $3, response_body = $4
+		WHERE id = $1`, id, status, NullString(contentType), string(body))
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// ReleaseIdempotentRequest forgets a key whose request failed, so the client can retry it
+func ReleaseIdempotentRequest(id int) error {
+	_, err := db.DB.Exec("DELETE FROM idempotency_keys WHERE id = $1", id)
+	return err
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Import commit modes
+const (
+	// ImportAllOrNothing validates every row first and imports nothing if any row is bad
+	ImportAllOrNothing = "all_or_nothing"
+	// ImportSkipBadRows imports the good rows and reports the bad ones
+	ImportSkipBadRows = "skip_bad_rows"
+)
+
+// Import job statuses
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportJob is a CSV import processed in the background
+type ImportJob struct {
+	ID            int            `json:"id"`
+	ImportType    string         `json:"import_type"`
+	Mode          string         `json:"mode"`
+	Status        string         `json:"status"`
+	Filename      sql.NullString `json:"filename,omitempty"`
+	SourceCSV     string         `json:"-"`
+	TotalRows     int            `json:"total_rows"`
+	ProcessedRows int            `json:"processed_rows"`
+	SucceededRows int            `json:"succeeded_rows"`
+	FailedRows    int            `json:"failed_rows"`
+	Attempts      int            `json:"attempts"` // Times a worker has claimed the job
+	Error         sql.NullString `json:"error,omitempty"`
+	CreatedBy     sql.NullInt32  `json:"created_by,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	StartedAt     sql.NullTime   `json:"started_at,omitempty"`
+	FinishedAt    sql.NullTime   `json:"finished_at,omitempty"`
+}
+
+// ImportRowError records why one row of an import could not be imported
+type ImportRowError struct {
+	RowNumber int    `json:"row_number"`
+	Message   string `json:"message"`
+	RawRow    string `json:"raw_row"`
+}
+
+// ValidImportMode reports whether mode is a known commit mode
+func ValidImportMode(mode string) bool {
+	return mode == ImportAllOrNothing || mode == ImportSkipBadRows
+}
+
+// CreateImportJob queues an import for the background worker
+func CreateImportJob(job *ImportJob) error {
+	return db.DB.QueryRow(`
+		INSERT INTO import_jobs (import_type, mode, filename, source_csv, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at`,
+		job.ImportType, job.Mode, job.Filename, job.SourceCSV, job.CreatedBy).
+		Scan(&job.ID, &job.Status, &job.CreatedAt)
+}
+
+const importJobColumns = `id, import_type, mode, status, filename, total_rows, processed_rows, succeeded_rows,
+	failed_rows, attempts, error, created_by, created_at, started_at, finished_at`
+
+func scanImportJob(row interface{ Scan(...interface{}) error }, extra ...interface{}) (ImportJob, error) {
+	var j ImportJob
+	dest := append([]interface{}{&j.ID, &j.ImportType, &j.Mode, &j.Status, &j.Filename, &j.TotalRows,
+		&j.ProcessedRows, &j.SucceededRows, &j.FailedRows, &j.Attempts, &j.Error, &j.CreatedBy, &j.CreatedAt,
+		&j.StartedAt, &j.FinishedAt}, extra...)
+	err := row.Scan(dest...)
+	return j, err
+}
+
+// GetImportJob retrieves an import job's progress by ID
+func GetImportJob(id int) (*ImportJob, error) {
+	job, err := scanImportJob(db.DB.QueryRow("SELECT "+importJobColumns+" FROM import_jobs WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetImportJobs returns the most recent import jobs, newest first
+func GetImportJobs(limit int) ([]ImportJob, error) {
+	rows, err := db.DB.Query("SELECT "+importJobColumns+" FROM import_jobs ORDER BY id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []ImportJob
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimImportJob marks the oldest queued job as running, leased to the caller for lease, and
+// returns it with its file contents. A running job whose lease expired, because its worker
+// stopped, is claimed again with its attempts counted. It returns sql.ErrNoRows when there is
+// nothing to run.
+func ClaimImportJob(lease time.Duration) (*ImportJob, error) {
+	now := time.Now()
+	query := fmt.Sprintf(`
+		UPDATE import_jobs
+		SET status = 'running', started_at = COALESCE(started_at, $2), lease_expires_at = $1,
+			attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM import_jobs
+			WHERE status = 'queued' OR (status = 'running' AND lease_expires_at <= $2)
+			ORDER BY id
+			LIMIT 1
+			%s
+		) AND (status = 'queued' OR (status = 'running' AND lease_expires_at <= $2))
+		RETURNING `+importJobColumns+`, source_csv`,
+		db.CurrentDialect.SkipLocked())
+
+	var source string
+	job, err := scanImportJob(db.DB.QueryRow(query, now.Add(lease), now), &source)
+	if err != nil {
+		return nil, err
+	}
+	job.SourceCSV = source
+	return &job, nil
+}
+
+// ExtendImportJobLease keeps a running job leased to its worker for another lease. It returns
+// sql.ErrNoRows if the job is no longer running.
+func ExtendImportJobLease(jobID int, lease time.Duration) error {
+	result, err := db.DB.Exec("UPDATE import_jobs SET lease_expires_at = $2 WHERE id = $1 AND status = 'running'",
+		jobID, time.Now().Add(lease))
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// ResetImportJobProgress clears the counts and row errors of an earlier attempt at a job, for
+// jobs whose rows are only saved at the end
+func ResetImportJobProgress(job *ImportJob) error {
+	if _, err := db.DB.Exec("DELETE FROM import_job_errors WHERE job_id = $1", job.ID); err != nil {
+		return err
+	}
+	job.ProcessedRows, job.SucceededRows, job.FailedRows = 0, 0, 0
+	return UpdateImportJobProgress(db.DB, job)
+}
+
+// UpdateImportJobProgress saves a running job's row counts so clients can poll them
+func UpdateImportJobProgress(q Querier, job *ImportJob) error {
+	_, err := q.Exec(`
+		UPDATE import_jobs
+		SET total_rows = $2, processed_rows = $3, succeeded_rows = $4, failed_rows = $5
+		WHERE id = $1`,
+		job.ID, job.TotalRows, job.ProcessedRows, job.SucceededRows, job.FailedRows)
+	return err
+}
+
+// FinishImportJob records a job's final status, counts and, for failed jobs, the reason
+func FinishImportJob(job *ImportJob) error {
+	_, err := db.DB.Exec(`
+		UPDATE import_jobs
+		SET status = $2, total_rows = $3, processed_rows = $4, succeeded_rows = $5, failed_rows = $6,
+			error = $7, finished_at = NOW()
+		WHERE id = $1`,
+		job.ID, job.Status, job.TotalRows, job.ProcessedRows, job.SucceededRows, job.FailedRows, job.Error)
+	return err
+}
+
+// AddImportRowError records a row that could not be imported
+func AddImportRowError(q Querier, jobID int, rowErr ImportRowError) error {
+	_, err := q.Exec(`
+		INSERT INTO import_job_errors (job_id, row_number, message, raw_row)
+		VALUES ($1, $2, $3, $4)`,
+		jobID, rowErr.RowNumber, rowErr.Message, rowErr.RawRow)
+	return err
+}
+
+// GetImportRowErrors returns a job's row errors in file order
+func GetImportRowErrors(jobID int) ([]ImportRowError, error) {
+	rows, err := db.DB.Query(`
+		SELECT row_number, message, raw_row FROM import_job_errors
+		WHERE job_id = $1
+		ORDER BY row_number, id`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rowErrors []ImportRowError
+	for rows.Next() {
+		var e ImportRowError
+		if err := rows.Scan(&e.RowNumber, &e.Message, &e.RawRow); err != nil {
+			return nil, err
+		}
+		rowErrors = append(rowErrors, e)
+	}
+	return rowErrors, rows.Err()
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimImportJob(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	columns := []string{"id", "import_type", "mode", "status", "filename", "total_rows", "processed_rows",
+		"succeeded_rows", "failed_rows", "attempts", "error", "created_by", "created_at", "started_at", "finished_at", "source_csv"}
+	mock.ExpectQuery(`UPDATE import_jobs\s+SET status = 'running'(.+)attempts = attempts \+ 1(.+)`+
+		`WHERE status = 'queued' OR \(status = 'running' AND lease_expires_at <= \$2\)(.+)RETURNING`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, "properties", ImportSkipBadRows, ImportRunning, "p.csv",
+			0, 0, 0, 0, 1, nil, 1, time.Now(), time.Now(), nil, "Name,Address,PropertyType\n"))
+
+	job, err := ClaimImportJob(time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.ID)
+	assert.Equal(t, ImportRunning, job.Status)
+	assert.Equal(t, "Name,Address,PropertyType\n", job.SourceCSV)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimImportJobNoneQueued(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`UPDATE import_jobs`).WillReturnError(sql.ErrNoRows)
+
+	_, err := ClaimImportJob(time.Minute)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
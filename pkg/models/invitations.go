@@ -0,0 +1,409 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// UserStatusInvited marks accounts created by an administrator whose owner has not yet
+// completed signup
+const UserStatusInvited = "invited"
+
+// InvitationTTL is how long an invitation token stays valid
+const InvitationTTL = 7 * 24 * time.Hour
+
+// ErrInvitationInvalid is returned for unknown, expired or already accepted invitation tokens
+var ErrInvitationInvalid = errors.New("invitation is invalid or has expired")
+
+// UserInvitation is a pending or accepted signup invitation
+type UserInvitation struct {
+	ID         int           `json:"id"`
+	UserID     int           `json:"user_id"`
+	Email      string        `json:"email"`
+	InvitedBy  sql.NullInt32 `json:"invited_by,omitempty"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+	AcceptedAt sql.NullTime  `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// ImportUserRow is one user parsed from a bulk import file
+type ImportUserRow struct {
+	Row         int      `json:"row"` // 1-based line number in the file, counting the header
+	Email       string   `json:"email"`
+	Username    string   `json:"username"`
+	FirstName   string   `json:"first_name"`
+	LastName    string   `json:"last_name"`
+	PhoneNumber string   `json:"phone_number,omitempty"`
+	Roles       []string `json:"roles"`
+}
+
+// Validate checks a row's required fields and fills in a username derived from the email
+func (row *ImportUserRow) Validate() error {
+	row.Email = strings.TrimSpace(row.Email)
+	address, err := mail.ParseAddress(row.Email)
+	if err != nil || address.Address != row.Email {
+		return fmt.Errorf("invalid email address %q", row.Email)
+	}
+	if row.Username == "" {
+		row.Username = strings.ToLower(row.Email[:strings.Index(row.Email, "@")])
+	}
+	if strings.ContainsAny(row.Username, " \t") {
+		return fmt.Errorf("username must not contain spaces")
+	}
+	if len(row.Roles) == 0 {
+		row.Roles = []string{"tenant"}
+	}
+	return nil
+}
+
+// ParseImportRows converts CSV records into import rows. The first record is a header naming
+// the columns: email is required; username, first_name, last_name, phone_number and roles
+// (separated by ";" or "|") are optional.
+func ParseImportRows(records [][]string) ([]ImportUserRow, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("header must include an email column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []ImportUserRow
+	for i, record := range records[1:] {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		row := ImportUserRow{
+			Row:         i + 2,
+			Email:       field(record, "email"),
+			Username:    field(record, "username"),
+			FirstName:   field(record, "first_name"),
+			LastName:    field(record, "last_name"),
+			PhoneNumber: field(record, "phone_number"),
+		}
+		for _, role := range strings.FieldsFunc(field(record, "roles"), func(r rune) bool { return r == ';' || r == '|' }) {
+			if role = strings.TrimSpace(role); role != "" {
+				row.Roles = append(row.Roles, role)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// CreateInvitation issues a signup token for a user and returns the plaintext token, which is
+// not stored. Earlier unaccepted invitations for the user are revoked.
+func CreateInvitation(q Querier, userID int, email string, invitedBy sql.NullInt32, now time.Time) (string, *UserInvitation, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(bytes)
+
+	if _, err := q.Exec(
+		"DELETE FROM user_invitations WHERE user_id = $1 AND accepted_at IS NULL", userID); err != nil {
+		return "", nil, err
+	}
+
+	invitation := &UserInvitation{UserID: userID, Email: email, InvitedBy: invitedBy, ExpiresAt: now.Add(InvitationTTL)}
+	err := q.QueryRow(`
+		INSERT INTO user_invitations (user_id, email, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		userID, email, HashAPIKey(token), invitedBy, invitation.ExpiresAt).Scan(&invitation.ID, &invitation.CreatedAt)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, invitation, nil
+}
+
+// GetInvitationByToken returns the pending invitation for a plaintext token, or
+// ErrInvitationInvalid if it is unknown, expired or already accepted
+func GetInvitationByToken(token string, now time.Time) (*UserInvitation, error) {
+	invitation := &UserInvitation{}
+	err := db.DB.QueryRow(`
+		SELECT id, user_id, email, invited_by, expires_at, accepted_at, created_at
+		FROM user_invitations WHERE token_hash = $1`, HashAPIKey(token)).Scan(
+		&invitation.ID, &invitation.UserID, &invitation.Email, &invitation.InvitedBy,
+		&invitation.ExpiresAt, &invitation.AcceptedAt, &invitation.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invitation.AcceptedAt.Valid || !now.Before(invitation.ExpiresAt) {
+		return nil, ErrInvitationInvalid
+	}
+	return invitation, nil
+}
+
+// AcceptInvitation completes signup: the profile fields are saved with a verified email and the
+// invitation is marked accepted. The account stays invited until LinkInvitedUser attaches the
+// identity it signs in with.
+func AcceptInvitation(token string, firstName, lastName, phoneNumber string, now time.Time) (*User, error) {
+	invitation, err := GetInvitationByToken(token, now)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The accepted_at check guards against the same token being accepted twice concurrently
+	result, err := tx.Exec(
+		"UPDATE user_invitations SET accepted_at = $1 WHERE id = $2 AND accepted_at IS NULL", now, invitation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, ErrInvitationInvalid
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE users SET first_name = COALESCE(NULLIF($1, ''), first_name),
+		                 last_name = COALESCE(NULLIF($2, ''), last_name),
+		                 phone_number = COALESCE($3, phone_number),
+		                 email_verified = $4, updated_at = NOW()
+		WHERE id = $5 AND status = $6`,
+		firstName, lastName, NullString(phoneNumber), true, invitation.UserID, UserStatusInvited); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetUserByID(invitation.UserID)
+}
+
+// ErrUserExists is returned when an imported user's email or username is already taken
+var ErrUserExists = errors.New("a user with this email or username already exists")
+
+// ImportOptions controls how ImportUser creates an account
+type ImportOptions struct {
+	InvitedBy       sql.NullInt32
+	KeycloakID      string                    // Set when the account was already created in Keycloak
+	InviteLink      func(token string) string // Builds the signup link; nil skips the invitation email
+	ApprovalRoleIDs []int                     // Sensitive roles requested for a second admin's approval
+	ApprovalLink    string                    // Where approvers review role requests
+	Now             time.Time
+}
+
+// ImportUser creates an account for an import row with the given roles. Roles in
+// opts.ApprovalRoleIDs are not granted; a role request is created for each instead. Accounts
+// created in Keycloak are active immediately; others are created as invited and, when
+// opts.InviteLink is set, an invitation email is queued. Everything happens in one transaction.
+func ImportUser(row ImportUserRow, roleIDs []int, opts ImportOptions) (*User, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE LOWER(email) = LOWER($1) OR username = $2",
+		row.Email, row.Username).Scan(&existing); err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrUserExists
+	}
+
+	user := &User{
+		KeycloakID:  NullString(opts.KeycloakID),
+		Username:    row.Username,
+		Email:       row.Email,
+		FirstName:   row.FirstName,
+		LastName:    row.LastName,
+		PhoneNumber: NullString(row.PhoneNumber),
+		Status:      UserStatusInvited,
+	}
+	if opts.KeycloakID != "" {
+		user.Status = "active"
+	}
+	if err := tx.QueryRow(`
+		INSERT INTO users (keycloak_id, username, email, first_name, last_name, phone_number, email_verified, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		user.KeycloakID, user.Username, user.Email, user.FirstName, user.LastName, user.PhoneNumber,
+		false, user.Status).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	var assignedBy interface{}
+	if opts.InvitedBy.Valid {
+		assignedBy = opts.InvitedBy.Int32
+	}
+	for _, roleID := range roleIDs {
+		if _, err := tx.Exec("INSERT INTO user_roles (user_id, role_id, assigned_by) VALUES ($1, $2, $3)",
+			user.ID, roleID, assignedBy); err != nil {
+			return nil, err
+		}
+	}
+	for _, roleID := range opts.ApprovalRoleIDs {
+		rq := &RoleRequest{UserID: user.ID, RoleID: roleID, RequestedBy: opts.InvitedBy}
+		if err := createRoleRequest(tx, rq, opts.ApprovalLink); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.KeycloakID == "" && opts.InviteLink != nil {
+		if err := queueInvitation(tx, user, opts.InvitedBy, opts.InviteLink, opts.Now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ResendInvitation issues a fresh invitation to a user who has not completed signup
+func ResendInvitation(userID int, invitedBy sql.NullInt32, inviteLink func(token string) string, now time.Time) error {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Status != UserStatusInvited {
+		return ErrInvitationInvalid
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := queueInvitation(tx, user, invitedBy, inviteLink, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// queueInvitation creates an invitation token and queues the invitation email
+func queueInvitation(q Querier, user *User, invitedBy sql.NullInt32, inviteLink func(token string) string, now time.Time) error {
+	token, invitation, err := CreateInvitation(q, user.ID, user.Email, invitedBy, now)
+	if err != nil {
+		return err
+	}
+
+	subject, body := FormatInvitationEmail(user, inviteLink(token), invitation.ExpiresAt)
+	return EnqueueOutboxMessage(q, &OutboxMessage{
+		Channel:     "email",
+		EventType:   "user.invited",
+		Destination: user.Email,
+		Payload: map[string]interface{}{
+			"user_id":       user.ID,
+			"invitation_id": invitation.ID,
+			"subject":       subject,
+			"body":          body,
+		},
+	})
+}
+
+// FormatInvitationEmail builds the subject and body of an invitation email
+func FormatInvitationEmail(user *User, link string, expires time.Time) (string, string) {
+	name := strings.TrimSpace(user.FirstName)
+	if name == "" {
+		name = user.Username
+	}
+	subject := "You're invited to Fire PMAAS"
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"An account has been created for you on Fire PMAAS with the username %s.\n\n"+
+		"Complete your signup here:\n%s\n\n"+
+		"This link expires on %s.\n",
+		name, user.Username, link, expires.Format("January 2, 2006"))
+	return subject, body
+}
+
+// ErrPrivilegedLink is returned when an identity would be linked to an account holding a role
+// that needs approval. Those accounts are only linked by their Keycloak subject.
+var ErrPrivilegedLink = errors.New("privileged accounts are not linked by invitation")
+
+// LinkInvitedUser attaches a Keycloak identity to the invited account whose invitation token was
+// accepted, and activates it. The identity's verified email must match the invitation, and
+// accounts holding any role for which privileged reports true are never linked.
+func LinkInvitedUser(token, keycloakID, email string, privileged func(role string) bool, now time.Time) (*User, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var invitedEmail string
+	err = tx.QueryRow(`
+		SELECT i.user_id, i.email FROM user_invitations i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.token_hash = $1 AND i.accepted_at IS NOT NULL AND i.expires_at > $2
+		  AND u.status = $3 AND u.keycloak_id IS NULL`,
+		HashAPIKey(token), now, UserStatusInvited).Scan(&userID, &invitedEmail)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(invitedEmail, email) {
+		return nil, ErrInvitationInvalid
+	}
+
+	rows, err := tx.Query(`
+		SELECT r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		if privileged(role) {
+			return nil, ErrPrivilegedLink
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE users SET keycloak_id = $1, email_verified = $2, status = 'active', updated_at = NOW()
+		WHERE id = $3 AND status = $4 AND keycloak_id IS NULL`,
+		keycloakID, true, userID, UserStatusInvited)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, ErrInvitationInvalid
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetUserByID(userID)
+}
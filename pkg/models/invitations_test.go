@@ -0,0 +1,183 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportRows(t *testing.T) {
+	rows, err := ParseImportRows([][]string{
+		{"Email", "first_name", "roles"},
+		{"jane@example.com", "Jane", "admin; viewer"},
+		{"", "", ""},
+		{"bob@example.com", "Bob", ""},
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, 2, rows[0].Row)
+	assert.Equal(t, []string{"admin", "viewer"}, rows[0].Roles)
+	assert.Equal(t, 4, rows[1].Row)
+	assert.Equal(t, "Bob", rows[1].FirstName)
+
+	_, err = ParseImportRows([][]string{{"username"}, {"jane"}})
+	assert.Error(t, err)
+}
+
+func TestImportUserRowValidate(t *testing.T) {
+	row := ImportUserRow{Email: " Jane.Doe@example.com "}
+	require.NoError(t, row.Validate())
+	assert.Equal(t, "jane.doe", row.Username)
+	assert.Equal(t, []string{"tenant"}, row.Roles)
+
+	row = ImportUserRow{Email: "not-an-email"}
+	assert.Error(t, row.Validate())
+
+	row = ImportUserRow{Email: "jane@example.com", Username: "jane doe"}
+	assert.Error(t, row.Validate())
+}
+
+func TestFormatInvitationEmail(t *testing.T) {
+	expires := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	subject, body := FormatInvitationEmail(&User{Username: "jdoe"}, "https://app/invitations/accept?token=abc", expires)
+	assert.NotEmpty(t, subject)
+	assert.Contains(t, body, "Hi jdoe,")
+	assert.Contains(t, body, "https://app/invitations/accept?token=abc")
+	assert.Contains(t, body, "March 8, 2024")
+}
+
+func TestImportUserQueuesInvitation(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users`).
+		WithArgs("jane@example.com", "jane").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO users`).
+		WithArgs(nil, "jane", "jane@example.com", "", "", nil, false, UserStatusInvited).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(7, now, now))
+	mock.ExpectExec(`INSERT INTO user_roles`).WithArgs(7, 3, nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM user_invitations`).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO user_invitations`).
+		WithArgs(7, "jane@example.com", sqlmock.AnyArg(), nil, now.Add(InvitationTTL)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(11, now))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", "user.invited", "jane@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	row := ImportUserRow{Email: "jane@example.com", Username: "jane"}
+	user, err := ImportUser(row, []int{3}, ImportOptions{
+		InviteLink: func(token string) string { return "https://app/accept?token=" + token },
+		Now:        now,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 7, user.ID)
+	assert.Equal(t, UserStatusInvited, user.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportUserRequestsSensitiveRoles(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	admin := sql.NullInt32{Int32: 1, Valid: true}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO users`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(7, now, now))
+	mock.ExpectExec(`INSERT INTO user_roles`).WithArgs(7, 3, int32(1)).WillReturnResult(sqlmock.NewResult(1, 1))
+	// The admin role is only requested; it is not inserted into user_roles
+	mock.ExpectQuery(`SELECT username FROM users`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("jane"))
+	mock.ExpectQuery(`SELECT name FROM roles`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("admin"))
+	mock.ExpectQuery(`SELECT 1 FROM user_roles`).WithArgs(7, 2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT 1 FROM role_requests`).WithArgs(7, 2, RoleRequestPending).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`INSERT INTO role_requests`).WithArgs(7, 2, sql.NullTime{}, admin, RoleRequestPending).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "requested_at"}).AddRow(5, now))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(admin, AuditRoleRequested, "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	mock.ExpectQuery(`SELECT DISTINCT u.email`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}))
+	mock.ExpectCommit()
+
+	row := ImportUserRow{Email: "jane@example.com", Username: "jane"}
+	_, err := ImportUser(row, []int{3}, ImportOptions{InvitedBy: admin, ApprovalRoleIDs: []int{2}, Now: now})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportUserRejectsExisting(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	_, err := ImportUser(ImportUserRow{Email: "jane@example.com", Username: "jane"}, nil, ImportOptions{})
+	assert.Equal(t, ErrUserExists, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetInvitationByTokenExpired(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT (.+) FROM user_invitations WHERE token_hash = \$1`).
+		WithArgs(HashAPIKey("abc")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "email", "invited_by", "expires_at", "accepted_at", "created_at"}).
+			AddRow(1, 7, "jane@example.com", nil, now.Add(-time.Hour), nil, now.Add(-InvitationTTL)))
+
+	_, err := GetInvitationByToken("abc", now)
+	assert.Equal(t, ErrInvitationInvalid, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLinkInvitedUserRefusesPrivilegedAndMismatchedAccounts(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	privileged := func(role string) bool { return role == "admin" }
+	invitation := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"user_id", "email"}).AddRow(7, "Jane@example.com")
+	}
+
+	// The token was never accepted, or the account is no longer invited
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM user_invitations i`).WithArgs(HashAPIKey("abc"), now, UserStatusInvited).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err := LinkInvitedUser("abc", "kc-1", "jane@example.com", privileged, now)
+	assert.Equal(t, ErrInvitationInvalid, err)
+
+	// The identity's email differs from the invitation's
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM user_invitations i`).WillReturnRows(invitation())
+	mock.ExpectRollback()
+	_, err = LinkInvitedUser("abc", "kc-1", "mallory@example.com", privileged, now)
+	assert.Equal(t, ErrInvitationInvalid, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM user_invitations i`).WillReturnRows(invitation())
+	mock.ExpectQuery(`SELECT r.name FROM user_roles ur`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("tenant").AddRow("admin"))
+	mock.ExpectRollback()
+	_, err = LinkInvitedUser("abc", "kc-1", "jane@example.com", privileged, now)
+	assert.Equal(t, ErrPrivilegedLink, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,300 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// DigestSeriesLength is the number of periods plotted in each digest sparkline
+const DigestSeriesLength = 8
+
+// DigestLeaseHorizonDays is how far ahead the digest lists expiring leases
+const DigestLeaseHorizonDays = 60
+
+// DigestTrendThreshold is the change, in percent, from the previous period at which a KPI is
+// called out as a notable trend
+const DigestTrendThreshold = 10.0
+
+// DigestRecipient is a manager who receives the KPI digest
+type DigestRecipient struct {
+	UserID      int
+	Email       string
+	FirstName   string
+	Preferences UserPreferences
+	Properties  PropertyFilter // The properties their digest covers
+}
+
+// DigestMetric is one KPI with its value over the last DigestSeriesLength periods, oldest first
+type DigestMetric struct {
+	Key    string
+	Label  string
+	Format string // StatFormatCurrency, StatFormatPercentage or StatFormatCount
+	Values []float64
+}
+
+// Current returns the value for the period the digest covers
+func (m DigestMetric) Current() float64 {
+	if len(m.Values) == 0 {
+		return 0
+	}
+	return m.Values[len(m.Values)-1]
+}
+
+// Change returns the percentage change from the previous period. ok is false when there is no
+// previous period or it was zero.
+func (m DigestMetric) Change() (change float64, ok bool) {
+	if len(m.Values) < 2 || m.Values[len(m.Values)-2] == 0 {
+		return 0, false
+	}
+	previous := m.Values[len(m.Values)-2]
+	return math.Round((m.Current()-previous)/math.Abs(previous)*1000) / 10, true
+}
+
+// DigestLease is a lease expiring within DigestLeaseHorizonDays
+type DigestLease struct {
+	LeaseID      int
+	PropertyName string
+	UnitNumber   string
+	TenantName   string
+	EndDate      time.Time
+	MonthlyRent  float64
+}
+
+// KPIDigest is the content of one digest email
+type KPIDigest struct {
+	Frequency          string
+	Period             string // Delivery key, e.g. "weekly:2026-W41"
+	PeriodStart        time.Time
+	PeriodEnd          time.Time // Exclusive
+	Metrics            []DigestMetric
+	ExpiringLeases     []DigestLease
+	OverdueMaintenance []MaintenanceSLARecord
+}
+
+// DigestTrend is a KPI whose change from the previous period reached DigestTrendThreshold
+type DigestTrend struct {
+	Label  string
+	Change float64
+}
+
+// Trends returns the notable changes from the previous period, largest first
+func (d *KPIDigest) Trends() []DigestTrend {
+	var trends []DigestTrend
+	for _, metric := range d.Metrics {
+		if change, ok := metric.Change(); ok && math.Abs(change) >= DigestTrendThreshold {
+			trends = append(trends, DigestTrend{Label: metric.Label, Change: change})
+		}
+	}
+	sort.SliceStable(trends, func(i, j int) bool { return math.Abs(trends[i].Change) > math.Abs(trends[j].Change) })
+	return trends
+}
+
+// DigestPeriod returns the most recently completed digest period before now: the previous ISO
+// week (Monday to Monday) or calendar month. end is exclusive.
+func DigestPeriod(frequency string, now time.Time) (key string, start, end time.Time) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if frequency == DigestMonthly {
+		end = midnight.AddDate(0, 0, 1-now.Day())
+		start = end.AddDate(0, -1, 0)
+		return fmt.Sprintf("%s:%s", DigestMonthly, start.Format("2006-01")), start, end
+	}
+
+	// Weekdays count from Sunday; ISO weeks start on Monday
+	end = midnight.AddDate(0, 0, -(int(now.Weekday())+6)%7)
+	start = end.AddDate(0, 0, -7)
+	year, week := start.ISOWeek()
+	return fmt.Sprintf("%s:%d-W%02d", DigestWeekly, year, week), start, end
+}
+
+// previousDigestPeriod returns the period before the one starting at start
+func previousDigestPeriod(frequency string, start time.Time) time.Time {
+	if frequency == DigestMonthly {
+		return start.AddDate(0, -1, 0)
+	}
+	return start.AddDate(0, 0, -7)
+}
+
+// GetKPIDigestRecipients returns the active admins and property managers with their preferences
+// and the properties they manage
+func GetKPIDigestRecipients() ([]DigestRecipient, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT u.id, u.email, u.first_name, u.preferences,
+			   EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON ur.role_id = r.id
+				WHERE ur.user_id = u.id AND r.name = 'admin')
+		FROM users u
+		WHERE u.status = 'active' AND u.email <> ''
+		  AND EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON ur.role_id = r.id
+			WHERE ur.user_id = u.id AND r.name IN ('admin', 'property_manager'))
+		ORDER BY u.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []DigestRecipient
+	var admin []bool
+	for rows.Next() {
+		var recipient DigestRecipient
+		var stored sql.NullString
+		var isAdmin bool
+		if err := rows.Scan(&recipient.UserID, &recipient.Email, &recipient.FirstName, &stored, &isAdmin); err != nil {
+			return nil, err
+		}
+		// Unreadable preferences fall back to the defaults rather than dropping the recipient
+		recipient.Preferences, _ = parsePreferences(stored)
+		recipients = append(recipients, recipient)
+		admin = append(admin, isAdmin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Recipients other than admins are property managers, who only hear about the properties
+	// of their regions or portfolios
+	for i := range recipients {
+		if admin[i] {
+			continue
+		}
+		ids, scoped, err := managedPropertyIDs(recipients[i].UserID, true)
+		if err != nil {
+			return nil, err
+		}
+		recipients[i].Properties = PropertyFilter{IDs: ids, Scoped: scoped}
+	}
+	return recipients, nil
+}
+
+// BuildKPIDigest compiles the KPIs at properties for the most recent completed period and the
+// DigestSeriesLength-1 periods before it, upcoming lease expirations and overdue maintenance
+func BuildKPIDigest(frequency string, now time.Time, properties PropertyFilter) (*KPIDigest, error) {
+	period, start, end := DigestPeriod(frequency, now)
+	digest := &KPIDigest{
+		Frequency:   frequency,
+		Period:      period,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Metrics: []DigestMetric{
+			{Key: "rent_collected", Label: "Rent collected", Format: StatFormatCurrency},
+			{Key: "occupancy_rate", Label: "Occupancy", Format: StatFormatPercentage},
+			{Key: "maintenance_opened", Label: "Maintenance requests opened", Format: StatFormatCount},
+			{Key: "maintenance_resolved", Label: "Maintenance requests resolved", Format: StatFormatCount},
+		},
+	}
+	for i := range digest.Metrics {
+		digest.Metrics[i].Values = make([]float64, DigestSeriesLength)
+	}
+
+	periodStart, periodEnd := start, end
+	for i := DigestSeriesLength - 1; i >= 0; i-- {
+		values, err := digestPeriodValues(periodStart, periodEnd, properties)
+		if err != nil {
+			return nil, err
+		}
+		for m := range digest.Metrics {
+			digest.Metrics[m].Values[i] = values[m]
+		}
+		periodStart, periodEnd = previousDigestPeriod(frequency, periodStart), periodStart
+	}
+
+	leases, err := getDigestExpiringLeases(now, properties)
+	if err != nil {
+		return nil, err
+	}
+	digest.ExpiringLeases = leases
+
+	records, err := GetOpenMaintenanceSLARecords(properties)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.SLA != nil && record.SLA.Status == SLAStatusBreached {
+			digest.OverdueMaintenance = append(digest.OverdueMaintenance, record)
+		}
+	}
+
+	return digest, nil
+}
+
+// digestPeriodValues returns the digest metrics for one period, in the order BuildKPIDigest
+// declares them. Occupancy is measured at the end of the period.
+func digestPeriodValues(start, end time.Time, properties PropertyFilter) ([]float64, error) {
+	args := []interface{}{start, end}
+	var paymentScope, leaseScope, unitScope, openedScope, resolvedScope string
+	paymentScope, args = properties.condition(`(SELECT pu.property_id FROM leases l
+		JOIN property_units pu ON pu.id = l.unit_id WHERE l.id = payments.lease_id)`, args)
+	leaseScope, args = properties.condition("(SELECT property_id FROM property_units WHERE id = leases.unit_id)", args)
+	unitScope, args = properties.condition("property_id", args)
+	openedScope, args = properties.condition("property_id", args)
+	resolvedScope, args = properties.condition("property_id", args)
+
+	var collected float64
+	var occupied, units, opened, resolved int
+	err := db.ReadDB().QueryRow(`
+		SELECT
+			(SELECT COALESCE(SUM(amount), 0) FROM payments
+				WHERE status = 'completed' AND payment_date >= $1 AND payment_date < $2`+paymentScope+`),
+			(SELECT COUNT(DISTINCT unit_id) FROM leases
+				WHERE status <> 'pending' AND start_date < $2 AND end_date >= $2`+leaseScope+`),
+			(SELECT COUNT(*) FROM property_units WHERE 1 = 1`+unitScope+`),
+			(SELECT COUNT(*) FROM maintenance_requests WHERE created_at >= $1 AND created_at < $2`+openedScope+`),
+			(SELECT COUNT(*) FROM maintenance_requests WHERE resolved_at >= $1 AND resolved_at < $2`+resolvedScope+`)`,
+		args...).Scan(&collected, &occupied, &units, &opened, &resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	occupancy := 0.0
+	if units > 0 {
+		occupancy = math.Round(float64(occupied)/float64(units)*1000) / 10
+	}
+	return []float64{collected, occupancy, float64(opened), float64(resolved)}, nil
+}
+
+// getDigestExpiringLeases returns active leases at properties ending within DigestLeaseHorizonDays of now
+func getDigestExpiringLeases(now time.Time, properties PropertyFilter) ([]DigestLease, error) {
+	scope, args := properties.condition("p.id", []interface{}{now, now.AddDate(0, 0, DigestLeaseHorizonDays)})
+	rows, err := db.ReadDB().Query(`
+		SELECT l.id, p.name, COALESCE(pu.unit_number, ''), t.first_name || ' ' || t.last_name,
+			l.end_date, l.monthly_rent
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		JOIN tenants t ON l.tenant_id = t.id
+		WHERE l.status = 'active' AND l.end_date >= $1 AND l.end_date < $2`+scope+`
+		ORDER BY l.end_date, p.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leases []DigestLease
+	for rows.Next() {
+		var lease DigestLease
+		if err := rows.Scan(&lease.LeaseID, &lease.PropertyName, &lease.UnitNumber, &lease.TenantName,
+			&lease.EndDate, &lease.MonthlyRent); err != nil {
+			return nil, err
+		}
+		leases = append(leases, lease)
+	}
+	return leases, rows.Err()
+}
+
+// ClaimKPIDigest records that a user's digest for a period is being sent, using the caller's
+// transaction. It returns false when the digest was already sent.
+func ClaimKPIDigest(q Querier, userID int, period string) (bool, error) {
+	var id int
+	err := q.QueryRow(`
+		INSERT INTO kpi_digest_deliveries (user_id, period) VALUES ($1, $2)
+		ON CONFLICT (user_id, period) DO NOTHING
+		RETURNING id`, userID, period).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestPeriod(t *testing.T) {
+	// Friday 16 October 2026
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+
+	key, start, end := DigestPeriod(DigestWeekly, now)
+	assert.Equal(t, "weekly:2026-W41", key)
+	assert.Equal(t, time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), end)
+
+	// On a Monday the week that just ended is reported
+	key, _, _ = DigestPeriod(DigestWeekly, time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC))
+	assert.Equal(t, "weekly:2026-W41", key)
+
+	key, start, end = DigestPeriod(DigestMonthly, now)
+	assert.Equal(t, "monthly:2026-09", key)
+	assert.Equal(t, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), end)
+
+	key, _, _ = DigestPeriod(DigestMonthly, time.Date(2027, 1, 1, 7, 0, 0, 0, time.UTC))
+	assert.Equal(t, "monthly:2026-12", key)
+}
+
+func TestKPIDigestTrends(t *testing.T) {
+	digest := &KPIDigest{Metrics: []DigestMetric{
+		{Label: "Rent collected", Values: []float64{900, 1000, 1150}},
+		{Label: "Occupancy", Values: []float64{90, 92, 91}},
+		{Label: "Maintenance requests opened", Values: []float64{3, 5, 2}},
+		{Label: "Maintenance requests resolved", Values: []float64{0, 0, 4}},
+	}}
+
+	change, ok := digest.Metrics[0].Change()
+	assert.True(t, ok)
+	assert.Equal(t, 15.0, change)
+	_, ok = digest.Metrics[3].Change()
+	assert.False(t, ok)
+
+	assert.Equal(t, []DigestTrend{
+		{Label: "Maintenance requests opened", Change: -60},
+		{Label: "Rent collected", Change: 15},
+	}, digest.Trends())
+}
+
+func TestClaimKPIDigest(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`INSERT INTO kpi_digest_deliveries`).WithArgs(4, "weekly:2026-W41").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO kpi_digest_deliveries`).WithArgs(4, "weekly:2026-W41").
+		WillReturnError(sql.ErrNoRows)
+
+	claimed, err := ClaimKPIDigest(db.DB, 4, "weekly:2026-W41")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = ClaimKPIDigest(db.DB, 4, "weekly:2026-W41")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
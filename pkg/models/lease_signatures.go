@@ -0,0 +1,206 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Lease signature statuses
+const (
+	SignatureSent     = "sent"
+	SignatureSigned   = "signed"
+	SignatureDeclined = "declined"
+	SignatureVoided   = "voided"
+)
+
+// LeaseStatusSigned is the status of a pending lease once every party has signed it
+const LeaseStatusSigned = "signed"
+
+var (
+	// ErrLeaseNotPending is returned when sending a lease for signature that is not pending
+	ErrLeaseNotPending = errors.New("only pending leases can be sent for signature")
+	// ErrSignatureOutstanding is returned when the lease is already out for signature
+	ErrSignatureOutstanding = errors.New("the lease has already been sent for signature")
+)
+
+// LeaseSignature tracks a lease document sent to an e-signature provider
+type LeaseSignature struct {
+	ID                int            `json:"id"`
+	LeaseID           int            `json:"lease_id"`
+	Provider          string         `json:"provider"`
+	EnvelopeID        string         `json:"envelope_id"`
+	Status            string         `json:"status"`
+	SignedDocumentURL sql.NullString `json:"signed_document_url,omitempty"`
+	SentBy            sql.NullInt32  `json:"sent_by,omitempty"`
+	SentAt            time.Time      `json:"sent_at"`
+	CompletedAt       sql.NullTime   `json:"completed_at,omitempty"`
+}
+
+// LeaseDocument holds the terms printed on a generated lease
+type LeaseDocument struct {
+	LeaseID         int             `json:"lease_id"`
+	Status          string          `json:"status"`
+	TenantName      string          `json:"tenant_name"`
+	TenantEmail     string          `json:"tenant_email"`
+	PropertyName    string          `json:"property_name"`
+	PropertyAddress string          `json:"property_address"`
+	UnitNumber      sql.NullString  `json:"unit_number,omitempty"`
+	StartDate       time.Time       `json:"start_date"`
+	EndDate         time.Time       `json:"end_date"`
+	MonthlyRent     float64         `json:"monthly_rent"`
+	SecurityDeposit sql.NullFloat64 `json:"security_deposit,omitempty"`
+}
+
+// GetLeaseDocument loads the terms of a lease for its generated document
+func GetLeaseDocument(leaseID int) (*LeaseDocument, error) {
+	doc := &LeaseDocument{LeaseID: leaseID}
+	err := db.DB.QueryRow(`
+		SELECT l.status, t.first_name || ' ' || t.last_name, t.email, pr.name, pr.address, pu.unit_number,
+			l.start_date, l.end_date, l.monthly_rent, l.security_deposit
+		FROM leases l
+		JOIN tenants t ON l.tenant_id = t.id
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties pr ON pu.property_id = pr.id
+		WHERE l.id = $1`, leaseID).Scan(&doc.Status, &doc.TenantName, &doc.TenantEmail, &doc.PropertyName,
+		&doc.PropertyAddress, &doc.UnitNumber, &doc.StartDate, &doc.EndDate, &doc.MonthlyRent, &doc.SecurityDeposit)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// CheckLeaseSignable returns ErrLeaseNotPending or ErrSignatureOutstanding if the lease document
+// cannot be sent for signature
+func CheckLeaseSignable(doc *LeaseDocument) error {
+	if doc.Status != "pending" {
+		return ErrLeaseNotPending
+	}
+	var outstanding int
+	err := db.DB.QueryRow("SELECT COUNT(*) FROM lease_signatures WHERE lease_id = $1 AND status = $2",
+		doc.LeaseID, SignatureSent).Scan(&outstanding)
+	if err != nil {
+		return err
+	}
+	if outstanding > 0 {
+		return ErrSignatureOutstanding
+	}
+	return nil
+}
+
+// CreateLeaseSignature records an envelope sent to a provider
+func CreateLeaseSignature(sig *LeaseSignature) error {
+	sig.Status = SignatureSent
+	return db.DB.QueryRow(`
+		INSERT INTO lease_signatures (lease_id, provider, envelope_id, status, sent_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, sent_at`,
+		sig.LeaseID, sig.Provider, sig.EnvelopeID, sig.Status, sig.SentBy).Scan(&sig.ID, &sig.SentAt)
+}
+
+const leaseSignatureColumns = `id, lease_id, provider, envelope_id, status, signed_document_url, sent_by, sent_at, completed_at`
+
+func scanLeaseSignature(row interface{ Scan(...interface{}) error }) (LeaseSignature, error) {
+	var sig LeaseSignature
+	err := row.Scan(&sig.ID, &sig.LeaseID, &sig.Provider, &sig.EnvelopeID, &sig.Status, &sig.SignedDocumentURL,
+		&sig.SentBy, &sig.SentAt, &sig.CompletedAt)
+	return sig, err
+}
+
+// GetLeaseSignatureByEnvelope finds the signature record for a provider's envelope
+func GetLeaseSignatureByEnvelope(provider, envelopeID string) (*LeaseSignature, error) {
+	sig, err := scanLeaseSignature(db.DB.QueryRow(
+		"SELECT "+leaseSignatureColumns+" FROM lease_signatures WHERE provider = $1 AND envelope_id = $2",
+		provider, envelopeID))
+	if err != nil {
+		return nil, err
+	}
+	return &sig, nil
+}
+
+// GetLeaseSignatures retrieves a lease's signature requests, newest first
+func GetLeaseSignatures(leaseID int) ([]LeaseSignature, error) {
+	rows, err := db.DB.Query(
+		"SELECT "+leaseSignatureColumns+" FROM lease_signatures WHERE lease_id = $1 ORDER BY sent_at DESC, id DESC",
+		leaseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var signatures []LeaseSignature
+	for rows.Next() {
+		sig, err := scanLeaseSignature(rows)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, sig)
+	}
+	return signatures, rows.Err()
+}
+
+// CompleteLeaseSignature stores the executed document and marks the lease signed if it is still pending
+func CompleteLeaseSignature(id int, documentURL string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var leaseID int
+	err = tx.QueryRow(`
+		UPDATE lease_signatures SET status = $2, signed_document_url = $3, completed_at = NOW()
+		WHERE id = $1
+		RETURNING lease_id`, id, SignatureSigned, documentURL).Scan(&leaseID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE leases SET status = $2, updated_at = NOW() WHERE id = $1 AND status = 'pending'",
+		leaseID, LeaseStatusSigned)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CloseLeaseSignature records a declined or voided envelope, leaving the lease pending so it can be resent
+func CloseLeaseSignature(id int, status string) error {
+	result, err := db.DB.Exec(`
+		UPDATE lease_signatures SET status = $2, completed_at = NOW()
+		WHERE id = $1 AND status = $3`, id, status, SignatureSent)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// RecordESignDelivery claims a webhook delivery before it is applied, returning false when the
+// delivery was already recorded. Deliveries received before pruneBefore are deleted first.
+func RecordESignDelivery(provider, envelopeID, deliveryID string, pruneBefore time.Time) (bool, error) {
+	if _, err := db.DB.Exec("DELETE FROM esign_webhook_deliveries WHERE received_at < $1", pruneBefore); err != nil {
+		return false, err
+	}
+	result, err := db.DB.Exec(`
+		INSERT INTO esign_webhook_deliveries (provider, envelope_id, delivery_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, provider, envelopeID, deliveryID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ForgetESignDelivery releases a delivery that failed to apply, so the provider's retry is applied
+func ForgetESignDelivery(provider, envelopeID, deliveryID string) error {
+	_, err := db.DB.Exec(
+		"DELETE FROM esign_webhook_deliveries WHERE provider = $1 AND envelope_id = $2 AND delivery_id = $3",
+		provider, envelopeID, deliveryID)
+	return err
+}
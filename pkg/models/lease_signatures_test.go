@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckLeaseSignable(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	assert.Equal(t, ErrLeaseNotPending, CheckLeaseSignable(&LeaseDocument{LeaseID: 3, Status: "active"}))
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM lease_signatures`).WithArgs(3, SignatureSent).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	assert.Equal(t, ErrSignatureOutstanding, CheckLeaseSignable(&LeaseDocument{LeaseID: 3, Status: "pending"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompleteLeaseSignature(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE lease_signatures SET status`).WithArgs(8, SignatureSigned, "/uploads/leases/3/signed_env.pdf").
+		WillReturnRows(sqlmock.NewRows([]string{"lease_id"}).AddRow(3))
+	mock.ExpectExec(`UPDATE leases SET status = \$2(.+)status = 'pending'`).WithArgs(3, LeaseStatusSigned).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, CompleteLeaseSignature(8, "/uploads/leases/3/signed_env.pdf"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordESignDelivery(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	pruneBefore := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`DELETE FROM esign_webhook_deliveries WHERE received_at`).WithArgs(pruneBefore).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO esign_webhook_deliveries(.+)ON CONFLICT DO NOTHING`).WithArgs("docusign", "env-1", "sig").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM esign_webhook_deliveries WHERE received_at`).WithArgs(pruneBefore).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO esign_webhook_deliveries(.+)ON CONFLICT DO NOTHING`).WithArgs("docusign", "env-1", "sig").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	fresh, err := RecordESignDelivery("docusign", "env-1", "sig", pruneBefore)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+
+	// The same delivery replayed is not applied again
+	fresh, err = RecordESignDelivery("docusign", "env-1", "sig", pruneBefore)
+	require.NoError(t, err)
+	assert.False(t, fresh)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
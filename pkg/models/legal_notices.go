@@ -0,0 +1,461 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Legal notice types
+const (
+	NoticeLateRent       = "late_rent"
+	NoticeLeaseViolation = "lease_violation"
+	NoticeEntry          = "entry"
+)
+
+// Legal notice delivery methods
+const (
+	NoticeDeliveryEmail         = "email"
+	NoticeDeliveryHand          = "hand_delivery"
+	NoticeDeliveryPosted        = "posted"
+	NoticeDeliveryCertifiedMail = "certified_mail"
+)
+
+var (
+	ErrInvalidNoticeType      = errors.New("notice_type must be late_rent, lease_violation or entry")
+	ErrInvalidDeliveryMethod  = errors.New("delivery_method must be email, hand_delivery, posted or certified_mail")
+	ErrInvalidNoticeTemplate  = errors.New("notice template needs a title and a body that parses as a template")
+	ErrNoNoticeTemplate       = errors.New("no notice template for this notice type and jurisdiction")
+	ErrNoticeDetailsRequired  = errors.New("description is required, and entry notices also need entry_date")
+	ErrEntryNoticeTooShort    = errors.New("entry_date does not give the notice period required in this jurisdiction")
+	ErrNoRentPastDue          = errors.New("the lease has no rent past due")
+	validNoticeTypes          = map[string]bool{NoticeLateRent: true, NoticeLeaseViolation: true, NoticeEntry: true}
+	validNoticeDeliveryMethod = map[string]bool{
+		NoticeDeliveryEmail: true, NoticeDeliveryHand: true, NoticeDeliveryPosted: true, NoticeDeliveryCertifiedMail: true,
+	}
+)
+
+// noticeFuncs are the functions available to notice templates
+var noticeFuncs = template.FuncMap{
+	"money": func(amount float64) string { return fmt.Sprintf("$%.2f", amount) },
+	"date":  func(t time.Time) string { return t.Format("January 2, 2006") },
+}
+
+// NoticeTemplate is the wording of a notice type in a jurisdiction. An empty jurisdiction is the
+// default used by properties without their own template.
+type NoticeTemplate struct {
+	ID               int       `json:"id"`
+	NoticeType       string    `json:"notice_type"`
+	Jurisdiction     string    `json:"jurisdiction"`
+	Title            string    `json:"title"`
+	Body             string    `json:"body"`
+	NoticePeriodDays int       `json:"notice_period_days"` // Days to pay or cure, or notice needed before entry
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Validate checks the template can be saved and merged
+func (t *NoticeTemplate) Validate() error {
+	if !validNoticeTypes[t.NoticeType] {
+		return ErrInvalidNoticeType
+	}
+	t.Jurisdiction = strings.TrimSpace(t.Jurisdiction)
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" || strings.TrimSpace(t.Body) == "" || t.NoticePeriodDays < 0 {
+		return ErrInvalidNoticeTemplate
+	}
+	if _, err := template.New("notice").Funcs(noticeFuncs).Parse(t.Body); err != nil {
+		return ErrInvalidNoticeTemplate
+	}
+	return nil
+}
+
+// NoticeData is the tenant and lease data merged into a notice template
+type NoticeData struct {
+	TenantName      string
+	PropertyName    string
+	PropertyAddress string
+	UnitNumber      string
+	Premises        string // Unit and address
+	Jurisdiction    string
+	LeaseStart      time.Time
+	LeaseEnd        time.Time
+	MonthlyRent     float64
+	AmountDue       float64 // Rent past due, for late rent notices
+	NoticeDate      time.Time
+	Deadline        time.Time // Date to pay or cure by
+	Description     string    // Violation, or purpose of entry
+	EntryDate       time.Time
+	EntryWindow     string // e.g. "9am and 12pm"
+}
+
+// MergeNoticeTemplate renders a template's body with the notice data
+func MergeNoticeTemplate(t *NoticeTemplate, data NoticeData) (string, error) {
+	tmpl, err := template.New("notice").Funcs(noticeFuncs).Option("missingkey=error").Parse(t.Body)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// noticeTemplateFor picks the jurisdiction's template for a notice type, falling back to the default
+func noticeTemplateFor(templates []NoticeTemplate, noticeType, jurisdiction string) *NoticeTemplate {
+	var fallback *NoticeTemplate
+	for i := range templates {
+		if templates[i].NoticeType != noticeType {
+			continue
+		}
+		if jurisdiction != "" && strings.EqualFold(templates[i].Jurisdiction, jurisdiction) {
+			return &templates[i]
+		}
+		if templates[i].Jurisdiction == "" {
+			fallback = &templates[i]
+		}
+	}
+	return fallback
+}
+
+// GetNoticeTemplates retrieves every notice template
+func GetNoticeTemplates() ([]NoticeTemplate, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, notice_type, jurisdiction, title, body, notice_period_days, created_at, updated_at
+		FROM notice_templates
+		ORDER BY notice_type, jurisdiction`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []NoticeTemplate
+	for rows.Next() {
+		var t NoticeTemplate
+		if err := rows.Scan(&t.ID, &t.NoticeType, &t.Jurisdiction, &t.Title, &t.Body, &t.NoticePeriodDays,
+			&t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// SaveNoticeTemplate creates or updates the template for t.NoticeType in t.Jurisdiction
+func SaveNoticeTemplate(t *NoticeTemplate) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	err := db.DB.QueryRow(`
+		UPDATE notice_templates SET title = $3, body = $4, notice_period_days = $5, updated_at = NOW()
+		WHERE notice_type = $1 AND jurisdiction = $2
+		RETURNING id, created_at, updated_at`,
+		t.NoticeType, t.Jurisdiction, t.Title, t.Body, t.NoticePeriodDays).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	return db.DB.QueryRow(`
+		INSERT INTO notice_templates (notice_type, jurisdiction, title, body, notice_period_days)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		t.NoticeType, t.Jurisdiction, t.Title, t.Body, t.NoticePeriodDays).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+}
+
+// DeleteNoticeTemplate removes a template; properties in its jurisdiction fall back to the default
+func DeleteNoticeTemplate(id int) error {
+	result, err := db.DB.Exec("DELETE FROM notice_templates WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SetPropertyJurisdiction sets the jurisdiction whose notice templates apply to a property; an
+// empty jurisdiction uses the defaults
+func SetPropertyJurisdiction(propertyID int, jurisdiction string) error {
+	result, err := db.DB.Exec("UPDATE properties SET jurisdiction = $2, updated_at = NOW() WHERE id = $1",
+		propertyID, NullString(strings.TrimSpace(jurisdiction)))
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// NoticeRequest asks for a notice to be generated for a lease
+type NoticeRequest struct {
+	LeaseID        int
+	NoticeType     string
+	DeliveryMethod string
+	Description    string
+	EntryDate      time.Time
+	EntryWindow    string
+	AmountDue      sql.NullFloat64 // Overrides the rent past due on late rent notices
+	CreatedBy      sql.NullInt32
+}
+
+// LegalNotice is a notice generated for a tenant, with the merged text kept as issued
+type LegalNotice struct {
+	ID             int           `json:"id"`
+	TenantID       int           `json:"tenant_id"`
+	LeaseID        int           `json:"lease_id"`
+	TemplateID     sql.NullInt32 `json:"template_id,omitempty"`
+	NoticeType     string        `json:"notice_type"`
+	Jurisdiction   string        `json:"jurisdiction"`
+	Title          string        `json:"title"`
+	Body           string        `json:"body"`
+	Deadline       sql.NullTime  `json:"deadline,omitempty"`
+	DocumentURL    string        `json:"document_url"`
+	DeliveryMethod string        `json:"delivery_method"`
+	CreatedBy      sql.NullInt32 `json:"created_by,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+
+	TenantEmail string     `json:"-"`
+	Data        NoticeData `json:"-"`
+}
+
+// PrepareLegalNotice merges the template for the lease's jurisdiction with the tenant and lease
+// data. The notice is not saved until RecordLegalNotice. It returns sql.ErrNoRows if the lease does
+// not exist and ErrNoRentPastDue for a late rent notice on a lease that is paid up.
+func PrepareLegalNotice(req *NoticeRequest, now time.Time) (*LegalNotice, error) {
+	if !validNoticeTypes[req.NoticeType] {
+		return nil, ErrInvalidNoticeType
+	}
+	if !validNoticeDeliveryMethod[req.DeliveryMethod] {
+		return nil, ErrInvalidDeliveryMethod
+	}
+	req.Description = strings.TrimSpace(req.Description)
+	if (req.NoticeType != NoticeLateRent && req.Description == "") ||
+		(req.NoticeType == NoticeEntry && req.EntryDate.IsZero()) {
+		return nil, ErrNoticeDetailsRequired
+	}
+
+	n := &LegalNotice{LeaseID: req.LeaseID, NoticeType: req.NoticeType, DeliveryMethod: req.DeliveryMethod,
+		CreatedBy: req.CreatedBy}
+	d := &n.Data
+	var unit, jurisdiction sql.NullString
+	err := db.DB.QueryRow(`
+		SELECT l.tenant_id, t.first_name || ' ' || t.last_name, t.email, p.name, p.address, pu.unit_number,
+			   p.jurisdiction, l.start_date, l.end_date, l.monthly_rent
+		FROM leases l
+		JOIN tenants t ON l.tenant_id = t.id
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		WHERE l.id = $1`, req.LeaseID).Scan(&n.TenantID, &d.TenantName, &n.TenantEmail, &d.PropertyName,
+		&d.PropertyAddress, &unit, &jurisdiction, &d.LeaseStart, &d.LeaseEnd, &d.MonthlyRent)
+	if err != nil {
+		return nil, err
+	}
+	d.UnitNumber, d.Jurisdiction = unit.String, jurisdiction.String
+	d.Premises = d.PropertyAddress
+	if d.UnitNumber != "" {
+		d.Premises = d.UnitNumber + ", " + d.PropertyAddress
+	}
+
+	templates, err := GetNoticeTemplates()
+	if err != nil {
+		return nil, err
+	}
+	tmpl := noticeTemplateFor(templates, req.NoticeType, d.Jurisdiction)
+	if tmpl == nil {
+		return nil, ErrNoNoticeTemplate
+	}
+	n.TemplateID = sql.NullInt32{Int32: int32(tmpl.ID), Valid: true}
+	n.Jurisdiction, n.Title = tmpl.Jurisdiction, tmpl.Title
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	d.NoticeDate = today
+	d.Deadline = today.AddDate(0, 0, tmpl.NoticePeriodDays)
+	d.Description, d.EntryWindow = req.Description, strings.TrimSpace(req.EntryWindow)
+	switch req.NoticeType {
+	case NoticeLateRent:
+		d.AmountDue = req.AmountDue.Float64
+		if !req.AmountDue.Valid {
+			if d.AmountDue, err = leaseRentPastDue(req.LeaseID, now); err != nil {
+				return nil, err
+			}
+		}
+		if d.AmountDue <= 0 {
+			return nil, ErrNoRentPastDue
+		}
+	case NoticeEntry:
+		if req.EntryDate.Before(d.Deadline) {
+			return nil, ErrEntryNoticeTooShort
+		}
+		d.EntryDate, d.Deadline = req.EntryDate, req.EntryDate
+	}
+	n.Deadline = sql.NullTime{Time: d.Deadline, Valid: true}
+
+	if n.Body, err = MergeNoticeTemplate(tmpl, *d); err != nil {
+		return nil, fmt.Errorf("failed to merge notice template %d: %w", tmpl.ID, err)
+	}
+	return n, nil
+}
+
+// leaseRentPastDue returns the rent past due on a lease after applying its completed payments
+func leaseRentPastDue(leaseID int, asOf time.Time) (float64, error) {
+	lease, err := GetScheduledLease(leaseID)
+	if err != nil {
+		return 0, err
+	}
+	var paid float64
+	err = db.DB.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM payments WHERE lease_id = $1 AND status = 'completed'`,
+		leaseID).Scan(&paid)
+	if err != nil {
+		return 0, err
+	}
+
+	var d LeaseDelinquency
+	AgeLeaseBalance(&d, lease.Charges(), paid, asOf)
+	return d.PastDue, nil
+}
+
+// RecordLegalNotice saves a prepared notice once its document is stored, logs it on the tenant
+// timeline, and emails it to the tenant when that is the delivery method
+func RecordLegalNotice(n *LegalNotice, now time.Time) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	n.CreatedAt = now
+	err = tx.QueryRow(`
+		INSERT INTO legal_notices (tenant_id, lease_id, template_id, notice_type, jurisdiction, title, body,
+			deadline, document_url, delivery_method, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id`,
+		n.TenantID, n.LeaseID, n.TemplateID, n.NoticeType, n.Jurisdiction, n.Title, n.Body, n.Deadline,
+		n.DocumentURL, n.DeliveryMethod, n.CreatedBy, n.CreatedAt).Scan(&n.ID)
+	if err != nil {
+		return err
+	}
+
+	event := &TenantTimelineEvent{
+		TenantID:      n.TenantID,
+		LeaseID:       sql.NullInt32{Int32: int32(n.LeaseID), Valid: true},
+		EventType:     "legal_notice",
+		Summary:       fmt.Sprintf("%s issued by %s", n.Title, strings.ReplaceAll(n.DeliveryMethod, "_", " ")),
+		ReferenceType: NullString("legal_notice"),
+		ReferenceID:   sql.NullInt32{Int32: int32(n.ID), Valid: true},
+		CreatedBy:     n.CreatedBy,
+		OccurredAt:    now,
+	}
+	if err := RecordTimelineEvent(tx, event); err != nil {
+		return err
+	}
+
+	if n.DeliveryMethod == NoticeDeliveryEmail {
+		err := EnqueueOutboxMessage(tx, &OutboxMessage{
+			Channel:     "email",
+			EventType:   "tenant.legal_notice",
+			Destination: n.TenantEmail,
+			Payload: map[string]interface{}{
+				"legal_notice_id": n.ID,
+				"subject":         n.Title,
+				"body":            n.Body,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const legalNoticeColumns = `
+	SELECT id, tenant_id, lease_id, template_id, notice_type, jurisdiction, title, body, deadline,
+		   document_url, delivery_method, created_by, created_at
+	FROM legal_notices`
+
+func scanLegalNotice(row interface{ Scan(...interface{}) error }) (*LegalNotice, error) {
+	var n LegalNotice
+	err := row.Scan(&n.ID, &n.TenantID, &n.LeaseID, &n.TemplateID, &n.NoticeType, &n.Jurisdiction, &n.Title,
+		&n.Body, &n.Deadline, &n.DocumentURL, &n.DeliveryMethod, &n.CreatedBy, &n.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// GetLegalNotice retrieves one notice
+func GetLegalNotice(id int) (*LegalNotice, error) {
+	return scanLegalNotice(db.DB.QueryRow(legalNoticeColumns+" WHERE id = $1", id))
+}
+
+// GetTenantLegalNotices lists a tenant's notices, newest first
+func GetTenantLegalNotices(tenantID int) ([]LegalNotice, error) {
+	rows, err := db.DB.Query(legalNoticeColumns+" WHERE tenant_id = $1 ORDER BY created_at DESC, id DESC", tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notices []LegalNotice
+	for rows.Next() {
+		n, err := scanLegalNotice(rows)
+		if err != nil {
+			return nil, err
+		}
+		notices = append(notices, *n)
+	}
+	return notices, rows.Err()
+}
+
+// TenantTimelineEvent is a dated event in a tenant's history
+type TenantTimelineEvent struct {
+	ID            int            `json:"id"`
+	TenantID      int            `json:"tenant_id"`
+	LeaseID       sql.NullInt32  `json:"lease_id,omitempty"`
+	EventType     string         `json:"event_type"`
+	Summary       string         `json:"summary"`
+	ReferenceType sql.NullString `json:"reference_type,omitempty"`
+	ReferenceID   sql.NullInt32  `json:"reference_id,omitempty"`
+	CreatedBy     sql.NullInt32  `json:"created_by,omitempty"`
+	OccurredAt    time.Time      `json:"occurred_at"`
+}
+
+// RecordTimelineEvent adds an event to a tenant's timeline
+func RecordTimelineEvent(q Querier, e *TenantTimelineEvent) error {
+	return q.QueryRow(`
+		INSERT INTO tenant_timeline_events (tenant_id, lease_id, event_type, summary, reference_type, reference_id,
+			created_by, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		e.TenantID, e.LeaseID, e.EventType, e.Summary, e.ReferenceType, e.ReferenceID, e.CreatedBy, e.OccurredAt,
+	).Scan(&e.ID)
+}
+
+// GetTenantTimeline returns up to limit of a tenant's most recent timeline events, newest first
+func GetTenantTimeline(tenantID, limit int) ([]TenantTimelineEvent, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT id, tenant_id, lease_id, event_type, summary, reference_type, reference_id, created_by, occurred_at
+		FROM tenant_timeline_events
+		WHERE tenant_id = $1
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $2`, tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []TenantTimelineEvent
+	for rows.Next() {
+		var e TenantTimelineEvent
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.LeaseID, &e.EventType, &e.Summary, &e.ReferenceType,
+			&e.ReferenceID, &e.CreatedBy, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoticeTemplateForPrefersJurisdiction(t *testing.T) {
+	templates := []NoticeTemplate{
+		{ID: 1, NoticeType: NoticeLateRent, Jurisdiction: ""},
+		{ID: 2, NoticeType: NoticeEntry, Jurisdiction: ""},
+		{ID: 3, NoticeType: NoticeLateRent, Jurisdiction: "California"},
+	}
+
+	assert.Equal(t, 3, noticeTemplateFor(templates, NoticeLateRent, "california").ID)
+	assert.Equal(t, 1, noticeTemplateFor(templates, NoticeLateRent, "Oregon").ID, "falls back to the default")
+	assert.Equal(t, 2, noticeTemplateFor(templates, NoticeEntry, "California").ID)
+	assert.Nil(t, noticeTemplateFor(templates, NoticeLeaseViolation, ""))
+}
+
+func TestMergeNoticeTemplate(t *testing.T) {
+	tmpl := &NoticeTemplate{
+		NoticeType: NoticeLateRent,
+		Title:      "Notice of Late Rent",
+		Body:       "To {{.TenantName}}, tenant of {{.Premises}}: pay {{money .AmountDue}} by {{date .Deadline}}.",
+	}
+	require.NoError(t, tmpl.Validate())
+
+	body, err := MergeNoticeTemplate(tmpl, NoticeData{
+		TenantName: "Dana Reyes",
+		Premises:   "4B, 12 Elm Court",
+		AmountDue:  1250.5,
+		Deadline:   date(2026, 10, 21),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "To Dana Reyes, tenant of 4B, 12 Elm Court: pay $1250.50 by October 21, 2026.", body)
+}
+
+func TestNoticeTemplateValidate(t *testing.T) {
+	assert.Equal(t, ErrInvalidNoticeType, (&NoticeTemplate{NoticeType: "eviction", Title: "x", Body: "x"}).Validate())
+	assert.Equal(t, ErrInvalidNoticeTemplate, (&NoticeTemplate{NoticeType: NoticeEntry, Title: " ", Body: "x"}).Validate())
+	assert.Equal(t, ErrInvalidNoticeTemplate, (&NoticeTemplate{NoticeType: NoticeEntry, Title: "x", Body: "{{.TenantName"}).Validate())
+
+	tmpl := &NoticeTemplate{NoticeType: NoticeEntry, Jurisdiction: " Oregon ", Title: "Entry", Body: "{{date .EntryDate}}", NoticePeriodDays: 1}
+	assert.NoError(t, tmpl.Validate())
+	assert.Equal(t, "Oregon", tmpl.Jurisdiction)
+}
@@ -0,0 +1,215 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ErrInvalidListing is returned when a listing has no asking rent
+var ErrInvalidListing = errors.New("a listing needs an asking rent greater than zero")
+
+// UnitListing advertises a unit for rent. Active listings are included in syndication feeds.
+type UnitListing struct {
+	UnitID        int            `json:"unit_id"`
+	Active        bool           `json:"active"`
+	AskingRent    float64        `json:"asking_rent"`
+	AvailableDate sql.NullTime   `json:"available_date,omitempty"`
+	Headline      sql.NullString `json:"headline,omitempty"`
+	Description   sql.NullString `json:"description,omitempty"`
+	SquareFeet    sql.NullInt32  `json:"square_feet,omitempty"`
+	ContactName   sql.NullString `json:"contact_name,omitempty"`
+	ContactEmail  sql.NullString `json:"contact_email,omitempty"`
+	ContactPhone  sql.NullString `json:"contact_phone,omitempty"`
+	ListedAt      time.Time      `json:"listed_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// SyndicatedListing is an active listing with the unit, property and photo details listing
+// sites need
+type SyndicatedListing struct {
+	UnitListing
+	PropertyID      int    `json:"property_id"`
+	PropertyName    string `json:"property_name"`
+	PropertyAddress string `json:"property_address"`
+	PropertyType    string `json:"property_type"`
+	UnitNumber      string `json:"unit_number"`
+	Bedrooms        int    `json:"bedrooms"`
+	Bathrooms       int    `json:"bathrooms"`
+	UnitDescription string `json:"unit_description"`
+	PhotoIDs        []int  `json:"photo_ids"` // Unit photos first, then the property's
+	Occupied        bool   `json:"occupied"`  // Pre-leasing: the current lease has not ended yet
+}
+
+// SyndicationChannel is a listing site that receives a vacancy feed in its format
+type SyndicationChannel struct {
+	Name            string         `json:"name"`
+	Format          string         `json:"format"`
+	Enabled         bool           `json:"enabled"`
+	FeedURL         sql.NullString `json:"feed_url,omitempty"`
+	ListingCount    int            `json:"listing_count"`
+	LastGeneratedAt sql.NullTime   `json:"last_generated_at,omitempty"`
+	LastError       sql.NullString `json:"last_error,omitempty"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// SaveUnitListing lists a unit or updates its listing. It returns sql.ErrNoRows if the unit
+// does not exist.
+func SaveUnitListing(l *UnitListing) error {
+	if l.AskingRent <= 0 {
+		return ErrInvalidListing
+	}
+	var exists int
+	if err := db.DB.QueryRow("SELECT 1 FROM property_units WHERE id = $1", l.UnitID).Scan(&exists); err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO unit_listings (unit_id, active, asking_rent, available_date, headline, description, square_feet,
+			contact_name, contact_email, contact_phone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (unit_id) DO UPDATE
+		SET active = EXCLUDED.active, asking_rent = EXCLUDED.asking_rent, available_date = EXCLUDED.available_date,
+			headline = EXCLUDED.headline, description = EXCLUDED.description, square_feet = EXCLUDED.square_feet,
+			contact_name = EXCLUDED.contact_name, contact_email = EXCLUDED.contact_email,
+			contact_phone = EXCLUDED.contact_phone, updated_at = NOW()
+		RETURNING listed_at, updated_at`,
+		l.UnitID, l.Active, l.AskingRent, l.AvailableDate, l.Headline, l.Description, l.SquareFeet,
+		l.ContactName, l.ContactEmail, l.ContactPhone).
+		Scan(&l.ListedAt, &l.UpdatedAt)
+}
+
+// DeleteUnitListing removes a unit's listing
+func DeleteUnitListing(unitID int) error {
+	result, err := db.DB.Exec("DELETE FROM unit_listings WHERE unit_id = $1", unitID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetSyndicatedListings returns the active listings with their unit, property and photo
+// details, ordered by property and unit
+func GetSyndicatedListings() ([]SyndicatedListing, error) {
+	rows, err := db.DB.Query(`
+		SELECT l.unit_id, l.active, l.asking_rent, l.available_date, l.headline, l.description, l.square_feet,
+			   l.contact_name, l.contact_email, l.contact_phone, l.listed_at, l.updated_at,
+			   p.id, p.name, p.address, p.property_type, COALESCE(pu.unit_number, ''), pu.bedrooms, pu.bathrooms,
+			   COALESCE(pu.description, ''),
+			   EXISTS (SELECT 1 FROM leases le WHERE le.unit_id = pu.id AND le.status = 'active')
+		FROM unit_listings l
+		JOIN property_units pu ON pu.id = l.unit_id
+		JOIN properties p ON p.id = pu.property_id
+		WHERE l.active = TRUE
+		ORDER BY p.id, pu.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var listings []SyndicatedListing
+	index := map[int]int{}
+	for rows.Next() {
+		var s SyndicatedListing
+		err := rows.Scan(&s.UnitID, &s.Active, &s.AskingRent, &s.AvailableDate, &s.Headline, &s.Description, &s.SquareFeet,
+			&s.ContactName, &s.ContactEmail, &s.ContactPhone, &s.ListedAt, &s.UpdatedAt,
+			&s.PropertyID, &s.PropertyName, &s.PropertyAddress, &s.PropertyType, &s.UnitNumber, &s.Bedrooms, &s.Bathrooms,
+			&s.UnitDescription, &s.Occupied)
+		if err != nil {
+			return nil, err
+		}
+		s.PhotoIDs = []int{}
+		index[s.UnitID] = len(listings)
+		listings = append(listings, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(listings) == 0 {
+		return listings, nil
+	}
+
+	// Photos of each listed unit, then of its property, in gallery order
+	photos, err := db.DB.Query(`
+		SELECT l.unit_id, ph.id
+		FROM unit_listings l
+		JOIN property_units pu ON pu.id = l.unit_id
+		JOIN property_photos ph ON ph.property_id = pu.property_id AND (ph.unit_id = pu.id OR ph.unit_id IS NULL)
+		WHERE l.active = TRUE
+		ORDER BY l.unit_id, CASE WHEN ph.unit_id IS NULL THEN 1 ELSE 0 END, ph.position, ph.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer photos.Close()
+	for photos.Next() {
+		var unitID, photoID int
+		if err := photos.Scan(&unitID, &photoID); err != nil {
+			return nil, err
+		}
+		if i, ok := index[unitID]; ok {
+			listings[i].PhotoIDs = append(listings[i].PhotoIDs, photoID)
+		}
+	}
+	return listings, photos.Err()
+}
+
+const syndicationChannelColumns = `name, format, enabled, feed_url, listing_count, last_generated_at, last_error, updated_at`
+
+func scanSyndicationChannel(row interface{ Scan(...interface{}) error }) (SyndicationChannel, error) {
+	var c SyndicationChannel
+	err := row.Scan(&c.Name, &c.Format, &c.Enabled, &c.FeedURL, &c.ListingCount, &c.LastGeneratedAt, &c.LastError, &c.UpdatedAt)
+	return c, err
+}
+
+// GetSyndicationChannels returns every listing site channel
+func GetSyndicationChannels() ([]SyndicationChannel, error) {
+	rows, err := db.DB.Query("SELECT " + syndicationChannelColumns + " FROM syndication_channels ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []SyndicationChannel
+	for rows.Next() {
+		c, err := scanSyndicationChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// GetSyndicationChannel retrieves a channel by name
+func GetSyndicationChannel(name string) (*SyndicationChannel, error) {
+	c, err := scanSyndicationChannel(db.DB.QueryRow("SELECT "+syndicationChannelColumns+" FROM syndication_channels WHERE name = $1", name))
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SetSyndicationChannelEnabled turns a channel's feed on or off
+func SetSyndicationChannelEnabled(name string, enabled bool) error {
+	result, err := db.DB.Exec("UPDATE syndication_channels SET enabled = $2, updated_at = NOW() WHERE name = $1", name, enabled)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// RecordSyndicationFeed records a feed generation. On failure the previous feed is kept and the
+// error recorded.
+func RecordSyndicationFeed(name, feedURL string, listingCount int, genErr error) error {
+	if genErr != nil {
+		_, err := db.DB.Exec("UPDATE syndication_channels SET last_error = $2, updated_at = NOW() WHERE name = $1",
+			name, genErr.Error())
+		return err
+	}
+	_, err := db.DB.Exec(`
+		UPDATE syndication_channels
+		SET feed_url = $2, listing_count = $3, last_generated_at = NOW(), last_error = NULL, updated_at = NOW()
+		WHERE name = $1`, name, feedURL, listingCount)
+	return err
+}
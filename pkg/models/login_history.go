@@ -0,0 +1,175 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// LoginAttempt is one sign-in attempt recorded in the login history
+type LoginAttempt struct {
+	ID            int            `json:"id"`
+	UserID        sql.NullInt32  `json:"user_id,omitempty"`
+	Identity      sql.NullString `json:"identity,omitempty"`
+	Success       bool           `json:"success"`
+	FailureReason sql.NullString `json:"failure_reason,omitempty"`
+	IPAddress     string         `json:"ip_address"`
+	Network       string         `json:"network"`
+	UserAgent     string         `json:"user_agent"`
+	MFAUsed       bool           `json:"mfa_used"`
+	NewDevice     bool           `json:"new_device"`
+	NewLocation   bool           `json:"new_location"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// IPNetwork returns the /24 (IPv4) or /48 (IPv6) prefix of an address, optionally with a
+// port, as a coarse location. It returns "" if the address cannot be parsed.
+func IPNetwork(address string) string {
+	address = strings.TrimSpace(address)
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// MFAUsed reports whether the OIDC authentication methods (amr claim) include a second factor
+func MFAUsed(amr []string) bool {
+	for _, method := range amr {
+		switch strings.ToLower(method) {
+		case "mfa", "otp", "totp", "hwk", "swk", "sms", "webauthn":
+			return true
+		}
+	}
+	return false
+}
+
+// RecordLoginAttempt stores a sign-in attempt. For a successful login by a known user the
+// attempt is compared with earlier successful logins: an unseen user agent or network is
+// flagged, and the user is emailed about it unless this is their first login. The user's
+// last_login is updated in the same transaction.
+func RecordLoginAttempt(attempt *LoginAttempt, now time.Time) error {
+	attempt.Network = IPNetwork(attempt.IPAddress)
+	attempt.CreatedAt = now
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	known := attempt.Success && attempt.UserID.Valid
+	var previous, sameDevice, sameNetwork int
+	if known {
+		if err := tx.QueryRow(`
+			SELECT COUNT(*),
+			       COALESCE(SUM(CASE WHEN user_agent = $2 THEN 1 ELSE 0 END), 0),
+			       COALESCE(SUM(CASE WHEN network = $3 THEN 1 ELSE 0 END), 0)
+			FROM login_history WHERE user_id = $1 AND success = $4`,
+			attempt.UserID, attempt.UserAgent, attempt.Network, true).Scan(&previous, &sameDevice, &sameNetwork); err != nil {
+			return err
+		}
+		attempt.NewDevice = previous > 0 && sameDevice == 0
+		attempt.NewLocation = previous > 0 && sameNetwork == 0
+	}
+
+	if err := tx.QueryRow(`
+		INSERT INTO login_history (user_id, identity, success, failure_reason, ip_address, network,
+		                           user_agent, mfa_used, new_device, new_location, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`,
+		attempt.UserID, attempt.Identity, attempt.Success, attempt.FailureReason, attempt.IPAddress,
+		attempt.Network, attempt.UserAgent, attempt.MFAUsed, attempt.NewDevice, attempt.NewLocation,
+		now).Scan(&attempt.ID); err != nil {
+		return err
+	}
+
+	if known {
+		if _, err := tx.Exec("UPDATE users SET last_login = $1 WHERE id = $2", now, attempt.UserID); err != nil {
+			return err
+		}
+	}
+
+	if attempt.NewDevice || attempt.NewLocation {
+		var email, username string
+		if err := tx.QueryRow("SELECT email, username FROM users WHERE id = $1", attempt.UserID).
+			Scan(&email, &username); err != nil {
+			return err
+		}
+		subject, body := FormatLoginAlertEmail(username, attempt)
+		if err := EnqueueOutboxMessage(tx, &OutboxMessage{
+			Channel:     "email",
+			EventType:   "user.login_alert",
+			Destination: email,
+			Payload: map[string]interface{}{
+				"user_id":  attempt.UserID.Int32,
+				"login_id": attempt.ID,
+				"subject":  subject,
+				"body":     body,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FormatLoginAlertEmail builds the subject and body of a new device or location alert
+func FormatLoginAlertEmail(username string, attempt *LoginAttempt) (string, string) {
+	var reasons []string
+	if attempt.NewDevice {
+		reasons = append(reasons, "a new device")
+	}
+	if attempt.NewLocation {
+		reasons = append(reasons, "a new location")
+	}
+
+	subject := "New sign-in to your Fire PMAAS account"
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"Your account was signed in to from %s.\n\n"+
+		"Time: %s\nIP address: %s\nDevice: %s\n\n"+
+		"If this was you, no action is needed. If not, contact your administrator immediately.\n",
+		username, strings.Join(reasons, " and "), attempt.CreatedAt.UTC().Format(time.RFC1123),
+		attempt.IPAddress, attempt.UserAgent)
+	return subject, body
+}
+
+// GetLoginHistory returns a user's most recent login attempts, newest first
+func GetLoginHistory(userID, limit int) ([]LoginAttempt, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, user_id, identity, success, failure_reason, ip_address, network, user_agent,
+		       mfa_used, new_device, new_location, created_at
+		FROM login_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []LoginAttempt
+	for rows.Next() {
+		var attempt LoginAttempt
+		var ip, network, userAgent sql.NullString
+		if err := rows.Scan(&attempt.ID, &attempt.UserID, &attempt.Identity, &attempt.Success,
+			&attempt.FailureReason, &ip, &network, &userAgent, &attempt.MFAUsed,
+			&attempt.NewDevice, &attempt.NewLocation, &attempt.CreatedAt); err != nil {
+			return nil, err
+		}
+		attempt.IPAddress, attempt.Network, attempt.UserAgent = ip.String, network.String, userAgent.String
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
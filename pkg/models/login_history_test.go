@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPNetwork(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", IPNetwork("203.0.113.42"))
+	assert.Equal(t, "203.0.113.0/24", IPNetwork("203.0.113.42:51234"))
+	assert.Equal(t, "2001:db8:1::/48", IPNetwork("[2001:db8:1:2::1]:443"))
+	assert.Equal(t, "", IPNetwork("not-an-ip"))
+}
+
+func TestMFAUsed(t *testing.T) {
+	assert.True(t, MFAUsed([]string{"pwd", "otp"}))
+	assert.False(t, MFAUsed([]string{"pwd"}))
+	assert.False(t, MFAUsed(nil))
+}
+
+func TestRecordLoginAttemptAlertsOnNewDevice(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	userID := sql.NullInt32{Int32: 4, Valid: true}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\)`).WithArgs(userID, "NewBrowser/1.0", "198.51.100.0/24", true).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "same_device", "same_network"}).AddRow(3, 0, 3))
+	mock.ExpectQuery(`INSERT INTO login_history`).
+		WithArgs(userID, sqlmock.AnyArg(), true, sqlmock.AnyArg(), "198.51.100.7", "198.51.100.0/24",
+			"NewBrowser/1.0", false, true, false, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`UPDATE users SET last_login`).WithArgs(now, userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT email, username FROM users`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"email", "username"}).AddRow("jane@example.com", "jane"))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", "user.login_alert", "jane@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	attempt := &LoginAttempt{UserID: userID, Success: true, IPAddress: "198.51.100.7", UserAgent: "NewBrowser/1.0"}
+	require.NoError(t, RecordLoginAttempt(attempt, now))
+	assert.True(t, attempt.NewDevice)
+	assert.False(t, attempt.NewLocation)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordLoginAttemptFailureWithoutUser(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO login_history`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectCommit()
+
+	attempt := &LoginAttempt{Success: false, FailureReason: NullString("token exchange failed"), IPAddress: "10.0.0.1"}
+	require.NoError(t, RecordLoginAttempt(attempt, now))
+	assert.False(t, attempt.NewDevice)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFormatLoginAlertEmail(t *testing.T) {
+	attempt := &LoginAttempt{IPAddress: "198.51.100.7", UserAgent: "NewBrowser/1.0", NewDevice: true, NewLocation: true,
+		CreatedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	_, body := FormatLoginAlertEmail("jane", attempt)
+	assert.Contains(t, body, "a new device and a new location")
+	assert.Contains(t, body, "198.51.100.7")
+}
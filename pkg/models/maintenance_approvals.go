@@ -0,0 +1,401 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// CostApprovalTTL is how long an emailed approval link stays valid
+const CostApprovalTTL = 14 * 24 * time.Hour
+
+// Cost approval statuses
+const (
+	ApprovalNotRequired  = "not_required"
+	ApprovalAutoApproved = "auto_approved"
+	ApprovalPending      = "pending"
+	ApprovalApproved     = "approved"
+	ApprovalDeclined     = "declined"
+	ApprovalSuperseded   = "superseded"
+)
+
+var (
+	// ErrInvalidApprovalPolicy is returned for a policy without a valid approver email or with a
+	// negative limit
+	ErrInvalidApprovalPolicy = errors.New("an approval policy needs a valid approver email and a limit of zero or more")
+	// ErrInvalidEstimate is returned for a negative cost estimate
+	ErrInvalidEstimate = errors.New("estimated_cost must be zero or greater")
+	// ErrApprovalInvalid is returned for unknown or expired approval links, and links whose
+	// estimate was already decided or superseded
+	ErrApprovalInvalid = errors.New("this approval link is invalid, has expired or has already been used")
+)
+
+// MaintenanceApprovalPolicy sets the cost above which a property's work orders need the
+// approver's sign-off
+type MaintenanceApprovalPolicy struct {
+	PropertyID       int            `json:"property_id"`
+	PropertyName     string         `json:"property_name"`
+	AutoApproveLimit float64        `json:"auto_approve_limit"`
+	ApproverName     sql.NullString `json:"approver_name,omitempty"`
+	ApproverEmail    string         `json:"approver_email"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// MaintenanceCostApproval is a cost estimate submitted for a work order and its approval
+type MaintenanceCostApproval struct {
+	ID               int             `json:"id"`
+	RequestID        int             `json:"request_id"`
+	EstimatedCost    float64         `json:"estimated_cost"`
+	EstimateNotes    sql.NullString  `json:"estimate_notes,omitempty"`
+	AutoApproveLimit sql.NullFloat64 `json:"auto_approve_limit,omitempty"`
+	Status           string          `json:"status"`
+	ApproverEmail    sql.NullString  `json:"approver_email,omitempty"`
+	ExpiresAt        sql.NullTime    `json:"expires_at,omitempty"`
+	DecidedAt        sql.NullTime    `json:"decided_at,omitempty"`
+	DecisionNotes    sql.NullString  `json:"decision_notes,omitempty"`
+	RequestedBy      sql.NullInt32   `json:"requested_by,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+// CostApprovalReview is what an approver sees when following an approval link
+type CostApprovalReview struct {
+	MaintenanceCostApproval
+	PropertyName       string `json:"property_name"`
+	RequestDescription string `json:"request_description"`
+	Priority           string `json:"priority"`
+	Expired            bool   `json:"expired"`
+}
+
+// ApprovalStatusFor decides how an estimate is handled under a property's policy, which is nil
+// when the property has none
+func ApprovalStatusFor(policy *MaintenanceApprovalPolicy, estimate float64) string {
+	switch {
+	case policy == nil:
+		return ApprovalNotRequired
+	case estimate <= policy.AutoApproveLimit:
+		return ApprovalAutoApproved
+	default:
+		return ApprovalPending
+	}
+}
+
+const approvalPolicyColumns = `ap.property_id, p.name, ap.auto_approve_limit, ap.approver_name, ap.approver_email, ap.updated_at`
+
+func scanApprovalPolicy(row interface{ Scan(...interface{}) error }) (MaintenanceApprovalPolicy, error) {
+	var p MaintenanceApprovalPolicy
+	err := row.Scan(&p.PropertyID, &p.PropertyName, &p.AutoApproveLimit, &p.ApproverName, &p.ApproverEmail, &p.UpdatedAt)
+	return p, err
+}
+
+// GetMaintenanceApprovalPolicies returns every property's approval policy
+func GetMaintenanceApprovalPolicies() ([]MaintenanceApprovalPolicy, error) {
+	rows, err := db.DB.Query(`
+		SELECT ` + approvalPolicyColumns + `
+		FROM maintenance_approval_policies ap JOIN properties p ON p.id = ap.property_id
+		ORDER BY p.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []MaintenanceApprovalPolicy
+	for rows.Next() {
+		p, err := scanApprovalPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// getApprovalPolicy returns a property's policy, or nil if it has none
+func getApprovalPolicy(q Querier, propertyID int) (*MaintenanceApprovalPolicy, error) {
+	p, err := scanApprovalPolicy(q.QueryRow(`
+		SELECT `+approvalPolicyColumns+`
+		FROM maintenance_approval_policies ap JOIN properties p ON p.id = ap.property_id
+		WHERE ap.property_id = $1`, propertyID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveMaintenanceApprovalPolicy creates or replaces a property's approval policy. It returns
+// sql.ErrNoRows if the property does not exist.
+func SaveMaintenanceApprovalPolicy(p *MaintenanceApprovalPolicy) error {
+	p.ApproverEmail = strings.TrimSpace(p.ApproverEmail)
+	if address, err := mail.ParseAddress(p.ApproverEmail); err != nil || address.Address != p.ApproverEmail || p.AutoApproveLimit < 0 {
+		return ErrInvalidApprovalPolicy
+	}
+
+	if err := db.DB.QueryRow("SELECT name FROM properties WHERE id = $1", p.PropertyID).Scan(&p.PropertyName); err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO maintenance_approval_policies (property_id, auto_approve_limit, approver_name, approver_email)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (property_id) DO UPDATE
+		SET auto_approve_limit = EXCLUDED.auto_approve_limit, approver_name = EXCLUDED.approver_name,
+			approver_email = EXCLUDED.approver_email, updated_at = NOW()
+		RETURNING updated_at`,
+		p.PropertyID, p.AutoApproveLimit, p.ApproverName, p.ApproverEmail).Scan(&p.UpdatedAt)
+}
+
+// DeleteMaintenanceApprovalPolicy removes a property's policy, so its estimates no longer need approval
+func DeleteMaintenanceApprovalPolicy(propertyID int) error {
+	result, err := db.DB.Exec("DELETE FROM maintenance_approval_policies WHERE property_id = $1", propertyID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SubmitMaintenanceEstimate records a work order's cost estimate and applies the property's
+// approval policy. An estimate above the auto-approval limit emails the approver a link built
+// by reviewLink; a pending estimate for the same work order is superseded. It returns
+// sql.ErrNoRows if the request does not exist.
+func SubmitMaintenanceEstimate(requestID int, estimate float64, notes string, requestedBy sql.NullInt32,
+	reviewLink func(token string) string, now time.Time) (*MaintenanceCostApproval, error) {
+	if estimate < 0 {
+		return nil, ErrInvalidEstimate
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	review := &CostApprovalReview{}
+	var propertyID int
+	err = tx.QueryRow(`
+		SELECT mr.property_id, p.name, mr.description, COALESCE(mr.priority, 'medium')
+		FROM maintenance_requests mr JOIN properties p ON p.id = mr.property_id
+		WHERE mr.id = $1`, requestID).Scan(&propertyID, &review.PropertyName, &review.RequestDescription, &review.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE maintenance_cost_approvals SET status = 'superseded', decided_at = $2
+		WHERE request_id = $1 AND status = 'pending'`, requestID, now); err != nil {
+		return nil, err
+	}
+
+	policy, err := getApprovalPolicy(tx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	approval := &review.MaintenanceCostApproval
+	approval.RequestID = requestID
+	approval.EstimatedCost = estimate
+	approval.EstimateNotes = NullString(notes)
+	approval.Status = ApprovalStatusFor(policy, estimate)
+	approval.RequestedBy = requestedBy
+	if policy != nil {
+		approval.AutoApproveLimit = sql.NullFloat64{Float64: policy.AutoApproveLimit, Valid: true}
+		approval.ApproverEmail = sql.NullString{String: policy.ApproverEmail, Valid: true}
+	}
+
+	var token string
+	var tokenHash sql.NullString
+	if approval.Status == ApprovalPending {
+		bytes := make([]byte, 32)
+		if _, err := rand.Read(bytes); err != nil {
+			return nil, err
+		}
+		token = hex.EncodeToString(bytes)
+		tokenHash = sql.NullString{String: HashAPIKey(token), Valid: true}
+		approval.ExpiresAt = sql.NullTime{Time: now.Add(CostApprovalTTL), Valid: true}
+	} else {
+		approval.DecidedAt = sql.NullTime{Time: now, Valid: true}
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO maintenance_cost_approvals (request_id, estimated_cost, estimate_notes, auto_approve_limit, status,
+			approver_email, token_hash, expires_at, decided_at, requested_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`,
+		requestID, estimate, approval.EstimateNotes, approval.AutoApproveLimit, approval.Status,
+		approval.ApproverEmail, tokenHash, approval.ExpiresAt, approval.DecidedAt, requestedBy).
+		Scan(&approval.ID, &approval.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE maintenance_requests SET estimated_cost = $2, approval_status = $3, updated_at = NOW()
+		WHERE id = $1`, requestID, estimate, approval.Status); err != nil {
+		return nil, err
+	}
+
+	if approval.Status == ApprovalPending {
+		subject, body := FormatCostApprovalEmail(review, policy, reviewLink(token))
+		err := EnqueueOutboxMessage(tx, &OutboxMessage{
+			Channel:     "email",
+			EventType:   "maintenance.approval_requested",
+			Destination: policy.ApproverEmail,
+			Payload: map[string]interface{}{
+				"request_id":  requestID,
+				"approval_id": approval.ID,
+				"subject":     subject,
+				"body":        body,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// FormatCostApprovalEmail builds the approval request sent to a property's approver
+func FormatCostApprovalEmail(review *CostApprovalReview, policy *MaintenanceApprovalPolicy, link string) (string, string) {
+	name := "there"
+	if policy.ApproverName.Valid {
+		name = policy.ApproverName.String
+	}
+	subject := fmt.Sprintf("Approval needed: $%.2f work order at %s", review.EstimatedCost, review.PropertyName)
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"A %s priority maintenance work order at %s is estimated at $%.2f, above the $%.2f you approve automatically.\n\n"+
+		"%s\n\n",
+		name, review.Priority, review.PropertyName, review.EstimatedCost, policy.AutoApproveLimit, review.RequestDescription)
+	if review.EstimateNotes.Valid {
+		body += "Estimate notes: " + review.EstimateNotes.String + "\n\n"
+	}
+	body += fmt.Sprintf("Approve or decline it here:\n%s\n\nThis link expires on %s.\n",
+		link, review.ExpiresAt.Time.Format("January 2, 2006"))
+	return subject, body
+}
+
+const costApprovalColumns = `a.id, a.request_id, a.estimated_cost, a.estimate_notes, a.auto_approve_limit, a.status,
+	a.approver_email, a.expires_at, a.decided_at, a.decision_notes, a.requested_by, a.created_at`
+
+func scanCostApproval(row interface{ Scan(...interface{}) error }, dest ...interface{}) (MaintenanceCostApproval, error) {
+	var a MaintenanceCostApproval
+	err := row.Scan(append([]interface{}{&a.ID, &a.RequestID, &a.EstimatedCost, &a.EstimateNotes, &a.AutoApproveLimit,
+		&a.Status, &a.ApproverEmail, &a.ExpiresAt, &a.DecidedAt, &a.DecisionNotes, &a.RequestedBy, &a.CreatedAt}, dest...)...)
+	return a, err
+}
+
+func queryCostApprovals(query string, args ...interface{}) ([]MaintenanceCostApproval, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []MaintenanceCostApproval
+	for rows.Next() {
+		a, err := scanCostApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, a)
+	}
+	return approvals, rows.Err()
+}
+
+// GetMaintenanceCostApprovals returns a work order's estimates and their approvals, newest first
+func GetMaintenanceCostApprovals(requestID int) ([]MaintenanceCostApproval, error) {
+	return queryCostApprovals(`
+		SELECT `+costApprovalColumns+`
+		FROM maintenance_cost_approvals a
+		WHERE a.request_id = $1
+		ORDER BY a.created_at DESC, a.id DESC`, requestID)
+}
+
+// GetPendingCostApprovals returns the estimates for requests at properties awaiting an approver,
+// oldest first
+func GetPendingCostApprovals(properties PropertyFilter) ([]MaintenanceCostApproval, error) {
+	scope, args := properties.condition("mr.property_id", nil)
+	return queryCostApprovals(`
+		SELECT `+costApprovalColumns+`
+		FROM maintenance_cost_approvals a
+		JOIN maintenance_requests mr ON mr.id = a.request_id
+		WHERE a.status = 'pending'`+scope+`
+		ORDER BY a.created_at, a.id`, args...)
+}
+
+// GetCostApprovalByToken returns the estimate an approval link is for, or ErrApprovalInvalid if
+// the token is unknown. Decided and expired estimates are returned so the approver can see
+// what happened.
+func GetCostApprovalByToken(token string, now time.Time) (*CostApprovalReview, error) {
+	review := &CostApprovalReview{}
+	a, err := scanCostApproval(db.DB.QueryRow(`
+		SELECT `+costApprovalColumns+`, p.name, mr.description, COALESCE(mr.priority, 'medium')
+		FROM maintenance_cost_approvals a
+		JOIN maintenance_requests mr ON mr.id = a.request_id
+		JOIN properties p ON p.id = mr.property_id
+		WHERE a.token_hash = $1`, HashAPIKey(token)),
+		&review.PropertyName, &review.RequestDescription, &review.Priority)
+	if err == sql.ErrNoRows {
+		return nil, ErrApprovalInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	review.MaintenanceCostApproval = a
+	review.Expired = a.Status == ApprovalPending && a.ExpiresAt.Valid && !now.Before(a.ExpiresAt.Time)
+	return review, nil
+}
+
+// DecideCostApproval records the approver's decision on a pending estimate and updates the work
+// order's approval status. It returns ErrApprovalInvalid unless the link's estimate is still
+// pending and unexpired.
+func DecideCostApproval(token string, approve bool, notes string, now time.Time) (*MaintenanceCostApproval, error) {
+	status := ApprovalDeclined
+	if approve {
+		status = ApprovalApproved
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id, requestID int
+	err = tx.QueryRow(`
+		UPDATE maintenance_cost_approvals
+		SET status = $2, decided_at = $3, decision_notes = $4
+		WHERE token_hash = $1 AND status = 'pending' AND expires_at > $3
+		RETURNING id, request_id`, HashAPIKey(token), status, now, NullString(notes)).Scan(&id, &requestID)
+	if err == sql.ErrNoRows {
+		return nil, ErrApprovalInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE maintenance_requests SET approval_status = $2, updated_at = NOW() WHERE id = $1",
+		requestID, status); err != nil {
+		return nil, err
+	}
+
+	approval, err := scanCostApproval(tx.QueryRow("SELECT "+costApprovalColumns+" FROM maintenance_cost_approvals a WHERE a.id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
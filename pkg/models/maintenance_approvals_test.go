@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApprovalStatusFor(t *testing.T) {
+	policy := &MaintenanceApprovalPolicy{AutoApproveLimit: 500}
+	assert.Equal(t, ApprovalNotRequired, ApprovalStatusFor(nil, 5000))
+	assert.Equal(t, ApprovalAutoApproved, ApprovalStatusFor(policy, 500))
+	assert.Equal(t, ApprovalPending, ApprovalStatusFor(policy, 500.01))
+}
+
+func TestSubmitMaintenanceEstimateAboveLimitEmailsApprover(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM maintenance_requests mr JOIN properties p`).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"property_id", "name", "description", "priority"}).
+			AddRow(3, "Maple Court", "Replace water heater", "high"))
+	mock.ExpectExec(`UPDATE maintenance_cost_approvals SET status = 'superseded'`).WithArgs(8, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM maintenance_approval_policies ap`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"property_id", "name", "auto_approve_limit", "approver_name",
+			"approver_email", "updated_at"}).AddRow(3, "Maple Court", 500.0, "Dana", "owner@example.com", now))
+	mock.ExpectQuery(`INSERT INTO maintenance_cost_approvals`).
+		WithArgs(8, 1800.0, sqlmock.AnyArg(), sqlmock.AnyArg(), ApprovalPending, sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(14, now))
+	mock.ExpectExec(`UPDATE maintenance_requests SET estimated_cost`).WithArgs(8, 1800.0, ApprovalPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	outboxColumns := []string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).WithArgs("email", "maintenance.approval_requested",
+		"owner@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(outboxColumns).AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	var link string
+	approval, err := SubmitMaintenanceEstimate(8, 1800, "Tank is leaking", sql.NullInt32{Int32: 2, Valid: true},
+		func(token string) string {
+			link = "https://pm.example.com/work-orders/approve?token=" + token
+			return link
+		}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 14, approval.ID)
+	assert.Equal(t, ApprovalPending, approval.Status)
+	assert.Equal(t, now.Add(CostApprovalTTL), approval.ExpiresAt.Time)
+	assert.True(t, strings.Contains(link, "token="))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDecideCostApprovalRejectsUsedLink(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE maintenance_cost_approvals`).
+		WithArgs(HashAPIKey("used-token"), ApprovalApproved, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "request_id"}))
+	mock.ExpectRollback()
+
+	_, err := DecideCostApproval("used-token", true, "", time.Now())
+	assert.Equal(t, ErrApprovalInvalid, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
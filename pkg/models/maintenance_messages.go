@@ -0,0 +1,468 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/mailer"
+)
+
+// Maintenance message author roles
+const (
+	MessageAuthorTenant  = "tenant"
+	MessageAuthorManager = "manager"
+	MessageAuthorVendor  = "vendor"
+)
+
+// MaintenanceRequestDetail is a maintenance request with its participants and message thread
+type MaintenanceRequestDetail struct {
+	ID                 int                      `json:"id"`
+	PropertyID         int                      `json:"property_id"`
+	PropertyName       string                   `json:"property_name"`
+	ReportedByTenantID sql.NullInt32            `json:"reported_by_tenant_id,omitempty"`
+	Description        string                   `json:"description"`
+	Status             string                   `json:"status"`
+	Priority           sql.NullString           `json:"priority,omitempty"`
+	ReportedDate       time.Time                `json:"reported_date"`
+	CompletedDate      sql.NullTime             `json:"completed_date,omitempty"`
+	RespondedAt        sql.NullTime             `json:"responded_at,omitempty"`
+	ResolvedAt         sql.NullTime             `json:"resolved_at,omitempty"`
+	Participants       []MaintenanceParticipant `json:"participants"`
+	Messages           []MaintenanceMessage     `json:"messages"`
+}
+
+// MaintenanceParticipant is a vendor added to a maintenance request's thread
+type MaintenanceParticipant struct {
+	UserID  int       `json:"user_id"`
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	Role    string    `json:"role"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// MaintenanceMessage is one message in a maintenance request's thread
+type MaintenanceMessage struct {
+	ID          int                     `json:"id"`
+	RequestID   int                     `json:"request_id"`
+	AuthorID    sql.NullInt32           `json:"author_id,omitempty"`
+	AuthorName  string                  `json:"author_name"`
+	AuthorRole  string                  `json:"author_role"`
+	Body        string                  `json:"body"`
+	CreatedAt   time.Time               `json:"created_at"`
+	Attachments []MaintenanceAttachment `json:"attachments"`
+	ReadBy      []MessageReadReceipt    `json:"read_by"`
+}
+
+// MaintenanceAttachment is a file attached to a maintenance message. The stored file is only
+// reachable through DownloadURL, which checks the caller can see the thread.
+type MaintenanceAttachment struct {
+	ID          int    `json:"id"`
+	MessageID   int    `json:"message_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int    `json:"size_bytes"`
+	URL         string `json:"-"`
+	DownloadURL string `json:"download_url"`
+}
+
+// MessageReadReceipt records that a thread participant has read a message
+type MessageReadReceipt struct {
+	UserID int       `json:"user_id"`
+	Name   string    `json:"name"`
+	ReadAt time.Time `json:"read_at"`
+}
+
+// MaintenanceThreadAccess describes who may take part in a request's thread
+type MaintenanceThreadAccess struct {
+	ReportedByTenantID sql.NullInt32
+	ParticipantIDs     map[int]bool
+}
+
+func attachmentDownloadURL(id int) string {
+	return fmt.Sprintf("/api/maintenance/attachments/%d", id)
+}
+
+// GetMaintenanceRequestDetail retrieves a maintenance request with its participants and thread
+func GetMaintenanceRequestDetail(id int) (*MaintenanceRequestDetail, error) {
+	var d MaintenanceRequestDetail
+	err := db.DB.QueryRow(`
+		SELECT mr.id, mr.property_id, p.name, mr.reported_by_tenant_id, mr.description, mr.status,
+			   mr.priority, mr.reported_date, mr.completed_date, mr.responded_at, mr.resolved_at
+		FROM maintenance_requests mr
+		JOIN properties p ON p.id = mr.property_id
+		WHERE mr.id = $1`, id).
+		Scan(&d.ID, &d.PropertyID, &d.PropertyName, &d.ReportedByTenantID, &d.Description, &d.Status,
+			&d.Priority, &d.ReportedDate, &d.CompletedDate, &d.RespondedAt, &d.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Participants, err = GetMaintenanceParticipants(id); err != nil {
+		return nil, err
+	}
+	if d.Messages, err = GetMaintenanceThread(id); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetMaintenanceParticipants returns the vendors added to a request
+func GetMaintenanceParticipants(requestID int) ([]MaintenanceParticipant, error) {
+	rows, err := db.DB.Query(`
+		SELECT u.id, u.first_name || ' ' || u.last_name, u.email, mp.role, mp.added_at
+		FROM maintenance_request_participants mp
+		JOIN users u ON u.id = mp.user_id
+		WHERE mp.request_id = $1
+		ORDER BY mp.added_at, u.id`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := []MaintenanceParticipant{}
+	for rows.Next() {
+		var p MaintenanceParticipant
+		if err := rows.Scan(&p.UserID, &p.Name, &p.Email, &p.Role, &p.AddedAt); err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+	return participants, rows.Err()
+}
+
+// AddMaintenanceParticipant gives a vendor access to a request's thread
+func AddMaintenanceParticipant(requestID, userID int, addedBy sql.NullInt32) error {
+	var exists int
+	if err := db.DB.QueryRow("SELECT 1 FROM maintenance_requests WHERE id = $1", requestID).Scan(&exists); err != nil {
+		return err
+	}
+	if err := db.DB.QueryRow("SELECT 1 FROM users WHERE id = $1", userID).Scan(&exists); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec(`
+		INSERT INTO maintenance_request_participants (request_id, user_id, role, added_by)
+		VALUES ($1, $2, 'vendor', $3)
+		ON CONFLICT (request_id, user_id) DO NOTHING`, requestID, userID, addedBy)
+	return err
+}
+
+// RemoveMaintenanceParticipant revokes a vendor's access to a request's thread
+func RemoveMaintenanceParticipant(requestID, userID int) error {
+	result, err := db.DB.Exec("DELETE FROM maintenance_request_participants WHERE request_id = $1 AND user_id = $2",
+		requestID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetMaintenanceThreadAccess returns the reporting tenant and vendors of a request
+func GetMaintenanceThreadAccess(requestID int) (*MaintenanceThreadAccess, error) {
+	access := &MaintenanceThreadAccess{ParticipantIDs: map[int]bool{}}
+	err := db.DB.QueryRow("SELECT reported_by_tenant_id FROM maintenance_requests WHERE id = $1", requestID).
+		Scan(&access.ReportedByTenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query("SELECT user_id FROM maintenance_request_participants WHERE request_id = $1", requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		access.ParticipantIDs[userID] = true
+	}
+	return access, rows.Err()
+}
+
+// CountUnreadMaintenanceMessages counts the messages by others the user has not read in the
+// threads they take part in: those they were added to, have read or posted in, and, when
+// tenantID is not 0, those of the requests the tenant reported
+func CountUnreadMaintenanceMessages(userID, tenantID int) (int, error) {
+	var count int
+	err := db.ReadDB().QueryRow(`
+		SELECT COUNT(*)
+		FROM maintenance_messages m
+		LEFT JOIN maintenance_thread_reads r ON r.request_id = m.request_id AND r.user_id = $1
+		WHERE (m.author_id IS NULL OR m.author_id <> $1)
+		  AND m.id > COALESCE(r.last_read_message_id, 0)
+		  AND (r.user_id IS NOT NULL
+		       OR m.request_id IN (SELECT request_id FROM maintenance_request_participants WHERE user_id = $1)
+		       OR m.request_id IN (SELECT id FROM maintenance_requests WHERE reported_by_tenant_id = $2))`,
+		userID, tenantID).Scan(&count)
+	return count, err
+}
+
+// GetMaintenanceThread returns a request's messages, oldest first, with their attachments and
+// read receipts
+func GetMaintenanceThread(requestID int) ([]MaintenanceMessage, error) {
+	rows, err := db.DB.Query(`
+		SELECT m.id, m.request_id, m.author_id, COALESCE(u.first_name || ' ' || u.last_name, 'Deleted user'),
+			   m.author_role, m.body, m.created_at
+		FROM maintenance_messages m
+		LEFT JOIN users u ON u.id = m.author_id
+		WHERE m.request_id = $1
+		ORDER BY m.id`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []MaintenanceMessage{}
+	index := map[int]int{}
+	for rows.Next() {
+		m := MaintenanceMessage{Attachments: []MaintenanceAttachment{}, ReadBy: []MessageReadReceipt{}}
+		if err := rows.Scan(&m.ID, &m.RequestID, &m.AuthorID, &m.AuthorName, &m.AuthorRole, &m.Body, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		index[m.ID] = len(messages)
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return messages, nil
+	}
+
+	attachments, err := db.DB.Query(`
+		SELECT a.id, a.message_id, a.filename, a.content_type, a.size_bytes, a.url
+		FROM maintenance_message_attachments a
+		JOIN maintenance_messages m ON m.id = a.message_id
+		WHERE m.request_id = $1
+		ORDER BY a.id`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer attachments.Close()
+	for attachments.Next() {
+		var a MaintenanceAttachment
+		if err := attachments.Scan(&a.ID, &a.MessageID, &a.Filename, &a.ContentType, &a.SizeBytes, &a.URL); err != nil {
+			return nil, err
+		}
+		a.DownloadURL = attachmentDownloadURL(a.ID)
+		if i, ok := index[a.MessageID]; ok {
+			messages[i].Attachments = append(messages[i].Attachments, a)
+		}
+	}
+	if err := attachments.Err(); err != nil {
+		return nil, err
+	}
+
+	// Each reader has read every message up to their last read message, except their own
+	reads, err := db.DB.Query(`
+		SELECT r.user_id, u.first_name || ' ' || u.last_name, r.last_read_message_id, r.read_at
+		FROM maintenance_thread_reads r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.request_id = $1
+		ORDER BY r.user_id`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer reads.Close()
+	for reads.Next() {
+		var receipt MessageReadReceipt
+		var lastRead int
+		if err := reads.Scan(&receipt.UserID, &receipt.Name, &lastRead, &receipt.ReadAt); err != nil {
+			return nil, err
+		}
+		for i := range messages {
+			m := &messages[i]
+			if m.ID <= lastRead && !(m.AuthorID.Valid && int(m.AuthorID.Int32) == receipt.UserID) {
+				m.ReadBy = append(m.ReadBy, receipt)
+			}
+		}
+	}
+	return messages, reads.Err()
+}
+
+// markThreadRead records that userID has read every message in the thread so far
+func markThreadRead(q Querier, requestID, userID int) error {
+	var lastID sql.NullInt64
+	if err := q.QueryRow("SELECT MAX(id) FROM maintenance_messages WHERE request_id = $1", requestID).Scan(&lastID); err != nil {
+		return err
+	}
+	if !lastID.Valid {
+		return nil
+	}
+	_, err := q.Exec(`
+		INSERT INTO maintenance_thread_reads (request_id, user_id, last_read_message_id, read_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (request_id, user_id) DO UPDATE SET
+			last_read_message_id = EXCLUDED.last_read_message_id, read_at = EXCLUDED.read_at`,
+		requestID, userID, lastID.Int64)
+	return err
+}
+
+// MarkMaintenanceThreadRead records a read receipt for every message in the thread so far
+func MarkMaintenanceThreadRead(requestID, userID int) error {
+	return markThreadRead(db.DB, requestID, userID)
+}
+
+// CreateMaintenanceMessage posts a message with its attachments, marks the thread read by its
+// author and queues email notifications to the other people in the thread. link points
+// recipients to the thread.
+func CreateMaintenanceMessage(msg *MaintenanceMessage, link string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO maintenance_messages (request_id, author_id, author_role, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		msg.RequestID, msg.AuthorID, msg.AuthorRole, msg.Body).Scan(&msg.ID, &msg.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i := range msg.Attachments {
+		a := &msg.Attachments[i]
+		a.MessageID = msg.ID
+		err := tx.QueryRow(`
+			INSERT INTO maintenance_message_attachments (message_id, filename, content_type, size_bytes, url)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id`,
+			a.MessageID, a.Filename, a.ContentType, a.SizeBytes, a.URL).Scan(&a.ID)
+		if err != nil {
+			return err
+		}
+		a.DownloadURL = attachmentDownloadURL(a.ID)
+	}
+
+	if msg.AuthorID.Valid {
+		if err := markThreadRead(tx, msg.RequestID, int(msg.AuthorID.Int32)); err != nil {
+			return err
+		}
+	}
+
+	recipients, err := maintenanceMessageRecipients(tx, msg.RequestID, msg.AuthorID)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return tx.Commit()
+	}
+	email, err := mailer.Render("maintenance_message", map[string]interface{}{
+		"RequestID":  msg.RequestID,
+		"AuthorName": msg.AuthorName,
+		"Body":       msg.Body,
+		"Link":       link,
+	})
+	if err != nil {
+		return err
+	}
+	for _, to := range recipients {
+		email.To = to
+		err := EnqueueEmail(tx, "maintenance.message", email, map[string]interface{}{
+			"request_id": msg.RequestID,
+			"message_id": msg.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// maintenanceMessageRecipients returns the email addresses notified of a new message: the
+// reporting tenant, the vendors, and the managers in the conversation (the on-call manager and
+// any who have posted), falling back to every active property manager. The author is excluded.
+func maintenanceMessageRecipients(q Querier, requestID int, authorID sql.NullInt32) ([]string, error) {
+	var authorEmail string
+	if authorID.Valid {
+		if err := q.QueryRow("SELECT email FROM users WHERE id = $1", authorID).Scan(&authorEmail); err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	queryEmails := func(query string, args ...interface{}) ([]string, error) {
+		rows, err := q.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var emails []string
+		for rows.Next() {
+			var email string
+			if err := rows.Scan(&email); err != nil {
+				return nil, err
+			}
+			emails = append(emails, email)
+		}
+		return emails, rows.Err()
+	}
+
+	managers, err := queryEmails(`
+		SELECT u.email FROM maintenance_requests mr
+		JOIN users u ON u.id = mr.oncall_user_id
+		WHERE mr.id = $1
+		UNION
+		SELECT u.email FROM maintenance_messages m
+		JOIN users u ON u.id = m.author_id
+		WHERE m.request_id = $1 AND m.author_role = 'manager'`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if len(managers) == 0 {
+		if managers, err = queryEmails(`
+			SELECT DISTINCT u.email
+			FROM users u
+			JOIN user_roles ur ON ur.user_id = u.id
+			JOIN roles r ON ur.role_id = r.id
+			WHERE r.name = 'property_manager' AND u.status = 'active'`); err != nil {
+			return nil, err
+		}
+	}
+
+	others, err := queryEmails(`
+		SELECT t.email FROM maintenance_requests mr
+		JOIN tenants t ON t.id = mr.reported_by_tenant_id
+		WHERE mr.id = $1
+		UNION
+		SELECT u.email FROM maintenance_request_participants mp
+		JOIN users u ON u.id = mp.user_id
+		WHERE mp.request_id = $1`, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{strings.ToLower(authorEmail): true}
+	var recipients []string
+	for _, email := range append(managers, others...) {
+		key := strings.ToLower(email)
+		if email == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		recipients = append(recipients, email)
+	}
+	return recipients, nil
+}
+
+// GetMaintenanceAttachment retrieves an attachment and the request whose thread it belongs to
+func GetMaintenanceAttachment(id int) (*MaintenanceAttachment, int, error) {
+	var a MaintenanceAttachment
+	var requestID int
+	err := db.DB.QueryRow(`
+		SELECT a.id, a.message_id, a.filename, a.content_type, a.size_bytes, a.url, m.request_id
+		FROM maintenance_message_attachments a
+		JOIN maintenance_messages m ON m.id = a.message_id
+		WHERE a.id = $1`, id).
+		Scan(&a.ID, &a.MessageID, &a.Filename, &a.ContentType, &a.SizeBytes, &a.URL, &requestID)
+	if err != nil {
+		return nil, 0, err
+	}
+	a.DownloadURL = attachmentDownloadURL(a.ID)
+	return &a, requestID, nil
+}
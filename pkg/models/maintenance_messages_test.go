@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMaintenanceThreadReadReceipts(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM maintenance_messages m`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "request_id", "author_id", "name", "author_role", "body", "created_at"}).
+			AddRow(10, 3, 7, "Tina Tenant", "tenant", "The sink leaks", now).
+			AddRow(11, 3, 2, "Mia Manager", "manager", "A plumber is coming", now))
+	mock.ExpectQuery(`FROM maintenance_message_attachments a`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "filename", "content_type", "size_bytes", "url"}).
+			AddRow(5, 10, "sink.jpg", "image/jpeg", 1024, "/uploads/maintenance/3/abc.jpg"))
+	mock.ExpectQuery(`FROM maintenance_thread_reads r`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "last_read_message_id", "read_at"}).
+			AddRow(2, "Mia Manager", 11, now).
+			AddRow(7, "Tina Tenant", 10, now))
+
+	messages, err := GetMaintenanceThread(3)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	require.Len(t, messages[0].Attachments, 1)
+	assert.Equal(t, "/api/maintenance/attachments/5", messages[0].Attachments[0].DownloadURL)
+
+	// Authors are not listed as readers of their own messages
+	require.Len(t, messages[0].ReadBy, 1)
+	assert.Equal(t, 2, messages[0].ReadBy[0].UserID)
+	assert.Empty(t, messages[1].ReadBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Maintenance request statuses. A request moves reported -> assigned -> in_progress -> completed.
+const (
+	MaintenanceReported   = "reported"
+	MaintenanceAssigned   = "assigned"
+	MaintenanceInProgress = "in_progress"
+	MaintenanceCompleted  = "completed"
+)
+
+// Maintenance request errors
+var (
+	ErrInvalidMaintenanceRequest    = errors.New("a maintenance request needs a description and a priority of low, medium, high or emergency")
+	ErrInvalidMaintenanceTransition = errors.New("the maintenance request cannot move to that status from its current one")
+	ErrMaintenanceAssigneeRequired  = errors.New("assigning a maintenance request needs an existing user to assign it to")
+	ErrMaintenanceAwaitingApproval  = errors.New("work on the maintenance request cannot start or finish until its cost estimate is approved")
+)
+
+// maintenanceTransitions lists the statuses each status may move to. Assigning an assigned
+// request again reassigns it.
+var maintenanceTransitions = map[string][]string{
+	MaintenanceReported:   {MaintenanceAssigned},
+	MaintenanceAssigned:   {MaintenanceAssigned, MaintenanceInProgress},
+	MaintenanceInProgress: {MaintenanceCompleted},
+}
+
+// CanTransitionMaintenance reports whether a request in status from may move to status to
+func CanTransitionMaintenance(from, to string) bool {
+	for _, next := range maintenanceTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// MaintenanceRequest is a reported maintenance issue at a property and its progress
+type MaintenanceRequest struct {
+	ID                 int           `json:"id"`
+	PropertyID         int           `json:"property_id"`
+	PropertyName       string        `json:"property_name"`
+	ReportedByTenantID sql.NullInt32 `json:"reported_by_tenant_id,omitempty"`
+	Description        string        `json:"description"`
+	Status             string        `json:"status"`
+	Priority           string        `json:"priority"`
+	ReportedDate       time.Time     `json:"reported_date"`
+	DueDate            sql.NullTime  `json:"due_date,omitempty"`
+	CompletedDate      sql.NullTime  `json:"completed_date,omitempty"`
+	AssignedTo         sql.NullInt32 `json:"assigned_to,omitempty"`
+	AssignedAt         sql.NullTime  `json:"assigned_at,omitempty"`
+	RespondedAt        sql.NullTime  `json:"responded_at,omitempty"`
+	ResolvedAt         sql.NullTime  `json:"resolved_at,omitempty"`
+	CreatedAt          time.Time     `json:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at"`
+}
+
+// MaintenanceRequestFilter narrows a list of maintenance requests; zero values match everything
+type MaintenanceRequestFilter struct {
+	Status     string
+	PropertyID int
+	TenantID   int // Requests reported by this tenant
+	AssignedTo int
+	Properties PropertyFilter // The properties the caller manages
+}
+
+const maintenanceRequestColumns = `mr.id, mr.property_id, p.name, mr.reported_by_tenant_id, mr.description,
+	mr.status, COALESCE(mr.priority, 'medium'), mr.reported_date, mr.due_date, mr.completed_date,
+	mr.assigned_to, mr.assigned_at, mr.responded_at, mr.resolved_at, mr.created_at, mr.updated_at`
+
+func scanMaintenanceRequest(row interface{ Scan(...interface{}) error }) (MaintenanceRequest, error) {
+	var m MaintenanceRequest
+	err := row.Scan(&m.ID, &m.PropertyID, &m.PropertyName, &m.ReportedByTenantID, &m.Description,
+		&m.Status, &m.Priority, &m.ReportedDate, &m.DueDate, &m.CompletedDate,
+		&m.AssignedTo, &m.AssignedAt, &m.RespondedAt, &m.ResolvedAt, &m.CreatedAt, &m.UpdatedAt)
+	return m, err
+}
+
+// validateMaintenanceRequest trims the description and defaults the priority to medium
+func validateMaintenanceRequest(m *MaintenanceRequest) error {
+	m.Description = strings.TrimSpace(m.Description)
+	m.Priority = strings.ToLower(strings.TrimSpace(m.Priority))
+	if m.Priority == "" {
+		m.Priority = "medium"
+	}
+	switch m.Priority {
+	case "low", "medium", "high", "emergency":
+	default:
+		return ErrInvalidMaintenanceRequest
+	}
+	if m.Description == "" {
+		return ErrInvalidMaintenanceRequest
+	}
+	return nil
+}
+
+// CreateMaintenanceRequest saves a newly reported request. It returns sql.ErrNoRows if the
+// property does not exist.
+func CreateMaintenanceRequest(m *MaintenanceRequest) error {
+	if err := validateMaintenanceRequest(m); err != nil {
+		return err
+	}
+
+	if err := db.DB.QueryRow("SELECT name FROM properties WHERE id = $1", m.PropertyID).Scan(&m.PropertyName); err != nil {
+		return err
+	}
+
+	return db.DB.QueryRow(`
+		INSERT INTO maintenance_requests (property_id, reported_by_tenant_id, description, priority, due_date)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, reported_date, created_at, updated_at`,
+		m.PropertyID, m.ReportedByTenantID, m.Description, m.Priority, m.DueDate).
+		Scan(&m.ID, &m.Status, &m.ReportedDate, &m.CreatedAt, &m.UpdatedAt)
+}
+
+// GetMaintenanceRequest retrieves a maintenance request
+func GetMaintenanceRequest(id int) (*MaintenanceRequest, error) {
+	m, err := scanMaintenanceRequest(db.DB.QueryRow(`
+		SELECT `+maintenanceRequestColumns+`
+		FROM maintenance_requests mr
+		JOIN properties p ON p.id = mr.property_id
+		WHERE mr.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetMaintenanceRequestPropertyID returns the property a request was reported at
+func GetMaintenanceRequestPropertyID(id int) (int, error) {
+	var propertyID int
+	err := db.DB.QueryRow("SELECT property_id FROM maintenance_requests WHERE id = $1", id).Scan(&propertyID)
+	return propertyID, err
+}
+
+// GetMaintenanceRequests lists the requests matching a filter, newest first
+func GetMaintenanceRequests(filter MaintenanceRequestFilter) ([]MaintenanceRequest, error) {
+	scope, args := filter.Properties.condition("mr.property_id",
+		[]interface{}{filter.Status, filter.PropertyID, filter.TenantID, filter.AssignedTo})
+	rows, err := db.ReadDB().Query(`
+		SELECT `+maintenanceRequestColumns+`
+		FROM maintenance_requests mr
+		JOIN properties p ON p.id = mr.property_id
+		WHERE ($1 = '' OR mr.status = $1)
+		  AND ($2 = 0 OR mr.property_id = $2)
+		  AND ($3 = 0 OR mr.reported_by_tenant_id = $3)
+		  AND ($4 = 0 OR mr.assigned_to = $4)`+scope+`
+		ORDER BY mr.created_at DESC, mr.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []MaintenanceRequest
+	for rows.Next() {
+		m, err := scanMaintenanceRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, m)
+	}
+	return requests, rows.Err()
+}
+
+// UpdateMaintenanceRequest changes a request's description, priority and due date. Its status
+// only changes through TransitionMaintenanceRequest.
+func UpdateMaintenanceRequest(m *MaintenanceRequest) error {
+	if err := validateMaintenanceRequest(m); err != nil {
+		return err
+	}
+	result, err := db.DB.Exec(`
+		UPDATE maintenance_requests SET description = $2, priority = $3, due_date = $4, updated_at = NOW()
+		WHERE id = $1`, m.ID, m.Description, m.Priority, m.DueDate)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteMaintenanceRequest deletes a request with its thread and cost approvals
+func DeleteMaintenanceRequest(id int) error {
+	result, err := db.DB.Exec("DELETE FROM maintenance_requests WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// TransitionMaintenanceRequest moves a request to a new status. Assigning needs the user it is
+// assigned to and, like starting work, counts as the first response for the SLA; completing
+// records the resolution. It returns sql.ErrNoRows if the request does not exist,
+// ErrInvalidMaintenanceTransition if it cannot move to the status from its current one and
+// ErrMaintenanceAwaitingApproval if work would start or finish while its cost estimate is
+// pending or declined.
+func TransitionMaintenanceRequest(id int, status string, assignee sql.NullInt32, now time.Time) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	var approval sql.NullString
+	if err := tx.QueryRow("SELECT status, approval_status FROM maintenance_requests WHERE id = $1", id).
+		Scan(&current, &approval); err != nil {
+		return err
+	}
+	if !CanTransitionMaintenance(current, status) {
+		return ErrInvalidMaintenanceTransition
+	}
+	if status != MaintenanceAssigned && awaitingApproval(approval.String) {
+		return ErrMaintenanceAwaitingApproval
+	}
+
+	var result sql.Result
+	switch status {
+	case MaintenanceAssigned:
+		if !assignee.Valid {
+			return ErrMaintenanceAssigneeRequired
+		}
+		var exists int
+		if err := tx.QueryRow("SELECT 1 FROM users WHERE id = $1", assignee.Int32).Scan(&exists); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrMaintenanceAssigneeRequired
+			}
+			return err
+		}
+		result, err = tx.Exec(`
+			UPDATE maintenance_requests
+			SET status = $3, assigned_to = $4, assigned_at = $5, responded_at = COALESCE(responded_at, $5), updated_at = $5
+			WHERE id = $1 AND status = $2`, id, current, status, assignee, now)
+	case MaintenanceInProgress:
+		result, err = tx.Exec(`
+			UPDATE maintenance_requests
+			SET status = $3, responded_at = COALESCE(responded_at, $4), updated_at = $4
+			WHERE id = $1 AND status = $2 AND COALESCE(approval_status, '') NOT IN ('pending', 'declined')`, id, current, status, now)
+	case MaintenanceCompleted:
+		result, err = tx.Exec(`
+			UPDATE maintenance_requests
+			SET status = $3, resolved_at = COALESCE(resolved_at, $4), responded_at = COALESCE(responded_at, $4),
+				completed_date = $5, updated_at = $4
+			WHERE id = $1 AND status = $2 AND COALESCE(approval_status, '') NOT IN ('pending', 'declined')`, id, current, status, now, now.Format("2006-01-02"))
+	}
+	if err != nil {
+		return err
+	}
+	// Another change moved the request on, or submitted an estimate, since it was read
+	if err := requireAffected(result); err == sql.ErrNoRows {
+		return ErrInvalidMaintenanceTransition
+	} else if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// awaitingApproval reports whether a request's cost approval status holds back work on it
+func awaitingApproval(status string) bool {
+	return status == ApprovalPending || status == ApprovalDeclined
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanTransitionMaintenance(t *testing.T) {
+	assert.True(t, CanTransitionMaintenance(MaintenanceReported, MaintenanceAssigned))
+	assert.True(t, CanTransitionMaintenance(MaintenanceAssigned, MaintenanceAssigned), "reassigning")
+	assert.True(t, CanTransitionMaintenance(MaintenanceAssigned, MaintenanceInProgress))
+	assert.True(t, CanTransitionMaintenance(MaintenanceInProgress, MaintenanceCompleted))
+	assert.False(t, CanTransitionMaintenance(MaintenanceReported, MaintenanceCompleted))
+	assert.False(t, CanTransitionMaintenance(MaintenanceCompleted, MaintenanceReported))
+	assert.False(t, CanTransitionMaintenance(MaintenanceReported, "closed"))
+}
+
+func TestCreateMaintenanceRequest(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	assert.Equal(t, ErrInvalidMaintenanceRequest, CreateMaintenanceRequest(&MaintenanceRequest{PropertyID: 3, Description: "  "}))
+	assert.Equal(t, ErrInvalidMaintenanceRequest,
+		CreateMaintenanceRequest(&MaintenanceRequest{PropertyID: 3, Description: "Leak", Priority: "urgent"}))
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT name FROM properties`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Maple Court"))
+	mock.ExpectQuery(`INSERT INTO maintenance_requests`).
+		WithArgs(3, sql.NullInt32{Int32: 7, Valid: true}, "Kitchen sink leaks", "medium", sql.NullTime{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "reported_date", "created_at", "updated_at"}).
+			AddRow(21, MaintenanceReported, now, now, now))
+
+	request := &MaintenanceRequest{
+		PropertyID:         3,
+		ReportedByTenantID: sql.NullInt32{Int32: 7, Valid: true},
+		Description:        " Kitchen sink leaks ",
+	}
+	require.NoError(t, CreateMaintenanceRequest(request))
+	assert.Equal(t, 21, request.ID)
+	assert.Equal(t, "Maple Court", request.PropertyName)
+	assert.Equal(t, MaintenanceReported, request.Status)
+
+	mock.ExpectQuery(`SELECT name FROM properties`).WithArgs(99).WillReturnError(sql.ErrNoRows)
+	assert.Equal(t, sql.ErrNoRows, CreateMaintenanceRequest(&MaintenanceRequest{PropertyID: 99, Description: "Leak"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransitionMaintenanceRequest(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	assignee := sql.NullInt32{Int32: 12, Valid: true}
+
+	// Assigning records the assignee and the first response
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(21).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).AddRow(MaintenanceReported, nil))
+	mock.ExpectQuery(`SELECT 1 FROM users`).WithArgs(int32(12)).
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectExec(`SET status = \$3, assigned_to = \$4, assigned_at = \$5, responded_at = COALESCE\(responded_at, \$5\)`).
+		WithArgs(21, MaintenanceReported, MaintenanceAssigned, assignee, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, TransitionMaintenanceRequest(21, MaintenanceAssigned, assignee, now))
+
+	// Completing records the resolution
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(21).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).AddRow(MaintenanceInProgress, nil))
+	mock.ExpectExec(`resolved_at = COALESCE\(resolved_at, \$4\)`).
+		WithArgs(21, MaintenanceInProgress, MaintenanceCompleted, now, "2026-10-16").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, TransitionMaintenanceRequest(21, MaintenanceCompleted, sql.NullInt32{}, now))
+
+	// Steps cannot be skipped
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(21).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).AddRow(MaintenanceReported, nil))
+	mock.ExpectRollback()
+	assert.Equal(t, ErrInvalidMaintenanceTransition, TransitionMaintenanceRequest(21, MaintenanceCompleted, sql.NullInt32{}, now))
+
+	// Assigning needs an existing user
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(21).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).AddRow(MaintenanceReported, nil))
+	mock.ExpectRollback()
+	assert.Equal(t, ErrMaintenanceAssigneeRequired, TransitionMaintenanceRequest(21, MaintenanceAssigned, sql.NullInt32{}, now))
+
+	// A concurrent change since the status was read is a conflict
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(21).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).AddRow(MaintenanceAssigned, nil))
+	mock.ExpectExec(`UPDATE maintenance_requests`).
+		WithArgs(21, MaintenanceAssigned, MaintenanceInProgress, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.Equal(t, ErrInvalidMaintenanceTransition, TransitionMaintenanceRequest(21, MaintenanceInProgress, sql.NullInt32{}, now))
+
+	// Work waits for a pending or declined estimate to be approved
+	for _, approval := range []string{ApprovalPending, ApprovalDeclined} {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(21).
+			WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).AddRow(MaintenanceAssigned, approval))
+		mock.ExpectRollback()
+		assert.Equal(t, ErrMaintenanceAwaitingApproval, TransitionMaintenanceRequest(21, MaintenanceInProgress, sql.NullInt32{}, now))
+	}
+
+	// Approved work can start
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(21).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "approval_status"}).AddRow(MaintenanceAssigned, ApprovalApproved))
+	mock.ExpectExec(`AND COALESCE\(approval_status, ''\) NOT IN \('pending', 'declined'\)`).
+		WithArgs(21, MaintenanceAssigned, MaintenanceInProgress, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, TransitionMaintenanceRequest(21, MaintenanceInProgress, sql.NullInt32{}, now))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, approval_status FROM maintenance_requests`).WithArgs(404).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	assert.Equal(t, sql.ErrNoRows, TransitionMaintenanceRequest(404, MaintenanceAssigned, assignee, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// SLA statuses, ordered from best to worst
+const (
+	SLAStatusMet      = "met"
+	SLAStatusOnTrack  = "on_track"
+	SLAStatusAtRisk   = "at_risk"
+	SLAStatusBreached = "breached"
+)
+
+// SLAWarningThreshold is the fraction of an SLA window after which an open request is at risk
+var SLAWarningThreshold = 0.8
+
+// MaintenanceSLAPolicy defines response and resolution targets for a priority
+type MaintenanceSLAPolicy struct {
+	ID              int       `json:"id"`
+	Priority        string    `json:"priority"`
+	ResponseHours   int       `json:"response_hours"`
+	ResolutionHours int       `json:"resolution_hours"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SLAStatus is the SLA evaluation of a single maintenance request
+type SLAStatus struct {
+	ResponseDueAt    time.Time `json:"response_due_at"`
+	ResolutionDueAt  time.Time `json:"resolution_due_at"`
+	ResponseStatus   string    `json:"response_status"`
+	ResolutionStatus string    `json:"resolution_status"`
+	Status           string    `json:"status"` // Worst of the response and resolution statuses
+}
+
+// MaintenanceSLARecord is a maintenance request with its SLA evaluation
+type MaintenanceSLARecord struct {
+	ID            int            `json:"id"`
+	PropertyID    int            `json:"property_id"`
+	PropertyName  string         `json:"property_name"`
+	Description   string         `json:"description"`
+	Status        string         `json:"status"`
+	Priority      string         `json:"priority"`
+	CreatedAt     time.Time      `json:"created_at"`
+	RespondedAt   sql.NullTime   `json:"responded_at,omitempty"`
+	ResolvedAt    sql.NullTime   `json:"resolved_at,omitempty"`
+	SLAAlertLevel sql.NullString `json:"-"`
+	SLA           *SLAStatus     `json:"sla,omitempty"`
+}
+
+// slaRank orders statuses so the worst can be selected
+var slaRank = map[string]int{SLAStatusMet: 0, SLAStatusOnTrack: 1, SLAStatusAtRisk: 2, SLAStatusBreached: 3}
+
+// evaluateSLATarget evaluates a single target that was due within window of openedAt
+func evaluateSLATarget(openedAt time.Time, window time.Duration, doneAt sql.NullTime, now time.Time) (time.Time, string) {
+	dueAt := openedAt.Add(window)
+
+	if doneAt.Valid {
+		if doneAt.Time.After(dueAt) {
+			return dueAt, SLAStatusBreached
+		}
+		return dueAt, SLAStatusMet
+	}
+
+	switch {
+	case now.After(dueAt):
+		return dueAt, SLAStatusBreached
+	case now.Sub(openedAt) >= time.Duration(float64(window)*SLAWarningThreshold):
+		return dueAt, SLAStatusAtRisk
+	default:
+		return dueAt, SLAStatusOnTrack
+	}
+}
+
+// ComputeSLAStatus evaluates a request opened at openedAt against policy at time now
+func ComputeSLAStatus(openedAt time.Time, respondedAt, resolvedAt sql.NullTime, policy MaintenanceSLAPolicy, now time.Time) *SLAStatus {
+	// Resolving a request also counts as responding to it
+	if !respondedAt.Valid && resolvedAt.Valid {
+		respondedAt = resolvedAt
+	}
+
+	status := &SLAStatus{}
+	status.ResponseDueAt, status.ResponseStatus = evaluateSLATarget(openedAt,
+		time.Duration(policy.ResponseHours)*time.Hour, respondedAt, now)
+	status.ResolutionDueAt, status.ResolutionStatus = evaluateSLATarget(openedAt,
+		time.Duration(policy.ResolutionHours)*time.Hour, resolvedAt, now)
+
+	status.Status = status.ResponseStatus
+	if slaRank[status.ResolutionStatus] > slaRank[status.Status] {
+		status.Status = status.ResolutionStatus
+	}
+	return status
+}
+
+// SLAAlertLevel returns the alert that should be sent for a request, or "" if managers have
+// already been alerted at this level. Each request alerts at most once per level.
+func SLAAlertLevel(status *SLAStatus, lastLevel string) string {
+	if status == nil {
+		return ""
+	}
+	switch status.Status {
+	case SLAStatusBreached:
+		if lastLevel != SLAStatusBreached {
+			return SLAStatusBreached
+		}
+	case SLAStatusAtRisk:
+		if lastLevel == "" {
+			return SLAStatusAtRisk
+		}
+	}
+	return ""
+}
+
+// GetMaintenanceSLAPolicies retrieves all SLA policies keyed by priority
+func GetMaintenanceSLAPolicies() (map[string]MaintenanceSLAPolicy, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, priority, response_hours, resolution_hours, created_at, updated_at
+		FROM maintenance_sla_policies`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := make(map[string]MaintenanceSLAPolicy)
+	for rows.Next() {
+		var policy MaintenanceSLAPolicy
+		err := rows.Scan(&policy.ID, &policy.Priority, &policy.ResponseHours, &policy.ResolutionHours,
+			&policy.CreatedAt, &policy.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		policies[policy.Priority] = policy
+	}
+
+	return policies, nil
+}
+
+// SaveMaintenanceSLAPolicy creates or updates the policy for policy.Priority
+func SaveMaintenanceSLAPolicy(policy *MaintenanceSLAPolicy) error {
+	query := `
+		UPDATE maintenance_sla_policies
+		SET response_hours = $2, resolution_hours = $3, updated_at = NOW()
+		WHERE priority = $1
+		RETURNING id, created_at, updated_at`
+
+	err := db.DB.QueryRow(query, policy.Priority, policy.ResponseHours, policy.ResolutionHours).
+		Scan(&policy.ID, &policy.CreatedAt, &policy.UpdatedAt)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	query = `
+		INSERT INTO maintenance_sla_policies (priority, response_hours, resolution_hours)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`
+
+	return db.DB.QueryRow(query, policy.Priority, policy.ResponseHours, policy.ResolutionHours).
+		Scan(&policy.ID, &policy.CreatedAt, &policy.UpdatedAt)
+}
+
+// GetOpenMaintenanceSLARecords retrieves every unresolved maintenance request at properties with
+// its SLA status
+func GetOpenMaintenanceSLARecords(properties PropertyFilter) ([]MaintenanceSLARecord, error) {
+	policies, err := GetMaintenanceSLAPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	scope, args := properties.condition("mr.property_id", nil)
+	query := `
+		SELECT mr.id, mr.property_id, p.name, mr.description, mr.status,
+			   COALESCE(mr.priority, 'medium'), mr.created_at, mr.responded_at, mr.resolved_at,
+			   mr.sla_alert_level
+		FROM maintenance_requests mr
+		JOIN properties p ON mr.property_id = p.id
+		WHERE mr.status <> 'completed' AND mr.resolved_at IS NULL` + scope + `
+		ORDER BY mr.created_at`
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var records []MaintenanceSLARecord
+	for rows.Next() {
+		var record MaintenanceSLARecord
+		err := rows.Scan(&record.ID, &record.PropertyID, &record.PropertyName, &record.Description,
+			&record.Status, &record.Priority, &record.CreatedAt, &record.RespondedAt,
+			&record.ResolvedAt, &record.SLAAlertLevel)
+		if err != nil {
+			return nil, err
+		}
+
+		if policy, ok := policies[record.Priority]; ok {
+			record.SLA = ComputeSLAStatus(record.CreatedAt, record.RespondedAt, record.ResolvedAt, policy, now)
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// GetMaintenanceSLAStats summarises open requests at properties by priority and SLA status
+func GetMaintenanceSLAStats(properties PropertyFilter) (map[string]interface{}, error) {
+	records, err := GetOpenMaintenanceSLARecords(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	priorityBreakdown := map[string]int{}
+	atRisk, breached := 0, 0
+	breaches := []MaintenanceSLARecord{}
+	for _, record := range records {
+		priorityBreakdown[record.Priority]++
+		if record.SLA == nil {
+			continue
+		}
+		switch record.SLA.Status {
+		case SLAStatusAtRisk:
+			atRisk++
+		case SLAStatusBreached:
+			breached++
+			breaches = append(breaches, record)
+		}
+	}
+
+	return map[string]interface{}{
+		"open_requests":      len(records),
+		"priority_breakdown": priorityBreakdown,
+		"sla_at_risk":        atRisk,
+		"sla_breached":       breached,
+		"sla_breaches":       breaches,
+	}, nil
+}
+
+// SetMaintenanceActualCost records what a request cost to complete
+func SetMaintenanceActualCost(id int, cost float64) error {
+	result, err := db.DB.Exec("UPDATE maintenance_requests SET actual_cost = $2, updated_at = NOW() WHERE id = $1", id, cost)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SetMaintenanceSLAAlertLevel records the most recent SLA alert sent for a request
+func SetMaintenanceSLAAlertLevel(id int, level string) error {
+	_, err := db.DB.Exec("UPDATE maintenance_requests SET sla_alert_level = $2 WHERE id = $1", id, level)
+	return err
+}
+
+// requireAffected returns sql.ErrNoRows if an update matched no rows
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
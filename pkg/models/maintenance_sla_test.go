@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeSLAStatus(t *testing.T) {
+	policy := MaintenanceSLAPolicy{Priority: "high", ResponseHours: 4, ResolutionHours: 24}
+	opened := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// Fresh request is on track
+	status := ComputeSLAStatus(opened, sql.NullTime{}, sql.NullTime{}, policy, opened.Add(time.Hour))
+	assert.Equal(t, SLAStatusOnTrack, status.Status)
+	assert.Equal(t, opened.Add(4*time.Hour), status.ResponseDueAt)
+	assert.Equal(t, opened.Add(24*time.Hour), status.ResolutionDueAt)
+
+	// 80% of the response window elapsed without a response
+	status = ComputeSLAStatus(opened, sql.NullTime{}, sql.NullTime{}, policy, opened.Add(200*time.Minute))
+	assert.Equal(t, SLAStatusAtRisk, status.ResponseStatus)
+	assert.Equal(t, SLAStatusAtRisk, status.Status)
+
+	// Responded in time but resolution overdue
+	responded := sql.NullTime{Time: opened.Add(time.Hour), Valid: true}
+	status = ComputeSLAStatus(opened, responded, sql.NullTime{}, policy, opened.Add(25*time.Hour))
+	assert.Equal(t, SLAStatusMet, status.ResponseStatus)
+	assert.Equal(t, SLAStatusBreached, status.ResolutionStatus)
+	assert.Equal(t, SLAStatusBreached, status.Status)
+
+	// Resolved in time counts as responded
+	resolved := sql.NullTime{Time: opened.Add(3 * time.Hour), Valid: true}
+	status = ComputeSLAStatus(opened, sql.NullTime{}, resolved, policy, opened.Add(48*time.Hour))
+	assert.Equal(t, SLAStatusMet, status.Status)
+}
+
+func TestSLAAlertLevel(t *testing.T) {
+	atRisk := &SLAStatus{Status: SLAStatusAtRisk}
+	breached := &SLAStatus{Status: SLAStatusBreached}
+	onTrack := &SLAStatus{Status: SLAStatusOnTrack}
+
+	assert.Equal(t, SLAStatusAtRisk, SLAAlertLevel(atRisk, ""))
+	assert.Equal(t, "", SLAAlertLevel(atRisk, SLAStatusAtRisk))
+	assert.Equal(t, SLAStatusBreached, SLAAlertLevel(breached, SLAStatusAtRisk))
+	assert.Equal(t, "", SLAAlertLevel(breached, SLAStatusBreached))
+	assert.Equal(t, "", SLAAlertLevel(onTrack, ""))
+	assert.Equal(t, "", SLAAlertLevel(nil, ""))
+}
@@ -0,0 +1,498 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Market benchmark datasets that can be imported
+const (
+	BenchmarkDatasetMarketRent   = "market_rent"
+	BenchmarkDatasetExpenseRatio = "expense_ratio"
+)
+
+// MarketRentBenchmark is the market rent for a unit size in a zip code
+type MarketRentBenchmark struct {
+	ID         int            `json:"id"`
+	ZipCode    string         `json:"zip_code"`
+	Bedrooms   int            `json:"bedrooms"` // 0 for studios
+	MarketRent float64        `json:"market_rent"`
+	Source     sql.NullString `json:"source,omitempty"`
+	AsOf       sql.NullTime   `json:"as_of,omitempty"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// ExpenseRatioBenchmark is the market operating expense ratio, as a percentage of revenue. An
+// empty ZipCode or PropertyType applies wherever no more specific benchmark exists.
+type ExpenseRatioBenchmark struct {
+	ID           int            `json:"id"`
+	ZipCode      string         `json:"zip_code"`
+	PropertyType string         `json:"property_type"`
+	ExpenseRatio float64        `json:"expense_ratio"`
+	Source       sql.NullString `json:"source,omitempty"`
+	AsOf         sql.NullTime   `json:"as_of,omitempty"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// BenchmarkRowError describes a row of a benchmark import file that could not be used
+type BenchmarkRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// zipCodePattern accepts five digit US zip codes with an optional +4 suffix
+var zipCodePattern = regexp.MustCompile(`^\d{5}(-\d{4})?$`)
+
+// addressZipCode matches the zip code at the end of an address
+var addressZipCode = regexp.MustCompile(`(\d{5})(?:-\d{4})?\s*$`)
+
+// unitTypePattern matches unit types such as "2", "2br", "2 bed" or "2 bedrooms"
+var unitTypePattern = regexp.MustCompile(`^(\d+)\s*(br|bd|bdr|bed|beds|bedroom|bedrooms)?$`)
+
+// ZipCodeFromAddress returns the five digit zip code at the end of an address, or ""
+func ZipCodeFromAddress(address string) string {
+	if m := addressZipCode.FindStringSubmatch(address); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// ParseUnitType converts a unit type such as "studio", "1br" or "2 bedrooms" to a bedroom count
+func ParseUnitType(unitType string) (int, error) {
+	normalized := strings.ToLower(strings.TrimSpace(unitType))
+	if normalized == "studio" || normalized == "efficiency" {
+		return 0, nil
+	}
+	m := unitTypePattern.FindStringSubmatch(normalized)
+	if m == nil {
+		return 0, fmt.Errorf("unit_type %q must be studio or a bedroom count such as 2br", unitType)
+	}
+	return strconv.Atoi(m[1])
+}
+
+// benchmarkColumns indexes a CSV header by lower-cased column name and checks required columns
+func benchmarkColumns(records [][]string, required ...string) (map[string]int, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("header must include a %s column", name)
+		}
+	}
+	return columns, nil
+}
+
+// benchmarkField returns a trimmed field of a record by column name
+func benchmarkField(columns map[string]int, record []string, name string) string {
+	if i, ok := columns[name]; ok && i < len(record) {
+		return strings.TrimSpace(record[i])
+	}
+	return ""
+}
+
+// parseBenchmarkCommon reads the optional source and as_of columns shared by every dataset
+func parseBenchmarkCommon(columns map[string]int, record []string, defaultSource string) (sql.NullString, sql.NullTime, error) {
+	source := benchmarkField(columns, record, "source")
+	if source == "" {
+		source = defaultSource
+	}
+	var asOf sql.NullTime
+	if value := benchmarkField(columns, record, "as_of"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return sql.NullString{}, asOf, fmt.Errorf("as_of must be a date in YYYY-MM-DD format")
+		}
+		asOf = sql.NullTime{Time: parsed, Valid: true}
+	}
+	return NullString(source), asOf, nil
+}
+
+// parseBenchmarkAmount parses a number that may include a currency symbol, thousands separators
+// or a percent sign
+func parseBenchmarkAmount(value string) (float64, error) {
+	cleaned := strings.NewReplacer("$", "", ",", "", "%", "").Replace(value)
+	return strconv.ParseFloat(strings.TrimSpace(cleaned), 64)
+}
+
+// ParseMarketRentRows reads a market rent CSV with zip_code, unit_type (or bedrooms) and
+// market_rent columns and optional source and as_of columns. Rows that cannot be used are
+// returned as errors. defaultSource applies to rows without a source.
+func ParseMarketRentRows(records [][]string, defaultSource string) ([]MarketRentBenchmark, []BenchmarkRowError, error) {
+	columns, err := benchmarkColumns(records, "zip_code", "market_rent")
+	if err != nil {
+		return nil, nil, err
+	}
+	unitColumn := "unit_type"
+	if _, ok := columns[unitColumn]; !ok {
+		unitColumn = "bedrooms"
+		if _, ok := columns[unitColumn]; !ok {
+			return nil, nil, fmt.Errorf("header must include a unit_type or bedrooms column")
+		}
+	}
+
+	var benchmarks []MarketRentBenchmark
+	var rowErrors []BenchmarkRowError
+	seen := map[string]int{}
+	for i, record := range records[1:] {
+		row := i + 2
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		b := MarketRentBenchmark{ZipCode: benchmarkField(columns, record, "zip_code")}
+		if !zipCodePattern.MatchString(b.ZipCode) {
+			rowErrors = append(rowErrors, BenchmarkRowError{Row: row, Error: fmt.Sprintf("zip_code %q is not a valid zip code", b.ZipCode)})
+			continue
+		}
+		b.ZipCode = b.ZipCode[:5]
+		if b.Bedrooms, err = ParseUnitType(benchmarkField(columns, record, unitColumn)); err != nil {
+			rowErrors = append(rowErrors, BenchmarkRowError{Row: row, Error: err.Error()})
+			continue
+		}
+		if b.MarketRent, err = parseBenchmarkAmount(benchmarkField(columns, record, "market_rent")); err != nil || b.MarketRent <= 0 {
+			rowErrors = append(rowErrors, BenchmarkRowError{Row: row, Error: "market_rent must be a positive amount"})
+			continue
+		}
+		if b.Source, b.AsOf, err = parseBenchmarkCommon(columns, record, defaultSource); err != nil {
+			rowErrors = append(rowErrors, BenchmarkRowError{Row: row, Error: err.Error()})
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%d", b.ZipCode, b.Bedrooms)
+		if first, dup := seen[key]; dup {
+			rowErrors = append(rowErrors, BenchmarkRowError{Row: row, Error: fmt.Sprintf("duplicates row %d", first)})
+			continue
+		}
+		seen[key] = row
+		benchmarks = append(benchmarks, b)
+	}
+	return benchmarks, rowErrors, nil
+}
+
+// ParseExpenseRatioRows reads an expense ratio CSV with an expense_ratio column (a percentage of
+// revenue) and optional zip_code, property_type, source and as_of columns
+func ParseExpenseRatioRows(records [][]string, defaultSource string) ([]ExpenseRatioBenchmark, []BenchmarkRowError, error) {
+	columns, err := benchmarkColumns(records, "expense_ratio")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var benchmarks []ExpenseRatioBenchmark
+	var rowErrors []BenchmarkRowError
+	seen := map[string]int{}
+	for i, record := range records[1:] {
+		row := i + 2
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		b := ExpenseRatioBenchmark{
+			ZipCode:      benchmarkField(columns, record, "zip_code"),
+			PropertyType: benchmarkField(columns, record, "property_type"),
+		}
+		if b.ZipCode != "" {
+			if !zipCodePattern.MatchString(b.ZipCode) {
+				rowErrors = append(rowErrors, BenchmarkRowError{Row: row, Error: fmt.Sprintf("zip_code %q is not a valid zip code", b.ZipCode)})
+				continue
+			}
+			b.ZipCode = b.ZipCode[:5]
+		}
+		if b.ExpenseRatio, err = parseBenchmarkAmount(benchmarkField(columns, record, "expense_ratio")); err != nil || b.ExpenseRatio < 0 || b.ExpenseRatio > 100 {
+			rowErrors = append(rowErrors, BenchmarkRowError{Row: row, Error: "expense_ratio must be a percentage from 0 to 100"})
+			continue
+		}
+		if b.Source, b.AsOf, err = parseBenchmarkCommon(columns, record, defaultSource); err != nil {
+			rowErrors = append(rowErrors, BenchmarkRowError{Row: row, Error: err.Error()})
+			continue
+		}
+
+		key := b.ZipCode + "/" + strings.ToLower(b.PropertyType)
+		if first, dup := seen[key]; dup {
+			rowErrors = append(rowErrors, BenchmarkRowError{Row: row, Error: fmt.Sprintf("duplicates row %d", first)})
+			continue
+		}
+		seen[key] = row
+		benchmarks = append(benchmarks, b)
+	}
+	return benchmarks, rowErrors, nil
+}
+
+// ImportMarketRentBenchmarks upserts market rents in one transaction. With replace set the
+// existing dataset is cleared first.
+func ImportMarketRentBenchmarks(benchmarks []MarketRentBenchmark, replace bool) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err := tx.Exec("DELETE FROM market_rent_benchmarks"); err != nil {
+			return err
+		}
+	}
+	for i := range benchmarks {
+		b := &benchmarks[i]
+		err := tx.QueryRow(`
+			INSERT INTO market_rent_benchmarks (zip_code, bedrooms, market_rent, source, as_of)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (zip_code, bedrooms) DO UPDATE
+			SET market_rent = EXCLUDED.market_rent, source = EXCLUDED.source, as_of = EXCLUDED.as_of, updated_at = NOW()
+			RETURNING id, updated_at`,
+			b.ZipCode, b.Bedrooms, b.MarketRent, b.Source, b.AsOf).Scan(&b.ID, &b.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ImportExpenseRatioBenchmarks upserts expense ratios in one transaction. With replace set the
+// existing dataset is cleared first.
+func ImportExpenseRatioBenchmarks(benchmarks []ExpenseRatioBenchmark, replace bool) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err := tx.Exec("DELETE FROM expense_ratio_benchmarks"); err != nil {
+			return err
+		}
+	}
+	for i := range benchmarks {
+		b := &benchmarks[i]
+		err := tx.QueryRow(`
+			INSERT INTO expense_ratio_benchmarks (zip_code, property_type, expense_ratio, source, as_of)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (zip_code, property_type) DO UPDATE
+			SET expense_ratio = EXCLUDED.expense_ratio, source = EXCLUDED.source, as_of = EXCLUDED.as_of, updated_at = NOW()
+			RETURNING id, updated_at`,
+			b.ZipCode, b.PropertyType, b.ExpenseRatio, b.Source, b.AsOf).Scan(&b.ID, &b.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetMarketRentBenchmarks returns the imported market rents
+func GetMarketRentBenchmarks() ([]MarketRentBenchmark, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT id, zip_code, bedrooms, market_rent, source, as_of, updated_at
+		FROM market_rent_benchmarks ORDER BY zip_code, bedrooms`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var benchmarks []MarketRentBenchmark
+	for rows.Next() {
+		var b MarketRentBenchmark
+		if err := rows.Scan(&b.ID, &b.ZipCode, &b.Bedrooms, &b.MarketRent, &b.Source, &b.AsOf, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		benchmarks = append(benchmarks, b)
+	}
+	return benchmarks, rows.Err()
+}
+
+// GetExpenseRatioBenchmarks returns the imported expense ratios
+func GetExpenseRatioBenchmarks() ([]ExpenseRatioBenchmark, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT id, zip_code, property_type, expense_ratio, source, as_of, updated_at
+		FROM expense_ratio_benchmarks ORDER BY zip_code, property_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var benchmarks []ExpenseRatioBenchmark
+	for rows.Next() {
+		var b ExpenseRatioBenchmark
+		if err := rows.Scan(&b.ID, &b.ZipCode, &b.PropertyType, &b.ExpenseRatio, &b.Source, &b.AsOf, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		benchmarks = append(benchmarks, b)
+	}
+	return benchmarks, rows.Err()
+}
+
+// MarketBenchmarks looks up imported benchmarks while a report is built
+type MarketBenchmarks struct {
+	rents  map[string]float64 // By "zip/bedrooms"
+	ratios map[string]float64 // By "zip/property type", either part possibly empty
+}
+
+// LoadMarketBenchmarks reads both benchmark datasets
+func LoadMarketBenchmarks() (*MarketBenchmarks, error) {
+	rents, err := GetMarketRentBenchmarks()
+	if err != nil {
+		return nil, err
+	}
+	ratios, err := GetExpenseRatioBenchmarks()
+	if err != nil {
+		return nil, err
+	}
+	return NewMarketBenchmarks(rents, ratios), nil
+}
+
+// NewMarketBenchmarks indexes benchmark datasets for lookup
+func NewMarketBenchmarks(rents []MarketRentBenchmark, ratios []ExpenseRatioBenchmark) *MarketBenchmarks {
+	m := &MarketBenchmarks{rents: map[string]float64{}, ratios: map[string]float64{}}
+	for _, b := range rents {
+		m.rents[fmt.Sprintf("%s/%d", b.ZipCode, b.Bedrooms)] = b.MarketRent
+	}
+	for _, b := range ratios {
+		m.ratios[b.ZipCode+"/"+strings.ToLower(b.PropertyType)] = b.ExpenseRatio
+	}
+	return m
+}
+
+// Empty reports whether no benchmarks have been imported
+func (m *MarketBenchmarks) Empty() bool {
+	return m == nil || len(m.rents) == 0 && len(m.ratios) == 0
+}
+
+// MarketRent returns the market rent for a unit size in a zip code
+func (m *MarketBenchmarks) MarketRent(zipCode string, bedrooms int) (float64, bool) {
+	if m == nil || zipCode == "" {
+		return 0, false
+	}
+	rent, ok := m.rents[fmt.Sprintf("%s/%d", zipCode, bedrooms)]
+	return rent, ok
+}
+
+// ExpenseRatio returns the most specific expense ratio benchmark for a property: its zip code
+// and type, then its zip code, then its type, then the market-wide ratio
+func (m *MarketBenchmarks) ExpenseRatio(zipCode, propertyType string) (float64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	propertyType = strings.ToLower(propertyType)
+	for _, key := range []string{zipCode + "/" + propertyType, zipCode + "/", "/" + propertyType, "/"} {
+		if ratio, ok := m.ratios[key]; ok {
+			return ratio, true
+		}
+	}
+	return 0, false
+}
+
+// VarianceToMarket returns how far actual is above (positive) or below market, as a percentage
+// of market rounded to two decimal places
+func VarianceToMarket(actual, market float64) float64 {
+	if market == 0 {
+		return 0
+	}
+	return math.Round((actual-market)/market*10000) / 100
+}
+
+// attachMarketComparisons sets the market rent and expense ratio benchmarks of the compared
+// properties, with the rent and utility costs they are measured against. It does nothing when
+// no benchmarks have been imported.
+func attachMarketComparisons(properties []PropertyComparison, index map[int]int, startDate, endDate time.Time, propertyIDs []interface{}) error {
+	benchmarks, err := LoadMarketBenchmarks()
+	if err != nil || benchmarks.Empty() {
+		return err
+	}
+
+	for i := range properties {
+		p := &properties[i]
+		if ratio, ok := benchmarks.ExpenseRatio(p.ZipCode, p.PropertyType); ok {
+			p.MarketExpenseRatio = &ratio
+		}
+	}
+
+	expenses, err := GetUtilityExpenses(PropertyFilter{}, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	for _, e := range expenses {
+		if i, ok := index[e.PropertyID]; ok {
+			properties[i].UtilityCost += ProrateExpense(e, startDate, endDate)
+		}
+	}
+
+	query := `
+		SELECT pu.property_id, pu.bedrooms, l.monthly_rent
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		WHERE l.status IN ('active', 'ended') AND l.start_date <= $1 AND l.end_date >= $1`
+	args := []interface{}{endDate}
+	if len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+1)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND pu.property_id IN (%s)", strings.Join(placeholders, ", "))
+	}
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var propertyID, bedrooms int
+		var rent float64
+		if err := rows.Scan(&propertyID, &bedrooms, &rent); err != nil {
+			return err
+		}
+		i, ok := index[propertyID]
+		if !ok {
+			continue
+		}
+		if market, ok := benchmarks.MarketRent(properties[i].ZipCode, bedrooms); ok {
+			properties[i].BenchmarkedRent += rent
+			properties[i].MarketRent += market
+		}
+	}
+	return rows.Err()
+}
+
+// addMarketComparisonColumns adds rent and expense ratio variance-to-market columns to a
+// portfolio comparison when any property has a benchmark. rows are in the order of properties.
+func addMarketComparisonColumns(data *ReportData, rows []map[string]interface{}, properties []PropertyComparison) {
+	var hasRent, hasRatio bool
+	for i, p := range properties {
+		if p.MarketRent > 0 {
+			hasRent = true
+			rows[i]["Rent vs Market %"] = VarianceToMarket(p.BenchmarkedRent, p.MarketRent)
+		}
+		if p.MarketExpenseRatio != nil {
+			hasRatio = true
+			rows[i]["Market Expense Ratio %"] = *p.MarketExpenseRatio
+			if ratio, ok := p.ExpenseRatio(); ok {
+				rows[i]["Expense Ratio %"] = ratio
+				rows[i]["Expense Ratio vs Market"] = math.Round((ratio-*p.MarketExpenseRatio)*100) / 100
+			}
+		}
+	}
+
+	if hasRent {
+		data.Headers = append(data.Headers, "Rent vs Market %")
+		var rent, market float64
+		for _, p := range properties {
+			rent += p.BenchmarkedRent
+			market += p.MarketRent
+		}
+		data.Summary["rent_vs_market_percent"] = VarianceToMarket(rent, market)
+	}
+	if hasRatio {
+		data.Headers = append(data.Headers, "Expense Ratio %", "Market Expense Ratio %", "Expense Ratio vs Market")
+	}
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUnitType(t *testing.T) {
+	for input, bedrooms := range map[string]int{"Studio": 0, "0": 0, "1br": 1, "2 bed": 2, "3 Bedrooms": 3, " 4BD ": 4} {
+		got, err := ParseUnitType(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, bedrooms, got, input)
+	}
+	_, err := ParseUnitType("penthouse")
+	assert.Error(t, err)
+
+	assert.Equal(t, "62701", ZipCodeFromAddress("12 Elm St, Springfield, IL 62701-1234"))
+	assert.Equal(t, "", ZipCodeFromAddress("Somewhere rural"))
+}
+
+func TestParseMarketRentRows(t *testing.T) {
+	records := [][]string{
+		{"Zip_Code", "Unit_Type", "Market_Rent", "As_Of"},
+		{"62701", "studio", "$950", "2026-09-30"},
+		{"62701-1234", "2br", "1,475.50", ""},
+		{"", "", "", ""},
+		{"6270", "1br", "1100", ""},
+		{"62702", "1br", "free", ""},
+		{"62701", "2 bed", "1500", ""},
+	}
+
+	benchmarks, rowErrors, err := ParseMarketRentRows(records, "RentData")
+	require.NoError(t, err)
+	require.Len(t, benchmarks, 2)
+	assert.Equal(t, MarketRentBenchmark{ZipCode: "62701", Bedrooms: 2, MarketRent: 1475.5, Source: NullString("RentData")}, benchmarks[1])
+	assert.Equal(t, time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC), benchmarks[0].AsOf.Time)
+	assert.Equal(t, []BenchmarkRowError{
+		{Row: 5, Error: `zip_code "6270" is not a valid zip code`},
+		{Row: 6, Error: "market_rent must be a positive amount"},
+		{Row: 7, Error: "duplicates row 3"},
+	}, rowErrors)
+
+	_, _, err = ParseMarketRentRows([][]string{{"zip_code", "market_rent"}}, "")
+	assert.ErrorContains(t, err, "unit_type or bedrooms")
+
+	ratios, rowErrors, err := ParseExpenseRatioRows([][]string{
+		{"property_type", "expense_ratio"}, {"", "38%"}, {"Apartment Building", "41.5"}, {"Duplex", "140"},
+	}, "")
+	require.NoError(t, err)
+	assert.Len(t, ratios, 2)
+	assert.Equal(t, 38.0, ratios[0].ExpenseRatio)
+	assert.Equal(t, []BenchmarkRowError{{Row: 4, Error: "expense_ratio must be a percentage from 0 to 100"}}, rowErrors)
+}
+
+func TestMarketBenchmarksLookup(t *testing.T) {
+	benchmarks := NewMarketBenchmarks(
+		[]MarketRentBenchmark{{ZipCode: "62701", Bedrooms: 1, MarketRent: 1200}},
+		[]ExpenseRatioBenchmark{
+			{ExpenseRatio: 40},
+			{PropertyType: "Apartment Building", ExpenseRatio: 45},
+			{ZipCode: "62701", ExpenseRatio: 35},
+		})
+
+	rent, ok := benchmarks.MarketRent("62701", 1)
+	assert.True(t, ok)
+	assert.Equal(t, 1200.0, rent)
+	_, ok = benchmarks.MarketRent("62701", 2)
+	assert.False(t, ok)
+
+	ratio, _ := benchmarks.ExpenseRatio("62701", "Apartment Building")
+	assert.Equal(t, 35.0, ratio, "zip code is more specific than property type")
+	ratio, _ = benchmarks.ExpenseRatio("10001", "apartment building")
+	assert.Equal(t, 45.0, ratio)
+	ratio, _ = benchmarks.ExpenseRatio("", "Duplex")
+	assert.Equal(t, 40.0, ratio)
+
+	assert.True(t, NewMarketBenchmarks(nil, nil).Empty())
+	assert.Equal(t, -10.0, VarianceToMarket(1080, 1200))
+}
+
+func TestPortfolioComparisonMarketColumns(t *testing.T) {
+	ratio := 40.0
+	properties := []PropertyComparison{
+		{PropertyID: 1, Name: "Oak", Units: 2, UnitMonths: 6, OccupiedUnitMonths: 6, Revenue: 6000, RentDue: 6000,
+			MaintenanceCost: 1500, UtilityCost: 900, BenchmarkedRent: 2100, MarketRent: 2000, MarketExpenseRatio: &ratio},
+		{PropertyID: 2, Name: "Pine", Units: 2, UnitMonths: 6, OccupiedUnitMonths: 3, Revenue: 3000, RentDue: 3000},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	data := buildPortfolioComparison(properties, ComparisonKPIs, start, end)
+
+	assert.Equal(t, "Overall Rank", data.Headers[len(data.Headers)-1])
+	assert.Contains(t, data.Headers, "Rent vs Market %")
+	assert.Contains(t, data.Headers, "Expense Ratio vs Market")
+	assert.Equal(t, "Oak", data.Rows[0]["Property"])
+	assert.Equal(t, 5.0, data.Rows[0]["Rent vs Market %"])
+	assert.Equal(t, 40.0, data.Rows[0]["Expense Ratio %"])
+	assert.Equal(t, 0.0, data.Rows[0]["Expense Ratio vs Market"])
+	assert.NotContains(t, data.Rows[1], "Rent vs Market %")
+	assert.Equal(t, 5.0, data.Summary["rent_vs_market_percent"])
+}
+
+func TestImportMarketRentBenchmarks(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM market_rent_benchmarks`).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery(`INSERT INTO market_rent_benchmarks`).
+		WithArgs("62701", 1, 1200.0, NullString("RentData"), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "updated_at"}).AddRow(9, time.Now()))
+	mock.ExpectCommit()
+
+	benchmarks := []MarketRentBenchmark{{ZipCode: "62701", Bedrooms: 1, MarketRent: 1200, Source: NullString("RentData")}}
+	require.NoError(t, ImportMarketRentBenchmarks(benchmarks, true))
+	assert.Equal(t, 9, benchmarks[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -5,11 +5,11 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"encoding/base64"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/greenbrown932/fire-pmaas/pkg/db"
-	"github.com/lib/pq"
 	"github.com/pquerna/otp/totp"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -33,6 +33,7 @@ type PropertyUnit struct {
 	Bedrooms    int
 	Bathrooms   int
 	Description string
+	MarketRent  sql.NullFloat64 // Current market rent, used for rent increase planning
 	CreatedAt   time.Time
 	UpdatedAt   time.Time // Time the property unit was last updated
 }
@@ -51,15 +52,20 @@ type Tenant struct {
 
 // Lease connects a tenant to a specific unit for a period of time.
 type Lease struct {
-	ID          int // Unique identifier for the lease
-	UnitID      int
-	TenantID    int
-	StartDate   time.Time
-	EndDate     time.Time
-	MonthlyRent float64
-	Status      string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time // Time the lease was last updated
+	ID                   int // Unique identifier for the lease
+	UnitID               int
+	TenantID             int
+	StartDate            time.Time
+	EndDate              time.Time
+	MonthlyRent          float64
+	Status               string
+	NoticeGivenDate      sql.NullTime // Set once the tenant has given notice to vacate
+	SecurityDeposit      sql.NullFloat64
+	DepositReceivedDate  sql.NullTime
+	DepositEscrowAccount sql.NullString // Account the deposit is held in, if escrowed
+	ProrationConvention  string         // Day-count convention for partly covered first and last months
+	CreatedAt            time.Time
+	UpdatedAt            time.Time // Time the lease was last updated
 }
 
 // PropertyDetail represents a detailed view of a property, including lease and tenant information.
@@ -146,7 +152,7 @@ func (a StringArray) Value() (driver.Value, error) {
 	if len(a) == 0 {
 		return nil, nil
 	}
-	return pq.Array(a).Value()
+	return db.CurrentDialect.ArrayValue(a)
 }
 
 // Scan implements the sql.Scanner interface for database retrieval
@@ -155,7 +161,42 @@ func (a *StringArray) Scan(value interface{}) error {
 		*a = nil
 		return nil
 	}
-	return pq.Array(a).Scan(value)
+	values, err := db.CurrentDialect.ScanArray(value)
+	if err != nil {
+		return err
+	}
+	*a = values
+	return nil
+}
+
+// flexibleTime scans timestamps returned either as time.Time (PostgreSQL) or as
+// text (SQLite expressions such as strftime carry no column type)
+type flexibleTime struct {
+	time.Time
+}
+
+// Scan implements the sql.Scanner interface for database retrieval
+func (t *flexibleTime) Scan(value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		t.Time = v
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into time", value)
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse %q as time", text)
 }
 
 // UserRegistration represents the data needed for user registration
@@ -184,8 +225,13 @@ type PasswordReset struct {
 }
 
 // CreateProperty creates a new property in the database.
-func CreateProperty(property *Property) error {
-	_, err := db.DB.Exec(`
+func (sqlRepository) CreateProperty(property *Property) error {
+	return InsertProperty(db.DB, property)
+}
+
+// InsertProperty inserts a property using the given querier, so imports can run in a transaction.
+func InsertProperty(q Querier, property *Property) error {
+	_, err := q.Exec(`
 		INSERT INTO properties (name, address, property_type)
 		VALUES ($1, $2, $3)
 	`, property.Name, property.Address, property.PropertyType)
@@ -193,7 +239,7 @@ func CreateProperty(property *Property) error {
 }
 
 // UpdateProperty updates an existing property in the database.
-func UpdateProperty(property *Property) error {
+func (sqlRepository) UpdateProperty(property *Property) error {
 	_, err := db.DB.Exec(`
 		UPDATE properties
 		SET name = $1, address = $2, property_type = $3
@@ -203,7 +249,7 @@ func UpdateProperty(property *Property) error {
 }
 
 // DeleteProperty deletes a property from the database.
-func DeleteProperty(id int) error {
+func (sqlRepository) DeleteProperty(id int) error {
 	_, err := db.DB.Exec(`
 		DELETE FROM properties
 		WHERE id = $1
@@ -212,7 +258,7 @@ func DeleteProperty(id int) error {
 }
 
 // GetProperties retrieves a list of properties with details including address, rent, status, and tenant name.
-func GetProperties() ([]PropertyDetail, error) {
+func (sqlRepository) GetProperties() ([]PropertyDetail, error) {
 	// Execute the SQL query to retrieve property details.
 	rows, err := db.DB.Query(`
 		SELECT
@@ -244,10 +290,37 @@ func GetProperties() ([]PropertyDetail, error) {
 	return properties, nil
 }
 
+// propertyListingTables are the tables the property listings are built from
+var propertyListingTables = []string{"properties", "property_units", "leases", "tenants", "property_groups"}
+
+// GetPropertiesVersion returns when the data behind the property listings last changed and how
+// many rows it spans, so that deletions also change the version.
+func GetPropertiesVersion() (time.Time, int, error) {
+	var lastModified time.Time
+	for _, table := range propertyListingTables {
+		var updatedAt time.Time
+		err := db.DB.QueryRow("SELECT updated_at FROM " + table +
+			" WHERE updated_at IS NOT NULL ORDER BY updated_at DESC LIMIT 1").Scan(&updatedAt)
+		if err != nil && err != sql.ErrNoRows {
+			return time.Time{}, 0, err
+		}
+		if updatedAt.After(lastModified) {
+			lastModified = updatedAt
+		}
+	}
+
+	var rowCount int
+	err := db.DB.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM properties) + (SELECT COUNT(*) FROM property_units) +
+			   (SELECT COUNT(*) FROM leases) + (SELECT COUNT(*) FROM tenants) +
+			   (SELECT COUNT(*) FROM property_groups)`).Scan(&rowCount)
+	return lastModified, rowCount, err
+}
+
 // GetPropertiesByTags retrieves a list of properties with details including address, rent, status, and tenant name, filtered by tags.
-func GetPropertiesByTags(tags []string) ([]PropertyDetail, error) {
+func (sqlRepository) GetPropertiesByTags(tags []string) ([]PropertyDetail, error) {
 	// Execute the SQL query to retrieve property details.
-	rows, err := db.DB.Query(`
+	rows, err := db.DB.Query(fmt.Sprintf(`
 		SELECT
 			p.id,                             -- Property ID
 			p.address,                        -- Property Address
@@ -260,8 +333,8 @@ func GetPropertiesByTags(tags []string) ([]PropertyDetail, error) {
 		LEFT JOIN property_units pu ON p.id = pu.property_id   -- Join with property_units table
 		LEFT JOIN leases l ON pu.id = l.unit_id AND l.status = 'active' -- Only active leases
 		LEFT JOIN tenants t ON l.tenant_id = t.id             -- Join with tenants table
-		WHERE p.tags @> $1                                  -- Filter by tags
-	`, pq.Array(tags))
+		WHERE %s                                  -- Filter by tags
+	`, db.CurrentDialect.ArrayContainsAll("p.tags", "$1")), StringArray(tags))
 	if err != nil {
 		return nil, err
 	}
@@ -319,7 +392,7 @@ func ValidateMFACode(secret, code string) bool {
 }
 
 // CreateUser creates a new user in the database
-func CreateUser(user *User) error {
+func (sqlRepository) CreateUser(user *User) error {
 	query := `
 		INSERT INTO users (keycloak_id, username, email, first_name, last_name, phone_number,
 						   profile_picture_url, email_verified, mfa_enabled, mfa_secret, status)
@@ -332,7 +405,7 @@ func CreateUser(user *User) error {
 }
 
 // GetUserByID retrieves a user by their ID
-func GetUserByID(id int) (*User, error) {
+func (sqlRepository) GetUserByID(id int) (*User, error) {
 	user := &User{}
 	query := `
 		SELECT id, keycloak_id, username, email, first_name, last_name, phone_number,
@@ -360,7 +433,7 @@ func GetUserByID(id int) (*User, error) {
 }
 
 // GetUserByEmail retrieves a user by their email
-func GetUserByEmail(email string) (*User, error) {
+func (sqlRepository) GetUserByEmail(email string) (*User, error) {
 	user := &User{}
 	query := `
 		SELECT id, keycloak_id, username, email, first_name, last_name, phone_number,
@@ -388,7 +461,7 @@ func GetUserByEmail(email string) (*User, error) {
 }
 
 // GetUserByUsername retrieves a user by their username
-func GetUserByUsername(username string) (*User, error) {
+func (sqlRepository) GetUserByUsername(username string) (*User, error) {
 	user := &User{}
 	query := `
 		SELECT id, keycloak_id, username, email, first_name, last_name, phone_number,
@@ -416,7 +489,7 @@ func GetUserByUsername(username string) (*User, error) {
 }
 
 // UpdateUser updates user information in the database
-func UpdateUser(user *User) error {
+func (sqlRepository) UpdateUser(user *User) error {
 	query := `
 		UPDATE users SET username = $1, email = $2, first_name = $3, last_name = $4,
 					     phone_number = $5, profile_picture_url = $6, email_verified = $7,
@@ -432,19 +505,18 @@ func UpdateUser(user *User) error {
 	return err
 }
 
-// DeleteUser deletes a user from the database
-func DeleteUser(id int) error {
-	_, err := db.DB.Exec("DELETE FROM users WHERE id = $1", id)
-	return err
+// DeleteUser soft-deletes a user and records who deleted them in the audit log
+func (sqlRepository) DeleteUser(id int, actorID sql.NullInt32, reason string) error {
+	return deleteUser(id, actorID, reason)
 }
 
 // GetUserRoles retrieves all roles for a specific user
-func GetUserRoles(userID int) ([]Role, error) {
+func (sqlRepository) GetUserRoles(userID int) ([]Role, error) {
 	query := `
 		SELECT r.id, r.name, r.display_name, r.description, r.permissions, r.created_at, r.updated_at
 		FROM roles r
 		JOIN user_roles ur ON r.id = ur.role_id
-		WHERE ur.user_id = $1
+		WHERE ur.user_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
 		ORDER BY r.name`
 
 	rows, err := db.DB.Query(query, userID)
@@ -497,21 +569,19 @@ func (u *User) HasRole(roleName string) bool {
 }
 
 // AssignRole assigns a role to a user
-func AssignRole(userID, roleID int, assignedBy *int) error {
-	query := `INSERT INTO user_roles (user_id, role_id, assigned_by) VALUES ($1, $2, $3)`
-	_, err := db.DB.Exec(query, userID, roleID, assignedBy)
-	return err
+func (sqlRepository) AssignRole(userID, roleID int, assignedBy *int) error {
+	return AssignRoleUntil(userID, roleID, assignedBy, sql.NullTime{})
 }
 
 // RemoveRole removes a role from a user
-func RemoveRole(userID, roleID int) error {
+func (sqlRepository) RemoveRole(userID, roleID int) error {
 	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`
 	_, err := db.DB.Exec(query, userID, roleID)
 	return err
 }
 
 // GetAllRoles retrieves all available roles
-func GetAllRoles() ([]Role, error) {
+func (sqlRepository) GetAllRoles() ([]Role, error) {
 	query := `SELECT id, name, display_name, description, permissions, created_at, updated_at FROM roles ORDER BY name`
 
 	rows, err := db.DB.Query(query)
@@ -535,7 +605,7 @@ func GetAllRoles() ([]Role, error) {
 }
 
 // GetRoleByName retrieves a role by its name
-func GetRoleByName(name string) (*Role, error) {
+func (sqlRepository) GetRoleByName(name string) (*Role, error) {
 	role := &Role{}
 	query := `SELECT id, name, display_name, description, permissions, created_at, updated_at FROM roles WHERE name = $1`
 
@@ -583,7 +653,7 @@ func CleanupExpiredSessions() error {
 }
 
 // GetUserByKeycloakID retrieves a user by their Keycloak ID
-func GetUserByKeycloakID(keycloakID string) (*User, error) {
+func (sqlRepository) GetUserByKeycloakID(keycloakID string) (*User, error) {
 	user := &User{}
 	query := `
 		SELECT id, keycloak_id, username, email, first_name, last_name, phone_number,
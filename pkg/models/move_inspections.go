@@ -0,0 +1,442 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Lease inspection types
+const (
+	InspectionMoveIn  = "move_in"
+	InspectionMoveOut = "move_out"
+)
+
+// ItemConditions lists the accepted checklist conditions, best first
+var ItemConditions = []string{"excellent", "good", "fair", "poor", "damaged", "missing"}
+
+var (
+	// ErrInspectionCompleted is returned when changing a checklist that has been completed
+	ErrInspectionCompleted = errors.New("inspection has been completed and can no longer be changed")
+	// ErrMoveOutIncomplete is returned when charging damages before the move-out inspection is completed
+	ErrMoveOutIncomplete = errors.New("the move-out inspection must be completed before damages are charged")
+	// ErrChargeItemMismatch is returned when a damage charge references an item outside the lease's move-out checklist
+	ErrChargeItemMismatch = errors.New("inspection item is not on this lease's move-out checklist")
+)
+
+// ValidInspectionType reports whether t is a known inspection type
+func ValidInspectionType(t string) bool {
+	return t == InspectionMoveIn || t == InspectionMoveOut
+}
+
+// conditionRank orders conditions so that a lower rank is worse
+func conditionRank(condition string) int {
+	for i, c := range ItemConditions {
+		if c == condition {
+			return len(ItemConditions) - i
+		}
+	}
+	return 0
+}
+
+// ValidItemCondition reports whether condition is one of ItemConditions
+func ValidItemCondition(condition string) bool {
+	return conditionRank(condition) > 0
+}
+
+// LeaseInspection is a move-in or move-out condition checklist for a lease
+type LeaseInspection struct {
+	ID             int              `json:"id"`
+	LeaseID        int              `json:"lease_id"`
+	InspectionType string           `json:"inspection_type"`
+	InspectionDate time.Time        `json:"inspection_date"`
+	Notes          sql.NullString   `json:"notes,omitempty"`
+	InspectedBy    sql.NullInt32    `json:"inspected_by,omitempty"`
+	CompletedAt    sql.NullTime     `json:"completed_at,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+	Items          []InspectionItem `json:"items"`
+}
+
+// InspectionItem is the recorded condition of one item in one area of the unit
+type InspectionItem struct {
+	ID           int            `json:"id"`
+	InspectionID int            `json:"inspection_id"`
+	Area         string         `json:"area"`
+	Item         string         `json:"item"`
+	Condition    string         `json:"condition"`
+	Notes        sql.NullString `json:"notes,omitempty"`
+}
+
+// key matches items between checklists, ignoring case and surrounding space
+func (i InspectionItem) key() string {
+	return strings.ToLower(strings.TrimSpace(i.Area)) + "\x00" + strings.ToLower(strings.TrimSpace(i.Item))
+}
+
+// SaveLeaseInspection creates or replaces a lease's checklist of inspection.InspectionType, including
+// its items. It returns sql.ErrNoRows if the lease does not exist and ErrInspectionCompleted if the
+// checklist has already been completed.
+func SaveLeaseInspection(inspection *LeaseInspection) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow("SELECT 1 FROM leases WHERE id = $1", inspection.LeaseID).Scan(&exists); err != nil {
+		return err
+	}
+
+	// The unique (lease_id, inspection_type) constraint keeps concurrent saves to one checklist, and
+	// a completed checklist is left unchanged, returning no row
+	err = tx.QueryRow(`
+		INSERT INTO lease_inspections (lease_id, inspection_type, inspection_date, notes, inspected_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (lease_id, inspection_type) DO UPDATE
+		SET inspection_date = EXCLUDED.inspection_date, notes = EXCLUDED.notes,
+			inspected_by = EXCLUDED.inspected_by, updated_at = NOW()
+		WHERE lease_inspections.completed_at IS NULL
+		RETURNING id, created_at, updated_at`,
+		inspection.LeaseID, inspection.InspectionType, inspection.InspectionDate, inspection.Notes,
+		inspection.InspectedBy).Scan(&inspection.ID, &inspection.CreatedAt, &inspection.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrInspectionCompleted
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM lease_inspection_items WHERE inspection_id = $1", inspection.ID); err != nil {
+		return err
+	}
+	for i := range inspection.Items {
+		item := &inspection.Items[i]
+		item.InspectionID = inspection.ID
+		err := tx.QueryRow(`
+			INSERT INTO lease_inspection_items (inspection_id, area, item, condition, notes)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id`,
+			item.InspectionID, item.Area, item.Item, item.Condition, item.Notes).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CompleteLeaseInspection locks a checklist against further changes. Completing it again has no effect.
+func CompleteLeaseInspection(leaseID int, inspectionType string) error {
+	result, err := db.DB.Exec(`
+		UPDATE lease_inspections SET completed_at = COALESCE(completed_at, NOW())
+		WHERE lease_id = $1 AND inspection_type = $2`, leaseID, inspectionType)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetLeaseInspections retrieves a lease's checklists with their items, move-in first
+func GetLeaseInspections(leaseID int) ([]LeaseInspection, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, lease_id, inspection_type, inspection_date, notes, inspected_by, completed_at, created_at, updated_at
+		FROM lease_inspections
+		WHERE lease_id = $1
+		ORDER BY inspection_date, id`, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inspections []LeaseInspection
+	for rows.Next() {
+		var in LeaseInspection
+		err := rows.Scan(&in.ID, &in.LeaseID, &in.InspectionType, &in.InspectionDate, &in.Notes, &in.InspectedBy,
+			&in.CompletedAt, &in.CreatedAt, &in.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		in.Items = []InspectionItem{}
+		inspections = append(inspections, in)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range inspections {
+		items, err := getInspectionItems(inspections[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		inspections[i].Items = append(inspections[i].Items, items...)
+	}
+	return inspections, nil
+}
+
+func getInspectionItems(inspectionID int) ([]InspectionItem, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, inspection_id, area, item, condition, notes
+		FROM lease_inspection_items
+		WHERE inspection_id = $1
+		ORDER BY id`, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []InspectionItem
+	for rows.Next() {
+		var item InspectionItem
+		if err := rows.Scan(&item.ID, &item.InspectionID, &item.Area, &item.Item, &item.Condition, &item.Notes); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ConditionComparison is one checklist item's condition at move-in against move-out
+type ConditionComparison struct {
+	Area             string         `json:"area"`
+	Item             string         `json:"item"`
+	MoveInCondition  string         `json:"move_in_condition,omitempty"`  // Empty when not on the move-in checklist
+	MoveOutCondition string         `json:"move_out_condition,omitempty"` // Empty when not on the move-out checklist
+	MoveOutItemID    sql.NullInt32  `json:"move_out_item_id,omitempty"`
+	MoveOutNotes     sql.NullString `json:"move_out_notes,omitempty"`
+	Worsened         bool           `json:"worsened"`
+}
+
+// CompareInspections lines up the move-out checklist against the move-in one, flagging items whose
+// condition got worse. Move-in items not checked at move-out are listed last. Either checklist may be nil.
+func CompareInspections(moveIn, moveOut *LeaseInspection) []ConditionComparison {
+	comparisons := []ConditionComparison{}
+	baseline := map[string]InspectionItem{}
+	if moveIn != nil {
+		for _, item := range moveIn.Items {
+			baseline[item.key()] = item
+		}
+	}
+
+	seen := map[string]bool{}
+	if moveOut != nil {
+		for _, item := range moveOut.Items {
+			c := ConditionComparison{
+				Area:             item.Area,
+				Item:             item.Item,
+				MoveOutCondition: item.Condition,
+				MoveOutItemID:    sql.NullInt32{Int32: int32(item.ID), Valid: true},
+				MoveOutNotes:     item.Notes,
+			}
+			if before, ok := baseline[item.key()]; ok {
+				c.MoveInCondition = before.Condition
+				c.Worsened = conditionRank(item.Condition) < conditionRank(before.Condition)
+			}
+			seen[item.key()] = true
+			comparisons = append(comparisons, c)
+		}
+	}
+
+	if moveIn != nil {
+		for _, item := range moveIn.Items {
+			if !seen[item.key()] {
+				comparisons = append(comparisons, ConditionComparison{
+					Area: item.Area, Item: item.Item, MoveInCondition: item.Condition,
+				})
+			}
+		}
+	}
+	return comparisons
+}
+
+// DamageCharge is an itemized move-out charge deducted from the security deposit
+type DamageCharge struct {
+	ID               int           `json:"id"`
+	LeaseID          int           `json:"lease_id"`
+	InspectionItemID sql.NullInt32 `json:"inspection_item_id,omitempty"`
+	Description      string        `json:"description"`
+	Amount           float64       `json:"amount"`
+	CreatedBy        sql.NullInt32 `json:"created_by,omitempty"`
+	CreatedAt        time.Time     `json:"created_at"`
+}
+
+// CreateDamageCharge records a charge against a lease whose move-out inspection is completed.
+// It returns sql.ErrNoRows if the lease has no move-out inspection.
+func CreateDamageCharge(c *DamageCharge) error {
+	var inspectionID int
+	var completedAt sql.NullTime
+	err := db.DB.QueryRow(`
+		SELECT id, completed_at FROM lease_inspections WHERE lease_id = $1 AND inspection_type = $2`,
+		c.LeaseID, InspectionMoveOut).Scan(&inspectionID, &completedAt)
+	if err != nil {
+		return err
+	}
+	if !completedAt.Valid {
+		return ErrMoveOutIncomplete
+	}
+
+	if c.InspectionItemID.Valid {
+		var exists int
+		err := db.DB.QueryRow("SELECT 1 FROM lease_inspection_items WHERE id = $1 AND inspection_id = $2",
+			c.InspectionItemID, inspectionID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return ErrChargeItemMismatch
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.DB.QueryRow(`
+		INSERT INTO lease_damage_charges (lease_id, inspection_item_id, description, amount, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		c.LeaseID, c.InspectionItemID, c.Description, c.Amount, c.CreatedBy).Scan(&c.ID, &c.CreatedAt)
+}
+
+// DeleteDamageCharge removes a charge from a lease
+func DeleteDamageCharge(leaseID, chargeID int) error {
+	result, err := db.DB.Exec("DELETE FROM lease_damage_charges WHERE id = $1 AND lease_id = $2", chargeID, leaseID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetDamageCharges retrieves a lease's move-out charges in the order they were recorded
+func GetDamageCharges(leaseID int) ([]DamageCharge, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, lease_id, inspection_item_id, description, amount, created_by, created_at
+		FROM lease_damage_charges
+		WHERE lease_id = $1
+		ORDER BY id`, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var charges []DamageCharge
+	for rows.Next() {
+		var c DamageCharge
+		err := rows.Scan(&c.ID, &c.LeaseID, &c.InspectionItemID, &c.Description, &c.Amount, &c.CreatedBy, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		charges = append(charges, c)
+	}
+	return charges, rows.Err()
+}
+
+// DepositDisposition splits a security deposit between move-out deductions and the refund owed to
+// the tenant. Charges beyond the deposit are a balance the tenant still owes.
+type DepositDisposition struct {
+	SecurityDeposit float64 `json:"security_deposit"`
+	TotalCharges    float64 `json:"total_charges"`
+	Deductions      float64 `json:"deductions"`
+	Refund          float64 `json:"refund"`
+	BalanceDue      float64 `json:"balance_due"`
+}
+
+// SettleDeposit applies the charges to the deposit
+func SettleDeposit(deposit float64, charges []DamageCharge) DepositDisposition {
+	d := DepositDisposition{SecurityDeposit: deposit}
+	for _, c := range charges {
+		d.TotalCharges += c.Amount
+	}
+	d.TotalCharges = math.Round(d.TotalCharges*100) / 100
+	d.Deductions = math.Min(d.TotalCharges, deposit)
+	d.Refund = math.Round((deposit-d.Deductions)*100) / 100
+	d.BalanceDue = math.Round((d.TotalCharges-d.Deductions)*100) / 100
+	return d
+}
+
+// MoveOutSummary is the move-out report shared with the tenant
+type MoveOutSummary struct {
+	LeaseID      int                   `json:"lease_id"`
+	TenantID     int                   `json:"tenant_id"`
+	TenantName   string                `json:"tenant_name"`
+	TenantEmail  string                `json:"tenant_email"`
+	PropertyName string                `json:"property_name"`
+	UnitNumber   sql.NullString        `json:"unit_number,omitempty"`
+	MoveIn       *LeaseInspection      `json:"move_in,omitempty"`
+	MoveOut      *LeaseInspection      `json:"move_out,omitempty"`
+	Comparison   []ConditionComparison `json:"comparison"`
+	Charges      []DamageCharge        `json:"charges"`
+	Deposit      DepositDisposition    `json:"deposit"`
+}
+
+// GetMoveOutSummary compares a lease's checklists and settles its deposit against the damage charges.
+// It returns sql.ErrNoRows if the lease does not exist.
+func GetMoveOutSummary(leaseID int) (*MoveOutSummary, error) {
+	summary := &MoveOutSummary{LeaseID: leaseID}
+	var deposit sql.NullFloat64
+	err := db.DB.QueryRow(`
+		SELECT t.id, t.first_name || ' ' || t.last_name, t.email, pr.name, pu.unit_number, l.security_deposit
+		FROM leases l
+		JOIN tenants t ON l.tenant_id = t.id
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties pr ON pu.property_id = pr.id
+		WHERE l.id = $1`, leaseID).Scan(&summary.TenantID, &summary.TenantName, &summary.TenantEmail,
+		&summary.PropertyName, &summary.UnitNumber, &deposit)
+	if err != nil {
+		return nil, err
+	}
+
+	inspections, err := GetLeaseInspections(leaseID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range inspections {
+		switch inspections[i].InspectionType {
+		case InspectionMoveIn:
+			summary.MoveIn = &inspections[i]
+		case InspectionMoveOut:
+			summary.MoveOut = &inspections[i]
+		}
+	}
+
+	summary.Charges, err = GetDamageCharges(leaseID)
+	if err != nil {
+		return nil, err
+	}
+	if summary.Charges == nil {
+		summary.Charges = []DamageCharge{}
+	}
+
+	summary.Comparison = CompareInspections(summary.MoveIn, summary.MoveOut)
+	summary.Deposit = SettleDeposit(deposit.Float64, summary.Charges)
+	return summary, nil
+}
+
+// EmailMoveOutSummary queues an email to the tenant with their deposit disposition and a link to
+// the summary PDF in the tenant portal
+func EmailMoveOutSummary(summary *MoveOutSummary, link string) error {
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"Your move-out inspection for %s is complete.\n\n"+
+		"Security deposit: $%.2f\n"+
+		"Deductions:       $%.2f\n"+
+		"Refund:           $%.2f\n",
+		summary.TenantName, summary.PropertyName, summary.Deposit.SecurityDeposit,
+		summary.Deposit.Deductions, summary.Deposit.Refund)
+	if summary.Deposit.BalanceDue > 0 {
+		body += fmt.Sprintf("Balance due:      $%.2f\n", summary.Deposit.BalanceDue)
+	}
+	body += "\nThe itemized summary is available at:\n" + link + "\n"
+
+	return EnqueueOutboxMessage(db.DB, &OutboxMessage{
+		Channel:     "email",
+		EventType:   "lease.move_out_summary",
+		Destination: summary.TenantEmail,
+		Payload: map[string]interface{}{
+			"lease_id": summary.LeaseID,
+			"subject":  "Your move-out summary for " + summary.PropertyName,
+			"body":     body,
+		},
+	})
+}
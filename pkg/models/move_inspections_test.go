@@ -0,0 +1,96 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareInspections(t *testing.T) {
+	moveIn := &LeaseInspection{Items: []InspectionItem{
+		{Area: "Kitchen", Item: "Walls", Condition: "good"},
+		{Area: "Kitchen", Item: "Oven", Condition: "fair"},
+		{Area: "Bedroom", Item: "Carpet", Condition: "excellent"},
+	}}
+	moveOut := &LeaseInspection{Items: []InspectionItem{
+		{ID: 10, Area: "kitchen ", Item: "walls", Condition: "damaged"},
+		{ID: 11, Area: "Kitchen", Item: "Oven", Condition: "good"},
+		{ID: 12, Area: "Bath", Item: "Mirror", Condition: "missing"},
+	}}
+
+	comparisons := CompareInspections(moveIn, moveOut)
+	require.Len(t, comparisons, 4)
+	assert.Equal(t, "good", comparisons[0].MoveInCondition)
+	assert.True(t, comparisons[0].Worsened)
+	assert.Equal(t, int32(10), comparisons[0].MoveOutItemID.Int32)
+	assert.False(t, comparisons[1].Worsened)
+	assert.Empty(t, comparisons[2].MoveInCondition)
+	assert.False(t, comparisons[2].Worsened)
+	assert.Equal(t, "Carpet", comparisons[3].Item)
+	assert.Empty(t, comparisons[3].MoveOutCondition)
+
+	assert.Empty(t, CompareInspections(nil, nil))
+}
+
+func TestSettleDeposit(t *testing.T) {
+	d := SettleDeposit(1000, []DamageCharge{{Amount: 250.50}, {Amount: 100}})
+	assert.Equal(t, 350.5, d.Deductions)
+	assert.Equal(t, 649.5, d.Refund)
+	assert.Zero(t, d.BalanceDue)
+
+	d = SettleDeposit(300, []DamageCharge{{Amount: 450}})
+	assert.Equal(t, 300.0, d.Deductions)
+	assert.Zero(t, d.Refund)
+	assert.Equal(t, 150.0, d.BalanceDue)
+}
+
+func TestCreateDamageChargeRequiresCompletedMoveOut(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT id, completed_at FROM lease_inspections`).WithArgs(5, InspectionMoveOut).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "completed_at"}).AddRow(2, nil))
+	err := CreateDamageCharge(&DamageCharge{LeaseID: 5, Description: "Wall repair", Amount: 120})
+	assert.Equal(t, ErrMoveOutIncomplete, err)
+
+	mock.ExpectQuery(`SELECT id, completed_at FROM lease_inspections`).WithArgs(5, InspectionMoveOut).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "completed_at"}).AddRow(2, time.Now()))
+	mock.ExpectQuery(`SELECT 1 FROM lease_inspection_items`).WillReturnError(sql.ErrNoRows)
+	err = CreateDamageCharge(&DamageCharge{LeaseID: 5, InspectionItemID: sql.NullInt32{Int32: 99, Valid: true},
+		Description: "Wall repair", Amount: 120})
+	assert.Equal(t, ErrChargeItemMismatch, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveLeaseInspectionUpserts(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	date := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	inspection := &LeaseInspection{LeaseID: 3, InspectionType: "move_out", InspectionDate: date,
+		Items: []InspectionItem{{Area: "Kitchen", Item: "Walls", Condition: "damaged"}}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT 1 FROM leases`).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO lease_inspections(.+)ON CONFLICT \(lease_id, inspection_type\) DO UPDATE(.+)completed_at IS NULL`).
+		WithArgs(3, "move_out", date, sql.NullString{}, sql.NullInt32{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(5, date, date))
+	mock.ExpectExec(`DELETE FROM lease_inspection_items`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO lease_inspection_items`).WithArgs(5, "Kitchen", "Walls", "damaged", sql.NullString{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectCommit()
+	require.NoError(t, SaveLeaseInspection(inspection))
+	assert.Equal(t, 5, inspection.ID)
+
+	// A completed checklist matches the constraint but is not updated
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT 1 FROM leases`).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO lease_inspections`).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	assert.Equal(t, ErrInspectionCompleted, SaveLeaseInspection(inspection))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
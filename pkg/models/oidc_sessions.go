@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// oidcRevocationRetention is how long revocations are kept. ID tokens expire well within it,
+// after which they are rejected anyway.
+const oidcRevocationRetention = 24 * time.Hour
+
+// RevokeOIDCSession records that the identity provider ended an SSO session, or every session of
+// subject when sessionID is empty, and deletes the subject's local sessions
+func RevokeOIDCSession(subject, sessionID string, now time.Time) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM oidc_session_revocations WHERE revoked_at < $1", now.Add(-oidcRevocationRetention)); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO oidc_session_revocations (subject, session_id, revoked_at)
+		VALUES ($1, $2, $3)`, subject, sessionID, now)
+	if err != nil {
+		return err
+	}
+	if subject != "" {
+		_, err = tx.Exec(`
+			DELETE FROM user_sessions
+			WHERE user_id IN (SELECT id FROM users WHERE keycloak_id = $1)`, subject)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// IsOIDCSessionRevoked reports whether an ID token issued at issuedAt for the subject's SSO
+// session has been revoked by a back-channel logout
+func IsOIDCSessionRevoked(subject, sessionID string, issuedAt time.Time) (bool, error) {
+	// Read from the primary so a logout takes effect without waiting for the replica
+	var count int
+	err := db.DB.QueryRow(`
+		SELECT COUNT(*) FROM oidc_session_revocations
+		WHERE (session_id <> '' AND session_id = $1)
+		   OR (session_id = '' AND subject = $2 AND revoked_at >= $3)`,
+		sessionID, subject, issuedAt).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeOIDCSession(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM oidc_session_revocations WHERE revoked_at`).
+		WithArgs(now.Add(-24 * time.Hour)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO oidc_session_revocations`).
+		WithArgs("kc-user", "sid-1", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM user_sessions`).
+		WithArgs("kc-user").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, RevokeOIDCSession("kc-user", "sid-1", now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsOIDCSessionRevoked(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	issuedAt := time.Date(2026, 10, 16, 11, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM oidc_session_revocations`).
+		WithArgs("sid-1", "kc-user", issuedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	revoked, err := IsOIDCSessionRevoked("kc-user", "sid-1", issuedAt)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,259 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// EmergencyPriority is the maintenance priority routed to the on-call contact
+const EmergencyPriority = "emergency"
+
+// On-call contact sources
+const (
+	OnCallSourceShift            = "shift"
+	OnCallSourceEmergencyContact = "emergency_contact"
+)
+
+// OnCallShift assigns a user to handle emergency maintenance for a property during a time window
+type OnCallShift struct {
+	ID         int            `json:"id"`
+	PropertyID int            `json:"property_id"`
+	UserID     int            `json:"user_id"`
+	UserName   string         `json:"user_name"`
+	StartsAt   time.Time      `json:"starts_at"`
+	EndsAt     time.Time      `json:"ends_at"`
+	Notes      sql.NullString `json:"notes,omitempty"`
+	CreatedBy  sql.NullInt32  `json:"created_by,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// OnCallContact is the person to reach for a property's emergencies at a point in time
+type OnCallContact struct {
+	PropertyID int        `json:"property_id"`
+	Source     string     `json:"source"` // 'shift' or 'emergency_contact'
+	UserID     *int       `json:"user_id,omitempty"`
+	Name       string     `json:"name"`
+	Phone      string     `json:"phone,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	Until      *time.Time `json:"until,omitempty"` // End of the covering shift
+}
+
+// EmergencyContact is a property's fallback emergency contact
+type EmergencyContact struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+}
+
+// EmergencyRequest is an emergency maintenance request awaiting on-call routing
+type EmergencyRequest struct {
+	ID           int       `json:"id"`
+	PropertyID   int       `json:"property_id"`
+	PropertyName string    `json:"property_name"`
+	Description  string    `json:"description"`
+	ReportedDate time.Time `json:"reported_date"`
+	Escalated    bool      `json:"escalated"` // Managers were told it could not be routed
+}
+
+// SelectOnCallShift returns the shift covering at. When shifts overlap, the one that
+// started most recently wins so that short swaps override a longer rotation.
+func SelectOnCallShift(shifts []OnCallShift, at time.Time) *OnCallShift {
+	var selected *OnCallShift
+	for i := range shifts {
+		shift := &shifts[i]
+		if at.Before(shift.StartsAt) || !at.Before(shift.EndsAt) {
+			continue
+		}
+		if selected == nil || shift.StartsAt.After(selected.StartsAt) {
+			selected = shift
+		}
+	}
+	return selected
+}
+
+// CreateOnCallShift schedules an on-call shift
+func CreateOnCallShift(shift *OnCallShift) error {
+	query := `
+		INSERT INTO oncall_shifts (property_id, user_id, starts_at, ends_at, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	return db.DB.QueryRow(query, shift.PropertyID, shift.UserID, shift.StartsAt, shift.EndsAt,
+		shift.Notes, shift.CreatedBy).Scan(&shift.ID, &shift.CreatedAt)
+}
+
+// GetOnCallShiftPropertyID returns the property a shift covers
+func GetOnCallShiftPropertyID(shiftID int) (int, error) {
+	var propertyID int
+	err := db.DB.QueryRow("SELECT property_id FROM oncall_shifts WHERE id = $1", shiftID).Scan(&propertyID)
+	return propertyID, err
+}
+
+// DeleteOnCallShift removes a shift. It returns sql.ErrNoRows if the shift does not exist.
+func DeleteOnCallShift(shiftID int) error {
+	result, err := db.DB.Exec("DELETE FROM oncall_shifts WHERE id = $1", shiftID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetOnCallShifts retrieves the shifts at properties overlapping start to end
+func GetOnCallShifts(properties PropertyFilter, start, end time.Time) ([]OnCallShift, error) {
+	scope, args := properties.condition("s.property_id", []interface{}{start, end})
+	query := `
+		SELECT s.id, s.property_id, s.user_id, u.first_name || ' ' || u.last_name,
+			   s.starts_at, s.ends_at, s.notes, s.created_by, s.created_at
+		FROM oncall_shifts s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.starts_at < $2 AND s.ends_at > $1` + scope + `
+		ORDER BY s.property_id, s.starts_at`
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shifts []OnCallShift
+	for rows.Next() {
+		var shift OnCallShift
+		err := rows.Scan(&shift.ID, &shift.PropertyID, &shift.UserID, &shift.UserName,
+			&shift.StartsAt, &shift.EndsAt, &shift.Notes, &shift.CreatedBy, &shift.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, shift)
+	}
+
+	return shifts, nil
+}
+
+// Reachable reports whether the contact has a phone number or email address to notify
+func (c *OnCallContact) Reachable() bool {
+	return c.Phone != "" || c.Email != ""
+}
+
+// GetOnCallContact resolves who handles a property's emergencies at the given time: the user on
+// the covering shift, or the property's emergency contact when no shift applies or the shift's
+// user has neither a phone number nor an email address. It returns sql.ErrNoRows when neither
+// is configured.
+func GetOnCallContact(propertyID int, at time.Time) (*OnCallContact, error) {
+	shifts, err := GetOnCallShifts(PropertyFilter{IDs: []int{propertyID}, Scoped: true}, at, at.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	if shift := SelectOnCallShift(shifts, at); shift != nil {
+		contact := &OnCallContact{
+			PropertyID: propertyID,
+			Source:     OnCallSourceShift,
+			UserID:     &shift.UserID,
+			Name:       shift.UserName,
+			Until:      &shift.EndsAt,
+		}
+
+		var phone sql.NullString
+		err := db.DB.QueryRow("SELECT email, phone_number FROM users WHERE id = $1", shift.UserID).
+			Scan(&contact.Email, &phone)
+		if err != nil {
+			return nil, err
+		}
+		contact.Phone = phone.String
+		if contact.Reachable() {
+			return contact, nil
+		}
+	}
+
+	emergency, err := GetPropertyEmergencyContact(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if emergency.Name == "" && emergency.Phone == "" && emergency.Email == "" {
+		return nil, sql.ErrNoRows
+	}
+
+	return &OnCallContact{
+		PropertyID: propertyID,
+		Source:     OnCallSourceEmergencyContact,
+		Name:       emergency.Name,
+		Phone:      emergency.Phone,
+		Email:      emergency.Email,
+	}, nil
+}
+
+// GetPropertyEmergencyContact retrieves a property's fallback emergency contact
+func GetPropertyEmergencyContact(propertyID int) (*EmergencyContact, error) {
+	var name, phone, email sql.NullString
+	err := db.DB.QueryRow(`
+		SELECT emergency_contact_name, emergency_contact_phone, emergency_contact_email
+		FROM properties WHERE id = $1`, propertyID).Scan(&name, &phone, &email)
+	if err != nil {
+		return nil, err
+	}
+	return &EmergencyContact{Name: name.String, Phone: phone.String, Email: email.String}, nil
+}
+
+// SetPropertyEmergencyContact sets a property's fallback emergency contact
+func SetPropertyEmergencyContact(propertyID int, contact EmergencyContact) error {
+	result, err := db.DB.Exec(`
+		UPDATE properties
+		SET emergency_contact_name = $2, emergency_contact_phone = $3, emergency_contact_email = $4,
+			updated_at = NOW()
+		WHERE id = $1`,
+		propertyID, NullString(contact.Name), NullString(contact.Phone), NullString(contact.Email))
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetUnroutedEmergencyRequests retrieves open emergency requests that have not yet been
+// sent to an on-call contact
+func GetUnroutedEmergencyRequests() ([]EmergencyRequest, error) {
+	query := `
+		SELECT mr.id, mr.property_id, p.name, mr.description, mr.reported_date,
+			   mr.oncall_escalated_at IS NOT NULL
+		FROM maintenance_requests mr
+		JOIN properties p ON mr.property_id = p.id
+		WHERE mr.priority = $1 AND mr.oncall_notified_at IS NULL
+		  AND mr.status NOT IN ('completed', 'cancelled')
+		ORDER BY mr.id`
+
+	rows, err := db.DB.Query(query, EmergencyPriority)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []EmergencyRequest
+	for rows.Next() {
+		var req EmergencyRequest
+		if err := rows.Scan(&req.ID, &req.PropertyID, &req.PropertyName, &req.Description, &req.ReportedDate,
+			&req.Escalated); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, nil
+}
+
+// MarkEmergencyRouted records that a request was sent to the on-call contact
+func MarkEmergencyRouted(q Querier, requestID int, userID *int) error {
+	_, err := q.Exec(`
+		UPDATE maintenance_requests
+		SET oncall_notified_at = NOW(), oncall_user_id = $2, updated_at = NOW()
+		WHERE id = $1`, requestID, userID)
+	return err
+}
+
+// MarkEmergencyEscalated records that managers were told a request could not be routed
+func MarkEmergencyEscalated(q Querier, requestID int) error {
+	_, err := q.Exec(`
+		UPDATE maintenance_requests SET oncall_escalated_at = NOW(), updated_at = NOW()
+		WHERE id = $1`, requestID)
+	return err
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectOnCallShift(t *testing.T) {
+	base := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	shifts := []OnCallShift{
+		{ID: 1, StartsAt: base, EndsAt: base.AddDate(0, 0, 7)},                        // Weekly rotation
+		{ID: 2, StartsAt: base.Add(48 * time.Hour), EndsAt: base.Add(60 * time.Hour)}, // Swap covering part of a day
+		{ID: 3, StartsAt: base.AddDate(0, 0, 7), EndsAt: base.AddDate(0, 0, 14)},      // Next week
+	}
+
+	assert.Equal(t, 1, SelectOnCallShift(shifts, base.Add(time.Hour)).ID)
+	assert.Equal(t, 2, SelectOnCallShift(shifts, base.Add(50*time.Hour)).ID)
+	assert.Equal(t, 1, SelectOnCallShift(shifts, base.Add(60*time.Hour)).ID)
+
+	// Shift boundaries are half-open
+	assert.Equal(t, 3, SelectOnCallShift(shifts, base.AddDate(0, 0, 7)).ID)
+	assert.Nil(t, SelectOnCallShift(shifts, base.AddDate(0, 0, 14)))
+	assert.Nil(t, SelectOnCallShift(nil, base))
+}
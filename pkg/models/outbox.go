@@ -0,0 +1,240 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/mailer"
+)
+
+// Outbox message statuses
+const (
+	OutboxStatusPending    = "pending"
+	OutboxStatusProcessing = "processing"
+	OutboxStatusDelivered  = "delivered"
+	OutboxStatusDead       = "dead"
+)
+
+// OutboxMessage represents a notification or webhook queued for asynchronous delivery
+type OutboxMessage struct {
+	ID            int                    `json:"id"`
+	Channel       string                 `json:"channel"`
+	EventType     string                 `json:"event_type"`
+	Destination   string                 `json:"destination"`
+	Payload       map[string]interface{} `json:"payload"`
+	Status        string                 `json:"status"`
+	Attempts      int                    `json:"attempts"`
+	MaxAttempts   int                    `json:"max_attempts"`
+	NextAttemptAt time.Time              `json:"next_attempt_at"`
+	LastError     sql.NullString         `json:"last_error,omitempty"`
+	DeliveredAt   sql.NullTime           `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+// Querier is implemented by both *sql.DB and *sql.Tx so that outbox messages
+// can be written in the same transaction as the domain change that produced them
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// EnqueueOutboxMessage writes a message to the outbox using the given querier.
+// Pass the caller's *sql.Tx to make delivery conditional on the transaction committing.
+func EnqueueOutboxMessage(q Querier, msg *OutboxMessage) error {
+	payloadJSON, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	if msg.MaxAttempts <= 0 {
+		msg.MaxAttempts = 8
+	}
+
+	query := `
+		INSERT INTO outbox_messages (channel, event_type, destination, payload, max_attempts)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, attempts, next_attempt_at, created_at, updated_at`
+
+	return q.QueryRow(query, msg.Channel, msg.EventType, msg.Destination, payloadJSON,
+		msg.MaxAttempts).Scan(&msg.ID, &msg.Status, &msg.Attempts, &msg.NextAttemptAt,
+		&msg.CreatedAt, &msg.UpdatedAt)
+}
+
+// EnqueueEmail queues a rendered email to its recipient. extra adds fields such as entity IDs
+// to the payload for tracing; they are ignored on delivery.
+func EnqueueEmail(q Querier, eventType string, msg *mailer.Message, extra map[string]interface{}) error {
+	payload := msg.Payload()
+	for key, value := range extra {
+		payload[key] = value
+	}
+	return EnqueueOutboxMessage(q, &OutboxMessage{
+		Channel:     "email",
+		EventType:   eventType,
+		Destination: msg.To,
+		Payload:     payload,
+	})
+}
+
+// AddLeaseNoticeHTML renders the "subject" and "body" of a lease notice payload as HTML and adds
+// it as the payload's "html" field. The same payload can go to SMS, which ignores it.
+func AddLeaseNoticeHTML(payload map[string]interface{}) error {
+	msg, err := mailer.Render("lease_notice", map[string]interface{}{
+		"Subject": payload["subject"],
+		"Body":    payload["body"],
+	})
+	if err != nil {
+		return err
+	}
+	payload["html"] = msg.HTML
+	return nil
+}
+
+// ClaimOutboxMessages atomically claims up to limit messages that are due for delivery.
+// Claimed messages are leased for leaseDuration; if the dispatcher crashes before
+// recording an outcome they become eligible again once the lease expires.
+func ClaimOutboxMessages(limit int, leaseDuration time.Duration) ([]OutboxMessage, error) {
+	now := time.Now()
+	query := fmt.Sprintf(`
+		UPDATE outbox_messages
+		SET status = 'processing', attempts = attempts + 1,
+			next_attempt_at = $2, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM outbox_messages
+			WHERE status IN ('pending', 'processing') AND next_attempt_at <= $3
+			ORDER BY next_attempt_at
+			LIMIT $1
+			%s
+		)
+		RETURNING id, channel, event_type, destination, payload, status, attempts,
+				  max_attempts, next_attempt_at, last_error, delivered_at, created_at, updated_at`,
+		db.CurrentDialect.SkipLocked())
+
+	rows, err := db.DB.Query(query, limit, now.Add(leaseDuration), now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanOutboxMessages(rows)
+}
+
+// MarkOutboxMessageDelivered records a successful delivery
+func MarkOutboxMessageDelivered(id int) error {
+	_, err := db.DB.Exec(`
+		UPDATE outbox_messages
+		SET status = 'delivered', delivered_at = NOW(), last_error = NULL, updated_at = NOW()
+		WHERE id = $1`, id)
+	return err
+}
+
+// MarkOutboxMessageFailed records a failed delivery attempt. The message is
+// rescheduled for nextAttempt, or moved to the dead-letter status when dead is true.
+func MarkOutboxMessageFailed(id int, deliveryErr string, nextAttempt time.Time, dead bool) error {
+	status := OutboxStatusPending
+	if dead {
+		status = OutboxStatusDead
+	}
+
+	_, err := db.DB.Exec(`
+		UPDATE outbox_messages
+		SET status = $1, last_error = $2, next_attempt_at = $3, updated_at = NOW()
+		WHERE id = $4`, status, deliveryErr, nextAttempt, id)
+	return err
+}
+
+// GetOutboxMessages retrieves outbox messages, optionally filtered by status
+func GetOutboxMessages(status string, limit int) ([]OutboxMessage, error) {
+	query := `
+		SELECT id, channel, event_type, destination, payload, status, attempts,
+			   max_attempts, next_attempt_at, last_error, delivered_at, created_at, updated_at
+		FROM outbox_messages`
+
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE status = $1"
+		args = append(args, status)
+	}
+
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", len(args))
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanOutboxMessages(rows)
+}
+
+// RequeueOutboxMessage resets a dead-lettered message so it is retried immediately
+func RequeueOutboxMessage(id int) error {
+	result, err := db.DB.Exec(`
+		UPDATE outbox_messages
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'dead'`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// OutboxChannelHealth summarizes recent deliveries on one outbox channel
+type OutboxChannelHealth struct {
+	Delivered int `json:"delivered"` // Delivered since the window start
+	Retrying  int `json:"retrying"`  // Pending after at least one failed attempt
+	Dead      int `json:"dead"`      // Given up on since the window start
+	Overdue   int `json:"overdue"`   // Pending and due before the overdue cutoff, so not being picked up
+}
+
+// GetOutboxChannelHealth counts a channel's deliveries since since, with pending messages due
+// before overdueBefore counted as overdue
+func GetOutboxChannelHealth(channel string, since, overdueBefore time.Time) (*OutboxChannelHealth, error) {
+	h := &OutboxChannelHealth{}
+	err := db.ReadDB().QueryRow(`
+		SELECT
+			COUNT(CASE WHEN status = 'delivered' AND delivered_at >= $2 THEN 1 END),
+			COUNT(CASE WHEN status = 'pending' AND attempts > 0 THEN 1 END),
+			COUNT(CASE WHEN status = 'dead' AND updated_at >= $2 THEN 1 END),
+			COUNT(CASE WHEN status = 'pending' AND next_attempt_at < $3 THEN 1 END)
+		FROM outbox_messages
+		WHERE channel = $1`, channel, since, overdueBefore).Scan(&h.Delivered, &h.Retrying, &h.Dead, &h.Overdue)
+	return h, err
+}
+
+// scanOutboxMessages scans outbox rows into messages
+func scanOutboxMessages(rows *sql.Rows) ([]OutboxMessage, error) {
+	var messages []OutboxMessage
+	for rows.Next() {
+		var msg OutboxMessage
+		var payloadJSON []byte
+
+		err := rows.Scan(&msg.ID, &msg.Channel, &msg.EventType, &msg.Destination, &payloadJSON,
+			&msg.Status, &msg.Attempts, &msg.MaxAttempts, &msg.NextAttemptAt, &msg.LastError,
+			&msg.DeliveredAt, &msg.CreatedAt, &msg.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(payloadJSON, &msg.Payload); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
@@ -0,0 +1,352 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Owner portal permissions, held by the owner role
+const (
+	PermOwnerStatements  = "owner.statements.read"
+	PermOwnerOccupancy   = "owner.occupancy.read"
+	PermOwnerMaintenance = "owner.maintenance.read"
+	PermOwnerDocuments   = "owner.documents.read"
+)
+
+// ErrInvalidOwnership is returned for an ownership share outside (0, 100], a user listed twice,
+// or owners holding more than 100% of a property between them
+var ErrInvalidOwnership = errors.New("each owner needs a share above 0 and at most 100, listed once, and the shares cannot total more than 100")
+
+// PropertyOwnership records a user's ownership of a property
+type PropertyOwnership struct {
+	PropertyID       int       `json:"property_id"`
+	PropertyName     string    `json:"property_name,omitempty"`
+	UserID           int       `json:"user_id"`
+	UserName         string    `json:"user_name,omitempty"`
+	Email            string    `json:"email,omitempty"`
+	OwnershipPercent float64   `json:"ownership_percent"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// validateOwnerships checks the owners of one property
+func validateOwnerships(owners []PropertyOwnership) error {
+	seen := map[int]bool{}
+	var total float64
+	for _, o := range owners {
+		if o.OwnershipPercent <= 0 || o.OwnershipPercent > 100 || seen[o.UserID] {
+			return ErrInvalidOwnership
+		}
+		seen[o.UserID] = true
+		total += o.OwnershipPercent
+	}
+	if total > 100 {
+		return ErrInvalidOwnership
+	}
+	return nil
+}
+
+// SetPropertyOwners replaces a property's owners. It returns sql.ErrNoRows when the property does
+// not exist and an UnknownUsersError when any of the users do not exist.
+func SetPropertyOwners(propertyID int, owners []PropertyOwnership) error {
+	if err := validateOwnerships(owners); err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow("SELECT 1 FROM properties WHERE id = $1", propertyID).Scan(&exists); err != nil {
+		return err
+	}
+	userIDs := make([]int, len(owners))
+	for i, o := range owners {
+		userIDs[i] = o.UserID
+	}
+	if err := checkBulkRoleUsers(tx, userIDs); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM property_ownerships WHERE property_id = $1", propertyID); err != nil {
+		return err
+	}
+	for _, o := range owners {
+		if _, err := tx.Exec(`
+			INSERT INTO property_ownerships (property_id, user_id, ownership_percent) VALUES ($1, $2, $3)`,
+			propertyID, o.UserID, o.OwnershipPercent); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// scanOwnerships reads ownership rows selected with a property and user join
+func scanOwnerships(rows *sql.Rows) ([]PropertyOwnership, error) {
+	defer rows.Close()
+
+	var owners []PropertyOwnership
+	for rows.Next() {
+		var o PropertyOwnership
+		if err := rows.Scan(&o.PropertyID, &o.PropertyName, &o.UserID, &o.UserName, &o.Email,
+			&o.OwnershipPercent, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		owners = append(owners, o)
+	}
+	return owners, rows.Err()
+}
+
+const ownershipColumns = `
+	SELECT o.property_id, p.name, o.user_id, u.username, u.email, o.ownership_percent, o.created_at
+	FROM property_ownerships o
+	JOIN properties p ON p.id = o.property_id
+	JOIN users u ON u.id = o.user_id`
+
+// GetPropertyOwners lists a property's owners, largest share first
+func GetPropertyOwners(propertyID int) ([]PropertyOwnership, error) {
+	rows, err := db.DB.Query(ownershipColumns+" WHERE o.property_id = $1 ORDER BY o.ownership_percent DESC, u.username", propertyID)
+	if err != nil {
+		return nil, err
+	}
+	return scanOwnerships(rows)
+}
+
+// GetOwnedProperties lists the properties a user owns
+func GetOwnedProperties(userID int) ([]PropertyOwnership, error) {
+	rows, err := db.ReadDB().Query(ownershipColumns+" WHERE o.user_id = $1 ORDER BY p.name", userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanOwnerships(rows)
+}
+
+// OwnerStatement is an owner's monthly statement for one property: cash collected less expenses
+// paid, and the owner's share of the result
+type OwnerStatement struct {
+	PropertyID       int       `json:"property_id"`
+	PropertyName     string    `json:"property_name"`
+	Month            time.Time `json:"month"`
+	OwnershipPercent float64   `json:"ownership_percent"`
+	RentCollected    float64   `json:"rent_collected"`
+	MaintenanceCosts float64   `json:"maintenance_costs"`
+	UtilityCosts     float64   `json:"utility_costs"`
+	NetIncome        float64   `json:"net_income"`
+	OwnerShare       float64   `json:"owner_share"`
+}
+
+// computeTotals sets the statement's net income and the owner's share of it
+func (s *OwnerStatement) computeTotals() {
+	s.NetIncome = math.Round((s.RentCollected-s.MaintenanceCosts-s.UtilityCosts)*100) / 100
+	s.OwnerShare = math.Round(s.NetIncome*s.OwnershipPercent) / 100
+}
+
+// GetOwnerStatements returns a user's statement for each property they own for the month
+// containing month. Rent is counted when paid, maintenance when completed, and utilities by the
+// start of their billing period.
+func GetOwnerStatements(userID int, month time.Time) ([]OwnerStatement, error) {
+	start := PeriodStart(month)
+	end := start.AddDate(0, 1, 0)
+	rows, err := db.ReadDB().Query(`
+		SELECT p.id, p.name, o.ownership_percent,
+			   COALESCE((SELECT SUM(pay.amount) FROM payments pay
+			             JOIN leases l ON pay.lease_id = l.id
+			             JOIN property_units pu ON l.unit_id = pu.id
+			             WHERE pu.property_id = p.id AND pay.status = 'completed'
+			               AND pay.payment_date >= $2 AND pay.payment_date < $3), 0),
+			   COALESCE((SELECT SUM(mr.actual_cost) FROM maintenance_requests mr
+			             WHERE mr.property_id = p.id AND mr.completed_date >= $2 AND mr.completed_date < $3), 0),
+			   COALESCE((SELECT SUM(ue.amount) FROM utility_expenses ue
+			             WHERE ue.property_id = p.id AND ue.period_start >= $2 AND ue.period_start < $3), 0)
+		FROM property_ownerships o
+		JOIN properties p ON p.id = o.property_id
+		WHERE o.user_id = $1
+		ORDER BY p.name`, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statements []OwnerStatement
+	for rows.Next() {
+		s := OwnerStatement{Month: start}
+		if err := rows.Scan(&s.PropertyID, &s.PropertyName, &s.OwnershipPercent, &s.RentCollected,
+			&s.MaintenanceCosts, &s.UtilityCosts); err != nil {
+			return nil, err
+		}
+		s.computeTotals()
+		statements = append(statements, s)
+	}
+	return statements, rows.Err()
+}
+
+// OwnerOccupancy is the current occupancy of an owned property
+type OwnerOccupancy struct {
+	PropertyID    int     `json:"property_id"`
+	PropertyName  string  `json:"property_name"`
+	Units         int     `json:"units"`
+	OccupiedUnits int     `json:"occupied_units"`
+	VacantUnits   int     `json:"vacant_units"`
+	OccupancyRate float64 `json:"occupancy_rate"` // Percent of units with an active lease
+}
+
+// GetOwnerOccupancy returns the occupancy of each property a user owns
+func GetOwnerOccupancy(userID int) ([]OwnerOccupancy, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT p.id, p.name,
+			   (SELECT COUNT(*) FROM property_units pu WHERE pu.property_id = p.id),
+			   (SELECT COUNT(*) FROM property_units pu
+			    WHERE pu.property_id = p.id
+			      AND EXISTS (SELECT 1 FROM leases l WHERE l.unit_id = pu.id AND l.status = 'active'))
+		FROM property_ownerships o
+		JOIN properties p ON p.id = o.property_id
+		WHERE o.user_id = $1
+		ORDER BY p.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var occupancy []OwnerOccupancy
+	for rows.Next() {
+		var o OwnerOccupancy
+		if err := rows.Scan(&o.PropertyID, &o.PropertyName, &o.Units, &o.OccupiedUnits); err != nil {
+			return nil, err
+		}
+		o.VacantUnits = o.Units - o.OccupiedUnits
+		if o.Units > 0 {
+			o.OccupancyRate = math.Round(float64(o.OccupiedUnits)/float64(o.Units)*10000) / 100
+		}
+		occupancy = append(occupancy, o)
+	}
+	return occupancy, rows.Err()
+}
+
+// OwnerMaintenanceRequest is a work order at an owned property whose estimate was above the
+// property's auto-approval limit
+type OwnerMaintenanceRequest struct {
+	ID             int          `json:"id"`
+	PropertyID     int          `json:"property_id"`
+	PropertyName   string       `json:"property_name"`
+	Description    string       `json:"description"`
+	Status         string       `json:"status"`
+	Priority       string       `json:"priority"`
+	ReportedDate   time.Time    `json:"reported_date"`
+	CompletedDate  sql.NullTime `json:"completed_date,omitempty"`
+	EstimatedCost  float64      `json:"estimated_cost"`
+	ApprovalStatus string       `json:"approval_status"`
+}
+
+// GetOwnerMaintenance lists the work orders at a user's properties whose latest estimate needed
+// approval, newest first
+func GetOwnerMaintenance(userID int) ([]OwnerMaintenanceRequest, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT mr.id, p.id, p.name, mr.description, mr.status, COALESCE(mr.priority, ''), mr.reported_date,
+			   mr.completed_date, mr.estimated_cost, mr.approval_status
+		FROM property_ownerships o
+		JOIN properties p ON p.id = o.property_id
+		JOIN maintenance_requests mr ON mr.property_id = p.id
+		WHERE o.user_id = $1 AND mr.approval_status IN ($2, $3, $4)
+		ORDER BY mr.reported_date DESC, mr.id DESC`,
+		userID, ApprovalPending, ApprovalApproved, ApprovalDeclined)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []OwnerMaintenanceRequest
+	for rows.Next() {
+		var m OwnerMaintenanceRequest
+		if err := rows.Scan(&m.ID, &m.PropertyID, &m.PropertyName, &m.Description, &m.Status, &m.Priority,
+			&m.ReportedDate, &m.CompletedDate, &m.EstimatedCost, &m.ApprovalStatus); err != nil {
+			return nil, err
+		}
+		requests = append(requests, m)
+	}
+	return requests, rows.Err()
+}
+
+// OwnerDocument is a document shared with a property's owners
+type OwnerDocument struct {
+	ID           int           `json:"id"`
+	PropertyID   int           `json:"property_id"`
+	PropertyName string        `json:"property_name,omitempty"`
+	Title        string        `json:"title"`
+	Filename     string        `json:"filename"`
+	ContentType  string        `json:"content_type"`
+	SizeBytes    int           `json:"size_bytes"`
+	URL          string        `json:"url"`
+	UploadedBy   sql.NullInt32 `json:"uploaded_by,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+// CreateOwnerDocument records a document already saved to the document store. It returns
+// sql.ErrNoRows when the property does not exist.
+func CreateOwnerDocument(d *OwnerDocument) error {
+	if err := db.DB.QueryRow("SELECT name FROM properties WHERE id = $1", d.PropertyID).Scan(&d.PropertyName); err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO owner_documents (property_id, title, filename, content_type, size_bytes, document_url, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		d.PropertyID, d.Title, d.Filename, d.ContentType, d.SizeBytes, d.URL, d.UploadedBy).Scan(&d.ID, &d.CreatedAt)
+}
+
+// GetOwnerDocumentPropertyID returns the property a document is shared for
+func GetOwnerDocumentPropertyID(id int) (int, error) {
+	var propertyID int
+	err := db.DB.QueryRow("SELECT property_id FROM owner_documents WHERE id = $1", id).Scan(&propertyID)
+	return propertyID, err
+}
+
+// DeleteOwnerDocument removes a document and returns its URL, so the caller can delete the file
+func DeleteOwnerDocument(id int) (string, error) {
+	var url string
+	err := db.DB.QueryRow("DELETE FROM owner_documents WHERE id = $1 RETURNING document_url", id).Scan(&url)
+	return url, err
+}
+
+const ownerDocumentColumns = `
+	SELECT d.id, d.property_id, p.name, d.title, d.filename, d.content_type, d.size_bytes, d.document_url,
+		   d.uploaded_by, d.created_at
+	FROM owner_documents d
+	JOIN properties p ON p.id = d.property_id`
+
+func queryOwnerDocuments(query string, args ...interface{}) ([]OwnerDocument, error) {
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []OwnerDocument
+	for rows.Next() {
+		var d OwnerDocument
+		if err := rows.Scan(&d.ID, &d.PropertyID, &d.PropertyName, &d.Title, &d.Filename, &d.ContentType,
+			&d.SizeBytes, &d.URL, &d.UploadedBy, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		documents = append(documents, d)
+	}
+	return documents, rows.Err()
+}
+
+// GetPropertyOwnerDocuments lists the documents shared with a property's owners, newest first
+func GetPropertyOwnerDocuments(propertyID int) ([]OwnerDocument, error) {
+	return queryOwnerDocuments(ownerDocumentColumns+" WHERE d.property_id = $1 ORDER BY d.created_at DESC, d.id DESC", propertyID)
+}
+
+// GetOwnerDocuments lists the documents shared for every property a user owns, newest first
+func GetOwnerDocuments(userID int) ([]OwnerDocument, error) {
+	return queryOwnerDocuments(ownerDocumentColumns+`
+		JOIN property_ownerships o ON o.property_id = d.property_id
+		WHERE o.user_id = $1
+		ORDER BY d.created_at DESC, d.id DESC`, userID)
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOwnerships(t *testing.T) {
+	assert.NoError(t, validateOwnerships(nil), "a property can have no owners")
+	assert.NoError(t, validateOwnerships([]PropertyOwnership{
+		{UserID: 1, OwnershipPercent: 60},
+		{UserID: 2, OwnershipPercent: 40},
+	}))
+
+	assert.Equal(t, ErrInvalidOwnership, validateOwnerships([]PropertyOwnership{{UserID: 1, OwnershipPercent: 0}}))
+	assert.Equal(t, ErrInvalidOwnership, validateOwnerships([]PropertyOwnership{{UserID: 1, OwnershipPercent: 100.5}}))
+	assert.Equal(t, ErrInvalidOwnership, validateOwnerships([]PropertyOwnership{
+		{UserID: 1, OwnershipPercent: 30},
+		{UserID: 1, OwnershipPercent: 30},
+	}), "an owner is listed once")
+	assert.Equal(t, ErrInvalidOwnership, validateOwnerships([]PropertyOwnership{
+		{UserID: 1, OwnershipPercent: 70},
+		{UserID: 2, OwnershipPercent: 40},
+	}))
+}
+
+func TestOwnerStatementTotals(t *testing.T) {
+	s := OwnerStatement{OwnershipPercent: 25, RentCollected: 12500, MaintenanceCosts: 1830.45, UtilityCosts: 612.2}
+	s.computeTotals()
+	assert.Equal(t, 10057.35, s.NetIncome)
+	assert.Equal(t, 2514.34, s.OwnerShare)
+
+	loss := OwnerStatement{OwnershipPercent: 100, MaintenanceCosts: 400}
+	loss.computeTotals()
+	assert.Equal(t, -400.0, loss.NetIncome)
+	assert.Equal(t, -400.0, loss.OwnerShare)
+}
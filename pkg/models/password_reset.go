@@ -0,0 +1,110 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/mailer"
+)
+
+// PasswordResetTTL is how long a password reset link stays valid
+const PasswordResetTTL = time.Hour
+
+// ErrPasswordResetInvalid is returned for unknown, expired or already used reset tokens
+var ErrPasswordResetInvalid = errors.New("password reset link is invalid or has expired")
+
+// displayName is how emails greet a user
+func displayName(user *User) string {
+	if name := strings.TrimSpace(user.FirstName); name != "" {
+		return name
+	}
+	return user.Username
+}
+
+// RequestPasswordReset issues a reset token for a user and queues an email with the link built
+// by resetLink. Only the token's hash is stored, and a new request replaces any earlier token.
+func RequestPasswordReset(user *User, resetLink func(token string) string, now time.Time) error {
+	token, err := GenerateResetToken()
+	if err != nil {
+		return err
+	}
+	expires := now.Add(PasswordResetTTL)
+
+	msg, err := mailer.Render("password_reset", map[string]interface{}{
+		"Name":    displayName(user),
+		"Link":    resetLink(token),
+		"Expires": expires.UTC().Format("January 2, 2006 15:04 MST"),
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = user.Email
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE users SET password_reset_token = $1, password_reset_expires = $2, updated_at = NOW()
+		WHERE id = $3`, HashAPIKey(token), expires, user.ID); err != nil {
+		return err
+	}
+	if err := EnqueueEmail(tx, "user.password_reset_requested", msg, map[string]interface{}{"user_id": user.ID}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetUserByResetToken returns the user a plaintext reset token was issued to, or
+// ErrPasswordResetInvalid if it is unknown or expired
+func GetUserByResetToken(token string, now time.Time) (*User, error) {
+	var userID int
+	var expires sql.NullTime
+	err := db.DB.QueryRow("SELECT id, password_reset_expires FROM users WHERE password_reset_token = $1",
+		HashAPIKey(token)).Scan(&userID, &expires)
+	if err == sql.ErrNoRows {
+		return nil, ErrPasswordResetInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !expires.Valid || !now.Before(expires.Time) {
+		return nil, ErrPasswordResetInvalid
+	}
+	return GetUserByID(userID)
+}
+
+// CompletePasswordReset consumes a reset token once the new password has been set, and records
+// the reset in the audit log
+func CompletePasswordReset(userID int, token string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET password_reset_token = NULL, password_reset_expires = NULL, updated_at = NOW()
+		WHERE id = $1 AND password_reset_token = $2`, userID, HashAPIKey(token))
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return ErrPasswordResetInvalid
+	}
+
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    sql.NullInt32{Int32: int32(userID), Valid: true},
+		Action:     "user.password_reset",
+		EntityType: "user",
+		EntityID:   userID,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// payloadArg captures an outbox payload so tests can inspect the queued email
+type payloadArg struct {
+	payload map[string]interface{}
+}
+
+func (a *payloadArg) Match(v driver.Value) bool {
+	raw, ok := v.([]byte)
+	return ok && json.Unmarshal(raw, &a.payload) == nil
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var link string
+	payload := &payloadArg{}
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET password_reset_token = \$1`).
+		WithArgs(sqlmock.AnyArg(), now.Add(PasswordResetTTL), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", "user.password_reset_requested", "jane@example.com", payload, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	user := &User{ID: 7, Username: "jdoe", Email: "jane@example.com", FirstName: "Jane"}
+	err := RequestPasswordReset(user, func(token string) string {
+		link = "https://app/password-reset?token=" + token
+		return link
+	}, now)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, "Reset your password", payload.payload["subject"])
+	assert.Contains(t, payload.payload["body"], "Hello Jane,")
+	assert.Contains(t, payload.payload["body"], link)
+	assert.Contains(t, payload.payload["html"], "Reset password")
+	assert.Equal(t, float64(7), payload.payload["user_id"])
+}
+
+func TestGetUserByResetTokenExpired(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT id, password_reset_expires FROM users WHERE password_reset_token = \$1`).
+		WithArgs(HashAPIKey("abc")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_reset_expires"}).AddRow(7, now.Add(-time.Minute)))
+	mock.ExpectQuery(`SELECT id, password_reset_expires FROM users`).
+		WithArgs(HashAPIKey("unknown")).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := GetUserByResetToken("abc", now)
+	assert.Equal(t, ErrPasswordResetInvalid, err)
+	_, err = GetUserByResetToken("unknown", now)
+	assert.Equal(t, ErrPasswordResetInvalid, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompletePasswordReset(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET password_reset_token = NULL`).
+		WithArgs(7, HashAPIKey("abc")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 7, Valid: true}, "user.password_reset", "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+	require.NoError(t, CompletePasswordReset(7, "abc"))
+
+	// A token used concurrently has already been cleared
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET password_reset_token = NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.Equal(t, ErrPasswordResetInvalid, CompletePasswordReset(7, "abc"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisplayName(t *testing.T) {
+	assert.Equal(t, "Jane", displayName(&User{Username: "jdoe", FirstName: " Jane "}))
+	assert.Equal(t, "jdoe", displayName(&User{Username: "jdoe"}))
+	assert.Equal(t, "jdoe", displayName(&User{Username: "jdoe", FirstName: " "}))
+}
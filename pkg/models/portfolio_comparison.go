@@ -0,0 +1,437 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ComparisonKPI is a metric that properties can be compared and ranked on
+type ComparisonKPI struct {
+	Key            string
+	Label          string
+	HigherIsBetter bool
+	Column         ReportColumn // Type of the KPI's report column
+}
+
+// ComparisonKPIs are the metrics available to the portfolio comparison report
+var ComparisonKPIs = []ComparisonKPI{
+	{Key: "occupancy", Label: "Occupancy %", HigherIsBetter: true, Column: percentColumn},
+	{Key: "revenue_per_unit", Label: "Revenue / Unit", HigherIsBetter: true, Column: currencyColumn},
+	{Key: "maintenance_cost_per_unit", Label: "Maintenance Cost / Unit", HigherIsBetter: false, Column: currencyColumn},
+	{Key: "delinquency", Label: "Delinquency %", HigherIsBetter: false, Column: percentColumn},
+}
+
+// PropertyComparison holds the period totals a property's comparison KPIs are derived from
+type PropertyComparison struct {
+	PropertyID         int
+	Name               string
+	Units              int
+	UnitMonths         int // Units multiplied by months in the period
+	OccupiedUnitMonths int // Months in the period a lease covered each unit
+	Revenue            float64
+	RentDue            float64
+	MaintenanceCost    float64
+
+	// Market comparison, set when benchmarks have been imported
+	ZipCode            string
+	PropertyType       string
+	UtilityCost        float64  // Utility bills prorated to the period
+	BenchmarkedRent    float64  // Rent of leases in force at the end of the period whose units have a market rent
+	MarketRent         float64  // Market rent of the same units
+	MarketExpenseRatio *float64 // Percentage of revenue
+}
+
+// ExpenseRatio returns operating expenses (maintenance and utilities) as a percentage of revenue,
+// rounded to two decimal places. ok is false when there was no revenue.
+func (p PropertyComparison) ExpenseRatio() (float64, bool) {
+	if p.Revenue <= 0 {
+		return 0, false
+	}
+	return math.Round((p.MaintenanceCost+p.UtilityCost)/p.Revenue*10000) / 100, true
+}
+
+// KPIValue calculates one comparison KPI, rounded to two decimal places
+func (p PropertyComparison) KPIValue(kpi string) float64 {
+	var value float64
+	switch kpi {
+	case "occupancy":
+		if p.UnitMonths > 0 {
+			value = float64(p.OccupiedUnitMonths) / float64(p.UnitMonths) * 100
+		}
+	case "revenue_per_unit":
+		if p.Units > 0 {
+			value = p.Revenue / float64(p.Units)
+		}
+	case "maintenance_cost_per_unit":
+		if p.Units > 0 {
+			value = p.MaintenanceCost / float64(p.Units)
+		}
+	case "delinquency":
+		if p.RentDue > 0 {
+			value = math.Max(0, p.RentDue-p.Revenue) / p.RentDue * 100
+		}
+	}
+	return math.Round(value*100) / 100
+}
+
+// RankValues assigns competition ranks (1 is best; ties share a rank and skip the next)
+func RankValues(values []float64, higherIsBetter bool) []int {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if higherIsBetter {
+			return values[order[a]] > values[order[b]]
+		}
+		return values[order[a]] < values[order[b]]
+	})
+
+	ranks := make([]int, len(values))
+	for pos, idx := range order {
+		if pos > 0 && values[idx] == values[order[pos-1]] {
+			ranks[idx] = ranks[order[pos-1]]
+		} else {
+			ranks[idx] = pos + 1
+		}
+	}
+	return ranks
+}
+
+// NormalizeScores scales values to 0-100 within the set, where 100 is the best value
+func NormalizeScores(values []float64, higherIsBetter bool) []float64 {
+	scores := make([]float64, len(values))
+	if len(values) == 0 {
+		return scores
+	}
+
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+
+	for i, v := range values {
+		if hi == lo {
+			scores[i] = 100
+			continue
+		}
+		score := (v - lo) / (hi - lo) * 100
+		if !higherIsBetter {
+			score = 100 - score
+		}
+		scores[i] = math.Round(score*10) / 10
+	}
+	return scores
+}
+
+// selectedComparisonKPIs returns the KPIs named in parameters["kpis"] or criteria["kpis"], or all of them
+func selectedComparisonKPIs(report *CustomReport, parameters map[string]interface{}) []ComparisonKPI {
+	requested, ok := parameters["kpis"].([]interface{})
+	if !ok {
+		requested, _ = report.Criteria["kpis"].([]interface{})
+	}
+	if len(requested) == 0 {
+		return ComparisonKPIs
+	}
+
+	var kpis []ComparisonKPI
+	for _, kpi := range ComparisonKPIs {
+		for _, name := range requested {
+			if name == kpi.Key {
+				kpis = append(kpis, kpi)
+				break
+			}
+		}
+	}
+	if len(kpis) == 0 {
+		return ComparisonKPIs
+	}
+	return kpis
+}
+
+// generatePortfolioComparisonReport lines up the selected properties (criteria property_ids, default
+// all) across the chosen KPIs for the period start_date to end_date (default the last three months)
+func generatePortfolioComparisonReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	endDate := time.Now()
+	startDate := PeriodStart(endDate).AddDate(0, -2, 0)
+
+	if start, ok := parameters["start_date"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", start); err == nil {
+			startDate = parsed
+		}
+	}
+	if end, ok := parameters["end_date"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", end); err == nil {
+			endDate = parsed
+		}
+	}
+
+	properties, err := loadPropertyComparisons(report, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildPortfolioComparison(properties, selectedComparisonKPIs(report, parameters), startDate, endDate), nil
+}
+
+// loadPropertyComparisons gathers units, occupancy, rent and maintenance totals per property
+func loadPropertyComparisons(report *CustomReport, startDate, endDate time.Time) ([]PropertyComparison, error) {
+	// The filter is numbered after the period's $1 and $2 in every query except the unit count
+	var propertyIDs []interface{}
+	if ids, ok := report.Criteria["property_ids"].([]interface{}); ok {
+		propertyIDs = ids
+	}
+	propertyFilter := func(offset int) string {
+		if len(propertyIDs) == 0 {
+			return ""
+		}
+		placeholders := make([]string, len(propertyIDs))
+		for i := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", offset+i+1)
+		}
+		return fmt.Sprintf(" AND p.id IN (%s)", strings.Join(placeholders, ", "))
+	}
+	args := append([]interface{}{startDate, endDate}, propertyIDs...)
+
+	unitRows, err := db.ReadDB().Query(fmt.Sprintf(`
+		SELECT p.id, p.name, p.address, p.property_type, COUNT(pu.id)
+		FROM properties p
+		LEFT JOIN property_units pu ON pu.property_id = p.id
+		WHERE 1=1%s
+		GROUP BY p.id, p.name, p.address, p.property_type
+		ORDER BY p.name`, propertyFilter(0)), propertyIDs...)
+	if err != nil {
+		return nil, err
+	}
+	defer unitRows.Close()
+
+	var properties []PropertyComparison
+	index := map[int]int{}
+	months := MonthsInRange(startDate, endDate)
+	for unitRows.Next() {
+		var p PropertyComparison
+		var address string
+		if err := unitRows.Scan(&p.PropertyID, &p.Name, &address, &p.PropertyType, &p.Units); err != nil {
+			return nil, err
+		}
+		p.ZipCode = ZipCodeFromAddress(address)
+		p.UnitMonths = p.Units * len(months)
+		index[p.PropertyID] = len(properties)
+		properties = append(properties, p)
+	}
+
+	// Occupancy and rent due, net of concessions, from leases overlapping the period
+	leases, err := loadScheduledLeases(db.ReadDB(), startDate, endDate, propertyIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, lease := range leases {
+		i, ok := index[lease.PropertyID]
+		if !ok {
+			continue
+		}
+		for _, month := range months {
+			if rent, concession := lease.RentForMonth(month); rent > 0 {
+				properties[i].OccupiedUnitMonths++
+				properties[i].RentDue += rent - concession
+			}
+		}
+	}
+
+	totals := []struct {
+		query string
+		apply func(p *PropertyComparison, v float64)
+	}{
+		{`
+			SELECT p.id, COALESCE(SUM(pay.amount), 0)
+			FROM payments pay
+			JOIN leases l ON pay.lease_id = l.id
+			JOIN property_units pu ON l.unit_id = pu.id
+			JOIN properties p ON pu.property_id = p.id
+			WHERE pay.status = 'completed' AND pay.payment_date >= $1 AND pay.payment_date <= $2%s
+			GROUP BY p.id`, func(p *PropertyComparison, v float64) { p.Revenue = v }},
+		{`
+			SELECT p.id, COALESCE(SUM(mr.actual_cost), 0)
+			FROM maintenance_requests mr
+			JOIN properties p ON mr.property_id = p.id
+			WHERE mr.completed_date >= $1 AND mr.completed_date <= $2%s
+			GROUP BY p.id`, func(p *PropertyComparison, v float64) { p.MaintenanceCost = v }},
+	}
+
+	for _, total := range totals {
+		rows, err := db.ReadDB().Query(fmt.Sprintf(total.query, propertyFilter(2)), args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var propertyID int
+			var value float64
+			if err := rows.Scan(&propertyID, &value); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if i, ok := index[propertyID]; ok {
+				total.apply(&properties[i], value)
+			}
+		}
+		rows.Close()
+	}
+
+	if err := attachMarketComparisons(properties, index, startDate, endDate, propertyIDs); err != nil {
+		return nil, err
+	}
+	return properties, nil
+}
+
+// buildPortfolioComparison ranks the properties on each KPI and overall, and charts their scores
+func buildPortfolioComparison(properties []PropertyComparison, kpis []ComparisonKPI, startDate, endDate time.Time) *ReportData {
+	data := &ReportData{
+		Headers: []string{"Property"},
+		Rows:    []map[string]interface{}{},
+		Summary: map[string]interface{}{
+			"period_start":    startDate.Format("2006-01-02"),
+			"period_end":      endDate.Format("2006-01-02"),
+			"property_count":  len(properties),
+			"best_by_kpi":     map[string]string{},
+			"portfolio_value": map[string]float64{},
+		},
+	}
+
+	rows := make([]map[string]interface{}, len(properties))
+	labels := make([]interface{}, len(properties))
+	for i, p := range properties {
+		rows[i] = map[string]interface{}{"Property": p.Name}
+		labels[i] = p.Name
+	}
+
+	rankSums := make([]float64, len(properties))
+	scores := make([][]float64, len(kpis))
+	for k, kpi := range kpis {
+		data.Headers = append(data.Headers, kpi.Label, kpi.Label+" Rank")
+
+		values := make([]float64, len(properties))
+		for i, p := range properties {
+			values[i] = p.KPIValue(kpi.Key)
+		}
+		ranks := RankValues(values, kpi.HigherIsBetter)
+		scores[k] = NormalizeScores(values, kpi.HigherIsBetter)
+
+		for i := range properties {
+			rows[i][kpi.Label] = values[i]
+			rows[i][kpi.Label+" Rank"] = ranks[i]
+			rankSums[i] += float64(ranks[i])
+			if ranks[i] == 1 {
+				if _, set := data.Summary["best_by_kpi"].(map[string]string)[kpi.Key]; !set {
+					data.Summary["best_by_kpi"].(map[string]string)[kpi.Key] = properties[i].Name
+				}
+			}
+		}
+
+		data.Summary["portfolio_value"].(map[string]float64)[kpi.Key] = portfolioKPIValue(properties, kpi.Key)
+	}
+
+	addMarketComparisonColumns(data, rows, properties)
+
+	// Overall rank is by average rank across the chosen KPIs
+	overall := RankValues(rankSums, false)
+	data.Headers = append(data.Headers, "Overall Rank")
+	for i := range rows {
+		rows[i]["Overall Rank"] = overall[i]
+	}
+	sort.SliceStable(rows, func(a, b int) bool {
+		return rows[a]["Overall Rank"].(int) < rows[b]["Overall Rank"].(int)
+	})
+	data.Rows = rows
+
+	types := map[string]ReportColumn{
+		"Overall Rank": numberColumn, "Rent vs Market %": percentColumn, "Expense Ratio %": percentColumn,
+		"Market Expense Ratio %": percentColumn, "Expense Ratio vs Market": percentColumn,
+	}
+	for _, kpi := range kpis {
+		types[kpi.Label], types[kpi.Label+" Rank"] = kpi.Column, numberColumn
+	}
+	data.Columns = typedColumns(data.Headers, types)
+
+	if len(properties) > 0 {
+		data.Charts = portfolioComparisonCharts(properties, kpis, scores, labels)
+	}
+	return data
+}
+
+// portfolioKPIValue calculates a KPI across all compared properties combined
+func portfolioKPIValue(properties []PropertyComparison, kpi string) float64 {
+	var total PropertyComparison
+	for _, p := range properties {
+		total.Units += p.Units
+		total.UnitMonths += p.UnitMonths
+		total.OccupiedUnitMonths += p.OccupiedUnitMonths
+		total.Revenue += p.Revenue
+		total.RentDue += p.RentDue
+		total.MaintenanceCost += p.MaintenanceCost
+	}
+	return total.KPIValue(kpi)
+}
+
+// portfolioComparisonCharts builds a radar chart of normalized scores (one dataset per property)
+// and a grouped bar chart of the same scores by KPI
+func portfolioComparisonCharts(properties []PropertyComparison, kpis []ComparisonKPI, scores [][]float64, labels []interface{}) []ChartData {
+	colors := []string{"54, 162, 235", "255, 99, 132", "75, 192, 192", "255, 206, 86", "153, 102, 255", "255, 159, 64"}
+
+	kpiLabels := make([]interface{}, len(kpis))
+	for k, kpi := range kpis {
+		kpiLabels[k] = kpi.Label
+	}
+
+	radarSets := make([]map[string]interface{}, len(properties))
+	for i, p := range properties {
+		data := make([]interface{}, len(kpis))
+		for k := range kpis {
+			data[k] = scores[k][i]
+		}
+		color := colors[i%len(colors)]
+		radarSets[i] = map[string]interface{}{
+			"label":           p.Name,
+			"data":            data,
+			"backgroundColor": fmt.Sprintf("rgba(%s, 0.2)", color),
+			"borderColor":     fmt.Sprintf("rgba(%s, 1)", color),
+		}
+	}
+
+	barSets := make([]map[string]interface{}, len(kpis))
+	for k, kpi := range kpis {
+		data := make([]interface{}, len(properties))
+		for i := range properties {
+			data[i] = scores[k][i]
+		}
+		color := colors[k%len(colors)]
+		barSets[k] = map[string]interface{}{
+			"label":           kpi.Label,
+			"data":            data,
+			"backgroundColor": fmt.Sprintf("rgba(%s, 0.6)", color),
+			"borderColor":     fmt.Sprintf("rgba(%s, 1)", color),
+			"borderWidth":     1,
+		}
+	}
+
+	scale := map[string]interface{}{"min": 0, "max": 100}
+	return []ChartData{
+		{
+			Type:   "radar",
+			Title:  "Portfolio Comparison (score, 100 = best)",
+			Data:   map[string]interface{}{"labels": kpiLabels, "datasets": radarSets},
+			Config: map[string]interface{}{"scales": map[string]interface{}{"r": scale}},
+		},
+		{
+			Type:   "bar",
+			Title:  "KPI Scores by Property",
+			Data:   map[string]interface{}{"labels": labels, "datasets": barSets},
+			Config: map[string]interface{}{"scales": map[string]interface{}{"y": scale}},
+		},
+	}
+}
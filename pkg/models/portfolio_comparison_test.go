@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropertyComparisonKPIValue(t *testing.T) {
+	p := PropertyComparison{
+		Units:              4,
+		UnitMonths:         12,
+		OccupiedUnitMonths: 10,
+		Revenue:            9000,
+		RentDue:            10000,
+		MaintenanceCost:    1250,
+	}
+
+	assert.Equal(t, 83.33, p.KPIValue("occupancy"))
+	assert.Equal(t, 2250.0, p.KPIValue("revenue_per_unit"))
+	assert.Equal(t, 312.5, p.KPIValue("maintenance_cost_per_unit"))
+	assert.Equal(t, 10.0, p.KPIValue("delinquency"))
+
+	// Overpayment is not negative delinquency, and empty properties score zero
+	p.Revenue = 12000
+	assert.Equal(t, 0.0, p.KPIValue("delinquency"))
+	assert.Equal(t, 0.0, PropertyComparison{}.KPIValue("occupancy"))
+}
+
+func TestRankValues(t *testing.T) {
+	assert.Equal(t, []int{2, 1, 2, 4}, RankValues([]float64{80, 95, 80, 60}, true))
+	assert.Equal(t, []int{3, 1, 2}, RankValues([]float64{30, 10, 20}, false))
+	assert.Empty(t, RankValues(nil, true))
+}
+
+func TestNormalizeScores(t *testing.T) {
+	assert.Equal(t, []float64{100, 0, 50}, NormalizeScores([]float64{90, 70, 80}, true))
+	assert.Equal(t, []float64{0, 100, 50}, NormalizeScores([]float64{90, 70, 80}, false))
+	assert.Equal(t, []float64{100, 100}, NormalizeScores([]float64{5, 5}, true))
+}
+
+func TestBuildPortfolioComparison(t *testing.T) {
+	properties := []PropertyComparison{
+		{PropertyID: 1, Name: "Oak", Units: 2, UnitMonths: 6, OccupiedUnitMonths: 3, Revenue: 3000, RentDue: 4000, MaintenanceCost: 800},
+		{PropertyID: 2, Name: "Pine", Units: 2, UnitMonths: 6, OccupiedUnitMonths: 6, Revenue: 6000, RentDue: 6000, MaintenanceCost: 200},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	data := buildPortfolioComparison(properties, ComparisonKPIs, start, end)
+
+	assert.Equal(t, "Property", data.Headers[0])
+	assert.Equal(t, "Overall Rank", data.Headers[len(data.Headers)-1])
+	assert.Len(t, data.Headers, 2+2*len(ComparisonKPIs))
+
+	// Pine wins every KPI so it is listed first
+	assert.Equal(t, "Pine", data.Rows[0]["Property"])
+	assert.Equal(t, 1, data.Rows[0]["Overall Rank"])
+	assert.Equal(t, 100.0, data.Rows[0]["Occupancy %"])
+	assert.Equal(t, 2, data.Rows[1]["Delinquency % Rank"])
+
+	assert.Equal(t, "Pine", data.Summary["best_by_kpi"].(map[string]string)["occupancy"])
+	assert.Equal(t, 75.0, data.Summary["portfolio_value"].(map[string]float64)["occupancy"])
+	assert.Equal(t, 250.0, data.Summary["portfolio_value"].(map[string]float64)["maintenance_cost_per_unit"])
+
+	assert.Len(t, data.Charts, 2)
+	assert.Equal(t, "radar", data.Charts[0].Type)
+	assert.Equal(t, "bar", data.Charts[1].Type)
+
+	empty := buildPortfolioComparison(nil, ComparisonKPIs, start, end)
+	assert.Empty(t, empty.Rows)
+	assert.Empty(t, empty.Charts)
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Supported themes
+const (
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+	ThemeSystem = "system"
+)
+
+// KPI digest frequencies. An unset frequency means weekly.
+const (
+	DigestWeekly  = "weekly"
+	DigestMonthly = "monthly"
+	DigestOff     = "off"
+)
+
+// dateLayouts maps the supported date format patterns to Go layouts
+var dateLayouts = map[string]string{
+	"YYYY-MM-DD":  "2006-01-02",
+	"MM/DD/YYYY":  "01/02/2006",
+	"DD/MM/YYYY":  "02/01/2006",
+	"DD.MM.YYYY":  "02.01.2006",
+	"MMM D, YYYY": "Jan 2, 2006",
+	"D MMM YYYY":  "2 Jan 2006",
+}
+
+// localePattern accepts language or language-region tags such as "en" or "en-US"
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// commaDecimalLanguages write numbers with a decimal comma
+var commaDecimalLanguages = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "nl": true, "pt": true,
+	"pl": true, "ru": true, "sv": true, "da": true, "fi": true, "nb": true,
+	"cs": true, "tr": true,
+}
+
+// UserPreferences holds the UI settings a user can persist
+type UserPreferences struct {
+	Theme              string `json:"theme"`
+	Locale             string `json:"locale"`
+	DefaultDashboardID int    `json:"default_dashboard_id,omitempty"` // 0 uses the standard dashboard
+	DateFormat         string `json:"date_format"`
+	KPIDigest          string `json:"kpi_digest,omitempty"` // Empty means weekly
+}
+
+// DefaultPreferences returns the settings used for anything a user has not set
+func DefaultPreferences() UserPreferences {
+	return UserPreferences{Theme: ThemeLight, Locale: "en-US", DateFormat: "YYYY-MM-DD"}
+}
+
+// DateFormats returns the supported date format patterns
+func DateFormats() []string {
+	formats := make([]string, 0, len(dateLayouts))
+	for format := range dateLayouts {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Validate checks that every setting has a supported value
+func (p UserPreferences) Validate() error {
+	switch p.Theme {
+	case ThemeLight, ThemeDark, ThemeSystem:
+	default:
+		return fmt.Errorf("theme must be light, dark or system")
+	}
+	if !localePattern.MatchString(p.Locale) {
+		return fmt.Errorf("locale must be a language tag such as en or en-US")
+	}
+	if _, ok := dateLayouts[p.DateFormat]; !ok {
+		return fmt.Errorf("date_format must be one of %s", strings.Join(DateFormats(), ", "))
+	}
+	if p.DefaultDashboardID < 0 {
+		return fmt.Errorf("default_dashboard_id must not be negative")
+	}
+	switch p.KPIDigest {
+	case "", DigestWeekly, DigestMonthly, DigestOff:
+	default:
+		return fmt.Errorf("kpi_digest must be weekly, monthly or off")
+	}
+	return nil
+}
+
+// DigestFrequency returns how often the user receives the KPI digest email
+func (p UserPreferences) DigestFrequency() string {
+	switch p.KPIDigest {
+	case DigestMonthly, DigestOff:
+		return p.KPIDigest
+	}
+	return DigestWeekly
+}
+
+// FormatDate formats t using the preferred date format
+func (p UserPreferences) FormatDate(t time.Time) string {
+	layout, ok := dateLayouts[p.DateFormat]
+	if !ok {
+		layout = dateLayouts[DefaultPreferences().DateFormat]
+	}
+	return t.Format(layout)
+}
+
+// Language returns the language part of the locale, e.g. "de" for "de-DE"
+func (p UserPreferences) Language() string {
+	language, _, _ := strings.Cut(p.Locale, "-")
+	return language
+}
+
+// DecimalSeparator returns the decimal separator used by the locale
+func (p UserPreferences) DecimalSeparator() string {
+	if commaDecimalLanguages[p.Language()] {
+		return ","
+	}
+	return "."
+}
+
+// parsePreferences overlays stored JSON on the defaults so missing keys keep their default
+func parsePreferences(stored sql.NullString) (UserPreferences, error) {
+	prefs := DefaultPreferences()
+	if !stored.Valid || stored.String == "" {
+		return prefs, nil
+	}
+	if err := json.Unmarshal([]byte(stored.String), &prefs); err != nil {
+		return DefaultPreferences(), err
+	}
+	// Fall back individually for values that are no longer supported
+	defaults := DefaultPreferences()
+	switch prefs.Theme {
+	case ThemeLight, ThemeDark, ThemeSystem:
+	default:
+		prefs.Theme = defaults.Theme
+	}
+	if !localePattern.MatchString(prefs.Locale) {
+		prefs.Locale = defaults.Locale
+	}
+	if _, ok := dateLayouts[prefs.DateFormat]; !ok {
+		prefs.DateFormat = defaults.DateFormat
+	}
+	switch prefs.KPIDigest {
+	case DigestWeekly, DigestMonthly, DigestOff:
+	default:
+		prefs.KPIDigest = defaults.KPIDigest
+	}
+	return prefs, nil
+}
+
+// GetUserPreferences returns a user's preferences merged with the defaults
+func GetUserPreferences(userID int) (UserPreferences, error) {
+	var stored sql.NullString
+	if err := db.DB.QueryRow("SELECT preferences FROM users WHERE id = $1", userID).Scan(&stored); err != nil {
+		return DefaultPreferences(), err
+	}
+	return parsePreferences(stored)
+}
+
+// SaveUserPreferences validates and stores a user's preferences
+func SaveUserPreferences(userID int, prefs UserPreferences) error {
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	result, err := db.DB.Exec("UPDATE users SET preferences = $1, updated_at = NOW() WHERE id = $2", string(data), userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserPreferencesValidate(t *testing.T) {
+	assert.NoError(t, DefaultPreferences().Validate())
+	assert.NoError(t, UserPreferences{Theme: ThemeDark, Locale: "de", DateFormat: "DD.MM.YYYY", DefaultDashboardID: 3}.Validate())
+
+	assert.Error(t, UserPreferences{Theme: "neon", Locale: "en-US", DateFormat: "YYYY-MM-DD"}.Validate())
+	assert.Error(t, UserPreferences{Theme: ThemeLight, Locale: "english", DateFormat: "YYYY-MM-DD"}.Validate())
+	assert.Error(t, UserPreferences{Theme: ThemeLight, Locale: "en-US", DateFormat: "%d/%m"}.Validate())
+	assert.Error(t, UserPreferences{Theme: ThemeLight, Locale: "en-US", DateFormat: "YYYY-MM-DD", DefaultDashboardID: -1}.Validate())
+	assert.Error(t, UserPreferences{Theme: ThemeLight, Locale: "en-US", DateFormat: "YYYY-MM-DD", KPIDigest: "daily"}.Validate())
+	assert.NoError(t, UserPreferences{Theme: ThemeLight, Locale: "en-US", DateFormat: "YYYY-MM-DD", KPIDigest: DigestOff}.Validate())
+	assert.Equal(t, DigestWeekly, DefaultPreferences().DigestFrequency())
+}
+
+func TestUserPreferencesFormatting(t *testing.T) {
+	date := time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "2024-03-07", DefaultPreferences().FormatDate(date))
+	assert.Equal(t, "03/07/2024", UserPreferences{DateFormat: "MM/DD/YYYY"}.FormatDate(date))
+	assert.Equal(t, "7 Mar 2024", UserPreferences{DateFormat: "D MMM YYYY"}.FormatDate(date))
+	assert.Equal(t, "2024-03-07", UserPreferences{DateFormat: "unknown"}.FormatDate(date))
+
+	assert.Equal(t, ".", DefaultPreferences().DecimalSeparator())
+	assert.Equal(t, ",", UserPreferences{Locale: "de-DE"}.DecimalSeparator())
+}
+
+func TestParsePreferencesFallsBackToDefaults(t *testing.T) {
+	prefs, err := parsePreferences(sql.NullString{})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultPreferences(), prefs)
+
+	prefs, err = parsePreferences(sql.NullString{String: `{"theme":"dark","date_format":"bogus"}`, Valid: true})
+	assert.NoError(t, err)
+	assert.Equal(t, UserPreferences{Theme: ThemeDark, Locale: "en-US", DateFormat: "YYYY-MM-DD"}, prefs)
+}
+
+func TestSaveUserPreferences(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	prefs := UserPreferences{Theme: ThemeDark, Locale: "fr-FR", DateFormat: "DD/MM/YYYY"}
+	mock.ExpectExec(`UPDATE users SET preferences`).
+		WithArgs(`{"theme":"dark","locale":"fr-FR","date_format":"DD/MM/YYYY"}`, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, SaveUserPreferences(5, prefs))
+	assert.Error(t, SaveUserPreferences(5, UserPreferences{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,347 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// MaintenancePlan is a recurring preventive maintenance task for a property or unit
+type MaintenancePlan struct {
+	ID           int            `json:"id"`
+	PropertyID   int            `json:"property_id"`
+	UnitID       sql.NullInt32  `json:"unit_id,omitempty"`
+	Title        string         `json:"title"`
+	Description  sql.NullString `json:"description,omitempty"`
+	IntervalDays int            `json:"interval_days"`
+	Priority     string         `json:"priority"`
+	NextDueDate  time.Time      `json:"next_due_date"`
+	Active       bool           `json:"active"`
+	CreatedBy    sql.NullInt32  `json:"created_by,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// MaintenanceCalendarEvent is a scheduled or generated preventive task on the calendar
+type MaintenanceCalendarEvent struct {
+	PlanID     int       `json:"plan_id"`
+	PropertyID int       `json:"property_id"`
+	UnitID     *int      `json:"unit_id,omitempty"`
+	Title      string    `json:"title"`
+	DueDate    time.Time `json:"due_date"`
+	RequestID  *int      `json:"request_id,omitempty"` // Set once the request has been generated
+	Status     string    `json:"status"`               // 'scheduled' or the request status
+}
+
+// PreventiveCompliance is the completion compliance KPI for preventive maintenance
+type PreventiveCompliance struct {
+	TotalDue        int     `json:"total_due"`
+	CompletedOnTime int     `json:"completed_on_time"`
+	CompletedLate   int     `json:"completed_late"`
+	OpenOverdue     int     `json:"open_overdue"`
+	ComplianceRate  float64 `json:"compliance_rate"` // Percentage completed on or before the due date
+}
+
+// AdvanceDueDate returns the first due date after now, stepping from due by intervalDays.
+// Missed cycles are skipped so that an outage does not create a backlog of duplicate requests.
+func AdvanceDueDate(due time.Time, intervalDays int, now time.Time) time.Time {
+	next := due.AddDate(0, 0, intervalDays)
+	for !next.After(now) {
+		next = next.AddDate(0, 0, intervalDays)
+	}
+	return next
+}
+
+// ProjectPlanOccurrences lists the due dates of a plan between start and end, inclusive
+func ProjectPlanOccurrences(nextDue time.Time, intervalDays int, start, end time.Time) []time.Time {
+	var dates []time.Time
+	if intervalDays <= 0 {
+		return dates
+	}
+	for due := nextDue; !due.After(end); due = due.AddDate(0, 0, intervalDays) {
+		if !due.Before(start) {
+			dates = append(dates, due)
+		}
+	}
+	return dates
+}
+
+// CreateMaintenancePlan creates a preventive maintenance plan
+func CreateMaintenancePlan(plan *MaintenancePlan) error {
+	if plan.Priority == "" {
+		plan.Priority = "low"
+	}
+
+	query := `
+		INSERT INTO maintenance_plans (property_id, unit_id, title, description, interval_days,
+									   priority, next_due_date, active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at`
+
+	return db.DB.QueryRow(query, plan.PropertyID, plan.UnitID, plan.Title, plan.Description,
+		plan.IntervalDays, plan.Priority, plan.NextDueDate, plan.Active, plan.CreatedBy).
+		Scan(&plan.ID, &plan.CreatedAt, &plan.UpdatedAt)
+}
+
+// UpdateMaintenancePlan updates a plan. It returns sql.ErrNoRows if the plan does not exist.
+func UpdateMaintenancePlan(plan *MaintenancePlan) error {
+	query := `
+		UPDATE maintenance_plans
+		SET unit_id = $2, title = $3, description = $4, interval_days = $5, priority = $6,
+			next_due_date = $7, active = $8, updated_at = NOW()
+		WHERE id = $1`
+
+	result, err := db.DB.Exec(query, plan.ID, plan.UnitID, plan.Title, plan.Description,
+		plan.IntervalDays, plan.Priority, plan.NextDueDate, plan.Active)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetMaintenancePlanPropertyID returns the property a plan schedules work at
+func GetMaintenancePlanPropertyID(id int) (int, error) {
+	var propertyID int
+	err := db.DB.QueryRow("SELECT property_id FROM maintenance_plans WHERE id = $1", id).Scan(&propertyID)
+	return propertyID, err
+}
+
+// GetMaintenancePlans retrieves the plans at properties
+func GetMaintenancePlans(properties PropertyFilter) ([]MaintenancePlan, error) {
+	scope, args := properties.condition("property_id", nil)
+	query := `
+		SELECT id, property_id, unit_id, title, description, interval_days, priority,
+			   next_due_date, active, created_by, created_at, updated_at
+		FROM maintenance_plans
+		WHERE 1 = 1` + scope + `
+		ORDER BY next_due_date`
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plans []MaintenancePlan
+	for rows.Next() {
+		var plan MaintenancePlan
+		err := rows.Scan(&plan.ID, &plan.PropertyID, &plan.UnitID, &plan.Title, &plan.Description,
+			&plan.IntervalDays, &plan.Priority, &plan.NextDueDate, &plan.Active, &plan.CreatedBy,
+			&plan.CreatedAt, &plan.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+// GenerateDuePreventiveRequests creates a maintenance request for every active plan due on or
+// before now and advances the plan to its next due date. It returns the number of requests created.
+//
+// Several server instances may run it at once: a plan is only advanced from the due date that was
+// read, and the unique (plan_id, due_date) index drops a second request for the same occurrence.
+func GenerateDuePreventiveRequests(now time.Time) (int, error) {
+	rows, err := db.DB.Query(`
+		SELECT mp.id, mp.property_id, mp.title, mp.description, mp.interval_days, mp.priority,
+			   mp.next_due_date, pu.unit_number
+		FROM maintenance_plans mp
+		LEFT JOIN property_units pu ON mp.unit_id = pu.id
+		WHERE mp.active = TRUE AND mp.next_due_date <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+
+	type duePlan struct {
+		MaintenancePlan
+		unitNumber sql.NullString
+	}
+	var due []duePlan
+	for rows.Next() {
+		var plan duePlan
+		err := rows.Scan(&plan.ID, &plan.PropertyID, &plan.Title, &plan.Description,
+			&plan.IntervalDays, &plan.Priority, &plan.NextDueDate, &plan.unitNumber)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, plan)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, plan := range due {
+		description := "[Preventive] " + plan.Title
+		if plan.unitNumber.Valid {
+			description += fmt.Sprintf(" (Unit %s)", plan.unitNumber.String)
+		}
+		if plan.Description.Valid {
+			description += ": " + plan.Description.String
+		}
+
+		inserted, err := generatePreventiveRequest(plan.MaintenancePlan, description, now)
+		if err != nil {
+			return created, err
+		}
+		if inserted {
+			created++
+		}
+	}
+
+	return created, nil
+}
+
+// generatePreventiveRequest advances a due plan and creates its request in one transaction. It
+// reports false when another scheduler already advanced the plan or created the request.
+func generatePreventiveRequest(plan MaintenancePlan, description string, now time.Time) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE maintenance_plans SET next_due_date = $2, updated_at = NOW()
+		WHERE id = $1 AND next_due_date = $3`,
+		plan.ID, AdvanceDueDate(plan.NextDueDate, plan.IntervalDays, now), plan.NextDueDate)
+	if err != nil {
+		return false, err
+	}
+	if err := requireAffected(result); err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	result, err = tx.Exec(`
+		INSERT INTO maintenance_requests (property_id, description, status, priority,
+										  reported_date, plan_id, due_date)
+		VALUES ($1, $2, 'reported', $3, $4, $5, $4)
+		ON CONFLICT (plan_id, due_date) DO NOTHING`,
+		plan.PropertyID, description, plan.Priority, plan.NextDueDate, plan.ID)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, tx.Commit()
+}
+
+// GetMaintenanceCalendar returns generated preventive requests and projected plan occurrences
+// between start and end at properties
+func GetMaintenanceCalendar(start, end time.Time, properties PropertyFilter) ([]MaintenanceCalendarEvent, error) {
+	events := []MaintenanceCalendarEvent{}
+
+	// Requests already generated from plans
+	scope, args := properties.condition("mp.property_id", []interface{}{start, end})
+	query := `
+		SELECT mp.id, mp.property_id, mp.unit_id, mp.title, mr.due_date, mr.id, mr.status
+		FROM maintenance_requests mr
+		JOIN maintenance_plans mp ON mr.plan_id = mp.id
+		WHERE mr.due_date >= $1 AND mr.due_date <= $2` + scope + `
+		ORDER BY mr.due_date`
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event MaintenanceCalendarEvent
+		var unitID sql.NullInt32
+		var requestID int
+		err := rows.Scan(&event.PlanID, &event.PropertyID, &unitID, &event.Title, &event.DueDate,
+			&requestID, &event.Status)
+		if err != nil {
+			return nil, err
+		}
+		if unitID.Valid {
+			id := int(unitID.Int32)
+			event.UnitID = &id
+		}
+		event.RequestID = &requestID
+		events = append(events, event)
+	}
+
+	// Future occurrences of active plans
+	plans, err := GetMaintenancePlans(properties)
+	if err != nil {
+		return nil, err
+	}
+	for _, plan := range plans {
+		if !plan.Active {
+			continue
+		}
+		for _, due := range ProjectPlanOccurrences(plan.NextDueDate, plan.IntervalDays, start, end) {
+			event := MaintenanceCalendarEvent{
+				PlanID:     plan.ID,
+				PropertyID: plan.PropertyID,
+				Title:      plan.Title,
+				DueDate:    due,
+				Status:     "scheduled",
+			}
+			if plan.UnitID.Valid {
+				id := int(plan.UnitID.Int32)
+				event.UnitID = &id
+			}
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// GetPreventiveCompliance calculates how many preventive requests due between start and end
+// at properties were completed on or before their due date
+func GetPreventiveCompliance(start, end time.Time, properties PropertyFilter) (*PreventiveCompliance, error) {
+	scope, args := properties.condition("mr.property_id", []interface{}{start, end})
+	query := `
+		SELECT mr.due_date, mr.completed_date
+		FROM maintenance_requests mr
+		WHERE mr.plan_id IS NOT NULL AND mr.due_date >= $1 AND mr.due_date <= $2` + scope
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	today := time.Now()
+	compliance := &PreventiveCompliance{}
+	for rows.Next() {
+		var dueDate time.Time
+		var completedDate sql.NullTime
+		if err := rows.Scan(&dueDate, &completedDate); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case completedDate.Valid && !completedDate.Time.After(dueDate):
+			compliance.CompletedOnTime++
+		case completedDate.Valid:
+			compliance.CompletedLate++
+		case today.After(dueDate):
+			compliance.OpenOverdue++
+		default:
+			// Not yet due; excluded from the rate
+			continue
+		}
+		compliance.TotalDue++
+	}
+
+	if compliance.TotalDue > 0 {
+		compliance.ComplianceRate = math.Round(float64(compliance.CompletedOnTime)/float64(compliance.TotalDue)*10000) / 100
+	}
+
+	return compliance, nil
+}
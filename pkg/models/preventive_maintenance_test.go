@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdvanceDueDate(t *testing.T) {
+	due := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// On schedule: next cycle
+	assert.Equal(t, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		AdvanceDueDate(due, 90, time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)))
+
+	// Several cycles missed: skip to the first future date
+	assert.Equal(t, time.Date(2024, 6, 29, 0, 0, 0, 0, time.UTC),
+		AdvanceDueDate(due, 90, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestProjectPlanOccurrences(t *testing.T) {
+	nextDue := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)
+
+	dates := ProjectPlanOccurrences(nextDue, 30, start, end)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 2, 9, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 4, 9, 0, 0, 0, 0, time.UTC),
+	}, dates)
+
+	assert.Empty(t, ProjectPlanOccurrences(nextDue, 0, start, end))
+}
+
+func TestGenerateDuePreventiveRequestsSkipsPlansAdvancedElsewhere(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM maintenance_plans mp`).WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "property_id", "title", "description", "interval_days",
+			"priority", "next_due_date", "unit_number"}).
+			AddRow(1, 4, "Replace HVAC filter", nil, 90, "low", due, nil).
+			AddRow(2, 4, "Test smoke alarms", nil, 180, "medium", due, "2B"))
+
+	// Another scheduler already advanced plan 1
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE maintenance_plans SET next_due_date = \$2(.+)WHERE id = \$1 AND next_due_date = \$3`).
+		WithArgs(1, due.AddDate(0, 0, 90), due).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE maintenance_plans SET next_due_date`).
+		WithArgs(2, due.AddDate(0, 0, 180), due).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO maintenance_requests(.+)ON CONFLICT \(plan_id, due_date\) DO NOTHING`).
+		WithArgs(4, "[Preventive] Test smoke alarms (Unit 2B)", "medium", due, 2).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectCommit()
+
+	created, err := GenerateDuePreventiveRequests(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,612 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Property group types. Regions contain portfolios, and portfolios contain properties.
+const (
+	PropertyGroupRegion    = "region"
+	PropertyGroupPortfolio = "portfolio"
+)
+
+var (
+	// ErrInvalidPropertyGroup is returned when a group is missing its name or breaks the hierarchy
+	ErrInvalidPropertyGroup = errors.New("a property group needs a name and a type of region or portfolio; portfolios belong to a region and regions have no parent")
+	// ErrPropertyGroupInUse is returned when deleting a group that still contains portfolios or properties
+	ErrPropertyGroupInUse = errors.New("the group still contains portfolios or properties; move them first")
+	// ErrNotPortfolio is returned when placing a property in a group that is not a portfolio
+	ErrNotPortfolio = errors.New("properties can only be placed in a portfolio")
+)
+
+// PropertyGroup is a region or portfolio in the property hierarchy
+type PropertyGroup struct {
+	ID            int            `json:"id"`
+	ParentID      sql.NullInt32  `json:"parent_id,omitempty"` // The region a portfolio belongs to
+	GroupType     string         `json:"group_type"`
+	Name          string         `json:"name"`
+	Description   sql.NullString `json:"description,omitempty"`
+	PropertyCount int            `json:"property_count"` // Properties in the group, including its portfolios
+	PropertyIDs   []int          `json:"property_ids,omitempty"`
+	ManagerIDs    []int          `json:"manager_ids,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// Validate checks the group has a name and a known type, and that only portfolios have a parent
+func (g *PropertyGroup) Validate() error {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" {
+		return ErrInvalidPropertyGroup
+	}
+	switch g.GroupType {
+	case PropertyGroupRegion:
+		if g.ParentID.Valid {
+			return ErrInvalidPropertyGroup
+		}
+	case PropertyGroupPortfolio:
+		if !g.ParentID.Valid {
+			return ErrInvalidPropertyGroup
+		}
+	default:
+		return ErrInvalidPropertyGroup
+	}
+	return nil
+}
+
+// checkPropertyGroupParent returns sql.ErrNoRows when a portfolio's region does not exist, or
+// ErrInvalidPropertyGroup when its parent is not a region
+func checkPropertyGroupParent(q Querier, g *PropertyGroup) error {
+	if !g.ParentID.Valid {
+		return nil
+	}
+	var parentType string
+	if err := q.QueryRow("SELECT group_type FROM property_groups WHERE id = $1", g.ParentID.Int32).Scan(&parentType); err != nil {
+		return err
+	}
+	if parentType != PropertyGroupRegion {
+		return ErrInvalidPropertyGroup
+	}
+	return nil
+}
+
+// CreatePropertyGroup saves a new region or portfolio
+func CreatePropertyGroup(g *PropertyGroup) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	if err := checkPropertyGroupParent(db.DB, g); err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO property_groups (parent_id, group_type, name, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`,
+		g.ParentID, g.GroupType, g.Name, g.Description).Scan(&g.ID, &g.CreatedAt, &g.UpdatedAt)
+}
+
+// UpdatePropertyGroup renames a group or moves a portfolio to another region. A group's type
+// cannot change, since its children would no longer fit the hierarchy.
+func UpdatePropertyGroup(g *PropertyGroup) error {
+	if err := db.DB.QueryRow("SELECT group_type FROM property_groups WHERE id = $1", g.ID).Scan(&g.GroupType); err != nil {
+		return err
+	}
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	if err := checkPropertyGroupParent(db.DB, g); err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		UPDATE property_groups SET parent_id = $2, name = $3, description = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		g.ID, g.ParentID, g.Name, g.Description).Scan(&g.CreatedAt, &g.UpdatedAt)
+}
+
+// DeletePropertyGroup removes an empty group. It returns ErrPropertyGroupInUse while portfolios
+// or properties still belong to it.
+func DeletePropertyGroup(id int) error {
+	var members int
+	err := db.DB.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM property_groups WHERE parent_id = $1) +
+			   (SELECT COUNT(*) FROM properties WHERE group_id = $1)`, id).Scan(&members)
+	if err != nil {
+		return err
+	}
+	if members > 0 {
+		return ErrPropertyGroupInUse
+	}
+
+	result, err := db.DB.Exec("DELETE FROM property_groups WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+const propertyGroupColumns = `g.id, g.parent_id, g.group_type, g.name, g.description, g.created_at, g.updated_at,
+	(SELECT COUNT(*) FROM properties p JOIN property_groups pg ON p.group_id = pg.id
+	 WHERE pg.id = g.id OR pg.parent_id = g.id)`
+
+func scanPropertyGroup(row interface{ Scan(...interface{}) error }) (PropertyGroup, error) {
+	var g PropertyGroup
+	err := row.Scan(&g.ID, &g.ParentID, &g.GroupType, &g.Name, &g.Description, &g.CreatedAt, &g.UpdatedAt,
+		&g.PropertyCount)
+	return g, err
+}
+
+// GetPropertyGroups returns the groups of one type, or all of them when groupType is empty,
+// regions first and then by name
+func GetPropertyGroups(groupType string) ([]PropertyGroup, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT `+propertyGroupColumns+`
+		FROM property_groups g
+		WHERE $1 = '' OR g.group_type = $1
+		ORDER BY CASE g.group_type WHEN 'region' THEN 0 ELSE 1 END, g.name, g.id`, groupType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []PropertyGroup
+	for rows.Next() {
+		g, err := scanPropertyGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// GetPropertyGroup retrieves a group with the properties under it and its managers
+func GetPropertyGroup(id int) (*PropertyGroup, error) {
+	g, err := scanPropertyGroup(db.ReadDB().QueryRow(`
+		SELECT `+propertyGroupColumns+`
+		FROM property_groups g
+		WHERE g.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if g.PropertyIDs, err = GroupPropertyIDs(id); err != nil {
+		return nil, err
+	}
+	if g.ManagerIDs, err = queryIDs(`
+		SELECT user_id FROM property_group_managers WHERE group_id = $1 ORDER BY user_id`, id); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// queryIDs runs a query selecting a single integer column. It reads from the primary because
+// the IDs decide which properties a manager may see, which must not lag behind group changes.
+func queryIDs(query string, args ...interface{}) ([]int, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GroupPropertyIDs returns the properties in a portfolio, or in every portfolio of a region
+func GroupPropertyIDs(groupID int) ([]int, error) {
+	return queryIDs(`
+		SELECT p.id
+		FROM properties p
+		JOIN property_groups pg ON p.group_id = pg.id
+		WHERE pg.id = $1 OR pg.parent_id = $1
+		ORDER BY p.id`, groupID)
+}
+
+// SetPropertyGroup places a property in a portfolio, or removes it from its portfolio when
+// groupID is not valid. It returns sql.ErrNoRows when the property or portfolio does not exist.
+func SetPropertyGroup(propertyID int, groupID sql.NullInt32) error {
+	if groupID.Valid {
+		var groupType string
+		if err := db.DB.QueryRow("SELECT group_type FROM property_groups WHERE id = $1", groupID.Int32).Scan(&groupType); err != nil {
+			return err
+		}
+		if groupType != PropertyGroupPortfolio {
+			return ErrNotPortfolio
+		}
+	}
+
+	result, err := db.DB.Exec("UPDATE properties SET group_id = $2, updated_at = NOW() WHERE id = $1", propertyID, groupID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SetPropertyGroupManagers replaces the managers scoped to a group. It returns an
+// UnknownUsersError when any of the users do not exist.
+func SetPropertyGroupManagers(groupID int, userIDs []int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow("SELECT 1 FROM property_groups WHERE id = $1", groupID).Scan(&exists); err != nil {
+		return err
+	}
+	if err := checkBulkRoleUsers(tx, userIDs); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM property_group_managers WHERE group_id = $1", groupID); err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if _, err := tx.Exec("INSERT INTO property_group_managers (group_id, user_id) VALUES ($1, $2)", groupID, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ManagedPropertyIDs returns the properties a user may see when they are scoped to regions or
+// portfolios. scoped is false for admins, who see every property. Property managers assigned to
+// no group see every property only until managers are first assigned to groups, and none after.
+func ManagedPropertyIDs(user *User) (ids []int, scoped bool, err error) {
+	if user == nil || user.HasRole("admin") {
+		return nil, false, nil
+	}
+
+	return managedPropertyIDs(user.ID, user.HasRole("property_manager"))
+}
+
+// managedPropertyIDs returns the properties under the groups a non-admin user manages
+func managedPropertyIDs(userID int, manager bool) (ids []int, scoped bool, err error) {
+	var groups, assignments int
+	if err := db.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN user_id = $1 THEN 1 ELSE 0 END), 0)
+		FROM property_group_managers`, userID).Scan(&assignments, &groups); err != nil {
+		return nil, false, err
+	}
+	if groups == 0 {
+		// Once any manager is scoped, a manager without groups is scoped to nothing
+		if manager && assignments > 0 {
+			return []int{}, true, nil
+		}
+		return nil, false, nil
+	}
+
+	ids, err = queryIDs(`
+		SELECT DISTINCT p.id
+		FROM property_group_managers m
+		JOIN property_groups pg ON pg.id = m.group_id OR pg.parent_id = m.group_id
+		JOIN properties p ON p.group_id = pg.id
+		WHERE m.user_id = $1
+		ORDER BY p.id`, userID)
+	return ids, true, err
+}
+
+// PropertyScope resolves the properties a request may cover from an optional group filter and
+// the user's manager scope. scoped is false when every property is allowed.
+func PropertyScope(groupID int, user *User) (ids []int, scoped bool, err error) {
+	if groupID > 0 {
+		if ids, err = GroupPropertyIDs(groupID); err != nil {
+			return nil, false, err
+		}
+		scoped = true
+	}
+
+	managed, managerScoped, err := ManagedPropertyIDs(user)
+	if err != nil {
+		return nil, false, err
+	}
+	if managerScoped {
+		if scoped {
+			ids = intersectIDs(ids, managed)
+		} else {
+			ids = managed
+		}
+		scoped = true
+	}
+	return ids, scoped, nil
+}
+
+// PropertyFilter limits a query to the properties a request may cover. The zero value covers
+// every property; a scoped filter with no IDs covers none.
+type PropertyFilter struct {
+	IDs    []int
+	Scoped bool
+}
+
+// ScopeProperties returns the filter for a request from an optional group and the user's
+// manager scope
+func ScopeProperties(groupID int, user *User) (PropertyFilter, error) {
+	ids, scoped, err := PropertyScope(groupID, user)
+	return PropertyFilter{IDs: ids, Scoped: scoped}, err
+}
+
+// UnitPropertyID returns the property a unit belongs to
+func UnitPropertyID(unitID int) (int, error) {
+	var propertyID int
+	err := db.DB.QueryRow("SELECT property_id FROM property_units WHERE id = $1", unitID).Scan(&propertyID)
+	return propertyID, err
+}
+
+// Allows reports whether the filter covers a property
+func (f PropertyFilter) Allows(propertyID int) bool {
+	if !f.Scoped {
+		return true
+	}
+	for _, id := range f.IDs {
+		if id == propertyID {
+			return true
+		}
+	}
+	return false
+}
+
+// Only narrows the filter to a single property, or to none when the property is outside it
+func (f PropertyFilter) Only(propertyID int) PropertyFilter {
+	if !f.Allows(propertyID) {
+		return PropertyFilter{Scoped: true}
+	}
+	return PropertyFilter{IDs: []int{propertyID}, Scoped: true}
+}
+
+// condition returns " AND column IN (...)" binding the filter's IDs after args, or nothing
+// when every property is covered
+func (f PropertyFilter) condition(column string, args []interface{}) (string, []interface{}) {
+	if !f.Scoped {
+		return "", args
+	}
+	if len(f.IDs) == 0 {
+		return " AND 1 = 0", args
+	}
+	placeholders := make([]string, len(f.IDs))
+	for i, id := range f.IDs {
+		args = append(args, id)
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+	return fmt.Sprintf(" AND %s IN (%s)", column, strings.Join(placeholders, ", ")), args
+}
+
+// intersectIDs returns the IDs of a that are also in b, in the order of a
+func intersectIDs(a, b []int) []int {
+	in := make(map[int]bool, len(b))
+	for _, id := range b {
+		in[id] = true
+	}
+	result := []int{}
+	for _, id := range a {
+		if in[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// FilterPropertyDetails keeps the property listing rows whose property is in ids
+func FilterPropertyDetails(properties []PropertyDetail, ids []int) []PropertyDetail {
+	in := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		in[id] = true
+	}
+	filtered := []PropertyDetail{}
+	for _, p := range properties {
+		if in[p.ID] {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// propertyIDValue reads a property or group ID from decoded JSON report criteria or parameters
+func propertyIDValue(v interface{}) (int, bool) {
+	switch id := v.(type) {
+	case float64:
+		return int(id), id > 0
+	case int:
+		return id, id > 0
+	case string:
+		parsed, err := strconv.Atoi(id)
+		return parsed, err == nil && parsed > 0
+	}
+	return 0, false
+}
+
+// scopeReportProperties limits a report to the properties of the group named by the group_id
+// parameter or criterion, and to the viewer's managed properties, by narrowing its property_ids
+// criterion. Only report types that honour property_ids are affected.
+func scopeReportProperties(report *CustomReport, parameters map[string]interface{}, viewer *User) error {
+	groupID, ok := propertyIDValue(parameters["group_id"])
+	if !ok {
+		groupID, _ = propertyIDValue(report.Criteria["group_id"])
+	}
+	allowed, scoped, err := PropertyScope(groupID, viewer)
+	if err != nil || !scoped {
+		return err
+	}
+
+	if requested, ok := report.Criteria["property_ids"].([]interface{}); ok && len(requested) > 0 {
+		var ids []int
+		for _, v := range requested {
+			if id, ok := propertyIDValue(v); ok {
+				ids = append(ids, id)
+			}
+		}
+		allowed = intersectIDs(ids, allowed)
+	}
+
+	// IDs are stored as float64 like criteria decoded from JSON. An empty property_ids means every
+	// property, so an empty scope matches no property instead.
+	propertyIDs := []interface{}{float64(0)}
+	if len(allowed) > 0 {
+		propertyIDs = make([]interface{}, len(allowed))
+		for i, id := range allowed {
+			propertyIDs[i] = float64(id)
+		}
+	}
+
+	criteria := make(map[string]interface{}, len(report.Criteria)+1)
+	for k, v := range report.Criteria {
+		criteria[k] = v
+	}
+	criteria["property_ids"] = propertyIDs
+	report.Criteria = criteria
+	return nil
+}
+
+// reportProperties returns the filter for a report's property_ids criterion, which covers every
+// property when it is empty
+func reportProperties(report *CustomReport) PropertyFilter {
+	requested, _ := report.Criteria["property_ids"].([]interface{})
+	if len(requested) == 0 {
+		return PropertyFilter{}
+	}
+	filter := PropertyFilter{Scoped: true}
+	for _, v := range requested {
+		if id, ok := propertyIDValue(v); ok {
+			filter.IDs = append(filter.IDs, id)
+		}
+	}
+	return filter
+}
+
+// PropertyGroupRollup is the comparison KPIs of all properties in a region or portfolio combined
+type PropertyGroupRollup struct {
+	GroupID       sql.NullInt32      `json:"group_id"` // Null for properties outside any group
+	Name          string             `json:"name"`
+	PropertyCount int                `json:"property_count"`
+	Units         int                `json:"units"`
+	Revenue       float64            `json:"revenue"`
+	KPIs          map[string]float64 `json:"kpis"`
+}
+
+// propertyGroupMembership is the portfolio and region a property belongs to
+type propertyGroupMembership struct {
+	PortfolioID   int
+	PortfolioName string
+	RegionID      sql.NullInt32
+	RegionName    sql.NullString
+}
+
+// GetPropertyGroupRollup aggregates the comparison KPIs per region or portfolio for the period,
+// limited to propertyIDs when scoped
+func GetPropertyGroupRollup(groupType string, startDate, endDate time.Time, propertyIDs []int, scoped bool) ([]PropertyGroupRollup, error) {
+	if groupType != PropertyGroupRegion && groupType != PropertyGroupPortfolio {
+		return nil, ErrInvalidPropertyGroup
+	}
+	if scoped && len(propertyIDs) == 0 {
+		return []PropertyGroupRollup{}, nil
+	}
+
+	criteria := map[string]interface{}{}
+	if scoped {
+		ids := make([]interface{}, len(propertyIDs))
+		for i, id := range propertyIDs {
+			ids[i] = id
+		}
+		criteria["property_ids"] = ids
+	}
+	comparisons, err := loadPropertyComparisons(&CustomReport{Criteria: criteria}, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.ReadDB().Query(`
+		SELECT p.id, pg.id, pg.name, parent.id, parent.name
+		FROM properties p
+		JOIN property_groups pg ON p.group_id = pg.id
+		LEFT JOIN property_groups parent ON pg.parent_id = parent.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	membership := map[int]propertyGroupMembership{}
+	for rows.Next() {
+		var propertyID int
+		var m propertyGroupMembership
+		if err := rows.Scan(&propertyID, &m.PortfolioID, &m.PortfolioName, &m.RegionID, &m.RegionName); err != nil {
+			return nil, err
+		}
+		membership[propertyID] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rollUpComparisons(comparisons, membership, groupType), nil
+}
+
+// rollUpComparisons combines property comparisons per region or portfolio, ordered by name with
+// ungrouped properties last
+func rollUpComparisons(comparisons []PropertyComparison, membership map[int]propertyGroupMembership, groupType string) []PropertyGroupRollup {
+	type bucket struct {
+		rollup     PropertyGroupRollup
+		properties []PropertyComparison
+	}
+	buckets := map[int]*bucket{} // Keyed by group ID, 0 for ungrouped properties
+
+	for _, p := range comparisons {
+		key, name := 0, "Unassigned"
+		if m, ok := membership[p.PropertyID]; ok {
+			if groupType == PropertyGroupPortfolio {
+				key, name = m.PortfolioID, m.PortfolioName
+			} else if m.RegionID.Valid {
+				key, name = int(m.RegionID.Int32), m.RegionName.String
+			}
+		}
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{rollup: PropertyGroupRollup{Name: name}}
+			if key != 0 {
+				b.rollup.GroupID = sql.NullInt32{Int32: int32(key), Valid: true}
+			}
+			buckets[key] = b
+		}
+		b.properties = append(b.properties, p)
+	}
+
+	rollups := make([]PropertyGroupRollup, 0, len(buckets))
+	for _, b := range buckets {
+		r := b.rollup
+		r.PropertyCount = len(b.properties)
+		r.KPIs = make(map[string]float64, len(ComparisonKPIs))
+		for _, p := range b.properties {
+			r.Units += p.Units
+			r.Revenue += p.Revenue
+		}
+		for _, kpi := range ComparisonKPIs {
+			r.KPIs[kpi.Key] = portfolioKPIValue(b.properties, kpi.Key)
+		}
+		rollups = append(rollups, r)
+	}
+	sort.Slice(rollups, func(a, b int) bool {
+		if rollups[a].GroupID.Valid != rollups[b].GroupID.Valid {
+			return rollups[a].GroupID.Valid
+		}
+		if rollups[a].Name != rollups[b].Name {
+			return rollups[a].Name < rollups[b].Name
+		}
+		return rollups[a].GroupID.Int32 < rollups[b].GroupID.Int32
+	})
+	return rollups
+}
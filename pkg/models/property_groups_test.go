@@ -0,0 +1,160 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropertyGroupValidate(t *testing.T) {
+	region := sql.NullInt32{Int32: 1, Valid: true}
+	assert.NoError(t, (&PropertyGroup{GroupType: PropertyGroupRegion, Name: "West"}).Validate())
+	assert.NoError(t, (&PropertyGroup{GroupType: PropertyGroupPortfolio, Name: "Bay Area", ParentID: region}).Validate())
+
+	assert.Equal(t, ErrInvalidPropertyGroup, (&PropertyGroup{GroupType: PropertyGroupRegion, Name: " "}).Validate())
+	assert.Equal(t, ErrInvalidPropertyGroup, (&PropertyGroup{GroupType: PropertyGroupRegion, Name: "West", ParentID: region}).Validate())
+	assert.Equal(t, ErrInvalidPropertyGroup, (&PropertyGroup{GroupType: PropertyGroupPortfolio, Name: "Bay Area"}).Validate())
+	assert.Equal(t, ErrInvalidPropertyGroup, (&PropertyGroup{GroupType: "district", Name: "North"}).Validate())
+}
+
+func TestSetPropertyGroupRequiresPortfolio(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT group_type FROM property_groups`).WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"group_type"}).AddRow(PropertyGroupRegion))
+
+	assert.Equal(t, ErrNotPortfolio, SetPropertyGroup(5, sql.NullInt32{Int32: 1, Valid: true}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeletePropertyGroupInUse(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT \(SELECT COUNT\(\*\) FROM property_groups WHERE parent_id = \$1\)`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	assert.Equal(t, ErrPropertyGroupInUse, DeletePropertyGroup(2))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScopeReportPropertiesIntersectsGroupAndCriteria(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT p.id\s+FROM properties p\s+JOIN property_groups pg`).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+
+	original := map[string]interface{}{"property_ids": []interface{}{float64(11), float64(12)}}
+	report := &CustomReport{Criteria: original}
+	admin := &User{ID: 1, Roles: []Role{{Name: "admin"}}}
+
+	require.NoError(t, scopeReportProperties(report, map[string]interface{}{"group_id": "4"}, admin))
+	assert.Equal(t, []interface{}{float64(11)}, report.Criteria["property_ids"])
+	assert.Equal(t, PropertyFilter{IDs: []int{11}, Scoped: true}, reportProperties(report))
+	assert.Len(t, original["property_ids"], 2, "the stored criteria are left alone")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScopeReportPropertiesEmptyManagerScope(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`FROM property_group_managers`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(3, 1))
+	mock.ExpectQuery(`SELECT DISTINCT p.id`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	report := &CustomReport{Criteria: map[string]interface{}{}}
+	manager := &User{ID: 7, Roles: []Role{{Name: "property_manager"}}}
+
+	require.NoError(t, scopeReportProperties(report, nil, manager))
+	assert.Equal(t, []interface{}{float64(0)}, report.Criteria["property_ids"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestManagedPropertyIDsWithoutGroups(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	manager := &User{ID: 7, Roles: []Role{{Name: "property_manager"}}}
+
+	// Before any manager is scoped to a group, managers see every property
+	mock.ExpectQuery(`FROM property_group_managers`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(0, 0))
+	ids, scoped, err := ManagedPropertyIDs(manager)
+	require.NoError(t, err)
+	assert.False(t, scoped)
+	assert.Empty(t, ids)
+
+	// Afterwards a manager without groups sees none
+	mock.ExpectQuery(`FROM property_group_managers`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(2, 0))
+	ids, scoped, err = ManagedPropertyIDs(manager)
+	require.NoError(t, err)
+	assert.True(t, scoped)
+	assert.Empty(t, ids)
+
+	// Other roles without groups are not scoped
+	mock.ExpectQuery(`FROM property_group_managers`).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(2, 0))
+	_, scoped, err = ManagedPropertyIDs(&User{ID: 8, Roles: []Role{{Name: "accountant"}}})
+	require.NoError(t, err)
+	assert.False(t, scoped)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRollUpComparisons(t *testing.T) {
+	comparisons := []PropertyComparison{
+		{PropertyID: 1, Units: 10, UnitMonths: 30, OccupiedUnitMonths: 27, Revenue: 27000, RentDue: 27000},
+		{PropertyID: 2, Units: 10, UnitMonths: 30, OccupiedUnitMonths: 21, Revenue: 18000, RentDue: 21000},
+		{PropertyID: 3, Units: 5, UnitMonths: 15, OccupiedUnitMonths: 15, Revenue: 7500, RentDue: 7500},
+		{PropertyID: 4, Units: 2, UnitMonths: 6},
+	}
+	west := sql.NullInt32{Int32: 1, Valid: true}
+	membership := map[int]propertyGroupMembership{
+		1: {PortfolioID: 10, PortfolioName: "Bay Area", RegionID: west, RegionName: NullString("West")},
+		2: {PortfolioID: 11, PortfolioName: "Los Angeles", RegionID: west, RegionName: NullString("West")},
+		3: {PortfolioID: 12, PortfolioName: "Boston", RegionID: sql.NullInt32{Int32: 2, Valid: true}, RegionName: NullString("East")},
+	}
+
+	regions := rollUpComparisons(comparisons, membership, PropertyGroupRegion)
+	require.Len(t, regions, 3)
+	assert.Equal(t, "East", regions[0].Name)
+	assert.Equal(t, "West", regions[1].Name)
+	assert.Equal(t, 2, regions[1].PropertyCount)
+	assert.Equal(t, 20, regions[1].Units)
+	assert.Equal(t, 80.0, regions[1].KPIs["occupancy"])
+	assert.Equal(t, 2250.0, regions[1].KPIs["revenue_per_unit"])
+	assert.Equal(t, "Unassigned", regions[2].Name)
+	assert.False(t, regions[2].GroupID.Valid)
+
+	portfolios := rollUpComparisons(comparisons, membership, PropertyGroupPortfolio)
+	require.Len(t, portfolios, 4)
+	assert.Equal(t, "Bay Area", portfolios[0].Name)
+	assert.Equal(t, 90.0, portfolios[0].KPIs["occupancy"])
+}
+
+func TestPropertyFilter(t *testing.T) {
+	all := PropertyFilter{}
+	scope, args := all.condition("p.id", []interface{}{"x"})
+	assert.Empty(t, scope)
+	assert.Equal(t, []interface{}{"x"}, args)
+	assert.True(t, all.Allows(9))
+
+	managed := PropertyFilter{IDs: []int{3, 5}, Scoped: true}
+	scope, args = managed.condition("p.id", []interface{}{"x"})
+	assert.Equal(t, " AND p.id IN ($2, $3)", scope)
+	assert.Equal(t, []interface{}{"x", 3, 5}, args)
+	assert.True(t, managed.Allows(5))
+	assert.False(t, managed.Allows(9))
+	assert.Equal(t, PropertyFilter{IDs: []int{5}, Scoped: true}, managed.Only(5))
+	assert.Equal(t, PropertyFilter{Scoped: true}, managed.Only(9))
+
+	scope, _ = PropertyFilter{Scoped: true}.condition("p.id", nil)
+	assert.Equal(t, " AND 1 = 0", scope, "an empty scope matches nothing")
+}
@@ -0,0 +1,251 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+var (
+	// ErrUnitNotInProperty is returned when a unit gallery is addressed through another property
+	ErrUnitNotInProperty = errors.New("unit does not belong to this property")
+	// ErrPhotoOrderMismatch is returned when a new order does not list exactly the gallery's photos
+	ErrPhotoOrderMismatch = errors.New("photo_ids must list every photo in the gallery exactly once")
+)
+
+// PropertyPhoto is a photo in a property's or unit's gallery, stored in three sizes
+type PropertyPhoto struct {
+	ID           int            `json:"id"`
+	PropertyID   int            `json:"property_id"`
+	UnitID       sql.NullInt32  `json:"unit_id,omitempty"`
+	Caption      sql.NullString `json:"caption,omitempty"`
+	Position     int            `json:"position"`
+	IsCover      bool           `json:"is_cover"`
+	Width        int            `json:"width"`
+	Height       int            `json:"height"`
+	FullURL      string         `json:"full_url"`
+	MediumURL    string         `json:"medium_url"`
+	ThumbnailURL string         `json:"thumbnail_url"`
+	UploadedBy   sql.NullInt32  `json:"uploaded_by,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// VariantURL returns the URL of a named size, or "" for unknown names
+func (p *PropertyPhoto) VariantURL(variant string) string {
+	switch variant {
+	case "full":
+		return p.FullURL
+	case "medium":
+		return p.MediumURL
+	case "thumbnail":
+		return p.ThumbnailURL
+	}
+	return ""
+}
+
+// galleryFilter selects the photos of the property gallery or of one unit's gallery
+func galleryFilter(propertyID int, unitID sql.NullInt32) (string, []interface{}) {
+	if unitID.Valid {
+		return "property_id = $1 AND unit_id = $2", []interface{}{propertyID, unitID}
+	}
+	return "property_id = $1 AND unit_id IS NULL", []interface{}{propertyID}
+}
+
+// checkGallery returns sql.ErrNoRows if the property does not exist and ErrUnitNotInProperty if
+// the unit is not one of its units
+func checkGallery(q Querier, propertyID int, unitID sql.NullInt32) error {
+	var exists int
+	if err := q.QueryRow("SELECT 1 FROM properties WHERE id = $1", propertyID).Scan(&exists); err != nil {
+		return err
+	}
+	if !unitID.Valid {
+		return nil
+	}
+	err := q.QueryRow("SELECT 1 FROM property_units WHERE id = $1 AND property_id = $2", unitID, propertyID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrUnitNotInProperty
+	}
+	return err
+}
+
+// CreatePropertyPhoto adds a photo to the end of its gallery. The first photo becomes the cover.
+func CreatePropertyPhoto(photo *PropertyPhoto) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkGallery(tx, photo.PropertyID, photo.UnitID); err != nil {
+		return err
+	}
+
+	filter, args := galleryFilter(photo.PropertyID, photo.UnitID)
+	var count int
+	var maxPosition sql.NullInt64
+	if err := tx.QueryRow("SELECT COUNT(*), MAX(position) FROM property_photos WHERE "+filter, args...).
+		Scan(&count, &maxPosition); err != nil {
+		return err
+	}
+	photo.Position = int(maxPosition.Int64) + 1
+	photo.IsCover = count == 0
+
+	err = tx.QueryRow(`
+		INSERT INTO property_photos (property_id, unit_id, caption, position, is_cover, width, height,
+			full_url, medium_url, thumbnail_url, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at`,
+		photo.PropertyID, photo.UnitID, photo.Caption, photo.Position, photo.IsCover, photo.Width, photo.Height,
+		photo.FullURL, photo.MediumURL, photo.ThumbnailURL, photo.UploadedBy).Scan(&photo.ID, &photo.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const propertyPhotoColumns = `id, property_id, unit_id, caption, position, is_cover, width, height,
+	full_url, medium_url, thumbnail_url, uploaded_by, created_at`
+
+func scanPropertyPhoto(row interface{ Scan(...interface{}) error }) (PropertyPhoto, error) {
+	var p PropertyPhoto
+	err := row.Scan(&p.ID, &p.PropertyID, &p.UnitID, &p.Caption, &p.Position, &p.IsCover, &p.Width, &p.Height,
+		&p.FullURL, &p.MediumURL, &p.ThumbnailURL, &p.UploadedBy, &p.CreatedAt)
+	return p, err
+}
+
+// GetPropertyPhotos returns a gallery in display order
+func GetPropertyPhotos(propertyID int, unitID sql.NullInt32) ([]PropertyPhoto, error) {
+	return getGalleryPhotos(db.DB, propertyID, unitID)
+}
+
+func getGalleryPhotos(q Querier, propertyID int, unitID sql.NullInt32) ([]PropertyPhoto, error) {
+	filter, args := galleryFilter(propertyID, unitID)
+	rows, err := q.Query("SELECT "+propertyPhotoColumns+" FROM property_photos WHERE "+filter+" ORDER BY position, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var photos []PropertyPhoto
+	for rows.Next() {
+		photo, err := scanPropertyPhoto(rows)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, photo)
+	}
+	return photos, rows.Err()
+}
+
+// GetPropertyPhoto retrieves a photo by ID
+func GetPropertyPhoto(id int) (*PropertyPhoto, error) {
+	photo, err := scanPropertyPhoto(db.DB.QueryRow("SELECT "+propertyPhotoColumns+" FROM property_photos WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &photo, nil
+}
+
+// UpdatePhotoCaption replaces a photo's caption; an empty caption clears it
+func UpdatePhotoCaption(id int, caption string) error {
+	result, err := db.DB.Exec("UPDATE property_photos SET caption = $2 WHERE id = $1", id, NullString(caption))
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// ReorderPropertyPhotos sets the display order of a gallery to the order of photoIDs
+func ReorderPropertyPhotos(propertyID int, unitID sql.NullInt32, photoIDs []int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkGallery(tx, propertyID, unitID); err != nil {
+		return err
+	}
+	photos, err := getGalleryPhotos(tx, propertyID, unitID)
+	if err != nil {
+		return err
+	}
+
+	remaining := map[int]bool{}
+	for _, p := range photos {
+		remaining[p.ID] = true
+	}
+	if len(photoIDs) != len(photos) {
+		return ErrPhotoOrderMismatch
+	}
+	for _, id := range photoIDs {
+		if !remaining[id] {
+			return ErrPhotoOrderMismatch
+		}
+		delete(remaining, id)
+	}
+
+	for position, id := range photoIDs {
+		if _, err := tx.Exec("UPDATE property_photos SET position = $2 WHERE id = $1", id, position+1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetCoverPhoto makes a photo the cover of its gallery
+func SetCoverPhoto(id int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	photo, err := scanPropertyPhoto(tx.QueryRow("SELECT "+propertyPhotoColumns+" FROM property_photos WHERE id = $1", id))
+	if err != nil {
+		return err
+	}
+
+	filter, args := galleryFilter(photo.PropertyID, photo.UnitID)
+	if _, err := tx.Exec("UPDATE property_photos SET is_cover = FALSE WHERE "+filter, args...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE property_photos SET is_cover = TRUE WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeletePropertyPhoto removes a photo and returns it so its files can be deleted. If it was the
+// cover, the first remaining photo in the gallery becomes the cover.
+func DeletePropertyPhoto(id int) (*PropertyPhoto, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	photo, err := scanPropertyPhoto(tx.QueryRow("SELECT "+propertyPhotoColumns+" FROM property_photos WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM property_photos WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+
+	if photo.IsCover {
+		filter, args := galleryFilter(photo.PropertyID, photo.UnitID)
+		_, err := tx.Exec(`
+			UPDATE property_photos SET is_cover = TRUE
+			WHERE id = (SELECT id FROM property_photos WHERE `+filter+` ORDER BY position, id LIMIT 1)`, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &photo, nil
+}
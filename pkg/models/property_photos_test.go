@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePropertyPhotoFirstIsCover(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT 1 FROM properties`).WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectQuery(`SELECT 1 FROM property_units`).WithArgs(sql.NullInt32{Int32: 9, Valid: true}, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\), MAX\(position\) FROM property_photos WHERE property_id = \$1 AND unit_id = \$2`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(0, nil))
+	mock.ExpectQuery(`INSERT INTO property_photos`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+
+	photo := &PropertyPhoto{PropertyID: 4, UnitID: sql.NullInt32{Int32: 9, Valid: true}}
+	require.NoError(t, CreatePropertyPhoto(photo))
+	assert.True(t, photo.IsCover)
+	assert.Equal(t, 1, photo.Position)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReorderPropertyPhotosRequiresWholeGallery(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	columns := []string{"id", "property_id", "unit_id", "caption", "position", "is_cover", "width", "height",
+		"full_url", "medium_url", "thumbnail_url", "uploaded_by", "created_at"}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT 1 FROM properties`).WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectQuery(`FROM property_photos WHERE property_id = \$1 AND unit_id IS NULL`).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 4, nil, nil, 1, true, 800, 600, "/f1", "/m1", "/t1", nil, time.Now()).
+			AddRow(2, 4, nil, nil, 2, false, 800, 600, "/f2", "/m2", "/t2", nil, time.Now()))
+	mock.ExpectRollback()
+
+	assert.Equal(t, ErrPhotoOrderMismatch, ReorderPropertyPhotos(4, sql.NullInt32{}, []int{2, 2}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
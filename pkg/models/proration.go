@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Proration day-count conventions
+const (
+	ProrationActualDays = "actual_days" // Daily rate is the month's rent over the days in that month
+	ProrationThirtyDay  = "thirty_day"  // Every month counts as 30 days
+	ProrationAnnual365  = "annual_365"  // Daily rate is a year's rent over 365 days
+)
+
+// ValidProrationConventions lists the accepted proration_convention values
+var ValidProrationConventions = []string{ProrationActualDays, ProrationThirtyDay, ProrationAnnual365}
+
+// ErrInvalidProrationConvention is returned for an unknown day-count convention
+var ErrInvalidProrationConvention = errors.New("proration_convention must be actual_days, thirty_day or annual_365")
+
+// ValidProrationConvention reports whether convention is one of the supported day-count conventions
+func ValidProrationConvention(convention string) bool {
+	for _, valid := range ValidProrationConventions {
+		if convention == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// daysInMonth returns the number of days in the month starting at month
+func daysInMonth(month time.Time) int {
+	return month.AddDate(0, 1, -1).Day()
+}
+
+// ProrateRent returns the rent for the days from first to last (inclusive, within one month) and
+// the number of days charged under the convention. An empty convention means actual_days.
+// Covering the whole month always charges the full rent.
+func ProrateRent(monthlyRent float64, first, last time.Time, convention string) (float64, int) {
+	month := PeriodStart(first)
+	monthDays := daysInMonth(month)
+	if first.Day() == 1 && last.Day() == monthDays {
+		if convention == ProrationThirtyDay {
+			return monthlyRent, 30
+		}
+		return monthlyRent, monthDays
+	}
+
+	days := last.Day() - first.Day() + 1
+	var rent float64
+	switch convention {
+	case ProrationThirtyDay:
+		// 30/360: the 31st counts as the 30th, and running to the end of the month reaches day 30
+		from, to := first.Day(), last.Day()
+		if from > 30 {
+			from = 30
+		}
+		if to > 30 || to == monthDays {
+			to = 30
+		}
+		days = to - from + 1
+		rent = monthlyRent * float64(days) / 30
+	case ProrationAnnual365:
+		rent = monthlyRent * 12 / 365 * float64(days)
+	default:
+		rent = monthlyRent * float64(days) / float64(monthDays)
+	}
+	return math.Min(math.Round(rent*100)/100, monthlyRent), days
+}
+
+// RentCharge is the rent billed to a lease for one month
+type RentCharge struct {
+	Month        time.Time `json:"month"`
+	PeriodStart  time.Time `json:"period_start"` // First day of the month the lease covers
+	PeriodEnd    time.Time `json:"period_end"`   // Last day of the month the lease covers
+	Days         int       `json:"days"`         // Days charged under the proration convention
+	ContractRent float64   `json:"contract_rent"`
+	Prorated     bool      `json:"prorated"`
+	Rent         float64   `json:"rent"`
+	Concession   float64   `json:"concession"`
+	AmountDue    float64   `json:"amount_due"`
+}
+
+// ChargeForMonth returns the rent billed for a month. The first and last months are prorated under
+// the lease's convention when the lease starts or ends part way through them.
+func (l ScheduledLease) ChargeForMonth(month time.Time) RentCharge {
+	month = PeriodStart(month)
+	charge := RentCharge{Month: month}
+	if month.Before(PeriodStart(l.StartDate)) || month.After(l.EndDate) {
+		return charge
+	}
+
+	monthEnd := month.AddDate(0, 1, -1)
+	charge.PeriodStart, charge.PeriodEnd = month, monthEnd
+	if l.StartDate.After(month) {
+		charge.PeriodStart = l.StartDate
+		charge.Prorated = true
+	}
+	if l.EndDate.Before(monthEnd) {
+		charge.PeriodEnd = l.EndDate
+		charge.Prorated = true
+	}
+
+	charge.ContractRent = l.RentAsOf(month)
+	charge.Rent, charge.Days = ProrateRent(charge.ContractRent, charge.PeriodStart, charge.PeriodEnd, l.ProrationConvention)
+	for _, c := range l.Concessions {
+		charge.Concession += c.AmountForMonth(month, charge.Rent)
+	}
+	charge.Concession = math.Min(charge.Concession, charge.Rent)
+	charge.AmountDue = math.Round((charge.Rent-charge.Concession)*100) / 100
+	return charge
+}
+
+// Charges returns the rent billed for every month of the lease term
+func (l ScheduledLease) Charges() []RentCharge {
+	months := MonthsInRange(l.StartDate, l.EndDate)
+	charges := make([]RentCharge, len(months))
+	for i, month := range months {
+		charges[i] = l.ChargeForMonth(month)
+	}
+	return charges
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProrateRent(t *testing.T) {
+	tests := []struct {
+		name        string
+		first, last time.Time
+		convention  string
+		rent        float64
+		days        int
+	}{
+		{"actual days move-in", date(2026, 1, 15), date(2026, 1, 31), ProrationActualDays, 822.58, 17},
+		{"actual days move-out", date(2026, 3, 1), date(2026, 3, 14), ProrationActualDays, 677.42, 14},
+		{"empty convention is actual days", date(2026, 1, 15), date(2026, 1, 31), "", 822.58, 17},
+		{"thirty day move-in", date(2026, 1, 15), date(2026, 1, 31), ProrationThirtyDay, 800, 16},
+		{"thirty day february", date(2026, 2, 15), date(2026, 2, 28), ProrationThirtyDay, 800, 16},
+		{"thirty day on the 31st", date(2026, 1, 31), date(2026, 1, 31), ProrationThirtyDay, 50, 1},
+		{"annual 365 move-in", date(2026, 1, 15), date(2026, 1, 31), ProrationAnnual365, 838.36, 17},
+		{"annual 365 thirty days", date(2026, 1, 2), date(2026, 1, 31), ProrationAnnual365, 1479.45, 30},
+		{"whole month", date(2026, 2, 1), date(2026, 2, 28), ProrationAnnual365, 1500, 28},
+	}
+	for _, tt := range tests {
+		rent, days := ProrateRent(1500, tt.first, tt.last, tt.convention)
+		assert.Equal(t, tt.rent, rent, tt.name)
+		assert.Equal(t, tt.days, days, tt.name)
+	}
+}
+
+func TestLeaseChargesProrateFirstAndLastMonths(t *testing.T) {
+	lease := ScheduledLease{
+		MonthlyRent:         1500,
+		StartDate:           date(2026, 1, 15),
+		EndDate:             date(2027, 1, 14),
+		ProrationConvention: ProrationActualDays,
+		Concessions: []LeaseConcession{
+			{ConcessionType: ConcessionFreeRent, StartMonth: date(2026, 1, 1), Months: 1},
+		},
+	}
+
+	charges := lease.Charges()
+	require.Len(t, charges, 13)
+	assert.True(t, charges[0].Prorated)
+	assert.Equal(t, date(2026, 1, 15), charges[0].PeriodStart)
+	assert.Equal(t, 822.58, charges[0].Rent)
+	assert.Equal(t, 822.58, charges[0].Concession, "free rent waives the prorated amount")
+	assert.Equal(t, 0.0, charges[0].AmountDue)
+
+	assert.False(t, charges[1].Prorated)
+	assert.Equal(t, 1500.0, charges[1].AmountDue)
+
+	last := charges[12]
+	assert.True(t, last.Prorated)
+	assert.Equal(t, date(2027, 1, 14), last.PeriodEnd)
+	assert.Equal(t, 677.42, last.AmountDue)
+}
+
+func TestEffectiveRentWeighsProratedMonths(t *testing.T) {
+	lease := ScheduledLease{MonthlyRent: 1500, StartDate: date(2026, 1, 15), EndDate: date(2027, 1, 14)}
+	assert.Equal(t, 1500.0, lease.EffectiveRent())
+}
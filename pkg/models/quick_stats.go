@@ -0,0 +1,350 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Quick stat value formats
+const (
+	StatFormatCount      = "count"
+	StatFormatNumber     = "number"
+	StatFormatCurrency   = "currency"
+	StatFormatPercentage = "percentage"
+	StatFormatDays       = "days"
+)
+
+// StatField is one value of a quick stat widget. SQL is a query returning a single value, or
+// label/value rows when Breakdown is set. Property-keyed queries filter with
+// (:all_properties = 1 OR col IN (:property_ids)). They may reference the named parameters
+//
+//	:all_properties  1 when every property is covered, otherwise 0
+//	:property_ids    the covered properties, for use as col IN (:property_ids)
+//	:period_start    start of the current month
+//	:now             current time
+//
+// DialectSQL, when set, builds the query for dialects that need non-portable expressions.
+type StatField struct {
+	Key        string                    `json:"key"`
+	Label      string                    `json:"label"`
+	Format     string                    `json:"format"`
+	Breakdown  bool                      `json:"breakdown,omitempty"`
+	SQL        string                    `json:"-"`
+	DialectSQL func(d db.Dialect) string `json:"-"`
+}
+
+// QuickStat is a dashboard widget made of one or more SQL-backed fields. Extend may add
+// values that are not expressible as a single query.
+type QuickStat struct {
+	Name   string                                                               `json:"name"`
+	Title  string                                                               `json:"title"`
+	Fields []StatField                                                          `json:"fields"`
+	Extend func(values map[string]interface{}, properties PropertyFilter) error `json:"-"`
+}
+
+var quickStats = map[string]QuickStat{}
+
+// RegisterQuickStat adds a stat to the registry, replacing any stat with the same name
+func RegisterQuickStat(stat QuickStat) {
+	quickStats[stat.Name] = stat
+}
+
+// GetQuickStat looks up a registered stat by name
+func GetQuickStat(name string) (QuickStat, bool) {
+	stat, ok := quickStats[name]
+	return stat, ok
+}
+
+// ListQuickStats returns every registered stat ordered by name
+func ListQuickStats() []QuickStat {
+	stats := make([]QuickStat, 0, len(quickStats))
+	for _, stat := range quickStats {
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// namedStatParam matches :name but not PostgreSQL ::type casts
+var namedStatParam = regexp.MustCompile(`(^|[^:]):([a-z_]+)`)
+
+// BindStatParams rewrites :name parameters to positional placeholders, numbered in order of first
+// use, and returns the matching arguments. An []int parameter binds as a comma-separated list.
+func BindStatParams(query string, params map[string]interface{}) (string, []interface{}, error) {
+	placeholders := map[string]string{}
+	var args []interface{}
+	var bindErr error
+
+	bound := namedStatParam.ReplaceAllStringFunc(query, func(match string) string {
+		parts := namedStatParam.FindStringSubmatch(match)
+		prefix, name := parts[1], parts[2]
+		if placeholder, ok := placeholders[name]; ok {
+			return prefix + placeholder
+		}
+		value, ok := params[name]
+		if !ok {
+			if bindErr == nil {
+				bindErr = fmt.Errorf("unknown stat parameter %q", name)
+			}
+			return match
+		}
+		if list, ok := value.([]int); ok {
+			positions := make([]string, len(list))
+			for i, v := range list {
+				args = append(args, v)
+				positions[i] = fmt.Sprintf("$%d", len(args))
+			}
+			placeholders[name] = strings.Join(positions, ", ")
+		} else {
+			args = append(args, value)
+			placeholders[name] = fmt.Sprintf("$%d", len(args))
+		}
+		return prefix + placeholders[name]
+	})
+
+	return bound, args, bindErr
+}
+
+// statParams returns the named parameters of stat queries for properties at now
+func statParams(properties PropertyFilter, now time.Time) map[string]interface{} {
+	all, ids := 1, []int{0}
+	if properties.Scoped {
+		all = 0
+		// An empty list is not valid SQL, and no property has ID 0
+		if len(properties.IDs) > 0 {
+			ids = properties.IDs
+		}
+	}
+	return map[string]interface{}{
+		"all_properties": all,
+		"property_ids":   ids,
+		"period_start":   PeriodStart(now),
+		"now":            now,
+	}
+}
+
+// FormatStatValue rounds a raw value for display: counts become integers, percentages, days and
+// numbers keep one decimal place and currency keeps two
+func FormatStatValue(format string, value float64) interface{} {
+	switch format {
+	case StatFormatCount:
+		return int(math.Round(value))
+	case StatFormatCurrency:
+		return math.Round(value*100) / 100
+	default:
+		return math.Round(value*10) / 10
+	}
+}
+
+// ComputeQuickStat runs each field's query over properties and returns the values keyed by field key
+func ComputeQuickStat(stat QuickStat, properties PropertyFilter, now time.Time) (map[string]interface{}, error) {
+	params := statParams(properties, now)
+
+	values := make(map[string]interface{}, len(stat.Fields))
+	for _, field := range stat.Fields {
+		query := field.SQL
+		if field.DialectSQL != nil {
+			query = field.DialectSQL(db.CurrentDialect)
+		}
+
+		bound, args, err := BindStatParams(query, params)
+		if err != nil {
+			return nil, fmt.Errorf("stat %s.%s: %w", stat.Name, field.Key, err)
+		}
+
+		if field.Breakdown {
+			breakdown, err := queryStatBreakdown(field.Format, bound, args)
+			if err != nil {
+				return nil, fmt.Errorf("stat %s.%s: %w", stat.Name, field.Key, err)
+			}
+			values[field.Key] = breakdown
+			continue
+		}
+
+		var value *float64
+		if err := db.ReadDB().QueryRow(bound, args...).Scan(&value); err != nil {
+			return nil, fmt.Errorf("stat %s.%s: %w", stat.Name, field.Key, err)
+		}
+		if value == nil {
+			values[field.Key] = FormatStatValue(field.Format, 0)
+		} else {
+			values[field.Key] = FormatStatValue(field.Format, *value)
+		}
+	}
+
+	if stat.Extend != nil {
+		if err := stat.Extend(values, properties); err != nil {
+			return nil, fmt.Errorf("stat %s: %w", stat.Name, err)
+		}
+	}
+
+	return values, nil
+}
+
+func queryStatBreakdown(format, query string, args []interface{}) (map[string]interface{}, error) {
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breakdown := map[string]interface{}{}
+	for rows.Next() {
+		var label string
+		var value float64
+		if err := rows.Scan(&label, &value); err != nil {
+			return nil, err
+		}
+		breakdown[label] = FormatStatValue(format, value)
+	}
+	return breakdown, rows.Err()
+}
+
+// monthlyExpensesSQL sums the period's expenses as owner statements count them: maintenance
+// when completed and utilities by the start of their billing period
+const monthlyExpensesSQL = `COALESCE((SELECT SUM(mr.actual_cost) FROM maintenance_requests mr
+						WHERE mr.completed_date >= :period_start AND mr.completed_date <= :now
+						  AND (:all_properties = 1 OR mr.property_id IN (:property_ids))), 0)
+					+ COALESCE((SELECT SUM(ue.amount) FROM utility_expenses ue
+						WHERE ue.period_start >= :period_start AND ue.period_start <= :now
+						  AND (:all_properties = 1 OR ue.property_id IN (:property_ids))), 0)`
+
+// Built-in dashboard stats. Satisfaction surveys do not exist yet, so that field reports zero
+// until its data is available.
+func init() {
+	RegisterQuickStat(QuickStat{
+		Name:  "properties",
+		Title: "Properties",
+		Fields: []StatField{
+			{Key: "total_properties", Label: "Properties", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM properties WHERE (:all_properties = 1 OR id IN (:property_ids))`},
+			{Key: "occupied_units", Label: "Occupied Units", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM property_units pu
+				WHERE (:all_properties = 1 OR pu.property_id IN (:property_ids))
+				  AND EXISTS (SELECT 1 FROM leases l WHERE l.unit_id = pu.id AND l.status = 'active')`},
+			{Key: "vacant_units", Label: "Vacant Units", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM property_units pu
+				WHERE (:all_properties = 1 OR pu.property_id IN (:property_ids))
+				  AND NOT EXISTS (SELECT 1 FROM leases l WHERE l.unit_id = pu.id AND l.status = 'active')`},
+			{Key: "maintenance_requests", Label: "Open Maintenance Requests", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM maintenance_requests
+				WHERE (:all_properties = 1 OR property_id IN (:property_ids)) AND status NOT IN ('completed', 'cancelled')`},
+			{Key: "occupancy_rate", Label: "Occupancy Rate", Format: StatFormatPercentage, SQL: `
+				SELECT 100.0 * SUM(CASE WHEN EXISTS (SELECT 1 FROM leases l WHERE l.unit_id = pu.id AND l.status = 'active')
+										THEN 1 ELSE 0 END) / NULLIF(COUNT(*), 0)
+				FROM property_units pu
+				WHERE (:all_properties = 1 OR pu.property_id IN (:property_ids))`},
+		},
+	})
+
+	RegisterQuickStat(QuickStat{
+		Name:  "financial",
+		Title: "Financial",
+		Fields: []StatField{
+			{Key: "monthly_revenue", Label: "Revenue This Month", Format: StatFormatCurrency, SQL: `
+				SELECT COALESCE(SUM(p.amount), 0) FROM payments p
+				JOIN leases l ON p.lease_id = l.id
+				JOIN property_units pu ON l.unit_id = pu.id
+				WHERE p.status = 'completed' AND p.payment_date >= :period_start AND p.payment_date <= :now
+				  AND (:all_properties = 1 OR pu.property_id IN (:property_ids))`},
+			{Key: "total_expenses", Label: "Expenses This Month", Format: StatFormatCurrency, SQL: `
+				SELECT ` + monthlyExpensesSQL},
+			{Key: "net_income", Label: "Net Income This Month", Format: StatFormatCurrency, SQL: `
+				SELECT COALESCE((SELECT SUM(p.amount) FROM payments p
+						JOIN leases l ON p.lease_id = l.id
+						JOIN property_units pu ON l.unit_id = pu.id
+						WHERE p.status = 'completed' AND p.payment_date >= :period_start AND p.payment_date <= :now
+						  AND (:all_properties = 1 OR pu.property_id IN (:property_ids))), 0)
+					- (` + monthlyExpensesSQL + `)`},
+			{Key: "collection_rate", Label: "Collection Rate", Format: StatFormatPercentage, SQL: `
+				SELECT 100.0 * COALESCE(SUM(paid.amount), 0) / NULLIF(SUM(l.monthly_rent), 0)
+				FROM leases l
+				JOIN property_units pu ON l.unit_id = pu.id
+				LEFT JOIN (
+					SELECT lease_id, SUM(amount) AS amount FROM payments
+					WHERE status = 'completed' AND payment_date >= :period_start AND payment_date <= :now
+					GROUP BY lease_id
+				) paid ON paid.lease_id = l.id
+				WHERE l.status = 'active' AND (:all_properties = 1 OR pu.property_id IN (:property_ids))`},
+			{Key: "average_rent", Label: "Average Rent", Format: StatFormatCurrency, SQL: `
+				SELECT AVG(l.monthly_rent) FROM leases l
+				JOIN property_units pu ON l.unit_id = pu.id
+				WHERE l.status = 'active' AND (:all_properties = 1 OR pu.property_id IN (:property_ids))`},
+		},
+	})
+
+	RegisterQuickStat(QuickStat{
+		Name:  "tenants",
+		Title: "Tenants",
+		Fields: []StatField{
+			{Key: "total_tenants", Label: "Active Tenants", Format: StatFormatCount, SQL: `
+				SELECT COUNT(DISTINCT l.tenant_id) FROM leases l
+				JOIN property_units pu ON l.unit_id = pu.id
+				WHERE l.status = 'active' AND (:all_properties = 1 OR pu.property_id IN (:property_ids))`},
+			{Key: "new_tenants", Label: "New Tenants This Month", Format: StatFormatCount, SQL: `
+				SELECT COUNT(DISTINCT l.tenant_id) FROM leases l
+				JOIN property_units pu ON l.unit_id = pu.id
+				WHERE l.start_date >= :period_start AND l.start_date <= :now
+				  AND (:all_properties = 1 OR pu.property_id IN (:property_ids))
+				  AND NOT EXISTS (SELECT 1 FROM leases prev WHERE prev.tenant_id = l.tenant_id AND prev.start_date < l.start_date)`},
+			{Key: "lease_renewals", Label: "Renewals This Month", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM leases l
+				JOIN property_units pu ON l.unit_id = pu.id
+				WHERE l.start_date >= :period_start AND l.start_date <= :now
+				  AND (:all_properties = 1 OR pu.property_id IN (:property_ids))
+				  AND EXISTS (SELECT 1 FROM leases prev
+							  WHERE prev.tenant_id = l.tenant_id AND prev.unit_id = l.unit_id AND prev.start_date < l.start_date)`},
+			{Key: "move_outs", Label: "Move-outs This Month", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM leases l
+				JOIN property_units pu ON l.unit_id = pu.id
+				WHERE l.status IN ('active', 'ended') AND l.end_date >= :period_start AND l.end_date <= :now
+				  AND (:all_properties = 1 OR pu.property_id IN (:property_ids))`},
+			{Key: "satisfaction_score", Label: "Satisfaction Score", Format: StatFormatNumber, SQL: `SELECT 0`},
+		},
+	})
+
+	RegisterQuickStat(QuickStat{
+		Name:  "maintenance",
+		Title: "Maintenance",
+		Fields: []StatField{
+			{Key: "open_requests", Label: "Open Requests", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM maintenance_requests
+				WHERE (:all_properties = 1 OR property_id IN (:property_ids)) AND status NOT IN ('completed', 'cancelled')`},
+			{Key: "completed_this_month", Label: "Completed This Month", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM maintenance_requests
+				WHERE (:all_properties = 1 OR property_id IN (:property_ids)) AND status = 'completed'
+				  AND completed_date >= :period_start`},
+			{Key: "avg_resolution_time", Label: "Average Resolution Time", Format: StatFormatDays,
+				DialectSQL: func(d db.Dialect) string {
+					return `
+						SELECT AVG(` + d.DaysBetween("reported_date", "completed_date") + `) FROM maintenance_requests
+						WHERE (:all_properties = 1 OR property_id IN (:property_ids)) AND status = 'completed'
+						  AND completed_date >= :period_start`
+				}},
+			{Key: "total_cost", Label: "Cost This Month", Format: StatFormatCurrency, SQL: `
+				SELECT COALESCE(SUM(actual_cost), 0) FROM maintenance_requests
+				WHERE (:all_properties = 1 OR property_id IN (:property_ids)) AND completed_date >= :period_start`},
+			{Key: "priority_breakdown", Label: "Open Requests by Priority", Format: StatFormatCount, Breakdown: true, SQL: `
+				SELECT COALESCE(priority, 'unknown'), COUNT(*) FROM maintenance_requests
+				WHERE (:all_properties = 1 OR property_id IN (:property_ids)) AND status NOT IN ('completed', 'cancelled')
+				GROUP BY priority`},
+		},
+		Extend: func(values map[string]interface{}, properties PropertyFilter) error {
+			// SLA status is computed in Go from the per-priority policies
+			slaStats, err := GetMaintenanceSLAStats(properties)
+			if err != nil {
+				return err
+			}
+			for _, key := range []string{"sla_at_risk", "sla_breached", "sla_breaches"} {
+				values[key] = slaStats[key]
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindStatParams(t *testing.T) {
+	params := map[string]interface{}{"all_properties": 0, "property_ids": []int{3, 5}, "period_start": "2024-03-01"}
+
+	query, args, err := BindStatParams(
+		"SELECT x::numeric FROM t WHERE (:all_properties = 1 OR p IN (:property_ids)) AND d >= :period_start AND q IN (:property_ids)", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT x::numeric FROM t WHERE ($1 = 1 OR p IN ($2, $3)) AND d >= $4 AND q IN ($2, $3)", query)
+	assert.Equal(t, []interface{}{0, 3, 5, "2024-03-01"}, args)
+
+	_, _, err = BindStatParams("SELECT :unknown", params)
+	assert.Error(t, err)
+}
+
+func TestFormatStatValue(t *testing.T) {
+	assert.Equal(t, 12, FormatStatValue(StatFormatCount, 11.6))
+	assert.Equal(t, 1234.57, FormatStatValue(StatFormatCurrency, 1234.5678))
+	assert.Equal(t, 93.3, FormatStatValue(StatFormatPercentage, 93.3333))
+	assert.Equal(t, 2.5, FormatStatValue(StatFormatDays, 2.46))
+}
+
+// Every registered stat must bind with the standard parameters and use a known format
+func TestQuickStatRegistry(t *testing.T) {
+	formats := map[string]bool{StatFormatCount: true, StatFormatNumber: true, StatFormatCurrency: true,
+		StatFormatPercentage: true, StatFormatDays: true}
+	params := statParams(PropertyFilter{}, time.Now())
+
+	for _, name := range []string{"properties", "financial", "tenants", "maintenance"} {
+		_, ok := GetQuickStat(name)
+		assert.True(t, ok, name)
+	}
+
+	for _, stat := range ListQuickStats() {
+		keys := map[string]bool{}
+		for _, field := range stat.Fields {
+			assert.False(t, keys[field.Key], "%s.%s is duplicated", stat.Name, field.Key)
+			keys[field.Key] = true
+			assert.True(t, formats[field.Format], "%s.%s has unknown format %q", stat.Name, field.Key, field.Format)
+			assert.NotEmpty(t, field.Label)
+
+			for _, d := range []db.Dialect{db.PostgresDialect{}, db.SQLiteDialect{}} {
+				query := field.SQL
+				if field.DialectSQL != nil {
+					query = field.DialectSQL(d)
+				}
+				assert.NotEmpty(t, query)
+				_, _, err := BindStatParams(query, params)
+				assert.NoError(t, err, "%s.%s", stat.Name, field.Key)
+			}
+		}
+	}
+}
+
+func TestComputeQuickStat(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	stat := QuickStat{
+		Name: "example",
+		Fields: []StatField{
+			{Key: "count", Format: StatFormatCount, SQL: "SELECT COUNT(*) FROM properties WHERE (:all_properties = 1 OR id IN (:property_ids))"},
+			{Key: "rate", Format: StatFormatPercentage, SQL: "SELECT AVG(x) FROM t"},
+			{Key: "by_priority", Format: StatFormatCount, Breakdown: true, SQL: "SELECT priority, COUNT(*) FROM m GROUP BY priority"},
+		},
+	}
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM properties WHERE \(\$1 = 1 OR id IN \(\$2\)\)`).
+		WithArgs(0, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+	mock.ExpectQuery(`SELECT AVG\(x\) FROM t`).
+		WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(nil))
+	mock.ExpectQuery(`SELECT priority, COUNT\(\*\) FROM m`).
+		WillReturnRows(sqlmock.NewRows([]string{"priority", "count"}).AddRow("high", 2).AddRow("low", 5))
+
+	values, err := ComputeQuickStat(stat, PropertyFilter{IDs: []int{7}, Scoped: true}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"count":       4,
+		"rate":        0.0,
+		"by_priority": map[string]interface{}{"high": 2, "low": 5},
+	}, values)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
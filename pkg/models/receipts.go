@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ErrReceiptUnavailable is returned for payments that are not completed and so have no receipt
+var ErrReceiptUnavailable = errors.New("receipts are only available for completed payments")
+
+// TenantPayment is a payment with the tenant, unit and property it was made for
+type TenantPayment struct {
+	PaymentID        int            `json:"payment_id"`
+	ReceiptNumber    string         `json:"receipt_number"`
+	Amount           float64        `json:"amount"`
+	PaymentDate      time.Time      `json:"payment_date"`
+	PaymentMethod    sql.NullString `json:"payment_method,omitempty"`
+	Status           string         `json:"status"`
+	AdjustsPaymentID sql.NullInt32  `json:"adjusts_payment_id,omitempty"`
+	LeaseID          int            `json:"lease_id"`
+	TenantID         int            `json:"tenant_id"`
+	TenantName       string         `json:"tenant_name"`
+	TenantEmail      string         `json:"tenant_email"`
+	PropertyName     string         `json:"property_name"`
+	PropertyAddress  string         `json:"property_address"`
+	UnitNumber       sql.NullString `json:"unit_number,omitempty"`
+}
+
+// ReceiptNumber formats the receipt number for a payment, e.g. R-2024-000123
+func ReceiptNumber(paymentID int, paymentDate time.Time) string {
+	return fmt.Sprintf("R-%d-%06d", paymentDate.Year(), paymentID)
+}
+
+const tenantPaymentQuery = `
+	SELECT p.id, p.amount, p.payment_date, p.payment_method, p.status, p.adjusts_payment_id,
+	       l.id, t.id, t.first_name || ' ' || t.last_name, t.email, pr.name, pr.address, pu.unit_number
+	FROM payments p
+	JOIN leases l ON p.lease_id = l.id
+	JOIN tenants t ON l.tenant_id = t.id
+	JOIN property_units pu ON l.unit_id = pu.id
+	JOIN properties pr ON pu.property_id = pr.id`
+
+// scanTenantPayment scans a row selected by tenantPaymentQuery
+func scanTenantPayment(row interface{ Scan(...interface{}) error }) (TenantPayment, error) {
+	var p TenantPayment
+	err := row.Scan(&p.PaymentID, &p.Amount, &p.PaymentDate, &p.PaymentMethod, &p.Status,
+		&p.AdjustsPaymentID, &p.LeaseID, &p.TenantID, &p.TenantName, &p.TenantEmail,
+		&p.PropertyName, &p.PropertyAddress, &p.UnitNumber)
+	if err == nil {
+		p.ReceiptNumber = ReceiptNumber(p.PaymentID, p.PaymentDate)
+	}
+	return p, err
+}
+
+// GetPaymentReceipt returns the receipt details for a payment. It returns sql.ErrNoRows if
+// the payment does not exist and ErrReceiptUnavailable if it is not completed. A non-zero
+// tenantID restricts it to that tenant's payments; other tenants' payments are reported as
+// sql.ErrNoRows before their status is checked, so nothing is revealed about them.
+func GetPaymentReceipt(paymentID, tenantID int) (*TenantPayment, error) {
+	receipt, err := scanTenantPayment(db.DB.QueryRow(tenantPaymentQuery+" WHERE p.id = $1", paymentID))
+	if err != nil {
+		return nil, err
+	}
+	if tenantID != 0 && receipt.TenantID != tenantID {
+		return nil, sql.ErrNoRows
+	}
+	if receipt.Status != "completed" {
+		return nil, ErrReceiptUnavailable
+	}
+	return &receipt, nil
+}
+
+// GetTenantPayments returns a tenant's payments dated in [from, to), oldest first. Zero times
+// leave that end of the range open.
+func GetTenantPayments(tenantID int, from, to time.Time) ([]TenantPayment, error) {
+	query := tenantPaymentQuery + " WHERE t.id = $1"
+	args := []interface{}{tenantID}
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND p.payment_date >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND p.payment_date < $%d", len(args))
+	}
+
+	rows, err := db.DB.Query(query+" ORDER BY p.payment_date, p.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []TenantPayment
+	for rows.Next() {
+		payment, err := scanTenantPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+// RentStatement is a tenant's year-end statement of rent paid
+type RentStatement struct {
+	TenantID    int             `json:"tenant_id"`
+	TenantName  string          `json:"tenant_name"`
+	TenantEmail string          `json:"tenant_email"`
+	Year        int             `json:"year"`
+	Payments    []TenantPayment `json:"payments"`
+	Total       float64         `json:"total"`
+}
+
+// GetRentStatement totals a tenant's completed payments, including adjusting entries, dated
+// in the given calendar year
+func GetRentStatement(tenant *Tenant, year int) (*RentStatement, error) {
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	payments, err := GetTenantPayments(tenant.ID, from, from.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &RentStatement{
+		TenantID:    tenant.ID,
+		TenantName:  strings.TrimSpace(tenant.FirstName + " " + tenant.LastName),
+		TenantEmail: tenant.Email,
+		Year:        year,
+		Payments:    []TenantPayment{},
+	}
+	for _, payment := range payments {
+		if payment.Status != "completed" {
+			continue
+		}
+		statement.Payments = append(statement.Payments, payment)
+		statement.Total += payment.Amount
+	}
+	return statement, nil
+}
+
+// EmailPaymentReceipt queues an email to the tenant with the receipt details and a link to
+// download the PDF from the tenant portal
+func EmailPaymentReceipt(receipt *TenantPayment, link string) error {
+	subject := fmt.Sprintf("Payment receipt %s", receipt.ReceiptNumber)
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"Thank you for your payment of $%.2f on %s for %s.\n\n"+
+		"Receipt number: %s\n\n"+
+		"Download your receipt:\n%s\n",
+		receipt.TenantName, receipt.Amount, receipt.PaymentDate.Format("January 2, 2006"),
+		receipt.PropertyName, receipt.ReceiptNumber, link)
+
+	return EnqueueOutboxMessage(db.DB, &OutboxMessage{
+		Channel:     "email",
+		EventType:   "payment.receipt",
+		Destination: receipt.TenantEmail,
+		Payload: map[string]interface{}{
+			"payment_id": receipt.PaymentID,
+			"subject":    subject,
+			"body":       body,
+		},
+	})
+}
+
+// GetTenantByID retrieves a tenant by ID
+func GetTenantByID(id int) (*Tenant, error) {
+	var tenant Tenant
+	var phone sql.NullString
+	err := db.DB.QueryRow(`
+		SELECT id, first_name, last_name, email, phone_number, status, created_at, updated_at
+		FROM tenants WHERE id = $1`, id).
+		Scan(&tenant.ID, &tenant.FirstName, &tenant.LastName, &tenant.Email, &phone,
+			&tenant.Status, &tenant.CreatedAt, &tenant.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	tenant.PhoneNumber = phone.String
+	return &tenant, nil
+}
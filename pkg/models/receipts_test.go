@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tenantPaymentColumns = []string{"id", "amount", "payment_date", "payment_method", "status",
+	"adjusts_payment_id", "lease_id", "tenant_id", "tenant_name", "email", "name", "address", "unit_number"}
+
+func TestReceiptNumber(t *testing.T) {
+	assert.Equal(t, "R-2024-000123", ReceiptNumber(123, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestGetPaymentReceiptRequiresCompletedPayment(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM payments p (.+) WHERE p.id = \$1`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(tenantPaymentColumns).
+			AddRow(7, 1200.0, date, "ach", "pending", nil, 3, 4, "Ann Lee", "ann@example.com", "Elm Court", "1 Elm St", "2B"))
+
+	_, err := GetPaymentReceipt(7, 0)
+	assert.Equal(t, ErrReceiptUnavailable, err)
+
+	// Another tenant learns nothing about the payment, not even that it is pending
+	mock.ExpectQuery(`FROM payments p (.+) WHERE p.id = \$1`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(tenantPaymentColumns).
+			AddRow(7, 1200.0, date, "ach", "pending", nil, 3, 4, "Ann Lee", "ann@example.com", "Elm Court", "1 Elm St", "2B"))
+	_, err = GetPaymentReceipt(7, 5)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRentStatement(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	jan := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`WHERE t.id = \$1 AND p.payment_date >= \$2 AND p.payment_date < \$3`).
+		WithArgs(4, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows(tenantPaymentColumns).
+			AddRow(1, 1200.0, jan, "ach", "completed", nil, 3, 4, "Ann Lee", "ann@example.com", "Elm Court", "1 Elm St", "2B").
+			AddRow(2, -50.0, jan, nil, "completed", 1, 3, 4, "Ann Lee", "ann@example.com", "Elm Court", "1 Elm St", "2B").
+			AddRow(3, 1200.0, feb, "ach", "failed", nil, 3, 4, "Ann Lee", "ann@example.com", "Elm Court", "1 Elm St", "2B"))
+
+	statement, err := GetRentStatement(&Tenant{ID: 4, FirstName: "Ann", LastName: "Lee"}, 2024)
+	require.NoError(t, err)
+	assert.Equal(t, "Ann Lee", statement.TenantName)
+	assert.Len(t, statement.Payments, 2)
+	assert.Equal(t, 1150.0, statement.Total)
+	assert.Equal(t, "R-2024-000001", statement.Payments[0].ReceiptNumber)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,581 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Renewal offer statuses
+const (
+	RenewalOfferSent      = "sent"
+	RenewalOfferAccepted  = "accepted"
+	RenewalOfferDeclined  = "declined"
+	RenewalOfferExpired   = "expired"
+	RenewalOfferWithdrawn = "withdrawn"
+)
+
+// DefaultRenewalTermMonths is the length of the renewal lease offered unless a manager changes it
+const DefaultRenewalTermMonths = 12
+
+// ErrRenewalOfferClosed is returned when responding to an offer that is no longer open
+var ErrRenewalOfferClosed = errors.New("renewal offer is no longer open")
+
+// RenewalOffer proposes renewal terms to a tenant ahead of their lease's expiry
+type RenewalOffer struct {
+	ID             int            `json:"id"`
+	LeaseID        int            `json:"lease_id"`
+	ProposalID     sql.NullInt32  `json:"proposal_id,omitempty"`
+	PropertyID     int            `json:"property_id"`
+	PropertyName   string         `json:"property_name,omitempty"`
+	UnitNumber     string         `json:"unit_number,omitempty"`
+	TenantID       int            `json:"tenant_id"`
+	TenantName     string         `json:"tenant_name,omitempty"`
+	LeaseEndDate   time.Time      `json:"lease_end_date"`
+	CurrentRent    float64        `json:"current_rent"`
+	ProposedRent   float64        `json:"proposed_rent"`
+	TermMonths     int            `json:"term_months"`
+	OfferExpiresAt time.Time      `json:"offer_expires_at"`
+	Status         string         `json:"status"`
+	ViewedAt       sql.NullTime   `json:"viewed_at,omitempty"`
+	RespondedAt    sql.NullTime   `json:"responded_at,omitempty"`
+	DeclineReason  sql.NullString `json:"decline_reason,omitempty"`
+	RenewalLeaseID sql.NullInt32  `json:"renewal_lease_id,omitempty"`
+	CreatedBy      sql.NullInt32  `json:"created_by,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// RenewalStart is the first day of the renewal lease the offer would create
+func (o *RenewalOffer) RenewalStart() time.Time {
+	return o.LeaseEndDate.AddDate(0, 0, 1)
+}
+
+// RenewalEnd is the last day of the renewal lease the offer would create
+func (o *RenewalOffer) RenewalEnd() time.Time {
+	return o.RenewalStart().AddDate(0, o.TermMonths, -1)
+}
+
+// IsOpen reports whether the tenant can still respond to the offer at the given time
+func (o *RenewalOffer) IsOpen(now time.Time) bool {
+	return o.Status == RenewalOfferSent && now.Before(o.OfferExpiresAt.AddDate(0, 0, 1))
+}
+
+const renewalOfferColumns = `
+	ro.id, ro.lease_id, ro.proposal_id, pu.property_id, p.name, COALESCE(pu.unit_number, ''), l.tenant_id,
+	t.first_name || ' ' || t.last_name, l.end_date, l.monthly_rent, ro.proposed_rent, ro.term_months,
+	ro.offer_expires_at, ro.status, ro.viewed_at, ro.responded_at, ro.decline_reason, ro.renewal_lease_id,
+	ro.created_by, ro.created_at, ro.updated_at`
+
+const renewalOfferJoins = `
+	FROM renewal_offers ro
+	JOIN leases l ON ro.lease_id = l.id
+	JOIN tenants t ON l.tenant_id = t.id
+	JOIN property_units pu ON l.unit_id = pu.id
+	JOIN properties p ON pu.property_id = p.id`
+
+func scanRenewalOffer(row interface{ Scan(...interface{}) error }) (*RenewalOffer, error) {
+	var o RenewalOffer
+	err := row.Scan(&o.ID, &o.LeaseID, &o.ProposalID, &o.PropertyID, &o.PropertyName, &o.UnitNumber, &o.TenantID,
+		&o.TenantName, &o.LeaseEndDate, &o.CurrentRent, &o.ProposedRent, &o.TermMonths, &o.OfferExpiresAt,
+		&o.Status, &o.ViewedAt, &o.RespondedAt, &o.DeclineReason, &o.RenewalLeaseID, &o.CreatedBy,
+		&o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// FormatRenewalOffer renders the notice sent to the tenant with a new offer
+func FormatRenewalOffer(o *RenewalOffer) (string, string) {
+	subject := fmt.Sprintf("Your lease renewal offer for %s %s", o.PropertyName, o.UnitNumber)
+	body := fmt.Sprintf("Your lease ends on %s. We would like to offer you a %d-month renewal from %s at %.2f per month "+
+		"(currently %.2f). Please accept or decline this offer in the tenant portal by %s.",
+		o.LeaseEndDate.Format("January 2, 2006"), o.TermMonths, o.RenewalStart().Format("January 2, 2006"),
+		o.ProposedRent, o.CurrentRent, o.OfferExpiresAt.Format("January 2, 2006"))
+	return subject, body
+}
+
+// renewalCandidate is an expiring lease that has not yet been offered a renewal
+type renewalCandidate struct {
+	offer        RenewalOffer
+	marketRent   sql.NullFloat64
+	tenancyStart flexibleTime
+	proposalRent sql.NullFloat64
+	tenantEmail  string
+	tenantPhone  sql.NullString
+}
+
+// GenerateRenewalOffers sends renewal offers for active leases ending within the given number of
+// days that have not been offered one yet; an offer withdrawn by a manager can be replaced. Terms
+// come from the lease's approved rent increase proposal when there is one, otherwise the rent is
+// calculated like a new proposal. Tenants have responseDays to respond, but never beyond the end
+// of their lease. A propertyID of 0 includes every property.
+func GenerateRenewalOffers(propertyID, withinDays, responseDays int, createdBy sql.NullInt32, now time.Time) ([]RenewalOffer, error) {
+	rules, err := GetRentIncreaseRules()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT l.id, l.tenant_id, l.monthly_rent, l.end_date, COALESCE(pu.market_rent, f.market_rent), pu.property_id,
+			   p.name, COALESCE(pu.unit_number, ''), t.first_name || ' ' || t.last_name, t.email, t.phone_number,
+			   (SELECT MIN(l2.start_date) FROM leases l2
+				WHERE l2.tenant_id = l.tenant_id AND l2.unit_id = l.unit_id),
+			   rp.id, rp.proposed_rent
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		JOIN tenants t ON l.tenant_id = t.id
+		LEFT JOIN floorplans f ON pu.floorplan_id = f.id
+		LEFT JOIN rent_increase_proposals rp ON rp.lease_id = l.id AND rp.status = 'approved'
+		WHERE l.status = 'active' AND l.end_date >= $1 AND l.end_date <= $2
+		  AND ($3 = 0 OR pu.property_id = $3)
+		  AND NOT EXISTS (
+			  SELECT 1 FROM renewal_offers ro
+			  WHERE ro.lease_id = l.id AND ro.status <> 'withdrawn'
+		  )
+		  AND NOT EXISTS (
+			  SELECT 1 FROM rent_increase_proposals applied
+			  WHERE applied.lease_id = l.id AND applied.status = 'applied'
+		  )
+		ORDER BY l.end_date`
+
+	rows, err := db.DB.Query(query, now, now.AddDate(0, 0, withinDays), propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []renewalCandidate
+	for rows.Next() {
+		var c renewalCandidate
+		o := &c.offer
+		err := rows.Scan(&o.LeaseID, &o.TenantID, &o.CurrentRent, &o.LeaseEndDate, &c.marketRent, &o.PropertyID,
+			&o.PropertyName, &o.UnitNumber, &o.TenantName, &c.tenantEmail, &c.tenantPhone, &c.tenancyStart,
+			&o.ProposalID, &c.proposalRent)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	offers := make([]RenewalOffer, 0, len(candidates))
+	for _, c := range candidates {
+		o := c.offer
+		o.ProposedRent = c.proposalRent.Float64
+		if !c.proposalRent.Valid {
+			tenureMonths := int(o.LeaseEndDate.Sub(c.tenancyStart.Time).Hours() / 24 / 30)
+			o.ProposedRent, _ = ProposeRent(o.CurrentRent, c.marketRent, tenureMonths, ruleForProperty(rules, o.PropertyID))
+		}
+		o.TermMonths = DefaultRenewalTermMonths
+		o.OfferExpiresAt = now.AddDate(0, 0, responseDays)
+		if o.OfferExpiresAt.After(o.LeaseEndDate) {
+			o.OfferExpiresAt = o.LeaseEndDate
+		}
+		o.Status = RenewalOfferSent
+		o.CreatedBy = createdBy
+
+		err := tx.QueryRow(`
+			INSERT INTO renewal_offers (lease_id, proposal_id, proposed_rent, term_months, offer_expires_at, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at, updated_at`,
+			o.LeaseID, o.ProposalID, o.ProposedRent, o.TermMonths, o.OfferExpiresAt, o.CreatedBy,
+		).Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := enqueueRenewalOfferNotice(tx, &o, c.tenantEmail, c.tenantPhone); err != nil {
+			return nil, err
+		}
+		offers = append(offers, o)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+// enqueueRenewalOfferNotice queues the offer notice to the tenant by email, and by SMS when they
+// have a phone number
+func enqueueRenewalOfferNotice(q Querier, o *RenewalOffer, email string, phone sql.NullString) error {
+	subject, body := FormatRenewalOffer(o)
+	payload := map[string]interface{}{
+		"lease_id":         o.LeaseID,
+		"renewal_offer_id": o.ID,
+		"proposed_rent":    o.ProposedRent,
+		"term_months":      o.TermMonths,
+		"offer_expires_at": o.OfferExpiresAt.Format("2006-01-02"),
+		"subject":          subject,
+		"body":             body,
+	}
+	if err := AddLeaseNoticeHTML(payload); err != nil {
+		return err
+	}
+
+	notices := []OutboxMessage{{Channel: "email", Destination: email}}
+	if phone.Valid && phone.String != "" {
+		notices = append(notices, OutboxMessage{Channel: "sms", Destination: phone.String})
+	}
+	for _, notice := range notices {
+		notice.EventType = "lease.renewal_offered"
+		notice.Payload = payload
+		if err := EnqueueOutboxMessage(q, &notice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRenewalOffers lists offers, optionally filtered by status and tenant (0 for every tenant)
+func GetRenewalOffers(status string, tenantID int) ([]RenewalOffer, error) {
+	query := "SELECT" + renewalOfferColumns + renewalOfferJoins + " WHERE 1=1"
+	var args []interface{}
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" AND ro.status = $%d", len(args))
+	}
+	if tenantID != 0 {
+		args = append(args, tenantID)
+		query += fmt.Sprintf(" AND l.tenant_id = $%d", len(args))
+	}
+	query += " ORDER BY l.end_date, p.name, ro.id"
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var offers []RenewalOffer
+	for rows.Next() {
+		o, err := scanRenewalOffer(rows)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, *o)
+	}
+	return offers, rows.Err()
+}
+
+// GetRenewalOffer retrieves one offer
+func GetRenewalOffer(id int) (*RenewalOffer, error) {
+	return scanRenewalOffer(db.DB.QueryRow("SELECT"+renewalOfferColumns+renewalOfferJoins+" WHERE ro.id = $1", id))
+}
+
+// MarkRenewalOffersViewed records when the tenant first saw each of their open offers
+func MarkRenewalOffersViewed(tenantID int, now time.Time) error {
+	_, err := db.DB.Exec(`
+		UPDATE renewal_offers SET viewed_at = $2
+		WHERE status = 'sent' AND viewed_at IS NULL
+		  AND lease_id IN (SELECT id FROM leases WHERE tenant_id = $1)`, tenantID, now)
+	return err
+}
+
+// tenantRenewalOffer loads an offer on one of the tenant's leases, returning sql.ErrNoRows for
+// offers that belong to someone else and ErrRenewalOfferClosed once they can no longer respond
+func tenantRenewalOffer(q Querier, id, tenantID int, now time.Time) (*RenewalOffer, error) {
+	o, err := scanRenewalOffer(q.QueryRow("SELECT"+renewalOfferColumns+renewalOfferJoins+
+		" WHERE ro.id = $1 AND l.tenant_id = $2", id, tenantID))
+	if err != nil {
+		return nil, err
+	}
+	if !o.IsOpen(now) {
+		return nil, ErrRenewalOfferClosed
+	}
+	return o, nil
+}
+
+// closeRenewalOffer moves an open offer to the given status, returning ErrRenewalOfferClosed if
+// it was closed in the meantime
+func closeRenewalOffer(q Querier, id int, status string, reason sql.NullString, renewalLeaseID sql.NullInt32, now time.Time) error {
+	result, err := q.Exec(`
+		UPDATE renewal_offers
+		SET status = $2, responded_at = $3, decline_reason = $4, renewal_lease_id = $5, updated_at = $3
+		WHERE id = $1 AND status = 'sent'`, id, status, now, reason, renewalLeaseID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return ErrRenewalOfferClosed
+	}
+	return nil
+}
+
+// AcceptRenewalOffer accepts an open offer on the tenant's behalf and creates the pending renewal
+// lease at the offered rent and term. An approved proposal the offer came from is marked applied.
+func AcceptRenewalOffer(id, tenantID int, now time.Time) (*RenewalOffer, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	o, err := tenantRenewalOffer(tx, id, tenantID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaseID int
+	err = tx.QueryRow(`
+		INSERT INTO leases (unit_id, tenant_id, start_date, end_date, monthly_rent, status, proration_convention)
+		SELECT unit_id, tenant_id, $2, $3, $4, 'pending', proration_convention FROM leases WHERE id = $1
+		RETURNING id`, o.LeaseID, o.RenewalStart(), o.RenewalEnd(), o.ProposedRent).Scan(&leaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	renewalLeaseID := sql.NullInt32{Int32: int32(leaseID), Valid: true}
+	if err := closeRenewalOffer(tx, id, RenewalOfferAccepted, sql.NullString{}, renewalLeaseID, now); err != nil {
+		return nil, err
+	}
+
+	if o.ProposalID.Valid {
+		_, err = tx.Exec(`
+			UPDATE rent_increase_proposals SET status = 'applied', renewal_lease_id = $2
+			WHERE id = $1 AND status = 'approved'`, o.ProposalID.Int32, leaseID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	o.Status = RenewalOfferAccepted
+	o.RespondedAt = sql.NullTime{Time: now, Valid: true}
+	o.RenewalLeaseID = renewalLeaseID
+	return o, nil
+}
+
+// DeclineRenewalOffer records the tenant declining an open offer, with their optional reason
+func DeclineRenewalOffer(id, tenantID int, reason string, now time.Time) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tenantRenewalOffer(tx, id, tenantID, now); err != nil {
+		return err
+	}
+	if err := closeRenewalOffer(tx, id, RenewalOfferDeclined, NullString(strings.TrimSpace(reason)), sql.NullInt32{}, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// WithdrawRenewalOffer withdraws an offer the tenant has not responded to, so that a new one can
+// be sent. It returns sql.ErrNoRows if no open offer matched.
+func WithdrawRenewalOffer(id int, now time.Time) error {
+	result, err := db.DB.Exec(`
+		UPDATE renewal_offers SET status = 'withdrawn', updated_at = $2
+		WHERE id = $1 AND status = 'sent'`, id, now)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// ExpireRenewalOffers closes offers whose response window ended before today and returns the
+// number expired
+func ExpireRenewalOffers(now time.Time) (int, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	result, err := db.DB.Exec(`
+		UPDATE renewal_offers SET status = 'expired', updated_at = $2
+		WHERE status = 'sent' AND offer_expires_at < $1`, today, now)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// RenewalFunnelLine counts one property's expiring leases at each stage of the renewal process
+type RenewalFunnelLine struct {
+	PropertyID       int     `json:"property_id"`
+	PropertyName     string  `json:"property_name"`
+	ExpiringLeases   int     `json:"expiring_leases"`
+	Offered          int     `json:"offered"`
+	Viewed           int     `json:"viewed"`
+	Accepted         int     `json:"accepted"`
+	Declined         int     `json:"declined"`
+	Expired          int     `json:"expired"`
+	Open             int     `json:"open"`
+	AcceptedIncrease float64 `json:"accepted_increase_percent"` // Average rent increase of accepted offers
+}
+
+// AcceptanceRate is the percentage of offers that were accepted
+func (l RenewalFunnelLine) AcceptanceRate() float64 {
+	if l.Offered == 0 {
+		return 0
+	}
+	return math.Round(float64(l.Accepted)/float64(l.Offered)*10000) / 100
+}
+
+// GetRenewalFunnel counts the leases ending between start and end per property, and how far their
+// latest renewal offer got. Withdrawn offers are ignored.
+func GetRenewalFunnel(propertyIDs []interface{}, start, end time.Time) ([]RenewalFunnelLine, error) {
+	query := `
+		SELECT p.id, p.name, COUNT(DISTINCT l.id), COUNT(ro.id), COUNT(ro.viewed_at),
+			   COALESCE(SUM(CASE WHEN ro.status = 'accepted' THEN 1 ELSE 0 END), 0),
+			   COALESCE(SUM(CASE WHEN ro.status = 'declined' THEN 1 ELSE 0 END), 0),
+			   COALESCE(SUM(CASE WHEN ro.status = 'expired' THEN 1 ELSE 0 END), 0),
+			   COALESCE(SUM(CASE WHEN ro.status = 'sent' THEN 1 ELSE 0 END), 0),
+			   COALESCE(AVG(CASE WHEN ro.status = 'accepted' AND l.monthly_rent > 0
+			                     THEN (ro.proposed_rent - l.monthly_rent) / l.monthly_rent * 100 END), 0)
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		LEFT JOIN renewal_offers ro ON ro.lease_id = l.id AND ro.status <> 'withdrawn'
+		WHERE l.status IN ('active', 'ended') AND l.end_date >= $1 AND l.end_date <= $2`
+	args := []interface{}{start, end}
+	if len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+1)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND p.id IN (%s)", strings.Join(placeholders, ", "))
+	}
+	query += `
+		GROUP BY p.id, p.name
+		ORDER BY p.name`
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []RenewalFunnelLine
+	for rows.Next() {
+		var l RenewalFunnelLine
+		if err := rows.Scan(&l.PropertyID, &l.PropertyName, &l.ExpiringLeases, &l.Offered, &l.Viewed, &l.Accepted,
+			&l.Declined, &l.Expired, &l.Open, &l.AcceptedIncrease); err != nil {
+			return nil, err
+		}
+		l.AcceptedIncrease = math.Round(l.AcceptedIncrease*100) / 100
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// generateRenewalsFunnelReport reports the renewal funnel for leases ending between start_date and
+// end_date (default the next 90 days) at the selected properties (criteria property_ids, default all)
+func generateRenewalsFunnelReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	startDate := time.Now()
+	endDate := startDate.AddDate(0, 0, 90)
+
+	if start, ok := parameters["start_date"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", start); err == nil {
+			startDate = parsed
+		}
+	}
+	if end, ok := parameters["end_date"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", end); err == nil {
+			endDate = parsed
+		}
+	}
+
+	propertyIDs, _ := report.Criteria["property_ids"].([]interface{})
+	lines, err := GetRenewalFunnel(propertyIDs, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	return buildRenewalsFunnelReport(lines, startDate, endDate), nil
+}
+
+// buildRenewalsFunnelReport lays out the funnel per property with totals and a chart of the stages
+func buildRenewalsFunnelReport(lines []RenewalFunnelLine, start, end time.Time) *ReportData {
+	data := &ReportData{
+		Headers: []string{"Property", "Expiring Leases", "Offered", "Viewed", "Accepted", "Declined", "Expired",
+			"Awaiting Response", "Acceptance %", "Accepted Increase %"},
+		Rows: []map[string]interface{}{},
+	}
+
+	var total RenewalFunnelLine
+	var increaseTotal float64
+	for _, l := range lines {
+		data.Rows = append(data.Rows, map[string]interface{}{
+			"Property":            l.PropertyName,
+			"Expiring Leases":     l.ExpiringLeases,
+			"Offered":             l.Offered,
+			"Viewed":              l.Viewed,
+			"Accepted":            l.Accepted,
+			"Declined":            l.Declined,
+			"Expired":             l.Expired,
+			"Awaiting Response":   l.Open,
+			"Acceptance %":        l.AcceptanceRate(),
+			"Accepted Increase %": l.AcceptedIncrease,
+		})
+
+		total.ExpiringLeases += l.ExpiringLeases
+		total.Offered += l.Offered
+		total.Viewed += l.Viewed
+		total.Accepted += l.Accepted
+		total.Declined += l.Declined
+		total.Expired += l.Expired
+		total.Open += l.Open
+		increaseTotal += l.AcceptedIncrease * float64(l.Accepted)
+	}
+
+	data.Summary = map[string]interface{}{
+		"start_date":                start.Format("2006-01-02"),
+		"end_date":                  end.Format("2006-01-02"),
+		"expiring_leases":           total.ExpiringLeases,
+		"offered":                   total.Offered,
+		"accepted":                  total.Accepted,
+		"declined":                  total.Declined,
+		"expired":                   total.Expired,
+		"awaiting_response":         total.Open,
+		"acceptance_rate":           total.AcceptanceRate(),
+		"offer_rate":                0.0,
+		"accepted_increase_percent": 0.0,
+	}
+	if total.ExpiringLeases > 0 {
+		data.Summary["offer_rate"] = math.Round(float64(total.Offered)/float64(total.ExpiringLeases)*10000) / 100
+	}
+	if total.Accepted > 0 {
+		data.Summary["accepted_increase_percent"] = math.Round(increaseTotal/float64(total.Accepted)*100) / 100
+	}
+
+	if total.ExpiringLeases > 0 {
+		data.Charts = []ChartData{{
+			Type:  "bar",
+			Title: "Renewals Funnel",
+			Data: map[string]interface{}{
+				"labels": []interface{}{"Expiring", "Offered", "Viewed", "Accepted"},
+				"datasets": []map[string]interface{}{{
+					"label":           "Leases",
+					"data":            []interface{}{total.ExpiringLeases, total.Offered, total.Viewed, total.Accepted},
+					"backgroundColor": "rgba(75, 192, 192, 0.5)",
+				}},
+			},
+		}}
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Expiring Leases": numberColumn, "Offered": numberColumn, "Viewed": numberColumn, "Accepted": numberColumn,
+		"Declined": numberColumn, "Expired": numberColumn, "Awaiting Response": numberColumn,
+		"Acceptance %": percentColumn, "Accepted Increase %": percentColumn,
+	})
+	return data
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var renewalOfferTestColumns = []string{"id", "lease_id", "proposal_id", "property_id", "property_name", "unit_number",
+	"tenant_id", "tenant_name", "end_date", "monthly_rent", "proposed_rent", "term_months", "offer_expires_at",
+	"status", "viewed_at", "responded_at", "decline_reason", "renewal_lease_id", "created_by", "created_at", "updated_at"}
+
+func TestRenewalOfferTerms(t *testing.T) {
+	offer := &RenewalOffer{
+		LeaseEndDate:   time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+		TermMonths:     12,
+		OfferExpiresAt: time.Date(2026, 11, 15, 0, 0, 0, 0, time.UTC),
+		Status:         RenewalOfferSent,
+	}
+	assert.Equal(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), offer.RenewalStart())
+	assert.Equal(t, time.Date(2027, 12, 31, 0, 0, 0, 0, time.UTC), offer.RenewalEnd())
+
+	assert.True(t, offer.IsOpen(time.Date(2026, 11, 15, 18, 0, 0, 0, time.UTC)), "open through the last day")
+	assert.False(t, offer.IsOpen(time.Date(2026, 11, 16, 0, 0, 0, 0, time.UTC)))
+	offer.Status = RenewalOfferDeclined
+	assert.False(t, offer.IsOpen(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestAcceptRenewalOfferCreatesRenewalLease(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	leaseEnd := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM renewal_offers ro\s+JOIN leases l`).WithArgs(4, 9).
+		WillReturnRows(sqlmock.NewRows(renewalOfferTestColumns).
+			AddRow(4, 20, 6, 1, "Elm Court", "2B", 9, "Dana Reyes", leaseEnd, 1500.0, 1560.0, 12,
+				now.AddDate(0, 0, 30), RenewalOfferSent, now, nil, nil, nil, nil, now, now))
+	mock.ExpectQuery(`INSERT INTO leases`).
+		WithArgs(20, leaseEnd.AddDate(0, 0, 1), time.Date(2027, 12, 31, 0, 0, 0, 0, time.UTC), 1560.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(31))
+	mock.ExpectExec(`UPDATE renewal_offers\s+SET status = \$2`).
+		WithArgs(4, RenewalOfferAccepted, now, nil, 31).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE rent_increase_proposals SET status = 'applied'`).WithArgs(int32(6), 31).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	offer, err := AcceptRenewalOffer(4, 9, now)
+	require.NoError(t, err)
+	assert.Equal(t, RenewalOfferAccepted, offer.Status)
+	assert.Equal(t, int32(31), offer.RenewalLeaseID.Int32)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeclineRenewalOfferAfterExpiry(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM renewal_offers ro\s+JOIN leases l`).WithArgs(4, 9).
+		WillReturnRows(sqlmock.NewRows(renewalOfferTestColumns).
+			AddRow(4, 20, nil, 1, "Elm Court", "2B", 9, "Dana Reyes", now.AddDate(0, 2, 0), 1500.0, 1560.0, 12,
+				now.AddDate(0, 0, -2), RenewalOfferSent, nil, nil, nil, nil, nil, now, now))
+	mock.ExpectRollback()
+
+	assert.Equal(t, ErrRenewalOfferClosed, DeclineRenewalOffer(4, 9, "Moving out of state", now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildRenewalsFunnelReport(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	lines := []RenewalFunnelLine{
+		{PropertyName: "Elm Court", ExpiringLeases: 10, Offered: 8, Viewed: 6, Accepted: 4, Declined: 2, Expired: 1, Open: 1, AcceptedIncrease: 3},
+		{PropertyName: "Oak Plaza", ExpiringLeases: 2},
+	}
+
+	data := buildRenewalsFunnelReport(lines, start, start.AddDate(0, 3, 0))
+	require.Len(t, data.Rows, 2)
+	assert.Equal(t, 50.0, data.Rows[0]["Acceptance %"])
+	assert.Equal(t, 0.0, data.Rows[1]["Acceptance %"])
+
+	assert.Equal(t, 12, data.Summary["expiring_leases"])
+	assert.Equal(t, 66.67, data.Summary["offer_rate"])
+	assert.Equal(t, 50.0, data.Summary["acceptance_rate"])
+	assert.Equal(t, 3.0, data.Summary["accepted_increase_percent"])
+	require.Len(t, data.Charts, 1)
+	assert.Equal(t, []interface{}{12, 8, 6, 4}, data.Charts[0].Data["datasets"].([]map[string]interface{})[0]["data"])
+}
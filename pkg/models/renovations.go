@@ -0,0 +1,543 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+var (
+	// ErrInvalidRenovation is returned when a renovation project is missing its name or has impossible figures
+	ErrInvalidRenovation = errors.New("a renovation needs a name, a start date, a budget and actual cost of zero or more, and an end date no earlier than its start")
+	// ErrRenovationUnit is returned when a renovation names a unit of another property
+	ErrRenovationUnit = errors.New("the unit belongs to a different property than the renovation")
+	// ErrRenovationNotComplete is returned when comparing a renovation that has not ended
+	ErrRenovationNotComplete = errors.New("the renovation has not been completed")
+)
+
+// Renovation project statuses, derived from the project dates
+const (
+	RenovationPlanned    = "planned"
+	RenovationInProgress = "in_progress"
+	RenovationCompleted  = "completed"
+)
+
+// DefaultRenovationWindowMonths is how far before the start and after the end of a renovation
+// its performance is measured
+const DefaultRenovationWindowMonths = 12
+
+// averageDaysPerMonth converts day counts to months for monthly figures
+const averageDaysPerMonth = 365.25 / 12
+
+// RenovationProject is a capital project on a property, or on one of its units when UnitID is set
+type RenovationProject struct {
+	ID         int             `json:"id"`
+	PropertyID int             `json:"property_id"`
+	UnitID     sql.NullInt32   `json:"unit_id,omitempty"`
+	UnitNumber sql.NullString  `json:"unit_number,omitempty"`
+	Name       string          `json:"name"`
+	Scope      sql.NullString  `json:"scope,omitempty"`
+	Budget     float64         `json:"budget"`
+	ActualCost sql.NullFloat64 `json:"actual_cost,omitempty"`
+	StartDate  time.Time       `json:"start_date"`
+	EndDate    sql.NullTime    `json:"end_date,omitempty"` // Completion date; null while the end is unknown
+	Status     string          `json:"status"`
+	CreatedBy  sql.NullInt32   `json:"created_by,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// Validate checks the project has a name and sensible figures and dates
+func (p *RenovationProject) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" || p.StartDate.IsZero() || p.Budget < 0 {
+		return ErrInvalidRenovation
+	}
+	if p.ActualCost.Valid && p.ActualCost.Float64 < 0 {
+		return ErrInvalidRenovation
+	}
+	if p.EndDate.Valid && p.EndDate.Time.Before(p.StartDate) {
+		return ErrInvalidRenovation
+	}
+	return nil
+}
+
+// Cost returns the actual cost of the project, or its budget until the actual cost is known
+func (p RenovationProject) Cost() float64 {
+	if p.ActualCost.Valid {
+		return p.ActualCost.Float64
+	}
+	return p.Budget
+}
+
+// statusOn derives the project status on a day: planned before it starts, completed once its end
+// date has passed and in progress otherwise
+func (p RenovationProject) statusOn(day time.Time) string {
+	switch {
+	case day.Before(p.StartDate):
+		return RenovationPlanned
+	case p.EndDate.Valid && day.After(p.EndDate.Time):
+		return RenovationCompleted
+	default:
+		return RenovationInProgress
+	}
+}
+
+const renovationColumns = `r.id, r.property_id, r.unit_id, pu.unit_number, r.name, r.scope, r.budget, r.actual_cost,
+	r.start_date, r.end_date, r.created_by, r.created_at, r.updated_at`
+
+func scanRenovationProject(row interface{ Scan(...interface{}) error }) (RenovationProject, error) {
+	var p RenovationProject
+	err := row.Scan(&p.ID, &p.PropertyID, &p.UnitID, &p.UnitNumber, &p.Name, &p.Scope, &p.Budget, &p.ActualCost,
+		&p.StartDate, &p.EndDate, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+	p.Status = p.statusOn(currentDate())
+	return p, nil
+}
+
+// currentDate returns the current date at midnight UTC, matching DATE columns
+func currentDate() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// checkRenovationUnit checks the project's property exists and its unit, if any, belongs to it.
+// It returns sql.ErrNoRows when either does not exist.
+func checkRenovationUnit(p *RenovationProject) error {
+	var exists int
+	if err := db.DB.QueryRow("SELECT 1 FROM properties WHERE id = $1", p.PropertyID).Scan(&exists); err != nil {
+		return err
+	}
+	if !p.UnitID.Valid {
+		return nil
+	}
+	var unitPropertyID int
+	if err := db.DB.QueryRow("SELECT property_id FROM property_units WHERE id = $1", p.UnitID.Int32).Scan(&unitPropertyID); err != nil {
+		return err
+	}
+	if unitPropertyID != p.PropertyID {
+		return ErrRenovationUnit
+	}
+	return nil
+}
+
+// CreateRenovationProject saves a new renovation project. It returns sql.ErrNoRows when the
+// property or unit does not exist.
+func CreateRenovationProject(p *RenovationProject) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := checkRenovationUnit(p); err != nil {
+		return err
+	}
+	err := db.DB.QueryRow(`
+		INSERT INTO renovation_projects (property_id, unit_id, name, scope, budget, actual_cost, start_date, end_date, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at`,
+		p.PropertyID, p.UnitID, p.Name, p.Scope, p.Budget, p.ActualCost, p.StartDate, p.EndDate, p.CreatedBy).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	p.Status = p.statusOn(currentDate())
+	return nil
+}
+
+// UpdateRenovationProject changes a renovation project. Its property cannot change; the unit
+// must belong to the project's property.
+func UpdateRenovationProject(p *RenovationProject) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := db.DB.QueryRow("SELECT property_id FROM renovation_projects WHERE id = $1", p.ID).Scan(&p.PropertyID); err != nil {
+		return err
+	}
+	if err := checkRenovationUnit(p); err != nil {
+		return err
+	}
+	result, err := db.DB.Exec(`
+		UPDATE renovation_projects
+		SET unit_id = $2, name = $3, scope = $4, budget = $5, actual_cost = $6, start_date = $7, end_date = $8,
+			updated_at = NOW()
+		WHERE id = $1`,
+		p.ID, p.UnitID, p.Name, p.Scope, p.Budget, p.ActualCost, p.StartDate, p.EndDate)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteRenovationProject removes a renovation project
+func DeleteRenovationProject(id int) error {
+	result, err := db.DB.Exec("DELETE FROM renovation_projects WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetRenovationProject retrieves a renovation project by ID
+func GetRenovationProject(id int) (*RenovationProject, error) {
+	p, err := scanRenovationProject(db.ReadDB().QueryRow(`
+		SELECT `+renovationColumns+`
+		FROM renovation_projects r
+		LEFT JOIN property_units pu ON r.unit_id = pu.id
+		WHERE r.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetRenovationProjects returns a property's renovation projects, most recent first
+func GetRenovationProjects(propertyID int) ([]RenovationProject, error) {
+	return queryRenovationProjects(`
+		SELECT `+renovationColumns+`
+		FROM renovation_projects r
+		LEFT JOIN property_units pu ON r.unit_id = pu.id
+		WHERE r.property_id = $1
+		ORDER BY r.start_date DESC, r.id DESC`, propertyID)
+}
+
+func queryRenovationProjects(query string, args ...interface{}) ([]RenovationProject, error) {
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []RenovationProject
+	for rows.Next() {
+		p, err := scanRenovationProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// RenovationPeriod is the performance of a renovation's units over a window before or after it
+type RenovationPeriod struct {
+	From                    time.Time `json:"from"`
+	To                      time.Time `json:"to"`
+	Days                    int       `json:"days"`
+	AverageRent             float64   `json:"average_rent"`      // Of the leases in force during the window
+	OccupancyPercent        float64   `json:"occupancy_percent"` // Share of unit-days under lease
+	MaintenanceCost         float64   `json:"maintenance_cost"`  // Of the whole property, by completion date
+	MaintenancePerUnitMonth float64   `json:"maintenance_per_unit_month"`
+}
+
+// RenovationComparison compares a completed renovation's units before its start and after its
+// end, and estimates the return on its cost from the change in rent income less maintenance
+type RenovationComparison struct {
+	Project           RenovationProject `json:"project"`
+	Units             int               `json:"units"`
+	WindowMonths      int               `json:"window_months"`
+	Before            RenovationPeriod  `json:"before"`
+	After             RenovationPeriod  `json:"after"`
+	RentChange        float64           `json:"rent_change"`
+	RentChangePercent float64           `json:"rent_change_percent"`
+	OccupancyChange   float64           `json:"occupancy_change"` // Percentage points
+	MaintenanceChange float64           `json:"maintenance_change"`
+	MonthlyNetGain    float64           `json:"monthly_net_gain"` // Rent income less maintenance, after minus before
+	AnnualROIPercent  float64           `json:"annual_roi_percent"`
+	PaybackMonths     sql.NullFloat64   `json:"payback_months,omitempty"` // Null when the project does not pay back
+}
+
+// renovationWindows returns the windows before the project's start and after its end, each up
+// to months long. The after window stops at asOf, so a recently completed project is measured
+// over the months it has had.
+func renovationWindows(p RenovationProject, months int, asOf time.Time) (before, after RenovationPeriod, err error) {
+	if !p.EndDate.Valid || !asOf.After(p.EndDate.Time) {
+		return before, after, ErrRenovationNotComplete
+	}
+	before.To = p.StartDate.AddDate(0, 0, -1)
+	before.From = p.StartDate.AddDate(0, -months, 0)
+	after.From = p.EndDate.Time.AddDate(0, 0, 1)
+	after.To = p.EndDate.Time.AddDate(0, months, 0)
+	if after.To.After(asOf) {
+		after.To = asOf
+	}
+	before.Days = int(before.To.Sub(before.From).Hours()/24) + 1
+	after.Days = int(after.To.Sub(after.From).Hours()/24) + 1
+	return before, after, nil
+}
+
+// computeChanges fills in the monthly maintenance per unit and the change between the periods.
+// The net gain is the change in monthly rent income (average rent at the period's occupancy)
+// less the change in maintenance, across the project's units.
+func (c *RenovationComparison) computeChanges() {
+	var netPerUnit [2]float64
+	for i, period := range []*RenovationPeriod{&c.Before, &c.After} {
+		var maintenance float64
+		if months := float64(period.Days) / averageDaysPerMonth; c.Units > 0 && months > 0 {
+			maintenance = period.MaintenanceCost / float64(c.Units) / months
+		}
+		period.MaintenancePerUnitMonth = math.Round(maintenance*100) / 100
+		netPerUnit[i] = period.AverageRent*period.OccupancyPercent/100 - maintenance
+	}
+
+	c.RentChange = math.Round((c.After.AverageRent-c.Before.AverageRent)*100) / 100
+	c.RentChangePercent = 0
+	if c.Before.AverageRent > 0 {
+		c.RentChangePercent = math.Round(c.RentChange/c.Before.AverageRent*10000) / 100
+	}
+	c.OccupancyChange = math.Round((c.After.OccupancyPercent-c.Before.OccupancyPercent)*100) / 100
+	c.MaintenanceChange = math.Round((c.After.MaintenancePerUnitMonth-c.Before.MaintenancePerUnitMonth)*100) / 100
+
+	gain := (netPerUnit[1] - netPerUnit[0]) * float64(c.Units)
+	c.MonthlyNetGain = math.Round(gain*100) / 100
+
+	cost := c.Project.Cost()
+	c.AnnualROIPercent = 0
+	c.PaybackMonths = sql.NullFloat64{}
+	if cost > 0 {
+		c.AnnualROIPercent = math.Round(gain*12/cost*10000) / 100
+	}
+	if gain > 0 {
+		c.PaybackMonths = sql.NullFloat64{Float64: math.Round(cost/gain*10) / 10, Valid: true}
+	}
+}
+
+// GetRenovationComparison compares a completed renovation over windows of months before and
+// after it, as of asOf. It returns sql.ErrNoRows when the project does not exist and
+// ErrRenovationNotComplete when it has not ended by asOf.
+func GetRenovationComparison(id, months int, asOf time.Time) (*RenovationComparison, error) {
+	p, err := GetRenovationProject(id)
+	if err != nil {
+		return nil, err
+	}
+	if months <= 0 {
+		months = DefaultRenovationWindowMonths
+	}
+	before, after, err := renovationWindows(*p, months, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &RenovationComparison{Project: *p, WindowMonths: months, Units: 1, Before: before, After: after}
+	var propertyUnits int
+	if err := db.ReadDB().QueryRow("SELECT COUNT(*) FROM property_units WHERE property_id = $1", p.PropertyID).
+		Scan(&propertyUnits); err != nil {
+		return nil, err
+	}
+	if !p.UnitID.Valid {
+		c.Units = propertyUnits
+	}
+
+	for _, period := range []*RenovationPeriod{&c.Before, &c.After} {
+		if err := loadRenovationPeriod(*p, c.Units, propertyUnits, period); err != nil {
+			return nil, err
+		}
+	}
+	c.computeChanges()
+	return c, nil
+}
+
+// loadRenovationPeriod measures rent and occupancy of the project's units over the period.
+// Maintenance requests are recorded per property, so the property's maintenance cost is
+// apportioned to the project's units by unit count.
+func loadRenovationPeriod(p RenovationProject, units, propertyUnits int, period *RenovationPeriod) error {
+	query := `
+		SELECT COALESCE(AVG(l.monthly_rent), 0),
+			   COALESCE(SUM((LEAST(l.end_date, $3) - GREATEST(l.start_date, $2)) + 1), 0)
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		WHERE pu.property_id = $1 AND l.status IN ('active', 'ended')
+		  AND l.start_date <= $3 AND l.end_date >= $2`
+	args := []interface{}{p.PropertyID, period.From, period.To}
+	if p.UnitID.Valid {
+		query += " AND pu.id = $4"
+		args = append(args, p.UnitID.Int32)
+	}
+
+	var occupiedDays int
+	if err := db.ReadDB().QueryRow(query, args...).Scan(&period.AverageRent, &occupiedDays); err != nil {
+		return err
+	}
+	period.AverageRent = math.Round(period.AverageRent*100) / 100
+	if units > 0 && period.Days > 0 {
+		// Overlapping leases on a unit can count a day twice
+		occupancy := math.Min(float64(occupiedDays)/float64(units*period.Days)*100, 100)
+		period.OccupancyPercent = math.Round(occupancy*100) / 100
+	}
+
+	var propertyCost float64
+	if err := db.ReadDB().QueryRow(`
+		SELECT COALESCE(SUM(actual_cost), 0) FROM maintenance_requests
+		WHERE property_id = $1 AND completed_date >= $2 AND completed_date <= $3`,
+		p.PropertyID, period.From, period.To).Scan(&propertyCost); err != nil {
+		return err
+	}
+	if propertyUnits > 0 {
+		propertyCost = propertyCost * float64(units) / float64(propertyUnits)
+	}
+	period.MaintenanceCost = math.Round(propertyCost*100) / 100
+	return nil
+}
+
+// generateRenovationROIReport compares every renovation completed before parameters["as_of"]
+// (default today) over parameters["window_months"] (default 12) before and after it, for the
+// criteria's property_ids or every property
+func generateRenovationROIReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	asOf := currentDate()
+	if value, ok := parameters["as_of"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			asOf = parsed
+		}
+	}
+	months := DefaultRenovationWindowMonths
+	if value, ok := parameters["window_months"].(float64); ok && value > 0 {
+		months = int(value)
+	}
+
+	query := `
+		SELECT r.id FROM renovation_projects r
+		WHERE r.end_date IS NOT NULL AND r.end_date < $1`
+	args := []interface{}{asOf}
+	if propertyIDs, _ := report.Criteria["property_ids"].([]interface{}); len(propertyIDs) > 0 {
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+1)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND r.property_id IN (%s)", strings.Join(placeholders, ", "))
+	}
+	query += " ORDER BY r.end_date DESC, r.id DESC"
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	comparisons := make([]RenovationComparison, 0, len(ids))
+	for _, id := range ids {
+		c, err := GetRenovationComparison(id, months, asOf)
+		if err != nil {
+			return nil, err
+		}
+		comparisons = append(comparisons, *c)
+	}
+
+	propertyNames, err := renovationPropertyNames(comparisons)
+	if err != nil {
+		return nil, err
+	}
+	return buildRenovationROIReport(comparisons, propertyNames, months, asOf), nil
+}
+
+// renovationPropertyNames looks up the names of the compared projects' properties
+func renovationPropertyNames(comparisons []RenovationComparison) (map[int]string, error) {
+	names := map[int]string{}
+	for _, c := range comparisons {
+		if _, ok := names[c.Project.PropertyID]; ok {
+			continue
+		}
+		var name string
+		if err := db.ReadDB().QueryRow("SELECT name FROM properties WHERE id = $1", c.Project.PropertyID).Scan(&name); err != nil {
+			return nil, err
+		}
+		names[c.Project.PropertyID] = name
+	}
+	return names, nil
+}
+
+// buildRenovationROIReport lays out one row per renovation with totals and a chart of returns
+func buildRenovationROIReport(comparisons []RenovationComparison, propertyNames map[int]string, months int, asOf time.Time) *ReportData {
+	data := &ReportData{
+		Headers: []string{"Property", "Unit", "Project", "Completed", "Cost", "Rent Before", "Rent After",
+			"Rent Change %", "Occupancy Before %", "Occupancy After %", "Maintenance/Unit Before", "Maintenance/Unit After",
+			"Monthly Net Gain", "Annual ROI %", "Payback Months"},
+		Rows: []map[string]interface{}{},
+	}
+
+	var totalCost, totalGain float64
+	labels := make([]interface{}, len(comparisons))
+	returns := make([]interface{}, len(comparisons))
+	for i, c := range comparisons {
+		unit := "Whole property"
+		if c.Project.UnitNumber.Valid {
+			unit = c.Project.UnitNumber.String
+		}
+		row := map[string]interface{}{
+			"Property":                propertyNames[c.Project.PropertyID],
+			"Unit":                    unit,
+			"Project":                 c.Project.Name,
+			"Completed":               c.Project.EndDate.Time.Format("2006-01-02"),
+			"Cost":                    c.Project.Cost(),
+			"Rent Before":             c.Before.AverageRent,
+			"Rent After":              c.After.AverageRent,
+			"Rent Change %":           c.RentChangePercent,
+			"Occupancy Before %":      c.Before.OccupancyPercent,
+			"Occupancy After %":       c.After.OccupancyPercent,
+			"Maintenance/Unit Before": c.Before.MaintenancePerUnitMonth,
+			"Maintenance/Unit After":  c.After.MaintenancePerUnitMonth,
+			"Monthly Net Gain":        c.MonthlyNetGain,
+			"Annual ROI %":            c.AnnualROIPercent,
+		}
+		if c.PaybackMonths.Valid {
+			row["Payback Months"] = c.PaybackMonths.Float64
+		}
+		data.Rows = append(data.Rows, row)
+
+		totalCost += c.Project.Cost()
+		totalGain += c.MonthlyNetGain
+		labels[i] = c.Project.Name
+		returns[i] = c.AnnualROIPercent
+	}
+
+	data.Summary = map[string]interface{}{
+		"as_of":              asOf.Format("2006-01-02"),
+		"window_months":      months,
+		"project_count":      len(comparisons),
+		"total_cost":         math.Round(totalCost*100) / 100,
+		"monthly_net_gain":   math.Round(totalGain*100) / 100,
+		"annual_roi_percent": 0.0,
+	}
+	if totalCost > 0 {
+		data.Summary["annual_roi_percent"] = math.Round(totalGain*12/totalCost*10000) / 100
+	}
+
+	if len(comparisons) > 0 {
+		data.Charts = []ChartData{{
+			Type:  "bar",
+			Title: "Annual ROI by Renovation",
+			Data: map[string]interface{}{
+				"labels": labels,
+				"datasets": []map[string]interface{}{{
+					"label":           "Annual ROI %",
+					"data":            returns,
+					"backgroundColor": "rgba(75, 192, 192, 0.5)",
+				}},
+			},
+		}}
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Completed": dateColumn, "Cost": currencyColumn, "Rent Before": currencyColumn, "Rent After": currencyColumn,
+		"Rent Change %": percentColumn, "Occupancy Before %": percentColumn, "Occupancy After %": percentColumn,
+		"Maintenance/Unit Before": currencyColumn, "Maintenance/Unit After": currencyColumn, "Monthly Net Gain": currencyColumn,
+		"Annual ROI %": percentColumn, "Payback Months": numberColumn.WithDecimals(1),
+	})
+	return data
+}
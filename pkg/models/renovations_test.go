@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renovationDate(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func TestRenovationProjectValidate(t *testing.T) {
+	p := RenovationProject{Name: " Kitchen remodel ", Budget: 12000, StartDate: renovationDate("2025-03-01")}
+	require.NoError(t, p.Validate())
+	assert.Equal(t, "Kitchen remodel", p.Name)
+
+	invalid := []RenovationProject{
+		{Budget: 100, StartDate: renovationDate("2025-03-01")},
+		{Name: "Roof", Budget: 100},
+		{Name: "Roof", Budget: -1, StartDate: renovationDate("2025-03-01")},
+		{Name: "Roof", Budget: 100, StartDate: renovationDate("2025-03-01"), ActualCost: sql.NullFloat64{Float64: -5, Valid: true}},
+		{Name: "Roof", Budget: 100, StartDate: renovationDate("2025-03-01"), EndDate: sql.NullTime{Time: renovationDate("2025-02-28"), Valid: true}},
+	}
+	for _, p := range invalid {
+		assert.Equal(t, ErrInvalidRenovation, p.Validate(), "%+v", p)
+	}
+}
+
+func TestRenovationStatusAndCost(t *testing.T) {
+	p := RenovationProject{Budget: 10000, StartDate: renovationDate("2025-03-01")}
+	assert.Equal(t, RenovationPlanned, p.statusOn(renovationDate("2025-02-28")))
+	assert.Equal(t, RenovationInProgress, p.statusOn(renovationDate("2026-01-01")), "no end date yet")
+
+	p.EndDate = sql.NullTime{Time: renovationDate("2025-04-15"), Valid: true}
+	assert.Equal(t, RenovationInProgress, p.statusOn(renovationDate("2025-04-15")))
+	assert.Equal(t, RenovationCompleted, p.statusOn(renovationDate("2025-04-16")))
+
+	assert.Equal(t, 10000.0, p.Cost(), "the budget until the actual cost is known")
+	p.ActualCost = sql.NullFloat64{Float64: 11250, Valid: true}
+	assert.Equal(t, 11250.0, p.Cost())
+}
+
+func TestRenovationWindows(t *testing.T) {
+	p := RenovationProject{StartDate: renovationDate("2025-03-01")}
+	_, _, err := renovationWindows(p, 12, renovationDate("2026-01-01"))
+	assert.Equal(t, ErrRenovationNotComplete, err)
+
+	p.EndDate = sql.NullTime{Time: renovationDate("2025-04-30"), Valid: true}
+	_, _, err = renovationWindows(p, 12, renovationDate("2025-04-30"))
+	assert.Equal(t, ErrRenovationNotComplete, err, "the after window has not started")
+
+	before, after, err := renovationWindows(p, 12, renovationDate("2027-01-01"))
+	require.NoError(t, err)
+	assert.Equal(t, renovationDate("2024-03-01"), before.From)
+	assert.Equal(t, renovationDate("2025-02-28"), before.To)
+	assert.Equal(t, 365, before.Days)
+	assert.Equal(t, renovationDate("2025-05-01"), after.From)
+	assert.Equal(t, renovationDate("2026-04-30"), after.To)
+	assert.Equal(t, 365, after.Days)
+
+	_, after, err = renovationWindows(p, 12, renovationDate("2025-07-31"))
+	require.NoError(t, err)
+	assert.Equal(t, renovationDate("2025-07-31"), after.To, "stops at as_of")
+	assert.Equal(t, 92, after.Days)
+}
+
+func TestRenovationComparisonComputeChanges(t *testing.T) {
+	c := RenovationComparison{
+		Project: RenovationProject{Budget: 20000, ActualCost: sql.NullFloat64{Float64: 24000, Valid: true}},
+		Units:   2,
+		// 487 days is exactly 16 average months
+		Before: RenovationPeriod{Days: 487, AverageRent: 1500, OccupancyPercent: 90, MaintenanceCost: 3200},
+		After:  RenovationPeriod{Days: 487, AverageRent: 1800, OccupancyPercent: 100, MaintenanceCost: 1600},
+	}
+	c.computeChanges()
+
+	assert.Equal(t, 300.0, c.RentChange)
+	assert.Equal(t, 20.0, c.RentChangePercent)
+	assert.Equal(t, 10.0, c.OccupancyChange)
+	assert.Equal(t, 100.0, c.Before.MaintenancePerUnitMonth)
+	assert.Equal(t, 50.0, c.After.MaintenancePerUnitMonth)
+	assert.Equal(t, -50.0, c.MaintenanceChange)
+	// Per unit: (1800 - 50) - (1350 - 100) = 500 a month
+	assert.Equal(t, 1000.0, c.MonthlyNetGain)
+	assert.Equal(t, 50.0, c.AnnualROIPercent)
+	assert.Equal(t, sql.NullFloat64{Float64: 24, Valid: true}, c.PaybackMonths)
+
+	loss := RenovationComparison{
+		Project: RenovationProject{Budget: 5000},
+		Units:   1,
+		Before:  RenovationPeriod{Days: 365, AverageRent: 1000, OccupancyPercent: 100},
+		After:   RenovationPeriod{Days: 365, AverageRent: 1000, OccupancyPercent: 50},
+	}
+	loss.computeChanges()
+	assert.Equal(t, -500.0, loss.MonthlyNetGain)
+	assert.Equal(t, -120.0, loss.AnnualROIPercent)
+	assert.False(t, loss.PaybackMonths.Valid, "a project that loses money never pays back")
+}
+
+func TestBuildRenovationROIReport(t *testing.T) {
+	unitProject := RenovationComparison{
+		Project: RenovationProject{PropertyID: 1, Name: "Kitchen", UnitNumber: NullString("4A"), Budget: 6000,
+			EndDate: sql.NullTime{Time: renovationDate("2025-04-30"), Valid: true}},
+		AnnualROIPercent: 40,
+		MonthlyNetGain:   200,
+		PaybackMonths:    sql.NullFloat64{Float64: 30, Valid: true},
+	}
+	roof := RenovationComparison{
+		Project: RenovationProject{PropertyID: 1, Name: "Roof", Budget: 18000,
+			EndDate: sql.NullTime{Time: renovationDate("2024-10-01"), Valid: true}},
+		MonthlyNetGain: -100,
+	}
+
+	data := buildRenovationROIReport([]RenovationComparison{unitProject, roof}, map[int]string{1: "Maple Court"}, 12, renovationDate("2026-01-01"))
+	require.Len(t, data.Rows, 2)
+	assert.Equal(t, "4A", data.Rows[0]["Unit"])
+	assert.Equal(t, "2025-04-30", data.Rows[0]["Completed"])
+	assert.Equal(t, 30.0, data.Rows[0]["Payback Months"])
+	assert.Equal(t, "Whole property", data.Rows[1]["Unit"])
+	assert.NotContains(t, data.Rows[1], "Payback Months")
+
+	assert.Equal(t, 24000.0, data.Summary["total_cost"])
+	assert.Equal(t, 100.0, data.Summary["monthly_net_gain"])
+	assert.Equal(t, 5.0, data.Summary["annual_roi_percent"])
+	assert.Len(t, data.Columns, len(data.Headers))
+	require.Len(t, data.Charts, 1)
+}
@@ -0,0 +1,388 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Rent increase proposal statuses
+const (
+	ProposalStatusPending  = "pending"
+	ProposalStatusApproved = "approved"
+	ProposalStatusRejected = "rejected"
+	ProposalStatusApplied  = "applied"
+)
+
+// RentIncreaseRule caps proposed increases, either by default or for one property
+type RentIncreaseRule struct {
+	ID                int             `json:"id"`
+	PropertyID        sql.NullInt32   `json:"property_id,omitempty"`
+	Jurisdiction      sql.NullString  `json:"jurisdiction,omitempty"`
+	MaxPercentPerYear sql.NullFloat64 `json:"max_percent_per_year,omitempty"`
+	MaxAmount         sql.NullFloat64 `json:"max_amount,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+// RentIncreaseProposal is a proposed rent change for a lease renewal
+type RentIncreaseProposal struct {
+	ID             int             `json:"id"`
+	LeaseID        int             `json:"lease_id"`
+	PropertyName   string          `json:"property_name,omitempty"`
+	UnitNumber     string          `json:"unit_number,omitempty"`
+	TenantName     string          `json:"tenant_name,omitempty"`
+	LeaseEndDate   time.Time       `json:"lease_end_date"`
+	CurrentRent    float64         `json:"current_rent"`
+	MarketRent     sql.NullFloat64 `json:"market_rent,omitempty"`
+	ProposedRent   float64         `json:"proposed_rent"`
+	Reason         sql.NullString  `json:"reason,omitempty"`
+	Status         string          `json:"status"`
+	ReviewedBy     sql.NullInt32   `json:"reviewed_by,omitempty"`
+	ReviewedAt     sql.NullTime    `json:"reviewed_at,omitempty"`
+	RenewalLeaseID sql.NullInt32   `json:"renewal_lease_id,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// ProposeRent calculates a renewal rent from the market rent, the tenant's tenure and the rule's caps.
+// Long-standing tenants close less of the gap to market: 75% after a year, 50% after three.
+func ProposeRent(currentRent float64, marketRent sql.NullFloat64, tenureMonths int, rule *RentIncreaseRule) (float64, string) {
+	if !marketRent.Valid {
+		return currentRent, "No market rent set for unit"
+	}
+
+	gap := marketRent.Float64 - currentRent
+	if gap <= 0 {
+		return currentRent, "Current rent is at or above market"
+	}
+
+	share, reason := 1.0, "Raise to market rent"
+	switch {
+	case tenureMonths >= 36:
+		share, reason = 0.5, fmt.Sprintf("Close 50%% of gap to market (tenure %d months)", tenureMonths)
+	case tenureMonths >= 12:
+		share, reason = 0.75, fmt.Sprintf("Close 75%% of gap to market (tenure %d months)", tenureMonths)
+	}
+	increase := gap * share
+
+	if rule != nil {
+		if rule.MaxPercentPerYear.Valid {
+			if limit := currentRent * rule.MaxPercentPerYear.Float64 / 100; increase > limit {
+				increase = limit
+				reason = fmt.Sprintf("Capped at %.2f%% per year", rule.MaxPercentPerYear.Float64)
+			}
+		}
+		if rule.MaxAmount.Valid && increase > rule.MaxAmount.Float64 {
+			increase = rule.MaxAmount.Float64
+			reason = fmt.Sprintf("Capped at %.2f per renewal", rule.MaxAmount.Float64)
+		}
+		if rule.Jurisdiction.Valid && rule.Jurisdiction.String != "" && reason != "Raise to market rent" {
+			reason += " (" + rule.Jurisdiction.String + ")"
+		}
+	}
+
+	// Round down to whole currency units so caps are never exceeded
+	return currentRent + math.Floor(increase), reason
+}
+
+// SetUnitMarketRent updates the market rent of a unit. It returns sql.ErrNoRows if the unit does not exist.
+func SetUnitMarketRent(unitID int, marketRent float64) error {
+	result, err := db.DB.Exec("UPDATE property_units SET market_rent = $2, updated_at = NOW() WHERE id = $1",
+		unitID, marketRent)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetRentIncreaseRules retrieves all configured rules
+func GetRentIncreaseRules() ([]RentIncreaseRule, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, property_id, jurisdiction, max_percent_per_year, max_amount, created_at, updated_at
+		FROM rent_increase_rules
+		ORDER BY property_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []RentIncreaseRule
+	for rows.Next() {
+		var rule RentIncreaseRule
+		err := rows.Scan(&rule.ID, &rule.PropertyID, &rule.Jurisdiction, &rule.MaxPercentPerYear,
+			&rule.MaxAmount, &rule.CreatedAt, &rule.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// SaveRentIncreaseRule creates or replaces the rule for rule.PropertyID (or the default rule)
+func SaveRentIncreaseRule(rule *RentIncreaseRule) error {
+	var existingID int
+	var err error
+	if rule.PropertyID.Valid {
+		err = db.DB.QueryRow("SELECT id FROM rent_increase_rules WHERE property_id = $1",
+			rule.PropertyID).Scan(&existingID)
+	} else {
+		err = db.DB.QueryRow("SELECT id FROM rent_increase_rules WHERE property_id IS NULL").Scan(&existingID)
+	}
+
+	switch {
+	case err == sql.ErrNoRows:
+		query := `
+			INSERT INTO rent_increase_rules (property_id, jurisdiction, max_percent_per_year, max_amount)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, updated_at`
+		return db.DB.QueryRow(query, rule.PropertyID, rule.Jurisdiction, rule.MaxPercentPerYear,
+			rule.MaxAmount).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	case err != nil:
+		return err
+	}
+
+	query := `
+		UPDATE rent_increase_rules
+		SET jurisdiction = $2, max_percent_per_year = $3, max_amount = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING id, created_at, updated_at`
+	return db.DB.QueryRow(query, existingID, rule.Jurisdiction, rule.MaxPercentPerYear,
+		rule.MaxAmount).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+}
+
+// ruleForProperty picks the property's rule, falling back to the default rule
+func ruleForProperty(rules []RentIncreaseRule, propertyID int) *RentIncreaseRule {
+	var fallback *RentIncreaseRule
+	for i := range rules {
+		if rules[i].PropertyID.Valid && int(rules[i].PropertyID.Int32) == propertyID {
+			return &rules[i]
+		}
+		if !rules[i].PropertyID.Valid {
+			fallback = &rules[i]
+		}
+	}
+	return fallback
+}
+
+// GenerateRentIncreaseProposals creates pending proposals for active leases ending within
+// the given number of days that do not already have an open proposal. A propertyID of 0
+// includes every property.
+func GenerateRentIncreaseProposals(propertyID, withinDays int) ([]RentIncreaseProposal, error) {
+	rules, err := GetRentIncreaseRules()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	query := `
+		SELECT l.id, l.monthly_rent, l.end_date, COALESCE(pu.market_rent, f.market_rent), pu.property_id,
+			   (SELECT MIN(l2.start_date) FROM leases l2
+				WHERE l2.tenant_id = l.tenant_id AND l2.unit_id = l.unit_id)
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		LEFT JOIN floorplans f ON pu.floorplan_id = f.id
+		WHERE l.status = 'active' AND l.end_date >= $1 AND l.end_date <= $2
+		  AND ($3 = 0 OR pu.property_id = $3)
+		  AND NOT EXISTS (
+			  SELECT 1 FROM rent_increase_proposals rp
+			  WHERE rp.lease_id = l.id AND rp.status IN ('pending', 'approved', 'applied')
+		  )
+		ORDER BY l.end_date`
+
+	rows, err := db.DB.Query(query, now, now.AddDate(0, 0, withinDays), propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var proposals []RentIncreaseProposal
+	for rows.Next() {
+		var proposal RentIncreaseProposal
+		var unitPropertyID int
+		var tenancyStart flexibleTime
+		err := rows.Scan(&proposal.LeaseID, &proposal.CurrentRent, &proposal.LeaseEndDate,
+			&proposal.MarketRent, &unitPropertyID, &tenancyStart)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		tenureMonths := int(proposal.LeaseEndDate.Sub(tenancyStart.Time).Hours() / 24 / 30)
+		proposedRent, reason := ProposeRent(proposal.CurrentRent, proposal.MarketRent, tenureMonths,
+			ruleForProperty(rules, unitPropertyID))
+		proposal.ProposedRent = proposedRent
+		proposal.Reason = NullString(reason)
+		proposal.Status = ProposalStatusPending
+		proposals = append(proposals, proposal)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range proposals {
+		query := `
+			INSERT INTO rent_increase_proposals (lease_id, current_rent, market_rent, proposed_rent, reason)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at`
+		err := db.DB.QueryRow(query, proposals[i].LeaseID, proposals[i].CurrentRent, proposals[i].MarketRent,
+			proposals[i].ProposedRent, proposals[i].Reason).Scan(&proposals[i].ID, &proposals[i].CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return proposals, nil
+}
+
+// GetRentIncreaseProposals retrieves proposals for review, optionally filtered by status
+func GetRentIncreaseProposals(status string) ([]RentIncreaseProposal, error) {
+	query := `
+		SELECT rp.id, rp.lease_id, p.name, COALESCE(pu.unit_number, ''),
+			   t.first_name || ' ' || t.last_name, l.end_date, rp.current_rent, rp.market_rent,
+			   rp.proposed_rent, rp.reason, rp.status, rp.reviewed_by, rp.reviewed_at,
+			   rp.renewal_lease_id, rp.created_at
+		FROM rent_increase_proposals rp
+		JOIN leases l ON rp.lease_id = l.id
+		JOIN tenants t ON l.tenant_id = t.id
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id`
+	var args []interface{}
+	if status != "" {
+		query += " WHERE rp.status = $1"
+		args = append(args, status)
+	}
+	query += " ORDER BY l.end_date, p.name"
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var proposals []RentIncreaseProposal
+	for rows.Next() {
+		var proposal RentIncreaseProposal
+		err := rows.Scan(&proposal.ID, &proposal.LeaseID, &proposal.PropertyName, &proposal.UnitNumber,
+			&proposal.TenantName, &proposal.LeaseEndDate, &proposal.CurrentRent, &proposal.MarketRent,
+			&proposal.ProposedRent, &proposal.Reason, &proposal.Status, &proposal.ReviewedBy,
+			&proposal.ReviewedAt, &proposal.RenewalLeaseID, &proposal.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, proposal)
+	}
+
+	return proposals, nil
+}
+
+// ReviewRentIncreaseProposal approves or rejects a pending proposal. A non-nil proposedRent
+// overrides the calculated amount. It returns sql.ErrNoRows if no pending proposal matched.
+func ReviewRentIncreaseProposal(id int, approve bool, proposedRent *float64, reviewerID int) error {
+	status := ProposalStatusRejected
+	if approve {
+		status = ProposalStatusApproved
+	}
+
+	query := `
+		UPDATE rent_increase_proposals
+		SET status = $2, proposed_rent = COALESCE($3, proposed_rent), reviewed_by = $4, reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'`
+
+	result, err := db.DB.Exec(query, id, status, proposedRent, reviewerID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ApplyApprovedRentIncreases creates a pending one-year renewal lease at the proposed rent for
+// every approved proposal and marks the proposals as applied. Proposals offered to the tenant are
+// left until the tenant accepts the offer. It returns the number applied.
+func ApplyApprovedRentIncreases() (int, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT rp.id, rp.proposed_rent, l.unit_id, l.tenant_id, l.end_date
+		FROM rent_increase_proposals rp
+		JOIN leases l ON rp.lease_id = l.id
+		WHERE rp.status = 'approved'
+		  AND NOT EXISTS (
+			  SELECT 1 FROM renewal_offers ro
+			  WHERE ro.proposal_id = rp.id AND ro.status = 'sent'
+		  )`)
+	if err != nil {
+		return 0, err
+	}
+
+	type renewal struct {
+		proposalID int
+		rent       float64
+		unitID     int
+		tenantID   int
+		endDate    time.Time
+	}
+	var renewals []renewal
+	for rows.Next() {
+		var r renewal
+		if err := rows.Scan(&r.proposalID, &r.rent, &r.unitID, &r.tenantID, &r.endDate); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		renewals = append(renewals, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range renewals {
+		start := r.endDate.AddDate(0, 0, 1)
+		end := start.AddDate(1, 0, -1)
+
+		var leaseID int
+		err := tx.QueryRow(`
+			INSERT INTO leases (unit_id, tenant_id, start_date, end_date, monthly_rent, status)
+			VALUES ($1, $2, $3, $4, $5, 'pending')
+			RETURNING id`, r.unitID, r.tenantID, start, end, r.rent).Scan(&leaseID)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE rent_increase_proposals SET status = 'applied', renewal_lease_id = $2
+			WHERE id = $1`, r.proposalID, leaseID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(renewals), nil
+}
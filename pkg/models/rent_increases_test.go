@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProposeRent(t *testing.T) {
+	market := sql.NullFloat64{Float64: 1500, Valid: true}
+
+	// New tenant without caps moves straight to market
+	rent, _ := ProposeRent(1400, market, 6, nil)
+	assert.Equal(t, 1500.0, rent)
+
+	// Tenure softens the increase
+	rent, _ = ProposeRent(1400, market, 18, nil)
+	assert.Equal(t, 1475.0, rent)
+	rent, _ = ProposeRent(1400, market, 48, nil)
+	assert.Equal(t, 1450.0, rent)
+
+	// Already at market or no market rent set
+	rent, _ = ProposeRent(1600, market, 6, nil)
+	assert.Equal(t, 1600.0, rent)
+	rent, reason := ProposeRent(1400, sql.NullFloat64{}, 6, nil)
+	assert.Equal(t, 1400.0, rent)
+	assert.Equal(t, "No market rent set for unit", reason)
+}
+
+func TestProposeRentCaps(t *testing.T) {
+	market := sql.NullFloat64{Float64: 2000, Valid: true}
+	rule := &RentIncreaseRule{
+		Jurisdiction:      sql.NullString{String: "Oregon SB 608", Valid: true},
+		MaxPercentPerYear: sql.NullFloat64{Float64: 7, Valid: true},
+	}
+
+	rent, reason := ProposeRent(1000, market, 6, rule)
+	assert.Equal(t, 1070.0, rent)
+	assert.Equal(t, "Capped at 7.00% per year (Oregon SB 608)", reason)
+
+	rule.MaxAmount = sql.NullFloat64{Float64: 50, Valid: true}
+	rent, _ = ProposeRent(1000, market, 6, rule)
+	assert.Equal(t, 1050.0, rent)
+}
+
+func TestRuleForProperty(t *testing.T) {
+	rules := []RentIncreaseRule{
+		{ID: 1},
+		{ID: 2, PropertyID: sql.NullInt32{Int32: 5, Valid: true}},
+	}
+
+	assert.Equal(t, 2, ruleForProperty(rules, 5).ID)
+	assert.Equal(t, 1, ruleForProperty(rules, 9).ID)
+	assert.Nil(t, ruleForProperty(nil, 9))
+}
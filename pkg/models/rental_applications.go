@@ -0,0 +1,368 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Rental application and screening request statuses
+const (
+	ApplicationPending   = "pending"
+	ApplicationApproved  = "approved"
+	ApplicationRejected  = "rejected"
+	ApplicationWithdrawn = "withdrawn"
+
+	ScreeningPending   = "pending"
+	ScreeningCompleted = "completed"
+	ScreeningFailed    = "failed"
+)
+
+// Rental application and screening errors
+var (
+	ErrInvalidApplication         = errors.New("an application needs the applicant's name and email")
+	ErrApplicationClosed          = errors.New("the application has already been decided or withdrawn")
+	ErrConsentRequired            = errors.New("the applicant has not consented to screening")
+	ErrScreeningInProgress        = errors.New("a screening is already in progress for this application")
+	ErrScreeningRequired          = errors.New("approval requires a completed screening that was not declined")
+	ErrConditionsRequired         = errors.New("a conditional screening decision needs the conditions recorded as the reason")
+	ErrInvalidApplicationDecision = errors.New("the decision must be approved, rejected or withdrawn")
+)
+
+// RentalApplication is a prospective tenant's application for a property or unit
+type RentalApplication struct {
+	ID                int                `json:"id"`
+	PropertyID        int                `json:"property_id"`
+	PropertyName      string             `json:"property_name"`
+	UnitID            sql.NullInt32      `json:"unit_id,omitempty"`
+	FirstName         string             `json:"first_name"`
+	LastName          string             `json:"last_name"`
+	Email             string             `json:"email"`
+	PhoneNumber       sql.NullString     `json:"phone_number,omitempty"`
+	MonthlyIncome     sql.NullFloat64    `json:"monthly_income,omitempty"`
+	DesiredMoveIn     sql.NullTime       `json:"desired_move_in,omitempty"`
+	Status            string             `json:"status"`
+	ConsentText       sql.NullString     `json:"consent_text,omitempty"`
+	ConsentGivenAt    sql.NullTime       `json:"consent_given_at,omitempty"`
+	ConsentIP         sql.NullString     `json:"consent_ip,omitempty"`
+	ConsentUserAgent  sql.NullString     `json:"consent_user_agent,omitempty"`
+	ConsentRecordedBy sql.NullInt32      `json:"consent_recorded_by,omitempty"`
+	DecisionReason    sql.NullString     `json:"decision_reason,omitempty"`
+	DecidedBy         sql.NullInt32      `json:"decided_by,omitempty"`
+	DecidedAt         sql.NullTime       `json:"decided_at,omitempty"`
+	CreatedBy         sql.NullInt32      `json:"created_by,omitempty"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+	Screenings        []ScreeningRequest `json:"screenings,omitempty"`
+}
+
+// ScreeningRequest is a credit and background check sent to a screening provider. Only the
+// provider's summarized decision is kept.
+type ScreeningRequest struct {
+	ID            int            `json:"id"`
+	ApplicationID int            `json:"application_id"`
+	Provider      string         `json:"provider"`
+	ExternalID    string         `json:"external_id"`
+	Status        string         `json:"status"`
+	Decision      sql.NullString `json:"decision,omitempty"`
+	CreditScore   sql.NullInt32  `json:"credit_score,omitempty"`
+	Summary       sql.NullString `json:"summary,omitempty"`
+	Error         sql.NullString `json:"error,omitempty"`
+	RequestedBy   sql.NullInt32  `json:"requested_by,omitempty"`
+	RequestedAt   time.Time      `json:"requested_at"`
+	CompletedAt   sql.NullTime   `json:"completed_at,omitempty"`
+}
+
+const rentalApplicationColumns = `a.id, a.property_id, p.name, a.unit_id, a.first_name, a.last_name, a.email,
+	a.phone_number, a.monthly_income, a.desired_move_in, a.status, a.consent_text, a.consent_given_at,
+	a.consent_ip, a.consent_user_agent, a.consent_recorded_by, a.decision_reason, a.decided_by, a.decided_at,
+	a.created_by, a.created_at, a.updated_at`
+
+func scanRentalApplication(row interface{ Scan(...interface{}) error }) (RentalApplication, error) {
+	var a RentalApplication
+	err := row.Scan(&a.ID, &a.PropertyID, &a.PropertyName, &a.UnitID, &a.FirstName, &a.LastName, &a.Email,
+		&a.PhoneNumber, &a.MonthlyIncome, &a.DesiredMoveIn, &a.Status, &a.ConsentText, &a.ConsentGivenAt,
+		&a.ConsentIP, &a.ConsentUserAgent, &a.ConsentRecordedBy, &a.DecisionReason, &a.DecidedBy, &a.DecidedAt,
+		&a.CreatedBy, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// CreateRentalApplication saves a new pending application. It returns sql.ErrNoRows if the
+// property is missing or the unit is not in it.
+func CreateRentalApplication(a *RentalApplication) error {
+	a.FirstName = strings.TrimSpace(a.FirstName)
+	a.LastName = strings.TrimSpace(a.LastName)
+	a.Email = strings.TrimSpace(a.Email)
+	if a.FirstName == "" || a.LastName == "" || !strings.Contains(a.Email, "@") {
+		return ErrInvalidApplication
+	}
+
+	var exists int
+	if err := db.DB.QueryRow("SELECT 1 FROM properties WHERE id = $1", a.PropertyID).Scan(&exists); err != nil {
+		return err
+	}
+	if a.UnitID.Valid {
+		err := db.DB.QueryRow("SELECT 1 FROM property_units WHERE id = $1 AND property_id = $2",
+			a.UnitID.Int32, a.PropertyID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.DB.QueryRow(`
+		INSERT INTO rental_applications (property_id, unit_id, first_name, last_name, email, phone_number,
+			monthly_income, desired_move_in, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, status, created_at, updated_at`,
+		a.PropertyID, a.UnitID, a.FirstName, a.LastName, a.Email, a.PhoneNumber,
+		a.MonthlyIncome, a.DesiredMoveIn, a.CreatedBy).
+		Scan(&a.ID, &a.Status, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// GetRentalApplications returns applications, newest first, optionally filtered by status
+// (empty for all)
+func GetRentalApplications(status string) ([]RentalApplication, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+rentalApplicationColumns+`
+		FROM rental_applications a
+		JOIN properties p ON p.id = a.property_id
+		WHERE ($1 = '' OR a.status = $1)
+		ORDER BY a.created_at DESC, a.id DESC`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applications []RentalApplication
+	for rows.Next() {
+		a, err := scanRentalApplication(rows)
+		if err != nil {
+			return nil, err
+		}
+		applications = append(applications, a)
+	}
+	return applications, rows.Err()
+}
+
+// GetRentalApplication retrieves an application with its screenings, newest first
+func GetRentalApplication(id int) (*RentalApplication, error) {
+	a, err := scanRentalApplication(db.DB.QueryRow(`
+		SELECT `+rentalApplicationColumns+`
+		FROM rental_applications a
+		JOIN properties p ON p.id = a.property_id
+		WHERE a.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query("SELECT "+screeningRequestColumns+" FROM screening_requests WHERE application_id = $1 ORDER BY requested_at DESC, id DESC", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	a.Screenings = []ScreeningRequest{}
+	for rows.Next() {
+		s, err := scanScreeningRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		a.Screenings = append(a.Screenings, s)
+	}
+	return &a, rows.Err()
+}
+
+// applicationStatusError explains why an update matched no pending application
+func applicationStatusError(id int) error {
+	var status string
+	if err := db.DB.QueryRow("SELECT status FROM rental_applications WHERE id = $1", id).Scan(&status); err != nil {
+		return err
+	}
+	return ErrApplicationClosed
+}
+
+// RecordScreeningConsent records the applicant's consent to a credit and background check: the
+// disclosure they agreed to and where the consent came from
+func RecordScreeningConsent(id int, consentText, ip, userAgent string, recordedBy sql.NullInt32) error {
+	result, err := db.DB.Exec(`
+		UPDATE rental_applications
+		SET consent_text = $2, consent_given_at = NOW(), consent_ip = $3, consent_user_agent = $4,
+			consent_recorded_by = $5, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'`,
+		id, consentText, NullString(ip), NullString(userAgent), recordedBy)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != sql.ErrNoRows {
+		return err
+	}
+	return applicationStatusError(id)
+}
+
+// GetScreenableApplication returns a pending application that may be sent for screening: the
+// applicant has consented and no screening is already in progress
+func GetScreenableApplication(id int) (*RentalApplication, error) {
+	a, err := GetRentalApplication(id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != ApplicationPending {
+		return nil, ErrApplicationClosed
+	}
+	if !a.ConsentGivenAt.Valid {
+		return nil, ErrConsentRequired
+	}
+	for _, s := range a.Screenings {
+		if s.Status == ScreeningPending {
+			return nil, ErrScreeningInProgress
+		}
+	}
+	return a, nil
+}
+
+const screeningRequestColumns = `id, application_id, provider, external_id, status, decision, credit_score,
+	summary, error, requested_by, requested_at, completed_at`
+
+func scanScreeningRequest(row interface{ Scan(...interface{}) error }) (ScreeningRequest, error) {
+	var s ScreeningRequest
+	err := row.Scan(&s.ID, &s.ApplicationID, &s.Provider, &s.ExternalID, &s.Status, &s.Decision, &s.CreditScore,
+		&s.Summary, &s.Error, &s.RequestedBy, &s.RequestedAt, &s.CompletedAt)
+	return s, err
+}
+
+// CreateScreeningRequest records a screening the provider has accepted
+func CreateScreeningRequest(s *ScreeningRequest) error {
+	return db.DB.QueryRow(`
+		INSERT INTO screening_requests (application_id, provider, external_id, requested_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, requested_at`,
+		s.ApplicationID, s.Provider, s.ExternalID, s.RequestedBy).
+		Scan(&s.ID, &s.Status, &s.RequestedAt)
+}
+
+// GetScreeningRequest retrieves a screening by ID
+func GetScreeningRequest(id int) (*ScreeningRequest, error) {
+	s, err := scanScreeningRequest(db.DB.QueryRow("SELECT "+screeningRequestColumns+" FROM screening_requests WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetScreeningRequestByExternalID finds a screening by the provider's ID for it
+func GetScreeningRequestByExternalID(provider, externalID string) (*ScreeningRequest, error) {
+	s, err := scanScreeningRequest(db.DB.QueryRow(
+		"SELECT "+screeningRequestColumns+" FROM screening_requests WHERE provider = $1 AND external_id = $2",
+		provider, externalID))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetPendingScreeningRequests returns a provider's screenings that are awaiting a result
+func GetPendingScreeningRequests(provider string) ([]ScreeningRequest, error) {
+	rows, err := db.DB.Query("SELECT "+screeningRequestColumns+" FROM screening_requests WHERE provider = $1 AND status = 'pending' ORDER BY requested_at", provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []ScreeningRequest
+	for rows.Next() {
+		s, err := scanScreeningRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, s)
+	}
+	return requests, rows.Err()
+}
+
+// CompleteScreeningRequest stores the provider's summarized decision. Screenings that are no
+// longer pending are left unchanged and sql.ErrNoRows is returned.
+func CompleteScreeningRequest(id int, decision string, creditScore int, summary string) error {
+	score := sql.NullInt32{Int32: int32(creditScore), Valid: creditScore > 0}
+	result, err := db.DB.Exec(`
+		UPDATE screening_requests
+		SET status = 'completed', decision = $2, credit_score = $3, summary = $4, completed_at = NOW()
+		WHERE id = $1 AND status = 'pending'`,
+		id, decision, score, NullString(summary))
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// FailScreeningRequest records that a screening could not be completed
+func FailScreeningRequest(id int, message string) error {
+	result, err := db.DB.Exec(`
+		UPDATE screening_requests
+		SET status = 'failed', error = $2, completed_at = NOW()
+		WHERE id = $1 AND status = 'pending'`, id, message)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DecideRentalApplication approves, rejects or withdraws a pending application. Approval is
+// gated on the most recent screening: it must be completed and not declined, and a conditional
+// decision needs the conditions recorded as the reason.
+func DecideRentalApplication(id int, status, reason string, decidedBy sql.NullInt32) error {
+	if status != ApplicationApproved && status != ApplicationRejected && status != ApplicationWithdrawn {
+		return ErrInvalidApplicationDecision
+	}
+	reason = strings.TrimSpace(reason)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	if err := tx.QueryRow("SELECT status FROM rental_applications WHERE id = $1", id).Scan(&current); err != nil {
+		return err
+	}
+	if current != ApplicationPending {
+		return ErrApplicationClosed
+	}
+
+	if status == ApplicationApproved {
+		var screeningStatus string
+		var decision sql.NullString
+		err := tx.QueryRow(`
+			SELECT status, decision FROM screening_requests
+			WHERE application_id = $1
+			ORDER BY requested_at DESC, id DESC LIMIT 1`, id).Scan(&screeningStatus, &decision)
+		if err == sql.ErrNoRows {
+			return ErrScreeningRequired
+		}
+		if err != nil {
+			return err
+		}
+		if screeningStatus != ScreeningCompleted || decision.String == "decline" {
+			return ErrScreeningRequired
+		}
+		if decision.String == "conditional" && reason == "" {
+			return ErrConditionsRequired
+		}
+	}
+
+	result, err := tx.Exec(`
+		UPDATE rental_applications
+		SET status = $2, decision_reason = $3, decided_by = $4, decided_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'`,
+		id, status, NullString(reason), decidedBy)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
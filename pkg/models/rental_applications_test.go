@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecideRentalApplicationRequiresScreening(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM rental_applications`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("pending"))
+	mock.ExpectQuery(`FROM screening_requests`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "decision"}).AddRow("completed", "decline"))
+	mock.ExpectRollback()
+
+	assert.Equal(t, ErrScreeningRequired, DecideRentalApplication(3, ApplicationApproved, "", sql.NullInt32{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDecideRentalApplicationConditionalNeedsReason(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM rental_applications`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("pending"))
+	mock.ExpectQuery(`FROM screening_requests`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "decision"}).AddRow("completed", "conditional"))
+	mock.ExpectRollback()
+	assert.Equal(t, ErrConditionsRequired, DecideRentalApplication(3, ApplicationApproved, " ", sql.NullInt32{}))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM rental_applications`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("pending"))
+	mock.ExpectQuery(`FROM screening_requests`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "decision"}).AddRow("completed", "conditional"))
+	mock.ExpectExec(`UPDATE rental_applications`).
+		WithArgs(3, ApplicationApproved, sql.NullString{String: "Guarantor required", Valid: true}, sql.NullInt32{}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, DecideRentalApplication(3, ApplicationApproved, "Guarantor required", sql.NullInt32{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetScreenableApplicationRequiresConsent(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	columns := []string{"id", "property_id", "name", "unit_id", "first_name", "last_name", "email",
+		"phone_number", "monthly_income", "desired_move_in", "status", "consent_text", "consent_given_at",
+		"consent_ip", "consent_user_agent", "consent_recorded_by", "decision_reason", "decided_by", "decided_at",
+		"created_by", "created_at", "updated_at"}
+	mock.ExpectQuery(`FROM rental_applications a`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 1, "Maple Court", nil, "Ann", "Lee", "ann@example.com",
+			nil, nil, nil, "pending", nil, nil, nil, nil, nil, nil, nil, nil, nil, now, now))
+	mock.ExpectQuery(`FROM screening_requests WHERE application_id = \$1`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := GetScreenableApplication(3)
+	assert.Equal(t, ErrConsentRequired, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
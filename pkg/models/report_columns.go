@@ -0,0 +1,122 @@
+package models
+
+import (
+	"time"
+)
+
+// Report column types
+const (
+	ColumnString   = "string"
+	ColumnNumber   = "number"
+	ColumnCurrency = "currency"
+	ColumnDate     = "date"
+	ColumnPercent  = "percent"
+)
+
+// ColumnFormatMonth marks a date column whose values are months ("2006-01") rather than days
+const ColumnFormatMonth = "month"
+
+// DefaultReportCurrency is the currency of currency columns
+const DefaultReportCurrency = "USD"
+
+// ReportColumn declares the type of a report column and how to format its values. Date columns
+// hold "2006-01-02" strings, or "2006-01" with the month format. Percent columns hold
+// percentages (85.5 for 85.5%), not fractions. A redacted column keeps its type but holds
+// RedactedValue, so exporters fall back to the raw value when it is not of the declared type.
+type ReportColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Decimals int    `json:"decimals"`           // Digits after the decimal point of number, currency and percent values
+	Currency string `json:"currency,omitempty"` // ISO 4217 code of currency columns
+	Format   string `json:"format,omitempty"`   // "month" for date columns holding months
+}
+
+// Column declarations used by the report generators, named by typedColumns
+var (
+	stringColumn   = ReportColumn{Type: ColumnString}
+	numberColumn   = ReportColumn{Type: ColumnNumber}
+	currencyColumn = ReportColumn{Type: ColumnCurrency, Decimals: 2, Currency: DefaultReportCurrency}
+	percentColumn  = ReportColumn{Type: ColumnPercent, Decimals: 2}
+	dateColumn     = ReportColumn{Type: ColumnDate}
+	monthColumn    = ReportColumn{Type: ColumnDate, Format: ColumnFormatMonth}
+)
+
+// WithDecimals returns the column with a different number of decimal places
+func (c ReportColumn) WithDecimals(decimals int) ReportColumn {
+	c.Decimals = decimals
+	return c
+}
+
+// IsNumeric reports whether the column holds numbers
+func (c ReportColumn) IsNumeric() bool {
+	return c.Type == ColumnNumber || c.Type == ColumnCurrency || c.Type == ColumnPercent
+}
+
+// typedColumns declares a report's columns in header order. Headers missing from types are
+// string columns.
+func typedColumns(headers []string, types map[string]ReportColumn) []ReportColumn {
+	columns := make([]ReportColumn, len(headers))
+	for i, header := range headers {
+		column, ok := types[header]
+		if !ok {
+			column = stringColumn
+		}
+		column.Name = header
+		columns[i] = column
+	}
+	return columns
+}
+
+// Column returns the declared column for a header
+func (d *ReportData) Column(name string) (ReportColumn, bool) {
+	for _, column := range d.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return ReportColumn{}, false
+}
+
+// completeReportColumns declares any header the generator left undeclared, inferring its type
+// from the first non-null value, and drops declarations for columns not in the headers
+func completeReportColumns(data *ReportData) {
+	declared := map[string]ReportColumn{}
+	for _, column := range data.Columns {
+		declared[column.Name] = column
+	}
+
+	columns := make([]ReportColumn, len(data.Headers))
+	for i, header := range data.Headers {
+		column, ok := declared[header]
+		if !ok {
+			column = inferColumn(data.Rows, header)
+			column.Name = header
+		}
+		columns[i] = column
+	}
+	data.Columns = columns
+}
+
+// inferColumn guesses a column's type from its first non-null value
+func inferColumn(rows []map[string]interface{}, header string) ReportColumn {
+	for _, row := range rows {
+		switch v := row[header].(type) {
+		case nil:
+			continue
+		case int, int32, int64:
+			return numberColumn
+		case float32, float64:
+			return numberColumn.WithDecimals(2)
+		case time.Time:
+			return dateColumn
+		case string:
+			if _, err := time.Parse("2006-01-02", v); err == nil {
+				return dateColumn
+			}
+			return stringColumn
+		default:
+			return stringColumn
+		}
+	}
+	return stringColumn
+}
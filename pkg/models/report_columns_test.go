@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedColumns(t *testing.T) {
+	columns := typedColumns([]string{"Property", "Rent", "Occupancy %"},
+		map[string]ReportColumn{"Rent": currencyColumn, "Occupancy %": percentColumn, "Missing": dateColumn})
+
+	assert.Equal(t, []ReportColumn{
+		{Name: "Property", Type: ColumnString},
+		{Name: "Rent", Type: ColumnCurrency, Decimals: 2, Currency: "USD"},
+		{Name: "Occupancy %", Type: ColumnPercent, Decimals: 2},
+	}, columns)
+}
+
+func TestCompleteReportColumns(t *testing.T) {
+	data := &ReportData{
+		Headers: []string{"Name", "Units", "Balance", "Since", "Updated", "Note"},
+		Rows: []map[string]interface{}{
+			{"Name": "Maple", "Units": nil, "Balance": 10.5, "Since": "2024-03-07", "Updated": time.Now(), "Note": nil},
+			{"Name": "Oak", "Units": 4, "Balance": 0.0, "Since": "2024-05-01"},
+		},
+		Columns: []ReportColumn{
+			{Name: "Balance", Type: ColumnCurrency, Decimals: 2, Currency: "USD"},
+			{Name: "Dropped", Type: ColumnNumber},
+		},
+	}
+
+	completeReportColumns(data)
+	require.Len(t, data.Columns, 6)
+	assert.Equal(t, ReportColumn{Name: "Name", Type: ColumnString}, data.Columns[0])
+	assert.Equal(t, ReportColumn{Name: "Units", Type: ColumnNumber}, data.Columns[1], "nulls are skipped when inferring")
+	assert.Equal(t, ColumnCurrency, data.Columns[2].Type, "declared columns are kept")
+	assert.Equal(t, ColumnDate, data.Columns[3].Type)
+	assert.Equal(t, ColumnDate, data.Columns[4].Type)
+	assert.Equal(t, ColumnString, data.Columns[5].Type, "columns with no values are strings")
+
+	column, ok := data.Column("Balance")
+	assert.True(t, ok)
+	assert.True(t, column.IsNumeric())
+	_, ok = data.Column("Dropped")
+	assert.False(t, ok, "declarations for columns not in the headers are dropped")
+}
+
+func TestComputedColumnTypes(t *testing.T) {
+	data := &ReportData{
+		Headers: []string{"Rent", "Units"},
+		Rows:    []map[string]interface{}{{"Rent": 1000.0, "Units": 4}},
+	}
+	criteria := map[string]interface{}{"computed_columns": []interface{}{
+		"Per Unit = Rent / Units",
+		map[string]interface{}{"name": "Rent / Unit", "expression": "Rent / Units", "type": "currency", "decimals": 0.0},
+	}}
+
+	require.NoError(t, applyComputedColumns(data, criteria))
+	per, _ := data.Column("Per Unit")
+	assert.Equal(t, ReportColumn{Name: "Per Unit", Type: ColumnNumber, Decimals: 2}, per)
+	rent, _ := data.Column("Rent / Unit")
+	assert.Equal(t, ReportColumn{Name: "Rent / Unit", Type: ColumnCurrency, Decimals: 0, Currency: "USD"}, rent)
+
+	err := applyComputedColumns(&ReportData{Headers: []string{"Rent"}}, map[string]interface{}{"computed_columns": []interface{}{
+		map[string]interface{}{"name": "X", "expression": "Rent", "type": "date"},
+	}})
+	assert.ErrorContains(t, err, "type must be number, currency or percent")
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReportWatermark(t *testing.T) {
+	report := &CustomReport{ID: 4, Confidentiality: ConfidentialityRestricted}
+	user := &User{ID: 9, Username: "jdoe", Email: "jane@example.com"}
+	at := time.Date(2026, 10, 16, 3, 40, 0, 0, time.FixedZone("CDT", -5*3600))
+
+	w := NewReportWatermark(report, user, at)
+	require.NotNil(t, w)
+	assert.Equal(t, "Restricted - Exported by jdoe <jane@example.com> - 2026-10-16 08:40 UTC", w.Text())
+
+	report.Confidentiality = ConfidentialityNone
+	assert.Nil(t, NewReportWatermark(report, user, at))
+	report.Confidentiality = ""
+	assert.Nil(t, NewReportWatermark(report, user, at))
+
+	assert.NoError(t, ValidateConfidentiality(ConfidentialityInternal))
+	assert.Equal(t, ErrInvalidConfidentiality, ValidateConfidentiality("secret"))
+}
+
+func TestRecordReportExport(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	report := &CustomReport{ID: 4, Confidentiality: ConfidentialityConfidential}
+	user := &User{ID: 9}
+
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sqlmock.AnyArg(), AuditReportExported, "report", 4, nil,
+			[]byte(`{"bytes":2048,"channel":"download","confidentiality":"confidential","format":"pdf","watermarked":true}`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+
+	require.NoError(t, RecordReportExport(report, user, "pdf", "download", 2048))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// ReportDiff describes the differences between two executions of the same report
+type ReportDiff struct {
+	BaseExecutionID   int                      `json:"base_execution_id"`
+	TargetExecutionID int                      `json:"target_execution_id"`
+	KeyColumn         string                   `json:"key_column"`
+	Added             []map[string]interface{} `json:"added"`
+	Removed           []map[string]interface{} `json:"removed"`
+	Changed           []RowChange              `json:"changed"`
+	UnchangedCount    int                      `json:"unchanged_count"`
+	SummaryDeltas     map[string]ValueDelta    `json:"summary_deltas"`
+}
+
+// RowChange describes the changed columns of a row present in both executions
+type RowChange struct {
+	Key     interface{}           `json:"key"`
+	Changes map[string]ValueDelta `json:"changes"`
+}
+
+// ValueDelta is a before/after pair. Delta is set when both values are numeric.
+type ValueDelta struct {
+	Base   interface{} `json:"base"`
+	Target interface{} `json:"target"`
+	Delta  *float64    `json:"delta,omitempty"`
+}
+
+// DiffReportData compares two report outputs. Rows are matched on keyColumn, which defaults
+// to the first header of the target report.
+func DiffReportData(base, target *ReportData, keyColumn string) *ReportDiff {
+	if keyColumn == "" && len(target.Headers) > 0 {
+		keyColumn = target.Headers[0]
+	}
+
+	diff := &ReportDiff{
+		KeyColumn:     keyColumn,
+		Added:         []map[string]interface{}{},
+		Removed:       []map[string]interface{}{},
+		Changed:       []RowChange{},
+		SummaryDeltas: map[string]ValueDelta{},
+	}
+
+	baseRows := indexRows(base.Rows, keyColumn)
+	targetRows := indexRows(target.Rows, keyColumn)
+
+	for _, key := range sortedKeys(targetRows) {
+		targetRow := targetRows[key]
+		baseRow, ok := baseRows[key]
+		if !ok {
+			diff.Added = append(diff.Added, targetRow)
+			continue
+		}
+
+		changes := diffValues(baseRow, targetRow)
+		if len(changes) == 0 {
+			diff.UnchangedCount++
+			continue
+		}
+		diff.Changed = append(diff.Changed, RowChange{Key: targetRow[keyColumn], Changes: changes})
+	}
+
+	for _, key := range sortedKeys(baseRows) {
+		if _, ok := targetRows[key]; !ok {
+			diff.Removed = append(diff.Removed, baseRows[key])
+		}
+	}
+
+	diff.SummaryDeltas = diffValues(base.Summary, target.Summary)
+	return diff
+}
+
+// indexRows maps rows by the string form of their key column
+func indexRows(rows []map[string]interface{}, keyColumn string) map[string]map[string]interface{} {
+	index := make(map[string]map[string]interface{}, len(rows))
+	for _, row := range rows {
+		index[fmt.Sprint(row[keyColumn])] = row
+	}
+	return index
+}
+
+// sortedKeys returns map keys in a stable order so diffs are deterministic
+func sortedKeys(rows map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffValues returns the entries whose values differ between base and target
+func diffValues(base, target map[string]interface{}) map[string]ValueDelta {
+	changes := map[string]ValueDelta{}
+
+	columns := map[string]bool{}
+	for column := range base {
+		columns[column] = true
+	}
+	for column := range target {
+		columns[column] = true
+	}
+
+	for column := range columns {
+		baseValue, targetValue := base[column], target[column]
+
+		baseNum, baseIsNum := toFloat(baseValue)
+		targetNum, targetIsNum := toFloat(targetValue)
+		if baseIsNum && targetIsNum {
+			if baseNum == targetNum {
+				continue
+			}
+			delta := math.Round((targetNum-baseNum)*100) / 100
+			changes[column] = ValueDelta{Base: baseValue, Target: targetValue, Delta: &delta}
+			continue
+		}
+
+		if !reflect.DeepEqual(baseValue, targetValue) {
+			changes[column] = ValueDelta{Base: baseValue, Target: targetValue}
+		}
+	}
+
+	return changes
+}
+
+// toFloat converts JSON-decoded and native numeric values to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffReportData(t *testing.T) {
+	base := &ReportData{
+		Headers: []string{"Month", "Total Amount"},
+		Rows: []map[string]interface{}{
+			{"Month": "2024-01", "Total Amount": 1000.0},
+			{"Month": "2024-02", "Total Amount": 1200.0},
+			{"Month": "2024-03", "Total Amount": 900.0},
+		},
+		Summary: map[string]interface{}{"total_amount": 3100.0, "reporting_period": "3 months"},
+	}
+	target := &ReportData{
+		Headers: []string{"Month", "Total Amount"},
+		Rows: []map[string]interface{}{
+			{"Month": "2024-02", "Total Amount": 1200.0},
+			{"Month": "2024-03", "Total Amount": 950.0},
+			{"Month": "2024-04", "Total Amount": 1100.0},
+		},
+		Summary: map[string]interface{}{"total_amount": 3250.0, "reporting_period": "3 months"},
+	}
+
+	diff := DiffReportData(base, target, "")
+
+	assert.Equal(t, "Month", diff.KeyColumn)
+	assert.Equal(t, 1, diff.UnchangedCount)
+
+	assert.Len(t, diff.Added, 1)
+	assert.Equal(t, "2024-04", diff.Added[0]["Month"])
+
+	assert.Len(t, diff.Removed, 1)
+	assert.Equal(t, "2024-01", diff.Removed[0]["Month"])
+
+	assert.Len(t, diff.Changed, 1)
+	assert.Equal(t, "2024-03", diff.Changed[0].Key)
+	assert.Equal(t, 50.0, *diff.Changed[0].Changes["Total Amount"].Delta)
+
+	assert.Len(t, diff.SummaryDeltas, 1)
+	assert.Equal(t, 150.0, *diff.SummaryDeltas["total_amount"].Delta)
+}
+
+func TestDiffReportDataNonNumericChange(t *testing.T) {
+	base := &ReportData{Rows: []map[string]interface{}{{"Name": "Oak", "Status": "active"}}}
+	target := &ReportData{Rows: []map[string]interface{}{{"Name": "Oak", "Status": "archived"}}}
+
+	diff := DiffReportData(base, target, "Name")
+
+	assert.Len(t, diff.Changed, 1)
+	change := diff.Changed[0].Changes["Status"]
+	assert.Equal(t, "active", change.Base)
+	assert.Equal(t, "archived", change.Target)
+	assert.Nil(t, change.Delta)
+}
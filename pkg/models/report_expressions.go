@@ -0,0 +1,540 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Limits on computed columns, so a report definition cannot make execution arbitrarily expensive
+const (
+	maxComputedColumns      = 20
+	maxExpressionLength     = 500
+	defaultComputedDecimals = 2
+)
+
+// ComputedColumn is a report column calculated from the other columns of each row, declared in
+// a report's criteria under "computed_columns". Expressions support numbers, column references
+// (bare names such as Units, or [Avg Rent] for names with spaces or symbols), + - * / %,
+// parentheses and the functions round, abs, min, max and coalesce. A row whose referenced values
+// are missing or not numeric, or that divides by zero, gets a null value.
+type ComputedColumn struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Summary    string `json:"summary,omitempty"`  // 'avg' (default), 'sum', 'min', 'max' or 'none'
+	Decimals   *int   `json:"decimals,omitempty"` // Digits to round to, default 2
+	Type       string `json:"type,omitempty"`     // Column type: 'number' (default), 'currency' or 'percent'
+}
+
+// parseComputedColumns reads and compiles the computed columns in a report's criteria. Each entry
+// is either an object with name and expression or a "Name = expression" string.
+func parseComputedColumns(criteria map[string]interface{}) ([]ComputedColumn, []expression, error) {
+	raw, ok := criteria["computed_columns"]
+	if !ok || raw == nil {
+		return nil, nil, nil
+	}
+	entries, ok := raw.([]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("computed_columns must be a list")
+	}
+	if len(entries) > maxComputedColumns {
+		return nil, nil, fmt.Errorf("a report may have at most %d computed columns", maxComputedColumns)
+	}
+
+	columns := make([]ComputedColumn, 0, len(entries))
+	compiled := make([]expression, 0, len(entries))
+	seen := map[string]bool{}
+	for i, entry := range entries {
+		var column ComputedColumn
+		switch v := entry.(type) {
+		case string:
+			eq := strings.Index(v, "=")
+			if eq < 0 {
+				return nil, nil, fmt.Errorf("computed_columns[%d]: expected \"Name = expression\"", i)
+			}
+			column.Name, column.Expression = v[:eq], v[eq+1:]
+		case map[string]interface{}:
+			column.Name, _ = v["name"].(string)
+			column.Expression, _ = v["expression"].(string)
+			column.Summary, _ = v["summary"].(string)
+			column.Type, _ = v["type"].(string)
+			if d, ok := v["decimals"].(float64); ok {
+				decimals := int(d)
+				column.Decimals = &decimals
+			}
+		default:
+			return nil, nil, fmt.Errorf("computed_columns[%d]: expected an object or a string", i)
+		}
+
+		column.Name = strings.TrimSpace(column.Name)
+		column.Expression = strings.TrimSpace(column.Expression)
+		if column.Name == "" || column.Expression == "" {
+			return nil, nil, fmt.Errorf("computed_columns[%d]: name and expression are required", i)
+		}
+		if seen[column.Name] {
+			return nil, nil, fmt.Errorf("computed column %q is defined twice", column.Name)
+		}
+		seen[column.Name] = true
+		switch column.Summary {
+		case "":
+			column.Summary = "avg"
+		case "avg", "sum", "min", "max", "none":
+		default:
+			return nil, nil, fmt.Errorf("computed column %q: summary must be avg, sum, min, max or none", column.Name)
+		}
+		switch column.Type {
+		case "":
+			column.Type = ColumnNumber
+		case ColumnNumber, ColumnCurrency, ColumnPercent:
+		default:
+			return nil, nil, fmt.Errorf("computed column %q: type must be number, currency or percent", column.Name)
+		}
+		if column.Decimals != nil && (*column.Decimals < 0 || *column.Decimals > 10) {
+			return nil, nil, fmt.Errorf("computed column %q: decimals must be between 0 and 10", column.Name)
+		}
+
+		expr, err := compileExpression(column.Expression)
+		if err != nil {
+			return nil, nil, fmt.Errorf("computed column %q: %v", column.Name, err)
+		}
+		columns = append(columns, column)
+		compiled = append(compiled, expr)
+	}
+	return columns, compiled, nil
+}
+
+// ValidateComputedColumns checks the syntax of a report's computed columns. References are only
+// checked against the report's columns when it runs.
+func ValidateComputedColumns(criteria map[string]interface{}) error {
+	_, _, err := parseComputedColumns(criteria)
+	return err
+}
+
+// applyComputedColumns adds a report's computed columns to each row, to the headers and to the
+// summary. ComputedColumns records the result columns each one is derived from, so redaction
+// masks computed values built from sensitive columns.
+func applyComputedColumns(data *ReportData, criteria map[string]interface{}) error {
+	columns, compiled, err := parseComputedColumns(criteria)
+	if err != nil || len(columns) == 0 {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, header := range data.Headers {
+		known[header] = true
+	}
+	sources := map[string][]string{}
+
+	for i, column := range columns {
+		if known[column.Name] {
+			return fmt.Errorf("computed column %q has the same name as a report column", column.Name)
+		}
+
+		// Resolve references to the underlying result columns, following earlier computed columns
+		base := map[string]bool{}
+		for _, ref := range compiled[i].references() {
+			if !known[ref] {
+				return fmt.Errorf("computed column %q: unknown column %q", column.Name, ref)
+			}
+			if derived, ok := sources[ref]; ok {
+				for _, source := range derived {
+					base[source] = true
+				}
+			} else {
+				base[ref] = true
+			}
+		}
+		for source := range base {
+			sources[column.Name] = append(sources[column.Name], source)
+		}
+		sort.Strings(sources[column.Name])
+
+		decimals := defaultComputedDecimals
+		if column.Decimals != nil {
+			decimals = *column.Decimals
+		}
+		scale := math.Pow(10, float64(decimals))
+
+		var values []float64
+		for _, row := range data.Rows {
+			value, ok := compiled[i].eval(row)
+			if !ok || math.IsInf(value, 0) || math.IsNaN(value) {
+				row[column.Name] = nil
+				continue
+			}
+			value = math.Round(value*scale) / scale
+			row[column.Name] = value
+			values = append(values, value)
+		}
+
+		data.Headers = append(data.Headers, column.Name)
+		declared := ReportColumn{Name: column.Name, Type: column.Type, Decimals: decimals}
+		if column.Type == ColumnCurrency {
+			declared.Currency = DefaultReportCurrency
+		}
+		data.Columns = append(data.Columns, declared)
+		known[column.Name] = true
+		if summary, ok := summarizeValues(values, column.Summary); ok {
+			if data.Summary == nil {
+				data.Summary = map[string]interface{}{}
+			}
+			data.Summary[column.Name] = math.Round(summary*scale) / scale
+		}
+	}
+
+	data.ComputedColumns = sources
+	return nil
+}
+
+// summarizeValues aggregates a computed column's non-null values
+func summarizeValues(values []float64, kind string) (float64, bool) {
+	if len(values) == 0 || kind == "none" {
+		return 0, false
+	}
+	result := values[0]
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+		if kind == "min" && v < result || kind == "max" && v > result {
+			result = v
+		}
+	}
+	switch kind {
+	case "sum":
+		return sum, true
+	case "avg":
+		return sum / float64(len(values)), true
+	}
+	return result, true
+}
+
+// expression is a compiled computed column expression
+type expression interface {
+	// eval computes the expression for a row; ok is false when the result is null
+	eval(row map[string]interface{}) (value float64, ok bool)
+	// references lists the columns the expression reads
+	references() []string
+}
+
+type numberExpr float64
+
+func (n numberExpr) eval(map[string]interface{}) (float64, bool) { return float64(n), true }
+func (n numberExpr) references() []string                        { return nil }
+
+type columnExpr string
+
+func (c columnExpr) eval(row map[string]interface{}) (float64, bool) {
+	return numericValue(row[string(c)])
+}
+func (c columnExpr) references() []string { return []string{string(c)} }
+
+type negateExpr struct{ operand expression }
+
+func (n negateExpr) eval(row map[string]interface{}) (float64, bool) {
+	v, ok := n.operand.eval(row)
+	return -v, ok
+}
+func (n negateExpr) references() []string { return n.operand.references() }
+
+type binaryExpr struct {
+	op          byte
+	left, right expression
+}
+
+func (b binaryExpr) eval(row map[string]interface{}) (float64, bool) {
+	l, ok := b.left.eval(row)
+	if !ok {
+		return 0, false
+	}
+	r, ok := b.right.eval(row)
+	if !ok {
+		return 0, false
+	}
+	switch b.op {
+	case '+':
+		return l + r, true
+	case '-':
+		return l - r, true
+	case '*':
+		return l * r, true
+	case '/':
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	case '%':
+		if r == 0 {
+			return 0, false
+		}
+		return math.Mod(l, r), true
+	}
+	return 0, false
+}
+func (b binaryExpr) references() []string {
+	return append(b.left.references(), b.right.references()...)
+}
+
+type callExpr struct {
+	name string
+	args []expression
+}
+
+func (c callExpr) eval(row map[string]interface{}) (float64, bool) {
+	if c.name == "coalesce" {
+		for _, arg := range c.args {
+			if v, ok := arg.eval(row); ok {
+				return v, true
+			}
+		}
+		return 0, false
+	}
+
+	values := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		v, ok := arg.eval(row)
+		if !ok {
+			return 0, false
+		}
+		values[i] = v
+	}
+	switch c.name {
+	case "abs":
+		return math.Abs(values[0]), true
+	case "round":
+		scale := 1.0
+		if len(values) == 2 {
+			scale = math.Pow(10, math.Round(values[1]))
+		}
+		return math.Round(values[0]*scale) / scale, true
+	case "min", "max":
+		result := values[0]
+		for _, v := range values[1:] {
+			if c.name == "min" && v < result || c.name == "max" && v > result {
+				result = v
+			}
+		}
+		return result, true
+	}
+	return 0, false
+}
+func (c callExpr) references() []string {
+	var refs []string
+	for _, arg := range c.args {
+		refs = append(refs, arg.references()...)
+	}
+	return refs
+}
+
+// expressionFunctions maps each supported function to its minimum and maximum argument count
+var expressionFunctions = map[string][2]int{
+	"abs":      {1, 1},
+	"round":    {1, 2},
+	"min":      {1, 20},
+	"max":      {1, 20},
+	"coalesce": {1, 20},
+}
+
+// numericValue converts a report cell to a number
+func numericValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(n)), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compileExpression parses an expression with a recursive descent parser:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | primary
+//	primary = number | column | function "(" expr { "," expr } ")" | "(" expr ")"
+func compileExpression(source string) (expression, error) {
+	if len(source) > maxExpressionLength {
+		return nil, fmt.Errorf("expression must be at most %d characters", maxExpressionLength)
+	}
+	p := &expressionParser{src: source}
+	expr, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.src[p.pos], p.pos+1)
+	}
+	return expr, nil
+}
+
+// maxExpressionDepth bounds parenthesis and function nesting
+const maxExpressionDepth = 32
+
+type expressionParser struct {
+	src string
+	pos int
+}
+
+func (p *expressionParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *expressionParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *expressionParser) parseExpr(depth int) (expression, error) {
+	if depth > maxExpressionDepth {
+		return nil, fmt.Errorf("expression is nested too deeply")
+	}
+	left, err := p.parseTerm(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseTerm(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseTerm(depth int) (expression, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseUnary(depth int) (expression, error) {
+	if p.peek() == '-' {
+		p.pos++
+		if depth > maxExpressionDepth {
+			return nil, fmt.Errorf("expression is nested too deeply")
+		}
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return negateExpr{operand: operand}, nil
+	}
+	return p.parsePrimary(depth)
+}
+
+func (p *expressionParser) parsePrimary(depth int) (expression, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case c == '(':
+		p.pos++
+		expr, err := p.parseExpr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return expr, nil
+	case c == '[':
+		end := strings.IndexByte(p.src[p.pos:], ']')
+		if end < 0 {
+			return nil, fmt.Errorf("missing closing bracket")
+		}
+		name := strings.TrimSpace(p.src[p.pos+1 : p.pos+end])
+		if name == "" {
+			return nil, fmt.Errorf("empty column name at position %d", p.pos+1)
+		}
+		p.pos += end + 1
+		return columnExpr(name), nil
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return numberExpr(n), nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) ||
+			unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if p.peek() != '(' {
+			return columnExpr(name), nil
+		}
+
+		arity, ok := expressionFunctions[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %q", name)
+		}
+		p.pos++
+		call := callExpr{name: strings.ToLower(name)}
+		if p.peek() != ')' {
+			for {
+				arg, err := p.parseExpr(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+				if p.peek() != ',' {
+					break
+				}
+				p.pos++
+			}
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis after arguments to %s", name)
+		}
+		p.pos++
+		if len(call.args) < arity[0] || len(call.args) > arity[1] {
+			return nil, fmt.Errorf("%s takes %d to %d arguments", call.name, arity[0], arity[1])
+		}
+		return call, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileExpression(t *testing.T) {
+	row := map[string]interface{}{"Units": 4, "Occupied": 3, "Avg Rent": 1250.5, "Notes": "n/a", "Past Due": "80.25"}
+
+	tests := []struct {
+		expr  string
+		value float64
+		ok    bool
+	}{
+		{"100 - Occupied/Units*100", 25, true},
+		{"[Avg Rent] * Units", 5002, true},
+		{"-(Units - Occupied) + 2", 1, true},
+		{"Units % 3", 1, true},
+		{"round([Avg Rent] / 3, 1)", 416.8, true},
+		{"max(Units, Occupied, 7)", 7, true},
+		{"abs(Occupied - Units)", 1, true},
+		{"[Past Due] * 2", 160.5, true},
+		{"Occupied / (Units - 4)", 0, false},
+		{"Notes + 1", 0, false},
+		{"Missing * 2", 0, false},
+		{"coalesce(Missing, Units)", 4, true},
+	}
+	for _, tt := range tests {
+		expr, err := compileExpression(tt.expr)
+		require.NoError(t, err, tt.expr)
+		value, ok := expr.eval(row)
+		assert.Equal(t, tt.ok, ok, tt.expr)
+		if tt.ok {
+			assert.InDelta(t, tt.value, value, 1e-9, tt.expr)
+		}
+	}
+
+	for _, bad := range []string{"", "1 +", "(Units", "[Avg Rent", "exec(1)", "round(1, 2, 3)", "Units Occupied", "1 ; DROP"} {
+		_, err := compileExpression(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestApplyComputedColumns(t *testing.T) {
+	data := &ReportData{
+		Headers: []string{"Name", "Units", "Occupied", "Balance"},
+		Rows: []map[string]interface{}{
+			{"Name": "Maple", "Units": 4, "Occupied": 3, "Balance": 100.0},
+			{"Name": "Oak", "Units": 0, "Occupied": 0, "Balance": 50.0},
+			{"Name": "Elm", "Units": 10, "Occupied": 5, "Balance": 0.0},
+		},
+	}
+	criteria := map[string]interface{}{"computed_columns": []interface{}{
+		"Vacancy % = 100 - Occupied/Units*100",
+		map[string]interface{}{"name": "Balance Per Unit", "expression": "Balance / Units", "summary": "sum"},
+		map[string]interface{}{"name": "Doubled", "expression": "[Balance Per Unit] * 2", "summary": "none"},
+	}}
+
+	require.NoError(t, applyComputedColumns(data, criteria))
+	assert.Equal(t, []string{"Name", "Units", "Occupied", "Balance", "Vacancy %", "Balance Per Unit", "Doubled"}, data.Headers)
+	assert.Equal(t, 25.0, data.Rows[0]["Vacancy %"])
+	assert.Nil(t, data.Rows[1]["Vacancy %"])
+	assert.Equal(t, 50.0, data.Rows[0]["Doubled"])
+	assert.Equal(t, 37.5, data.Summary["Vacancy %"])
+	assert.Equal(t, 25.0, data.Summary["Balance Per Unit"])
+	assert.NotContains(t, data.Summary, "Doubled")
+	assert.Equal(t, []string{"Balance", "Units"}, data.ComputedColumns["Doubled"])
+
+	// Values derived from a sensitive column are masked with it
+	RedactReportData(data, nil)
+	assert.Equal(t, RedactedValue, data.Rows[0]["Doubled"])
+	assert.Equal(t, RedactedValue, data.Summary["Balance Per Unit"])
+	assert.Equal(t, 25.0, data.Rows[0]["Vacancy %"])
+
+	err := applyComputedColumns(&ReportData{Headers: []string{"Units"}},
+		map[string]interface{}{"computed_columns": []interface{}{"X = Rent * 2"}})
+	assert.ErrorContains(t, err, `unknown column "Rent"`)
+}
+
+func TestValidateComputedColumns(t *testing.T) {
+	assert.NoError(t, ValidateComputedColumns(map[string]interface{}{}))
+	assert.Error(t, ValidateComputedColumns(map[string]interface{}{"computed_columns": "x"}))
+	assert.Error(t, ValidateComputedColumns(map[string]interface{}{"computed_columns": []interface{}{"no equals sign"}}))
+	assert.Error(t, ValidateComputedColumns(map[string]interface{}{"computed_columns": []interface{}{"A = 1", "A = 2"}}))
+	assert.Error(t, ValidateComputedColumns(map[string]interface{}{"computed_columns": []interface{}{
+		map[string]interface{}{"name": "A", "expression": "1", "summary": "median"}}}))
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ReportPlugin is a custom report type. Deployments add bespoke reports by registering a plugin
+// from an init function, either in a package blank-imported by cmd/server or in a Go plugin loaded
+// at startup (see pkg/reportplugin).
+type ReportPlugin interface {
+	// BuildQuery returns the SQL and arguments for one run of the report. The query must select
+	// one column per entry of Columns, in the same order.
+	BuildQuery(report *CustomReport, parameters map[string]interface{}) (string, []interface{}, error)
+	// Columns returns the report's headers, which are also the row keys
+	Columns() []string
+	// Summarize returns the summary statistics for the report's rows, or nil for none
+	Summarize(rows []map[string]interface{}) map[string]interface{}
+}
+
+// ReportPluginColumnTypes is implemented by plugins that declare the types of their columns. The
+// types of other plugins' columns are inferred from their values.
+type ReportPluginColumnTypes interface {
+	// ColumnTypes maps headers to column types such as ColumnCurrency; headers not listed are inferred
+	ColumnTypes() map[string]string
+}
+
+// builtinReportTypes are handled by buildAndExecuteReportQuery and cannot be replaced by plugins
+var builtinReportTypes = map[string]bool{
+	"property": true, "financial": true, "tenant": true, "maintenance": true, "vacancy_forecast": true,
+	"portfolio_comparison": true, "rent_roll": true, "deposit_compliance": true, "dues_delinquency": true,
+	"association_violations": true, "utility_benchmark": true, "unit_mix": true,
+	"renewals_funnel": true, "collections_status": true, "renovation_roi": true,
+	"turnover": true,
+}
+
+var (
+	reportPluginsMu sync.RWMutex
+	reportPlugins   = map[string]ReportPlugin{}
+)
+
+// RegisterReportType makes a custom report type available under name. Like database/sql.Register
+// it panics if the name is empty, built in or already registered, since that is a programming error.
+func RegisterReportType(name string, plugin ReportPlugin) {
+	reportPluginsMu.Lock()
+	defer reportPluginsMu.Unlock()
+
+	if name == "" || plugin == nil {
+		panic("models: RegisterReportType needs a name and a plugin")
+	}
+	if builtinReportTypes[name] {
+		panic("models: report type " + name + " is built in")
+	}
+	if _, exists := reportPlugins[name]; exists {
+		panic("models: report type " + name + " registered twice")
+	}
+	reportPlugins[name] = plugin
+}
+
+// ReportTypes returns the names of the built-in and registered report types, sorted
+func ReportTypes() []string {
+	reportPluginsMu.RLock()
+	defer reportPluginsMu.RUnlock()
+
+	names := make([]string, 0, len(builtinReportTypes)+len(reportPlugins))
+	for name := range builtinReportTypes {
+		names = append(names, name)
+	}
+	for name := range reportPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidReportType reports whether name is a built-in or registered report type
+func ValidReportType(name string) bool {
+	if builtinReportTypes[name] {
+		return true
+	}
+	_, ok := getReportPlugin(name)
+	return ok
+}
+
+// getReportPlugin returns the plugin registered for a report type
+func getReportPlugin(name string) (ReportPlugin, bool) {
+	reportPluginsMu.RLock()
+	defer reportPluginsMu.RUnlock()
+	plugin, ok := reportPlugins[name]
+	return plugin, ok
+}
+
+// generatePluginReport runs a registered report type's query and maps each result column to
+// the plugin's header at the same position
+func generatePluginReport(plugin ReportPlugin, report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	query, args, err := plugin.BuildQuery(report, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	headers := plugin.Columns()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) != len(headers) {
+		return nil, fmt.Errorf("report type %s: query returns %d columns but %d headers are declared",
+			report.ReportType, len(columns), len(headers))
+	}
+
+	data := &ReportData{
+		Headers: headers,
+		Rows:    []map[string]interface{}{},
+	}
+
+	values := make([]interface{}, len(headers))
+	pointers := make([]interface{}, len(headers))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(headers))
+		for i, header := range headers {
+			// Drivers return text columns as []byte
+			if b, ok := values[i].([]byte); ok {
+				row[header] = string(b)
+			} else {
+				row[header] = values[i]
+			}
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if typed, ok := plugin.(ReportPluginColumnTypes); ok {
+		for header, columnType := range typed.ColumnTypes() {
+			column := ReportColumn{Name: header, Type: columnType}
+			switch columnType {
+			case ColumnCurrency:
+				column = currencyColumn
+			case ColumnPercent:
+				column = percentColumn
+			}
+			column.Name = header
+			data.Columns = append(data.Columns, column)
+		}
+	}
+
+	data.Summary = plugin.Summarize(data.Rows)
+	return data, nil
+}
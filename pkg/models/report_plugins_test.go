@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lateFeeReport is a minimal plugin used to exercise the registry
+type lateFeeReport struct{}
+
+func (lateFeeReport) BuildQuery(report *CustomReport, parameters map[string]interface{}) (string, []interface{}, error) {
+	return "SELECT name, fee FROM late_fees WHERE fee >= $1", []interface{}{25}, nil
+}
+
+func (lateFeeReport) Columns() []string { return []string{"Tenant", "Fee"} }
+
+func (lateFeeReport) Summarize(rows []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"count": len(rows)}
+}
+
+func TestRegisteredReportTypeExecutes(t *testing.T) {
+	RegisterReportType("test_late_fees", lateFeeReport{})
+	assert.Contains(t, ReportTypes(), "test_late_fees")
+	assert.Panics(t, func() { RegisterReportType("test_late_fees", lateFeeReport{}) })
+	assert.Panics(t, func() { RegisterReportType("rent_roll", lateFeeReport{}) })
+
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+	mock.ExpectQuery("FROM late_fees").WithArgs(25).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "fee"}).AddRow([]byte("Ann Lee"), 40.0))
+
+	data, err := buildAndExecuteReportQuery(&CustomReport{ReportType: "test_late_fees"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Tenant", "Fee"}, data.Headers)
+	assert.Equal(t, []map[string]interface{}{{"Tenant": "Ann Lee", "Fee": 40.0}}, data.Rows)
+	assert.Equal(t, 1, data.Summary["count"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = buildAndExecuteReportQuery(&CustomReport{ReportType: "unknown"}, nil)
+	assert.ErrorContains(t, err, "unsupported report type")
+}
+
+func TestPreviewReportTruncatesRows(t *testing.T) {
+	RegisterReportType("test_preview", lateFeeReport{})
+
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+	rows := sqlmock.NewRows([]string{"name", "fee"})
+	for i := 0; i < PreviewRowLimit+10; i++ {
+		rows.AddRow("Tenant", 30.0)
+	}
+	mock.ExpectQuery("FROM late_fees").WillReturnRows(rows)
+
+	preview, err := PreviewReport(&CustomReport{ReportType: "test_preview", Criteria: map[string]interface{}{
+		"computed_columns": []interface{}{"Fee With Tax = Fee * 1.1"},
+	}}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, PreviewRowLimit+10, preview.TotalRows)
+	assert.True(t, preview.Truncated)
+	assert.Len(t, preview.Rows, PreviewRowLimit)
+	assert.Equal(t, 33.0, preview.Rows[0]["Fee With Tax"])
+	assert.Equal(t, PreviewRowLimit+10, preview.Summary["count"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,113 @@
+package models
+
+import "sort"
+
+// RedactedValue replaces the value of a column the viewer may not see
+const RedactedValue = "[redacted]"
+
+// Column sensitivity classes
+const (
+	SensitivityContact   = "contact"   // Tenant and owner phone numbers and email addresses
+	SensitivityFinancial = "financial" // Balances, deposits and other amounts owed
+)
+
+// SensitivityPermissions is the permission a viewer needs to see each sensitivity class
+var SensitivityPermissions = map[string]string{
+	SensitivityContact:   "tenants.contact.read",
+	SensitivityFinancial: "payments.read",
+}
+
+// ReportColumnSensitivity classifies the report columns and summary fields holding sensitive
+// data. Columns not listed are visible to everyone who can run the report.
+var ReportColumnSensitivity = map[string]string{
+	"Email":            SensitivityContact,
+	"Phone":            SensitivityContact,
+	"Balance":          SensitivityFinancial,
+	"Past Due":         SensitivityFinancial,
+	"0-30 Days":        SensitivityFinancial,
+	"31-60 Days":       SensitivityFinancial,
+	"61-90 Days":       SensitivityFinancial,
+	"Over 90 Days":     SensitivityFinancial,
+	"Security Deposit": SensitivityFinancial,
+	"Interest Owed":    SensitivityFinancial,
+	"Fines":            SensitivityFinancial,
+	"Promised Amount":  SensitivityFinancial,
+
+	"total_past_due":       SensitivityFinancial,
+	"over_90_days":         SensitivityFinancial,
+	"deposits_in_question": SensitivityFinancial,
+	"interest_owed":        SensitivityFinancial,
+}
+
+// RedactReportData masks the sensitive columns and summary fields the viewer lacks permission
+// for, records them in RedactedColumns and drops charts built from them. A nil viewer sees no
+// sensitive data.
+func RedactReportData(data *ReportData, viewer *User) {
+	if data == nil {
+		return
+	}
+
+	hidden := map[string]bool{}
+	for column, sensitivity := range ReportColumnSensitivity {
+		if viewer == nil || !viewer.HasPermission(SensitivityPermissions[sensitivity]) {
+			hidden[column] = true
+		}
+	}
+	// Computed columns are as sensitive as the columns they are derived from
+	for column, sources := range data.ComputedColumns {
+		for _, source := range sources {
+			if hidden[source] {
+				hidden[column] = true
+			}
+		}
+	}
+
+	redacted := map[string]bool{}
+	for _, header := range data.Headers {
+		if hidden[header] {
+			redacted[header] = true
+		}
+	}
+	for _, row := range data.Rows {
+		for column := range row {
+			if hidden[column] {
+				row[column] = RedactedValue
+				redacted[column] = true
+			}
+		}
+	}
+	for field := range data.Summary {
+		if hidden[field] {
+			data.Summary[field] = RedactedValue
+			redacted[field] = true
+		}
+	}
+	if len(redacted) == 0 {
+		return
+	}
+
+	charts := data.Charts[:0]
+	for _, chart := range data.Charts {
+		if !chartUsesColumns(chart, redacted) {
+			charts = append(charts, chart)
+		}
+	}
+	data.Charts = charts
+
+	data.RedactedColumns = make([]string, 0, len(redacted))
+	for column := range redacted {
+		data.RedactedColumns = append(data.RedactedColumns, column)
+	}
+	sort.Strings(data.RedactedColumns)
+}
+
+// chartUsesColumns reports whether any chart dataset is labelled with one of the columns
+func chartUsesColumns(chart ChartData, columns map[string]bool) bool {
+	datasets, _ := chart.Data["datasets"].([]map[string]interface{})
+	for _, dataset := range datasets {
+		if label, ok := dataset["label"].(string); ok && columns[label] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func redactionTestData() *ReportData {
+	return &ReportData{
+		Headers: []string{"Owner", "Email", "Balance"},
+		Rows: []map[string]interface{}{
+			{"Owner": "Ana Ruiz", "Email": "ana@example.com", "Balance": 420.0},
+		},
+		Summary: map[string]interface{}{"delinquent_owners": 1, "total_past_due": 420.0},
+		Charts: []ChartData{
+			{Title: "Balances", Data: map[string]interface{}{
+				"datasets": []map[string]interface{}{{"label": "Balance", "data": []interface{}{420.0}}},
+			}},
+		},
+	}
+}
+
+func TestRedactReportDataMasksColumnsWithoutPermission(t *testing.T) {
+	viewer := &User{Roles: []Role{{Name: "viewer", Permissions: StringArray{"tenants.read"}}}}
+	data := redactionTestData()
+
+	RedactReportData(data, viewer)
+
+	assert.Equal(t, "Ana Ruiz", data.Rows[0]["Owner"])
+	assert.Equal(t, RedactedValue, data.Rows[0]["Email"])
+	assert.Equal(t, RedactedValue, data.Rows[0]["Balance"])
+	assert.Equal(t, 1, data.Summary["delinquent_owners"])
+	assert.Equal(t, RedactedValue, data.Summary["total_past_due"])
+	assert.Empty(t, data.Charts)
+	assert.Equal(t, []string{"Balance", "Email", "total_past_due"}, data.RedactedColumns)
+}
+
+func TestRedactReportDataKeepsPermittedColumns(t *testing.T) {
+	manager := &User{Roles: []Role{{Name: "property_manager",
+		Permissions: StringArray{"payments.read", "tenants.contact.read"}}}}
+	data := redactionTestData()
+
+	RedactReportData(data, manager)
+
+	assert.Equal(t, "ana@example.com", data.Rows[0]["Email"])
+	assert.Equal(t, 420.0, data.Rows[0]["Balance"])
+	assert.Len(t, data.Charts, 1)
+	assert.Empty(t, data.RedactedColumns)
+}
+
+func TestRedactReportDataNilViewerSeesNoSensitiveData(t *testing.T) {
+	data := redactionTestData()
+
+	RedactReportData(data, nil)
+
+	assert.Equal(t, RedactedValue, data.Rows[0]["Email"])
+	assert.Equal(t, RedactedValue, data.Summary["total_past_due"])
+}
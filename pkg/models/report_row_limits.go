@@ -0,0 +1,378 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// DefaultReportRowLimit is the most rows a report returns to users of a role without a
+// configured limit
+const DefaultReportRowLimit = 10000
+
+// Report parameters controlling the row limit
+const (
+	ReportParamGroupBy        = "group_by"         // Column to aggregate the rows by
+	ReportParamIgnoreRowLimit = "ignore_row_limit" // true to return every row whatever the limit
+)
+
+// maxGroupBySuggestions is how many aggregations a too-large report suggests
+const maxGroupBySuggestions = 3
+
+// Report row limit errors
+var (
+	ErrInvalidReportRowLimit = errors.New("the row limit must be 0 (unlimited) or more")
+	ErrUnknownGroupByColumn  = errors.New("group_by must name one of the report's columns")
+)
+
+// ReportRowLimit is the most rows a report returns to users of a role. A MaxRows of 0 is unlimited.
+type ReportRowLimit struct {
+	RoleID    int           `json:"role_id"`
+	RoleName  string        `json:"role"`
+	MaxRows   int           `json:"max_rows"`
+	UpdatedBy sql.NullInt32 `json:"updated_by,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// ReportAggregation is a way to run a too-large report aggregated by one of its columns
+type ReportAggregation struct {
+	GroupBy    string                 `json:"group_by"`
+	Groups     int                    `json:"groups,omitempty"` // Rows the aggregated report returns, when known
+	Parameters map[string]interface{} `json:"parameters"`       // Execution parameters that run it
+}
+
+// ReportTooLargeError is returned in place of a report that would return more rows than the
+// viewer's row limit, with aggregations that would return fewer
+type ReportTooLargeError struct {
+	EstimatedRows int                 `json:"estimated_rows"`
+	RowLimit      int                 `json:"row_limit"`
+	Suggestions   []ReportAggregation `json:"suggestions"`
+}
+
+func (e *ReportTooLargeError) Error() string {
+	return fmt.Sprintf("the report would return about %d rows, more than the limit of %d", e.EstimatedRows, e.RowLimit)
+}
+
+// GetReportRowLimits lists the configured row limits by role name
+func GetReportRowLimits() ([]ReportRowLimit, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT rl.role_id, r.name, rl.max_rows, rl.updated_by, rl.updated_at
+		FROM report_row_limits rl
+		JOIN roles r ON r.id = rl.role_id
+		ORDER BY r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var limits []ReportRowLimit
+	for rows.Next() {
+		var l ReportRowLimit
+		if err := rows.Scan(&l.RoleID, &l.RoleName, &l.MaxRows, &l.UpdatedBy, &l.UpdatedAt); err != nil {
+			return nil, err
+		}
+		limits = append(limits, l)
+	}
+	return limits, rows.Err()
+}
+
+// SetReportRowLimit sets the row limit of a role, replacing any existing one
+func SetReportRowLimit(roleID, maxRows int, updatedBy sql.NullInt32) error {
+	if maxRows < 0 {
+		return ErrInvalidReportRowLimit
+	}
+	_, err := db.DB.Exec(`
+		INSERT INTO report_row_limits (role_id, max_rows, updated_by, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (role_id) DO UPDATE
+		SET max_rows = EXCLUDED.max_rows, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+		roleID, maxRows, updatedBy)
+	return err
+}
+
+// DeleteReportRowLimit returns a role to DefaultReportRowLimit. It returns sql.ErrNoRows if the
+// role has no configured limit.
+func DeleteReportRowLimit(roleID int) error {
+	result, err := db.DB.Exec("DELETE FROM report_row_limits WHERE role_id = $1", roleID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetUserReportRowLimit returns the row limit of a user: the most generous limit of their roles,
+// counting roles without a configured limit as DefaultReportRowLimit. 0 is unlimited.
+func GetUserReportRowLimit(user *User) (int, error) {
+	if user == nil || len(user.Roles) == 0 {
+		return DefaultReportRowLimit, nil
+	}
+
+	rows, err := db.ReadDB().Query(`
+		SELECT rl.role_id, rl.max_rows
+		FROM report_row_limits rl
+		JOIN user_roles ur ON ur.role_id = rl.role_id
+		WHERE ur.user_id = $1`, user.ID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	configured := map[int]int{}
+	for rows.Next() {
+		var roleID, maxRows int
+		if err := rows.Scan(&roleID, &maxRows); err != nil {
+			return 0, err
+		}
+		configured[roleID] = maxRows
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	limit := -1
+	for _, role := range user.Roles {
+		roleLimit, ok := configured[role.ID]
+		if !ok {
+			roleLimit = DefaultReportRowLimit
+		}
+		if roleLimit == 0 {
+			return 0, nil
+		}
+		if roleLimit > limit {
+			limit = roleLimit
+		}
+	}
+	return limit, nil
+}
+
+// reportRowEstimators count the rows of the report types whose size grows with the portfolio
+// without generating them, so a too-large report is refused before its query runs
+var reportRowEstimators = map[string]func(report *CustomReport) (int, error){
+	"property": func(report *CustomReport) (int, error) {
+		// Property reports return one row per listed property
+		if propertyIDs, ok := report.Criteria["property_ids"].([]interface{}); ok && len(propertyIDs) > 0 {
+			return len(propertyIDs), nil
+		}
+		return countReportRows("SELECT COUNT(*) FROM properties")
+	},
+	"tenant": func(*CustomReport) (int, error) {
+		return countReportRows("SELECT COUNT(*) FROM tenants")
+	},
+	"maintenance": func(*CustomReport) (int, error) {
+		return countReportRows("SELECT COUNT(*) FROM maintenance_requests mr JOIN properties p ON mr.property_id = p.id")
+	},
+}
+
+func countReportRows(query string) (int, error) {
+	var count int
+	err := db.ReadDB().QueryRow(query).Scan(&count)
+	return count, err
+}
+
+// reportGroupByColumns are the columns each built-in report type is usefully aggregated by.
+// Other types are suggested the columns of their result with the fewest distinct values.
+var reportGroupByColumns = map[string][]string{
+	"property":    {"Type"},
+	"tenant":      {"Property", "Status"},
+	"maintenance": {"Property", "Status", "Priority"},
+}
+
+// ExecuteReportWithinLimit executes a report like ExecuteReport unless it would return more rows
+// than the viewer's row limit, in which case it returns a *ReportTooLargeError suggesting
+// aggregations instead. Reports aggregated with group_by, or run with ignore_row_limit, are not
+// limited.
+func ExecuteReportWithinLimit(reportID int, parameters map[string]interface{}, viewer *User) (*ReportData, error) {
+	if ignore, _ := parameters[ReportParamIgnoreRowLimit].(bool); ignore {
+		return ExecuteReport(reportID, parameters, viewer)
+	}
+	if column, _ := parameters[ReportParamGroupBy].(string); column != "" {
+		return ExecuteReport(reportID, parameters, viewer)
+	}
+
+	limit, err := GetUserReportRowLimit(viewer)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		return ExecuteReport(reportID, parameters, viewer)
+	}
+
+	report, err := GetCustomReportByID(reportID)
+	if err != nil {
+		return nil, err
+	}
+	if estimate, ok := reportRowEstimators[report.ReportType]; ok {
+		if err := scopeReportProperties(report, parameters, viewer); err != nil {
+			return nil, err
+		}
+		estimated, err := estimate(report)
+		if err != nil {
+			return nil, err
+		}
+		if estimated > limit {
+			return nil, newReportTooLargeError(report.ReportType, nil, estimated, limit, parameters)
+		}
+	}
+
+	// Types that cannot be estimated are checked once they have run
+	data, err := ExecuteReport(reportID, parameters, viewer)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Rows) > limit {
+		return nil, newReportTooLargeError(report.ReportType, data, len(data.Rows), limit, parameters)
+	}
+	return data, nil
+}
+
+// newReportTooLargeError suggests aggregating a report by the columns of its type, or by the
+// columns of data with the fewest distinct values. data is nil when the report has not run, so
+// group counts are unknown.
+func newReportTooLargeError(reportType string, data *ReportData, rows, limit int, parameters map[string]interface{}) *ReportTooLargeError {
+	columns := reportGroupByColumns[reportType]
+	groups := map[string]int{}
+	if data != nil {
+		for _, column := range data.Headers {
+			distinct := map[string]bool{}
+			for _, row := range data.Rows {
+				distinct[fmt.Sprint(row[column])] = true
+			}
+			groups[column] = len(distinct)
+		}
+		if columns == nil {
+			columns = groupByCandidates(data, groups, limit)
+		}
+	}
+
+	e := &ReportTooLargeError{EstimatedRows: rows, RowLimit: limit, Suggestions: []ReportAggregation{}}
+	for _, column := range columns {
+		suggested := make(map[string]interface{}, len(parameters)+1)
+		for k, v := range parameters {
+			suggested[k] = v
+		}
+		suggested[ReportParamGroupBy] = column
+		e.Suggestions = append(e.Suggestions, ReportAggregation{GroupBy: column, Groups: groups[column], Parameters: suggested})
+	}
+	return e
+}
+
+// groupByCandidates returns the non-numeric columns of data that group its rows into between 2
+// and limit groups, fewest groups first
+func groupByCandidates(data *ReportData, groups map[string]int, limit int) []string {
+	numeric := map[string]bool{}
+	for _, column := range data.Columns {
+		numeric[column.Name] = column.IsNumeric()
+	}
+
+	var candidates []string
+	for _, column := range data.Headers {
+		if !numeric[column] && groups[column] >= 2 && groups[column] <= limit {
+			candidates = append(candidates, column)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return groups[candidates[i]] < groups[candidates[j]] })
+	if len(candidates) > maxGroupBySuggestions {
+		candidates = candidates[:maxGroupBySuggestions]
+	}
+	return candidates
+}
+
+// AggregateReportData groups a report's rows by the value of one column. Each group has a Rows
+// count, a "Total" of each number and currency column, and an "Average" of each percent and
+// average column; ID columns and other text and date columns are dropped. The summary and charts
+// are kept as they cover every row. Aggregates are computed columns of the columns they come
+// from, so they are redacted with them.
+func AggregateReportData(data *ReportData, column string) (*ReportData, error) {
+	var key ReportColumn
+	found := false
+	for _, c := range data.Columns {
+		if c.Name == column {
+			key, found = c, true
+		}
+	}
+	if !found {
+		return nil, ErrUnknownGroupByColumn
+	}
+
+	type aggregate struct {
+		name    string
+		source  string
+		average bool
+	}
+	var aggregates []aggregate
+	aggregated := &ReportData{
+		Headers:         []string{column, "Rows"},
+		Columns:         []ReportColumn{key, {Name: "Rows", Type: ColumnNumber}},
+		Summary:         data.Summary,
+		Charts:          data.Charts,
+		ComputedColumns: map[string][]string{},
+	}
+	if sources, ok := data.ComputedColumns[column]; ok {
+		aggregated.ComputedColumns[column] = sources
+	}
+	for _, c := range data.Columns {
+		if !c.IsNumeric() || c.Name == column || c.Name == "ID" || strings.HasSuffix(c.Name, " ID") {
+			continue
+		}
+		a := aggregate{name: "Total " + c.Name, source: c.Name}
+		if c.Type == ColumnPercent || strings.HasPrefix(c.Name, "Avg ") {
+			a = aggregate{name: "Average " + strings.TrimPrefix(c.Name, "Avg "), source: c.Name, average: true}
+		}
+		aggregates = append(aggregates, a)
+		out := c
+		out.Name = a.name
+		aggregated.Headers = append(aggregated.Headers, a.name)
+		aggregated.Columns = append(aggregated.Columns, out)
+		aggregated.ComputedColumns[a.name] = append([]string{c.Name}, data.ComputedColumns[c.Name]...)
+	}
+
+	// Groups are listed in the order their first row appears
+	type group struct {
+		row    map[string]interface{}
+		sums   map[string]float64
+		counts map[string]int
+	}
+	var order []string
+	groups := map[string]*group{}
+	for _, row := range data.Rows {
+		k := fmt.Sprint(row[column])
+		g, ok := groups[k]
+		if !ok {
+			g = &group{row: map[string]interface{}{"Rows": 0}, sums: map[string]float64{}, counts: map[string]int{}}
+			if value, ok := row[column]; ok {
+				g.row[column] = value
+			}
+			groups[k] = g
+			order = append(order, k)
+		}
+		g.row["Rows"] = g.row["Rows"].(int) + 1
+		for _, a := range aggregates {
+			if value, ok := toFloat(row[a.source]); ok {
+				g.sums[a.name] += value
+				g.counts[a.name]++
+			}
+		}
+	}
+
+	aggregated.Rows = make([]map[string]interface{}, 0, len(order))
+	for _, k := range order {
+		g := groups[k]
+		for _, a := range aggregates {
+			if g.counts[a.name] == 0 {
+				continue
+			}
+			if a.average {
+				g.row[a.name] = g.sums[a.name] / float64(g.counts[a.name])
+			} else {
+				g.row[a.name] = g.sums[a.name]
+			}
+		}
+		aggregated.Rows = append(aggregated.Rows, g.row)
+	}
+	return aggregated, nil
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserReportRowLimit(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	user := &User{ID: 7, Roles: []Role{{ID: 2, Name: "viewer"}, {ID: 3, Name: "tenant"}}}
+
+	// The most generous role wins; roles without a limit count as the default
+	mock.ExpectQuery(`FROM report_row_limits rl`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"role_id", "max_rows"}).AddRow(2, 500))
+	limit, err := GetUserReportRowLimit(user)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultReportRowLimit, limit)
+
+	mock.ExpectQuery(`FROM report_row_limits rl`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"role_id", "max_rows"}).AddRow(2, 500).AddRow(3, 2000))
+	limit, err = GetUserReportRowLimit(user)
+	require.NoError(t, err)
+	assert.Equal(t, 2000, limit)
+
+	// Any unlimited role lifts the limit
+	mock.ExpectQuery(`FROM report_row_limits rl`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"role_id", "max_rows"}).AddRow(2, 0).AddRow(3, 2000))
+	limit, err = GetUserReportRowLimit(user)
+	require.NoError(t, err)
+	assert.Equal(t, 0, limit)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetReportRowLimitRejectsNegative(t *testing.T) {
+	assert.Equal(t, ErrInvalidReportRowLimit, SetReportRowLimit(2, -1, sql.NullInt32{}))
+}
+
+func TestAggregateReportData(t *testing.T) {
+	headers := []string{"ID", "Property", "Status", "Rent", "Occupancy"}
+	data := &ReportData{
+		Headers: headers,
+		Columns: typedColumns(headers, map[string]ReportColumn{
+			"ID": numberColumn, "Rent": currencyColumn, "Occupancy": percentColumn,
+		}),
+		Rows: []map[string]interface{}{
+			{"ID": 1, "Property": "Elm", "Status": "active", "Rent": 1000.0, "Occupancy": 90.0},
+			{"ID": 2, "Property": "Oak", "Status": "active", "Rent": 1500.0, "Occupancy": 80.0},
+			{"ID": 3, "Property": "Elm", "Status": "inactive", "Rent": 1200.0, "Occupancy": 70.0},
+		},
+		Summary: map[string]interface{}{"total": 3},
+	}
+
+	aggregated, err := AggregateReportData(data, "Property")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Property", "Rows", "Total Rent", "Average Occupancy"}, aggregated.Headers)
+	assert.Equal(t, ColumnCurrency, aggregated.Columns[2].Type)
+	require.Len(t, aggregated.Rows, 2)
+	assert.Equal(t, map[string]interface{}{"Property": "Elm", "Rows": 2, "Total Rent": 2200.0, "Average Occupancy": 80.0}, aggregated.Rows[0])
+	assert.Equal(t, map[string]interface{}{"Property": "Oak", "Rows": 1, "Total Rent": 1500.0, "Average Occupancy": 80.0}, aggregated.Rows[1])
+	assert.Equal(t, data.Summary, aggregated.Summary)
+	assert.Equal(t, []string{"Rent"}, aggregated.ComputedColumns["Total Rent"])
+
+	_, err = AggregateReportData(data, "Unknown")
+	assert.Equal(t, ErrUnknownGroupByColumn, err)
+}
+
+func TestAggregatesAreRedactedWithTheirSource(t *testing.T) {
+	headers := []string{"Tenant", "Balance"}
+	data := &ReportData{
+		Headers: headers,
+		Columns: typedColumns(headers, map[string]ReportColumn{"Balance": currencyColumn}),
+		Rows:    []map[string]interface{}{{"Tenant": "A", "Balance": 10.0}, {"Tenant": "A", "Balance": 5.0}},
+	}
+
+	aggregated, err := AggregateReportData(data, "Tenant")
+	require.NoError(t, err)
+	RedactReportData(aggregated, &User{})
+	assert.Equal(t, RedactedValue, aggregated.Rows[0]["Total Balance"])
+}
+
+func TestNewReportTooLargeError(t *testing.T) {
+	parameters := map[string]interface{}{"as_of": "2026-10-01"}
+
+	// Built-in types suggest their own columns before they run
+	e := newReportTooLargeError("maintenance", nil, 25000, 10000, parameters)
+	assert.Equal(t, 25000, e.EstimatedRows)
+	require.Len(t, e.Suggestions, 3)
+	assert.Equal(t, "Property", e.Suggestions[0].GroupBy)
+	assert.Equal(t, map[string]interface{}{"as_of": "2026-10-01", ReportParamGroupBy: "Property"}, e.Suggestions[0].Parameters)
+	assert.NotContains(t, parameters, ReportParamGroupBy)
+
+	// Other types suggest the text columns with the fewest distinct values
+	headers := []string{"ID", "Name", "Region", "Kind", "Amount"}
+	data := &ReportData{
+		Headers: headers,
+		Columns: typedColumns(headers, map[string]ReportColumn{"ID": numberColumn, "Amount": currencyColumn}),
+	}
+	for i := 0; i < 6; i++ {
+		data.Rows = append(data.Rows, map[string]interface{}{
+			"ID": i, "Name": string(rune('a' + i)), "Region": []string{"North", "South"}[i%2],
+			"Kind": []string{"x", "y", "z"}[i%3], "Amount": float64(i),
+		})
+	}
+	e = newReportTooLargeError("plugin_report", data, 6, 5, nil)
+	require.Len(t, e.Suggestions, 2)
+	assert.Equal(t, "Region", e.Suggestions[0].GroupBy)
+	assert.Equal(t, 2, e.Suggestions[0].Groups)
+	assert.Equal(t, "Kind", e.Suggestions[1].GroupBy)
+}
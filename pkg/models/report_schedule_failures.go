@@ -0,0 +1,181 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Schedule types of report schedule failures
+const (
+	ScheduleTypeSubscription = "subscription" // Report subscription deliveries
+	ScheduleTypeReport       = "report"       // Runs of a report's own schedule
+)
+
+// ReportScheduleFailure is one failed attempt of a scheduled report delivery
+type ReportScheduleFailure struct {
+	ID           int       `json:"id"`
+	ScheduleType string    `json:"schedule_type"`
+	ScheduleID   int       `json:"schedule_id"`
+	ReportID     int       `json:"report_id"`
+	Attempt      int       `json:"attempt"`
+	Error        string    `json:"error"`
+	GaveUp       bool      `json:"gave_up"`
+	FailedAt     time.Time `json:"failed_at"`
+}
+
+// FailingReportSchedule is a schedule whose most recent attempt failed, for the admin health view
+type FailingReportSchedule struct {
+	ScheduleType   string       `json:"schedule_type"`
+	ScheduleID     int          `json:"schedule_id"`
+	ReportID       int          `json:"report_id"`
+	ReportName     string       `json:"report_name"`
+	OwnerID        int          `json:"owner_id"`
+	OwnerEmail     string       `json:"owner_email"`
+	RetryCount     int          `json:"retry_count"` // Failed attempts of the current run; 0 once it was given up
+	LastError      string       `json:"last_error"`
+	LastFailedAt   time.Time    `json:"last_failed_at"`
+	LastSucceeded  sql.NullTime `json:"last_succeeded_at,omitempty"`
+	NextRun        time.Time    `json:"next_run"`
+	RecentFailures int          `json:"recent_failures"` // Failed attempts since the window start
+}
+
+// RecordReportSubscriptionFailure records a failed attempt at a subscription's due run and moves
+// the run to nextRun: a retry, or when gaveUp the next regular run. It returns false when another
+// server already rescheduled the run. Pass the transaction that notifies the owner, if any.
+func RecordReportSubscriptionFailure(q Querier, s *ReportSubscription, attempt int, message string, nextRun time.Time, gaveUp bool, now time.Time) (bool, error) {
+	retryCount := attempt
+	if gaveUp {
+		retryCount = 0
+	}
+	result, err := q.Exec(`
+		UPDATE report_subscriptions
+		SET next_run = $1, retry_count = $2, last_error = $3, last_failed_at = $4, updated_at = NOW()
+		WHERE id = $5 AND next_run = $6`,
+		nextRun, retryCount, message, now, s.ID, s.NextRun)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+
+	return insertReportScheduleFailure(q, ScheduleTypeSubscription, s.ID, s.ReportID, attempt, message, gaveUp, now)
+}
+
+// RecordReportScheduleFailure records a failed attempt at a report schedule's due run and moves
+// the run to nextRun like RecordReportSubscriptionFailure
+func RecordReportScheduleFailure(q Querier, s *ReportSchedule, attempt int, message string, nextRun time.Time, gaveUp bool, now time.Time) (bool, error) {
+	retryCount := attempt
+	if gaveUp {
+		retryCount = 0
+	}
+	result, err := q.Exec(`
+		UPDATE custom_reports
+		SET next_run = $1, retry_count = $2, last_error = $3, last_failed_at = $4
+		WHERE id = $5 AND next_run = $6`,
+		nextRun, retryCount, message, now, s.ReportID, s.NextRun)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+
+	return insertReportScheduleFailure(q, ScheduleTypeReport, s.ReportID, s.ReportID, attempt, message, gaveUp, now)
+}
+
+// insertReportScheduleFailure logs one failed attempt of a schedule
+func insertReportScheduleFailure(q Querier, scheduleType string, scheduleID, reportID, attempt int, message string, gaveUp bool, now time.Time) (bool, error) {
+	_, err := q.Exec(`
+		INSERT INTO report_schedule_failures (schedule_type, schedule_id, report_id, attempt, error, gave_up, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		scheduleType, scheduleID, reportID, attempt, message, gaveUp, now)
+	return err == nil, err
+}
+
+// GetFailingReportSchedules lists the schedules whose last attempt failed, most recent first, with
+// their failed attempts since since
+func GetFailingReportSchedules(since time.Time) ([]FailingReportSchedule, error) {
+	subscriptions, err := queryFailingReportSchedules(ScheduleTypeSubscription, `
+		SELECT s.id, s.report_id, r.name, s.user_id, u.email, s.retry_count, s.last_error,
+			   s.last_failed_at, s.last_run, s.next_run,
+			   (SELECT COUNT(*) FROM report_schedule_failures f
+				WHERE f.schedule_type = $1 AND f.schedule_id = s.id AND f.failed_at >= $2)
+		FROM report_subscriptions s
+		JOIN custom_reports r ON r.id = s.report_id
+		JOIN users u ON u.id = s.user_id
+		WHERE s.last_failed_at IS NOT NULL AND (s.last_run IS NULL OR s.last_failed_at > s.last_run)
+		ORDER BY s.last_failed_at DESC`, since)
+	if err != nil {
+		return nil, err
+	}
+	reports, err := queryFailingReportSchedules(ScheduleTypeReport, `
+		SELECT r.id, r.id, r.name, r.created_by, u.email, r.retry_count, r.last_error,
+			   r.last_failed_at, r.last_generated, r.next_run,
+			   (SELECT COUNT(*) FROM report_schedule_failures f
+				WHERE f.schedule_type = $1 AND f.schedule_id = r.id AND f.failed_at >= $2)
+		FROM custom_reports r
+		JOIN users u ON u.id = r.created_by
+		WHERE r.is_scheduled = true AND r.last_failed_at IS NOT NULL
+		  AND (r.last_generated IS NULL OR r.last_failed_at > r.last_generated)
+		ORDER BY r.last_failed_at DESC`, since)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := append(subscriptions, reports...)
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].LastFailedAt.After(schedules[j].LastFailedAt)
+	})
+	return schedules, nil
+}
+
+// queryFailingReportSchedules runs one schedule type's failing schedule query
+func queryFailingReportSchedules(scheduleType, query string, since time.Time) ([]FailingReportSchedule, error) {
+	rows, err := db.ReadDB().Query(query, scheduleType, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []FailingReportSchedule
+	for rows.Next() {
+		f := FailingReportSchedule{ScheduleType: scheduleType}
+		if err := rows.Scan(&f.ScheduleID, &f.ReportID, &f.ReportName, &f.OwnerID, &f.OwnerEmail,
+			&f.RetryCount, &f.LastError, &f.LastFailedAt, &f.LastSucceeded, &f.NextRun, &f.RecentFailures); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, f)
+	}
+	return schedules, rows.Err()
+}
+
+// GetReportScheduleFailures lists the failed attempts of one schedule, most recent first
+func GetReportScheduleFailures(scheduleType string, scheduleID, limit int) ([]ReportScheduleFailure, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT id, schedule_type, schedule_id, report_id, attempt, error, gave_up, failed_at
+		FROM report_schedule_failures
+		WHERE schedule_type = $1 AND schedule_id = $2
+		ORDER BY failed_at DESC, id DESC
+		LIMIT $3`, scheduleType, scheduleID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []ReportScheduleFailure
+	for rows.Next() {
+		var f ReportScheduleFailure
+		if err := rows.Scan(&f.ID, &f.ScheduleType, &f.ScheduleID, &f.ReportID, &f.Attempt, &f.Error,
+			&f.GaveUp, &f.FailedAt); err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
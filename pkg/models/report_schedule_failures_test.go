@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReportSubscriptionFailure(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+	retryAt := now.Add(30 * time.Minute)
+	s := &ReportSubscription{ID: 3, ReportID: 4, NextRun: now, RetryCount: 1}
+
+	mock.ExpectExec(`UPDATE report_subscriptions`).
+		WithArgs(retryAt, 2, "timeout", now, 3, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO report_schedule_failures`).
+		WithArgs(ScheduleTypeSubscription, 3, 4, 2, "timeout", false, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	recorded, err := RecordReportSubscriptionFailure(db.DB, s, 2, "timeout", retryAt, false, now)
+	require.NoError(t, err)
+	assert.True(t, recorded)
+
+	// Giving up clears the retries; a run another server rescheduled is left alone
+	nextRun := time.Date(2026, 10, 26, 7, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`UPDATE report_subscriptions`).
+		WithArgs(nextRun, 0, "timeout", now, 3, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	recorded, err = RecordReportSubscriptionFailure(db.DB, s, 5, "timeout", nextRun, true, now)
+	require.NoError(t, err)
+	assert.False(t, recorded)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetFailingReportSchedules(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	since := time.Date(2026, 10, 9, 0, 0, 0, 0, time.UTC)
+	failedAt := time.Date(2026, 10, 16, 7, 15, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM report_subscriptions s`).
+		WithArgs(ScheduleTypeSubscription, since).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "report_id", "name", "user_id", "email", "retry_count",
+			"last_error", "last_failed_at", "last_run", "next_run", "count"}).
+			AddRow(3, 4, "Rent Roll", 9, "pm@example.com", 1, "timeout", failedAt, nil, failedAt.Add(15*time.Minute), 6))
+	mock.ExpectQuery(`FROM custom_reports r`).
+		WithArgs(ScheduleTypeReport, since).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id", "name", "created_by", "email", "retry_count",
+			"last_error", "last_failed_at", "last_generated", "next_run", "count"}).
+			AddRow(7, 7, "Owner Statement", 9, "pm@example.com", 0, "render failed", failedAt.Add(time.Hour),
+				failedAt.AddDate(0, 0, -7), failedAt.AddDate(0, 0, 7), 5))
+
+	schedules, err := GetFailingReportSchedules(since)
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+
+	// The most recent failure comes first whatever its type
+	assert.Equal(t, ScheduleTypeReport, schedules[0].ScheduleType)
+	assert.Equal(t, 7, schedules[0].ScheduleID)
+	assert.True(t, schedules[0].LastSucceeded.Valid)
+	assert.Equal(t, ScheduleTypeSubscription, schedules[1].ScheduleType)
+	assert.Equal(t, "timeout", schedules[1].LastError)
+	assert.False(t, schedules[1].LastSucceeded.Valid)
+	assert.Equal(t, 6, schedules[1].RecentFailures)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
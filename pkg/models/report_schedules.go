@@ -0,0 +1,136 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/cron"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ReportSchedule is a scheduled report's own schedule and the state of its runs
+type ReportSchedule struct {
+	ReportID          int            `json:"report_id"`
+	ReportName        string         `json:"report_name"`
+	OwnerID           int            `json:"owner_id"`
+	OwnerEmail        string         `json:"owner_email"`
+	Cron              string         `json:"schedule_cron"`
+	Format            string         `json:"schedule_format"`
+	EffectiveTimezone string         `json:"effective_timezone"` // The report's zone, else its creator's organization's
+	NextRun           sql.NullTime   `json:"next_run,omitempty"` // Not yet planned when null
+	RetryCount        int            `json:"retry_count"`
+	LastError         sql.NullString `json:"last_error,omitempty"`
+	LastFailedAt      sql.NullTime   `json:"last_failed_at,omitempty"`
+}
+
+// Location returns the time zone the schedule runs in; UTC when none is set
+func (s *ReportSchedule) Location() *time.Location {
+	return LoadTimezone(s.EffectiveTimezone)
+}
+
+// Next returns the first run of the schedule strictly after t, in UTC
+func (s *ReportSchedule) Next(t time.Time) (time.Time, error) {
+	schedule, err := cron.Parse(s.Cron)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("report %d: %w", s.ReportID, err)
+	}
+	return schedule.Next(t, s.Location()).UTC(), nil
+}
+
+const reportScheduleColumns = `r.id, r.name, r.created_by, u.email, r.schedule_cron, r.schedule_format,
+	COALESCE(r.schedule_timezone, o.timezone, ''), r.next_run, r.retry_count, r.last_error, r.last_failed_at`
+
+// reportScheduleTables joins the report's creator and their organization, whose time zone applies
+// when the report has none
+var reportScheduleTables = fmt.Sprintf(`custom_reports r
+		JOIN users u ON u.id = r.created_by
+		LEFT JOIN organizations o ON o.id = COALESCE(u.organization_id, %d)`, DefaultOrganizationID)
+
+func scanReportSchedule(row interface{ Scan(...interface{}) error }) (*ReportSchedule, error) {
+	s := &ReportSchedule{}
+	err := row.Scan(&s.ReportID, &s.ReportName, &s.OwnerID, &s.OwnerEmail, &s.Cron, &s.Format,
+		&s.EffectiveTimezone, &s.NextRun, &s.RetryCount, &s.LastError, &s.LastFailedAt)
+	return s, err
+}
+
+// GetDueReportSchedules lists the scheduled reports whose next run is due by now, and those whose
+// next run has not been planned yet
+func GetDueReportSchedules(now time.Time) ([]ReportSchedule, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT `+reportScheduleColumns+`
+		FROM `+reportScheduleTables+`
+		WHERE r.is_scheduled = true AND r.schedule_cron IS NOT NULL AND r.schedule_cron <> ''
+		  AND (r.next_run IS NULL OR r.next_run <= $1)
+		ORDER BY r.next_run, r.id`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []ReportSchedule
+	for rows.Next() {
+		s, err := scanReportSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, rows.Err()
+}
+
+// PlanReportScheduleRun sets the first run of a schedule that has none. It returns false when
+// another server planned it first.
+func PlanReportScheduleRun(s *ReportSchedule, nextRun time.Time) (bool, error) {
+	result, err := db.DB.Exec(`
+		UPDATE custom_reports SET next_run = $1, retry_count = 0
+		WHERE id = $2 AND next_run IS NULL`, nextRun, s.ReportID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ClaimReportScheduleRun marks a schedule's due run as generated at now and moves it on to
+// nextRun, so no other server runs it too. It returns false when another server already claimed it
+// or the report is no longer scheduled. Pass the transaction that stores the run's output.
+func ClaimReportScheduleRun(q Querier, s *ReportSchedule, nextRun, now time.Time) (bool, error) {
+	result, err := q.Exec(`
+		UPDATE custom_reports
+		SET last_generated = $1, next_run = $2, retry_count = 0
+		WHERE id = $3 AND next_run = $4 AND is_scheduled = true`,
+		now, nextRun, s.ReportID, s.NextRun)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetStoredReportExecutions lists a report's most recent executions with a stored output file,
+// newest first, without their snapshots
+func GetStoredReportExecutions(reportID, limit int) ([]ReportExecution, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT id, report_id, executed_by, execution_time, status, output_format, file_path,
+			   row_count, execution_duration_ms, error_message
+		FROM report_executions
+		WHERE report_id = $1 AND file_path IS NOT NULL
+		ORDER BY execution_time DESC, id DESC
+		LIMIT $2`, reportID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var executions []ReportExecution
+	for rows.Next() {
+		var e ReportExecution
+		if err := rows.Scan(&e.ID, &e.ReportID, &e.ExecutedBy, &e.ExecutionTime, &e.Status, &e.OutputFormat,
+			&e.FilePath, &e.RowCount, &e.ExecutionDurationMs, &e.ErrorMessage); err != nil {
+			return nil, err
+		}
+		executions = append(executions, e)
+	}
+	return executions, rows.Err()
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportScheduleNext(t *testing.T) {
+	s := &ReportSchedule{ReportID: 7, Cron: "0 6 * * MON", EffectiveTimezone: "America/Chicago"}
+
+	// 06:00 on Monday in Chicago is 11:00 UTC during daylight saving time
+	next, err := s.Next(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC), next)
+
+	s.Cron = "every monday"
+	_, err = s.Next(time.Now())
+	assert.Error(t, err)
+}
+
+func TestGetDueReportSchedules(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM custom_reports r(.+)r.next_run IS NULL OR r.next_run <= \$1`).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_by", "email", "schedule_cron",
+			"schedule_format", "timezone", "next_run", "retry_count", "last_error", "last_failed_at"}).
+			AddRow(7, "Owner Statement", 9, "pm@example.com", "0 6 * * MON", "csv", "America/Chicago", now, 0, nil, nil).
+			AddRow(8, "Rent Roll", 9, "pm@example.com", "@daily", "pdf", "", nil, 0, nil, nil))
+
+	schedules, err := GetDueReportSchedules(now)
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+	assert.Equal(t, "csv", schedules[0].Format)
+	assert.Equal(t, "America/Chicago", schedules[0].Location().String())
+	assert.False(t, schedules[1].NextRun.Valid, "not planned yet")
+	assert.Equal(t, time.UTC, schedules[1].Location())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimReportScheduleRun(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 19, 11, 0, 30, 0, time.UTC)
+	due := time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC)
+	next := due.AddDate(0, 0, 7)
+	s := &ReportSchedule{ReportID: 7, NextRun: sql.NullTime{Time: due, Valid: true}}
+
+	mock.ExpectExec(`UPDATE custom_reports`).
+		WithArgs(now, next, 7, s.NextRun).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	claimed, err := ClaimReportScheduleRun(db.DB, s, next, now)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Another server claimed the run first
+	mock.ExpectExec(`UPDATE custom_reports`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	claimed, err = ClaimReportScheduleRun(db.DB, s, next, now)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordReportScheduleFailure(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC)
+	retryAt := now.Add(10 * time.Minute)
+	s := &ReportSchedule{ReportID: 7, NextRun: sql.NullTime{Time: now, Valid: true}, RetryCount: 1}
+
+	mock.ExpectExec(`UPDATE custom_reports`).
+		WithArgs(retryAt, 2, "timeout", now, 7, s.NextRun).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO report_schedule_failures`).
+		WithArgs(ScheduleTypeReport, 7, 7, 2, "timeout", false, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	recorded, err := RecordReportScheduleFailure(db.DB, s, 2, "timeout", retryAt, false, now)
+	require.NoError(t, err)
+	assert.True(t, recorded)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/cron"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Report subscription frequencies
+const (
+	SubscriptionDaily   = "daily"
+	SubscriptionWeekly  = "weekly"  // Mondays
+	SubscriptionMonthly = "monthly" // The 1st of the month
+)
+
+// SubscriptionDeliveryHour is the hour of day report subscriptions are delivered, on the clock of
+// the subscription's time zone
+const SubscriptionDeliveryHour = 7
+
+// subscriptionSchedules are the delivery schedules of each frequency
+var subscriptionSchedules = map[string]*cron.Schedule{
+	SubscriptionDaily:   cron.MustParse(fmt.Sprintf("0 %d * * *", SubscriptionDeliveryHour)),
+	SubscriptionWeekly:  cron.MustParse(fmt.Sprintf("0 %d * * MON", SubscriptionDeliveryHour)),
+	SubscriptionMonthly: cron.MustParse(fmt.Sprintf("0 %d 1 * *", SubscriptionDeliveryHour)),
+}
+
+// SubscriptionFormats are the output formats a subscription can be delivered in
+var SubscriptionFormats = []string{"pdf", "csv", "excel"}
+
+// Report subscription errors
+var (
+	ErrInvalidSubscription   = errors.New("frequency must be daily, weekly or monthly and format pdf, csv or excel")
+	ErrDuplicateSubscription = errors.New("already subscribed to this report in this format")
+)
+
+// ReportSubscription delivers a report's output to a user by email on their own frequency
+type ReportSubscription struct {
+	ID           int          `json:"id"`
+	ReportID     int          `json:"report_id"`
+	UserID       int          `json:"user_id"`
+	OutputFormat string       `json:"format"`
+	Frequency    string       `json:"frequency"`
+	IsActive     bool         `json:"is_active"`
+	NextRun      time.Time    `json:"next_run"`
+	LastRun      sql.NullTime `json:"last_run,omitempty"`
+
+	// Failed attempts of the current run and the most recent failure
+	RetryCount   int            `json:"retry_count"`
+	LastError    sql.NullString `json:"last_error,omitempty"`
+	LastFailedAt sql.NullTime   `json:"last_failed_at,omitempty"`
+
+	// The subscription's own time zone, and the one it is delivered in: its own, else its
+	// subscriber's organization's, else UTC
+	Timezone          sql.NullString `json:"timezone,omitempty"`
+	EffectiveTimezone string         `json:"effective_timezone"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Set when listing a report's subscribers
+	Email string `json:"email,omitempty"`
+}
+
+// Validate checks the subscription's frequency and format
+func (s *ReportSubscription) Validate() error {
+	switch s.Frequency {
+	case SubscriptionDaily, SubscriptionWeekly, SubscriptionMonthly:
+	default:
+		return ErrInvalidSubscription
+	}
+	if err := ValidateTimezone(s.Timezone.String); err != nil {
+		return err
+	}
+	if !validSubscriptionFormat(s.OutputFormat) {
+		return ErrInvalidSubscription
+	}
+	return nil
+}
+
+// validSubscriptionFormat reports whether format is one of SubscriptionFormats
+func validSubscriptionFormat(format string) bool {
+	for _, f := range SubscriptionFormats {
+		if format == f {
+			return true
+		}
+	}
+	return false
+}
+
+// NextSubscriptionRun returns the first delivery time for a frequency strictly after t, on loc's
+// wall clock. A nil loc means UTC.
+func NextSubscriptionRun(frequency string, t time.Time, loc *time.Location) time.Time {
+	schedule, ok := subscriptionSchedules[frequency]
+	if !ok {
+		schedule = subscriptionSchedules[SubscriptionDaily]
+	}
+	return schedule.Next(t, loc).UTC()
+}
+
+// Location returns the time zone the subscription is delivered in
+func (s *ReportSubscription) Location() *time.Location {
+	return LoadTimezone(s.EffectiveTimezone)
+}
+
+// CanSubscribeToReport reports whether a user may subscribe to a report: its owner, admins and,
+// for public reports, anyone
+func CanSubscribeToReport(report *CustomReport, user *User) bool {
+	return report.IsPublic || report.CreatedBy == user.ID || user.HasRole("admin")
+}
+
+const reportSubscriptionColumns = `s.id, s.report_id, s.user_id, s.output_format, s.frequency, s.is_active,
+	s.next_run, s.last_run, s.retry_count, s.last_error, s.last_failed_at, s.timezone,
+	COALESCE(s.timezone, o.timezone, ''), s.created_at, s.updated_at, u.email`
+
+// reportSubscriptionTables joins the subscriber and their organization, whose time zone applies
+// when the subscription has none. Users not yet assigned one belong to the default organization.
+var reportSubscriptionTables = fmt.Sprintf(`report_subscriptions s
+		JOIN users u ON u.id = s.user_id
+		LEFT JOIN organizations o ON o.id = COALESCE(u.organization_id, %d)`, DefaultOrganizationID)
+
+func scanReportSubscription(row interface{ Scan(...interface{}) error }) (*ReportSubscription, error) {
+	s := &ReportSubscription{}
+	err := row.Scan(&s.ID, &s.ReportID, &s.UserID, &s.OutputFormat, &s.Frequency, &s.IsActive,
+		&s.NextRun, &s.LastRun, &s.RetryCount, &s.LastError, &s.LastFailedAt, &s.Timezone,
+		&s.EffectiveTimezone, &s.CreatedAt, &s.UpdatedAt, &s.Email)
+	return s, err
+}
+
+func queryReportSubscriptions(where string, args ...interface{}) ([]ReportSubscription, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT `+reportSubscriptionColumns+`
+		FROM `+reportSubscriptionTables+`
+		WHERE `+where+`
+		ORDER BY s.report_id, s.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []ReportSubscription
+	for rows.Next() {
+		s, err := scanReportSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *s)
+	}
+	return subscriptions, rows.Err()
+}
+
+// CreateReportSubscription subscribes a user to a report, first delivered at the next run of its
+// frequency after now in its time zone
+func CreateReportSubscription(s *ReportSubscription, now time.Time) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	var existing int
+	err := db.DB.QueryRow(`
+		SELECT COUNT(*) FROM report_subscriptions
+		WHERE report_id = $1 AND user_id = $2 AND output_format = $3`,
+		s.ReportID, s.UserID, s.OutputFormat).Scan(&existing)
+	if err != nil {
+		return err
+	}
+	if existing > 0 {
+		return ErrDuplicateSubscription
+	}
+
+	s.EffectiveTimezone = s.Timezone.String
+	if !s.Timezone.Valid {
+		if s.EffectiveTimezone, err = GetUserOrganizationTimezone(s.UserID); err != nil {
+			return err
+		}
+	}
+
+	s.IsActive = true
+	s.NextRun = NextSubscriptionRun(s.Frequency, now, s.Location())
+	return db.DB.QueryRow(`
+		INSERT INTO report_subscriptions (report_id, user_id, output_format, frequency, timezone, next_run)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`,
+		s.ReportID, s.UserID, s.OutputFormat, s.Frequency, s.Timezone, s.NextRun).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// GetReportSubscription retrieves a subscription by ID
+func GetReportSubscription(id int) (*ReportSubscription, error) {
+	return scanReportSubscription(db.ReadDB().QueryRow(`
+		SELECT `+reportSubscriptionColumns+`
+		FROM `+reportSubscriptionTables+`
+		WHERE s.id = $1`, id))
+}
+
+// GetReportSubscriptions lists a report's subscriptions
+func GetReportSubscriptions(reportID int) ([]ReportSubscription, error) {
+	return queryReportSubscriptions("s.report_id = $1", reportID)
+}
+
+// GetUserReportSubscriptions lists the subscriptions of a user across reports
+func GetUserReportSubscriptions(userID int) ([]ReportSubscription, error) {
+	return queryReportSubscriptions("s.user_id = $1", userID)
+}
+
+// GetDueReportSubscriptions lists the active subscriptions whose next run is at or before now
+func GetDueReportSubscriptions(now time.Time) ([]ReportSubscription, error) {
+	return queryReportSubscriptions("s.is_active = true AND s.next_run <= $1", now)
+}
+
+// UpdateReportSubscription changes a subscription's format, frequency, time zone and active flag.
+// Changing the frequency or time zone or resuming a subscription reschedules it from now, dropping
+// pending retries.
+func UpdateReportSubscription(s *ReportSubscription, now time.Time) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	existing, err := GetReportSubscription(s.ID)
+	if err != nil {
+		return err
+	}
+	s.NextRun, s.RetryCount = existing.NextRun, existing.RetryCount
+	s.EffectiveTimezone = existing.EffectiveTimezone
+	if s.Timezone != existing.Timezone {
+		s.EffectiveTimezone = s.Timezone.String
+		if !s.Timezone.Valid {
+			if s.EffectiveTimezone, err = GetUserOrganizationTimezone(existing.UserID); err != nil {
+				return err
+			}
+		}
+	}
+	if s.Frequency != existing.Frequency || s.Timezone != existing.Timezone || (s.IsActive && !existing.IsActive) {
+		s.NextRun, s.RetryCount = NextSubscriptionRun(s.Frequency, now, s.Location()), 0
+	}
+
+	result, err := db.DB.Exec(`
+		UPDATE report_subscriptions
+		SET output_format = $1, frequency = $2, is_active = $3, timezone = $4, next_run = $5,
+			retry_count = $6, updated_at = NOW()
+		WHERE id = $7`,
+		s.OutputFormat, s.Frequency, s.IsActive, s.Timezone, s.NextRun, s.RetryCount, s.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteReportSubscription removes a subscription
+func DeleteReportSubscription(id int) error {
+	result, err := db.DB.Exec("DELETE FROM report_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// ClaimReportSubscriptionRun advances a due subscription to its next run, clearing its retries, and
+// reports whether this caller claimed it, so a run is delivered once even with several servers.
+// Pass the transaction that queues the delivery so the claim is undone if delivery fails.
+func ClaimReportSubscriptionRun(q Querier, s *ReportSubscription, now time.Time) (bool, error) {
+	result, err := q.Exec(`
+		UPDATE report_subscriptions
+		SET last_run = $1, next_run = $2, retry_count = 0, updated_at = NOW()
+		WHERE id = $3 AND next_run = $4 AND is_active = true`,
+		now, NextSubscriptionRun(s.Frequency, now, s.Location()), s.ID, s.NextRun)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
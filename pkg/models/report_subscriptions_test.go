@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextSubscriptionRun(t *testing.T) {
+	// Friday 16 October 2026
+	morning := time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC)
+	afternoon := time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC), NextSubscriptionRun(SubscriptionDaily, morning, nil))
+	assert.Equal(t, time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC), NextSubscriptionRun(SubscriptionDaily, afternoon, nil))
+	assert.Equal(t, time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC), NextSubscriptionRun(SubscriptionWeekly, afternoon, nil))
+	assert.Equal(t, time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC), NextSubscriptionRun(SubscriptionMonthly, afternoon, nil))
+
+	// A run exactly at the delivery time schedules the following one
+	monday := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 10, 26, 7, 0, 0, 0, time.UTC), NextSubscriptionRun(SubscriptionWeekly, monday, nil))
+	first := time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 12, 1, 7, 0, 0, 0, time.UTC), NextSubscriptionRun(SubscriptionMonthly, first, nil))
+
+	// Deliveries are at 07:00 local time, whatever the offset is that day
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC), NextSubscriptionRun(SubscriptionMonthly, afternoon, newYork))
+	assert.Equal(t, time.Date(2026, 10, 31, 11, 0, 0, 0, time.UTC),
+		NextSubscriptionRun(SubscriptionDaily, time.Date(2026, 10, 30, 12, 0, 0, 0, time.UTC), newYork))
+	assert.Equal(t, time.Date(2026, 11, 2, 12, 0, 0, 0, time.UTC),
+		NextSubscriptionRun(SubscriptionDaily, time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC), newYork))
+}
+
+func TestReportSubscriptionValidate(t *testing.T) {
+	assert.NoError(t, (&ReportSubscription{Frequency: SubscriptionWeekly, OutputFormat: "csv"}).Validate())
+	assert.Equal(t, ErrInvalidSubscription, (&ReportSubscription{Frequency: "hourly", OutputFormat: "csv"}).Validate())
+	assert.Equal(t, ErrInvalidSubscription, (&ReportSubscription{Frequency: SubscriptionDaily, OutputFormat: "json"}).Validate())
+	assert.Equal(t, ErrInvalidTimezone, (&ReportSubscription{Frequency: SubscriptionDaily, OutputFormat: "csv",
+		Timezone: NullString("Mars/Olympus_Mons")}).Validate())
+}
+
+func TestCanSubscribeToReport(t *testing.T) {
+	report := &CustomReport{CreatedBy: 1}
+	assert.True(t, CanSubscribeToReport(report, &User{ID: 1}))
+	assert.True(t, CanSubscribeToReport(report, &User{ID: 2, Roles: []Role{{Name: "admin"}}}))
+	assert.False(t, CanSubscribeToReport(report, &User{ID: 2}))
+
+	report.IsPublic = true
+	assert.True(t, CanSubscribeToReport(report, &User{ID: 2}))
+}
+
+func TestCreateReportSubscriptionRejectsDuplicate(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM report_subscriptions`).
+		WithArgs(4, 9, "pdf").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	err := CreateReportSubscription(&ReportSubscription{ReportID: 4, UserID: 9, OutputFormat: "pdf",
+		Frequency: SubscriptionDaily}, time.Now())
+	assert.Equal(t, ErrDuplicateSubscription, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimReportSubscriptionRun(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 19, 7, 5, 0, 0, time.UTC)
+	s := &ReportSubscription{ID: 3, Frequency: SubscriptionWeekly, NextRun: time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)}
+
+	mock.ExpectExec(`UPDATE report_subscriptions`).
+		WithArgs(now, time.Date(2026, 10, 26, 7, 0, 0, 0, time.UTC), 3, s.NextRun).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	claimed, err := ClaimReportSubscriptionRun(db.DB, s, now)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Another server already advanced the run
+	mock.ExpectExec(`UPDATE report_subscriptions`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	claimed, err = ClaimReportSubscriptionRun(db.DB, s, now)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
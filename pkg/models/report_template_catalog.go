@@ -0,0 +1,422 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Report template review statuses. System templates and reviewed submissions are published;
+// only published templates are listed in the catalog and can be installed.
+const (
+	ReportTemplatePending   = "pending"
+	ReportTemplatePublished = "published"
+	ReportTemplateRejected  = "rejected"
+)
+
+// Audit actions recorded for the template catalog
+const (
+	AuditReportTemplateSubmitted = "report_template.submitted"
+	AuditReportTemplatePublished = "report_template.published"
+	AuditReportTemplateRejected  = "report_template.rejected"
+)
+
+var (
+	// ErrInvalidTemplateSort is returned for a catalog sort order that is not supported
+	ErrInvalidTemplateSort = errors.New("sort must be one of: popular, name, newest")
+	// ErrTemplateNotInstallable is returned when a template does not name a supported report type
+	ErrTemplateNotInstallable = errors.New("template does not define a supported report type")
+	// ErrTemplateReviewed is returned when reviewing a template that is no longer pending
+	ErrTemplateReviewed = errors.New("template has already been reviewed")
+	// ErrTemplateSubmissionPending is returned when a report already has a submission awaiting review
+	ErrTemplateSubmissionPending = errors.New("report already has a template awaiting review")
+)
+
+// reportTemplateSorts maps catalog sort names to ORDER BY clauses
+var reportTemplateSorts = map[string]string{
+	"":        "is_system DESC, category, name",
+	"popular": "install_count DESC, name",
+	"name":    "name, id",
+	"newest":  "created_at DESC, id DESC",
+}
+
+// templateDataSources maps the data_source of templates that predate report_type in their
+// config to the report type that reads it
+var templateDataSources = map[string]string{
+	"payments":             "financial",
+	"properties":           "property",
+	"tenants":              "tenant",
+	"maintenance_requests": "maintenance",
+}
+
+// reportTemplateColumns lists the columns scanned by scanReportTemplate, without sample data
+const reportTemplateColumns = `id, name, description, category, template_config, is_system, created_by,
+	screenshot_url, install_count, status, source_report_id, organization_id, reviewed_by, reviewed_at,
+	review_note, created_at, updated_at`
+
+// ReportTemplateFilter selects catalog templates. Status defaults to published; Search matches
+// the name or description.
+type ReportTemplateFilter struct {
+	Category string
+	Search   string
+	Status   string
+	Sort     string // popular, name or newest; system templates first by category when empty
+}
+
+// ReportTemplateCategory is a catalog category with the number of published templates in it
+type ReportTemplateCategory struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+// ReportTemplateSubmission is a user's report offered to the catalog as a template
+type ReportTemplateSubmission struct {
+	Name           string      `json:"name"`
+	Description    string      `json:"description,omitempty"`
+	Category       string      `json:"category"`
+	ScreenshotURL  string      `json:"screenshot_url,omitempty"`
+	SampleData     *ReportData `json:"sample_data,omitempty"`
+	SubmittedBy    int         `json:"-"`
+	OrganizationID int         `json:"-"`
+}
+
+// scanReportTemplate scans reportTemplateColumns, followed by any extra columns the query selects
+func scanReportTemplate(row interface{ Scan(...interface{}) error }, t *ReportTemplate, extra ...interface{}) error {
+	var configJSON []byte
+	dest := []interface{}{&t.ID, &t.Name, &t.Description, &t.Category, &configJSON, &t.IsSystem, &t.CreatedBy,
+		&t.ScreenshotURL, &t.InstallCount, &t.Status, &t.SourceReportID, &t.OrganizationID, &t.ReviewedBy,
+		&t.ReviewedAt, &t.ReviewNote, &t.CreatedAt, &t.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	return json.Unmarshal(configJSON, &t.TemplateConfig)
+}
+
+// SearchReportTemplates lists the templates matching a filter
+func SearchReportTemplates(filter ReportTemplateFilter) ([]ReportTemplate, error) {
+	orderBy, ok := reportTemplateSorts[filter.Sort]
+	if !ok {
+		return nil, ErrInvalidTemplateSort
+	}
+	if filter.Status == "" {
+		filter.Status = ReportTemplatePublished
+	}
+
+	args := []interface{}{filter.Status}
+	query := "SELECT " + reportTemplateColumns + " FROM report_templates WHERE status = $1"
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		query += fmt.Sprintf(" AND category = $%d", len(args))
+	}
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		args = append(args, "%"+strings.ToLower(search)+"%")
+		query += fmt.Sprintf(" AND (LOWER(name) LIKE $%d OR LOWER(COALESCE(description, '')) LIKE $%d)", len(args), len(args))
+	}
+	query += " ORDER BY " + orderBy
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []ReportTemplate{}
+	for rows.Next() {
+		var t ReportTemplate
+		if err := scanReportTemplate(rows, &t); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// GetReportTemplateCategories lists the categories of published templates with their sizes
+func GetReportTemplateCategories() ([]ReportTemplateCategory, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT category, COUNT(*) FROM report_templates
+		WHERE status = $1
+		GROUP BY category ORDER BY category`, ReportTemplatePublished)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []ReportTemplateCategory{}
+	for rows.Next() {
+		var c ReportTemplateCategory
+		if err := rows.Scan(&c.Category, &c.Count); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// GetReportTemplate returns a template with its sample data, whatever its status
+func GetReportTemplate(id int) (*ReportTemplate, error) {
+	return getReportTemplate(db.DB, id)
+}
+
+func getReportTemplate(q Querier, id int) (*ReportTemplate, error) {
+	var t ReportTemplate
+	var sample []byte
+	row := q.QueryRow("SELECT "+reportTemplateColumns+", sample_data FROM report_templates WHERE id = $1", id)
+	if err := scanReportTemplate(row, &t, &sample); err != nil {
+		return nil, err
+	}
+	if len(sample) > 0 {
+		t.SampleData = &ReportData{}
+		if err := json.Unmarshal(sample, t.SampleData); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// ReportDefinition fills in a report from the template's config: its report type, criteria,
+// columns and chart config. Templates that only name a data source use the report type that
+// reads it.
+func (t *ReportTemplate) ReportDefinition(report *CustomReport) error {
+	reportType, _ := t.TemplateConfig["report_type"].(string)
+	if reportType == "" {
+		source, _ := t.TemplateConfig["data_source"].(string)
+		reportType = templateDataSources[source]
+	}
+	if reportType == "" || !ValidReportType(reportType) {
+		return ErrTemplateNotInstallable
+	}
+	report.ReportType = reportType
+
+	if criteria, ok := t.TemplateConfig["criteria"].(map[string]interface{}); ok {
+		report.Criteria = criteria
+	} else {
+		report.Criteria = map[string]interface{}{}
+	}
+	report.Columns = nil
+	if columns, ok := t.TemplateConfig["columns"].([]interface{}); ok {
+		for _, c := range columns {
+			if name, ok := c.(string); ok {
+				report.Columns = append(report.Columns, name)
+			}
+		}
+	}
+	if chart, ok := t.TemplateConfig["chart_config"].(map[string]interface{}); ok {
+		report.ChartConfig = chart
+	}
+	return nil
+}
+
+// InstallReportTemplate creates a report for report.CreatedBy from a published template and
+// counts the install. The caller sets the report's name, description and owner; the template
+// supplies its definition. It returns sql.ErrNoRows when the template is not published.
+func InstallReportTemplate(templateID int, report *CustomReport) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	template, err := getReportTemplate(tx, templateID)
+	if err != nil {
+		return err
+	}
+	if template.Status != ReportTemplatePublished {
+		return sql.ErrNoRows
+	}
+	if err := template.ReportDefinition(report); err != nil {
+		return err
+	}
+	if report.Name == "" {
+		report.Name = template.Name
+	}
+	if !report.Description.Valid {
+		report.Description = template.Description
+	}
+
+	if err := insertCustomReport(tx, report); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE report_templates SET install_count = install_count + 1 WHERE id = $1", templateID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SubmitReportTemplate offers a report's definition to the catalog. The template is pending
+// until an admin publishes it, and admins are emailed about it. Only the definition is copied:
+// schedules and confidentiality stay with the report, and previews use the submitted screenshot
+// and sample data rather than the report's output, which holds its organization's data.
+func SubmitReportTemplate(report *CustomReport, sub ReportTemplateSubmission) (*ReportTemplate, error) {
+	config := map[string]interface{}{"report_type": report.ReportType}
+	if len(report.Criteria) > 0 {
+		config["criteria"] = report.Criteria
+	}
+	if len(report.Columns) > 0 {
+		config["columns"] = report.Columns
+	}
+	if len(report.ChartConfig) > 0 {
+		config["chart_config"] = report.ChartConfig
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var sampleJSON interface{}
+	if sub.SampleData != nil {
+		if sampleJSON, err = json.Marshal(sub.SampleData); err != nil {
+			return nil, err
+		}
+	}
+	var organizationID sql.NullInt32
+	if sub.OrganizationID != 0 {
+		organizationID = sql.NullInt32{Int32: int32(sub.OrganizationID), Valid: true}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var pending int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM report_templates WHERE source_report_id = $1 AND status = $2",
+		report.ID, ReportTemplatePending).Scan(&pending); err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, ErrTemplateSubmissionPending
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO report_templates (name, description, category, template_config, is_system, created_by,
+		                              screenshot_url, sample_data, status, source_report_id, organization_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`,
+		sub.Name, NullString(sub.Description), sub.Category, configJSON, false, sub.SubmittedBy,
+		NullString(sub.ScreenshotURL), sampleJSON, ReportTemplatePending, report.ID, organizationID).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    sql.NullInt32{Int32: int32(sub.SubmittedBy), Valid: true},
+		Action:     AuditReportTemplateSubmitted,
+		EntityType: "report_template",
+		EntityID:   id,
+		Details:    map[string]interface{}{"report_id": report.ID, "name": sub.Name, "category": sub.Category},
+	}); err != nil {
+		return nil, err
+	}
+
+	admins, err := adminEmails(tx, sub.SubmittedBy)
+	if err != nil {
+		return nil, err
+	}
+	for _, email := range admins {
+		if err := EnqueueOutboxMessage(tx, &OutboxMessage{
+			Channel:     "email",
+			Destination: email,
+			EventType:   AuditReportTemplateSubmitted,
+			Payload: map[string]interface{}{
+				"subject":     "Report template awaiting review: " + sub.Name,
+				"body":        fmt.Sprintf("A report has been submitted to the template catalog as %q in %s and is waiting for review.\n", sub.Name, sub.Category),
+				"template_id": id,
+			},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	template, err := getReportTemplate(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// ReviewReportTemplate publishes or rejects a pending template, audits the decision and tells
+// the submitter. It returns ErrTemplateReviewed if the template is not pending.
+func ReviewReportTemplate(id, reviewerID int, publish bool, note string, now time.Time) (*ReportTemplate, error) {
+	status, action := ReportTemplateRejected, AuditReportTemplateRejected
+	if publish {
+		status, action = ReportTemplatePublished, AuditReportTemplatePublished
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE report_templates
+		SET status = $1, reviewed_by = $2, reviewed_at = $3, review_note = $4, updated_at = $3
+		WHERE id = $5 AND status = $6`,
+		status, reviewerID, now, NullString(note), id, ReportTemplatePending)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		// Distinguish a missing template from one that was already reviewed
+		if _, err := getReportTemplate(tx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrTemplateReviewed
+	}
+
+	template, err := getReportTemplate(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    sql.NullInt32{Int32: int32(reviewerID), Valid: true},
+		Action:     action,
+		EntityType: "report_template",
+		EntityID:   id,
+		Reason:     NullString(note),
+	}); err != nil {
+		return nil, err
+	}
+
+	if template.CreatedBy.Valid && int(template.CreatedBy.Int32) != reviewerID {
+		var email string
+		err := tx.QueryRow("SELECT email FROM users WHERE id = $1", template.CreatedBy.Int32).Scan(&email)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil {
+			body := fmt.Sprintf("Your report template %q was %s.\n", template.Name, status)
+			if note != "" {
+				body += "\nNote from the reviewer: " + note + "\n"
+			}
+			if err := EnqueueOutboxMessage(tx, &OutboxMessage{
+				Channel:     "email",
+				Destination: email,
+				EventType:   action,
+				Payload: map[string]interface{}{
+					"subject":     fmt.Sprintf("Report template %s: %s", status, template.Name),
+					"body":        body,
+					"template_id": id,
+				},
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
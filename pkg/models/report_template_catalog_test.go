@@ -0,0 +1,222 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var reportTemplateTestColumns = []string{"id", "name", "description", "category", "template_config", "is_system",
+	"created_by", "screenshot_url", "install_count", "status", "source_report_id", "organization_id", "reviewed_by",
+	"reviewed_at", "review_note", "created_at", "updated_at"}
+
+// reportTemplateRow returns a single-template result set including sample data
+func reportTemplateRow(id int, status, config string, createdBy interface{}) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows(append(reportTemplateTestColumns, "sample_data")).
+		AddRow(id, "Rent Roll", "Active leases", "financial", config, false, createdBy,
+			"https://cdn.example.com/rent-roll.png", 3, status, 12, 1, nil, nil, nil, now, now,
+			[]byte(`{"headers":["Unit"],"rows":[{"Unit":"101"}]}`))
+}
+
+func TestSearchReportTemplatesFilters(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`FROM report_templates WHERE status = \$1 AND category = \$2 `+
+		`AND \(LOWER\(name\) LIKE \$3 OR LOWER\(COALESCE\(description, ''\)\) LIKE \$3\) ORDER BY install_count DESC`).
+		WithArgs(ReportTemplatePending, "financial", "%rent roll%").
+		WillReturnRows(sqlmock.NewRows(reportTemplateTestColumns))
+
+	templates, err := SearchReportTemplates(ReportTemplateFilter{
+		Category: "financial", Search: " Rent Roll ", Status: ReportTemplatePending, Sort: "popular",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, templates)
+	assert.NotNil(t, templates, "an empty catalog encodes as []")
+
+	_, err = SearchReportTemplates(ReportTemplateFilter{Sort: "random"})
+	assert.Equal(t, ErrInvalidTemplateSort, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetReportTemplateCategories(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT category, COUNT\(\*\) FROM report_templates`).WithArgs(ReportTemplatePublished).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "count"}).AddRow("financial", 3).AddRow("operational", 4))
+
+	categories, err := GetReportTemplateCategories()
+	require.NoError(t, err)
+	assert.Equal(t, []ReportTemplateCategory{{"financial", 3}, {"operational", 4}}, categories)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportTemplateReportDefinition(t *testing.T) {
+	template := &ReportTemplate{TemplateConfig: map[string]interface{}{
+		"report_type":  "rent_roll",
+		"criteria":     map[string]interface{}{"status": "active"},
+		"columns":      []interface{}{"Unit", "Rent"},
+		"chart_config": map[string]interface{}{"type": "bar"},
+	}}
+	var report CustomReport
+	require.NoError(t, template.ReportDefinition(&report))
+	assert.Equal(t, "rent_roll", report.ReportType)
+	assert.Equal(t, map[string]interface{}{"status": "active"}, report.Criteria)
+	assert.Equal(t, StringArray{"Unit", "Rent"}, report.Columns)
+	assert.Equal(t, map[string]interface{}{"type": "bar"}, report.ChartConfig)
+
+	// Older system templates only name their data source
+	legacy := &ReportTemplate{TemplateConfig: map[string]interface{}{"data_source": "payments"}}
+	require.NoError(t, legacy.ReportDefinition(&report))
+	assert.Equal(t, "financial", report.ReportType)
+	assert.Nil(t, report.Columns)
+
+	multi := &ReportTemplate{TemplateConfig: map[string]interface{}{"data_source": "multi"}}
+	assert.Equal(t, ErrTemplateNotInstallable, multi.ReportDefinition(&report))
+}
+
+func TestInstallReportTemplate(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+), sample_data FROM report_templates WHERE id = \$1`).WithArgs(5).
+		WillReturnRows(reportTemplateRow(5, ReportTemplatePublished, `{"report_type": "rent_roll"}`, nil))
+	mock.ExpectQuery(`INSERT INTO custom_reports`).
+		WithArgs("Rent Roll", NullString("Active leases"), "rent_roll", 7, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), false, false, sqlmock.AnyArg(), sqlmock.AnyArg(), "pdf", ConfidentialityNone).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(40, time.Now(), time.Now()))
+	mock.ExpectExec(`UPDATE report_templates SET install_count = install_count \+ 1 WHERE id = \$1`).WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	report := CustomReport{CreatedBy: 7}
+	require.NoError(t, InstallReportTemplate(5, &report))
+	assert.Equal(t, 40, report.ID)
+	assert.Equal(t, "rent_roll", report.ReportType)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInstallReportTemplateRequiresPublished(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM report_templates WHERE id = \$1`).WithArgs(5).
+		WillReturnRows(reportTemplateRow(5, ReportTemplatePending, `{"report_type": "rent_roll"}`, 9))
+	mock.ExpectRollback()
+
+	err := InstallReportTemplate(5, &CustomReport{CreatedBy: 7})
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubmitReportTemplate(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	submitter := sql.NullInt32{Int32: 9, Valid: true}
+	report := &CustomReport{ID: 12, Name: "My Rent Roll", ReportType: "rent_roll", CreatedBy: 9,
+		Columns: StringArray{"Unit"}, IsScheduled: true, ScheduleCron: NullString("0 6 * * 1")}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM report_templates WHERE source_report_id = \$1`).
+		WithArgs(12, ReportTemplatePending).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO report_templates`).
+		WithArgs("Rent Roll", NullString("Active leases"), "financial", []byte(`{"columns":["Unit"],"report_type":"rent_roll"}`),
+			false, 9, NullString(""), nil, ReportTemplatePending, 12, sql.NullInt32{Int32: 1, Valid: true}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(submitter, AuditReportTemplateSubmitted, "report_template", 5, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectQuery(`SELECT DISTINCT u.email`).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("admin@example.com"))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", AuditReportTemplateSubmitted, "admin@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, time.Now(), time.Now(), time.Now()))
+	mock.ExpectQuery(`FROM report_templates WHERE id = \$1`).WithArgs(5).
+		WillReturnRows(reportTemplateRow(5, ReportTemplatePending, `{"report_type": "rent_roll"}`, 9))
+	mock.ExpectCommit()
+
+	template, err := SubmitReportTemplate(report, ReportTemplateSubmission{
+		Name: "Rent Roll", Description: "Active leases", Category: "financial", SubmittedBy: 9, OrganizationID: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ReportTemplatePending, template.Status)
+	assert.Equal(t, []string{"Unit"}, template.SampleData.Headers)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubmitReportTemplateAlreadyPending(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM report_templates`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	_, err := SubmitReportTemplate(&CustomReport{ID: 12, ReportType: "rent_roll"},
+		ReportTemplateSubmission{Name: "Rent Roll", Category: "financial", SubmittedBy: 9})
+	assert.Equal(t, ErrTemplateSubmissionPending, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReviewReportTemplate(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE report_templates\s+SET status = \$1`).
+		WithArgs(ReportTemplatePublished, 1, now, NullString("Nice work"), 5, ReportTemplatePending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM report_templates WHERE id = \$1`).WithArgs(5).
+		WillReturnRows(reportTemplateRow(5, ReportTemplatePublished, `{"report_type": "rent_roll"}`, 9))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 1, Valid: true}, AuditReportTemplatePublished, "report_template", 5,
+			NullString("Nice work"), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	mock.ExpectQuery(`SELECT email FROM users WHERE id = \$1`).WithArgs(int32(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("jane@example.com"))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", AuditReportTemplatePublished, "jane@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	template, err := ReviewReportTemplate(5, 1, true, "Nice work", now)
+	require.NoError(t, err)
+	assert.Equal(t, ReportTemplatePublished, template.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReviewReportTemplateAlreadyReviewed(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE report_templates`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FROM report_templates WHERE id = \$1`).WithArgs(5).
+		WillReturnRows(reportTemplateRow(5, ReportTemplateRejected, `{}`, 9))
+	mock.ExpectRollback()
+
+	_, err := ReviewReportTemplate(5, 1, true, "", time.Now())
+	assert.Equal(t, ErrTemplateReviewed, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE report_templates`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FROM report_templates WHERE id = \$1`).WithArgs(6).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err = ReviewReportTemplate(6, 1, false, "", time.Now())
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,30 +4,34 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"math"
 	"strings"
 	"time"
 
+	"github.com/greenbrown932/fire-pmaas/pkg/cron"
 	"github.com/greenbrown932/fire-pmaas/pkg/db"
-	"github.com/lib/pq"
 )
 
 // CustomReport represents a user-defined report configuration
 type CustomReport struct {
-	ID            int                    `json:"id"`
-	Name          string                 `json:"name"`
-	Description   sql.NullString         `json:"description,omitempty"`
-	ReportType    string                 `json:"report_type"`
-	CreatedBy     int                    `json:"created_by"`
-	Criteria      map[string]interface{} `json:"criteria"`
-	Columns       StringArray            `json:"columns"`
-	ChartConfig   map[string]interface{} `json:"chart_config,omitempty"`
-	IsPublic      bool                   `json:"is_public"`
-	IsScheduled   bool                   `json:"is_scheduled"`
-	ScheduleCron  sql.NullString         `json:"schedule_cron,omitempty"`
-	LastGenerated sql.NullTime           `json:"last_generated,omitempty"`
-	CreatedAt     time.Time              `json:"created_at"`
-	UpdatedAt     time.Time              `json:"updated_at"`
+	ID               int                    `json:"id"`
+	Name             string                 `json:"name"`
+	Description      sql.NullString         `json:"description,omitempty"`
+	ReportType       string                 `json:"report_type"`
+	CreatedBy        int                    `json:"created_by"`
+	Criteria         map[string]interface{} `json:"criteria"`
+	Columns          StringArray            `json:"columns"`
+	ChartConfig      map[string]interface{} `json:"chart_config,omitempty"`
+	IsPublic         bool                   `json:"is_public"`
+	IsScheduled      bool                   `json:"is_scheduled"`
+	ScheduleCron     sql.NullString         `json:"schedule_cron,omitempty"`
+	ScheduleTimezone sql.NullString         `json:"schedule_timezone,omitempty"` // Zone ScheduleCron runs in; the creator's organization's when not set
+	ScheduleFormat   string                 `json:"schedule_format,omitempty"`   // Format scheduled runs are stored in
+	Confidentiality  string                 `json:"confidentiality,omitempty"`   // Level exports are watermarked with
+	LastGenerated    sql.NullTime           `json:"last_generated,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
 }
 
 // ReportExecution represents a report execution instance
@@ -43,6 +47,7 @@ type ReportExecution struct {
 	ExecutionDurationMs sql.NullInt32          `json:"execution_duration_ms,omitempty"`
 	ErrorMessage        sql.NullString         `json:"error_message,omitempty"`
 	Parameters          map[string]interface{} `json:"parameters,omitempty"`
+	Snapshot            *ReportData            `json:"snapshot,omitempty"`
 }
 
 // AnalyticsDashboard represents a custom analytics dashboard
@@ -90,7 +95,8 @@ type KPIMetric struct {
 	CreatedAt         time.Time       `json:"created_at"`
 }
 
-// ReportTemplate represents a predefined report template
+// ReportTemplate represents a predefined report template. Templates submitted from users'
+// reports are listed in the catalog once an admin publishes them.
 type ReportTemplate struct {
 	ID             int                    `json:"id"`
 	Name           string                 `json:"name"`
@@ -99,6 +105,15 @@ type ReportTemplate struct {
 	TemplateConfig map[string]interface{} `json:"template_config"`
 	IsSystem       bool                   `json:"is_system"`
 	CreatedBy      sql.NullInt32          `json:"created_by,omitempty"`
+	ScreenshotURL  sql.NullString         `json:"screenshot_url,omitempty"`
+	SampleData     *ReportData            `json:"sample_data,omitempty"` // Only loaded for a single template
+	InstallCount   int                    `json:"install_count"`
+	Status         string                 `json:"status"`
+	SourceReportID sql.NullInt32          `json:"source_report_id,omitempty"`
+	OrganizationID sql.NullInt32          `json:"organization_id,omitempty"`
+	ReviewedBy     sql.NullInt32          `json:"reviewed_by,omitempty"`
+	ReviewedAt     sql.NullTime           `json:"reviewed_at,omitempty"`
+	ReviewNote     sql.NullString         `json:"review_note,omitempty"`
 	CreatedAt      time.Time              `json:"created_at"`
 	UpdatedAt      time.Time              `json:"updated_at"`
 }
@@ -109,6 +124,12 @@ type ReportData struct {
 	Rows    []map[string]interface{} `json:"rows"`
 	Summary map[string]interface{}   `json:"summary,omitempty"`
 	Charts  []ChartData              `json:"charts,omitempty"`
+	// Columns declares the type and formatting hints of each header, in the same order
+	Columns []ReportColumn `json:"columns,omitempty"`
+	// RedactedColumns lists the columns and summary fields masked for the viewer
+	RedactedColumns []string `json:"redacted_columns,omitempty"`
+	// ComputedColumns maps each computed column to the result columns it is derived from
+	ComputedColumns map[string][]string `json:"computed_columns,omitempty"`
 }
 
 // ChartData represents chart configuration and data
@@ -139,8 +160,39 @@ type ForecastPoint struct {
 
 // Report creation and management functions
 
+// ValidateSchedule checks a scheduled report's cron expression, time zone and output format, which
+// defaults to pdf
+func (r *CustomReport) ValidateSchedule() error {
+	if r.ScheduleFormat == "" {
+		r.ScheduleFormat = "pdf"
+	}
+	if !validSubscriptionFormat(r.ScheduleFormat) {
+		return fmt.Errorf("schedule format must be pdf, csv or excel")
+	}
+	if r.ScheduleCron.Valid && r.ScheduleCron.String != "" {
+		if _, err := cron.Parse(r.ScheduleCron.String); err != nil {
+			return err
+		}
+	} else if r.IsScheduled {
+		return fmt.Errorf("%w: a scheduled report needs a schedule", cron.ErrInvalidExpression)
+	}
+	return ValidateTimezone(r.ScheduleTimezone.String)
+}
+
 // CreateCustomReport creates a new custom report
-func CreateCustomReport(report *CustomReport) error {
+func (sqlRepository) CreateCustomReport(report *CustomReport) error {
+	return insertCustomReport(db.DB, report)
+}
+
+// insertCustomReport writes a new custom report using the given querier
+func insertCustomReport(q Querier, report *CustomReport) error {
+	if report.ScheduleFormat == "" {
+		report.ScheduleFormat = "pdf"
+	}
+	if report.Confidentiality == "" {
+		report.Confidentiality = ConfidentialityNone
+	}
+
 	criteriaJSON, err := json.Marshal(report.Criteria)
 	if err != nil {
 		return err
@@ -153,22 +205,24 @@ func CreateCustomReport(report *CustomReport) error {
 
 	query := `
 		INSERT INTO custom_reports (name, description, report_type, created_by, criteria, columns,
-								  chart_config, is_public, is_scheduled, schedule_cron)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+								  chart_config, is_public, is_scheduled, schedule_cron, schedule_timezone,
+								  schedule_format, confidentiality)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING id, created_at, updated_at`
 
-	return db.DB.QueryRow(query, report.Name, report.Description, report.ReportType,
-		report.CreatedBy, criteriaJSON, pq.Array(report.Columns), chartConfigJSON,
-		report.IsPublic, report.IsScheduled, report.ScheduleCron).
+	return q.QueryRow(query, report.Name, report.Description, report.ReportType,
+		report.CreatedBy, criteriaJSON, report.Columns, chartConfigJSON,
+		report.IsPublic, report.IsScheduled, report.ScheduleCron, report.ScheduleTimezone,
+		report.ScheduleFormat, report.Confidentiality).
 		Scan(&report.ID, &report.CreatedAt, &report.UpdatedAt)
 }
 
 // GetCustomReports retrieves custom reports for a user
-func GetCustomReports(userID int) ([]CustomReport, error) {
+func (sqlRepository) GetCustomReports(userID int) ([]CustomReport, error) {
 	query := `
 		SELECT id, name, description, report_type, created_by, criteria, columns,
-			   chart_config, is_public, is_scheduled, schedule_cron, last_generated,
-			   created_at, updated_at
+			   chart_config, is_public, is_scheduled, schedule_cron, schedule_timezone, schedule_format,
+			   confidentiality, last_generated, created_at, updated_at
 		FROM custom_reports
 		WHERE created_by = $1 OR is_public = true
 		ORDER BY updated_at DESC`
@@ -185,9 +239,9 @@ func GetCustomReports(userID int) ([]CustomReport, error) {
 		var criteriaJSON, chartConfigJSON []byte
 
 		err := rows.Scan(&report.ID, &report.Name, &report.Description, &report.ReportType,
-			&report.CreatedBy, &criteriaJSON, pq.Array(&report.Columns),
+			&report.CreatedBy, &criteriaJSON, &report.Columns,
 			&chartConfigJSON, &report.IsPublic, &report.IsScheduled, &report.ScheduleCron,
-			&report.LastGenerated, &report.CreatedAt, &report.UpdatedAt)
+			&report.ScheduleTimezone, &report.ScheduleFormat, &report.Confidentiality, &report.LastGenerated, &report.CreatedAt, &report.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -209,23 +263,37 @@ func GetCustomReports(userID int) ([]CustomReport, error) {
 	return reports, nil
 }
 
-// ExecuteReport generates report data based on report configuration
-func ExecuteReport(reportID int, parameters map[string]interface{}) (*ReportData, error) {
+// ExecuteReport generates report data based on report configuration, redacting the sensitive
+// columns the viewer lacks permission for
+func ExecuteReport(reportID int, parameters map[string]interface{}, viewer *User) (*ReportData, error) {
+	data, _, err := ExecuteReportRecorded(reportID, parameters, viewer)
+	return data, err
+}
+
+// ExecuteReportRecorded executes a report like ExecuteReport and also returns the recorded
+// execution, whose ID is 0 if it could not be recorded
+func ExecuteReportRecorded(reportID int, parameters map[string]interface{}, viewer *User) (*ReportData, *ReportExecution, error) {
 	// Get report configuration
 	report, err := GetCustomReportByID(reportID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	startTime := time.Now()
 
+	// Limit the report to the requested group and the viewer's managed properties
+	if err := scopeReportProperties(report, parameters, viewer); err != nil {
+		return nil, nil, err
+	}
+
 	// Build and execute query based on report type and criteria
 	data, err := buildAndExecuteReportQuery(report, parameters)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Record execution
+	// Record execution with the full snapshot so executions can be compared by anyone allowed
+	// to see them; the viewer only receives the redacted data
 	execution := &ReportExecution{
 		ReportID:            reportID,
 		ExecutionTime:       startTime,
@@ -234,31 +302,80 @@ func ExecuteReport(reportID int, parameters map[string]interface{}) (*ReportData
 		RowCount:            sql.NullInt32{Int32: int32(len(data.Rows)), Valid: true},
 		ExecutionDurationMs: sql.NullInt32{Int32: int32(time.Since(startTime).Milliseconds()), Valid: true},
 		Parameters:          parameters,
+		Snapshot:            data,
+	}
+	if viewer != nil {
+		execution.ExecutedBy = sql.NullInt32{Int32: int32(viewer.ID), Valid: true}
 	}
 
 	if err := CreateReportExecution(execution); err != nil {
 		// Log error but don't fail the report generation
-		fmt.Printf("Failed to record report execution: %v\n", err)
+		slog.Error("Failed to record report execution", "report_id", reportID, "error", err)
 	}
 
-	return data, nil
+	RedactReportData(data, viewer)
+	return data, execution, nil
+}
+
+// ReportFile is a report rendered in an export format
+type ReportFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// PreviewRowLimit is how many rows a report preview returns
+const PreviewRowLimit = 50
+
+// ReportPreview is the first rows of an unsaved report definition's result with the total row count
+type ReportPreview struct {
+	*ReportData
+	TotalRows int  `json:"total_rows"`
+	Truncated bool `json:"truncated"` // More rows than PreviewRowLimit were found
+}
+
+// PreviewReport runs a report definition without saving it or recording an execution, so users
+// can iterate on criteria before saving. Summaries and charts cover every row; only the returned
+// rows are cut to PreviewRowLimit.
+func PreviewReport(report *CustomReport, parameters map[string]interface{}, viewer *User) (*ReportPreview, error) {
+	if err := scopeReportProperties(report, parameters, viewer); err != nil {
+		return nil, err
+	}
+	data, err := buildAndExecuteReportQuery(report, parameters)
+	if err != nil {
+		return nil, err
+	}
+	RedactReportData(data, viewer)
+
+	preview := &ReportPreview{ReportData: data, TotalRows: len(data.Rows)}
+	if len(data.Rows) > PreviewRowLimit {
+		data.Rows = data.Rows[:PreviewRowLimit]
+		preview.Truncated = true
+	}
+	return preview, nil
+}
+
+// GetAnalyticsDashboardByID retrieves a specific analytics dashboard
+func GetAnalyticsDashboardByID(id int) (*AnalyticsDashboard, error) {
+	return scanDashboard(db.ReadDB().QueryRow(
+		`SELECT `+dashboardColumns+` FROM analytics_dashboards WHERE id = $1`, id))
 }
 
 // GetCustomReportByID retrieves a specific custom report
-func GetCustomReportByID(id int) (*CustomReport, error) {
+func (sqlRepository) GetCustomReportByID(id int) (*CustomReport, error) {
 	report := &CustomReport{}
 	var criteriaJSON, chartConfigJSON []byte
 
 	query := `
 		SELECT id, name, description, report_type, created_by, criteria, columns,
-			   chart_config, is_public, is_scheduled, schedule_cron, last_generated,
-			   created_at, updated_at
+			   chart_config, is_public, is_scheduled, schedule_cron, schedule_timezone, schedule_format,
+			   confidentiality, last_generated, created_at, updated_at
 		FROM custom_reports WHERE id = $1`
 
 	err := db.DB.QueryRow(query, id).Scan(&report.ID, &report.Name, &report.Description,
-		&report.ReportType, &report.CreatedBy, &criteriaJSON, pq.Array(&report.Columns),
+		&report.ReportType, &report.CreatedBy, &criteriaJSON, &report.Columns,
 		&chartConfigJSON, &report.IsPublic, &report.IsScheduled, &report.ScheduleCron,
-		&report.LastGenerated, &report.CreatedAt, &report.UpdatedAt)
+		&report.ScheduleTimezone, &report.ScheduleFormat, &report.Confidentiality, &report.LastGenerated, &report.CreatedAt, &report.UpdatedAt)
 
 	if err != nil {
 		return nil, err
@@ -285,17 +402,71 @@ func CreateReportExecution(execution *ReportExecution) error {
 		return err
 	}
 
+	// Keep a copy of the output so that later runs can be diffed against it
+	var snapshotJSON []byte
+	if execution.Snapshot != nil {
+		snapshotJSON, err = json.Marshal(execution.Snapshot)
+		if err != nil {
+			return err
+		}
+	}
+
 	query := `
 		INSERT INTO report_executions (report_id, executed_by, execution_time, status,
 									 output_format, file_path, row_count, execution_duration_ms,
-									 error_message, parameters)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+									 error_message, parameters, result_snapshot)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id`
 
 	return db.DB.QueryRow(query, execution.ReportID, execution.ExecutedBy,
 		execution.ExecutionTime, execution.Status, execution.OutputFormat,
 		execution.FilePath, execution.RowCount, execution.ExecutionDurationMs,
-		execution.ErrorMessage, parametersJSON).Scan(&execution.ID)
+		execution.ErrorMessage, parametersJSON, snapshotJSON).Scan(&execution.ID)
+}
+
+// SetReportExecutionFile records the stored file an execution's output was rendered to and its
+// size in bytes
+func SetReportExecutionFile(executionID int, format, filePath string, size int64) error {
+	result, err := db.DB.Exec(`
+		UPDATE report_executions SET output_format = $1, file_path = $2, file_size = $3 WHERE id = $4`,
+		format, filePath, size, executionID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetReportExecutionByID retrieves a report execution including its stored snapshot
+func GetReportExecutionByID(id int) (*ReportExecution, error) {
+	execution := &ReportExecution{}
+	var parametersJSON, snapshotJSON []byte
+
+	query := `
+		SELECT id, report_id, executed_by, execution_time, status, output_format, file_path,
+			   row_count, execution_duration_ms, error_message, parameters, result_snapshot
+		FROM report_executions WHERE id = $1`
+
+	err := db.ReadDB().QueryRow(query, id).Scan(&execution.ID, &execution.ReportID,
+		&execution.ExecutedBy, &execution.ExecutionTime, &execution.Status,
+		&execution.OutputFormat, &execution.FilePath, &execution.RowCount,
+		&execution.ExecutionDurationMs, &execution.ErrorMessage, &parametersJSON, &snapshotJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parametersJSON) > 0 {
+		if err := json.Unmarshal(parametersJSON, &execution.Parameters); err != nil {
+			return nil, err
+		}
+	}
+	if len(snapshotJSON) > 0 {
+		execution.Snapshot = &ReportData{}
+		if err := json.Unmarshal(snapshotJSON, execution.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	return execution, nil
 }
 
 // buildAndExecuteReportQuery builds and executes the appropriate query for a report
@@ -312,22 +483,61 @@ func buildAndExecuteReportQuery(report *CustomReport, parameters map[string]inte
 		data, err = generateTenantReport(report, parameters)
 	case "maintenance":
 		data, err = generateMaintenanceReport(report, parameters)
+	case "vacancy_forecast":
+		data, err = generateVacancyForecastReport(report, parameters)
+	case "portfolio_comparison":
+		data, err = generatePortfolioComparisonReport(report, parameters)
+	case "rent_roll":
+		data, err = generateRentRollReport(report, parameters)
+	case "deposit_compliance":
+		data, err = generateDepositComplianceReport(report, parameters)
+	case "dues_delinquency":
+		data, err = generateDuesDelinquencyReport(report, parameters)
+	case "association_violations":
+		data, err = generateAssociationViolationsReport(report, parameters)
+	case "utility_benchmark":
+		data, err = generateUtilityBenchmarkReport(report, parameters)
+	case "unit_mix":
+		data, err = generateUnitMixReport(report, parameters)
+	case "renewals_funnel":
+		data, err = generateRenewalsFunnelReport(report, parameters)
+	case "collections_status":
+		data, err = generateCollectionsStatusReport(report, parameters)
+	case "renovation_roi":
+		data, err = generateRenovationROIReport(report, parameters)
+	case "turnover":
+		data, err = generateTurnoverReport(report, parameters)
 	default:
-		return nil, fmt.Errorf("unsupported report type: %s", report.ReportType)
+		plugin, ok := getReportPlugin(report.ReportType)
+		if !ok {
+			return nil, fmt.Errorf("unsupported report type: %s", report.ReportType)
+		}
+		data, err = generatePluginReport(plugin, report, parameters)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Add computed columns before charts so they can be charted
+	if err := applyComputedColumns(data, report.Criteria); err != nil {
+		return nil, err
+	}
+	completeReportColumns(data)
+
 	// Generate charts if chart config is provided
 	if report.ChartConfig != nil && len(report.ChartConfig) > 0 {
 		charts, err := generateChartsForReport(data, report.ChartConfig)
 		if err == nil {
-			data.Charts = charts
+			data.Charts = append(data.Charts, charts...)
 		}
 	}
 
+	// Aggregate last so the summary and charts still cover every row
+	if column, ok := parameters[ReportParamGroupBy].(string); ok && column != "" {
+		return AggregateReportData(data, column)
+	}
+
 	return data, nil
 }
 
@@ -351,14 +561,18 @@ func generatePropertyReport(report *CustomReport, parameters map[string]interfac
 
 	// Apply criteria filters
 	if propertyIDs, ok := report.Criteria["property_ids"].([]interface{}); ok && len(propertyIDs) > 0 {
-		argCount++
-		query += fmt.Sprintf(" AND p.id = ANY($%d)", argCount)
-		args = append(args, pq.Array(propertyIDs))
+		placeholders := make([]string, len(propertyIDs))
+		for i, id := range propertyIDs {
+			argCount++
+			placeholders[i] = fmt.Sprintf("$%d", argCount)
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" AND p.id IN (%s)", strings.Join(placeholders, ", "))
 	}
 
 	query += " GROUP BY p.id, p.name, p.address, p.property_type ORDER BY p.name"
 
-	rows, err := db.DB.Query(query, args...)
+	rows, err := db.ReadDB().Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -368,6 +582,10 @@ func generatePropertyReport(report *CustomReport, parameters map[string]interfac
 	data := &ReportData{
 		Headers: headers,
 		Rows:    []map[string]interface{}{},
+		Columns: typedColumns(headers, map[string]ReportColumn{
+			"ID": numberColumn, "Units": numberColumn, "Occupied": numberColumn, "Avg Rent": currencyColumn,
+			"Maintenance Requests": numberColumn,
+		}),
 	}
 
 	for rows.Next() {
@@ -421,18 +639,19 @@ func generateFinancialReport(report *CustomReport, parameters map[string]interfa
 		}
 	}
 
-	query := `
+	monthExpr := db.CurrentDialect.DateTrunc("month", "p.payment_date")
+	query := fmt.Sprintf(`
 		SELECT
-			DATE_TRUNC('month', p.payment_date) as month,
+			%s as month,
 			COUNT(p.id) as payment_count,
 			SUM(p.amount) as total_amount,
 			AVG(p.amount) as avg_amount
 		FROM payments p
 		WHERE p.payment_date BETWEEN $1 AND $2
-		GROUP BY DATE_TRUNC('month', p.payment_date)
-		ORDER BY month`
+		GROUP BY %s
+		ORDER BY month`, monthExpr, monthExpr)
 
-	rows, err := db.DB.Query(query, startDate, endDate)
+	rows, err := db.ReadDB().Query(query, startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
@@ -442,10 +661,14 @@ func generateFinancialReport(report *CustomReport, parameters map[string]interfa
 	data := &ReportData{
 		Headers: headers,
 		Rows:    []map[string]interface{}{},
+		Columns: typedColumns(headers, map[string]ReportColumn{
+			"Month": monthColumn, "Payment Count": numberColumn, "Total Amount": currencyColumn,
+			"Average Amount": currencyColumn,
+		}),
 	}
 
 	for rows.Next() {
-		var month time.Time
+		var month flexibleTime
 		var paymentCount int
 		var totalAmount, avgAmount float64
 
@@ -467,8 +690,27 @@ func generateFinancialReport(report *CustomReport, parameters map[string]interfa
 	// Calculate financial summary
 	if len(data.Rows) > 0 {
 		data.Summary = calculateFinancialSummary(data.Rows)
+	} else {
+		data.Summary = map[string]interface{}{}
 	}
 
+	// Rent charged net of concessions, so promotions show up instead of silently lowering revenue
+	leases, err := loadScheduledLeases(db.ReadDB(), startDate, endDate, nil)
+	if err != nil {
+		return nil, err
+	}
+	schedule := SummarizeRentSchedule(leases, startDate, endDate)
+	data.Summary["scheduled_rent"] = schedule.ScheduledRent
+	data.Summary["concessions"] = schedule.Concessions
+	data.Summary["effective_rent"] = schedule.EffectiveRent
+
+	// Flag figures that may still change because their accounting periods are open
+	unlocked, err := HasUnlockedPeriods(nil, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	data.Summary["includes_unlocked_periods"] = unlocked
+
 	return data, nil
 }
 
@@ -483,7 +725,7 @@ func generateTenantReport(report *CustomReport, parameters map[string]interface{
 		LEFT JOIN properties p ON pu.property_id = p.id
 		ORDER BY t.last_name, t.first_name`
 
-	rows, err := db.DB.Query(query)
+	rows, err := db.ReadDB().Query(query)
 	if err != nil {
 		return nil, err
 	}
@@ -493,6 +735,9 @@ func generateTenantReport(report *CustomReport, parameters map[string]interface{
 	data := &ReportData{
 		Headers: headers,
 		Rows:    []map[string]interface{}{},
+		Columns: typedColumns(headers, map[string]ReportColumn{
+			"ID": numberColumn, "Rent": currencyColumn, "Start Date": dateColumn, "End Date": dateColumn,
+		}),
 	}
 
 	for rows.Next() {
@@ -540,39 +785,45 @@ func generateTenantReport(report *CustomReport, parameters map[string]interface{
 
 // generateMaintenanceReport generates maintenance-related reports
 func generateMaintenanceReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	policies, err := GetMaintenanceSLAPolicies()
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT mr.id, p.name as property_name, mr.description, mr.status, mr.priority,
-			   mr.reported_date, mr.completed_date,
-			   CASE
-				   WHEN mr.completed_date IS NOT NULL THEN
-					   EXTRACT(days FROM mr.completed_date - mr.reported_date)
-				   ELSE NULL
-			   END as resolution_days
+		SELECT mr.id, p.name as property_name, mr.description, mr.status,
+			   COALESCE(mr.priority, 'medium'), mr.reported_date, mr.completed_date,
+			   mr.created_at, mr.responded_at, mr.resolved_at
 		FROM maintenance_requests mr
 		JOIN properties p ON mr.property_id = p.id
 		ORDER BY mr.reported_date DESC`
 
-	rows, err := db.DB.Query(query)
+	rows, err := db.ReadDB().Query(query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	headers := []string{"ID", "Property", "Description", "Status", "Priority", "Reported Date", "Completed Date", "Resolution Days"}
+	headers := []string{"ID", "Property", "Description", "Status", "Priority", "Reported Date", "Completed Date", "Resolution Days", "SLA Status"}
 	data := &ReportData{
 		Headers: headers,
 		Rows:    []map[string]interface{}{},
+		Columns: typedColumns(headers, map[string]ReportColumn{
+			"ID": numberColumn, "Reported Date": dateColumn, "Completed Date": dateColumn,
+			"Resolution Days": numberColumn,
+		}),
 	}
 
+	now := time.Now()
+	slaCounts := map[string]int{}
 	for rows.Next() {
 		var id int
 		var propertyName, description, status, priority string
-		var reportedDate time.Time
-		var completedDate sql.NullTime
-		var resolutionDays sql.NullFloat64
+		var reportedDate, createdAt time.Time
+		var completedDate, respondedAt, resolvedAt sql.NullTime
 
 		err := rows.Scan(&id, &propertyName, &description, &status, &priority,
-			&reportedDate, &completedDate, &resolutionDays)
+			&reportedDate, &completedDate, &createdAt, &respondedAt, &resolvedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -588,14 +839,29 @@ func generateMaintenanceReport(report *CustomReport, parameters map[string]inter
 
 		if completedDate.Valid {
 			row["Completed Date"] = completedDate.Time.Format("2006-01-02")
+			row["Resolution Days"] = int(completedDate.Time.Sub(reportedDate).Hours() / 24)
 		}
-		if resolutionDays.Valid {
-			row["Resolution Days"] = int(resolutionDays.Float64)
+
+		// Requests completed before SLA tracking existed have no resolved_at timestamp
+		if !resolvedAt.Valid && status == "completed" {
+			row["SLA Status"] = ""
+		} else if policy, ok := policies[priority]; ok {
+			sla := ComputeSLAStatus(createdAt, respondedAt, resolvedAt, policy, now)
+			row["SLA Status"] = sla.Status
+			slaCounts[sla.Status]++
 		}
 
 		data.Rows = append(data.Rows, row)
 	}
 
+	data.Summary = map[string]interface{}{
+		"total_requests": len(data.Rows),
+		"sla_met":        slaCounts[SLAStatusMet],
+		"sla_on_track":   slaCounts[SLAStatusOnTrack],
+		"sla_at_risk":    slaCounts[SLAStatusAtRisk],
+		"sla_breached":   slaCounts[SLAStatusBreached],
+	}
+
 	return data, nil
 }
 
@@ -692,6 +958,44 @@ func generateChartsForReport(data *ReportData, chartConfig map[string]interface{
 			}
 			charts = append(charts, chart)
 		}
+
+		// Chart the columns the report asks for, including computed columns, e.g.
+		// {"type": "bar", "label_column": "Name", "columns": ["Vacancy %"]}
+		labelColumn, _ := chartConfig["label_column"].(string)
+		columns, _ := chartConfig["columns"].([]interface{})
+		if labelColumn != "" && len(columns) > 0 {
+			chartType, _ := chartConfig["type"].(string)
+			if chartType == "" {
+				chartType = "bar"
+			}
+			var datasets []map[string]interface{}
+			var names []string
+			for _, c := range columns {
+				name, ok := c.(string)
+				if !ok || !hasNumericColumn(data.Rows, name) {
+					continue
+				}
+				datasets = append(datasets, map[string]interface{}{
+					"label": name,
+					"data":  extractColumn(data.Rows, name),
+				})
+				names = append(names, name)
+			}
+			if len(datasets) > 0 {
+				title, _ := chartConfig["title"].(string)
+				if title == "" {
+					title = strings.Join(names, ", ") + " by " + labelColumn
+				}
+				charts = append(charts, ChartData{
+					Type:  chartType,
+					Title: title,
+					Data: map[string]interface{}{
+						"labels":   extractColumn(data.Rows, labelColumn),
+						"datasets": datasets,
+					},
+				})
+			}
+		}
 	}
 
 	return charts, nil
@@ -724,7 +1028,7 @@ func extractColumn(rows []map[string]interface{}, columnName string) []interface
 // KPI and Analytics functions
 
 // GetKPIMetrics retrieves KPI metrics for a specific period and category
-func GetKPIMetrics(category string, startDate, endDate time.Time, propertyID *int) ([]KPIMetric, error) {
+func (sqlRepository) GetKPIMetrics(category string, startDate, endDate time.Time, propertyID *int) ([]KPIMetric, error) {
 	query := `
 		SELECT id, metric_name, metric_value, metric_unit, category, period_start, period_end,
 			   property_id, calculated_by, calculation_method, benchmark_value, created_at
@@ -740,7 +1044,7 @@ func GetKPIMetrics(category string, startDate, endDate time.Time, propertyID *in
 
 	query += " ORDER BY period_start DESC"
 
-	rows, err := db.DB.Query(query, args...)
+	rows, err := db.ReadDB().Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -763,7 +1067,7 @@ func GetKPIMetrics(category string, startDate, endDate time.Time, propertyID *in
 }
 
 // CreateKPIMetric creates a new KPI metric
-func CreateKPIMetric(metric *KPIMetric) error {
+func (sqlRepository) CreateKPIMetric(metric *KPIMetric) error {
 	query := `
 		INSERT INTO kpi_metrics (metric_name, metric_value, metric_unit, category,
 							   period_start, period_end, property_id, calculated_by,
@@ -795,7 +1099,7 @@ func CalculateOccupancyRate(startDate, endDate time.Time, propertyID *int) (floa
 	}
 
 	var totalUnits, occupiedUnits int
-	err := db.DB.QueryRow(query, args...).Scan(&totalUnits, &occupiedUnits)
+	err := db.ReadDB().QueryRow(query, args...).Scan(&totalUnits, &occupiedUnits)
 	if err != nil {
 		return 0, err
 	}
@@ -922,38 +1226,63 @@ func generateInsights(metricName, trendType string, changeRate float64) []string
 	return insights
 }
 
-// GetReportTemplates retrieves available report templates
-func GetReportTemplates() ([]ReportTemplate, error) {
-	query := `
-		SELECT id, name, description, category, template_config, is_system,
-			   created_by, created_at, updated_at
-		FROM report_templates
-		ORDER BY is_system DESC, category, name`
+// GetReportTemplates retrieves the published report templates
+func (sqlRepository) GetReportTemplates() ([]ReportTemplate, error) {
+	return SearchReportTemplates(ReportTemplateFilter{})
+}
 
-	rows, err := db.DB.Query(query)
+// PropertyKPIMetric is a KPI metric together with the name of the property it belongs to
+type PropertyKPIMetric struct {
+	KPIMetric
+	PropertyName string `json:"property_name,omitempty"`
+}
+
+// GetLatestKPIMetrics retrieves the most recent value of each named metric, per property.
+// PropertyName is empty for global metrics.
+func GetLatestKPIMetrics(metricNames []string) ([]PropertyKPIMetric, error) {
+	if len(metricNames) == 0 {
+		return nil, nil
+	}
+
+	placeholders := make([]string, len(metricNames))
+	args := make([]interface{}, len(metricNames))
+	for i, name := range metricNames {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = name
+	}
+
+	query := fmt.Sprintf(`
+		SELECT k.id, k.metric_name, k.metric_value, k.metric_unit, k.category, k.period_start,
+			   k.period_end, k.property_id, k.calculated_by, k.calculation_method,
+			   k.benchmark_value, k.created_at, COALESCE(p.name, '')
+		FROM kpi_metrics k
+		LEFT JOIN properties p ON k.property_id = p.id
+		WHERE k.metric_name IN (%s)
+		  AND k.period_end = (
+			  SELECT MAX(k2.period_end) FROM kpi_metrics k2
+			  WHERE k2.metric_name = k.metric_name
+				AND COALESCE(k2.property_id, 0) = COALESCE(k.property_id, 0)
+		  )
+		ORDER BY k.metric_name, k.property_id`, strings.Join(placeholders, ", "))
+
+	rows, err := db.ReadDB().Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var templates []ReportTemplate
+	var metrics []PropertyKPIMetric
 	for rows.Next() {
-		var template ReportTemplate
-		var configJSON []byte
-
-		err := rows.Scan(&template.ID, &template.Name, &template.Description,
-			&template.Category, &configJSON, &template.IsSystem,
-			&template.CreatedBy, &template.CreatedAt, &template.UpdatedAt)
+		var metric PropertyKPIMetric
+		err := rows.Scan(&metric.ID, &metric.MetricName, &metric.MetricValue,
+			&metric.MetricUnit, &metric.Category, &metric.PeriodStart,
+			&metric.PeriodEnd, &metric.PropertyID, &metric.CalculatedBy,
+			&metric.CalculationMethod, &metric.BenchmarkValue, &metric.CreatedAt, &metric.PropertyName)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := json.Unmarshal(configJSON, &template.TemplateConfig); err != nil {
-			return nil, err
-		}
-
-		templates = append(templates, template)
+		metrics = append(metrics, metric)
 	}
 
-	return templates, nil
+	return metrics, nil
 }
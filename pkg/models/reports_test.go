@@ -41,7 +41,7 @@ func TestCreateCustomReport(t *testing.T) {
 	mock.ExpectQuery(`INSERT INTO custom_reports`).
 		WithArgs(report.Name, report.Description, report.ReportType, report.CreatedBy,
 			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
-			report.IsPublic, report.IsScheduled, report.ScheduleCron).
+			report.IsPublic, report.IsScheduled, report.ScheduleCron, report.ScheduleTimezone, "pdf", "none").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
 			AddRow(1, time.Now(), time.Now()))
 
@@ -61,11 +61,12 @@ func TestGetCustomReports(t *testing.T) {
 
 	rows := sqlmock.NewRows([]string{
 		"id", "name", "description", "report_type", "created_by", "criteria", "columns",
-		"chart_config", "is_public", "is_scheduled", "schedule_cron", "last_generated",
+		"chart_config", "is_public", "is_scheduled", "schedule_cron", "schedule_timezone", "schedule_format",
+		"confidentiality", "last_generated",
 		"created_at", "updated_at",
 	}).AddRow(1, "Test Report", "Test description", "property", 1,
 		`{"property_type": "apartment"}`, `{"ID","Name","Address"}`,
-		`{}`, false, false, nil, nil, now, now)
+		`{}`, false, false, nil, nil, "pdf", "none", nil, now, now)
 
 	mock.ExpectQuery(`SELECT (.+) FROM custom_reports`).
 		WithArgs(userID).
@@ -90,11 +91,12 @@ func TestExecuteReport(t *testing.T) {
 	// Mock GetCustomReportByID
 	reportRows := sqlmock.NewRows([]string{
 		"id", "name", "description", "report_type", "created_by", "criteria", "columns",
-		"chart_config", "is_public", "is_scheduled", "schedule_cron", "last_generated",
+		"chart_config", "is_public", "is_scheduled", "schedule_cron", "schedule_timezone", "schedule_format",
+		"confidentiality", "last_generated",
 		"created_at", "updated_at",
 	}).AddRow(1, "Property Report", "Test description", "property", 1,
 		`{}`, `{"ID","Name","Address"}`,
-		`{}`, false, false, nil, nil, time.Now(), time.Now())
+		`{}`, false, false, nil, nil, "pdf", "none", nil, time.Now(), time.Now())
 
 	mock.ExpectQuery(`SELECT (.+) FROM custom_reports WHERE id = \$1`).
 		WithArgs(reportID).
@@ -113,10 +115,10 @@ func TestExecuteReport(t *testing.T) {
 	mock.ExpectQuery(`INSERT INTO report_executions`).
 		WithArgs(reportID, sqlmock.AnyArg(), sqlmock.AnyArg(), "completed",
 			"json", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
-			sqlmock.AnyArg(), sqlmock.AnyArg()).
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-	data, err := ExecuteReport(reportID, parameters)
+	data, err := ExecuteReport(reportID, parameters, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, data)
 	assert.Len(t, data.Headers, 8) // Expected headers for property report
@@ -125,6 +127,26 @@ func TestExecuteReport(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetAnalyticsDashboardByID(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	updatedAt := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM analytics_dashboards WHERE id = \$1`).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "created_by", "layout", "widgets",
+			"is_default", "is_public", "created_at", "updated_at"}).
+			AddRow(4, "Leasing", nil, 2, []byte(`{"columns":2}`), []byte(`[{"type":"kpi"}]`), false, true,
+				updatedAt, updatedAt))
+
+	dashboard, err := GetAnalyticsDashboardByID(4)
+	assert.NoError(t, err)
+	assert.Equal(t, "Leasing", dashboard.Name)
+	assert.Equal(t, float64(2), dashboard.Layout["columns"])
+	assert.Len(t, dashboard.Widgets, 1)
+	assert.Equal(t, updatedAt, dashboard.UpdatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGeneratePropertyReport(t *testing.T) {
 	mock, cleanup := setupReportsTestDB(t)
 	defer cleanup()
@@ -178,6 +200,26 @@ func TestGenerateFinancialReport(t *testing.T) {
 		WithArgs(startDate, endDate).
 		WillReturnRows(dataRows)
 
+	// One lease for the whole year with two free months
+	mock.ExpectQuery(`SELECT (.+) FROM leases l`).
+		WithArgs(startDate, endDate).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "property_id", "monthly_rent", "start_date", "end_date",
+			"proration_convention"}).
+			AddRow(7, 1, 1000.0, startDate, endDate, ProrationActualDays))
+	mock.ExpectQuery(`SELECT (.+) FROM lease_concessions`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "lease_id", "concession_type", "start_month", "months",
+			"amount", "description", "created_by", "created_at"}).
+			AddRow(1, 7, ConcessionFreeRent, startDate, 2, nil, "Move-in special", nil, startDate))
+	mock.ExpectQuery(`SELECT (.+) FROM lease_escalations`).
+		WithArgs(EscalationStatusApplied, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"lease_id", "effective_date", "old_rent"}))
+
+	// 3 properties x 12 months, only 30 locked
+	mock.ExpectQuery(`SELECT (.+) FROM accounting_periods`).
+		WithArgs(startDate, time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"properties", "locked"}).AddRow(3, 30))
+
 	parameters := map[string]interface{}{
 		"start_date": "2024-01-01",
 		"end_date":   "2024-12-31",
@@ -193,6 +235,10 @@ func TestGenerateFinancialReport(t *testing.T) {
 	summary := data.Summary
 	assert.Equal(t, 79500.0, summary["total_amount"])
 	assert.Equal(t, 53, summary["total_payments"])
+	assert.Equal(t, true, summary["includes_unlocked_periods"])
+	assert.Equal(t, 12000.0, summary["scheduled_rent"])
+	assert.Equal(t, 2000.0, summary["concessions"])
+	assert.Equal(t, 10000.0, summary["effective_rent"])
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -320,15 +366,14 @@ func TestGetReportTemplates(t *testing.T) {
 	mock, cleanup := setupReportsTestDB(t)
 	defer cleanup()
 
-	rows := sqlmock.NewRows([]string{
-		"id", "name", "description", "category", "template_config",
-		"is_system", "created_by", "created_at", "updated_at",
-	}).AddRow(1, "Monthly Revenue Report", "Revenue summary", "financial",
-		`{"data_source": "payments"}`, true, nil, time.Now(), time.Now()).
-		AddRow(2, "Property Performance", "Property metrics", "operational",
-			`{"data_source": "properties"}`, true, nil, time.Now(), time.Now())
-
-	mock.ExpectQuery(`SELECT (.+) FROM report_templates`).
+	rows := sqlmock.NewRows(reportTemplateTestColumns).
+		AddRow(1, "Monthly Revenue Report", "Revenue summary", "financial", `{"data_source": "payments"}`, true, nil,
+			nil, 4, ReportTemplatePublished, nil, nil, nil, nil, nil, time.Now(), time.Now()).
+		AddRow(2, "Property Performance", "Property metrics", "operational", `{"data_source": "properties"}`, true, nil,
+			nil, 0, ReportTemplatePublished, nil, nil, nil, nil, nil, time.Now(), time.Now())
+
+	mock.ExpectQuery(`SELECT (.+) FROM report_templates WHERE status = \$1`).
+		WithArgs(ReportTemplatePublished).
 		WillReturnRows(rows)
 
 	templates, err := GetReportTemplates()
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// UserRepository stores users and their role assignments
+type UserRepository interface {
+	CreateUser(user *User) error
+	GetUserByID(id int) (*User, error)
+	GetUserByEmail(email string) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	GetUserByKeycloakID(keycloakID string) (*User, error)
+	UpdateUser(user *User) error
+	DeleteUser(id int, actorID sql.NullInt32, reason string) error
+	GetUserRoles(userID int) ([]Role, error)
+	AssignRole(userID, roleID int, assignedBy *int) error
+	RemoveRole(userID, roleID int) error
+	GetAllRoles() ([]Role, error)
+	GetRoleByName(name string) (*Role, error)
+}
+
+// PropertyRepository stores properties and lists them with their occupancy
+type PropertyRepository interface {
+	CreateProperty(property *Property) error
+	UpdateProperty(property *Property) error
+	DeleteProperty(id int) error
+	GetProperties() ([]PropertyDetail, error)
+	GetPropertiesByTags(tags []string) ([]PropertyDetail, error)
+}
+
+// ReportRepository stores custom report definitions and report templates
+type ReportRepository interface {
+	CreateCustomReport(report *CustomReport) error
+	GetCustomReports(userID int) ([]CustomReport, error)
+	GetCustomReportByID(id int) (*CustomReport, error)
+	GetReportTemplates() ([]ReportTemplate, error)
+}
+
+// KPIRepository stores calculated KPI metrics
+type KPIRepository interface {
+	GetKPIMetrics(category string, startDate, endDate time.Time, propertyID *int) ([]KPIMetric, error)
+	CreateKPIMetric(metric *KPIMetric) error
+}
+
+// sqlRepository implements the repositories on db.DB
+type sqlRepository struct{}
+
+// The repositories behind the package functions of the same names. Tests replace them with the
+// in-memory store from testutils.UseFakeStore.
+var (
+	UserRepo     UserRepository     = sqlRepository{}
+	PropertyRepo PropertyRepository = sqlRepository{}
+	ReportRepo   ReportRepository   = sqlRepository{}
+	KPIRepo      KPIRepository      = sqlRepository{}
+)
+
+// CreateUser creates a new user
+func CreateUser(user *User) error { return UserRepo.CreateUser(user) }
+
+// GetUserByID retrieves a user and their roles by ID
+func GetUserByID(id int) (*User, error) { return UserRepo.GetUserByID(id) }
+
+// GetUserByEmail retrieves a user and their roles by email
+func GetUserByEmail(email string) (*User, error) { return UserRepo.GetUserByEmail(email) }
+
+// GetUserByUsername retrieves a user and their roles by username
+func GetUserByUsername(username string) (*User, error) { return UserRepo.GetUserByUsername(username) }
+
+// GetUserByKeycloakID retrieves a user and their roles by Keycloak ID
+func GetUserByKeycloakID(keycloakID string) (*User, error) {
+	return UserRepo.GetUserByKeycloakID(keycloakID)
+}
+
+// UpdateUser updates a user's information
+func UpdateUser(user *User) error { return UserRepo.UpdateUser(user) }
+
+// DeleteUser soft-deletes a user, revoking their access and auditing the actor and reason
+func DeleteUser(id int, actorID sql.NullInt32, reason string) error {
+	return UserRepo.DeleteUser(id, actorID, reason)
+}
+
+// GetUserRoles retrieves the unexpired roles of a user
+func GetUserRoles(userID int) ([]Role, error) { return UserRepo.GetUserRoles(userID) }
+
+// AssignRole assigns a role to a user
+func AssignRole(userID, roleID int, assignedBy *int) error {
+	return UserRepo.AssignRole(userID, roleID, assignedBy)
+}
+
+// RemoveRole removes a role from a user
+func RemoveRole(userID, roleID int) error { return UserRepo.RemoveRole(userID, roleID) }
+
+// GetAllRoles retrieves all available roles
+func GetAllRoles() ([]Role, error) { return UserRepo.GetAllRoles() }
+
+// GetRoleByName retrieves a role by its name
+func GetRoleByName(name string) (*Role, error) { return UserRepo.GetRoleByName(name) }
+
+// CreateProperty creates a new property
+func CreateProperty(property *Property) error { return PropertyRepo.CreateProperty(property) }
+
+// UpdateProperty updates an existing property
+func UpdateProperty(property *Property) error { return PropertyRepo.UpdateProperty(property) }
+
+// DeleteProperty deletes a property
+func DeleteProperty(id int) error { return PropertyRepo.DeleteProperty(id) }
+
+// GetProperties lists properties with their rent, status and tenant
+func GetProperties() ([]PropertyDetail, error) { return PropertyRepo.GetProperties() }
+
+// GetPropertiesByTags lists the properties having all of the tags
+func GetPropertiesByTags(tags []string) ([]PropertyDetail, error) {
+	return PropertyRepo.GetPropertiesByTags(tags)
+}
+
+// CreateCustomReport creates a new custom report
+func CreateCustomReport(report *CustomReport) error { return ReportRepo.CreateCustomReport(report) }
+
+// GetCustomReports retrieves a user's own and public custom reports
+func GetCustomReports(userID int) ([]CustomReport, error) { return ReportRepo.GetCustomReports(userID) }
+
+// GetCustomReportByID retrieves a specific custom report
+func GetCustomReportByID(id int) (*CustomReport, error) { return ReportRepo.GetCustomReportByID(id) }
+
+// GetReportTemplates retrieves all report templates
+func GetReportTemplates() ([]ReportTemplate, error) { return ReportRepo.GetReportTemplates() }
+
+// GetKPIMetrics retrieves KPI metrics for a category within a period
+func GetKPIMetrics(category string, startDate, endDate time.Time, propertyID *int) ([]KPIMetric, error) {
+	return KPIRepo.GetKPIMetrics(category, startDate, endDate, propertyID)
+}
+
+// CreateKPIMetric creates a new KPI metric
+func CreateKPIMetric(metric *KPIMetric) error { return KPIRepo.CreateKPIMetric(metric) }
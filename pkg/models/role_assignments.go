@@ -0,0 +1,389 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// MaxBulkRoleUsers limits how many users one bulk role change may touch
+const MaxBulkRoleUsers = 500
+
+// Audit actions recorded for role assignment changes
+const (
+	AuditRoleAssigned = "role.assigned"
+	AuditRoleRemoved  = "role.removed"
+	AuditRoleExpired  = "role.expired"
+)
+
+// Outcomes of a bulk role change for one user
+const (
+	RoleChangeAssigned    = "assigned"
+	RoleChangeUpdated     = "updated"
+	RoleChangePermanent   = "already_permanent" // A temporary assignment does not replace a permanent one
+	RoleChangeRemoved     = "removed"
+	RoleChangeNotAssigned = "not_assigned"
+)
+
+// UnknownUsersError is returned when a bulk role change names users that do not exist
+type UnknownUsersError struct {
+	UserIDs []int
+}
+
+func (e *UnknownUsersError) Error() string {
+	ids := make([]string, len(e.UserIDs))
+	for i, id := range e.UserIDs {
+		ids[i] = fmt.Sprint(id)
+	}
+	return "unknown users: " + strings.Join(ids, ", ")
+}
+
+// RoleChange is the outcome of a bulk role change for one user
+type RoleChange struct {
+	UserID int    `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// RoleAssignment is a user holding a role, with when the assignment expires
+type RoleAssignment struct {
+	UserID     int           `json:"user_id"`
+	Username   string        `json:"username"`
+	Email      string        `json:"email"`
+	AssignedAt time.Time     `json:"assigned_at"`
+	AssignedBy sql.NullInt32 `json:"assigned_by,omitempty"`
+	ExpiresAt  sql.NullTime  `json:"expires_at,omitempty"`
+}
+
+// ExpiredRoleAssignment is a temporary role assignment removed because it expired
+type ExpiredRoleAssignment struct {
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	RoleID    int       `json:"role_id"`
+	RoleName  string    `json:"role_name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AssignRoleUntil assigns a role to a user that is removed at expiresAt, or never when it is
+// not valid, and audits the assignment. It returns sql.ErrNoRows when the role does not exist.
+func AssignRoleUntil(userID, roleID int, assignedBy *int, expiresAt sql.NullTime) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var roleName string
+	if err := tx.QueryRow("SELECT name FROM roles WHERE id = $1", roleID).Scan(&roleName); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO user_roles (user_id, role_id, assigned_by, expires_at) VALUES ($1, $2, $3, $4)`,
+		userID, roleID, assignedBy, expiresAt); err != nil {
+		return err
+	}
+
+	entry := &AuditEntry{
+		Action:     AuditRoleAssigned,
+		EntityType: "user",
+		EntityID:   userID,
+		Details:    map[string]interface{}{"role_id": roleID, "role": roleName},
+	}
+	if assignedBy != nil {
+		entry.ActorID = sql.NullInt32{Int32: int32(*assignedBy), Valid: true}
+	}
+	if expiresAt.Valid {
+		entry.Details["expires_at"] = expiresAt.Time
+	}
+	if err := RecordAudit(tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemovePermanentRole removes a role from a user unless it was assigned temporarily, so syncing
+// roles from the identity provider leaves temporary access in place until it expires
+func RemovePermanentRole(userID, roleID int) error {
+	_, err := db.DB.Exec(`DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2 AND expires_at IS NULL`, userID, roleID)
+	return err
+}
+
+// checkBulkRoleUsers returns an UnknownUsersError for any of the users that do not exist
+func checkBulkRoleUsers(q Querier, userIDs []int) error {
+	var unknown []int
+	for _, userID := range userIDs {
+		var id int
+		err := q.QueryRow("SELECT id FROM users WHERE id = $1", userID).Scan(&id)
+		if err == sql.ErrNoRows {
+			unknown = append(unknown, userID)
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(unknown) > 0 {
+		return &UnknownUsersError{UserIDs: unknown}
+	}
+	return nil
+}
+
+// BulkAssignRole assigns a role to every user in one transaction, updating the expiry of users
+// who already hold it, and audits each change. Users who hold the role permanently keep it
+// permanently when it is assigned with an expiry. Nothing is changed when the role or any user
+// does not exist.
+func BulkAssignRole(roleID int, userIDs []int, expiresAt sql.NullTime, actorID int) ([]RoleChange, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var roleName string
+	if err := tx.QueryRow("SELECT name FROM roles WHERE id = $1", roleID).Scan(&roleName); err != nil {
+		return nil, err
+	}
+	if err := checkBulkRoleUsers(tx, userIDs); err != nil {
+		return nil, err
+	}
+
+	changes := make([]RoleChange, 0, len(userIDs))
+	for _, userID := range userIDs {
+		change := RoleChange{UserID: userID, Status: RoleChangeAssigned}
+		var current sql.NullTime
+		err := tx.QueryRow("SELECT expires_at FROM user_roles WHERE user_id = $1 AND role_id = $2",
+			userID, roleID).Scan(&current)
+		switch {
+		case err == sql.ErrNoRows:
+			if _, err := tx.Exec(`
+				INSERT INTO user_roles (user_id, role_id, assigned_by, expires_at)
+				VALUES ($1, $2, $3, $4)`, userID, roleID, actorID, expiresAt); err != nil {
+				return nil, err
+			}
+		case err != nil:
+			return nil, err
+		case !current.Valid && expiresAt.Valid:
+			changes = append(changes, RoleChange{UserID: userID, Status: RoleChangePermanent})
+			continue
+		default:
+			change.Status = RoleChangeUpdated
+			if _, err := tx.Exec(`
+				UPDATE user_roles SET expires_at = $1, assigned_by = $2
+				WHERE user_id = $3 AND role_id = $4`, expiresAt, actorID, userID, roleID); err != nil {
+				return nil, err
+			}
+		}
+
+		details := map[string]interface{}{"role_id": roleID, "role": roleName, "bulk": true}
+		if expiresAt.Valid {
+			details["expires_at"] = expiresAt.Time
+		}
+		if err := RecordAudit(tx, &AuditEntry{
+			ActorID:    sql.NullInt32{Int32: int32(actorID), Valid: true},
+			Action:     AuditRoleAssigned,
+			EntityType: "user",
+			EntityID:   userID,
+			Details:    details,
+		}); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, tx.Commit()
+}
+
+// BulkRemoveRole removes a role from every user in one transaction and audits each removal.
+// Nothing is changed when the role or any user does not exist.
+func BulkRemoveRole(roleID int, userIDs []int, actorID int) ([]RoleChange, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var roleName string
+	if err := tx.QueryRow("SELECT name FROM roles WHERE id = $1", roleID).Scan(&roleName); err != nil {
+		return nil, err
+	}
+	if err := checkBulkRoleUsers(tx, userIDs); err != nil {
+		return nil, err
+	}
+
+	changes := make([]RoleChange, 0, len(userIDs))
+	for _, userID := range userIDs {
+		result, err := tx.Exec("DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2", userID, roleID)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if affected == 0 {
+			changes = append(changes, RoleChange{UserID: userID, Status: RoleChangeNotAssigned})
+			continue
+		}
+
+		if err := RecordAudit(tx, &AuditEntry{
+			ActorID:    sql.NullInt32{Int32: int32(actorID), Valid: true},
+			Action:     AuditRoleRemoved,
+			EntityType: "user",
+			EntityID:   userID,
+			Details:    map[string]interface{}{"role_id": roleID, "role": roleName, "bulk": true},
+		}); err != nil {
+			return nil, err
+		}
+		changes = append(changes, RoleChange{UserID: userID, Status: RoleChangeRemoved})
+	}
+	return changes, tx.Commit()
+}
+
+// GetRoleAssignments returns the users holding a role, including temporary assignments that
+// have not expired yet
+func GetRoleAssignments(roleID int, now time.Time) ([]RoleAssignment, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT u.id, u.username, u.email, ur.assigned_at, ur.assigned_by, ur.expires_at
+		FROM user_roles ur
+		JOIN users u ON u.id = ur.user_id
+		WHERE ur.role_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > $2)
+		ORDER BY u.username`, roleID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignments []RoleAssignment
+	for rows.Next() {
+		var a RoleAssignment
+		if err := rows.Scan(&a.UserID, &a.Username, &a.Email, &a.AssignedAt, &a.AssignedBy, &a.ExpiresAt); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+// RemoveExpiredRoles deletes the role assignments that expired by now, auditing each removal and
+// emailing every active admin a summary through the outbox in the same transaction, and returns
+// them
+func RemoveExpiredRoles(now time.Time) ([]ExpiredRoleAssignment, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT ur.user_id, u.username, u.email, ur.role_id, r.name, ur.expires_at
+		FROM user_roles ur
+		JOIN users u ON u.id = ur.user_id
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.expires_at IS NOT NULL AND ur.expires_at <= $1
+		ORDER BY ur.expires_at, ur.user_id`, now)
+	if err != nil {
+		return nil, err
+	}
+	var expired []ExpiredRoleAssignment
+	for rows.Next() {
+		var e ExpiredRoleAssignment
+		if err := rows.Scan(&e.UserID, &e.Username, &e.Email, &e.RoleID, &e.RoleName, &e.ExpiresAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	for _, e := range expired {
+		if _, err := tx.Exec("DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2 AND expires_at <= $3",
+			e.UserID, e.RoleID, now); err != nil {
+			return nil, err
+		}
+		if err := RecordAudit(tx, &AuditEntry{
+			Action:     AuditRoleExpired,
+			EntityType: "user",
+			EntityID:   e.UserID,
+			Details:    map[string]interface{}{"role_id": e.RoleID, "role": e.RoleName, "expires_at": e.ExpiresAt},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := notifyExpiredRoles(tx, expired); err != nil {
+		return nil, err
+	}
+	return expired, tx.Commit()
+}
+
+// adminEmails returns the addresses of every active admin except exceptUserID, which may be 0
+func adminEmails(q Querier, exceptUserID int) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT DISTINCT u.email
+		FROM users u
+		JOIN user_roles ur ON ur.user_id = u.id
+		JOIN roles r ON ur.role_id = r.id
+		WHERE r.name = 'admin' AND u.status = 'active' AND u.id <> $1
+		ORDER BY u.email`, exceptUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// notifyExpiredRoles emails every active admin the list of expired role assignments
+func notifyExpiredRoles(q Querier, expired []ExpiredRoleAssignment) error {
+	emails, err := adminEmails(q, 0)
+	if err != nil {
+		return err
+	}
+
+	var body strings.Builder
+	body.WriteString("The following temporary role assignments have expired and were removed:\n\n")
+	for _, e := range expired {
+		fmt.Fprintf(&body, "- %s (%s): %s, expired %s\n", e.Username, e.Email, e.RoleName, e.ExpiresAt.Format("2006-01-02 15:04 MST"))
+	}
+	subject := fmt.Sprintf("%d temporary role assignments expired", len(expired))
+	if len(expired) == 1 {
+		subject = "1 temporary role assignment expired"
+	}
+
+	for _, email := range emails {
+		err := EnqueueOutboxMessage(q, &OutboxMessage{
+			Channel:     "email",
+			Destination: email,
+			EventType:   "role.expired",
+			Payload: map[string]interface{}{
+				"subject": subject,
+				"body":    body.String(),
+				"expired": expired,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRoleName returns the name of a role, or sql.ErrNoRows if it does not exist
+func GetRoleName(roleID int) (string, error) {
+	var name string
+	err := db.DB.QueryRow("SELECT name FROM roles WHERE id = $1", roleID).Scan(&name)
+	return name, err
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkAssignRole(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	expires := sql.NullTime{Time: time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), Valid: true}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT name FROM roles`).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("contractor"))
+	mock.ExpectQuery(`SELECT id FROM users`).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(`SELECT id FROM users`).WithArgs(8).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectQuery(`SELECT id FROM users`).WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery(`SELECT expires_at FROM user_roles`).WithArgs(7, 4).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(`INSERT INTO user_roles`).WithArgs(7, 4, 1, expires).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 1, Valid: true}, AuditRoleAssigned, "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectQuery(`SELECT expires_at FROM user_roles`).WithArgs(8, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"expires_at"}).AddRow(time.Date(2026, 11, 30, 0, 0, 0, 0, time.UTC)))
+	mock.ExpectExec(`UPDATE user_roles SET expires_at`).WithArgs(expires, 1, 8, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 1, Valid: true}, AuditRoleAssigned, "user", 8, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, time.Now()))
+	// User 9 holds the role permanently and is not downgraded to temporary access
+	mock.ExpectQuery(`SELECT expires_at FROM user_roles`).WithArgs(9, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"expires_at"}).AddRow(nil))
+	mock.ExpectCommit()
+
+	changes, err := BulkAssignRole(4, []int{7, 8, 9}, expires, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []RoleChange{
+		{UserID: 7, Status: RoleChangeAssigned},
+		{UserID: 8, Status: RoleChangeUpdated},
+		{UserID: 9, Status: RoleChangePermanent},
+	}, changes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAssignRoleUntilAudits(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	expires := sql.NullTime{Time: time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), Valid: true}
+	assignedBy := 1
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT name FROM roles`).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("contractor"))
+	mock.ExpectExec(`INSERT INTO user_roles`).WithArgs(7, 4, &assignedBy, expires).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 1, Valid: true}, AuditRoleAssigned, "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+	require.NoError(t, AssignRoleUntil(7, 4, &assignedBy, expires))
+
+	// A failed assignment is not audited
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT name FROM roles`).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("contractor"))
+	mock.ExpectExec(`INSERT INTO user_roles`).WillReturnError(errors.New("duplicate key value"))
+	mock.ExpectRollback()
+	assert.Error(t, AssignRoleUntil(7, 4, nil, sql.NullTime{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkRemoveRoleRejectsUnknownUsers(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT name FROM roles`).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("contractor"))
+	mock.ExpectQuery(`SELECT id FROM users`).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(`SELECT id FROM users`).WithArgs(99).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err := BulkRemoveRole(4, []int{7, 99}, 1)
+	var unknown *UnknownUsersError
+	require.True(t, errors.As(err, &unknown))
+	assert.Equal(t, []int{99}, unknown.UserIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveExpiredRoles(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	expiredAt := now.Add(-time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT ur.user_id, u.username, u.email, ur.role_id, r.name, ur.expires_at`).WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "username", "email", "role_id", "name", "expires_at"}).
+			AddRow(7, "pat", "pat@example.com", 4, "contractor", expiredAt))
+	mock.ExpectExec(`DELETE FROM user_roles`).WithArgs(7, 4, now).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{}, AuditRoleExpired, "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	mock.ExpectQuery(`SELECT DISTINCT u.email`).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("admin@example.com"))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", "role.expired", "admin@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	expired, err := RemoveExpiredRoles(now)
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, "contractor", expired[0].RoleName)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
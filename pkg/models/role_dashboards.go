@@ -0,0 +1,207 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ErrNoDefaultDashboard is returned when neither a role nor the user has a default dashboard
+var ErrNoDefaultDashboard = errors.New("no default dashboard configured")
+
+// DashboardRolePriority orders roles when a user with several roles resolves their default
+// dashboard: the first role with a default wins. Roles not listed come after, by name.
+var DashboardRolePriority = []string{"admin", "property_manager", "tenant", "viewer"}
+
+// RoleDefaultDashboard designates the dashboard users of a role see by default. OrganizationID is
+// null for the global default and set for an organization-level override.
+type RoleDefaultDashboard struct {
+	ID             int           `json:"id"`
+	RoleID         int           `json:"role_id"`
+	RoleName       string        `json:"role"`
+	OrganizationID sql.NullInt32 `json:"organization_id"`
+	DashboardID    int           `json:"dashboard_id"`
+	DashboardName  string        `json:"dashboard_name"`
+	AssignedBy     sql.NullInt32 `json:"assigned_by,omitempty"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// DefaultDashboardResolution is the dashboard resolved for a user and why it was chosen
+type DefaultDashboardResolution struct {
+	Dashboard      *AnalyticsDashboard `json:"dashboard"`
+	Source         string              `json:"source"` // "preference", "organization", "role" or "user"
+	Role           string              `json:"role,omitempty"`
+	OrganizationID int                 `json:"organization_id,omitempty"`
+}
+
+// GetRoleDefaultDashboards lists every role default, global defaults before organization overrides
+func GetRoleDefaultDashboards() ([]RoleDefaultDashboard, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT rd.id, rd.role_id, r.name, rd.organization_id, rd.dashboard_id, d.name,
+			   rd.assigned_by, rd.updated_at
+		FROM role_default_dashboards rd
+		JOIN roles r ON r.id = rd.role_id
+		JOIN analytics_dashboards d ON d.id = rd.dashboard_id
+		ORDER BY r.name, rd.organization_id IS NOT NULL, rd.organization_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var defaults []RoleDefaultDashboard
+	for rows.Next() {
+		var d RoleDefaultDashboard
+		if err := rows.Scan(&d.ID, &d.RoleID, &d.RoleName, &d.OrganizationID, &d.DashboardID,
+			&d.DashboardName, &d.AssignedBy, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		defaults = append(defaults, d)
+	}
+	return defaults, rows.Err()
+}
+
+// SetRoleDefaultDashboard designates the default dashboard of a role, for one organization when
+// organizationID is valid and globally otherwise, replacing any existing designation
+func SetRoleDefaultDashboard(roleID int, organizationID sql.NullInt32, dashboardID int, assignedBy sql.NullInt32) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE FROM role_default_dashboards
+		WHERE role_id = $1 AND COALESCE(organization_id, 0) = COALESCE($2, 0)`,
+		roleID, organizationID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO role_default_dashboards (role_id, organization_id, dashboard_id, assigned_by)
+		VALUES ($1, $2, $3, $4)`,
+		roleID, organizationID, dashboardID, assignedBy); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteRoleDefaultDashboard removes the global default of a role, or one organization's override
+func DeleteRoleDefaultDashboard(roleID int, organizationID sql.NullInt32) error {
+	result, err := db.DB.Exec(`
+		DELETE FROM role_default_dashboards
+		WHERE role_id = $1 AND COALESCE(organization_id, 0) = COALESCE($2, 0)`,
+		roleID, organizationID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// rolePriority returns the roles sorted by DashboardRolePriority, unlisted roles last by name
+func rolePriority(roles []string) []string {
+	rank := func(role string) int {
+		for i, name := range DashboardRolePriority {
+			if name == role {
+				return i
+			}
+		}
+		return len(DashboardRolePriority)
+	}
+	sorted := append([]string(nil), roles...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ri, rj := rank(sorted[i]), rank(sorted[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
+
+// resolveRoleDefault picks the default for a user's roles within their organization. Roles are
+// tried in priority order; for each, the organization's override wins over the global default.
+func resolveRoleDefault(defaults []RoleDefaultDashboard, roles []string, organizationID int) *RoleDefaultDashboard {
+	for _, role := range rolePriority(roles) {
+		var global *RoleDefaultDashboard
+		for i := range defaults {
+			d := &defaults[i]
+			if d.RoleName != role {
+				continue
+			}
+			if !d.OrganizationID.Valid {
+				global = d
+			} else if int(d.OrganizationID.Int32) == organizationID {
+				return d
+			}
+		}
+		if global != nil {
+			return global
+		}
+	}
+	return nil
+}
+
+// ResolveDefaultDashboard returns the default dashboard for a user. The dashboard chosen in the
+// user's preferences comes first while it exists and they can see it; then role defaults, with
+// their organization's overrides; otherwise the user's own dashboard marked is_default.
+func ResolveDefaultDashboard(user *User) (*DefaultDashboardResolution, error) {
+	prefs, err := GetUserPreferences(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if prefs.DefaultDashboardID > 0 {
+		dashboard, err := GetAnalyticsDashboardByID(prefs.DefaultDashboardID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil && (dashboard.CreatedBy == user.ID || dashboard.IsPublic) {
+			return &DefaultDashboardResolution{Dashboard: dashboard, Source: "preference"}, nil
+		}
+	}
+
+	organizationID, err := UserOrganizationID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := GetRoleDefaultDashboards()
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, role.Name)
+	}
+
+	if match := resolveRoleDefault(defaults, roles, organizationID); match != nil {
+		dashboard, err := GetAnalyticsDashboardByID(match.DashboardID)
+		if err != nil {
+			return nil, err
+		}
+		resolution := &DefaultDashboardResolution{Dashboard: dashboard, Source: "role", Role: match.RoleName}
+		if match.OrganizationID.Valid {
+			resolution.Source = "organization"
+			resolution.OrganizationID = organizationID
+		}
+		return resolution, nil
+	}
+
+	var dashboardID int
+	err = db.ReadDB().QueryRow(`
+		SELECT id FROM analytics_dashboards
+		WHERE created_by = $1 AND is_default = true
+		ORDER BY updated_at DESC LIMIT 1`, user.ID).Scan(&dashboardID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoDefaultDashboard
+	}
+	if err != nil {
+		return nil, err
+	}
+	dashboard, err := GetAnalyticsDashboardByID(dashboardID)
+	if err != nil {
+		return nil, err
+	}
+	return &DefaultDashboardResolution{Dashboard: dashboard, Source: "user"}, nil
+}
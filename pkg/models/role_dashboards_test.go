@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolePriority(t *testing.T) {
+	assert.Equal(t, []string{"admin", "tenant", "auditor", "owner"},
+		rolePriority([]string{"owner", "tenant", "auditor", "admin"}))
+}
+
+func TestResolveRoleDefault(t *testing.T) {
+	org := func(id int32) sql.NullInt32 { return sql.NullInt32{Int32: id, Valid: true} }
+	defaults := []RoleDefaultDashboard{
+		{ID: 1, RoleName: "tenant", DashboardID: 10},
+		{ID: 2, RoleName: "tenant", OrganizationID: org(2), DashboardID: 11},
+		{ID: 3, RoleName: "property_manager", DashboardID: 20},
+	}
+
+	// Tenants see the global default unless their organization overrides it
+	assert.Equal(t, 10, resolveRoleDefault(defaults, []string{"tenant"}, 1).DashboardID)
+	assert.Equal(t, 11, resolveRoleDefault(defaults, []string{"tenant"}, 2).DashboardID)
+
+	// The highest priority role with a default wins, even over another role's override
+	assert.Equal(t, 20, resolveRoleDefault(defaults, []string{"tenant", "property_manager"}, 2).DashboardID)
+
+	// Roles without defaults are skipped
+	assert.Equal(t, 10, resolveRoleDefault(defaults, []string{"admin", "tenant"}, 1).DashboardID)
+	assert.Nil(t, resolveRoleDefault(defaults, []string{"viewer"}, 1))
+}
+
+func TestResolveDefaultDashboardRoleOverride(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT preferences FROM users`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"preferences"}).AddRow(nil))
+	mock.ExpectQuery(`SELECT COALESCE\(organization_id`).
+		WithArgs(7, DefaultOrganizationID).
+		WillReturnRows(sqlmock.NewRows([]string{"organization_id"}).AddRow(2))
+	mock.ExpectQuery(`FROM role_default_dashboards rd`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role_id", "name", "organization_id", "dashboard_id",
+			"name", "assigned_by", "updated_at"}).
+			AddRow(1, 3, "tenant", nil, 10, "Tenant Home", 1, now).
+			AddRow(2, 3, "tenant", 2, 11, "Acme Tenant Home", 1, now))
+	mock.ExpectQuery(`FROM analytics_dashboards WHERE id`).
+		WithArgs(11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "created_by", "layout", "widgets",
+			"is_default", "is_public", "created_at", "updated_at"}).
+			AddRow(11, "Acme Tenant Home", nil, 1, `{}`, `[]`, false, false, now, now))
+
+	user := &User{ID: 7, Roles: []Role{{Name: "tenant"}}}
+	resolution, err := ResolveDefaultDashboard(user)
+	require.NoError(t, err)
+	assert.Equal(t, 11, resolution.Dashboard.ID)
+	assert.Equal(t, "organization", resolution.Source)
+	assert.Equal(t, "tenant", resolution.Role)
+	assert.Equal(t, 2, resolution.OrganizationID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveDefaultDashboardPreferenceFirst(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT preferences FROM users`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"preferences"}).AddRow(`{"default_dashboard_id":12}`))
+	mock.ExpectQuery(`FROM analytics_dashboards WHERE id`).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "created_by", "layout", "widgets",
+			"is_default", "is_public", "created_at", "updated_at"}).
+			AddRow(12, "Portfolio", nil, 3, `{}`, `[]`, false, true, now, now))
+
+	resolution, err := ResolveDefaultDashboard(&User{ID: 7, Roles: []Role{{Name: "tenant"}}})
+	require.NoError(t, err)
+	assert.Equal(t, 12, resolution.Dashboard.ID)
+	assert.Equal(t, "preference", resolution.Source)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveDefaultDashboardNone(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	// The preferred dashboard was made private by its owner, so it is skipped
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT preferences FROM users`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"preferences"}).AddRow(`{"default_dashboard_id":12}`))
+	mock.ExpectQuery(`FROM analytics_dashboards WHERE id`).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "created_by", "layout", "widgets",
+			"is_default", "is_public", "created_at", "updated_at"}).
+			AddRow(12, "Someone Else's", nil, 3, `{}`, `[]`, false, false, now, now))
+	mock.ExpectQuery(`SELECT COALESCE\(organization_id`).
+		WithArgs(7, DefaultOrganizationID).
+		WillReturnRows(sqlmock.NewRows([]string{"organization_id"}).AddRow(1))
+	mock.ExpectQuery(`FROM role_default_dashboards rd`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role_id", "name", "organization_id", "dashboard_id",
+			"name", "assigned_by", "updated_at"}))
+	mock.ExpectQuery(`WHERE created_by = \$1 AND is_default = true`).
+		WithArgs(7).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := ResolveDefaultDashboard(&User{ID: 7, Roles: []Role{{Name: "viewer"}}})
+	assert.Equal(t, ErrNoDefaultDashboard, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetRoleDefaultDashboardReplacesScope(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	organization := sql.NullInt32{Int32: 2, Valid: true}
+	assignedBy := sql.NullInt32{Int32: 1, Valid: true}
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM role_default_dashboards`).
+		WithArgs(3, organization).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO role_default_dashboards`).
+		WithArgs(3, organization, 11, assignedBy).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, SetRoleDefaultDashboard(3, organization, 11, assignedBy))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,355 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Statuses of a role request
+const (
+	RoleRequestPending  = "pending"
+	RoleRequestApproved = "approved"
+	RoleRequestRejected = "rejected"
+)
+
+// Audit actions recorded for role requests
+const (
+	AuditRoleRequested = "role.requested"
+	AuditRoleApproved  = "role.approved"
+	AuditRoleRejected  = "role.rejected"
+)
+
+var (
+	// ErrRoleAlreadyAssigned is returned when a role is requested for a user who already holds it
+	ErrRoleAlreadyAssigned = errors.New("the user already holds this role")
+	// ErrRoleRequestPending is returned when the same role is already waiting for approval
+	ErrRoleRequestPending = errors.New("this role is already waiting for approval for the user")
+	// ErrRoleRequestDecided is returned when approving or rejecting a request that is not pending
+	ErrRoleRequestDecided = errors.New("the role request has already been decided")
+	// ErrSelfApproval is returned when the requester or the user receiving the role approves it
+	ErrSelfApproval = errors.New("a role request must be approved by someone other than the requester and the user receiving the role")
+	// ErrRoleRequestExpired is returned when approving a temporary grant whose expiry has passed
+	ErrRoleRequestExpired = errors.New("the requested role assignment would already have expired")
+)
+
+// RoleRequest is a grant of a sensitive role waiting for, or decided by, a second admin
+type RoleRequest struct {
+	ID              int            `json:"id"`
+	UserID          int            `json:"user_id"`
+	Username        string         `json:"username"`
+	RoleID          int            `json:"role_id"`
+	RoleName        string         `json:"role_name"`
+	ExpiresAt       sql.NullTime   `json:"expires_at,omitempty"`
+	RequestedBy     sql.NullInt32  `json:"requested_by,omitempty"`
+	RequestedByName sql.NullString `json:"requested_by_name,omitempty"`
+	RequestedAt     time.Time      `json:"requested_at"`
+	Status          string         `json:"status"`
+	DecidedBy       sql.NullInt32  `json:"decided_by,omitempty"`
+	DecidedAt       sql.NullTime   `json:"decided_at,omitempty"`
+	Reason          sql.NullString `json:"reason,omitempty"`
+}
+
+const roleRequestColumns = `rq.id, rq.user_id, u.username, rq.role_id, r.name, rq.expires_at, rq.requested_by,
+	ru.username, rq.requested_at, rq.status, rq.decided_by, rq.decided_at, rq.reason`
+
+const roleRequestFrom = `
+	FROM role_requests rq
+	JOIN users u ON u.id = rq.user_id
+	JOIN roles r ON r.id = rq.role_id
+	LEFT JOIN users ru ON ru.id = rq.requested_by`
+
+func scanRoleRequest(row interface{ Scan(...interface{}) error }) (*RoleRequest, error) {
+	rq := &RoleRequest{}
+	err := row.Scan(&rq.ID, &rq.UserID, &rq.Username, &rq.RoleID, &rq.RoleName, &rq.ExpiresAt, &rq.RequestedBy,
+		&rq.RequestedByName, &rq.RequestedAt, &rq.Status, &rq.DecidedBy, &rq.DecidedAt, &rq.Reason)
+	return rq, err
+}
+
+// GetRoleRequests lists role requests with the given status, or every request when status is
+// empty, oldest first
+func GetRoleRequests(status string) ([]RoleRequest, error) {
+	query := "SELECT " + roleRequestColumns + roleRequestFrom
+	var args []interface{}
+	if status != "" {
+		query += " WHERE rq.status = $1"
+		args = append(args, status)
+	}
+	rows, err := db.DB.Query(query+" ORDER BY rq.requested_at, rq.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []RoleRequest
+	for rows.Next() {
+		rq, err := scanRoleRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *rq)
+	}
+	return requests, rows.Err()
+}
+
+// GetRoleRequest retrieves a role request by ID
+func GetRoleRequest(id int) (*RoleRequest, error) {
+	return scanRoleRequest(db.DB.QueryRow("SELECT "+roleRequestColumns+roleRequestFrom+" WHERE rq.id = $1", id))
+}
+
+// CreateRoleRequest records a request to grant a role, audits it and emails every other active
+// admin to approve it through the outbox, all in one transaction. It returns sql.ErrNoRows if
+// the user or role does not exist. link points approvers to where requests are reviewed.
+func CreateRoleRequest(rq *RoleRequest, link string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createRoleRequest(tx, rq, link); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createRoleRequest records, audits and announces a role request within q
+func createRoleRequest(tx Querier, rq *RoleRequest, link string) error {
+	if err := tx.QueryRow("SELECT username FROM users WHERE id = $1", rq.UserID).Scan(&rq.Username); err != nil {
+		return err
+	}
+	if err := tx.QueryRow("SELECT name FROM roles WHERE id = $1", rq.RoleID).Scan(&rq.RoleName); err != nil {
+		return err
+	}
+
+	var exists int
+	err := tx.QueryRow(`
+		SELECT 1 FROM user_roles
+		WHERE user_id = $1 AND role_id = $2 AND (expires_at IS NULL OR expires_at > NOW())`,
+		rq.UserID, rq.RoleID).Scan(&exists)
+	if err == nil {
+		return ErrRoleAlreadyAssigned
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	err = tx.QueryRow("SELECT 1 FROM role_requests WHERE user_id = $1 AND role_id = $2 AND status = $3",
+		rq.UserID, rq.RoleID, RoleRequestPending).Scan(&exists)
+	if err == nil {
+		return ErrRoleRequestPending
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	rq.Status = RoleRequestPending
+	err = tx.QueryRow(`
+		INSERT INTO role_requests (user_id, role_id, expires_at, requested_by, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, requested_at`,
+		rq.UserID, rq.RoleID, rq.ExpiresAt, rq.RequestedBy, rq.Status).Scan(&rq.ID, &rq.RequestedAt)
+	if err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{"request_id": rq.ID, "role_id": rq.RoleID, "role": rq.RoleName}
+	if rq.ExpiresAt.Valid {
+		details["expires_at"] = rq.ExpiresAt.Time
+	}
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    rq.RequestedBy,
+		Action:     AuditRoleRequested,
+		EntityType: "user",
+		EntityID:   rq.UserID,
+		Details:    details,
+	}); err != nil {
+		return err
+	}
+
+	approvers, err := adminEmails(tx, int(rq.RequestedBy.Int32))
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("Approval needed: %s role for %s", rq.RoleName, rq.Username)
+	body := fmt.Sprintf("A request to grant the %s role to %s is waiting for a second admin's approval.\n\n"+
+		"Review it at %s\n", rq.RoleName, rq.Username, link)
+	for _, email := range approvers {
+		if err := EnqueueOutboxMessage(tx, &OutboxMessage{
+			Channel:     "email",
+			Destination: email,
+			EventType:   AuditRoleRequested,
+			Payload:     map[string]interface{}{"subject": subject, "body": body, "request_id": rq.ID},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decideRoleRequest marks a pending request approved or rejected by deciderID, returning
+// ErrRoleRequestDecided if another decision got there first
+func decideRoleRequest(q Querier, rq *RoleRequest, status string, deciderID int, reason sql.NullString, now time.Time) error {
+	result, err := q.Exec(`
+		UPDATE role_requests SET status = $1, decided_by = $2, decided_at = $3, reason = $4
+		WHERE id = $5 AND status = $6`,
+		status, deciderID, now, reason, rq.ID, RoleRequestPending)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err == sql.ErrNoRows {
+		return ErrRoleRequestDecided
+	} else if err != nil {
+		return err
+	}
+	rq.Status = status
+	rq.DecidedBy = sql.NullInt32{Int32: int32(deciderID), Valid: true}
+	rq.DecidedAt = sql.NullTime{Time: now, Valid: true}
+	rq.Reason = reason
+	return nil
+}
+
+// notifyRoleRequester emails the requester of a role request its outcome
+func notifyRoleRequester(q Querier, rq *RoleRequest) error {
+	if !rq.RequestedBy.Valid || int(rq.RequestedBy.Int32) == int(rq.DecidedBy.Int32) {
+		return nil
+	}
+	var email string
+	err := q.QueryRow("SELECT email FROM users WHERE id = $1", rq.RequestedBy.Int32).Scan(&email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Your request to grant the %s role to %s was %s.\n", rq.RoleName, rq.Username, rq.Status)
+	if rq.Reason.Valid {
+		body += "\nReason: " + rq.Reason.String + "\n"
+	}
+	return EnqueueOutboxMessage(q, &OutboxMessage{
+		Channel:     "email",
+		Destination: email,
+		EventType:   "role." + rq.Status,
+		Payload: map[string]interface{}{
+			"subject":    fmt.Sprintf("Role request %s: %s for %s", rq.Status, rq.RoleName, rq.Username),
+			"body":       body,
+			"request_id": rq.ID,
+		},
+	})
+}
+
+// ApproveRoleRequest grants the requested role, audits the approval and the assignment and
+// tells the requester, all in one transaction. The approver must be neither the requester nor
+// the user receiving the role. A user who already holds the role permanently keeps it
+// permanently when the request has an expiry.
+func ApproveRoleRequest(id, approverID int, now time.Time) (*RoleRequest, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rq, err := scanRoleRequest(tx.QueryRow("SELECT "+roleRequestColumns+roleRequestFrom+" WHERE rq.id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	if rq.Status != RoleRequestPending {
+		return nil, ErrRoleRequestDecided
+	}
+	if approverID == rq.UserID || (rq.RequestedBy.Valid && approverID == int(rq.RequestedBy.Int32)) {
+		return nil, ErrSelfApproval
+	}
+	if rq.ExpiresAt.Valid && !rq.ExpiresAt.Time.After(now) {
+		return nil, ErrRoleRequestExpired
+	}
+	if err := decideRoleRequest(tx, rq, RoleRequestApproved, approverID, sql.NullString{}, now); err != nil {
+		return nil, err
+	}
+
+	// The requester is recorded as assigning the role, as they would have without approval. Like
+	// BulkAssignRole, a temporary request never shortens a permanent assignment.
+	actions := []string{AuditRoleApproved, AuditRoleAssigned}
+	var current sql.NullTime
+	err = tx.QueryRow("SELECT expires_at FROM user_roles WHERE user_id = $1 AND role_id = $2",
+		rq.UserID, rq.RoleID).Scan(&current)
+	switch {
+	case err == sql.ErrNoRows:
+		if _, err := tx.Exec(`
+			INSERT INTO user_roles (user_id, role_id, assigned_by, expires_at)
+			VALUES ($1, $2, $3, $4)`, rq.UserID, rq.RoleID, rq.RequestedBy, rq.ExpiresAt); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case !current.Valid && rq.ExpiresAt.Valid:
+		actions = actions[:1]
+	default:
+		if _, err := tx.Exec(`
+			UPDATE user_roles SET expires_at = $1, assigned_by = $2
+			WHERE user_id = $3 AND role_id = $4`, rq.ExpiresAt, rq.RequestedBy, rq.UserID, rq.RoleID); err != nil {
+			return nil, err
+		}
+	}
+
+	details := map[string]interface{}{"request_id": rq.ID, "role_id": rq.RoleID, "role": rq.RoleName}
+	if rq.ExpiresAt.Valid {
+		details["expires_at"] = rq.ExpiresAt.Time
+	}
+	if len(actions) == 1 {
+		details["status"] = RoleChangePermanent
+	}
+	for _, action := range actions {
+		if err := RecordAudit(tx, &AuditEntry{
+			ActorID:    rq.DecidedBy,
+			Action:     action,
+			EntityType: "user",
+			EntityID:   rq.UserID,
+			Details:    details,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := notifyRoleRequester(tx, rq); err != nil {
+		return nil, err
+	}
+	return rq, tx.Commit()
+}
+
+// RejectRoleRequest declines a pending role request, audits the rejection and tells the
+// requester. Requesters may reject their own requests to withdraw them.
+func RejectRoleRequest(id, deciderID int, reason string, now time.Time) (*RoleRequest, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rq, err := scanRoleRequest(tx.QueryRow("SELECT "+roleRequestColumns+roleRequestFrom+" WHERE rq.id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	if rq.Status != RoleRequestPending {
+		return nil, ErrRoleRequestDecided
+	}
+	if err := decideRoleRequest(tx, rq, RoleRequestRejected, deciderID, NullString(reason), now); err != nil {
+		return nil, err
+	}
+
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    rq.DecidedBy,
+		Action:     AuditRoleRejected,
+		EntityType: "user",
+		EntityID:   rq.UserID,
+		Reason:     rq.Reason,
+		Details:    map[string]interface{}{"request_id": rq.ID, "role_id": rq.RoleID, "role": rq.RoleName},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := notifyRoleRequester(tx, rq); err != nil {
+		return nil, err
+	}
+	return rq, tx.Commit()
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var roleRequestRowColumns = []string{"id", "user_id", "username", "role_id", "name", "expires_at", "requested_by",
+	"username", "requested_at", "status", "decided_by", "decided_at", "reason"}
+
+func TestCreateRoleRequestNotifiesOtherAdmins(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	requester := sql.NullInt32{Int32: 1, Valid: true}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT username FROM users`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("jdoe"))
+	mock.ExpectQuery(`SELECT name FROM roles`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("admin"))
+	mock.ExpectQuery(`SELECT 1 FROM user_roles`).WithArgs(7, 2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT 1 FROM role_requests`).WithArgs(7, 2, RoleRequestPending).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`INSERT INTO role_requests`).WithArgs(7, 2, sql.NullTime{}, requester, RoleRequestPending).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "requested_at"}).AddRow(5, time.Now()))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(requester, AuditRoleRequested, "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectQuery(`SELECT DISTINCT u.email`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("second@example.com"))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", AuditRoleRequested, "second@example.com", sqlmock.AnyArg(), 8).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, time.Now(), time.Now(), time.Now()))
+	mock.ExpectCommit()
+
+	rq := &RoleRequest{UserID: 7, RoleID: 2, RequestedBy: requester}
+	require.NoError(t, CreateRoleRequest(rq, "http://localhost:8000/admin/users"))
+	assert.Equal(t, 5, rq.ID)
+	assert.Equal(t, RoleRequestPending, rq.Status)
+	assert.Equal(t, "admin", rq.RoleName)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateRoleRequestRejectsDuplicates(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT username FROM users`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("jdoe"))
+	mock.ExpectQuery(`SELECT name FROM roles`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("admin"))
+	mock.ExpectQuery(`SELECT 1 FROM user_roles`).WithArgs(7, 2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT 1 FROM role_requests`).WithArgs(7, 2, RoleRequestPending).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectRollback()
+
+	err := CreateRoleRequest(&RoleRequest{UserID: 7, RoleID: 2}, "")
+	assert.Equal(t, ErrRoleRequestPending, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApproveRoleRequest(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	requester := sql.NullInt32{Int32: 1, Valid: true}
+	pending := func() *sqlmock.Rows {
+		return sqlmock.NewRows(roleRequestRowColumns).
+			AddRow(5, 7, "jdoe", 2, "admin", nil, 1, "alice", now.Add(-time.Hour), RoleRequestPending, nil, nil, nil)
+	}
+
+	// Neither the requester nor the user receiving the role may approve it
+	for _, approver := range []int{1, 7} {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM role_requests rq`).WithArgs(5).WillReturnRows(pending())
+		mock.ExpectRollback()
+		_, err := ApproveRoleRequest(5, approver, now)
+		assert.Equal(t, ErrSelfApproval, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM role_requests rq`).WithArgs(5).WillReturnRows(pending())
+	mock.ExpectExec(`UPDATE role_requests SET status`).
+		WithArgs(RoleRequestApproved, 3, now, sql.NullString{}, 5, RoleRequestPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT expires_at FROM user_roles`).WithArgs(7, 2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(`INSERT INTO user_roles`).WithArgs(7, 2, requester, sql.NullTime{}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	approver := sql.NullInt32{Int32: 3, Valid: true}
+	for _, action := range []string{AuditRoleApproved, AuditRoleAssigned} {
+		mock.ExpectQuery(`INSERT INTO audit_log`).
+			WithArgs(approver, action, "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	}
+	mock.ExpectQuery(`SELECT email FROM users`).WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("alice@example.com"))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", AuditRoleApproved, "alice@example.com", sqlmock.AnyArg(), 8).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	rq, err := ApproveRoleRequest(5, 3, now)
+	require.NoError(t, err)
+	assert.Equal(t, RoleRequestApproved, rq.Status)
+	assert.Equal(t, approver, rq.DecidedBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApproveTemporaryRoleRequestKeepsPermanentRole(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	expires := now.Add(7 * 24 * time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM role_requests rq`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(roleRequestRowColumns).
+			AddRow(5, 7, "jdoe", 2, "admin", expires, 1, "alice", now.Add(-time.Hour), RoleRequestPending, nil, nil, nil))
+	mock.ExpectExec(`UPDATE role_requests SET status`).
+		WithArgs(RoleRequestApproved, 3, now, sql.NullString{}, 5, RoleRequestPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The user already holds the role with no expiry, so it is neither updated nor assigned again
+	mock.ExpectQuery(`SELECT expires_at FROM user_roles`).WithArgs(7, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"expires_at"}).AddRow(nil))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 3, Valid: true}, AuditRoleApproved, "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	mock.ExpectQuery(`SELECT email FROM users`).WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("alice@example.com"))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	rq, err := ApproveRoleRequest(5, 3, now)
+	require.NoError(t, err)
+	assert.Equal(t, RoleRequestApproved, rq.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRejectDecidedRoleRequest(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM role_requests rq`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(roleRequestRowColumns).
+			AddRow(5, 7, "jdoe", 2, "admin", nil, 1, "alice", now, RoleRequestApproved, 3, now, nil))
+	mock.ExpectRollback()
+
+	_, err := RejectRoleRequest(5, 3, "no longer needed", now)
+	assert.Equal(t, ErrRoleRequestDecided, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -121,12 +121,22 @@ func TestDeleteUser(t *testing.T) {
 	defer cleanup()
 
 	userID := 1
+	actorID := sql.NullInt32{Int32: 2, Valid: true}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM users WHERE id = \$1`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(UserStatusSuspended))
+	mock.ExpectExec(`UPDATE users SET status = \$1`).WithArgs(UserStatusDeleted, userID, UserStatusSuspended).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, table := range []string{"user_sessions", "api_keys", "user_invitations", "trusted_devices"} {
+		mock.ExpectExec(table).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(actorID, AuditUserDeleted, "user", userID, sql.NullString{String: "Left the company", Valid: true}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
 
-	mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).
-		WithArgs(userID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	err := DeleteUser(userID)
+	err := DeleteUser(userID, actorID, "Left the company")
 	assert.NoError(t, err)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
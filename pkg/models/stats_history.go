@@ -0,0 +1,143 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// MaxStatHistoryDays is the longest history GET /api/stats/{name}/history returns
+const MaxStatHistoryDays = 730
+
+// StatHistory is a quick stat's daily snapshots over a date range. Series holds one value per
+// date for every field key, or nil where a snapshot has no value for the field.
+type StatHistory struct {
+	Name       string                   `json:"name"`
+	PropertyID int                      `json:"property_id"`
+	Fields     []StatField              `json:"fields"`
+	Dates      []string                 `json:"dates"`
+	Series     map[string][]interface{} `json:"series"`
+}
+
+// snapshotDate returns the date a snapshot taken at now is recorded under
+func snapshotDate(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// HasQuickStatSnapshot reports whether the stats have already been snapshotted on now's date
+func HasQuickStatSnapshot(now time.Time) (bool, error) {
+	var count int
+	err := db.DB.QueryRow("SELECT COUNT(*) FROM stats_history WHERE snapshot_date = $1", snapshotDate(now)).Scan(&count)
+	return count > 0, err
+}
+
+// SnapshotQuickStats records every registered stat for the whole portfolio and for each
+// property under now's date, replacing any snapshot already taken that day. It returns the
+// number of snapshots written.
+func SnapshotQuickStats(now time.Time) (int, error) {
+	rows, err := db.ReadDB().Query("SELECT id FROM properties ORDER BY id")
+	if err != nil {
+		return 0, err
+	}
+	propertyIDs := []int{0}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		propertyIDs = append(propertyIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	date := snapshotDate(now)
+	written := 0
+	for _, stat := range ListQuickStats() {
+		for _, propertyID := range propertyIDs {
+			properties := PropertyFilter{}
+			if propertyID != 0 {
+				properties = PropertyFilter{IDs: []int{propertyID}, Scoped: true}
+			}
+			values, err := ComputeQuickStat(stat, properties, now)
+			if err != nil {
+				return written, err
+			}
+			data, err := json.Marshal(values)
+			if err != nil {
+				return written, err
+			}
+			_, err = db.DB.Exec(`
+				INSERT INTO stats_history (stat_name, property_id, snapshot_date, stat_values)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (stat_name, property_id, snapshot_date) DO UPDATE SET stat_values = EXCLUDED.stat_values`,
+				stat.Name, propertyID, date, string(data))
+			if err != nil {
+				return written, fmt.Errorf("stat %s: %w", stat.Name, err)
+			}
+			written++
+		}
+	}
+	return written, nil
+}
+
+// GetQuickStatHistory returns the snapshots of a stat for one property, or the portfolio when
+// propertyID is 0, taken over the last days days up to now
+func GetQuickStatHistory(stat QuickStat, propertyID, days int, now time.Time) (*StatHistory, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT snapshot_date, stat_values FROM stats_history
+		WHERE stat_name = $1 AND property_id = $2 AND snapshot_date > $3
+		ORDER BY snapshot_date`, stat.Name, propertyID, snapshotDate(now).AddDate(0, 0, -days))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &StatHistory{
+		Name:       stat.Name,
+		PropertyID: propertyID,
+		Fields:     stat.Fields,
+		Dates:      []string{},
+		Series:     map[string][]interface{}{},
+	}
+	var snapshots []map[string]interface{}
+	for rows.Next() {
+		var date time.Time
+		var data []byte
+		if err := rows.Scan(&date, &data); err != nil {
+			return nil, err
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("stat %s snapshot for %s: %w", stat.Name, date.Format("2006-01-02"), err)
+		}
+		history.Dates = append(history.Dates, date.Format("2006-01-02"))
+		snapshots = append(snapshots, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Fields added since older snapshots were taken are padded with nil
+	keys := map[string]bool{}
+	for _, field := range stat.Fields {
+		keys[field.Key] = true
+	}
+	for _, values := range snapshots {
+		for key := range values {
+			keys[key] = true
+		}
+	}
+	for key := range keys {
+		series := make([]interface{}, len(snapshots))
+		for i, values := range snapshots {
+			series[i] = values[key]
+		}
+		history.Series[key] = series
+	}
+	return history, nil
+}
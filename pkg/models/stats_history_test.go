@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetQuickStatHistory(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	stat := QuickStat{Name: "maintenance", Fields: []StatField{
+		{Key: "open_requests", Format: StatFormatCount},
+		{Key: "total_cost", Format: StatFormatCurrency},
+	}}
+	now := time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT snapshot_date, stat_values FROM stats_history`).
+		WithArgs("maintenance", 3, time.Date(2026, 9, 16, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"snapshot_date", "stat_values"}).
+			AddRow(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), `{"open_requests": 4}`).
+			AddRow(time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), `{"open_requests": 6, "total_cost": 120.5, "sla_breached": 1}`))
+
+	history, err := GetQuickStatHistory(stat, 3, 30, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2026-10-14", "2026-10-15"}, history.Dates)
+	assert.Equal(t, []interface{}{4.0, 6.0}, history.Series["open_requests"])
+	assert.Equal(t, []interface{}{nil, 120.5}, history.Series["total_cost"])
+	assert.Equal(t, []interface{}{nil, 1.0}, history.Series["sla_breached"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHasQuickStatSnapshot(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM stats_history WHERE snapshot_date`).
+		WithArgs(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+
+	taken, err := HasQuickStatSnapshot(time.Date(2026, 10, 16, 23, 59, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.True(t, taken)
+}
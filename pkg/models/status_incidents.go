@@ -0,0 +1,140 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Status page incident statuses
+const (
+	IncidentInvestigating = "investigating"
+	IncidentIdentified    = "identified"
+	IncidentMonitoring    = "monitoring"
+	IncidentResolved      = "resolved"
+)
+
+// ErrInvalidIncident is returned for an incident without a title or with an unknown status
+var ErrInvalidIncident = errors.New("title is required and status must be investigating, identified, monitoring or resolved")
+
+// StatusIncident is a note about an ongoing or recent incident shown on the public status page
+type StatusIncident struct {
+	ID         int            `json:"id"`
+	Title      string         `json:"title"`
+	Message    string         `json:"message"`
+	Status     string         `json:"status"`
+	Component  sql.NullString `json:"component,omitempty"` // Affected status page component, if any
+	CreatedBy  sql.NullInt32  `json:"-"`
+	ResolvedAt sql.NullTime   `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// Validate trims the incident's title and checks it and the status, which defaults to investigating
+func (i *StatusIncident) Validate() error {
+	i.Title = strings.TrimSpace(i.Title)
+	if i.Status == "" {
+		i.Status = IncidentInvestigating
+	}
+	switch i.Status {
+	case IncidentInvestigating, IncidentIdentified, IncidentMonitoring, IncidentResolved:
+	default:
+		return ErrInvalidIncident
+	}
+	if i.Title == "" {
+		return ErrInvalidIncident
+	}
+	return nil
+}
+
+const statusIncidentColumns = `id, title, message, status, component, created_by, resolved_at, created_at, updated_at`
+
+func scanStatusIncident(row interface{ Scan(...interface{}) error }) (*StatusIncident, error) {
+	i := &StatusIncident{}
+	err := row.Scan(&i.ID, &i.Title, &i.Message, &i.Status, &i.Component, &i.CreatedBy, &i.ResolvedAt,
+		&i.CreatedAt, &i.UpdatedAt)
+	return i, err
+}
+
+// CreateStatusIncident posts an incident note
+func CreateStatusIncident(i *StatusIncident, now time.Time) error {
+	if err := i.Validate(); err != nil {
+		return err
+	}
+	i.ResolvedAt = sql.NullTime{}
+	if i.Status == IncidentResolved {
+		i.ResolvedAt = sql.NullTime{Time: now, Valid: true}
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO status_incidents (title, message, status, component, created_by, resolved_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`,
+		i.Title, i.Message, i.Status, i.Component, i.CreatedBy, i.ResolvedAt).Scan(&i.ID, &i.CreatedAt, &i.UpdatedAt)
+}
+
+// GetStatusIncident retrieves an incident by ID
+func GetStatusIncident(id int) (*StatusIncident, error) {
+	return scanStatusIncident(db.ReadDB().QueryRow(`
+		SELECT `+statusIncidentColumns+` FROM status_incidents WHERE id = $1`, id))
+}
+
+// GetStatusIncidents lists the unresolved incidents and those resolved since resolvedSince, newest
+// first
+func GetStatusIncidents(resolvedSince time.Time) ([]StatusIncident, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT `+statusIncidentColumns+`
+		FROM status_incidents
+		WHERE resolved_at IS NULL OR resolved_at >= $1
+		ORDER BY created_at DESC, id DESC`, resolvedSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var incidents []StatusIncident
+	for rows.Next() {
+		i, err := scanStatusIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, *i)
+	}
+	return incidents, rows.Err()
+}
+
+// UpdateStatusIncident changes an incident's note and status. Resolving it records when; reopening
+// it clears that.
+func UpdateStatusIncident(i *StatusIncident, now time.Time) error {
+	if err := i.Validate(); err != nil {
+		return err
+	}
+	switch {
+	case i.Status == IncidentResolved && !i.ResolvedAt.Valid:
+		i.ResolvedAt = sql.NullTime{Time: now, Valid: true}
+	case i.Status != IncidentResolved:
+		i.ResolvedAt = sql.NullTime{}
+	}
+	i.UpdatedAt = now
+
+	result, err := db.DB.Exec(`
+		UPDATE status_incidents
+		SET title = $1, message = $2, status = $3, component = $4, resolved_at = $5, updated_at = $6
+		WHERE id = $7`,
+		i.Title, i.Message, i.Status, i.Component, i.ResolvedAt, now, i.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteStatusIncident removes an incident note
+func DeleteStatusIncident(id int) error {
+	result, err := db.DB.Exec("DELETE FROM status_incidents WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusIncidentValidate(t *testing.T) {
+	incident := &StatusIncident{Title: "  Email delays  "}
+	require.NoError(t, incident.Validate())
+	assert.Equal(t, "Email delays", incident.Title)
+	assert.Equal(t, IncidentInvestigating, incident.Status)
+
+	assert.Equal(t, ErrInvalidIncident, (&StatusIncident{Title: " "}).Validate())
+	assert.Equal(t, ErrInvalidIncident, (&StatusIncident{Title: "Outage", Status: "fixed"}).Validate())
+}
+
+func TestUpdateStatusIncidentRecordsResolution(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	incident := &StatusIncident{ID: 2, Title: "Email delays", Status: IncidentResolved}
+
+	mock.ExpectExec(`UPDATE status_incidents`).
+		WithArgs("Email delays", "", IncidentResolved, sql.NullString{}, sql.NullTime{Time: now, Valid: true}, now, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, UpdateStatusIncident(incident, now))
+	assert.Equal(t, now, incident.ResolvedAt.Time)
+
+	// Reopening clears the resolution
+	incident.Status = IncidentMonitoring
+	mock.ExpectExec(`UPDATE status_incidents`).
+		WithArgs("Email delays", "", IncidentMonitoring, sql.NullString{}, sql.NullTime{}, now, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, UpdateStatusIncident(incident, now))
+	assert.False(t, incident.ResolvedAt.Valid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// syncOverlap is how far before since a sync looks again. updated_at is stamped when a write
+// transaction starts, so a row can commit after a watermark that is later than its updated_at;
+// re-reading the overlap picks such rows up. Clients upsert by id, so repeats are harmless.
+const syncOverlap = 5 * time.Minute
+
+// SyncChanges are the entities created or updated since a mobile client last synced. Deleted
+// entities are not reported; clients drop them when a full sync (no since) no longer lists them.
+type SyncChanges struct {
+	Since               time.Time                `json:"since"`
+	ServerTime          time.Time                `json:"server_time"` // Pass back as since on the next sync
+	Properties          []SyncProperty           `json:"properties"`
+	Units               []SyncUnit               `json:"units"`
+	Tenants             []SyncTenant             `json:"tenants"`
+	Leases              []SyncLease              `json:"leases"`
+	MaintenanceRequests []SyncMaintenanceRequest `json:"maintenance_requests"`
+}
+
+// SyncProperty is a property as sent to mobile clients
+type SyncProperty struct {
+	ID           int         `json:"id"`
+	Name         string      `json:"name"`
+	Address      string      `json:"address"`
+	PropertyType string      `json:"property_type"`
+	Tags         StringArray `json:"tags"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// SyncUnit is a property unit as sent to mobile clients
+type SyncUnit struct {
+	ID          int       `json:"id"`
+	PropertyID  int       `json:"property_id"`
+	UnitNumber  *string   `json:"unit_number"`
+	Bedrooms    int       `json:"bedrooms"`
+	Bathrooms   int       `json:"bathrooms"`
+	Description *string   `json:"description"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SyncTenant is a tenant as sent to mobile clients
+type SyncTenant struct {
+	ID          int       `json:"id"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	Email       string    `json:"email"`
+	PhoneNumber *string   `json:"phone_number"`
+	Status      string    `json:"status"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SyncLease is a lease as sent to mobile clients
+type SyncLease struct {
+	ID          int       `json:"id"`
+	UnitID      int       `json:"unit_id"`
+	TenantID    int       `json:"tenant_id"`
+	StartDate   time.Time `json:"start_date"`
+	EndDate     time.Time `json:"end_date"`
+	MonthlyRent float64   `json:"monthly_rent"`
+	Status      string    `json:"status"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SyncMaintenanceRequest is a maintenance request as sent to mobile clients
+type SyncMaintenanceRequest struct {
+	ID            int        `json:"id"`
+	PropertyID    int        `json:"property_id"`
+	Description   string     `json:"description"`
+	Status        string     `json:"status"`
+	Priority      *string    `json:"priority"`
+	ReportedDate  time.Time  `json:"reported_date"`
+	CompletedDate *time.Time `json:"completed_date"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// GetSyncChanges returns the entities at properties updated at or after since (less syncOverlap);
+// a zero since returns everything. Tenants are included while they lease at one of the properties.
+// It reads the primary in one transaction and takes ServerTime from the database clock at its
+// start, so replica lag and application clock skew cannot move the watermark past unseen rows.
+func GetSyncChanges(since time.Time, properties PropertyFilter) (*SyncChanges, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var now flexibleTime
+	if err := tx.QueryRow("SELECT NOW()").Scan(&now); err != nil {
+		return nil, err
+	}
+	from := since
+	if !since.IsZero() {
+		from = since.Add(-syncOverlap)
+	}
+
+	changes := &SyncChanges{
+		Since:               since,
+		ServerTime:          now.UTC(),
+		Properties:          []SyncProperty{},
+		Units:               []SyncUnit{},
+		Tenants:             []SyncTenant{},
+		Leases:              []SyncLease{},
+		MaintenanceRequests: []SyncMaintenanceRequest{},
+	}
+
+	scope, args := properties.condition("id", []interface{}{from})
+	rows, err := tx.Query(`
+		SELECT id, name, address, property_type, tags, updated_at
+		FROM properties WHERE updated_at >= $1`+scope+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p SyncProperty
+		if err := rows.Scan(&p.ID, &p.Name, &p.Address, &p.PropertyType, &p.Tags, &p.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changes.Properties = append(changes.Properties, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	scope, args = properties.condition("property_id", []interface{}{from})
+	rows, err = tx.Query(`
+		SELECT id, property_id, unit_number, bedrooms, bathrooms, description, updated_at
+		FROM property_units WHERE updated_at >= $1`+scope+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var u SyncUnit
+		if err := rows.Scan(&u.ID, &u.PropertyID, &u.UnitNumber, &u.Bedrooms, &u.Bathrooms, &u.Description,
+			&u.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changes.Units = append(changes.Units, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tenantScope := ""
+	scope, args = properties.condition("pu.property_id", []interface{}{from})
+	if scope != "" {
+		tenantScope = ` AND EXISTS (SELECT 1 FROM leases l JOIN property_units pu ON pu.id = l.unit_id
+			WHERE l.tenant_id = tenants.id` + scope + `)`
+	}
+	rows, err = tx.Query(`
+		SELECT id, first_name, last_name, email, phone_number, status, updated_at
+		FROM tenants WHERE updated_at >= $1`+tenantScope+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t SyncTenant
+		if err := rows.Scan(&t.ID, &t.FirstName, &t.LastName, &t.Email, &t.PhoneNumber, &t.Status,
+			&t.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changes.Tenants = append(changes.Tenants, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	scope, args = properties.condition("(SELECT property_id FROM property_units WHERE id = leases.unit_id)",
+		[]interface{}{from})
+	rows, err = tx.Query(`
+		SELECT id, unit_id, tenant_id, start_date, end_date, monthly_rent, status, updated_at
+		FROM leases WHERE updated_at >= $1`+scope+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var l SyncLease
+		if err := rows.Scan(&l.ID, &l.UnitID, &l.TenantID, &l.StartDate, &l.EndDate, &l.MonthlyRent, &l.Status,
+			&l.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changes.Leases = append(changes.Leases, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	scope, args = properties.condition("property_id", []interface{}{from})
+	rows, err = tx.Query(`
+		SELECT id, property_id, description, status, priority, reported_date, completed_date, updated_at
+		FROM maintenance_requests WHERE updated_at >= $1`+scope+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m SyncMaintenanceRequest
+		if err := rows.Scan(&m.ID, &m.PropertyID, &m.Description, &m.Status, &m.Priority, &m.ReportedDate,
+			&m.CompletedDate, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		changes.MaintenanceRequests = append(changes.MaintenanceRequests, m)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSyncChangesUsesDatabaseWatermark(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	dbNow := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	from := since.Add(-syncOverlap)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT NOW\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"now"}).AddRow(dbNow))
+	mock.ExpectQuery(`FROM properties WHERE updated_at >= \$1`).
+		WithArgs(from).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "address", "property_type", "tags", "updated_at"}))
+	mock.ExpectQuery(`FROM property_units WHERE updated_at >= \$1`).
+		WithArgs(from).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "property_id", "unit_number", "bedrooms", "bathrooms",
+			"description", "updated_at"}).
+			AddRow(4, 7, nil, 2, 1, "Corner unit", since.Add(-time.Minute)))
+	mock.ExpectQuery(`FROM tenants WHERE updated_at >= \$1`).
+		WithArgs(from).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "phone_number", "status",
+			"updated_at"}))
+	mock.ExpectQuery(`FROM leases WHERE updated_at >= \$1`).
+		WithArgs(from).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "unit_id", "tenant_id", "start_date", "end_date",
+			"monthly_rent", "status", "updated_at"}))
+	mock.ExpectQuery(`FROM maintenance_requests WHERE updated_at >= \$1`).
+		WithArgs(from).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "property_id", "description", "status", "priority",
+			"reported_date", "completed_date", "updated_at"}))
+	mock.ExpectRollback()
+
+	changes, err := GetSyncChanges(since, PropertyFilter{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, dbNow, changes.ServerTime)
+	body, err := json.Marshal(changes.Units)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"unit_number":null`)
+	assert.Contains(t, string(body), `"description":"Corner unit"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// GetTenantForUser finds the tenant record belonging to a logged-in user by matching email addresses.
+// It returns sql.ErrNoRows if the user is not a tenant.
+func GetTenantForUser(user *User) (*Tenant, error) {
+	var tenant Tenant
+	var phone sql.NullString
+	err := db.DB.QueryRow(`
+		SELECT id, first_name, last_name, email, phone_number, status, created_at, updated_at
+		FROM tenants
+		WHERE LOWER(email) = LOWER($1)`, user.Email).
+		Scan(&tenant.ID, &tenant.FirstName, &tenant.LastName, &tenant.Email, &phone,
+			&tenant.Status, &tenant.CreatedAt, &tenant.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	tenant.PhoneNumber = phone.String
+	return &tenant, nil
+}
+
+// GetTenantPropertyIDs returns the properties where a tenant holds an active lease
+func GetTenantPropertyIDs(tenantID int) ([]int, error) {
+	rows, err := db.DB.Query(`
+		SELECT DISTINCT pu.property_id
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		WHERE l.tenant_id = $1 AND l.status = 'active'
+		ORDER BY pu.property_id`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var propertyIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		propertyIDs = append(propertyIDs, id)
+	}
+	return propertyIDs, nil
+}
+
+// GetLeaseTenantID returns the tenant holding a lease
+func GetLeaseTenantID(leaseID int) (int, error) {
+	var tenantID int
+	err := db.DB.QueryRow("SELECT tenant_id FROM leases WHERE id = $1", leaseID).Scan(&tenantID)
+	return tenantID, err
+}
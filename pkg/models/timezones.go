@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ErrInvalidTimezone is returned for a time zone name that is not in the IANA database
+var ErrInvalidTimezone = errors.New("unknown time zone, expected an IANA name such as America/New_York")
+
+// ValidateTimezone checks a time zone name. An empty name is valid and means none is set.
+func ValidateTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	// LoadLocation also accepts "Local", which would tie schedules to the server's zone
+	if name == "Local" {
+		return ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
+
+// LoadTimezone returns the location of a time zone name, or UTC when the name is empty or invalid
+func LoadTimezone(name string) *time.Location {
+	if name == "" || name == "Local" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// LocalDate returns the calendar date it is in loc at t, as midnight UTC like the DATE columns it is
+// compared with
+func LocalDate(t time.Time, loc *time.Location) time.Time {
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// GetOrganizationTimezone returns an organization's time zone, or "" when none is set
+func GetOrganizationTimezone(orgID int) (string, error) {
+	var tz sql.NullString
+	err := db.ReadDB().QueryRow("SELECT timezone FROM organizations WHERE id = $1", orgID).Scan(&tz)
+	return tz.String, err
+}
+
+// GetUserOrganizationTimezone returns the time zone of a user's organization, or "" when none is set
+func GetUserOrganizationTimezone(userID int) (string, error) {
+	orgID, err := UserOrganizationID(userID)
+	if err != nil {
+		return "", err
+	}
+	tz, err := GetOrganizationTimezone(orgID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return tz, err
+}
+
+// SetOrganizationTimezone sets the time zone an organization's schedules run in; "" clears it
+func SetOrganizationTimezone(orgID int, tz string) error {
+	if err := ValidateTimezone(tz); err != nil {
+		return err
+	}
+	result, err := db.DB.Exec("UPDATE organizations SET timezone = $1 WHERE id = $2", NullString(tz), orgID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetPropertyTimezone returns a property's time zone, or "" when none is set
+func GetPropertyTimezone(propertyID int) (string, error) {
+	var tz sql.NullString
+	err := db.ReadDB().QueryRow("SELECT timezone FROM properties WHERE id = $1", propertyID).Scan(&tz)
+	return tz.String, err
+}
+
+// SetPropertyTimezone sets the time zone a property's schedules run in; "" clears it
+func SetPropertyTimezone(propertyID int, tz string) error {
+	if err := ValidateTimezone(tz); err != nil {
+		return err
+	}
+	result, err := db.DB.Exec("UPDATE properties SET timezone = $1 WHERE id = $2", NullString(tz), propertyID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetPropertyTimezones returns the location of every property with a time zone set, by property ID
+func GetPropertyTimezones() (map[int]*time.Location, error) {
+	rows, err := db.ReadDB().Query("SELECT id, timezone FROM properties WHERE timezone IS NOT NULL AND timezone <> ''")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timezones := map[int]*time.Location{}
+	for rows.Next() {
+		var id int
+		var tz string
+		if err := rows.Scan(&id, &tz); err != nil {
+			return nil, err
+		}
+		timezones[id] = LoadTimezone(tz)
+	}
+	return timezones, rows.Err()
+}
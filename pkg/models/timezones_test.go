@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTimezone(t *testing.T) {
+	assert.NoError(t, ValidateTimezone(""))
+	assert.NoError(t, ValidateTimezone("America/New_York"))
+	assert.Equal(t, ErrInvalidTimezone, ValidateTimezone("Local"))
+	assert.Equal(t, ErrInvalidTimezone, ValidateTimezone("Eastern"))
+	assert.Equal(t, time.UTC, LoadTimezone("Eastern"))
+}
+
+func TestLocalDate(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// 20:00 UTC on 31 October is already 1 November in Tokyo
+	now := time.Date(2026, 10, 31, 20, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), LocalDate(now, tokyo))
+	assert.Equal(t, time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC), LocalDate(now, time.UTC))
+}
+
+func TestSetPropertyTimezone(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	assert.Equal(t, ErrInvalidTimezone, SetPropertyTimezone(3, "Mars/Olympus_Mons"))
+
+	mock.ExpectExec(`UPDATE properties SET timezone = \$1 WHERE id = \$2`).
+		WithArgs("Europe/Berlin", 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, SetPropertyTimezone(3, "Europe/Berlin"))
+
+	// An empty zone clears it
+	mock.ExpectExec(`UPDATE properties SET timezone = \$1 WHERE id = \$2`).
+		WithArgs(nil, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, SetPropertyTimezone(3, ""))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Polling triggers return items newest first with a stable integer "id" so that
+// Zapier and Make can deduplicate items they have already seen. Callers may pass
+// the highest id they have processed as a cursor to receive only newer items.
+
+// MaintenanceTrigger is a maintenance request as returned by the new-maintenance trigger
+type MaintenanceTrigger struct {
+	ID           int       `json:"id"`
+	PropertyID   int       `json:"property_id"`
+	PropertyName string    `json:"property_name"`
+	Description  string    `json:"description"`
+	Status       string    `json:"status"`
+	Priority     string    `json:"priority"`
+	ReportedDate time.Time `json:"reported_date"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PaymentTrigger is a payment as returned by the new-payment trigger
+type PaymentTrigger struct {
+	ID            int       `json:"id"`
+	LeaseID       int       `json:"lease_id"`
+	TenantName    string    `json:"tenant_name"`
+	TenantEmail   string    `json:"tenant_email"`
+	PropertyName  string    `json:"property_name"`
+	UnitNumber    string    `json:"unit_number"`
+	Amount        float64   `json:"amount"`
+	PaymentDate   time.Time `json:"payment_date"`
+	PaymentMethod string    `json:"payment_method"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// LeaseExpiringTrigger is an active lease as returned by the lease-expiring trigger
+type LeaseExpiringTrigger struct {
+	ID              int       `json:"id"`
+	TenantName      string    `json:"tenant_name"`
+	TenantEmail     string    `json:"tenant_email"`
+	PropertyName    string    `json:"property_name"`
+	UnitNumber      string    `json:"unit_number"`
+	StartDate       time.Time `json:"start_date"`
+	EndDate         time.Time `json:"end_date"`
+	MonthlyRent     float64   `json:"monthly_rent"`
+	DaysUntilExpiry int       `json:"days_until_expiry"`
+}
+
+// GetNewMaintenanceTriggers retrieves maintenance requests with an id greater than cursor
+func GetNewMaintenanceTriggers(cursor, limit int) ([]MaintenanceTrigger, error) {
+	query := `
+		SELECT m.id, m.property_id, p.name, m.description, m.status,
+			   COALESCE(m.priority, ''), m.reported_date, m.created_at
+		FROM maintenance_requests m
+		JOIN properties p ON m.property_id = p.id
+		WHERE m.id > $1
+		ORDER BY m.id DESC
+		LIMIT $2`
+
+	rows, err := db.DB.Query(query, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []MaintenanceTrigger
+	for rows.Next() {
+		var item MaintenanceTrigger
+		err := rows.Scan(&item.ID, &item.PropertyID, &item.PropertyName, &item.Description,
+			&item.Status, &item.Priority, &item.ReportedDate, &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// GetNewPaymentTriggers retrieves payments with an id greater than cursor
+func GetNewPaymentTriggers(cursor, limit int) ([]PaymentTrigger, error) {
+	query := `
+		SELECT pay.id, pay.lease_id, t.first_name || ' ' || t.last_name, t.email,
+			   p.name, COALESCE(pu.unit_number, ''), pay.amount, pay.payment_date,
+			   COALESCE(pay.payment_method, ''), pay.status, pay.created_at
+		FROM payments pay
+		JOIN leases l ON pay.lease_id = l.id
+		JOIN tenants t ON l.tenant_id = t.id
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		WHERE pay.id > $1
+		ORDER BY pay.id DESC
+		LIMIT $2`
+
+	rows, err := db.DB.Query(query, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []PaymentTrigger
+	for rows.Next() {
+		var item PaymentTrigger
+		err := rows.Scan(&item.ID, &item.LeaseID, &item.TenantName, &item.TenantEmail,
+			&item.PropertyName, &item.UnitNumber, &item.Amount, &item.PaymentDate,
+			&item.PaymentMethod, &item.Status, &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// GetLeaseExpiringTriggers retrieves active leases ending within the given number of days.
+// Each lease appears once, keyed by its id, so an automation fires once per expiring lease.
+func GetLeaseExpiringTriggers(withinDays, cursor, limit int) ([]LeaseExpiringTrigger, error) {
+	today := time.Now().Truncate(24 * time.Hour)
+	until := today.AddDate(0, 0, withinDays)
+
+	query := `
+		SELECT l.id, t.first_name || ' ' || t.last_name, t.email, p.name,
+			   COALESCE(pu.unit_number, ''), l.start_date, l.end_date, l.monthly_rent
+		FROM leases l
+		JOIN tenants t ON l.tenant_id = t.id
+		JOIN property_units pu ON l.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		WHERE l.status = 'active' AND l.end_date >= $1 AND l.end_date <= $2 AND l.id > $3
+		ORDER BY l.id DESC
+		LIMIT $4`
+
+	rows, err := db.DB.Query(query, today, until, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []LeaseExpiringTrigger
+	for rows.Next() {
+		var item LeaseExpiringTrigger
+		err := rows.Scan(&item.ID, &item.TenantName, &item.TenantEmail, &item.PropertyName,
+			&item.UnitNumber, &item.StartDate, &item.EndDate, &item.MonthlyRent)
+		if err != nil {
+			return nil, err
+		}
+		item.DaysUntilExpiry = int(item.EndDate.Sub(today).Hours() / 24)
+		items = append(items, item)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// TrustedDeviceDays is how long a device stays trusted after the user passes MFA on it
+const TrustedDeviceDays = 30
+
+// Audit actions recorded for trusted device changes
+const (
+	AuditTrustedDeviceGranted = "trusted_device.granted"
+	AuditTrustedDeviceRevoked = "trusted_device.revoked"
+)
+
+// TrustedDevice is a browser on which a user skips MFA
+type TrustedDevice struct {
+	ID         int            `json:"id"`
+	UserID     int            `json:"user_id"`
+	UserAgent  sql.NullString `json:"user_agent,omitempty"`
+	IPAddress  sql.NullString `json:"ip_address,omitempty"`
+	LastUsedAt sql.NullTime   `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Current    bool           `json:"current"` // Whether this is the device making the request
+}
+
+// CreateTrustedDevice trusts the device for TrustedDeviceDays and records the grant in the
+// audit log. It returns the plaintext token for the device cookie, which is not stored.
+func CreateTrustedDevice(userID int, userAgent, ipAddress string, now time.Time) (string, *TrustedDevice, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(bytes)
+
+	device := &TrustedDevice{
+		UserID:    userID,
+		UserAgent: NullString(userAgent),
+		IPAddress: NullString(ipAddress),
+		ExpiresAt: now.AddDate(0, 0, TrustedDeviceDays),
+		Current:   true,
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return "", nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO trusted_devices (user_id, token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		userID, HashAPIKey(token), device.UserAgent, device.IPAddress, device.ExpiresAt).
+		Scan(&device.ID, &device.CreatedAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    sql.NullInt32{Int32: int32(userID), Valid: true},
+		Action:     AuditTrustedDeviceGranted,
+		EntityType: "user",
+		EntityID:   userID,
+		Details: map[string]interface{}{
+			"device_id":  device.ID,
+			"user_agent": userAgent,
+			"ip_address": ipAddress,
+			"expires_at": device.ExpiresAt,
+		},
+	}); err != nil {
+		return "", nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", nil, err
+	}
+	return token, device, nil
+}
+
+// UseTrustedDevice checks that the device is trusted by the user with the given token and has
+// not expired or been revoked, and records that it was used. It returns sql.ErrNoRows when the
+// device is not trusted.
+func UseTrustedDevice(userID, deviceID int, token string, now time.Time) error {
+	result, err := db.DB.Exec(`
+		UPDATE trusted_devices SET last_used_at = $1
+		WHERE id = $2 AND user_id = $3 AND token_hash = $4 AND revoked_at IS NULL AND expires_at > $1`,
+		now, deviceID, userID, HashAPIKey(token))
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetTrustedDevices returns the user's devices that are still trusted, most recently used first
+func GetTrustedDevices(userID int, now time.Time) ([]TrustedDevice, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT id, user_id, user_agent, ip_address, last_used_at, expires_at, created_at
+		FROM trusted_devices
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY COALESCE(last_used_at, created_at) DESC, id DESC`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []TrustedDevice
+	for rows.Next() {
+		var device TrustedDevice
+		if err := rows.Scan(&device.ID, &device.UserID, &device.UserAgent, &device.IPAddress,
+			&device.LastUsedAt, &device.ExpiresAt, &device.CreatedAt); err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// RevokeTrustedDevice stops trusting one of the user's devices and records the revocation in the
+// audit log. It returns sql.ErrNoRows when the user has no such trusted device.
+func RevokeTrustedDevice(userID, deviceID, actorID int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE trusted_devices SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, deviceID, userID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    sql.NullInt32{Int32: int32(actorID), Valid: true},
+		Action:     AuditTrustedDeviceRevoked,
+		EntityType: "user",
+		EntityID:   userID,
+		Details:    map[string]interface{}{"device_id": deviceID},
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeTrustedDevices stops trusting all of the user's devices, recording the revocation in the
+// audit log when there were any, and returns how many were revoked
+func RevokeTrustedDevices(userID, actorID int) (int, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE trusted_devices SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if revoked == 0 {
+		return 0, nil
+	}
+
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    sql.NullInt32{Int32: int32(actorID), Valid: true},
+		Action:     AuditTrustedDeviceRevoked,
+		EntityType: "user",
+		EntityID:   userID,
+		Details:    map[string]interface{}{"devices_revoked": revoked},
+	}); err != nil {
+		return 0, err
+	}
+	return int(revoked), tx.Commit()
+}
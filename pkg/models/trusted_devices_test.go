@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTrustedDevice(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	expires := time.Date(2026, 11, 15, 9, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO trusted_devices`).
+		WithArgs(7, sqlmock.AnyArg(), NullString("Firefox"), NullString("10.0.0.1"), expires).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, now))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 7, Valid: true}, AuditTrustedDeviceGranted, "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	mock.ExpectCommit()
+
+	token, device, err := CreateTrustedDevice(7, "Firefox", "10.0.0.1", now)
+	require.NoError(t, err)
+	assert.Len(t, token, 64)
+	assert.Equal(t, 3, device.ID)
+	assert.Equal(t, expires, device.ExpiresAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUseTrustedDeviceRejectsUnknownToken(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`UPDATE trusted_devices SET last_used_at`).
+		WithArgs(now, 3, 7, HashAPIKey("wrong")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Equal(t, sql.ErrNoRows, UseTrustedDevice(7, 3, "wrong", now))
+}
+
+func TestRevokeTrustedDevices(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE trusted_devices SET revoked_at = NOW\(\)`).WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 1, Valid: true}, AuditTrustedDeviceRevoked, "user", 7, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+
+	revoked, err := RevokeTrustedDevices(7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, revoked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeTrustedDeviceNotFound(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE trusted_devices SET revoked_at = NOW\(\)`).WithArgs(9, 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	assert.Equal(t, sql.ErrNoRows, RevokeTrustedDevice(7, 9, 7))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,468 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// ErrInvalidTurnover is returned when make-ready details are impossible
+var ErrInvalidTurnover = errors.New("a turnover's make-ready cost must be zero or more and its ready date no earlier than the move-out")
+
+// Turnover statuses
+const (
+	TurnoverVacant = "vacant"
+	TurnoverLeased = "leased"
+)
+
+// UnitTurnover is a unit's vacancy between a move-out and the next move-in, with the cost of
+// making it ready and the rent lost while it stood empty
+type UnitTurnover struct {
+	ID             int            `json:"id"`
+	UnitID         int            `json:"unit_id"`
+	UnitNumber     string         `json:"unit_number"`
+	PropertyID     int            `json:"property_id"`
+	PropertyName   string         `json:"property_name"`
+	MoveOutLeaseID sql.NullInt32  `json:"move_out_lease_id,omitempty"`
+	MoveInLeaseID  sql.NullInt32  `json:"move_in_lease_id,omitempty"`
+	MoveOutDate    time.Time      `json:"move_out_date"`
+	ReadyDate      sql.NullTime   `json:"ready_date,omitempty"`
+	MoveInDate     sql.NullTime   `json:"move_in_date,omitempty"`
+	MakeReadyCost  float64        `json:"make_ready_cost"`
+	MarketRent     float64        `json:"market_rent"`
+	Notes          sql.NullString `json:"notes,omitempty"`
+	Status         string         `json:"status"`
+	DaysVacant     int            `json:"days_vacant"`     // Through the move-in, or as of today while vacant
+	MakeReadyDays  sql.NullInt32  `json:"make_ready_days"` // From move-out to ready
+	LostRent       float64        `json:"lost_rent"`       // Market rent for the days vacant
+	VacancyCost    float64        `json:"vacancy_cost"`    // Lost rent plus make-ready cost
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// vacantDays counts the days from the day after move-out through the day before move-in, or
+// through asOf while the unit is still vacant
+func (t UnitTurnover) vacantDays(from, to time.Time) int {
+	first := t.MoveOutDate.AddDate(0, 0, 1)
+	if first.Before(from) {
+		first = from
+	}
+	last := to
+	if t.MoveInDate.Valid && t.MoveInDate.Time.AddDate(0, 0, -1).Before(last) {
+		last = t.MoveInDate.Time.AddDate(0, 0, -1)
+	}
+	if last.Before(first) {
+		return 0
+	}
+	return int(last.Sub(first).Hours()/24) + 1
+}
+
+// dailyMarketRent converts the monthly market rent to a daily rate
+func (t UnitTurnover) dailyMarketRent() float64 {
+	return t.MarketRent * 12 / 365
+}
+
+// LostRentBetween returns the market rent lost to the vacancy on the days from from through to
+func (t UnitTurnover) LostRentBetween(from, to time.Time) float64 {
+	return math.Round(t.dailyMarketRent()*float64(t.vacantDays(from, to))*100) / 100
+}
+
+// computeCosts fills in the status, days vacant, make-ready days and costs as of asOf
+func (t *UnitTurnover) computeCosts(asOf time.Time) {
+	t.Status = TurnoverVacant
+	if t.MoveInDate.Valid && !asOf.Before(t.MoveInDate.Time) {
+		t.Status = TurnoverLeased
+	}
+	t.DaysVacant = t.vacantDays(t.MoveOutDate, asOf)
+	t.LostRent = t.LostRentBetween(t.MoveOutDate, asOf)
+	t.VacancyCost = math.Round((t.LostRent+t.MakeReadyCost)*100) / 100
+	t.MakeReadyDays = sql.NullInt32{}
+	if t.ReadyDate.Valid {
+		days := int32(t.ReadyDate.Time.Sub(t.MoveOutDate).Hours() / 24)
+		t.MakeReadyDays = sql.NullInt32{Int32: days, Valid: true}
+	}
+}
+
+const turnoverColumns = `t.id, t.unit_id, pu.unit_number, pu.property_id, p.name, t.move_out_lease_id, t.move_in_lease_id,
+	t.move_out_date, t.ready_date, t.move_in_date, t.make_ready_cost, t.market_rent, t.notes, t.created_at, t.updated_at`
+
+const turnoverJoins = `
+	FROM unit_turnovers t
+	JOIN property_units pu ON t.unit_id = pu.id
+	JOIN properties p ON pu.property_id = p.id`
+
+func scanUnitTurnover(row interface{ Scan(...interface{}) error }, asOf time.Time) (UnitTurnover, error) {
+	var t UnitTurnover
+	err := row.Scan(&t.ID, &t.UnitID, &t.UnitNumber, &t.PropertyID, &t.PropertyName, &t.MoveOutLeaseID, &t.MoveInLeaseID,
+		&t.MoveOutDate, &t.ReadyDate, &t.MoveInDate, &t.MakeReadyCost, &t.MarketRent, &t.Notes, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return t, err
+	}
+	t.computeCosts(asOf)
+	return t, nil
+}
+
+// GetUnitTurnover retrieves a turnover by ID with its costs as of today
+func GetUnitTurnover(id int) (*UnitTurnover, error) {
+	t, err := scanUnitTurnover(db.ReadDB().QueryRow(`
+		SELECT `+turnoverColumns+turnoverJoins+`
+		WHERE t.id = $1`, id), currentDate())
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// TurnoverFilter selects turnovers by move-out date and property. Zero dates are unbounded.
+type TurnoverFilter struct {
+	PropertyIDs []interface{}
+	From        time.Time
+	To          time.Time
+	VacantAfter time.Time // Only turnovers still vacant on or after this date
+}
+
+// GetUnitTurnovers returns the turnovers matching the filter, most recent move-out first, with
+// their costs as of asOf
+func GetUnitTurnovers(filter TurnoverFilter, asOf time.Time) ([]UnitTurnover, error) {
+	query := `SELECT ` + turnoverColumns + turnoverJoins + ` WHERE 1=1`
+	var args []interface{}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += fmt.Sprintf(" AND t.move_out_date >= $%d", len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		query += fmt.Sprintf(" AND t.move_out_date <= $%d", len(args))
+	}
+	if !filter.VacantAfter.IsZero() {
+		args = append(args, filter.VacantAfter)
+		query += fmt.Sprintf(" AND (t.move_in_date IS NULL OR t.move_in_date > $%d)", len(args))
+	}
+	if len(filter.PropertyIDs) > 0 {
+		placeholders := make([]string, len(filter.PropertyIDs))
+		for i, id := range filter.PropertyIDs {
+			args = append(args, id)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		query += fmt.Sprintf(" AND pu.property_id IN (%s)", strings.Join(placeholders, ", "))
+	}
+	query += " ORDER BY t.move_out_date DESC, t.id DESC"
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var turnovers []UnitTurnover
+	for rows.Next() {
+		t, err := scanUnitTurnover(rows, asOf)
+		if err != nil {
+			return nil, err
+		}
+		turnovers = append(turnovers, t)
+	}
+	return turnovers, rows.Err()
+}
+
+// UpdateUnitTurnoverMakeReady records when a unit was made ready, what it cost and notes on the
+// work. It returns sql.ErrNoRows when the turnover does not exist.
+func UpdateUnitTurnoverMakeReady(id int, readyDate sql.NullTime, cost float64, notes sql.NullString) error {
+	if cost < 0 {
+		return ErrInvalidTurnover
+	}
+	var moveOut time.Time
+	if err := db.DB.QueryRow("SELECT move_out_date FROM unit_turnovers WHERE id = $1", id).Scan(&moveOut); err != nil {
+		return err
+	}
+	if readyDate.Valid && readyDate.Time.Before(moveOut) {
+		return ErrInvalidTurnover
+	}
+	result, err := db.DB.Exec(`
+		UPDATE unit_turnovers SET ready_date = $2, make_ready_cost = $3, notes = $4, updated_at = NOW()
+		WHERE id = $1`, id, readyDate, cost, notes)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// TurnoverSyncResult counts the turnovers a sync opened and closed
+type TurnoverSyncResult struct {
+	Opened int `json:"opened"`
+	Closed int `json:"closed"`
+}
+
+// SyncUnitTurnovers opens a turnover for every lease whose term ended by now and whose tenant did
+// not renew on the unit, and closes open turnovers at the start of the unit's next lease. Market rent is the
+// unit's, then its floorplan's, then the ended lease's rent. Syncing is idempotent, so the first
+// run also backfills past turnovers.
+func SyncUnitTurnovers(now time.Time) (*TurnoverSyncResult, error) {
+	result := &TurnoverSyncResult{}
+
+	res, err := db.DB.Exec(`
+		INSERT INTO unit_turnovers (unit_id, move_out_lease_id, move_out_date, market_rent)
+		SELECT l.unit_id, l.id, l.end_date, COALESCE(pu.market_rent, f.market_rent, l.monthly_rent)
+		FROM leases l
+		JOIN property_units pu ON l.unit_id = pu.id
+		LEFT JOIN floorplans f ON pu.floorplan_id = f.id
+		WHERE l.status IN ('active', 'ended') AND l.end_date <= $1
+		  AND NOT EXISTS (SELECT 1 FROM unit_turnovers t WHERE t.move_out_lease_id = l.id)
+		  AND NOT EXISTS (SELECT 1 FROM leases renewal
+						  WHERE renewal.unit_id = l.unit_id AND renewal.tenant_id = l.tenant_id
+						    AND renewal.id <> l.id AND renewal.start_date > l.start_date)
+		ON CONFLICT (unit_id, move_out_date) DO NOTHING`, now)
+	if err != nil {
+		return nil, err
+	}
+	opened, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	result.Opened = int(opened)
+
+	// The next lease is the first to start after the move-out
+	rows, err := db.DB.Query(`
+		SELECT t.id, l.id, l.start_date
+		FROM unit_turnovers t
+		JOIN leases l ON l.unit_id = t.unit_id AND l.start_date > t.move_out_date
+		WHERE t.move_in_date IS NULL AND l.status IN ('active', 'ended') AND l.start_date <= $1
+		ORDER BY t.id, l.start_date, l.id`, now)
+	if err != nil {
+		return nil, err
+	}
+	type moveIn struct {
+		turnoverID, leaseID int
+		date                time.Time
+	}
+	var moveIns []moveIn
+	for rows.Next() {
+		var m moveIn
+		if err := rows.Scan(&m.turnoverID, &m.leaseID, &m.date); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if len(moveIns) == 0 || moveIns[len(moveIns)-1].turnoverID != m.turnoverID {
+			moveIns = append(moveIns, m)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, m := range moveIns {
+		if _, err := db.DB.Exec(`
+			UPDATE unit_turnovers SET move_in_lease_id = $2, move_in_date = $3, updated_at = NOW()
+			WHERE id = $1 AND move_in_date IS NULL`, m.turnoverID, m.leaseID, m.date); err != nil {
+			return result, err
+		}
+		result.Closed++
+	}
+	return result, nil
+}
+
+// TurnoverSummary totals a set of turnovers
+type TurnoverSummary struct {
+	Turnovers            int     `json:"turnovers"`
+	Vacant               int     `json:"vacant"`
+	AverageDaysVacant    float64 `json:"average_days_vacant"` // Of the turnovers that have been leased
+	AverageMakeReadyDays float64 `json:"average_make_ready_days"`
+	AverageMakeReadyCost float64 `json:"average_make_ready_cost"`
+	TotalMakeReadyCost   float64 `json:"total_make_ready_cost"`
+	TotalLostRent        float64 `json:"total_lost_rent"`
+	TotalVacancyCost     float64 `json:"total_vacancy_cost"`
+}
+
+// SummarizeTurnovers totals turnovers. Days vacant are averaged over leased turnovers only, since
+// open vacancies are still growing.
+func SummarizeTurnovers(turnovers []UnitTurnover) TurnoverSummary {
+	s := TurnoverSummary{Turnovers: len(turnovers)}
+	var leased, leasedDays, ready, readyDays int
+	for _, t := range turnovers {
+		if t.Status == TurnoverVacant {
+			s.Vacant++
+		} else {
+			leased++
+			leasedDays += t.DaysVacant
+		}
+		if t.MakeReadyDays.Valid {
+			ready++
+			readyDays += int(t.MakeReadyDays.Int32)
+		}
+		s.TotalMakeReadyCost += t.MakeReadyCost
+		s.TotalLostRent += t.LostRent
+	}
+	if leased > 0 {
+		s.AverageDaysVacant = math.Round(float64(leasedDays)/float64(leased)*10) / 10
+	}
+	if ready > 0 {
+		s.AverageMakeReadyDays = math.Round(float64(readyDays)/float64(ready)*10) / 10
+	}
+	if len(turnovers) > 0 {
+		s.AverageMakeReadyCost = math.Round(s.TotalMakeReadyCost/float64(len(turnovers))*100) / 100
+	}
+	s.TotalMakeReadyCost = math.Round(s.TotalMakeReadyCost*100) / 100
+	s.TotalLostRent = math.Round(s.TotalLostRent*100) / 100
+	s.TotalVacancyCost = math.Round((s.TotalMakeReadyCost+s.TotalLostRent)*100) / 100
+	return s
+}
+
+// generateTurnoverReport lists the turnovers with a move-out between parameters["start_date"]
+// (default a year ago) and parameters["end_date"] (default today), for the criteria's
+// property_ids or every property
+func generateTurnoverReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	asOf := currentDate()
+	filter := TurnoverFilter{From: asOf.AddDate(-1, 0, 0), To: asOf}
+	if value, ok := parameters["start_date"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			filter.From = parsed
+		}
+	}
+	if value, ok := parameters["end_date"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			filter.To = parsed
+		}
+	}
+	filter.PropertyIDs, _ = report.Criteria["property_ids"].([]interface{})
+
+	turnovers, err := GetUnitTurnovers(filter, asOf)
+	if err != nil {
+		return nil, err
+	}
+	return buildTurnoverReport(turnovers, filter.From, filter.To), nil
+}
+
+// buildTurnoverReport lays out one row per turnover with totals and a chart of vacancy cost
+func buildTurnoverReport(turnovers []UnitTurnover, from, to time.Time) *ReportData {
+	data := &ReportData{
+		Headers: []string{"Property", "Unit", "Move-out", "Ready", "Move-in", "Status", "Days Vacant",
+			"Make-Ready Days", "Make-Ready Cost", "Market Rent", "Lost Rent", "Vacancy Cost"},
+		Rows: []map[string]interface{}{},
+	}
+
+	byProperty := map[string]float64{}
+	var properties []interface{}
+	for _, t := range turnovers {
+		row := map[string]interface{}{
+			"Property":        t.PropertyName,
+			"Unit":            t.UnitNumber,
+			"Move-out":        t.MoveOutDate.Format("2006-01-02"),
+			"Status":          t.Status,
+			"Days Vacant":     t.DaysVacant,
+			"Make-Ready Cost": t.MakeReadyCost,
+			"Market Rent":     t.MarketRent,
+			"Lost Rent":       t.LostRent,
+			"Vacancy Cost":    t.VacancyCost,
+		}
+		if t.ReadyDate.Valid {
+			row["Ready"] = t.ReadyDate.Time.Format("2006-01-02")
+			row["Make-Ready Days"] = t.MakeReadyDays.Int32
+		}
+		if t.MoveInDate.Valid {
+			row["Move-in"] = t.MoveInDate.Time.Format("2006-01-02")
+		}
+		data.Rows = append(data.Rows, row)
+
+		if _, seen := byProperty[t.PropertyName]; !seen {
+			properties = append(properties, t.PropertyName)
+		}
+		byProperty[t.PropertyName] += t.VacancyCost
+	}
+
+	summary := SummarizeTurnovers(turnovers)
+	data.Summary = map[string]interface{}{
+		"start_date":              from.Format("2006-01-02"),
+		"end_date":                to.Format("2006-01-02"),
+		"turnovers":               summary.Turnovers,
+		"vacant":                  summary.Vacant,
+		"average_days_vacant":     summary.AverageDaysVacant,
+		"average_make_ready_days": summary.AverageMakeReadyDays,
+		"average_make_ready_cost": summary.AverageMakeReadyCost,
+		"total_lost_rent":         summary.TotalLostRent,
+		"total_vacancy_cost":      summary.TotalVacancyCost,
+	}
+
+	if len(properties) > 0 {
+		costs := make([]interface{}, len(properties))
+		for i, name := range properties {
+			costs[i] = math.Round(byProperty[name.(string)]*100) / 100
+		}
+		data.Charts = []ChartData{{
+			Type:  "bar",
+			Title: "Vacancy Cost by Property",
+			Data: map[string]interface{}{
+				"labels": properties,
+				"datasets": []map[string]interface{}{{
+					"label":           "Vacancy Cost",
+					"data":            costs,
+					"backgroundColor": "rgba(255, 99, 132, 0.5)",
+				}},
+			},
+		}}
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Move-out": dateColumn, "Ready": dateColumn, "Move-in": dateColumn, "Days Vacant": numberColumn,
+		"Make-Ready Days": numberColumn, "Make-Ready Cost": currencyColumn, "Market Rent": currencyColumn,
+		"Lost Rent": currencyColumn, "Vacancy Cost": currencyColumn,
+	})
+	return data
+}
+
+// The turnover quick stat computes the turnover KPIs for dashboards and the daily stats history
+func init() {
+	RegisterQuickStat(QuickStat{
+		Name:  "turnover",
+		Title: "Turnover",
+		Fields: []StatField{
+			{Key: "move_outs", Label: "Turnovers This Month", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM unit_turnovers t
+				JOIN property_units pu ON t.unit_id = pu.id
+				WHERE t.move_out_date >= :period_start AND (:all_properties = 1 OR pu.property_id IN (:property_ids))`},
+			{Key: "units_in_turnover", Label: "Units in Turnover", Format: StatFormatCount, SQL: `
+				SELECT COUNT(*) FROM unit_turnovers t
+				JOIN property_units pu ON t.unit_id = pu.id
+				WHERE t.move_in_date IS NULL AND (:all_properties = 1 OR pu.property_id IN (:property_ids))`},
+			{Key: "avg_days_vacant", Label: "Average Days Vacant", Format: StatFormatDays,
+				DialectSQL: func(d db.Dialect) string {
+					return `
+						SELECT AVG(` + d.DaysBetween("t.move_out_date", "t.move_in_date") + ` - 1) FROM unit_turnovers t
+						JOIN property_units pu ON t.unit_id = pu.id
+						WHERE t.move_in_date >= :period_start AND (:all_properties = 1 OR pu.property_id IN (:property_ids))`
+				}},
+			{Key: "make_ready_cost", Label: "Make-Ready Cost This Month", Format: StatFormatCurrency, SQL: `
+				SELECT COALESCE(SUM(t.make_ready_cost), 0) FROM unit_turnovers t
+				JOIN property_units pu ON t.unit_id = pu.id
+				WHERE t.move_out_date >= :period_start AND (:all_properties = 1 OR pu.property_id IN (:property_ids))`},
+		},
+		Extend: func(values map[string]interface{}, properties PropertyFilter) error {
+			// Rent lost this month spans vacancies that started in earlier months, so it is
+			// apportioned by day in Go
+			today := currentDate()
+			start := PeriodStart(today)
+			filter := TurnoverFilter{To: today, VacantAfter: start}
+			if properties.Scoped {
+				// 0 matches no property, so an empty scope stays empty
+				filter.PropertyIDs = []interface{}{0}
+				for _, id := range properties.IDs {
+					filter.PropertyIDs = append(filter.PropertyIDs, id)
+				}
+			}
+			turnovers, err := GetUnitTurnovers(filter, today)
+			if err != nil {
+				return err
+			}
+			var lost float64
+			for _, t := range turnovers {
+				lost += t.LostRentBetween(start, today)
+			}
+			values["lost_rent"] = FormatStatValue(StatFormatCurrency, lost)
+			return nil
+		},
+	})
+}
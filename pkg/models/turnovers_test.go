@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func turnoverDate(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func TestUnitTurnoverComputeCosts(t *testing.T) {
+	turnover := UnitTurnover{
+		MoveOutDate:   turnoverDate("2025-05-31"),
+		ReadyDate:     sql.NullTime{Time: turnoverDate("2025-06-10"), Valid: true},
+		MoveInDate:    sql.NullTime{Time: turnoverDate("2025-07-01"), Valid: true},
+		MakeReadyCost: 850,
+		MarketRent:    1825,
+	}
+
+	turnover.computeCosts(turnoverDate("2025-06-15"))
+	assert.Equal(t, TurnoverVacant, turnover.Status)
+	assert.Equal(t, 15, turnover.DaysVacant, "June 1 through 15")
+	assert.Equal(t, 900.0, turnover.LostRent, "1825 a month is 60 a day")
+	assert.Equal(t, 1750.0, turnover.VacancyCost)
+	assert.Equal(t, sql.NullInt32{Int32: 10, Valid: true}, turnover.MakeReadyDays)
+
+	turnover.computeCosts(turnoverDate("2025-12-31"))
+	assert.Equal(t, TurnoverLeased, turnover.Status)
+	assert.Equal(t, 30, turnover.DaysVacant, "the day before move-in is the last vacant day")
+	assert.Equal(t, 1800.0, turnover.LostRent)
+
+	back := UnitTurnover{MoveOutDate: turnoverDate("2025-05-31"), MarketRent: 1825,
+		MoveInDate: sql.NullTime{Time: turnoverDate("2025-06-01"), Valid: true}}
+	back.computeCosts(turnoverDate("2025-12-31"))
+	assert.Equal(t, 0, back.DaysVacant, "a move-in the day after move-out has no vacancy")
+	assert.False(t, back.MakeReadyDays.Valid)
+}
+
+func TestUnitTurnoverLostRentBetween(t *testing.T) {
+	turnover := UnitTurnover{
+		MoveOutDate: turnoverDate("2025-05-20"),
+		MoveInDate:  sql.NullTime{Time: turnoverDate("2025-06-11"), Valid: true},
+		MarketRent:  1825,
+	}
+	assert.Equal(t, 600.0, turnover.LostRentBetween(turnoverDate("2025-06-01"), turnoverDate("2025-06-30")),
+		"June 1 through 10")
+	assert.Equal(t, 660.0, turnover.LostRentBetween(turnoverDate("2025-05-01"), turnoverDate("2025-05-31")),
+		"May 21 through 31")
+	assert.Equal(t, 0.0, turnover.LostRentBetween(turnoverDate("2025-07-01"), turnoverDate("2025-07-31")))
+}
+
+func TestSummarizeTurnovers(t *testing.T) {
+	turnovers := []UnitTurnover{
+		{Status: TurnoverLeased, DaysVacant: 20, MakeReadyDays: sql.NullInt32{Int32: 8, Valid: true}, MakeReadyCost: 600, LostRent: 1000},
+		{Status: TurnoverLeased, DaysVacant: 35, MakeReadyDays: sql.NullInt32{Int32: 12, Valid: true}, MakeReadyCost: 1400, LostRent: 1750},
+		{Status: TurnoverVacant, DaysVacant: 90, MakeReadyCost: 400, LostRent: 4500},
+	}
+	s := SummarizeTurnovers(turnovers)
+	assert.Equal(t, 3, s.Turnovers)
+	assert.Equal(t, 1, s.Vacant)
+	assert.Equal(t, 27.5, s.AverageDaysVacant, "open vacancies are not averaged")
+	assert.Equal(t, 10.0, s.AverageMakeReadyDays)
+	assert.Equal(t, 800.0, s.AverageMakeReadyCost)
+	assert.Equal(t, 7250.0, s.TotalLostRent)
+	assert.Equal(t, 9650.0, s.TotalVacancyCost)
+
+	assert.Equal(t, TurnoverSummary{}, SummarizeTurnovers(nil))
+}
+
+func TestBuildTurnoverReport(t *testing.T) {
+	leased := UnitTurnover{PropertyName: "Maple Court", UnitNumber: "2B", MoveOutDate: turnoverDate("2025-05-31"),
+		MoveInDate: sql.NullTime{Time: turnoverDate("2025-07-01"), Valid: true}, MarketRent: 1825, MakeReadyCost: 850}
+	leased.computeCosts(turnoverDate("2025-12-31"))
+	vacant := UnitTurnover{PropertyName: "Oak Terrace", UnitNumber: "1", MoveOutDate: turnoverDate("2025-12-21"),
+		ReadyDate: sql.NullTime{Time: turnoverDate("2025-12-28"), Valid: true}, MarketRent: 1460}
+	vacant.computeCosts(turnoverDate("2025-12-31"))
+
+	data := buildTurnoverReport([]UnitTurnover{vacant, leased}, turnoverDate("2025-01-01"), turnoverDate("2025-12-31"))
+	require.Len(t, data.Rows, 2)
+	assert.Equal(t, "2025-12-28", data.Rows[0]["Ready"])
+	assert.Equal(t, int32(7), data.Rows[0]["Make-Ready Days"])
+	assert.NotContains(t, data.Rows[0], "Move-in")
+	assert.Equal(t, 480.0, data.Rows[0]["Lost Rent"], "December 22 through 31 at 48 a day")
+	assert.Equal(t, "2025-07-01", data.Rows[1]["Move-in"])
+
+	assert.Equal(t, 2, data.Summary["turnovers"])
+	assert.Equal(t, 30.0, data.Summary["average_days_vacant"])
+	assert.Equal(t, 3130.0, data.Summary["total_vacancy_cost"])
+	assert.Len(t, data.Columns, len(data.Headers))
+	require.Len(t, data.Charts, 1)
+	assert.Equal(t, []interface{}{"Oak Terrace", "Maple Court"}, data.Charts[0].Data["labels"])
+}
+
+func TestSyncUnitTurnoversOpensOnceTermEnds(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := turnoverDate("2026-04-01")
+	// Leases stay active past their end date, so the term ending opens the turnover
+	mock.ExpectExec(`INSERT INTO unit_turnovers (.+) WHERE l.status IN \('active', 'ended'\) AND l.end_date <= \$1`).
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT t.id, l.id, l.start_date`).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id", "start_date"}).
+			AddRow(3, 9, turnoverDate("2026-03-15")))
+	mock.ExpectExec(`UPDATE unit_turnovers SET move_in_lease_id`).
+		WithArgs(3, 9, turnoverDate("2026-03-15")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	result, err := SyncUnitTurnovers(now)
+	require.NoError(t, err)
+	assert.Equal(t, &TurnoverSyncResult{Opened: 2, Closed: 1}, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,255 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// DefaultOrganizationID is the organization users belong to until assigned elsewhere
+const DefaultOrganizationID = 1
+
+// Metered usage. Counters reset each calendar month (UTC).
+const (
+	UsageAPICalls         = "api_calls"         // Authenticated /api requests
+	UsageReportExecutions = "report_executions" // Report runs, including exports
+	UsageExportBytes      = "export_bytes"      // Bytes of exported report files
+	UsageStorageBytes     = "storage_bytes"     // Bytes written to file storage
+)
+
+// UsageMetrics lists the metered usage types
+var UsageMetrics = []string{UsageAPICalls, UsageReportExecutions, UsageExportBytes, UsageStorageBytes}
+
+// ValidUsageMetric reports whether metric is a metered usage type
+func ValidUsageMetric(metric string) bool {
+	for _, m := range UsageMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// UsagePeriod returns the monthly period key ("YYYY-MM") containing t
+func UsagePeriod(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// NextUsagePeriodStart returns when the period containing t ends and counters reset
+func NextUsagePeriodStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// UsageStatus is an organization's usage of one metric in the current period
+type UsageStatus struct {
+	OrganizationID int    `json:"organization_id"`
+	Metric         string `json:"metric"`
+	Used           int64  `json:"used"`
+	Limit          int64  `json:"limit"` // 0 means unlimited
+}
+
+// Exceeded reports whether usage is over the quota
+func (s UsageStatus) Exceeded() bool {
+	return s.Limit > 0 && s.Used > s.Limit
+}
+
+// Exhausted reports whether the quota leaves no room for further usage
+func (s UsageStatus) Exhausted() bool {
+	return s.Limit > 0 && s.Used >= s.Limit
+}
+
+// RecordUsage adds amount to the current period's counter for the user's organization and
+// returns the updated usage with its quota
+func RecordUsage(userID int, metric string, amount int64, now time.Time) (UsageStatus, error) {
+	status := UsageStatus{Metric: metric}
+	err := db.DB.QueryRow(`
+		INSERT INTO usage_counters (organization_id, period, metric, value, updated_at)
+		SELECT COALESCE(organization_id, $2), $3, $4, $5, $6 FROM users WHERE id = $1
+		ON CONFLICT (organization_id, period, metric)
+		DO UPDATE SET value = usage_counters.value + excluded.value, updated_at = excluded.updated_at
+		RETURNING organization_id, value`,
+		userID, DefaultOrganizationID, UsagePeriod(now), metric, amount, now).Scan(&status.OrganizationID, &status.Used)
+	if err != nil {
+		return status, err
+	}
+
+	status.Limit, err = getUsageLimit(status.OrganizationID, metric)
+	return status, err
+}
+
+// ReleaseUsage subtracts amount from the current period's counter for the user's organization,
+// for usage such as stored bytes that is given back when files are deleted. The counter does not
+// drop below zero.
+func ReleaseUsage(userID int, metric string, amount int64, now time.Time) error {
+	_, err := db.DB.Exec(`
+		UPDATE usage_counters
+		SET value = CASE WHEN value > $5 THEN value - $5 ELSE 0 END, updated_at = $6
+		WHERE organization_id = (SELECT COALESCE(organization_id, $2) FROM users WHERE id = $1)
+			AND period = $3 AND metric = $4`,
+		userID, DefaultOrganizationID, UsagePeriod(now), metric, amount, now)
+	return err
+}
+
+// GetUsageStatus returns the current period's usage and quota for the user's organization
+// without changing it
+func GetUsageStatus(userID int, metric string, now time.Time) (UsageStatus, error) {
+	status := UsageStatus{Metric: metric}
+	err := db.DB.QueryRow(`
+		SELECT COALESCE(u.organization_id, $2), COALESCE(c.value, 0)
+		FROM users u
+		LEFT JOIN usage_counters c ON c.organization_id = COALESCE(u.organization_id, $2)
+			AND c.period = $3 AND c.metric = $4
+		WHERE u.id = $1`,
+		userID, DefaultOrganizationID, UsagePeriod(now), metric).Scan(&status.OrganizationID, &status.Used)
+	if err != nil {
+		return status, err
+	}
+
+	status.Limit, err = getUsageLimit(status.OrganizationID, metric)
+	return status, err
+}
+
+// getUsageLimit returns an organization's monthly limit for a metric, or 0 if unlimited
+func getUsageLimit(organizationID int, metric string) (int64, error) {
+	var limit int64
+	err := db.DB.QueryRow("SELECT monthly_limit FROM usage_quotas WHERE organization_id = $1 AND metric = $2",
+		organizationID, metric).Scan(&limit)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return limit, err
+}
+
+// SetUsageQuota sets an organization's monthly limit for a metric. A limit of 0 removes the
+// quota. It returns sql.ErrNoRows if the organization does not exist.
+func SetUsageQuota(organizationID int, metric string, limit int64) error {
+	if !ValidUsageMetric(metric) {
+		return fmt.Errorf("unknown usage metric %q", metric)
+	}
+
+	var exists int
+	if err := db.DB.QueryRow("SELECT id FROM organizations WHERE id = $1", organizationID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if limit <= 0 {
+		_, err := db.DB.Exec("DELETE FROM usage_quotas WHERE organization_id = $1 AND metric = $2", organizationID, metric)
+		return err
+	}
+	_, err := db.DB.Exec(`
+		INSERT INTO usage_quotas (organization_id, metric, monthly_limit, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (organization_id, metric)
+		DO UPDATE SET monthly_limit = excluded.monthly_limit, updated_at = NOW()`,
+		organizationID, metric, limit)
+	return err
+}
+
+// OrganizationUsage is one organization's usage and quotas for a period
+type OrganizationUsage struct {
+	OrganizationID int              `json:"organization_id"`
+	Name           string           `json:"name"`
+	Period         string           `json:"period"`
+	Usage          map[string]int64 `json:"usage"`
+	Quotas         map[string]int64 `json:"quotas"`
+
+	// Stored report output against the organization's storage budget, for the admin overview
+	ExportStorage *ExportStorageUsage `json:"export_storage,omitempty"`
+}
+
+// GetOrganizationUsage returns usage and quotas for every organization in a period, or only
+// for organizationID when it is non-zero
+func GetOrganizationUsage(period string, organizationID int) ([]OrganizationUsage, error) {
+	query := "SELECT id, name FROM organizations"
+	var args []interface{}
+	if organizationID != 0 {
+		query += " WHERE id = $1"
+		args = append(args, organizationID)
+	}
+	rows, err := db.ReadDB().Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orgs []OrganizationUsage
+	index := map[int]int{}
+	for rows.Next() {
+		org := OrganizationUsage{Period: period, Usage: map[string]int64{}, Quotas: map[string]int64{}}
+		if err := rows.Scan(&org.OrganizationID, &org.Name); err != nil {
+			return nil, err
+		}
+		for _, metric := range UsageMetrics {
+			org.Usage[metric] = 0
+		}
+		index[org.OrganizationID] = len(orgs)
+		orgs = append(orgs, org)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Fill in counters and quotas for the organizations found above
+	fill := func(query string, target func(*OrganizationUsage) map[string]int64, args ...interface{}) error {
+		rows, err := db.ReadDB().Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var orgID int
+			var metric string
+			var value int64
+			if err := rows.Scan(&orgID, &metric, &value); err != nil {
+				return err
+			}
+			if i, ok := index[orgID]; ok {
+				target(&orgs[i])[metric] = value
+			}
+		}
+		return rows.Err()
+	}
+	if err := fill("SELECT organization_id, metric, value FROM usage_counters WHERE period = $1",
+		func(o *OrganizationUsage) map[string]int64 { return o.Usage }, period); err != nil {
+		return nil, err
+	}
+	if err := fill("SELECT organization_id, metric, monthly_limit FROM usage_quotas",
+		func(o *OrganizationUsage) map[string]int64 { return o.Quotas }); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// UserOrganizationID returns the organization a user belongs to
+func UserOrganizationID(userID int) (int, error) {
+	var organizationID int
+	err := db.DB.QueryRow("SELECT COALESCE(organization_id, $2) FROM users WHERE id = $1",
+		userID, DefaultOrganizationID).Scan(&organizationID)
+	return organizationID, err
+}
+
+// UserOrganization is the organization a user belongs to, as shown to the user
+type UserOrganization struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// GetUserOrganization returns the organization a user belongs to
+func GetUserOrganization(userID int) (*UserOrganization, error) {
+	var o UserOrganization
+	var tz sql.NullString
+	err := db.ReadDB().QueryRow(`
+		SELECT o.id, o.name, o.timezone
+		FROM users u
+		JOIN organizations o ON o.id = COALESCE(u.organization_id, $2)
+		WHERE u.id = $1`, userID, DefaultOrganizationID).Scan(&o.ID, &o.Name, &tz)
+	if err != nil {
+		return nil, err
+	}
+	o.Timezone = tz.String
+	return &o, nil
+}
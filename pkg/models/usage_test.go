@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsagePeriod(t *testing.T) {
+	now := time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2024-12", UsagePeriod(now))
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), NextUsagePeriodStart(now))
+}
+
+func TestUsageStatusLimits(t *testing.T) {
+	assert.False(t, UsageStatus{Used: 100}.Exceeded())
+	assert.False(t, UsageStatus{Used: 10, Limit: 10}.Exceeded())
+	assert.True(t, UsageStatus{Used: 10, Limit: 10}.Exhausted())
+	assert.True(t, UsageStatus{Used: 11, Limit: 10}.Exceeded())
+}
+
+func TestRecordUsage(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`INSERT INTO usage_counters (.+) ON CONFLICT`).
+		WithArgs(3, DefaultOrganizationID, "2024-07", UsageAPICalls, int64(1), now).
+		WillReturnRows(sqlmock.NewRows([]string{"organization_id", "value"}).AddRow(2, 1001))
+	mock.ExpectQuery(`SELECT monthly_limit FROM usage_quotas`).WithArgs(2, UsageAPICalls).
+		WillReturnRows(sqlmock.NewRows([]string{"monthly_limit"}).AddRow(1000))
+
+	status, err := RecordUsage(3, UsageAPICalls, 1, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.OrganizationID)
+	assert.True(t, status.Exceeded())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetUsageQuota(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	assert.Error(t, SetUsageQuota(1, "bogus", 10))
+
+	mock.ExpectQuery(`SELECT id FROM organizations`).WithArgs(9).WillReturnError(sql.ErrNoRows)
+	assert.Equal(t, sql.ErrNoRows, SetUsageQuota(9, UsageExportBytes, 10))
+
+	mock.ExpectQuery(`SELECT id FROM organizations`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`INSERT INTO usage_quotas`).WithArgs(1, UsageExportBytes, int64(5000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, SetUsageQuota(1, UsageExportBytes, 5000))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseUsage(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`UPDATE usage_counters\s+SET value = CASE WHEN value > \$5 THEN value - \$5 ELSE 0 END`).
+		WithArgs(3, DefaultOrganizationID, "2024-07", UsageStorageBytes, int64(4096), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, ReleaseUsage(3, UsageStorageBytes, 4096, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// UserListFilter narrows the admin user list. Zero values disable a filter.
+type UserListFilter struct {
+	Role            string     // Role name the user must hold
+	Status          string     // Exact account status; deleted users are only listed when this is "deleted"
+	Search          string     // Case-insensitive substring of email, username or name
+	LastLoginBefore *time.Time // Users who last logged in before this time, or never
+	LastLoginAfter  *time.Time // Users who last logged in at or after this time
+}
+
+// ErrInvalidUserSort is returned when the user list is sorted by an unknown field
+var ErrInvalidUserSort = errors.New("sort must be one of id, username, email, name, status, last_login or created_at, optionally prefixed with - for descending order")
+
+// userSortColumns maps the sort fields of the user list to the expressions they order by
+var userSortColumns = map[string]string{
+	"id":         "u.id",
+	"username":   "LOWER(u.username)",
+	"email":      "LOWER(u.email)",
+	"name":       "LOWER(u.last_name || ' ' || u.first_name)",
+	"status":     "u.status",
+	"last_login": "u.last_login",
+	"created_at": "u.created_at",
+}
+
+// UserListPage selects a page of the user list. Sort is a field of userSortColumns, prefixed
+// with - for descending order; empty sorts newest first. A Limit of 0 returns every user.
+type UserListPage struct {
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// orderBy builds the ORDER BY expressions for the page's sort, ending with the user ID so pages
+// are stable
+func (p UserListPage) orderBy() (string, error) {
+	sort := p.Sort
+	if sort == "" {
+		sort = "-created_at"
+	}
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+		sort = sort[1:]
+	}
+	column, ok := userSortColumns[sort]
+	if !ok {
+		return "", ErrInvalidUserSort
+	}
+	if sort == "id" {
+		return "u.id " + direction, nil
+	}
+	return column + " " + direction + ", u.id " + direction, nil
+}
+
+// UserSummary is a row of the admin user list
+type UserSummary struct {
+	ID            int            `json:"id"`
+	KeycloakID    sql.NullString `json:"keycloak_id,omitempty"`
+	Username      string         `json:"username"`
+	Email         string         `json:"email"`
+	FirstName     string         `json:"first_name"`
+	LastName      string         `json:"last_name"`
+	PhoneNumber   sql.NullString `json:"phone_number,omitempty"`
+	EmailVerified bool           `json:"email_verified"`
+	MFAEnabled    bool           `json:"mfa_enabled"`
+	Status        string         `json:"status"`
+	LastLogin     sql.NullTime   `json:"last_login,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Roles         []string       `json:"roles"`
+}
+
+// where builds the WHERE clause and arguments for the filter
+func (f UserListFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if f.Role != "" {
+		add(`u.id IN (SELECT fur.user_id FROM user_roles fur JOIN roles fr ON fr.id = fur.role_id WHERE fr.name = ?)`, f.Role)
+	}
+	if f.Status != "" {
+		add("u.status = ?", f.Status)
+	} else {
+		conditions = append(conditions, "u.status <> '"+UserStatusDeleted+"'")
+	}
+	if search := strings.TrimSpace(f.Search); search != "" {
+		add(`(LOWER(u.email) LIKE ? OR LOWER(u.username) LIKE ?
+			OR LOWER(u.first_name || ' ' || u.last_name) LIKE ?)`, "%"+strings.ToLower(search)+"%")
+	}
+	if f.LastLoginBefore != nil {
+		add("(u.last_login IS NULL OR u.last_login < ?)", *f.LastLoginBefore)
+	}
+	if f.LastLoginAfter != nil {
+		add("u.last_login >= ?", *f.LastLoginAfter)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// ListUsers returns a page of the users matching the filter with their role names, and how many
+// users match in total
+func ListUsers(filter UserListFilter, page UserListPage) ([]UserSummary, int, error) {
+	orderBy, err := page.orderBy()
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := filter.where()
+
+	var total int
+	if err := db.DB.QueryRow("SELECT COUNT(*) FROM users u "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// The page is chosen from users before joining roles, which yields one row per user and
+	// role; users without roles appear once with a NULL role
+	pageClause := ""
+	if page.Limit > 0 {
+		args = append(args, page.Limit, page.Offset)
+		pageClause = fmt.Sprintf("LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	rows, err := db.DB.Query(`
+		SELECT u.id, u.keycloak_id, u.username, u.email, u.first_name, u.last_name,
+			   u.phone_number, u.email_verified, u.mfa_enabled, u.status, u.last_login, u.created_at,
+			   r.name
+		FROM (
+			SELECT u.* FROM users u
+			`+where+`
+			ORDER BY `+orderBy+`
+			`+pageClause+`
+		) u
+		LEFT JOIN user_roles ur ON ur.user_id = u.id
+		LEFT JOIN roles r ON r.id = ur.role_id
+		ORDER BY `+orderBy+`, r.name`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []UserSummary
+	for rows.Next() {
+		var user UserSummary
+		var role sql.NullString
+		if err := rows.Scan(&user.ID, &user.KeycloakID, &user.Username, &user.Email,
+			&user.FirstName, &user.LastName, &user.PhoneNumber, &user.EmailVerified,
+			&user.MFAEnabled, &user.Status, &user.LastLogin, &user.CreatedAt, &role); err != nil {
+			return nil, 0, err
+		}
+
+		if n := len(users); n > 0 && users[n-1].ID == user.ID {
+			if role.Valid {
+				users[n-1].Roles = append(users[n-1].Roles, role.String)
+			}
+			continue
+		}
+		user.Roles = []string{}
+		if role.Valid {
+			user.Roles = append(user.Roles, role.String)
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
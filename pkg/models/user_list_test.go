@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserListFilterWhere(t *testing.T) {
+	where, args := UserListFilter{}.where()
+	assert.Equal(t, "WHERE u.status <> 'deleted'", where)
+	assert.Empty(t, args)
+
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	where, args = UserListFilter{Role: "admin", Search: " Jane ", LastLoginAfter: &after}.where()
+	assert.Contains(t, where, "fr.name = $1")
+	assert.Contains(t, where, "LOWER(u.email) LIKE $2 OR LOWER(u.username) LIKE $2")
+	assert.Contains(t, where, "u.last_login >= $3")
+	assert.Equal(t, []interface{}{"admin", "%jane%", after}, args)
+}
+
+func TestUserListPageOrderBy(t *testing.T) {
+	orderBy, err := UserListPage{}.orderBy()
+	assert.NoError(t, err)
+	assert.Equal(t, "u.created_at DESC, u.id DESC", orderBy)
+
+	orderBy, err = UserListPage{Sort: "email"}.orderBy()
+	assert.NoError(t, err)
+	assert.Equal(t, "LOWER(u.email) ASC, u.id ASC", orderBy)
+
+	orderBy, err = UserListPage{Sort: "-id"}.orderBy()
+	assert.NoError(t, err)
+	assert.Equal(t, "u.id DESC", orderBy)
+
+	_, err = UserListPage{Sort: "password_hash"}.orderBy()
+	assert.Equal(t, ErrInvalidUserSort, err)
+}
+
+func TestListUsersGroupsRoles(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	columns := []string{"id", "keycloak_id", "username", "email", "first_name", "last_name", "phone_number",
+		"email_verified", "mfa_enabled", "status", "last_login", "created_at", "name"}
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users u WHERE u.status = \$1`).
+		WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery(`FROM \(\s+SELECT u.\* FROM users u\s+WHERE u.status = \$1\s+ORDER BY LOWER\(u.username\) DESC, u.id DESC\s+LIMIT \$2 OFFSET \$3\s+\) u\s+LEFT JOIN user_roles ur`).
+		WithArgs("active", 2, 10).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(2, nil, "jdoe", "jane@example.com", "Jane", "Doe", nil, true, false, "active", nil, created, "admin").
+			AddRow(2, nil, "jdoe", "jane@example.com", "Jane", "Doe", nil, true, false, "active", nil, created, "viewer").
+			AddRow(1, nil, "bob", "bob@example.com", "Bob", "Smith", nil, false, false, "active", nil, created, nil))
+
+	users, total, err := ListUsers(UserListFilter{Status: "active"}, UserListPage{Sort: "-username", Limit: 2, Offset: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 12, total)
+	assert.Len(t, users, 2)
+	assert.Equal(t, []string{"admin", "viewer"}, users[0].Roles)
+	assert.Equal(t, []string{}, users[1].Roles)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Account statuses. Only active accounts can sign in or use API keys.
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusInactive  = "inactive"
+	UserStatusDeleted   = "deleted"
+)
+
+// Audit actions recorded for account status changes
+const (
+	AuditUserSuspended   = "user.suspended"
+	AuditUserReactivated = "user.reactivated"
+	AuditUserDeleted     = "user.deleted"
+)
+
+// ErrUserStatusConflict is returned when an account is not in a status the change applies to
+var ErrUserStatusConflict = errors.New("user account is not in a status that allows this change")
+
+// SuspendUser blocks an active or invited account. Its sessions, API keys, pending invitations
+// and trusted devices are revoked in the same transaction, and the reason is written to the
+// audit log.
+func SuspendUser(userID int, actorID sql.NullInt32, reason string) error {
+	return changeUserStatus(userID, []string{UserStatusActive, UserStatusInvited}, UserStatusSuspended,
+		AuditUserSuspended, actorID, reason)
+}
+
+// ReactivateUser restores a suspended or inactive account. Revoked API keys stay revoked; the
+// user signs in again and creates new keys.
+func ReactivateUser(userID int, actorID sql.NullInt32, reason string) error {
+	return changeUserStatus(userID, []string{UserStatusSuspended, UserStatusInactive}, UserStatusActive,
+		AuditUserReactivated, actorID, reason)
+}
+
+// deleteUser soft-deletes an account. The row is kept, with status deleted, so the audit log,
+// payments and other records keep pointing at it; access is revoked as on suspension.
+func deleteUser(userID int, actorID sql.NullInt32, reason string) error {
+	return changeUserStatus(userID, []string{UserStatusActive, UserStatusInvited, UserStatusSuspended, UserStatusInactive},
+		UserStatusDeleted, AuditUserDeleted, actorID, reason)
+}
+
+// changeUserStatus moves an account from one of the allowed statuses to a new one and audits it
+func changeUserStatus(userID int, from []string, to, action string, actorID sql.NullInt32, reason string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	if err := tx.QueryRow("SELECT status FROM users WHERE id = $1", userID).Scan(&current); err != nil {
+		return err
+	}
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || current == status
+	}
+	if !allowed {
+		return ErrUserStatusConflict
+	}
+
+	// Matching on the current status guards against a concurrent change
+	result, err := tx.Exec("UPDATE users SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3",
+		to, userID, current)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return ErrUserStatusConflict
+	}
+
+	details := map[string]interface{}{"previous_status": current, "status": to}
+	if to != UserStatusActive {
+		revoked, err := revokeUserAccess(tx, userID)
+		if err != nil {
+			return err
+		}
+		for k, v := range revoked {
+			details[k] = v
+		}
+	}
+
+	if err := RecordAudit(tx, &AuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: "user",
+		EntityID:   userID,
+		Reason:     NullString(reason),
+		Details:    details,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// revokeUserAccess ends a user's sessions and revokes their API keys, pending invitations and
+// trusted devices, returning how many of each were revoked
+func revokeUserAccess(q Querier, userID int) (map[string]interface{}, error) {
+	statements := []struct {
+		name  string
+		query string
+	}{
+		{"sessions_revoked", "DELETE FROM user_sessions WHERE user_id = $1"},
+		{"api_keys_revoked", "UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL"},
+		{"invitations_revoked", "DELETE FROM user_invitations WHERE user_id = $1 AND accepted_at IS NULL"},
+		{"trusted_devices_revoked", "UPDATE trusted_devices SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL"},
+	}
+
+	counts := map[string]interface{}{}
+	for _, stmt := range statements {
+		result, err := q.Exec(stmt.query, userID)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		counts[stmt.name] = affected
+	}
+	return counts, nil
+}
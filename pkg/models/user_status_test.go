@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuspendUserRevokesAccess(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM users WHERE id = \$1`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("active"))
+	mock.ExpectExec(`UPDATE users SET status = \$1`).WithArgs(UserStatusSuspended, 5, "active").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM user_sessions WHERE user_id = \$1`).WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE api_keys SET revoked_at = NOW\(\)`).WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM user_invitations`).WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE trusted_devices SET revoked_at = NOW\(\)`).WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(sql.NullInt32{Int32: 1, Valid: true}, AuditUserSuspended, "user", 5,
+			sql.NullString{String: "Lease terminated", Valid: true}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+
+	err := SuspendUser(5, sql.NullInt32{Int32: 1, Valid: true}, "Lease terminated")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReactivateUserRejectsActiveAccount(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM users WHERE id = \$1`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("active"))
+	mock.ExpectRollback()
+
+	err := ReactivateUser(5, sql.NullInt32{}, "Back from leave")
+	assert.Equal(t, ErrUserStatusConflict, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAuditLog(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT (.+) FROM audit_log`).WithArgs("user", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "action", "entity_type", "entity_id", "reason", "details", "created_at"}).
+			AddRow(2, 1, AuditUserSuspended, "user", 5, "Lease terminated", []byte(`{"previous_status":"active"}`), created))
+
+	entries, err := GetAuditLog("user", 5)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Lease terminated", entries[0].Reason.String)
+	assert.Equal(t, "active", entries[0].Details["previous_status"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,308 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// Anomaly detection defaults
+const (
+	UsageBaselineWindow      = 30  // Number of prior readings in the rolling baseline
+	UsageBaselineMinReadings = 7   // Readings required before a unit can be flagged
+	UsageAnomalyZScore       = 3.0 // Standard deviations above the mean that count as abnormal
+	UsageAnomalyMinRatio     = 1.5 // Consumption must also exceed the mean by this factor
+)
+
+// MeterReading is a unit's utility consumption for the period ending on ReadingDate
+type MeterReading struct {
+	ID          int            `json:"id"`
+	UnitID      int            `json:"unit_id"`
+	UtilityType string         `json:"utility_type"`
+	ReadingDate time.Time      `json:"reading_date"`
+	Consumption float64        `json:"consumption"`
+	UOM         sql.NullString `json:"uom,omitempty"`
+	EvaluatedAt sql.NullTime   `json:"evaluated_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// UsageBaseline summarises a unit's recent consumption
+type UsageBaseline struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	ZScore float64 `json:"z_score"`
+}
+
+// UtilityAnomaly is a reading flagged as abnormal consumption
+type UtilityAnomaly struct {
+	ID                   int           `json:"id"`
+	ReadingID            int           `json:"reading_id"`
+	UnitID               int           `json:"unit_id"`
+	UnitNumber           string        `json:"unit_number"`
+	PropertyID           int           `json:"property_id"`
+	PropertyName         string        `json:"property_name"`
+	UtilityType          string        `json:"utility_type"`
+	ReadingDate          time.Time     `json:"reading_date"`
+	Consumption          float64       `json:"consumption"`
+	BaselineMean         float64       `json:"baseline_mean"`
+	BaselineStdDev       float64       `json:"baseline_stddev"`
+	ZScore               float64       `json:"z_score"`
+	MaintenanceRequestID sql.NullInt32 `json:"maintenance_request_id,omitempty"`
+	DetectedAt           time.Time     `json:"detected_at"`
+}
+
+// DetectUsageAnomaly compares consumption with the baseline of prior readings. It returns the
+// baseline and whether the reading is abnormally high. Readings are only flagged once there are
+// at least UsageBaselineMinReadings of history, and must exceed both the z-score threshold and
+// the minimum ratio so that units with very steady usage are not flagged for small changes.
+func DetectUsageAnomaly(history []float64, consumption float64) (UsageBaseline, bool) {
+	var baseline UsageBaseline
+	if len(history) < UsageBaselineMinReadings {
+		return baseline, false
+	}
+
+	for _, v := range history {
+		baseline.Mean += v
+	}
+	baseline.Mean /= float64(len(history))
+
+	for _, v := range history {
+		baseline.StdDev += (v - baseline.Mean) * (v - baseline.Mean)
+	}
+	baseline.StdDev = math.Sqrt(baseline.StdDev / float64(len(history)))
+
+	if baseline.StdDev > 0 {
+		baseline.ZScore = math.Round((consumption-baseline.Mean)/baseline.StdDev*100) / 100
+	} else if consumption > baseline.Mean {
+		baseline.ZScore = math.Inf(1)
+	}
+
+	anomalous := baseline.ZScore >= UsageAnomalyZScore && consumption >= baseline.Mean*UsageAnomalyMinRatio
+	if math.IsInf(baseline.ZScore, 1) {
+		// Not representable in JSON or the database
+		baseline.ZScore = 999.99
+	}
+	return baseline, anomalous
+}
+
+// SaveMeterReading inserts a reading, replacing any existing reading for the same unit, utility and date
+func SaveMeterReading(reading *MeterReading) error {
+	query := `
+		INSERT INTO meter_readings (unit_id, utility_type, reading_date, consumption, uom)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (unit_id, utility_type, reading_date)
+		DO UPDATE SET consumption = EXCLUDED.consumption, uom = EXCLUDED.uom, evaluated_at = NULL
+		RETURNING id, created_at`
+
+	return db.DB.QueryRow(query, reading.UnitID, reading.UtilityType, reading.ReadingDate,
+		reading.Consumption, reading.UOM).Scan(&reading.ID, &reading.CreatedAt)
+}
+
+// GetMeterReadings retrieves a unit's readings between start and end, optionally filtered by utility type
+func GetMeterReadings(unitID int, utilityType string, start, end time.Time) ([]MeterReading, error) {
+	query := `
+		SELECT id, unit_id, utility_type, reading_date, consumption, uom, evaluated_at, created_at
+		FROM meter_readings
+		WHERE unit_id = $1 AND ($2 = '' OR utility_type = $2) AND reading_date >= $3 AND reading_date <= $4
+		ORDER BY utility_type, reading_date`
+
+	rows, err := db.ReadDB().Query(query, unitID, utilityType, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMeterReadings(rows)
+}
+
+// GetUnevaluatedMeterReadings retrieves readings the anomaly detector has not yet checked,
+// oldest first so that baselines build up in order
+func GetUnevaluatedMeterReadings(limit int) ([]MeterReading, error) {
+	query := `
+		SELECT id, unit_id, utility_type, reading_date, consumption, uom, evaluated_at, created_at
+		FROM meter_readings
+		WHERE evaluated_at IS NULL
+		ORDER BY reading_date, id
+		LIMIT $1`
+
+	rows, err := db.DB.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMeterReadings(rows)
+}
+
+func scanMeterReadings(rows *sql.Rows) ([]MeterReading, error) {
+	var readings []MeterReading
+	for rows.Next() {
+		var reading MeterReading
+		err := rows.Scan(&reading.ID, &reading.UnitID, &reading.UtilityType, &reading.ReadingDate,
+			&reading.Consumption, &reading.UOM, &reading.EvaluatedAt, &reading.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+	return readings, nil
+}
+
+// GetUsageHistory returns the consumption of up to limit readings before the given reading,
+// for the same unit and utility
+func GetUsageHistory(reading MeterReading, limit int) ([]float64, error) {
+	query := `
+		SELECT consumption
+		FROM meter_readings
+		WHERE unit_id = $1 AND utility_type = $2 AND reading_date < $3
+		ORDER BY reading_date DESC
+		LIMIT $4`
+
+	rows, err := db.DB.Query(query, reading.UnitID, reading.UtilityType, reading.ReadingDate, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []float64
+	for rows.Next() {
+		var v float64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		history = append(history, v)
+	}
+	return history, nil
+}
+
+// MarkMeterReadingEvaluated records that the detector has checked a reading
+func MarkMeterReadingEvaluated(q Querier, readingID int) error {
+	_, err := q.Exec("UPDATE meter_readings SET evaluated_at = NOW() WHERE id = $1", readingID)
+	return err
+}
+
+// RecordUtilityAnomaly stores an anomaly in tx and links it to a maintenance request. While an
+// earlier anomaly's request for the same unit and utility is still open it is reused, so a
+// continuing leak does not open a new request every day; opened reports whether one was created.
+// A reading that already has an anomaly, recorded by a concurrent run, returns a nil anomaly.
+func RecordUtilityAnomaly(tx *sql.Tx, reading MeterReading, baseline UsageBaseline) (anomaly *UtilityAnomaly, opened bool, err error) {
+	anomaly = &UtilityAnomaly{
+		ReadingID:      reading.ID,
+		UnitID:         reading.UnitID,
+		UtilityType:    reading.UtilityType,
+		ReadingDate:    reading.ReadingDate,
+		Consumption:    reading.Consumption,
+		BaselineMean:   baseline.Mean,
+		BaselineStdDev: baseline.StdDev,
+		ZScore:         baseline.ZScore,
+	}
+
+	err = tx.QueryRow(`
+		SELECT pu.unit_number, p.id, p.name
+		FROM property_units pu
+		JOIN properties p ON pu.property_id = p.id
+		WHERE pu.id = $1`, reading.UnitID).Scan(&anomaly.UnitNumber, &anomaly.PropertyID, &anomaly.PropertyName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Claim the reading before opening a request so a concurrent run cannot open a second one
+	err = tx.QueryRow(`
+		INSERT INTO utility_anomalies (reading_id, unit_id, utility_type, consumption, baseline_mean,
+									   baseline_stddev, z_score)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (reading_id) DO NOTHING
+		RETURNING id, detected_at`,
+		anomaly.ReadingID, anomaly.UnitID, anomaly.UtilityType, anomaly.Consumption, anomaly.BaselineMean,
+		anomaly.BaselineStdDev, anomaly.ZScore).Scan(&anomaly.ID, &anomaly.DetectedAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = tx.QueryRow(`
+		SELECT mr.id
+		FROM utility_anomalies ua
+		JOIN maintenance_requests mr ON ua.maintenance_request_id = mr.id
+		WHERE ua.unit_id = $1 AND ua.utility_type = $2 AND mr.status NOT IN ('completed', 'cancelled')
+		ORDER BY mr.id DESC LIMIT 1`, reading.UnitID, reading.UtilityType).Scan(&anomaly.MaintenanceRequestID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	if !anomaly.MaintenanceRequestID.Valid {
+		uom := uomSuffix(reading.UOM.String)
+		comparison := "up from no recent usage"
+		if baseline.Mean > 0 {
+			comparison = fmt.Sprintf("%.1fx the recent average of %.1f%s", reading.Consumption/baseline.Mean,
+				baseline.Mean, uom)
+		}
+		description := fmt.Sprintf("[Utility anomaly] Unit %s %s usage of %.1f%s on %s is %s. Check for leaks.",
+			anomaly.UnitNumber, reading.UtilityType, reading.Consumption, uom,
+			reading.ReadingDate.Format("2006-01-02"), comparison)
+
+		err = tx.QueryRow(`
+			INSERT INTO maintenance_requests (property_id, description, status, priority)
+			VALUES ($1, $2, 'reported', 'high')
+			RETURNING id`, anomaly.PropertyID, description).Scan(&anomaly.MaintenanceRequestID)
+		if err != nil {
+			return nil, false, err
+		}
+		opened = true
+	}
+
+	_, err = tx.Exec("UPDATE utility_anomalies SET maintenance_request_id = $2 WHERE id = $1",
+		anomaly.ID, anomaly.MaintenanceRequestID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return anomaly, opened, nil
+}
+
+func uomSuffix(uom string) string {
+	if uom == "" {
+		return ""
+	}
+	return " " + uom
+}
+
+// GetUtilityAnomalies retrieves anomalies at properties detected since the given time
+func GetUtilityAnomalies(properties PropertyFilter, since time.Time) ([]UtilityAnomaly, error) {
+	scope, args := properties.condition("p.id", []interface{}{since})
+	query := `
+		SELECT ua.id, ua.reading_id, ua.unit_id, pu.unit_number, p.id, p.name, ua.utility_type,
+			   mr.reading_date, ua.consumption, ua.baseline_mean, ua.baseline_stddev, ua.z_score,
+			   ua.maintenance_request_id, ua.detected_at
+		FROM utility_anomalies ua
+		JOIN meter_readings mr ON ua.reading_id = mr.id
+		JOIN property_units pu ON ua.unit_id = pu.id
+		JOIN properties p ON pu.property_id = p.id
+		WHERE ua.detected_at >= $1` + scope + `
+		ORDER BY ua.detected_at DESC`
+
+	rows, err := db.ReadDB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var anomalies []UtilityAnomaly
+	for rows.Next() {
+		var a UtilityAnomaly
+		err := rows.Scan(&a.ID, &a.ReadingID, &a.UnitID, &a.UnitNumber, &a.PropertyID, &a.PropertyName,
+			&a.UtilityType, &a.ReadingDate, &a.Consumption, &a.BaselineMean, &a.BaselineStdDev, &a.ZScore,
+			&a.MaintenanceRequestID, &a.DetectedAt)
+		if err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, a)
+	}
+
+	return anomalies, nil
+}
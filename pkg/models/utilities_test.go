@@ -0,0 +1,95 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectUsageAnomaly(t *testing.T) {
+	history := []float64{100, 110, 90, 105, 95, 100, 100}
+
+	// Mean 100, stddev ~5.98
+	baseline, anomalous := DetectUsageAnomaly(history, 250)
+	assert.True(t, anomalous)
+	assert.InDelta(t, 100, baseline.Mean, 0.001)
+	assert.InDelta(t, 5.98, baseline.StdDev, 0.01)
+	assert.Equal(t, 25.1, baseline.ZScore)
+
+	// Above three standard deviations but not 1.5x the mean
+	_, anomalous = DetectUsageAnomaly(history, 125)
+	assert.False(t, anomalous)
+
+	// Not enough history yet
+	_, anomalous = DetectUsageAnomaly(history[:6], 500)
+	assert.False(t, anomalous)
+
+	// Perfectly steady usage
+	baseline, anomalous = DetectUsageAnomaly([]float64{10, 10, 10, 10, 10, 10, 10}, 20)
+	assert.True(t, anomalous)
+	assert.Equal(t, 999.99, baseline.ZScore)
+
+	_, anomalous = DetectUsageAnomaly([]float64{10, 10, 10, 10, 10, 10, 10}, 10)
+	assert.False(t, anomalous)
+}
+
+func TestRecordUtilityAnomalySkipsRecordedReading(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	reading := MeterReading{ID: 30, UnitID: 4, UtilityType: "water", Consumption: 250}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pu.unit_number, p.id, p.name`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"unit_number", "id", "name"}).AddRow("2B", 7, "Elm Court"))
+	mock.ExpectQuery(`INSERT INTO utility_anomalies (.+) ON CONFLICT \(reading_id\) DO NOTHING`).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	tx, err := db.DB.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	anomaly, opened, err := RecordUtilityAnomaly(tx, reading, UsageBaseline{Mean: 100, StdDev: 6, ZScore: 25})
+	assert.NoError(t, err)
+	assert.Nil(t, anomaly)
+	assert.False(t, opened)
+}
+
+func TestRecordUtilityAnomalyWithoutPriorUsage(t *testing.T) {
+	mock, cleanup := setupReportsTestDB(t)
+	defer cleanup()
+
+	reading := MeterReading{ID: 30, UnitID: 4, UtilityType: "water", Consumption: 250,
+		ReadingDate: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pu.unit_number, p.id, p.name`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"unit_number", "id", "name"}).AddRow("2B", 7, "Elm Court"))
+	mock.ExpectQuery(`INSERT INTO utility_anomalies`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "detected_at"}).AddRow(12, time.Now()))
+	mock.ExpectQuery(`SELECT mr.id`).
+		WithArgs(4, "water").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`INSERT INTO maintenance_requests`).
+		WithArgs(7, "[Utility anomaly] Unit 2B water usage of 250.0 on 2026-03-02 is up from no recent usage. Check for leaks.").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(55))
+	mock.ExpectExec(`UPDATE utility_anomalies SET maintenance_request_id`).
+		WithArgs(12, sql.NullInt32{Int32: 55, Valid: true}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	tx, err := db.DB.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	anomaly, opened, err := RecordUtilityAnomaly(tx, reading, UsageBaseline{ZScore: 999.99})
+	require.NoError(t, err)
+	assert.True(t, opened)
+	assert.Equal(t, int32(55), anomaly.MaintenanceRequestID.Int32)
+}
@@ -0,0 +1,432 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// BenchmarkTolerance is how far above its benchmark a property's cost per square foot may be
+// before it is flagged
+const BenchmarkTolerance = 0.20
+
+var (
+	// ErrInvalidUtilityExpense is returned for an expense with a negative amount, an unknown
+	// utility type or a period that ends before it starts
+	ErrInvalidUtilityExpense = errors.New("an expense needs a utility type of water, electric or gas, an amount of zero or more and a period that ends on or after it starts")
+	// ErrInvalidUtilityBenchmark is returned for a benchmark with an unknown utility type or a
+	// cost that is not positive
+	ErrInvalidUtilityBenchmark = errors.New("a benchmark needs a utility type of water, electric or gas and a positive annual cost per square foot")
+	// ErrInvalidSquareFeet is returned for a floor area that is not positive
+	ErrInvalidSquareFeet = errors.New("square_feet must be greater than zero")
+)
+
+// BenchmarkUtilityTypes are the utilities that can be expensed and benchmarked
+var BenchmarkUtilityTypes = map[string]bool{"water": true, "electric": true, "gas": true}
+
+// UtilityExpense is a utility bill paid for a property
+type UtilityExpense struct {
+	ID          int            `json:"id"`
+	PropertyID  int            `json:"property_id"`
+	UtilityType string         `json:"utility_type"`
+	PeriodStart time.Time      `json:"period_start"`
+	PeriodEnd   time.Time      `json:"period_end"`
+	Amount      float64        `json:"amount"`
+	Vendor      sql.NullString `json:"vendor,omitempty"`
+	CreatedBy   sql.NullInt32  `json:"created_by,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// UtilityBenchmark is the target annual cost per square foot of a utility. An empty
+// PropertyType applies to every property type without its own benchmark.
+type UtilityBenchmark struct {
+	ID                int       `json:"id"`
+	UtilityType       string    `json:"utility_type"`
+	PropertyType      string    `json:"property_type"`
+	AnnualCostPerSqFt float64   `json:"annual_cost_per_sqft"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// UtilityBenchmarkRow is a property's cost for one utility over the report period, annualized
+// per square foot and compared with its benchmark and its peers
+type UtilityBenchmarkRow struct {
+	PropertyID        int      `json:"property_id"`
+	PropertyName      string   `json:"property_name"`
+	PropertyType      string   `json:"property_type"`
+	SquareFeet        int      `json:"square_feet"`
+	UtilityType       string   `json:"utility_type"`
+	Cost              float64  `json:"cost"`
+	Consumption       float64  `json:"consumption"` // Metered usage of the property's units in the period
+	AnnualCostPerSqFt float64  `json:"annual_cost_per_sqft"`
+	Benchmark         *float64 `json:"benchmark,omitempty"`
+	VariancePercent   *float64 `json:"variance_percent,omitempty"`
+	Outlier           bool     `json:"outlier"`
+	OutlierReasons    []string `json:"outlier_reasons"`
+}
+
+// SetPropertySquareFeet records a property's gross floor area
+func SetPropertySquareFeet(propertyID, squareFeet int) error {
+	if squareFeet <= 0 {
+		return ErrInvalidSquareFeet
+	}
+	result, err := db.DB.Exec("UPDATE properties SET square_feet = $2, updated_at = NOW() WHERE id = $1", propertyID, squareFeet)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// CreateUtilityExpense records a utility bill. It returns sql.ErrNoRows if the property does
+// not exist.
+func CreateUtilityExpense(e *UtilityExpense) error {
+	if !BenchmarkUtilityTypes[e.UtilityType] || e.Amount < 0 || e.PeriodEnd.Before(e.PeriodStart) {
+		return ErrInvalidUtilityExpense
+	}
+
+	var exists int
+	if err := db.DB.QueryRow("SELECT 1 FROM properties WHERE id = $1", e.PropertyID).Scan(&exists); err != nil {
+		return err
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO utility_expenses (property_id, utility_type, period_start, period_end, amount, vendor, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		e.PropertyID, e.UtilityType, e.PeriodStart, e.PeriodEnd, e.Amount, e.Vendor, e.CreatedBy).
+		Scan(&e.ID, &e.CreatedAt)
+}
+
+// GetUtilityExpenses returns the expenses at properties whose period overlaps start through end
+func GetUtilityExpenses(properties PropertyFilter, start, end time.Time) ([]UtilityExpense, error) {
+	scope, args := properties.condition("property_id", []interface{}{start, end})
+	rows, err := db.ReadDB().Query(`
+		SELECT id, property_id, utility_type, period_start, period_end, amount, vendor, created_by, created_at
+		FROM utility_expenses
+		WHERE period_start <= $2 AND period_end >= $1`+scope+`
+		ORDER BY property_id, utility_type, period_start`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expenses []UtilityExpense
+	for rows.Next() {
+		var e UtilityExpense
+		if err := rows.Scan(&e.ID, &e.PropertyID, &e.UtilityType, &e.PeriodStart, &e.PeriodEnd, &e.Amount,
+			&e.Vendor, &e.CreatedBy, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, e)
+	}
+	return expenses, rows.Err()
+}
+
+// ProrateExpense returns the share of an expense falling within start through end, assuming
+// the cost accrued evenly over the billing period
+func ProrateExpense(e UtilityExpense, start, end time.Time) float64 {
+	from, to := e.PeriodStart, e.PeriodEnd
+	if start.After(from) {
+		from = start
+	}
+	if end.Before(to) {
+		to = end
+	}
+	if to.Before(from) {
+		return 0
+	}
+	overlap := to.Sub(from).Hours()/24 + 1
+	period := e.PeriodEnd.Sub(e.PeriodStart).Hours()/24 + 1
+	return e.Amount * overlap / period
+}
+
+// GetUtilityBenchmarks returns the configured benchmarks
+func GetUtilityBenchmarks() ([]UtilityBenchmark, error) {
+	rows, err := db.ReadDB().Query(`
+		SELECT id, utility_type, property_type, annual_cost_per_sqft, updated_at
+		FROM utility_benchmarks ORDER BY utility_type, property_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var benchmarks []UtilityBenchmark
+	for rows.Next() {
+		var b UtilityBenchmark
+		if err := rows.Scan(&b.ID, &b.UtilityType, &b.PropertyType, &b.AnnualCostPerSqFt, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		benchmarks = append(benchmarks, b)
+	}
+	return benchmarks, rows.Err()
+}
+
+// SaveUtilityBenchmark creates or replaces the benchmark for a utility and property type
+func SaveUtilityBenchmark(b *UtilityBenchmark) error {
+	if !BenchmarkUtilityTypes[b.UtilityType] || b.AnnualCostPerSqFt <= 0 {
+		return ErrInvalidUtilityBenchmark
+	}
+	return db.DB.QueryRow(`
+		INSERT INTO utility_benchmarks (utility_type, property_type, annual_cost_per_sqft)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (utility_type, property_type) DO UPDATE
+		SET annual_cost_per_sqft = EXCLUDED.annual_cost_per_sqft, updated_at = NOW()
+		RETURNING id, updated_at`,
+		b.UtilityType, b.PropertyType, b.AnnualCostPerSqFt).Scan(&b.ID, &b.UpdatedAt)
+}
+
+// DeleteUtilityBenchmark removes a benchmark
+func DeleteUtilityBenchmark(id int) error {
+	result, err := db.DB.Exec("DELETE FROM utility_benchmarks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// benchmarkFor returns the benchmark for a property type, falling back to the one for every type
+func benchmarkFor(benchmarks []UtilityBenchmark, utilityType, propertyType string) *float64 {
+	var fallback *float64
+	for i := range benchmarks {
+		b := &benchmarks[i]
+		if b.UtilityType != utilityType {
+			continue
+		}
+		if b.PropertyType == propertyType {
+			return &b.AnnualCostPerSqFt
+		}
+		if b.PropertyType == "" {
+			fallback = &b.AnnualCostPerSqFt
+		}
+	}
+	return fallback
+}
+
+// quartiles returns the first and third quartiles of sorted values, interpolating between ranks
+func quartiles(sorted []float64) (float64, float64) {
+	at := func(p float64) float64 {
+		rank := p * float64(len(sorted)-1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+	}
+	return at(0.25), at(0.75)
+}
+
+// FlagBenchmarkOutliers compares each row with its benchmark and with the other properties'
+// cost for the same utility. A row is an outlier when it exceeds its benchmark by more than
+// BenchmarkTolerance, or falls outside 1.5 interquartile ranges of its peers (with at least
+// four properties to compare).
+func FlagBenchmarkOutliers(rows []UtilityBenchmarkRow) {
+	peers := map[string][]float64{}
+	for _, row := range rows {
+		peers[row.UtilityType] = append(peers[row.UtilityType], row.AnnualCostPerSqFt)
+	}
+	fences := map[string][2]float64{}
+	for utilityType, values := range peers {
+		if len(values) < 4 {
+			continue
+		}
+		sort.Float64s(values)
+		q1, q3 := quartiles(values)
+		iqr := q3 - q1
+		fences[utilityType] = [2]float64{q1 - 1.5*iqr, q3 + 1.5*iqr}
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		row.OutlierReasons = []string{}
+		if row.Benchmark != nil {
+			variance := math.Round((row.AnnualCostPerSqFt/(*row.Benchmark)-1)*1000) / 10
+			row.VariancePercent = &variance
+			if row.AnnualCostPerSqFt > *row.Benchmark*(1+BenchmarkTolerance) {
+				row.OutlierReasons = append(row.OutlierReasons, fmt.Sprintf("%.1f%% above benchmark", variance))
+			}
+		}
+		if fence, ok := fences[row.UtilityType]; ok {
+			if row.AnnualCostPerSqFt > fence[1] {
+				row.OutlierReasons = append(row.OutlierReasons, "high compared with other properties")
+			} else if row.AnnualCostPerSqFt < fence[0] {
+				row.OutlierReasons = append(row.OutlierReasons, "low compared with other properties")
+			}
+		}
+		row.Outlier = len(row.OutlierReasons) > 0
+	}
+}
+
+// GetUtilityBenchmarkRows builds the benchmarking comparison for start through end. Properties
+// without a recorded floor area cannot be normalised and are counted in the second return value.
+func GetUtilityBenchmarkRows(properties PropertyFilter, start, end time.Time) ([]UtilityBenchmarkRow, int, error) {
+	type property struct {
+		name, propertyType string
+		squareFeet         sql.NullInt32
+	}
+	scope, args := properties.condition("id", nil)
+	propRows, err := db.ReadDB().Query(`
+		SELECT id, name, property_type, square_feet FROM properties
+		WHERE 1 = 1`+scope+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer propRows.Close()
+
+	byID := map[int]property{}
+	var order []int
+	for propRows.Next() {
+		var id int
+		var p property
+		if err := propRows.Scan(&id, &p.name, &p.propertyType, &p.squareFeet); err != nil {
+			return nil, 0, err
+		}
+		byID[id] = p
+		order = append(order, id)
+	}
+	if err := propRows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	type key struct {
+		propertyID  int
+		utilityType string
+	}
+	costs := map[key]float64{}
+	expenses, err := GetUtilityExpenses(properties, start, end)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, e := range expenses {
+		costs[key{e.PropertyID, e.UtilityType}] += ProrateExpense(e, start, end)
+	}
+
+	consumption := map[key]float64{}
+	scope, args = properties.condition("pu.property_id", []interface{}{start, end})
+	usageRows, err := db.ReadDB().Query(`
+		SELECT pu.property_id, mr.utility_type, SUM(mr.consumption)
+		FROM meter_readings mr JOIN property_units pu ON pu.id = mr.unit_id
+		WHERE mr.reading_date >= $1 AND mr.reading_date <= $2`+scope+`
+		GROUP BY pu.property_id, mr.utility_type`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer usageRows.Close()
+	for usageRows.Next() {
+		var k key
+		var total float64
+		if err := usageRows.Scan(&k.propertyID, &k.utilityType, &total); err != nil {
+			return nil, 0, err
+		}
+		consumption[k] = total
+	}
+	if err := usageRows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	benchmarks, err := GetUtilityBenchmarks()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	days := end.Sub(start).Hours()/24 + 1
+	utilityTypes := []string{"electric", "gas", "water"}
+	var rows []UtilityBenchmarkRow
+	missingSquareFeet := 0
+	for _, id := range order {
+		p := byID[id]
+		if !p.squareFeet.Valid {
+			missingSquareFeet++
+			continue
+		}
+		for _, utilityType := range utilityTypes {
+			k := key{id, utilityType}
+			cost, hasCost := costs[k]
+			if !hasCost {
+				continue
+			}
+			rows = append(rows, UtilityBenchmarkRow{
+				PropertyID:        id,
+				PropertyName:      p.name,
+				PropertyType:      p.propertyType,
+				SquareFeet:        int(p.squareFeet.Int32),
+				UtilityType:       utilityType,
+				Cost:              math.Round(cost*100) / 100,
+				Consumption:       consumption[k],
+				AnnualCostPerSqFt: math.Round(cost*365/days/float64(p.squareFeet.Int32)*10000) / 10000,
+				Benchmark:         benchmarkFor(benchmarks, utilityType, p.propertyType),
+			})
+		}
+	}
+
+	FlagBenchmarkOutliers(rows)
+	return rows, missingSquareFeet, nil
+}
+
+// generateUtilityBenchmarkReport compares utility cost per square foot across properties and
+// against benchmarks, defaulting to the last twelve months
+func generateUtilityBenchmarkReport(report *CustomReport, parameters map[string]interface{}) (*ReportData, error) {
+	end := time.Now().Truncate(24 * time.Hour)
+	start := end.AddDate(-1, 0, 1)
+	if value, ok := parameters["start_date"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			start = parsed
+		}
+	}
+	if value, ok := parameters["end_date"].(string); ok {
+		if parsed, err := time.Parse("2006-01-02", value); err == nil {
+			end = parsed
+		}
+	}
+
+	rows, missingSquareFeet, err := GetUtilityBenchmarkRows(reportProperties(report), start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &ReportData{
+		Headers: []string{"Property", "Type", "Square Feet", "Utility", "Cost", "Consumption",
+			"Annual Cost / Sq Ft", "Benchmark", "Variance %", "Outlier"},
+		Rows: []map[string]interface{}{},
+	}
+
+	outliers := []string{}
+	for _, row := range rows {
+		var benchmark, variance interface{}
+		if row.Benchmark != nil {
+			benchmark, variance = *row.Benchmark, *row.VariancePercent
+		}
+		outlier := ""
+		if row.Outlier {
+			outlier = strings.Join(row.OutlierReasons, "; ")
+			outliers = append(outliers, fmt.Sprintf("%s %s: %s", row.PropertyName, row.UtilityType, outlier))
+		}
+		data.Rows = append(data.Rows, map[string]interface{}{
+			"Property":            row.PropertyName,
+			"Type":                row.PropertyType,
+			"Square Feet":         row.SquareFeet,
+			"Utility":             row.UtilityType,
+			"Cost":                row.Cost,
+			"Consumption":         row.Consumption,
+			"Annual Cost / Sq Ft": row.AnnualCostPerSqFt,
+			"Benchmark":           benchmark,
+			"Variance %":          variance,
+			"Outlier":             outlier,
+		})
+	}
+
+	data.Summary = map[string]interface{}{
+		"period_start":                  start.Format("2006-01-02"),
+		"period_end":                    end.Format("2006-01-02"),
+		"rows":                          len(rows),
+		"outlier_count":                 len(outliers),
+		"outliers":                      outliers,
+		"properties_missing_floor_area": missingSquareFeet,
+	}
+	data.Columns = typedColumns(data.Headers, map[string]ReportColumn{
+		"Square Feet": numberColumn, "Cost": currencyColumn, "Consumption": numberColumn.WithDecimals(2),
+		"Annual Cost / Sq Ft": currencyColumn, "Benchmark": currencyColumn, "Variance %": percentColumn,
+	})
+	return data, nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProrateExpense(t *testing.T) {
+	day := func(d string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", d)
+		return parsed
+	}
+	expense := UtilityExpense{PeriodStart: day("2026-01-01"), PeriodEnd: day("2026-01-10"), Amount: 100}
+
+	assert.InDelta(t, 100, ProrateExpense(expense, day("2025-12-01"), day("2026-02-01")), 0.001)
+	assert.InDelta(t, 50, ProrateExpense(expense, day("2026-01-06"), day("2026-03-01")), 0.001)
+	assert.InDelta(t, 0, ProrateExpense(expense, day("2026-02-01"), day("2026-03-01")), 0.001)
+}
+
+func TestBenchmarkForPrefersPropertyType(t *testing.T) {
+	benchmarks := []UtilityBenchmark{
+		{UtilityType: "water", PropertyType: "", AnnualCostPerSqFt: 0.5},
+		{UtilityType: "water", PropertyType: "apartment", AnnualCostPerSqFt: 0.7},
+	}
+
+	require.NotNil(t, benchmarkFor(benchmarks, "water", "apartment"))
+	assert.Equal(t, 0.7, *benchmarkFor(benchmarks, "water", "apartment"))
+	assert.Equal(t, 0.5, *benchmarkFor(benchmarks, "water", "house"))
+	assert.Nil(t, benchmarkFor(benchmarks, "gas", "house"))
+}
+
+func TestFlagBenchmarkOutliers(t *testing.T) {
+	benchmark := 1.0
+	rows := []UtilityBenchmarkRow{
+		{PropertyID: 1, UtilityType: "electric", AnnualCostPerSqFt: 1.1, Benchmark: &benchmark},
+		{PropertyID: 2, UtilityType: "electric", AnnualCostPerSqFt: 1.0},
+		{PropertyID: 3, UtilityType: "electric", AnnualCostPerSqFt: 0.9},
+		{PropertyID: 4, UtilityType: "electric", AnnualCostPerSqFt: 1.05},
+		{PropertyID: 5, UtilityType: "electric", AnnualCostPerSqFt: 3.0, Benchmark: &benchmark},
+	}
+
+	FlagBenchmarkOutliers(rows)
+
+	assert.False(t, rows[0].Outlier)
+	require.NotNil(t, rows[0].VariancePercent)
+	assert.Equal(t, 10.0, *rows[0].VariancePercent)
+	assert.False(t, rows[1].Outlier)
+	assert.Empty(t, rows[1].OutlierReasons)
+
+	assert.True(t, rows[4].Outlier)
+	assert.Equal(t, []string{"200.0% above benchmark", "high compared with other properties"}, rows[4].OutlierReasons)
+}
+
+func TestSaveUtilityBenchmarkValidates(t *testing.T) {
+	assert.Equal(t, ErrInvalidUtilityBenchmark, SaveUtilityBenchmark(&UtilityBenchmark{UtilityType: "steam", AnnualCostPerSqFt: 1}))
+	assert.Equal(t, ErrInvalidUtilityBenchmark, SaveUtilityBenchmark(&UtilityBenchmark{UtilityType: "gas"}))
+}
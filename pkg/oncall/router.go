@@ -0,0 +1,173 @@
+package oncall
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Router sends new emergency-priority maintenance requests to the property's current
+// on-call contact by SMS and email through the outbox
+type Router struct {
+	Interval time.Duration
+}
+
+// NewRouter creates a router that checks for new emergencies every minute
+func NewRouter() *Router {
+	return &Router{Interval: time.Minute}
+}
+
+// Run routes emergencies every Interval until the context is cancelled
+func (r *Router) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		if routed, err := r.RouteOnce(time.Now()); err != nil {
+			slog.Error("Emergency maintenance routing failed", "error", err)
+		} else if routed > 0 {
+			slog.Info("Routed emergency maintenance requests to on-call contacts", "count", routed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RouteOnce notifies the on-call contact for every unrouted emergency request and returns
+// the number routed. Requests for properties without a reachable on-call contact stay
+// unrouted and are retried on the next pass; property managers are emailed about them once.
+func (r *Router) RouteOnce(now time.Time) (int, error) {
+	requests, err := models.GetUnroutedEmergencyRequests()
+	if err != nil {
+		return 0, err
+	}
+
+	routed := 0
+	for _, req := range requests {
+		contact, err := models.GetOnCallContact(req.PropertyID, now)
+		if err != nil && err != sql.ErrNoRows {
+			return routed, err
+		}
+		if err == sql.ErrNoRows || !contact.Reachable() {
+			slog.Warn("No reachable on-call contact, emergency maintenance request is unrouted",
+				"property_id", req.PropertyID, "maintenance_request_id", req.ID)
+			if err := escalate(req); err != nil {
+				return routed, fmt.Errorf("failed to escalate request %d: %w", req.ID, err)
+			}
+			continue
+		}
+
+		if err := route(req, contact); err != nil {
+			return routed, fmt.Errorf("failed to route request %d: %w", req.ID, err)
+		}
+		routed++
+	}
+
+	return routed, nil
+}
+
+// route queues the notifications and marks the request routed in one transaction
+func route(req models.EmergencyRequest, contact *models.OnCallContact) error {
+	subject, body := FormatEmergencyMessage(req)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	payload := map[string]interface{}{
+		"maintenance_request_id": req.ID,
+		"property_id":            req.PropertyID,
+		"subject":                subject,
+		"body":                   body,
+	}
+	if contact.Phone != "" {
+		err := models.EnqueueOutboxMessage(tx, &models.OutboxMessage{
+			Channel:     "sms",
+			EventType:   "maintenance.emergency",
+			Destination: contact.Phone,
+			Payload:     payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if contact.Email != "" {
+		err := models.EnqueueOutboxMessage(tx, &models.OutboxMessage{
+			Channel:     "email",
+			EventType:   "maintenance.emergency",
+			Destination: contact.Email,
+			Payload:     payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := models.MarkEmergencyRouted(tx, req.ID, contact.UserID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// escalate emails property managers that a request could not be routed, once per request
+func escalate(req models.EmergencyRequest) error {
+	if req.Escalated {
+		return nil
+	}
+	managers, err := models.GetEscalationManagerEmails()
+	if err != nil {
+		return err
+	}
+	if len(managers) == 0 {
+		slog.Error("No property managers to escalate unrouted emergency to", "maintenance_request_id", req.ID)
+		return nil
+	}
+
+	subject, body := FormatEmergencyMessage(req)
+	payload := map[string]interface{}{
+		"maintenance_request_id": req.ID,
+		"property_id":            req.PropertyID,
+		"subject":                "UNROUTED " + subject,
+		"body":                   body + "\n\nNo on-call contact with a phone number or email address is set up for this property.",
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, email := range managers {
+		err := models.EnqueueOutboxMessage(tx, &models.OutboxMessage{
+			Channel:     "email",
+			EventType:   "maintenance.emergency_unrouted",
+			Destination: email,
+			Payload:     payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := models.MarkEmergencyEscalated(tx, req.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FormatEmergencyMessage builds the subject and short body sent to the on-call contact
+func FormatEmergencyMessage(req models.EmergencyRequest) (string, string) {
+	subject := fmt.Sprintf("EMERGENCY maintenance at %s (#%d)", req.PropertyName, req.ID)
+	body := fmt.Sprintf("Emergency maintenance request #%d at %s: %s", req.ID, req.PropertyName, req.Description)
+	return subject, body
+}
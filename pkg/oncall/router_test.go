@@ -0,0 +1,62 @@
+package oncall
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatEmergencyMessage(t *testing.T) {
+	subject, body := FormatEmergencyMessage(models.EmergencyRequest{
+		ID:           17,
+		PropertyName: "Sunset Apartments",
+		Description:  "Burst pipe in unit 4B",
+	})
+
+	assert.Equal(t, "EMERGENCY maintenance at Sunset Apartments (#17)", subject)
+	assert.Equal(t, "Emergency maintenance request #17 at Sunset Apartments: Burst pipe in unit 4B", body)
+}
+
+func TestRouteOnceEscalatesUnreachableContact(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	originalDB := db.DB
+	db.DB = mockDB
+	defer func() {
+		db.DB = originalDB
+		mockDB.Close()
+	}()
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM maintenance_requests mr`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "property_id", "name", "description", "reported_date", "escalated"}).
+			AddRow(17, 3, "Sunset Apartments", "Burst pipe", now, false))
+	mock.ExpectQuery(`FROM oncall_shifts`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "property_id", "user_id", "name", "starts_at", "ends_at",
+			"notes", "created_by", "created_at"}))
+	// A contact with a name but no phone number or email address cannot be notified
+	mock.ExpectQuery(`SELECT emergency_contact_name`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "phone", "email"}).AddRow("Front desk", nil, nil))
+	mock.ExpectQuery(`SELECT DISTINCT u.email`).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("manager@example.com"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", "maintenance.emergency_unrouted", "manager@example.com", sqlmock.AnyArg(), 8).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectExec(`UPDATE maintenance_requests SET oncall_escalated_at`).
+		WithArgs(17).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	routed, err := NewRouter().RouteOnce(now)
+	require.NoError(t, err)
+	assert.Zero(t, routed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,142 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Handler delivers a single outbox message. Returning an error schedules a retry.
+type Handler interface {
+	Deliver(ctx context.Context, msg *models.OutboxMessage) error
+}
+
+// HandlerFunc adapts an ordinary function to the Handler interface
+type HandlerFunc func(ctx context.Context, msg *models.OutboxMessage) error
+
+// Deliver calls f(ctx, msg)
+func (f HandlerFunc) Deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	return f(ctx, msg)
+}
+
+// Dispatcher polls the outbox table and hands due messages to the handler
+// registered for their channel, retrying failures with exponential backoff.
+type Dispatcher struct {
+	PollInterval  time.Duration
+	BatchSize     int
+	LeaseDuration time.Duration
+	BaseBackoff   time.Duration
+	MaxBackoff    time.Duration
+
+	handlers map[string]Handler
+}
+
+// NewDispatcher creates a dispatcher with sensible defaults
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		PollInterval:  5 * time.Second,
+		BatchSize:     25,
+		LeaseDuration: 2 * time.Minute,
+		BaseBackoff:   30 * time.Second,
+		MaxBackoff:    6 * time.Hour,
+		handlers:      make(map[string]Handler),
+	}
+}
+
+// Register sets the handler used to deliver messages on the given channel
+func (d *Dispatcher) Register(channel string, handler Handler) {
+	d.handlers[channel] = handler
+}
+
+// Channels returns the channels with a registered handler, in alphabetical order
+func (d *Dispatcher) Channels() []string {
+	channels := make([]string, 0, len(d.handlers))
+	for channel := range d.handlers {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Run polls for due messages until the context is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DispatchOnce(ctx); err != nil {
+			slog.Error("Outbox dispatch failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce claims a batch of due messages and attempts delivery of each.
+// It returns the number of messages that were delivered successfully.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	messages, err := models.ClaimOutboxMessages(d.BatchSize, d.LeaseDuration)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range messages {
+		msg := &messages[i]
+
+		deliveryErr := d.deliver(ctx, msg)
+		if deliveryErr == nil {
+			if err := models.MarkOutboxMessageDelivered(msg.ID); err != nil {
+				slog.Error("Failed to mark outbox message delivered", "message_id", msg.ID, "error", err)
+			}
+			delivered++
+			continue
+		}
+
+		dead := msg.Attempts >= msg.MaxAttempts
+		nextAttempt := time.Now().Add(Backoff(msg.Attempts, d.BaseBackoff, d.MaxBackoff))
+		if err := models.MarkOutboxMessageFailed(msg.ID, deliveryErr.Error(), nextAttempt, dead); err != nil {
+			slog.Error("Failed to record outbox failure", "message_id", msg.ID, "error", err)
+		}
+		if dead {
+			slog.Warn("Outbox message moved to dead-letter", "message_id", msg.ID, "event_type", msg.EventType,
+				"attempts", msg.Attempts, "error", deliveryErr)
+		}
+	}
+
+	return delivered, nil
+}
+
+// deliver routes a message to its channel handler
+func (d *Dispatcher) deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	handler, ok := d.handlers[msg.Channel]
+	if !ok {
+		return fmt.Errorf("no handler registered for channel %q", msg.Channel)
+	}
+	return handler.Deliver(ctx, msg)
+}
+
+// Backoff returns the delay before the next attempt, doubling from base for
+// each prior attempt and capped at max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/mailer"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	base := 30 * time.Second
+	max := 10 * time.Minute
+
+	assert.Equal(t, base, Backoff(0, base, max))
+	assert.Equal(t, base, Backoff(1, base, max))
+	assert.Equal(t, 60*time.Second, Backoff(2, base, max))
+	assert.Equal(t, 4*time.Minute, Backoff(4, base, max))
+	assert.Equal(t, max, Backoff(10, base, max))
+}
+
+func TestWebhookHandlerDeliver(t *testing.T) {
+	var gotEvent, gotDeliveryID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEvent = r.Header.Get("X-Event-Type")
+		gotDeliveryID = r.Header.Get("X-Delivery-ID")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	handler := NewWebhookHandler(time.Second)
+	msg := &models.OutboxMessage{
+		ID:          42,
+		EventType:   "user.registered",
+		Destination: srv.URL,
+		Payload:     map[string]interface{}{"user_id": 1},
+	}
+
+	assert.NoError(t, handler.Deliver(context.Background(), msg))
+	assert.Equal(t, "user.registered", gotEvent)
+	assert.Equal(t, "42", gotDeliveryID)
+}
+
+func TestWebhookHandlerDeliverFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	handler := NewWebhookHandler(time.Second)
+	msg := &models.OutboxMessage{Destination: srv.URL, Payload: map[string]interface{}{}}
+
+	err := handler.Deliver(context.Background(), msg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "502")
+}
+
+func TestDispatcherUnknownChannel(t *testing.T) {
+	d := NewDispatcher()
+	err := d.deliver(context.Background(), &models.OutboxMessage{Channel: "fax"})
+	assert.Error(t, err)
+}
+
+func TestSMSHandlerDeliver(t *testing.T) {
+	var got map[string]string
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	handler := &SMSHandler{GatewayURL: srv.URL, Token: "secret", Client: srv.Client()}
+	msg := &models.OutboxMessage{
+		Destination: "+15550100",
+		Payload:     map[string]interface{}{"body": "Burst pipe at Sunset Apartments"},
+	}
+
+	assert.NoError(t, handler.Deliver(context.Background(), msg))
+	assert.Equal(t, "Bearer secret", gotAuth)
+	assert.Equal(t, map[string]string{"to": "+15550100", "message": "Burst pipe at Sunset Apartments"}, got)
+
+	assert.Error(t, handler.Deliver(context.Background(), &models.OutboxMessage{Payload: map[string]interface{}{}}))
+}
+
+type recordingSender struct {
+	sent []*mailer.Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg *mailer.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestEmailHandlerDeliver(t *testing.T) {
+	sender := &recordingSender{}
+	handler := &EmailHandler{Sender: sender}
+
+	msg := &models.OutboxMessage{
+		ID:          3,
+		Destination: "pm@example.com",
+		Payload:     map[string]interface{}{"subject": "Digest", "body": "Plain", "html": "<p>Rich</p>", "report_id": 9},
+	}
+	assert.NoError(t, handler.Deliver(context.Background(), msg))
+	assert.Equal(t, []*mailer.Message{{To: "pm@example.com", Subject: "Digest", Text: "Plain", HTML: "<p>Rich</p>"}}, sender.sent)
+
+	assert.Error(t, handler.Deliver(context.Background(), &models.OutboxMessage{Payload: map[string]interface{}{}}))
+	assert.Len(t, sender.sent, 1)
+}
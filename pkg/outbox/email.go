@@ -0,0 +1,35 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/mailer"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// EmailHandler delivers outbox messages through a mailer backend. The destination is the
+// recipient address and the payload is decoded by mailer.MessageFromPayload: "subject" and
+// "body", an optional "html" alternative with inline "images", or "attachments".
+type EmailHandler struct {
+	Sender mailer.Sender
+}
+
+// NewEmailHandlerFromEnv configures email delivery with mailer.NewSenderFromEnv. It returns nil
+// when email is not configured.
+func NewEmailHandlerFromEnv() (*EmailHandler, error) {
+	sender, err := mailer.NewSenderFromEnv()
+	if err != nil || sender == nil {
+		return nil, err
+	}
+	return &EmailHandler{Sender: sender}, nil
+}
+
+// Deliver sends the message to its destination address
+func (h *EmailHandler) Deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	email, err := mailer.MessageFromPayload(msg.Destination, msg.Payload)
+	if err != nil {
+		return fmt.Errorf("email message %d: %w", msg.ID, err)
+	}
+	return h.Sender.Send(ctx, email)
+}
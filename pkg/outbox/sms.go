@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// SMSHandler delivers outbox messages through an HTTP SMS gateway. The gateway receives a
+// JSON body of {"to": destination, "message": payload["body"]}.
+type SMSHandler struct {
+	GatewayURL string
+	Token      string
+	Client     *http.Client
+}
+
+// NewSMSHandlerFromEnv configures SMS delivery from SMS_GATEWAY_URL and the optional
+// SMS_GATEWAY_TOKEN bearer token. It returns nil when SMS_GATEWAY_URL is not set.
+func NewSMSHandlerFromEnv() *SMSHandler {
+	gatewayURL := os.Getenv("SMS_GATEWAY_URL")
+	if gatewayURL == "" {
+		return nil
+	}
+
+	return &SMSHandler{
+		GatewayURL: gatewayURL,
+		Token:      os.Getenv("SMS_GATEWAY_TOKEN"),
+		Client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Deliver sends the message text to the destination phone number
+func (h *SMSHandler) Deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	text, _ := msg.Payload["body"].(string)
+	if text == "" {
+		return fmt.Errorf("sms message %d has no body", msg.ID)
+	}
+
+	body, err := json.Marshal(map[string]string{"to": msg.Destination, "message": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.GatewayURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sms gateway returned status %d", resp.StatusCode)
+	}
+	return nil
+}
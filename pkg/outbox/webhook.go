@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// WebhookHandler delivers outbox messages as JSON POST requests to the message destination
+type WebhookHandler struct {
+	Client *http.Client
+}
+
+// NewWebhookHandler creates a webhook handler with the given request timeout
+func NewWebhookHandler(timeout time.Duration) *WebhookHandler {
+	return &WebhookHandler{
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Deliver posts the message payload to its destination URL. Any non-2xx response is treated as a failure.
+func (h *WebhookHandler) Deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	body, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Destination, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", msg.EventType)
+	// Receivers can use the message ID to de-duplicate redelivered events
+	req.Header.Set("X-Delivery-ID", strconv.Itoa(msg.ID))
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
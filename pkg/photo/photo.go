@@ -0,0 +1,117 @@
+package photo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Register the GIF decoder
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder
+
+	"github.com/greenbrown932/fire-pmaas/pkg/avatar"
+)
+
+// MaxUploadBytes is the largest accepted photo
+const MaxUploadBytes = 10 << 20
+
+// Variant is a resized copy of a photo, bounded by MaxEdge pixels on its longest side
+type Variant struct {
+	Name    string
+	MaxEdge int
+}
+
+// Variants lists the sizes generated for every photo, largest first
+var Variants = []Variant{
+	{Name: "full", MaxEdge: 2048},
+	{Name: "medium", MaxEdge: 1024},
+	{Name: "thumbnail", MaxEdge: 320},
+}
+
+// ValidVariant reports whether name is one of Variants
+func ValidVariant(name string) bool {
+	for _, v := range Variants {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Processed is an uploaded photo encoded as JPEG in every variant size
+type Processed struct {
+	Width    int // Dimensions of the full variant
+	Height   int
+	Variants map[string][]byte
+}
+
+// Process validates an uploaded PNG, JPEG or GIF and produces its variants. It returns
+// avatar.ErrUnsupportedFormat or avatar.ErrTooLarge for unacceptable uploads.
+func Process(data []byte) (*Processed, error) {
+	if len(data) > MaxUploadBytes {
+		return nil, avatar.ErrTooLarge
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, avatar.ErrUnsupportedFormat
+	}
+	switch format {
+	case "png", "jpeg", "gif":
+	default:
+		return nil, avatar.ErrUnsupportedFormat
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, avatar.ErrUnsupportedFormat
+	}
+	if config.Width > avatar.MaxSourceDimension || config.Height > avatar.MaxSourceDimension {
+		return nil, avatar.ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	processed := &Processed{Variants: map[string][]byte{}}
+	// Each variant is scaled from the previous, larger one to keep large uploads cheap
+	current := src
+	for i, v := range Variants {
+		width, height := Fit(current.Bounds().Dx(), current.Bounds().Dy(), v.MaxEdge)
+		if width != current.Bounds().Dx() || height != current.Bounds().Dy() {
+			current = avatar.Scale(current.Bounds(), current, width, height)
+		}
+		if i == 0 {
+			processed.Width, processed.Height = width, height
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, flatten(current), &jpeg.Options{Quality: 85}); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		processed.Variants[v.Name] = buf.Bytes()
+	}
+	return processed, nil
+}
+
+// Fit scales width x height down so the longest side is at most maxEdge, keeping the aspect ratio.
+// Images that already fit are not enlarged.
+func Fit(width, height, maxEdge int) (int, int) {
+	if width <= maxEdge && height <= maxEdge {
+		return width, height
+	}
+	if width >= height {
+		return maxEdge, max(1, height*maxEdge/width)
+	}
+	return max(1, width*maxEdge/height), maxEdge
+}
+
+// flatten draws img over a white background, since JPEG has no transparency
+func flatten(img image.Image) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Over)
+	return dst
+}
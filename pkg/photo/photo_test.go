@@ -0,0 +1,56 @@
+package photo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/avatar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFit(t *testing.T) {
+	w, h := Fit(4000, 3000, 1024)
+	assert.Equal(t, 1024, w)
+	assert.Equal(t, 768, h)
+
+	w, h = Fit(600, 1200, 320)
+	assert.Equal(t, 160, w)
+	assert.Equal(t, 320, h)
+
+	w, h = Fit(200, 100, 320)
+	assert.Equal(t, 200, w, "small images are not enlarged")
+	assert.Equal(t, 100, h)
+}
+
+func TestProcess(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 1600, 900))
+	for y := 0; y < 900; y++ {
+		for x := 0; x < 1600; x++ {
+			src.Set(x, y, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, src))
+
+	processed, err := Process(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, 1600, processed.Width)
+	assert.Equal(t, 900, processed.Height)
+
+	thumb, err := jpeg.DecodeConfig(bytes.NewReader(processed.Variants["thumbnail"]))
+	require.NoError(t, err)
+	assert.Equal(t, 320, thumb.Width)
+	assert.Equal(t, 180, thumb.Height)
+
+	medium, err := jpeg.DecodeConfig(bytes.NewReader(processed.Variants["medium"]))
+	require.NoError(t, err)
+	assert.Equal(t, 1024, medium.Width)
+
+	_, err = Process([]byte("not an image"))
+	assert.Equal(t, avatar.ErrUnsupportedFormat, err)
+}
@@ -0,0 +1,40 @@
+package preventive
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Scheduler periodically generates maintenance requests for preventive maintenance plans
+// that have come due
+type Scheduler struct {
+	Interval time.Duration
+}
+
+// NewScheduler creates a scheduler that checks for due plans hourly
+func NewScheduler() *Scheduler {
+	return &Scheduler{Interval: time.Hour}
+}
+
+// Run generates due requests every Interval until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if created, err := models.GenerateDuePreventiveRequests(time.Now()); err != nil {
+			slog.Error("Preventive maintenance generation failed", "error", err)
+		} else if created > 0 {
+			slog.Info("Generated preventive maintenance requests", "count", created)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package renewals
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Offerer sends renewal offers LeadDays ahead of lease expiry and expires offers the tenant did
+// not respond to in time
+type Offerer struct {
+	Interval     time.Duration
+	LeadDays     int
+	ResponseDays int
+}
+
+// NewOfferer creates an hourly offerer. RENEWAL_OFFER_LEAD_DAYS sets how far ahead of expiry
+// offers are sent (default 90 days) and RENEWAL_OFFER_RESPONSE_DAYS how long tenants have to
+// respond (default 30 days).
+func NewOfferer() *Offerer {
+	o := &Offerer{Interval: time.Hour, LeadDays: 90, ResponseDays: 30}
+	if days, err := strconv.Atoi(os.Getenv("RENEWAL_OFFER_LEAD_DAYS")); err == nil && days > 0 {
+		o.LeadDays = days
+	}
+	if days, err := strconv.Atoi(os.Getenv("RENEWAL_OFFER_RESPONSE_DAYS")); err == nil && days > 0 {
+		o.ResponseDays = days
+	}
+	return o
+}
+
+// Run sends and expires offers every Interval until the context is cancelled
+func (o *Offerer) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if expired, err := models.ExpireRenewalOffers(now); err != nil {
+			slog.Error("Expiring renewal offers failed", "error", err)
+		} else if expired > 0 {
+			slog.Info("Expired renewal offers", "count", expired)
+		}
+		if sent, err := o.OfferOnce(now); err != nil {
+			slog.Error("Sending renewal offers failed", "error", err)
+		} else if sent > 0 {
+			slog.Info("Sent renewal offers", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// OfferOnce sends offers for every lease ending within LeadDays of now that has not had one and
+// returns the number sent
+func (o *Offerer) OfferOnce(now time.Time) (int, error) {
+	offers, err := models.GenerateRenewalOffers(0, o.LeadDays, o.ResponseDays, sql.NullInt32{}, now)
+	return len(offers), err
+}
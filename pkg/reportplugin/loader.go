@@ -0,0 +1,33 @@
+// Package reportplugin loads custom report types built as Go plugins. A plugin is a package main
+// built with -buildmode=plugin whose init function calls models.RegisterReportType; it must be
+// built with the same Go version and module versions as the server.
+package reportplugin
+
+import (
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+)
+
+// LoadDir opens every .so file in dir, in name order, so their init functions register their
+// report types. It returns the paths of the plugins loaded before the first failure.
+func LoadDir(dir string) ([]string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var loaded []string
+	for _, path := range paths {
+		if _, err := plugin.Open(path); err != nil {
+			return loaded, err
+		}
+		loaded = append(loaded, path)
+	}
+	return loaded, nil
+}
@@ -0,0 +1,45 @@
+package roleexpiry
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Expirer removes temporary role assignments once they expire and emails admins the list
+type Expirer struct {
+	Interval time.Duration
+}
+
+// NewExpirer creates an expirer that checks every five minutes
+func NewExpirer() *Expirer {
+	return &Expirer{Interval: 5 * time.Minute}
+}
+
+// Run removes expired assignments every Interval until the context is cancelled
+func (e *Expirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		if removed, err := e.ExpireOnce(time.Now()); err != nil {
+			slog.Error("Removing expired role assignments failed", "error", err)
+		} else if removed > 0 {
+			slog.Info("Removed expired role assignments", "count", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireOnce removes the assignments that expired by now and returns how many were removed
+func (e *Expirer) ExpireOnce(now time.Time) (int, error) {
+	expired, err := models.RemoveExpiredRoles(now)
+	return len(expired), err
+}
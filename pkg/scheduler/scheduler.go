@@ -0,0 +1,230 @@
+// Package scheduler runs reports on their own schedules. A report with is_scheduled and a
+// schedule_cron is executed at each run of the expression in its time zone, as its creator, and
+// the output is rendered in its schedule_format and stored with the execution record.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/outbox"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// Renderer renders report data as a file in an export format for a recipient
+type Renderer func(report *models.CustomReport, format string, data *models.ReportData, prefs models.UserPreferences, recipient *models.User) (*models.ReportFile, error)
+
+// Scheduler executes scheduled reports as their runs fall due. A failed run is retried with
+// exponential backoff, up to MaxRetries times, before it is skipped until the next scheduled run
+// and the report's creator is told why.
+type Scheduler struct {
+	Interval   time.Duration
+	Render     Renderer
+	Store      storage.Backend
+	MaxRetries int
+	RetryBase  time.Duration // Delay before the first retry, doubled for each one after
+	RetryMax   time.Duration
+}
+
+// NewScheduler creates a scheduler that checks for due reports every minute, so runs happen at
+// the minute their expression names, and retries a failed run four times, 5 minutes to an hour
+// apart. Output is stored in storage.Reports.
+func NewScheduler(render Renderer) *Scheduler {
+	return &Scheduler{
+		Interval:   time.Minute,
+		Render:     render,
+		Store:      storage.Reports,
+		MaxRetries: 4,
+		RetryBase:  5 * time.Minute,
+		RetryMax:   time.Hour,
+	}
+}
+
+// Run executes due reports every Interval until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if generated, err := s.RunOnce(time.Now()); err != nil {
+			slog.Error("Running scheduled reports failed", "error", err)
+		} else if generated > 0 {
+			slog.Info("Generated scheduled reports", "count", generated)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce plans the first run of newly scheduled reports, executes every due one and returns the
+// number generated. A report that fails is recorded and rescheduled; it does not hold up the rest.
+func (s *Scheduler) RunOnce(now time.Time) (int, error) {
+	due, err := models.GetDueReportSchedules(now)
+	if err != nil {
+		return 0, err
+	}
+
+	generated := 0
+	for i := range due {
+		schedule := &due[i]
+		if !schedule.NextRun.Valid {
+			if err := plan(schedule, now); err != nil {
+				slog.Error("Failed to plan scheduled report", "report_id", schedule.ReportID, "error", err)
+			}
+			continue
+		}
+
+		ok, err := s.generate(schedule, now)
+		if err != nil {
+			slog.Error("Failed to generate scheduled report", "report_id", schedule.ReportID, "error", err)
+			if err := s.recordFailure(schedule, err, now); err != nil {
+				slog.Error("Failed to record scheduled report failure", "report_id", schedule.ReportID, "error", err)
+			}
+			continue
+		}
+		if ok {
+			generated++
+		}
+	}
+	return generated, nil
+}
+
+// plan sets the first run of a report scheduled since the last check. Reports are not run the
+// moment they are scheduled, only at the times their expression names.
+func plan(schedule *models.ReportSchedule, now time.Time) error {
+	next, err := schedule.Next(now)
+	if err != nil {
+		return err
+	}
+	_, err = models.PlanReportScheduleRun(schedule, next)
+	return err
+}
+
+// generate claims the report's due run, executes it as its creator, and stores the rendered output
+// with the execution record. It returns false when another server claimed the run.
+func (s *Scheduler) generate(schedule *models.ReportSchedule, now time.Time) (bool, error) {
+	next, err := schedule.Next(now)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	claimed, err := models.ClaimReportScheduleRun(tx, schedule, next, now)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	owner, err := models.GetUserByID(schedule.OwnerID)
+	if err != nil {
+		return false, err
+	}
+	report, err := models.GetCustomReportByID(schedule.ReportID)
+	if err != nil {
+		return false, err
+	}
+
+	data, execution, err := models.ExecuteReportRecorded(report.ID, map[string]interface{}{}, owner)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute report %d: %w", report.ID, err)
+	}
+	if execution.ID == 0 {
+		return false, fmt.Errorf("execution of report %d was not recorded", report.ID)
+	}
+	prefs, err := models.GetUserPreferences(owner.ID)
+	if err != nil {
+		prefs = models.DefaultPreferences()
+	}
+	file, err := s.Render(report, schedule.Format, data, prefs, owner)
+	if err != nil {
+		return false, fmt.Errorf("failed to render report %d as %s: %w", report.ID, schedule.Format, err)
+	}
+
+	key := fmt.Sprintf("%d/%d-%s", report.ID, execution.ID, file.Filename)
+	if _, err := s.Store.Save(key, file.Data); err != nil {
+		return false, err
+	}
+	if err := models.SetReportExecutionFile(execution.ID, schedule.Format, key, int64(len(file.Data))); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	if err := models.RecordReportExport(report, owner, schedule.Format, "schedule", len(file.Data)); err != nil {
+		slog.Error("Failed to audit report export", "report_id", report.ID, "error", err)
+	}
+
+	// Scheduled runs count towards the creator's usage like the exports they stand in for
+	for metric, amount := range map[string]int64{
+		models.UsageReportExecutions: 1,
+		models.UsageExportBytes:      int64(len(file.Data)),
+	} {
+		if _, err := models.RecordUsage(owner.ID, metric, amount, now); err != nil {
+			slog.Error("Failed to record usage", "metric", metric, "user_id", owner.ID, "error", err)
+		}
+	}
+	return true, nil
+}
+
+// recordFailure reschedules a failed run for a retry after the backoff, or once the retries are
+// used up skips it until the next scheduled run and emails the report's creator the error
+func (s *Scheduler) recordFailure(schedule *models.ReportSchedule, cause error, now time.Time) error {
+	attempt := schedule.RetryCount + 1
+	gaveUp := attempt > s.MaxRetries
+	nextRun := now.Add(outbox.Backoff(attempt, s.RetryBase, s.RetryMax))
+	if gaveUp {
+		next, err := schedule.Next(now)
+		if err != nil {
+			// An expression saved before schedules were validated can never run; stop retrying it
+			// often and let the failure show on the admin health view
+			next = now.Add(24 * time.Hour)
+		}
+		nextRun = next
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	recorded, err := models.RecordReportScheduleFailure(tx, schedule, attempt, cause.Error(), nextRun, gaveUp, now)
+	if err != nil || !recorded {
+		return err
+	}
+
+	if gaveUp {
+		var body strings.Builder
+		fmt.Fprintf(&body, "The scheduled run of your report %q failed after %d attempts.\n\n", schedule.ReportName, attempt)
+		fmt.Fprintf(&body, "Error: %s\n\n", cause.Error())
+		fmt.Fprintf(&body, "This run was skipped. The next one is due %s.\n", nextRun.In(schedule.Location()).Format("2006-01-02 15:04 MST"))
+		err = models.EnqueueOutboxMessage(tx, &models.OutboxMessage{
+			Channel:     "email",
+			Destination: schedule.OwnerEmail,
+			EventType:   "report.schedule_failed",
+			Payload: map[string]interface{}{
+				"report_id": schedule.ReportID,
+				"subject":   fmt.Sprintf("Scheduled report failed: %s", schedule.ReportName),
+				"body":      body.String(),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDB(t *testing.T) sqlmock.Sqlmock {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	originalDB := db.DB
+	db.DB = mockDB
+	t.Cleanup(func() {
+		db.DB = originalDB
+		mockDB.Close()
+	})
+	return mock
+}
+
+var scheduleColumns = []string{"id", "name", "created_by", "email", "schedule_cron", "schedule_format",
+	"timezone", "next_run", "retry_count", "last_error", "last_failed_at"}
+
+func TestRunOncePlansNewlyScheduledReports(t *testing.T) {
+	mock := setupTestDB(t)
+	s := NewScheduler(nil)
+
+	// Scheduled on Friday afternoon in Chicago, the report first runs the next Monday at 06:00 there
+	now := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM custom_reports r`).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows(scheduleColumns).
+			AddRow(7, "Owner Statement", 9, "pm@example.com", "0 6 * * MON", "pdf", "America/Chicago", nil, 0, nil, nil))
+	mock.ExpectExec(`UPDATE custom_reports SET next_run = \$1`).
+		WithArgs(time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	generated, err := s.RunOnce(now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, generated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordFailureRetriesWithBackoff(t *testing.T) {
+	mock := setupTestDB(t)
+	s := NewScheduler(nil)
+
+	now := time.Date(2026, 10, 19, 11, 5, 0, 0, time.UTC)
+	schedule := &models.ReportSchedule{ReportID: 7, ReportName: "Owner Statement", Cron: "0 6 * * MON",
+		NextRun: sql.NullTime{Time: now, Valid: true}, RetryCount: 1, OwnerEmail: "pm@example.com"}
+
+	// The second failed attempt waits twice as long as the first
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE custom_reports`).
+		WithArgs(now.Add(10*time.Minute), 2, "query timeout", now, 7, schedule.NextRun).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO report_schedule_failures`).
+		WithArgs(models.ScheduleTypeReport, 7, 7, 2, "query timeout", false, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, s.recordFailure(schedule, errors.New("query timeout"), now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordFailureGivesUpAndNotifies(t *testing.T) {
+	mock := setupTestDB(t)
+	s := NewScheduler(nil)
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	schedule := &models.ReportSchedule{ReportID: 7, ReportName: "Owner Statement", Cron: "0 6 * * MON",
+		NextRun: sql.NullTime{Time: now, Valid: true}, RetryCount: s.MaxRetries, OwnerEmail: "pm@example.com"}
+
+	// The run is skipped until next Monday and the creator is emailed the error
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE custom_reports`).
+		WithArgs(time.Date(2026, 10, 26, 6, 0, 0, 0, time.UTC), 0, "query timeout", now, 7, schedule.NextRun).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO report_schedule_failures`).
+		WithArgs(models.ScheduleTypeReport, 7, 7, s.MaxRetries+1, "query timeout", true, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", "report.schedule_failed", "pm@example.com", sqlmock.AnyArg(), 8).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	require.NoError(t, s.recordFailure(schedule, errors.New("query timeout"), now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
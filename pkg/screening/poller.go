@@ -0,0 +1,82 @@
+package screening
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Poller fetches the results of pending screenings, for providers whose webhooks are missed or
+// not configured
+type Poller struct {
+	Interval time.Duration
+	Provider Provider
+}
+
+// NewPoller creates a poller for the configured provider that checks every 10 minutes
+func NewPoller() *Poller {
+	return &Poller{Interval: 10 * time.Minute, Provider: NewProviderFromEnv()}
+}
+
+// Run polls pending screenings every Interval until the context is cancelled. It does nothing
+// when no provider is configured.
+func (p *Poller) Run(ctx context.Context) {
+	if p.Provider == nil {
+		return
+	}
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if completed, err := p.PollOnce(ctx); err != nil {
+			slog.Error("Screening poll failed", "error", err)
+		} else if completed > 0 {
+			slog.Info("Completed screenings", "count", completed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollOnce fetches every pending screening and returns the number completed. A screening that
+// cannot be fetched is logged and retried on the next poll.
+func (p *Poller) PollOnce(ctx context.Context) (int, error) {
+	pending, err := models.GetPendingScreeningRequests(p.Provider.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for _, request := range pending {
+		result, err := p.Provider.Fetch(ctx, request.ExternalID)
+		if err != nil {
+			slog.Error("Failed to fetch screening", "screening_id", request.ID, "error", err)
+			continue
+		}
+		if !result.Completed {
+			continue
+		}
+		if err := Apply(&request, result); err != nil {
+			slog.Error("Failed to record screening", "screening_id", request.ID, "error", err)
+			continue
+		}
+		completed++
+	}
+	return completed, nil
+}
+
+// Apply records a completed result against its screening request. Results with a decision the
+// application does not know fail the screening rather than being stored.
+func Apply(request *models.ScreeningRequest, result *Result) error {
+	if !ValidDecision(result.Decision) {
+		return models.FailScreeningRequest(request.ID, "provider returned unknown decision "+result.Decision)
+	}
+	return models.CompleteScreeningRequest(request.ID, result.Decision, result.CreditScore, result.Summary)
+}
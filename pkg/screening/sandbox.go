@@ -0,0 +1,116 @@
+package screening
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sandbox is a screening provider for development and testing that calls no external service.
+// The decision follows the applicant's email address: a "+decline" or "+conditional" tag (e.g.
+// ann+decline@example.com) gives that decision, "+pending" leaves the screening pending until a
+// webhook completes it, and anything else is approved.
+//
+// Webhooks are JSON bodies {"external_id", "decision", "credit_score", "summary"} signed with
+// a hex HMAC-SHA256 of the body in the X-Sandbox-Signature header.
+type Sandbox struct {
+	WebhookSecret string
+}
+
+// sandboxPending marks a sandbox screening that only completes through a webhook
+const sandboxPending = "pending"
+
+// sandboxScores are the credit scores reported for each sandbox decision
+var sandboxScores = map[string]int{
+	DecisionApprove:     720,
+	DecisionConditional: 640,
+	DecisionDecline:     540,
+}
+
+// Name implements Provider
+func (s *Sandbox) Name() string { return "sandbox" }
+
+// Request implements Provider. The outcome is encoded in the returned ID so Fetch needs no state.
+func (s *Sandbox) Request(ctx context.Context, applicant Applicant) (string, error) {
+	outcome := DecisionApprove
+	local := strings.SplitN(strings.ToLower(applicant.Email), "@", 2)[0]
+	for _, tag := range []string{DecisionDecline, DecisionConditional, sandboxPending} {
+		if strings.HasSuffix(local, "+"+tag) {
+			outcome = tag
+		}
+	}
+
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sbx_%s_%s", outcome, hex.EncodeToString(token)), nil
+}
+
+// Fetch implements Provider
+func (s *Sandbox) Fetch(ctx context.Context, externalID string) (*Result, error) {
+	parts := strings.Split(externalID, "_")
+	if len(parts) != 3 || parts[0] != "sbx" {
+		return nil, fmt.Errorf("unknown sandbox screening %q", externalID)
+	}
+
+	outcome := parts[1]
+	if outcome == sandboxPending {
+		return &Result{ExternalID: externalID}, nil
+	}
+	score, ok := sandboxScores[outcome]
+	if !ok {
+		return nil, fmt.Errorf("unknown sandbox screening %q", externalID)
+	}
+	return &Result{
+		ExternalID:  externalID,
+		Completed:   true,
+		Decision:    outcome,
+		CreditScore: score,
+		Summary:     fmt.Sprintf("Sandbox screening: credit score %d, no criminal or eviction records found", score),
+	}, nil
+}
+
+// ParseWebhook implements Provider. Callbacks are refused when no webhook secret is configured.
+func (s *Sandbox) ParseWebhook(r *http.Request) (*Result, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.WebhookSecret == "" {
+		return nil, ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(s.WebhookSecret))
+	mac.Write(body)
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get("X-Sandbox-Signature"))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload struct {
+		ExternalID  string `json:"external_id"`
+		Decision    string `json:"decision"`
+		CreditScore int    `json:"credit_score"`
+		Summary     string `json:"summary"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.ExternalID == "" || !ValidDecision(payload.Decision) {
+		return nil, fmt.Errorf("sandbox webhook needs an external_id and a valid decision")
+	}
+	return &Result{
+		ExternalID:  payload.ExternalID,
+		Completed:   true,
+		Decision:    payload.Decision,
+		CreditScore: payload.CreditScore,
+		Summary:     payload.Summary,
+	}, nil
+}
@@ -0,0 +1,69 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrInvalidSignature is returned for webhook callbacks that fail the provider's authenticity check
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Screening decisions, as summarized by the provider
+const (
+	DecisionApprove     = "approve"
+	DecisionConditional = "conditional" // e.g. approve with a larger deposit or a guarantor
+	DecisionDecline     = "decline"
+)
+
+// Applicant is the person to be screened. Providers collect identity details such as a social
+// security number from the applicant directly, so they never pass through this application.
+type Applicant struct {
+	FirstName     string
+	LastName      string
+	Email         string
+	Phone         string
+	MonthlyIncome float64
+}
+
+// Result is the summarized outcome of a screening. Pending results have no decision yet.
+type Result struct {
+	ExternalID  string
+	Completed   bool
+	Decision    string
+	CreditScore int // Zero when the provider reports none
+	Summary     string
+}
+
+// Provider runs credit and background checks through a screening service
+type Provider interface {
+	// Name identifies the provider in webhook URLs and stored records
+	Name() string
+	// Request starts a screening and returns the provider's ID for it
+	Request(ctx context.Context, applicant Applicant) (string, error)
+	// Fetch polls the current result of a screening
+	Fetch(ctx context.Context, externalID string) (*Result, error)
+	// ParseWebhook authenticates a callback and returns the result it reports
+	ParseWebhook(r *http.Request) (*Result, error)
+}
+
+// ValidDecision reports whether decision is one of the known screening decisions
+func ValidDecision(decision string) bool {
+	switch decision {
+	case DecisionApprove, DecisionConditional, DecisionDecline:
+		return true
+	}
+	return false
+}
+
+// NewProviderFromEnv configures the provider named by SCREENING_PROVIDER. Only the sandbox
+// provider is built in. It returns nil when screening is not configured.
+func NewProviderFromEnv() Provider {
+	switch strings.ToLower(os.Getenv("SCREENING_PROVIDER")) {
+	case "sandbox":
+		return &Sandbox{WebhookSecret: os.Getenv("SCREENING_WEBHOOK_SECRET")}
+	}
+	return nil
+}
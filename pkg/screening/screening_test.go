@@ -0,0 +1,59 @@
+package screening
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxDecisionFollowsEmailTag(t *testing.T) {
+	s := &Sandbox{}
+	ctx := context.Background()
+
+	cases := map[string]string{
+		"ann@example.com":             DecisionApprove,
+		"ann+conditional@example.com": DecisionConditional,
+		"ann+decline@example.com":     DecisionDecline,
+	}
+	for email, decision := range cases {
+		id, err := s.Request(ctx, Applicant{Email: email})
+		require.NoError(t, err)
+		result, err := s.Fetch(ctx, id)
+		require.NoError(t, err)
+		assert.True(t, result.Completed, email)
+		assert.Equal(t, decision, result.Decision, email)
+	}
+
+	id, err := s.Request(ctx, Applicant{Email: "ann+pending@example.com"})
+	require.NoError(t, err)
+	result, err := s.Fetch(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, result.Completed)
+}
+
+func TestSandboxWebhookSignature(t *testing.T) {
+	s := &Sandbox{WebhookSecret: "secret"}
+	body := `{"external_id":"sbx_pending_01","decision":"conditional","credit_score":650,"summary":"Guarantor required"}`
+
+	req := httptest.NewRequest("POST", "/api/screening/webhook/sandbox", strings.NewReader(body))
+	req.Header.Set("X-Sandbox-Signature", "bad")
+	_, err := s.ParseWebhook(req)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	req = httptest.NewRequest("POST", "/api/screening/webhook/sandbox", strings.NewReader(body))
+	req.Header.Set("X-Sandbox-Signature", hex.EncodeToString(mac.Sum(nil)))
+	result, err := s.ParseWebhook(req)
+	require.NoError(t, err)
+	assert.Equal(t, "sbx_pending_01", result.ExternalID)
+	assert.Equal(t, DecisionConditional, result.Decision)
+	assert.Equal(t, 650, result.CreditScore)
+}
@@ -0,0 +1,117 @@
+package sla
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Monitor periodically checks open maintenance requests and alerts managers when a
+// request approaches or breaches its SLA. Alerts are delivered through the outbox
+// to SLA_ALERT_WEBHOOK_URL when it is set, and always logged.
+type Monitor struct {
+	Interval   time.Duration
+	WebhookURL string
+}
+
+// NewMonitor creates a monitor configured from the environment
+func NewMonitor() *Monitor {
+	return &Monitor{
+		Interval:   5 * time.Minute,
+		WebhookURL: os.Getenv("SLA_ALERT_WEBHOOK_URL"),
+	}
+}
+
+// Run checks SLAs every Interval until the context is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		if alerted, err := m.CheckOnce(); err != nil {
+			slog.Error("Maintenance SLA check failed", "error", err)
+		} else if alerted > 0 {
+			slog.Info("Sent maintenance SLA alerts", "count", alerted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckOnce alerts on every request whose SLA status has worsened since its last alert
+// and returns the number of alerts raised
+func (m *Monitor) CheckOnce() (int, error) {
+	records, err := models.GetOpenMaintenanceSLARecords(models.PropertyFilter{})
+	if err != nil {
+		return 0, err
+	}
+
+	alerted := 0
+	for _, record := range records {
+		level := models.SLAAlertLevel(record.SLA, record.SLAAlertLevel.String)
+		if level == "" {
+			continue
+		}
+
+		if err := m.alert(record, level); err != nil {
+			return alerted, fmt.Errorf("failed to alert for request %d: %w", record.ID, err)
+		}
+		if err := models.SetMaintenanceSLAAlertLevel(record.ID, level); err != nil {
+			return alerted, err
+		}
+		alerted++
+	}
+
+	return alerted, nil
+}
+
+// alert logs the alert and queues a webhook notification for managers
+func (m *Monitor) alert(record models.MaintenanceSLARecord, level string) error {
+	dueAt := record.SLA.ResolutionDueAt
+	if record.SLA.ResponseStatus == level {
+		dueAt = record.SLA.ResponseDueAt
+	}
+
+	slog.Warn("Maintenance request SLA alert", "maintenance_request_id", record.ID, "property", record.PropertyName,
+		"sla_status", level, "priority", record.Priority, "due_at", dueAt.Format(time.RFC3339))
+
+	if m.WebhookURL == "" {
+		return nil
+	}
+
+	return models.EnqueueOutboxMessage(db.DB, &models.OutboxMessage{
+		Channel:     "webhook",
+		EventType:   AlertEventType(level),
+		Destination: m.WebhookURL,
+		Payload:     AlertPayload(record),
+	})
+}
+
+// AlertEventType is the webhook event type of an alert level: maintenance.sla_at_risk or
+// maintenance.sla_breached
+func AlertEventType(level string) string {
+	return "maintenance.sla_" + level
+}
+
+// AlertPayload builds the webhook payload of an SLA alert. Its shape is published as the
+// maintenance.sla_at_risk and maintenance.sla_breached schemas in pkg/webhooks.
+func AlertPayload(record models.MaintenanceSLARecord) map[string]interface{} {
+	return map[string]interface{}{
+		"maintenance_request_id": record.ID,
+		"property_id":            record.PropertyID,
+		"property_name":          record.PropertyName,
+		"description":            record.Description,
+		"priority":               record.Priority,
+		"status":                 record.Status,
+		"sla":                    record.SLA,
+	}
+}
@@ -0,0 +1,49 @@
+package stathistory
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Recorder snapshots the dashboard quick stats once a day into stats_history
+type Recorder struct {
+	Interval time.Duration
+}
+
+// NewRecorder creates a recorder that checks hourly whether today's snapshot has been taken
+func NewRecorder() *Recorder {
+	return &Recorder{Interval: time.Hour}
+}
+
+// Run records snapshots every Interval until the context is cancelled
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		if written, err := r.RecordOnce(time.Now()); err != nil {
+			slog.Error("Recording quick stats history failed", "error", err)
+		} else if written > 0 {
+			slog.Info("Recorded quick stat snapshots", "count", written)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RecordOnce snapshots the stats unless today's snapshot already exists and returns the number
+// of snapshots written
+func (r *Recorder) RecordOnce(now time.Time) (int, error) {
+	taken, err := models.HasQuickStatSnapshot(now)
+	if err != nil || taken {
+		return 0, err
+	}
+	return models.SnapshotQuickStats(now)
+}
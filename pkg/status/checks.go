@@ -0,0 +1,56 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Outbox channel health thresholds
+const (
+	OutboxWindow       = time.Hour        // Deliveries counted towards a channel's health
+	OutboxOverdueAfter = 15 * time.Minute // A pending message this far past due is not being picked up
+)
+
+// APICheck reports the API as operational: when the status page is served, the API is answering
+func APICheck(context.Context) (string, string) {
+	return Operational, "Serving requests"
+}
+
+// DatabaseCheck pings the primary database. A configured read replica that is unhealthy degrades
+// the database, since reporting queries fall back to the primary.
+func DatabaseCheck(ctx context.Context) (string, string) {
+	if db.DB == nil {
+		return Outage, "Not connected"
+	}
+	if err := db.DB.PingContext(ctx); err != nil {
+		return Outage, "Unreachable"
+	}
+	if db.ReplicaDB != nil && !db.ReplicaHealthy() {
+		return Degraded, "Read replica unavailable, reports are served by the primary"
+	}
+	return Operational, "Connected"
+}
+
+// OutboxChannelCheck reports the health of notification delivery on an outbox channel, such as
+// email or webhook, from its deliveries over the last OutboxWindow. Messages given up on with none
+// delivered is an outage; any given up on, or left pending past due, degrades the channel.
+func OutboxChannelCheck(channel string) Check {
+	return func(ctx context.Context) (string, string) {
+		now := time.Now()
+		h, err := models.GetOutboxChannelHealth(channel, now.Add(-OutboxWindow), now.Add(-OutboxOverdueAfter))
+		if err != nil {
+			return Degraded, "Delivery status unavailable"
+		}
+		switch {
+		case h.Dead > 0 && h.Delivered == 0:
+			return Outage, fmt.Sprintf("%d deliveries failed in the last hour", h.Dead)
+		case h.Dead > 0 || h.Overdue > 0:
+			return Degraded, fmt.Sprintf("%d delivered, %d failed and %d delayed in the last hour", h.Delivered, h.Dead, h.Overdue)
+		}
+		return Operational, fmt.Sprintf("%d delivered in the last hour", h.Delivered)
+	}
+}
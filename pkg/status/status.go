@@ -0,0 +1,199 @@
+// Package status tracks the health of the server's components for the public status page: the
+// API and database, the background workers and the integrations notifications are delivered
+// through. Components are checked on an interval and the page serves the latest results, so
+// visitors to the page cannot add load to the components it reports on.
+package status
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Component statuses, from best to worst
+const (
+	Operational = "operational"
+	Degraded    = "degraded"
+	Outage      = "outage"
+)
+
+// Component groups
+const (
+	GroupCore         = "core"
+	GroupWorkers      = "workers"
+	GroupIntegrations = "integrations"
+)
+
+var severity = map[string]int{Operational: 0, Degraded: 1, Outage: 2}
+
+// Check reports a component's status and a short message safe to show the public
+type Check func(ctx context.Context) (status, message string)
+
+// Component is the latest known health of one component with its uptime counters
+type Component struct {
+	Name          string    `json:"name"`
+	Group         string    `json:"group"`
+	Status        string    `json:"status"`
+	Message       string    `json:"message,omitempty"`
+	Since         time.Time `json:"since"` // When the component last changed status
+	CheckedAt     time.Time `json:"checked_at"`
+	Checks        int64     `json:"checks"`
+	HealthyChecks int64     `json:"healthy_checks"` // Checks that found it operational
+	UptimePercent float64   `json:"uptime_percent"`
+}
+
+// Snapshot is the health of every component at one moment
+type Snapshot struct {
+	Status        string      `json:"status"` // The worst component status
+	StartedAt     time.Time   `json:"started_at"`
+	UptimeSeconds int64       `json:"uptime_seconds"`
+	Components    []Component `json:"components"`
+}
+
+type registeredCheck struct {
+	name, group string
+	check       Check
+}
+
+// Monitor checks the registered components every Interval and keeps their latest status
+type Monitor struct {
+	Interval time.Duration
+	Timeout  time.Duration // Limit on each check
+
+	mu         sync.Mutex
+	startedAt  time.Time
+	checks     []registeredCheck
+	components map[string]*Component
+	stopped    map[string]bool // Workers whose Run returned, by name
+}
+
+// NewMonitor creates a monitor that checks components every minute, giving each check 5 seconds
+func NewMonitor() *Monitor {
+	return &Monitor{
+		Interval:   time.Minute,
+		Timeout:    5 * time.Second,
+		startedAt:  time.Now(),
+		components: map[string]*Component{},
+		stopped:    map[string]bool{},
+	}
+}
+
+// Default is the monitor the server registers its components with and the status page reports
+var Default = NewMonitor()
+
+// AddCheck registers a component checked by check
+func (m *Monitor) AddCheck(name, group string, check Check) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks = append(m.checks, registeredCheck{name: name, group: group, check: check})
+}
+
+// Go runs a background worker in its own goroutine and registers it as a component, operational
+// while run is running. A worker that panics is logged and reported as an outage rather than
+// taking the server down with it.
+func (m *Monitor) Go(ctx context.Context, name string, run func(context.Context)) {
+	m.AddCheck(name, GroupWorkers, func(context.Context) (string, string) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.stopped[name] {
+			return Outage, "Stopped unexpectedly"
+		}
+		return Operational, "Running"
+	})
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("Worker panicked", "worker", name, "panic", r, "stack", string(debug.Stack()))
+			}
+			// Workers only return when their context is cancelled, at shutdown
+			if ctx.Err() == nil {
+				m.mu.Lock()
+				m.stopped[name] = true
+				m.mu.Unlock()
+			}
+		}()
+		run(ctx)
+	}()
+}
+
+// Run checks every component every Interval until the context is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.CheckOnce(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckOnce runs every registered check and updates the components' status and counters
+func (m *Monitor) CheckOnce(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	checks := append([]registeredCheck(nil), m.checks...)
+	m.mu.Unlock()
+
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, m.Timeout)
+		status, message := c.check(checkCtx)
+		cancel()
+		if _, ok := severity[status]; !ok {
+			status = Outage
+		}
+		m.record(c, status, message, now)
+	}
+}
+
+// record stores one check result
+func (m *Monitor) record(c registeredCheck, status, message string, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	component, ok := m.components[c.name]
+	if !ok {
+		component = &Component{Name: c.name, Group: c.group, Status: status, Since: now}
+		m.components[c.name] = component
+	}
+	if component.Status != status {
+		component.Status, component.Since = status, now
+	}
+	component.Message, component.CheckedAt = message, now
+	component.Checks++
+	if status == Operational {
+		component.HealthyChecks++
+	}
+	component.UptimePercent = math.Round(float64(component.HealthyChecks)/float64(component.Checks)*10000) / 100
+}
+
+// Snapshot returns the latest status of every component checked so far, in registration order
+func (m *Monitor) Snapshot(now time.Time) Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := Snapshot{
+		Status:        Operational,
+		StartedAt:     m.startedAt,
+		UptimeSeconds: int64(now.Sub(m.startedAt).Seconds()),
+		Components:    []Component{},
+	}
+	for _, c := range m.checks {
+		component, ok := m.components[c.name]
+		if !ok {
+			continue
+		}
+		snapshot.Components = append(snapshot.Components, *component)
+		if severity[component.Status] > severity[snapshot.Status] {
+			snapshot.Status = component.Status
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,70 @@
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorCountsUptime(t *testing.T) {
+	m := NewMonitor()
+	healthy := true
+	m.AddCheck("api", GroupCore, APICheck)
+	m.AddCheck("email", GroupIntegrations, func(context.Context) (string, string) {
+		if healthy {
+			return Operational, "ok"
+		}
+		return Degraded, "delayed"
+	})
+
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	m.CheckOnce(context.Background(), start)
+	healthy = false
+	m.CheckOnce(context.Background(), start.Add(time.Minute))
+	m.CheckOnce(context.Background(), start.Add(2*time.Minute))
+	healthy = true
+	m.CheckOnce(context.Background(), start.Add(3*time.Minute))
+
+	snapshot := m.Snapshot(start.Add(3 * time.Minute))
+	assert.Equal(t, Operational, snapshot.Status)
+	require.Len(t, snapshot.Components, 2)
+	assert.Equal(t, "api", snapshot.Components[0].Name)
+	assert.Equal(t, 100.0, snapshot.Components[0].UptimePercent)
+
+	email := snapshot.Components[1]
+	assert.Equal(t, int64(4), email.Checks)
+	assert.Equal(t, int64(2), email.HealthyChecks)
+	assert.Equal(t, 50.0, email.UptimePercent)
+	assert.Equal(t, start.Add(3*time.Minute), email.Since, "since the last status change")
+
+	healthy = false
+	m.CheckOnce(context.Background(), start.Add(4*time.Minute))
+	assert.Equal(t, Degraded, m.Snapshot(start.Add(4*time.Minute)).Status)
+}
+
+func TestMonitorReportsStoppedWorker(t *testing.T) {
+	m := NewMonitor()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	m.Go(ctx, "crashing", func(context.Context) {
+		defer close(done)
+		panic("boom")
+	})
+	m.Go(ctx, "steady", func(ctx context.Context) { <-ctx.Done() })
+	<-done
+	// The deferred recovery runs after done is closed
+	require.Eventually(t, func() bool {
+		m.CheckOnce(context.Background(), time.Now())
+		return m.Snapshot(time.Now()).Status == Outage
+	}, time.Second, 10*time.Millisecond)
+
+	components := m.Snapshot(time.Now()).Components
+	require.Len(t, components, 2)
+	assert.Equal(t, Outage, components[0].Status)
+	assert.Equal(t, Operational, components[1].Status)
+}
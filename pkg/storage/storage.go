@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys that would escape the storage root
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Backend stores uploaded files and generated artifacts under slash-separated keys
+type Backend interface {
+	// Save writes data under key and returns the URL it is served from
+	Save(key string, data []byte) (string, error)
+	// Load reads the file stored under key
+	Load(key string) ([]byte, error)
+	// Delete removes the file stored under key; deleting a missing file is not an error
+	Delete(key string) error
+	// Size returns the size in bytes of the file stored under key, or 0 for a missing file
+	Size(key string) (int64, error)
+	// KeyForURL returns the key of a URL returned by Save, or false for other URLs
+	KeyForURL(url string) (string, bool)
+}
+
+// Dir returns the directory used for generated files and uploads
+func Dir() string {
+	if dir := os.Getenv("STORAGE_DIR"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+// LocalBackend stores files on disk beneath Root and serves them under BaseURL
+type LocalBackend struct {
+	Root    string
+	BaseURL string
+}
+
+// Default is the backend used by the server, rooted at Dir()/uploads and served from /uploads
+var Default Backend = &LocalBackend{Root: filepath.Join(Dir(), "uploads"), BaseURL: "/uploads"}
+
+// Reports holds the output of scheduled report runs, rooted at Dir()/reports. It is not served
+// publicly; files are downloaded through the report execution they belong to.
+var Reports Backend = &LocalBackend{Root: filepath.Join(Dir(), "reports"), BaseURL: "/reports"}
+
+// path resolves a key to a file beneath Root
+func (b *LocalBackend) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(b.Root, filepath.FromSlash(clean)), nil
+}
+
+// Save implements Backend
+func (b *LocalBackend) Save(key string, data []byte) (string, error) {
+	file, err := b.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return strings.TrimSuffix(b.BaseURL, "/") + "/" + strings.TrimPrefix(key, "/"), nil
+}
+
+// Load implements Backend
+func (b *LocalBackend) Load(key string) ([]byte, error) {
+	file, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(file)
+}
+
+// Delete implements Backend
+func (b *LocalBackend) Delete(key string) error {
+	file, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Size implements Backend
+func (b *LocalBackend) Size(key string) (int64, error) {
+	file, err := b.path(key)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// KeyForURL implements Backend
+func (b *LocalBackend) KeyForURL(url string) (string, bool) {
+	prefix := strings.TrimSuffix(b.BaseURL, "/") + "/"
+	if !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, prefix), true
+}
+
+// Handler serves the backend's files; mount it at BaseURL
+func (b *LocalBackend) Handler() http.Handler {
+	return http.StripPrefix(strings.TrimSuffix(b.BaseURL, "/"), http.FileServer(http.Dir(b.Root)))
+}
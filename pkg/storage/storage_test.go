@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBackend(t *testing.T) {
+	backend := &LocalBackend{Root: t.TempDir(), BaseURL: "/uploads"}
+
+	url, err := backend.Save("avatars/1.png", []byte("png"))
+	require.NoError(t, err)
+	assert.Equal(t, "/uploads/avatars/1.png", url)
+
+	data, err := os.ReadFile(filepath.Join(backend.Root, "avatars", "1.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(data))
+
+	data, err = backend.Load("avatars/1.png")
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(data))
+
+	rec := httptest.NewRecorder()
+	backend.Handler().ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "png", rec.Body.String())
+
+	key, ok := backend.KeyForURL(url)
+	assert.True(t, ok)
+	assert.Equal(t, "avatars/1.png", key)
+	_, ok = backend.KeyForURL("https://example.com/me.png")
+	assert.False(t, ok)
+
+	size, err := backend.Size(key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), size)
+
+	require.NoError(t, backend.Delete(key))
+	require.NoError(t, backend.Delete(key), "deleting a missing file is not an error")
+	size, err = backend.Size(key)
+	require.NoError(t, err)
+	assert.Zero(t, size)
+
+	_, err = backend.Save("../outside.png", []byte("x"))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
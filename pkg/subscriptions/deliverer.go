@@ -0,0 +1,227 @@
+package subscriptions
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/outbox"
+)
+
+// Renderer renders report data as a file in an export format for a recipient
+type Renderer func(report *models.CustomReport, format string, data *models.ReportData, prefs models.UserPreferences, recipient *models.User) (*models.ReportFile, error)
+
+// Deliverer emails subscribers the reports they subscribed to as their runs fall due. Each run
+// executes the report as the subscriber, so they only see the properties and columns they could
+// see themselves. A failed run is retried with exponential backoff, up to MaxRetries times, before
+// it is skipped until the next regular run and the subscriber is told why.
+type Deliverer struct {
+	Interval   time.Duration
+	Render     Renderer
+	BaseURL    string
+	MaxRetries int
+	RetryBase  time.Duration // Delay before the first retry, doubled for each one after
+	RetryMax   time.Duration
+}
+
+// NewDeliverer creates a deliverer that checks for due subscriptions every 15 minutes and retries
+// a failed run four times, 15 minutes to two hours apart. APP_BASE_URL is used for the link to
+// manage subscriptions.
+func NewDeliverer(render Renderer) *Deliverer {
+	return &Deliverer{
+		Interval:   15 * time.Minute,
+		Render:     render,
+		BaseURL:    os.Getenv("APP_BASE_URL"),
+		MaxRetries: 4,
+		RetryBase:  15 * time.Minute,
+		RetryMax:   2 * time.Hour,
+	}
+}
+
+// Run delivers due subscriptions every Interval until the context is cancelled
+func (d *Deliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		if delivered, err := d.DeliverOnce(time.Now()); err != nil {
+			slog.Error("Delivering report subscriptions failed", "error", err)
+		} else if delivered > 0 {
+			slog.Info("Delivered report subscriptions", "count", delivered)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverOnce queues an email for every due subscription and returns the number queued. A
+// subscription that fails is recorded and rescheduled; it does not hold up the rest.
+func (d *Deliverer) DeliverOnce(now time.Time) (int, error) {
+	due, err := models.GetDueReportSubscriptions(now)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range due {
+		queued, err := d.deliver(&due[i], now)
+		if err != nil {
+			slog.Error("Failed to deliver report subscription", "subscription_id", due[i].ID, "error", err)
+			if err := d.recordFailure(&due[i], err, now); err != nil {
+				slog.Error("Failed to record report subscription failure", "subscription_id", due[i].ID, "error", err)
+			}
+			continue
+		}
+		if queued {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// deliver claims the subscription's run, executes and renders the report, and queues the email in
+// one transaction. It returns false when another server claimed the run or the subscriber may no
+// longer see the report, in which case the run is skipped.
+func (d *Deliverer) deliver(s *models.ReportSubscription, now time.Time) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	claimed, err := models.ClaimReportSubscriptionRun(tx, s, now)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	subscriber, err := models.GetUserByID(s.UserID)
+	if err != nil {
+		return false, err
+	}
+	report, err := models.GetCustomReportByID(s.ReportID)
+	if err != nil {
+		return false, err
+	}
+	if !models.CanSubscribeToReport(report, subscriber) {
+		slog.Warn("Skipping report subscription, user may no longer see the report", "subscription_id", s.ID,
+			"user_id", s.UserID, "report_id", s.ReportID)
+		return false, tx.Commit()
+	}
+
+	data, err := models.ExecuteReport(report.ID, map[string]interface{}{}, subscriber)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute report %d: %w", report.ID, err)
+	}
+	prefs, err := models.GetUserPreferences(subscriber.ID)
+	if err != nil {
+		prefs = models.DefaultPreferences()
+	}
+	file, err := d.Render(report, s.OutputFormat, data, prefs, subscriber)
+	if err != nil {
+		return false, fmt.Errorf("failed to render report %d as %s: %w", report.ID, s.OutputFormat, err)
+	}
+
+	err = models.EnqueueOutboxMessage(tx, &models.OutboxMessage{
+		Channel:     "email",
+		Destination: subscriber.Email,
+		EventType:   "report.subscription",
+		Payload: map[string]interface{}{
+			"subscription_id": s.ID,
+			"subject":         fmt.Sprintf("%s: %s", report.Name, now.In(s.Location()).Format("2006-01-02")),
+			"body":            d.body(report, s),
+			"attachments": []interface{}{map[string]interface{}{
+				"filename":     file.Filename,
+				"content_type": file.ContentType,
+				"data":         base64.StdEncoding.EncodeToString(file.Data),
+			}},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	if err := models.RecordReportExport(report, subscriber, s.OutputFormat, "subscription", len(file.Data)); err != nil {
+		slog.Error("Failed to audit report export", "report_id", report.ID, "error", err)
+	}
+
+	// Deliveries count towards the subscriber's usage like the exports they stand in for
+	for metric, amount := range map[string]int64{
+		models.UsageReportExecutions: 1,
+		models.UsageExportBytes:      int64(len(file.Data)),
+	} {
+		if _, err := models.RecordUsage(subscriber.ID, metric, amount, now); err != nil {
+			slog.Error("Failed to record usage", "metric", metric, "user_id", subscriber.ID, "error", err)
+		}
+	}
+	return true, nil
+}
+
+// recordFailure reschedules a failed run for a retry after the backoff, or once the retries are
+// used up skips it until the next regular run and emails the subscriber the error
+func (d *Deliverer) recordFailure(s *models.ReportSubscription, cause error, now time.Time) error {
+	attempt := s.RetryCount + 1
+	gaveUp := attempt > d.MaxRetries
+	nextRun := now.Add(outbox.Backoff(attempt, d.RetryBase, d.RetryMax))
+	if gaveUp {
+		nextRun = models.NextSubscriptionRun(s.Frequency, now, s.Location())
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	recorded, err := models.RecordReportSubscriptionFailure(tx, s, attempt, cause.Error(), nextRun, gaveUp, now)
+	if err != nil || !recorded {
+		return err
+	}
+
+	if gaveUp {
+		reportName := fmt.Sprintf("#%d", s.ReportID)
+		if report, err := models.GetCustomReportByID(s.ReportID); err == nil {
+			reportName = report.Name
+		}
+		var body strings.Builder
+		fmt.Fprintf(&body, "Your %s copy of the report %q could not be delivered after %d attempts.\n\n", s.Frequency, reportName, attempt)
+		fmt.Fprintf(&body, "Error: %s\n\n", cause.Error())
+		fmt.Fprintf(&body, "This run was skipped. The next one is due %s.\n", nextRun.In(s.Location()).Format("2006-01-02 15:04 MST"))
+		err = models.EnqueueOutboxMessage(tx, &models.OutboxMessage{
+			Channel:     "email",
+			Destination: s.Email,
+			EventType:   "report.subscription_failed",
+			Payload: map[string]interface{}{
+				"subscription_id": s.ID,
+				"subject":         fmt.Sprintf("Report delivery failed: %s", reportName),
+				"body":            body.String(),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// body is the plain-text email body accompanying the attached report
+func (d *Deliverer) body(report *models.CustomReport, s *models.ReportSubscription) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your %s copy of the report %q is attached.\n", s.Frequency, report.Name)
+	if d.BaseURL != "" {
+		fmt.Fprintf(&b, "\nManage your report subscriptions at %s/reports\n", strings.TrimRight(d.BaseURL, "/"))
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package subscriptions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDB(t *testing.T) sqlmock.Sqlmock {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	originalDB := db.DB
+	db.DB = mockDB
+	t.Cleanup(func() {
+		db.DB = originalDB
+		mockDB.Close()
+	})
+	return mock
+}
+
+func TestRecordFailureRetriesWithBackoff(t *testing.T) {
+	mock := setupTestDB(t)
+	d := NewDeliverer(nil)
+
+	now := time.Date(2026, 10, 19, 7, 15, 0, 0, time.UTC)
+	s := &models.ReportSubscription{ID: 3, ReportID: 4, Frequency: models.SubscriptionWeekly,
+		NextRun: now, RetryCount: 2, Email: "pm@example.com"}
+
+	// The third failed attempt waits twice as long as the second
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE report_subscriptions`).
+		WithArgs(now.Add(time.Hour), 3, "query timeout", now, 3, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO report_schedule_failures`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, d.recordFailure(s, errors.New("query timeout"), now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordFailureGivesUpAndNotifies(t *testing.T) {
+	mock := setupTestDB(t)
+	d := NewDeliverer(nil)
+
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	s := &models.ReportSubscription{ID: 3, ReportID: 4, Frequency: models.SubscriptionWeekly,
+		NextRun: now, RetryCount: d.MaxRetries, Email: "pm@example.com"}
+
+	// The run is skipped until next Monday and the subscriber is emailed the error
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE report_subscriptions`).
+		WithArgs(time.Date(2026, 10, 26, 7, 0, 0, 0, time.UTC), 0, "query timeout", now, 3, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO report_schedule_failures`).
+		WithArgs(models.ScheduleTypeSubscription, 3, 4, d.MaxRetries+1, "query timeout", true, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`FROM custom_reports`).
+		WillReturnError(errors.New("report lookup failed"))
+	mock.ExpectQuery(`INSERT INTO outbox_messages`).
+		WithArgs("email", "report.subscription_failed", "pm@example.com", sqlmock.AnyArg(), 8).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow(1, "pending", 0, now, now, now))
+	mock.ExpectCommit()
+
+	require.NoError(t, d.recordFailure(s, errors.New("query timeout"), now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,388 @@
+package syndication
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Format renders listings as a listing site's feed
+type Format interface {
+	// Render writes the feed. baseURL makes photo links absolute.
+	Render(listings []models.SyndicatedListing, baseURL string) ([]byte, error)
+	// Check returns the reasons a listing would be rejected by sites using this format
+	Check(listing models.SyndicatedListing) []string
+}
+
+// formats maps the format names stored on channels to their implementations
+var formats = map[string]Format{
+	"hotpads": hotPadsFormat{},
+	"mits":    mitsFormat{},
+}
+
+// FormatFor returns the named format, or nil if it is unknown
+func FormatFor(name string) Format {
+	return formats[name]
+}
+
+// Address is a property address split into the parts listing feeds need
+type Address struct {
+	Street string
+	City   string
+	State  string
+	Zip    string
+}
+
+var stateZip = regexp.MustCompile(`^([A-Za-z]{2})\s+(\d{5}(?:-\d{4})?)$`)
+
+// ParseAddress splits a one-line US address such as "12 Elm St, Springfield, IL 62701". When
+// the address does not follow that pattern it is all kept as the street.
+func ParseAddress(address string) Address {
+	parts := strings.Split(address, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if len(parts) >= 3 {
+		if m := stateZip.FindStringSubmatch(parts[len(parts)-1]); m != nil {
+			return Address{
+				Street: strings.Join(parts[:len(parts)-2], ", "),
+				City:   parts[len(parts)-2],
+				State:  strings.ToUpper(m[1]),
+				Zip:    m[2],
+			}
+		}
+	}
+	return Address{Street: strings.TrimSpace(address)}
+}
+
+// photoURLs returns the absolute URLs of a listing's photos
+func photoURLs(listing models.SyndicatedListing, baseURL string) []string {
+	urls := make([]string, len(listing.PhotoIDs))
+	for i, id := range listing.PhotoIDs {
+		urls[i] = fmt.Sprintf("%s/photos/%d/full", baseURL, id)
+	}
+	return urls
+}
+
+// checkCommon returns the problems every listing site rejects
+func checkCommon(listing models.SyndicatedListing) []string {
+	var problems []string
+	address := ParseAddress(listing.PropertyAddress)
+	if address.City == "" || address.State == "" || address.Zip == "" {
+		problems = append(problems, "property address must look like \"street, city, ST zip\"")
+	}
+	if listing.AskingRent <= 0 {
+		problems = append(problems, "asking rent must be greater than zero")
+	}
+	if !listing.ContactEmail.Valid && !listing.ContactPhone.Valid {
+		problems = append(problems, "a contact email or phone number is required")
+	}
+	if listing.Occupied && !listing.AvailableDate.Valid {
+		problems = append(problems, "the unit is occupied, so an available date is required")
+	}
+	return problems
+}
+
+// listingName is the headline, or the property and unit when there is none
+func listingName(listing models.SyndicatedListing) string {
+	if listing.Headline.Valid {
+		return listing.Headline.String
+	}
+	if listing.UnitNumber != "" {
+		return listing.PropertyName + " " + listing.UnitNumber
+	}
+	return listing.PropertyName
+}
+
+// listingDescription combines the listing and unit descriptions
+func listingDescription(listing models.SyndicatedListing) string {
+	var parts []string
+	if listing.Description.Valid {
+		parts = append(parts, listing.Description.String)
+	}
+	if listing.UnitDescription != "" {
+		parts = append(parts, listing.UnitDescription)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func formatRent(rent float64) string {
+	return strconv.FormatFloat(rent, 'f', 2, 64)
+}
+
+// marshalFeed encodes a feed document with an XML declaration
+func marshalFeed(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// hotPadsFormat is the HotPads rental feed read by the Zillow Rental Network
+type hotPadsFormat struct{}
+
+type hotPadsFeed struct {
+	XMLName  xml.Name         `xml:"hotPadsItems"`
+	Version  string           `xml:"version,attr"`
+	Listings []hotPadsListing `xml:"Listing"`
+}
+
+type hotPadsListing struct {
+	ID             string         `xml:"id,attr"`
+	Type           string         `xml:"type,attr"`
+	PropertyType   string         `xml:"propertyType,attr"`
+	Name           string         `xml:"name"`
+	Unit           string         `xml:"unit,omitempty"`
+	Street         string         `xml:"street"`
+	City           string         `xml:"city"`
+	State          string         `xml:"state"`
+	Zip            string         `xml:"zip"`
+	Country        string         `xml:"country"`
+	ContactName    string         `xml:"contactName,omitempty"`
+	ContactEmail   string         `xml:"contactEmail,omitempty"`
+	ContactPhone   string         `xml:"contactPhone,omitempty"`
+	PreviewMessage string         `xml:"previewMessage,omitempty"`
+	Description    string         `xml:"description,omitempty"`
+	Price          string         `xml:"price"`
+	Bedrooms       int            `xml:"numBedrooms"`
+	FullBaths      int            `xml:"numFullBaths"`
+	SquareFeet     int32          `xml:"squareFeet,omitempty"`
+	DateAvailable  string         `xml:"dateAvailable,omitempty"`
+	Photos         []hotPadsPhoto `xml:"ListingPhoto"`
+}
+
+type hotPadsPhoto struct {
+	Source string `xml:"source,attr"`
+}
+
+// hotPadsPropertyType maps a property type to the HotPads building size categories
+func hotPadsPropertyType(propertyType string) string {
+	t := strings.ToLower(propertyType)
+	switch {
+	case strings.Contains(t, "condo"):
+		return "CONDO"
+	case strings.Contains(t, "town"):
+		return "TOWNHOUSE"
+	case strings.Contains(t, "house") || strings.Contains(t, "single"):
+		return "HOUSE"
+	case strings.Contains(t, "duplex"):
+		return "DUPLEX"
+	}
+	return "MEDIUM"
+}
+
+// Render implements Format
+func (hotPadsFormat) Render(listings []models.SyndicatedListing, baseURL string) ([]byte, error) {
+	feed := hotPadsFeed{Version: "2.1", Listings: []hotPadsListing{}}
+	for _, l := range listings {
+		address := ParseAddress(l.PropertyAddress)
+		item := hotPadsListing{
+			ID:             fmt.Sprintf("unit-%d", l.UnitID),
+			Type:           "RENTAL",
+			PropertyType:   hotPadsPropertyType(l.PropertyType),
+			Name:           listingName(l),
+			Unit:           l.UnitNumber,
+			Street:         address.Street,
+			City:           address.City,
+			State:          address.State,
+			Zip:            address.Zip,
+			Country:        "US",
+			ContactName:    l.ContactName.String,
+			ContactEmail:   l.ContactEmail.String,
+			ContactPhone:   l.ContactPhone.String,
+			PreviewMessage: l.Headline.String,
+			Description:    listingDescription(l),
+			Price:          formatRent(l.AskingRent),
+			Bedrooms:       l.Bedrooms,
+			FullBaths:      l.Bathrooms,
+			SquareFeet:     l.SquareFeet.Int32,
+		}
+		if l.AvailableDate.Valid {
+			item.DateAvailable = l.AvailableDate.Time.Format("2006-01-02")
+		}
+		for _, url := range photoURLs(l, baseURL) {
+			item.Photos = append(item.Photos, hotPadsPhoto{Source: url})
+		}
+		feed.Listings = append(feed.Listings, item)
+	}
+	return marshalFeed(feed)
+}
+
+// Check implements Format. HotPads rejects listings without photos.
+func (hotPadsFormat) Check(listing models.SyndicatedListing) []string {
+	problems := checkCommon(listing)
+	if len(listing.PhotoIDs) == 0 {
+		problems = append(problems, "at least one photo is required")
+	}
+	return problems
+}
+
+// mitsFormat is the MITS (Multifamily Information and Transactions Standard) ILS feed read by
+// Apartments.com and most other apartment listing sites. Units are grouped by property.
+type mitsFormat struct{}
+
+type mitsFeed struct {
+	XMLName    xml.Name       `xml:"PhysicalProperty"`
+	Properties []mitsProperty `xml:"Property"`
+}
+
+type mitsProperty struct {
+	IDValue    string         `xml:"IDValue,attr"`
+	PropertyID mitsPropertyID `xml:"PropertyID"`
+	Units      []mitsUnit     `xml:"ILS_Unit"`
+}
+
+type mitsPropertyID struct {
+	Identification mitsIdentification `xml:"Identification"`
+	MarketingName  string             `xml:"MarketingName"`
+	Address        mitsAddress        `xml:"Address"`
+	Email          string             `xml:"Email,omitempty"`
+	Phone          *mitsPhone         `xml:"Phone,omitempty"`
+}
+
+type mitsIdentification struct {
+	IDValue          string `xml:"IDValue,attr"`
+	OrganizationName string `xml:"OrganizationName,attr,omitempty"`
+}
+
+type mitsAddress struct {
+	AddressType  string `xml:"AddressType,attr"`
+	AddressLine1 string `xml:"AddressLine1"`
+	City         string `xml:"City"`
+	State        string `xml:"State"`
+	PostalCode   string `xml:"PostalCode"`
+	Country      string `xml:"Country"`
+}
+
+type mitsPhone struct {
+	PhoneNumber string `xml:"PhoneNumber"`
+}
+
+type mitsUnit struct {
+	IDValue      string            `xml:"IDValue,attr"`
+	Unit         mitsUnitDetail    `xml:"Units>Unit"`
+	Availability *mitsAvailability `xml:"Availability,omitempty"`
+	Comment      string            `xml:"Comment,omitempty"`
+	Files        []mitsFile        `xml:"File"`
+}
+
+type mitsUnitDetail struct {
+	Identification mitsIdentification `xml:"Identification"`
+	MarketingName  string             `xml:"MarketingName"`
+	Bedrooms       int                `xml:"UnitBedrooms"`
+	Bathrooms      int                `xml:"UnitBathrooms"`
+	MinSquareFeet  int32              `xml:"MinSquareFeet,omitempty"`
+	MaxSquareFeet  int32              `xml:"MaxSquareFeet,omitempty"`
+	MarketRent     string             `xml:"MarketRent"`
+	LeasedStatus   string             `xml:"UnitLeasedStatus"`
+}
+
+type mitsAvailability struct {
+	MadeReadyDate mitsDate `xml:"MadeReadyDate"`
+}
+
+type mitsDate struct {
+	Month int `xml:"Month,attr"`
+	Day   int `xml:"Day,attr"`
+	Year  int `xml:"Year,attr"`
+}
+
+type mitsFile struct {
+	Active   bool   `xml:"Active,attr"`
+	FileID   string `xml:"FileID,attr"`
+	FileType string `xml:"FileType"`
+	Src      string `xml:"Src"`
+	Rank     int    `xml:"Rank"`
+}
+
+// Render implements Format
+func (mitsFormat) Render(listings []models.SyndicatedListing, baseURL string) ([]byte, error) {
+	byProperty := map[int]*mitsProperty{}
+	var order []int
+	for _, l := range listings {
+		property, ok := byProperty[l.PropertyID]
+		if !ok {
+			address := ParseAddress(l.PropertyAddress)
+			property = &mitsProperty{
+				IDValue: strconv.Itoa(l.PropertyID),
+				PropertyID: mitsPropertyID{
+					Identification: mitsIdentification{IDValue: strconv.Itoa(l.PropertyID), OrganizationName: "fire-pmaas"},
+					MarketingName:  l.PropertyName,
+					Address: mitsAddress{
+						AddressType:  "property",
+						AddressLine1: address.Street,
+						City:         address.City,
+						State:        address.State,
+						PostalCode:   address.Zip,
+						Country:      "US",
+					},
+				},
+			}
+			byProperty[l.PropertyID] = property
+			order = append(order, l.PropertyID)
+		}
+		// The first listing with contact details supplies the property's leasing contact
+		if property.PropertyID.Email == "" {
+			property.PropertyID.Email = l.ContactEmail.String
+		}
+		if property.PropertyID.Phone == nil && l.ContactPhone.Valid {
+			property.PropertyID.Phone = &mitsPhone{PhoneNumber: l.ContactPhone.String}
+		}
+
+		status := "available"
+		if l.Occupied {
+			status = "on_notice"
+		}
+		unit := mitsUnit{
+			IDValue: strconv.Itoa(l.UnitID),
+			Unit: mitsUnitDetail{
+				Identification: mitsIdentification{IDValue: strconv.Itoa(l.UnitID)},
+				MarketingName:  listingName(l),
+				Bedrooms:       l.Bedrooms,
+				Bathrooms:      l.Bathrooms,
+				MinSquareFeet:  l.SquareFeet.Int32,
+				MaxSquareFeet:  l.SquareFeet.Int32,
+				MarketRent:     formatRent(l.AskingRent),
+				LeasedStatus:   status,
+			},
+			Comment: listingDescription(l),
+		}
+		if l.AvailableDate.Valid {
+			d := l.AvailableDate.Time
+			unit.Availability = &mitsAvailability{MadeReadyDate: mitsDate{Month: int(d.Month()), Day: d.Day(), Year: d.Year()}}
+		}
+		for i, url := range photoURLs(l, baseURL) {
+			unit.Files = append(unit.Files, mitsFile{
+				Active:   true,
+				FileID:   strconv.Itoa(l.PhotoIDs[i]),
+				FileType: "Photo",
+				Src:      url,
+				Rank:     i + 1,
+			})
+		}
+		property.Units = append(property.Units, unit)
+	}
+
+	sort.Ints(order)
+	feed := mitsFeed{Properties: []mitsProperty{}}
+	for _, id := range order {
+		feed.Properties = append(feed.Properties, *byProperty[id])
+	}
+	return marshalFeed(feed)
+}
+
+// Check implements Format
+func (mitsFormat) Check(listing models.SyndicatedListing) []string {
+	return checkCommon(listing)
+}
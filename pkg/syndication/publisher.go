@@ -0,0 +1,187 @@
+package syndication
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/storage"
+)
+
+// Publisher regenerates the feed of every enabled syndication channel on a schedule and stores
+// it where the channel's listing site fetches it
+type Publisher struct {
+	Interval time.Duration
+	BaseURL  string // Makes photo links absolute; from APP_BASE_URL
+}
+
+// NewPublisher creates an hourly publisher
+func NewPublisher() *Publisher {
+	base := strings.TrimSuffix(os.Getenv("APP_BASE_URL"), "/")
+	if base == "" {
+		base = "http://localhost:8000"
+	}
+	return &Publisher{Interval: time.Hour, BaseURL: base}
+}
+
+// Run publishes feeds every Interval until the context is cancelled
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if published, err := p.PublishOnce(); err != nil {
+			slog.Error("Listing syndication failed", "error", err)
+		} else if published > 0 {
+			slog.Info("Published listing feeds", "count", published)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishOnce regenerates every enabled channel's feed and returns the number published. A
+// channel that fails keeps its previous feed and records the error.
+func (p *Publisher) PublishOnce() (int, error) {
+	channels, err := models.GetSyndicationChannels()
+	if err != nil {
+		return 0, err
+	}
+
+	var listings []models.SyndicatedListing
+	published := 0
+	for _, channel := range channels {
+		if !channel.Enabled {
+			continue
+		}
+		if listings == nil {
+			if listings, err = models.GetSyndicatedListings(); err != nil {
+				return published, err
+			}
+		}
+
+		url, count, genErr := p.publish(channel, listings)
+		if genErr != nil {
+			slog.Error("Failed to publish listing feed", "channel", channel.Name, "error", genErr)
+		} else {
+			published++
+		}
+		if err := models.RecordSyndicationFeed(channel.Name, url, count, genErr); err != nil {
+			return published, err
+		}
+	}
+	return published, nil
+}
+
+// publish renders and stores one channel's feed, leaving out listings the site would reject,
+// and returns the feed URL and the number of listings in it
+func (p *Publisher) publish(channel models.SyndicationChannel, listings []models.SyndicatedListing) (string, int, error) {
+	format := FormatFor(channel.Format)
+	if format == nil {
+		return "", 0, fmt.Errorf("unknown feed format %q", channel.Format)
+	}
+
+	var accepted []models.SyndicatedListing
+	for _, l := range listings {
+		if len(format.Check(l)) == 0 {
+			accepted = append(accepted, l)
+		}
+	}
+
+	feed, err := format.Render(accepted, p.BaseURL)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := checkWellFormed(feed); err != nil {
+		return "", 0, err
+	}
+	url, err := storage.Default.Save(FeedKey(channel.Name), feed)
+	if err != nil {
+		return "", 0, err
+	}
+	return url, len(accepted), nil
+}
+
+// FeedKey is the storage key of a channel's published feed
+func FeedKey(channel string) string {
+	return "feeds/" + channel + ".xml"
+}
+
+// ListingProblem is a listing left out of a channel's feed and why
+type ListingProblem struct {
+	UnitID       int      `json:"unit_id"`
+	PropertyName string   `json:"property_name"`
+	UnitNumber   string   `json:"unit_number"`
+	Problems     []string `json:"problems"`
+}
+
+// Validation reports what a channel's feed would contain if generated now
+type Validation struct {
+	Channel       string           `json:"channel"`
+	Format        string           `json:"format"`
+	Valid         bool             `json:"valid"`
+	ListingCount  int              `json:"listing_count"`
+	AcceptedCount int              `json:"accepted_count"`
+	Rejected      []ListingProblem `json:"rejected"`
+	Error         string           `json:"error,omitempty"` // Set when the feed itself cannot be generated
+}
+
+// Validate checks every active listing against a channel's format and that the resulting feed
+// is well-formed XML. The feed is valid when no listing would be left out.
+func Validate(channel models.SyndicationChannel, listings []models.SyndicatedListing, baseURL string) Validation {
+	v := Validation{Channel: channel.Name, Format: channel.Format, ListingCount: len(listings), Rejected: []ListingProblem{}}
+	format := FormatFor(channel.Format)
+	if format == nil {
+		v.Error = fmt.Sprintf("unknown feed format %q", channel.Format)
+		return v
+	}
+
+	var accepted []models.SyndicatedListing
+	for _, l := range listings {
+		if problems := format.Check(l); len(problems) > 0 {
+			v.Rejected = append(v.Rejected, ListingProblem{
+				UnitID:       l.UnitID,
+				PropertyName: l.PropertyName,
+				UnitNumber:   l.UnitNumber,
+				Problems:     problems,
+			})
+			continue
+		}
+		accepted = append(accepted, l)
+	}
+	v.AcceptedCount = len(accepted)
+
+	feed, err := format.Render(accepted, baseURL)
+	if err == nil {
+		err = checkWellFormed(feed)
+	}
+	if err != nil {
+		v.Error = err.Error()
+		return v
+	}
+	v.Valid = len(v.Rejected) == 0
+	return v
+}
+
+// checkWellFormed parses a generated feed to catch encoding problems before a site does
+func checkWellFormed(feed []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(feed))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("generated feed is not well-formed XML: %v", err)
+		}
+	}
+}
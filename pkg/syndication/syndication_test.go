@@ -0,0 +1,91 @@
+package syndication
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testListing() models.SyndicatedListing {
+	return models.SyndicatedListing{
+		UnitListing: models.UnitListing{
+			UnitID:        12,
+			Active:        true,
+			AskingRent:    1450,
+			AvailableDate: sql.NullTime{Time: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+			Headline:      sql.NullString{String: "Sunny 2BR & parking", Valid: true},
+			ContactEmail:  sql.NullString{String: "leasing@example.com", Valid: true},
+		},
+		PropertyID:      3,
+		PropertyName:    "Maple Court",
+		PropertyAddress: "12 Elm St, Springfield, IL 62701",
+		PropertyType:    "Apartment Building",
+		UnitNumber:      "Apt 101",
+		Bedrooms:        2,
+		Bathrooms:       1,
+		PhotoIDs:        []int{7},
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	assert.Equal(t, Address{Street: "12 Elm St, Suite 4", City: "Springfield", State: "IL", Zip: "62701"},
+		ParseAddress("12 Elm St, Suite 4, Springfield, il 62701"))
+	assert.Equal(t, Address{Street: "Somewhere rural"}, ParseAddress("Somewhere rural"))
+}
+
+func TestHotPadsRender(t *testing.T) {
+	feed, err := FormatFor("hotpads").Render([]models.SyndicatedListing{testListing()}, "https://pm.example.com")
+	require.NoError(t, err)
+	require.NoError(t, checkWellFormed(feed))
+
+	xml := string(feed)
+	assert.Contains(t, xml, `<Listing id="unit-12" type="RENTAL" propertyType="MEDIUM">`)
+	assert.Contains(t, xml, "<price>1450.00</price>")
+	assert.Contains(t, xml, "<dateAvailable>2026-11-01</dateAvailable>")
+	assert.Contains(t, xml, `<ListingPhoto source="https://pm.example.com/photos/7/full">`)
+	assert.Contains(t, xml, "Sunny 2BR &amp; parking")
+}
+
+func TestMITSRenderGroupsUnitsByProperty(t *testing.T) {
+	second := testListing()
+	second.UnitID = 13
+	second.UnitNumber = "Apt 102"
+
+	feed, err := FormatFor("mits").Render([]models.SyndicatedListing{testListing(), second}, "https://pm.example.com")
+	require.NoError(t, err)
+	require.NoError(t, checkWellFormed(feed))
+
+	xml := string(feed)
+	assert.Equal(t, 1, strings.Count(xml, "<Property "))
+	assert.Equal(t, 2, strings.Count(xml, "<ILS_Unit "))
+	assert.Contains(t, xml, `<MadeReadyDate Month="11" Day="1" Year="2026">`)
+}
+
+func TestValidateReportsRejectedListings(t *testing.T) {
+	noPhotos := testListing()
+	noPhotos.UnitID = 13
+	noPhotos.PhotoIDs = nil
+	occupied := testListing()
+	occupied.UnitID = 14
+	occupied.Occupied = true
+	occupied.AvailableDate = sql.NullTime{}
+
+	channel := models.SyndicationChannel{Name: "zillow", Format: "hotpads"}
+	v := Validate(channel, []models.SyndicatedListing{testListing(), noPhotos, occupied}, "https://pm.example.com")
+	assert.False(t, v.Valid)
+	assert.Empty(t, v.Error)
+	assert.Equal(t, 3, v.ListingCount)
+	assert.Equal(t, 1, v.AcceptedCount)
+	require.Len(t, v.Rejected, 2)
+	assert.Equal(t, []string{"at least one photo is required"}, v.Rejected[0].Problems)
+	assert.Equal(t, []string{"the unit is occupied, so an available date is required"}, v.Rejected[1].Problems)
+
+	// Apartments.com does not require photos
+	v = Validate(models.SyndicationChannel{Name: "apartments_com", Format: "mits"}, []models.SyndicatedListing{noPhotos}, "")
+	assert.True(t, v.Valid)
+}
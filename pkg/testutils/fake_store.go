@@ -0,0 +1,406 @@
+package testutils
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// FakeStore is an in-memory implementation of the user, property, report and KPI repositories.
+// Lookups of missing rows return sql.ErrNoRows, like the SQL implementations, so handlers map
+// them to the same responses. It is safe for concurrent use.
+type FakeStore struct {
+	mu         sync.Mutex
+	nextID     int
+	users      map[int]models.User
+	roles      map[int]models.Role
+	userRoles  map[int][]int // User ID to role IDs
+	properties map[int]models.Property
+	reports    map[int]models.CustomReport
+	templates  []models.ReportTemplate
+	kpis       map[int]models.KPIMetric
+	now        func() time.Time
+}
+
+// NewFakeStore creates an empty store whose timestamps are TestTime
+func NewFakeStore() *FakeStore {
+	return &FakeStore{
+		users:      map[int]models.User{},
+		roles:      map[int]models.Role{},
+		userRoles:  map[int][]int{},
+		properties: map[int]models.Property{},
+		reports:    map[int]models.CustomReport{},
+		kpis:       map[int]models.KPIMetric{},
+		now:        TestTime,
+	}
+}
+
+// UseFakeStore installs a new FakeStore behind the models package functions for the rest of the
+// test and restores the SQL repositories afterwards. Tests using it must not run in parallel.
+func UseFakeStore(t *testing.T) *FakeStore {
+	store := NewFakeStore()
+	users, properties, reports, kpis := models.UserRepo, models.PropertyRepo, models.ReportRepo, models.KPIRepo
+	models.UserRepo, models.PropertyRepo, models.ReportRepo, models.KPIRepo = store, store, store, store
+	t.Cleanup(func() {
+		models.UserRepo, models.PropertyRepo, models.ReportRepo, models.KPIRepo = users, properties, reports, kpis
+	})
+	return store
+}
+
+// id returns the next ID; IDs are unique across all tables of the store
+func (s *FakeStore) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+// AddRole stores a role and returns it with its ID
+func (s *FakeStore) AddRole(name string, permissions ...string) models.Role {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	role := models.Role{ID: s.id(), Name: name, DisplayName: name, Permissions: permissions,
+		CreatedAt: s.now(), UpdatedAt: s.now()}
+	s.roles[role.ID] = role
+	return role
+}
+
+// AddUser stores an active user with the named roles, creating roles that do not exist yet,
+// and returns the user as GetUserByID would
+func (s *FakeStore) AddUser(username string, roleNames ...string) *models.User {
+	user := &models.User{Username: username, Email: username + "@example.com", Status: "active"}
+	if err := s.CreateUser(user); err != nil {
+		panic(err)
+	}
+	for _, name := range roleNames {
+		role, err := s.GetRoleByName(name)
+		if err != nil {
+			created := s.AddRole(name)
+			role = &created
+		}
+		if err := s.AssignRole(user.ID, role.ID, nil); err != nil {
+			panic(err)
+		}
+	}
+	user, _ = s.GetUserByID(user.ID)
+	return user
+}
+
+// AddReportTemplate stores a report template
+func (s *FakeStore) AddReportTemplate(template models.ReportTemplate) models.ReportTemplate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	template.ID = s.id()
+	template.CreatedAt, template.UpdatedAt = s.now(), s.now()
+	s.templates = append(s.templates, template)
+	return template
+}
+
+// CreateUser stores a user, rejecting duplicate usernames and emails like the unique indexes
+func (s *FakeStore) CreateUser(user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return fmt.Errorf("duplicate user %q", user.Username)
+		}
+	}
+	user.ID = s.id()
+	user.CreatedAt, user.UpdatedAt = s.now(), s.now()
+	stored := *user
+	stored.Roles = nil
+	s.users[user.ID] = stored
+	return nil
+}
+
+// GetUserByID returns a user with their roles
+func (s *FakeStore) GetUserByID(id int) (*models.User, error) {
+	return s.findUser(func(u models.User) bool { return u.ID == id })
+}
+
+// GetUserByEmail returns a user with their roles
+func (s *FakeStore) GetUserByEmail(email string) (*models.User, error) {
+	return s.findUser(func(u models.User) bool { return u.Email == email })
+}
+
+// GetUserByUsername returns a user with their roles
+func (s *FakeStore) GetUserByUsername(username string) (*models.User, error) {
+	return s.findUser(func(u models.User) bool { return u.Username == username })
+}
+
+// GetUserByKeycloakID returns a user with their roles
+func (s *FakeStore) GetUserByKeycloakID(keycloakID string) (*models.User, error) {
+	return s.findUser(func(u models.User) bool { return u.KeycloakID.Valid && u.KeycloakID.String == keycloakID })
+}
+
+// findUser returns a copy of the first user matching, with their roles
+func (s *FakeStore) findUser(match func(models.User) bool) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if match(user) {
+			user.Roles = s.rolesOf(user.ID)
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// UpdateUser replaces a user's stored fields
+func (s *FakeStore) UpdateUser(user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.users[user.ID]
+	if !ok {
+		return nil // UPDATE of a missing row affects nothing
+	}
+	stored := *user
+	stored.Roles, stored.CreatedAt, stored.UpdatedAt = nil, existing.CreatedAt, s.now()
+	s.users[user.ID] = stored
+	return nil
+}
+
+// DeleteUser marks a user deleted, keeping the row as the SQL repository does
+func (s *FakeStore) DeleteUser(id int, actorID sql.NullInt32, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if user.Status == models.UserStatusDeleted {
+		return models.ErrUserStatusConflict
+	}
+	user.Status, user.UpdatedAt = models.UserStatusDeleted, s.now()
+	s.users[id] = user
+	return nil
+}
+
+// GetUserRoles returns a user's roles ordered by name
+func (s *FakeStore) GetUserRoles(userID int) ([]models.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rolesOf(userID), nil
+}
+
+// rolesOf returns a user's roles ordered by name; the caller holds the lock
+func (s *FakeStore) rolesOf(userID int) []models.Role {
+	var roles []models.Role
+	for _, roleID := range s.userRoles[userID] {
+		roles = append(roles, s.roles[roleID])
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles
+}
+
+// AssignRole assigns a role to a user once
+func (s *FakeStore) AssignRole(userID, roleID int, assignedBy *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return fmt.Errorf("user %d does not exist", userID)
+	}
+	if _, ok := s.roles[roleID]; !ok {
+		return fmt.Errorf("role %d does not exist", roleID)
+	}
+	for _, existing := range s.userRoles[userID] {
+		if existing == roleID {
+			return nil
+		}
+	}
+	s.userRoles[userID] = append(s.userRoles[userID], roleID)
+	return nil
+}
+
+// RemoveRole removes a role from a user
+func (s *FakeStore) RemoveRole(userID, roleID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.userRoles[userID][:0]
+	for _, existing := range s.userRoles[userID] {
+		if existing != roleID {
+			kept = append(kept, existing)
+		}
+	}
+	s.userRoles[userID] = kept
+	return nil
+}
+
+// GetAllRoles returns every role ordered by name
+func (s *FakeStore) GetAllRoles() ([]models.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var roles []models.Role
+	for _, role := range s.roles {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+// GetRoleByName returns a role by its name
+func (s *FakeStore) GetRoleByName(name string) (*models.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, role := range s.roles {
+		if role.Name == name {
+			return &role, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// CreateProperty stores a property and sets its ID
+func (s *FakeStore) CreateProperty(property *models.Property) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	property.ID = s.id()
+	property.CreatedAt, property.UpdatedAt = s.now(), s.now()
+	s.properties[property.ID] = *property
+	return nil
+}
+
+// UpdateProperty replaces a property's name, address and type
+func (s *FakeStore) UpdateProperty(property *models.Property) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.properties[property.ID]
+	if !ok {
+		return nil
+	}
+	existing.Name, existing.Address, existing.PropertyType = property.Name, property.Address, property.PropertyType
+	existing.UpdatedAt = s.now()
+	s.properties[property.ID] = existing
+	return nil
+}
+
+// DeleteProperty deletes a property
+func (s *FakeStore) DeleteProperty(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.properties, id)
+	return nil
+}
+
+// GetProperties lists the properties in ID order. The store has no units or leases, so every
+// property is vacant.
+func (s *FakeStore) GetProperties() ([]models.PropertyDetail, error) {
+	return s.GetPropertiesByTags(nil)
+}
+
+// GetPropertiesByTags lists the properties having all of the tags, in ID order
+func (s *FakeStore) GetPropertiesByTags(tags []string) ([]models.PropertyDetail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var details []models.PropertyDetail
+	for _, property := range s.properties {
+		if !hasAllTags(property.Tags, tags) {
+			continue
+		}
+		details = append(details, models.PropertyDetail{ID: property.ID, Address: property.Address, Status: "vacant"})
+	}
+	sort.Slice(details, func(i, j int) bool { return details[i].ID < details[j].ID })
+	return details, nil
+}
+
+// hasAllTags reports whether have contains every tag in want
+func hasAllTags(have, want []string) bool {
+	for _, tag := range want {
+		found := false
+		for _, h := range have {
+			if h == tag {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateCustomReport stores a report definition and sets its ID
+func (s *FakeStore) CreateCustomReport(report *models.CustomReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report.ID = s.id()
+	report.CreatedAt, report.UpdatedAt = s.now(), s.now()
+	s.reports[report.ID] = *report
+	return nil
+}
+
+// GetCustomReports returns the user's own and public reports, most recently updated first
+func (s *FakeStore) GetCustomReports(userID int) ([]models.CustomReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reports []models.CustomReport
+	for _, report := range s.reports {
+		if report.CreatedBy == userID || report.IsPublic {
+			reports = append(reports, report)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if !reports[i].UpdatedAt.Equal(reports[j].UpdatedAt) {
+			return reports[i].UpdatedAt.After(reports[j].UpdatedAt)
+		}
+		return reports[i].ID > reports[j].ID
+	})
+	return reports, nil
+}
+
+// GetCustomReportByID returns a report definition
+func (s *FakeStore) GetCustomReportByID(id int) (*models.CustomReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report, ok := s.reports[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &report, nil
+}
+
+// GetReportTemplates returns the templates in the order they were added
+func (s *FakeStore) GetReportTemplates() ([]models.ReportTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.ReportTemplate(nil), s.templates...), nil
+}
+
+// GetKPIMetrics returns a category's metrics within the period, latest period first
+func (s *FakeStore) GetKPIMetrics(category string, startDate, endDate time.Time, propertyID *int) ([]models.KPIMetric, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var metrics []models.KPIMetric
+	for _, metric := range s.kpis {
+		if metric.Category != category || metric.PeriodStart.Before(startDate) || metric.PeriodEnd.After(endDate) {
+			continue
+		}
+		if propertyID != nil && (!metric.PropertyID.Valid || int(metric.PropertyID.Int32) != *propertyID) {
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].PeriodStart.After(metrics[j].PeriodStart) })
+	return metrics, nil
+}
+
+// CreateKPIMetric stores a metric and sets its ID
+func (s *FakeStore) CreateKPIMetric(metric *models.KPIMetric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metric.ID = s.id()
+	metric.CreatedAt = s.now()
+	s.kpis[metric.ID] = *metric
+	return nil
+}
+
+// Compile-time checks that the store implements every repository
+var (
+	_ models.UserRepository     = (*FakeStore)(nil)
+	_ models.PropertyRepository = (*FakeStore)(nil)
+	_ models.ReportRepository   = (*FakeStore)(nil)
+	_ models.KPIRepository      = (*FakeStore)(nil)
+)
@@ -0,0 +1,70 @@
+package testutils
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeStoreUsers(t *testing.T) {
+	store := UseFakeStore(t)
+	admin := store.AddUser("alice", "admin", "property_manager")
+	assert.True(t, admin.HasRole("admin"))
+
+	// The models package functions use the fake
+	user, err := models.GetUserByUsername("alice")
+	require.NoError(t, err)
+	assert.Equal(t, admin.ID, user.ID)
+	assert.Equal(t, []string{"admin", "property_manager"}, []string{user.Roles[0].Name, user.Roles[1].Name})
+
+	role, err := models.GetRoleByName("admin")
+	require.NoError(t, err)
+	require.NoError(t, models.RemoveRole(user.ID, role.ID))
+	roles, _ := models.GetUserRoles(user.ID)
+	assert.Len(t, roles, 1)
+
+	assert.Error(t, models.CreateUser(&models.User{Username: "alice", Email: "other@example.com"}), "usernames are unique")
+
+	require.NoError(t, models.DeleteUser(user.ID, sql.NullInt32{}, ""))
+	user, err = models.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusDeleted, user.Status)
+	assert.Equal(t, models.ErrUserStatusConflict, models.DeleteUser(user.ID, sql.NullInt32{}, ""))
+}
+
+func TestFakeStoreReportsAndProperties(t *testing.T) {
+	UseFakeStore(t)
+
+	own := &models.CustomReport{Name: "Mine", ReportType: "property", CreatedBy: 1}
+	shared := &models.CustomReport{Name: "Shared", ReportType: "financial", CreatedBy: 2, IsPublic: true}
+	private := &models.CustomReport{Name: "Private", ReportType: "tenant", CreatedBy: 2}
+	for _, report := range []*models.CustomReport{own, shared, private} {
+		require.NoError(t, models.CreateCustomReport(report))
+	}
+	reports, err := models.GetCustomReports(1)
+	require.NoError(t, err)
+	assert.Len(t, reports, 2, "own and public reports")
+	_, err = models.GetCustomReportByID(999)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	require.NoError(t, models.CreateProperty(&models.Property{Name: "Maple", Address: "1 Maple St", Tags: models.StringArray{"downtown", "pets"}}))
+	require.NoError(t, models.CreateProperty(&models.Property{Name: "Oak", Address: "2 Oak St", Tags: models.StringArray{"downtown"}}))
+	all, _ := models.GetProperties()
+	assert.Len(t, all, 2)
+	tagged, _ := models.GetPropertiesByTags([]string{"downtown", "pets"})
+	require.Len(t, tagged, 1)
+	assert.Equal(t, "1 Maple St", tagged[0].Address)
+
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, models.CreateKPIMetric(&models.KPIMetric{MetricName: "occupancy", Category: "operational",
+		PeriodStart: january, PeriodEnd: january.AddDate(0, 1, -1), PropertyID: sql.NullInt32{Int32: 5, Valid: true}}))
+	propertyID := 5
+	metrics, _ := models.GetKPIMetrics("operational", january, january.AddDate(1, 0, 0), &propertyID)
+	assert.Len(t, metrics, 1)
+	metrics, _ = models.GetKPIMetrics("financial", january, january.AddDate(1, 0, 0), nil)
+	assert.Empty(t, metrics)
+}
@@ -0,0 +1,40 @@
+package turnover
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Tracker opens unit turnovers as leases end and closes them when the next lease starts. A sync
+// is idempotent, so a missed run is caught up by the next one.
+type Tracker struct {
+	Interval time.Duration
+}
+
+// NewTracker creates a tracker that syncs hourly
+func NewTracker() *Tracker {
+	return &Tracker{Interval: time.Hour}
+}
+
+// Run syncs turnovers every Interval until the context is cancelled
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		if result, err := models.SyncUnitTurnovers(time.Now()); err != nil {
+			slog.Error("Unit turnover sync failed", "error", err)
+		} else if result.Opened+result.Closed > 0 {
+			slog.Info("Synced unit turnovers", "opened", result.Opened, "closed", result.Closed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+)
+
+// Detector periodically checks new meter readings against each unit's rolling baseline.
+// Abnormal consumption opens a high-priority maintenance request and notifies managers
+// through the outbox at UTILITY_ALERT_WEBHOOK_URL when it is set. Later abnormal readings
+// for the same unit and utility are attached to the request while it remains open.
+type Detector struct {
+	Interval   time.Duration
+	BatchSize  int
+	WebhookURL string
+}
+
+// NewDetector creates a detector configured from the environment
+func NewDetector() *Detector {
+	return &Detector{
+		Interval:   15 * time.Minute,
+		BatchSize:  500,
+		WebhookURL: os.Getenv("UTILITY_ALERT_WEBHOOK_URL"),
+	}
+}
+
+// Run evaluates new readings every Interval until the context is cancelled
+func (d *Detector) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		if flagged, err := d.DetectOnce(); err != nil {
+			slog.Error("Utility anomaly detection failed", "error", err)
+		} else if flagged > 0 {
+			slog.Info("Flagged abnormal utility readings", "count", flagged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DetectOnce evaluates a batch of unchecked readings and returns the number flagged as anomalies
+func (d *Detector) DetectOnce() (int, error) {
+	readings, err := models.GetUnevaluatedMeterReadings(d.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	flagged := 0
+	for _, reading := range readings {
+		history, err := models.GetUsageHistory(reading, models.UsageBaselineWindow)
+		if err != nil {
+			return flagged, err
+		}
+
+		baseline, anomalous := models.DetectUsageAnomaly(history, reading.Consumption)
+		if !anomalous {
+			if err := models.MarkMeterReadingEvaluated(db.DB, reading.ID); err != nil {
+				return flagged, err
+			}
+			continue
+		}
+
+		if err := d.flag(reading, baseline); err != nil {
+			return flagged, fmt.Errorf("failed to flag reading %d: %w", reading.ID, err)
+		}
+		flagged++
+	}
+
+	return flagged, nil
+}
+
+// AnomalyEventType is the webhook event type of a new utility anomaly
+const AnomalyEventType = "utility.anomaly"
+
+// AnomalyPayload builds the webhook payload of a new utility anomaly. Its shape is published
+// as the utility.anomaly schema in pkg/webhooks. The maintenance request ID is null when no
+// request was opened.
+func AnomalyPayload(anomaly *models.UtilityAnomaly) map[string]interface{} {
+	var maintenanceRequestID interface{}
+	if anomaly.MaintenanceRequestID.Valid {
+		maintenanceRequestID = anomaly.MaintenanceRequestID.Int32
+	}
+	return map[string]interface{}{
+		"anomaly": map[string]interface{}{
+			"id":                     anomaly.ID,
+			"reading_id":             anomaly.ReadingID,
+			"unit_id":                anomaly.UnitID,
+			"unit_number":            anomaly.UnitNumber,
+			"property_id":            anomaly.PropertyID,
+			"property_name":          anomaly.PropertyName,
+			"utility_type":           anomaly.UtilityType,
+			"reading_date":           anomaly.ReadingDate,
+			"consumption":            anomaly.Consumption,
+			"baseline_mean":          anomaly.BaselineMean,
+			"baseline_stddev":        anomaly.BaselineStdDev,
+			"z_score":                anomaly.ZScore,
+			"maintenance_request_id": maintenanceRequestID,
+			"detected_at":            anomaly.DetectedAt,
+		},
+	}
+}
+
+// flag records the anomaly, its maintenance request and the notification in one transaction
+func (d *Detector) flag(reading models.MeterReading, baseline models.UsageBaseline) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	anomaly, opened, err := models.RecordUtilityAnomaly(tx, reading, baseline)
+	if err != nil {
+		return err
+	}
+	if err := models.MarkMeterReadingEvaluated(tx, reading.ID); err != nil {
+		return err
+	}
+
+	// Only notify when a new problem is opened, not for every day it continues
+	if !opened {
+		return tx.Commit()
+	}
+
+	slog.Warn("Abnormal utility usage", "utility", anomaly.UtilityType, "property", anomaly.PropertyName,
+		"unit", anomaly.UnitNumber, "consumption", anomaly.Consumption, "baseline", anomaly.BaselineMean,
+		"z_score", anomaly.ZScore, "maintenance_request_id", anomaly.MaintenanceRequestID.Int32)
+
+	if d.WebhookURL != "" {
+		err := models.EnqueueOutboxMessage(tx, &models.OutboxMessage{
+			Channel:     "webhook",
+			EventType:   AnomalyEventType,
+			Destination: d.WebhookURL,
+			Payload:     AnomalyPayload(anomaly),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,78 @@
+// Package webhooks publishes the JSON Schemas of the webhook payloads delivered through the
+// outbox, and validates payloads against them so emitters can be contract tested.
+package webhooks
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// eventSchemas maps each webhook event type to its schema file. Events sharing a payload
+// shape share a file.
+var eventSchemas = map[string]string{
+	"utility.anomaly":          "schemas/utility.anomaly.json",
+	"maintenance.sla_at_risk":  "schemas/maintenance.sla.json",
+	"maintenance.sla_breached": "schemas/maintenance.sla.json",
+}
+
+// ErrUnknownEvent is returned for an event type without a schema
+var ErrUnknownEvent = errors.New("unknown webhook event type")
+
+// EventTypes returns the event types with a published schema in sorted order
+func EventTypes() []string {
+	types := make([]string, 0, len(eventSchemas))
+	for eventType := range eventSchemas {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Schema returns the JSON Schema document of an event type's payload
+func Schema(eventType string) (json.RawMessage, error) {
+	name, ok := eventSchemas[eventType]
+	if !ok {
+		return nil, ErrUnknownEvent
+	}
+	return schemaFiles.ReadFile(name)
+}
+
+// Validate checks that a payload, as it would be marshalled for delivery, matches the schema
+// of its event type. Every violation is reported.
+func Validate(eventType string, payload interface{}) error {
+	raw, err := Schema(eventType)
+	if err != nil {
+		return err
+	}
+	var schema map[string]interface{}
+	if err := decodeJSON(raw, &schema); err != nil {
+		return fmt.Errorf("invalid schema for %s: %w", eventType, err)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	if err := decodeJSON(body, &value); err != nil {
+		return err
+	}
+
+	var problems []error
+	validateValue(schema, schema, value, "payload", &problems)
+	return errors.Join(problems...)
+}
+
+// decodeJSON decodes with numbers kept as json.Number so integers can be told from floats
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
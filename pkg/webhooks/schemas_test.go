@@ -0,0 +1,130 @@
+package webhooks
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/greenbrown932/fire-pmaas/pkg/sla"
+	"github.com/greenbrown932/fire-pmaas/pkg/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemasAreValidJSON(t *testing.T) {
+	for _, eventType := range EventTypes() {
+		raw, err := Schema(eventType)
+		require.NoError(t, err, eventType)
+
+		var schema map[string]interface{}
+		require.NoError(t, json.Unmarshal(raw, &schema), eventType)
+		assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"], eventType)
+		assert.Equal(t, "object", schema["type"], eventType)
+	}
+
+	_, err := Schema("no.such_event")
+	assert.Equal(t, ErrUnknownEvent, err)
+}
+
+// Every event type the emitters send must have a published schema
+func TestEmittedEventTypesHaveSchemas(t *testing.T) {
+	emitted := []string{
+		usage.AnomalyEventType,
+		sla.AlertEventType(models.SLAStatusAtRisk),
+		sla.AlertEventType(models.SLAStatusBreached),
+	}
+	assert.ElementsMatch(t, emitted, EventTypes())
+}
+
+func TestUtilityAnomalyPayloadMatchesSchema(t *testing.T) {
+	detected := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	anomaly := &models.UtilityAnomaly{
+		ID:                   7,
+		ReadingID:            42,
+		UnitID:               3,
+		UnitNumber:           "2B",
+		PropertyID:           1,
+		PropertyName:         "Maple Court",
+		UtilityType:          "water",
+		ReadingDate:          time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC),
+		Consumption:          812.5,
+		BaselineMean:         240,
+		BaselineStdDev:       31.25,
+		ZScore:               18.32,
+		MaintenanceRequestID: sql.NullInt32{Int32: 91, Valid: true},
+		DetectedAt:           detected,
+	}
+	assert.NoError(t, Validate(usage.AnomalyEventType, usage.AnomalyPayload(anomaly)))
+
+	raw, err := json.Marshal(usage.AnomalyPayload(anomaly))
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"maintenance_request_id":91`)
+
+	anomaly.MaintenanceRequestID = sql.NullInt32{}
+	assert.NoError(t, Validate(usage.AnomalyEventType, usage.AnomalyPayload(anomaly)))
+
+	anomaly.UtilityType = "steam"
+	err = Validate(usage.AnomalyEventType, usage.AnomalyPayload(anomaly))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "payload.anomaly.utility_type")
+}
+
+func TestSLAAlertPayloadMatchesSchema(t *testing.T) {
+	created := time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)
+	record := models.MaintenanceSLARecord{
+		ID:           12,
+		PropertyID:   1,
+		PropertyName: "Maple Court",
+		Description:  "No heat in unit 4A",
+		Status:       "open",
+		Priority:     "emergency",
+		CreatedAt:    created,
+		SLA: &models.SLAStatus{
+			ResponseDueAt:    created.Add(time.Hour),
+			ResolutionDueAt:  created.Add(24 * time.Hour),
+			ResponseStatus:   models.SLAStatusBreached,
+			ResolutionStatus: models.SLAStatusAtRisk,
+			Status:           models.SLAStatusBreached,
+		},
+	}
+	for _, level := range []string{models.SLAStatusAtRisk, models.SLAStatusBreached} {
+		assert.NoError(t, Validate(sla.AlertEventType(level), sla.AlertPayload(record)), level)
+	}
+
+	record.SLA = nil
+	err := Validate(sla.AlertEventType(models.SLAStatusBreached), sla.AlertPayload(record))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "payload.sla: expected object, got null")
+}
+
+func TestValidateReportsEveryViolation(t *testing.T) {
+	payload := map[string]interface{}{
+		"maintenance_request_id": 1.5,
+		"property_id":            1,
+		"property_name":          "Maple Court",
+		"description":            "Leak",
+		"priority":               "high",
+		"sla": map[string]interface{}{
+			"response_due_at":   "tomorrow",
+			"resolution_due_at": "2026-03-05T09:00:00Z",
+			"response_status":   "late",
+			"resolution_status": "on_track",
+			"status":            "on_track",
+		},
+		"extra": true,
+	}
+
+	err := Validate("maintenance.sla_at_risk", payload)
+	require.Error(t, err)
+	for _, problem := range []string{
+		"payload.maintenance_request_id: expected integer, got number",
+		`payload: missing required property "status"`,
+		`payload.sla.response_due_at: "tomorrow" is not an RFC 3339 date-time`,
+		"payload.sla.response_status: late is not one of",
+		"payload.extra: unexpected property",
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+}
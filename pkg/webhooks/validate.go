@@ -0,0 +1,163 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// validateValue checks a decoded JSON value against a schema. It supports the subset of JSON
+// Schema used by the published schemas: $ref to $defs, type, enum, format date-time, required,
+// properties, additionalProperties and items.
+func validateValue(root, schema map[string]interface{}, value interface{}, path string, problems *[]error) {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := resolveRef(root, ref)
+		if err != nil {
+			*problems = append(*problems, fmt.Errorf("%s: %w", path, err))
+			return
+		}
+		schema = resolved
+	}
+
+	if types := schemaTypes(schema); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if typeMatches(t, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			*problems = append(*problems, fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonType(value)))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*problems = append(*problems, fmt.Errorf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+
+	if format, _ := schema["format"].(string); format == "date-time" {
+		if s, ok := value.(string); ok {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				*problems = append(*problems, fmt.Errorf("%s: %q is not an RFC 3339 date-time", path, s))
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(root, schema, v, path, problems)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(root, items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+// validateObject checks required, declared and undeclared properties of an object
+func validateObject(root, schema, object map[string]interface{}, path string, problems *[]error) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, exists := object[name.(string)]; !exists {
+				*problems = append(*problems, fmt.Errorf("%s: missing required property %q", path, name))
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyPath := path + "." + name
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			validateValue(root, property, object[name], propertyPath, problems)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*problems = append(*problems, fmt.Errorf("%s: unexpected property", propertyPath))
+			}
+		case map[string]interface{}:
+			validateValue(root, additional, object[name], propertyPath, problems)
+		}
+	}
+}
+
+// resolveRef looks up a local reference of the form #/$defs/name
+func resolveRef(root map[string]interface{}, ref string) (map[string]interface{}, error) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	defs, _ := root["$defs"].(map[string]interface{})
+	schema, ok := defs[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("undefined $ref %q", ref)
+	}
+	return schema, nil
+}
+
+// schemaTypes returns the allowed types of a schema, which may be a single type or a list
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// typeMatches reports whether a decoded value is of a JSON Schema type
+func typeMatches(schemaType string, value interface{}) bool {
+	return jsonType(value) == schemaType || (schemaType == "number" && jsonType(value) == "integer")
+}
+
+// jsonType names the JSON Schema type of a decoded value, telling integers from other numbers
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
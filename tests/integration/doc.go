@@ -0,0 +1,8 @@
+// Package integration runs the models package against a real PostgreSQL database, covering the
+// JSONB, array and date truncation behaviour that sqlmock cannot verify. The suite is opt-in:
+//
+//	go test -tags integration ./tests/integration/...
+//
+// It starts a disposable postgres container with the docker CLI and applies db/migrations,
+// or uses the already migrated database at INTEGRATION_DATABASE_URL when that is set.
+package integration
@@ -0,0 +1,151 @@
+//go:build integration
+
+package integration
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+	"github.com/greenbrown932/fire-pmaas/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createAdmin stores a user holding the admin role seeded by the migrations
+func createAdmin(t *testing.T) *models.User {
+	t.Helper()
+	user := &models.User{Username: "admin", Email: "admin@example.com", FirstName: "Ada", LastName: "Admin", Status: "active"}
+	require.NoError(t, models.CreateUser(user))
+	role, err := models.GetRoleByName("admin")
+	require.NoError(t, err)
+	require.NoError(t, models.AssignRole(user.ID, role.ID, nil))
+
+	user, err = models.GetUserByID(user.ID)
+	require.NoError(t, err)
+	return user
+}
+
+func TestRolePermissionsArray(t *testing.T) {
+	truncate(t, "users")
+	admin := createAdmin(t)
+
+	require.Len(t, admin.Roles, 1)
+	assert.NotEmpty(t, admin.Roles[0].Permissions, "permissions scan from a text[] column")
+	assert.True(t, admin.HasRole("admin"))
+}
+
+func TestCustomReportJSONBRoundTrip(t *testing.T) {
+	truncate(t, "users", "custom_reports")
+	admin := createAdmin(t)
+
+	report := &models.CustomReport{
+		Name:        "Delinquency by region",
+		Description: sql.NullString{String: "Nested criteria", Valid: true},
+		ReportType:  "tenant",
+		CreatedBy:   admin.ID,
+		Criteria: map[string]interface{}{
+			"property_ids":     []interface{}{float64(3), float64(7)},
+			"computed_columns": []interface{}{map[string]interface{}{"name": "Double", "expression": "Balance * 2"}},
+			"min_balance":      12.5,
+		},
+		Columns:     models.StringArray{"Tenant", "Balance"},
+		ChartConfig: map[string]interface{}{"type": "bar", "stacked": true},
+	}
+	require.NoError(t, models.CreateCustomReport(report))
+
+	stored, err := models.GetCustomReportByID(report.ID)
+	require.NoError(t, err)
+	assert.Equal(t, report.Criteria, stored.Criteria)
+	assert.Equal(t, report.ChartConfig, stored.ChartConfig)
+	assert.Equal(t, report.Columns, stored.Columns)
+	assert.Equal(t, "Nested criteria", stored.Description.String)
+
+	reports, err := models.GetCustomReports(admin.ID)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, report.Criteria, reports[0].Criteria)
+}
+
+func TestPropertyTagsArray(t *testing.T) {
+	truncate(t, "properties")
+	for _, p := range []struct {
+		name string
+		tags []string
+	}{
+		{"Maple", []string{"downtown", "pets"}},
+		{"Oak", []string{"downtown"}},
+		{"Elm", nil},
+	} {
+		require.NoError(t, models.CreateProperty(&models.Property{Name: p.name, Address: p.name + " St", PropertyType: "Apartment Building"}))
+		tags, err := db.CurrentDialect.ArrayValue(p.tags)
+		require.NoError(t, err)
+		_, err = db.DB.Exec("UPDATE properties SET tags = $1 WHERE name = $2", tags, p.name)
+		require.NoError(t, err)
+	}
+
+	matching, err := models.GetPropertiesByTags([]string{"pets", "downtown"})
+	require.NoError(t, err)
+	require.Len(t, matching, 1, "every tag must match")
+	assert.Equal(t, "Maple St", matching[0].Address)
+
+	downtown, err := models.GetPropertiesByTags([]string{"downtown"})
+	require.NoError(t, err)
+	assert.Len(t, downtown, 2)
+}
+
+func TestFinancialReportMonthTruncation(t *testing.T) {
+	truncate(t, "users", "custom_reports", "properties", "tenants", "payments")
+	admin := createAdmin(t)
+
+	var propertyID, unitID, tenantID, leaseID int
+	require.NoError(t, db.DB.QueryRow(`INSERT INTO properties (name, address, property_type)
+		VALUES ('Maple', '1 Maple St', 'Apartment Building') RETURNING id`).Scan(&propertyID))
+	require.NoError(t, db.DB.QueryRow(`INSERT INTO property_units (property_id, unit_number)
+		VALUES ($1, '101') RETURNING id`, propertyID).Scan(&unitID))
+	require.NoError(t, db.DB.QueryRow(`INSERT INTO tenants (first_name, last_name, email)
+		VALUES ('Tia', 'Tenant', 'tia@example.com') RETURNING id`).Scan(&tenantID))
+	require.NoError(t, db.DB.QueryRow(`INSERT INTO leases (unit_id, tenant_id, start_date, end_date, monthly_rent)
+		VALUES ($1, $2, '2023-12-01', '2024-11-30', 1000) RETURNING id`, unitID, tenantID).Scan(&leaseID))
+	for _, payment := range []struct {
+		date   string
+		amount float64
+	}{{"2024-01-01", 1000}, {"2024-01-31", 50}, {"2024-02-01", 1000}} {
+		_, err := db.DB.Exec(`INSERT INTO payments (lease_id, amount, payment_date) VALUES ($1, $2, $3)`,
+			leaseID, payment.amount, payment.date)
+		require.NoError(t, err)
+	}
+
+	report := &models.CustomReport{Name: "Monthly income", ReportType: "financial", CreatedBy: admin.ID,
+		Criteria: map[string]interface{}{}}
+	require.NoError(t, models.CreateCustomReport(report))
+
+	data, err := models.ExecuteReport(report.ID, map[string]interface{}{"start_date": "2024-01-01", "end_date": "2024-02-29"}, admin)
+	require.NoError(t, err)
+	require.Len(t, data.Rows, 2, "payments group by calendar month, including month boundaries")
+	assert.Equal(t, "2024-01", data.Rows[0]["Month"])
+	assert.Equal(t, 2, data.Rows[0]["Payment Count"])
+	assert.Equal(t, 1050.0, data.Rows[0]["Total Amount"])
+	assert.Equal(t, "2024-02", data.Rows[1]["Month"])
+
+	var executions int
+	require.NoError(t, db.DB.QueryRow("SELECT COUNT(*) FROM report_executions WHERE report_id = $1", report.ID).Scan(&executions))
+	assert.Equal(t, 1, executions, "the execution and its JSONB snapshot are recorded")
+}
+
+func TestKPIMetricsPeriodFilter(t *testing.T) {
+	truncate(t, "kpi_metrics")
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for month := 0; month < 3; month++ {
+		start := january.AddDate(0, month, 0)
+		require.NoError(t, models.CreateKPIMetric(&models.KPIMetric{MetricName: "occupancy_rate", MetricValue: 90 + float64(month),
+			Category: "operational", PeriodStart: start, PeriodEnd: start.AddDate(0, 1, -1)}))
+	}
+
+	metrics, err := models.GetKPIMetrics("operational", january, january.AddDate(0, 2, -1), nil)
+	require.NoError(t, err)
+	require.Len(t, metrics, 2, "periods must fall inside the range")
+	assert.Equal(t, 91.0, metrics[0].MetricValue, "latest period first")
+	assert.True(t, metrics[0].PeriodStart.Equal(january.AddDate(0, 1, 0)), "DATE columns scan as midnight")
+}
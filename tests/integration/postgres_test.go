@@ -0,0 +1,144 @@
+//go:build integration
+
+package integration
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/greenbrown932/fire-pmaas/pkg/db"
+)
+
+// defaultPostgresImage is the image started when INTEGRATION_POSTGRES_IMAGE is not set
+const defaultPostgresImage = "postgres:16-alpine"
+
+// postgresContainer is a disposable database; id is empty for an external INTEGRATION_DATABASE_URL
+type postgresContainer struct {
+	id  string
+	url string
+}
+
+func TestMain(m *testing.M) {
+	container, err := startPostgres()
+	if err != nil {
+		log.Fatalf("integration: %v", err)
+	}
+
+	conn, err := openAndMigrate(container.url, container.id != "")
+	if err != nil {
+		container.Close()
+		log.Fatalf("integration: %v", err)
+	}
+	db.DB = conn
+
+	code := m.Run()
+	conn.Close()
+	container.Close()
+	os.Exit(code)
+}
+
+// startPostgres runs a postgres container published on a random local port
+func startPostgres() (*postgresContainer, error) {
+	if url := os.Getenv("INTEGRATION_DATABASE_URL"); url != "" {
+		return &postgresContainer{url: url}, nil
+	}
+
+	image := os.Getenv("INTEGRATION_POSTGRES_IMAGE")
+	if image == "" {
+		image = defaultPostgresImage
+	}
+	out, err := exec.Command("docker", "run", "-d", "--rm",
+		"-e", "POSTGRES_USER=pmaas", "-e", "POSTGRES_PASSWORD=pmaas", "-e", "POSTGRES_DB=pmaas_test",
+		"-p", "127.0.0.1::5432", image).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s (is docker running?): %w", image, commandError(err))
+	}
+	container := &postgresContainer{id: strings.TrimSpace(string(out))}
+
+	out, err = exec.Command("docker", "port", container.id, "5432/tcp").Output()
+	if err != nil {
+		container.Close()
+		return nil, fmt.Errorf("failed to find the postgres port: %w", commandError(err))
+	}
+	hostPort := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	container.url = fmt.Sprintf("postgres://pmaas:pmaas@%s/pmaas_test?sslmode=disable", hostPort)
+	return container, nil
+}
+
+// Close removes the container
+func (c *postgresContainer) Close() {
+	if c.id != "" {
+		exec.Command("docker", "rm", "-f", c.id).Run()
+	}
+}
+
+// openAndMigrate waits for the database to accept connections and, for containers, applies
+// every migration
+func openAndMigrate(url string, migrateUp bool) (*sql.DB, error) {
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(60 * time.Second)
+	for {
+		if err = conn.Ping(); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("database did not become ready: %w", err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	if !migrateUp {
+		return conn, nil
+	}
+
+	m, err := migrate.New("file://"+migrationsDir(), url)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		conn.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return conn, nil
+}
+
+// migrationsDir is the repository's PostgreSQL migrations directory
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "db", "migrations")
+}
+
+// commandError includes a failed command's stderr in its error
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+// truncate empties tables between tests, resetting their sequences
+func truncate(t *testing.T, tables ...string) {
+	t.Helper()
+	if _, err := db.DB.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE"); err != nil {
+		t.Fatalf("failed to truncate %v: %v", tables, err)
+	}
+}